@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/backup"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/dbmigrate"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+)
+
+const cliUsage = `用法：
+  tsdd [-config configs/tsdd.yaml] migrate up [-n 0]      执行未执行的数据库迁移 -n为最多执行的数量 0为全部
+  tsdd [-config configs/tsdd.yaml] migrate down [-n 1]    按执行的倒序回滚迁移 迁移文件需要有Down语句
+  tsdd [-config configs/tsdd.yaml] migrate status         查看迁移的状态
+  tsdd [-config configs/tsdd.yaml] seed [demo]            写入初始数据 可以重复执行
+  tsdd [-config configs/tsdd.yaml] create-admin -username admin -password xxx [-name 管理员] [-role admin]
+  tsdd [-config configs/tsdd.yaml] backup [-o tsdd-backup.zip]     备份数据库、redis中的同步版本号和文件清单
+  tsdd [-config configs/tsdd.yaml] restore -i tsdd-backup.zip -verify-only     只校验备份文件
+  tsdd [-config configs/tsdd.yaml] restore -i tsdd-backup.zip -force [-skip-redis]     校验后恢复 会删除并重建备份中的表
+`
+
+// runCommand 执行运维命令 不是运维命令时返回false
+func runCommand(ctx *config.Context, args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = runMigrate(ctx, args[1:])
+	case "seed":
+		err = runSeed(ctx, args[1:])
+	case "create-admin":
+		err = runCreateAdmin(ctx, args[1:])
+	case "backup":
+		err = runBackup(ctx, args[1:])
+	case "restore":
+		err = runRestore(ctx, args[1:])
+	case "help":
+		fmt.Print(cliUsage)
+	default:
+		return false
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return true
+}
+
+func runMigrate(ctx *config.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少migrate的操作\n%s", cliUsage)
+	}
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	n := fs.Int("n", 0, "数量")
+	if args[0] == "down" {
+		*n = 1
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	db := ctx.DB().DB
+	source := dbmigrate.NewSource(register.GetModules(ctx))
+	switch args[0] {
+	case "up":
+		applied, err := dbmigrate.Up(db, source, *n)
+		if err != nil {
+			return fmt.Errorf("执行迁移失败（已执行%d个）：%w", applied, err)
+		}
+		fmt.Printf("已执行%d个迁移\n", applied)
+	case "down":
+		rolledBack, err := dbmigrate.Down(db, source, *n)
+		if err != nil {
+			return fmt.Errorf("回滚迁移失败（已回滚%d个）：%w", rolledBack, err)
+		}
+		fmt.Printf("已回滚%d个迁移\n", rolledBack)
+	case "status":
+		statuses, err := dbmigrate.Statuses(db, source)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "迁移\t状态\t执行时间\t可回滚")
+		pending := 0
+		for _, s := range statuses {
+			status, appliedAt, rollback := "未执行", "", "否"
+			if s.Applied {
+				status = "已执行"
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			} else {
+				pending++
+			}
+			if s.Unknown {
+				status = "没有迁移文件"
+			}
+			if s.Rollback {
+				rollback = "是"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.ID, status, appliedAt, rollback)
+		}
+		w.Flush()
+		fmt.Printf("共%d个迁移，%d个未执行\n", len(statuses), pending)
+	default:
+		return fmt.Errorf("不支持的migrate操作：%s\n%s", args[0], cliUsage)
+	}
+	return nil
+}
+
+func runSeed(ctx *config.Context, args []string) error {
+	name := "demo"
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if err := dbmigrate.Seed(ctx.DB().DB, name); err != nil {
+		return err
+	}
+	fmt.Printf("已写入初始数据%s\n", name)
+	return nil
+}
+
+func runCreateAdmin(ctx *config.Context, args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := fs.String("username", "", "登录用户名")
+	name := fs.String("name", "", "名字 为空时使用登录用户名")
+	password := fs.String("password", "", "密码")
+	role := fs.String("role", "", "角色 superAdmin、admin、support、moderator、auditor 为空时为admin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	uid, err := user.CreateAdmin(ctx, *username, *name, *password, *role)
+	if err != nil {
+		return fmt.Errorf("创建管理员失败：%w", err)
+	}
+	fmt.Printf("已创建管理员 %s（uid：%s）\n", *username, uid)
+	return nil
+}
+
+func runBackup(ctx *config.Context, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := fs.String("o", fmt.Sprintf("tsdd-backup-%s.zip", time.Now().Format("20060102150405")), "备份文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(*output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	m, err := backup.Run(context.Background(), ctx, f)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(*output)
+		return fmt.Errorf("备份失败：%w", err)
+	}
+	fmt.Printf("已备份到%s：%d个表", *output, len(m.Tables))
+	if m.Redis != nil {
+		fmt.Printf("，%d个redis key", m.Redis.Count)
+	}
+	fmt.Printf("，%d个文件\n", m.Files.Count)
+	for _, note := range m.Notes {
+		fmt.Println("注意：" + note)
+	}
+	return nil
+}
+
+func runRestore(ctx *config.Context, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	input := fs.String("i", "", "备份文件")
+	verifyOnly := fs.Bool("verify-only", false, "只校验备份文件")
+	force := fs.Bool("force", false, "确认恢复 会删除并重建备份中的表")
+	skipRedis := fs.Bool("skip-redis", false, "不恢复redis")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *input == "" {
+		return fmt.Errorf("缺少备份文件\n%s", cliUsage)
+	}
+	f, err := os.Open(*input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	m, err := backup.Verify(f, info.Size())
+	if err != nil {
+		return fmt.Errorf("校验备份文件失败：%w", err)
+	}
+	fmt.Printf("备份文件校验通过：版本%s，备份时间%s，%d个表\n", m.Version, time.Unix(m.CreatedAt, 0).Format("2006-01-02 15:04:05"), len(m.Tables))
+	if *verifyOnly {
+		return nil
+	}
+	if !*force {
+		return fmt.Errorf("恢复会删除并重建备份中的表，确认后加上-force执行")
+	}
+	if m.Version != ctx.GetConfig().Version {
+		fmt.Printf("注意：备份的版本%s与当前版本%s不一致，恢复后需要执行 migrate up\n", m.Version, ctx.GetConfig().Version)
+	}
+	if _, err = backup.RunRestore(context.Background(), ctx, f, info.Size(), *skipRedis); err != nil {
+		return fmt.Errorf("恢复失败：%w", err)
+	}
+	fmt.Println("恢复完成")
+	return nil
+}
@@ -2,16 +2,27 @@ package modules
 
 // 引入模块
 import (
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/apikey"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/broadcast"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/channel"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/common"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/compliance"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/digest"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/eventhook"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/feature"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/ipguard"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/job"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/notice"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/openapi"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/qrcode"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/report"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/robot"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/sensitive"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/statistics"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/webhook"
@@ -1,23 +1,46 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"runtime"
 	"strings"
+	"syscall"
 
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/internal"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/apikey"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/apidoc"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/postgres"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/replica"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/dbmigrate"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/eventbus"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/imbreaker"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/jobqueue"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/metrics"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/profiling"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rpcapi"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/shutdown"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/module"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
-	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/server"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
 	"github.com/gin-gonic/gin"
+	rd "github.com/go-redis/redis"
 	"github.com/judwhite/go-svc"
 	"github.com/robfig/cron"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 )
 
 // go ldflags
@@ -49,6 +72,7 @@ func main() {
 	cfg := config.New()
 	cfg.Version = Version
 	cfg.ConfigureWithViper(vp)
+	extconfig.Configure(vp)
 
 	// 初始化context
 	ctx := config.NewContext(cfg)
@@ -60,6 +84,19 @@ func main() {
 	logOpts.LogDir = cfg.Logger.Dir
 	log.Configure(logOpts)
 
+	// 使用PostgreSQL时替换数据库连接 需要放在运维命令的前面
+	if err := postgres.Setup(ctx); err != nil {
+		panic(err)
+	}
+
+	// 运维命令 例如 migrate up、seed demo、create-admin
+	if runCommand(ctx, flag.Args()) {
+		return
+	}
+
+	// 配置热加载
+	setupConfigReload(CfgFile)
+
 	var serverType string
 	if len(os.Args) > 1 {
 		serverType = strings.TrimSpace(os.Args[1])
@@ -74,12 +111,14 @@ func main() {
 
 func runAPI(ctx *config.Context) {
 	// 创建server
-	s := server.New(ctx)
+	s := newAPIServer(ctx)
 	ctx.SetHttpRoute(s.GetRoute())
 	// 替换web下的配置文件
 	replaceWebConfig(ctx.GetConfig())
 	// 初始化api
-	s.GetRoute().UseGin(ctx.Tracer().GinMiddle()) // 需要放在 api.Route(s.GetRoute())的前面
+	s.GetRoute().UseGin(ctx.Tracer().GinMiddle())   // 需要放在 api.Route(s.GetRoute())的前面
+	s.GetRoute().UseGin(metrics.HTTPMiddleware())   // 接口的请求次数和耗时
+	s.GetRoute().UseGin(profiling.HTTPMiddleware()) // 慢接口统计
 	s.GetRoute().UseGin(func(c *gin.Context) {
 		ingorePaths := ingorePaths()
 		for _, ingorePath := range ingorePaths {
@@ -89,11 +128,49 @@ func runAPI(ctx *config.Context) {
 		}
 		gin.Logger()(c)
 	})
+	s.GetRoute().UseGin(errcode.Middleware())           // 错误的返回加上错误码并按Accept-Language翻译 需要放在模块安装的前面
+	s.GetRoute().UseGin(audit.Middleware(ctx))          // 记录管理后台的操作 需要放在模块安装的前面
+	s.GetRoute().UseGin(user.ImpersonationMiddleware()) // 模拟登录的会话只读 需要放在模块安装的前面
+	s.GetRoute().UseGin(apikey.Middleware(ctx))         // 验证管理后台的API密钥 需要放在模块安装的前面
+	s.GetRoute().UseGin(tenant.Middleware(ctx))         // 确定请求的组织 需要放在API密钥验证之后、模块安装的前面
+	// 从库
+	err := setupReplicas(ctx)
+	if err != nil {
+		panic(err)
+	}
+	// 慢查询统计和pprof 需要放在模块安装的前面
+	setupProfiling(ctx, s.GetRoute())
+	// 任务队列 模块安装时注册任务类型
+	queue := setupJobQueue(ctx)
+	// IM接口的熔断、超时和失败后重发 需要在任务队列Start之前注册重发的任务
+	imbreaker.Install(ctx.GetConfig().WuKongIM.APIURL)
+	// 领域事件 通过任务队列发布
+	err = setupEventBus()
+	if err != nil {
+		panic(err)
+	}
 	// 模块安装
-	err := module.Setup(ctx)
+	err = setupModules(ctx)
+	if err != nil {
+		panic(err)
+	}
+	queue.Start()
+	shutdown.Register(shutdown.PhaseStop, "jobQueue", func(context.Context) error {
+		queue.Stop() // 等待正在执行的任务结束
+		return nil
+	})
+	// 内部gRPC接口 模块安装时注册服务
+	err = setupRPCAPI()
 	if err != nil {
 		panic(err)
 	}
+	// 监控指标
+	err = setupMetrics(ctx)
+	if err != nil {
+		panic(err)
+	}
+	// 接口文档
+	setupAPIDoc(s.GetRoute())
 	//开始定时处理事件
 	cn := cron.New()
 	//定时发布事件 每59秒执行一次
@@ -104,6 +181,11 @@ func runAPI(ctx *config.Context) {
 		panic(err)
 	}
 	cn.Start()
+	shutdown.Register(shutdown.PhaseStop, "cron", func(context.Context) error {
+		cn.Stop()
+		return nil
+	})
+	setupShutdownClose(ctx)
 
 	// 打印服务器信息
 	printServerInfo(ctx)
@@ -115,6 +197,252 @@ func runAPI(ctx *config.Context) {
 	}
 }
 
+// setupConfigReload 收到SIGHUP信号或调用管理后台接口时重新读取配置文件 只有扩展配置会重新加载
+func setupConfigReload(cfgFile string) {
+	extconfig.SetLoader(func() (*viper.Viper, error) {
+		vp := viper.New()
+		vp.SetConfigFile(cfgFile)
+		if err := vp.ReadInConfig(); err != nil {
+			return nil, err
+		}
+		vp.SetEnvPrefix("ts")
+		vp.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+		vp.AutomaticEnv()
+		return vp, nil
+	})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			result, err := extconfig.Reload()
+			if err != nil {
+				log.Error("重新加载配置失败，继续使用原来的配置！", zap.Error(err))
+				continue
+			}
+			log.Info("配置已重新加载", zap.Strings("changed", result.Changed), zap.Strings("restartRequired", result.RestartRequired))
+		}
+	}()
+}
+
+// setupShutdownClose 退出时最后关闭事件总线和数据库连接
+// 使用的redis客户端没有提供关闭的方法 由进程退出时关闭
+func setupShutdownClose(ctx *config.Context) {
+	shutdown.Register(shutdown.PhaseClose, "eventBus", func(context.Context) error {
+		return eventbus.Close()
+	})
+	if set := replica.Get(); set != nil {
+		for name, session := range set.Sessions() {
+			session := session
+			shutdown.Register(shutdown.PhaseClose, "mysql.replica:"+name, func(context.Context) error {
+				return session.Close()
+			})
+		}
+	}
+	shutdown.Register(shutdown.PhaseClose, "mysql", func(context.Context) error {
+		return ctx.DB().Close()
+	})
+}
+
+// setupReplicas 连接配置的从库 定时检查复制延迟
+func setupReplicas(ctx *config.Context) error {
+	cfg := extconfig.Get().Replica
+	if len(cfg.Addrs) == 0 {
+		return nil
+	}
+	dbCfg := ctx.GetConfig().DB
+	opts := replica.Options{
+		MaxLag:          cfg.MaxLag,
+		StickyDuration:  cfg.StickyDuration,
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: dbCfg.MySQLConnMaxLifetime,
+	}
+	if opts.MaxOpenConns <= 0 {
+		opts.MaxOpenConns = dbCfg.MySQLMaxOpenConns
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = dbCfg.MySQLMaxIdleConns
+	}
+	set, err := replica.Open(cfg.Addrs, opts)
+	if err != nil {
+		return err
+	}
+	set.CheckLag()
+	replica.Configure(set)
+	ctx.Schedule(cfg.CheckInterval, set.CheckLag)
+	return nil
+}
+
+// setupModules 安装模块 关闭自动迁移时不执行数据库迁移 有未执行的迁移时返回错误
+func setupModules(ctx *config.Context) error {
+	autoMigrate := extconfig.Get().Migration.AutoMigrate
+	if autoMigrate && !postgres.Enabled() {
+		return module.Setup(ctx)
+	}
+	modules := register.GetModules(ctx)
+	source := dbmigrate.NewSource(modules)
+	if autoMigrate {
+		// module.Setup的迁移只支持MySQL
+		if _, err := dbmigrate.Up(ctx.DB().DB, source, 0); err != nil {
+			return err
+		}
+	} else {
+		pending, err := dbmigrate.Pending(ctx.DB().DB, source)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("有%d个未执行的数据库迁移（%s），请先执行 migrate up", len(pending), strings.Join(pending, "、"))
+		}
+	}
+	// 与module.Setup相同 只是不执行迁移
+	for _, m := range modules {
+		if m.SetupAPI != nil {
+			if a := m.SetupAPI(); a != nil {
+				a.Route(ctx.GetHttpRoute())
+			}
+		}
+		if ctx.SetupTask && m.SetupTask != nil {
+			if t := m.SetupTask(); t != nil {
+				t.RegisterTasks()
+			}
+		}
+	}
+	return nil
+}
+
+// setupJobQueue 创建后台任务队列 模块安装后调用Start开始执行任务
+func setupJobQueue(ctx *config.Context) *jobqueue.Queue {
+	cfg := extconfig.Get().JobQueue
+	client := rd.NewClient(&rd.Options{
+		Addr:       ctx.GetConfig().DB.RedisAddr,
+		Password:   ctx.GetConfig().DB.RedisPass,
+		MaxRetries: 3,
+	})
+	queue := jobqueue.New(client, jobqueue.Options{
+		Prefix:             cfg.Prefix,
+		Concurrency:        cfg.Concurrency,
+		DefaultConcurrency: cfg.DefaultConcurrency,
+		PollInterval:       cfg.PollInterval,
+		LeaseTimeout:       cfg.LeaseTimeout,
+		MaxRetry:           cfg.MaxRetry,
+		RetryBackoff:       cfg.RetryBackoff,
+		MaxBackoff:         cfg.MaxBackoff,
+		FailedRetention:    cfg.FailedRetention,
+	})
+	jobqueue.Configure(queue)
+	shutdown.Register(shutdown.PhaseClose, "jobQueue.redis", func(context.Context) error {
+		return client.Close()
+	})
+	return queue
+}
+
+// setupEventBus 配置领域事件的发布 没有配置类型时不发布
+func setupEventBus() error {
+	cfg := extconfig.Get().EventBus
+	var (
+		publisher eventbus.Publisher
+		err       error
+	)
+	switch cfg.Type {
+	case "":
+		eventbus.Configure(nil)
+		return nil
+	case "nats":
+		publisher, err = eventbus.NewNATSPublisher(eventbus.NATSOptions{
+			Addrs:    cfg.NATS.Addrs,
+			Subject:  cfg.NATS.Subject,
+			User:     cfg.NATS.User,
+			Password: cfg.NATS.Password,
+			Token:    cfg.NATS.Token,
+			Timeout:  cfg.Timeout,
+		})
+	case "kafka":
+		publisher, err = eventbus.NewKafkaPublisher(eventbus.KafkaOptions{
+			RestURL:  cfg.Kafka.RestURL,
+			Topic:    cfg.Kafka.Topic,
+			Username: cfg.Kafka.Username,
+			Password: cfg.Kafka.Password,
+			Timeout:  cfg.Timeout,
+		})
+	default:
+		return fmt.Errorf("不支持的事件总线类型：%s", cfg.Type)
+	}
+	if err != nil {
+		return err
+	}
+	eventbus.Configure(eventbus.New(publisher, eventbus.Options{
+		Source: cfg.Source,
+		Events: cfg.Events,
+	}))
+	return nil
+}
+
+// setupRPCAPI 启动内部gRPC接口 没有配置地址时不启动
+func setupRPCAPI() error {
+	cfg := extconfig.Get().RPCAPI
+	if cfg.Addr == "" {
+		return nil
+	}
+	s := rpcapi.New(rpcapi.Options{
+		Addr:  cfg.Addr,
+		Token: cfg.Token,
+	})
+	if err := s.Start(); err != nil {
+		return err
+	}
+	shutdown.Register(shutdown.PhaseHTTP, "rpcAPI", func(context.Context) error {
+		s.Stop() // 等待进行中的调用结束
+		return nil
+	})
+	return nil
+}
+
+// setupAPIDoc 开启时提供OpenAPI文档和Swagger UI
+func setupAPIDoc(r *wkhttp.WKHttp) {
+	cfg := extconfig.Get().APIDoc
+	if !cfg.Enable {
+		return
+	}
+	apidoc.Route(r, apidoc.Options{
+		Token: cfg.Token,
+		UIURL: cfg.UIURL,
+	})
+}
+
+// setupMetrics 采集数据库、redis和IM接口的指标 通过/metrics查看
+func setupMetrics(ctx *config.Context) error {
+	if err := metrics.RegisterDB(ctx.DB().DB, "tsdd"); err != nil {
+		return err
+	}
+	if set := replica.Get(); set != nil {
+		for name, session := range set.Sessions() {
+			if err := metrics.RegisterDB(session.DB, "replica:"+name); err != nil {
+				return err
+			}
+		}
+	}
+	if err := metrics.RegisterRedis(func() error {
+		_, err := ctx.GetRedisConn().Ping()
+		return err
+	}); err != nil {
+		return err
+	}
+	metrics.InstrumentIM(ctx.GetConfig().WuKongIM.APIURL)
+	return nil
+}
+
+// setupProfiling 统计主库和从库的慢查询 注册/debug/pprof
+func setupProfiling(ctx *config.Context, r *wkhttp.WKHttp) {
+	profiling.InstrumentDB(ctx.DB(), "tsdd")
+	if set := replica.Get(); set != nil {
+		for name, session := range set.Sessions() {
+			profiling.InstrumentDB(session, "replica:"+name)
+		}
+	}
+	profiling.RoutePProf(r)
+}
+
 func printServerInfo(ctx *config.Context) {
 	infoStr := `
 [?25l[?7lLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLL
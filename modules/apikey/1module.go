@@ -0,0 +1,30 @@
+package apikey
+
+import (
+	"embed"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+)
+
+//go:embed sql
+var sqlFS embed.FS
+
+//go:embed swagger/api.yaml
+var swaggerContent string
+
+func init() {
+
+	// 管理后台的API密钥
+	register.AddModule(func(ctx interface{}) register.Module {
+
+		return register.Module{
+			Name: "apikey",
+			SetupAPI: func() register.APIRouter {
+				return NewManager(ctx.(*config.Context))
+			},
+			SQLDir:  register.NewSQLFS(sqlFS),
+			Swagger: swaggerContent,
+		}
+	})
+}
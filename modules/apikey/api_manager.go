@@ -0,0 +1,245 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Manager 管理后台的API密钥 和管理员账号分开管理 只能调用权限范围内的接口
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	return &Manager{
+		ctx: ctx,
+		Log: log.NewTLog("apikeyManager"),
+		db:  newDB(ctx),
+	}
+}
+
+// Route 配置路由规则
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/apikey/scopes", m.scopes)      // 可以使用的权限范围
+		auth.POST("/apikeys", m.create)           // 创建密钥 密钥只在创建时返回
+		auth.GET("/apikeys", m.list)              // 密钥列表
+		auth.DELETE("/apikeys/:key_no", m.revoke) // 吊销密钥
+	}
+}
+
+// 可以使用的权限范围和包含的权限
+func (m *Manager) scopes(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermAdminManage); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	list := make([]*scopeResp, 0, len(rbac.Scopes()))
+	for _, scope := range rbac.Scopes() {
+		list = append(list, &scopeResp{
+			Scope:       string(scope),
+			Permissions: rbac.ScopePermissions([]string{string(scope)}),
+		})
+	}
+	c.Response(list)
+}
+
+type createReq struct {
+	Name       string   `json:"name"`        // 名称 例如对接的工具
+	Scopes     []string `json:"scopes"`      // 权限范围
+	ExpireDays int      `json:"expire_days"` // 有效天数 为0时默认90天
+}
+
+func (r *createReq) check() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("名称不能为空")
+	}
+	if len([]rune(r.Name)) > 100 {
+		return errors.New("名称不能超过100个字")
+	}
+	if strings.Contains(r.Name, "@") {
+		return errors.New("名称不能包含@")
+	}
+	if len(r.Scopes) == 0 {
+		return errors.New("权限范围不能为空")
+	}
+	for _, scope := range r.Scopes {
+		if !rbac.ValidScope(scope) {
+			return fmt.Errorf("权限范围[%s]有误", scope)
+		}
+	}
+	if r.ExpireDays == 0 {
+		r.ExpireDays = defaultExpireDays
+	}
+	if r.ExpireDays < 0 || r.ExpireDays > maxExpireDays {
+		return fmt.Errorf("有效天数需要在1到%d之间", maxExpireDays)
+	}
+	return nil
+}
+
+// 创建密钥 只返回一次 之后无法再查看
+func (m *Manager) create(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermAdminManage); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req createReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	key := keyFlag + util.GenerUUID()
+	scopes := strings.TrimPrefix(rbac.APIKeyRole(req.Scopes), rbac.RoleAPIKeyPrefix)
+	expireAt := time.Now().AddDate(0, 0, req.ExpireDays).Unix()
+	apiKey := &model{
+		KeyNo:     util.GenerUUID(),
+		Name:      req.Name,
+		KeyPrefix: key[:keyPrefixLen],
+		KeyHash:   hashKey(key),
+		Scopes:    scopes,
+		ExpireAt:  expireAt,
+		Status:    StatusNormal,
+		Creator:   c.GetLoginUID(),
+	}
+	if err := m.db.insert(apiKey); err != nil {
+		m.Error("添加API密钥失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加API密钥失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("key_no=%s", apiKey.KeyNo), nil, map[string]interface{}{
+		"name":       apiKey.Name,
+		"key_prefix": apiKey.KeyPrefix,
+		"scopes":     scopes,
+		"expire_at":  expireAt,
+	})
+	c.Response(map[string]interface{}{
+		"key_no":    apiKey.KeyNo,
+		"key":       key,
+		"scopes":    splitScopes(scopes),
+		"expire_at": expireAt,
+	})
+}
+
+// 密钥列表
+func (m *Manager) list(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermAdminManage); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.db.queryWithPage(uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询API密钥失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询API密钥失败！"))
+		return
+	}
+	count, err := m.db.queryCount()
+	if err != nil {
+		m.Error("查询API密钥数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询API密钥数量失败！"))
+		return
+	}
+	now := time.Now().Unix()
+	list := make([]*keyResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newKeyResp(model, now))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 吊销密钥 立即失效
+func (m *Manager) revoke(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermAdminManage); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	apiKey, err := m.db.queryWithKeyNo(c.Param("key_no"))
+	if err != nil {
+		m.Error("查询API密钥失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询API密钥失败！"))
+		return
+	}
+	if apiKey == nil {
+		c.ResponseError(errors.New("API密钥不存在"))
+		return
+	}
+	ok, err := m.db.revoke(apiKey.Id, c.GetLoginUID(), time.Now().Unix())
+	if err != nil {
+		m.Error("吊销API密钥失败！", zap.Error(err))
+		c.ResponseError(errors.New("吊销API密钥失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("API密钥已吊销"))
+		return
+	}
+	if err = m.ctx.Cache().Delete(m.ctx.GetConfig().Cache.TokenCachePrefix + sessionFlag + apiKey.KeyHash); err != nil {
+		m.Error("删除API密钥缓存失败！", zap.Error(err), zap.String("keyNo", apiKey.KeyNo))
+		c.ResponseError(errors.New("删除API密钥缓存失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("key_no=%s", apiKey.KeyNo), map[string]interface{}{"status": apiKey.Status}, map[string]interface{}{"status": StatusRevoked})
+	c.ResponseOK()
+}
+
+type scopeResp struct {
+	Scope       string            `json:"scope"`
+	Permissions []rbac.Permission `json:"permissions"`
+}
+
+type keyResp struct {
+	KeyNo      string   `json:"key_no"`
+	Name       string   `json:"name"`
+	KeyPrefix  string   `json:"key_prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpireAt   int64    `json:"expire_at"`
+	Expired    bool     `json:"expired"`
+	Status     int      `json:"status"`
+	Creator    string   `json:"creator"`
+	Revoker    string   `json:"revoker"`
+	RevokedAt  int64    `json:"revoked_at"`
+	LastUsedAt int64    `json:"last_used_at"` // 最后一次使用的时间 每5分钟更新一次
+	LastUsedIP string   `json:"last_used_ip"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+func newKeyResp(m *model, now int64) *keyResp {
+	return &keyResp{
+		KeyNo:      m.KeyNo,
+		Name:       m.Name,
+		KeyPrefix:  m.KeyPrefix,
+		Scopes:     splitScopes(m.Scopes),
+		ExpireAt:   m.ExpireAt,
+		Expired:    m.ExpireAt <= now,
+		Status:     m.Status,
+		Creator:    m.Creator,
+		Revoker:    m.Revoker,
+		RevokedAt:  m.RevokedAt,
+		LastUsedAt: m.LastUsedAt,
+		LastUsedIP: m.LastUsedIP,
+		CreatedAt:  m.CreatedAt.String(),
+	}
+}
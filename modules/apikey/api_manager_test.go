@@ -0,0 +1,27 @@
+package apikey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateReqCheck(t *testing.T) {
+	req := &createReq{Name: " 运营看板 ", Scopes: []string{"read-stats"}}
+	assert.NoError(t, req.check())
+	assert.Equal(t, "运营看板", req.Name)
+	assert.Equal(t, defaultExpireDays, req.ExpireDays)
+
+	assert.EqualError(t, (&createReq{Scopes: []string{"read-stats"}}).check(), "名称不能为空")
+	assert.EqualError(t, (&createReq{Name: "a@b", Scopes: []string{"read-stats"}}).check(), "名称不能包含@")
+	assert.EqualError(t, (&createReq{Name: "a"}).check(), "权限范围不能为空")
+	assert.EqualError(t, (&createReq{Name: "a", Scopes: []string{"admin"}}).check(), "权限范围[admin]有误")
+	assert.EqualError(t, (&createReq{Name: "a", Scopes: []string{"read-stats"}, ExpireDays: 400}).check(), "有效天数需要在1到365之间")
+}
+
+func TestNewKeyResp(t *testing.T) {
+	resp := newKeyResp(&model{KeyNo: "k1", Scopes: "manage-users", ExpireAt: 100, Status: StatusNormal}, 100)
+	assert.True(t, resp.Expired)
+	assert.Equal(t, []string{"manage-users"}, resp.Scopes)
+	assert.Empty(t, newKeyResp(&model{}, 0).Scopes)
+}
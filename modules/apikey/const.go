@@ -0,0 +1,28 @@
+package apikey
+
+import "time"
+
+// 密钥的状态
+const (
+	StatusRevoked = 0 // 已吊销
+	StatusNormal  = 1 // 正常
+)
+
+const (
+	// keyFlag API密钥的前缀 请求时放在token请求头
+	keyFlag = "tsak_"
+	// sessionFlag 验证密钥后替换请求头的token前缀 不能直接在请求中使用
+	sessionFlag = "tsaks_"
+	// keyPrefixLen 列表中展示的密钥长度
+	keyPrefixLen = 12
+	// keyUIDPrefix 密钥调用接口时的登录uid前缀 操作日志中通过uid区分密钥
+	keyUIDPrefix = "apikey_"
+	// sessionTTL 验证结果的缓存时间 最后使用时间按此间隔更新
+	sessionTTL = time.Minute * 5
+	// defaultExpireDays 默认有效天数
+	defaultExpireDays = 90
+	// maxExpireDays 最长有效天数 不能创建永久有效的密钥
+	maxExpireDays = 365
+	// managerPathPrefix 密钥只能调用管理后台的接口
+	managerPathPrefix = "/v1/manager/"
+)
@@ -0,0 +1,84 @@
+package apikey
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *db) insert(m *model) error {
+	_, err := d.session.InsertInto("manager_api_key").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryWithKeyNo(keyNo string) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("manager_api_key").Where("key_no=?", keyNo).Load(&m)
+	return m, err
+}
+
+func (d *db) queryWithKeyHash(keyHash string) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("manager_api_key").Where("key_hash=?", keyHash).Load(&m)
+	return m, err
+}
+
+func (d *db) queryWithPage(pageIndex, pageSize uint64) ([]*model, error) {
+	var models []*model
+	_, err := d.session.Select("*").From("manager_api_key").OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryCount() (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("manager_api_key").Load(&count)
+	return count, err
+}
+
+// revoke 吊销密钥 已吊销时返回false
+func (d *db) revoke(id int64, revoker string, revokedAt int64) (bool, error) {
+	result, err := d.session.Update("manager_api_key").SetMap(map[string]interface{}{
+		"status":     StatusRevoked,
+		"revoker":    revoker,
+		"revoked_at": revokedAt,
+	}).Where("id=? and status=?", id, StatusNormal).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (d *db) updateLastUsed(id int64, lastUsedAt int64, lastUsedIP string) error {
+	_, err := d.session.Update("manager_api_key").Set("last_used_at", lastUsedAt).Set("last_used_ip", lastUsedIP).Where("id=?", id).Exec()
+	return err
+}
+
+type model struct {
+	KeyNo      string
+	Name       string
+	KeyPrefix  string
+	KeyHash    string
+	Scopes     string // 逗号分隔
+	ExpireAt   int64
+	Status     int
+	Creator    string
+	Revoker    string
+	RevokedAt  int64
+	LastUsedAt int64
+	LastUsedIP string
+	dba.BaseModel
+}
@@ -0,0 +1,122 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Middleware 验证token请求头中的API密钥 验证通过后替换为缓存中的会话 由登录认证中间件识别
+// 需要在模块安装（注册路由）之前添加
+func Middleware(ctx *config.Context) gin.HandlerFunc {
+	d := newDB(ctx)
+	lg := log.NewTLog("APIKey")
+	return func(c *gin.Context) {
+		token := c.GetHeader("token")
+		if strings.HasPrefix(token, sessionFlag) {
+			abort(c, http.StatusUnauthorized, "token有误！")
+			return
+		}
+		if !strings.HasPrefix(token, keyFlag) {
+			c.Next()
+			return
+		}
+		if !strings.HasPrefix(c.Request.URL.Path, managerPathPrefix) {
+			abort(c, http.StatusForbidden, "API密钥只能调用管理后台的接口")
+			return
+		}
+		keyHash := hashKey(token)
+		session := sessionFlag + keyHash
+		cacheKey := ctx.GetConfig().Cache.TokenCachePrefix + session
+		value, err := ctx.Cache().Get(cacheKey)
+		if err != nil {
+			lg.Error("查询API密钥缓存失败！", zap.Error(err))
+			abort(c, http.StatusInternalServerError, "查询API密钥失败！")
+			return
+		}
+		if value == "" {
+			m, err := d.queryWithKeyHash(keyHash)
+			if err != nil {
+				lg.Error("查询API密钥失败！", zap.Error(err))
+				abort(c, http.StatusInternalServerError, "查询API密钥失败！")
+				return
+			}
+			now := time.Now()
+			if err = checkUsable(m, now); err != nil {
+				abort(c, http.StatusUnauthorized, err.Error())
+				return
+			}
+			err = ctx.Cache().SetAndExpire(cacheKey, sessionValue(m), sessionCacheTTL(m.ExpireAt, now))
+			if err != nil {
+				lg.Error("设置API密钥缓存失败！", zap.Error(err))
+				abort(c, http.StatusInternalServerError, "查询API密钥失败！")
+				return
+			}
+			if err = d.updateLastUsed(m.Id, now.Unix(), c.ClientIP()); err != nil {
+				lg.Warn("更新API密钥的使用时间失败！", zap.Error(err), zap.String("keyNo", m.KeyNo))
+			}
+		}
+		c.Request.Header.Set("token", session)
+		c.Next()
+	}
+}
+
+func abort(c *gin.Context, status int, msg string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"msg": msg,
+	})
+}
+
+// hashKey 密钥的sha256 数据库中只保存哈希
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkUsable 密钥是否可以使用
+func checkUsable(m *model, now time.Time) error {
+	if m == nil {
+		return errors.New("API密钥不存在")
+	}
+	if m.Status != StatusNormal {
+		return errors.New("API密钥已吊销")
+	}
+	if m.ExpireAt <= now.Unix() {
+		return errors.New("API密钥已过期")
+	}
+	return nil
+}
+
+// sessionValue 登录认证中间件使用的登录信息 格式为 uid@名称@角色
+func sessionValue(m *model) string {
+	return fmt.Sprintf("%s%s@%s@%s", keyUIDPrefix, m.KeyNo, m.Name, rbac.APIKeyRole(splitScopes(m.Scopes)))
+}
+
+// sessionCacheTTL 缓存不超过密钥的过期时间
+func sessionCacheTTL(expireAt int64, now time.Time) time.Duration {
+	ttl := time.Unix(expireAt, 0).Sub(now)
+	if ttl > sessionTTL {
+		return sessionTTL
+	}
+	if ttl < time.Second {
+		return time.Second
+	}
+	return ttl
+}
+
+func splitScopes(scopes string) []string {
+	if scopes == "" {
+		return nil
+	}
+	return strings.Split(scopes, ",")
+}
@@ -0,0 +1,33 @@
+package apikey
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckUsable(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	assert.EqualError(t, checkUsable(nil, now), "API密钥不存在")
+	assert.EqualError(t, checkUsable(&model{Status: StatusRevoked, ExpireAt: now.Unix() + 60}, now), "API密钥已吊销")
+	assert.EqualError(t, checkUsable(&model{Status: StatusNormal, ExpireAt: now.Unix()}, now), "API密钥已过期")
+	assert.NoError(t, checkUsable(&model{Status: StatusNormal, ExpireAt: now.Unix() + 60}, now))
+}
+
+func TestSessionValue(t *testing.T) {
+	m := &model{KeyNo: "k1", Name: "数据看板", Scopes: "read-stats,send-broadcast"}
+	assert.Equal(t, "apikey_k1@数据看板@apikey:read-stats,send-broadcast", sessionValue(m))
+}
+
+func TestSessionCacheTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	assert.Equal(t, sessionTTL, sessionCacheTTL(now.Unix()+3600, now))
+	assert.Equal(t, time.Minute, sessionCacheTTL(now.Unix()+60, now))
+	assert.Equal(t, time.Second, sessionCacheTTL(now.Unix(), now))
+}
+
+func TestHashKey(t *testing.T) {
+	assert.Len(t, hashKey(keyFlag+"abc"), 64)
+	assert.NotEqual(t, hashKey(keyFlag+"abc"), hashKey(keyFlag+"abd"))
+}
@@ -0,0 +1,23 @@
+-- +migrate Up
+
+-- 管理后台的API密钥 用于外部工具对接管理后台 只保存密钥的哈希
+create table `manager_api_key`
+(
+  id           bigint         not null primary key AUTO_INCREMENT,
+  key_no       VARCHAR(40)    not null default '',  -- 密钥编号
+  name         VARCHAR(100)   not null default '',  -- 名称 例如对接的工具
+  key_prefix   VARCHAR(20)    not null default '',  -- 密钥的前几位 用于辨认密钥
+  key_hash     VARCHAR(64)    not null default '',  -- 密钥的sha256
+  scopes       VARCHAR(255)   not null default '',  -- 权限范围 逗号分隔
+  expire_at    bigint         not null default 0,   -- 过期时间
+  status       smallint       not null default 1,   -- 状态 0.已吊销 1.正常
+  creator      VARCHAR(40)    not null default '',  -- 创建人
+  revoker      VARCHAR(40)    not null default '',  -- 吊销人
+  revoked_at   bigint         not null default 0,   -- 吊销时间
+  last_used_at bigint         not null default 0,   -- 最后一次使用的时间
+  last_used_ip VARCHAR(100)   not null default '',  -- 最后一次使用的IP
+  created_at   timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at   timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `manager_api_key_no_idx` on `manager_api_key` (`key_no`);
+CREATE UNIQUE INDEX `manager_api_key_hash_idx` on `manager_api_key` (`key_hash`);
@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"embed"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+)
+
+//go:embed sql
+var sqlFS embed.FS
+
+//go:embed swagger/api.yaml
+var swaggerContent string
+
+func init() {
+
+	// 管理后台操作日志
+	register.AddModule(func(ctx interface{}) register.Module {
+
+		return register.Module{
+			Name: "audit",
+			SetupAPI: func() register.APIRouter {
+				return NewManager(ctx.(*config.Context))
+			},
+			SQLDir:  register.NewSQLFS(sqlFS),
+			Swagger: swaggerContent,
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/cursor"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxExportCount 一次最多导出的日志数量
+	maxExportCount = 50000
+	// cleanBatchSize 清理过期日志时每次删除的数量
+	cleanBatchSize = 1000
+)
+
+// Manager 管理后台操作日志
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	return &Manager{
+		ctx: ctx,
+		Log: log.NewTLog("ManagerLogManager"),
+		db:  newDB(ctx),
+	}
+}
+
+// Route 路由配置
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/audit/logs", m.list)          // 操作日志列表
+		auth.GET("/audit/logs/export", m.export) // 导出操作日志
+	}
+	if extconfig.Get().ManagerLog.Enable && extconfig.Get().ManagerLog.Retention > 0 {
+		m.ctx.Schedule(time.Hour, m.cleanExpiredLogs)
+	}
+}
+
+// 操作日志列表 只有超级管理员可以查看
+func (m *Manager) list(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermAuditRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	filter, err := newLogFilter(c)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	// 带cursor参数时按游标分页返回 不统计总数
+	if cursor.Requested(c) {
+		page, err := cursor.Query(c)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		models, err := m.db.queryLogsWithCursor(filter, page.Key(0), page.FetchLimit())
+		if err != nil {
+			m.Error("查询操作日志失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询操作日志失败！"))
+			return
+		}
+		n, hasMore := page.Keep(len(models))
+		list := make([]*logResp, 0, n)
+		for _, model := range models[:n] {
+			list = append(list, newLogResp(model))
+		}
+		var lastID int64
+		if n > 0 {
+			lastID = models[n-1].Id
+		}
+		c.Response(cursor.NewResult(list, hasMore, lastID))
+		return
+	}
+	pageIndex, pageSize := cursor.OffsetPage(c)
+	models, err := m.db.queryLogsWithPage(filter, pageIndex, pageSize)
+	if err != nil {
+		m.Error("查询操作日志失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询操作日志失败！"))
+		return
+	}
+	count, err := m.db.queryLogsCount(filter)
+	if err != nil {
+		m.Error("查询操作日志数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询操作日志数量失败！"))
+		return
+	}
+	list := make([]*logResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newLogResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 导出操作日志 条件与列表相同 导出本身也会被记录
+func (m *Manager) export(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermAuditRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	filter, err := newLogFilter(c)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := m.db.queryLogs(filter, maxExportCount)
+	if err != nil {
+		m.Error("查询操作日志失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询操作日志失败！"))
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString("\xef\xbb\xbf") // excel打开时识别为utf-8
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write([]string{"时间", "操作人uid", "操作人", "角色", "操作", "请求地址", "操作对象", "修改前", "修改后", "IP", "状态码", "失败原因"})
+	for _, model := range models {
+		_ = writer.Write([]string{
+			model.CreatedAt.String(),
+			model.UID,
+			model.Name,
+			model.Role,
+			model.Action,
+			model.Path,
+			model.Target,
+			model.BeforeValue,
+			model.AfterValue,
+			model.IP,
+			strconv.Itoa(model.Status),
+			model.ErrMsg,
+		})
+	}
+	writer.Flush()
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=manager_logs_%s.csv", time.Now().Format("20060102150405")))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// cleanExpiredLogs 删除超过保留时长的日志
+func (m *Manager) cleanExpiredLogs() {
+	before := time.Now().Add(-extconfig.Get().ManagerLog.Retention)
+	for {
+		count, err := m.db.deleteBefore(before, cleanBatchSize)
+		if err != nil {
+			m.Warn("删除过期的操作日志失败！", zap.Error(err))
+			return
+		}
+		if count < cleanBatchSize {
+			return
+		}
+	}
+}
+
+// newLogFilter 查询条件 start_date和end_date格式为 2006-01-02 包含end_date当天
+func newLogFilter(c *wkhttp.Context) (*logFilter, error) {
+	filter := &logFilter{
+		UID:     c.Query("uid"),
+		Keyword: c.Query("keyword"),
+		Target:  c.Query("target"),
+		IP:      c.Query("ip"),
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			return nil, errors.New("开始日期格式有误！")
+		}
+		filter.StartTime = t
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			return nil, errors.New("结束日期格式有误！")
+		}
+		filter.EndTime = t.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+type logResp struct {
+	ID          int64  `json:"id"`
+	UID         string `json:"uid"`
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	Method      string `json:"method"`
+	Action      string `json:"action"`
+	Path        string `json:"path"`
+	Target      string `json:"target"`
+	BeforeValue string `json:"before_value"`
+	AfterValue  string `json:"after_value"`
+	IP          string `json:"ip"`
+	UserAgent   string `json:"user_agent"`
+	Status      int    `json:"status"`
+	ErrMsg      string `json:"err_msg"`
+	Latency     int64  `json:"latency"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func newLogResp(m *logModel) *logResp {
+	return &logResp{
+		ID:          m.Id,
+		UID:         m.UID,
+		Name:        m.Name,
+		Role:        m.Role,
+		Method:      m.Method,
+		Action:      m.Action,
+		Path:        m.Path,
+		Target:      m.Target,
+		BeforeValue: m.BeforeValue,
+		AfterValue:  m.AfterValue,
+		IP:          m.IP,
+		UserAgent:   m.UserAgent,
+		Status:      m.Status,
+		ErrMsg:      m.ErrMsg,
+		Latency:     m.Latency,
+		CreatedAt:   m.CreatedAt.String(),
+	}
+}
@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// insertLogs 批量添加操作日志
+func (d *db) insertLogs(models []*logModel) error {
+	if len(models) == 0 {
+		return nil
+	}
+	builder := d.session.InsertInto("manager_log").Columns(util.AttrToUnderscore(models[0])...)
+	for _, m := range models {
+		builder = builder.Record(m)
+	}
+	_, err := builder.Exec()
+	return err
+}
+
+func (d *db) queryLogsWithPage(filter *logFilter, pageIndex, pageSize uint64) ([]*logModel, error) {
+	var models []*logModel
+	_, err := filter.apply(d.session.Select("*").From("manager_log")).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+// queryLogsWithCursor 按id倒序查询 beforeID为0时从最新的开始
+func (d *db) queryLogsWithCursor(filter *logFilter, beforeID int64, limit uint64) ([]*logModel, error) {
+	var models []*logModel
+	builder := filter.apply(d.session.Select("*").From("manager_log"))
+	if beforeID > 0 {
+		builder = builder.Where("id<?", beforeID)
+	}
+	_, err := builder.OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
+func (d *db) queryLogsCount(filter *logFilter) (int64, error) {
+	var count int64
+	_, err := filter.apply(d.session.Select("count(*)").From("manager_log")).Load(&count)
+	return count, err
+}
+
+// queryLogs 按时间倒序查询 最多limit条
+func (d *db) queryLogs(filter *logFilter, limit uint64) ([]*logModel, error) {
+	var models []*logModel
+	_, err := filter.apply(d.session.Select("*").From("manager_log")).OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
+// deleteBefore 删除过期的日志 每次最多删除limit条
+func (d *db) deleteBefore(t time.Time, limit uint64) (int64, error) {
+	result, err := d.session.DeleteFrom("manager_log").Where("created_at<?", t).Limit(limit).Exec()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// logFilter 日志的查询条件
+type logFilter struct {
+	UID       string    // 操作人
+	Keyword   string    // 匹配操作、对象或请求地址
+	Target    string    // 操作对象
+	IP        string    // 操作人IP
+	StartTime time.Time // 为零值时不限制
+	EndTime   time.Time // 为零值时不限制
+}
+
+func (q *logFilter) apply(builder *dbr.SelectStmt) *dbr.SelectStmt {
+	if q.UID != "" {
+		builder = builder.Where("uid=?", q.UID)
+	}
+	if q.Keyword != "" {
+		keyword := "%" + q.Keyword + "%"
+		builder = builder.Where("(action like ? or target like ? or path like ?)", keyword, keyword, keyword)
+	}
+	if q.Target != "" {
+		builder = builder.Where("target=?", q.Target)
+	}
+	if q.IP != "" {
+		builder = builder.Where("ip=?", q.IP)
+	}
+	if !q.StartTime.IsZero() {
+		builder = builder.Where("created_at>=?", q.StartTime)
+	}
+	if !q.EndTime.IsZero() {
+		builder = builder.Where("created_at<?", q.EndTime)
+	}
+	return builder
+}
+
+type logModel struct {
+	UID         string
+	Name        string
+	Role        string
+	Method      string
+	Action      string
+	Path        string
+	Target      string
+	BeforeValue string
+	AfterValue  string
+	IP          string
+	UserAgent   string
+	Status      int
+	ErrMsg      string
+	Latency     int64
+	dba.BaseModel
+}
@@ -0,0 +1,327 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/shutdown"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// managerPathPrefix 管理后台的接口
+const managerPathPrefix = "/v1/manager/"
+
+// changeKey 请求上下文中保存Change的key
+const changeKey = "managerLogChange"
+
+// maskedValue 替换密码等字段的值
+const maskedValue = "******"
+
+// sensitiveFields 请求内容中不记录值的字段（包含即匹配）
+var sensitiveFields = []string{"password", "pwd", "secret", "token", "private_key"}
+
+// Change 接口记录的修改前后的值 没有记录时使用请求内容作为修改后的值
+type Change struct {
+	Target string
+	Before interface{}
+	After  interface{}
+}
+
+// SetChange 记录本次操作的对象和修改前后的值 before或after为nil时不记录
+// 在管理后台的接口中调用 没有开启操作日志时不做任何事
+func SetChange(c *wkhttp.Context, target string, before interface{}, after interface{}) {
+	value, ok := c.Get(changeKey)
+	if !ok {
+		return
+	}
+	change := value.(*Change)
+	change.Target = target
+	change.Before = before
+	change.After = after
+}
+
+// logger 异步批量写入操作日志
+type logger struct {
+	log.Log
+	insert   func(models []*logModel) error // 批量写入日志
+	logs     chan *logModel
+	draining chan chan struct{} // 写入队列中所有日志的请求 写入后关闭传入的chan
+}
+
+func newLogger(insert func(models []*logModel) error) *logger {
+	return &logger{
+		Log:      log.NewTLog("ManagerLog"),
+		insert:   insert,
+		logs:     make(chan *logModel, extconfig.Get().ManagerLog.QueueSize),
+		draining: make(chan chan struct{}),
+	}
+}
+
+// Middleware 记录管理后台的操作 需要在模块安装（注册路由）之前添加
+func Middleware(ctx *config.Context) gin.HandlerFunc {
+	cfg := extconfig.Get().ManagerLog
+	if !cfg.Enable {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	l := newLogger(newDB(ctx).insertLogs)
+	go l.run()
+	shutdown.Register(shutdown.PhaseFlush, "audit.managerLog", l.drain)
+	return l.handle
+}
+
+func (l *logger) handle(c *gin.Context) {
+	cfg := extconfig.Get().ManagerLog
+	if !shouldRecord(c.Request.Method, c.Request.URL.Path, cfg.RecordRead) {
+		c.Next()
+		return
+	}
+	body := l.readBody(c, cfg.MaxBodySize)
+	change := &Change{}
+	c.Set(changeKey, change)
+	writer := &responseWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	start := time.Now()
+
+	c.Next()
+
+	uid := c.GetString("uid")
+	if uid == "" {
+		// 没有登录的请求不记录
+		return
+	}
+	target := change.Target
+	if target == "" {
+		target = paramsTarget(c.Params)
+	}
+	afterValue := body
+	if change.After != nil {
+		afterValue = util.ToJson(change.After)
+	}
+	beforeValue := ""
+	if change.Before != nil {
+		beforeValue = util.ToJson(change.Before)
+	}
+	status := c.Writer.Status()
+	errMsg := ""
+	if status >= http.StatusBadRequest {
+		errMsg = responseErrMsg(writer.body.Bytes())
+	}
+	userAgent := c.GetHeader("User-Agent")
+	l.record(&logModel{
+		UID:         uid,
+		Name:        c.GetString("name"),
+		Role:        c.GetString("role"),
+		Method:      c.Request.Method,
+		Action:      fmt.Sprintf("%s %s", c.Request.Method, c.FullPath()),
+		Path:        truncate(c.Request.URL.RequestURI(), 1000),
+		Target:      truncate(target, 255),
+		BeforeValue: truncate(beforeValue, cfg.MaxBodySize),
+		AfterValue:  truncate(afterValue, cfg.MaxBodySize),
+		IP:          c.ClientIP(),
+		UserAgent:   truncate(userAgent, 255),
+		Status:      status,
+		ErrMsg:      truncate(errMsg, 500),
+		Latency:     time.Since(start).Milliseconds(),
+	})
+}
+
+// shouldRecord 只记录管理后台的修改操作 recordRead为true时同时记录查询
+func shouldRecord(method string, path string, recordRead bool) bool {
+	if !strings.HasPrefix(path, managerPathPrefix) {
+		return false
+	}
+	switch method {
+	case http.MethodOptions, http.MethodHead:
+		return false
+	case http.MethodGet:
+		return recordRead
+	}
+	return true
+}
+
+// readBody 读取请求内容用于记录 读取后重新放回 文件上传只记录文件名
+func (l *logger) readBody(c *gin.Context, maxSize int) string {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return ""
+	}
+	contentType := c.ContentType()
+	if strings.HasPrefix(contentType, "multipart/") {
+		return "[multipart]"
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(maxSize)+1))
+	if err != nil {
+		l.Warn("读取请求内容失败！", zap.Error(err), zap.String("path", c.Request.URL.Path))
+		return ""
+	}
+	c.Request.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(data), c.Request.Body), Closer: c.Request.Body}
+	if len(data) > maxSize {
+		// 截断后不是完整的json 无法去掉密码字段
+		return "[内容过长]"
+	}
+	return maskBody(data)
+}
+
+// maskBody 去掉请求内容中密码等字段的值 不是json时原样返回
+func maskBody(data []byte) string {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return string(data)
+	}
+	return util.ToJson(maskValue(value))
+}
+
+func maskValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSensitiveField(key) {
+				v[key] = maskedValue
+				continue
+			}
+			v[key] = maskValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = maskValue(item)
+		}
+	}
+	return value
+}
+
+func isSensitiveField(key string) bool {
+	key = strings.ToLower(key)
+	for _, field := range sensitiveFields {
+		if strings.Contains(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// paramsTarget 没有记录操作对象时使用路由参数 例如 uid=xxx,status=1
+func paramsTarget(params gin.Params) string {
+	if len(params) == 0 {
+		return ""
+	}
+	items := make([]string, 0, len(params))
+	for _, param := range params {
+		items = append(items, fmt.Sprintf("%s=%s", param.Key, param.Value))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// responseErrMsg 接口返回的错误信息 格式为{"msg":"xxx","status":400}
+func responseErrMsg(body []byte) string {
+	var resp struct {
+		Msg string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ""
+	}
+	return resp.Msg
+}
+
+// record 添加一条日志 队列满时丢弃
+func (l *logger) record(m *logModel) {
+	select {
+	case l.logs <- m:
+	default:
+		l.Warn("管理后台操作日志的队列已满！", zap.String("action", m.Action), zap.String("uid", m.UID))
+	}
+}
+
+func (l *logger) run() {
+	cfg := extconfig.Get().ManagerLog
+	ticker := time.NewTicker(cfg.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*logModel, 0, cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := l.insert(batch); err != nil {
+			l.Error("写入管理后台操作日志失败！", zap.Int("count", len(batch)), zap.Error(err))
+		}
+		batch = make([]*logModel, 0, cfg.BatchSize)
+	}
+	for {
+		select {
+		case m := <-l.logs:
+			batch = append(batch, m)
+			if len(batch) >= cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case done := <-l.draining:
+			for len(l.logs) > 0 {
+				batch = append(batch, <-l.logs)
+				if len(batch) >= cfg.BatchSize {
+					flush()
+				}
+			}
+			flush()
+			close(done)
+		}
+	}
+}
+
+// drain 写入队列中还没写入的日志 进程退出时调用
+func (l *logger) drain(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case l.draining <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if max <= 0 || len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
+// responseWriter 保存错误响应的内容 用于记录失败原因
+type responseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if w.Status() >= http.StatusBadRequest && w.body.Len() < 1024 {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldRecord(t *testing.T) {
+	assert.True(t, shouldRecord(http.MethodPut, "/v1/manager/user/liftban/u1/1", false))
+	assert.True(t, shouldRecord(http.MethodDelete, "/v1/manager/message", false))
+	assert.False(t, shouldRecord(http.MethodGet, "/v1/manager/user/list", false))
+	assert.True(t, shouldRecord(http.MethodGet, "/v1/manager/user/list", true))
+	assert.False(t, shouldRecord(http.MethodOptions, "/v1/manager/user/list", true))
+	assert.False(t, shouldRecord(http.MethodPost, "/v1/user/login", false))
+}
+
+func TestMaskBody(t *testing.T) {
+	body := maskBody([]byte(`{"username":"admin","password":"123456","items":[{"new_password":"abc","name":"x"}]}`))
+	assert.NotContains(t, body, "123456")
+	assert.NotContains(t, body, `"abc"`)
+	assert.Contains(t, body, `"username":"admin"`)
+	assert.Contains(t, body, `"name":"x"`)
+
+	assert.Equal(t, "uid=1&name=2", maskBody([]byte("uid=1&name=2")))
+}
+
+func TestParamsTarget(t *testing.T) {
+	assert.Equal(t, "", paramsTarget(nil))
+	assert.Equal(t, "status=1,uid=u1", paramsTarget(gin.Params{
+		{Key: "uid", Value: "u1"},
+		{Key: "status", Value: "1"},
+	}))
+}
+
+func TestResponseErrMsg(t *testing.T) {
+	assert.Equal(t, "用户不存在！", responseErrMsg([]byte(`{"msg":"用户不存在！","status":400}`)))
+	assert.Equal(t, "", responseErrMsg([]byte(`{"status":200}`)))
+	assert.Equal(t, "", responseErrMsg([]byte("ok")))
+}
@@ -0,0 +1,26 @@
+-- +migrate Up
+
+-- ##########  管理后台操作日志 ##########
+create table `manager_log`
+(
+    id           bigint        not null primary key AUTO_INCREMENT,
+    uid          VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '操作人uid',
+    name         VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '操作人名称',
+    role         VARCHAR(20)   NOT NULL DEFAULT '' COMMENT '操作人角色',
+    method       VARCHAR(10)   NOT NULL DEFAULT '' COMMENT '请求方法',
+    action       VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '操作 请求方法和路由 例如 PUT /v1/manager/user/liftban/:uid/:status',
+    path         VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '请求地址',
+    target       VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '操作对象',
+    before_value text                             COMMENT '修改前的值',
+    after_value  text                             COMMENT '修改后的值 没有单独记录时为请求内容',
+    ip           VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '操作人IP',
+    user_agent   VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '操作人设备',
+    status       integer       NOT NULL DEFAULT 0  COMMENT '响应状态码',
+    err_msg      VARCHAR(500)  NOT NULL DEFAULT '' COMMENT '失败原因',
+    latency      integer       NOT NULL DEFAULT 0  COMMENT '耗时（毫秒）',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX manager_log_created_at_idx on `manager_log` (created_at);
+CREATE INDEX manager_log_uid_idx on `manager_log` (uid, created_at);
+CREATE INDEX manager_log_target_idx on `manager_log` (target);
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+-- API密钥的角色带有权限范围 例如 apikey:read-stats,send-broadcast
+ALTER TABLE `manager_log` MODIFY COLUMN role VARCHAR(100) NOT NULL DEFAULT '' COMMENT '操作人角色';
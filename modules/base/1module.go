@@ -4,6 +4,7 @@ import (
 	"embed"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/app"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
 )
@@ -11,15 +12,40 @@ import (
 //go:embed sql
 var sqlFS embed.FS
 
+//go:embed swagger/app.yaml
+var appSwaggerContent string
+
+//go:embed swagger/sms.yaml
+var smsSwaggerContent string
+
 func init() {
 
 	register.AddModule(func(ctx interface{}) register.Module {
 
 		return register.Module{
+			Name: "app",
 			SetupAPI: func() register.APIRouter {
 				return app.New(ctx.(*config.Context))
 			},
-			SQLDir: register.NewSQLFS(sqlFS),
+			SQLDir:  register.NewSQLFS(sqlFS),
+			Swagger: appSwaggerContent,
+		}
+	})
+
+	register.AddModule(func(ctx interface{}) register.Module {
+		smsAPI := common.NewSMSAPI(ctx.(*config.Context))
+		return register.Module{
+			Name: "sms",
+			SetupAPI: func() register.APIRouter {
+				return smsAPI
+			},
+			Swagger: smsSwaggerContent,
+			Start: func() error {
+				return smsAPI.Start() // 短信服务商健康检查
+			},
+			Stop: func() error {
+				return smsAPI.Stop()
+			},
 		}
 	})
 }
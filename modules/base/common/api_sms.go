@@ -0,0 +1,344 @@
+package common
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SMSAPI 短信服务商回调和后台短信管理
+type SMSAPI struct {
+	ctx *config.Context
+	log.Log
+	templateDB    *smsTemplateDB
+	sendLogDB     *smsSendLogDB
+	healthChecker *SMSHealthChecker
+}
+
+// NewSMSAPI NewSMSAPI
+func NewSMSAPI(ctx *config.Context) *SMSAPI {
+	return &SMSAPI{
+		ctx:           ctx,
+		Log:           log.NewTLog("SMSAPI"),
+		templateDB:    newSMSTemplateDB(ctx.DB()),
+		sendLogDB:     newSMSSendLogDB(ctx.DB()),
+		healthChecker: NewSMSHealthChecker(ctx),
+	}
+}
+
+// Route 路由配置
+func (s *SMSAPI) Route(r *wkhttp.WKHttp) {
+	sms := r.Group("/v1/sms")
+	{
+		sms.POST("/twilio/status", s.twilioStatus) // twilio短信状态回调
+		sms.POST("/aliyun/report", s.aliyunReport) // 阿里云短信回执
+		sms.POST("/unisms/report", s.unismsReport) // unisms短信回执
+	}
+	auth := r.Group("/v1/manager", s.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/sms/templates", s.templates)             // 短信模版列表
+		auth.POST("/sms/templates", s.addTemplate)          // 新增短信模版
+		auth.PUT("/sms/templates/:id", s.updateTemplate)    // 修改短信模版
+		auth.DELETE("/sms/templates/:id", s.deleteTemplate) // 删除短信模版
+		auth.GET("/sms/logs", s.sendLogs)                   // 短信发送日志
+		auth.GET("/sms/logs/stats", s.sendLogStats)         // 短信发送统计
+		auth.GET("/sms/health", s.providerHealth)           // 短信服务商健康状态
+	}
+}
+
+// Start 开始短信服务商健康检查
+func (s *SMSAPI) Start() error {
+	return s.healthChecker.Start()
+}
+
+// Stop 停止短信服务商健康检查
+func (s *SMSAPI) Stop() error {
+	return s.healthChecker.Stop()
+}
+
+// twilio短信状态回调
+func (s *SMSAPI) twilioStatus(c *wkhttp.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		s.Error("解析twilio回调数据失败！", zap.Error(err))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	twilioCfg := extconfig.Get().TwilioSMS
+	// 没有配置签名需要的AuthToken和StatusCallback时不接收回调
+	if twilioCfg.AuthToken == "" || twilioCfg.StatusCallback == "" {
+		s.Warn("没有配置twilioSMS.authToken或twilioSMS.statusCallback，拒绝twilio回调！")
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	expected := twilioSignature(twilioCfg.AuthToken, twilioCfg.StatusCallback, c.Request.PostForm)
+	if !hmac.Equal([]byte(expected), []byte(c.GetHeader("X-Twilio-Signature"))) {
+		s.Warn("twilio回调签名错误！", zap.String("messageSid", c.PostForm("MessageSid")))
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	messageSid := c.PostForm("MessageSid")
+	messageStatus := c.PostForm("MessageStatus")
+	if messageStatus == "failed" || messageStatus == "undelivered" {
+		s.Warn("短信发送失败", zap.String("messageSid", messageSid), zap.String("status", messageStatus), zap.String("errorCode", c.PostForm("ErrorCode")), zap.String("to", c.PostForm("To")))
+		s.updateSendStatus(string(SMSProviderTwilio), messageSid, SMSSendStatusFailed, c.PostForm("ErrorCode"), messageStatus, 0)
+	} else {
+		s.Debug("短信状态", zap.String("messageSid", messageSid), zap.String("status", messageStatus))
+		if messageStatus == "delivered" {
+			s.updateSendStatus(string(SMSProviderTwilio), messageSid, SMSSendStatusDelivered, "", "", 0)
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// 阿里云短信回执（HTTP批量推送 https://help.aliyun.com/document_detail/101867.html）
+func (s *SMSAPI) aliyunReport(c *wkhttp.Context) {
+	if !s.checkReportToken(c) {
+		return
+	}
+	var reports []struct {
+		PhoneNumber string `json:"phone_number"`
+		Success     bool   `json:"success"`
+		ErrCode     string `json:"err_code"`
+		ErrMsg      string `json:"err_msg"`
+		BizID       string `json:"biz_id"`
+	}
+	if err := c.BindJSON(&reports); err != nil {
+		s.Error("解析阿里云回执数据失败！", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"code": 1, "msg": "数据格式有误"})
+		return
+	}
+	for _, report := range reports {
+		if report.Success {
+			s.updateSendStatus(string(config.SMSProviderAliyun), report.BizID, SMSSendStatusDelivered, "", "", 0)
+		} else {
+			s.Warn("短信发送失败", zap.String("bizId", report.BizID), zap.String("errCode", report.ErrCode), zap.String("errMsg", report.ErrMsg))
+			s.updateSendStatus(string(config.SMSProviderAliyun), report.BizID, SMSSendStatusFailed, report.ErrCode, report.ErrMsg, 0)
+		}
+	}
+	// 阿里云要求返回code为0 否则会重复推送
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "成功"})
+}
+
+// unisms短信回执
+func (s *SMSAPI) unismsReport(c *wkhttp.Context) {
+	if !s.checkReportToken(c) {
+		return
+	}
+	var report struct {
+		ID           string `json:"id"`
+		Status       string `json:"status"`
+		ErrorCode    string `json:"errorCode"`
+		ErrorMessage string `json:"errorMessage"`
+		Price        string `json:"price"`
+	}
+	if err := c.BindJSON(&report); err != nil {
+		s.Error("解析unisms回执数据失败！", zap.Error(err))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	cost, _ := strconv.ParseFloat(report.Price, 64)
+	switch report.Status {
+	case "delivered":
+		s.updateSendStatus(string(config.SMSProviderUnisms), report.ID, SMSSendStatusDelivered, "", "", cost)
+	case "undelivered", "failed", "rejected":
+		s.Warn("短信发送失败", zap.String("id", report.ID), zap.String("status", report.Status), zap.String("errorCode", report.ErrorCode), zap.String("errorMessage", report.ErrorMessage))
+		s.updateSendStatus(string(config.SMSProviderUnisms), report.ID, SMSSendStatusFailed, report.ErrorCode, report.ErrorMessage, cost)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// checkReportToken 校验回执地址的token参数 没有配置token时不接收回执
+func (s *SMSAPI) checkReportToken(c *wkhttp.Context) bool {
+	token := extconfig.Get().SMSReport.Token
+	if token == "" {
+		s.Warn("没有配置smsReport.token，拒绝短信回执！", zap.String("path", c.Request.URL.Path))
+		c.AbortWithStatus(http.StatusForbidden)
+		return false
+	}
+	if !hmac.Equal([]byte(token), []byte(c.Query("token"))) {
+		s.Warn("短信回执token错误！", zap.String("path", c.Request.URL.Path))
+		c.AbortWithStatus(http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (s *SMSAPI) updateSendStatus(provider, messageID string, status int, errCode, errMsg string, cost float64) {
+	if messageID == "" {
+		return
+	}
+	observeSMSDelivery(provider, status)
+	err := s.sendLogDB.updateStatus(provider, messageID, status, errCode, errMsg, cost)
+	if err != nil {
+		s.Error("更新短信发送状态失败！", zap.Error(err), zap.String("provider", provider), zap.String("messageId", messageID))
+	}
+}
+
+// 短信发送日志
+func (s *SMSAPI) sendLogs(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermLogRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	filter, err := s.sendLogFilter(c)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := s.sendLogDB.queryWithPage(filter, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		s.Error("查询短信发送日志错误", zap.Error(err))
+		c.ResponseError(errors.New("查询短信发送日志错误"))
+		return
+	}
+	count, err := s.sendLogDB.queryCount(filter)
+	if err != nil {
+		s.Error("查询短信发送日志数量错误", zap.Error(err))
+		c.ResponseError(errors.New("查询短信发送日志数量错误"))
+		return
+	}
+	list := make([]*smsSendLogResp, 0, len(models))
+	for _, m := range models {
+		list = append(list, &smsSendLogResp{
+			ID:        m.Id,
+			Provider:  m.Provider,
+			Zone:      m.Zone,
+			Phone:     m.Phone,
+			CodeType:  m.CodeType,
+			MessageID: m.MessageID,
+			Status:    m.Status,
+			ErrCode:   m.ErrCode,
+			ErrMsg:    m.ErrMsg,
+			Cost:      m.Cost,
+			Currency:  m.Currency,
+			Segments:  m.Segments,
+			Latency:   m.Latency,
+			CreatedAt: m.CreatedAt.String(),
+			UpdatedAt: m.UpdatedAt.String(),
+		})
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+// 短信发送统计（按服务商）
+func (s *SMSAPI) sendLogStats(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermStatsRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	filter, err := s.sendLogFilter(c)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := s.sendLogDB.queryStats(filter)
+	if err != nil {
+		s.Error("查询短信发送统计错误", zap.Error(err))
+		c.ResponseError(errors.New("查询短信发送统计错误"))
+		return
+	}
+	list := make([]*smsSendLogStatResp, 0, len(models))
+	for _, m := range models {
+		list = append(list, &smsSendLogStatResp{
+			Provider:   m.Provider,
+			Currency:   m.Currency,
+			Total:      m.Total,
+			Delivered:  m.Delivered,
+			Failed:     m.Failed,
+			Cost:       m.Cost,
+			AvgLatency: int64(m.AvgLatency),
+		})
+	}
+	c.Response(list)
+}
+
+func (s *SMSAPI) sendLogFilter(c *wkhttp.Context) (smsSendLogFilter, error) {
+	filter := smsSendLogFilter{
+		Provider: c.Query("provider"),
+		Phone:    c.Query("phone"),
+		CodeType: -1,
+	}
+	if c.Query("code_type") != "" {
+		codeType, err := strconv.Atoi(c.Query("code_type"))
+		if err != nil {
+			return filter, errors.New("验证码类型格式有误！")
+		}
+		filter.CodeType = codeType
+	}
+	if c.Query("status") != "" {
+		status, err := strconv.Atoi(c.Query("status"))
+		if err != nil {
+			return filter, errors.New("状态格式有误！")
+		}
+		filter.Status = status
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			return filter, errors.New("开始日期格式有误！")
+		}
+		filter.StartTime = start.Format("2006-01-02 15:04:05")
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			return filter, errors.New("结束日期格式有误！")
+		}
+		filter.EndTime = end.AddDate(0, 0, 1).Format("2006-01-02 15:04:05")
+	}
+	return filter, nil
+}
+
+type smsSendLogResp struct {
+	ID        int64   `json:"id"`
+	Provider  string  `json:"provider"`   // 短信服务商或通道
+	Zone      string  `json:"zone"`       // 区号
+	Phone     string  `json:"phone"`      // 手机号
+	CodeType  int     `json:"code_type"`  // 验证码类型
+	MessageID string  `json:"message_id"` // 服务商的消息ID
+	Status    int     `json:"status"`     // 状态 1.已提交 2.已送达 3.失败
+	ErrCode   string  `json:"err_code"`   // 错误码
+	ErrMsg    string  `json:"err_msg"`    // 错误信息
+	Cost      float64 `json:"cost"`       // 费用
+	Currency  string  `json:"currency"`   // 费用币种
+	Segments  int     `json:"segments"`   // 计费条数
+	Latency   int64   `json:"latency"`    // 发送接口耗时（毫秒）
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type smsSendLogStatResp struct {
+	Provider   string  `json:"provider"`    // 短信服务商或通道
+	Currency   string  `json:"currency"`    // 费用币种
+	Total      int64   `json:"total"`       // 发送次数
+	Delivered  int64   `json:"delivered"`   // 已送达次数
+	Failed     int64   `json:"failed"`      // 失败次数
+	Cost       float64 `json:"cost"`        // 总费用
+	AvgLatency int64   `json:"avg_latency"` // 平均发送接口耗时（毫秒）
+}
+
+// 短信服务商健康状态（最近一次健康检查的结果）
+func (s *SMSAPI) providerHealth(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermStatsRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(s.healthChecker.Results())
+}
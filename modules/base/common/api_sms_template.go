@@ -0,0 +1,221 @@
+package common
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 查询短信模版
+func (s *SMSAPI) templates(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	codeType := -1
+	if c.Query("code_type") != "" {
+		codeType, err = strconv.Atoi(c.Query("code_type"))
+		if err != nil {
+			c.ResponseError(errors.New("验证码类型格式有误！"))
+			return
+		}
+	}
+	models, err := s.templateDB.query(c.Query("provider"), codeType)
+	if err != nil {
+		s.Error("查询短信模版错误", zap.Error(err))
+		c.ResponseError(errors.New("查询短信模版错误"))
+		return
+	}
+	list := make([]*smsTemplateResp, 0, len(models))
+	for _, m := range models {
+		list = append(list, newSMSTemplateResp(m))
+	}
+	c.Response(list)
+}
+
+// 新增短信模版
+func (s *SMSAPI) addTemplate(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermOperationWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req smsTemplateReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	existModel, err := s.templateDB.queryWithKey(req.Provider, CodeType(req.CodeType), req.Locale)
+	if err != nil {
+		s.Error("查询短信模版错误", zap.Error(err))
+		c.ResponseError(errors.New("查询短信模版错误"))
+		return
+	}
+	if existModel != nil {
+		c.ResponseError(errors.New("该服务商、验证码类型和语言的模版已存在！"))
+		return
+	}
+	err = s.templateDB.insert(req.toModel())
+	if err != nil {
+		s.Error("新增短信模版错误", zap.Error(err))
+		c.ResponseError(errors.New("新增短信模版错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 修改短信模版
+func (s *SMSAPI) updateTemplate(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermOperationWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	var req smsTemplateReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := s.templateDB.queryWithID(id)
+	if err != nil {
+		s.Error("查询短信模版错误", zap.Error(err))
+		c.ResponseError(errors.New("查询短信模版错误"))
+		return
+	}
+	if model == nil {
+		c.ResponseError(errors.New("短信模版不存在！"))
+		return
+	}
+	existModel, err := s.templateDB.queryWithKey(req.Provider, CodeType(req.CodeType), req.Locale)
+	if err != nil {
+		s.Error("查询短信模版错误", zap.Error(err))
+		c.ResponseError(errors.New("查询短信模版错误"))
+		return
+	}
+	if existModel != nil && existModel.Id != model.Id {
+		c.ResponseError(errors.New("该服务商、验证码类型和语言的模版已存在！"))
+		return
+	}
+	newModel := req.toModel()
+	newModel.Id = model.Id
+	err = s.templateDB.update(newModel)
+	if err != nil {
+		s.Error("修改短信模版错误", zap.Error(err))
+		c.ResponseError(errors.New("修改短信模版错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 删除短信模版
+func (s *SMSAPI) deleteTemplate(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermOperationWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id <= 0 {
+		c.ResponseError(errors.New("模版ID不能为空！"))
+		return
+	}
+	err = s.templateDB.delete(id)
+	if err != nil {
+		s.Error("删除短信模版错误", zap.Error(err))
+		c.ResponseError(errors.New("删除短信模版错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// smsTemplateProviders 支持配置模版的短信服务商
+var smsTemplateProviders = []config.SMSProvider{
+	config.SMSProviderAliyun,
+	SMSProviderAliyunInternational,
+	config.SMSProviderUnisms,
+	SMSProviderTwilio,
+	SMSProviderTencent,
+	SMSProviderAWS,
+}
+
+type smsTemplateReq struct {
+	Provider string `json:"provider"`  // 短信服务商
+	CodeType int    `json:"code_type"` // 验证码类型
+	Locale   string `json:"locale"`    // 语言 为空表示默认
+	Template string `json:"template"`  // 模版 {appName}为应用名 {code}为验证码
+	Status   int    `json:"status"`    // 状态 0.禁用 1.启用
+}
+
+func (r *smsTemplateReq) check() error {
+	supported := false
+	for _, provider := range smsTemplateProviders {
+		if string(provider) == r.Provider {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return errors.New("不支持的短信服务商！")
+	}
+	if CodeType(r.CodeType).Name() == "" {
+		return errors.New("验证码类型有误！")
+	}
+	r.Locale = normalizeLocale(r.Locale)
+	r.Template = strings.TrimSpace(r.Template)
+	if r.Template == "" {
+		return errors.New("模版不能为空！")
+	}
+	if len(r.Template) > 1000 {
+		return errors.New("模版内容过长！")
+	}
+	return nil
+}
+
+func (r *smsTemplateReq) toModel() *smsTemplateModel {
+	return &smsTemplateModel{
+		Provider: r.Provider,
+		CodeType: r.CodeType,
+		Locale:   r.Locale,
+		Template: r.Template,
+		Status:   r.Status,
+	}
+}
+
+type smsTemplateResp struct {
+	ID        int64  `json:"id"`
+	Provider  string `json:"provider"`
+	CodeType  int    `json:"code_type"`
+	Locale    string `json:"locale"`
+	Template  string `json:"template"`
+	Status    int    `json:"status"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func newSMSTemplateResp(m *smsTemplateModel) *smsTemplateResp {
+	return &smsTemplateResp{
+		ID:        m.Id,
+		Provider:  m.Provider,
+		CodeType:  m.CodeType,
+		Locale:    m.Locale,
+		Template:  m.Template,
+		Status:    m.Status,
+		CreatedAt: m.CreatedAt.String(),
+		UpdatedAt: m.UpdatedAt.String(),
+	}
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMSTemplateReqCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    smsTemplateReq
+		err    string
+		locale string
+	}{
+		{name: "正常", req: smsTemplateReq{Provider: string(SMSProviderTwilio), CodeType: int(CodeTypeRegister), Locale: "zh_CN", Template: " {appName}验证码：{code} "}, locale: "zh-CN"},
+		{name: "默认语言", req: smsTemplateReq{Provider: string(config.SMSProviderAliyun), CodeType: int(CodeTypeBindEmail), Template: "SMS_1"}},
+		{name: "取第一个语言", req: smsTemplateReq{Provider: string(SMSProviderTencent), CodeType: int(CodeTypeRegister), Locale: "en-US,en;q=0.9", Template: "1001"}, locale: "en-US"},
+		{name: "服务商为空", req: smsTemplateReq{CodeType: int(CodeTypeRegister), Template: "1001"}, err: "不支持的短信服务商！"},
+		{name: "不支持的服务商", req: smsTemplateReq{Provider: "other", CodeType: int(CodeTypeRegister), Template: "1001"}, err: "不支持的短信服务商！"},
+		{name: "模拟短信不能配置模版", req: smsTemplateReq{Provider: string(SMSProviderMock), CodeType: int(CodeTypeRegister), Template: "1001"}, err: "不支持的短信服务商！"},
+		{name: "验证码类型有误", req: smsTemplateReq{Provider: string(SMSProviderTwilio), CodeType: 100, Template: "1001"}, err: "验证码类型有误！"},
+		{name: "验证码类型为负数", req: smsTemplateReq{Provider: string(SMSProviderTwilio), CodeType: -1, Template: "1001"}, err: "验证码类型有误！"},
+		{name: "模版为空", req: smsTemplateReq{Provider: string(SMSProviderTwilio), CodeType: int(CodeTypeRegister), Template: "  "}, err: "模版不能为空！"},
+		{name: "模版过长", req: smsTemplateReq{Provider: string(SMSProviderTwilio), CodeType: int(CodeTypeRegister), Template: strings.Repeat("a", 1001)}, err: "模版内容过长！"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.check()
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.locale, tt.req.Locale)
+			assert.Equal(t, strings.TrimSpace(tt.req.Template), tt.req.Template)
+		})
+	}
+}
+
+func TestSMSTemplateAPIValidation(t *testing.T) {
+	s := &SMSAPI{Log: log.NewTLog("SMSAPI")}
+	r := wkhttp.New()
+	// 用请求头模拟登录用户的角色
+	r.Use(func(c *wkhttp.Context) {
+		c.Set("role", c.GetHeader("X-Test-Role"))
+		c.Next()
+	})
+	auth := r.Group("/v1/manager")
+	auth.GET("/sms/templates", s.templates)
+	auth.POST("/sms/templates", s.addTemplate)
+	auth.PUT("/sms/templates/:id", s.updateTemplate)
+	auth.DELETE("/sms/templates/:id", s.deleteTemplate)
+
+	request := func(method, path, role, body string) string {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Test-Role", role)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var resp struct {
+			Msg string `json:"msg"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Msg
+	}
+	valid := `{"provider":"twilio","code_type":0,"template":"{code}"}`
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		role   string
+		body   string
+		err    string
+	}{
+		{name: "查询需要登录角色", method: http.MethodGet, path: "/v1/manager/sms/templates", err: "登录用户角色错误"},
+		{name: "查询需要配置查看权限", method: http.MethodGet, path: "/v1/manager/sms/templates", role: rbac.RoleSupport, err: "该用户无权执行此操作"},
+		{name: "查询验证码类型格式有误", method: http.MethodGet, path: "/v1/manager/sms/templates?code_type=a", role: rbac.RoleAuditor, err: "验证码类型格式有误！"},
+		{name: "新增需要运营配置权限", method: http.MethodPost, path: "/v1/manager/sms/templates", role: rbac.RoleAuditor, body: valid, err: "该用户无权执行此操作"},
+		{name: "新增数据格式有误", method: http.MethodPost, path: "/v1/manager/sms/templates", role: rbac.RoleAdmin, body: `{"provider":`, err: "请求数据格式有误！"},
+		{name: "新增不支持的服务商", method: http.MethodPost, path: "/v1/manager/sms/templates", role: rbac.RoleAdmin, body: `{"provider":"mock","code_type":0,"template":"{code}"}`, err: "不支持的短信服务商！"},
+		{name: "新增验证码类型有误", method: http.MethodPost, path: "/v1/manager/sms/templates", role: rbac.RoleSuperAdmin, body: `{"provider":"twilio","code_type":99,"template":"{code}"}`, err: "验证码类型有误！"},
+		{name: "新增模版为空", method: http.MethodPost, path: "/v1/manager/sms/templates", role: rbac.RoleAdmin, body: `{"provider":"twilio","code_type":0,"template":" "}`, err: "模版不能为空！"},
+		{name: "修改需要运营配置权限", method: http.MethodPut, path: "/v1/manager/sms/templates/1", role: rbac.RoleModerator, body: valid, err: "该用户无权执行此操作"},
+		{name: "修改数据格式有误", method: http.MethodPut, path: "/v1/manager/sms/templates/1", role: rbac.RoleAdmin, body: `[]`, err: "请求数据格式有误！"},
+		{name: "修改模版过长", method: http.MethodPut, path: "/v1/manager/sms/templates/1", role: rbac.RoleAdmin, body: `{"provider":"twilio","code_type":0,"template":"` + strings.Repeat("a", 1001) + `"}`, err: "模版内容过长！"},
+		{name: "删除需要运营配置权限", method: http.MethodDelete, path: "/v1/manager/sms/templates/1", role: rbac.RoleAuditor, err: "该用户无权执行此操作"},
+		{name: "删除模版ID有误", method: http.MethodDelete, path: "/v1/manager/sms/templates/a", role: rbac.RoleAdmin, err: "模版ID不能为空！"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.err, request(tt.method, tt.path, tt.role, tt.body))
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSMSReportRouter() *wkhttp.WKHttp {
+	s := &SMSAPI{Log: log.NewTLog("SMSAPI")}
+	r := wkhttp.New()
+	r.POST("/v1/sms/twilio/status", s.twilioStatus)
+	r.POST("/v1/sms/aliyun/report", s.aliyunReport)
+	r.POST("/v1/sms/unisms/report", s.unismsReport)
+	return r
+}
+
+func TestTwilioStatusSignature(t *testing.T) {
+	r := newTestSMSReportRouter()
+	form := url.Values{"MessageStatus": {"sent"}}
+	request := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/sms/twilio/status", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if signature != "" {
+			req.Header.Set("X-Twilio-Signature", signature)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	// 没有配置签名时不接收回调
+	assert.Equal(t, http.StatusForbidden, request(""))
+
+	cfg := &extconfig.Get().TwilioSMS
+	cfg.AuthToken, cfg.StatusCallback = "token", "https://api.example.com/v1/sms/twilio/status"
+	defer func() { cfg.AuthToken, cfg.StatusCallback = "", "" }()
+	assert.Equal(t, http.StatusForbidden, request(""))
+	assert.Equal(t, http.StatusForbidden, request("wrong"))
+	assert.Equal(t, http.StatusNoContent, request(twilioSignature(cfg.AuthToken, cfg.StatusCallback, form)))
+}
+
+func TestSMSReportToken(t *testing.T) {
+	r := newTestSMSReportRouter()
+	request := func(path string, body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w.Code
+	}
+	// 没有配置token时不接收回执
+	assert.Equal(t, http.StatusForbidden, request("/v1/sms/aliyun/report", `[]`))
+	assert.Equal(t, http.StatusForbidden, request("/v1/sms/unisms/report?token=", `{}`))
+
+	cfg := &extconfig.Get().SMSReport
+	cfg.Token = "secret"
+	defer func() { cfg.Token = "" }()
+	assert.Equal(t, http.StatusForbidden, request("/v1/sms/aliyun/report?token=wrong", `[]`))
+	assert.Equal(t, http.StatusOK, request("/v1/sms/aliyun/report?token=secret", `[]`))
+	assert.Equal(t, http.StatusNoContent, request("/v1/sms/unisms/report?token=secret", `{"status":"sending"}`))
+}
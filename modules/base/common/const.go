@@ -1,5 +1,31 @@
 package common
 
+import "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+
+const (
+	// SMSProviderAliyunInternational 阿里云国际短信（短信路由规则中使用，账号使用aliyunInternationalSMS的配置）
+	SMSProviderAliyunInternational config.SMSProvider = "aliyunInternational"
+	// SMSProviderTwilio twilio(https://www.twilio.com/docs/messaging/api/message-resource)
+	SMSProviderTwilio config.SMSProvider = "twilio"
+	// SMSProviderTencent 腾讯云短信(https://cloud.tencent.com/document/product/382)
+	SMSProviderTencent config.SMSProvider = "tencent"
+	// SMSProviderMock 模拟短信（测试环境使用，需要开启smsMock.enable）
+	SMSProviderMock config.SMSProvider = "mock"
+	// SMSProviderAWS aws sns(https://docs.aws.amazon.com/sns/latest/dg/sms_publish-to-phone.html)
+	SMSProviderAWS config.SMSProvider = "aws"
+)
+
+const (
+	// EmailProviderSMTP smtp
+	EmailProviderSMTP = "smtp"
+	// EmailProviderSES aws ses(https://docs.aws.amazon.com/ses/latest/APIReference/API_SendEmail.html)
+	EmailProviderSES = "ses"
+	// EmailProviderSendGrid sendgrid(https://docs.sendgrid.com/api-reference/mail-send/mail-send)
+	EmailProviderSendGrid = "sendgrid"
+	// EmailProviderMock 模拟邮件（测试环境使用，需要开启smsMock.enable）
+	EmailProviderMock = "mock"
+)
+
 // CodeType 验证码类型
 type CodeType int
 
@@ -14,9 +40,53 @@ const (
 	CodeTypeCheckMobile
 	// DestroyAccount 注销账号
 	CodeTypeDestroyAccount
+	// CodeTypeBindEmail 绑定邮箱
+	CodeTypeBindEmail
 )
 
+var codeTypeNames = map[CodeType]string{
+	CodeTypeRegister:       "register",
+	CodeTypePayPWD:         "payPWD",
+	CodeTypeForgetLoginPWD: "forgetLoginPWD",
+	CodeTypeCheckMobile:    "checkMobile",
+	CodeTypeDestroyAccount: "destroyAccount",
+	CodeTypeBindEmail:      "bindEmail",
+}
+
+// Name 验证码类型名（用于配置otp.codeTypes）
+func (c CodeType) Name() string {
+	return codeTypeNames[c]
+}
+
 const (
 	// CacheKeySMSCode 短信验证码的缓存key
 	CacheKeySMSCode string = "smscode:"
+	// CacheKeySMSCodeInterval 验证码发送间隔的缓存key
+	CacheKeySMSCodeInterval string = "smscode:interval:"
+	// CacheKeySMSCodeFailures 验证码验证失败次数的缓存key
+	CacheKeySMSCodeFailures string = "smscode:failures:"
+	// CacheKeySMSCodeSending 发送验证码的分布式锁key
+	CacheKeySMSCodeSending string = "smscode:sending:"
+	// CacheKeySMSQuota 短信发送量的缓存key
+	CacheKeySMSQuota string = "smsquota:"
+	// CacheKeySMSAbuse 短信防刷计数的缓存key
+	CacheKeySMSAbuse string = "smsabuse:"
+	// CacheKeySMSCodeLock 验证码锁定的缓存key
+	CacheKeySMSCodeLock string = "smscode:lock:"
+	// CacheKeyVoiceCodeInterval 语音验证码发送间隔的缓存key
+	CacheKeyVoiceCodeInterval string = "voicecode:interval:"
+	// CacheKeyVoiceCodeDaily 语音验证码每日次数的缓存key
+	CacheKeyVoiceCodeDaily string = "voicecode:daily:"
+)
+
+const (
+	// SMSSendStatusSent 已提交到服务商
+	SMSSendStatusSent = 1
+	// SMSSendStatusDelivered 已送达
+	SMSSendStatusDelivered = 2
+	// SMSSendStatusFailed 发送失败
+	SMSSendStatusFailed = 3
 )
+
+// smsRouteAnyZone 短信路由规则中匹配所有区号
+const smsRouteAnyZone = "*"
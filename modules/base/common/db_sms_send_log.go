@@ -0,0 +1,124 @@
+package common
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type smsSendLogDB struct {
+	session *dbr.Session
+}
+
+func newSMSSendLogDB(session *dbr.Session) *smsSendLogDB {
+	return &smsSendLogDB{
+		session: session,
+	}
+}
+
+func (s *smsSendLogDB) insert(m *smsSendLogModel) error {
+	_, err := s.session.InsertInto("sms_send_log").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+// updateStatus 根据服务商回执更新发送状态 费用有值时同时更新费用
+func (s *smsSendLogDB) updateStatus(provider, messageID string, status int, errCode, errMsg string, cost float64) error {
+	setMap := map[string]interface{}{
+		"status":     status,
+		"err_code":   errCode,
+		"err_msg":    errMsg,
+		"updated_at": time.Now(),
+	}
+	if cost > 0 {
+		setMap["cost"] = cost
+	}
+	_, err := s.session.Update("sms_send_log").SetMap(setMap).Where("provider=? and message_id=?", provider, messageID).Exec()
+	return err
+}
+
+func (s *smsSendLogDB) queryWithPage(filter smsSendLogFilter, pageSize, page uint64) ([]*smsSendLogModel, error) {
+	var models []*smsSendLogModel
+	_, err := filter.where(s.session.Select("*").From("sms_send_log")).Offset((page-1)*pageSize).Limit(pageSize).OrderDir("created_at", false).Load(&models)
+	return models, err
+}
+
+func (s *smsSendLogDB) queryCount(filter smsSendLogFilter) (int64, error) {
+	var count int64
+	_, err := filter.where(s.session.Select("count(*)").From("sms_send_log")).Load(&count)
+	return count, err
+}
+
+// queryStats 按服务商统计发送情况
+func (s *smsSendLogDB) queryStats(filter smsSendLogFilter) ([]*smsSendLogStatModel, error) {
+	var models []*smsSendLogStatModel
+	_, err := filter.where(s.session.Select(
+		"provider",
+		"currency",
+		"count(*) total",
+		"IFNULL(sum(status=2),0) delivered",
+		"IFNULL(sum(status=3),0) failed",
+		"IFNULL(sum(cost),0) cost",
+		"IFNULL(avg(latency),0) avg_latency",
+	).From("sms_send_log")).GroupBy("provider", "currency").Load(&models)
+	return models, err
+}
+
+// smsSendLogFilter 发送日志查询条件 为空的条件不过滤
+type smsSendLogFilter struct {
+	Provider  string
+	Phone     string
+	CodeType  int // 小于0不过滤
+	Status    int // 0不过滤
+	StartTime string
+	EndTime   string
+}
+
+func (f smsSendLogFilter) where(builder *dbr.SelectStmt) *dbr.SelectStmt {
+	if f.Provider != "" {
+		builder = builder.Where("provider=?", f.Provider)
+	}
+	if f.Phone != "" {
+		builder = builder.Where("phone=?", f.Phone)
+	}
+	if f.CodeType >= 0 {
+		builder = builder.Where("code_type=?", f.CodeType)
+	}
+	if f.Status > 0 {
+		builder = builder.Where("status=?", f.Status)
+	}
+	if f.StartTime != "" {
+		builder = builder.Where("created_at>=?", f.StartTime)
+	}
+	if f.EndTime != "" {
+		builder = builder.Where("created_at<?", f.EndTime)
+	}
+	return builder
+}
+
+type smsSendLogModel struct {
+	Provider  string
+	Zone      string
+	Phone     string
+	CodeType  int
+	MessageID string
+	Status    int
+	ErrCode   string
+	ErrMsg    string
+	Cost      float64
+	Currency  string
+	Segments  int
+	Latency   int64
+	db.BaseModel
+}
+
+type smsSendLogStatModel struct {
+	Provider   string
+	Currency   string
+	Total      int64
+	Delivered  int64
+	Failed     int64
+	Cost       float64
+	AvgLatency float64
+}
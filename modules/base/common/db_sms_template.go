@@ -0,0 +1,80 @@
+package common
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type smsTemplateDB struct {
+	session *dbr.Session
+}
+
+func newSMSTemplateDB(session *dbr.Session) *smsTemplateDB {
+	return &smsTemplateDB{
+		session: session,
+	}
+}
+
+// queryEnableWithLocales 查询服务商某个验证码类型指定语言的可用模版
+func (s *smsTemplateDB) queryEnableWithLocales(provider string, codeType CodeType, locales []string) ([]*smsTemplateModel, error) {
+	var models []*smsTemplateModel
+	_, err := s.session.Select("*").From("sms_template").Where("provider=? and code_type=? and status=1 and locale in ?", provider, codeType, locales).Load(&models)
+	return models, err
+}
+
+func (s *smsTemplateDB) queryWithID(id int64) (*smsTemplateModel, error) {
+	var m *smsTemplateModel
+	_, err := s.session.Select("*").From("sms_template").Where("id=?", id).Load(&m)
+	return m, err
+}
+
+func (s *smsTemplateDB) queryWithKey(provider string, codeType CodeType, locale string) (*smsTemplateModel, error) {
+	var m *smsTemplateModel
+	_, err := s.session.Select("*").From("sms_template").Where("provider=? and code_type=? and locale=?", provider, codeType, locale).Load(&m)
+	return m, err
+}
+
+// query 查询模版 provider为空查询所有服务商 codeType小于0查询所有类型
+func (s *smsTemplateDB) query(provider string, codeType int) ([]*smsTemplateModel, error) {
+	var models []*smsTemplateModel
+	builder := s.session.Select("*").From("sms_template")
+	if provider != "" {
+		builder = builder.Where("provider=?", provider)
+	}
+	if codeType >= 0 {
+		builder = builder.Where("code_type=?", codeType)
+	}
+	_, err := builder.OrderAsc("provider").OrderAsc("code_type").OrderAsc("locale").Load(&models)
+	return models, err
+}
+
+func (s *smsTemplateDB) insert(m *smsTemplateModel) error {
+	_, err := s.session.InsertInto("sms_template").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (s *smsTemplateDB) update(m *smsTemplateModel) error {
+	_, err := s.session.Update("sms_template").SetMap(map[string]interface{}{
+		"provider":  m.Provider,
+		"code_type": m.CodeType,
+		"locale":    m.Locale,
+		"template":  m.Template,
+		"status":    m.Status,
+	}).Where("id=?", m.Id).Exec()
+	return err
+}
+
+func (s *smsTemplateDB) delete(id int64) error {
+	_, err := s.session.DeleteFrom("sms_template").Where("id=?", id).Exec()
+	return err
+}
+
+type smsTemplateModel struct {
+	Provider string
+	CodeType int
+	Locale   string
+	Template string
+	Status   int
+	db.BaseModel
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
@@ -29,32 +30,40 @@ func NewAliyunProvider(ctx *config.Context) ISMSProvider {
 	}
 }
 
-func (a *AliyunProvider) SendSMS(ctx context.Context, zone, phone string, code string) error {
+func (a *AliyunProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	return a.SendSMSWithTemplate(ctx, zone, phone, code, "")
+}
+
+// SendSMSWithTemplate template为短信模版code 为空则使用aliyunSMS.templateCode
+func (a *AliyunProvider) SendSMSWithTemplate(ctx context.Context, zone, phone string, code string, template string) (*SMSSendResult, error) {
 	fmt.Println("AliyunProvider......")
 	span, _ := a.ctx.Tracer().StartSpanFromContext(ctx, "smsService.SendVerifyCode")
 	defer span.Finish()
 	client, err := dysmsapi.NewClientWithAccessKey("cn-hangzhou", a.ctx.GetConfig().AliyunSMS.AccessKeyID, a.ctx.GetConfig().AliyunSMS.AccessSecret)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	request := dysmsapi.CreateSendSmsRequest()
 	request.Scheme = "https"
 	request.PhoneNumbers = phone
 	request.SignName = a.ctx.GetConfig().AliyunSMS.SignName
 	request.TemplateCode = a.ctx.GetConfig().AliyunSMS.TemplateCode
+	if template != "" {
+		request.TemplateCode = template
+	}
 	request.TemplateParam = util.ToJson(map[string]interface{}{
 		"code": code,
 	})
 	response, err := client.SendSms(request)
 	if err != nil {
 		ext.LogError(span, err)
-		return err
+		return nil, err
 	}
 	if response.Code == "OK" {
 		fmt.Println("AliyunProvider......ok...")
-		return nil
+		return &SMSSendResult{MessageID: response.BizId}, nil
 	}
-	return errors.New(response.Message)
+	return nil, errors.New(response.Message)
 }
 
 type AliyunInternationalProvider struct {
@@ -70,28 +79,43 @@ func NewAliyunInternationalProvider(ctx *config.Context) ISMSProvider {
 	}
 }
 
-func (a *AliyunInternationalProvider) SendSMS(ctx context.Context, zone, phone string, code string) error {
+func (a *AliyunInternationalProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	return a.SendSMSWithTemplate(ctx, zone, phone, code, "")
+}
+
+// SendSMSWithTemplate template为短信内容 {appName}为应用名 {code}为验证码 为空则使用默认内容
+func (a *AliyunInternationalProvider) SendSMSWithTemplate(ctx context.Context, zone, phone string, code string, template string) (*SMSSendResult, error) {
 	span, _ := a.ctx.Tracer().StartSpanFromContext(ctx, "smsService.SendVerifyCode")
 	defer span.Finish()
 
+	message := fmt.Sprintf("【%s】您的验证码%s，该验证码5分钟内有效，请勿泄漏于他人！", a.ctx.GetConfig().AppName, code)
+	if template != "" {
+		message = strings.NewReplacer("{appName}", a.ctx.GetConfig().AppName, "{code}", code).Replace(template)
+	}
 	sendMessageToGlobeRequest := &sms_intl20180501.SendMessageToGlobeRequest{
 		To:      tea.String(fmt.Sprintf("%s%s", strings.TrimLeft(zone, "00"), phone)),
-		Message: tea.String(fmt.Sprintf("【%s】您的验证码%s，该验证码5分钟内有效，请勿泄漏于他人！", a.ctx.GetConfig().AppName, code)),
+		Message: tea.String(message),
 		Type:    tea.String("OTP"),
 	}
 	client, err := a.createClient()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	response, err := client.SendMessageToGlobe(sendMessageToGlobeRequest)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	result := &SMSSendResult{
+		MessageID: tea.StringValue(response.Body.MessageId),
+	}
+	if segments, err := strconv.Atoi(tea.StringValue(response.Body.Segments)); err == nil {
+		result.Segments = segments
+	}
 	if *response.Body.ResponseCode == "OK" {
-		return nil
+		return result, nil
 	}
-	return nil
+	return result, nil
 }
 
 // 初始化账号Client
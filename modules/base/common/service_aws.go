@@ -0,0 +1,86 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+type AWSProvider struct {
+	ctx *config.Context
+	log.Log
+}
+
+// NewAWSProvider 创建aws sns短信服务
+func NewAWSProvider(ctx *config.Context) ISMSProvider {
+	return &AWSProvider{
+		ctx: ctx,
+		Log: log.NewTLog("AWSProvider"),
+	}
+}
+
+func (a *AWSProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	return a.SendSMSWithTemplate(ctx, zone, phone, code, "")
+}
+
+// SendSMSWithTemplate template为短信内容 为空则使用awsSMS.template
+func (a *AWSProvider) SendSMSWithTemplate(ctx context.Context, zone, phone string, code string, template string) (*SMSSendResult, error) {
+	span, _ := a.ctx.Tracer().StartSpanFromContext(ctx, "smsService.SendVerifyCode")
+	defer span.Finish()
+
+	awsCfg := extconfig.Get().AWSSMS
+	awsConfig := aws.NewConfig().WithRegion(awsCfg.Region)
+	if awsCfg.AccessKeyID != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(awsCfg.AccessKeyID, awsCfg.SecretAccessKey, ""))
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		a.Error("创建aws会话失败！", zap.Error(err))
+		return nil, err
+	}
+
+	attributes := map[string]*sns.MessageAttributeValue{
+		"AWS.SNS.SMS.SMSType": {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(awsCfg.SMSType),
+		},
+	}
+	if awsCfg.SenderID != "" {
+		attributes["AWS.SNS.SMS.SenderID"] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(awsCfg.SenderID),
+		}
+	}
+	if awsCfg.OriginationNumber != "" {
+		attributes["AWS.MM.SMS.OriginationNumber"] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(awsCfg.OriginationNumber),
+		}
+	}
+	if template == "" {
+		template = awsCfg.Template
+	}
+	message := strings.NewReplacer("{appName}", a.ctx.GetConfig().AppName, "{code}", code).Replace(template)
+	output, err := sns.New(sess).PublishWithContext(ctx, &sns.PublishInput{
+		PhoneNumber:       aws.String(toE164(zone, phone)),
+		Message:           aws.String(message),
+		MessageAttributes: attributes,
+	})
+	if err != nil {
+		ext.LogError(span, err)
+		a.Error("发送短信失败！", zap.Error(err))
+		return nil, errors.New("短信发送失败，请稍后再试！")
+	}
+	a.Info("发送短信成功", zap.String("messageId", aws.StringValue(output.MessageId)))
+	return &SMSSendResult{MessageID: aws.StringValue(output.MessageId)}, nil
+}
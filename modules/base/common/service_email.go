@@ -0,0 +1,168 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// IEmailProvider 邮件服务商
+type IEmailProvider interface {
+	// SendEmail 发送邮件 body为HTML 返回服务商的消息ID
+	SendEmail(ctx context.Context, to, subject, body string) (string, error)
+}
+
+// NewEmailProvider 根据配置创建邮件服务商 未配置返回nil
+func NewEmailProvider(ctx *config.Context) IEmailProvider {
+	switch extconfig.Get().Email.Provider {
+	case EmailProviderSMTP:
+		return NewSMTPEmailProvider(ctx)
+	case EmailProviderSES:
+		return NewSESEmailProvider(ctx)
+	case EmailProviderSendGrid:
+		return NewSendGridEmailProvider(ctx)
+	case EmailProviderMock:
+		return NewMockEmailProvider(ctx)
+	}
+	return nil
+}
+
+// IEmailService 邮件验证码服务
+type IEmailService interface {
+	// 发送验证码
+	SendVerifyCode(ctx context.Context, email string, codeType CodeType) error
+	// 验证验证码(销毁缓存)
+	Verify(ctx context.Context, email, code string, codeType CodeType) error
+}
+
+// EmailService 邮件验证码服务 与短信验证码使用相同的验证码缓存、错误次数和锁定规则
+type EmailService struct {
+	ctx *config.Context
+	log.Log
+	codeStore *verifyCodeStore
+}
+
+// NewEmailService 创建邮件验证码服务
+func NewEmailService(ctx *config.Context) *EmailService {
+	return &EmailService{
+		ctx:       ctx,
+		Log:       log.NewTLog("EmailService"),
+		codeStore: newVerifyCodeStore(db.NewRedis(ctx.GetConfig().DB.RedisAddr, ctx.GetConfig().DB.RedisPass)),
+	}
+}
+
+// SendVerifyCode 发送邮件验证码
+func (e *EmailService) SendVerifyCode(ctx context.Context, email string, codeType CodeType) error {
+	span, ctx := e.ctx.Tracer().StartSpanFromContext(ctx, "emailService.SendVerifyCode")
+	defer span.Finish()
+
+	email = NormalizeEmail(email)
+	emailProvider := NewEmailProvider(e.ctx)
+	if emailProvider == nil {
+		return errors.New("未开启邮件验证码！")
+	}
+	subject := emailSubject(email)
+	release, err := e.codeStore.lockSending(codeType, subject)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := e.codeStore.checkLocked(codeType, subject); err != nil {
+		return err
+	}
+	if err := e.codeStore.checkInterval(codeType, subject); err != nil {
+		return err
+	}
+	verifyCode, revoke, err := e.codeStore.issue(codeType, subject)
+	if err != nil {
+		return err
+	}
+	emailCfg := extconfig.Get().Email
+	otpParams := extconfig.Get().OTP.Params(codeType.Name())
+	replacer := strings.NewReplacer(
+		"{appName}", e.ctx.GetConfig().AppName,
+		"{code}", verifyCode,
+		"{minutes}", fmt.Sprintf("%d", int(otpParams.TTL.Minutes())),
+	)
+	messageID, err := emailProvider.SendEmail(ctx, email, replacer.Replace(emailCfg.Subject), replacer.Replace(emailCfg.Template))
+	if err != nil {
+		e.Error("发送邮件验证码失败！", zap.Error(err), zap.String("provider", emailCfg.Provider), zap.String("email", email))
+		revoke()
+		return err
+	}
+	e.Info("发送邮件验证码成功", zap.String("provider", emailCfg.Provider), zap.String("messageId", messageID))
+	return nil
+}
+
+// Verify 验证邮件验证码
+func (e *EmailService) Verify(ctx context.Context, email, code string, codeType CodeType) error {
+	span, _ := e.ctx.Tracer().StartSpanFromContext(ctx, "emailService.Verify")
+	defer span.Finish()
+
+	return e.codeStore.verify(codeType, emailSubject(NormalizeEmail(email)), code)
+}
+
+// NormalizeEmail 去掉空格并转为小写
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CheckEmail 检查邮箱格式
+func CheckEmail(email string) error {
+	if email == "" {
+		return errors.New("邮箱不能为空！")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 100 {
+		return errors.New("邮箱格式有误！")
+	}
+	return nil
+}
+
+// emailSubject 邮件验证码的接收者（区号只有数字 不会与短信验证码冲突）
+func emailSubject(email string) string {
+	return fmt.Sprintf("email@%s", email)
+}
+
+// emailFrom 发件人 配置了发件人名称时为 名称 <邮箱>
+func emailFrom(ctx *config.Context) (string, string) {
+	emailCfg := extconfig.Get().Email
+	name := emailCfg.FromName
+	if name == "" {
+		name = ctx.GetConfig().AppName
+	}
+	return name, emailCfg.From
+}
+
+// MockEmailProvider 模拟邮件 不发送邮件只打印验证码（测试环境使用 需要开启smsMock.enable）
+type MockEmailProvider struct {
+	ctx *config.Context
+	log.Log
+}
+
+// NewMockEmailProvider 创建模拟邮件服务
+func NewMockEmailProvider(ctx *config.Context) IEmailProvider {
+	return &MockEmailProvider{
+		ctx: ctx,
+		Log: log.NewTLog("MockEmailProvider"),
+	}
+}
+
+func (m *MockEmailProvider) SendEmail(ctx context.Context, to, subject, body string) (string, error) {
+	if !extconfig.Get().SMSMock.Enable {
+		m.Error("未开启模拟短信，不能使用mock邮件服务商！")
+		return "", errors.New("邮件服务商配置有误！")
+	}
+	m.Warn("模拟发送邮件", zap.String("to", to), zap.String("subject", subject), zap.String("body", body))
+	return "mock-" + util.GenerUUID(), nil
+}
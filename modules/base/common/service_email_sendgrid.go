@@ -0,0 +1,94 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+const sendGridAPIURL = "https://api.sendgrid.com/v3/mail/send"
+
+type SendGridEmailProvider struct {
+	ctx *config.Context
+	log.Log
+	client *http.Client
+}
+
+// NewSendGridEmailProvider 创建sendgrid邮件服务
+func NewSendGridEmailProvider(ctx *config.Context) IEmailProvider {
+	return &SendGridEmailProvider{
+		ctx:    ctx,
+		Log:    log.NewTLog("SendGridEmailProvider"),
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (s *SendGridEmailProvider) SendEmail(ctx context.Context, to, subject, body string) (string, error) {
+	span, _ := s.ctx.Tracer().StartSpanFromContext(ctx, "emailService.SendVerifyCode")
+	defer span.Finish()
+
+	emailCfg := extconfig.Get().Email
+	if emailCfg.SendGrid.APIKey == "" || emailCfg.From == "" {
+		return "", errors.New("没有配置sendgrid！")
+	}
+	fromName, from := emailFrom(s.ctx)
+	payload, _ := json.Marshal(map[string]interface{}{
+		"personalizations": []map[string]interface{}{
+			{
+				"to": []map[string]string{
+					{"email": to},
+				},
+			},
+		},
+		"from": map[string]string{
+			"email": from,
+			"name":  fromName,
+		},
+		"subject": subject,
+		"content": []map[string]string{
+			{"type": "text/html", "value": body},
+		},
+	})
+	req, err := http.NewRequest(http.MethodPost, sendGridAPIURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+emailCfg.SendGrid.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		ext.LogError(span, err)
+		s.Error("发送sendgrid邮件失败！", zap.Error(err))
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		var result sendGridErrorResp
+		respBody, _ := io.ReadAll(resp.Body)
+		_ = json.Unmarshal(respBody, &result)
+		if len(result.Errors) > 0 {
+			s.Error("发送sendgrid邮件失败！", zap.Int("status", resp.StatusCode), zap.String("message", result.Errors[0].Message), zap.String("field", result.Errors[0].Field))
+			return "", errors.New(result.Errors[0].Message)
+		}
+		s.Error("发送sendgrid邮件失败！", zap.Int("status", resp.StatusCode), zap.String("body", string(respBody)))
+		return "", errors.New("发送sendgrid邮件失败！")
+	}
+	return resp.Header.Get("X-Message-Id"), nil
+}
+
+type sendGridErrorResp struct {
+	Errors []struct {
+		Message string `json:"message"`
+		Field   string `json:"field"`
+	} `json:"errors"`
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+type SESEmailProvider struct {
+	ctx *config.Context
+	log.Log
+	client *http.Client
+}
+
+// NewSESEmailProvider 创建aws ses邮件服务
+func NewSESEmailProvider(ctx *config.Context) IEmailProvider {
+	return &SESEmailProvider{
+		ctx:    ctx,
+		Log:    log.NewTLog("SESEmailProvider"),
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (s *SESEmailProvider) SendEmail(ctx context.Context, to, subject, body string) (string, error) {
+	span, _ := s.ctx.Tracer().StartSpanFromContext(ctx, "emailService.SendVerifyCode")
+	defer span.Finish()
+
+	emailCfg := extconfig.Get().Email
+	if emailCfg.From == "" {
+		return "", errors.New("没有配置发件人邮箱！")
+	}
+	sesCfg := emailCfg.SES
+	awsConfig := aws.NewConfig().WithRegion(sesCfg.Region).WithHTTPClient(s.client)
+	if sesCfg.AccessKeyID != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(sesCfg.AccessKeyID, sesCfg.SecretAccessKey, ""))
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		s.Error("创建aws会话失败！", zap.Error(err))
+		return "", err
+	}
+	fromName, from := emailFrom(s.ctx)
+	output, err := ses.New(sess).SendEmailWithContext(ctx, &ses.SendEmailInput{
+		Source: aws.String((&mail.Address{Name: fromName, Address: from}).String()),
+		Destination: &ses.Destination{
+			ToAddresses: []*string{aws.String(to)},
+		},
+		Message: &ses.Message{
+			Subject: &ses.Content{
+				Charset: aws.String("UTF-8"),
+				Data:    aws.String(subject),
+			},
+			Body: &ses.Body{
+				Html: &ses.Content{
+					Charset: aws.String("UTF-8"),
+					Data:    aws.String(body),
+				},
+			},
+		},
+	})
+	if err != nil {
+		ext.LogError(span, err)
+		s.Error("发送ses邮件失败！", zap.Error(err))
+		return "", err
+	}
+	return aws.StringValue(output.MessageId), nil
+}
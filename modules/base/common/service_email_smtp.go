@@ -0,0 +1,112 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+type SMTPEmailProvider struct {
+	ctx *config.Context
+	log.Log
+}
+
+// NewSMTPEmailProvider 创建smtp邮件服务
+func NewSMTPEmailProvider(ctx *config.Context) IEmailProvider {
+	return &SMTPEmailProvider{
+		ctx: ctx,
+		Log: log.NewTLog("SMTPEmailProvider"),
+	}
+}
+
+func (s *SMTPEmailProvider) SendEmail(ctx context.Context, to, subject, body string) (string, error) {
+	span, _ := s.ctx.Tracer().StartSpanFromContext(ctx, "emailService.SendVerifyCode")
+	defer span.Finish()
+
+	emailCfg := extconfig.Get().Email
+	smtpCfg := emailCfg.SMTP
+	if smtpCfg.Host == "" || emailCfg.From == "" {
+		return "", errors.New("没有配置smtp！")
+	}
+	fromName, from := emailFrom(s.ctx)
+	messageID := fmt.Sprintf("<%s@%s>", util.GenerUUID(), smtpCfg.Host)
+
+	var msg bytes.Buffer
+	msg.WriteString(fmt.Sprintf("From: %s\r\n", (&mail.Address{Name: fromName, Address: from}).String()))
+	msg.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject)))
+	msg.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	msg.WriteString(fmt.Sprintf("Message-ID: %s\r\n", messageID))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(body)
+
+	err := s.send(smtpCfg, from, to, msg.Bytes())
+	if err != nil {
+		ext.LogError(span, err)
+		s.Error("发送smtp邮件失败！", zap.Error(err))
+		return "", err
+	}
+	return messageID, nil
+}
+
+// send 使用SSL时直接建立TLS连接 否则由smtp.SendMail在服务器支持时使用STARTTLS
+func (s *SMTPEmailProvider) send(smtpCfg extconfig.EmailSMTPConfig, from, to string, msg []byte) error {
+	addr := net.JoinHostPort(smtpCfg.Host, strconv.Itoa(smtpCfg.Port))
+	var auth smtp.Auth
+	if smtpCfg.Username != "" {
+		auth = smtp.PlainAuth("", smtpCfg.Username, smtpCfg.Password, smtpCfg.Host)
+	}
+	if !smtpCfg.SSL {
+		return smtp.SendMail(addr, auth, from, []string{to}, msg)
+	}
+	dialer := &net.Dialer{Timeout: time.Second * 10}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: smtpCfg.Host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, smtpCfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if auth != nil {
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(from); err != nil {
+		return err
+	}
+	if err = client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
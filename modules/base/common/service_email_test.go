@@ -0,0 +1,259 @@
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	assert.Equal(t, "user@example.com", NormalizeEmail("  User@Example.COM "))
+	assert.NoError(t, CheckEmail("user@example.com"))
+	assert.Error(t, CheckEmail(""))
+	assert.Error(t, CheckEmail("Name <user@example.com>"))
+	assert.Error(t, CheckEmail("user"))
+}
+
+func TestSendGridEmailProviderSend(t *testing.T) {
+	cfg := config.New()
+	cfg.AppName = "tsdd"
+	emailCfg := &extconfig.Get().Email
+	old := *emailCfg
+	defer func() { *emailCfg = old }()
+
+	provider := NewSendGridEmailProvider(testutil.NewTestContext(cfg)).(*SendGridEmailProvider)
+	*emailCfg = extconfig.EmailConfig{From: "noreply@example.com"}
+	_, err := provider.SendEmail(context.Background(), "user@example.com", "subject", "body")
+	assert.EqualError(t, err, "没有配置sendgrid！")
+
+	emailCfg.SendGrid.APIKey = "SG.key"
+	provider.client = newRedirectClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/mail/send", r.URL.Path)
+		assert.Equal(t, "Bearer SG.key", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req struct {
+			Personalizations []struct {
+				To []map[string]string `json:"to"`
+			} `json:"personalizations"`
+			From    map[string]string   `json:"from"`
+			Subject string              `json:"subject"`
+			Content []map[string]string `json:"content"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		to := req.Personalizations[0].To[0]["email"]
+		if to != "user@example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []map[string]string{{"message": "Does not contain a valid address.", "field": "personalizations.0.to.0.email"}}})
+			return
+		}
+		assert.Equal(t, map[string]string{"email": "noreply@example.com", "name": "tsdd"}, req.From)
+		assert.Equal(t, "验证码", req.Subject)
+		assert.Equal(t, []map[string]string{{"type": "text/html", "value": "<b>123456</b>"}}, req.Content)
+		w.Header().Set("X-Message-Id", "sg-1")
+		w.WriteHeader(http.StatusAccepted)
+	})
+	messageID, err := provider.SendEmail(context.Background(), "user@example.com", "验证码", "<b>123456</b>")
+	assert.NoError(t, err)
+	assert.Equal(t, "sg-1", messageID)
+
+	_, err = provider.SendEmail(context.Background(), "bad", "验证码", "<b>123456</b>")
+	assert.EqualError(t, err, "Does not contain a valid address.")
+}
+
+func TestSESEmailProviderSend(t *testing.T) {
+	cfg := config.New()
+	cfg.AppName = "tsdd"
+	emailCfg := &extconfig.Get().Email
+	old := *emailCfg
+	defer func() { *emailCfg = old }()
+
+	provider := NewSESEmailProvider(testutil.NewTestContext(cfg)).(*SESEmailProvider)
+	*emailCfg = extconfig.EmailConfig{}
+	_, err := provider.SendEmail(context.Background(), "user@example.com", "subject", "body")
+	assert.EqualError(t, err, "没有配置发件人邮箱！")
+
+	*emailCfg = extconfig.EmailConfig{From: "noreply@example.com", FromName: "TSDD"}
+	emailCfg.SES.Region = "us-east-1"
+	emailCfg.SES.AccessKeyID = "AKID"
+	emailCfg.SES.SecretAccessKey = "secret"
+	t.Setenv("AWS_CA_BUNDLE", "") // 自定义的CA需要http.Transport 测试使用的是转发到测试服务器的Transport
+	provider.client = newRedirectClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/ses/aws4_request")
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "SendEmail", r.PostForm.Get("Action"))
+		assert.Equal(t, `"TSDD" <noreply@example.com>`, r.PostForm.Get("Source"))
+		assert.Equal(t, "user@example.com", r.PostForm.Get("Destination.ToAddresses.member.1"))
+		assert.Equal(t, "验证码", r.PostForm.Get("Message.Subject.Data"))
+		assert.Equal(t, "UTF-8", r.PostForm.Get("Message.Body.Html.Charset"))
+		assert.Equal(t, "<b>123456</b>", r.PostForm.Get("Message.Body.Html.Data"))
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<SendEmailResponse xmlns="http://ses.amazonaws.com/doc/2010-12-01/"><SendEmailResult><MessageId>ses-1</MessageId></SendEmailResult></SendEmailResponse>`))
+	})
+	messageID, err := provider.SendEmail(context.Background(), "user@example.com", "验证码", "<b>123456</b>")
+	assert.NoError(t, err)
+	assert.Equal(t, "ses-1", messageID)
+}
+
+// smtpMail 测试smtp服务器收到的邮件
+type smtpMail struct {
+	auth string
+	from string
+	to   string
+	data string
+}
+
+// newTestSMTPServer 只处理一封邮件的smtp服务器 返回端口
+func newTestSMTPServer(t *testing.T) (int, <-chan *smtpMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	mails := make(chan *smtpMail, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		m := &smtpMail{}
+		_ = tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch cmd {
+			case "EHLO":
+				_ = tp.PrintfLine("250-localhost")
+				_ = tp.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				m.auth = strings.TrimPrefix(line, "AUTH PLAIN ")
+				_ = tp.PrintfLine("235 2.7.0 Authentication successful")
+			case "MAIL":
+				m.from = line
+				_ = tp.PrintfLine("250 OK")
+			case "RCPT":
+				m.to = line
+				_ = tp.PrintfLine("250 OK")
+			case "DATA":
+				_ = tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				m.data = string(data)
+				_ = tp.PrintfLine("250 OK")
+			case "QUIT":
+				_ = tp.PrintfLine("221 Bye")
+				mails <- m
+				return
+			default:
+				_ = tp.PrintfLine("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, mails
+}
+
+func TestSMTPEmailProviderSend(t *testing.T) {
+	cfg := config.New()
+	cfg.AppName = "tsdd"
+	emailCfg := &extconfig.Get().Email
+	old := *emailCfg
+	defer func() { *emailCfg = old }()
+
+	provider := NewSMTPEmailProvider(testutil.NewTestContext(cfg))
+	*emailCfg = extconfig.EmailConfig{From: "noreply@example.com"}
+	_, err := provider.SendEmail(context.Background(), "user@example.com", "subject", "body")
+	assert.EqualError(t, err, "没有配置smtp！")
+
+	port, mails := newTestSMTPServer(t)
+	emailCfg.SMTP = extconfig.EmailSMTPConfig{Host: "127.0.0.1", Port: port, Username: "user", Password: "pass"}
+	messageID, err := provider.SendEmail(context.Background(), "user@example.com", "验证码", "<b>123456</b>")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(messageID, "@127.0.0.1>"))
+
+	var m *smtpMail
+	select {
+	case m = <-mails:
+	case <-time.After(time.Second * 5):
+		t.Fatal("没有收到邮件")
+	}
+	auth, _ := base64.StdEncoding.DecodeString(m.auth)
+	assert.Equal(t, "\x00user\x00pass", string(auth))
+	assert.Equal(t, "MAIL FROM:<noreply@example.com>", m.from)
+	assert.Equal(t, "RCPT TO:<user@example.com>", m.to)
+	headers, body, _ := strings.Cut(m.data, "\n\n")
+	assert.Contains(t, headers, `From: "tsdd" <noreply@example.com>`)
+	assert.Contains(t, headers, "To: user@example.com")
+	assert.Contains(t, headers, "Subject: =?UTF-8?b?6aqM6K+B56CB?=")
+	assert.Contains(t, headers, "Message-ID: "+messageID)
+	assert.Contains(t, headers, "Content-Type: text/html; charset=UTF-8")
+	assert.Equal(t, "<b>123456</b>\n", body)
+}
+
+func TestEmailSendVerifyCode(t *testing.T) {
+	emailCfg := &extconfig.Get().Email
+	oldEmail := *emailCfg
+	mockCfg := &extconfig.Get().SMSMock
+	oldMock := *mockCfg
+	defer func() {
+		*emailCfg = oldEmail
+		*mockCfg = oldMock
+	}()
+	emailCfg.Provider = EmailProviderMock
+	mockCfg.Enable = true
+	mockCfg.UniversalCode = ""
+
+	cache := newMemCodeCache()
+	e := &EmailService{
+		ctx:       testutil.NewTestContext(config.New()),
+		Log:       log.NewTLog("EmailService"),
+		codeStore: newTestCodeStore(cache),
+	}
+	otpParams := extconfig.Get().OTP.Params(CodeTypeRegister.Name())
+	codeKey := e.codeStore.key(CacheKeySMSCode, CodeTypeRegister, emailSubject("user@example.com"))
+
+	// 邮箱不区分大小写和前后空格
+	assert.NoError(t, e.SendVerifyCode(context.Background(), " User@Example.com", CodeTypeRegister))
+	code, _ := cache.GetString(codeKey)
+	assert.Len(t, code, otpParams.Length)
+	assert.ErrorIs(t, e.SendVerifyCode(context.Background(), "user@example.com", CodeTypeRegister), errcode.ErrVerifyCodeTooFrequent)
+	assert.NoError(t, e.Verify(context.Background(), "USER@example.com ", code, CodeTypeRegister))
+	assert.Error(t, e.Verify(context.Background(), "user@example.com", code, CodeTypeRegister))
+
+	// 错误次数过多时作废验证码并锁定
+	cache.advance(otpParams.Interval)
+	assert.NoError(t, e.SendVerifyCode(context.Background(), "user@example.com", CodeTypeRegister))
+	code, _ = cache.GetString(codeKey)
+	wrong := strings.Repeat("x", len(code))
+	var err error
+	for i := 0; i < otpParams.MaxFailures; i++ {
+		err = e.Verify(context.Background(), "user@example.com", wrong, CodeTypeRegister)
+	}
+	assert.EqualError(t, err, "验证码错误次数过多，请稍后再试！")
+	assert.Error(t, e.Verify(context.Background(), "user@example.com", code, CodeTypeRegister))
+	cache.advance(otpParams.LockDuration)
+	assert.NoError(t, e.SendVerifyCode(context.Background(), "user@example.com", CodeTypeRegister))
+
+	// 发送失败时可以马上重试
+	cache.advance(otpParams.TTL)
+	mockCfg.Enable = false
+	assert.Error(t, e.SendVerifyCode(context.Background(), "other@example.com", CodeTypeRegister))
+	assert.NoError(t, e.codeStore.checkInterval(CodeTypeRegister, emailSubject("other@example.com")))
+}
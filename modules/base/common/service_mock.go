@@ -0,0 +1,35 @@
+package common
+
+import (
+	"context"
+	"errors"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// MockProvider 模拟短信 不发送短信只打印验证码（测试环境使用 需要开启smsMock.enable）
+type MockProvider struct {
+	ctx *config.Context
+	log.Log
+}
+
+// NewMockProvider 创建模拟短信服务
+func NewMockProvider(ctx *config.Context) ISMSProvider {
+	return &MockProvider{
+		ctx: ctx,
+		Log: log.NewTLog("MockProvider"),
+	}
+}
+
+func (m *MockProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	if !extconfig.Get().SMSMock.Enable {
+		m.Error("未开启模拟短信，不能使用mock短信服务商！")
+		return nil, errors.New("短信服务商配置有误！")
+	}
+	m.Warn("模拟发送验证码", zap.String("zone", zone), zap.String("phone", phone), zap.String("code", code))
+	return &SMSSendResult{MessageID: "mock-" + util.GenerUUID()}, nil
+}
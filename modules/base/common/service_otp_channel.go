@@ -0,0 +1,205 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+const (
+	// OTPChannelWhatsApp WhatsApp Business Cloud API(https://developers.facebook.com/docs/whatsapp/business-management-api/authentication-templates)
+	OTPChannelWhatsApp = "whatsapp"
+	// OTPChannelTelegram Telegram Gateway(https://core.telegram.org/gateway/api)
+	OTPChannelTelegram = "telegram"
+)
+
+const (
+	whatsAppAPIURL     = "https://graph.facebook.com/v19.0"
+	telegramGatewayURL = "https://gatewayapi.telegram.org"
+)
+
+// NewOTPChannelProvider 根据通道名创建验证码发送者 不支持的通道返回nil
+func NewOTPChannelProvider(ctx *config.Context, channel string) ISMSProvider {
+	switch channel {
+	case OTPChannelWhatsApp:
+		return NewWhatsAppProvider(ctx)
+	case OTPChannelTelegram:
+		return NewTelegramProvider(ctx)
+	}
+	return nil
+}
+
+type WhatsAppProvider struct {
+	ctx *config.Context
+	log.Log
+	client *http.Client
+}
+
+// NewWhatsAppProvider 创建WhatsApp验证码发送服务
+func NewWhatsAppProvider(ctx *config.Context) ISMSProvider {
+	return &WhatsAppProvider{
+		ctx:    ctx,
+		Log:    log.NewTLog("WhatsAppProvider"),
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (w *WhatsAppProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	span, _ := w.ctx.Tracer().StartSpanFromContext(ctx, "smsService.SendVerifyCode")
+	defer span.Finish()
+
+	whatsAppCfg := extconfig.Get().OTPChannel.WhatsApp
+	if whatsAppCfg.Token == "" || whatsAppCfg.PhoneNumberID == "" || whatsAppCfg.TemplateName == "" {
+		return nil, errors.New("没有配置WhatsApp！")
+	}
+	// Authentication类型模版 正文和复制验证码按钮都需要传入验证码
+	payload, _ := json.Marshal(map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                strings.TrimPrefix(toE164(zone, phone), "+"),
+		"type":              "template",
+		"template": map[string]interface{}{
+			"name": whatsAppCfg.TemplateName,
+			"language": map[string]string{
+				"code": whatsAppCfg.TemplateLanguage,
+			},
+			"components": []map[string]interface{}{
+				{
+					"type": "body",
+					"parameters": []map[string]string{
+						{"type": "text", "text": code},
+					},
+				},
+				{
+					"type":     "button",
+					"sub_type": "url",
+					"index":    "0",
+					"parameters": []map[string]string{
+						{"type": "text", "text": code},
+					},
+				},
+			},
+		},
+	})
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s/messages", whatsAppAPIURL, whatsAppCfg.PhoneNumberID), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+whatsAppCfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		ext.LogError(span, err)
+		w.Error("发送WhatsApp验证码失败！", zap.Error(err))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result whatsAppMessageResp
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		w.Error("解析WhatsApp返回数据失败！", zap.Error(err), zap.Int("status", resp.StatusCode))
+		return nil, errors.New("发送WhatsApp验证码失败！")
+	}
+	if result.Error != nil {
+		w.Error("发送WhatsApp验证码失败！", zap.Int("status", resp.StatusCode), zap.Int("code", result.Error.Code), zap.String("message", result.Error.Message))
+		return nil, errors.New(result.Error.Message)
+	}
+	sendResult := &SMSSendResult{}
+	if len(result.Messages) > 0 {
+		sendResult.MessageID = result.Messages[0].ID
+		w.Info("发送WhatsApp验证码成功", zap.String("id", sendResult.MessageID))
+	}
+	return sendResult, nil
+}
+
+type TelegramProvider struct {
+	ctx *config.Context
+	log.Log
+	client *http.Client
+}
+
+// NewTelegramProvider 创建Telegram Gateway验证码发送服务
+func NewTelegramProvider(ctx *config.Context) ISMSProvider {
+	return &TelegramProvider{
+		ctx:    ctx,
+		Log:    log.NewTLog("TelegramProvider"),
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (t *TelegramProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	span, _ := t.ctx.Tracer().StartSpanFromContext(ctx, "smsService.SendVerifyCode")
+	defer span.Finish()
+
+	telegramCfg := extconfig.Get().OTPChannel.Telegram
+	if telegramCfg.Token == "" {
+		return nil, errors.New("没有配置Telegram Gateway！")
+	}
+	params := map[string]interface{}{
+		"phone_number": toE164(zone, phone),
+		"code":         code,
+	}
+	if telegramCfg.TTL > 0 {
+		params["ttl"] = telegramCfg.TTL
+	}
+	payload, _ := json.Marshal(params)
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/sendVerificationMessage", telegramGatewayURL), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+telegramCfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		ext.LogError(span, err)
+		t.Error("发送Telegram验证码失败！", zap.Error(err))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result telegramGatewayResp
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Error("解析Telegram返回数据失败！", zap.Error(err), zap.Int("status", resp.StatusCode))
+		return nil, errors.New("发送Telegram验证码失败！")
+	}
+	if !result.OK {
+		// 用户未注册Telegram时返回 PHONE_NUMBER_INVALID 等错误
+		t.Warn("发送Telegram验证码失败！", zap.String("error", result.Error))
+		return nil, errors.New(result.Error)
+	}
+	t.Info("发送Telegram验证码成功", zap.String("requestId", result.Result.RequestID))
+	return &SMSSendResult{
+		MessageID: result.Result.RequestID,
+		Cost:      result.Result.RequestCost,
+		Currency:  "USD",
+	}, nil
+}
+
+type whatsAppMessageResp struct {
+	Messages []struct {
+		ID string `json:"id"`
+	} `json:"messages"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type telegramGatewayResp struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error"`
+	Result struct {
+		RequestID   string  `json:"request_id"`
+		RequestCost float64 `json:"request_cost"`
+	} `json:"result"`
+}
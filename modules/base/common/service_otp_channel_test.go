@@ -0,0 +1,209 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhatsAppProviderSend(t *testing.T) {
+	ctx := testutil.NewTestContext(config.New())
+	channelCfg := &extconfig.Get().OTPChannel
+	old := *channelCfg
+	defer func() { *channelCfg = old }()
+
+	provider := NewWhatsAppProvider(ctx).(*WhatsAppProvider)
+	// 没有配置
+	channelCfg.WhatsApp.Token = ""
+	_, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.EqualError(t, err, "没有配置WhatsApp！")
+
+	channelCfg.WhatsApp.Token = "token"
+	channelCfg.WhatsApp.PhoneNumberID = "1001"
+	channelCfg.WhatsApp.TemplateName = "otp"
+	channelCfg.WhatsApp.TemplateLanguage = "zh_CN"
+	var payload struct {
+		MessagingProduct string `json:"messaging_product"`
+		To               string `json:"to"`
+		Type             string `json:"type"`
+		Template         struct {
+			Name     string `json:"name"`
+			Language struct {
+				Code string `json:"code"`
+			} `json:"language"`
+			Components []struct {
+				Type       string `json:"type"`
+				SubType    string `json:"sub_type"`
+				Index      string `json:"index"`
+				Parameters []struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"parameters"`
+			} `json:"components"`
+		} `json:"template"`
+	}
+	provider.client = newRedirectClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v19.0/1001/messages", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		if payload.To == "85212345678" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":131026,"message":"Message undeliverable"}}`))
+			return
+		}
+		w.Write([]byte(`{"messaging_product":"whatsapp","messages":[{"id":"wamid.1"}]}`))
+	})
+	result, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, "wamid.1", result.MessageID)
+	assert.Equal(t, "whatsapp", payload.MessagingProduct)
+	assert.Equal(t, "8613800138000", payload.To)
+	assert.Equal(t, "template", payload.Type)
+	assert.Equal(t, "otp", payload.Template.Name)
+	assert.Equal(t, "zh_CN", payload.Template.Language.Code)
+	// 正文和复制验证码按钮都带上验证码
+	assert.Len(t, payload.Template.Components, 2)
+	assert.Equal(t, "body", payload.Template.Components[0].Type)
+	assert.Equal(t, "123456", payload.Template.Components[0].Parameters[0].Text)
+	assert.Equal(t, "button", payload.Template.Components[1].Type)
+	assert.Equal(t, "url", payload.Template.Components[1].SubType)
+	assert.Equal(t, "0", payload.Template.Components[1].Index)
+	assert.Equal(t, "123456", payload.Template.Components[1].Parameters[0].Text)
+
+	// 返回错误时使用WhatsApp的错误信息
+	_, err = provider.SendSMS(context.Background(), "00852", "12345678", "123456")
+	assert.EqualError(t, err, "Message undeliverable")
+}
+
+func TestTelegramProviderSend(t *testing.T) {
+	ctx := testutil.NewTestContext(config.New())
+	channelCfg := &extconfig.Get().OTPChannel
+	old := *channelCfg
+	defer func() { *channelCfg = old }()
+
+	provider := NewTelegramProvider(ctx).(*TelegramProvider)
+	// 没有配置
+	channelCfg.Telegram.Token = ""
+	_, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.EqualError(t, err, "没有配置Telegram Gateway！")
+
+	channelCfg.Telegram.Token = "token"
+	channelCfg.Telegram.TTL = 300
+	var params map[string]interface{}
+	provider.client = newRedirectClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/sendVerificationMessage", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		params = map[string]interface{}{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		if params["phone_number"] == "+85212345678" {
+			w.Write([]byte(`{"ok":false,"error":"PHONE_NUMBER_INVALID"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{"request_id":"req-1","request_cost":0.01}}`))
+	})
+	result, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, "req-1", result.MessageID)
+	assert.Equal(t, 0.01, result.Cost)
+	assert.Equal(t, "USD", result.Currency)
+	assert.Equal(t, "+8613800138000", params["phone_number"])
+	assert.Equal(t, "123456", params["code"])
+	assert.Equal(t, float64(300), params["ttl"])
+
+	// 没有配置有效期时不传ttl
+	channelCfg.Telegram.TTL = 0
+	_, err = provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.NoError(t, err)
+	_, ok := params["ttl"]
+	assert.False(t, ok)
+
+	// 用户没有Telegram时返回Gateway的错误
+	_, err = provider.SendSMS(context.Background(), "00852", "12345678", "123456")
+	assert.EqualError(t, err, "PHONE_NUMBER_INVALID")
+}
+
+func TestSMSOTPChannelSenders(t *testing.T) {
+	channelCfg := &extconfig.Get().OTPChannel
+	old := *channelCfg
+	defer func() { *channelCfg = old }()
+
+	s := &SMSService{
+		ctx: testutil.NewTestContext(config.New()),
+		Log: log.NewTLog("SMSService"),
+	}
+	tests := []struct {
+		name         string
+		channels     []string
+		smsOnlyZones []string
+		zone         string
+		want         []string
+	}{
+		{name: "没有配置通道", zone: "0086"},
+		{name: "按配置顺序", channels: []string{OTPChannelTelegram, OTPChannelWhatsApp}, zone: "0086", want: []string{OTPChannelTelegram, OTPChannelWhatsApp}},
+		{name: "跳过不支持的通道", channels: []string{"line", OTPChannelWhatsApp}, zone: "0086", want: []string{OTPChannelWhatsApp}},
+		{name: "只使用短信的区号", channels: []string{OTPChannelWhatsApp}, smsOnlyZones: []string{"0086"}, zone: "0086"},
+		{name: "其他区号不受影响", channels: []string{OTPChannelWhatsApp}, smsOnlyZones: []string{"0086"}, zone: "00852", want: []string{OTPChannelWhatsApp}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channelCfg.Channels = tt.channels
+			channelCfg.SMSOnlyZones = tt.smsOnlyZones
+			var names []string
+			for _, sender := range s.getOTPChannelSenders(tt.zone) {
+				assert.True(t, sender.channel)
+				names = append(names, sender.name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestSMSSendVerifyCodeChannelFallback(t *testing.T) {
+	routes := extconfig.Get().SMSRoutes
+	mockCfg := extconfig.Get().SMSMock
+	channelCfg := extconfig.Get().OTPChannel
+	defer func() {
+		extconfig.Get().SMSRoutes = routes
+		extconfig.Get().SMSMock = mockCfg
+		extconfig.Get().OTPChannel = channelCfg
+	}()
+	extconfig.Get().SMSRoutes = []extconfig.SMSRouteConfig{{Zones: []string{"*"}, Provider: string(SMSProviderMock)}}
+	// 没有配置Telegram令牌 Telegram通道会发送失败
+	extconfig.Get().OTPChannel = extconfig.OTPChannelConfig{Channels: []string{OTPChannelTelegram}}
+
+	ctx := testutil.NewTestContext(config.New())
+	newService := func() (*SMSService, *memCodeCache) {
+		cache := newMemCodeCache()
+		return &SMSService{
+			ctx:       ctx,
+			Log:       log.NewTLog("SMSService"),
+			sendLogDB: newSMSSendLogDB(ctx.DB()),
+			codeStore: newTestCodeStore(cache),
+		}, cache
+	}
+
+	// 优先通道和短信都失败时返回最后尝试的短信的错误 说明先尝试了优先通道
+	extconfig.Get().SMSMock.Enable = false
+	s, _ := newService()
+	err := s.SendVerifyCode(context.Background(), "0086", "13800138000", CodeTypeRegister)
+	assert.EqualError(t, err, "短信服务商配置有误！")
+
+	// 优先通道失败时改用短信发送 验证码照常可以验证
+	extconfig.Get().SMSMock.Enable = true
+	s, cache := newService()
+	err = s.SendVerifyCode(context.Background(), "0086", "13800138000", CodeTypeRegister)
+	assert.NoError(t, err)
+	subject := smsSubject("0086", "13800138000")
+	code, _ := cache.GetString(s.codeStore.key(CacheKeySMSCode, CodeTypeRegister, subject))
+	assert.NotEqual(t, "", code)
+	assert.Error(t, s.codeStore.checkInterval(CodeTypeRegister, subject))
+}
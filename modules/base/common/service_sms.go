@@ -4,72 +4,319 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"go.uber.org/zap"
 )
 
 type ISMSProvider interface {
-	SendSMS(ctx context.Context, zone, phone string, code string) error
+	SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error)
+}
+
+// SMSSendResult 发送结果 服务商没有返回的字段为空
+type SMSSendResult struct {
+	MessageID string  // 服务商的消息ID（用于匹配回执）
+	Cost      float64 // 费用
+	Currency  string  // 费用币种
+	Segments  int     // 计费条数
+}
+
+// ISMSTemplateProvider 支持指定模版的短信提供商（短信路由规则中配置了模版时使用）
+type ISMSTemplateProvider interface {
+	SendSMSWithTemplate(ctx context.Context, zone, phone string, code string, template string) (*SMSSendResult, error)
 }
 
 // ISMSService ISMSService
 type ISMSService interface {
 	// 发送验证码
 	SendVerifyCode(ctx context.Context, zone, phone string, codeType CodeType) error
+	// 通过语音播报已发送的验证码（短信收不到时使用）
+	SendVoiceVerifyCode(ctx context.Context, zone, phone string, codeType CodeType) error
 	// 验证验证码(销毁缓存)
 	Verify(ctx context.Context, zone, phone, code string, codeType CodeType) error
 }
 
+// smsSendingLockExpire 发送验证码锁的过期时间 需要大于所有通道发送的总耗时
+const smsSendingLockExpire = time.Second * 30
+
 // SMSService 短信服务
 type SMSService struct {
 	ctx *config.Context
 	log.Log
+	templateDB smsTemplateQuerier
+	sendLogDB  *smsSendLogDB
+	codeStore  *verifyCodeStore
 }
 
 // NewSMSService 创建短信服务
 func NewSMSService(ctx *config.Context) *SMSService {
+	if extconfig.Get().SMSMock.Enable && ctx.GetConfig().Mode == config.ReleaseMode {
+		log.Warn("已开启模拟短信（smsMock.enable），正式环境请关闭！")
+	}
 	return &SMSService{
-		ctx: ctx,
-		Log: log.NewTLog("SMSService"),
+		ctx:        ctx,
+		Log:        log.NewTLog("SMSService"),
+		templateDB: newSMSTemplateDB(ctx.DB()),
+		sendLogDB:  newSMSSendLogDB(ctx.DB()),
+		codeStore:  newVerifyCodeStore(db.NewRedis(ctx.GetConfig().DB.RedisAddr, ctx.GetConfig().DB.RedisPass)),
 	}
 }
 
-// SendVerifyCode 发送验证码
+// SendVerifyCode 发送验证码 优先使用配置的其他通道（WhatsApp/Telegram），都失败后使用短信
 func (s *SMSService) SendVerifyCode(ctx context.Context, zone, phone string, codeType CodeType) error {
-	var smsProvider ISMSProvider
+	senders := s.getOTPChannelSenders(zone)
+	if smsSender := s.getSMSSender(ctx, zone, codeType); smsSender != nil {
+		senders = append(senders, smsSender)
+	}
+	if len(senders) == 0 {
+		return errors.New("没有找到短信提供商！")
+	}
 
-	smsProviderName := s.ctx.GetConfig().SMSProvider
-	if smsProviderName == config.SMSProviderAliyun {
-		if zone != "0086" && s.ctx.GetConfig().AliyunInternationalSMS.AccessKeyID != "" {
-			smsProvider = NewAliyunInternationalProvider(s.ctx)
-		} else {
-			smsProvider = NewAliyunProvider(s.ctx)
+	subject := smsSubject(zone, phone)
+	release, err := s.codeStore.lockSending(codeType, subject)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := s.codeStore.checkLocked(codeType, subject); err != nil {
+		return err
+	}
+	if err := s.codeStore.checkInterval(codeType, subject); err != nil {
+		return err
+	}
+	if err := s.checkAbuse(ctx, zone, phone); err != nil {
+		return err
+	}
+	senders, err = s.checkQuota(ctx, zone, phone, senders)
+	if err != nil {
+		return err
+	}
+	senders = smsProviderStates.sortSenders(senders)
+	verifyCode, revoke, err := s.codeStore.issue(codeType, subject)
+	if err != nil {
+		return err
+	}
+	for i, sender := range senders {
+		err = s.send(ctx, sender, zone, phone, codeType, verifyCode)
+		if err == nil {
+			s.incrQuota(zone, phone, sender)
+			return nil
 		}
+		if i < len(senders)-1 {
+			s.Warn("验证码通道发送失败，尝试下一个通道！", zap.Error(err), zap.String("provider", sender.name), zap.String("zone", zone))
+		}
+	}
+	revoke()
+	return err
+}
+
+// smsSender 验证码发送者
+type smsSender struct {
+	name     string // 服务商或通道名
+	provider ISMSProvider
+	channel  bool // 是否是WhatsApp/Telegram通道
+}
+
+// send 发送验证码并记录发送日志
+func (s *SMSService) send(ctx context.Context, sender *smsSender, zone, phone string, codeType CodeType, verifyCode string) error {
+	start := time.Now()
+	result, err := sender.provider.SendSMS(ctx, zone, phone, verifyCode)
+	latency := time.Since(start)
+	observeSMSSend(sender.name, latency, err)
+	sendLog := &smsSendLogModel{
+		Provider: sender.name,
+		Zone:     zone,
+		Phone:    phone,
+		CodeType: int(codeType),
+		Status:   SMSSendStatusSent,
+		Latency:  latency.Milliseconds(),
+	}
+	if result != nil {
+		sendLog.MessageID = result.MessageID
+		sendLog.Cost = result.Cost
+		sendLog.Currency = result.Currency
+		sendLog.Segments = result.Segments
+	}
+	if err != nil {
+		sendLog.Status = SMSSendStatusFailed
+		sendLog.ErrMsg = err.Error()
+	}
+	if logErr := s.sendLogDB.insert(sendLog); logErr != nil {
+		s.Warn("记录短信发送日志失败！", zap.Error(logErr))
+	}
+	return err
+}
+
+// getSMSSender 根据短信路由规则获取该区号的短信提供商 未配置返回nil
+// 模版优先使用后台配置的模版，其次是路由规则中的模版
+// 请求的组织配置了短信路由时使用组织的路由规则
+func (s *SMSService) getSMSSender(ctx context.Context, zone string, codeType CodeType) *smsSender {
+	routes := getSMSRoutes(s.ctx.GetConfig())
+	if tenantCfg := tenant.Config(tenant.FromContext(ctx)); tenantCfg != nil && len(tenantCfg.SMSRoutes) > 0 {
+		routes = tenantCfg.SMSRoutes
+	}
+	route := matchSMSRoute(routes, zone)
+	if route == nil {
+		return nil
+	}
+	smsProvider := s.newSMSProvider(config.SMSProvider(route.Provider))
+	if smsProvider == nil {
+		s.Warn("不支持的短信服务商！", zap.String("provider", route.Provider))
+		return nil
+	}
+	sender := &smsSender{name: route.Provider, provider: smsProvider}
+	templateProvider, ok := smsProvider.(ISMSTemplateProvider)
+	if !ok {
+		return sender
+	}
+	template := s.getTemplate(ctx, route.Provider, zone, codeType)
+	if template == "" {
+		template = route.Template
+	}
+	if template != "" {
+		sender.provider = &templateSMSProvider{provider: templateProvider, template: template}
+	}
+	return sender
+}
+
+// getSMSRoutes 短信路由规则 没有配置时由smsProvider生成默认规则
+func getSMSRoutes(cfg *config.Config) []extconfig.SMSRouteConfig {
+	if routes := extconfig.Get().SMSRoutes; len(routes) > 0 {
+		return routes
+	}
+	smsProviderName := cfg.SMSProvider
+	if smsProviderName == "" {
+		return nil
+	}
+	routes := make([]extconfig.SMSRouteConfig, 0, 2)
+	if smsProviderName == config.SMSProviderAliyun && cfg.AliyunInternationalSMS.AccessKeyID != "" {
+		// 配置了阿里云国际短信，国内区号使用阿里云短信，其他区号使用阿里云国际短信
+		routes = append(routes, extconfig.SMSRouteConfig{Zones: []string{"0086"}, Provider: string(config.SMSProviderAliyun)})
+		routes = append(routes, extconfig.SMSRouteConfig{Zones: []string{smsRouteAnyZone}, Provider: string(SMSProviderAliyunInternational)})
+		return routes
+	}
+	routes = append(routes, extconfig.SMSRouteConfig{Zones: []string{smsRouteAnyZone}, Provider: string(smsProviderName)})
+	return routes
+}
+
+func (s *SMSService) newSMSProvider(smsProviderName config.SMSProvider) ISMSProvider {
+	var smsProvider ISMSProvider
+	if smsProviderName == config.SMSProviderAliyun {
+		smsProvider = NewAliyunProvider(s.ctx)
+	} else if smsProviderName == SMSProviderAliyunInternational {
+		smsProvider = NewAliyunInternationalProvider(s.ctx)
 	} else if smsProviderName == config.SMSProviderUnisms {
 		smsProvider = NewUnismsProvider(s.ctx)
+	} else if smsProviderName == SMSProviderTwilio {
+		smsProvider = NewTwilioProvider(s.ctx)
+	} else if smsProviderName == SMSProviderTencent {
+		smsProvider = NewTencentProvider(s.ctx)
+	} else if smsProviderName == SMSProviderAWS {
+		smsProvider = NewAWSProvider(s.ctx)
+	} else if smsProviderName == SMSProviderMock {
+		smsProvider = NewMockProvider(s.ctx)
 	}
+	return smsProvider
+}
 
-	if smsProvider == nil {
-		return errors.New("没有找到短信提供商！")
+// matchSMSRoute 按顺序匹配区号 返回第一条匹配的规则
+func matchSMSRoute(routes []extconfig.SMSRouteConfig, zone string) *extconfig.SMSRouteConfig {
+	for i, route := range routes {
+		for _, routeZone := range route.Zones {
+			if routeZone == smsRouteAnyZone || routeZone == zone {
+				return &routes[i]
+			}
+		}
 	}
+	return nil
+}
+
+// templateSMSProvider 使用路由规则中指定模版的短信提供商
+type templateSMSProvider struct {
+	provider ISMSTemplateProvider
+	template string
+}
+
+func (t *templateSMSProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	return t.provider.SendSMSWithTemplate(ctx, zone, phone, code, t.template)
+}
 
-	verifyCode := ""
-	rand.Seed(int64(time.Now().Nanosecond()))
-	for i := 0; i < 4; i++ {
-		verifyCode += fmt.Sprintf("%v", rand.Intn(10))
+// getOTPChannelSenders 获取该区号可用的其他验证码通道（按配置顺序）
+func (s *SMSService) getOTPChannelSenders(zone string) []*smsSender {
+	channelCfg := extconfig.Get().OTPChannel
+	for _, smsOnlyZone := range channelCfg.SMSOnlyZones {
+		if smsOnlyZone == zone {
+			return nil
+		}
+	}
+	senders := make([]*smsSender, 0, len(channelCfg.Channels)+1)
+	for _, channel := range channelCfg.Channels {
+		provider := NewOTPChannelProvider(s.ctx, channel)
+		if provider == nil {
+			s.Warn("不支持的验证码通道！", zap.String("channel", channel))
+			continue
+		}
+		senders = append(senders, &smsSender{name: channel, provider: provider, channel: true})
 	}
-	s.Info("发送验证码", zap.String("code", verifyCode))
-	cacheKey := fmt.Sprintf("%s%d@%s@%s", CacheKeySMSCode, codeType, zone, phone)
-	err := s.ctx.GetRedisConn().SetAndExpire(cacheKey, verifyCode, time.Minute*5)
+	return senders
+}
+
+// SendVoiceVerifyCode 语音播报验证码 复用已发送的短信验证码，验证方式不变
+func (s *SMSService) SendVoiceVerifyCode(ctx context.Context, zone, phone string, codeType CodeType) error {
+	voiceProvider := NewVoiceProvider(s.ctx)
+	if voiceProvider == nil {
+		return errors.New("未开启语音验证码！")
+	}
+	return s.sendVoiceCode(ctx, voiceProvider, zone, phone, codeType)
+}
+
+// sendVoiceCode 检查语音验证码的发送间隔和每天的次数后发送
+func (s *SMSService) sendVoiceCode(ctx context.Context, voiceProvider IVoiceProvider, zone, phone string, codeType CodeType) error {
+	cache := s.codeStore.cache
+	verifyCode, err := s.codeStore.get(codeType, smsSubject(zone, phone))
 	if err != nil {
 		return err
 	}
-	err = smsProvider.SendSMS(ctx, zone, phone, verifyCode)
-	return err
+	if verifyCode == "" {
+		return errors.New("请先获取短信验证码！")
+	}
+	voiceCfg := extconfig.Get().VoiceOTP
+	intervalKey := fmt.Sprintf("%s%s@%s", CacheKeyVoiceCodeInterval, zone, phone)
+	interval, err := cache.GetString(intervalKey)
+	if err != nil {
+		return err
+	}
+	if interval != "" {
+		return errcode.ErrVoiceCodeTooFrequent
+	}
+	dailyKey := fmt.Sprintf("%s%s@%s@%s", CacheKeyVoiceCodeDaily, time.Now().Format("20060102"), zone, phone)
+	count, err := cache.Incr(dailyKey)
+	if err != nil {
+		return err
+	}
+	if count == 1 {
+		_ = cache.Expire(dailyKey, time.Hour*24)
+	}
+	if voiceCfg.DailyLimit > 0 && count > int64(voiceCfg.DailyLimit) {
+		return errors.New("今日语音验证码次数已达上限！")
+	}
+	err = cache.SetAndExpire(intervalKey, "1", voiceCfg.Interval)
+	if err != nil {
+		return err
+	}
+	if err = voiceProvider.SendVoiceCode(ctx, zone, phone, verifyCode); err != nil {
+		// 没有打通时可以马上重试
+		_ = cache.Del(intervalKey)
+		return err
+	}
+	return nil
 }
 
 // Verify 验证验证码
@@ -77,14 +324,5 @@ func (s *SMSService) Verify(ctx context.Context, zone, phone, code string, codeT
 	span, _ := s.ctx.Tracer().StartSpanFromContext(ctx, "smsService.Verify")
 	defer span.Finish()
 
-	cacheKey := fmt.Sprintf("%s%d@%s@%s", CacheKeySMSCode, codeType, zone, phone)
-	sysCode, err := s.ctx.GetRedisConn().GetString(cacheKey)
-	if err != nil {
-		return err
-	}
-	if sysCode != "" && sysCode == code {
-		s.ctx.GetRedisConn().Del(cacheKey)
-		return nil
-	}
-	return errors.New("验证码无效！")
+	return s.codeStore.verify(codeType, smsSubject(zone, phone), code)
 }
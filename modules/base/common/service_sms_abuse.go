@@ -0,0 +1,232 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// SMSAbuseActionCaptcha 触发防刷后需要人机验证
+	SMSAbuseActionCaptcha = "captcha"
+	// SMSAbuseActionReject 触发防刷后拒绝发送
+	SMSAbuseActionReject = "reject"
+)
+
+type smsClientCtxKey struct{}
+
+// smsClient 获取验证码的客户端
+type smsClient struct {
+	ip       string
+	deviceID string
+}
+
+// WithSMSClient 设置获取验证码的客户端IP和设备标识（用于防刷）
+func WithSMSClient(ctx context.Context, ip, deviceID string) context.Context {
+	return context.WithValue(ctx, smsClientCtxKey{}, &smsClient{
+		ip:       strings.TrimSpace(ip),
+		deviceID: strings.TrimSpace(deviceID),
+	})
+}
+
+// checkAbuse 防刷检查 禁止的号码直接拒绝，超出IP、设备或号段限制时根据配置要求人机验证或拒绝发送
+func (s *SMSService) checkAbuse(ctx context.Context, zone, phone string) error {
+	abuseCfg := extconfig.Get().SMSAbuse
+	if smsBlocklist.blocked(toE164(zone, phone), abuseCfg) {
+		smsAbuseTotal.WithLabelValues("blocklist").Inc()
+		s.Warn("禁止获取验证码的号码", zap.String("zone", zone), zap.String("phone", phone))
+		return errors.New("该手机号不支持获取验证码！")
+	}
+
+	client, _ := ctx.Value(smsClientCtxKey{}).(*smsClient)
+	if client == nil {
+		client = &smsClient{}
+	}
+	now := time.Now()
+	hour := now.Format("2006010215")
+	reasons := make([]string, 0)
+	if client.ip != "" {
+		if s.overAbuseLimit(fmt.Sprintf("%sip:%s:%s", CacheKeySMSAbuse, hour, client.ip), abuseCfg.IPHourlyLimit, time.Hour) {
+			reasons = append(reasons, "ip")
+		}
+		if ipRange := smsIPRange(client.ip); ipRange != "" {
+			if s.overAbuseLimit(fmt.Sprintf("%siprange:%s:%s", CacheKeySMSAbuse, hour, ipRange), abuseCfg.IPRangeHourlyLimit, time.Hour) {
+				reasons = append(reasons, "ip_range")
+			}
+		}
+	}
+	if client.deviceID != "" {
+		if s.overAbuseLimit(fmt.Sprintf("%sdevice:%s:%s", CacheKeySMSAbuse, hour, client.deviceID), abuseCfg.DeviceHourlyLimit, time.Hour) {
+			reasons = append(reasons, "device")
+		}
+		if abuseCfg.DevicePhoneLimit > 0 {
+			phonesKey := fmt.Sprintf("%sdevicephone:%s:%s", CacheKeySMSAbuse, now.Format("20060102"), client.deviceID)
+			if s.overAbusePhoneLimit(phonesKey, zone, phone, abuseCfg.DevicePhoneLimit) {
+				reasons = append(reasons, "device_phone")
+			}
+		}
+	} else if abuseCfg.RequireDevice {
+		reasons = append(reasons, "no_device")
+	}
+	// 号段：去掉手机号最后3位 刷量通常是同一号段的连续号码
+	if len(phone) > 6 {
+		prefixKey := fmt.Sprintf("%sprefix:%s:%s@%s", CacheKeySMSAbuse, hour, zone, phone[:len(phone)-3])
+		if s.overAbuseLimit(prefixKey, abuseCfg.PrefixHourlyLimit, time.Hour) {
+			reasons = append(reasons, "prefix")
+		}
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	for _, reason := range reasons {
+		smsAbuseTotal.WithLabelValues(reason).Inc()
+	}
+	s.Warn("获取验证码触发防刷限制", zap.Strings("reasons", reasons), zap.String("zone", zone), zap.String("phone", phone), zap.String("ip", client.ip), zap.String("deviceID", client.deviceID))
+	if abuseCfg.Action == SMSAbuseActionReject {
+		return errors.New("获取验证码过于频繁，请稍后再试！")
+	}
+	return s.verifyCaptcha(ctx)
+}
+
+// overAbuseLimit 计数加1 返回是否超出限制（超出限制的请求也计数）
+func (s *SMSService) overAbuseLimit(key string, limit int, expire time.Duration) bool {
+	if limit <= 0 {
+		return false
+	}
+	count := s.incrQuotaCount(key, expire)
+	return count > int64(limit)
+}
+
+// overAbusePhoneLimit 记录设备获取验证码的手机号 返回不同手机号的数量是否超出限制
+func (s *SMSService) overAbusePhoneLimit(key, zone, phone string, limit int) bool {
+	conn := s.codeStore.redisConn
+	if err := conn.SAdd(key, fmt.Sprintf("%s@%s", zone, phone)); err != nil {
+		s.Warn("记录设备获取验证码的手机号失败！", zap.Error(err), zap.String("key", key))
+		return false
+	}
+	_ = conn.SetExpire(key, time.Hour*25)
+	count, err := conn.SCard(key)
+	if err != nil {
+		s.Warn("查询设备获取验证码的手机号数量失败！", zap.Error(err), zap.String("key", key))
+		return false
+	}
+	return count > int64(limit)
+}
+
+// smsIPRange IP所在的网段 IPv4为/24 IPv6为/64
+func smsIPRange(ip string) string {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return ""
+	}
+	if ipv4 := parsedIP.To4(); ipv4 != nil {
+		return ipv4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsedIP.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// smsBlocklist 禁止获取验证码的号码（配置的号码前缀和号码文件）
+var smsBlocklist = &smsNumberBlocklist{Log: log.NewTLog("SMSBlocklist")}
+
+type smsNumberBlocklist struct {
+	log.Log
+	mu       sync.RWMutex
+	file     string
+	modTime  time.Time
+	prefixes []string
+}
+
+// blocked 号码是否以禁止的号码或号码前缀开头
+func (b *smsNumberBlocklist) blocked(number string, abuseCfg extconfig.SMSAbuseConfig) bool {
+	for _, prefix := range abuseCfg.BlockedPrefixes {
+		if prefix = normalizeBlockedNumber(prefix); prefix != "" && strings.HasPrefix(number, prefix) {
+			return true
+		}
+	}
+	if abuseCfg.BlocklistFile == "" {
+		return false
+	}
+	b.reloadIfNeed(abuseCfg.BlocklistFile)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, prefix := range b.prefixes {
+		if strings.HasPrefix(number, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadIfNeed 文件修改后重新加载
+func (b *smsNumberBlocklist) reloadIfNeed(file string) {
+	info, err := os.Stat(file)
+	if err != nil {
+		b.mu.Lock()
+		if b.file != file || !b.modTime.IsZero() {
+			b.Warn("读取禁止获取验证码的号码文件失败！", zap.Error(err), zap.String("file", file))
+			b.file = file
+			b.modTime = time.Time{}
+			b.prefixes = nil
+		}
+		b.mu.Unlock()
+		return
+	}
+	b.mu.RLock()
+	loaded := b.file == file && b.modTime.Equal(info.ModTime())
+	b.mu.RUnlock()
+	if loaded {
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		b.Warn("读取禁止获取验证码的号码文件失败！", zap.Error(err), zap.String("file", file))
+		return
+	}
+	defer f.Close()
+	prefixes := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if prefix := normalizeBlockedNumber(line); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		b.Warn("读取禁止获取验证码的号码文件失败！", zap.Error(err), zap.String("file", file))
+		return
+	}
+	b.mu.Lock()
+	b.file = file
+	b.modTime = info.ModTime()
+	b.prefixes = prefixes
+	b.mu.Unlock()
+	b.Info("已加载禁止获取验证码的号码文件", zap.String("file", file), zap.Int("count", len(prefixes)))
+}
+
+// normalizeBlockedNumber 转为E.164格式 兼容00开头的写法
+func normalizeBlockedNumber(number string) string {
+	number = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(number))
+	if number == "" {
+		return ""
+	}
+	if strings.HasPrefix(number, "00") {
+		return "+" + strings.TrimPrefix(number, "00")
+	}
+	if !strings.HasPrefix(number, "+") {
+		return "+" + number
+	}
+	return number
+}
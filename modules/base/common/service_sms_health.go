@@ -0,0 +1,287 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
+)
+
+// smsProviderProbeURLs 健康检查时探测的服务商接口地址（能返回响应即认为可以访问）
+var smsProviderProbeURLs = map[string]string{
+	string(config.SMSProviderAliyun):       "https://dysmsapi.aliyuncs.com",
+	string(SMSProviderAliyunInternational): "https://dysmsapi.ap-southeast-1.aliyuncs.com",
+	string(config.SMSProviderUnisms):       "https://uni.apistd.com",
+	string(SMSProviderTwilio):              twilioAPIURL,
+	string(SMSProviderTencent):             "https://" + tencentSMSHost,
+	OTPChannelWhatsApp:                     whatsAppAPIURL,
+	OTPChannelTelegram:                     telegramGatewayURL,
+}
+
+// smsProviderStates 最近一次检查时异常的服务商 发送验证码时异常的服务商排在最后
+var smsProviderStates = &smsProviderState{unhealthy: map[string]bool{}}
+
+// smsProviderState 服务商是否异常
+type smsProviderState struct {
+	mu        sync.RWMutex
+	unhealthy map[string]bool
+}
+
+// update 记录检查的结果
+func (s *smsProviderState) update(results map[string]*smsProviderHealth) {
+	unhealthy := make(map[string]bool, len(results))
+	for provider, result := range results {
+		if !result.Healthy {
+			unhealthy[provider] = true
+		}
+	}
+	s.mu.Lock()
+	s.unhealthy = unhealthy
+	s.mu.Unlock()
+}
+
+// sortSenders 异常的服务商排到最后（仍然会尝试） 其他的保持原来的顺序
+func (s *smsProviderState) sortSenders(senders []*smsSender) []*smsSender {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.unhealthy) == 0 {
+		return senders
+	}
+	sorted := make([]*smsSender, len(senders))
+	copy(sorted, senders)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return !s.unhealthy[sorted[i].name] && s.unhealthy[sorted[j].name]
+	})
+	return sorted
+}
+
+// smsProviderHealth 短信服务商健康状态
+type smsProviderHealth struct {
+	Provider     string  `json:"provider"`      // 服务商或通道名
+	Reachable    bool    `json:"reachable"`     // 服务商接口是否可以访问
+	ProbeLatency int64   `json:"probe_latency"` // 探测耗时（毫秒）
+	ProbeError   string  `json:"probe_error"`   // 探测失败的原因
+	Total        int64   `json:"total"`         // 时间窗口内的发送次数
+	Failed       int64   `json:"failed"`        // 时间窗口内的发送失败次数
+	SuccessRate  float64 `json:"success_rate"`  // 时间窗口内的发送成功率（百分比）
+	Healthy      bool    `json:"healthy"`       // 是否正常
+	CheckedAt    string  `json:"checked_at"`    // 检查时间
+}
+
+// SMSHealthChecker 定时检查短信服务商是否可以访问以及最近的发送成功率
+type SMSHealthChecker struct {
+	ctx *config.Context
+	log.Log
+	sendLogDB *smsSendLogDB
+	client    *http.Client
+
+	mu      sync.RWMutex
+	results map[string]*smsProviderHealth
+	timer   *timingwheel.Timer
+}
+
+// NewSMSHealthChecker 创建短信服务商健康检查
+func NewSMSHealthChecker(ctx *config.Context) *SMSHealthChecker {
+	return &SMSHealthChecker{
+		ctx:       ctx,
+		Log:       log.NewTLog("SMSHealthChecker"),
+		sendLogDB: newSMSSendLogDB(ctx.DB()),
+		client: &http.Client{
+			Timeout: extconfig.Get().SMSHealth.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		results: map[string]*smsProviderHealth{},
+	}
+}
+
+// Start 开始定时检查 检查间隔为0时不检查
+func (h *SMSHealthChecker) Start() error {
+	interval := extconfig.Get().SMSHealth.Interval
+	if interval <= 0 {
+		return nil
+	}
+	go h.check()
+	h.timer = h.ctx.Schedule(interval, h.check)
+	return nil
+}
+
+// Stop 停止定时检查
+func (h *SMSHealthChecker) Stop() error {
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	return nil
+}
+
+// Results 最近一次检查的结果
+func (h *SMSHealthChecker) Results() []*smsProviderHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	results := make([]*smsProviderHealth, 0, len(h.results))
+	for _, result := range h.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Provider < results[j].Provider
+	})
+	return results
+}
+
+func (h *SMSHealthChecker) check() {
+	healthCfg := extconfig.Get().SMSHealth
+	providers := h.providers()
+	if len(providers) == 0 {
+		return
+	}
+	stats, err := h.windowStats(healthCfg.Window)
+	if err != nil {
+		h.Warn("查询短信发送统计失败！", zap.Error(err))
+	}
+	now := time.Now()
+	results := make(map[string]*smsProviderHealth, len(providers))
+	for _, provider := range providers {
+		result := &smsProviderHealth{
+			Provider:  provider,
+			CheckedAt: now.Format("2006-01-02 15:04:05"),
+		}
+		h.probe(result)
+		if stat := stats[provider]; stat != nil {
+			result.Total = stat.Total
+			result.Failed = stat.Failed
+		}
+		result.Healthy = result.Reachable
+		if result.Total > 0 {
+			result.SuccessRate = float64(result.Total-result.Failed) * 100 / float64(result.Total)
+			smsProviderSuccessRatio.WithLabelValues(provider).Set(result.SuccessRate / 100)
+			if result.Total >= int64(healthCfg.MinSamples) && result.SuccessRate < float64(healthCfg.MinSuccessPercent) {
+				result.Healthy = false
+			}
+		}
+		if result.Healthy {
+			smsProviderUp.WithLabelValues(provider).Set(1)
+		} else {
+			smsProviderUp.WithLabelValues(provider).Set(0)
+		}
+		results[provider] = result
+	}
+
+	h.mu.Lock()
+	lastResults := h.results
+	h.results = results
+	h.mu.Unlock()
+	smsProviderStates.update(results)
+
+	for _, result := range results {
+		lastResult := lastResults[result.Provider]
+		if lastResult == nil {
+			// 第一次检查只提醒异常的服务商
+			if !result.Healthy {
+				h.alert(result)
+			}
+			continue
+		}
+		if lastResult.Healthy != result.Healthy {
+			h.alert(result)
+		}
+	}
+}
+
+// probe 访问服务商接口 返回任意非5xx响应都认为可以访问
+func (h *SMSHealthChecker) probe(result *smsProviderHealth) {
+	probeURL := smsProviderProbeURLs[result.Provider]
+	if result.Provider == string(SMSProviderAWS) {
+		probeURL = fmt.Sprintf("https://sns.%s.amazonaws.com", extconfig.Get().AWSSMS.Region)
+	}
+	if probeURL == "" {
+		// 无法探测的服务商（例如mock）只根据发送成功率判断
+		result.Reachable = true
+		return
+	}
+	start := time.Now()
+	resp, err := h.client.Get(probeURL)
+	latency := time.Since(start)
+	result.ProbeLatency = latency.Milliseconds()
+	smsProviderProbeDuration.WithLabelValues(result.Provider).Set(latency.Seconds())
+	if err != nil {
+		result.ProbeError = err.Error()
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		result.ProbeError = resp.Status
+		return
+	}
+	result.Reachable = true
+}
+
+// windowStats 时间窗口内各服务商的发送统计
+func (h *SMSHealthChecker) windowStats(window time.Duration) (map[string]*smsSendLogStatModel, error) {
+	models, err := h.sendLogDB.queryStats(smsSendLogFilter{
+		CodeType:  -1,
+		StartTime: time.Now().Add(-window).Format("2006-01-02 15:04:05"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]*smsSendLogStatModel, len(models))
+	for _, m := range models {
+		// 同一服务商可能有多个币种
+		if stat := stats[m.Provider]; stat != nil {
+			stat.Total += m.Total
+			stat.Failed += m.Failed
+			continue
+		}
+		stats[m.Provider] = &smsSendLogStatModel{Provider: m.Provider, Total: m.Total, Failed: m.Failed}
+	}
+	return stats, nil
+}
+
+// providers 需要检查的服务商（短信路由、验证码通道和超出配额后使用的服务商）
+func (h *SMSHealthChecker) providers() []string {
+	providers := make([]string, 0)
+	exist := map[string]bool{}
+	add := func(provider string) {
+		if provider == "" || exist[provider] {
+			return
+		}
+		exist[provider] = true
+		providers = append(providers, provider)
+	}
+	for _, route := range getSMSRoutes(h.ctx.GetConfig()) {
+		add(route.Provider)
+	}
+	for _, channel := range extconfig.Get().OTPChannel.Channels {
+		add(channel)
+	}
+	quotaCfg := extconfig.Get().SMSQuota
+	if quotaCfg.OverBudgetAction == SMSOverBudgetProvider {
+		add(quotaCfg.OverBudgetProvider)
+	}
+	return providers
+}
+
+// alert 服务商状态变化时通知管理员
+func (h *SMSHealthChecker) alert(result *smsProviderHealth) {
+	var content string
+	if result.Healthy {
+		content = fmt.Sprintf("短信服务商提醒：%s已恢复正常。", result.Provider)
+	} else if !result.Reachable {
+		content = fmt.Sprintf("短信服务商提醒：%s接口无法访问（%s），请检查网络或切换短信路由。", result.Provider, result.ProbeError)
+	} else {
+		content = fmt.Sprintf("短信服务商提醒：%s最近%s发送成功率为%.1f%%（%d/%d），低于%d%%。", result.Provider, extconfig.Get().SMSHealth.Window, result.SuccessRate, result.Total-result.Failed, result.Total, extconfig.Get().SMSHealth.MinSuccessPercent)
+	}
+	h.Warn(content)
+	if err := sendAdminAlert(h.ctx, content); err != nil {
+		h.Error("发送短信服务商提醒失败！", zap.Error(err))
+	}
+}
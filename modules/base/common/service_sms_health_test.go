@@ -0,0 +1,97 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMSProviderStateSortSenders(t *testing.T) {
+	state := &smsProviderState{unhealthy: map[string]bool{}}
+	telegram := &smsSender{name: OTPChannelTelegram, channel: true}
+	whatsApp := &smsSender{name: OTPChannelWhatsApp, channel: true}
+	twilio := &smsSender{name: string(SMSProviderTwilio)}
+	senders := []*smsSender{telegram, whatsApp, twilio}
+	assert.Equal(t, senders, state.sortSenders(senders))
+
+	// 异常的排到最后 其他的保持原来的顺序
+	state.update(map[string]*smsProviderHealth{
+		OTPChannelTelegram: {Provider: OTPChannelTelegram, Healthy: false},
+		OTPChannelWhatsApp: {Provider: OTPChannelWhatsApp, Healthy: true},
+	})
+	assert.Equal(t, []*smsSender{whatsApp, twilio, telegram}, state.sortSenders(senders))
+	assert.Equal(t, []*smsSender{telegram, whatsApp, twilio}, senders)
+
+	// 恢复后按原来的顺序
+	state.update(map[string]*smsProviderHealth{
+		OTPChannelTelegram: {Provider: OTPChannelTelegram, Healthy: true},
+	})
+	assert.Equal(t, senders, state.sortSenders(senders))
+}
+
+func TestSMSHealthCheck(t *testing.T) {
+	routes := extconfig.Get().SMSRoutes
+	channels := extconfig.Get().OTPChannel.Channels
+	defer func() {
+		extconfig.Get().SMSRoutes = routes
+		extconfig.Get().OTPChannel.Channels = channels
+		smsProviderStates.update(nil)
+	}()
+	extconfig.Get().SMSRoutes = []extconfig.SMSRouteConfig{{Zones: []string{smsRouteAnyZone}, Provider: string(SMSProviderTwilio)}}
+	extconfig.Get().OTPChannel.Channels = []string{OTPChannelWhatsApp}
+
+	cfg := config.New()
+	cfg.Account.AdminUID = "" // 不发送提醒消息
+	ctx := testutil.NewTestContext(cfg)
+	twilioDown := true
+	h := &SMSHealthChecker{
+		ctx:       ctx,
+		Log:       log.NewTLog("SMSHealthChecker"),
+		sendLogDB: newSMSSendLogDB(ctx.DB()),
+		client: newRedirectClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/2010-04-01" && twilioDown {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}),
+		results: map[string]*smsProviderHealth{},
+	}
+	whatsApp := &smsSender{name: OTPChannelWhatsApp, channel: true}
+	twilio := &smsSender{name: string(SMSProviderTwilio)}
+
+	// 服务商接口返回5xx时为异常 发送时排在最后
+	h.check()
+	results := h.Results()
+	assert.Len(t, results, 2)
+	assert.Equal(t, string(SMSProviderTwilio), results[0].Provider)
+	assert.False(t, results[0].Reachable)
+	assert.False(t, results[0].Healthy)
+	assert.Equal(t, "503 Service Unavailable", results[0].ProbeError)
+	assert.True(t, results[1].Healthy)
+	assert.Equal(t, []*smsSender{whatsApp, twilio}, smsProviderStates.sortSenders([]*smsSender{twilio, whatsApp}))
+
+	// 恢复后按原来的顺序
+	twilioDown = false
+	h.check()
+	results = h.Results()
+	assert.True(t, results[0].Healthy)
+	assert.Equal(t, []*smsSender{twilio, whatsApp}, smsProviderStates.sortSenders([]*smsSender{twilio, whatsApp}))
+}
+
+func TestSMSHealthCheckerStartDisabled(t *testing.T) {
+	healthCfg := &extconfig.Get().SMSHealth
+	interval := healthCfg.Interval
+	defer func() { healthCfg.Interval = interval }()
+	healthCfg.Interval = 0
+
+	h := &SMSHealthChecker{Log: log.NewTLog("SMSHealthChecker")}
+	assert.NoError(t, h.Start())
+	assert.Nil(t, h.timer)
+	assert.NoError(t, h.Stop())
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	smsMetricsResultSuccess = "success"
+	smsMetricsResultFailed  = "failed"
+)
+
+var (
+	// smsSendTotal 验证码发送次数（按服务商和发送结果）
+	smsSendTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_sms_send_total",
+		Help: "验证码发送次数",
+	}, []string{"provider", "result"})
+	// smsSendDuration 调用服务商发送接口的耗时
+	smsSendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tsdd_sms_send_duration_seconds",
+		Help:    "调用服务商发送验证码接口的耗时",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10},
+	}, []string{"provider"})
+	// smsDeliveryTotal 服务商回执次数（按服务商和回执状态）
+	smsDeliveryTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_sms_delivery_total",
+		Help: "短信回执次数",
+	}, []string{"provider", "status"})
+	// smsAbuseTotal 触发防刷的次数（按原因）
+	smsAbuseTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_sms_abuse_total",
+		Help: "获取验证码触发防刷的次数",
+	}, []string{"reason"})
+	// smsProviderUp 服务商健康状态 1.正常 0.异常
+	smsProviderUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tsdd_sms_provider_up",
+		Help: "短信服务商健康状态 1.正常 0.异常",
+	}, []string{"provider"})
+	// smsProviderProbeDuration 探测服务商接口的耗时
+	smsProviderProbeDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tsdd_sms_provider_probe_duration_seconds",
+		Help: "探测短信服务商接口的耗时",
+	}, []string{"provider"})
+	// smsProviderSuccessRatio 健康检查时间窗口内的发送成功率
+	smsProviderSuccessRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tsdd_sms_provider_success_ratio",
+		Help: "健康检查时间窗口内的验证码发送成功率",
+	}, []string{"provider"})
+)
+
+// observeSMSSend 记录一次验证码发送
+func observeSMSSend(provider string, latency time.Duration, err error) {
+	result := smsMetricsResultSuccess
+	if err != nil {
+		result = smsMetricsResultFailed
+	}
+	smsSendTotal.WithLabelValues(provider, result).Inc()
+	smsSendDuration.WithLabelValues(provider).Observe(latency.Seconds())
+}
+
+// observeSMSDelivery 记录一次服务商回执
+func observeSMSDelivery(provider string, status int) {
+	statusName := "sent"
+	if status == SMSSendStatusDelivered {
+		statusName = "delivered"
+	} else if status == SMSSendStatusFailed {
+		statusName = "failed"
+	}
+	smsDeliveryTotal.WithLabelValues(provider, statusName).Inc()
+}
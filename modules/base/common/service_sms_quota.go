@@ -0,0 +1,249 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
+	extutil "github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/util"
+	libcommon "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// ErrSMSCaptchaRequired 超出短信配额后需要人机验证才能获取验证码
+var ErrSMSCaptchaRequired = errors.New("请完成人机验证后再获取验证码！")
+
+const (
+	// SMSOverBudgetReject 超出配额后拒绝发送
+	SMSOverBudgetReject = "reject"
+	// SMSOverBudgetProvider 超出配额后切换到其他短信服务商
+	SMSOverBudgetProvider = "provider"
+	// SMSOverBudgetCaptcha 超出配额后需要人机验证
+	SMSOverBudgetCaptcha = "captcha"
+)
+
+type captchaTokenCtxKey struct{}
+
+// captchaToken 人机验证token 同一个token只能校验一次，校验通过后同一请求中不再重复校验
+type captchaToken struct {
+	token    string
+	verified bool
+}
+
+// WithCaptchaToken 设置人机验证的token（超出短信配额或触发防刷且需要人机验证时校验）
+func WithCaptchaToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, captchaTokenCtxKey{}, &captchaToken{token: strings.TrimSpace(token)})
+}
+
+// WithSMSRequest 从请求中读取发送验证码需要的语言（Accept-Language）、人机验证token（X-Captcha-Token）、客户端IP、设备标识（X-Device-ID）和组织
+// 客户端IP只信任来自可信代理（ipGuard.trustedProxies）的X-Forwarded-For 避免伪造IP绕过按IP和IP段的限制
+func WithSMSRequest(ctx context.Context, c *wkhttp.Context) context.Context {
+	ctx = tenant.WithContext(ctx, tenant.ID(c.Context))
+	ctx = WithLocale(ctx, c.GetHeader("Accept-Language"))
+	ctx = WithSMSClient(ctx, extutil.GetTrustedClientIP(c.Request, extconfig.Get().IPGuard.TrustedProxies), c.GetHeader("X-Device-ID"))
+	return WithCaptchaToken(ctx, c.GetHeader("X-Captcha-Token"))
+}
+
+// checkQuota 检查发送配额 超出配额时根据配置拒绝发送、切换短信服务商或要求人机验证
+func (s *SMSService) checkQuota(ctx context.Context, zone, phone string, senders []*smsSender) ([]*smsSender, error) {
+	quotaCfg := extconfig.Get().SMSQuota
+	now := time.Now()
+	if quotaCfg.PhoneDailyLimit > 0 {
+		count, err := s.getQuotaCount(fmt.Sprintf("%sphone:day:%s@%s@%s", CacheKeySMSQuota, now.Format("20060102"), zone, phone))
+		if err != nil {
+			return nil, err
+		}
+		if count >= int64(quotaCfg.PhoneDailyLimit) {
+			return nil, errors.New("该手机号今日获取验证码次数已达上限！")
+		}
+	}
+	if quotaCfg.PhoneMonthlyLimit > 0 {
+		count, err := s.getQuotaCount(fmt.Sprintf("%sphone:month:%s@%s@%s", CacheKeySMSQuota, now.Format("200601"), zone, phone))
+		if err != nil {
+			return nil, err
+		}
+		if count >= int64(quotaCfg.PhoneMonthlyLimit) {
+			return nil, errors.New("该手机号本月获取验证码次数已达上限！")
+		}
+	}
+
+	overBudget := false
+	if quotaCfg.DailyLimit > 0 {
+		count, err := s.getQuotaCount(fmt.Sprintf("%sday:%s", CacheKeySMSQuota, now.Format("20060102")))
+		if err != nil {
+			return nil, err
+		}
+		overBudget = count >= int64(quotaCfg.DailyLimit)
+	}
+	if !overBudget && quotaCfg.MonthlyLimit > 0 {
+		count, err := s.getQuotaCount(fmt.Sprintf("%smonth:%s", CacheKeySMSQuota, now.Format("200601")))
+		if err != nil {
+			return nil, err
+		}
+		overBudget = count >= int64(quotaCfg.MonthlyLimit)
+	}
+	if !overBudget {
+		return senders, nil
+	}
+
+	switch quotaCfg.OverBudgetAction {
+	case SMSOverBudgetProvider:
+		smsProvider := s.newSMSProvider(config.SMSProvider(quotaCfg.OverBudgetProvider))
+		if smsProvider == nil {
+			s.Error("超出短信配额后使用的短信服务商不支持！", zap.String("provider", quotaCfg.OverBudgetProvider))
+			return nil, errors.New("短信发送量已达上限，请稍后再试！")
+		}
+		newSenders := make([]*smsSender, 0, len(senders))
+		for _, sender := range senders {
+			if sender.channel {
+				newSenders = append(newSenders, sender)
+			}
+		}
+		return append(newSenders, &smsSender{name: quotaCfg.OverBudgetProvider, provider: smsProvider}), nil
+	case SMSOverBudgetCaptcha:
+		if err := s.verifyCaptcha(ctx); err != nil {
+			return nil, err
+		}
+		return senders, nil
+	}
+	return nil, errors.New("短信发送量已达上限，请稍后再试！")
+}
+
+// incrQuota 发送成功后增加发送量 短信发送量达到提醒值或配额时通知管理员
+func (s *SMSService) incrQuota(zone, phone string, sender *smsSender) {
+	quotaCfg := extconfig.Get().SMSQuota
+	now := time.Now()
+	if quotaCfg.PhoneDailyLimit > 0 {
+		s.incrQuotaCount(fmt.Sprintf("%sphone:day:%s@%s@%s", CacheKeySMSQuota, now.Format("20060102"), zone, phone), time.Hour*25)
+	}
+	if quotaCfg.PhoneMonthlyLimit > 0 {
+		s.incrQuotaCount(fmt.Sprintf("%sphone:month:%s@%s@%s", CacheKeySMSQuota, now.Format("200601"), zone, phone), time.Hour*24*32)
+	}
+	if sender.channel {
+		// WhatsApp/Telegram 不计入短信发送量
+		return
+	}
+	dayCount := s.incrQuotaCount(fmt.Sprintf("%sday:%s", CacheKeySMSQuota, now.Format("20060102")), time.Hour*25)
+	monthCount := s.incrQuotaCount(fmt.Sprintf("%smonth:%s", CacheKeySMSQuota, now.Format("200601")), time.Hour*24*32)
+	s.checkQuotaAlert("今日", dayCount, quotaCfg.DailyLimit, quotaCfg)
+	s.checkQuotaAlert("本月", monthCount, quotaCfg.MonthlyLimit, quotaCfg)
+}
+
+func (s *SMSService) getQuotaCount(key string) (int64, error) {
+	value, err := s.codeStore.cache.GetString(key)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (s *SMSService) incrQuotaCount(key string, expire time.Duration) int64 {
+	count, err := s.codeStore.cache.Incr(key)
+	if err != nil {
+		s.Warn("增加短信发送量失败！", zap.Error(err), zap.String("key", key))
+		return 0
+	}
+	if count == 1 {
+		_ = s.codeStore.cache.Expire(key, expire)
+	}
+	return count
+}
+
+// checkQuotaAlert 发送量刚好达到提醒值或配额时通知管理员（Incr是原子的，每个值只会有一个请求拿到）
+func (s *SMSService) checkQuotaAlert(period string, count int64, limit int, quotaCfg extconfig.SMSQuotaConfig) {
+	if limit <= 0 || count <= 0 {
+		return
+	}
+	if count == int64(limit) {
+		action := "拒绝发送"
+		if quotaCfg.OverBudgetAction == SMSOverBudgetProvider {
+			action = fmt.Sprintf("切换到%s发送", quotaCfg.OverBudgetProvider)
+		} else if quotaCfg.OverBudgetAction == SMSOverBudgetCaptcha {
+			action = "需要人机验证后发送"
+		}
+		s.sendQuotaAlert(fmt.Sprintf("短信配额提醒：%s短信发送量已达到配额%d条，之后的验证码将%s。", period, limit, action))
+		return
+	}
+	if quotaCfg.AlertPercent > 0 && quotaCfg.AlertPercent < 100 && count == int64(limit*quotaCfg.AlertPercent/100) {
+		s.sendQuotaAlert(fmt.Sprintf("短信配额提醒：%s短信发送量已达%d条，为配额%d条的%d%%。", period, count, limit, quotaCfg.AlertPercent))
+	}
+}
+
+// sendQuotaAlert 以系统账号给管理员发送提醒消息
+func (s *SMSService) sendQuotaAlert(content string) {
+	s.Warn(content)
+	if err := sendAdminAlert(s.ctx, content); err != nil {
+		s.Error("发送短信配额提醒失败！", zap.Error(err))
+	}
+}
+
+// sendAdminAlert 以系统账号给管理员发送文本消息 没有配置管理员时不发送
+func sendAdminAlert(ctx *config.Context, content string) error {
+	adminUID := ctx.GetConfig().Account.AdminUID
+	if adminUID == "" {
+		return nil
+	}
+	return ctx.SendMessage(&config.MsgSendReq{
+		FromUID:     ctx.GetConfig().Account.SystemUID,
+		ChannelID:   adminUID,
+		ChannelType: libcommon.ChannelTypePerson.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"type":    libcommon.Text,
+		})),
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+	})
+}
+
+// verifyCaptcha 校验人机验证token
+func (s *SMSService) verifyCaptcha(ctx context.Context) error {
+	captcha, _ := ctx.Value(captchaTokenCtxKey{}).(*captchaToken)
+	if captcha == nil || captcha.token == "" {
+		return ErrSMSCaptchaRequired
+	}
+	if captcha.verified {
+		return nil
+	}
+	captchaCfg := extconfig.Get().Captcha
+	if captchaCfg.Secret == "" {
+		s.Error("没有配置人机验证的密钥！")
+		return ErrSMSCaptchaRequired
+	}
+	client := &http.Client{Timeout: time.Second * 10}
+	resp, err := client.PostForm(captchaCfg.VerifyURL, url.Values{
+		"secret":   {captchaCfg.Secret},
+		"response": {captcha.token},
+	})
+	if err != nil {
+		s.Error("人机验证请求失败！", zap.Error(err))
+		return errors.New("人机验证失败，请稍后再试！")
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		s.Error("解析人机验证返回数据失败！", zap.Error(err), zap.Int("status", resp.StatusCode))
+		return errors.New("人机验证失败，请稍后再试！")
+	}
+	if !result.Success {
+		s.Warn("人机验证未通过", zap.Strings("errorCodes", result.ErrorCodes))
+		return ErrSMSCaptchaRequired
+	}
+	captcha.verified = true
+	return nil
+}
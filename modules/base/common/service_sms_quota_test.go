@@ -0,0 +1,147 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSMSRequestClientIP(t *testing.T) {
+	request := func(remoteAddr, forwarded string) *smsClient {
+		r := httptest.NewRequest("POST", "/v1/user/sms/registercode", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-For", forwarded)
+		r.Header.Set("X-Device-ID", "d1")
+		ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ginCtx.Request = r
+		ctx := WithSMSRequest(r.Context(), &wkhttp.Context{Context: ginCtx})
+		client, _ := ctx.Value(smsClientCtxKey{}).(*smsClient)
+		return client
+	}
+	// 直连时伪造的X-Forwarded-For不生效
+	client := request("1.2.3.4:5678", "8.8.8.8")
+	assert.Equal(t, "1.2.3.4", client.ip)
+	assert.Equal(t, "d1", client.deviceID)
+	// 经过可信代理时使用最右边不可信的地址
+	client = request("127.0.0.1:5678", "8.8.8.8, 1.2.3.4")
+	assert.Equal(t, "1.2.3.4", client.ip)
+}
+
+func newTestQuotaService() *SMSService {
+	cfg := config.New()
+	cfg.Account.AdminUID = "" // 达到配额时不发送提醒消息
+	return &SMSService{
+		ctx:       testutil.NewTestContext(cfg),
+		Log:       log.NewTLog("SMSService"),
+		codeStore: newTestCodeStore(newMemCodeCache()),
+	}
+}
+
+func TestSMSCheckQuota(t *testing.T) {
+	quotaCfg := &extconfig.Get().SMSQuota
+	old := *quotaCfg
+	defer func() { *quotaCfg = old }()
+	*quotaCfg = extconfig.SMSQuotaConfig{DailyLimit: 3, PhoneDailyLimit: 2, OverBudgetAction: SMSOverBudgetReject}
+
+	s := newTestQuotaService()
+	sender := &smsSender{name: "aliyun"}
+	senders := []*smsSender{sender}
+	result, err := s.checkQuota(context.Background(), "0086", "13800138000", senders)
+	assert.NoError(t, err)
+	assert.Equal(t, senders, result)
+
+	// 同一手机号每天的限制
+	s.incrQuota("0086", "13800138000", sender)
+	s.incrQuota("0086", "13800138000", sender)
+	_, err = s.checkQuota(context.Background(), "0086", "13800138000", senders)
+	assert.EqualError(t, err, "该手机号今日获取验证码次数已达上限！")
+	_, err = s.checkQuota(context.Background(), "0086", "13800138001", senders)
+	assert.NoError(t, err)
+
+	// WhatsApp/Telegram不计入短信发送量
+	channel := &smsSender{name: "whatsapp", channel: true}
+	s.incrQuota("0086", "13800138002", channel)
+	_, err = s.checkQuota(context.Background(), "0086", "13800138001", senders)
+	assert.NoError(t, err)
+
+	// 超出每天的配额
+	s.incrQuota("0086", "13800138001", sender)
+	_, err = s.checkQuota(context.Background(), "0086", "13800138001", senders)
+	assert.EqualError(t, err, "短信发送量已达上限，请稍后再试！")
+
+	// 超出配额后切换服务商 保留WhatsApp/Telegram通道
+	quotaCfg.OverBudgetAction = SMSOverBudgetProvider
+	quotaCfg.OverBudgetProvider = string(SMSProviderMock)
+	result, err = s.checkQuota(context.Background(), "0086", "13800138001", []*smsSender{channel, sender})
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.Equal(t, channel, result[0])
+	assert.Equal(t, string(SMSProviderMock), result[1].name)
+	quotaCfg.OverBudgetProvider = "unknown"
+	_, err = s.checkQuota(context.Background(), "0086", "13800138001", senders)
+	assert.Error(t, err)
+}
+
+func TestSMSCheckQuotaCaptcha(t *testing.T) {
+	quotaCfg := &extconfig.Get().SMSQuota
+	captchaCfg := &extconfig.Get().Captcha
+	oldQuota, oldCaptcha := *quotaCfg, *captchaCfg
+	defer func() { *quotaCfg, *captchaCfg = oldQuota, oldCaptcha }()
+	*quotaCfg = extconfig.SMSQuotaConfig{DailyLimit: 1, OverBudgetAction: SMSOverBudgetCaptcha}
+
+	verifyCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifyCount++
+		assert.Equal(t, "secret", r.FormValue("secret"))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": r.FormValue("response") == "pass"})
+	}))
+	defer server.Close()
+	*captchaCfg = extconfig.CaptchaConfig{VerifyURL: server.URL, Secret: "secret"}
+
+	s := newTestQuotaService()
+	senders := []*smsSender{{name: "aliyun"}}
+	s.incrQuota("0086", "13800138000", senders[0])
+
+	_, err := s.checkQuota(context.Background(), "0086", "13800138000", senders)
+	assert.Equal(t, ErrSMSCaptchaRequired, err)
+	_, err = s.checkQuota(WithCaptchaToken(context.Background(), "fail"), "0086", "13800138000", senders)
+	assert.Equal(t, ErrSMSCaptchaRequired, err)
+
+	// 同一请求中校验通过后不再重复校验
+	ctx := WithCaptchaToken(context.Background(), "pass")
+	_, err = s.checkQuota(ctx, "0086", "13800138000", senders)
+	assert.NoError(t, err)
+	_, err = s.checkQuota(ctx, "0086", "13800138000", senders)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, verifyCount)
+}
+
+func TestSMSCheckAbuse(t *testing.T) {
+	abuseCfg := &extconfig.Get().SMSAbuse
+	old := *abuseCfg
+	defer func() { *abuseCfg = old }()
+	*abuseCfg = extconfig.SMSAbuseConfig{IPHourlyLimit: 1, Action: SMSAbuseActionReject, BlockedPrefixes: []string{"+882"}}
+
+	s := newTestQuotaService()
+	assert.EqualError(t, s.checkAbuse(context.Background(), "00882", "12345678"), "该手机号不支持获取验证码！")
+
+	ctx := WithSMSClient(context.Background(), "1.2.3.4", "")
+	assert.NoError(t, s.checkAbuse(ctx, "0086", "13800138000"))
+	assert.EqualError(t, s.checkAbuse(ctx, "0086", "13800138001"), "获取验证码过于频繁，请稍后再试！")
+	assert.NoError(t, s.checkAbuse(WithSMSClient(context.Background(), "1.2.3.5", ""), "0086", "13800138002"))
+
+	// 没有设备标识时需要人机验证
+	abuseCfg.Action = SMSAbuseActionCaptcha
+	abuseCfg.RequireDevice = true
+	assert.Equal(t, ErrSMSCaptchaRequired, s.checkAbuse(WithSMSClient(context.Background(), "1.2.3.6", ""), "0086", "13800138003"))
+}
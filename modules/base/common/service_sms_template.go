@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type localeCtxKey struct{}
+
+// smsTemplateQuerier 查询启用的短信模版 测试时可以替换
+type smsTemplateQuerier interface {
+	queryEnableWithLocales(provider string, codeType CodeType, locales []string) ([]*smsTemplateModel, error)
+}
+
+// WithLocale 设置发送验证码使用的语言 例如 Accept-Language 请求头的值
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeCtxKey{}, normalizeLocale(locale))
+}
+
+// normalizeLocale 取第一个语言 例如 "zh-CN,zh;q=0.9" => "zh-CN"
+func normalizeLocale(locale string) string {
+	locale = strings.TrimSpace(locale)
+	if idx := strings.IndexAny(locale, ",;"); idx >= 0 {
+		locale = locale[:idx]
+	}
+	return strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+}
+
+// smsLocales 模版匹配的语言顺序：完整语言 > 语言（不含地区） > 默认
+// 没有设置语言时 国内区号使用zh-CN 其他使用en
+func smsLocales(ctx context.Context, zone string) []string {
+	locale, _ := ctx.Value(localeCtxKey{}).(string)
+	if locale == "" {
+		if zone == "0086" {
+			locale = "zh-CN"
+		} else {
+			locale = "en"
+		}
+	}
+	locales := []string{locale}
+	if idx := strings.Index(locale, "-"); idx > 0 {
+		locales = append(locales, locale[:idx])
+	}
+	return append(locales, "")
+}
+
+// getTemplate 获取后台配置的短信模版 没有配置返回空
+func (s *SMSService) getTemplate(ctx context.Context, provider string, zone string, codeType CodeType) string {
+	locales := smsLocales(ctx, zone)
+	models, err := s.templateDB.queryEnableWithLocales(provider, codeType, locales)
+	if err != nil {
+		s.Warn("查询短信模版失败！", zap.Error(err), zap.String("provider", provider))
+		return ""
+	}
+	for _, locale := range locales {
+		for _, m := range models {
+			if strings.EqualFold(m.Locale, locale) {
+				return m.Template
+			}
+		}
+	}
+	return ""
+}
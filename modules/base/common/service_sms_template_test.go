@@ -0,0 +1,157 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// memTemplateDB 内存中的短信模版 按数据库的查询条件过滤
+type memTemplateDB struct {
+	models []*smsTemplateModel
+	err    error
+}
+
+func (m *memTemplateDB) queryEnableWithLocales(provider string, codeType CodeType, locales []string) ([]*smsTemplateModel, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	list := make([]*smsTemplateModel, 0)
+	for _, model := range m.models {
+		if model.Provider != provider || model.CodeType != int(codeType) || model.Status != 1 {
+			continue
+		}
+		// 数据库的排序规则不区分大小写
+		for _, locale := range locales {
+			if strings.EqualFold(model.Locale, locale) {
+				list = append(list, model)
+				break
+			}
+		}
+	}
+	return list, nil
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "", want: ""},
+		{locale: "zh-CN", want: "zh-CN"},
+		{locale: " zh_CN ", want: "zh-CN"},
+		{locale: "zh-CN,zh;q=0.9,en;q=0.8", want: "zh-CN"},
+		{locale: "en;q=0.8", want: "en"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, normalizeLocale(tt.locale), tt.locale)
+	}
+}
+
+func TestSMSLocales(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+		zone   string
+		want   []string
+	}{
+		{name: "国内区号默认中文", zone: "0086", want: []string{"zh-CN", "zh", ""}},
+		{name: "其他区号默认英文", zone: "001", want: []string{"en", ""}},
+		{name: "完整语言再到语言", locale: "pt_BR", zone: "0055", want: []string{"pt-BR", "pt", ""}},
+		{name: "设置的语言优先于区号", locale: "en-US,en;q=0.9", zone: "0086", want: []string{"en-US", "en", ""}},
+		{name: "没有地区", locale: "ja", zone: "0081", want: []string{"ja", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.locale != "" {
+				ctx = WithLocale(ctx, tt.locale)
+			}
+			assert.Equal(t, tt.want, smsLocales(ctx, tt.zone))
+		})
+	}
+}
+
+func TestSMSGetTemplate(t *testing.T) {
+	templateDB := &memTemplateDB{models: []*smsTemplateModel{
+		{Provider: string(SMSProviderTwilio), CodeType: int(CodeTypeRegister), Locale: "zh-CN", Template: "twilio-register-zh-CN", Status: 1},
+		{Provider: string(SMSProviderTwilio), CodeType: int(CodeTypeRegister), Locale: "zh", Template: "twilio-register-zh", Status: 1},
+		{Provider: string(SMSProviderTwilio), CodeType: int(CodeTypeRegister), Locale: "", Template: "twilio-register", Status: 1},
+		{Provider: string(SMSProviderTwilio), CodeType: int(CodeTypeRegister), Locale: "en", Template: "twilio-register-en-disabled", Status: 0},
+		{Provider: string(SMSProviderTwilio), CodeType: int(CodeTypeForgetLoginPWD), Locale: "", Template: "twilio-forget", Status: 1},
+		{Provider: string(SMSProviderTencent), CodeType: int(CodeTypeRegister), Locale: "en", Template: "tencent-register-en", Status: 1},
+	}}
+	s := &SMSService{Log: log.NewTLog("SMSService"), templateDB: templateDB}
+
+	tests := []struct {
+		name     string
+		provider config.SMSProvider
+		zone     string
+		locale   string
+		codeType CodeType
+		want     string
+	}{
+		{name: "完整语言", provider: SMSProviderTwilio, zone: "0086", locale: "zh-CN", codeType: CodeTypeRegister, want: "twilio-register-zh-CN"},
+		{name: "语言不区分大小写", provider: SMSProviderTwilio, zone: "0086", locale: "ZH-cn", codeType: CodeTypeRegister, want: "twilio-register-zh-CN"},
+		{name: "地区没有模版时使用语言", provider: SMSProviderTwilio, zone: "00886", locale: "zh-TW", codeType: CodeTypeRegister, want: "twilio-register-zh"},
+		{name: "语言没有模版时使用默认", provider: SMSProviderTwilio, zone: "0081", locale: "ja-JP", codeType: CodeTypeRegister, want: "twilio-register"},
+		{name: "没有设置语言时按区号", provider: SMSProviderTwilio, zone: "0086", codeType: CodeTypeRegister, want: "twilio-register-zh-CN"},
+		{name: "禁用的模版不使用", provider: SMSProviderTwilio, zone: "001", codeType: CodeTypeRegister, want: "twilio-register"},
+		{name: "按验证码类型", provider: SMSProviderTwilio, zone: "0086", locale: "zh-CN", codeType: CodeTypeForgetLoginPWD, want: "twilio-forget"},
+		{name: "验证码类型没有模版", provider: SMSProviderTwilio, zone: "0086", codeType: CodeTypePayPWD, want: ""},
+		{name: "按服务商", provider: SMSProviderTencent, zone: "001", locale: "en-US", codeType: CodeTypeRegister, want: "tencent-register-en"},
+		{name: "服务商没有匹配的语言和默认模版", provider: SMSProviderTencent, zone: "0086", codeType: CodeTypeRegister, want: ""},
+		{name: "服务商没有模版", provider: SMSProviderAWS, zone: "001", codeType: CodeTypeRegister, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.locale != "" {
+				ctx = WithLocale(ctx, tt.locale)
+			}
+			assert.Equal(t, tt.want, s.getTemplate(ctx, string(tt.provider), tt.zone, tt.codeType))
+		})
+	}
+
+	// 查询失败时不使用后台模版
+	templateDB.err = errors.New("db error")
+	assert.Equal(t, "", s.getTemplate(context.Background(), string(SMSProviderTwilio), "0086", CodeTypeRegister))
+}
+
+func TestSMSSenderTemplate(t *testing.T) {
+	routes := extconfig.Get().SMSRoutes
+	defer func() { extconfig.Get().SMSRoutes = routes }()
+	extconfig.Get().SMSRoutes = []extconfig.SMSRouteConfig{
+		{Zones: []string{"0086"}, Provider: string(SMSProviderTwilio), Template: "route-template"},
+		{Zones: []string{"*"}, Provider: string(SMSProviderTwilio)},
+	}
+	templateDB := &memTemplateDB{models: []*smsTemplateModel{
+		{Provider: string(SMSProviderTwilio), CodeType: int(CodeTypeRegister), Locale: "zh-CN", Template: "db-template", Status: 1},
+	}}
+	s := &SMSService{
+		ctx:        testutil.NewTestContext(config.New()),
+		Log:        log.NewTLog("SMSService"),
+		templateDB: templateDB,
+	}
+	template := func(zone string, codeType CodeType) string {
+		sender := s.getSMSSender(context.Background(), zone, codeType)
+		assert.NotNil(t, sender)
+		if templateProvider, ok := sender.provider.(*templateSMSProvider); ok {
+			return templateProvider.template
+		}
+		return ""
+	}
+	// 后台模版优先
+	assert.Equal(t, "db-template", template("0086", CodeTypeRegister))
+	// 没有后台模版时使用路由规则的模版
+	assert.Equal(t, "route-template", template("0086", CodeTypeForgetLoginPWD))
+	// 都没有时使用服务商默认模版
+	assert.Equal(t, "", template("001", CodeTypeForgetLoginPWD))
+}
@@ -0,0 +1,214 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// redirectTransport 把请求转发到测试服务器 服务商的接口地址是固定的
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (r *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newRedirectClient(t *testing.T, handler http.HandlerFunc) *http.Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	return &http.Client{Transport: &redirectTransport{target: target}}
+}
+
+func TestTwilioProviderSend(t *testing.T) {
+	cfg := config.New()
+	cfg.AppName = "tsdd"
+	ctx := testutil.NewTestContext(cfg)
+	twilioCfg := &extconfig.Get().TwilioSMS
+	old := *twilioCfg
+	defer func() { *twilioCfg = old }()
+
+	provider := NewTwilioProvider(ctx).(*TwilioProvider)
+	// 没有配置
+	*twilioCfg = extconfig.TwilioSMSConfig{}
+	_, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.Error(t, err)
+
+	*twilioCfg = extconfig.TwilioSMSConfig{AccountSID: "AC1", AuthToken: "token", Template: "{appName}验证码：{code}"}
+	_, err = provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.EqualError(t, err, "twilio短信需要配置from或messagingServiceSID！")
+
+	twilioCfg.From = "+15005550006"
+	provider.client = newRedirectClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/2010-04-01/Accounts/AC1/Messages.json", r.URL.Path)
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "AC1", user)
+		assert.Equal(t, "token", pass)
+		assert.Equal(t, "+15005550006", r.FormValue("From"))
+		if r.FormValue("To") != "+8613800138000" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 21211, "message": "Invalid 'To' Phone Number"})
+			return
+		}
+		assert.Equal(t, "tsdd验证码：123456", r.FormValue("Body"))
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"sid": "SM1", "status": "queued"})
+	})
+	result, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, "SM1", result.MessageID)
+
+	_, err = provider.SendSMS(context.Background(), "0086", "1", "123456")
+	assert.EqualError(t, err, "Invalid 'To' Phone Number")
+}
+
+func TestTencentProviderSend(t *testing.T) {
+	ctx := testutil.NewTestContext(config.New())
+	tencentCfg := &extconfig.Get().TencentSMS
+	old := *tencentCfg
+	defer func() { *tencentCfg = old }()
+
+	provider := NewTencentProvider(ctx).(*TencentProvider)
+	*tencentCfg = extconfig.TencentSMSConfig{}
+	_, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.Error(t, err)
+
+	*tencentCfg = extconfig.TencentSMSConfig{SecretID: "id", SecretKey: "key", Region: "ap-guangzhou", SmsSdkAppID: "1400000000", SignName: "签名", TemplateID: "100", InternationalTemplateID: "200"}
+	provider.client = newRedirectClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "SendSms", r.Header.Get("X-TC-Action"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "TC3-HMAC-SHA256 Credential=id/"))
+		var req struct {
+			PhoneNumberSet []string
+			SignName       string
+			TemplateId     string
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		resp := map[string]interface{}{"RequestId": "r1"}
+		switch req.PhoneNumberSet[0] {
+		case "+8613800138000":
+			assert.Equal(t, "签名", req.SignName)
+			assert.Equal(t, "100", req.TemplateId)
+			resp["SendStatusSet"] = []map[string]interface{}{{"SerialNo": "s1", "Fee": 1, "Code": "Ok"}}
+		case "+85261234567":
+			// 国际/港澳台短信不需要签名
+			assert.Equal(t, "", req.SignName)
+			assert.Equal(t, "200", req.TemplateId)
+			resp["SendStatusSet"] = []map[string]interface{}{{"SerialNo": "s2", "Fee": 2, "Code": "LimitExceeded.PhoneNumberDailyLimit"}}
+		default:
+			resp["Error"] = map[string]interface{}{"Code": "InvalidParameterValue.IncorrectPhoneNumber", "Message": "incorrect"}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Response": resp})
+	})
+	result, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", result.MessageID)
+	assert.Equal(t, 1, result.Segments)
+
+	_, err = provider.SendSMS(context.Background(), "00852", "61234567", "123456")
+	assert.EqualError(t, err, "该手机号今日接收短信次数已达上限！")
+
+	_, err = provider.SendSMS(context.Background(), "0086", "1", "123456")
+	assert.EqualError(t, err, "手机号格式有误！")
+	assert.EqualError(t, tencentSMSError("Unknown"), "短信发送失败，请稍后再试！")
+}
+
+func TestMockProvider(t *testing.T) {
+	mockCfg := &extconfig.Get().SMSMock
+	enable := mockCfg.Enable
+	defer func() { mockCfg.Enable = enable }()
+
+	provider := NewMockProvider(nil)
+	mockCfg.Enable = false
+	_, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.Error(t, err)
+
+	mockCfg.Enable = true
+	result, err := provider.SendSMS(context.Background(), "0086", "13800138000", "123456")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(result.MessageID, "mock-"))
+}
+
+func TestSMSRoutes(t *testing.T) {
+	routes := extconfig.Get().SMSRoutes
+	defer func() { extconfig.Get().SMSRoutes = routes }()
+	extconfig.Get().SMSRoutes = nil
+
+	cfg := config.New()
+	cfg.SMSProvider = ""
+	assert.Nil(t, getSMSRoutes(cfg))
+
+	cfg.SMSProvider = config.SMSProviderAliyun
+	cfg.AliyunInternationalSMS.AccessKeyID = "id"
+	defaultRoutes := getSMSRoutes(cfg)
+	assert.Equal(t, string(config.SMSProviderAliyun), matchSMSRoute(defaultRoutes, "0086").Provider)
+	assert.Equal(t, string(SMSProviderAliyunInternational), matchSMSRoute(defaultRoutes, "001").Provider)
+
+	extconfig.Get().SMSRoutes = []extconfig.SMSRouteConfig{
+		{Zones: []string{"0086", "00852"}, Provider: string(SMSProviderTencent), Template: "100"},
+		{Zones: []string{"001"}, Provider: string(SMSProviderTwilio)},
+	}
+	configRoutes := getSMSRoutes(cfg)
+	assert.Len(t, configRoutes, 2)
+	assert.Equal(t, "100", matchSMSRoute(configRoutes, "00852").Template)
+	assert.Equal(t, string(SMSProviderTwilio), matchSMSRoute(configRoutes, "001").Provider)
+	assert.Nil(t, matchSMSRoute(configRoutes, "0044"))
+}
+
+func TestSMSSendVerifyCodeAllFailed(t *testing.T) {
+	routes := extconfig.Get().SMSRoutes
+	mockCfg := extconfig.Get().SMSMock
+	channels := extconfig.Get().OTPChannel.Channels
+	defer func() {
+		extconfig.Get().SMSRoutes = routes
+		extconfig.Get().SMSMock = mockCfg
+		extconfig.Get().OTPChannel.Channels = channels
+	}()
+	// 关闭模拟短信时模拟短信服务商发送失败
+	extconfig.Get().SMSRoutes = []extconfig.SMSRouteConfig{{Zones: []string{"0086"}, Provider: string(SMSProviderMock)}}
+	extconfig.Get().SMSMock.Enable = false
+	extconfig.Get().OTPChannel.Channels = nil
+
+	ctx := testutil.NewTestContext(config.New())
+	cache := newMemCodeCache()
+	s := &SMSService{
+		ctx:       ctx,
+		Log:       log.NewTLog("SMSService"),
+		sendLogDB: newSMSSendLogDB(ctx.DB()),
+		codeStore: newTestCodeStore(cache),
+	}
+	subject := smsSubject("0086", "13800138000")
+	codeKey := s.codeStore.key(CacheKeySMSCode, CodeTypeRegister, subject)
+
+	// 都发送失败时可以马上重试 也不保留没有发出的验证码
+	err := s.SendVerifyCode(context.Background(), "0086", "13800138000", CodeTypeRegister)
+	assert.Error(t, err)
+	assert.NoError(t, s.codeStore.checkInterval(CodeTypeRegister, subject))
+	code, _ := cache.GetString(codeKey)
+	assert.Equal(t, "", code)
+
+	// 继续使用之前已发出的验证码时保留验证码
+	issued, _, err := s.codeStore.issue(CodeTypeRegister, subject)
+	assert.NoError(t, err)
+	cache.advance(extconfig.Get().OTP.Params(CodeTypeRegister.Name()).Interval + time.Second)
+	err = s.SendVerifyCode(context.Background(), "0086", "13800138000", CodeTypeRegister)
+	assert.Error(t, err)
+	assert.NoError(t, s.codeStore.checkInterval(CodeTypeRegister, subject))
+	code, _ = cache.GetString(codeKey)
+	assert.Equal(t, issued, code)
+}
@@ -0,0 +1,183 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+const (
+	tencentSMSHost    = "sms.tencentcloudapi.com"
+	tencentSMSService = "sms"
+	tencentSMSVersion = "2021-01-11"
+)
+
+// 腾讯云短信错误码对应的提示（https://cloud.tencent.com/document/api/382/55981）
+var tencentSMSErrMessages = map[string]string{
+	"LimitExceeded.PhoneNumberThirtySecondLimit":                "发送过于频繁，请30秒后再试！",
+	"LimitExceeded.PhoneNumberOneHourLimit":                     "该手机号1小时内接收短信次数已达上限！",
+	"LimitExceeded.PhoneNumberDailyLimit":                       "该手机号今日接收短信次数已达上限！",
+	"LimitExceeded.PhoneNumberSameContentDailyLimit":            "该手机号今日接收短信次数已达上限！",
+	"LimitExceeded.DeliveryFrequencyLimit":                      "发送过于频繁，请稍后再试！",
+	"InvalidParameterValue.IncorrectPhoneNumber":                "手机号格式有误！",
+	"UnsupportedOperation.UnsupportedRegion":                    "暂不支持该国家或地区的手机号！",
+	"UnsupportedOperation.ChineseMainlandTemplateToGlobalPhone": "暂不支持该国家或地区的手机号！",
+	"UnsupportedOperation.GlobalTemplateToChineseMainlandPhone": "暂不支持该国家或地区的手机号！",
+	"FailedOperation.PhoneNumberInBlacklist":                    "该手机号已退订短信，无法接收验证码！",
+}
+
+type TencentProvider struct {
+	ctx *config.Context
+	log.Log
+	client *http.Client
+}
+
+// NewTencentProvider 创建腾讯云短信服务
+func NewTencentProvider(ctx *config.Context) ISMSProvider {
+	return &TencentProvider{
+		ctx:    ctx,
+		Log:    log.NewTLog("TencentProvider"),
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (t *TencentProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	return t.SendSMSWithTemplate(ctx, zone, phone, code, "")
+}
+
+// SendSMSWithTemplate template为模版ID 为空则根据区号使用templateID或internationalTemplateID
+func (t *TencentProvider) SendSMSWithTemplate(ctx context.Context, zone, phone string, code string, template string) (*SMSSendResult, error) {
+	span, _ := t.ctx.Tracer().StartSpanFromContext(ctx, "smsService.SendVerifyCode")
+	defer span.Finish()
+
+	tencentCfg := extconfig.Get().TencentSMS
+	if tencentCfg.SecretID == "" || tencentCfg.SecretKey == "" || tencentCfg.SmsSdkAppID == "" {
+		return nil, errors.New("没有配置腾讯云短信！")
+	}
+	templateID := tencentCfg.TemplateID
+	signName := tencentCfg.SignName
+	if zone != "0086" {
+		// 国际/港澳台短信不需要签名
+		signName = ""
+		if tencentCfg.InternationalTemplateID != "" {
+			templateID = tencentCfg.InternationalTemplateID
+		}
+	}
+	if template != "" {
+		templateID = template
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"PhoneNumberSet":   []string{toE164(zone, phone)},
+		"SmsSdkAppId":      tencentCfg.SmsSdkAppID,
+		"SignName":         signName,
+		"TemplateId":       templateID,
+		"TemplateParamSet": []string{code},
+	})
+
+	req, err := http.NewRequest(http.MethodPost, "https://"+tencentSMSHost, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Host", tencentSMSHost)
+	req.Header.Set("X-TC-Action", "SendSms")
+	req.Header.Set("X-TC-Version", tencentSMSVersion)
+	req.Header.Set("X-TC-Timestamp", fmt.Sprintf("%d", timestamp))
+	req.Header.Set("X-TC-Region", tencentCfg.Region)
+	req.Header.Set("Authorization", tencentAuthorization(tencentCfg.SecretID, tencentCfg.SecretKey, "SendSms", payload, timestamp))
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		ext.LogError(span, err)
+		t.Error("发送短信失败！", zap.Error(err))
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result tencentSendSMSResp
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Error("解析腾讯云短信返回数据失败！", zap.Error(err), zap.Int("status", resp.StatusCode))
+		return nil, errors.New("发送短信失败！")
+	}
+	if result.Response.Error != nil {
+		t.Error("发送短信失败！", zap.String("code", result.Response.Error.Code), zap.String("message", result.Response.Error.Message), zap.String("requestId", result.Response.RequestID))
+		return nil, tencentSMSError(result.Response.Error.Code)
+	}
+	for _, status := range result.Response.SendStatusSet {
+		if !strings.EqualFold(status.Code, "Ok") {
+			t.Error("发送短信失败！", zap.String("code", status.Code), zap.String("message", status.Message), zap.String("requestId", result.Response.RequestID))
+			return nil, tencentSMSError(status.Code)
+		}
+	}
+	sendResult := &SMSSendResult{}
+	for _, status := range result.Response.SendStatusSet {
+		sendResult.MessageID = status.SerialNo
+		sendResult.Segments = status.Fee
+	}
+	return sendResult, nil
+}
+
+// tencentSMSError 将腾讯云错误码转为用户可读的错误
+func tencentSMSError(code string) error {
+	if msg, ok := tencentSMSErrMessages[code]; ok {
+		return errors.New(msg)
+	}
+	return errors.New("短信发送失败，请稍后再试！")
+}
+
+// tencentAuthorization 腾讯云 TC3-HMAC-SHA256 签名（https://cloud.tencent.com/document/api/382/52071）
+func tencentAuthorization(secretID, secretKey string, action string, payload []byte, timestamp int64) string {
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+	signedHeaders := "content-type;host;x-tc-action"
+	canonicalRequest := fmt.Sprintf("POST\n/\n\ncontent-type:application/json; charset=utf-8\nhost:%s\nx-tc-action:%s\n\n%s\n%s", tencentSMSHost, strings.ToLower(action), signedHeaders, sha256Hex(payload))
+	credentialScope := fmt.Sprintf("%s/%s/tc3_request", date, tencentSMSService)
+	stringToSign := fmt.Sprintf("TC3-HMAC-SHA256\n%d\n%s\n%s", timestamp, credentialScope, sha256Hex([]byte(canonicalRequest)))
+
+	secretDate := hmacSHA256([]byte("TC3"+secretKey), date)
+	secretService := hmacSHA256(secretDate, tencentSMSService)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+	return fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", secretID, credentialScope, signedHeaders, signature)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+type tencentSendSMSResp struct {
+	Response struct {
+		SendStatusSet []struct {
+			SerialNo    string `json:"SerialNo"`
+			PhoneNumber string `json:"PhoneNumber"`
+			Fee         int    `json:"Fee"`
+			Code        string `json:"Code"`
+			Message     string `json:"Message"`
+		} `json:"SendStatusSet"`
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+		RequestID string `json:"RequestId"`
+	} `json:"Response"`
+}
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+const twilioAPIURL = "https://api.twilio.com/2010-04-01"
+
+type TwilioProvider struct {
+	ctx *config.Context
+	log.Log
+	client *http.Client
+}
+
+// NewTwilioProvider 创建twilio短信服务
+func NewTwilioProvider(ctx *config.Context) ISMSProvider {
+	return &TwilioProvider{
+		ctx:    ctx,
+		Log:    log.NewTLog("TwilioProvider"),
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (t *TwilioProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	return t.SendSMSWithTemplate(ctx, zone, phone, code, "")
+}
+
+// SendSMSWithTemplate template为短信内容 为空则使用twilioSMS.template
+func (t *TwilioProvider) SendSMSWithTemplate(ctx context.Context, zone, phone string, code string, template string) (*SMSSendResult, error) {
+	span, _ := t.ctx.Tracer().StartSpanFromContext(ctx, "smsService.SendVerifyCode")
+	defer span.Finish()
+
+	twilioCfg := extconfig.Get().TwilioSMS
+	if twilioCfg.AccountSID == "" || twilioCfg.AuthToken == "" {
+		return nil, errors.New("没有配置twilio短信！")
+	}
+	if template == "" {
+		template = twilioCfg.Template
+	}
+	body := strings.NewReplacer("{appName}", t.ctx.GetConfig().AppName, "{code}", code).Replace(template)
+
+	form := url.Values{}
+	form.Set("To", toE164(zone, phone))
+	form.Set("Body", body)
+	if twilioCfg.MessagingServiceSID != "" {
+		form.Set("MessagingServiceSid", twilioCfg.MessagingServiceSID)
+	} else if twilioCfg.From != "" {
+		form.Set("From", twilioCfg.From)
+	} else {
+		return nil, errors.New("twilio短信需要配置from或messagingServiceSID！")
+	}
+	if twilioCfg.StatusCallback != "" {
+		form.Set("StatusCallback", twilioCfg.StatusCallback)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/Accounts/%s/Messages.json", twilioAPIURL, twilioCfg.AccountSID), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(twilioCfg.AccountSID, twilioCfg.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		ext.LogError(span, err)
+		t.Error("发送短信失败！", zap.Error(err))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result twilioMessageResp
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Error("解析twilio返回数据失败！", zap.Error(err), zap.Int("status", resp.StatusCode))
+		return nil, errors.New("发送短信失败！")
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		t.Error("发送短信失败！", zap.Int("status", resp.StatusCode), zap.Int("code", result.Code), zap.String("message", result.Message))
+		return nil, errors.New(result.Message)
+	}
+	t.Info("发送短信成功", zap.String("sid", result.SID), zap.String("status", result.Status))
+	return &SMSSendResult{MessageID: result.SID}, nil
+}
+
+// toE164 区号+手机号转为E.164格式 例如 0086 13800138000 => +8613800138000
+func toE164(zone, phone string) string {
+	return fmt.Sprintf("+%s%s", strings.TrimPrefix(zone, "00"), phone)
+}
+
+// twilioSignature 计算twilio回调签名 https://www.twilio.com/docs/usage/webhooks/webhooks-security
+func twilioSignature(authToken string, callbackURL string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buff strings.Builder
+	buff.WriteString(callbackURL)
+	for _, key := range keys {
+		for _, value := range params[key] {
+			buff.WriteString(key)
+			buff.WriteString(value)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(buff.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type twilioMessageResp struct {
+	SID     string `json:"sid"`
+	Status  string `json:"status"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 	"errors"
+	"strconv"
 	"strings"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
@@ -24,7 +25,12 @@ func NewUnismsProvider(ctx *config.Context) ISMSProvider {
 	}
 }
 
-func (u *UnismsProvider) SendSMS(ctx context.Context, zone, phone string, code string) error {
+func (u *UnismsProvider) SendSMS(ctx context.Context, zone, phone string, code string) (*SMSSendResult, error) {
+	return u.SendSMSWithTemplate(ctx, zone, phone, code, "")
+}
+
+// SendSMSWithTemplate template为模版ID 为空则使用uniSMS.templateId
+func (u *UnismsProvider) SendSMSWithTemplate(ctx context.Context, zone, phone string, code string, template string) (*SMSSendResult, error) {
 	ph := phone
 	if zone != "0086" {
 		if len(zone) > 2 {
@@ -38,18 +44,41 @@ func (u *UnismsProvider) SendSMS(ctx context.Context, zone, phone string, code s
 	message := unisms.BuildMessage()
 	message.SetTo(ph)
 	message.SetSignature(u.ctx.GetConfig().UniSMS.Signature)
-	message.SetTemplateId(u.ctx.GetConfig().UniSMS.TemplateId)
+	if template == "" {
+		template = u.ctx.GetConfig().UniSMS.TemplateId
+	}
+	message.SetTemplateId(template)
 	message.SetTemplateData(map[string]string{"code": code}) // 设置自定义参数 (变量短信)
 
 	// 发送短信
 	res, err := cli.Send(message)
 	if err != nil {
 		u.Error("发送短信失败！", zap.Error(err))
-		return err
+		return nil, err
 	}
 	if res.Code != "0" {
 		u.Error("发送短信失败！", zap.String("message", res.Message))
-		return errors.New(res.Message)
+		return nil, errors.New(res.Message)
+	}
+	return unismsSendResult(res.Data), nil
+}
+
+// unismsSendResult 解析unisms返回的消息ID和费用
+func unismsSendResult(data map[string]interface{}) *SMSSendResult {
+	result := &SMSSendResult{Currency: "CNY"}
+	if amount, ok := data["totalAmount"].(string); ok {
+		result.Cost, _ = strconv.ParseFloat(amount, 64)
+	}
+	messages, _ := data["messages"].([]interface{})
+	for _, message := range messages {
+		messageMap, ok := message.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		result.MessageID, _ = messageMap["id"].(string)
+		if messageCount, ok := messageMap["messageCount"].(float64); ok {
+			result.Segments = int(messageCount)
+		}
 	}
-	return nil
+	return result
 }
@@ -0,0 +1,194 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/redis"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// verifyCodeCache 验证码使用的redis命令
+type verifyCodeCache interface {
+	GetString(key string) (string, error)
+	SetAndExpire(key string, value interface{}, expire time.Duration) error
+	Del(key string) error
+	Incr(key string) (int64, error)
+	Expire(key string, expiration time.Duration) error
+	TTL(key string) (time.Duration, error)
+	SetNX(key string, value interface{}, expire time.Duration) (bool, error)
+	DelIfValue(key string, value string) error
+}
+
+// verifyCodeStore 验证码缓存 短信和邮件验证码共用（发送间隔、错误次数和锁定规则相同）
+// subject为验证码的接收者 短信为{zone}@{phone} 邮件为email@{邮箱}
+type verifyCodeStore struct {
+	log.Log
+	cache     verifyCodeCache
+	redisConn *redis.Conn // 需要SETNX 使用单独的连接
+}
+
+func newVerifyCodeStore(redisConn *redis.Conn) *verifyCodeStore {
+	return &verifyCodeStore{
+		Log:       log.NewTLog("verifyCodeStore"),
+		cache:     redisConn,
+		redisConn: redisConn,
+	}
+}
+
+func (v *verifyCodeStore) key(prefix string, codeType CodeType, subject string) string {
+	return fmt.Sprintf("%s%d@%s", prefix, codeType, subject)
+}
+
+// lockSending 同一接收者同一类型的验证码同时只能有一个请求在发送 返回释放锁的方法
+func (v *verifyCodeStore) lockSending(codeType CodeType, subject string) (func(), error) {
+	sendingKey := v.key(CacheKeySMSCodeSending, codeType, subject)
+	sendingValue := util.GenerUUID()
+	ok, err := v.cache.SetNX(sendingKey, sendingValue, smsSendingLockExpire)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("验证码正在发送中，请稍后再试！")
+	}
+	return func() {
+		if err := v.cache.DelIfValue(sendingKey, sendingValue); err != nil {
+			v.Warn("释放验证码发送锁失败！", zap.Error(err))
+		}
+	}, nil
+}
+
+// checkLocked 验证码错误次数过多时锁定
+func (v *verifyCodeStore) checkLocked(codeType CodeType, subject string) error {
+	locked, err := v.cache.GetString(v.key(CacheKeySMSCodeLock, codeType, subject))
+	if err != nil {
+		return err
+	}
+	if locked != "" {
+		return errors.New("验证码错误次数过多，请稍后再试！")
+	}
+	return nil
+}
+
+// checkInterval 检查发送间隔
+func (v *verifyCodeStore) checkInterval(codeType CodeType, subject string) error {
+	interval, err := v.cache.GetString(v.key(CacheKeySMSCodeInterval, codeType, subject))
+	if err != nil {
+		return err
+	}
+	if interval != "" {
+		return errcode.ErrVerifyCodeTooFrequent
+	}
+	return nil
+}
+
+// get 获取未过期的验证码
+func (v *verifyCodeStore) get(codeType CodeType, subject string) (string, error) {
+	return v.cache.GetString(v.key(CacheKeySMSCode, codeType, subject))
+}
+
+// issue 生成验证码并开始计算发送间隔 返回发送失败时撤销的方法
+// 未过期的验证码继续使用并保留原来的过期时间 避免用户收到多条验证码时只有最后一条有效 也避免重复获取延长验证码的有效期
+func (v *verifyCodeStore) issue(codeType CodeType, subject string) (string, func(), error) {
+	otpParams := extconfig.Get().OTP.Params(codeType.Name())
+	codeKey := v.key(CacheKeySMSCode, codeType, subject)
+	verifyCode, err := v.get(codeType, subject)
+	if err != nil {
+		return "", nil, err
+	}
+	reused := false
+	if len(verifyCode) == otpParams.Length {
+		ttl, err := v.cache.TTL(codeKey)
+		if err != nil {
+			return "", nil, err
+		}
+		// 剩余的有效期不足一个发送间隔或没有过期时间的重新生成
+		reused = ttl > otpParams.Interval && ttl <= otpParams.TTL
+	}
+	if !reused {
+		verifyCode = ""
+		rand.Seed(int64(time.Now().Nanosecond()))
+		for i := 0; i < otpParams.Length; i++ {
+			verifyCode += fmt.Sprintf("%v", rand.Intn(10))
+		}
+		if err = v.cache.SetAndExpire(codeKey, verifyCode, otpParams.TTL); err != nil {
+			return "", nil, err
+		}
+	}
+	v.Info("发送验证码", zap.String("code", verifyCode), zap.Bool("reused", reused))
+	intervalKey := v.key(CacheKeySMSCodeInterval, codeType, subject)
+	err = v.cache.SetAndExpire(intervalKey, "1", otpParams.Interval)
+	if err != nil {
+		return "", nil, err
+	}
+	// 没有发送成功时清除发送间隔 用户可以马上重试 新生成的验证码一并清除 继续使用的验证码之前已发送成功所以保留
+	revoke := func() {
+		if err := v.cache.Del(intervalKey); err != nil {
+			v.Warn("清除验证码发送间隔失败！", zap.Error(err))
+		}
+		if !reused {
+			if err := v.cache.DelIfValue(codeKey, verifyCode); err != nil {
+				v.Warn("清除验证码失败！", zap.Error(err))
+			}
+		}
+	}
+	return verifyCode, revoke, nil
+}
+
+// verify 验证验证码 验证通过销毁验证码 错误次数过多时作废验证码并锁定
+func (v *verifyCodeStore) verify(codeType CodeType, subject, code string) error {
+	if err := v.checkLocked(codeType, subject); err != nil {
+		return err
+	}
+	cacheKey := v.key(CacheKeySMSCode, codeType, subject)
+	mockCfg := extconfig.Get().SMSMock
+	if mockCfg.Enable && mockCfg.UniversalCode != "" && code == mockCfg.UniversalCode {
+		v.Warn("使用万能验证码通过验证", zap.String("subject", subject))
+		v.cache.Del(cacheKey)
+		return nil
+	}
+	sysCode, err := v.cache.GetString(cacheKey)
+	if err != nil {
+		return err
+	}
+	failuresKey := v.key(CacheKeySMSCodeFailures, codeType, subject)
+	if sysCode != "" && sysCode == code {
+		v.cache.Del(cacheKey)
+		v.cache.Del(failuresKey)
+		return nil
+	}
+	if sysCode == "" {
+		return errors.New("验证码无效！")
+	}
+	otpParams := extconfig.Get().OTP.Params(codeType.Name())
+	failures, err := v.cache.Incr(failuresKey)
+	if err != nil {
+		return err
+	}
+	if failures == 1 {
+		_ = v.cache.Expire(failuresKey, otpParams.TTL)
+	}
+	if otpParams.MaxFailures > 0 && failures >= int64(otpParams.MaxFailures) {
+		// 错误次数过多 作废验证码并锁定
+		v.cache.Del(cacheKey)
+		v.cache.Del(failuresKey)
+		err = v.cache.SetAndExpire(v.key(CacheKeySMSCodeLock, codeType, subject), "1", otpParams.LockDuration)
+		if err != nil {
+			return err
+		}
+		v.Warn("验证码错误次数过多，已锁定！", zap.String("subject", subject), zap.Int("codeType", int(codeType)))
+		return errors.New("验证码错误次数过多，请稍后再试！")
+	}
+	return errors.New("验证码无效！")
+}
+
+// smsSubject 短信验证码的接收者
+func smsSubject(zone, phone string) string {
+	return fmt.Sprintf("%s@%s", zone, phone)
+}
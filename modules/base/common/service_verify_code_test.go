@@ -0,0 +1,229 @@
+package common
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// memCodeCache 内存实现的verifyCodeCache 使用可以调整的时间
+type memCodeCache struct {
+	mu      sync.Mutex
+	now     time.Time
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+func newMemCodeCache() *memCodeCache {
+	return &memCodeCache{now: time.Now(), values: map[string]string{}, expires: map[string]time.Time{}}
+}
+
+func (m *memCodeCache) advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
+
+// lookup 调用方需要持有锁
+func (m *memCodeCache) lookup(key string) (string, bool) {
+	if expire, ok := m.expires[key]; ok && !m.now.Before(expire) {
+		delete(m.values, key)
+		delete(m.expires, key)
+	}
+	value, ok := m.values[key]
+	return value, ok
+}
+
+func (m *memCodeCache) set(key string, value interface{}, expire time.Duration) {
+	m.values[key] = toString(value)
+	delete(m.expires, key)
+	if expire > 0 {
+		m.expires[key] = m.now.Add(expire)
+	}
+}
+
+func (m *memCodeCache) GetString(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, _ := m.lookup(key)
+	return value, nil
+}
+
+func (m *memCodeCache) SetAndExpire(key string, value interface{}, expire time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(key, value, expire)
+	return nil
+}
+
+func (m *memCodeCache) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+	delete(m.expires, key)
+	return nil
+}
+
+func (m *memCodeCache) Incr(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, _ := m.lookup(key)
+	count, _ := strconv.ParseInt(value, 10, 64)
+	count++
+	m.values[key] = strconv.FormatInt(count, 10)
+	return count, nil
+}
+
+func (m *memCodeCache) Expire(key string, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(key); ok {
+		m.expires[key] = m.now.Add(expiration)
+	}
+	return nil
+}
+
+func (m *memCodeCache) TTL(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(key); !ok {
+		return -2, nil
+	}
+	expire, ok := m.expires[key]
+	if !ok {
+		return -1, nil
+	}
+	return expire.Sub(m.now), nil
+}
+
+func (m *memCodeCache) SetNX(key string, value interface{}, expire time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(key); ok {
+		return false, nil
+	}
+	m.set(key, value, expire)
+	return true, nil
+}
+
+func (m *memCodeCache) DelIfValue(key string, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.lookup(key); ok && current == value {
+		delete(m.values, key)
+		delete(m.expires, key)
+	}
+	return nil
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	}
+	return ""
+}
+
+func newTestCodeStore(cache verifyCodeCache) *verifyCodeStore {
+	return &verifyCodeStore{Log: log.NewTLog("verifyCodeStore"), cache: cache}
+}
+
+func TestVerifyCodeIssueKeepsExpiry(t *testing.T) {
+	cache := newMemCodeCache()
+	store := newTestCodeStore(cache)
+	otpParams := extconfig.Get().OTP.Params(CodeTypeRegister.Name())
+	codeKey := store.key(CacheKeySMSCode, CodeTypeRegister, "0086@13600000001")
+
+	code, _, err := store.issue(CodeTypeRegister, "0086@13600000001")
+	assert.NoError(t, err)
+	assert.Len(t, code, otpParams.Length)
+	assert.ErrorIs(t, store.checkInterval(CodeTypeRegister, "0086@13600000001"), errcode.ErrVerifyCodeTooFrequent)
+
+	// 重新获取时继续使用未过期的验证码 不延长有效期
+	cache.advance(otpParams.Interval + time.Second)
+	assert.NoError(t, store.checkInterval(CodeTypeRegister, "0086@13600000001"))
+	again, _, err := store.issue(CodeTypeRegister, "0086@13600000001")
+	assert.NoError(t, err)
+	assert.Equal(t, code, again)
+	ttl, _ := cache.TTL(codeKey)
+	assert.Equal(t, otpParams.TTL-otpParams.Interval-time.Second, ttl)
+
+	// 剩余的有效期不足一个发送间隔时重新生成
+	cache.advance(ttl - otpParams.Interval)
+	_, _, err = store.issue(CodeTypeRegister, "0086@13600000001")
+	assert.NoError(t, err)
+	ttl, _ = cache.TTL(codeKey)
+	assert.Equal(t, otpParams.TTL, ttl)
+
+	// 过期后不能再验证
+	cache.advance(otpParams.TTL)
+	assert.Error(t, store.verify(CodeTypeRegister, "0086@13600000001", code))
+}
+
+func TestVerifyCodeLockSending(t *testing.T) {
+	cache := newMemCodeCache()
+	store := newTestCodeStore(cache)
+
+	release, err := store.lockSending(CodeTypeRegister, "0086@13600000001")
+	assert.NoError(t, err)
+	_, err = store.lockSending(CodeTypeRegister, "0086@13600000001")
+	assert.Error(t, err)
+	// 不同的接收者互不影响
+	otherRelease, err := store.lockSending(CodeTypeRegister, "0086@13600000002")
+	assert.NoError(t, err)
+	otherRelease()
+	release()
+	release, err = store.lockSending(CodeTypeRegister, "0086@13600000001")
+	assert.NoError(t, err)
+
+	// 锁过期后被其他请求获取 原来的请求释放时不会删除其他请求的锁
+	cache.advance(smsSendingLockExpire)
+	release2, err := store.lockSending(CodeTypeRegister, "0086@13600000001")
+	assert.NoError(t, err)
+	release()
+	_, err = store.lockSending(CodeTypeRegister, "0086@13600000001")
+	assert.Error(t, err)
+	release2()
+	_, err = store.lockSending(CodeTypeRegister, "0086@13600000001")
+	assert.NoError(t, err)
+}
+
+func TestVerifyCodeConcurrentSend(t *testing.T) {
+	store := newTestCodeStore(newMemCodeCache())
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[string]bool{}
+	locked := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := store.lockSending(CodeTypeRegister, "0086@13600000001")
+			if err != nil {
+				return
+			}
+			defer release()
+			if err = store.checkInterval(CodeTypeRegister, "0086@13600000001"); err != nil {
+				return
+			}
+			code, _, err := store.issue(CodeTypeRegister, "0086@13600000001")
+			assert.NoError(t, err)
+			mu.Lock()
+			locked++
+			codes[code] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	// 同一时间只有一个请求能发送 发送后在间隔内不能再次发送
+	assert.Equal(t, 1, locked)
+	assert.Len(t, codes, 1)
+}
@@ -0,0 +1,149 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/dyvmsapi"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+const (
+	// VoiceProviderAliyun 阿里云语音服务
+	VoiceProviderAliyun = "aliyun"
+	// VoiceProviderTwilio twilio语音
+	VoiceProviderTwilio = "twilio"
+)
+
+// IVoiceProvider 语音验证码提供者
+type IVoiceProvider interface {
+	SendVoiceCode(ctx context.Context, zone, phone string, code string) error
+}
+
+// NewVoiceProvider 根据配置创建语音验证码提供者 未配置返回nil
+func NewVoiceProvider(ctx *config.Context) IVoiceProvider {
+	switch extconfig.Get().VoiceOTP.Provider {
+	case VoiceProviderAliyun:
+		return NewAliyunVoiceProvider(ctx)
+	case VoiceProviderTwilio:
+		return NewTwilioVoiceProvider(ctx)
+	}
+	return nil
+}
+
+type AliyunVoiceProvider struct {
+	ctx *config.Context
+	log.Log
+}
+
+// NewAliyunVoiceProvider 创建阿里云语音验证码服务 账号使用aliyunSMS的配置
+func NewAliyunVoiceProvider(ctx *config.Context) IVoiceProvider {
+	return &AliyunVoiceProvider{
+		ctx: ctx,
+		Log: log.NewTLog("AliyunVoiceProvider"),
+	}
+}
+
+func (a *AliyunVoiceProvider) SendVoiceCode(ctx context.Context, zone, phone string, code string) error {
+	span, _ := a.ctx.Tracer().StartSpanFromContext(ctx, "smsService.SendVoiceVerifyCode")
+	defer span.Finish()
+
+	voiceCfg := extconfig.Get().VoiceOTP
+	client, err := dyvmsapi.NewClientWithAccessKey("cn-hangzhou", a.ctx.GetConfig().AliyunSMS.AccessKeyID, a.ctx.GetConfig().AliyunSMS.AccessSecret)
+	if err != nil {
+		return err
+	}
+	request := dyvmsapi.CreateSingleCallByTtsRequest()
+	request.Scheme = "https"
+	request.CalledNumber = phone
+	request.CalledShowNumber = voiceCfg.CalledShowNumber
+	request.TtsCode = voiceCfg.TtsCode
+	request.TtsParam = util.ToJson(map[string]interface{}{
+		"code": code,
+	})
+	request.PlayTimes = "2"
+	response, err := client.SingleCallByTts(request)
+	if err != nil {
+		ext.LogError(span, err)
+		a.Error("发送语音验证码失败！", zap.Error(err))
+		return err
+	}
+	if response.Code != "OK" {
+		a.Error("发送语音验证码失败！", zap.String("code", response.Code), zap.String("message", response.Message))
+		return errors.New(response.Message)
+	}
+	return nil
+}
+
+type TwilioVoiceProvider struct {
+	ctx *config.Context
+	log.Log
+	client *http.Client
+}
+
+// NewTwilioVoiceProvider 创建twilio语音验证码服务 账号使用twilioSMS的配置
+func NewTwilioVoiceProvider(ctx *config.Context) IVoiceProvider {
+	return &TwilioVoiceProvider{
+		ctx:    ctx,
+		Log:    log.NewTLog("TwilioVoiceProvider"),
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (t *TwilioVoiceProvider) SendVoiceCode(ctx context.Context, zone, phone string, code string) error {
+	span, _ := t.ctx.Tracer().StartSpanFromContext(ctx, "smsService.SendVoiceVerifyCode")
+	defer span.Finish()
+
+	twilioCfg := extconfig.Get().TwilioSMS
+	voiceCfg := extconfig.Get().VoiceOTP
+	if twilioCfg.AccountSID == "" || twilioCfg.AuthToken == "" || voiceCfg.From == "" {
+		return errors.New("没有配置twilio语音！")
+	}
+	// 验证码逐位播报
+	spokenCode := strings.Join(strings.Split(code, ""), " ")
+	text := strings.NewReplacer("{appName}", t.ctx.GetConfig().AppName, "{code}", spokenCode).Replace(voiceCfg.Template)
+	var escaped strings.Builder
+	if err := xml.EscapeText(&escaped, []byte(text)); err != nil {
+		return err
+	}
+	form := url.Values{}
+	form.Set("To", toE164(zone, phone))
+	form.Set("From", voiceCfg.From)
+	form.Set("Twiml", fmt.Sprintf(`<Response><Say language="%s">%s</Say></Response>`, voiceCfg.Language, escaped.String()))
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/Accounts/%s/Calls.json", twilioAPIURL, twilioCfg.AccountSID), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(twilioCfg.AccountSID, twilioCfg.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		ext.LogError(span, err)
+		t.Error("发送语音验证码失败！", zap.Error(err))
+		return err
+	}
+	defer resp.Body.Close()
+	var result twilioMessageResp
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Error("解析twilio返回数据失败！", zap.Error(err), zap.Int("status", resp.StatusCode))
+		return errors.New("发送语音验证码失败！")
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		t.Error("发送语音验证码失败！", zap.Int("status", resp.StatusCode), zap.Int("code", result.Code), zap.String("message", result.Message))
+		return errors.New(result.Message)
+	}
+	return nil
+}
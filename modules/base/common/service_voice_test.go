@@ -0,0 +1,113 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVoiceProvider(t *testing.T) {
+	voiceCfg := &extconfig.Get().VoiceOTP
+	provider := voiceCfg.Provider
+	defer func() { voiceCfg.Provider = provider }()
+
+	ctx := testutil.NewTestContext(config.New())
+	voiceCfg.Provider = ""
+	assert.Nil(t, NewVoiceProvider(ctx))
+	voiceCfg.Provider = VoiceProviderAliyun
+	assert.IsType(t, &AliyunVoiceProvider{}, NewVoiceProvider(ctx))
+	voiceCfg.Provider = VoiceProviderTwilio
+	assert.IsType(t, &TwilioVoiceProvider{}, NewVoiceProvider(ctx))
+}
+
+func TestTwilioVoiceProviderSend(t *testing.T) {
+	cfg := config.New()
+	cfg.AppName = "T&D"
+	twilioCfg := &extconfig.Get().TwilioSMS
+	oldTwilio := *twilioCfg
+	voiceCfg := &extconfig.Get().VoiceOTP
+	oldVoice := *voiceCfg
+	defer func() {
+		*twilioCfg = oldTwilio
+		*voiceCfg = oldVoice
+	}()
+
+	provider := NewTwilioVoiceProvider(testutil.NewTestContext(cfg)).(*TwilioVoiceProvider)
+	*twilioCfg = extconfig.TwilioSMSConfig{AccountSID: "AC1", AuthToken: "token"}
+	voiceCfg.From = ""
+	assert.EqualError(t, provider.SendVoiceCode(context.Background(), "0086", "13800138000", "1234"), "没有配置twilio语音！")
+
+	voiceCfg.From = "+15005550006"
+	provider.client = newRedirectClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/2010-04-01/Accounts/AC1/Calls.json", r.URL.Path)
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "AC1", user)
+		assert.Equal(t, "token", pass)
+		assert.Equal(t, "+15005550006", r.FormValue("From"))
+		if r.FormValue("To") != "+8613800138000" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 21211, "message": "Invalid 'To' Phone Number"})
+			return
+		}
+		// 验证码逐位播报 内容需要转义
+		assert.Equal(t, `<Response><Say language="en-US">Your T&amp;D verification code is 1 2 3 4. Again, your code is 1 2 3 4.</Say></Response>`, r.FormValue("Twiml"))
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"sid": "CA1", "status": "queued"})
+	})
+	assert.NoError(t, provider.SendVoiceCode(context.Background(), "0086", "13800138000", "1234"))
+	assert.EqualError(t, provider.SendVoiceCode(context.Background(), "0086", "1", "1234"), "Invalid 'To' Phone Number")
+}
+
+// fakeVoiceProvider 记录播报的验证码 err不为空时播报失败
+type fakeVoiceProvider struct {
+	codes []string
+	err   error
+}
+
+func (f *fakeVoiceProvider) SendVoiceCode(ctx context.Context, zone, phone string, code string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.codes = append(f.codes, code)
+	return nil
+}
+
+func TestSMSSendVoiceCodeLimit(t *testing.T) {
+	voiceCfg := &extconfig.Get().VoiceOTP
+	old := *voiceCfg
+	defer func() { *voiceCfg = old }()
+	voiceCfg.DailyLimit = 2
+
+	cache := newMemCodeCache()
+	s := &SMSService{
+		ctx:       testutil.NewTestContext(config.New()),
+		Log:       log.NewTLog("SMSService"),
+		codeStore: newTestCodeStore(cache),
+	}
+	provider := &fakeVoiceProvider{}
+	// 需要先获取短信验证码
+	assert.EqualError(t, s.sendVoiceCode(context.Background(), provider, "0086", "13800138000", CodeTypeRegister), "请先获取短信验证码！")
+
+	code, _, err := s.codeStore.issue(CodeTypeRegister, smsSubject("0086", "13800138000"))
+	assert.NoError(t, err)
+	assert.NoError(t, s.sendVoiceCode(context.Background(), provider, "0086", "13800138000", CodeTypeRegister))
+	assert.Equal(t, []string{code}, provider.codes)
+	assert.Equal(t, errcode.ErrVoiceCodeTooFrequent, s.sendVoiceCode(context.Background(), provider, "0086", "13800138000", CodeTypeRegister))
+
+	// 播报失败时不限制间隔 但计入每天的次数
+	cache.advance(voiceCfg.Interval)
+	provider.err = errors.New("call failed")
+	assert.EqualError(t, s.sendVoiceCode(context.Background(), provider, "0086", "13800138000", CodeTypeRegister), "call failed")
+	provider.err = nil
+	assert.EqualError(t, s.sendVoiceCode(context.Background(), provider, "0086", "13800138000", CodeTypeRegister), "今日语音验证码次数已达上限！")
+	assert.Len(t, provider.codes, 1)
+}
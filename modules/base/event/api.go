@@ -40,6 +40,8 @@ const (
 	ConversationDelete string = "conversation.delete"
 	// EventUserRegister 用户注册
 	EventUserRegister string = "user.register"
+	// EventUserBanAppeal 被封禁的用户提交申诉
+	EventUserBanAppeal string = "user.ban.appeal"
 	// EventUserPublishMoment 用户发布动态
 	EventUserPublishMoment string = "moment.publish"
 	// EventUserDeleteMoment 用户删除动态
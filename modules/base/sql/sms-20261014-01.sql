@@ -0,0 +1,14 @@
+-- +migrate Up
+
+create table `sms_template`
+(
+    id         integer       not null primary key AUTO_INCREMENT,
+    provider   VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '短信服务商 aliyun aliyunInternational unisms twilio tencent aws',
+    code_type  smallint      NOT NULL DEFAULT 0  COMMENT '验证码类型 0.注册 1.支付密码 2.忘记登录密码 3.校验手机号 4.注销账号',
+    locale     VARCHAR(20)   NOT NULL DEFAULT '' COMMENT '语言 例如 zh-CN en 为空表示默认',
+    template   VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '模版（模版code/ID或短信内容，与服务商有关） {appName}为应用名 {code}为验证码',
+    status     smallint      NOT NULL DEFAULT 1  COMMENT '状态 0.禁用 1.启用',
+    created_at timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX sms_template_uidx on `sms_template` (provider, code_type, locale);
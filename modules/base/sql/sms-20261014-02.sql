@@ -0,0 +1,23 @@
+-- +migrate Up
+
+create table `sms_send_log`
+(
+    id         bigint        not null primary key AUTO_INCREMENT,
+    provider   VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '短信服务商或通道 aliyun unisms twilio whatsapp telegram 等',
+    zone       VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '区号',
+    phone      VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '手机号',
+    code_type  smallint      NOT NULL DEFAULT 0  COMMENT '验证码类型',
+    message_id VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '服务商的消息ID',
+    status     smallint      NOT NULL DEFAULT 0  COMMENT '状态 1.已提交 2.已送达 3.失败',
+    err_code   VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '错误码',
+    err_msg    VARCHAR(500)  NOT NULL DEFAULT '' COMMENT '错误信息',
+    cost       DECIMAL(12,6) NOT NULL DEFAULT 0  COMMENT '费用',
+    currency   VARCHAR(10)   NOT NULL DEFAULT '' COMMENT '费用币种',
+    segments   integer       NOT NULL DEFAULT 0  COMMENT '计费条数',
+    latency    integer       NOT NULL DEFAULT 0  COMMENT '发送接口耗时（毫秒）',
+    created_at timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX sms_send_log_message_idx on `sms_send_log` (provider, message_id);
+CREATE INDEX sms_send_log_phone_idx on `sms_send_log` (phone);
+CREATE INDEX sms_send_log_created_at_idx on `sms_send_log` (created_at);
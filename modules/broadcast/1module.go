@@ -0,0 +1,41 @@
+package broadcast
+
+import (
+	"embed"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+)
+
+//go:embed sql
+var sqlFS embed.FS
+
+//go:embed swagger/api.yaml
+var swaggerContent string
+
+func init() {
+
+	// 系统公告
+	register.AddModule(func(ctx interface{}) register.Module {
+
+		return register.Module{
+			Name: "broadcast",
+			SetupAPI: func() register.APIRouter {
+				return New(ctx.(*config.Context))
+			},
+			SQLDir:  register.NewSQLFS(sqlFS),
+			Swagger: swaggerContent,
+		}
+	})
+
+	// 系统公告管理
+	register.AddModule(func(ctx interface{}) register.Module {
+
+		return register.Module{
+			Name: "broadcast_manager",
+			SetupAPI: func() register.APIRouter {
+				return NewManager(ctx.(*config.Context))
+			},
+		}
+	})
+}
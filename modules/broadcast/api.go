@@ -0,0 +1,79 @@
+package broadcast
+
+import (
+	"errors"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Broadcast 系统公告
+type Broadcast struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// New New
+func New(ctx *config.Context) *Broadcast {
+	return &Broadcast{
+		ctx: ctx,
+		Log: log.NewTLog("Broadcast"),
+		db:  newDB(ctx),
+	}
+}
+
+// Route 路由配置
+func (b *Broadcast) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/broadcasts", b.ctx.AuthMiddleware(r))
+	{
+		auth.POST("/:broadcast_no/read", b.read) // 公告已读
+	}
+}
+
+// 公告已读 客户端展示公告消息后上报 用于统计已读数 重复上报只统计一次
+func (b *Broadcast) read(c *wkhttp.Context) {
+	broadcastNo := c.Param("broadcast_no")
+	loginUID := c.GetLoginUID()
+	broadcast, err := b.db.queryWithBroadcastNo(broadcastNo)
+	if err != nil {
+		b.Error("查询公告失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询公告失败！"))
+		return
+	}
+	if broadcast == nil {
+		c.ResponseError(errors.New("公告不存在"))
+		return
+	}
+	tx, _ := b.db.session.Begin()
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	inserted, err := b.db.insertReadTx(broadcastNo, loginUID, tx)
+	if err != nil {
+		tx.Rollback()
+		b.Error("保存公告已读失败！", zap.Error(err), zap.String("uid", loginUID))
+		c.ResponseError(errors.New("保存公告已读失败！"))
+		return
+	}
+	if inserted {
+		if err = b.db.incrReadCountTx(broadcastNo, tx); err != nil {
+			tx.Rollback()
+			b.Error("更新公告已读数失败！", zap.Error(err))
+			c.ResponseError(errors.New("保存公告已读失败！"))
+			return
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		b.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("保存公告已读失败！"))
+		return
+	}
+	c.ResponseOK()
+}
@@ -0,0 +1,279 @@
+package broadcast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Manager 系统公告管理
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db          *db
+	userService user.IService
+	dispatching atomic.Bool
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	m := &Manager{
+		ctx:         ctx,
+		Log:         log.NewTLog("BroadcastManager"),
+		db:          newDB(ctx),
+		userService: user.NewService(ctx),
+	}
+	m.ctx.Schedule(extconfig.Get().Broadcast.Interval, m.dispatchJob)
+	return m
+}
+
+// Route 路由配置
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.POST("/broadcasts", m.create)                     // 创建公告
+		auth.GET("/broadcasts", m.list)                        // 公告列表
+		auth.POST("/broadcasts/preview", m.preview)            // 预览接收的用户数
+		auth.GET("/broadcasts/:broadcast_no", m.detail)        // 公告详情和发送统计
+		auth.PUT("/broadcasts/:broadcast_no/cancel", m.cancel) // 取消发送
+	}
+}
+
+type createReq struct {
+	Title   string  `json:"title"`   // 标题 只在后台展示
+	Content string  `json:"content"` // 公告内容
+	SendAt  int64   `json:"send_at"` // 发送时间（时间戳秒） 为0或已过时立即发送
+	Segment segment `json:"segment"` // 接收的用户范围 为空时发送给所有用户
+}
+
+func (r createReq) check() error {
+	if strings.TrimSpace(r.Content) == "" {
+		return errors.New("公告内容不能为空")
+	}
+	if len([]rune(r.Content)) > contentMaxLen {
+		return fmt.Errorf("公告内容不能超过%d个字", contentMaxLen)
+	}
+	if len([]rune(r.Title)) > titleMaxLen {
+		return fmt.Errorf("标题不能超过%d个字", titleMaxLen)
+	}
+	if r.SendAt < 0 {
+		return errors.New("发送时间有误")
+	}
+	return r.Segment.check()
+}
+
+// 创建公告 到发送时间后分批发送
+func (m *Manager) create(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermBroadcastSend); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req createReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	sendAt := req.SendAt
+	if now := time.Now().Unix(); sendAt < now {
+		sendAt = now
+	}
+	broadcast := &model{
+		BroadcastNo: util.GenerUUID(),
+		Title:       strings.TrimSpace(req.Title),
+		Content:     req.Content,
+		Segment:     req.Segment.encode(),
+		SendAt:      sendAt,
+		Status:      StatusPending,
+		Creator:     c.GetLoginUID(),
+	}
+	if err := m.db.insert(broadcast); err != nil {
+		m.Error("添加公告失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加公告失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("broadcast_no=%s", broadcast.BroadcastNo), nil, req)
+	c.Response(map[string]interface{}{
+		"broadcast_no": broadcast.BroadcastNo,
+		"send_at":      broadcast.SendAt,
+	})
+}
+
+// 公告列表
+func (m *Manager) list(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermMessageRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	status := c.Query("status")
+	models, err := m.db.queryWithPage(status, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询公告列表失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询公告列表失败！"))
+		return
+	}
+	count, err := m.db.queryCount(status)
+	if err != nil {
+		m.Error("查询公告数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询公告数量失败！"))
+		return
+	}
+	list := make([]*broadcastResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newBroadcastResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 预览接收公告的用户数 实际的用户数以开始发送时为准
+func (m *Manager) preview(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermMessageRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req segment
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	count, err := m.userService.GetCountWithFilter(req.userFilter(m.excludeUIDs()))
+	if err != nil {
+		m.Error("查询接收公告的用户数失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询接收公告的用户数失败！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"recipient_count": count,
+	})
+}
+
+// 公告详情和发送统计
+func (m *Manager) detail(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermMessageRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	broadcast, err := m.db.queryWithBroadcastNo(c.Param("broadcast_no"))
+	if err != nil {
+		m.Error("查询公告失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询公告失败！"))
+		return
+	}
+	if broadcast == nil {
+		c.ResponseError(errors.New("公告不存在"))
+		return
+	}
+	c.Response(newBroadcastResp(broadcast))
+}
+
+// 取消发送 已发送的消息不会撤回
+func (m *Manager) cancel(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermBroadcastSend); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	broadcastNo := c.Param("broadcast_no")
+	broadcast, err := m.db.queryWithBroadcastNo(broadcastNo)
+	if err != nil {
+		m.Error("查询公告失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询公告失败！"))
+		return
+	}
+	if broadcast == nil {
+		c.ResponseError(errors.New("公告不存在"))
+		return
+	}
+	ok, err := m.db.updateCanceled(broadcast.Id, time.Now().Unix())
+	if err != nil {
+		m.Error("取消公告失败！", zap.Error(err))
+		c.ResponseError(errors.New("取消公告失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("公告已发送完成或已取消"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("broadcast_no=%s", broadcastNo), map[string]interface{}{"status": broadcast.Status}, map[string]interface{}{"status": StatusCanceled})
+	c.ResponseOK()
+}
+
+type broadcastResp struct {
+	BroadcastNo    string  `json:"broadcast_no"`
+	Title          string  `json:"title"`
+	Content        string  `json:"content"`
+	Segment        segment `json:"segment"`
+	SendAt         int64   `json:"send_at"`
+	Status         int     `json:"status"` // 0.待发送 1.发送中 2.已发送 3.已取消
+	Creator        string  `json:"creator"`
+	RecipientCount int64   `json:"recipient_count"` // 开始发送时符合范围的用户数
+	SentCount      int64   `json:"sent_count"`      // 已发送的用户数
+	FailedCount    int64   `json:"failed_count"`    // 发送失败的用户数
+	ReadCount      int64   `json:"read_count"`      // 已读的用户数
+	Progress       float64 `json:"progress"`        // 发送进度（百分比）
+	ReadRate       float64 `json:"read_rate"`       // 已读率（百分比） 已读数/已发送数
+	StartedAt      int64   `json:"started_at"`
+	FinishedAt     int64   `json:"finished_at"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+func newBroadcastResp(m *model) *broadcastResp {
+	seg, _ := decodeSegment(m.Segment)
+	resp := &broadcastResp{
+		BroadcastNo:    m.BroadcastNo,
+		Title:          m.Title,
+		Content:        m.Content,
+		Segment:        seg,
+		SendAt:         m.SendAt,
+		Status:         m.Status,
+		Creator:        m.Creator,
+		RecipientCount: m.RecipientCount,
+		SentCount:      m.SentCount,
+		FailedCount:    m.FailedCount,
+		ReadCount:      m.ReadCount,
+		StartedAt:      m.StartedAt,
+		FinishedAt:     m.FinishedAt,
+		CreatedAt:      m.CreatedAt.String(),
+	}
+	if m.Status == StatusFinished {
+		resp.Progress = 100
+	} else {
+		resp.Progress = percent(m.SentCount+m.FailedCount, m.RecipientCount)
+	}
+	resp.ReadRate = percent(m.ReadCount, m.SentCount)
+	return resp
+}
+
+// percent 保留两位小数的百分比 最大为100
+func percent(count int64, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	if count >= total {
+		return 100
+	}
+	return float64(count*10000/total) / 100
+}
@@ -0,0 +1,28 @@
+package broadcast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercent(t *testing.T) {
+	assert.Equal(t, float64(0), percent(10, 0))
+	assert.Equal(t, 33.33, percent(1, 3))
+	assert.Equal(t, float64(100), percent(5, 3))
+}
+
+func TestNewBroadcastResp(t *testing.T) {
+	resp := newBroadcastResp(&model{
+		Status:         StatusSending,
+		RecipientCount: 200,
+		SentCount:      90,
+		FailedCount:    10,
+		ReadCount:      45,
+	})
+	assert.Equal(t, float64(50), resp.Progress)
+	assert.Equal(t, float64(50), resp.ReadRate)
+
+	resp = newBroadcastResp(&model{Status: StatusFinished, RecipientCount: 200, SentCount: 150})
+	assert.Equal(t, float64(100), resp.Progress)
+}
@@ -0,0 +1,20 @@
+package broadcast
+
+// 公告的状态
+const (
+	StatusPending  = 0 // 待发送 到发送时间后开始发送
+	StatusSending  = 1 // 发送中
+	StatusFinished = 2 // 已发送
+	StatusCanceled = 3 // 已取消 已发送的消息不会撤回
+)
+
+const (
+	// titleMaxLen 标题的最大字数
+	titleMaxLen = 100
+	// contentMaxLen 公告内容的最大字数
+	contentMaxLen = 2000
+	// activeDaysMax 最近在线天数的最大值
+	activeDaysMax = 365
+	// segmentDateLayout 注册日期的格式
+	segmentDateLayout = "2006-01-02"
+)
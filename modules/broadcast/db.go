@@ -0,0 +1,135 @@
+package broadcast
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *db) insert(m *model) error {
+	_, err := d.session.InsertInto("broadcast").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryWithBroadcastNo(broadcastNo string) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("broadcast").Where("broadcast_no=?", broadcastNo).Load(&m)
+	return m, err
+}
+
+func (d *db) queryWithPage(status string, pageIndex, pageSize uint64) ([]*model, error) {
+	var models []*model
+	_, err := d.statusWhere(d.session.Select("*").From("broadcast"), status).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryCount(status string) (int64, error) {
+	var count int64
+	_, err := d.statusWhere(d.session.Select("count(*)").From("broadcast"), status).Load(&count)
+	return count, err
+}
+
+func (d *db) statusWhere(builder *dbr.SelectStmt, status string) *dbr.SelectStmt {
+	if status != "" {
+		builder = builder.Where("status=?", status)
+	}
+	return builder
+}
+
+// queryNextDispatch 下一个需要发送的公告 发送中的优先 然后按发送时间
+func (d *db) queryNextDispatch(now int64) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("broadcast").Where("(status=? or (status=? and send_at<=?))", StatusSending, StatusPending, now).OrderDir("status", false).OrderDir("send_at", true).OrderDir("id", true).Limit(1).Load(&m)
+	return m, err
+}
+
+// updateStart 开始发送 其他地方已修改了状态（取消或其他实例已开始发送）时返回false
+func (d *db) updateStart(id int64, recipientCount int64, startedAt int64) (bool, error) {
+	result, err := d.session.Update("broadcast").SetMap(map[string]interface{}{
+		"status":          StatusSending,
+		"recipient_count": recipientCount,
+		"started_at":      startedAt,
+	}).Where("id=? and status=?", id, StatusPending).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// updateCursor 领取一批用户 cursor已被修改（取消或其他实例已领取）时返回false
+func (d *db) updateCursor(id int64, cursorID int64, nextCursorID int64) (bool, error) {
+	result, err := d.session.Update("broadcast").Set("cursor_id", nextCursorID).Where("id=? and status=? and cursor_id=?", id, StatusSending, cursorID).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// incrSendResult 累加一批的发送结果
+func (d *db) incrSendResult(id int64, sentCount int64, failedCount int64) error {
+	_, err := d.session.UpdateBySql("update broadcast set sent_count=sent_count+?,failed_count=failed_count+? where id=?", sentCount, failedCount, id).Exec()
+	return err
+}
+
+func (d *db) updateFinished(id int64, finishedAt int64) error {
+	_, err := d.session.Update("broadcast").Set("status", StatusFinished).Set("finished_at", finishedAt).Where("id=? and status=?", id, StatusSending).Exec()
+	return err
+}
+
+// updateCanceled 取消待发送或发送中的公告
+func (d *db) updateCanceled(id int64, finishedAt int64) (bool, error) {
+	result, err := d.session.Update("broadcast").Set("status", StatusCanceled).Set("finished_at", finishedAt).Where("id=? and status in ?", id, []int{StatusPending, StatusSending}).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// insertReadTx 记录已读 已经读过时返回false
+func (d *db) insertReadTx(broadcastNo string, uid string, tx *dbr.Tx) (bool, error) {
+	result, err := tx.InsertBySql("insert ignore into broadcast_read(broadcast_no,uid) values(?,?)", broadcastNo, uid).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (d *db) incrReadCountTx(broadcastNo string, tx *dbr.Tx) error {
+	_, err := tx.UpdateBySql("update broadcast set read_count=read_count+1 where broadcast_no=?", broadcastNo).Exec()
+	return err
+}
+
+type model struct {
+	BroadcastNo    string
+	Title          string
+	Content        string
+	Segment        string
+	SendAt         int64
+	Status         int
+	Creator        string
+	RecipientCount int64
+	SentCount      int64
+	FailedCount    int64
+	ReadCount      int64
+	CursorID       int64
+	StartedAt      int64
+	FinishedAt     int64
+	dba.BaseModel
+}
@@ -0,0 +1,100 @@
+package broadcast
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// dispatchJob 每次只发送一批 下次执行时继续发送 发送进度保存在数据库中 重启后从上次的进度继续
+// 公告表就是发送队列：到发送时间的待发送公告和发送中的公告按顺序逐个发送
+func (m *Manager) dispatchJob() {
+	if !m.dispatching.CompareAndSwap(false, true) {
+		return
+	}
+	defer m.dispatching.Store(false)
+
+	broadcast, err := m.db.queryNextDispatch(time.Now().Unix())
+	if err != nil {
+		m.Error("查询需要发送的公告失败！", zap.Error(err))
+		return
+	}
+	if broadcast == nil {
+		return
+	}
+	if err = m.dispatchBatch(broadcast, extconfig.Get().Broadcast.BatchSize); err != nil {
+		m.Error("发送公告失败！", zap.Error(err), zap.String("broadcastNo", broadcast.BroadcastNo))
+	}
+}
+
+// dispatchBatch 给公告的下一批用户发送消息 没有更多用户时发送完成
+func (m *Manager) dispatchBatch(broadcast *model, batchSize int) error {
+	seg, err := decodeSegment(broadcast.Segment)
+	if err != nil {
+		return err
+	}
+	filter := seg.userFilter(m.excludeUIDs())
+	if broadcast.Status == StatusPending {
+		recipientCount, err := m.userService.GetCountWithFilter(filter)
+		if err != nil {
+			return err
+		}
+		ok, err := m.db.updateStart(broadcast.Id, recipientCount, time.Now().Unix())
+		if err != nil || !ok {
+			return err
+		}
+		m.Info("开始发送公告", zap.String("broadcastNo", broadcast.BroadcastNo), zap.Int64("recipientCount", recipientCount))
+	}
+	uids, nextCursorID, err := m.userService.GetUIDsWithFilter(filter, broadcast.CursorID, batchSize)
+	if err != nil {
+		return err
+	}
+	if len(uids) == 0 {
+		m.Info("公告发送完成", zap.String("broadcastNo", broadcast.BroadcastNo))
+		return m.db.updateFinished(broadcast.Id, time.Now().Unix())
+	}
+	// 先领取这一批用户 已取消的公告不再发送
+	ok, err := m.db.updateCursor(broadcast.Id, broadcast.CursorID, nextCursorID)
+	if err != nil || !ok {
+		return err
+	}
+	var sentCount, failedCount int64
+	err = m.ctx.SendMessageBatch(&config.MsgSendBatch{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		FromUID:     m.ctx.GetConfig().Account.SystemUID,
+		Payload:     broadcastPayload(broadcast),
+		Subscribers: uids,
+	})
+	if err != nil {
+		m.Warn("发送一批公告失败！", zap.Error(err), zap.String("broadcastNo", broadcast.BroadcastNo), zap.Int("count", len(uids)))
+		failedCount = int64(len(uids))
+	} else {
+		sentCount = int64(len(uids))
+	}
+	return m.db.incrSendResult(broadcast.Id, sentCount, failedCount)
+}
+
+// excludeUIDs 不接收公告的用户
+func (m *Manager) excludeUIDs() []string {
+	account := m.ctx.GetConfig().Account
+	return []string{account.SystemUID, account.FileHelperUID}
+}
+
+// broadcastPayload 公告的消息内容 客户端根据broadcast_no上报已读
+func broadcastPayload(broadcast *model) []byte {
+	payload := map[string]interface{}{
+		"type":         common.Text,
+		"content":      broadcast.Content,
+		"broadcast_no": broadcast.BroadcastNo,
+	}
+	if broadcast.Title != "" {
+		payload["title"] = broadcast.Title
+	}
+	return []byte(util.ToJson(payload))
+}
@@ -0,0 +1,71 @@
+package broadcast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+)
+
+// segment 接收公告的用户范围 条件都为空时发送给所有用户
+type segment struct {
+	RegisterStart string `json:"register_start,omitempty"` // 注册日期的开始 例如 2026-10-01
+	RegisterEnd   string `json:"register_end,omitempty"`   // 注册日期的结束（包含）
+	ActiveDays    int    `json:"active_days,omitempty"`    // 最近几天内在线过
+}
+
+func (s segment) check() error {
+	var start, end time.Time
+	var err error
+	if s.RegisterStart != "" {
+		if start, err = time.Parse(segmentDateLayout, s.RegisterStart); err != nil {
+			return errors.New("register_start格式有误")
+		}
+	}
+	if s.RegisterEnd != "" {
+		if end, err = time.Parse(segmentDateLayout, s.RegisterEnd); err != nil {
+			return errors.New("register_end格式有误")
+		}
+	}
+	if s.RegisterStart != "" && s.RegisterEnd != "" && start.After(end) {
+		return errors.New("register_start不能大于register_end")
+	}
+	if s.ActiveDays < 0 || s.ActiveDays > activeDaysMax {
+		return fmt.Errorf("active_days需要在0到%d之间", activeDaysMax)
+	}
+	return nil
+}
+
+func (s segment) isAll() bool {
+	return s.RegisterStart == "" && s.RegisterEnd == "" && s.ActiveDays == 0
+}
+
+// encode 保存到数据库的值 所有用户时为空
+func (s segment) encode() string {
+	if s.isAll() {
+		return ""
+	}
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func decodeSegment(value string) (segment, error) {
+	var s segment
+	if value == "" {
+		return s, nil
+	}
+	err := json.Unmarshal([]byte(value), &s)
+	return s, err
+}
+
+// userFilter 查询用户的条件 系统账号和文件助手不接收公告
+func (s segment) userFilter(excludeUIDs []string) user.UserFilter {
+	return user.UserFilter{
+		RegisterStart: s.RegisterStart,
+		RegisterEnd:   s.RegisterEnd,
+		ActiveDays:    s.ActiveDays,
+		ExcludeUIDs:   excludeUIDs,
+	}
+}
@@ -0,0 +1,30 @@
+package broadcast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentCheck(t *testing.T) {
+	assert.NoError(t, segment{}.check())
+	assert.NoError(t, segment{RegisterStart: "2026-10-01", RegisterEnd: "2026-10-01", ActiveDays: 7}.check())
+	assert.EqualError(t, segment{RegisterStart: "2026/10/01"}.check(), "register_start格式有误")
+	assert.EqualError(t, segment{RegisterStart: "2026-10-02", RegisterEnd: "2026-10-01"}.check(), "register_start不能大于register_end")
+	assert.EqualError(t, segment{ActiveDays: 366}.check(), "active_days需要在0到365之间")
+}
+
+func TestSegmentEncode(t *testing.T) {
+	assert.Equal(t, "", segment{}.encode())
+
+	seg := segment{RegisterStart: "2026-10-01", ActiveDays: 7}
+	value := seg.encode()
+	assert.Equal(t, `{"register_start":"2026-10-01","active_days":7}`, value)
+	decoded, err := decodeSegment(value)
+	assert.NoError(t, err)
+	assert.Equal(t, seg, decoded)
+
+	decoded, err = decodeSegment("")
+	assert.NoError(t, err)
+	assert.True(t, decoded.isAll())
+}
@@ -0,0 +1,36 @@
+-- +migrate Up
+
+-- 系统公告 通过系统账号分批发送给全部或部分用户
+create table `broadcast`
+(
+  id              bigint         not null primary key AUTO_INCREMENT,
+  broadcast_no    VARCHAR(40)    not null default '',  -- 公告编号
+  title           VARCHAR(100)   not null default '',  -- 标题 只在后台展示
+  content         TEXT,                                -- 公告内容
+  segment         VARCHAR(1000)  not null default '',  -- 接收的用户范围（json） 为空时发送给所有用户
+  send_at         bigint         not null default 0,   -- 发送时间
+  status          smallint       not null default 0,   -- 状态 0.待发送 1.发送中 2.已发送 3.已取消
+  creator         VARCHAR(40)    not null default '',  -- 创建人
+  recipient_count bigint         not null default 0,   -- 开始发送时符合范围的用户数
+  sent_count      bigint         not null default 0,   -- 已发送的用户数
+  failed_count    bigint         not null default 0,   -- 发送失败的用户数
+  read_count      bigint         not null default 0,   -- 已读的用户数
+  cursor_id       bigint         not null default 0,   -- 已发送到的用户id 每批发送后更新 重启后从这里继续发送
+  started_at      bigint         not null default 0,   -- 开始发送的时间
+  finished_at     bigint         not null default 0,   -- 发送完成的时间
+  created_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `broadcast_no_idx` on `broadcast` (`broadcast_no`);
+CREATE INDEX `broadcast_status_send_at_idx` on `broadcast` (`status`, `send_at`);
+
+-- 公告的已读记录
+create table `broadcast_read`
+(
+  id              bigint         not null primary key AUTO_INCREMENT,
+  broadcast_no    VARCHAR(40)    not null default '',  -- 公告编号
+  uid             VARCHAR(40)    not null default '',  -- 已读的用户
+  created_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `broadcast_read_uid_idx` on `broadcast_read` (`broadcast_no`, `uid`);
@@ -8,6 +8,7 @@ import (
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/model"
@@ -294,7 +295,7 @@ func (ch *Channel) setAutoDeleteForMessage(c *wkhttp.Context) {
 		})
 		if err != nil {
 			ch.Error("发送消息失败！", zap.Error(err))
-			c.ResponseError(errors.New("发送消息失败！"))
+			c.ResponseError(errcode.ErrSendMessage)
 			return
 		}
 	}
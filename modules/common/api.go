@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -11,32 +12,48 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/health"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/httpcache"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
+const (
+	appConfigCacheKey = "appconfig" // app配置的HTTP缓存
+	appModuleCacheKey = "appmodule" // app模块列表的HTTP缓存
+)
+
 // Common Common
 type Common struct {
 	ctx *config.Context
 	log.Log
-	db          *db
-	appConfigDB *appConfigDB
+	db            *db
+	appConfigDB   *appConfigDB
+	healthChecker *health.Checker
 }
 
 // New New
 func New(ctx *config.Context) *Common {
-	return &Common{
+	cn := &Common{
 		ctx:         ctx,
 		db:          newDB(ctx.DB()),
 		appConfigDB: newAppConfigDB(ctx),
 		Log:         log.NewTLog("common"),
 	}
+	cn.healthChecker = cn.newHealthChecker(file.NewService(ctx))
+	return cn
 }
 
 // Route 路由配置
@@ -57,6 +74,7 @@ func (cn *Common) Route(r *wkhttp.WKHttp) {
 		commonNoAuth.GET("/keepalive", cn.getKeepAliveVideo)   // 获取后台运行引导视频
 		commonNoAuth.GET("/updater/:os/:version", cn.updater)  // 版本更新检查（兼容tauri）
 		commonNoAuth.GET("/pcupdater/:os", cn.getPCNewVersion) // pc版本更新检查
+		commonNoAuth.GET("/tenant", cn.tenantInfo)             // 请求的组织信息
 	}
 
 	r.GET("/v1/health", func(c *wkhttp.Context) {
@@ -90,6 +108,24 @@ func (cn *Common) Route(r *wkhttp.WKHttp) {
 
 		c.JSON(http.StatusOK, statusMap)
 	})
+	r.GET("/healthz", cn.healthz) // 存活探针
+	r.GET("/readyz", cn.readyz)   // 就绪探针
+
+	metricsHandler := promhttp.Handler()
+	r.GET("/metrics", func(c *wkhttp.Context) { // Prometheus指标
+		token := extconfig.Get().Metrics.Token
+		if token != "" {
+			reqToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if reqToken == "" {
+				reqToken = c.Query("token")
+			}
+			if !hmac.Equal([]byte(token), []byte(reqToken)) {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+		}
+		metricsHandler.ServeHTTP(c.Writer, c.Request)
+	})
 
 	appConfigM, err := cn.insertAppConfigIfNeed()
 	if err != nil {
@@ -203,7 +239,7 @@ func (cn *Common) appModule(c *wkhttp.Context) {
 			})
 		}
 	}
-	c.Response(list)
+	httpcache.ResponseWithModified(c, list, httpcache.Modified(cn.ctx.GetRedisConn(), appModuleCacheKey))
 }
 
 // 查询聊天背景列表
@@ -216,7 +252,7 @@ func (cn *Common) chatBgList(c *wkhttp.Context) {
 	}
 	resps := make([]*chatBgResp, 0)
 	if len(list) == 0 {
-		c.Response(resps)
+		httpcache.Response(c, resps)
 		return
 	}
 	for index, model := range list {
@@ -236,7 +272,7 @@ func (cn *Common) chatBgList(c *wkhttp.Context) {
 			DarkColors:  darkColors,
 		})
 	}
-	c.Response(resps)
+	httpcache.Response(c, resps)
 }
 func (cn *Common) insertAppConfigIfNeed() (*appConfigModel, error) {
 
@@ -293,6 +329,22 @@ func (cn *Common) insertAppConfigIfNeed() (*appConfigModel, error) {
 	return appConfigM, err
 }
 
+// tenantInfo 请求的组织的名称和品牌 默认组织或没有开启多组织时返回空
+func (cn *Common) tenantInfo(c *wkhttp.Context) {
+	cfg := tenant.Config(tenant.ID(c.Context))
+	if cfg == nil {
+		c.JSON(http.StatusOK, &tenantResp{})
+		return
+	}
+	c.JSON(http.StatusOK, &tenantResp{
+		TenantID:   cfg.ID,
+		Name:       cfg.Name,
+		AppName:    cfg.AppName,
+		Logo:       cfg.Logo,
+		ThemeColor: cfg.ThemeColor,
+	})
+}
+
 func (cn *Common) appConfig(c *wkhttp.Context) {
 	versionStr := c.Query("version")
 	appConfigM, err := cn.appConfigDB.query()
@@ -302,10 +354,11 @@ func (cn *Common) appConfig(c *wkhttp.Context) {
 		return
 	}
 	versionI64, _ := strconv.ParseInt(versionStr, 10, 64)
+	modified := httpcache.Modified(cn.ctx.GetRedisConn(), appConfigCacheKey)
 	if versionI64 != 0 && int(versionI64) >= appConfigM.Version {
-		c.JSON(http.StatusOK, &appConfigResp{
+		httpcache.ResponseWithModified(c, &appConfigResp{
 			Version: appConfigM.Version,
-		})
+		}, modified)
 		return
 	}
 	var phoneSearchOff int
@@ -323,7 +376,7 @@ func (cn *Common) appConfig(c *wkhttp.Context) {
 		revokeSecond = appConfigM.RevokeSecond
 	}
 
-	c.JSON(http.StatusOK, &appConfigResp{
+	httpcache.ResponseWithModified(c, &appConfigResp{
 		Version:                        appConfigM.Version,
 		PhoneSearchOff:                 phoneSearchOff,
 		ShortnoEditOff:                 shortnoEditOff,
@@ -334,7 +387,8 @@ func (cn *Common) appConfig(c *wkhttp.Context) {
 		InviteSystemAccountJoinGroupOn: appConfigM.InviteSystemAccountJoinGroupOn,
 		RegisterUserMustCompleteInfoOn: appConfigM.RegisterUserMustCompleteInfoOn,
 		CanModifyApiUrl:                appConfigM.CanModifyApiUrl,
-	})
+		FollowOn:                       appConfigM.FollowOn,
+	}, modified)
 }
 
 func (cn *Common) countriesList(c *wkhttp.Context) {
@@ -343,7 +397,7 @@ func (cn *Common) countriesList(c *wkhttp.Context) {
 
 // 添加app版本
 func (cn *Common) addAppVersion(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermOperationWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -408,7 +462,7 @@ func (cn *Common) getNewVersion(c *wkhttp.Context) {
 
 // 查询总记录
 func (cn *Common) appVersionList(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermConfigRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -482,6 +536,14 @@ type chatBgResp struct {
 	DarkColors  []string `json:"dark_colors"`
 }
 
+type tenantResp struct {
+	TenantID   string `json:"tenant_id"`   // 组织ID
+	Name       string `json:"name"`        // 组织名称
+	AppName    string `json:"app_name"`    // 应用名称
+	Logo       string `json:"logo"`        // logo地址
+	ThemeColor string `json:"theme_color"` // 主题色
+}
+
 type appConfigResp struct {
 	Version                        int    `json:"version"`
 	WebURL                         string `json:"web_url"`
@@ -494,6 +556,7 @@ type appConfigResp struct {
 	InviteSystemAccountJoinGroupOn int    `json:"invite_system_account_join_group_on"` // 开启系统账号加入群聊
 	RegisterUserMustCompleteInfoOn int    `json:"register_user_must_complete_info_on"` // 注册用户必须填写完整信息
 	CanModifyApiUrl                int    `json:"can_modify_api_url"`                  // 允许修改api地址
+	FollowOn                       int    `json:"follow_on"`                           // 开启单向关注
 }
 
 type appVersionReq struct {
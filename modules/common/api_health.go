@@ -0,0 +1,89 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/health"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/shutdown"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+)
+
+// 健康检查的依赖
+const (
+	healthMySQL   = "mysql"
+	healthRedis   = "redis"
+	healthIM      = "im"
+	healthStorage = "storage"
+)
+
+func (cn *Common) newHealthChecker(fileService file.IService) *health.Checker {
+	healthCfg := extconfig.Get().Health
+	optional := map[string]bool{}
+	for _, name := range healthCfg.Optional {
+		optional[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	checker := health.New(healthCfg.Timeout, healthCfg.CacheTTL)
+	checker.Register(healthMySQL, !optional[healthMySQL], func(ctx context.Context) error {
+		return cn.db.session.PingContext(ctx)
+	})
+	checker.Register(healthRedis, !optional[healthRedis], func(ctx context.Context) error {
+		_, err := cn.ctx.GetRedisConn().Ping()
+		return err
+	})
+	checker.Register(healthIM, !optional[healthIM], cn.pingIM)
+	checker.Register(healthStorage, !optional[healthStorage], fileService.Ping)
+	return checker
+}
+
+// pingIM 请求IM的健康检查接口 返回5xx或连接失败时视为不可用
+func (cn *Common) pingIM(ctx context.Context) error {
+	apiURL := strings.TrimSuffix(cn.ctx.GetConfig().WuKongIM.APIURL, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("IM返回状态码：%d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 存活探针 进程能处理请求就返回200 依赖不可用时不返回错误避免重启所有实例
+// 返回的依赖检查结果只用于排查问题
+func (cn *Common) healthz(c *wkhttp.Context) {
+	c.JSON(http.StatusOK, cn.runHealthCheck())
+}
+
+// 就绪探针 必需的依赖不可用或正在退出时返回503 负载均衡不再转发请求到该实例
+func (cn *Common) readyz(c *wkhttp.Context) {
+	if shutdown.Draining() {
+		c.JSON(http.StatusServiceUnavailable, &health.Report{
+			Status:    health.StatusDraining,
+			CheckedAt: time.Now().UnixMilli(),
+			Checks:    map[string]*health.Result{},
+		})
+		return
+	}
+	report := cn.runHealthCheck()
+	if !report.Ready() {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// runHealthCheck 不使用请求的ctx 探针断开连接时不影响缓存的检查结果
+func (cn *Common) runHealthCheck() *health.Report {
+	return cn.healthChecker.Run(context.Background())
+}
@@ -2,8 +2,17 @@ package common
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/backup"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/httpcache"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/profiling"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
@@ -38,10 +47,14 @@ func (m *Manager) Route(r *wkhttp.WKHttp) {
 		auth.PUT("/common/appmodule", m.updateAppModule)         // 修改app模块
 		auth.POST("/common/appmodule", m.addAppModule)           // 新增app模块
 		auth.DELETE("/common/:sid/appmodule", m.deleteAppModule) // 删除app模块
+		auth.POST("/common/config/reload", m.reloadConfig)       // 重新加载配置文件
+		auth.POST("/common/backup", m.backup)                    // 下载数据备份
+		auth.GET("/common/profiling", m.profilingReport)         // 慢查询和慢接口统计
+		auth.DELETE("/common/profiling", m.profilingReset)       // 清空慢查询和慢接口统计
 	}
 }
 func (m *Manager) deleteAppModule(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -68,12 +81,13 @@ func (m *Manager) deleteAppModule(c *wkhttp.Context) {
 		c.ResponseError(errors.New("删除app模块错误"))
 		return
 	}
+	m.touchHTTPCache(appModuleCacheKey)
 	c.ResponseOK()
 }
 
 // 新增app模块
 func (m *Manager) addAppModule(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -115,10 +129,11 @@ func (m *Manager) addAppModule(c *wkhttp.Context) {
 		c.ResponseError(errors.New("新增app模块错误"))
 		return
 	}
+	m.touchHTTPCache(appModuleCacheKey)
 	c.ResponseOK()
 }
 func (m *Manager) updateAppModule(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -158,12 +173,13 @@ func (m *Manager) updateAppModule(c *wkhttp.Context) {
 		c.ResponseError(errors.New("修改app模块错误"))
 		return
 	}
+	m.touchHTTPCache(appModuleCacheKey)
 	c.ResponseOK()
 }
 
 // 获取app模块
 func (m *Manager) getAppModule(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermConfigRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -188,7 +204,7 @@ func (m *Manager) getAppModule(c *wkhttp.Context) {
 	c.Response(list)
 }
 func (m *Manager) updateConfig(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -204,6 +220,7 @@ func (m *Manager) updateConfig(c *wkhttp.Context) {
 		RegisterUserMustCompleteInfoOn int    `json:"register_user_must_complete_info_on"` // 注册用户必须填写完整信息
 		ChannelPinnedMessageMaxCount   int    `json:"channel_pinned_message_max_count"`    // 频道置顶消息最大数量
 		CanModifyApiUrl                int    `json:"can_modify_api_url"`                  // 是否可以修改api地址
+		FollowOn                       int    `json:"follow_on"`                           // 是否开启单向关注
 	}
 	var req reqVO
 	if err := c.BindJSON(&req); err != nil {
@@ -227,16 +244,128 @@ func (m *Manager) updateConfig(c *wkhttp.Context) {
 	configMap["register_user_must_complete_info_on"] = req.RegisterUserMustCompleteInfoOn
 	configMap["channel_pinned_message_max_count"] = req.ChannelPinnedMessageMaxCount
 	configMap["can_modify_api_url"] = req.CanModifyApiUrl
+	configMap["follow_on"] = req.FollowOn
 	err = m.appconfigDB.updateWithMap(configMap, appConfigM.Id)
 	if err != nil {
 		m.Error("修改app配置信息错误", zap.Error(err))
 		c.ResponseError(errors.New("修改app配置信息错误"))
 		return
 	}
+	audit.SetChange(c, "app_config", appConfigValues(appConfigM, configMap), configMap)
+	m.touchHTTPCache(appConfigCacheKey)
 	c.ResponseOK()
 }
+
+// 重新加载配置文件中的扩展配置（短信、推送、限流、敏感词等） 配置有误时保留原来的配置
+func (m *Manager) reloadConfig(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	result, err := extconfig.Reload()
+	if err != nil {
+		m.Warn("重新加载配置失败！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	m.Info("配置已重新加载", zap.Strings("changed", result.Changed), zap.Strings("restartRequired", result.RestartRequired))
+	c.Response(result)
+}
+
+// 下载数据备份 边导出边写入响应 恢复需要使用restore命令
+func (m *Manager) backup(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermBackup)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	started := time.Now()
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=tsdd-backup-%s.zip", started.Format("20060102150405")))
+	c.Header("Content-Type", "application/zip")
+	mf, err := backup.Run(c.Request.Context(), m.ctx, c.Writer)
+	if err != nil {
+		// 响应已经开始写入 不完整的备份文件不能通过校验
+		m.Error("备份失败！", zap.Error(err), zap.String("operator", c.GetLoginUID()))
+		c.Abort()
+		return
+	}
+	m.Info("下载数据备份", zap.String("operator", c.GetLoginUID()), zap.Int("tables", len(mf.Tables)), zap.Duration("cost", time.Since(started)))
+	audit.SetChange(c, "backup", nil, map[string]interface{}{"tables": len(mf.Tables), "created_at": mf.CreatedAt})
+}
+
+// 慢查询和慢接口统计 只包含当前实例
+func (m *Manager) profilingReport(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermProfiling)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	sortBy := c.DefaultQuery("sort", profiling.SortTotal)
+	switch sortBy {
+	case profiling.SortTotal, profiling.SortMax, profiling.SortAvg, profiling.SortCount:
+	default:
+		c.ResponseError(errors.New("不支持的排序方式！"))
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	cfg := extconfig.Get().Profiling
+	c.Response(map[string]interface{}{
+		"slow_sql_ms":  cfg.SlowSQL.Milliseconds(),
+		"slow_http_ms": cfg.SlowHTTP.Milliseconds(),
+		"sql":          profiling.SQLReport(sortBy, limit),
+		"http":         profiling.HTTPReport(sortBy, limit),
+	})
+}
+
+// 清空慢查询和慢接口统计 优化后重新统计
+func (m *Manager) profilingReset(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermProfiling)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	profiling.Reset()
+	m.Info("清空慢查询和慢接口统计", zap.String("operator", c.GetLoginUID()))
+	c.ResponseOK()
+}
+
+// touchHTTPCache 数据修改后让客户端缓存的数据失效
+func (m *Manager) touchHTTPCache(key string) {
+	if err := httpcache.Touch(m.ctx.GetRedisConn(), key); err != nil {
+		m.Warn("更新HTTP缓存修改时间失败！", zap.Error(err), zap.String("key", key))
+	}
+}
+
+// appConfigValues 修改前的配置 只包含本次修改的字段
+func appConfigValues(m *appConfigModel, configMap map[string]interface{}) map[string]interface{} {
+	values := map[string]interface{}{
+		"revoke_second":                       m.RevokeSecond,
+		"welcome_message":                     m.WelcomeMessage,
+		"new_user_join_system_group":          m.NewUserJoinSystemGroup,
+		"search_by_phone":                     m.SearchByPhone,
+		"register_invite_on":                  m.RegisterInviteOn,
+		"send_welcome_message_on":             m.SendWelcomeMessageOn,
+		"invite_system_account_join_group_on": m.InviteSystemAccountJoinGroupOn,
+		"register_user_must_complete_info_on": m.RegisterUserMustCompleteInfoOn,
+		"channel_pinned_message_max_count":    m.ChannelPinnedMessageMaxCount,
+		"can_modify_api_url":                  m.CanModifyApiUrl,
+		"follow_on":                           m.FollowOn,
+	}
+	before := make(map[string]interface{}, len(configMap))
+	for key := range configMap {
+		before[key] = values[key]
+	}
+	return before
+}
 func (m *Manager) appconfig(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermConfigRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -257,6 +386,7 @@ func (m *Manager) appconfig(c *wkhttp.Context) {
 	var registerUserMustCompleteInfoOn = 0
 	var channelPinnedMessageMaxCount = 10
 	var canModifyApiUrl = 0
+	var followOn = 0
 	if appconfig != nil {
 		revokeSecond = appconfig.RevokeSecond
 		welcomeMessage = appconfig.WelcomeMessage
@@ -268,6 +398,7 @@ func (m *Manager) appconfig(c *wkhttp.Context) {
 		registerUserMustCompleteInfoOn = appconfig.RegisterUserMustCompleteInfoOn
 		channelPinnedMessageMaxCount = appconfig.ChannelPinnedMessageMaxCount
 		canModifyApiUrl = appconfig.CanModifyApiUrl
+		followOn = appconfig.FollowOn
 	}
 	if revokeSecond == 0 {
 		revokeSecond = 120
@@ -286,6 +417,7 @@ func (m *Manager) appconfig(c *wkhttp.Context) {
 		RegisterUserMustCompleteInfoOn: registerUserMustCompleteInfoOn,
 		ChannelPinnedMessageMaxCount:   channelPinnedMessageMaxCount,
 		CanModifyApiUrl:                canModifyApiUrl,
+		FollowOn:                       followOn,
 	})
 }
 
@@ -300,6 +432,7 @@ type managerAppConfigResp struct {
 	RegisterUserMustCompleteInfoOn int    `json:"register_user_must_complete_info_on"` // 注册用户必须填写完整信息
 	ChannelPinnedMessageMaxCount   int    `json:"channel_pinned_message_max_count"`    // 频道置顶消息最大数量
 	CanModifyApiUrl                int    `json:"can_modify_api_url"`                  // 是否可以修改api地址
+	FollowOn                       int    `json:"follow_on"`                           // 是否开启单向关注
 }
 
 type managerAppModule struct {
@@ -51,5 +51,6 @@ type appConfigModel struct {
 	RegisterUserMustCompleteInfoOn int    // 注册用户是否必须完善个人信息
 	ChannelPinnedMessageMaxCount   int    // 频道置顶消息最大数量
 	CanModifyApiUrl                int    // 是否可以修改API地址
+	FollowOn                       int    // 是否开启单向关注
 	ldb.BaseModel
 }
@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE `app_config` ADD COLUMN follow_on smallint not null DEFAULT 0 COMMENT '是否开启单向关注';
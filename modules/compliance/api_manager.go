@@ -0,0 +1,483 @@
+package compliance
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/report"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/sensitive"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Manager 用户数据的合规导出 申请后需要申请人以外的管理员审批 审批通过后申请人在有效期内下载
+// 消息检索 每次检索都记录原因 只返回消息的元数据和被举报或命中敏感词的消息原文
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db               *db
+	userService      user.IService
+	groupService     group.IService
+	messageService   message.IService
+	reportService    report.IService
+	sensitiveService sensitive.IService
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	return &Manager{
+		ctx:              ctx,
+		Log:              log.NewTLog("ComplianceManager"),
+		db:               newDB(ctx),
+		userService:      user.NewService(ctx),
+		groupService:     group.NewService(ctx),
+		messageService:   message.NewService(ctx),
+		reportService:    report.NewService(ctx),
+		sensitiveService: sensitive.NewService(ctx),
+	}
+}
+
+// Route 路由配置
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.POST("/compliance/exports", m.create)                       // 申请导出
+		auth.GET("/compliance/exports", m.list)                          // 申请列表
+		auth.PUT("/compliance/exports/:export_no/approve", m.approve)    // 审批通过
+		auth.PUT("/compliance/exports/:export_no/reject", m.reject)      // 拒绝
+		auth.POST("/compliance/exports/:export_no/download", m.download) // 下载（POST 保证记录到操作日志）
+
+		// 消息检索（合规调查）
+		auth.POST("/compliance/searches", m.search)    // 检索消息 需要填写原因
+		auth.GET("/compliance/searches", m.searchList) // 检索记录
+	}
+}
+
+type createReq struct {
+	UID      string `json:"uid"`       // 导出数据的用户
+	Reason   string `json:"reason"`    // 申请原因
+	TicketNo string `json:"ticket_no"` // 关联的法务工单号
+}
+
+func (r createReq) check() error {
+	if strings.TrimSpace(r.UID) == "" {
+		return errors.New("用户uid不能为空")
+	}
+	if strings.TrimSpace(r.Reason) == "" {
+		return errors.New("申请原因不能为空")
+	}
+	if len([]rune(r.Reason)) > reasonMaxLen {
+		return fmt.Errorf("申请原因不能超过%d个字", reasonMaxLen)
+	}
+	if len(r.TicketNo) > ticketNoMaxLen {
+		return fmt.Errorf("工单号不能超过%d个字符", ticketNoMaxLen)
+	}
+	return nil
+}
+
+// 申请导出用户的数据
+func (m *Manager) create(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermComplianceExport); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req createReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	uid := strings.TrimSpace(req.UID)
+	userResp, err := m.userService.GetUser(uid)
+	if err != nil {
+		m.Error("查询用户失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户失败！"))
+		return
+	}
+	if userResp == nil {
+		c.ResponseError(errors.New("用户不存在"))
+		return
+	}
+	export := &model{
+		ExportNo:  util.GenerUUID(),
+		UID:       uid,
+		Reason:    strings.TrimSpace(req.Reason),
+		TicketNo:  strings.TrimSpace(req.TicketNo),
+		Requester: c.GetLoginUID(),
+		Status:    StatusPending,
+	}
+	if err := m.db.insert(export); err != nil {
+		m.Error("添加导出申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加导出申请失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("export_no=%s", export.ExportNo), nil, req)
+	c.Response(map[string]interface{}{
+		"export_no": export.ExportNo,
+	})
+}
+
+// 申请列表 申请人和审批人都可以查看
+func (m *Manager) list(c *wkhttp.Context) {
+	role := c.GetLoginRole()
+	if !rbac.HasPermission(role, rbac.PermComplianceExport) && !rbac.HasPermission(role, rbac.PermComplianceApprove) {
+		c.ResponseError(errcode.ErrPermissionDenied)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	filter := exportFilter{
+		status:    c.Query("status"),
+		uid:       c.Query("uid"),
+		requester: c.Query("requester"),
+	}
+	models, err := m.db.queryWithPage(filter, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询导出申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询导出申请失败！"))
+		return
+	}
+	count, err := m.db.queryCount(filter)
+	if err != nil {
+		m.Error("查询导出申请数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询导出申请数量失败！"))
+		return
+	}
+	maxDownloads := extconfig.Get().Compliance.MaxDownloads
+	now := time.Now().Unix()
+	list := make([]*exportResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newExportResp(model, maxDownloads, now))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 审批通过 不能审批自己的申请
+func (m *Manager) approve(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermComplianceApprove); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	export, ok := m.getExport(c)
+	if !ok {
+		return
+	}
+	approver := c.GetLoginUID()
+	if err := checkApprove(export, approver); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	now := time.Now()
+	expireAt := now.Add(extconfig.Get().Compliance.DownloadTTL).Unix()
+	ok, err := m.db.updateApproved(export.Id, approver, now.Unix(), expireAt)
+	if err != nil {
+		m.Error("审批导出申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("审批导出申请失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("申请已审批"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("export_no=%s", export.ExportNo), map[string]interface{}{"status": export.Status}, map[string]interface{}{"status": StatusApproved, "expire_at": expireAt})
+	c.ResponseOK()
+}
+
+// 拒绝申请
+func (m *Manager) reject(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermComplianceApprove); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"` // 拒绝原因
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if len([]rune(req.Reason)) > reasonMaxLen {
+		c.ResponseError(fmt.Errorf("拒绝原因不能超过%d个字", reasonMaxLen))
+		return
+	}
+	export, ok := m.getExport(c)
+	if !ok {
+		return
+	}
+	approver := c.GetLoginUID()
+	if err := checkApprove(export, approver); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	ok, err := m.db.updateRejected(export.Id, approver, strings.TrimSpace(req.Reason), time.Now().Unix())
+	if err != nil {
+		m.Error("拒绝导出申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("拒绝导出申请失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("申请已审批"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("export_no=%s", export.ExportNo), map[string]interface{}{"status": export.Status}, map[string]interface{}{"status": StatusRejected, "reason": req.Reason})
+	c.ResponseOK()
+}
+
+// 下载导出的数据（zip） 只有申请人可以下载 每次下载时重新查询数据
+func (m *Manager) download(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermComplianceExport); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	export, ok := m.getExport(c)
+	if !ok {
+		return
+	}
+	cfg := extconfig.Get().Compliance
+	now := time.Now().Unix()
+	if err := checkDownload(export, c.GetLoginUID(), now, cfg.MaxDownloads); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	mf, files, err := m.collect(export, cfg.MaxRows)
+	if err != nil {
+		m.Error("查询用户数据失败！", zap.Error(err), zap.String("uid", export.UID))
+		c.ResponseError(errors.New("查询用户数据失败！"))
+		return
+	}
+	var buf bytes.Buffer
+	if err = writeArchive(&buf, mf, files); err != nil {
+		m.Error("生成导出文件失败！", zap.Error(err))
+		c.ResponseError(errors.New("生成导出文件失败！"))
+		return
+	}
+	ok, err = m.db.incrDownloadCount(export.Id, cfg.MaxDownloads, now)
+	if err != nil {
+		m.Error("记录下载次数失败！", zap.Error(err))
+		c.ResponseError(errors.New("记录下载次数失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("下载已过期或下载次数已用完"))
+		return
+	}
+	m.Info("下载合规导出", zap.String("exportNo", export.ExportNo), zap.String("uid", export.UID), zap.String("operator", c.GetLoginUID()))
+	audit.SetChange(c, fmt.Sprintf("export_no=%s", export.ExportNo), nil, map[string]interface{}{"uid": export.UID, "download_count": export.DownloadCount + 1})
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=user_data_%s_%s.zip", export.UID, time.Now().Format("20060102150405")))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+func (m *Manager) getExport(c *wkhttp.Context) (*model, bool) {
+	export, err := m.db.queryWithExportNo(c.Param("export_no"))
+	if err != nil {
+		m.Error("查询导出申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询导出申请失败！"))
+		return nil, false
+	}
+	if export == nil {
+		c.ResponseError(errors.New("导出申请不存在"))
+		return nil, false
+	}
+	return export, true
+}
+
+// exportGroup 用户加入的群和在群内的资料
+type exportGroup struct {
+	*group.InfoResp
+	MemberRole   int    `json:"member_role"`   // 在群内的角色 0.成员 1.群主 2.管理员
+	MemberRemark string `json:"member_remark"` // 在群内的备注
+	JoinedAt     int64  `json:"joined_at"`
+}
+
+// collect 查询用户的所有数据
+func (m *Manager) collect(export *model, maxRows int) (*manifest, []archiveFile, error) {
+	userData, err := m.userService.GetExportData(export.UID, maxRows)
+	if err != nil {
+		return nil, nil, err
+	}
+	if userData == nil {
+		return nil, nil, errors.New("用户不存在")
+	}
+	groups, err := m.groupService.GetGroupsWithMemberUID(export.UID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if maxRows > 0 && len(groups) > maxRows {
+		groups = groups[:maxRows]
+	}
+	groupNos := make([]string, 0, len(groups))
+	for _, g := range groups {
+		groupNos = append(groupNos, g.GroupNo)
+	}
+	members := make([]*group.MemberResp, 0)
+	if len(groupNos) > 0 {
+		members, err = m.groupService.GetMembersWithUIDAndGroupIds(export.UID, groupNos)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	memberMap := make(map[string]*group.MemberResp, len(members))
+	for _, member := range members {
+		memberMap[member.GroupNo] = member
+	}
+	exportGroups := make([]*exportGroup, 0, len(groups))
+	for _, g := range groups {
+		eg := &exportGroup{InfoResp: g}
+		if member := memberMap[g.GroupNo]; member != nil {
+			eg.MemberRole = member.Role
+			eg.MemberRemark = member.Remark
+			eg.JoinedAt = member.CreatedAt
+		}
+		exportGroups = append(exportGroups, eg)
+	}
+	messageData, err := m.messageService.GetExportData(export.UID, maxRows)
+	if err != nil {
+		return nil, nil, err
+	}
+	reportData, err := m.reportService.GetExportData(export.UID, maxRows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mf := &manifest{
+		ExportNo:    export.ExportNo,
+		UID:         export.UID,
+		Reason:      export.Reason,
+		TicketNo:    export.TicketNo,
+		Requester:   export.Requester,
+		Approver:    export.Approver,
+		ApprovedAt:  export.ApprovedAt,
+		GeneratedAt: time.Now().Unix(),
+		MaxRows:     maxRows,
+		Sections:    make([]*manifestSection, 0),
+		Notes:       notes,
+	}
+	mf.addSection("profile.json", "devices", len(userData.Devices))
+	mf.addSection("profile.json", "login_logs", len(userData.LoginLogs))
+	mf.addSection("profile.json", "bans", len(userData.Bans))
+	mf.addSection("contacts.json", "friends", len(userData.Friends))
+	mf.addSection("contacts.json", "friend_applys", len(userData.FriendApplys))
+	mf.addSection("contacts.json", "blacklist", len(userData.Blacklist))
+	mf.addSection("contacts.json", "maillist", len(userData.Maillist))
+	mf.addSection("contacts.json", "following", len(userData.Following))
+	mf.addSection("contacts.json", "followers", len(userData.Followers))
+	mf.addSection("groups.json", "groups", len(exportGroups))
+	mf.addSection("messages.json", "conversations", len(messageData.Conversations))
+	mf.addSection("messages.json", "reactions", len(messageData.Reactions))
+	mf.addSection("messages.json", "message_flags", len(messageData.MessageFlags))
+	mf.addSection("messages.json", "reminders", len(messageData.Reminders))
+	mf.addSection("reports.json", "submitted", len(reportData.Submitted))
+	mf.addSection("reports.json", "received", len(reportData.Received))
+
+	files := []archiveFile{
+		{name: "profile.json", data: map[string]interface{}{
+			"profile":    userData.Profile,
+			"devices":    userData.Devices,
+			"login_logs": userData.LoginLogs,
+			"bans":       userData.Bans,
+		}},
+		{name: "contacts.json", data: map[string]interface{}{
+			"friends":       userData.Friends,
+			"friend_applys": userData.FriendApplys,
+			"blacklist":     userData.Blacklist,
+			"maillist":      userData.Maillist,
+			"following":     userData.Following,
+			"followers":     userData.Followers,
+		}},
+		{name: "groups.json", data: map[string]interface{}{
+			"groups": exportGroups,
+		}},
+		{name: "messages.json", data: messageData},
+		{name: "reports.json", data: reportData},
+	}
+	return mf, files, nil
+}
+
+// checkApprove 检查是否可以审批 申请人不能审批自己的申请
+func checkApprove(export *model, approver string) error {
+	if export.Status != StatusPending {
+		return errors.New("申请已审批")
+	}
+	if export.Requester == approver {
+		return errors.New("不能审批自己的申请")
+	}
+	return nil
+}
+
+// checkDownload 检查是否可以下载 只有申请人可以在有效期内下载 下载次数有限制
+func checkDownload(export *model, uid string, now int64, maxDownloads int) error {
+	if export.Requester != uid {
+		return errors.New("只有申请人可以下载")
+	}
+	switch export.Status {
+	case StatusPending:
+		return errors.New("申请还没有审批")
+	case StatusRejected:
+		return errors.New("申请已被拒绝")
+	}
+	if export.ExpireAt <= now {
+		return errors.New("下载已过期")
+	}
+	if export.DownloadCount >= maxDownloads {
+		return errors.New("下载次数已用完")
+	}
+	return nil
+}
+
+type exportResp struct {
+	ExportNo       string `json:"export_no"`
+	UID            string `json:"uid"`
+	Reason         string `json:"reason"`
+	TicketNo       string `json:"ticket_no"`
+	Requester      string `json:"requester"`
+	Approver       string `json:"approver"`
+	Status         int    `json:"status"`  // 0.待审批 1.已通过 2.已拒绝
+	Expired        int    `json:"expired"` // 审批通过后是否已不能下载（过期或下载次数已用完） 1.是
+	RejectReason   string `json:"reject_reason"`
+	ApprovedAt     int64  `json:"approved_at"`
+	ExpireAt       int64  `json:"expire_at"`
+	DownloadCount  int    `json:"download_count"`
+	LastDownloadAt int64  `json:"last_download_at"`
+	CreatedAt      string `json:"created_at"`
+}
+
+func newExportResp(m *model, maxDownloads int, now int64) *exportResp {
+	resp := &exportResp{
+		ExportNo:       m.ExportNo,
+		UID:            m.UID,
+		Reason:         m.Reason,
+		TicketNo:       m.TicketNo,
+		Requester:      m.Requester,
+		Approver:       m.Approver,
+		Status:         m.Status,
+		RejectReason:   m.RejectReason,
+		ApprovedAt:     m.ApprovedAt,
+		ExpireAt:       m.ExpireAt,
+		DownloadCount:  m.DownloadCount,
+		LastDownloadAt: m.LastDownloadAt,
+		CreatedAt:      m.CreatedAt.String(),
+	}
+	if m.Status == StatusApproved && (m.ExpireAt <= now || m.DownloadCount >= maxDownloads) {
+		resp.Expired = 1
+	}
+	return resp
+}
@@ -0,0 +1,258 @@
+package compliance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+type searchReq struct {
+	UID      string `json:"uid"`       // 检索的用户 和群都传时检索用户在群内发送的消息
+	GroupNo  string `json:"group_no"`  // 检索的群
+	StartAt  int64  `json:"start_at"`  // 消息时间的开始（秒）
+	EndAt    int64  `json:"end_at"`    // 消息时间的结束（秒） 不包含
+	Reason   string `json:"reason"`    // 检索原因
+	TicketNo string `json:"ticket_no"` // 关联的法务工单号
+}
+
+func (r searchReq) check(maxRange time.Duration) error {
+	if strings.TrimSpace(r.UID) == "" && strings.TrimSpace(r.GroupNo) == "" {
+		return errors.New("用户uid和群编号不能都为空")
+	}
+	if strings.TrimSpace(r.Reason) == "" {
+		return errors.New("检索原因不能为空")
+	}
+	if len([]rune(r.Reason)) > reasonMaxLen {
+		return fmt.Errorf("检索原因不能超过%d个字", reasonMaxLen)
+	}
+	if len(r.TicketNo) > ticketNoMaxLen {
+		return fmt.Errorf("工单号不能超过%d个字符", ticketNoMaxLen)
+	}
+	if r.StartAt <= 0 || r.EndAt <= r.StartAt {
+		return errors.New("消息时间的范围有误")
+	}
+	if time.Duration(r.EndAt-r.StartAt)*time.Second > maxRange {
+		return fmt.Errorf("消息时间的范围不能超过%d天", int(maxRange.Hours()/24))
+	}
+	return nil
+}
+
+// searchMessageResp 检索到的消息 只有被标记的文本消息返回原文
+type searchMessageResp struct {
+	*message.SearchMessage
+	Flags   []string `json:"flags"`             // report.被举报过 sensitive.命中过敏感词
+	Content string   `json:"content,omitempty"` // 被标记的文本消息的原文
+}
+
+// 检索用户或群的消息（合规调查） 每次检索都记录原因 只返回消息的元数据和被标记消息的原文
+func (m *Manager) search(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermComplianceSearch); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req searchReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	cfg := extconfig.Get().Compliance
+	if err := req.check(cfg.SearchMaxRange); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	uid := strings.TrimSpace(req.UID)
+	groupNo := strings.TrimSpace(req.GroupNo)
+	if err := m.checkSearchTarget(uid, groupNo); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	// 先记录检索 记录失败时不检索
+	search := &searchModel{
+		SearchNo: util.GenerUUID(),
+		Operator: c.GetLoginUID(),
+		UID:      uid,
+		GroupNo:  groupNo,
+		Reason:   strings.TrimSpace(req.Reason),
+		TicketNo: strings.TrimSpace(req.TicketNo),
+		StartAt:  req.StartAt,
+		EndAt:    req.EndAt,
+	}
+	if err := m.db.insertSearch(search); err != nil {
+		m.Error("添加检索记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加检索记录失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("search_no=%s", search.SearchNo), nil, req)
+
+	messages, err := m.messageService.SearchMessages(&message.SearchReq{
+		UID:     uid,
+		GroupNo: groupNo,
+		StartAt: req.StartAt,
+		EndAt:   req.EndAt,
+		Limit:   cfg.SearchMaxRows + 1,
+	})
+	if err != nil {
+		m.Error("检索消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("检索消息失败！"))
+		return
+	}
+	truncated := len(messages) > cfg.SearchMaxRows
+	if truncated {
+		messages = messages[:cfg.SearchMaxRows]
+	}
+	messageIDs := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		messageIDs = append(messageIDs, msg.MessageID)
+	}
+	reportedIDs, err := m.reportService.GetReportedMessageIDs(messageIDs)
+	if err != nil {
+		m.Error("查询被举报的消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询被举报的消息失败！"))
+		return
+	}
+	hitIDs, err := m.sensitiveService.GetHitMessageIDs(messageIDs)
+	if err != nil {
+		m.Error("查询命中敏感词的消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询命中敏感词的消息失败！"))
+		return
+	}
+	list, flaggedCount := newSearchMessageResps(messages, reportedIDs, hitIDs)
+	if err = m.db.updateSearchResult(search.SearchNo, len(list), flaggedCount); err != nil {
+		m.Warn("记录检索结果失败！", zap.Error(err), zap.String("searchNo", search.SearchNo))
+	}
+	m.Info("合规检索消息", zap.String("searchNo", search.SearchNo), zap.String("uid", uid), zap.String("groupNo", groupNo), zap.String("operator", search.Operator), zap.Int("count", len(list)))
+	c.Response(map[string]interface{}{
+		"search_no": search.SearchNo,
+		"list":      list,
+		"truncated": truncated,
+	})
+}
+
+// checkSearchTarget 检索的用户和群需要存在
+func (m *Manager) checkSearchTarget(uid string, groupNo string) error {
+	if uid != "" {
+		userResp, err := m.userService.GetUser(uid)
+		if err != nil {
+			m.Error("查询用户失败！", zap.Error(err))
+			return errors.New("查询用户失败！")
+		}
+		if userResp == nil {
+			return errors.New("用户不存在")
+		}
+	}
+	if groupNo != "" {
+		groupResp, err := m.groupService.GetGroupWithGroupNo(groupNo)
+		if err != nil {
+			m.Error("查询群失败！", zap.Error(err))
+			return errors.New("查询群失败！")
+		}
+		if groupResp == nil {
+			return errors.New("群不存在")
+		}
+	}
+	return nil
+}
+
+// 检索记录 检索的管理员和审批人都可以查看
+func (m *Manager) searchList(c *wkhttp.Context) {
+	role := c.GetLoginRole()
+	if !rbac.HasPermission(role, rbac.PermComplianceSearch) && !rbac.HasPermission(role, rbac.PermComplianceApprove) {
+		c.ResponseError(errcode.ErrPermissionDenied)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	filter := searchFilter{
+		operator: c.Query("operator"),
+		uid:      c.Query("uid"),
+		groupNo:  c.Query("group_no"),
+	}
+	models, err := m.db.querySearchWithPage(filter, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询检索记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询检索记录失败！"))
+		return
+	}
+	count, err := m.db.querySearchCount(filter)
+	if err != nil {
+		m.Error("查询检索记录数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询检索记录数量失败！"))
+		return
+	}
+	list := make([]*searchLogResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newSearchLogResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// newSearchMessageResps 标记被举报或命中敏感词的消息 只有被标记的消息返回原文
+func newSearchMessageResps(messages []*message.SearchMessage, reportedIDs []string, hitIDs []string) ([]*searchMessageResp, int) {
+	reported := make(map[string]bool, len(reportedIDs))
+	for _, id := range reportedIDs {
+		reported[id] = true
+	}
+	hit := make(map[string]bool, len(hitIDs))
+	for _, id := range hitIDs {
+		hit[id] = true
+	}
+	flaggedCount := 0
+	list := make([]*searchMessageResp, 0, len(messages))
+	for _, msg := range messages {
+		resp := &searchMessageResp{SearchMessage: msg, Flags: make([]string, 0)}
+		if reported[msg.MessageID] {
+			resp.Flags = append(resp.Flags, FlagReport)
+		}
+		if hit[msg.MessageID] {
+			resp.Flags = append(resp.Flags, FlagSensitive)
+		}
+		if len(resp.Flags) > 0 {
+			resp.Content = msg.Text
+			flaggedCount++
+		}
+		list = append(list, resp)
+	}
+	return list, flaggedCount
+}
+
+type searchLogResp struct {
+	SearchNo     string `json:"search_no"`
+	Operator     string `json:"operator"`
+	UID          string `json:"uid"`
+	GroupNo      string `json:"group_no"`
+	Reason       string `json:"reason"`
+	TicketNo     string `json:"ticket_no"`
+	StartAt      int64  `json:"start_at"`
+	EndAt        int64  `json:"end_at"`
+	ResultCount  int    `json:"result_count"`
+	FlaggedCount int    `json:"flagged_count"`
+	CreatedAt    string `json:"created_at"`
+}
+
+func newSearchLogResp(m *searchModel) *searchLogResp {
+	return &searchLogResp{
+		SearchNo:     m.SearchNo,
+		Operator:     m.Operator,
+		UID:          m.UID,
+		GroupNo:      m.GroupNo,
+		Reason:       m.Reason,
+		TicketNo:     m.TicketNo,
+		StartAt:      m.StartAt,
+		EndAt:        m.EndAt,
+		ResultCount:  m.ResultCount,
+		FlaggedCount: m.FlaggedCount,
+		CreatedAt:    m.CreatedAt.String(),
+	}
+}
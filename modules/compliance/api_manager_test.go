@@ -0,0 +1,91 @@
+package compliance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckApprove(t *testing.T) {
+	export := &model{Requester: "admin", Status: StatusPending}
+	assert.NoError(t, checkApprove(export, "auditor"))
+	// 不能审批自己的申请
+	assert.Error(t, checkApprove(export, "admin"))
+
+	export.Status = StatusRejected
+	assert.Error(t, checkApprove(export, "auditor"))
+}
+
+func TestCheckDownload(t *testing.T) {
+	now := int64(1000)
+	export := &model{Requester: "admin", Status: StatusApproved, ExpireAt: now + 60, DownloadCount: 2}
+	assert.NoError(t, checkDownload(export, "admin", now, 3))
+	// 只有申请人可以下载
+	assert.Error(t, checkDownload(export, "auditor", now, 3))
+	// 下载次数已用完
+	assert.Error(t, checkDownload(export, "admin", now, 2))
+	// 已过期
+	assert.Error(t, checkDownload(export, "admin", now+60, 3))
+
+	export.Status = StatusPending
+	assert.Error(t, checkDownload(export, "admin", now, 3))
+	export.Status = StatusRejected
+	assert.Error(t, checkDownload(export, "admin", now, 3))
+}
+
+func TestNewExportResp(t *testing.T) {
+	now := int64(1000)
+	resp := newExportResp(&model{Status: StatusApproved, ExpireAt: now + 60, DownloadCount: 1}, 3, now)
+	assert.Equal(t, 0, resp.Expired)
+
+	resp = newExportResp(&model{Status: StatusApproved, ExpireAt: now + 60, DownloadCount: 3}, 3, now)
+	assert.Equal(t, 1, resp.Expired)
+
+	resp = newExportResp(&model{Status: StatusApproved, ExpireAt: now, DownloadCount: 0}, 3, now)
+	assert.Equal(t, 1, resp.Expired)
+
+	resp = newExportResp(&model{Status: StatusPending}, 3, now)
+	assert.Equal(t, 0, resp.Expired)
+}
+
+func TestSearchReqCheck(t *testing.T) {
+	maxRange := time.Hour * 24
+	req := searchReq{UID: "u1", Reason: "调查", StartAt: 1000, EndAt: 1000 + 3600}
+	assert.NoError(t, req.check(maxRange))
+
+	// 检索原因必填
+	noReason := req
+	noReason.Reason = " "
+	assert.Error(t, noReason.check(maxRange))
+
+	noTarget := req
+	noTarget.UID = ""
+	assert.Error(t, noTarget.check(maxRange))
+
+	// 时间范围有误或超过最长时间
+	badRange := req
+	badRange.EndAt = badRange.StartAt
+	assert.Error(t, badRange.check(maxRange))
+	tooLong := req
+	tooLong.EndAt = tooLong.StartAt + 3600*25
+	assert.Error(t, tooLong.check(maxRange))
+}
+
+func TestNewSearchMessageResps(t *testing.T) {
+	messages := []*message.SearchMessage{
+		{MessageID: "1", Text: "hello"},
+		{MessageID: "2", Text: "reported"},
+		{MessageID: "3", Text: "both"},
+	}
+	list, flaggedCount := newSearchMessageResps(messages, []string{"2", "3"}, []string{"3"})
+	assert.Equal(t, 2, flaggedCount)
+	assert.Len(t, list, 3)
+	// 没有被标记的消息不返回原文
+	assert.Empty(t, list[0].Content)
+	assert.Empty(t, list[0].Flags)
+	assert.Equal(t, "reported", list[1].Content)
+	assert.Equal(t, []string{FlagReport}, list[1].Flags)
+	assert.Equal(t, []string{FlagReport, FlagSensitive}, list[2].Flags)
+}
@@ -0,0 +1,73 @@
+package compliance
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+)
+
+// notes 导出文件的说明
+var notes = []string{
+	"消息正文保存在悟空IM，不在导出范围内，messages.json只包含会话的阅读位置、草稿、回应、删除标记和提醒等元数据",
+	"reports.json中用户被举报的记录不包含举报人和举报人填写的备注",
+	"truncated为true的项数量达到了max_rows，可能没有导出完整",
+}
+
+// manifest 导出文件的说明 保存为manifest.json
+type manifest struct {
+	ExportNo    string             `json:"export_no"`
+	UID         string             `json:"uid"`
+	Reason      string             `json:"reason"`
+	TicketNo    string             `json:"ticket_no"`
+	Requester   string             `json:"requester"`
+	Approver    string             `json:"approver"`
+	ApprovedAt  int64              `json:"approved_at"`
+	GeneratedAt int64              `json:"generated_at"`
+	MaxRows     int                `json:"max_rows"` // 每一项最多导出的条数
+	Sections    []*manifestSection `json:"sections"`
+	Notes       []string           `json:"notes"`
+}
+
+// manifestSection 导出的一项数据
+type manifestSection struct {
+	File      string `json:"file"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
+	Truncated bool   `json:"truncated"`
+}
+
+// addSection 记录一项数据的条数
+func (m *manifest) addSection(file string, name string, count int) {
+	m.Sections = append(m.Sections, &manifestSection{
+		File:      file,
+		Name:      name,
+		Count:     count,
+		Truncated: m.MaxRows > 0 && count >= m.MaxRows,
+	})
+}
+
+// archiveFile 导出文件中的一个json文件
+type archiveFile struct {
+	name string
+	data interface{}
+}
+
+// writeArchive 把说明和数据写入zip 说明为第一个文件
+func writeArchive(w io.Writer, m *manifest, files []archiveFile) error {
+	zw := zip.NewWriter(w)
+	files = append([]archiveFile{{name: "manifest.json", data: m}}, files...)
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return err
+		}
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err = fw.Write(data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,53 @@
+package compliance
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestAddSection(t *testing.T) {
+	mf := &manifest{MaxRows: 2}
+	mf.addSection("contacts.json", "friends", 1)
+	mf.addSection("contacts.json", "followers", 2)
+	assert.False(t, mf.Sections[0].Truncated)
+	assert.True(t, mf.Sections[1].Truncated)
+
+	// 没有限制条数时不会截断
+	mf = &manifest{}
+	mf.addSection("contacts.json", "friends", 100)
+	assert.False(t, mf.Sections[0].Truncated)
+}
+
+func TestWriteArchive(t *testing.T) {
+	mf := &manifest{ExportNo: "e1", UID: "u1", MaxRows: 10, Notes: notes}
+	mf.addSection("profile.json", "devices", 1)
+	var buf bytes.Buffer
+	err := writeArchive(&buf, mf, []archiveFile{
+		{name: "profile.json", data: map[string]interface{}{"uid": "u1"}},
+		{name: "reports.json", data: []string{}},
+	})
+	assert.NoError(t, err)
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	names := make([]string, 0, len(reader.File))
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"manifest.json", "profile.json", "reports.json"}, names)
+
+	f, err := reader.File[0].Open()
+	assert.NoError(t, err)
+	data, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	var result manifest
+	assert.NoError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, "u1", result.UID)
+	assert.Len(t, result.Sections, 1)
+	assert.Equal(t, "devices", result.Sections[0].Name)
+}
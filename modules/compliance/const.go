@@ -0,0 +1,21 @@
+package compliance
+
+// 导出申请的状态
+const (
+	StatusPending  = 0 // 待审批
+	StatusApproved = 1 // 已通过 有效期内申请人可以下载
+	StatusRejected = 2 // 已拒绝
+)
+
+const (
+	// reasonMaxLen 申请原因和拒绝原因的最大字数
+	reasonMaxLen = 500
+	// ticketNoMaxLen 工单号的最大长度
+	ticketNoMaxLen = 100
+)
+
+// 检索到的消息的标记 被标记的文本消息返回原文
+const (
+	FlagReport    = "report"    // 被举报过
+	FlagSensitive = "sensitive" // 命中过敏感词
+)
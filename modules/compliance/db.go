@@ -0,0 +1,177 @@
+package compliance
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *db) insert(m *model) error {
+	_, err := d.session.InsertInto("compliance_export").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryWithExportNo(exportNo string) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("compliance_export").Where("export_no=?", exportNo).Load(&m)
+	return m, err
+}
+
+func (d *db) queryWithPage(filter exportFilter, pageIndex, pageSize uint64) ([]*model, error) {
+	var models []*model
+	_, err := d.filterWhere(d.session.Select("*").From("compliance_export"), filter).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryCount(filter exportFilter) (int64, error) {
+	var count int64
+	_, err := d.filterWhere(d.session.Select("count(*)").From("compliance_export"), filter).Load(&count)
+	return count, err
+}
+
+func (d *db) filterWhere(builder *dbr.SelectStmt, filter exportFilter) *dbr.SelectStmt {
+	if filter.status != "" {
+		builder = builder.Where("status=?", filter.status)
+	}
+	if filter.uid != "" {
+		builder = builder.Where("uid=?", filter.uid)
+	}
+	if filter.requester != "" {
+		builder = builder.Where("requester=?", filter.requester)
+	}
+	return builder
+}
+
+// updateApproved 审批通过 申请已被审批时返回false
+func (d *db) updateApproved(id int64, approver string, approvedAt int64, expireAt int64) (bool, error) {
+	result, err := d.session.Update("compliance_export").SetMap(map[string]interface{}{
+		"status":      StatusApproved,
+		"approver":    approver,
+		"approved_at": approvedAt,
+		"expire_at":   expireAt,
+	}).Where("id=? and status=? and requester<>?", id, StatusPending, approver).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// updateRejected 拒绝申请 申请已被审批时返回false
+func (d *db) updateRejected(id int64, approver string, rejectReason string, approvedAt int64) (bool, error) {
+	result, err := d.session.Update("compliance_export").SetMap(map[string]interface{}{
+		"status":        StatusRejected,
+		"approver":      approver,
+		"reject_reason": rejectReason,
+		"approved_at":   approvedAt,
+	}).Where("id=? and status=?", id, StatusPending).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// incrDownloadCount 记录一次下载 已过期或下载次数已用完时返回false
+func (d *db) incrDownloadCount(id int64, maxDownloads int, now int64) (bool, error) {
+	result, err := d.session.UpdateBySql("update compliance_export set download_count=download_count+1,last_download_at=? where id=? and status=? and expire_at>? and download_count<?", now, id, StatusApproved, now, maxDownloads).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+type exportFilter struct {
+	status    string
+	uid       string
+	requester string
+}
+
+type model struct {
+	ExportNo       string
+	UID            string
+	Reason         string
+	TicketNo       string
+	Requester      string
+	Approver       string
+	Status         int
+	RejectReason   string
+	ApprovedAt     int64
+	ExpireAt       int64
+	DownloadCount  int
+	LastDownloadAt int64
+	dba.BaseModel
+}
+
+func (d *db) insertSearch(m *searchModel) error {
+	_, err := d.session.InsertInto("compliance_search").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+// updateSearchResult 记录检索返回的消息数
+func (d *db) updateSearchResult(searchNo string, resultCount int, flaggedCount int) error {
+	_, err := d.session.Update("compliance_search").SetMap(map[string]interface{}{
+		"result_count":  resultCount,
+		"flagged_count": flaggedCount,
+	}).Where("search_no=?", searchNo).Exec()
+	return err
+}
+
+func (d *db) querySearchWithPage(filter searchFilter, pageIndex, pageSize uint64) ([]*searchModel, error) {
+	var models []*searchModel
+	_, err := d.searchWhere(d.session.Select("*").From("compliance_search"), filter).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) querySearchCount(filter searchFilter) (int64, error) {
+	var count int64
+	_, err := d.searchWhere(d.session.Select("count(*)").From("compliance_search"), filter).Load(&count)
+	return count, err
+}
+
+func (d *db) searchWhere(builder *dbr.SelectStmt, filter searchFilter) *dbr.SelectStmt {
+	if filter.operator != "" {
+		builder = builder.Where("operator=?", filter.operator)
+	}
+	if filter.uid != "" {
+		builder = builder.Where("uid=?", filter.uid)
+	}
+	if filter.groupNo != "" {
+		builder = builder.Where("group_no=?", filter.groupNo)
+	}
+	return builder
+}
+
+type searchFilter struct {
+	operator string
+	uid      string
+	groupNo  string
+}
+
+type searchModel struct {
+	SearchNo     string
+	Operator     string
+	UID          string
+	GroupNo      string
+	Reason       string
+	TicketNo     string
+	StartAt      int64
+	EndAt        int64
+	ResultCount  int
+	FlaggedCount int
+	dba.BaseModel
+}
@@ -0,0 +1,23 @@
+-- +migrate Up
+
+-- 用户数据的合规导出申请 需要申请人以外的管理员审批后才能下载
+create table `compliance_export`
+(
+  id               bigint         not null primary key AUTO_INCREMENT,
+  export_no        VARCHAR(40)    not null default '',  -- 申请编号
+  uid              VARCHAR(40)    not null default '',  -- 导出数据的用户
+  reason           VARCHAR(1000)  not null default '',  -- 申请原因
+  ticket_no        VARCHAR(100)   not null default '',  -- 关联的法务工单号
+  requester        VARCHAR(40)    not null default '',  -- 申请人
+  approver         VARCHAR(40)    not null default '',  -- 审批人
+  status           smallint       not null default 0,   -- 状态 0.待审批 1.已通过 2.已拒绝
+  reject_reason    VARCHAR(1000)  not null default '',  -- 拒绝原因
+  approved_at      bigint         not null default 0,   -- 审批时间
+  expire_at        bigint         not null default 0,   -- 下载的截止时间
+  download_count   int            not null default 0,   -- 已下载的次数
+  last_download_at bigint         not null default 0,   -- 最后一次下载的时间
+  created_at       timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at       timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `compliance_export_no_idx` on `compliance_export` (`export_no`);
+CREATE INDEX `compliance_export_uid_idx` on `compliance_export` (`uid`);
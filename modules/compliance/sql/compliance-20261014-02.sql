@@ -0,0 +1,23 @@
+-- +migrate Up
+
+-- 消息检索（合规调查）的记录 每次检索都需要填写原因
+create table `compliance_search`
+(
+  id            bigint         not null primary key AUTO_INCREMENT,
+  search_no     VARCHAR(40)    not null default '',  -- 检索编号
+  operator      VARCHAR(40)    not null default '',  -- 检索的管理员
+  uid           VARCHAR(40)    not null default '',  -- 检索的用户
+  group_no      VARCHAR(40)    not null default '',  -- 检索的群
+  reason        VARCHAR(1000)  not null default '',  -- 检索原因
+  ticket_no     VARCHAR(100)   not null default '',  -- 关联的法务工单号
+  start_at      bigint         not null default 0,   -- 消息时间的开始（秒）
+  end_at        bigint         not null default 0,   -- 消息时间的结束（秒）
+  result_count  int            not null default 0,   -- 返回的消息数
+  flagged_count int            not null default 0,   -- 返回原文的消息数（被举报或命中敏感词）
+  created_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `compliance_search_no_idx` on `compliance_search` (`search_no`);
+CREATE INDEX `compliance_search_operator_idx` on `compliance_search` (`operator`);
+CREATE INDEX `compliance_search_uid_idx` on `compliance_search` (`uid`);
+CREATE INDEX `compliance_search_group_no_idx` on `compliance_search` (`group_no`);
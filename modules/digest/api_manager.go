@@ -0,0 +1,447 @@
+package digest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	commonapi "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/report"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/sensitive"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/statistics"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/metrics"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Manager 定时报告 每天或每周把运营数据、待处理的举报和错误数发送邮件或发到管理员群
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db                *db
+	statisticsService statistics.IService
+	reportService     report.IService
+	sensitiveService  sensitive.IService
+	groupService      group.IService
+	running           atomic.Bool // 是否正在发送报告
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	m := &Manager{
+		ctx:               ctx,
+		Log:               log.NewTLog("DigestManager"),
+		db:                newDB(ctx),
+		statisticsService: statistics.NewService(ctx),
+		reportService:     report.NewService(ctx),
+		sensitiveService:  sensitive.NewService(ctx),
+		groupService:      group.NewService(ctx),
+	}
+	m.ctx.Schedule(extconfig.Get().Digest.CheckInterval, m.runJob)
+	return m
+}
+
+// Route 路由配置
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/digests", m.list)                          // 报告列表
+		auth.POST("/digests", m.add)                          // 添加报告
+		auth.PUT("/digests/:schedule_no", m.update)           // 修改报告
+		auth.DELETE("/digests/:schedule_no", m.delete)        // 删除报告 发送记录保留
+		auth.POST("/digests/:schedule_no/send", m.sendNow)    // 立即发送一次 不影响定时发送
+		auth.POST("/digests/:schedule_no/preview", m.preview) // 预览报告 不发送
+		auth.GET("/digests/logs", m.logs)                     // 发送记录
+	}
+}
+
+type scheduleReq struct {
+	Name       string   `json:"name"`       // 报告名称
+	Period     string   `json:"period"`     // 周期 daily.每天 weekly.每周
+	Weekday    int      `json:"weekday"`    // 每周发送的星期 0.星期日 1-6.星期一至星期六
+	Hour       int      `json:"hour"`       // 发送的时间（小时 0-23）
+	Channel    string   `json:"channel"`    // 发送方式 email.邮件 group.管理员群
+	Recipients []string `json:"recipients"` // 接收邮件的邮箱
+	GroupNo    string   `json:"group_no"`   // 接收报告的群
+	Sections   []string `json:"sections"`   // 报告的内容 metrics.运营数据 moderation.待处理 errors.错误数
+	Subject    string   `json:"subject"`    // 标题模版 为空时使用默认模版
+	Template   string   `json:"template"`   // 正文模版 为空时使用默认模版
+	Status     int      `json:"status"`     // 0.停用 1.启用
+}
+
+func (r *scheduleReq) check() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.GroupNo = strings.TrimSpace(r.GroupNo)
+	if r.Name == "" {
+		return errors.New("报告名称不能为空")
+	}
+	if len([]rune(r.Name)) > nameMaxLen {
+		return fmt.Errorf("报告名称不能超过%d个字", nameMaxLen)
+	}
+	if _, ok := periods[r.Period]; !ok {
+		return errors.New("报告周期有误")
+	}
+	if r.Period == PeriodWeekly && (r.Weekday < 0 || r.Weekday > 6) {
+		return errors.New("发送的星期有误")
+	}
+	if r.Period == PeriodDaily {
+		r.Weekday = 0
+	}
+	if r.Hour < 0 || r.Hour > 23 {
+		return errors.New("发送的时间需要在0到23点之间")
+	}
+	if !channels[r.Channel] {
+		return errors.New("发送方式有误")
+	}
+	if err := r.checkRecipients(); err != nil {
+		return err
+	}
+	if len(r.Sections) == 0 {
+		return errors.New("报告的内容不能为空")
+	}
+	for _, section := range r.Sections {
+		if !sections[section] {
+			return fmt.Errorf("报告的内容有误：%s", section)
+		}
+	}
+	if len([]rune(r.Subject)) > subjectMaxLen {
+		return fmt.Errorf("标题模版不能超过%d个字", subjectMaxLen)
+	}
+	if len([]rune(r.Template)) > templateMaxLen {
+		return fmt.Errorf("正文模版不能超过%d个字", templateMaxLen)
+	}
+	if r.Status != StatusDisabled && r.Status != StatusEnabled {
+		return errors.New("报告状态有误")
+	}
+	return nil
+}
+
+// checkRecipients 邮件需要接收邮箱 发到群时需要群编号
+func (r *scheduleReq) checkRecipients() error {
+	if r.Channel == ChannelGroup {
+		r.Recipients = nil
+		if r.GroupNo == "" {
+			return errors.New("接收报告的群不能为空")
+		}
+		return nil
+	}
+	r.GroupNo = ""
+	recipients := make([]string, 0, len(r.Recipients))
+	for _, recipient := range r.Recipients {
+		recipient = commonapi.NormalizeEmail(recipient)
+		if err := commonapi.CheckEmail(recipient); err != nil {
+			return err
+		}
+		recipients = append(recipients, recipient)
+	}
+	if len(recipients) == 0 {
+		return errors.New("接收邮件的邮箱不能为空")
+	}
+	if len(recipients) > recipientMaxCount {
+		return fmt.Errorf("接收邮件的邮箱不能超过%d个", recipientMaxCount)
+	}
+	r.Recipients = recipients
+	return nil
+}
+
+// checkScheduleReq 检查请求 发到群时群需要存在
+func (m *Manager) checkScheduleReq(req *scheduleReq) error {
+	if err := req.check(); err != nil {
+		return err
+	}
+	if req.Channel != ChannelGroup {
+		return nil
+	}
+	if _, err := m.groupService.GetGroupWithGroupNo(req.GroupNo); err != nil {
+		m.Warn("查询接收报告的群失败！", zap.Error(err), zap.String("groupNo", req.GroupNo))
+		return errors.New("接收报告的群不存在")
+	}
+	return nil
+}
+
+// toModel 按请求修改报告 重新计算下次发送的时间
+func (r *scheduleReq) toModel(m *model, now time.Time) {
+	m.Name = r.Name
+	m.Period = r.Period
+	m.Weekday = r.Weekday
+	m.Hour = r.Hour
+	m.Channel = r.Channel
+	m.Recipients = strings.Join(r.Recipients, ",")
+	m.GroupNo = r.GroupNo
+	m.Sections = strings.Join(r.Sections, ",")
+	m.Subject = strings.TrimSpace(r.Subject)
+	m.Template = r.Template
+	m.Status = r.Status
+	m.NextRunAt = nextRunAt(r.Period, r.Weekday, r.Hour, now).Unix()
+}
+
+// 报告列表
+func (m *Manager) list(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := m.db.queryAll()
+	if err != nil {
+		m.Error("查询报告失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询报告失败！"))
+		return
+	}
+	list := make([]*scheduleResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newScheduleResp(model))
+	}
+	c.Response(list)
+}
+
+// 添加报告
+func (m *Manager) add(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req scheduleReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if err := m.checkScheduleReq(&req); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	schedule := &model{
+		ScheduleNo: util.GenerUUID(),
+		Creator:    c.GetLoginUID(),
+	}
+	// 第一次发送时统计从现在开始的错误数
+	schedule.HTTPErrorTotal, schedule.IMErrorTotal = metrics.ErrorTotals()
+	req.toModel(schedule, time.Now())
+	if err := m.db.insert(schedule); err != nil {
+		m.Error("添加报告失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加报告失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("schedule_no=%s", schedule.ScheduleNo), nil, req)
+	c.Response(map[string]interface{}{
+		"schedule_no": schedule.ScheduleNo,
+	})
+}
+
+// 修改报告
+func (m *Manager) update(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req scheduleReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if err := m.checkScheduleReq(&req); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	schedule, err := m.querySchedule(c.Param("schedule_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	before := newScheduleResp(schedule)
+	req.toModel(schedule, time.Now())
+	if err = m.db.update(schedule); err != nil {
+		m.Error("修改报告失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改报告失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("schedule_no=%s", schedule.ScheduleNo), before, req)
+	c.ResponseOK()
+}
+
+// 删除报告
+func (m *Manager) delete(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	schedule, err := m.querySchedule(c.Param("schedule_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err = m.db.delete(schedule.ScheduleNo); err != nil {
+		m.Error("删除报告失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除报告失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("schedule_no=%s", schedule.ScheduleNo), newScheduleResp(schedule), nil)
+	c.ResponseOK()
+}
+
+// 立即发送一次 用于检查模版和接收人 统计截止到昨天
+func (m *Manager) sendNow(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	schedule, err := m.querySchedule(c.Param("schedule_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	logM, err := m.send(schedule, time.Now(), true)
+	if logM == nil {
+		m.Error("生成报告失败！", zap.Error(err), zap.String("scheduleNo", schedule.ScheduleNo))
+		c.ResponseError(errors.New("生成报告失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("schedule_no=%s", schedule.ScheduleNo), nil, map[string]interface{}{"status": logM.Status})
+	if err != nil {
+		c.ResponseError(fmt.Errorf("发送报告失败！%s", logM.Error))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 预览报告 使用当前的数据生成 不发送
+func (m *Manager) preview(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	schedule, err := m.querySchedule(c.Param("schedule_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	data, err := m.collect(schedule, time.Now())
+	if err != nil {
+		m.Error("生成报告失败！", zap.Error(err), zap.String("scheduleNo", schedule.ScheduleNo))
+		c.ResponseError(errors.New("生成报告失败！"))
+		return
+	}
+	subject, content := render(schedule.Subject, schedule.Template, splitValues(schedule.Sections), data)
+	c.Response(map[string]interface{}{
+		"subject": subject,
+		"content": content,
+	})
+}
+
+// 发送记录 可按报告查询
+func (m *Manager) logs(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	scheduleNo := c.Query("schedule_no")
+	models, err := m.db.queryLogsWithPage(scheduleNo, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询报告的发送记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询报告的发送记录失败！"))
+		return
+	}
+	count, err := m.db.queryLogCount(scheduleNo)
+	if err != nil {
+		m.Error("查询报告的发送记录数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询报告的发送记录数量失败！"))
+		return
+	}
+	list := make([]*logResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newLogResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+func (m *Manager) querySchedule(scheduleNo string) (*model, error) {
+	schedule, err := m.db.queryWithScheduleNo(scheduleNo)
+	if err != nil {
+		m.Error("查询报告失败！", zap.Error(err))
+		return nil, errors.New("查询报告失败！")
+	}
+	if schedule == nil {
+		return nil, errors.New("报告不存在")
+	}
+	return schedule, nil
+}
+
+type scheduleResp struct {
+	ScheduleNo string   `json:"schedule_no"`
+	Name       string   `json:"name"`
+	Period     string   `json:"period"`
+	Weekday    int      `json:"weekday"`
+	Hour       int      `json:"hour"`
+	Channel    string   `json:"channel"`
+	Recipients []string `json:"recipients"`
+	GroupNo    string   `json:"group_no"`
+	Sections   []string `json:"sections"`
+	Subject    string   `json:"subject"`
+	Template   string   `json:"template"`
+	Status     int      `json:"status"`
+	Creator    string   `json:"creator"`
+	NextRunAt  int64    `json:"next_run_at"`
+	LastRunAt  int64    `json:"last_run_at"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+func newScheduleResp(m *model) *scheduleResp {
+	return &scheduleResp{
+		ScheduleNo: m.ScheduleNo,
+		Name:       m.Name,
+		Period:     m.Period,
+		Weekday:    m.Weekday,
+		Hour:       m.Hour,
+		Channel:    m.Channel,
+		Recipients: splitValues(m.Recipients),
+		GroupNo:    m.GroupNo,
+		Sections:   splitValues(m.Sections),
+		Subject:    m.Subject,
+		Template:   m.Template,
+		Status:     m.Status,
+		Creator:    m.Creator,
+		NextRunAt:  m.NextRunAt,
+		LastRunAt:  m.LastRunAt,
+		CreatedAt:  m.CreatedAt.String(),
+	}
+}
+
+type logResp struct {
+	ScheduleNo string `json:"schedule_no"`
+	StartDate  string `json:"start_date"`
+	EndDate    string `json:"end_date"`
+	Channel    string `json:"channel"`
+	Subject    string `json:"subject"`
+	Content    string `json:"content"`
+	Manual     int    `json:"manual"` // 是否为手动发送 1.是
+	Status     int    `json:"status"` // 0.失败 1.成功
+	Error      string `json:"error"`
+	CreatedAt  string `json:"created_at"`
+}
+
+func newLogResp(m *logModel) *logResp {
+	return &logResp{
+		ScheduleNo: m.ScheduleNo,
+		StartDate:  m.StartDate,
+		EndDate:    m.EndDate,
+		Channel:    m.Channel,
+		Subject:    m.Subject,
+		Content:    m.Content,
+		Manual:     m.Manual,
+		Status:     m.Status,
+		Error:      m.Error,
+		CreatedAt:  m.CreatedAt.String(),
+	}
+}
@@ -0,0 +1,72 @@
+package digest
+
+// 报告的周期
+const (
+	PeriodDaily  = "daily"  // 每天 统计前一天
+	PeriodWeekly = "weekly" // 每周 统计前七天
+)
+
+// 报告的发送方式
+const (
+	ChannelEmail = "email" // 发送邮件
+	ChannelGroup = "group" // 以系统账号发到管理员群
+)
+
+// 报告的内容
+const (
+	SectionMetrics    = "metrics"    // 运营数据
+	SectionModeration = "moderation" // 待处理的举报和待审核的敏感词
+	SectionErrors     = "errors"     // 接口5xx和调用IM失败的次数
+)
+
+// 报告的状态
+const (
+	StatusDisabled = 0 // 停用
+	StatusEnabled  = 1 // 启用
+)
+
+// 发送记录的状态
+const (
+	LogStatusFailed  = 0 // 失败
+	LogStatusSuccess = 1 // 成功
+)
+
+const (
+	// nameMaxLen 报告名称的最大字数
+	nameMaxLen = 100
+	// subjectMaxLen 标题模版的最大字数
+	subjectMaxLen = 200
+	// templateMaxLen 正文模版的最大字数
+	templateMaxLen = 5000
+	// recipientMaxCount 最多的接收邮箱数
+	recipientMaxCount = 20
+	// dueBatchSize 每次检查最多发送的报告数
+	dueBatchSize = 10
+)
+
+// 默认的模版 模版中可以使用的变量见 templateVars
+const (
+	defaultSubject  = "{appName}{periodName}（{startDate} ~ {endDate}）"
+	defaultTemplate = "{appName}{periodName}（{startDate} ~ {endDate}）\n\n{metrics}\n\n{moderation}\n\n{errors}"
+)
+
+var periods = map[string]int{
+	PeriodDaily:  1,
+	PeriodWeekly: 7,
+}
+
+var periodNames = map[string]string{
+	PeriodDaily:  "日报",
+	PeriodWeekly: "周报",
+}
+
+var channels = map[string]bool{
+	ChannelEmail: true,
+	ChannelGroup: true,
+}
+
+var sections = map[string]bool{
+	SectionMetrics:    true,
+	SectionModeration: true,
+	SectionErrors:     true,
+}
@@ -0,0 +1,149 @@
+package digest
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *db) insert(m *model) error {
+	_, err := d.session.InsertInto("digest_schedule").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) update(m *model) error {
+	_, err := d.session.Update("digest_schedule").SetMap(map[string]interface{}{
+		"name":        m.Name,
+		"period":      m.Period,
+		"weekday":     m.Weekday,
+		"hour":        m.Hour,
+		"channel":     m.Channel,
+		"recipients":  m.Recipients,
+		"group_no":    m.GroupNo,
+		"sections":    m.Sections,
+		"subject":     m.Subject,
+		"template":    m.Template,
+		"status":      m.Status,
+		"next_run_at": m.NextRunAt,
+	}).Where("schedule_no=?", m.ScheduleNo).Exec()
+	return err
+}
+
+func (d *db) delete(scheduleNo string) error {
+	_, err := d.session.DeleteFrom("digest_schedule").Where("schedule_no=?", scheduleNo).Exec()
+	return err
+}
+
+func (d *db) queryWithScheduleNo(scheduleNo string) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("digest_schedule").Where("schedule_no=?", scheduleNo).Load(&m)
+	return m, err
+}
+
+func (d *db) queryAll() ([]*model, error) {
+	var models []*model
+	_, err := d.session.Select("*").From("digest_schedule").OrderDir("id", true).Load(&models)
+	return models, err
+}
+
+// queryDue 到发送时间的报告
+func (d *db) queryDue(now int64, limit uint64) ([]*model, error) {
+	var models []*model
+	_, err := d.session.Select("*").From("digest_schedule").Where("status=? and next_run_at<=?", StatusEnabled, now).OrderDir("next_run_at", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+// updateNextRun 领取这次发送 已被其他实例领取或报告已修改时返回false
+func (d *db) updateNextRun(id int64, nextRunAt int64, newNextRunAt int64, now int64) (bool, error) {
+	result, err := d.session.Update("digest_schedule").SetMap(map[string]interface{}{
+		"next_run_at": newNextRunAt,
+		"last_run_at": now,
+	}).Where("id=? and status=? and next_run_at=?", id, StatusEnabled, nextRunAt).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// updateErrorSnapshot 记录发送时的错误总数和本周期的错误数
+func (d *db) updateErrorSnapshot(id int64, httpErrorTotal int64, imErrorTotal int64, errorCount int64) error {
+	_, err := d.session.Update("digest_schedule").SetMap(map[string]interface{}{
+		"http_error_total": httpErrorTotal,
+		"im_error_total":   imErrorTotal,
+		"last_error_count": errorCount,
+	}).Where("id=?", id).Exec()
+	return err
+}
+
+func (d *db) insertLog(m *logModel) error {
+	_, err := d.session.InsertInto("digest_log").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryLogsWithPage(scheduleNo string, pageIndex, pageSize uint64) ([]*logModel, error) {
+	var models []*logModel
+	_, err := d.logWhere(d.session.Select("*").From("digest_log"), scheduleNo).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryLogCount(scheduleNo string) (int64, error) {
+	var count int64
+	_, err := d.logWhere(d.session.Select("count(*)").From("digest_log"), scheduleNo).Load(&count)
+	return count, err
+}
+
+func (d *db) logWhere(builder *dbr.SelectStmt, scheduleNo string) *dbr.SelectStmt {
+	if scheduleNo != "" {
+		builder = builder.Where("schedule_no=?", scheduleNo)
+	}
+	return builder
+}
+
+type model struct {
+	ScheduleNo     string
+	Name           string
+	Period         string
+	Weekday        int
+	Hour           int
+	Channel        string
+	Recipients     string
+	GroupNo        string
+	Sections       string
+	Subject        string
+	Template       string
+	Status         int
+	Creator        string
+	NextRunAt      int64
+	LastRunAt      int64
+	HTTPErrorTotal int64
+	IMErrorTotal   int64
+	LastErrorCount int64
+	dba.BaseModel
+}
+
+type logModel struct {
+	ScheduleNo string
+	StartDate  string
+	EndDate    string
+	Channel    string
+	Subject    string
+	Content    string
+	Manual     int
+	Status     int
+	Error      string
+	dba.BaseModel
+}
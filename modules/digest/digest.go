@@ -0,0 +1,178 @@
+package digest
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/report"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/statistics"
+)
+
+// dateLayout 统计的日期格式
+const dateLayout = "2006-01-02"
+
+// nextRunAt now之后的第一个发送时间 每周的报告在weekday的hour点发送
+func nextRunAt(period string, weekday int, hour int, now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	days := 1
+	if period == PeriodWeekly {
+		days = 7
+		next = next.AddDate(0, 0, (weekday-int(next.Weekday())+7)%7)
+	}
+	if !next.After(now) {
+		next = next.AddDate(0, 0, days)
+	}
+	return next
+}
+
+// reportDates 报告统计的日期范围（包含起止日期） 发送当天之前的一天或七天
+func reportDates(period string, runAt time.Time) (string, string) {
+	end := time.Date(runAt.Year(), runAt.Month(), runAt.Day(), 0, 0, 0, 0, runAt.Location()).AddDate(0, 0, -1)
+	start := end.AddDate(0, 0, -(periods[period] - 1))
+	return start.Format(dateLayout), end.Format(dateLayout)
+}
+
+// errorDelta 本周期的错误数 实例重启后计数从0开始 小于上次的总数时使用重启后的总数
+func errorDelta(current, last int64) int64 {
+	if current < last {
+		return current
+	}
+	return current - last
+}
+
+// isSpike 错误数达到min且为上一周期的2倍以上
+func isSpike(count, lastCount, min int64) bool {
+	return count >= min && count >= lastCount*2
+}
+
+// reportData 报告的数据 没有选择的内容为空
+type reportData struct {
+	AppName    string
+	Period     string
+	StartDate  string
+	EndDate    string
+	Metrics    *statistics.DailyTotal
+	Reports    *report.Backlog
+	PendingHit int64
+	HTTPErrors int64 // 本周期接口返回5xx的次数
+	IMErrors   int64 // 本周期调用IM失败的次数
+	LastErrors int64 // 上一周期的错误数
+	Spike      bool
+}
+
+func (r *reportData) errorCount() int64 {
+	return r.HTTPErrors + r.IMErrors
+}
+
+func (r *reportData) metricsText() string {
+	if r.Metrics == nil {
+		return ""
+	}
+	m := r.Metrics
+	lines := []string{
+		"【运营数据】",
+		fmt.Sprintf("注册用户：%d", m.RegisterCount),
+		fmt.Sprintf("发送消息：%d", m.MessageCount),
+		fmt.Sprintf("新建群：%d", m.GroupCreatedCount),
+		fmt.Sprintf("最高日活：%d 平均日活：%d", m.MaxActiveCount, m.AvgActiveCount),
+		fmt.Sprintf("用户总数：%d 群总数：%d", m.UserTotalCount, m.GroupTotalCount),
+	}
+	if m.RolledDays < m.Days {
+		lines = append(lines, fmt.Sprintf("（%d天中有%d天还没有汇总）", m.Days, m.Days-m.RolledDays))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *reportData) moderationText() string {
+	if r.Reports == nil {
+		return ""
+	}
+	return strings.Join([]string{
+		"【待处理】",
+		fmt.Sprintf("待处理的举报：%d", r.Reports.Pending),
+		fmt.Sprintf("处理中的举报：%d", r.Reports.Reviewing),
+		fmt.Sprintf("待审核的敏感词命中：%d", r.PendingHit),
+	}, "\n")
+}
+
+func (r *reportData) errorsText(enabled bool) string {
+	if !enabled {
+		return ""
+	}
+	lines := []string{
+		"【错误】",
+		fmt.Sprintf("接口错误（5xx）：%d", r.HTTPErrors),
+		fmt.Sprintf("调用IM失败：%d", r.IMErrors),
+		fmt.Sprintf("上一周期：%d", r.LastErrors),
+	}
+	if r.Spike {
+		lines = append(lines, "错误数激增，请及时排查！")
+	}
+	lines = append(lines, "（只统计发送报告的实例）")
+	return strings.Join(lines, "\n")
+}
+
+// templateVars 模版中可以使用的变量
+func (r *reportData) templateVars(sectionList []string) []string {
+	enabled := make(map[string]bool, len(sectionList))
+	for _, section := range sectionList {
+		enabled[section] = true
+	}
+	var metrics, moderation string
+	if enabled[SectionMetrics] {
+		metrics = r.metricsText()
+	}
+	if enabled[SectionModeration] {
+		moderation = r.moderationText()
+	}
+	return []string{
+		"{appName}", r.AppName,
+		"{periodName}", periodNames[r.Period],
+		"{startDate}", r.StartDate,
+		"{endDate}", r.EndDate,
+		"{metrics}", metrics,
+		"{moderation}", moderation,
+		"{errors}", r.errorsText(enabled[SectionErrors]),
+		"{errorCount}", fmt.Sprintf("%d", r.errorCount()),
+	}
+}
+
+// render 用模版生成报告的标题和正文 模版为空时使用默认模版
+func render(subjectTpl, tpl string, sectionList []string, data *reportData) (string, string) {
+	if strings.TrimSpace(subjectTpl) == "" {
+		subjectTpl = defaultSubject
+	}
+	if strings.TrimSpace(tpl) == "" {
+		tpl = defaultTemplate
+	}
+	replacer := strings.NewReplacer(data.templateVars(sectionList)...)
+	return replacer.Replace(subjectTpl), compactLines(replacer.Replace(tpl))
+}
+
+// compactLines 去掉没有选择的内容留下的多余空行
+func compactLines(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+	return strings.TrimSpace(text)
+}
+
+// emailBody 邮件正文为HTML 转义后保留换行
+func emailBody(content string) string {
+	return strings.ReplaceAll(html.EscapeString(content), "\n", "<br>")
+}
+
+// splitValues 逗号分隔的值 去掉空格和空值
+func splitValues(s string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,113 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/report"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/statistics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextRunAt(t *testing.T) {
+	// 2026-10-14 是星期三
+	now := time.Date(2026, 10, 14, 10, 30, 0, 0, time.Local)
+
+	assert.Equal(t, time.Date(2026, 10, 15, 9, 0, 0, 0, time.Local), nextRunAt(PeriodDaily, 0, 9, now))
+	assert.Equal(t, time.Date(2026, 10, 14, 11, 0, 0, 0, time.Local), nextRunAt(PeriodDaily, 0, 11, now))
+
+	// 每周一9点
+	assert.Equal(t, time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local), nextRunAt(PeriodWeekly, 1, 9, now))
+	// 当天已过发送时间 下周发送
+	assert.Equal(t, time.Date(2026, 10, 21, 9, 0, 0, 0, time.Local), nextRunAt(PeriodWeekly, 3, 9, now))
+	assert.Equal(t, time.Date(2026, 10, 14, 11, 0, 0, 0, time.Local), nextRunAt(PeriodWeekly, 3, 11, now))
+}
+
+func TestReportDates(t *testing.T) {
+	runAt := time.Date(2026, 10, 14, 9, 0, 0, 0, time.Local)
+	start, end := reportDates(PeriodDaily, runAt)
+	assert.Equal(t, "2026-10-13", start)
+	assert.Equal(t, "2026-10-13", end)
+
+	start, end = reportDates(PeriodWeekly, runAt)
+	assert.Equal(t, "2026-10-07", start)
+	assert.Equal(t, "2026-10-13", end)
+}
+
+func TestErrorDelta(t *testing.T) {
+	assert.Equal(t, int64(5), errorDelta(15, 10))
+	// 实例重启后计数从0开始
+	assert.Equal(t, int64(3), errorDelta(3, 10))
+
+	assert.True(t, isSpike(200, 50, 100))
+	assert.False(t, isSpike(200, 150, 100))
+	assert.False(t, isSpike(80, 0, 100))
+}
+
+func TestRender(t *testing.T) {
+	data := &reportData{
+		AppName:    "唐僧叨叨",
+		Period:     PeriodDaily,
+		StartDate:  "2026-10-13",
+		EndDate:    "2026-10-13",
+		Metrics:    &statistics.DailyTotal{RegisterCount: 12, Days: 1, RolledDays: 1},
+		Reports:    &report.Backlog{Pending: 3, Reviewing: 1},
+		PendingHit: 7,
+		HTTPErrors: 150,
+		IMErrors:   10,
+		Spike:      true,
+	}
+	subject, content := render("", "", []string{SectionMetrics, SectionErrors}, data)
+	assert.Equal(t, "唐僧叨叨日报（2026-10-13 ~ 2026-10-13）", subject)
+	assert.Contains(t, content, "注册用户：12")
+	assert.Contains(t, content, "接口错误（5xx）：150")
+	assert.Contains(t, content, "错误数激增")
+	// 没有选择的内容不显示 也不留下多余的空行
+	assert.NotContains(t, content, "待处理的举报")
+	assert.NotContains(t, content, "\n\n\n")
+
+	subject, content = render("{periodName} {errorCount}", "待审核：{moderation}", []string{SectionModeration}, data)
+	assert.Equal(t, "日报 160", subject)
+	assert.True(t, strings.HasPrefix(content, "待审核：【待处理】"))
+	assert.Contains(t, content, "待审核的敏感词命中：7")
+}
+
+func TestScheduleReqCheck(t *testing.T) {
+	req := &scheduleReq{
+		Name:       "运营日报",
+		Period:     PeriodDaily,
+		Weekday:    3,
+		Hour:       9,
+		Channel:    ChannelEmail,
+		Recipients: []string{" Admin@Example.com "},
+		GroupNo:    "g1",
+		Sections:   []string{SectionMetrics},
+		Status:     StatusEnabled,
+	}
+	assert.NoError(t, req.check())
+	assert.Equal(t, []string{"admin@example.com"}, req.Recipients)
+	// 每天发送时不使用星期 发邮件时不使用群
+	assert.Equal(t, 0, req.Weekday)
+	assert.Equal(t, "", req.GroupNo)
+
+	bad := *req
+	bad.Recipients = []string{"not-email"}
+	assert.Error(t, bad.check())
+
+	bad = *req
+	bad.Sections = []string{"unknown"}
+	assert.Error(t, bad.check())
+
+	bad = *req
+	bad.Hour = 24
+	assert.Error(t, bad.check())
+
+	groupReq := *req
+	groupReq.Channel = ChannelGroup
+	groupReq.GroupNo = ""
+	assert.Error(t, groupReq.check())
+	groupReq.GroupNo = "g1"
+	assert.NoError(t, groupReq.check())
+	assert.Empty(t, groupReq.Recipients)
+}
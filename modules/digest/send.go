@@ -0,0 +1,159 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	commonapi "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/metrics"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// runJob 发送到时间的报告 先领取再发送 多个实例时只有一个实例发送
+// 服务停止期间错过的报告只补发一次 下次发送时间从现在开始计算
+func (m *Manager) runJob() {
+	if !m.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer m.running.Store(false)
+
+	now := time.Now()
+	schedules, err := m.db.queryDue(now.Unix(), dueBatchSize)
+	if err != nil {
+		m.Error("查询需要发送的报告失败！", zap.Error(err))
+		return
+	}
+	for _, schedule := range schedules {
+		next := nextRunAt(schedule.Period, schedule.Weekday, schedule.Hour, now).Unix()
+		ok, err := m.db.updateNextRun(schedule.Id, schedule.NextRunAt, next, now.Unix())
+		if err != nil {
+			m.Error("领取报告失败！", zap.Error(err), zap.String("scheduleNo", schedule.ScheduleNo))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if _, err = m.send(schedule, time.Unix(schedule.NextRunAt, 0), false); err != nil {
+			m.Warn("发送报告失败！", zap.Error(err), zap.String("scheduleNo", schedule.ScheduleNo))
+		}
+	}
+}
+
+// send 生成并发送报告 手动发送时不更新错误数的统计 不影响下次定时发送
+func (m *Manager) send(schedule *model, runAt time.Time, manual bool) (*logModel, error) {
+	data, err := m.collect(schedule, runAt)
+	if err != nil {
+		return nil, err
+	}
+	subject, content := render(schedule.Subject, schedule.Template, splitValues(schedule.Sections), data)
+	sendErr := m.deliver(schedule, subject, content)
+	logM := &logModel{
+		ScheduleNo: schedule.ScheduleNo,
+		StartDate:  data.StartDate,
+		EndDate:    data.EndDate,
+		Channel:    schedule.Channel,
+		Subject:    subject,
+		Content:    content,
+		Status:     LogStatusSuccess,
+	}
+	if manual {
+		logM.Manual = 1
+	}
+	if sendErr != nil {
+		logM.Status = LogStatusFailed
+		logM.Error = truncate(sendErr.Error(), 255)
+	}
+	if err = m.db.insertLog(logM); err != nil {
+		m.Warn("保存报告的发送记录失败！", zap.Error(err), zap.String("scheduleNo", schedule.ScheduleNo))
+	}
+	if !manual {
+		httpErrorTotal, imErrorTotal := metrics.ErrorTotals()
+		if err = m.db.updateErrorSnapshot(schedule.Id, httpErrorTotal, imErrorTotal, data.errorCount()); err != nil {
+			m.Warn("记录报告的错误数失败！", zap.Error(err), zap.String("scheduleNo", schedule.ScheduleNo))
+		}
+	}
+	if sendErr != nil {
+		return logM, sendErr
+	}
+	m.Info("发送报告成功", zap.String("scheduleNo", schedule.ScheduleNo), zap.String("channel", schedule.Channel), zap.Bool("manual", manual))
+	return logM, nil
+}
+
+// collect 查询报告需要的数据 只查询选择的内容
+func (m *Manager) collect(schedule *model, runAt time.Time) (*reportData, error) {
+	startDate, endDate := reportDates(schedule.Period, runAt)
+	data := &reportData{
+		AppName:   m.ctx.GetConfig().AppName,
+		Period:    schedule.Period,
+		StartDate: startDate,
+		EndDate:   endDate,
+	}
+	var err error
+	for _, section := range splitValues(schedule.Sections) {
+		switch section {
+		case SectionMetrics:
+			if data.Metrics, err = m.statisticsService.GetDailyTotal(startDate, endDate); err != nil {
+				return nil, err
+			}
+		case SectionModeration:
+			if data.Reports, err = m.reportService.GetBacklog(); err != nil {
+				return nil, err
+			}
+			if data.PendingHit, err = m.sensitiveService.GetPendingHitCount(); err != nil {
+				return nil, err
+			}
+		case SectionErrors:
+			httpErrorTotal, imErrorTotal := metrics.ErrorTotals()
+			data.HTTPErrors = errorDelta(httpErrorTotal, schedule.HTTPErrorTotal)
+			data.IMErrors = errorDelta(imErrorTotal, schedule.IMErrorTotal)
+			data.LastErrors = schedule.LastErrorCount
+			data.Spike = isSpike(data.errorCount(), schedule.LastErrorCount, extconfig.Get().Digest.ErrorSpikeMin)
+		}
+	}
+	return data, nil
+}
+
+// deliver 发送邮件或以系统账号发到管理员群
+func (m *Manager) deliver(schedule *model, subject, content string) error {
+	switch schedule.Channel {
+	case ChannelEmail:
+		provider := commonapi.NewEmailProvider(m.ctx)
+		if provider == nil {
+			return errors.New("没有配置邮件服务")
+		}
+		body := emailBody(content)
+		for _, to := range splitValues(schedule.Recipients) {
+			if _, err := provider.SendEmail(context.Background(), to, subject, body); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ChannelGroup:
+		return m.ctx.SendMessage(&config.MsgSendReq{
+			FromUID:     m.ctx.GetConfig().Account.SystemUID,
+			ChannelID:   schedule.GroupNo,
+			ChannelType: common.ChannelTypeGroup.Uint8(),
+			Payload: []byte(util.ToJson(map[string]interface{}{
+				"content": content,
+				"type":    common.Text,
+			})),
+			Header: config.MsgHeader{
+				RedDot: 1,
+			},
+		})
+	}
+	return errors.New("发送方式有误")
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,48 @@
+-- +migrate Up
+
+-- 定时报告 每天或每周汇总运营数据、待审核的内容和错误数 发送邮件或发到管理员群
+create table `digest_schedule`
+(
+  id               bigint         not null primary key AUTO_INCREMENT,
+  schedule_no      VARCHAR(40)    not null default '',  -- 报告编号
+  name             VARCHAR(100)   not null default '',  -- 报告名称
+  period           VARCHAR(20)    not null default '',  -- 周期 daily.每天 weekly.每周
+  weekday          smallint       not null default 0,   -- 每周发送的星期 0.星期日 1-6.星期一至星期六
+  hour             smallint       not null default 0,   -- 发送的时间（小时 0-23）
+  channel          VARCHAR(20)    not null default '',  -- 发送方式 email.邮件 group.管理员群
+  recipients       VARCHAR(2000)  not null default '',  -- 接收邮件的邮箱 多个用逗号分隔
+  group_no         VARCHAR(40)    not null default '',  -- 接收报告的群
+  sections         VARCHAR(100)   not null default '',  -- 报告的内容 metrics.运营数据 moderation.待审核 errors.错误数 多个用逗号分隔
+  subject          VARCHAR(200)   not null default '',  -- 标题模版
+  template         text,                                -- 正文模版
+  status           smallint       not null default 1,   -- 状态 0.停用 1.启用
+  creator          VARCHAR(40)    not null default '',  -- 创建人
+  next_run_at      bigint         not null default 0,   -- 下次发送的时间
+  last_run_at      bigint         not null default 0,   -- 上次发送的时间
+  http_error_total bigint         not null default 0,   -- 上次发送时实例的接口5xx总数 用于计算本周期的错误数
+  im_error_total   bigint         not null default 0,   -- 上次发送时实例的调用IM失败总数
+  last_error_count bigint         not null default 0,   -- 上一周期的错误数 用于判断错误激增
+  created_at       timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at       timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `digest_schedule_no_idx` on `digest_schedule` (`schedule_no`);
+CREATE INDEX `digest_schedule_next_run_idx` on `digest_schedule` (`status`, `next_run_at`);
+
+
+-- 报告的发送记录
+create table `digest_log`
+(
+  id               bigint         not null primary key AUTO_INCREMENT,
+  schedule_no      VARCHAR(40)    not null default '',  -- 报告编号
+  start_date       VARCHAR(10)    not null default '',  -- 统计的开始日期
+  end_date         VARCHAR(10)    not null default '',  -- 统计的结束日期
+  channel          VARCHAR(20)    not null default '',  -- 发送方式
+  subject          VARCHAR(200)   not null default '',  -- 标题
+  content          text,                                -- 正文
+  manual           smallint       not null default 0,   -- 是否为手动发送 1.是
+  status           smallint       not null default 0,   -- 状态 0.失败 1.成功
+  error            VARCHAR(255)   not null default '',  -- 失败原因
+  created_at       timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at       timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE INDEX `digest_log_schedule_idx` on `digest_log` (`schedule_no`, `created_at`);
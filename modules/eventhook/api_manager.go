@@ -0,0 +1,512 @@
+package eventhook
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/eventbus"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Manager 管理领域事件的webhook和推送失败的死信
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db    *db
+	hooks *Hooks
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	m := &Manager{
+		ctx:   ctx,
+		Log:   log.NewTLog("eventhookManager"),
+		db:    newDB(ctx),
+		hooks: newHooks(ctx),
+	}
+	m.hooks.start()
+	return m
+}
+
+// Route 配置路由规则
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/eventhook/events", m.events)                              // 可以订阅的事件类型
+		auth.POST("/eventhooks", m.create)                                   // 添加webhook 密钥只在添加时返回
+		auth.GET("/eventhooks", m.list)                                      // webhook列表
+		auth.PUT("/eventhooks/:webhook_no", m.update)                        // 修改webhook
+		auth.POST("/eventhooks/:webhook_no/secret", m.resetSecret)           // 重新生成密钥
+		auth.DELETE("/eventhooks/:webhook_no", m.delete)                     // 删除webhook和死信
+		auth.POST("/eventhooks/:webhook_no/deadletters/replay", m.replayAll) // 重新推送webhook所有待处理的死信
+		auth.GET("/eventhook/deadletters", m.deadLetters)                    // 死信列表
+		auth.POST("/eventhook/deadletters/:id/replay", m.replayDeadLetter)   // 重新推送死信
+		auth.DELETE("/eventhook/deadletters/:id", m.deleteDeadLetter)        // 删除死信
+	}
+}
+
+// 可以订阅的事件类型
+func (m *Manager) events(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(eventbus.EventTypes)
+}
+
+type webhookReq struct {
+	Name   string   `json:"name"`   // 名称 例如对接的系统
+	URL    string   `json:"url"`    // 推送地址
+	Events []string `json:"events"` // 订阅的事件类型 *为所有事件
+	Status *int     `json:"status"` // 状态 0.停用 1.启用 添加时默认启用
+}
+
+func (r *webhookReq) check() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("名称不能为空")
+	}
+	if len([]rune(r.Name)) > 100 {
+		return errors.New("名称不能超过100个字")
+	}
+	if err := checkURL(r.URL); err != nil {
+		return err
+	}
+	if len(r.Events) == 0 {
+		return errors.New("订阅的事件不能为空")
+	}
+	for _, e := range r.Events {
+		if e != allEvents && !validEventType(e) {
+			return fmt.Errorf("事件类型[%s]有误", e)
+		}
+	}
+	if r.Status != nil && *r.Status != StatusEnabled && *r.Status != StatusDisabled {
+		return errors.New("状态有误")
+	}
+	return nil
+}
+
+// checkURL 推送地址必须是http或https的完整地址
+func checkURL(webhookURL string) error {
+	if webhookURL == "" {
+		return errors.New("url不能为空！")
+	}
+	if len(webhookURL) > urlMaxLen {
+		return fmt.Errorf("url不能超过%d个字符！", urlMaxLen)
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url必须是http或https地址！")
+	}
+	return nil
+}
+
+func validEventType(eventType string) bool {
+	for _, e := range eventbus.EventTypes {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// 添加webhook
+func (m *Manager) create(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req webhookReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	status := StatusEnabled
+	if req.Status != nil {
+		status = *req.Status
+	}
+	hook := &model{
+		WebhookNo: util.GenerUUID(),
+		Name:      req.Name,
+		URL:       req.URL,
+		Events:    strings.Join(req.Events, ","),
+		Secret:    util.GenerUUID(),
+		Status:    status,
+		Creator:   c.GetLoginUID(),
+	}
+	if err := m.db.insert(hook); err != nil {
+		m.Error("添加事件webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加事件webhook失败！"))
+		return
+	}
+	m.hooks.refresh()
+	audit.SetChange(c, fmt.Sprintf("webhook_no=%s", hook.WebhookNo), nil, map[string]interface{}{
+		"name":   hook.Name,
+		"url":    hook.URL,
+		"events": hook.Events,
+		"status": hook.Status,
+	})
+	c.Response(map[string]interface{}{
+		"webhook_no": hook.WebhookNo,
+		"secret":     hook.Secret,
+	})
+}
+
+// webhook列表
+func (m *Manager) list(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.db.queryWithPage(uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询事件webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询事件webhook失败！"))
+		return
+	}
+	count, err := m.db.queryCount()
+	if err != nil {
+		m.Error("查询事件webhook数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询事件webhook数量失败！"))
+		return
+	}
+	webhookNos := make([]string, 0, len(models))
+	for _, model := range models {
+		webhookNos = append(webhookNos, model.WebhookNo)
+	}
+	deadCounts, err := m.db.queryPendingDeadLetterCounts(webhookNos)
+	if err != nil {
+		m.Error("查询死信数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询死信数量失败！"))
+		return
+	}
+	list := make([]*webhookResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newWebhookResp(model, deadCounts[model.WebhookNo]))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+func (m *Manager) queryWebhook(c *wkhttp.Context) (*model, bool) {
+	hook, err := m.db.queryWithWebhookNo(c.Param("webhook_no"))
+	if err != nil {
+		m.Error("查询事件webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询事件webhook失败！"))
+		return nil, false
+	}
+	if hook == nil {
+		c.ResponseError(errors.New("webhook不存在"))
+		return nil, false
+	}
+	return hook, true
+}
+
+// 修改webhook
+func (m *Manager) update(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req webhookReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	hook, ok := m.queryWebhook(c)
+	if !ok {
+		return
+	}
+	before := map[string]interface{}{
+		"name":   hook.Name,
+		"url":    hook.URL,
+		"events": hook.Events,
+		"status": hook.Status,
+	}
+	hook.Name = req.Name
+	hook.URL = req.URL
+	hook.Events = strings.Join(req.Events, ",")
+	if req.Status != nil {
+		hook.Status = *req.Status
+	}
+	if err := m.db.update(hook); err != nil {
+		m.Error("修改事件webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改事件webhook失败！"))
+		return
+	}
+	m.hooks.refresh()
+	audit.SetChange(c, fmt.Sprintf("webhook_no=%s", hook.WebhookNo), before, map[string]interface{}{
+		"name":   hook.Name,
+		"url":    hook.URL,
+		"events": hook.Events,
+		"status": hook.Status,
+	})
+	c.ResponseOK()
+}
+
+// 重新生成密钥 立即生效 还没有推送的事件使用新的密钥签名
+func (m *Manager) resetSecret(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	hook, ok := m.queryWebhook(c)
+	if !ok {
+		return
+	}
+	hook.Secret = util.GenerUUID()
+	if err := m.db.update(hook); err != nil {
+		m.Error("重新生成webhook密钥失败！", zap.Error(err))
+		c.ResponseError(errors.New("重新生成webhook密钥失败！"))
+		return
+	}
+	m.hooks.refresh()
+	audit.SetChange(c, fmt.Sprintf("webhook_no=%s", hook.WebhookNo), nil, map[string]interface{}{"secret": "reset"})
+	c.Response(map[string]interface{}{
+		"secret": hook.Secret,
+	})
+}
+
+// 删除webhook 还没有推送的事件不再推送
+func (m *Manager) delete(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	hook, ok := m.queryWebhook(c)
+	if !ok {
+		return
+	}
+	if err := m.db.delete(hook.Id); err != nil {
+		m.Error("删除事件webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除事件webhook失败！"))
+		return
+	}
+	if err := m.db.deleteDeadLettersWithWebhookNo(hook.WebhookNo); err != nil {
+		m.Error("删除webhook的死信失败！", zap.Error(err), zap.String("webhookNo", hook.WebhookNo))
+	}
+	m.hooks.refresh()
+	audit.SetChange(c, fmt.Sprintf("webhook_no=%s", hook.WebhookNo), map[string]interface{}{
+		"name": hook.Name,
+		"url":  hook.URL,
+	}, nil)
+	c.ResponseOK()
+}
+
+// 死信列表
+func (m *Manager) deadLetters(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermLogRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	webhookNo := c.Query("webhook_no")
+	status, _ := strconv.Atoi(c.DefaultQuery("status", strconv.Itoa(DeadLetterPending)))
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.db.queryDeadLettersWithPage(webhookNo, status, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询死信失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询死信失败！"))
+		return
+	}
+	count, err := m.db.queryDeadLetterCount(webhookNo, status)
+	if err != nil {
+		m.Error("查询死信数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询死信数量失败！"))
+		return
+	}
+	list := make([]*deadLetterResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newDeadLetterResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// replayOne 重新推送一条死信 已经重新推送过时返回false
+func (m *Manager) replayOne(deadLetter *deadLetterModel, replayer string) (bool, error) {
+	ok, err := m.db.markReplayed(deadLetter.Id, replayer, time.Now().Unix())
+	if err != nil || !ok {
+		return false, err
+	}
+	if err = m.hooks.replay(deadLetter); err != nil {
+		if resetErr := m.db.resetReplayed(deadLetter.Id); resetErr != nil {
+			m.Error("恢复死信为待处理失败！", zap.Error(resetErr), zap.Int64("id", deadLetter.Id))
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// 重新推送死信
+func (m *Manager) replayDeadLetter(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	deadLetter, err := m.db.queryDeadLetter(id)
+	if err != nil {
+		m.Error("查询死信失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询死信失败！"))
+		return
+	}
+	if deadLetter == nil {
+		c.ResponseError(errors.New("死信不存在"))
+		return
+	}
+	ok, err := m.replayOne(deadLetter, c.GetLoginUID())
+	if err != nil {
+		m.Error("重新推送死信失败！", zap.Error(err), zap.Int64("id", id))
+		c.ResponseError(errors.New("重新推送死信失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("死信已经重新推送过"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("dead_letter_id=%d", id), map[string]interface{}{"status": DeadLetterPending}, map[string]interface{}{"status": DeadLetterReplayed})
+	c.ResponseOK()
+}
+
+// 重新推送webhook所有待处理的死信 每次最多replayBatchSize条 返回重新推送的数量
+func (m *Manager) replayAll(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	hook, ok := m.queryWebhook(c)
+	if !ok {
+		return
+	}
+	deadLetters, err := m.db.queryPendingDeadLetters(hook.WebhookNo, replayBatchSize)
+	if err != nil {
+		m.Error("查询死信失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询死信失败！"))
+		return
+	}
+	replayed := 0
+	for _, deadLetter := range deadLetters {
+		ok, err := m.replayOne(deadLetter, c.GetLoginUID())
+		if err != nil {
+			m.Error("重新推送死信失败！", zap.Error(err), zap.Int64("id", deadLetter.Id))
+			c.ResponseError(errors.New("重新推送死信失败！"))
+			return
+		}
+		if ok {
+			replayed++
+		}
+	}
+	audit.SetChange(c, fmt.Sprintf("webhook_no=%s", hook.WebhookNo), nil, map[string]interface{}{"replayed": replayed})
+	c.Response(map[string]interface{}{
+		"replayed": replayed,
+	})
+}
+
+// 删除死信 不再推送
+func (m *Manager) deleteDeadLetter(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	deadLetter, err := m.db.queryDeadLetter(id)
+	if err != nil {
+		m.Error("查询死信失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询死信失败！"))
+		return
+	}
+	if deadLetter == nil {
+		c.ResponseError(errors.New("死信不存在"))
+		return
+	}
+	if err = m.db.deleteDeadLetter(id); err != nil {
+		m.Error("删除死信失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除死信失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("dead_letter_id=%d", id), map[string]interface{}{
+		"webhook_no": deadLetter.WebhookNo,
+		"event_id":   deadLetter.EventID,
+	}, nil)
+	c.ResponseOK()
+}
+
+type webhookResp struct {
+	WebhookNo      string   `json:"webhook_no"`
+	Name           string   `json:"name"`
+	URL            string   `json:"url"`
+	Events         []string `json:"events"`
+	Status         int      `json:"status"`
+	DeadLetterSize int64    `json:"dead_letter_size"` // 待处理的死信数
+	Creator        string   `json:"creator"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+func newWebhookResp(m *model, deadLetterSize int64) *webhookResp {
+	return &webhookResp{
+		WebhookNo:      m.WebhookNo,
+		Name:           m.Name,
+		URL:            m.URL,
+		Events:         strings.Split(m.Events, ","),
+		Status:         m.Status,
+		DeadLetterSize: deadLetterSize,
+		Creator:        m.Creator,
+		CreatedAt:      m.CreatedAt.String(),
+	}
+}
+
+type deadLetterResp struct {
+	ID         int64  `json:"id"`
+	WebhookNo  string `json:"webhook_no"`
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	Body       string `json:"body"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error"`
+	Status     int    `json:"status"`
+	Replayer   string `json:"replayer"`
+	ReplayedAt int64  `json:"replayed_at"`
+	CreatedAt  string `json:"created_at"`
+}
+
+func newDeadLetterResp(m *deadLetterModel) *deadLetterResp {
+	return &deadLetterResp{
+		ID:         m.Id,
+		WebhookNo:  m.WebhookNo,
+		EventID:    m.EventID,
+		EventType:  m.EventType,
+		Body:       m.Body,
+		Attempts:   m.Attempts,
+		StatusCode: m.StatusCode,
+		Error:      m.Error,
+		Status:     m.Status,
+		Replayer:   m.Replayer,
+		ReplayedAt: m.ReplayedAt,
+		CreatedAt:  m.CreatedAt.String(),
+	}
+}
@@ -0,0 +1,40 @@
+package eventhook
+
+// webhook的状态
+const (
+	StatusDisabled = 0 // 停用
+	StatusEnabled  = 1 // 启用
+)
+
+// 死信的状态
+const (
+	DeadLetterPending  = 0 // 待处理
+	DeadLetterReplayed = 1 // 已重新推送
+)
+
+// 推送的请求头
+const (
+	// eventIDHeader 事件id 重试时不变 接收方可据此去重
+	eventIDHeader = "X-Tsdd-Event-Id"
+	// eventTypeHeader 事件类型
+	eventTypeHeader = "X-Tsdd-Event-Type"
+	// timestampHeader 推送时间（秒级时间戳） 接收方应拒绝时间相差过大的请求
+	timestampHeader = "X-Tsdd-Timestamp"
+	// signatureHeader 签名 hex(HMAC-SHA256(secret, timestamp + "." + body))
+	signatureHeader = "X-Tsdd-Signature"
+)
+
+const (
+	// allEvents 订阅所有事件
+	allEvents = "*"
+	// jobQueueEventHook 推送的任务队列
+	jobQueueEventHook = "eventhook"
+	// JobTypeDeliver 推送一个事件到一个webhook
+	JobTypeDeliver = "eventhook.deliver"
+	// urlMaxLen 推送地址的最大长度
+	urlMaxLen = 255
+	// errorMaxLen 死信中保存的失败原因的最大长度
+	errorMaxLen = 255
+	// replayBatchSize 批量重新推送时每次最多处理的死信数
+	replayBatchSize = 500
+)
@@ -0,0 +1,182 @@
+package eventhook
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *db) insert(m *model) error {
+	_, err := d.session.InsertInto("event_webhook").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) update(m *model) error {
+	_, err := d.session.Update("event_webhook").SetMap(map[string]interface{}{
+		"name":   m.Name,
+		"url":    m.URL,
+		"events": m.Events,
+		"secret": m.Secret,
+		"status": m.Status,
+	}).Where("id=?", m.Id).Exec()
+	return err
+}
+
+func (d *db) delete(id int64) error {
+	_, err := d.session.DeleteFrom("event_webhook").Where("id=?", id).Exec()
+	return err
+}
+
+func (d *db) queryWithWebhookNo(webhookNo string) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("event_webhook").Where("webhook_no=?", webhookNo).Load(&m)
+	return m, err
+}
+
+func (d *db) queryEnabled() ([]*model, error) {
+	var models []*model
+	_, err := d.session.Select("*").From("event_webhook").Where("status=?", StatusEnabled).Load(&models)
+	return models, err
+}
+
+func (d *db) queryWithPage(pageIndex, pageSize uint64) ([]*model, error) {
+	var models []*model
+	_, err := d.session.Select("*").From("event_webhook").OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryCount() (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("event_webhook").Load(&count)
+	return count, err
+}
+
+// queryPendingDeadLetterCounts 每个webhook待处理的死信数
+func (d *db) queryPendingDeadLetterCounts(webhookNos []string) (map[string]int64, error) {
+	counts := map[string]int64{}
+	if len(webhookNos) == 0 {
+		return counts, nil
+	}
+	var rows []*struct {
+		WebhookNo string
+		Count     int64
+	}
+	_, err := d.session.Select("webhook_no,count(*) count").From("event_webhook_dead_letter").Where("webhook_no in ? and status=?", webhookNos, DeadLetterPending).GroupBy("webhook_no").Load(&rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.WebhookNo] = row.Count
+	}
+	return counts, nil
+}
+
+func (d *db) insertDeadLetter(m *deadLetterModel) error {
+	_, err := d.session.InsertInto("event_webhook_dead_letter").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryDeadLetter(id int64) (*deadLetterModel, error) {
+	var m *deadLetterModel
+	_, err := d.session.Select("*").From("event_webhook_dead_letter").Where("id=?", id).Load(&m)
+	return m, err
+}
+
+func (d *db) deadLetterCond(webhookNo string, status int) *dbr.SelectStmt {
+	builder := d.session.Select("*").From("event_webhook_dead_letter").Where("status=?", status)
+	if webhookNo != "" {
+		builder = builder.Where("webhook_no=?", webhookNo)
+	}
+	return builder
+}
+
+func (d *db) queryDeadLettersWithPage(webhookNo string, status int, pageIndex, pageSize uint64) ([]*deadLetterModel, error) {
+	var models []*deadLetterModel
+	_, err := d.deadLetterCond(webhookNo, status).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryDeadLetterCount(webhookNo string, status int) (int64, error) {
+	var count int64
+	builder := d.session.Select("count(*)").From("event_webhook_dead_letter").Where("status=?", status)
+	if webhookNo != "" {
+		builder = builder.Where("webhook_no=?", webhookNo)
+	}
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+// queryPendingDeadLetters webhook待处理的死信 按时间先后
+func (d *db) queryPendingDeadLetters(webhookNo string, limit uint64) ([]*deadLetterModel, error) {
+	var models []*deadLetterModel
+	_, err := d.deadLetterCond(webhookNo, DeadLetterPending).OrderDir("id", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+// markReplayed 标记为已重新推送 已经处理过时返回false 避免重复推送
+func (d *db) markReplayed(id int64, replayer string, replayedAt int64) (bool, error) {
+	result, err := d.session.Update("event_webhook_dead_letter").SetMap(map[string]interface{}{
+		"status":      DeadLetterReplayed,
+		"replayer":    replayer,
+		"replayed_at": replayedAt,
+	}).Where("id=? and status=?", id, DeadLetterPending).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// resetReplayed 添加重新推送的任务失败时恢复为待处理
+func (d *db) resetReplayed(id int64) error {
+	_, err := d.session.Update("event_webhook_dead_letter").Set("status", DeadLetterPending).Set("replayer", "").Set("replayed_at", 0).Where("id=?", id).Exec()
+	return err
+}
+
+func (d *db) deleteDeadLetter(id int64) error {
+	_, err := d.session.DeleteFrom("event_webhook_dead_letter").Where("id=?", id).Exec()
+	return err
+}
+
+func (d *db) deleteDeadLettersWithWebhookNo(webhookNo string) error {
+	_, err := d.session.DeleteFrom("event_webhook_dead_letter").Where("webhook_no=?", webhookNo).Exec()
+	return err
+}
+
+type model struct {
+	WebhookNo string
+	Name      string
+	URL       string
+	Events    string // 逗号分隔 *为所有事件
+	Secret    string
+	Status    int
+	Creator   string
+	dba.BaseModel
+}
+
+type deadLetterModel struct {
+	WebhookNo  string
+	EventID    string
+	EventType  string
+	Body       string
+	Attempts   int
+	StatusCode int
+	Error      string
+	Status     int
+	Replayer   string
+	ReplayedAt int64
+	dba.BaseModel
+}
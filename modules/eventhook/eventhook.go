@@ -0,0 +1,224 @@
+package eventhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/eventbus"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/jobqueue"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
+)
+
+// Hooks 把领域事件推送到订阅了的webhook
+// 每个事件对每个webhook添加一个推送任务 失败时由任务队列按退避间隔重试 重试次数用完后放入死信列表
+type Hooks struct {
+	ctx *config.Context
+	log.Log
+	db     *db
+	client *http.Client
+
+	mu         sync.RWMutex
+	hooks      []*model
+	refreshing atomic.Bool
+}
+
+func newHooks(ctx *config.Context) *Hooks {
+	return &Hooks{
+		ctx:    ctx,
+		Log:    log.NewTLog("EventHooks"),
+		db:     newDB(ctx),
+		client: &http.Client{Timeout: extconfig.Get().EventHook.Timeout},
+	}
+}
+
+type deliverJob struct {
+	WebhookNo string          `json:"webhook_no"`
+	Event     *eventbus.Event `json:"event"`
+}
+
+// start 加载webhook 订阅事件并注册推送任务
+func (h *Hooks) start() {
+	h.refresh()
+	h.ctx.Schedule(extconfig.Get().EventHook.RefreshInterval, h.refresh)
+	jobqueue.Register(jobQueueEventHook, JobTypeDeliver, h.handleDeliverJob)
+	eventbus.Subscribe(h)
+}
+
+// refresh 重新加载启用的webhook
+func (h *Hooks) refresh() {
+	if !h.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	defer h.refreshing.Store(false)
+	hooks, err := h.db.queryEnabled()
+	if err != nil {
+		h.Error("查询事件webhook失败！", zap.Error(err))
+		return
+	}
+	h.mu.Lock()
+	h.hooks = hooks
+	h.mu.Unlock()
+}
+
+func (h *Hooks) subscribed(eventType string) []*model {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var hooks []*model
+	for _, hook := range h.hooks {
+		if subscribes(hook.Events, eventType) {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// Enabled 是否有webhook订阅了此类型的事件
+func (h *Hooks) Enabled(eventType string) bool {
+	return len(h.subscribed(eventType)) > 0
+}
+
+// Receive 为订阅了事件的webhook添加推送任务
+func (h *Hooks) Receive(events []*eventbus.Event) {
+	maxRetry := extconfig.Get().EventHook.MaxRetry
+	for _, e := range events {
+		for _, hook := range h.subscribed(e.Type) {
+			h.enqueue(hook.WebhookNo, e, maxRetry)
+		}
+	}
+}
+
+func (h *Hooks) enqueue(webhookNo string, e *eventbus.Event, maxRetry int) {
+	_, err := jobqueue.Enqueue(JobTypeDeliver, &deliverJob{
+		WebhookNo: webhookNo,
+		Event:     e,
+	}, jobqueue.JobID(fmt.Sprintf("eventhook:%s:%s", webhookNo, e.ID)), jobqueue.MaxRetry(maxRetry))
+	if err != nil && err != jobqueue.ErrJobExists {
+		h.Error("添加事件推送任务失败！", zap.Error(err), zap.String("webhookNo", webhookNo), zap.String("eventID", e.ID))
+	}
+}
+
+// handleDeliverJob 推送事件 最后一次重试仍失败时放入死信列表
+func (h *Hooks) handleDeliverJob(ctx context.Context, job *jobqueue.Job) error {
+	var req deliverJob
+	if err := job.Bind(&req); err != nil {
+		return err
+	}
+	if req.Event == nil {
+		return nil
+	}
+	hook, err := h.db.queryWithWebhookNo(req.WebhookNo)
+	if err != nil {
+		return err
+	}
+	if hook == nil || hook.Status != StatusEnabled {
+		// webhook已删除或停用 不再推送
+		return nil
+	}
+	body, err := json.Marshal(req.Event)
+	if err != nil {
+		return err
+	}
+	statusCode, err := h.post(ctx, hook, req.Event, body)
+	if err == nil {
+		return nil
+	}
+	if job.Retried < job.MaxRetry {
+		return err
+	}
+	errMsg := err.Error()
+	if len(errMsg) > errorMaxLen {
+		errMsg = errMsg[:errorMaxLen]
+	}
+	err = h.db.insertDeadLetter(&deadLetterModel{
+		WebhookNo:  hook.WebhookNo,
+		EventID:    req.Event.ID,
+		EventType:  req.Event.Type,
+		Body:       string(body),
+		Attempts:   job.Retried + 1,
+		StatusCode: statusCode,
+		Error:      errMsg,
+		Status:     DeadLetterPending,
+	})
+	if err != nil {
+		// 保存失败时留在任务队列的失败列表中
+		h.Error("保存事件推送的死信失败！", zap.Error(err), zap.String("webhookNo", hook.WebhookNo), zap.String("eventID", req.Event.ID))
+		return err
+	}
+	h.Warn("事件推送失败，已放入死信列表", zap.String("webhookNo", hook.WebhookNo), zap.String("eventID", req.Event.ID), zap.String("error", errMsg))
+	return nil
+}
+
+// post 推送事件 返回的状态码不是2xx时视为失败
+func (h *Hooks) post(ctx context.Context, hook *model, e *eventbus.Event, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(eventIDHeader, e.ID)
+	req.Header.Set(eventTypeHeader, e.Type)
+	timestamp := time.Now().Unix()
+	req.Header.Set(timestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(signatureHeader, signature(hook.Secret, timestamp, body))
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook返回的状态码为%d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// replay 重新推送死信 使用新的重试次数
+func (h *Hooks) replay(m *deadLetterModel) error {
+	var e *eventbus.Event
+	if err := json.Unmarshal([]byte(m.Body), &e); err != nil {
+		return err
+	}
+	if e == nil {
+		return errors.New("死信的内容有误")
+	}
+	_, err := jobqueue.Enqueue(JobTypeDeliver, &deliverJob{
+		WebhookNo: m.WebhookNo,
+		Event:     e,
+	}, jobqueue.MaxRetry(extconfig.Get().EventHook.MaxRetry))
+	return err
+}
+
+// signature 推送的签名 hex(HMAC-SHA256(secret, timestamp + "." + body))
+func signature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribes events（逗号分隔）是否包含eventType
+func subscribes(events string, eventType string) bool {
+	for _, e := range strings.Split(events, ",") {
+		if e == allEvents || e == eventType {
+			return true
+		}
+	}
+	return false
+}
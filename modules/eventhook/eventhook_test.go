@@ -0,0 +1,59 @@
+package eventhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/eventbus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribes(t *testing.T) {
+	assert.True(t, subscribes("*", eventbus.MessageSent))
+	assert.True(t, subscribes("user.registered,message.sent", eventbus.MessageSent))
+	assert.False(t, subscribes("user.registered", eventbus.MessageSent))
+	assert.False(t, subscribes("", eventbus.MessageSent))
+}
+
+func TestWebhookReqCheck(t *testing.T) {
+	req := &webhookReq{Name: " crm ", URL: "https://example.com/hook", Events: []string{eventbus.GroupCreated, allEvents}}
+	assert.NoError(t, req.check())
+	assert.Equal(t, "crm", req.Name)
+
+	req = &webhookReq{Name: "crm", URL: "ftp://example.com", Events: []string{eventbus.GroupCreated}}
+	assert.Error(t, req.check())
+
+	req = &webhookReq{Name: "crm", URL: "https://example.com/hook", Events: []string{"group.unknown"}}
+	assert.Error(t, req.check())
+
+	status := 2
+	req = &webhookReq{Name: "crm", URL: "https://example.com/hook", Events: []string{eventbus.GroupCreated}, Status: &status}
+	assert.Error(t, req.check())
+}
+
+func TestPost(t *testing.T) {
+	body := []byte(`{"id":"e1"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamp, _ := strconv.ParseInt(r.Header.Get(timestampHeader), 10, 64)
+		assert.Equal(t, signature("secret", timestamp, body), r.Header.Get(signatureHeader))
+		assert.Equal(t, "e1", r.Header.Get(eventIDHeader))
+		assert.Equal(t, eventbus.UserRegistered, r.Header.Get(eventTypeHeader))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	h := &Hooks{client: server.Client()}
+	e := &eventbus.Event{ID: "e1", Type: eventbus.UserRegistered}
+	statusCode, err := h.post(context.Background(), &model{URL: server.URL, Secret: "secret"}, e, body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	statusCode, err = h.post(context.Background(), &model{URL: server.URL + "/fail", Secret: "secret"}, e, body)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadGateway, statusCode)
+}
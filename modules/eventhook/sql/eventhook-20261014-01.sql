@@ -0,0 +1,36 @@
+-- +migrate Up
+
+-- 领域事件的webhook 管理后台配置 按事件类型订阅
+create table `event_webhook`
+(
+  id           bigint         not null primary key AUTO_INCREMENT,
+  webhook_no   VARCHAR(40)    not null default '',  -- 编号
+  name         VARCHAR(100)   not null default '',  -- 名称 例如对接的系统
+  url          VARCHAR(255)   not null default '',  -- 推送地址
+  events       VARCHAR(500)   not null default '',  -- 订阅的事件类型 逗号分隔 *为所有事件
+  secret       VARCHAR(100)   not null default '',  -- 签名的密钥
+  status       smallint       not null default 1,   -- 状态 0.停用 1.启用
+  creator      VARCHAR(40)    not null default '',  -- 创建人
+  created_at   timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at   timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `event_webhook_no_idx` on `event_webhook` (`webhook_no`);
+
+-- 重试次数用完仍推送失败的事件（死信） 可以在管理后台重新推送
+create table `event_webhook_dead_letter`
+(
+  id           bigint         not null primary key AUTO_INCREMENT,
+  webhook_no   VARCHAR(40)    not null default '',  -- webhook编号
+  event_id     VARCHAR(40)    not null default '',  -- 事件id
+  event_type   VARCHAR(40)    not null default '',  -- 事件类型
+  body         mediumtext     not null,             -- 推送的内容
+  attempts     int            not null default 0,   -- 已推送的次数
+  status_code  int            not null default 0,   -- 最后一次推送返回的状态码 0为请求失败
+  error        VARCHAR(255)   not null default '',  -- 最后一次推送失败的原因
+  status       smallint       not null default 0,   -- 状态 0.待处理 1.已重新推送
+  replayer     VARCHAR(40)    not null default '',  -- 重新推送的管理员
+  replayed_at  bigint         not null default 0,   -- 重新推送的时间
+  created_at   timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at   timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE INDEX `event_webhook_dead_letter_webhook_idx` on `event_webhook_dead_letter` (`webhook_no`, `status`);
@@ -0,0 +1,36 @@
+package feature
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+)
+
+// Feature 功能开关
+type Feature struct {
+	ctx *config.Context
+	log.Log
+	service IService
+}
+
+// New New
+func New(ctx *config.Context) *Feature {
+	return &Feature{
+		ctx:     ctx,
+		Log:     log.NewTLog("Feature"),
+		service: NewService(ctx),
+	}
+}
+
+// Route 路由配置
+func (f *Feature) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/common", f.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/features", f.features) // 功能开关
+	}
+}
+
+// 当前用户和客户端版本的功能开关 只返回没有删除的开关
+func (f *Feature) features(c *wkhttp.Context) {
+	c.Response(f.service.Evaluate(TargetWithContext(c)))
+}
@@ -0,0 +1,318 @@
+package feature
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Manager 功能开关管理
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	return &Manager{
+		ctx: ctx,
+		Log: log.NewTLog("FeatureManager"),
+		db:  newDB(ctx),
+	}
+}
+
+// Route 路由配置
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/features", m.list)               // 功能开关列表
+		auth.POST("/features", m.add)               // 添加功能开关
+		auth.PUT("/features/:key", m.update)        // 修改功能开关
+		auth.DELETE("/features/:key", m.delete)     // 删除功能开关
+		auth.POST("/features/evaluate", m.evaluate) // 计算开关对某个用户的结果
+	}
+	m.reloadFlags(false)
+	m.ctx.Schedule(extconfig.Get().Feature.ReloadInterval, func() {
+		m.reloadFlags(false)
+	})
+}
+
+func (m *Manager) reloadFlags(force bool) {
+	if err := flags.reload(m.db, force); err != nil {
+		m.Warn("加载功能开关失败！", zap.Error(err))
+	}
+}
+
+// 功能开关列表
+func (m *Manager) list(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	keyword := strings.TrimSpace(c.Query("keyword"))
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.db.queryWithPage(keyword, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询功能开关失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询功能开关失败！"))
+		return
+	}
+	count, err := m.db.queryCount(keyword)
+	if err != nil {
+		m.Error("查询功能开关数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询功能开关数量失败！"))
+		return
+	}
+	list := make([]*flagResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newFlagResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 添加功能开关
+func (m *Manager) add(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req flagReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	req.Key = strings.TrimSpace(req.Key)
+	if !validKey(req.Key) {
+		c.ResponseError(errors.New("开关的key只能包含小写字母、数字、下划线和点，以小写字母开头，长度为2-50"))
+		return
+	}
+	model, err := req.toModel()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	exist, err := m.db.queryWithKey(req.Key)
+	if err != nil {
+		m.Error("查询功能开关失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询功能开关失败！"))
+		return
+	}
+	if exist != nil {
+		c.ResponseError(errors.New("功能开关已存在"))
+		return
+	}
+	model.Updater = c.GetLoginUID()
+	model.Version = m.ctx.GenSeq(seqKey)
+	if err = m.db.insertOrRecover(model); err != nil {
+		m.Error("添加功能开关失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加功能开关失败！"))
+		return
+	}
+	m.reloadFlags(false)
+	audit.SetChange(c, fmt.Sprintf("key=%s", model.FlagKey), nil, newFlagResp(model))
+	c.ResponseOK()
+}
+
+// 修改功能开关
+func (m *Manager) update(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req flagReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	req.Key = c.Param("key")
+	model, err := req.toModel()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	old, err := m.db.queryWithKey(req.Key)
+	if err != nil {
+		m.Error("查询功能开关失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询功能开关失败！"))
+		return
+	}
+	if old == nil {
+		c.ResponseError(errors.New("功能开关不存在"))
+		return
+	}
+	model.Updater = c.GetLoginUID()
+	model.Version = m.ctx.GenSeq(seqKey)
+	ok, err := m.db.update(model)
+	if err != nil {
+		m.Error("修改功能开关失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改功能开关失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("功能开关不存在"))
+		return
+	}
+	m.reloadFlags(false)
+	audit.SetChange(c, fmt.Sprintf("key=%s", model.FlagKey), newFlagResp(old), newFlagResp(model))
+	c.ResponseOK()
+}
+
+// 删除功能开关 删除后对所有用户都不生效
+func (m *Manager) delete(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	key := c.Param("key")
+	old, err := m.db.queryWithKey(key)
+	if err != nil {
+		m.Error("查询功能开关失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询功能开关失败！"))
+		return
+	}
+	if old == nil {
+		c.ResponseError(errors.New("功能开关不存在"))
+		return
+	}
+	ok, err := m.db.delete(key, c.GetLoginUID(), m.ctx.GenSeq(seqKey))
+	if err != nil {
+		m.Error("删除功能开关失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除功能开关失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("功能开关不存在"))
+		return
+	}
+	m.reloadFlags(false)
+	audit.SetChange(c, fmt.Sprintf("key=%s", key), newFlagResp(old), nil)
+	c.ResponseOK()
+}
+
+// 计算开关对某个用户和客户端版本的结果 用于检查灰度设置
+func (m *Manager) evaluate(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		UID     string `json:"uid"`
+		Version string `json:"version"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	m.reloadFlags(false)
+	c.Response(flags.evaluate(Target{
+		UID:     strings.TrimSpace(req.UID),
+		Version: strings.TrimSpace(req.Version),
+	}))
+}
+
+type flagReq struct {
+	Key            string   `json:"key"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	Enabled        int      `json:"enabled"`         // 1.开启 0.关闭
+	RolloutPercent int      `json:"rollout_percent"` // 灰度比例（0-100）
+	TargetUIDs     []string `json:"target_uids"`     // 指定开启的用户 不受灰度比例限制
+	MinVersion     string   `json:"min_version"`     // 最低的客户端版本（包含）
+	MaxVersion     string   `json:"max_version"`     // 最高的客户端版本（包含）
+}
+
+func (r flagReq) toModel() (*model, error) {
+	name := strings.TrimSpace(r.Name)
+	if name == "" {
+		return nil, errors.New("名称不能为空")
+	}
+	if len([]rune(name)) > 100 {
+		return nil, errors.New("名称不能超过100个字")
+	}
+	if len([]rune(r.Description)) > 500 {
+		return nil, errors.New("说明不能超过500个字")
+	}
+	if r.Enabled != 0 && r.Enabled != 1 {
+		return nil, errors.New("开关状态只能为0或1")
+	}
+	if r.RolloutPercent < 0 || r.RolloutPercent > 100 {
+		return nil, errors.New("灰度比例必须在0-100之间")
+	}
+	if len(r.TargetUIDs) > targetUIDMaxCount {
+		return nil, fmt.Errorf("指定的用户不能超过%d个", targetUIDMaxCount)
+	}
+	minVersion := strings.TrimSpace(r.MinVersion)
+	maxVersion := strings.TrimSpace(r.MaxVersion)
+	if len(minVersion) > versionMaxLen || len(maxVersion) > versionMaxLen {
+		return nil, fmt.Errorf("版本号不能超过%d个字符", versionMaxLen)
+	}
+	if minVersion != "" && maxVersion != "" && util.CompareVersion(minVersion, maxVersion) > 0 {
+		return nil, errors.New("最低版本不能大于最高版本")
+	}
+	uids := make([]string, 0, len(r.TargetUIDs))
+	exists := map[string]bool{}
+	for _, uid := range r.TargetUIDs {
+		uid = strings.TrimSpace(uid)
+		if uid == "" || exists[uid] {
+			continue
+		}
+		if strings.Contains(uid, ",") {
+			return nil, fmt.Errorf("用户uid[%s]有误", uid)
+		}
+		exists[uid] = true
+		uids = append(uids, uid)
+	}
+	return &model{
+		FlagKey:        r.Key,
+		Name:           name,
+		Description:    strings.TrimSpace(r.Description),
+		Enabled:        r.Enabled,
+		RolloutPercent: r.RolloutPercent,
+		TargetUids:     strings.Join(uids, ","),
+		MinVersion:     minVersion,
+		MaxVersion:     maxVersion,
+	}, nil
+}
+
+type flagResp struct {
+	Key            string   `json:"key"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	Enabled        int      `json:"enabled"`
+	RolloutPercent int      `json:"rollout_percent"`
+	TargetUIDs     []string `json:"target_uids"`
+	MinVersion     string   `json:"min_version"`
+	MaxVersion     string   `json:"max_version"`
+	Updater        string   `json:"updater"`
+	CreatedAt      string   `json:"created_at"`
+	UpdatedAt      string   `json:"updated_at"`
+}
+
+func newFlagResp(m *model) *flagResp {
+	return &flagResp{
+		Key:            m.FlagKey,
+		Name:           m.Name,
+		Description:    m.Description,
+		Enabled:        m.Enabled,
+		RolloutPercent: m.RolloutPercent,
+		TargetUIDs:     splitUIDs(m.TargetUids),
+		MinVersion:     m.MinVersion,
+		MaxVersion:     m.MaxVersion,
+		Updater:        m.Updater,
+		CreatedAt:      m.CreatedAt.String(),
+		UpdatedAt:      m.UpdatedAt.String(),
+	}
+}
@@ -0,0 +1,21 @@
+package feature
+
+import "regexp"
+
+// VersionHeader 客户端版本的请求头 没有时使用查询参数version
+const VersionHeader = "X-App-Version"
+
+// seqKey 功能开关版本的序号
+const seqKey = "FeatureFlag"
+
+const (
+	targetUIDMaxCount = 1000 // 每个开关最多指定的用户数
+	versionMaxLen     = 40   // 版本号的最大长度
+)
+
+// keyRegexp 开关的key 小写字母开头 只能包含小写字母、数字、下划线和点
+var keyRegexp = regexp.MustCompile(`^[a-z][a-z0-9_.]{1,49}$`)
+
+func validKey(key string) bool {
+	return keyRegexp.MatchString(key)
+}
@@ -0,0 +1,113 @@
+package feature
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// insertOrRecover 添加开关 已删除的同名开关会被恢复并覆盖设置
+func (d *db) insertOrRecover(m *model) error {
+	_, err := d.session.InsertBySql("insert into feature_flag(flag_key,name,description,enabled,rollout_percent,target_uids,min_version,max_version,updater,is_deleted,version) values(?,?,?,?,?,?,?,?,?,0,?) ON DUPLICATE KEY UPDATE name=VALUES(name),description=VALUES(description),enabled=VALUES(enabled),rollout_percent=VALUES(rollout_percent),target_uids=VALUES(target_uids),min_version=VALUES(min_version),max_version=VALUES(max_version),updater=VALUES(updater),is_deleted=0,version=VALUES(version)", m.FlagKey, m.Name, m.Description, m.Enabled, m.RolloutPercent, m.TargetUids, m.MinVersion, m.MaxVersion, m.Updater, m.Version).Exec()
+	return err
+}
+
+// update 修改开关 开关不存在时返回false
+func (d *db) update(m *model) (bool, error) {
+	result, err := d.session.Update("feature_flag").SetMap(map[string]interface{}{
+		"name":            m.Name,
+		"description":     m.Description,
+		"enabled":         m.Enabled,
+		"rollout_percent": m.RolloutPercent,
+		"target_uids":     m.TargetUids,
+		"min_version":     m.MinVersion,
+		"max_version":     m.MaxVersion,
+		"updater":         m.Updater,
+		"version":         m.Version,
+	}).Where("flag_key=? and is_deleted=0", m.FlagKey).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// delete 删除开关 开关不存在时返回false
+func (d *db) delete(key string, updater string, version int64) (bool, error) {
+	result, err := d.session.Update("feature_flag").SetMap(map[string]interface{}{
+		"is_deleted": 1,
+		"updater":    updater,
+		"version":    version,
+	}).Where("flag_key=? and is_deleted=0", key).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (d *db) queryWithKey(key string) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("feature_flag").Where("flag_key=? and is_deleted=0", key).Load(&m)
+	return m, err
+}
+
+// queryFlags 所有没有删除的开关
+func (d *db) queryFlags() ([]*model, error) {
+	var models []*model
+	_, err := d.session.Select("*").From("feature_flag").Where("is_deleted=0").Load(&models)
+	return models, err
+}
+
+func (d *db) queryWithPage(keyword string, pageIndex, pageSize uint64) ([]*model, error) {
+	var models []*model
+	_, err := d.flagsWhere(d.session.Select("*").From("feature_flag"), keyword).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryCount(keyword string) (int64, error) {
+	var count int64
+	_, err := d.flagsWhere(d.session.Select("count(*)").From("feature_flag"), keyword).Load(&count)
+	return count, err
+}
+
+func (d *db) flagsWhere(builder *dbr.SelectStmt, keyword string) *dbr.SelectStmt {
+	builder = builder.Where("is_deleted=0")
+	if keyword != "" {
+		builder = builder.Where("(flag_key like ? or name like ?)", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	return builder
+}
+
+func (d *db) queryMaxVersion() (int64, error) {
+	var version int64
+	_, err := d.session.Select("IFNULL(max(version),0)").From("feature_flag").Load(&version)
+	return version, err
+}
+
+type model struct {
+	FlagKey        string
+	Name           string
+	Description    string
+	Enabled        int
+	RolloutPercent int
+	TargetUids     string // 逗号分隔
+	MinVersion     string
+	MaxVersion     string
+	Updater        string
+	IsDeleted      int
+	Version        int64
+	dba.BaseModel
+}
@@ -0,0 +1,165 @@
+package feature
+
+import (
+	"hash/crc32"
+	"strings"
+	"sync"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/util"
+)
+
+// flag 内存中的开关
+type flag struct {
+	key            string
+	enabled        bool
+	rolloutPercent int
+	targetUIDs     map[string]bool
+	minVersion     string
+	maxVersion     string
+}
+
+func newFlag(m *model) *flag {
+	targetUIDs := map[string]bool{}
+	for _, uid := range splitUIDs(m.TargetUids) {
+		targetUIDs[uid] = true
+	}
+	return &flag{
+		key:            m.FlagKey,
+		enabled:        m.Enabled == 1,
+		rolloutPercent: m.RolloutPercent,
+		targetUIDs:     targetUIDs,
+		minVersion:     m.MinVersion,
+		maxVersion:     m.MaxVersion,
+	}
+}
+
+// evaluate 开关对用户是否生效
+// 关闭的开关和不在版本范围内的客户端都不生效 指定的用户不受灰度比例限制
+func (f *flag) evaluate(target Target) bool {
+	if !f.enabled {
+		return false
+	}
+	if !versionInRange(target.Version, f.minVersion, f.maxVersion) {
+		return false
+	}
+	if target.UID != "" && f.targetUIDs[target.UID] {
+		return true
+	}
+	return inRollout(f.key, target.UID, f.rolloutPercent)
+}
+
+// inRollout 用户是否在灰度比例内 按key和uid分桶 比例调大时已经生效的用户保持生效
+func inRollout(key string, uid string, percent int) bool {
+	if percent >= 100 {
+		return true
+	}
+	if percent <= 0 || uid == "" {
+		return false
+	}
+	return int(crc32.ChecksumIEEE([]byte(key+":"+uid))%100) < percent
+}
+
+// versionInRange 客户端版本是否在[min,max]内 没有限制版本时都生效 限制了版本但客户端没有传版本时不生效
+func versionInRange(version string, min string, max string) bool {
+	if min == "" && max == "" {
+		return true
+	}
+	if strings.TrimSpace(version) == "" {
+		return false
+	}
+	if min != "" && util.CompareVersion(version, min) < 0 {
+		return false
+	}
+	if max != "" && util.CompareVersion(version, max) > 0 {
+		return false
+	}
+	return true
+}
+
+func splitUIDs(value string) []string {
+	uids := make([]string, 0)
+	for _, uid := range strings.Split(value, ",") {
+		if uid = strings.TrimSpace(uid); uid != "" {
+			uids = append(uids, uid)
+		}
+	}
+	return uids
+}
+
+// flagSet 内存中的开关 管理后台修改开关后各实例定时检查版本重新加载
+type flagSet struct {
+	lock    sync.RWMutex
+	flags   map[string]*flag
+	version int64
+	loaded  bool
+}
+
+// flags 所有Service共用的开关
+var flags = newFlagSet()
+
+func newFlagSet() *flagSet {
+	return &flagSet{
+		flags: map[string]*flag{},
+	}
+}
+
+// reload 开关版本变化后重新加载 force为true时不比较版本
+func (s *flagSet) reload(d *db, force bool) error {
+	version, err := d.queryMaxVersion()
+	if err != nil {
+		return err
+	}
+	s.lock.RLock()
+	unchanged := s.loaded && s.version == version
+	s.lock.RUnlock()
+	if unchanged && !force {
+		return nil
+	}
+	models, err := d.queryFlags()
+	if err != nil {
+		return err
+	}
+	s.set(models, version)
+	return nil
+}
+
+func (s *flagSet) set(models []*model, version int64) {
+	flagMap := make(map[string]*flag, len(models))
+	for _, m := range models {
+		flagMap[m.FlagKey] = newFlag(m)
+	}
+
+	s.lock.Lock()
+	s.flags = flagMap
+	s.version = version
+	s.loaded = true
+	s.lock.Unlock()
+}
+
+func (s *flagSet) isLoaded() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.loaded
+}
+
+// enabled 开关不存在时不生效
+func (s *flagSet) enabled(key string, target Target) bool {
+	s.lock.RLock()
+	f := s.flags[key]
+	s.lock.RUnlock()
+	if f == nil {
+		return false
+	}
+	return f.evaluate(target)
+}
+
+// evaluate 所有开关的结果
+func (s *flagSet) evaluate(target Target) map[string]bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	result := make(map[string]bool, len(s.flags))
+	for key, f := range s.flags {
+		result[key] = f.evaluate(target)
+	}
+	return result
+}
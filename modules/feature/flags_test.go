@@ -0,0 +1,102 @@
+package feature
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionInRange(t *testing.T) {
+	assert.True(t, versionInRange("", "", ""))
+	assert.False(t, versionInRange("", "1.0.0", ""))
+	assert.True(t, versionInRange("1.0.0", "1.0.0", "2.0.0"))
+	assert.True(t, versionInRange("2.0.0", "1.0.0", "2.0.0"))
+	assert.False(t, versionInRange("0.9.9", "1.0.0", ""))
+	assert.False(t, versionInRange("2.0.1", "", "2.0.0"))
+}
+
+func TestInRollout(t *testing.T) {
+	assert.True(t, inRollout("a", "", 100))
+	assert.False(t, inRollout("a", "u1", 0))
+	assert.False(t, inRollout("a", "", 50))
+
+	// 同一用户的结果固定 比例调大时已经生效的用户保持生效
+	count := 0
+	for i := 0; i < 1000; i++ {
+		uid := string(rune('a'+i%26)) + string(rune('a'+i/26))
+		in := inRollout("message.edit", uid, 30)
+		assert.Equal(t, in, inRollout("message.edit", uid, 30))
+		if in {
+			count++
+			assert.True(t, inRollout("message.edit", uid, 60))
+		}
+	}
+	assert.True(t, count > 200 && count < 400)
+}
+
+func TestFlagEvaluate(t *testing.T) {
+	f := newFlag(&model{
+		FlagKey:        "message.edit",
+		Enabled:        1,
+		RolloutPercent: 0,
+		TargetUids:     "u1, u2",
+		MinVersion:     "1.2.0",
+	})
+	assert.True(t, f.evaluate(Target{UID: "u1", Version: "1.2.0"}))
+	assert.True(t, f.evaluate(Target{UID: "u2", Version: "1.3"}))
+	// 指定的用户也需要满足版本
+	assert.False(t, f.evaluate(Target{UID: "u1", Version: "1.1.9"}))
+	assert.False(t, f.evaluate(Target{UID: "u1"}))
+	assert.False(t, f.evaluate(Target{UID: "u3", Version: "1.2.0"}))
+
+	f.rolloutPercent = 100
+	assert.True(t, f.evaluate(Target{UID: "u3", Version: "1.2.0"}))
+	f.enabled = false
+	assert.False(t, f.evaluate(Target{UID: "u1", Version: "1.2.0"}))
+}
+
+func TestFlagSet(t *testing.T) {
+	s := newFlagSet()
+	assert.False(t, s.isLoaded())
+	s.set([]*model{
+		{FlagKey: "a", Enabled: 1, RolloutPercent: 100},
+		{FlagKey: "b", Enabled: 0, RolloutPercent: 100},
+	}, 1)
+	assert.True(t, s.isLoaded())
+	assert.True(t, s.enabled("a", Target{UID: "u1"}))
+	assert.False(t, s.enabled("b", Target{UID: "u1"}))
+	assert.False(t, s.enabled("c", Target{UID: "u1"}))
+	assert.Equal(t, map[string]bool{"a": true, "b": false}, s.evaluate(Target{UID: "u1"}))
+}
+
+func TestFlagReqToModel(t *testing.T) {
+	m, err := flagReq{
+		Key:            "message.edit",
+		Name:           " 编辑消息 ",
+		Enabled:        1,
+		RolloutPercent: 10,
+		TargetUIDs:     []string{"u1", " u1", "", "u2"},
+		MinVersion:     "1.0.0",
+		MaxVersion:     "2.0.0",
+	}.toModel()
+	assert.NoError(t, err)
+	assert.Equal(t, "编辑消息", m.Name)
+	assert.Equal(t, "u1,u2", m.TargetUids)
+
+	_, err = flagReq{Name: "a", RolloutPercent: 101}.toModel()
+	assert.Error(t, err)
+	_, err = flagReq{Name: "a", Enabled: 2}.toModel()
+	assert.Error(t, err)
+	_, err = flagReq{Name: "a", MinVersion: "2.0", MaxVersion: "1.0"}.toModel()
+	assert.Error(t, err)
+	_, err = flagReq{Name: "a", TargetUIDs: []string{"u1,u2"}}.toModel()
+	assert.Error(t, err)
+	_, err = flagReq{}.toModel()
+	assert.Error(t, err)
+
+	assert.True(t, validKey("message.edit"))
+	assert.True(t, validKey("new_ui2"))
+	assert.False(t, validKey("Message"))
+	assert.False(t, validKey("1abc"))
+	assert.False(t, validKey("a"))
+}
@@ -0,0 +1,87 @@
+package feature
+
+import (
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// IService 功能开关服务
+//
+// 在接口中使用：
+//
+//	if s.featureService.EnabledWithContext(c, "message.edit") {
+//		...
+//	}
+type IService interface {
+	// Enabled 开关对用户是否生效 开关不存在或加载失败时返回false
+	Enabled(key string, target Target) bool
+	// EnabledWithContext 开关对当前请求的用户和客户端版本是否生效
+	EnabledWithContext(c *wkhttp.Context, key string) bool
+	// Evaluate 所有开关对用户的结果
+	Evaluate(target Target) map[string]bool
+}
+
+// Target 计算开关的用户
+type Target struct {
+	UID     string // 用户uid 为空时只有灰度比例为100的开关生效
+	Version string // 客户端版本 开关限制了版本时需要
+}
+
+// TargetWithContext 当前请求的用户和客户端版本 版本取请求头X-App-Version 没有时取查询参数version
+func TargetWithContext(c *wkhttp.Context) Target {
+	version := strings.TrimSpace(c.GetHeader(VersionHeader))
+	if version == "" {
+		version = strings.TrimSpace(c.Query("version"))
+	}
+	return Target{
+		UID:     c.GetLoginUID(),
+		Version: version,
+	}
+}
+
+// Service Service
+type Service struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// NewService NewService
+func NewService(ctx *config.Context) IService {
+	return &Service{
+		ctx: ctx,
+		Log: log.NewTLog("FeatureService"),
+		db:  newDB(ctx),
+	}
+}
+
+// Enabled 开关对用户是否生效
+func (s *Service) Enabled(key string, target Target) bool {
+	s.loadIfNeed()
+	return flags.enabled(key, target)
+}
+
+// EnabledWithContext 开关对当前请求是否生效
+func (s *Service) EnabledWithContext(c *wkhttp.Context, key string) bool {
+	return s.Enabled(key, TargetWithContext(c))
+}
+
+// Evaluate 所有开关对用户的结果
+func (s *Service) Evaluate(target Target) map[string]bool {
+	s.loadIfNeed()
+	return flags.evaluate(target)
+}
+
+func (s *Service) loadIfNeed() {
+	if flags.isLoaded() {
+		return
+	}
+	// 管理模块还没有加载开关
+	if err := flags.reload(s.db, false); err != nil {
+		s.Warn("加载功能开关失败！", zap.Error(err))
+	}
+}
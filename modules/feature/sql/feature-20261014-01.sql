@@ -0,0 +1,22 @@
+-- +migrate Up
+
+-- ##########  功能开关 ##########
+create table `feature_flag`
+(
+    id              integer       not null primary key AUTO_INCREMENT,
+    flag_key        VARCHAR(50)   NOT NULL DEFAULT '' COMMENT '开关的key 客户端和代码中使用',
+    name            VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '名称',
+    description     VARCHAR(500)  NOT NULL DEFAULT '' COMMENT '说明',
+    enabled         smallint      NOT NULL DEFAULT 0  COMMENT '是否开启 关闭时对所有用户都不生效',
+    rollout_percent smallint      NOT NULL DEFAULT 0  COMMENT '灰度比例（0-100） 按uid分桶 同一用户的结果固定',
+    target_uids     text                              COMMENT '指定开启的用户 多个用逗号分隔 不受灰度比例限制',
+    min_version     VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '最低的客户端版本（包含） 为空不限制',
+    max_version     VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '最高的客户端版本（包含） 为空不限制',
+    updater         VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '最后修改的管理员uid',
+    is_deleted      smallint      NOT NULL DEFAULT 0  COMMENT '是否已删除',
+    version         bigint        NOT NULL DEFAULT 0  COMMENT '数据版本 开关变化后重新加载',
+    created_at      timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at      timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX feature_flag_uidx on `feature_flag` (flag_key);
+CREATE INDEX feature_flag_version_idx on `feature_flag` (version);
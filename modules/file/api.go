@@ -1,15 +1,25 @@
 package file
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/redis"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -22,16 +32,36 @@ import (
 type File struct {
 	ctx *config.Context
 	log.Log
-	service IService
+	service         IService
+	db              *db
+	tusCache        tusLockCache // 断点续传的上传锁
+	thumbnailWorker *thumbnailWorker
+	transcodeWorker *transcodeWorker
+	auditLogger     *auditLogger
+	tenantService   *tenant.Service
+	shareCache      sharePwdCache // 分享密码错误次数
+	// 生命周期任务是否正在执行
+	lifecycleRunning atomic.Bool
 }
 
 // New New
 func New(ctx *config.Context) *File {
-	return &File{
-		ctx:     ctx,
-		Log:     log.NewTLog("File"),
-		service: NewService(ctx),
+	service := NewService(ctx)
+	fileDB := newDB(ctx)
+	f := &File{
+		ctx:             ctx,
+		Log:             log.NewTLog("File"),
+		service:         service,
+		db:              fileDB,
+		tusCache:        redis.New(ctx.GetConfig().DB.RedisAddr, ctx.GetConfig().DB.RedisPass),
+		thumbnailWorker: newThumbnailWorker(service, fileDB),
+		transcodeWorker: newTranscodeWorker(ctx, service, fileDB),
+		auditLogger:     newAuditLogger(fileDB),
+		tenantService:   tenant.NewService(ctx),
+		shareCache:      ctx.GetRedisConn(),
 	}
+	uploader = f
+	return f
 }
 
 // Route 路由
@@ -43,6 +73,12 @@ func (f *File) Route(r *wkhttp.WKHttp) {
 		api.POST("/compose/*path", f.makeImageCompose)
 		// 获取文件
 		api.GET("/preview/*path", f.getFile)
+		api.Handle(http.MethodHead, "/preview/*path", r.WKHttpHandler(f.getFile))
+		// 分享链接 不需要登录
+		api.GET("/share/:share_no", f.getShare)
+		api.GET("/share/:share_no/download", f.downloadShare)
+		api.POST("/share/:share_no/download", f.downloadShare)
+		api.GET("/share/:share_no/content", f.shareContent)
 	}
 	auth := r.Group("/v1/file", f.ctx.AuthMiddleware(r))
 	{
@@ -50,6 +86,63 @@ func (f *File) Route(r *wkhttp.WKHttp) {
 		auth.GET("/upload", f.getFilePath)
 		//上传文件
 		auth.POST("/upload", f.uploadFile)
+		//上传前检查是否已有相同内容的文件（秒传）
+		auth.GET("/upload/check", f.checkUpload)
+		//使用已有的相同内容的文件（秒传）
+		auth.POST("/upload/claim", f.claimUpload)
+		//获取自己的存储空间
+		auth.GET("/quota", f.getQuota)
+		//获取文件信息
+		auth.GET("/info", f.getFileInfo)
+		//获取视频转码状态
+		auth.GET("/transcode", f.getTranscode)
+		//获取文件的签名地址
+		auth.GET("/sign", f.getSignURL)
+		//获取直传文件地址
+		auth.GET("/upload/presign", f.getPresignUploadURL)
+		//获取直传文件的临时凭证
+		auth.GET("/upload/credentials", f.getUploadCredentials)
+		// 断点续传（tus协议）
+		auth.POST("/tus", f.tusCreate)
+		auth.Handle(http.MethodHead, "/tus/:id", r.WKHttpHandler(f.tusHead))
+		auth.Handle(http.MethodPatch, "/tus/:id", r.WKHttpHandler(f.tusPatch))
+		auth.DELETE("/tus/:id", f.tusDelete)
+		//生成文件的分享链接
+		auth.POST("/share", f.createShare)
+		//查询自己的分享
+		auth.GET("/shares", f.getShares)
+		//取消分享
+		auth.DELETE("/share/:share_no", f.deleteShare)
+	}
+	api.Handle(http.MethodOptions, "/tus", r.WKHttpHandler(f.tusOptions))
+
+	manager := r.Group("/v1/manager/file", f.ctx.AuthMiddleware(r))
+	{
+		manager.GET("/quota", f.managerGetUserQuota)                    // 查询用户的存储空间
+		manager.PUT("/quota", f.managerUpdateUserQuota)                 // 设置用户的配额
+		manager.GET("/quota/roles", f.managerGetRoleQuotas)             // 查询角色的配额
+		manager.PUT("/quota/role", f.managerUpdateRoleQuota)            // 设置角色的配额
+		manager.GET("/lifecycle/report", f.managerLifecycleReport)      // 预览生命周期规则会处理的文件
+		manager.GET("/lifecycle/rules", f.managerLifecycleRules)        // 查询生命周期规则
+		manager.PUT("/lifecycle/rule", f.managerUpdateLifecycleRule)    // 设置频道的生命周期规则
+		manager.DELETE("/lifecycle/rule", f.managerDeleteLifecycleRule) // 删除频道的生命周期规则
+		manager.GET("/audit/channels", f.managerAuditChannels)          // 查询需要审计的频道
+		manager.POST("/audit/channel", f.managerAddAuditChannel)        // 添加需要审计的频道
+		manager.DELETE("/audit/channel", f.managerDeleteAuditChannel)   // 删除需要审计的频道
+		manager.GET("/audit/logs", f.managerAuditLogs)                  // 查询文件访问记录
+	}
+
+	f.ctx.Schedule(extconfig.Get().Tus.CleanInterval, f.cleanExpiredTusUploads) // 清理过期未完成的断点续传
+	// 视频转码
+	f.transcodeWorker.start()
+	// 文件生命周期
+	if extconfig.Get().Lifecycle.Enable {
+		f.ctx.Schedule(extconfig.Get().Lifecycle.Interval, f.lifecycleJob)
+	}
+	// 文件访问审计
+	if extconfig.Get().Audit.Enable {
+		f.auditLogger.refreshChannels()
+		f.ctx.Schedule(extconfig.Get().Audit.RefreshInterval, f.auditLogger.refreshChannels)
 	}
 }
 
@@ -57,7 +150,7 @@ func (f *File) makeImageCompose(c *wkhttp.Context) {
 	var imageURLs []string
 	if err := c.BindJSON(&imageURLs); err != nil {
 		f.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if len(imageURLs) <= 0 {
@@ -127,63 +220,298 @@ func (f *File) uploadFile(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
-	file, _, err := c.Request.FormFile("file")
+	file, fileHeader, err := c.Request.FormFile("file")
 	if err != nil {
 		f.Error("读取文件失败！", zap.Error(err))
-		c.ResponseError(errors.New("读取文件失败！"))
+		c.ResponseError(errcode.ErrReadFile)
 		return
 	}
 	path := uploadPath
 	if !strings.HasPrefix(path, "/") {
 		path = fmt.Sprintf("/%s", path)
 	}
+	defer file.Close()
+	// 客户端加密的文件 内容为密文
+	encryption, err := parseEncryption(c.PostForm)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	resp, err := f.saveFile(&SaveFileReq{
+		UID:         c.GetLoginUID(),
+		Role:        c.GetLoginRole(),
+		FileType:    Type(fileType),
+		Path:        path,
+		Name:        fileHeader.Filename,
+		ContentType: contentType,
+		Content:     file,
+		Size:        fileHeader.Size,
+		Signature:   signatureInt == 1,
+		encryption:  encryption,
+	})
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(resp)
+}
+
+// saveFile 保存上传的文件 去重、配额、缩略图、转码和语音波形的处理与上传接口相同
+func (f *File) saveFile(req *SaveFileReq) (map[string]interface{}, error) {
+	fileType := string(req.FileType)
+	path := req.Path
+	contentType := req.ContentType
+	encryption := req.encryption
+	// 图片去掉GPS等元数据后再保存
+	var content = req.Content
+	size := req.Size
+	if encryption == nil {
+		if stripped := f.privacySafeImage(req.FileType, path, contentType, req.Content, size); stripped != nil {
+			content = bytes.NewReader(stripped)
+			size = int64(len(stripped))
+		}
+	}
+	// 一次读取同时计算去重用的sha256和需要返回的sha512
+	hashWriter := sha256.New()
+	var signWriter hash.Hash
+	writers := []io.Writer{hashWriter}
+	if req.Signature {
+		signWriter = sha512.New()
+		writers = append(writers, signWriter)
+	}
+	_, err := io.Copy(io.MultiWriter(writers...), content)
+	if err != nil {
+		f.Error("读取文件错误", zap.Error(err))
+		return nil, errors.New("读取文件错误")
+	}
 	var sign []byte
-	if signatureInt == 1 {
-		// bytes, err := ioutil.ReadAll(file)
-		// if err != nil {
-		// 	f.Error("读取文件错误", zap.Error(err))
-		// 	c.ResponseError(errors.New("读取文件错误"))
-		// 	return
-		// }
-		h := sha512.New()
-		_, err := io.Copy(h, file)
-		if err != nil {
-			f.Error("签名复制文件错误", zap.Error(err))
-			c.ResponseError(errors.New("签名复制文件错误"))
-			return
+	if signWriter != nil {
+		sign = signWriter.Sum(nil)
+	}
+	fileM := &fileModel{
+		UID:         req.UID,
+		FileType:    fileType,
+		Path:        fmt.Sprintf("%s%s", fileType, path),
+		Name:        req.Name,
+		Size:        size,
+		ContentType: contentType,
+		Hash:        hex.EncodeToString(hashWriter.Sum(nil)),
+		TenantID:    f.userTenant(req.UID),
+	}
+	if encryption != nil {
+		if err = encryption.verify(fileM.Hash); err != nil {
+			return nil, err
 		}
-		sign = h.Sum(nil)
-		//	sign = sha512.Sum512(bytes)
-
+		encryption.applyTo(fileM)
 	}
-	_, err = f.service.UploadFile(fmt.Sprintf("%s%s", fileType, path), contentType, func(w io.Writer) error {
-		_, err := file.Seek(0, io.SeekStart)
-		if err != nil {
-			f.Error("设置文件偏移量错误", zap.Error(err))
+	if err = f.checkQuota(fileM.UID, req.Role, fileM.Size); err != nil {
+		return nil, err
+	}
+	// 已有相同内容的文件时不再上传 返回已有文件的路径
+	blob, err := f.reuseBlob(fileM)
+	if err != nil {
+		f.Warn("查询相同内容的文件失败！", zap.String("path", fileM.Path), zap.Error(err))
+	}
+	var resp map[string]interface{}
+	if blob != nil {
+		resp = map[string]interface{}{
+			"path":  fmt.Sprintf("file/preview/%s", blob.Path),
+			"dedup": 1,
+		}
+	} else {
+		_, err = f.service.UploadFile(fileM.Path, contentType, func(w io.Writer) error {
+			_, err := content.Seek(0, io.SeekStart)
+			if err != nil {
+				f.Error("设置文件偏移量错误", zap.Error(err))
+				return err
+			}
+			_, err = io.Copy(w, content)
 			return err
+		})
+		if err != nil {
+			f.Error("上传文件失败！", zap.Error(err))
+			return nil, errcode.ErrUploadFile
+		}
+		f.addQuotaUsed(fileM.UID, fileM.Size)
+		err = f.db.insertFile(fileM)
+		if err != nil {
+			f.Warn("添加文件记录失败！", zap.String("path", path), zap.Error(err))
+		} else {
+			f.registerBlob(fileM)
+		}
+		resp = map[string]interface{}{
+			"path": fmt.Sprintf("file/preview/%s", fileM.Path),
+		}
+		// 加密的文件服务端无法解析 不生成缩略图和转码
+		if err == nil && fileM.Encrypted == 0 {
+			// 图片异步生成缩略图 缩略图生成前通过缩略图地址访问的是原图
+			if thumbnails := f.thumbnailWorker.enqueue(req.FileType, fileM.Path, req.Name, contentType); thumbnails != nil {
+				for size, thumbnailURL := range thumbnails {
+					thumbnails[size] = f.service.SignURL(thumbnailURL, req.UID)
+				}
+				resp["thumbnails"] = thumbnails
+			}
+			// 视频异步转码 客户端可通过/v1/file/transcode查询转码状态
+			if transcode := f.transcodeWorker.enqueue(req.FileType, req.UID, fileM.Path, req.Name, contentType); transcode != nil {
+				resp["transcode"] = transcode
+			}
+		}
+	}
+	// 语音计算时长和波形 客户端发送消息时带上
+	if fileM.Encrypted == 0 {
+		if waveform := f.voiceWaveform(req.FileType, fileM.Path, contentType, content); waveform != nil {
+			resp["duration"] = waveform.Duration
+			resp["waveform"] = waveform.Waveform
 		}
-		_, err = io.Copy(w, file)
-		return err
+	} else {
+		resp["encryption"] = fileM.encryptionInfo()
+	}
+	if fileSignRequired(fileM.Path) {
+		// path用于发送消息 url为带签名的访问地址
+		resp["url"] = f.service.SignURL(resp["path"].(string), req.UID)
+	}
+	if req.Signature {
+		encoded := base64.StdEncoding.EncodeToString(sign[:])
+		fmt.Print("编码文件", encoded)
+		resp["sha512"] = encoded
+	}
+	return resp, nil
+}
+
+// 获取文件信息（图片的尺寸、blurhash和缩略图）
+func (f *File) getFileInfo(c *wkhttp.Context) {
+	ph := strings.TrimPrefix(strings.TrimPrefix(c.Query("path"), "/"), "file/preview/")
+	if ph == "" {
+		c.ResponseError(errcode.ErrFilePathRequired)
+		return
+	}
+	resp, err := f.service.GetFileInfo(ph, c.GetLoginUID())
+	if err != nil {
+		f.Error("查询文件记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询文件记录失败！"))
+		return
+	}
+	if resp == nil {
+		c.ResponseError(errcode.ErrFileNotExist)
+		return
+	}
+	c.Response(resp)
+}
+
+// 获取文件的签名地址 签名地址过期后客户端通过此接口重新获取
+func (f *File) getSignURL(c *wkhttp.Context) {
+	fileURL := c.Query("path")
+	if fileURL == "" {
+		c.ResponseError(errcode.ErrFilePathRequired)
+		return
+	}
+	if !strings.Contains(fileURL, filePreviewPrefix) {
+		fileURL = filePreviewPrefix + strings.TrimPrefix(fileURL, "/")
+	}
+	c.Response(map[string]interface{}{
+		"url":    f.service.SignURL(fileURL, c.GetLoginUID()),
+		"expire": int64(extconfig.Get().FileSign.Expire.Seconds()),
 	})
+}
 
-	defer file.Close()
+// tokenUID 通过token获取登录用户 token无效时返回空
+func (f *File) tokenUID(token string) string {
+	if token == "" {
+		return ""
+	}
+	uidAndName, err := f.ctx.Cache().Get(f.ctx.GetConfig().Cache.TokenCachePrefix + token)
 	if err != nil {
-		f.Error("上传文件失败！", zap.Error(err))
-		c.ResponseError(errors.New("上传文件失败！"))
+		f.Warn("获取登录信息失败！", zap.Error(err))
+		return ""
+	}
+	return strings.Split(uidAndName, "@")[0]
+}
+
+// 获取视频转码状态
+func (f *File) getTranscode(c *wkhttp.Context) {
+	ph := strings.TrimPrefix(strings.TrimPrefix(c.Query("path"), "/"), "file/preview/")
+	if ph == "" {
+		c.ResponseError(errcode.ErrFilePathRequired)
 		return
 	}
-	if signatureInt == 1 {
-		encoded := base64.StdEncoding.EncodeToString(sign[:])
-		fmt.Print("编码文件", encoded)
-		c.Response(map[string]interface{}{
-			"path":   fmt.Sprintf("file/preview/%s%s", fileType, path),
-			"sha512": encoded,
-		})
-	} else {
-		c.Response(map[string]string{
-			"path": fmt.Sprintf("file/preview/%s%s", fileType, path),
-		})
+	transcodeM, err := f.db.queryTranscodeWithPath(ph)
+	if err != nil {
+		f.Error("查询转码任务失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询转码任务失败！"))
+		return
+	}
+	if transcodeM == nil {
+		c.ResponseError(errors.New("转码任务不存在！"))
+		return
+	}
+	resp := map[string]interface{}{
+		"path":   fmt.Sprintf("file/preview/%s", transcodeM.Path),
+		"status": transcodeM.Status,
 	}
+	switch transcodeM.Status {
+	case TranscodeStatusSuccess:
+		resp["url"] = f.service.SignURL(fmt.Sprintf("file/preview/%s", transcodeM.OutputPath), c.GetLoginUID())
+		resp["cover"] = f.service.SignURL(fmt.Sprintf("file/preview/%s", transcodeM.PosterPath), c.GetLoginUID())
+		resp["width"] = transcodeM.Width
+		resp["height"] = transcodeM.Height
+		resp["second"] = transcodeM.Duration
+	case TranscodeStatusFailed:
+		resp["err_msg"] = transcodeM.ErrMsg
+	}
+	c.Response(resp)
+}
+
+// 获取直传文件地址 客户端通过返回的url直接PUT文件到对象存储
+func (f *File) getPresignUploadURL(c *wkhttp.Context) {
+	uploadPath := c.Query("path")
+	fileType := c.Query("type")
+	contentType := c.Query("contenttype")
+	err := f.checkReq(Type(fileType), uploadPath)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	path := uploadPath
+	if !strings.HasPrefix(path, "/") {
+		path = fmt.Sprintf("/%s", path)
+	}
+	uploadURL, expire, err := f.service.PresignUploadURL(fmt.Sprintf("%s%s", fileType, path), contentType)
+	if err != nil {
+		f.Error("获取直传文件地址失败！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.Response(map[string]interface{}{
+		"url":    uploadURL,
+		"method": http.MethodPut,
+		"expire": int64(expire.Seconds()),
+		"path":   fmt.Sprintf("file/preview/%s%s", fileType, path),
+	})
+}
+
+// 获取直传文件的临时凭证 客户端使用oss/cos的sdk直接上传文件
+func (f *File) getUploadCredentials(c *wkhttp.Context) {
+	uploadPath := c.Query("path")
+	fileType := c.Query("type")
+	err := f.checkReq(Type(fileType), uploadPath)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	path := uploadPath
+	if !strings.HasPrefix(path, "/") {
+		path = fmt.Sprintf("/%s", path)
+	}
+	credentials, err := f.service.UploadCredentials(fmt.Sprintf("%s%s", fileType, path))
+	if err != nil {
+		f.Error("获取直传文件的临时凭证失败！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.Response(map[string]interface{}{
+		"credentials": credentials,
+		"path":        fmt.Sprintf("file/preview/%s%s", fileType, path),
+	})
 }
 
 // 获取文件
@@ -193,6 +521,44 @@ func (f *File) getFile(c *wkhttp.Context) {
 		c.Response(errors.New("访问路径不能为空"))
 		return
 	}
+	ph, ok := cleanFilePath(ph)
+	if !ok {
+		c.ResponseErrorWithStatus(errors.New("访问路径错误！"), http.StatusBadRequest)
+		return
+	}
+	if fileSignRequired(ph) {
+		loginUID := ""
+		if c.Query(signQueryUID) != "" {
+			loginUID = f.tokenUID(c.GetHeader("token"))
+		}
+		if err := verifyFileSign(ph, c.Request.URL.Query(), loginUID, time.Now()); err != nil {
+			c.ResponseErrorWithStatus(err, http.StatusForbidden)
+			return
+		}
+	}
+	f.auditDownload(c, ph)
+	size := c.Query("size")
+	// 图片可能有webp、avif格式 按Accept返回
+	negotiate := extconfig.Get().Variant.Enable && isThumbnailImage(ph, "")
+	if size != "" || negotiate {
+		fileM, err := f.db.queryFileWithPath(strings.TrimPrefix(ph, "/"))
+		if err != nil {
+			f.Warn("查询文件记录失败！", zap.Error(err))
+		} else if fileM != nil {
+			// 访问缩略图 没有该尺寸的缩略图（生成中或原图更小）时访问原图
+			thumbSize := 0
+			if thumbPath := fileM.thumbnailMap()[size]; size != "" && thumbPath != "" {
+				ph = fmt.Sprintf("/%s", thumbPath)
+				thumbSize, _ = strconv.Atoi(size)
+			}
+			if negotiate {
+				c.Header("Vary", "Accept")
+				if format := acceptedVariant(c.GetHeader("Accept"), fileM.variantFormats(thumbSize)); format != "" {
+					ph = variantPath(ph, format)
+				}
+			}
+		}
+	}
 	filename := c.Query("filename")
 	if filename == "" {
 		paths := strings.Split(ph, "/")
@@ -200,11 +566,19 @@ func (f *File) getFile(c *wkhttp.Context) {
 			filename = paths[len(paths)-1]
 		}
 	}
+	if cdnEnabled(ph) {
+		c.Redirect(http.StatusFound, cdnURL(ph, time.Now()))
+		return
+	}
 	downloadURL, err := f.service.DownloadURL(ph, filename)
 	if err != nil {
 		c.ResponseError(err)
 		return
 	}
+	if streamEnabled(ph) {
+		f.streamFile(c, downloadURL)
+		return
+	}
 	c.Redirect(http.StatusFound, downloadURL)
 }
 
@@ -0,0 +1,512 @@
+package file
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 断点续传 tus协议（https://tus.io/protocols/resumable-upload） 支持 creation expiration termination 扩展
+const (
+	tusResumable   = "1.0.0"
+	tusExtension   = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+)
+
+const (
+	tusLockPrefix = "fileTusLock:"
+	// tusLockExpire 上传锁的过期时间 上传分片时定时续期 实例异常退出后自动释放
+	tusLockExpire = time.Minute
+)
+
+// errTusLocked 同一个上传有其他请求正在写入
+var errTusLocked = errors.New("上传正在进行中，请稍后重试！")
+
+// tusLockCache 断点续传的上传锁 多个实例时同一个上传同时也只能有一个请求写入
+type tusLockCache interface {
+	SetNX(key string, value interface{}, expire time.Duration) (bool, error)
+	DelIfValue(key string, value string) error
+	ExpireIfValue(key string, value string, expire time.Duration) error
+}
+
+// tus协议 获取服务端支持的协议版本和扩展
+func (f *File) tusOptions(c *wkhttp.Context) {
+	c.Header("Tus-Resumable", tusResumable)
+	c.Header("Tus-Version", tusResumable)
+	c.Header("Tus-Extension", tusExtension)
+	c.Header("Tus-Max-Size", strconv.FormatInt(tusMaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// tus协议 创建上传 Upload-Metadata需要包含type和path（与普通上传的参数相同） 可选filename和filetype 客户端加密的文件带上encrypted、encrypt_alg、key_wrap和cipher_hash
+func (f *File) tusCreate(c *wkhttp.Context) {
+	if !f.checkTusResumable(c) {
+		return
+	}
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		f.tusError(c, http.StatusBadRequest, errors.New("Upload-Length有误！"))
+		return
+	}
+	if size > tusMaxSize() {
+		f.tusError(c, http.StatusRequestEntityTooLarge, errors.New("文件太大！"))
+		return
+	}
+	if err = f.checkQuota(c.GetLoginUID(), c.GetLoginRole(), size); err != nil {
+		f.tusError(c, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	metadata := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	fileType := metadata["type"]
+	uploadPath := metadata["path"]
+	err = f.checkReq(Type(fileType), uploadPath)
+	if err != nil {
+		f.tusError(c, http.StatusBadRequest, err)
+		return
+	}
+	path := uploadPath
+	if !strings.HasPrefix(path, "/") {
+		path = fmt.Sprintf("/%s", path)
+	}
+	name := metadata["filename"]
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	contentType := metadata["filetype"]
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	encryption, err := parseEncryption(func(key string) string { return metadata[key] })
+	if err != nil {
+		f.tusError(c, http.StatusBadRequest, err)
+		return
+	}
+	uploadID := util.GenerUUID()
+	tusCfg := extconfig.Get().Tus
+	err = os.MkdirAll(tusCfg.Dir, os.ModePerm)
+	if err != nil {
+		f.Error("创建断点续传目录失败！", zap.String("dir", tusCfg.Dir), zap.Error(err))
+		f.tusError(c, http.StatusInternalServerError, errors.New("创建上传失败！"))
+		return
+	}
+	chunkFile, err := os.Create(tusChunkPath(uploadID))
+	if err != nil {
+		f.Error("创建断点续传文件失败！", zap.Error(err))
+		f.tusError(c, http.StatusInternalServerError, errors.New("创建上传失败！"))
+		return
+	}
+	chunkFile.Close()
+	expiredAt := time.Now().Add(tusCfg.Expire)
+	upload := &uploadModel{
+		UploadID:    uploadID,
+		UID:         c.GetLoginUID(),
+		FileType:    fileType,
+		Path:        fmt.Sprintf("%s%s", fileType, path),
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		Status:      uploadStatusUploading,
+		ExpiredAt:   expiredAt,
+		Node:        tusNode(),
+	}
+	if encryption != nil {
+		upload.Encrypted = 1
+		upload.EncryptAlg = encryption.Alg
+		upload.KeyWrap = encryption.KeyWrap
+		upload.CipherHash = encryption.CipherHash
+	}
+	err = f.db.insertUpload(upload)
+	if err != nil {
+		os.Remove(tusChunkPath(uploadID))
+		f.Error("添加上传记录失败！", zap.Error(err))
+		f.tusError(c, http.StatusInternalServerError, errors.New("创建上传失败！"))
+		return
+	}
+	if size == 0 {
+		// 空文件直接完成
+		if err := f.completeTusUpload(upload); err != nil {
+			f.tusError(c, http.StatusInternalServerError, errcode.ErrUploadFile)
+			return
+		}
+	}
+	c.Header("Location", fmt.Sprintf("%s/file/tus/%s", f.ctx.GetConfig().External.APIBaseURL, uploadID))
+	c.Header("Upload-Expires", expiredAt.UTC().Format(http.TimeFormat))
+	c.Header("Upload-File-Path", fmt.Sprintf("file/preview/%s", upload.Path))
+	c.Status(http.StatusCreated)
+}
+
+// tus协议 获取已上传的大小
+func (f *File) tusHead(c *wkhttp.Context) {
+	if !f.checkTusResumable(c) {
+		return
+	}
+	upload := f.getTusUpload(c)
+	if upload == nil {
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.UploadOffset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	if upload.Status == uploadStatusUploading {
+		c.Header("Upload-Expires", upload.ExpiredAt.UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusOK)
+}
+
+// tus协议 从Upload-Offset开始追加分片 全部上传后保存到文件服务
+func (f *File) tusPatch(c *wkhttp.Context) {
+	if !f.checkTusResumable(c) {
+		return
+	}
+	if c.ContentType() != tusContentType {
+		f.tusError(c, http.StatusUnsupportedMediaType, fmt.Errorf("Content-Type必须为%s！", tusContentType))
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		f.tusError(c, http.StatusBadRequest, errors.New("Upload-Offset有误！"))
+		return
+	}
+	uploadID := c.Param("id")
+	release, err := f.lockTusUpload(uploadID)
+	if err != nil {
+		f.tusLockError(c, uploadID, err)
+		return
+	}
+	defer release()
+
+	upload := f.getTusUpload(c)
+	if upload == nil {
+		return
+	}
+	if offset != upload.UploadOffset {
+		f.tusError(c, http.StatusConflict, errors.New("Upload-Offset与已上传的大小不一致！"))
+		return
+	}
+	if upload.Status == uploadStatusUploading && offset < upload.Size {
+		chunkFile, err := os.OpenFile(tusChunkPath(uploadID), os.O_WRONLY, 0644)
+		if err != nil {
+			f.Error("打开断点续传文件失败！", zap.String("uploadID", uploadID), zap.Error(err))
+			f.tusError(c, http.StatusInternalServerError, errors.New("上传分片失败！"))
+			return
+		}
+		// 丢弃上次中断时写入但没有记录的数据
+		if err = chunkFile.Truncate(offset); err == nil {
+			_, err = chunkFile.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			chunkFile.Close()
+			f.Error("设置断点续传文件偏移量失败！", zap.String("uploadID", uploadID), zap.Error(err))
+			f.tusError(c, http.StatusInternalServerError, errors.New("上传分片失败！"))
+			return
+		}
+		// 连接中断时保留已写入的数据 客户端可以从新的偏移量继续上传
+		n, copyErr := io.Copy(chunkFile, io.LimitReader(c.Request.Body, upload.Size-offset))
+		chunkFile.Close()
+		upload.UploadOffset = offset + n
+		upload.ExpiredAt = time.Now().Add(extconfig.Get().Tus.Expire)
+		err = f.db.updateUploadOffset(uploadID, upload.UploadOffset, upload.ExpiredAt)
+		if err != nil {
+			f.Error("更新已上传的大小失败！", zap.String("uploadID", uploadID), zap.Error(err))
+			f.tusError(c, http.StatusInternalServerError, errors.New("上传分片失败！"))
+			return
+		}
+		if copyErr != nil {
+			f.Warn("上传分片中断", zap.String("uploadID", uploadID), zap.Int64("offset", upload.UploadOffset), zap.Error(copyErr))
+			f.tusError(c, http.StatusInternalServerError, errors.New("上传分片中断！"))
+			return
+		}
+	}
+	if upload.Status == uploadStatusUploading && upload.UploadOffset == upload.Size {
+		// 保存到文件服务失败时客户端可以用相同的Upload-Offset重试
+		if err := f.completeTusUpload(upload); err != nil {
+			f.tusError(c, http.StatusInternalServerError, errcode.ErrUploadFile)
+			return
+		}
+		c.Header("Upload-File-Path", fmt.Sprintf("file/preview/%s", upload.Path))
+	} else if upload.Status == uploadStatusUploading {
+		c.Header("Upload-Expires", upload.ExpiredAt.UTC().Format(http.TimeFormat))
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(upload.UploadOffset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// tus协议 取消上传
+func (f *File) tusDelete(c *wkhttp.Context) {
+	if !f.checkTusResumable(c) {
+		return
+	}
+	uploadID := c.Param("id")
+	release, err := f.lockTusUpload(uploadID)
+	if err != nil {
+		f.tusLockError(c, uploadID, err)
+		return
+	}
+	defer release()
+
+	upload := f.getTusUpload(c)
+	if upload == nil {
+		return
+	}
+	if upload.Status == uploadStatusCompleted {
+		f.tusError(c, http.StatusBadRequest, errors.New("文件已上传完成！"))
+		return
+	}
+	err = f.db.deleteUpload(uploadID)
+	if err != nil {
+		f.Error("删除上传记录失败！", zap.String("uploadID", uploadID), zap.Error(err))
+		f.tusError(c, http.StatusInternalServerError, errors.New("取消上传失败！"))
+		return
+	}
+	f.removeTusChunk(uploadID)
+	c.Status(http.StatusNoContent)
+}
+
+// completeTusUpload 保存到文件服务并添加文件记录
+func (f *File) completeTusUpload(upload *uploadModel) error {
+	chunkPath := tusChunkPath(upload.UploadID)
+	_, err := f.service.UploadFile(upload.Path, upload.ContentType, func(w io.Writer) error {
+		chunkFile, err := os.Open(chunkPath)
+		if err != nil {
+			return err
+		}
+		defer chunkFile.Close()
+		_, err = io.Copy(w, chunkFile)
+		return err
+	})
+	if err != nil {
+		f.Error("断点续传保存到文件服务失败！", zap.String("uploadID", upload.UploadID), zap.String("path", upload.Path), zap.Error(err))
+		return err
+	}
+	fileM := &fileModel{
+		UID:         upload.UID,
+		FileType:    upload.FileType,
+		Path:        upload.Path,
+		Name:        upload.Name,
+		Size:        upload.Size,
+		ContentType: upload.ContentType,
+		TenantID:    f.userTenant(upload.UID),
+	}
+	// 断点续传创建时已返回了文件路径 所以不使用已有的相同文件 只记录文件内容供之后秒传
+	if chunkFile, err := os.Open(chunkPath); err == nil {
+		fileM.Hash, err = fileHash(chunkFile)
+		chunkFile.Close()
+		if err != nil {
+			f.Warn("计算文件hash失败！", zap.String("uploadID", upload.UploadID), zap.Error(err))
+		}
+	}
+	if upload.Encrypted == 1 {
+		encryption := &fileEncryption{Alg: upload.EncryptAlg, KeyWrap: upload.KeyWrap, CipherHash: upload.CipherHash}
+		if fileM.Hash != "" {
+			// 已保存到文件服务 不一致时以实际内容的hash为准
+			if err := encryption.verify(fileM.Hash); err != nil {
+				f.Warn("断点续传的密文hash不一致！", zap.String("uploadID", upload.UploadID), zap.String("cipherHash", upload.CipherHash), zap.String("hash", fileM.Hash))
+				encryption.CipherHash = ""
+			}
+		}
+		encryption.applyTo(fileM)
+	}
+	err = f.db.completeUpload(upload.UploadID, fileM)
+	if err != nil {
+		f.Error("更新上传记录失败！", zap.String("uploadID", upload.UploadID), zap.Error(err))
+		return err
+	}
+	f.registerBlob(fileM)
+	f.addQuotaUsed(fileM.UID, fileM.Size)
+	upload.Status = uploadStatusCompleted
+	f.removeTusChunk(upload.UploadID)
+	if fileM.Encrypted == 0 {
+		f.thumbnailWorker.enqueue(Type(upload.FileType), upload.Path, upload.Name, upload.ContentType)
+		f.transcodeWorker.enqueue(Type(upload.FileType), upload.UID, upload.Path, upload.Name, upload.ContentType)
+	}
+	return nil
+}
+
+// cleanExpiredTusUploads 删除过期未完成的上传 dir不共享时每个实例只清理自己保存的分片
+func (f *File) cleanExpiredTusUploads() {
+	node := ""
+	if !extconfig.Get().Tus.Shared {
+		node = tusNode()
+	}
+	for {
+		uploads, err := f.db.queryExpiredUploads(time.Now(), node, 100)
+		if err != nil {
+			f.Error("查询过期的上传失败！", zap.Error(err))
+			return
+		}
+		deleted := 0
+		for _, upload := range uploads {
+			release, err := f.lockTusUpload(upload.UploadID)
+			if err != nil {
+				// 正在上传的下次再清理
+				if err != errTusLocked {
+					f.Warn("获取上传锁失败！", zap.String("uploadID", upload.UploadID), zap.Error(err))
+				}
+				continue
+			}
+			err = f.db.deleteUpload(upload.UploadID)
+			if err == nil {
+				f.removeTusChunk(upload.UploadID)
+				deleted++
+			}
+			release()
+			if err != nil {
+				f.Error("删除过期的上传失败！", zap.String("uploadID", upload.UploadID), zap.Error(err))
+				return
+			}
+		}
+		if len(uploads) < 100 || deleted == 0 {
+			return
+		}
+	}
+}
+
+// lockTusUpload 获取上传锁 有其他请求正在写入时返回errTusLocked 返回释放锁的方法
+func (f *File) lockTusUpload(uploadID string) (func(), error) {
+	key := tusLockPrefix + uploadID
+	value := util.GenerUUID()
+	ok, err := f.tusCache.SetNX(key, value, tusLockExpire)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errTusLocked
+	}
+	// 上传大的分片时定时续期
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(tusLockExpire / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := f.tusCache.ExpireIfValue(key, value, tusLockExpire); err != nil {
+					f.Warn("上传锁续期失败！", zap.String("uploadID", uploadID), zap.Error(err))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		if err := f.tusCache.DelIfValue(key, value); err != nil {
+			f.Warn("释放上传锁失败！", zap.String("uploadID", uploadID), zap.Error(err))
+		}
+	}, nil
+}
+
+func (f *File) tusLockError(c *wkhttp.Context, uploadID string, err error) {
+	if err == errTusLocked {
+		f.tusError(c, http.StatusLocked, err)
+		return
+	}
+	f.Error("获取上传锁失败！", zap.String("uploadID", uploadID), zap.Error(err))
+	f.tusError(c, http.StatusInternalServerError, errors.New("获取上传锁失败！"))
+}
+
+// getTusUpload 获取当前用户的上传 不存在时返回错误
+func (f *File) getTusUpload(c *wkhttp.Context) *uploadModel {
+	upload, err := f.db.queryUpload(c.Param("id"))
+	if err != nil {
+		f.Error("查询上传记录失败！", zap.Error(err))
+		f.tusError(c, http.StatusInternalServerError, errors.New("查询上传记录失败！"))
+		return nil
+	}
+	if upload == nil || upload.UID != c.GetLoginUID() {
+		f.tusError(c, http.StatusNotFound, errors.New("上传不存在！"))
+		return nil
+	}
+	if upload.Status == uploadStatusUploading && upload.ExpiredAt.Before(time.Now()) {
+		f.tusError(c, http.StatusGone, errors.New("上传已过期！"))
+		return nil
+	}
+	if upload.Status == uploadStatusUploading && !tusLocal(upload) {
+		// 分片保存在其他实例 在当前实例继续写入会丢失已上传的数据
+		f.Warn("断点续传的请求没有到保存分片的实例！", zap.String("uploadID", upload.UploadID), zap.String("node", upload.Node), zap.String("currentNode", tusNode()))
+		f.tusError(c, http.StatusMisdirectedRequest, errors.New("上传不在当前实例，请按上传地址会话保持或配置共享的tus.dir！"))
+		return nil
+	}
+	return upload
+}
+
+func (f *File) checkTusResumable(c *wkhttp.Context) bool {
+	c.Header("Tus-Resumable", tusResumable)
+	if c.GetHeader("Tus-Resumable") != tusResumable {
+		c.Header("Tus-Version", tusResumable)
+		f.tusError(c, http.StatusPreconditionFailed, errors.New("不支持的tus协议版本！"))
+		return false
+	}
+	return true
+}
+
+func (f *File) tusError(c *wkhttp.Context, status int, err error) {
+	c.JSON(status, gin.H{
+		"msg":    err.Error(),
+		"status": status,
+	})
+}
+
+func (f *File) removeTusChunk(uploadID string) {
+	err := os.Remove(tusChunkPath(uploadID))
+	if err != nil && !os.IsNotExist(err) {
+		f.Warn("删除断点续传文件失败！", zap.String("uploadID", uploadID), zap.Error(err))
+	}
+}
+
+func tusChunkPath(uploadID string) string {
+	return filepath.Join(extconfig.Get().Tus.Dir, uploadID)
+}
+
+// tusNode 当前实例的标识
+func tusNode() string {
+	if node := extconfig.Get().Tus.Node; node != "" {
+		return node
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// tusLocal 上传中的分片是否可以在当前实例读写 dir共享或上传记录没有实例（之前版本创建的）时不限制
+func tusLocal(upload *uploadModel) bool {
+	return extconfig.Get().Tus.Shared || upload.Node == "" || upload.Node == tusNode()
+}
+
+func tusMaxSize() int64 {
+	return extconfig.Get().Tus.MaxSize * 1024 * 1024
+}
+
+// parseTusMetadata 解析Upload-Metadata 格式为 key base64(value),key base64(value)
+func parseTusMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		kv := strings.Fields(pair)
+		if len(kv) == 0 {
+			continue
+		}
+		value := ""
+		if len(kv) > 1 {
+			decoded, err := base64.StdEncoding.DecodeString(kv[1])
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+		metadata[kv[0]] = value
+	}
+	return metadata
+}
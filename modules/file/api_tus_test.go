@@ -0,0 +1,224 @@
+package file
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTusMetadata(t *testing.T) {
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	metadata := parseTusMetadata("type " + encode("chat") + ",path " + encode("/1/a.mp4") + ", encrypted,filename !!!")
+	assert.Equal(t, map[string]string{"type": "chat", "path": "/1/a.mp4", "encrypted": ""}, metadata)
+}
+
+func TestTusErrors(t *testing.T) {
+	tusCfg := &extconfig.Get().Tus
+	maxSize := tusCfg.MaxSize
+	defer func() { tusCfg.MaxSize = maxSize }()
+	tusCfg.MaxSize = 1
+
+	f := &File{Log: log.NewTLog("File")}
+	r := wkhttp.New()
+	api := r.Group("/v1/file")
+	api.Handle(http.MethodOptions, "/tus", r.WKHttpHandler(f.tusOptions))
+	api.POST("/tus", f.tusCreate)
+	request := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/file/tus", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodOptions, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, tusExtension, w.Header().Get("Tus-Extension"))
+	assert.Equal(t, "1048576", w.Header().Get("Tus-Max-Size"))
+
+	// 不支持的协议版本
+	w = request(http.MethodPost, map[string]string{"Upload-Length": "10"})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, tusResumable, w.Header().Get("Tus-Version"))
+
+	w = request(http.MethodPost, map[string]string{"Tus-Resumable": tusResumable, "Upload-Length": "-1"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = request(http.MethodPost, map[string]string{"Tus-Resumable": tusResumable, "Upload-Length": "1048577"})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+// memTusLockCache 内存中的上传锁
+type memTusLockCache struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+func newMemTusLockCache() *memTusLockCache {
+	return &memTusLockCache{values: map[string]string{}, expires: map[string]time.Time{}}
+}
+
+func (m *memTusLockCache) get(key string) string {
+	if expire, ok := m.expires[key]; ok && time.Now().After(expire) {
+		delete(m.values, key)
+		delete(m.expires, key)
+	}
+	return m.values[key]
+}
+
+func (m *memTusLockCache) SetNX(key string, value interface{}, expire time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.get(key) != "" {
+		return false, nil
+	}
+	m.values[key] = value.(string)
+	m.expires[key] = time.Now().Add(expire)
+	return true, nil
+}
+
+func (m *memTusLockCache) DelIfValue(key string, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.get(key) == value {
+		delete(m.values, key)
+		delete(m.expires, key)
+	}
+	return nil
+}
+
+func (m *memTusLockCache) ExpireIfValue(key string, value string, expire time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.get(key) == value {
+		m.expires[key] = time.Now().Add(expire)
+	}
+	return nil
+}
+
+// expire 模拟锁过期
+func (m *memTusLockCache) expire(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expires[key] = time.Now().Add(-time.Second)
+}
+
+func TestTusLock(t *testing.T) {
+	cache := newMemTusLockCache()
+	f := &File{Log: log.NewTLog("File"), tusCache: cache}
+
+	release, err := f.lockTusUpload("u1")
+	assert.NoError(t, err)
+	_, err = f.lockTusUpload("u1")
+	assert.Equal(t, errTusLocked, err)
+	// 不同的上传互不影响
+	release2, err := f.lockTusUpload("u2")
+	assert.NoError(t, err)
+	release2()
+	release()
+	release, err = f.lockTusUpload("u1")
+	assert.NoError(t, err)
+
+	// 锁过期后被其他请求获取 之前的请求释放时不删除其他请求的锁
+	cache.expire(tusLockPrefix + "u1")
+	release2, err = f.lockTusUpload("u1")
+	assert.NoError(t, err)
+	release()
+	_, err = f.lockTusUpload("u1")
+	assert.Equal(t, errTusLocked, err)
+	release2()
+
+	// 同时请求时只有一个请求可以写入
+	var wg sync.WaitGroup
+	var locked int32
+	releases := make(chan func(), 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := f.lockTusUpload("u3")
+			if err == nil {
+				atomic.AddInt32(&locked, 1)
+				releases <- release
+			}
+		}()
+	}
+	wg.Wait()
+	close(releases)
+	assert.Equal(t, int32(1), locked)
+	for release := range releases {
+		release()
+	}
+}
+
+func TestTusLockedRequest(t *testing.T) {
+	cache := newMemTusLockCache()
+	f := &File{Log: log.NewTLog("File"), tusCache: cache}
+	r := wkhttp.New()
+	api := r.Group("/v1/file")
+	api.Handle(http.MethodPatch, "/tus/:id", r.WKHttpHandler(f.tusPatch))
+	api.DELETE("/tus/:id", f.tusDelete)
+
+	release, err := f.lockTusUpload("u1")
+	assert.NoError(t, err)
+	defer release()
+
+	// 其他请求正在写入时返回423 客户端稍后重试
+	req := httptest.NewRequest(http.MethodPatch, "/v1/file/tus/u1", strings.NewReader("data"))
+	req.Header.Set("Tus-Resumable", tusResumable)
+	req.Header.Set("Content-Type", tusContentType)
+	req.Header.Set("Upload-Offset", "0")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusLocked, w.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/file/tus/u1", nil)
+	req.Header.Set("Tus-Resumable", tusResumable)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusLocked, w.Code)
+}
+
+func TestTusLocal(t *testing.T) {
+	tusCfg := &extconfig.Get().Tus
+	old := *tusCfg
+	defer func() { *tusCfg = old }()
+
+	tusCfg.Node = "node-1"
+	tests := []struct {
+		name   string
+		shared bool
+		node   string
+		want   bool
+	}{
+		{name: "当前实例的上传", node: "node-1", want: true},
+		{name: "其他实例的上传", node: "node-2", want: false},
+		{name: "之前版本没有记录实例", node: "", want: true},
+		{name: "共享目录时不限制", shared: true, node: "node-2", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tusCfg.Shared = tt.shared
+			assert.Equal(t, tt.want, tusLocal(&uploadModel{Node: tt.node}))
+		})
+	}
+
+	// 没有配置时使用主机名
+	tusCfg.Node = ""
+	assert.NotEqual(t, "", tusNode())
+}
@@ -0,0 +1,349 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/cursor"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/shutdown"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// auditLogger 记录审计频道的文件下载 异步批量写入数据库
+type auditLogger struct {
+	log.Log
+	db       *db
+	insert   func(models []*auditLogModel) error // 批量写入记录
+	logs     chan *auditLogModel
+	draining chan chan struct{} // 写入队列中所有记录的请求 写入后关闭传入的chan
+	lock     sync.RWMutex
+	channels map[string]bool // 需要审计的频道 key为lifecycleChannelKey
+}
+
+func newAuditLogger(db *db) *auditLogger {
+	cfg := extconfig.Get().Audit
+	a := &auditLogger{
+		Log:      log.NewTLog("FileAudit"),
+		db:       db,
+		insert:   db.insertAuditLogs,
+		logs:     make(chan *auditLogModel, cfg.QueueSize),
+		draining: make(chan chan struct{}),
+		channels: map[string]bool{},
+	}
+	if cfg.Enable {
+		go a.run()
+		shutdown.Register(shutdown.PhaseFlush, "file.audit", a.drain)
+	}
+	return a
+}
+
+// refreshChannels 重新加载需要审计的频道
+func (a *auditLogger) refreshChannels() {
+	models, err := a.db.queryAuditChannels()
+	if err != nil {
+		a.Warn("查询审计频道失败！", zap.Error(err))
+		return
+	}
+	channels := make(map[string]bool, len(models))
+	for _, m := range models {
+		channels[lifecycleChannelKey(m.ChannelID, m.ChannelType)] = true
+	}
+	a.lock.Lock()
+	a.channels = channels
+	a.lock.Unlock()
+}
+
+// audited 文件是否属于需要审计的频道 path例如 /chat/2/g1/xxx.png
+func (a *auditLogger) audited(path string) (string, uint8, bool) {
+	if !extconfig.Get().Audit.Enable {
+		return "", 0, false
+	}
+	channelID, channelType, ok := lifecycleChannel(path)
+	if !ok {
+		return "", 0, false
+	}
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return channelID, channelType, a.channels[lifecycleChannelKey(channelID, channelType)]
+}
+
+// record 添加一条下载记录 队列满时丢弃
+func (a *auditLogger) record(m *auditLogModel) {
+	select {
+	case a.logs <- m:
+	default:
+		a.Warn("文件访问记录的队列已满！", zap.String("path", m.Path), zap.String("uid", m.UID))
+	}
+}
+
+func (a *auditLogger) run() {
+	cfg := extconfig.Get().Audit
+	ticker := time.NewTicker(cfg.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*auditLogModel, 0, cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := a.insert(batch); err != nil {
+			a.Error("写入文件访问记录失败！", zap.Int("count", len(batch)), zap.Error(err))
+		}
+		batch = make([]*auditLogModel, 0, cfg.BatchSize)
+	}
+	for {
+		select {
+		case m := <-a.logs:
+			batch = append(batch, m)
+			if len(batch) >= cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case done := <-a.draining:
+			for len(a.logs) > 0 {
+				batch = append(batch, <-a.logs)
+				if len(batch) >= cfg.BatchSize {
+					flush()
+				}
+			}
+			flush()
+			close(done)
+		}
+	}
+}
+
+// drain 写入队列中还没写入的记录 进程退出时调用
+func (a *auditLogger) drain(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case a.draining <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// auditDownload 审计频道的文件被下载时记录访问的用户、IP和设备
+func (f *File) auditDownload(c *wkhttp.Context, path string) {
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+	// 分段下载只记录从头开始的请求
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" && !strings.HasPrefix(rangeHeader, "bytes=0-") {
+		return
+	}
+	channelID, channelType, ok := f.auditLogger.audited(path)
+	if !ok {
+		return
+	}
+	// 签名地址带uid时已校验过 否则通过token识别
+	uid := c.Query(signQueryUID)
+	if uid == "" {
+		uid = f.tokenUID(c.GetHeader("token"))
+	}
+	userAgent := c.GetHeader("User-Agent")
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	f.auditLogger.record(&auditLogModel{
+		UID:         uid,
+		Path:        strings.TrimPrefix(path, "/"),
+		ChannelID:   channelID,
+		ChannelType: channelType,
+		IP:          c.ClientIP(),
+		UserAgent:   userAgent,
+	})
+}
+
+// 管理员查询需要审计的频道
+func (f *File) managerAuditChannels(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := f.db.queryAuditChannels()
+	if err != nil {
+		f.Error("查询审计频道失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询审计频道失败！"))
+		return
+	}
+	list := make([]map[string]interface{}, 0, len(models))
+	for _, m := range models {
+		list = append(list, map[string]interface{}{
+			"channel_id":   m.ChannelID,
+			"channel_type": m.ChannelType,
+			"operator":     m.Operator,
+			"created_at":   m.CreatedAt.String(),
+		})
+	}
+	c.Response(map[string]interface{}{
+		"enable": extconfig.Get().Audit.Enable,
+		"list":   list,
+	})
+}
+
+// 管理员添加需要审计的频道
+func (f *File) managerAddAuditChannel(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermOperationWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		ChannelID   string `json:"channel_id"`
+		ChannelType uint8  `json:"channel_type"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if req.ChannelID == "" || req.ChannelType == 0 {
+		c.ResponseError(errors.New("频道不能为空！"))
+		return
+	}
+	err := f.db.insertAuditChannel(&auditChannelModel{
+		ChannelID:   req.ChannelID,
+		ChannelType: req.ChannelType,
+		Operator:    c.GetLoginUID(),
+	})
+	if err != nil {
+		f.Error("添加审计频道失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加审计频道失败！"))
+		return
+	}
+	f.auditLogger.refreshChannels()
+	c.ResponseOK()
+}
+
+// 管理员删除需要审计的频道 已有的访问记录保留
+func (f *File) managerDeleteAuditChannel(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermOperationWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	channelID := c.Query("channel_id")
+	channelType, _ := strconv.ParseUint(c.Query("channel_type"), 10, 8)
+	if channelID == "" || channelType == 0 {
+		c.ResponseError(errors.New("频道不能为空！"))
+		return
+	}
+	if err := f.db.deleteAuditChannel(channelID, uint8(channelType)); err != nil {
+		f.Error("删除审计频道失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除审计频道失败！"))
+		return
+	}
+	f.auditLogger.refreshChannels()
+	c.ResponseOK()
+}
+
+// 管理员查询文件访问记录
+func (f *File) managerAuditLogs(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermLogRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	// 带cursor参数时按游标分页返回 不统计总数
+	if cursor.Requested(c) {
+		page, err := cursor.Query(c)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		models, err := f.db.queryAuditLogsWithCursor(filter, page.Key(0), page.FetchLimit())
+		if err != nil {
+			f.Error("查询文件访问记录失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询文件访问记录失败！"))
+			return
+		}
+		n, hasMore := page.Keep(len(models))
+		models = models[:n]
+		var lastID int64
+		if n > 0 {
+			lastID = models[n-1].Id
+		}
+		c.Response(cursor.NewResult(auditLogResps(models), hasMore, lastID))
+		return
+	}
+	pageIndex, pageSize := cursor.OffsetPage(c)
+	models, err := f.db.queryAuditLogs(filter, pageIndex, pageSize)
+	if err != nil {
+		f.Error("查询文件访问记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询文件访问记录失败！"))
+		return
+	}
+	count, err := f.db.queryAuditLogCount(filter)
+	if err != nil {
+		f.Error("查询文件访问记录数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询文件访问记录数量失败！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"list":  auditLogResps(models),
+		"count": count,
+	})
+}
+
+func auditLogResps(models []*auditLogModel) []map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(models))
+	for _, m := range models {
+		list = append(list, map[string]interface{}{
+			"uid":          m.UID,
+			"path":         m.Path,
+			"channel_id":   m.ChannelID,
+			"channel_type": m.ChannelType,
+			"ip":           m.IP,
+			"user_agent":   m.UserAgent,
+			"created_at":   m.CreatedAt.String(),
+		})
+	}
+	return list
+}
+
+// parseAuditFilter 解析查询条件 start和end为秒级时间戳
+func parseAuditFilter(c *wkhttp.Context) (*auditFilter, error) {
+	filter := &auditFilter{
+		UID:       c.Query("uid"),
+		Path:      strings.TrimPrefix(strings.TrimPrefix(c.Query("path"), "/"), "file/preview/"),
+		ChannelID: c.Query("channel_id"),
+	}
+	if filter.ChannelID != "" {
+		channelType, _ := strconv.ParseUint(c.Query("channel_type"), 10, 8)
+		if channelType == 0 {
+			return nil, errcode.ErrChannelTypeRequired
+		}
+		filter.ChannelType = uint8(channelType)
+	}
+	for _, item := range []struct {
+		key   string
+		value *time.Time
+	}{{"start", &filter.Start}, {"end", &filter.End}} {
+		if c.Query(item.key) == "" {
+			continue
+		}
+		timestamp, err := strconv.ParseInt(c.Query(item.key), 10, 64)
+		if err != nil {
+			return nil, errors.New("时间格式有误！")
+		}
+		*item.value = time.Unix(timestamp, 0)
+	}
+	return filter, nil
+}
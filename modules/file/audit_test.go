@@ -0,0 +1,99 @@
+package file
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAuditLogger(t *testing.T, insert func(models []*auditLogModel) error) *auditLogger {
+	vp := viper.New()
+	vp.Set("audit.enable", true)
+	vp.Set("audit.batchSize", 2)
+	vp.Set("audit.flushInterval", "50ms")
+	extconfig.Configure(vp)
+	t.Cleanup(func() {
+		extconfig.Configure(viper.New())
+	})
+	return &auditLogger{
+		Log:    log.NewTLog("FileAudit"),
+		insert: insert,
+		logs:   make(chan *auditLogModel, 10),
+		channels: map[string]bool{
+			lifecycleChannelKey("g1", 2): true,
+		},
+	}
+}
+
+func TestAuditLoggerAudited(t *testing.T) {
+	a := newTestAuditLogger(t, nil)
+	channelID, channelType, ok := a.audited("/chat/2/g1/a.png")
+	assert.True(t, ok)
+	assert.Equal(t, "g1", channelID)
+	assert.Equal(t, uint8(2), channelType)
+
+	_, _, ok = a.audited("/chat/1/g1/a.png")
+	assert.False(t, ok)
+	_, _, ok = a.audited("/avatar/2/g1/a.png")
+	assert.False(t, ok)
+
+	extconfig.Configure(viper.New())
+	_, _, ok = a.audited("/chat/2/g1/a.png")
+	assert.False(t, ok)
+}
+
+func TestAuditLoggerBatch(t *testing.T) {
+	var lock sync.Mutex
+	batches := make([]int, 0)
+	a := newTestAuditLogger(t, func(models []*auditLogModel) error {
+		lock.Lock()
+		defer lock.Unlock()
+		batches = append(batches, len(models))
+		return nil
+	})
+	go a.run()
+	for i := 0; i < 3; i++ {
+		a.record(&auditLogModel{UID: "u1", Path: "chat/2/g1/a.png"})
+	}
+	// 满一批立即写入 剩下的等到间隔后写入
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(batches) == 2
+	}, time.Second, 10*time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []int{2, 1}, batches)
+}
+
+func TestAuditLoggerDrain(t *testing.T) {
+	var lock sync.Mutex
+	batches := make([]int, 0)
+	a := newTestAuditLogger(t, func(models []*auditLogModel) error {
+		lock.Lock()
+		defer lock.Unlock()
+		batches = append(batches, len(models))
+		return nil
+	})
+	a.draining = make(chan chan struct{})
+	go a.run()
+	for i := 0; i < 3; i++ {
+		a.record(&auditLogModel{UID: "u1", Path: "chat/2/g1/a.png"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, a.drain(ctx))
+	lock.Lock()
+	defer lock.Unlock()
+	total := 0
+	for _, n := range batches {
+		total += n
+	}
+	assert.Equal(t, 3, total)
+}
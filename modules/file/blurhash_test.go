@@ -0,0 +1,31 @@
+package file
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeBlurHash(t *testing.T) {
+	img := imaging.New(32, 24, color.NRGBA{R: 255, G: 0, B: 0, A: 255})
+	hash := encodeBlurHash(img, 4, 3)
+	// 1位尺寸 + 1位最大值 + 4位平均色 + 每个AC分量2位
+	assert.Len(t, hash, 1+1+4+2*(4*3-1))
+	// 纯色图片的平均色为原色 AC分量都为0
+	assert.Equal(t, encodeBase83(0xff0000, 4), hash[2:6])
+
+	assert.Equal(t, "", encodeBlurHash(image.NewRGBA(image.Rect(0, 0, 0, 0)), 4, 3))
+}
+
+func TestThumbnailPath(t *testing.T) {
+	thumbPath, contentType := thumbnailPath("chat/1/abc.jpeg", 480, "jpeg")
+	assert.Equal(t, "chat/1/abc_480.jpg", thumbPath)
+	assert.Equal(t, "image/jpeg", contentType)
+
+	thumbPath, contentType = thumbnailPath("chat/1/abc.png", 240, "png")
+	assert.Equal(t, "chat/1/abc_240.png", thumbPath)
+	assert.Equal(t, "image/png", contentType)
+}
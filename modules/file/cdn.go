@@ -0,0 +1,165 @@
+package file
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"go.uber.org/zap"
+)
+
+const (
+	// cdnAuthTypeA 阿里云和腾讯云的A类鉴权
+	cdnAuthTypeA = "typeA"
+
+	cdnPurgeWebhook    = "webhook"
+	cdnPurgeCloudflare = "cloudflare"
+
+	// cloudflarePurgeBatch Cloudflare每次最多刷新30个地址
+	cloudflarePurgeBatch = 30
+)
+
+var cdnPurgeClient = &http.Client{Timeout: time.Second * 30}
+
+// cdnEnabled 该文件是否通过CDN访问 path例如 /chat/1/xxx.png
+func cdnEnabled(path string) bool {
+	cfg := extconfig.Get().CDN
+	if !cfg.Enable || cfg.Domain == "" {
+		return false
+	}
+	fileType := fileTypeOfPath(path)
+	for _, cdnType := range cfg.Types {
+		if cdnType == fileType {
+			return true
+		}
+	}
+	return false
+}
+
+// cdnBaseURL 文件的CDN地址（不带鉴权参数）
+func cdnBaseURL(path string) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(extconfig.Get().CDN.Domain, "/"), (&url.URL{Path: strings.TrimPrefix(path, "/")}).EscapedPath())
+}
+
+// cdnURL 文件的CDN访问地址 开启鉴权时带上鉴权参数
+func cdnURL(path string, now time.Time) string {
+	cfg := extconfig.Get().CDN
+	baseURL := cdnBaseURL(path)
+	if cfg.AuthType != cdnAuthTypeA || cfg.AuthKey == "" {
+		return baseURL
+	}
+	// A类鉴权 auth_key=timestamp-rand-uid-md5(uri-timestamp-rand-uid-key) rand和uid不使用时为0
+	uri := "/" + (&url.URL{Path: strings.TrimPrefix(path, "/")}).EscapedPath()
+	timestamp := now.Unix()
+	hash := md5.Sum([]byte(fmt.Sprintf("%s-%d-0-0-%s", uri, timestamp, cfg.AuthKey)))
+	return fmt.Sprintf("%s?%s=%d-0-0-%s", baseURL, cfg.AuthParam, timestamp, hex.EncodeToString(hash[:]))
+}
+
+// purgeCDN 刷新CDN缓存 paths例如 chat/1/xxx.png
+func purgeCDN(paths []string) error {
+	cfg := extconfig.Get().CDN
+	if !cfg.Enable || cfg.PurgeProvider == "" {
+		return nil
+	}
+	urls := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if cdnEnabled(path) {
+			urls = append(urls, cdnBaseURL(path))
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	switch cfg.PurgeProvider {
+	case cdnPurgeWebhook:
+		if cfg.PurgeURL == "" {
+			return errors.New("没有配置CDN刷新地址")
+		}
+		return postPurge(cfg.PurgeURL, cfg.PurgeToken, map[string]interface{}{"urls": urls})
+	case cdnPurgeCloudflare:
+		purgeURL := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/purge_cache", cfg.PurgeZoneID)
+		for start := 0; start < len(urls); start += cloudflarePurgeBatch {
+			end := start + cloudflarePurgeBatch
+			if end > len(urls) {
+				end = len(urls)
+			}
+			if err := postPurge(purgeURL, cfg.PurgeToken, map[string]interface{}{"files": urls[start:end]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("不支持的CDN刷新方式：%s", cfg.PurgeProvider)
+}
+
+func postPurge(purgeURL string, token string, body map[string]interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, purgeURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := cdnPurgeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("刷新CDN缓存返回状态有误：%d", resp.StatusCode)
+	}
+	return nil
+}
+
+// PurgeCDN 异步刷新文件的CDN缓存 包括缩略图、其他格式的图片和转码后的视频
+func (s *Service) PurgeCDN(paths []string) {
+	cfg := extconfig.Get().CDN
+	if !cfg.Enable || cfg.PurgeProvider == "" || len(paths) == 0 {
+		return
+	}
+	go func() {
+		allPaths := make([]string, 0, len(paths))
+		for _, path := range paths {
+			path = strings.TrimPrefix(path, "/")
+			allPaths = append(allPaths, path)
+			allPaths = append(allPaths, s.derivedPaths(path)...)
+		}
+		if err := purgeCDN(allPaths); err != nil {
+			s.Warn("刷新CDN缓存失败！", zap.Strings("paths", allPaths), zap.Error(err))
+		}
+	}()
+}
+
+// derivedPaths 由文件生成的缩略图、其他格式的图片、转码后的视频和封面
+func (s *Service) derivedPaths(path string) []string {
+	paths := make([]string, 0)
+	fileM, err := s.db.queryFileWithPath(path)
+	if err != nil {
+		s.Warn("查询文件记录失败！", zap.String("path", path), zap.Error(err))
+	} else if fileM != nil {
+		for _, thumbPath := range fileM.thumbnailMap() {
+			paths = append(paths, thumbPath)
+		}
+		paths = append(paths, fileM.variantPaths()...)
+	}
+	transcodeM, err := s.db.queryTranscodeWithPath(path)
+	if err != nil {
+		s.Warn("查询转码任务失败！", zap.String("path", path), zap.Error(err))
+	} else if transcodeM != nil && transcodeM.Status == TranscodeStatusSuccess {
+		paths = append(paths, transcodeM.OutputPath, transcodeM.PosterPath)
+	}
+	return paths
+}
@@ -0,0 +1,67 @@
+package file
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func configureCDN(t *testing.T, values map[string]interface{}) {
+	vp := viper.New()
+	vp.Set("cdn.enable", true)
+	vp.Set("cdn.domain", "https://cdn.example.com/")
+	for k, v := range values {
+		vp.Set(k, v)
+	}
+	extconfig.Configure(vp)
+	t.Cleanup(func() {
+		extconfig.Configure(viper.New())
+	})
+}
+
+func TestCDNURL(t *testing.T) {
+	configureCDN(t, nil)
+	now := time.Unix(1700000000, 0)
+	assert.True(t, cdnEnabled("/chat/1/u1/a b.png"))
+	assert.False(t, cdnEnabled("/avatar/1/u1/a.png"))
+	assert.Equal(t, "https://cdn.example.com/chat/1/u1/a%20b.png", cdnURL("/chat/1/u1/a b.png", now))
+
+	configureCDN(t, map[string]interface{}{
+		"cdn.authType": cdnAuthTypeA,
+		"cdn.authKey":  "key",
+	})
+	hash := md5.Sum([]byte("/chat/1/u1/a.png-1700000000-0-0-key"))
+	assert.Equal(t, fmt.Sprintf("https://cdn.example.com/chat/1/u1/a.png?auth_key=1700000000-0-0-%s", hex.EncodeToString(hash[:])), cdnURL("chat/1/u1/a.png", now))
+}
+
+func TestPurgeCDNWebhook(t *testing.T) {
+	var purged []string
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		var req struct {
+			URLs []string `json:"urls"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		purged = req.URLs
+	}))
+	defer server.Close()
+
+	configureCDN(t, map[string]interface{}{
+		"cdn.purgeProvider": cdnPurgeWebhook,
+		"cdn.purgeURL":      server.URL,
+		"cdn.purgeToken":    "token",
+	})
+	assert.NoError(t, purgeCDN([]string{"chat/1/u1/a.png", "avatar/1/u1/a.png"}))
+	assert.Equal(t, "Bearer token", authorization)
+	assert.Equal(t, []string{"https://cdn.example.com/chat/1/u1/a.png"}, purged)
+}
@@ -25,3 +25,6 @@ const (
 	// TypeWorkplaceAppIcon
 	TypeWorkplaceAppIcon Type = "workplaceappicon"
 )
+
+// healthCheckObject 检查存储连通性时查询的对象 不需要存在
+const healthCheckObject = "tsdd-health-check"
@@ -0,0 +1,397 @@
+package file
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+const (
+	// uploadStatusUploading 上传中
+	uploadStatusUploading = 0
+	// uploadStatusCompleted 已完成
+	uploadStatusCompleted = 1
+)
+
+// TranscodeStatus 视频转码状态
+type TranscodeStatus int
+
+const (
+	// TranscodeStatusPending 等待转码
+	TranscodeStatusPending TranscodeStatus = 0
+	// TranscodeStatusProcessing 转码中
+	TranscodeStatusProcessing TranscodeStatus = 1
+	// TranscodeStatusSuccess 转码成功
+	TranscodeStatusSuccess TranscodeStatus = 2
+	// TranscodeStatusFailed 转码失败
+	TranscodeStatusFailed TranscodeStatus = 3
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// insertFile 添加文件记录
+func (d *db) insertFile(m *fileModel) error {
+	_, err := d.session.InsertInto("file").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+// queryFileWithPath 查询文件记录 同一个路径多次上传时返回最后一次的
+func (d *db) queryFileWithPath(path string) (*fileModel, error) {
+	var m *fileModel
+	_, err := d.session.Select("*").From("file").Where("path=? and is_deleted=0", path).OrderDir("id", false).Limit(1).Load(&m)
+	return m, err
+}
+
+// updateFileImage 更新图片的尺寸、blurhash和缩略图
+func (d *db) updateFileImage(path string, width, height int, blurhash, thumbnails string) error {
+	_, err := d.session.Update("file").SetMap(map[string]interface{}{
+		"width":      width,
+		"height":     height,
+		"blurhash":   blurhash,
+		"thumbnails": thumbnails,
+		"updated_at": time.Now(),
+	}).Where("path=?", path).Exec()
+	return err
+}
+
+// updateFileVariants 更新图片的其他格式
+func (d *db) updateFileVariants(path string, variants string) error {
+	_, err := d.session.Update("file").SetMap(map[string]interface{}{
+		"variants":   variants,
+		"updated_at": time.Now(),
+	}).Where("path=?", path).Exec()
+	return err
+}
+
+// updateFileAudio 更新语音的时长和波形
+func (d *db) updateFileAudio(path string, duration int, waveform string) error {
+	_, err := d.session.Update("file").SetMap(map[string]interface{}{
+		"duration":   duration,
+		"waveform":   waveform,
+		"updated_at": time.Now(),
+	}).Where("path=?", path).Exec()
+	return err
+}
+
+// queryWaveformsWithPaths 查询已生成波形的语音
+func (d *db) queryWaveformsWithPaths(paths []string) ([]*fileModel, error) {
+	var models []*fileModel
+	_, err := d.session.Select("*").From("file").Where("path in ? and waveform<>''", paths).Load(&models)
+	return models, err
+}
+
+func (d *db) insertUpload(m *uploadModel) error {
+	_, err := d.session.InsertInto("file_upload").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryUpload(uploadID string) (*uploadModel, error) {
+	var m *uploadModel
+	_, err := d.session.Select("*").From("file_upload").Where("upload_id=?", uploadID).Load(&m)
+	return m, err
+}
+
+func (d *db) updateUploadOffset(uploadID string, offset int64, expiredAt time.Time) error {
+	_, err := d.session.Update("file_upload").SetMap(map[string]interface{}{
+		"upload_offset": offset,
+		"expired_at":    expiredAt,
+		"updated_at":    time.Now(),
+	}).Where("upload_id=?", uploadID).Exec()
+	return err
+}
+
+// completeUpload 上传完成 同时添加文件记录
+func (d *db) completeUpload(uploadID string, file *fileModel) error {
+	tx, err := d.session.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	_, err = tx.Update("file_upload").SetMap(map[string]interface{}{
+		"upload_offset": file.Size,
+		"status":        uploadStatusCompleted,
+		"updated_at":    time.Now(),
+	}).Where("upload_id=?", uploadID).Exec()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.InsertInto("file").Columns(util.AttrToUnderscore(file)...).Record(file).Exec()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *db) deleteUpload(uploadID string) error {
+	_, err := d.session.DeleteFrom("file_upload").Where("upload_id=?", uploadID).Exec()
+	return err
+}
+
+// queryExpiredUploads 查询过期未完成的上传 node不为空时只查询该实例的上传
+func (d *db) queryExpiredUploads(now time.Time, node string, limit uint64) ([]*uploadModel, error) {
+	var models []*uploadModel
+	builder := d.session.Select("*").From("file_upload").Where("status=? and expired_at<?", uploadStatusUploading, now)
+	if node != "" {
+		builder = builder.Where("node in ?", []string{node, ""})
+	}
+	_, err := builder.Limit(limit).Load(&models)
+	return models, err
+}
+
+// insertOrResetTranscode 添加转码任务 同一个路径重新上传时重新转码
+func (d *db) insertOrResetTranscode(m *transcodeModel) error {
+	_, err := d.session.InsertBySql("insert into file_transcode(uid,path,name,status) values(?,?,?,?) ON DUPLICATE KEY UPDATE uid=VALUES(uid),name=VALUES(name),status=VALUES(status),attempts=0,output_path='',poster_path='',width=0,height=0,duration=0,err_msg='',updated_at=NOW()", m.UID, m.Path, m.Name, m.Status).Exec()
+	return err
+}
+
+func (d *db) queryTranscodeWithPath(path string) (*transcodeModel, error) {
+	var m *transcodeModel
+	_, err := d.session.Select("*").From("file_transcode").Where("path=?", path).Load(&m)
+	return m, err
+}
+
+// queryTranscodesWithPaths 查询转码成功的任务
+func (d *db) queryTranscodesWithPaths(paths []string) ([]*transcodeModel, error) {
+	var models []*transcodeModel
+	_, err := d.session.Select("*").From("file_transcode").Where("path in ? and status=?", paths, TranscodeStatusSuccess).Load(&models)
+	return models, err
+}
+
+// queryPendingTranscodes 查询等待转码的任务
+func (d *db) queryPendingTranscodes(limit uint64) ([]*transcodeModel, error) {
+	var models []*transcodeModel
+	_, err := d.session.Select("*").From("file_transcode").Where("status=?", TranscodeStatusPending).OrderDir("id", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+// claimTranscode 把任务改为转码中 返回是否抢到了任务 多个实例同时扫描时只有一个能抢到
+func (d *db) claimTranscode(id int64) (bool, error) {
+	result, err := d.session.Update("file_transcode").SetMap(map[string]interface{}{
+		"status":     TranscodeStatusProcessing,
+		"attempts":   dbr.Expr("attempts+1"),
+		"updated_at": time.Now(),
+	}).Where("id=? and status=?", id, TranscodeStatusPending).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// resetStuckTranscodes 转码中的实例退出后任务会一直处于转码中 超时后改回等待转码
+func (d *db) resetStuckTranscodes(before time.Time) error {
+	_, err := d.session.Update("file_transcode").SetMap(map[string]interface{}{
+		"status":     TranscodeStatusPending,
+		"updated_at": time.Now(),
+	}).Where("status=? and updated_at<?", TranscodeStatusProcessing, before).Exec()
+	return err
+}
+
+func (d *db) updateTranscodeSuccess(m *transcodeModel) error {
+	_, err := d.session.Update("file_transcode").SetMap(map[string]interface{}{
+		"status":      TranscodeStatusSuccess,
+		"output_path": m.OutputPath,
+		"poster_path": m.PosterPath,
+		"width":       m.Width,
+		"height":      m.Height,
+		"duration":    m.Duration,
+		"err_msg":     "",
+		"updated_at":  time.Now(),
+	}).Where("id=?", m.Id).Exec()
+	return err
+}
+
+// updateTranscodeFailed 转码失败 status为等待转码时会重试
+func (d *db) updateTranscodeFailed(id int64, status TranscodeStatus, errMsg string) error {
+	_, err := d.session.Update("file_transcode").SetMap(map[string]interface{}{
+		"status":     status,
+		"err_msg":    errMsg,
+		"updated_at": time.Now(),
+	}).Where("id=?", id).Exec()
+	return err
+}
+
+func (d *db) queryBlob(tenantID, fileType, hash string) (*blobModel, error) {
+	var m *blobModel
+	_, err := d.session.Select("*").From("file_blob").Where("tenant_id=? and file_type=? and hash=?", tenantID, fileType, hash).Load(&m)
+	return m, err
+}
+
+// insertBlob 添加文件内容 已存在时忽略
+func (d *db) insertBlob(m *blobModel) error {
+	_, err := d.session.InsertBySql("insert into file_blob(tenant_id,file_type,hash,path,size,content_type,ref_count) values(?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE id=id", m.TenantID, m.FileType, m.Hash, m.Path, m.Size, m.ContentType, m.RefCount).Exec()
+	return err
+}
+
+// addBlobRef 引用已有的文件内容 引用次数加1并添加文件记录
+func (d *db) addBlobRef(blobID int64, file *fileModel) error {
+	tx, err := d.session.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	_, err = tx.Update("file_blob").SetMap(map[string]interface{}{
+		"ref_count":  dbr.Expr("ref_count+1"),
+		"updated_at": time.Now(),
+	}).Where("id=?", blobID).Exec()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.InsertInto("file").Columns(util.AttrToUnderscore(file)...).Record(file).Exec()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type fileModel struct {
+	UID          string
+	FileType     string
+	Path         string
+	Name         string
+	Size         int64
+	ContentType  string
+	Width        int    // 图片宽度
+	Height       int    // 图片高度
+	Blurhash     string // 图片blurhash占位图
+	Thumbnails   string // 缩略图 json 尺寸:文件路径
+	Hash         string // 文件内容的sha256
+	StorageClass string // 存储类型 空为标准存储
+	IsDeleted    int    // 是否已被生命周期规则删除
+	Duration     int    // 语音时长（秒）
+	Waveform     string // 语音波形 base64
+	Variants     string // 图片的其他格式 json 格式:[尺寸] 原图的尺寸为0
+	Encrypted    int    // 是否为客户端加密的文件
+	EncryptAlg   string // 加密算法
+	KeyWrap      string // 包装后的文件密钥等元数据 由客户端解析
+	CipherHash   string // 密文的sha256
+	TenantID     string // 所属组织
+	dba.BaseModel
+}
+
+// thumbnailMap 缩略图 尺寸:文件路径
+func (m *fileModel) thumbnailMap() map[string]string {
+	thumbnails := make(map[string]string)
+	if m.Thumbnails != "" {
+		_ = json.Unmarshal([]byte(m.Thumbnails), &thumbnails)
+	}
+	return thumbnails
+}
+
+type uploadModel struct {
+	UploadID     string
+	UID          string
+	FileType     string
+	Path         string
+	Name         string
+	ContentType  string
+	Size         int64
+	UploadOffset int64
+	Status       int
+	ExpiredAt    time.Time
+	Encrypted    int    // 是否为客户端加密的文件
+	EncryptAlg   string // 加密算法
+	KeyWrap      string // 包装后的文件密钥等元数据
+	CipherHash   string // 密文的sha256
+	Node         string // 保存分片的实例
+	dba.BaseModel
+}
+
+type transcodeModel struct {
+	UID        string
+	Path       string // 原视频路径
+	Name       string
+	Status     TranscodeStatus
+	Attempts   int
+	OutputPath string // 转码后的视频路径
+	PosterPath string // 封面路径
+	Width      int
+	Height     int
+	Duration   int // 视频时长（秒）
+	ErrMsg     string
+	dba.BaseModel
+}
+
+// blobModel 文件内容 相同内容的文件只保存一份
+type blobModel struct {
+	FileType    string
+	Hash        string
+	Path        string // 文件服务中保存的路径
+	Size        int64
+	ContentType string
+	RefCount    int    // 引用次数
+	TenantID    string // 所属组织 只在同一组织内共用
+	dba.BaseModel
+}
+
+func (m *fileModel) variantMap() map[string][]int {
+	variants := make(map[string][]int)
+	if m.Variants != "" {
+		_ = json.Unmarshal([]byte(m.Variants), &variants)
+	}
+	return variants
+}
+
+// variantFormats 原图（thumbSize为0）或缩略图已生成的其他格式
+func (m *fileModel) variantFormats(thumbSize int) []string {
+	formats := make([]string, 0)
+	for format, sizes := range m.variantMap() {
+		for _, size := range sizes {
+			if size == thumbSize {
+				formats = append(formats, format)
+				break
+			}
+		}
+	}
+	return formats
+}
+
+// variantPaths 所有其他格式的图片路径
+func (m *fileModel) variantPaths() []string {
+	paths := make([]string, 0)
+	thumbnails := m.thumbnailMap()
+	for format, sizes := range m.variantMap() {
+		for _, size := range sizes {
+			srcPath := m.Path
+			if size > 0 {
+				srcPath = thumbnails[strconv.Itoa(size)]
+			}
+			if srcPath != "" {
+				paths = append(paths, variantPath(srcPath, format))
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,110 @@
+package file
+
+import (
+	"time"
+
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+func (d *db) queryAuditChannels() ([]*auditChannelModel, error) {
+	var models []*auditChannelModel
+	_, err := d.session.Select("*").From("file_audit_channel").OrderDir("id", true).Load(&models)
+	return models, err
+}
+
+func (d *db) insertAuditChannel(m *auditChannelModel) error {
+	_, err := d.session.InsertBySql("insert into file_audit_channel(channel_id,channel_type,operator) values(?,?,?) ON DUPLICATE KEY UPDATE operator=VALUES(operator),updated_at=NOW()", m.ChannelID, m.ChannelType, m.Operator).Exec()
+	return err
+}
+
+func (d *db) deleteAuditChannel(channelID string, channelType uint8) error {
+	_, err := d.session.DeleteFrom("file_audit_channel").Where("channel_id=? and channel_type=?", channelID, channelType).Exec()
+	return err
+}
+
+// insertAuditLogs 批量添加文件访问记录
+func (d *db) insertAuditLogs(models []*auditLogModel) error {
+	if len(models) == 0 {
+		return nil
+	}
+	builder := d.session.InsertInto("file_audit_log").Columns(util.AttrToUnderscore(models[0])...)
+	for _, m := range models {
+		builder = builder.Record(m)
+	}
+	_, err := builder.Exec()
+	return err
+}
+
+func (d *db) queryAuditLogs(filter *auditFilter, pageIndex, pageSize uint64) ([]*auditLogModel, error) {
+	var models []*auditLogModel
+	builder := filter.apply(d.session.Select("*").From("file_audit_log"))
+	_, err := builder.Offset((pageIndex-1)*pageSize).Limit(pageSize).OrderDir("id", false).Load(&models)
+	return models, err
+}
+
+// queryAuditLogsWithCursor 按id倒序查询访问记录 beforeID为0时从最新的开始
+func (d *db) queryAuditLogsWithCursor(filter *auditFilter, beforeID int64, limit uint64) ([]*auditLogModel, error) {
+	var models []*auditLogModel
+	builder := filter.apply(d.session.Select("*").From("file_audit_log"))
+	if beforeID > 0 {
+		builder = builder.Where("id<?", beforeID)
+	}
+	_, err := builder.OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
+func (d *db) queryAuditLogCount(filter *auditFilter) (int64, error) {
+	var count int64
+	_, err := filter.apply(d.session.Select("count(*)").From("file_audit_log")).Load(&count)
+	return count, err
+}
+
+// auditFilter 查询文件访问记录的条件 为空的条件不使用
+type auditFilter struct {
+	UID         string
+	Path        string
+	ChannelID   string
+	ChannelType uint8
+	Start       time.Time
+	End         time.Time
+}
+
+func (f *auditFilter) apply(builder *dbr.SelectStmt) *dbr.SelectStmt {
+	if f.UID != "" {
+		builder = builder.Where("uid=?", f.UID)
+	}
+	if f.Path != "" {
+		builder = builder.Where("path=?", f.Path)
+	}
+	if f.ChannelID != "" {
+		builder = builder.Where("channel_id=? and channel_type=?", f.ChannelID, f.ChannelType)
+	}
+	if !f.Start.IsZero() {
+		builder = builder.Where("created_at>=?", f.Start)
+	}
+	if !f.End.IsZero() {
+		builder = builder.Where("created_at<?", f.End)
+	}
+	return builder
+}
+
+// auditChannelModel 需要审计文件访问的频道
+type auditChannelModel struct {
+	ChannelID   string
+	ChannelType uint8
+	Operator    string
+	dba.BaseModel
+}
+
+// auditLogModel 文件访问记录
+type auditLogModel struct {
+	UID         string
+	Path        string
+	ChannelID   string
+	ChannelType uint8
+	IP          string
+	UserAgent   string
+	dba.BaseModel
+}
@@ -0,0 +1,85 @@
+package file
+
+import (
+	"time"
+
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+)
+
+// queryLifecycleFiles 按id顺序查询before之前上传且未删除的文件
+func (d *db) queryLifecycleFiles(fileType string, lastID int64, before time.Time, limit uint64) ([]*fileModel, error) {
+	var models []*fileModel
+	_, err := d.session.Select("*").From("file").Where("file_type=? and is_deleted=0 and id>? and created_at<?", fileType, lastID, before).OrderDir("id", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+// markFileDeleted 标记文件记录已删除 返回是否标记成功 已被其他实例删除时返回false
+func (d *db) markFileDeleted(id int64) (bool, error) {
+	result, err := d.session.Update("file").SetMap(map[string]interface{}{
+		"is_deleted": 1,
+		"updated_at": time.Now(),
+	}).Where("id=? and is_deleted=0", id).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// countLiveFilesWithPath 同一个路径未删除的文件记录数（去重后多条记录共用一个文件）
+func (d *db) countLiveFilesWithPath(path string) (int, error) {
+	var count int
+	_, err := d.session.Select("count(*)").From("file").Where("path=? and is_deleted=0", path).Load(&count)
+	return count, err
+}
+
+func (d *db) updateFileStorageClass(path string, storageClass string) error {
+	_, err := d.session.Update("file").SetMap(map[string]interface{}{
+		"storage_class": storageClass,
+		"updated_at":    time.Now(),
+	}).Where("path=?", path).Exec()
+	return err
+}
+
+// decrBlobRef 引用次数减1
+func (d *db) decrBlobRef(path string) error {
+	_, err := d.session.Update("file_blob").SetMap(map[string]interface{}{
+		"ref_count":  dbr.Expr("GREATEST(ref_count-1,0)"),
+		"updated_at": time.Now(),
+	}).Where("path=?", path).Exec()
+	return err
+}
+
+func (d *db) deleteBlobWithPath(path string) error {
+	_, err := d.session.DeleteFrom("file_blob").Where("path=?", path).Exec()
+	return err
+}
+
+func (d *db) queryLifecycleRules() ([]*lifecycleRuleModel, error) {
+	var models []*lifecycleRuleModel
+	_, err := d.session.Select("*").From("file_lifecycle_rule").OrderDir("id", true).Load(&models)
+	return models, err
+}
+
+func (d *db) upsertLifecycleRule(m *lifecycleRuleModel) error {
+	_, err := d.session.InsertBySql("insert into file_lifecycle_rule(channel_id,channel_type,delete_after_days,cold_after_days) values(?,?,?,?) ON DUPLICATE KEY UPDATE delete_after_days=VALUES(delete_after_days),cold_after_days=VALUES(cold_after_days),updated_at=NOW()", m.ChannelID, m.ChannelType, m.DeleteAfterDays, m.ColdAfterDays).Exec()
+	return err
+}
+
+func (d *db) deleteLifecycleRule(channelID string, channelType uint8) error {
+	_, err := d.session.DeleteFrom("file_lifecycle_rule").Where("channel_id=? and channel_type=?", channelID, channelType).Exec()
+	return err
+}
+
+// lifecycleRuleModel 频道的文件生命周期规则
+type lifecycleRuleModel struct {
+	ChannelID       string
+	ChannelType     uint8
+	DeleteAfterDays int // 文件保存天数 -1.使用默认规则 0.永久保存
+	ColdAfterDays   int // 超过天数转为低频存储 -1.使用默认规则 0.不转
+	dba.BaseModel
+}
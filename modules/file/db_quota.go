@@ -0,0 +1,78 @@
+package file
+
+import (
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+)
+
+// quotaUnset 没有单独设置用户配额
+const quotaUnset int64 = -1
+
+func (d *db) queryQuota(uid string) (*quotaModel, error) {
+	var m *quotaModel
+	_, err := d.session.Select("*").From("file_quota").Where("uid=?", uid).Load(&m)
+	return m, err
+}
+
+// addQuotaUsed 增加用户已使用的空间 size为负数时减少
+func (d *db) addQuotaUsed(uid string, size int64) error {
+	_, err := d.session.InsertBySql("insert into file_quota(uid,used) values(?,GREATEST(?,0)) ON DUPLICATE KEY UPDATE used=GREATEST(used+?,0),updated_at=NOW()", uid, size, size).Exec()
+	return err
+}
+
+// updateUserQuota 设置用户配额 quota为-1时使用角色或默认配额
+func (d *db) updateUserQuota(uid string, quota int64) error {
+	_, err := d.session.InsertBySql("insert into file_quota(uid,quota) values(?,?) ON DUPLICATE KEY UPDATE quota=VALUES(quota),updated_at=NOW()", uid, quota).Exec()
+	return err
+}
+
+// queryTotalQuotaUsed 所有用户已使用的空间
+func (d *db) queryTotalQuotaUsed() (int64, error) {
+	var used int64
+	_, err := d.session.SelectBySql("select IFNULL(sum(used),0) from file_quota").Load(&used)
+	return used, err
+}
+
+func (d *db) queryRoleQuota(role string) (*roleQuotaModel, error) {
+	var m *roleQuotaModel
+	_, err := d.session.Select("*").From("file_quota_role").Where("role=?", role).Load(&m)
+	return m, err
+}
+
+func (d *db) queryRoleQuotas() ([]*roleQuotaModel, error) {
+	var models []*roleQuotaModel
+	_, err := d.session.Select("*").From("file_quota_role").Load(&models)
+	return models, err
+}
+
+func (d *db) upsertRoleQuota(role string, quota int64) error {
+	_, err := d.session.InsertBySql("insert into file_quota_role(role,quota) values(?,?) ON DUPLICATE KEY UPDATE quota=VALUES(quota),updated_at=NOW()", role, quota).Exec()
+	return err
+}
+
+func (d *db) deleteRoleQuota(role string) error {
+	_, err := d.session.DeleteFrom("file_quota_role").Where("role=?", role).Exec()
+	return err
+}
+
+// queryUserRole 查询用户的角色 用户模块依赖文件模块 所以这里直接查询user表
+func (d *db) queryUserRole(uid string) (string, bool, error) {
+	var roles []string
+	_, err := d.session.Select("role").From("user").Where("uid=?", uid).Load(&roles)
+	if err != nil || len(roles) == 0 {
+		return "", false, err
+	}
+	return roles[0], true, nil
+}
+
+type quotaModel struct {
+	UID   string
+	Used  int64 // 已使用的空间（字节）
+	Quota int64 // 用户配额（字节） -1.使用角色或默认配额 0.不限制
+	dba.BaseModel
+}
+
+type roleQuotaModel struct {
+	Role  string
+	Quota int64 // 配额（字节） 0.不限制
+	dba.BaseModel
+}
@@ -0,0 +1,94 @@
+package file
+
+import (
+	"time"
+
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+)
+
+const (
+	// shareStatusCanceled 已取消
+	shareStatusCanceled = 0
+	// shareStatusNormal 正常
+	shareStatusNormal = 1
+)
+
+// queryUserFileWithPath 查询用户上传的文件记录
+func (d *db) queryUserFileWithPath(uid string, path string) (*fileModel, error) {
+	var m *fileModel
+	_, err := d.session.Select("*").From("file").Where("uid=? and path=? and is_deleted=0", uid, path).OrderDir("id", false).Limit(1).Load(&m)
+	return m, err
+}
+
+func (d *db) insertShare(m *shareModel) error {
+	_, err := d.session.InsertInto("file_share").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryShare(shareNo string) (*shareModel, error) {
+	var m *shareModel
+	_, err := d.session.Select("*").From("file_share").Where("share_no=?", shareNo).Load(&m)
+	return m, err
+}
+
+func (d *db) queryShares(uid string, pageIndex, pageSize uint64) ([]*shareModel, error) {
+	var models []*shareModel
+	_, err := d.session.Select("*").From("file_share").Where("uid=?", uid).Offset((pageIndex-1)*pageSize).Limit(pageSize).OrderDir("id", false).Load(&models)
+	return models, err
+}
+
+// querySharesWithCursor 按id倒序查询分享 beforeID为0时从最新的开始
+func (d *db) querySharesWithCursor(uid string, beforeID int64, limit uint64) ([]*shareModel, error) {
+	var models []*shareModel
+	builder := d.session.Select("*").From("file_share").Where("uid=?", uid)
+	if beforeID > 0 {
+		builder = builder.Where("id<?", beforeID)
+	}
+	_, err := builder.OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
+func (d *db) queryShareCount(uid string) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("file_share").Where("uid=?", uid).Load(&count)
+	return count, err
+}
+
+// cancelShare 取消分享
+func (d *db) cancelShare(uid string, shareNo string) error {
+	_, err := d.session.Update("file_share").SetMap(map[string]interface{}{
+		"status":     shareStatusCanceled,
+		"updated_at": time.Now(),
+	}).Where("uid=? and share_no=?", uid, shareNo).Exec()
+	return err
+}
+
+// incrShareDownload 增加下载次数 已达到最多下载次数时返回false
+func (d *db) incrShareDownload(shareNo string) (bool, error) {
+	result, err := d.session.UpdateBySql("update file_share set download_count=download_count+1,updated_at=NOW() where share_no=? and status=? and (max_downloads=0 or download_count<max_downloads)", shareNo, shareStatusNormal).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// shareModel 文件分享链接
+type shareModel struct {
+	ShareNo       string
+	UID           string
+	Path          string
+	Name          string
+	Size          int64
+	ContentType   string
+	Password      string // md5(md5(密码)) 为空不需要密码
+	ExpiredAt     time.Time
+	MaxDownloads  int // 最多下载次数 0为不限制
+	DownloadCount int // 已下载次数
+	Status        int
+	dba.BaseModel
+}
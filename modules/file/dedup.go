@@ -0,0 +1,184 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+var sha256HexRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// dedupEnabled 该类型的文件是否去重
+func dedupEnabled(fileType Type) bool {
+	cfg := extconfig.Get().Dedup
+	if !cfg.Enable {
+		return false
+	}
+	for _, dedupType := range cfg.Types {
+		if dedupType == string(fileType) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileHash 计算文件内容的sha256
+func fileHash(reader io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findBlob 查询同一组织内相同内容的文件 没有时返回nil 只查询不添加引用
+func (f *File) findBlob(file *fileModel) (*blobModel, error) {
+	// 加密的文件按路径查询密钥元数据 所以不与其他文件共用路径
+	if file.Hash == "" || file.Encrypted == 1 || !dedupEnabled(Type(file.FileType)) {
+		return nil, nil
+	}
+	blob, err := f.db.queryBlob(file.TenantID, file.FileType, file.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if blob == nil || blob.Size != file.Size {
+		return nil, nil
+	}
+	return blob, nil
+}
+
+// reuseBlob 已有相同内容的文件时引用已有的文件并添加文件记录 返回已有的文件内容 没有时返回nil
+func (f *File) reuseBlob(file *fileModel) (*blobModel, error) {
+	blob, err := f.findBlob(file)
+	if err != nil || blob == nil {
+		return nil, err
+	}
+	file.Path = blob.Path
+	if err = f.db.addBlobRef(blob.Id, file); err != nil {
+		return nil, err
+	}
+	// 共用文件内容但用户的空间仍按文件大小计算
+	f.addQuotaUsed(file.UID, file.Size)
+	return blob, nil
+}
+
+// registerBlob 记录新上传的文件内容 之后上传相同内容的文件时直接引用
+func (f *File) registerBlob(file *fileModel) {
+	if file.Hash == "" || file.Encrypted == 1 || !dedupEnabled(Type(file.FileType)) {
+		return
+	}
+	err := f.db.insertBlob(&blobModel{
+		FileType:    file.FileType,
+		Hash:        file.Hash,
+		Path:        file.Path,
+		Size:        file.Size,
+		ContentType: file.ContentType,
+		RefCount:    1,
+		TenantID:    file.TenantID,
+	})
+	if err != nil {
+		f.Warn("添加文件内容记录失败！", zap.String("path", file.Path), zap.Error(err))
+	}
+}
+
+// checkBlobReq 检查秒传的参数
+func (f *File) checkBlobReq(fileType, hash string, size int64) error {
+	if err := f.checkReq(Type(fileType), "/"); err != nil {
+		return err
+	}
+	if !sha256HexRegexp.MatchString(hash) {
+		return errors.New("hash必须为文件内容的sha256（小写十六进制）！")
+	}
+	if size <= 0 {
+		return errors.New("文件大小不能为空！")
+	}
+	return nil
+}
+
+// 上传前检查是否已有相同内容的文件 只返回是否存在 使用已有的文件需要调用claimUpload
+func (f *File) checkUpload(c *wkhttp.Context) {
+	fileType := c.Query("type")
+	hash := c.Query("hash")
+	size, _ := strconv.ParseInt(c.Query("size"), 10, 64)
+	if err := f.checkBlobReq(fileType, hash, size); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	blob, err := f.findBlob(&fileModel{
+		FileType: fileType,
+		Size:     size,
+		Hash:     hash,
+		TenantID: f.userTenant(c.GetLoginUID()),
+	})
+	if err != nil {
+		f.Error("查询相同内容的文件失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询相同内容的文件失败！"))
+		return
+	}
+	exists := 0
+	if blob != nil {
+		exists = 1
+	}
+	c.Response(map[string]interface{}{
+		"exists": exists,
+	})
+}
+
+// 使用已有的相同内容的文件 添加文件记录并计入存储空间 直接返回文件地址（秒传）
+func (f *File) claimUpload(c *wkhttp.Context) {
+	var req struct {
+		Type        string `json:"type"`
+		Hash        string `json:"hash"`
+		Size        int64  `json:"size"`
+		Name        string `json:"name"`
+		ContentType string `json:"content_type"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := f.checkBlobReq(req.Type, req.Hash, req.Size); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := f.checkQuota(c.GetLoginUID(), c.GetLoginRole(), req.Size); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	blob, err := f.reuseBlob(&fileModel{
+		UID:         c.GetLoginUID(),
+		FileType:    req.Type,
+		Name:        req.Name,
+		Size:        req.Size,
+		ContentType: contentType,
+		Hash:        req.Hash,
+		TenantID:    f.userTenant(c.GetLoginUID()),
+	})
+	if err != nil {
+		f.Error("使用相同内容的文件失败！", zap.Error(err))
+		c.ResponseError(errors.New("使用相同内容的文件失败！"))
+		return
+	}
+	if blob == nil {
+		c.ResponseError(errors.New("没有相同内容的文件，请上传文件！"))
+		return
+	}
+	previewPath := fmt.Sprintf("file/preview/%s", blob.Path)
+	c.Response(map[string]interface{}{
+		"path": previewPath,
+		"url":  f.service.SignURL(previewPath, c.GetLoginUID()),
+	})
+}
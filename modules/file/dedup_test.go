@@ -0,0 +1,78 @@
+package file
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileHash(t *testing.T) {
+	hash, err := fileHash(strings.NewReader("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", hash)
+	assert.True(t, sha256HexRegexp.MatchString(hash))
+}
+
+func TestDedupEnabled(t *testing.T) {
+	assert.True(t, dedupEnabled(TypeChat))
+	assert.False(t, dedupEnabled(TypeMomentCover))
+}
+
+func TestCheckUploadReadOnly(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	err := testutil.CleanAllTables(ctx)
+	assert.NoError(t, err)
+	d := newDB(ctx)
+	hash := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	err = d.insertBlob(&blobModel{FileType: string(TypeChat), Hash: hash, Path: "chat/1/hello.txt", Size: 5, RefCount: 1})
+	assert.NoError(t, err)
+	// 其他组织的文件内容不能使用
+	err = d.insertBlob(&blobModel{FileType: string(TypeChat), Hash: strings.Repeat("a", 64), Path: "chat/2/a.txt", Size: 5, RefCount: 1, TenantID: "t1"})
+	assert.NoError(t, err)
+
+	check := func(hash string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/file/upload/check?type=chat&size=5&hash="+hash, nil)
+		req.Header.Set("token", testutil.Token)
+		s.GetRoute().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, `{"exists":1}`, check(hash))
+	}
+	assert.Equal(t, `{"exists":0}`, check(strings.Repeat("a", 64)))
+
+	// 检查不增加引用次数和已使用的空间
+	blob, err := d.queryBlob("", string(TypeChat), hash)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, blob.RefCount)
+	quotaM, err := d.queryQuota(testutil.UID)
+	assert.NoError(t, err)
+	assert.Nil(t, quotaM)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/file/upload/claim", bytes.NewReader([]byte(util.ToJson(map[string]interface{}{
+		"type": "chat",
+		"hash": hash,
+		"size": 5,
+		"name": "hello.txt",
+	}))))
+	req.Header.Set("token", testutil.Token)
+	s.GetRoute().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"path":"file/preview/chat/1/hello.txt"`)
+
+	blob, err = d.queryBlob("", string(TypeChat), hash)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, blob.RefCount)
+	quotaM, err = d.queryQuota(testutil.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), quotaM.Used)
+}
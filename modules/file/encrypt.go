@@ -0,0 +1,76 @@
+package file
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// encryptAlgMaxLen 加密算法名称的最大长度
+	encryptAlgMaxLen = 40
+	// keyWrapMaxLen 密钥元数据的最大长度
+	keyWrapMaxLen = 2000
+)
+
+// fileEncryption 客户端加密的文件的元数据 服务端只保存不解析 文件内容为密文
+type fileEncryption struct {
+	Alg        string // 加密算法 例如 AES-256-GCM
+	KeyWrap    string // 包装后的文件密钥、iv等 由客户端定义格式
+	CipherHash string // 密文的sha256（小写十六进制） 可为空
+}
+
+// parseEncryption 读取上传参数中的加密信息 encrypted不为1时返回nil
+func parseEncryption(get func(key string) string) (*fileEncryption, error) {
+	if get("encrypted") != "1" {
+		return nil, nil
+	}
+	encryption := &fileEncryption{
+		Alg:        strings.TrimSpace(get("encrypt_alg")),
+		KeyWrap:    get("key_wrap"),
+		CipherHash: strings.ToLower(get("cipher_hash")),
+	}
+	if encryption.Alg == "" {
+		return nil, errors.New("加密算法不能为空！")
+	}
+	if len(encryption.Alg) > encryptAlgMaxLen {
+		return nil, errors.New("加密算法名称太长！")
+	}
+	if len(encryption.KeyWrap) > keyWrapMaxLen {
+		return nil, errors.New("密钥元数据太长！")
+	}
+	if encryption.CipherHash != "" && !sha256HexRegexp.MatchString(encryption.CipherHash) {
+		return nil, errors.New("cipher_hash必须为密文的sha256（十六进制）！")
+	}
+	return encryption, nil
+}
+
+// verify 校验上传的内容与客户端计算的密文hash是否一致 hash为服务端计算的sha256
+func (e *fileEncryption) verify(hash string) error {
+	if e.CipherHash != "" && e.CipherHash != hash {
+		return errors.New("文件内容与cipher_hash不一致！")
+	}
+	return nil
+}
+
+// applyTo 保存加密信息到文件记录 没有传密文hash时使用服务端计算的
+func (e *fileEncryption) applyTo(m *fileModel) {
+	m.Encrypted = 1
+	m.EncryptAlg = e.Alg
+	m.KeyWrap = e.KeyWrap
+	m.CipherHash = e.CipherHash
+	if m.CipherHash == "" {
+		m.CipherHash = m.Hash
+	}
+}
+
+// encryptionInfo 返回给客户端的加密信息 不是加密的文件时返回nil
+func (m *fileModel) encryptionInfo() map[string]interface{} {
+	if m.Encrypted != 1 {
+		return nil
+	}
+	return map[string]interface{}{
+		"encrypt_alg": m.EncryptAlg,
+		"key_wrap":    m.KeyWrap,
+		"cipher_hash": m.CipherHash,
+	}
+}
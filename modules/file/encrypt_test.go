@@ -0,0 +1,50 @@
+package file
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEncryption(t *testing.T) {
+	params := func(values map[string]string) func(key string) string {
+		return func(key string) string { return values[key] }
+	}
+	encryption, err := parseEncryption(params(map[string]string{}))
+	assert.NoError(t, err)
+	assert.Nil(t, encryption)
+
+	hash := strings.Repeat("ab", 32)
+	encryption, err = parseEncryption(params(map[string]string{
+		"encrypted":   "1",
+		"encrypt_alg": "AES-256-GCM",
+		"key_wrap":    "wrapped",
+		"cipher_hash": strings.ToUpper(hash),
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, &fileEncryption{Alg: "AES-256-GCM", KeyWrap: "wrapped", CipherHash: hash}, encryption)
+	assert.NoError(t, encryption.verify(hash))
+	assert.Error(t, encryption.verify(strings.Repeat("cd", 32)))
+
+	_, err = parseEncryption(params(map[string]string{"encrypted": "1"}))
+	assert.Error(t, err)
+	_, err = parseEncryption(params(map[string]string{"encrypted": "1", "encrypt_alg": "AES-256-GCM", "cipher_hash": "abc"}))
+	assert.Error(t, err)
+	_, err = parseEncryption(params(map[string]string{"encrypted": "1", "encrypt_alg": "AES-256-GCM", "key_wrap": strings.Repeat("a", keyWrapMaxLen+1)}))
+	assert.Error(t, err)
+}
+
+func TestFileEncryptionApply(t *testing.T) {
+	m := &fileModel{Hash: "hash"}
+	assert.Nil(t, m.encryptionInfo())
+
+	(&fileEncryption{Alg: "AES-256-GCM", KeyWrap: "wrapped"}).applyTo(m)
+	assert.Equal(t, 1, m.Encrypted)
+	// 没有传密文hash时使用服务端计算的
+	assert.Equal(t, map[string]interface{}{
+		"encrypt_alg": "AES-256-GCM",
+		"key_wrap":    "wrapped",
+		"cipher_hash": "hash",
+	}, m.encryptionInfo())
+}
@@ -0,0 +1,308 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/disintegration/imaging"
+	"go.uber.org/zap"
+)
+
+// exifMaxFileSize 超过该大小的图片不处理 避免占用过多内存
+const exifMaxFileSize = 50 * 1024 * 1024
+
+var (
+	jpegSOI      = []byte{0xFF, 0xD8}
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	exifHeader   = []byte("Exif\x00\x00")
+	iccHeader    = []byte("ICC_PROFILE\x00")
+
+	errInvalidJPEG = errors.New("jpeg格式有误")
+	errInvalidPNG  = errors.New("png格式有误")
+)
+
+// pngKeepChunks 需要保留的png辅助块 其他辅助块（tEXt、eXIf、tIME等）都会被去掉 关键块始终保留
+var pngKeepChunks = map[string]bool{
+	"tRNS": true, "gAMA": true, "cHRM": true, "sRGB": true, "iCCP": true, "sBIT": true, "bKGD": true, "pHYs": true,
+	// apng的动画块
+	"acTL": true, "fcTL": true, "fdAT": true,
+}
+
+// exifStripEnabled 该类型的文件是否需要去掉图片元数据
+func exifStripEnabled(fileType Type) bool {
+	cfg := extconfig.Get().Exif
+	if !cfg.Strip {
+		return false
+	}
+	if len(cfg.Types) == 0 {
+		return true
+	}
+	for _, stripType := range cfg.Types {
+		if stripType == string(fileType) {
+			return true
+		}
+	}
+	return false
+}
+
+// privacySafeImage 上传的图片去掉元数据后的内容 不需要处理或处理失败时返回nil 使用原文件
+func (f *File) privacySafeImage(fileType Type, path, contentType string, file io.ReadSeeker, size int64) []byte {
+	if !exifStripEnabled(fileType) || !isThumbnailImage(path, contentType) || size > exifMaxFileSize {
+		return nil
+	}
+	data, err := io.ReadAll(file)
+	if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil && err == nil {
+		err = seekErr
+	}
+	if err != nil {
+		f.Warn("读取图片失败！", zap.String("path", path), zap.Error(err))
+		return nil
+	}
+	cfg := extconfig.Get().Exif
+	stripped, err := stripImageMetadata(data, cfg.Reencode, cfg.Quality, cfg.MaxPixels)
+	if err != nil {
+		f.Warn("去除图片元数据失败！", zap.String("path", path), zap.Error(err))
+		return nil
+	}
+	return stripped
+}
+
+// stripImageMetadata 去掉jpeg和png的元数据 图片方向保留在只有方向的EXIF中 reencode时按方向旋转后重新编码
+// 不是jpeg和png（例如gif）时返回nil
+func stripImageMetadata(data []byte, reencode bool, quality, maxPixels int) ([]byte, error) {
+	var (
+		stripped    []byte
+		orientation int
+		err         error
+	)
+	if bytes.HasPrefix(data, jpegSOI) {
+		stripped, orientation, err = stripJPEGMetadata(data)
+	} else if bytes.HasPrefix(data, pngSignature) {
+		stripped, orientation, err = stripPNGMetadata(data)
+		// png看方向的客户端很少 有方向时直接旋转图片
+		reencode = reencode || orientation > 1
+	} else {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !reencode {
+		if orientation > 1 {
+			stripped = insertJPEGOrientation(stripped, orientation)
+		}
+		return stripped, nil
+	}
+	imgCfg, _, err := image.DecodeConfig(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, err
+	}
+	if imgCfg.Width*imgCfg.Height > maxPixels {
+		if orientation > 1 && bytes.HasPrefix(stripped, jpegSOI) {
+			stripped = insertJPEGOrientation(stripped, orientation)
+		}
+		return stripped, nil
+	}
+	return reencodeImage(stripped, orientation, quality)
+}
+
+// reencodeImage 按方向旋转后重新编码 结果不含任何元数据
+func reencodeImage(data []byte, orientation int, quality int) ([]byte, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img = applyOrientation(img, orientation)
+	buf := new(bytes.Buffer)
+	if format == "png" {
+		err = png.Encode(buf, img)
+	} else {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// applyOrientation 按EXIF方向旋转图片
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
+// stripJPEGMetadata 去掉jpeg的EXIF、XMP、IPTC、注释和结束标记之后的数据 保留JFIF、ICC和Adobe段 返回原图的方向
+func stripJPEGMetadata(data []byte) ([]byte, int, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, jpegSOI...)
+	orientation := 1
+	i := len(jpegSOI)
+	for {
+		if i+2 > len(data) || data[i] != 0xFF {
+			return nil, 0, errInvalidJPEG
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF: // 填充字节
+			i++
+			continue
+		case marker == 0xD9: // EOI 之后的数据都去掉
+			return append(out, 0xFF, 0xD9), orientation, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+		if i+4 > len(data) {
+			return nil, 0, errInvalidJPEG
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return nil, 0, errInvalidJPEG
+		}
+		payload := data[i+4 : end]
+		keep := true
+		switch {
+		case marker == 0xE1:
+			if bytes.HasPrefix(payload, exifHeader) {
+				orientation = exifOrientation(payload[len(exifHeader):])
+			}
+			keep = false
+		case marker == 0xE2:
+			keep = bytes.HasPrefix(payload, iccHeader)
+		case marker >= 0xE3 && marker <= 0xEF:
+			keep = marker == 0xEE
+		case marker == 0xFE:
+			keep = false
+		}
+		if keep {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+		if marker == 0xDA {
+			// 扫描数据直到下一个标记（0xFF00和RST标记属于扫描数据）
+			start := i
+			for i+1 < len(data) && !(data[i] == 0xFF && data[i+1] != 0x00 && (data[i+1] < 0xD0 || data[i+1] > 0xD7)) {
+				i++
+			}
+			if i+1 >= len(data) {
+				return nil, 0, errInvalidJPEG
+			}
+			out = append(out, data[start:i]...)
+		}
+	}
+}
+
+// stripPNGMetadata 去掉png的文本、EXIF、时间等辅助块和IEND之后的数据 返回eXIf中的方向
+func stripPNGMetadata(data []byte) ([]byte, int, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	orientation := 1
+	i := len(pngSignature)
+	for {
+		if i+12 > len(data) {
+			return nil, 0, errInvalidPNG
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if end > len(data) {
+			return nil, 0, errInvalidPNG
+		}
+		chunkType := string(data[i+4 : i+8])
+		if chunkType == "eXIf" {
+			orientation = exifOrientation(data[i+8 : i+8+length])
+		}
+		// 首字母大写为关键块
+		if chunkType[0] >= 'A' && chunkType[0] <= 'Z' || pngKeepChunks[chunkType] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+		if chunkType == "IEND" {
+			return out, orientation, nil
+		}
+	}
+}
+
+// exifOrientation 从EXIF的TIFF数据中读取IFD0的方向 没有时返回1
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) != 0x0112 {
+			continue
+		}
+		// 类型为SHORT
+		if order.Uint16(tiff[entry+2:]) != 3 {
+			return 1
+		}
+		if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+			return v
+		}
+		return 1
+	}
+	return 1
+}
+
+// insertJPEGOrientation 在jpeg中加入只有方向的EXIF 放在JFIF段之后
+func insertJPEGOrientation(data []byte, orientation int) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // 大端 IFD0偏移8
+		0x00, 0x01, // 1个条目
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, byte(orientation), 0x00, 0x00, // 方向 SHORT 1个
+		0x00, 0x00, 0x00, 0x00, // 没有下一个IFD
+	}
+	payload := append(append([]byte{}, exifHeader...), tiff...)
+	segment := make([]byte, 4, 4+len(payload))
+	segment[0], segment[1] = 0xFF, 0xE1
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	pos := len(jpegSOI)
+	if len(data) > pos+4 && data[pos] == 0xFF && data[pos+1] == 0xE0 {
+		pos += 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+	}
+	out := make([]byte, 0, len(data)+len(segment))
+	out = append(out, data[:pos]...)
+	out = append(out, segment...)
+	return append(out, data[pos:]...)
+}
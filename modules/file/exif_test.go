@@ -0,0 +1,112 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 10), G: uint8(y * 10), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+// testExif 小端的EXIF 包含方向和一个假的GPS字符串
+func testExif(orientation int) []byte {
+	tiff := []byte{'I', 'I', 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, 0x01, 0x00}
+	tiff = append(tiff, 0x12, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, byte(orientation), 0x00, 0x00, 0x00)
+	tiff = append(tiff, 0x00, 0x00, 0x00, 0x00)
+	tiff = append(tiff, []byte("GPS:31.2304,121.4737")...)
+	return tiff
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := make([]byte, 4)
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(chunk[4:]))
+	return append(chunk, crc...)
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, jpeg.Encode(buf, testImage(20, 10), nil))
+	encoded := buf.Bytes()
+
+	data := append([]byte{}, jpegSOI...)
+	data = append(data, jpegSegment(0xE1, append(append([]byte{}, exifHeader...), testExif(6)...))...)
+	data = append(data, jpegSegment(0xFE, []byte("secret comment"))...)
+	data = append(data, encoded[2:]...)
+	data = append(data, []byte("appended payload")...)
+
+	stripped, err := stripImageMetadata(data, false, 90, 40000000)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(stripped, []byte("GPS")))
+	assert.False(t, bytes.Contains(stripped, []byte("secret comment")))
+	assert.False(t, bytes.Contains(stripped, []byte("appended payload")))
+
+	// 方向保留在新的EXIF中
+	_, orientation, err := stripJPEGMetadata(stripped)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, orientation)
+	img, err := jpeg.Decode(bytes.NewReader(stripped))
+	assert.NoError(t, err)
+	assert.Equal(t, 20, img.Bounds().Dx())
+	oriented, err := imaging.Decode(bytes.NewReader(stripped), imaging.AutoOrientation(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, oriented.Bounds().Dx())
+
+	// 重新编码时按方向旋转
+	reencoded, err := stripImageMetadata(data, true, 90, 40000000)
+	assert.NoError(t, err)
+	_, orientation, err = stripJPEGMetadata(reencoded)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, orientation)
+	img, err = jpeg.Decode(bytes.NewReader(reencoded))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, img.Bounds().Dx())
+	assert.Equal(t, 20, img.Bounds().Dy())
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, png.Encode(buf, testImage(8, 8)))
+	encoded := buf.Bytes()
+
+	// 在IHDR之后加入文本块
+	ihdrEnd := len(pngSignature) + 12 + 13
+	data := append([]byte{}, encoded[:ihdrEnd]...)
+	data = append(data, pngChunk("tEXt", []byte("Comment\x00GPS:31.2304,121.4737"))...)
+	data = append(data, encoded[ihdrEnd:]...)
+
+	stripped, err := stripImageMetadata(data, false, 90, 40000000)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(stripped, []byte("GPS")))
+	assert.Equal(t, encoded, stripped)
+
+	// gif等其他格式不处理
+	stripped, err = stripImageMetadata([]byte("GIF89a"), false, 90, 40000000)
+	assert.NoError(t, err)
+	assert.Nil(t, stripped)
+}
@@ -0,0 +1,379 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// lifecycleActionDelete 删除文件
+	lifecycleActionDelete = "delete"
+	// lifecycleActionCold 转为低频存储
+	lifecycleActionCold = "cold"
+
+	// lifecycleRuleInherit 频道规则使用默认规则
+	lifecycleRuleInherit = -1
+	// lifecycleReportMaxItems 报告中最多返回的文件数
+	lifecycleReportMaxItems = 100
+)
+
+// lifecycleRule 生命周期规则 天数为0表示不处理
+type lifecycleRule struct {
+	DeleteAfterDays int `json:"delete_after_days"`
+	ColdAfterDays   int `json:"cold_after_days"`
+}
+
+// lifecycleRules 默认规则和频道的规则
+type lifecycleRules struct {
+	defaultRule lifecycleRule
+	channels    map[string]lifecycleRule
+}
+
+func lifecycleChannelKey(channelID string, channelType uint8) string {
+	return fmt.Sprintf("%s-%d", channelID, channelType)
+}
+
+func (f *File) loadLifecycleRules() (*lifecycleRules, error) {
+	cfg := extconfig.Get().Lifecycle
+	rules := &lifecycleRules{
+		defaultRule: lifecycleRule{
+			DeleteAfterDays: cfg.DeleteAfterDays,
+			ColdAfterDays:   cfg.ColdAfterDays,
+		},
+		channels: map[string]lifecycleRule{},
+	}
+	models, err := f.db.queryLifecycleRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range models {
+		rule := rules.defaultRule
+		if m.DeleteAfterDays != lifecycleRuleInherit {
+			rule.DeleteAfterDays = m.DeleteAfterDays
+		}
+		if m.ColdAfterDays != lifecycleRuleInherit {
+			rule.ColdAfterDays = m.ColdAfterDays
+		}
+		rules.channels[lifecycleChannelKey(m.ChannelID, m.ChannelType)] = rule
+	}
+	return rules, nil
+}
+
+// ruleOf 频道的规则 没有单独设置时返回默认规则
+func (r *lifecycleRules) ruleOf(path string) lifecycleRule {
+	channelID, channelType, ok := lifecycleChannel(path)
+	if ok {
+		if rule, exist := r.channels[lifecycleChannelKey(channelID, channelType)]; exist {
+			return rule
+		}
+	}
+	return r.defaultRule
+}
+
+// minDays 所有规则中最小的天数 没有需要处理的规则时返回0
+func (r *lifecycleRules) minDays() int {
+	minDays := 0
+	check := func(days int) {
+		if days > 0 && (minDays == 0 || days < minDays) {
+			minDays = days
+		}
+	}
+	check(r.defaultRule.DeleteAfterDays)
+	check(r.defaultRule.ColdAfterDays)
+	for _, rule := range r.channels {
+		check(rule.DeleteAfterDays)
+		check(rule.ColdAfterDays)
+	}
+	return minDays
+}
+
+// action 文件需要执行的操作 不需要处理时返回空
+func (r *lifecycleRules) action(file *fileModel, now time.Time) string {
+	rule := r.ruleOf(file.Path)
+	age := now.Sub(time.Time(file.CreatedAt))
+	if rule.DeleteAfterDays > 0 && age >= time.Duration(rule.DeleteAfterDays)*24*time.Hour {
+		return lifecycleActionDelete
+	}
+	if rule.ColdAfterDays > 0 && file.StorageClass == "" && age >= time.Duration(rule.ColdAfterDays)*24*time.Hour {
+		return lifecycleActionCold
+	}
+	return ""
+}
+
+// lifecycleChannel 从聊天文件的路径中解析频道 路径格式为 chat/{频道类型}/{频道ID}/xxx
+func lifecycleChannel(path string) (string, uint8, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) < 4 || parts[0] != string(TypeChat) {
+		return "", 0, false
+	}
+	channelType, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil || parts[2] == "" {
+		return "", 0, false
+	}
+	return parts[2], uint8(channelType), true
+}
+
+type lifecycleItem struct {
+	ID        int64  `json:"id"`
+	UID       string `json:"uid"`
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Action    string `json:"action"`
+	CreatedAt string `json:"created_at"`
+}
+
+// lifecycleReport 执行或预览生命周期规则的结果
+type lifecycleReport struct {
+	DryRun      bool             `json:"dry_run"`
+	Scanned     int              `json:"scanned"`      // 扫描的文件数
+	DeleteCount int              `json:"delete_count"` // 删除的文件数
+	DeleteSize  int64            `json:"delete_size"`  // 删除的文件大小（字节）
+	ColdCount   int              `json:"cold_count"`   // 转为低频存储的文件数
+	ColdSize    int64            `json:"cold_size"`    // 转为低频存储的文件大小（字节）
+	Failed      int              `json:"failed"`       // 处理失败的文件数
+	Items       []*lifecycleItem `json:"items"`        // 处理的文件（最多100个）
+}
+
+func (r *lifecycleReport) add(file *fileModel, action string) {
+	if action == lifecycleActionDelete {
+		r.DeleteCount++
+		r.DeleteSize += file.Size
+	} else {
+		r.ColdCount++
+		r.ColdSize += file.Size
+	}
+	if len(r.Items) < lifecycleReportMaxItems {
+		r.Items = append(r.Items, &lifecycleItem{
+			ID:        file.Id,
+			UID:       file.UID,
+			Path:      file.Path,
+			Size:      file.Size,
+			Action:    action,
+			CreatedAt: file.CreatedAt.String(),
+		})
+	}
+}
+
+// runLifecycle 执行生命周期规则 dryRun时只统计不处理 maxScan为最多扫描的文件数 0为不限制
+func (f *File) runLifecycle(dryRun bool, maxScan int) (*lifecycleReport, error) {
+	report := &lifecycleReport{DryRun: dryRun, Items: make([]*lifecycleItem, 0)}
+	rules, err := f.loadLifecycleRules()
+	if err != nil {
+		return nil, err
+	}
+	minDays := rules.minDays()
+	if minDays == 0 {
+		return report, nil
+	}
+	cfg := extconfig.Get().Lifecycle
+	now := time.Now()
+	before := now.Add(-time.Duration(minDays) * 24 * time.Hour)
+	var lastID int64
+	for {
+		files, err := f.db.queryLifecycleFiles(string(TypeChat), lastID, before, uint64(cfg.BatchSize))
+		if err != nil {
+			return report, err
+		}
+		for _, file := range files {
+			lastID = file.Id
+			report.Scanned++
+			action := rules.action(file, now)
+			if action == "" {
+				continue
+			}
+			if !dryRun {
+				if err := f.applyLifecycle(file, action, cfg.ColdStorageClass); err != nil {
+					f.Warn("执行文件生命周期规则失败！", zap.String("path", file.Path), zap.String("action", action), zap.Error(err))
+					report.Failed++
+					continue
+				}
+			}
+			report.add(file, action)
+			if maxScan > 0 && report.Scanned >= maxScan {
+				return report, nil
+			}
+		}
+		if len(files) < cfg.BatchSize {
+			return report, nil
+		}
+	}
+}
+
+func (f *File) applyLifecycle(file *fileModel, action string, coldStorageClass string) error {
+	if action == lifecycleActionCold {
+		if err := f.service.SetStorageClass(file.Path, coldStorageClass); err != nil {
+			return err
+		}
+		return f.db.updateFileStorageClass(file.Path, coldStorageClass)
+	}
+	marked, err := f.db.markFileDeleted(file.Id)
+	if err != nil || !marked {
+		return err
+	}
+	f.addQuotaUsed(file.UID, -file.Size)
+	if file.Hash != "" {
+		if err = f.db.decrBlobRef(file.Path); err != nil {
+			f.Warn("减少文件内容的引用次数失败！", zap.String("path", file.Path), zap.Error(err))
+		}
+	}
+	// 去重后其他记录还在使用时不删除文件
+	liveCount, err := f.db.countLiveFilesWithPath(file.Path)
+	if err != nil || liveCount > 0 {
+		return err
+	}
+	if err = f.db.deleteBlobWithPath(file.Path); err != nil {
+		f.Warn("删除文件内容记录失败！", zap.String("path", file.Path), zap.Error(err))
+	}
+	paths := []string{file.Path}
+	for _, thumbPath := range file.thumbnailMap() {
+		paths = append(paths, thumbPath)
+	}
+	paths = append(paths, file.variantPaths()...)
+	transcodeM, err := f.db.queryTranscodeWithPath(file.Path)
+	if err != nil {
+		f.Warn("查询转码任务失败！", zap.String("path", file.Path), zap.Error(err))
+	} else if transcodeM != nil && transcodeM.Status == TranscodeStatusSuccess {
+		paths = append(paths, transcodeM.OutputPath, transcodeM.PosterPath)
+	}
+	for _, ph := range paths {
+		if err = f.service.DeleteFile(ph); err != nil {
+			return err
+		}
+	}
+	f.service.PurgeCDN(paths)
+	return nil
+}
+
+// lifecycleJob 定时执行生命周期规则
+func (f *File) lifecycleJob() {
+	if !f.lifecycleRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer f.lifecycleRunning.Store(false)
+	report, err := f.runLifecycle(extconfig.Get().Lifecycle.DryRun, 0)
+	if err != nil {
+		f.Error("执行文件生命周期规则失败！", zap.Error(err))
+	}
+	if report != nil {
+		f.Info("执行文件生命周期规则完成", zap.Bool("dryRun", report.DryRun), zap.Int("scanned", report.Scanned), zap.Int("deleteCount", report.DeleteCount), zap.Int64("deleteSize", report.DeleteSize), zap.Int("coldCount", report.ColdCount), zap.Int64("coldSize", report.ColdSize), zap.Int("failed", report.Failed))
+	}
+}
+
+// 管理员预览生命周期规则会处理的文件 不做任何修改
+func (f *File) managerLifecycleReport(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermStatsRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10000"))
+	if limit <= 0 {
+		limit = 10000
+	}
+	report, err := f.runLifecycle(true, limit)
+	if err != nil {
+		f.Error("预览文件生命周期规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("预览文件生命周期规则失败！"))
+		return
+	}
+	c.Response(report)
+}
+
+// 管理员查询生命周期规则
+func (f *File) managerLifecycleRules(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := f.db.queryLifecycleRules()
+	if err != nil {
+		f.Error("查询生命周期规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询生命周期规则失败！"))
+		return
+	}
+	cfg := extconfig.Get().Lifecycle
+	channels := make([]map[string]interface{}, 0, len(models))
+	for _, m := range models {
+		channels = append(channels, map[string]interface{}{
+			"channel_id":        m.ChannelID,
+			"channel_type":      m.ChannelType,
+			"delete_after_days": m.DeleteAfterDays,
+			"cold_after_days":   m.ColdAfterDays,
+		})
+	}
+	c.Response(map[string]interface{}{
+		"enable":  cfg.Enable,
+		"dry_run": cfg.DryRun,
+		"default": lifecycleRule{
+			DeleteAfterDays: cfg.DeleteAfterDays,
+			ColdAfterDays:   cfg.ColdAfterDays,
+		},
+		"channels": channels,
+	})
+}
+
+// 管理员设置频道的生命周期规则
+func (f *File) managerUpdateLifecycleRule(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermOperationWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		ChannelID       string `json:"channel_id"`
+		ChannelType     uint8  `json:"channel_type"`
+		DeleteAfterDays int    `json:"delete_after_days"` // -1.使用默认规则 0.永久保存
+		ColdAfterDays   int    `json:"cold_after_days"`   // -1.使用默认规则 0.不转
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if req.ChannelID == "" || req.ChannelType == 0 {
+		c.ResponseError(errors.New("频道不能为空！"))
+		return
+	}
+	if req.DeleteAfterDays < lifecycleRuleInherit || req.ColdAfterDays < lifecycleRuleInherit {
+		c.ResponseError(errors.New("天数不能小于-1！"))
+		return
+	}
+	err := f.db.upsertLifecycleRule(&lifecycleRuleModel{
+		ChannelID:       req.ChannelID,
+		ChannelType:     req.ChannelType,
+		DeleteAfterDays: req.DeleteAfterDays,
+		ColdAfterDays:   req.ColdAfterDays,
+	})
+	if err != nil {
+		f.Error("设置生命周期规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置生命周期规则失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 管理员删除频道的生命周期规则 删除后使用默认规则
+func (f *File) managerDeleteLifecycleRule(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermOperationWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	channelID := c.Query("channel_id")
+	channelType, _ := strconv.ParseUint(c.Query("channel_type"), 10, 8)
+	if channelID == "" || channelType == 0 {
+		c.ResponseError(errors.New("频道不能为空！"))
+		return
+	}
+	if err := f.db.deleteLifecycleRule(channelID, uint8(channelType)); err != nil {
+		f.Error("删除生命周期规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除生命周期规则失败！"))
+		return
+	}
+	c.ResponseOK()
+}
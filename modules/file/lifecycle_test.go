@@ -0,0 +1,54 @@
+package file
+
+import (
+	"testing"
+	"time"
+
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycleChannel(t *testing.T) {
+	channelID, channelType, ok := lifecycleChannel("chat/2/g1/abc.png")
+	assert.True(t, ok)
+	assert.Equal(t, "g1", channelID)
+	assert.Equal(t, uint8(2), channelType)
+
+	_, _, ok = lifecycleChannel("/chat/1/u1/abc.png")
+	assert.True(t, ok)
+
+	_, _, ok = lifecycleChannel("chat/abc.png")
+	assert.False(t, ok)
+	_, _, ok = lifecycleChannel("avatar/1/u1/abc.png")
+	assert.False(t, ok)
+	_, _, ok = lifecycleChannel("chat/x/u1/abc.png")
+	assert.False(t, ok)
+}
+
+func TestLifecycleRulesAction(t *testing.T) {
+	rules := &lifecycleRules{
+		defaultRule: lifecycleRule{DeleteAfterDays: 90, ColdAfterDays: 30},
+		channels: map[string]lifecycleRule{
+			lifecycleChannelKey("g1", 2): {DeleteAfterDays: 0, ColdAfterDays: 7},
+		},
+	}
+	assert.Equal(t, 7, rules.minDays())
+
+	now := time.Now()
+	file := func(path string, days int, storageClass string) *fileModel {
+		m := &fileModel{Path: path, StorageClass: storageClass}
+		m.CreatedAt = dba.Time(now.Add(-time.Duration(days) * 24 * time.Hour))
+		return m
+	}
+	assert.Equal(t, "", rules.action(file("chat/1/u1/a.png", 10, ""), now))
+	assert.Equal(t, lifecycleActionCold, rules.action(file("chat/1/u1/a.png", 40, ""), now))
+	assert.Equal(t, "", rules.action(file("chat/1/u1/a.png", 40, "STANDARD_IA"), now))
+	assert.Equal(t, lifecycleActionDelete, rules.action(file("chat/1/u1/a.png", 100, "STANDARD_IA"), now))
+
+	// 频道规则覆盖默认规则
+	assert.Equal(t, lifecycleActionCold, rules.action(file("chat/2/g1/a.png", 10, ""), now))
+	assert.Equal(t, "", rules.action(file("chat/2/g1/a.png", 365, "STANDARD_IA"), now))
+
+	empty := &lifecycleRules{channels: map[string]lifecycleRule{}}
+	assert.Equal(t, 0, empty.minDays())
+}
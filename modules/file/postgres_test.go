@@ -0,0 +1,49 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/postgres/pgtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPostgresQuota 文件配额的语句在PostgreSQL上执行 需要设置TSDD_TEST_POSTGRES
+func TestPostgresQuota(t *testing.T) {
+	ctx := pgtest.NewTestContext(t)
+	d := newDB(ctx)
+
+	assert.NoError(t, d.addQuotaUsed("u1", 100))
+	assert.NoError(t, d.addQuotaUsed("u1", 50))
+	assert.NoError(t, d.addQuotaUsed("u2", 10))
+	m, err := d.queryQuota("u1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(150), m.Used)
+	assert.Equal(t, quotaUnset, m.Quota)
+
+	// 减少时不小于0
+	assert.NoError(t, d.addQuotaUsed("u1", -200))
+	m, err = d.queryQuota("u1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), m.Used)
+
+	assert.NoError(t, d.updateUserQuota("u1", 1024))
+	m, err = d.queryQuota("u1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), m.Quota)
+	assert.Equal(t, int64(0), m.Used)
+
+	used, err := d.queryTotalQuotaUsed()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), used)
+
+	assert.NoError(t, d.upsertRoleQuota("admin", 100))
+	assert.NoError(t, d.upsertRoleQuota("admin", 200))
+	roles, err := d.queryRoleQuotas()
+	assert.NoError(t, err)
+	assert.Len(t, roles, 1)
+	assert.Equal(t, int64(200), roles[0].Quota)
+	assert.NoError(t, d.deleteRoleQuota("admin"))
+	role, err := d.queryRoleQuota("admin")
+	assert.NoError(t, err)
+	assert.Nil(t, role)
+}
@@ -0,0 +1,303 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// quotaRoleUser 普通用户的角色（user表中role为空）
+const quotaRoleUser = "user"
+
+// quotaUsage 用户的存储空间
+type quotaUsage struct {
+	Used  int64 `json:"used"`  // 已使用（字节）
+	Quota int64 `json:"quota"` // 配额（字节） 0为不限制
+}
+
+// remaining 剩余空间 不限制时返回-1
+func (q *quotaUsage) remaining() int64 {
+	if q.Quota <= 0 {
+		return -1
+	}
+	if q.Used >= q.Quota {
+		return 0
+	}
+	return q.Quota - q.Used
+}
+
+// quotaRole 把user表中的角色转为配额的角色
+func quotaRole(role string) string {
+	if role == "" {
+		return quotaRoleUser
+	}
+	return role
+}
+
+// getQuotaUsage 获取用户的存储空间 配额优先级为 用户配额 > 角色配额 > 默认配额
+func (f *File) getQuotaUsage(uid string, role string) (*quotaUsage, error) {
+	quotaM, err := f.db.queryQuota(uid)
+	if err != nil {
+		return nil, err
+	}
+	usage := &quotaUsage{}
+	if quotaM != nil {
+		usage.Used = quotaM.Used
+		if quotaM.Quota != quotaUnset {
+			usage.Quota = quotaM.Quota
+			return usage, nil
+		}
+	}
+	roleQuotaM, err := f.db.queryRoleQuota(quotaRole(role))
+	if err != nil {
+		return nil, err
+	}
+	if roleQuotaM != nil {
+		usage.Quota = roleQuotaM.Quota
+		return usage, nil
+	}
+	usage.Quota = extconfig.Get().Quota.DefaultQuota * 1024 * 1024
+	return usage, nil
+}
+
+// checkQuota 检查用户是否还有size大小的空间
+func (f *File) checkQuota(uid string, role string, size int64) error {
+	if err := f.checkTenantQuota(uid, size); err != nil {
+		return err
+	}
+	if !extconfig.Get().Quota.Enable {
+		return nil
+	}
+	usage, err := f.getQuotaUsage(uid, role)
+	if err != nil {
+		f.Error("查询用户存储空间失败！", zap.String("uid", uid), zap.Error(err))
+		return errors.New("查询用户存储空间失败！")
+	}
+	if usage.Quota > 0 && usage.Used+size > usage.Quota {
+		return fmt.Errorf("存储空间不足！已使用%s，共%s，该文件%s", formatBytes(usage.Used), formatBytes(usage.Quota), formatBytes(size))
+	}
+	return nil
+}
+
+// checkTenantQuota 检查用户所属组织是否还有size大小的空间
+func (f *File) checkTenantQuota(uid string, size int64) error {
+	if !tenant.Enabled() {
+		return nil
+	}
+	tenantID, err := f.tenantService.UserTenant(uid)
+	if err != nil {
+		f.Error("查询用户的组织失败！", zap.String("uid", uid), zap.Error(err))
+		return errors.New("查询用户的组织失败！")
+	}
+	err = f.tenantService.CheckFileQuota(tenantID, size)
+	if err != nil && err != errcode.ErrTenantFileQuota {
+		f.Error("查询组织的存储空间失败！", zap.String("tenantID", tenantID), zap.Error(err))
+		return errors.New("查询组织的存储空间失败！")
+	}
+	return err
+}
+
+// userTenant 文件所属的组织 为上传者的组织
+func (f *File) userTenant(uid string) string {
+	if !tenant.Enabled() {
+		return ""
+	}
+	tenantID, err := f.tenantService.UserTenant(uid)
+	if err != nil {
+		f.Warn("查询用户的组织失败！", zap.String("uid", uid), zap.Error(err))
+	}
+	return tenantID
+}
+
+// addQuotaUsed 记录用户使用的空间 没有开启配额时也记录 方便之后开启
+func (f *File) addQuotaUsed(uid string, size int64) {
+	if uid == "" || size == 0 {
+		return
+	}
+	if err := f.db.addQuotaUsed(uid, size); err != nil {
+		f.Warn("更新用户已使用的空间失败！", zap.String("uid", uid), zap.Int64("size", size), zap.Error(err))
+	}
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// 获取自己的存储空间
+func (f *File) getQuota(c *wkhttp.Context) {
+	usage, err := f.getQuotaUsage(c.GetLoginUID(), c.GetLoginRole())
+	if err != nil {
+		f.Error("查询用户存储空间失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户存储空间失败！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"enable":    extconfig.Get().Quota.Enable,
+		"used":      usage.Used,
+		"quota":     usage.Quota,
+		"remaining": usage.remaining(),
+	})
+}
+
+// 管理员查询用户的存储空间
+func (f *File) managerGetUserQuota(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	uid := c.Query("uid")
+	if uid == "" {
+		c.ResponseError(errors.New("用户uid不能为空！"))
+		return
+	}
+	role, exist, err := f.db.queryUserRole(uid)
+	if err != nil {
+		f.Error("查询用户角色失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户角色失败！"))
+		return
+	}
+	if !exist {
+		c.ResponseError(errcode.ErrUserNotExist)
+		return
+	}
+	usage, err := f.getQuotaUsage(uid, role)
+	if err != nil {
+		f.Error("查询用户存储空间失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户存储空间失败！"))
+		return
+	}
+	quotaM, err := f.db.queryQuota(uid)
+	if err != nil {
+		f.Error("查询用户配额失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户配额失败！"))
+		return
+	}
+	userQuota := quotaUnset
+	if quotaM != nil {
+		userQuota = quotaM.Quota
+	}
+	c.Response(map[string]interface{}{
+		"uid":        uid,
+		"role":       quotaRole(role),
+		"used":       usage.Used,
+		"quota":      usage.Quota,
+		"remaining":  usage.remaining(),
+		"user_quota": userQuota,
+	})
+}
+
+// 管理员设置用户的配额
+func (f *File) managerUpdateUserQuota(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermOperationWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		UID   string `json:"uid"`
+		Quota int64  `json:"quota"` // 配额（字节） -1.使用角色或默认配额 0.不限制
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if req.UID == "" {
+		c.ResponseError(errors.New("用户uid不能为空！"))
+		return
+	}
+	if req.Quota < quotaUnset {
+		c.ResponseError(errors.New("配额不能小于-1！"))
+		return
+	}
+	_, exist, err := f.db.queryUserRole(req.UID)
+	if err != nil {
+		f.Error("查询用户失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户失败！"))
+		return
+	}
+	if !exist {
+		c.ResponseError(errcode.ErrUserNotExist)
+		return
+	}
+	if err = f.db.updateUserQuota(req.UID, req.Quota); err != nil {
+		f.Error("设置用户配额失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置用户配额失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 管理员查询角色的配额
+func (f *File) managerGetRoleQuotas(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := f.db.queryRoleQuotas()
+	if err != nil {
+		f.Error("查询角色配额失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询角色配额失败！"))
+		return
+	}
+	list := make([]map[string]interface{}, 0, len(models))
+	for _, m := range models {
+		list = append(list, map[string]interface{}{
+			"role":  m.Role,
+			"quota": m.Quota,
+		})
+	}
+	c.Response(map[string]interface{}{
+		"default_quota": extconfig.Get().Quota.DefaultQuota * 1024 * 1024,
+		"roles":         list,
+	})
+}
+
+// 管理员设置角色的配额
+func (f *File) managerUpdateRoleQuota(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		Role  string `json:"role"`  // user.普通用户 或管理后台的角色（admin、superAdmin、support、moderator、auditor）
+		Quota int64  `json:"quota"` // 配额（字节） -1.删除角色配额使用默认配额 0.不限制
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if req.Role != quotaRoleUser && !rbac.IsManagerRole(req.Role) {
+		c.ResponseError(errors.New("角色有误！"))
+		return
+	}
+	if req.Quota < quotaUnset {
+		c.ResponseError(errors.New("配额不能小于-1！"))
+		return
+	}
+	var err error
+	if req.Quota == quotaUnset {
+		err = f.db.deleteRoleQuota(req.Role)
+	} else {
+		err = f.db.upsertRoleQuota(req.Role, req.Quota)
+	}
+	if err != nil {
+		f.Error("设置角色配额失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置角色配额失败！"))
+		return
+	}
+	c.ResponseOK()
+}
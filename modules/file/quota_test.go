@@ -0,0 +1,21 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512B", formatBytes(512))
+	assert.Equal(t, "1.5KB", formatBytes(1536))
+	assert.Equal(t, "10.0GB", formatBytes(10*1024*1024*1024))
+}
+
+func TestQuotaUsageRemaining(t *testing.T) {
+	assert.Equal(t, int64(-1), (&quotaUsage{Used: 100, Quota: 0}).remaining())
+	assert.Equal(t, int64(0), (&quotaUsage{Used: 200, Quota: 100}).remaining())
+	assert.Equal(t, int64(60), (&quotaUsage{Used: 40, Quota: 100}).remaining())
+	assert.Equal(t, quotaRoleUser, quotaRole(""))
+	assert.Equal(t, "admin", quotaRole("admin"))
+}
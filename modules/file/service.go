@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
@@ -15,6 +16,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,13 +34,91 @@ type IUploadService interface {
 	DownloadURL(path string, filename string) (string, error)
 }
 
+// IPresignUploadService 支持客户端直传的文件服务
+type IPresignUploadService interface {
+	// 获取签名的上传地址（PUT）和有效期
+	PresignUploadURL(filePath string, contentType string) (string, time.Duration, error)
+}
+
+// IUploadCredentialsService 支持下发临时凭证给客户端直传的文件服务
+type IUploadCredentialsService interface {
+	// 获取只能上传到filePath的临时凭证
+	UploadCredentials(filePath string) (*UploadCredentials, error)
+}
+
+// IDeleteService 支持删除文件的文件服务
+type IDeleteService interface {
+	DeleteFile(filePath string) error
+}
+
+// IStorageClassService 支持修改存储类型的文件服务
+type IStorageClassService interface {
+	// 修改文件的存储类型 例如转为低频访问
+	SetStorageClass(filePath string, storageClass string) error
+}
+
+// IPingService 支持检查连通性的文件服务
+type IPingService interface {
+	// 检查能否访问存储 用于健康检查
+	Ping(ctx context.Context) error
+}
+
+// UploadCredentials 客户端直传的临时凭证
+type UploadCredentials struct {
+	Provider        string `json:"provider"` // 文件服务 aliyunOSS or tencentCOS
+	AccessKeyID     string `json:"access_key_id"`
+	AccessKeySecret string `json:"access_key_secret"`
+	SecurityToken   string `json:"security_token"`
+	Expiration      int64  `json:"expiration"` // 过期时间（秒级时间戳）
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region"`
+	Endpoint        string `json:"endpoint"`
+	Key             string `json:"key"` // 对象名 临时凭证只能上传该对象
+}
+
 // IService IService
 type IService interface {
 	IUploadService
+	IPresignUploadService
+	IUploadCredentialsService
+	IDeleteService
+	IStorageClassService
+	IPingService
 	DownloadAndMakeCompose(uploadPath string, downloadURLs []string) (map[string]interface{}, error)
 	DownloadImage(url string, ctx context.Context) (io.ReadCloser, error)
+	// 查询已转码的视频 返回原视频路径:转码后的视频 没有转码或转码未完成的不返回
+	PlayableVideos(paths []string) (map[string]*PlayableVideo, error)
+	// 查询语音的时长和波形 返回语音路径:波形 没有计算波形的不返回
+	VoiceWaveforms(paths []string) (map[string]*VoiceWaveform, error)
+	// 给文件预览地址加上有效期内的签名 不需要签名时原样返回
+	SignURL(fileURL string, uid string) string
+	// 异步刷新文件的CDN缓存 没有开启CDN刷新时不处理
+	PurgeCDN(paths []string)
+	// 保存文件 与上传接口的处理相同（去重、配额、缩略图和转码）
+	SaveFile(req *SaveFileReq) (map[string]interface{}, error)
+	// 获取文件信息 文件不存在时返回nil
+	GetFileInfo(path string, uid string) (map[string]interface{}, error)
+	// 所有用户已使用的存储空间（字节）
+	GetStorageUsed() (int64, error)
+}
+
+// SaveFileReq 保存文件的请求
+type SaveFileReq struct {
+	UID         string // 上传者
+	Role        string // 上传者的角色 用于计算配额
+	FileType    Type
+	Path        string // 文件类型下的路径 以/开头
+	Name        string
+	ContentType string
+	Content     io.ReadSeeker
+	Size        int64
+	Signature   bool // 是否返回sha512
+	encryption  *fileEncryption
 }
 
+// uploader 在New中设置 通过Service保存文件时使用文件模块的缩略图和转码队列
+var uploader *File
+
 // NewService NewService
 func NewService(ctx *config.Context) IService {
 	var uploadService IUploadService
@@ -47,6 +127,10 @@ func NewService(ctx *config.Context) IService {
 		uploadService = NewServiceMinio(ctx)
 	} else if service == config.FileServiceAliyunOSS {
 		uploadService = NewServiceOSS(ctx)
+	} else if service == FileServiceS3 {
+		uploadService = NewServiceS3(ctx)
+	} else if service == FileServiceTencentCOS {
+		uploadService = NewServiceCOS(ctx)
 	} else {
 		uploadService = NewSeaweedFS(ctx)
 	}
@@ -57,6 +141,7 @@ func NewService(ctx *config.Context) IService {
 			Timeout: time.Second * 30,
 		},
 		uploadService: uploadService,
+		db:            newDB(ctx),
 	}
 	// return NewServiceMinio(ctx)
 }
@@ -67,6 +152,7 @@ type Service struct {
 	log.Log
 	ctx           *config.Context
 	uploadService IUploadService
+	db            *db
 }
 
 func (s *Service) UploadFile(filePath string, contentType string, copyFileWriter func(io.Writer) error) (map[string]interface{}, error) {
@@ -78,6 +164,137 @@ func (s *Service) DownloadURL(path string, filename string) (string, error) {
 	return s.uploadService.DownloadURL(path, filename)
 }
 
+func (s *Service) PresignUploadURL(filePath string, contentType string) (string, time.Duration, error) {
+	presignService, ok := s.uploadService.(IPresignUploadService)
+	if !ok {
+		return "", 0, errors.New("当前文件服务不支持直传！")
+	}
+	return presignService.PresignUploadURL(filePath, contentType)
+}
+
+func (s *Service) UploadCredentials(filePath string) (*UploadCredentials, error) {
+	credentialsService, ok := s.uploadService.(IUploadCredentialsService)
+	if !ok {
+		return nil, errors.New("当前文件服务不支持临时凭证！")
+	}
+	return credentialsService.UploadCredentials(filePath)
+}
+
+func (s *Service) DeleteFile(filePath string) error {
+	deleteService, ok := s.uploadService.(IDeleteService)
+	if !ok {
+		return errors.New("当前文件服务不支持删除文件！")
+	}
+	return deleteService.DeleteFile(filePath)
+}
+
+func (s *Service) SetStorageClass(filePath string, storageClass string) error {
+	storageClassService, ok := s.uploadService.(IStorageClassService)
+	if !ok {
+		return errors.New("当前文件服务不支持修改存储类型！")
+	}
+	return storageClassService.SetStorageClass(filePath, storageClass)
+}
+
+func (s *Service) Ping(ctx context.Context) error {
+	pingService, ok := s.uploadService.(IPingService)
+	if !ok {
+		return errors.New("当前文件服务不支持检查连通性！")
+	}
+	return pingService.Ping(ctx)
+}
+
+func (s *Service) PlayableVideos(paths []string) (map[string]*PlayableVideo, error) {
+	videos := make(map[string]*PlayableVideo)
+	if len(paths) == 0 {
+		return videos, nil
+	}
+	models, err := s.db.queryTranscodesWithPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range models {
+		videos[m.Path] = &PlayableVideo{
+			URL:      fmt.Sprintf("file/preview/%s", m.OutputPath),
+			Cover:    fmt.Sprintf("file/preview/%s", m.PosterPath),
+			Width:    m.Width,
+			Height:   m.Height,
+			Duration: m.Duration,
+		}
+	}
+	return videos, nil
+}
+
+func (s *Service) VoiceWaveforms(paths []string) (map[string]*VoiceWaveform, error) {
+	waveforms := make(map[string]*VoiceWaveform)
+	if len(paths) == 0 {
+		return waveforms, nil
+	}
+	models, err := s.db.queryWaveformsWithPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range models {
+		waveforms[m.Path] = &VoiceWaveform{
+			Duration: m.Duration,
+			Waveform: m.Waveform,
+		}
+	}
+	return waveforms, nil
+}
+
+func (s *Service) SignURL(fileURL string, uid string) string {
+	return signFileURL(fileURL, uid, time.Now())
+}
+
+func (s *Service) SaveFile(req *SaveFileReq) (map[string]interface{}, error) {
+	if uploader == nil {
+		return nil, errors.New("文件模块未启动！")
+	}
+	if !strings.HasPrefix(req.Path, "/") {
+		req.Path = fmt.Sprintf("/%s", req.Path)
+	}
+	return uploader.saveFile(req)
+}
+
+// GetStorageUsed 所有用户已使用的空间 不包含没有记录上传者的文件
+func (s *Service) GetStorageUsed() (int64, error) {
+	return s.db.queryTotalQuotaUsed()
+}
+
+func (s *Service) GetFileInfo(path string, uid string) (map[string]interface{}, error) {
+	ph := strings.TrimPrefix(strings.TrimPrefix(path, "/"), filePreviewPrefix)
+	fileM, err := s.db.queryFileWithPath(ph)
+	if err != nil {
+		return nil, err
+	}
+	if fileM == nil {
+		return nil, nil
+	}
+	resp := map[string]interface{}{
+		"path":         fmt.Sprintf("%s%s", filePreviewPrefix, fileM.Path),
+		"url":          s.SignURL(fmt.Sprintf("%s%s", filePreviewPrefix, fileM.Path), uid),
+		"name":         fileM.Name,
+		"size":         fileM.Size,
+		"content_type": fileM.ContentType,
+		"width":        fileM.Width,
+		"height":       fileM.Height,
+		"blurhash":     fileM.Blurhash,
+	}
+	if encryption := fileM.encryptionInfo(); encryption != nil {
+		resp["encryption"] = encryption
+	}
+	thumbnails := fileM.thumbnailMap()
+	if len(thumbnails) > 0 {
+		thumbnailURLs := make(map[string]string, len(thumbnails))
+		for size, thumbPath := range thumbnails {
+			thumbnailURLs[size] = s.SignURL(fmt.Sprintf("%s%s", filePreviewPrefix, thumbPath), uid)
+		}
+		resp["thumbnails"] = thumbnailURLs
+	}
+	return resp, nil
+}
+
 func (s *Service) DownloadImage(url string, ctx context.Context) (io.ReadCloser, error) {
 	reader, err := s.downloadImage(url, ctx)
 	if err != nil {
@@ -0,0 +1,305 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
+)
+
+// FileServiceTencentCOS 腾讯云cos
+const FileServiceTencentCOS config.FileService = "tencentCOS"
+
+const (
+	tencentSTSHost    = "sts.tencentcloudapi.com"
+	tencentSTSService = "sts"
+	tencentSTSVersion = "2018-08-13"
+)
+
+// ServiceCOS 腾讯云cos
+type ServiceCOS struct {
+	log.Log
+	ctx    *config.Context
+	client *http.Client
+}
+
+// NewServiceCOS NewServiceCOS
+func NewServiceCOS(ctx *config.Context) *ServiceCOS {
+	return &ServiceCOS{
+		Log: log.NewTLog("ServiceCOS"),
+		ctx: ctx,
+		client: &http.Client{
+			Timeout: time.Second * 120,
+		},
+	}
+}
+
+// UploadFile 上传文件
+func (s *ServiceCOS) UploadFile(filePath string, contentType string, copyFileWriter func(io.Writer) error) (map[string]interface{}, error) {
+	cosCfg := extconfig.Get().COS
+	if cosCfg.SecretID == "" || cosCfg.SecretKey == "" || cosCfg.Bucket == "" {
+		return nil, errors.New("没有配置腾讯云cos！")
+	}
+	buff := bytes.NewBuffer(make([]byte, 0))
+	err := copyFileWriter(buff)
+	if err != nil {
+		s.Error("复制文件内容失败！", zap.Error(err))
+		return nil, err
+	}
+	key := strings.TrimPrefix(filePath, "/")
+	host := cosHost(cosCfg)
+	objectURL := &url.URL{Scheme: "https", Host: host, Path: "/" + key}
+	req, err := http.NewRequest(http.MethodPut, objectURL.String(), buff)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", cosSignature(cosCfg.SecretID, cosCfg.SecretKey, http.MethodPut, objectURL.Path, nil, map[string]string{"host": host}, time.Minute*10))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.Error("上传文件失败！", zap.String("key", key), zap.Error(err))
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		s.Error("上传文件失败！", zap.String("key", key), zap.Int("status", resp.StatusCode), zap.String("body", string(respBody)))
+		return nil, errcode.ErrUploadFile
+	}
+	return map[string]interface{}{
+		"path": key,
+	}, nil
+}
+
+// DownloadURL 配置了公开访问地址则返回公开地址 否则返回带签名的临时地址
+func (s *ServiceCOS) DownloadURL(ph string, filename string) (string, error) {
+	cosCfg := extconfig.Get().COS
+	key := strings.TrimPrefix(ph, "/")
+	if cosCfg.DownloadURL != "" {
+		return url.JoinPath(cosCfg.DownloadURL, key)
+	}
+	host := cosHost(cosCfg)
+	params := map[string]string{
+		"response-content-disposition": fmt.Sprintf("inline; filename=\"%s\"", filename),
+	}
+	sign := cosSignature(cosCfg.SecretID, cosCfg.SecretKey, http.MethodGet, "/"+key, params, map[string]string{"host": host}, cosCfg.Duration)
+	vals := url.Values{}
+	for k, v := range params {
+		vals.Set(k, v)
+	}
+	objectURL := &url.URL{Scheme: "https", Host: host, Path: "/" + key}
+	return fmt.Sprintf("%s?%s&%s", objectURL.String(), vals.Encode(), strings.ReplaceAll(sign, ";", "%3B")), nil
+}
+
+// DeleteFile 删除文件
+func (s *ServiceCOS) DeleteFile(filePath string) error {
+	return s.objectRequest(http.MethodDelete, filePath, nil, http.StatusNoContent)
+}
+
+// SetStorageClass 通过复制到自身修改存储类型
+func (s *ServiceCOS) SetStorageClass(filePath string, storageClass string) error {
+	cosCfg := extconfig.Get().COS
+	key := strings.TrimPrefix(filePath, "/")
+	return s.objectRequest(http.MethodPut, filePath, map[string]string{
+		"x-cos-copy-source":        fmt.Sprintf("%s%s", cosHost(cosCfg), (&url.URL{Path: "/" + key}).EscapedPath()),
+		"x-cos-storage-class":      storageClass,
+		"x-cos-metadata-directive": "Copy",
+	}, http.StatusOK)
+}
+
+// Ping 检查能否访问存储桶（HEAD Bucket）
+func (s *ServiceCOS) Ping(ctx context.Context) error {
+	return s.objectRequestWithContext(ctx, http.MethodHead, "", nil, http.StatusOK)
+}
+
+// objectRequest 请求cos的对象接口
+func (s *ServiceCOS) objectRequest(method string, filePath string, headers map[string]string, expectStatus int) error {
+	return s.objectRequestWithContext(context.Background(), method, filePath, headers, expectStatus)
+}
+
+func (s *ServiceCOS) objectRequestWithContext(ctx context.Context, method string, filePath string, headers map[string]string, expectStatus int) error {
+	cosCfg := extconfig.Get().COS
+	if cosCfg.SecretID == "" || cosCfg.SecretKey == "" || cosCfg.Bucket == "" {
+		return errors.New("没有配置腾讯云cos！")
+	}
+	key := strings.TrimPrefix(filePath, "/")
+	host := cosHost(cosCfg)
+	objectURL := &url.URL{Scheme: "https", Host: host, Path: "/" + key}
+	req, err := http.NewRequestWithContext(ctx, method, objectURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Authorization", cosSignature(cosCfg.SecretID, cosCfg.SecretKey, method, objectURL.Path, nil, map[string]string{"host": host}, time.Minute*10))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expectStatus {
+		respBody, _ := io.ReadAll(resp.Body)
+		s.Error("请求cos失败！", zap.String("method", method), zap.String("key", key), zap.Int("status", resp.StatusCode), zap.String("body", string(respBody)))
+		return fmt.Errorf("请求cos失败！status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// UploadCredentials 通过STS获取只能上传filePath的临时凭证
+func (s *ServiceCOS) UploadCredentials(filePath string) (*UploadCredentials, error) {
+	cosCfg := extconfig.Get().COS
+	if cosCfg.SecretID == "" || cosCfg.SecretKey == "" || cosCfg.Bucket == "" {
+		return nil, errors.New("没有配置腾讯云cos！")
+	}
+	appID := cosCfg.Bucket[strings.LastIndex(cosCfg.Bucket, "-")+1:]
+	key := strings.TrimPrefix(filePath, "/")
+	policy, _ := json.Marshal(map[string]interface{}{
+		"version": "2.0",
+		"statement": []map[string]interface{}{
+			{
+				"effect":   "allow",
+				"action":   []string{"name/cos:PutObject", "name/cos:PostObject", "name/cos:InitiateMultipartUpload", "name/cos:ListMultipartUploads", "name/cos:ListParts", "name/cos:UploadPart", "name/cos:CompleteMultipartUpload", "name/cos:AbortMultipartUpload"},
+				"resource": []string{fmt.Sprintf("qcs::cos:%s:uid/%s:%s/%s", cosCfg.Region, appID, cosCfg.Bucket, key)},
+			},
+		},
+	})
+	payload, _ := json.Marshal(map[string]interface{}{
+		"Name":            "tsdd",
+		"Policy":          url.QueryEscape(string(policy)),
+		"DurationSeconds": int(cosCfg.Duration.Seconds()),
+	})
+	req, err := http.NewRequest(http.MethodPost, "https://"+tencentSTSHost, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Host", tencentSTSHost)
+	req.Header.Set("X-TC-Action", "GetFederationToken")
+	req.Header.Set("X-TC-Version", tencentSTSVersion)
+	req.Header.Set("X-TC-Timestamp", fmt.Sprintf("%d", timestamp))
+	req.Header.Set("X-TC-Region", cosCfg.Region)
+	req.Header.Set("Authorization", tencentSTSAuthorization(cosCfg.SecretID, cosCfg.SecretKey, "GetFederationToken", payload, timestamp))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.Error("获取cos临时凭证失败！", zap.Error(err))
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result tencentFederationTokenResp
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		s.Error("解析腾讯云sts返回数据失败！", zap.Error(err), zap.Int("status", resp.StatusCode))
+		return nil, errors.New("获取cos临时凭证失败！")
+	}
+	if result.Response.Error != nil {
+		s.Error("获取cos临时凭证失败！", zap.String("code", result.Response.Error.Code), zap.String("message", result.Response.Error.Message), zap.String("requestId", result.Response.RequestID))
+		return nil, errors.New("获取cos临时凭证失败！")
+	}
+	return &UploadCredentials{
+		Provider:        FileServiceTencentCOS.String(),
+		AccessKeyID:     result.Response.Credentials.TmpSecretID,
+		AccessKeySecret: result.Response.Credentials.TmpSecretKey,
+		SecurityToken:   result.Response.Credentials.Token,
+		Expiration:      result.Response.ExpiredTime,
+		Bucket:          cosCfg.Bucket,
+		Region:          cosCfg.Region,
+		Endpoint:        cosHost(cosCfg),
+		Key:             key,
+	}, nil
+}
+
+func cosHost(cosCfg extconfig.COSConfig) string {
+	return fmt.Sprintf("%s.cos.%s.myqcloud.com", cosCfg.Bucket, cosCfg.Region)
+}
+
+// cosSignature cos请求签名（https://cloud.tencent.com/document/product/436/7778）
+func cosSignature(secretID, secretKey, method, pathname string, params map[string]string, headers map[string]string, expire time.Duration) string {
+	now := time.Now()
+	keyTime := fmt.Sprintf("%d;%d", now.Add(-time.Minute).Unix(), now.Add(expire).Unix())
+	paramList, httpParameters := cosFormatKV(params)
+	headerList, httpHeaders := cosFormatKV(headers)
+	httpString := fmt.Sprintf("%s\n%s\n%s\n%s\n", strings.ToLower(method), pathname, httpParameters, httpHeaders)
+	httpStringSum := sha1.Sum([]byte(httpString))
+	stringToSign := fmt.Sprintf("sha1\n%s\n%s\n", keyTime, hex.EncodeToString(httpStringSum[:]))
+	signKey := hex.EncodeToString(hmacSum(sha1.New, []byte(secretKey), keyTime))
+	signature := hex.EncodeToString(hmacSum(sha1.New, []byte(signKey), stringToSign))
+	return fmt.Sprintf("q-sign-algorithm=sha1&q-ak=%s&q-sign-time=%s&q-key-time=%s&q-header-list=%s&q-url-param-list=%s&q-signature=%s", secretID, keyTime, keyTime, headerList, paramList, signature)
+}
+
+// cosFormatKV 按key排序 返回key列表和key=value列表
+func cosFormatKV(kv map[string]string) (string, string) {
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	}
+	keys := make([]string, 0, len(kv))
+	values := make(map[string]string, len(kv))
+	for k, v := range kv {
+		key := strings.ToLower(escape(k))
+		keys = append(keys, key)
+		values[key] = escape(v)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+values[key])
+	}
+	return strings.Join(keys, ";"), strings.Join(pairs, "&")
+}
+
+// tencentSTSAuthorization 腾讯云 TC3-HMAC-SHA256 签名（https://cloud.tencent.com/document/api/1312/48171）
+func tencentSTSAuthorization(secretID, secretKey string, action string, payload []byte, timestamp int64) string {
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+	signedHeaders := "content-type;host;x-tc-action"
+	payloadSum := sha256.Sum256(payload)
+	canonicalRequest := fmt.Sprintf("POST\n/\n\ncontent-type:application/json; charset=utf-8\nhost:%s\nx-tc-action:%s\n\n%s\n%s", tencentSTSHost, strings.ToLower(action), signedHeaders, hex.EncodeToString(payloadSum[:]))
+	credentialScope := fmt.Sprintf("%s/%s/tc3_request", date, tencentSTSService)
+	canonicalRequestSum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := fmt.Sprintf("TC3-HMAC-SHA256\n%d\n%s\n%s", timestamp, credentialScope, hex.EncodeToString(canonicalRequestSum[:]))
+
+	secretDate := hmacSum(sha256.New, []byte("TC3"+secretKey), date)
+	secretService := hmacSum(sha256.New, secretDate, tencentSTSService)
+	secretSigning := hmacSum(sha256.New, secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSum(sha256.New, secretSigning, stringToSign))
+	return fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", secretID, credentialScope, signedHeaders, signature)
+}
+
+func hmacSum(h func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(h, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+type tencentFederationTokenResp struct {
+	Response struct {
+		Credentials struct {
+			Token        string `json:"Token"`
+			TmpSecretID  string `json:"TmpSecretId"`
+			TmpSecretKey string `json:"TmpSecretKey"`
+		} `json:"Credentials"`
+		ExpiredTime int64 `json:"ExpiredTime"`
+		Error       *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+		RequestID string `json:"RequestId"`
+	} `json:"Response"`
+}
@@ -0,0 +1,119 @@
+package file
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// redirectTransport 把请求转发到测试服务器 cos和sts的地址是固定的
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (r *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newTestCOS(t *testing.T, handler http.HandlerFunc) *ServiceCOS {
+	cosCfg := &extconfig.Get().COS
+	old := *cosCfg
+	t.Cleanup(func() { *cosCfg = old })
+	*cosCfg = extconfig.COSConfig{SecretID: "id", SecretKey: "key", Region: "ap-guangzhou", Bucket: "tsdd-1250000000", Duration: time.Hour}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	service := NewServiceCOS(testutil.NewTestContext(config.New()))
+	service.client = &http.Client{Transport: &redirectTransport{target: target}}
+	return service
+}
+
+func TestServiceCOS(t *testing.T) {
+	objects := map[string]string{}
+	service := newTestCOS(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "tsdd-1250000000.cos.ap-guangzhou.myqcloud.com", r.Host)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "q-sign-algorithm=sha1&q-ak=id&"))
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("x-cos-copy-source") != "" {
+				assert.Equal(t, "ARCHIVE", r.Header.Get("x-cos-storage-class"))
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		case http.MethodDelete:
+			if _, ok := objects[r.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	result, err := service.UploadFile("/chat/1/a.txt", "text/plain", func(w io.Writer) error {
+		_, err := w.Write([]byte("this is test content"))
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "chat/1/a.txt", result["path"])
+	assert.Equal(t, "this is test content", objects["/chat/1/a.txt"])
+
+	assert.NoError(t, service.SetStorageClass("/chat/1/a.txt", "ARCHIVE"))
+	assert.NoError(t, service.DeleteFile("/chat/1/a.txt"))
+	assert.Error(t, service.DeleteFile("/chat/1/a.txt"))
+
+	downloadURL, err := service.DownloadURL("/chat/1/a.txt", "a.txt")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(downloadURL, "https://tsdd-1250000000.cos.ap-guangzhou.myqcloud.com/chat/1/a.txt?response-content-disposition="))
+	assert.Contains(t, downloadURL, "q-signature=")
+
+	extconfig.Get().COS.SecretID = ""
+	_, err = service.UploadFile("/chat/1/a.txt", "text/plain", func(w io.Writer) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestServiceCOSUploadCredentials(t *testing.T) {
+	service := newTestCOS(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GetFederationToken", r.Header.Get("X-TC-Action"))
+		var req struct {
+			Policy          string
+			DurationSeconds int
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, 3600, req.DurationSeconds)
+		policy, _ := url.QueryUnescape(req.Policy)
+		if !strings.Contains(policy, "qcs::cos:ap-guangzhou:uid/1250000000:tsdd-1250000000/chat/1/a.txt") {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"Response": map[string]interface{}{"Error": map[string]string{"Code": "InvalidParameter"}}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Response": map[string]interface{}{
+			"Credentials": map[string]string{"Token": "token", "TmpSecretId": "tmpID", "TmpSecretKey": "tmpKey"},
+			"ExpiredTime": 1700000000,
+		}})
+	})
+	credentials, err := service.UploadCredentials("/chat/1/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "tmpID", credentials.AccessKeyID)
+	assert.Equal(t, "tmpKey", credentials.AccessKeySecret)
+	assert.Equal(t, "token", credentials.SecurityToken)
+	assert.Equal(t, int64(1700000000), credentials.Expiration)
+	assert.Equal(t, "chat/1/a.txt", credentials.Key)
+
+	_, err = service.UploadCredentials("/chat/2/a.txt")
+	assert.Error(t, err)
+}
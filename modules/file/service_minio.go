@@ -119,6 +119,42 @@ func (sm *ServiceMinio) UploadFile(filePath string, contentType string, copyFile
 	}, err
 }
 
+// DeleteFile 删除文件 路径的第一级为存储桶
+func (sm *ServiceMinio) DeleteFile(filePath string) error {
+	minioConfig := sm.ctx.GetConfig().Minio
+	uploadUl, err := url.Parse(minioConfig.UploadURL)
+	if err != nil {
+		return err
+	}
+	minioClient, err := minio.New(uploadUl.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(minioConfig.AccessKeyID, minioConfig.SecretAccessKey, ""),
+		Secure: strings.HasPrefix(uploadUl.Scheme, "https"),
+	})
+	if err != nil {
+		return err
+	}
+	bucketName, fileName, _ := strings.Cut(strings.TrimPrefix(filePath, "/"), "/")
+	return minioClient.RemoveObject(context.Background(), bucketName, fileName, minio.RemoveObjectOptions{})
+}
+
+// Ping 检查能否访问默认的存储桶
+func (sm *ServiceMinio) Ping(ctx context.Context) error {
+	minioConfig := sm.ctx.GetConfig().Minio
+	uploadUl, err := url.Parse(minioConfig.UploadURL)
+	if err != nil {
+		return err
+	}
+	minioClient, err := minio.New(uploadUl.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(minioConfig.AccessKeyID, minioConfig.SecretAccessKey, ""),
+		Secure: strings.HasPrefix(uploadUl.Scheme, "https"),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = minioClient.BucketExists(ctx, "file")
+	return err
+}
+
 func (sm *ServiceMinio) DownloadURL(ph string, filename string) (string, error) {
 	minioConfig := sm.ctx.GetConfig().Minio
 	vals := url.Values{}
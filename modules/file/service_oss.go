@@ -2,11 +2,20 @@ package file
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/url"
+	"strings"
+	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"go.uber.org/zap"
 )
@@ -72,3 +81,100 @@ func (s *ServiceOSS) DownloadURL(path string, filename string) (string, error) {
 	rpath, _ := url.JoinPath(ossCfg.BucketURL, path)
 	return rpath, nil
 }
+
+// DeleteFile 删除文件
+func (s *ServiceOSS) DeleteFile(filePath string) error {
+	bucket, err := s.bucket()
+	if err != nil {
+		return err
+	}
+	return bucket.DeleteObject(strings.TrimPrefix(filePath, "/"))
+}
+
+// SetStorageClass 通过复制到自身修改存储类型
+func (s *ServiceOSS) SetStorageClass(filePath string, storageClass string) error {
+	bucket, err := s.bucket()
+	if err != nil {
+		return err
+	}
+	key := strings.TrimPrefix(filePath, "/")
+	_, err = bucket.CopyObject(key, key, oss.ObjectStorageClass(oss.StorageClassType(storageClass)), oss.MetadataDirective(oss.MetaCopy))
+	return err
+}
+
+// Ping 检查能否访问存储桶 对象不存在不影响结果
+// oss的sdk不支持传入ctx 超时由调用方处理
+func (s *ServiceOSS) Ping(ctx context.Context) error {
+	bucket, err := s.bucket()
+	if err != nil {
+		return err
+	}
+	_, err = bucket.IsObjectExist(healthCheckObject)
+	return err
+}
+
+func (s *ServiceOSS) bucket() (*oss.Bucket, error) {
+	ossCfg := s.ctx.GetConfig().OSS
+	client, err := oss.New(ossCfg.Endpoint, ossCfg.AccessKeyID, ossCfg.AccessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+	return client.Bucket(ossCfg.BucketName)
+}
+
+// UploadCredentials 通过STS扮演角色获取只能上传filePath的临时凭证
+func (s *ServiceOSS) UploadCredentials(filePath string) (*UploadCredentials, error) {
+	ossCfg := s.ctx.GetConfig().OSS
+	stsCfg := extconfig.Get().OSSSTS
+	if stsCfg.RoleArn == "" {
+		return nil, errors.New("没有配置oss临时凭证！")
+	}
+	client, err := sts.NewClientWithAccessKey(stsCfg.Region, ossCfg.AccessKeyID, ossCfg.AccessKeySecret)
+	if err != nil {
+		s.Error("创建sts客户端失败！", zap.Error(err))
+		return nil, err
+	}
+	key := strings.TrimPrefix(filePath, "/")
+	policy, _ := json.Marshal(map[string]interface{}{
+		"Version": "1",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"oss:PutObject", "oss:InitiateMultipartUpload", "oss:UploadPart", "oss:CompleteMultipartUpload", "oss:AbortMultipartUpload", "oss:ListParts"},
+				"Resource": []string{"acs:oss:*:*:" + ossCfg.BucketName + "/" + key},
+			},
+		},
+	})
+	request := sts.CreateAssumeRoleRequest()
+	request.Scheme = "https"
+	request.RoleArn = stsCfg.RoleArn
+	request.RoleSessionName = "tsdd-" + util.GenerUUID()
+	request.Policy = string(policy)
+	request.DurationSeconds = requests.NewInteger(int(stsCfg.Duration.Seconds()))
+	response, err := client.AssumeRole(request)
+	if err != nil {
+		s.Error("获取oss临时凭证失败！", zap.String("key", key), zap.Error(err))
+		return nil, errors.New("获取oss临时凭证失败！")
+	}
+	expiration, _ := time.Parse(time.RFC3339, response.Credentials.Expiration)
+	return &UploadCredentials{
+		Provider:        config.FileServiceAliyunOSS.String(),
+		AccessKeyID:     response.Credentials.AccessKeyId,
+		AccessKeySecret: response.Credentials.AccessKeySecret,
+		SecurityToken:   response.Credentials.SecurityToken,
+		Expiration:      expiration.Unix(),
+		Bucket:          ossCfg.BucketName,
+		Region:          ossRegion(ossCfg.Endpoint),
+		Endpoint:        ossCfg.Endpoint,
+		Key:             key,
+	}, nil
+}
+
+// ossRegion 从endpoint获取地域 例如 oss-cn-hangzhou.aliyuncs.com 为 oss-cn-hangzhou
+func ossRegion(endpoint string) string {
+	host := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return strings.TrimSuffix(strings.Split(host, ".")[0], "-internal")
+}
@@ -0,0 +1,222 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"go.uber.org/zap"
+)
+
+// FileServiceS3 S3兼容的对象存储（AWS S3、MinIO等）
+const FileServiceS3 config.FileService = "s3"
+
+// ServiceS3 S3兼容的对象存储 文件超过分片大小时自动使用分片上传
+type ServiceS3 struct {
+	log.Log
+	ctx *config.Context
+
+	initOnce sync.Once
+	initErr  error
+	client   *s3.S3
+	uploader *s3manager.Uploader
+}
+
+// NewServiceS3 NewServiceS3
+func NewServiceS3(ctx *config.Context) *ServiceS3 {
+	return &ServiceS3{
+		Log: log.NewTLog("ServiceS3"),
+		ctx: ctx,
+	}
+}
+
+// UploadFile 上传文件
+func (s *ServiceS3) UploadFile(filePath string, contentType string, copyFileWriter func(io.Writer) error) (map[string]interface{}, error) {
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	s3Cfg := extconfig.Get().S3
+	key := s3ObjectKey(filePath)
+
+	// 边复制边上传 避免大文件全部读入内存
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(copyFileWriter(writer))
+	}()
+	output, err := s.uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(s3Cfg.Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        reader,
+	})
+	reader.Close()
+	if err != nil {
+		s.Error("上传文件失败！", zap.String("key", key), zap.Error(err))
+		return nil, err
+	}
+	return map[string]interface{}{
+		"path":     key,
+		"location": output.Location,
+	}, nil
+}
+
+// DownloadURL 配置了公开访问地址则返回公开地址 否则返回带签名的临时地址
+func (s *ServiceS3) DownloadURL(ph string, filename string) (string, error) {
+	s3Cfg := extconfig.Get().S3
+	key := s3ObjectKey(ph)
+	if s3Cfg.DownloadURL != "" {
+		return url.JoinPath(s3Cfg.DownloadURL, key)
+	}
+	if err := s.init(); err != nil {
+		return "", err
+	}
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     aws.String(s3Cfg.Bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(fmt.Sprintf("inline; filename=\"%s\"", filename)),
+	})
+	downloadURL, err := req.Presign(s3Cfg.PresignExpire)
+	if err != nil {
+		s.Error("生成下载地址失败！", zap.String("key", key), zap.Error(err))
+		return "", err
+	}
+	return downloadURL, nil
+}
+
+// PresignUploadURL 生成客户端直传的签名地址（PUT）
+func (s *ServiceS3) PresignUploadURL(filePath string, contentType string) (string, time.Duration, error) {
+	if err := s.init(); err != nil {
+		return "", 0, err
+	}
+	s3Cfg := extconfig.Get().S3
+	key := s3ObjectKey(filePath)
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s3Cfg.Bucket),
+		Key:    aws.String(key),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	req, _ := s.client.PutObjectRequest(input)
+	uploadURL, err := req.Presign(s3Cfg.PresignExpire)
+	if err != nil {
+		s.Error("生成上传地址失败！", zap.String("key", key), zap.Error(err))
+		return "", 0, err
+	}
+	return uploadURL, s3Cfg.PresignExpire, nil
+}
+
+// DeleteFile 删除文件
+func (s *ServiceS3) DeleteFile(filePath string) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(extconfig.Get().S3.Bucket),
+		Key:    aws.String(s3ObjectKey(filePath)),
+	})
+	return err
+}
+
+// SetStorageClass 通过复制到自身修改存储类型
+func (s *ServiceS3) SetStorageClass(filePath string, storageClass string) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	bucket := extconfig.Get().S3.Bucket
+	key := s3ObjectKey(filePath)
+	_, err := s.client.CopyObject(&s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String((&url.URL{Path: bucket + "/" + key}).EscapedPath()),
+		StorageClass:      aws.String(storageClass),
+		MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+	})
+	return err
+}
+
+// Ping 检查能否访问存储桶
+func (s *ServiceS3) Ping(ctx context.Context) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	_, err := s.client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(extconfig.Get().S3.Bucket)})
+	return err
+}
+
+func (s *ServiceS3) init() error {
+	s.initOnce.Do(func() {
+		s3Cfg := extconfig.Get().S3
+		if s3Cfg.Bucket == "" {
+			s.initErr = errors.New("没有配置s3存储桶！")
+			return
+		}
+		awsConfig := aws.NewConfig().WithRegion(s3Cfg.Region).WithS3ForcePathStyle(s3Cfg.ForcePathStyle)
+		if s3Cfg.Endpoint != "" {
+			awsConfig = awsConfig.WithEndpoint(s3Cfg.Endpoint).WithDisableSSL(strings.HasPrefix(s3Cfg.Endpoint, "http://"))
+		}
+		if s3Cfg.AccessKeyID != "" {
+			awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(s3Cfg.AccessKeyID, s3Cfg.SecretAccessKey, ""))
+		}
+		sess, err := session.NewSession(awsConfig)
+		if err != nil {
+			s.Error("创建aws会话失败！", zap.Error(err))
+			s.initErr = err
+			return
+		}
+		s.client = s3.New(sess)
+		partSize := int64(s3Cfg.PartSize) * 1024 * 1024
+		if partSize < s3manager.MinUploadPartSize {
+			partSize = s3manager.MinUploadPartSize
+		}
+		s.uploader = s3manager.NewUploaderWithClient(s.client, func(u *s3manager.Uploader) {
+			u.PartSize = partSize
+			if s3Cfg.Concurrency > 0 {
+				u.Concurrency = s3Cfg.Concurrency
+			}
+		})
+		s.ensureBucket(s3Cfg.Bucket, s3Cfg.Region)
+	})
+	return s.initErr
+}
+
+// ensureBucket 存储桶不存在则创建 创建失败只记录日志（可能没有创建权限）
+func (s *ServiceS3) ensureBucket(bucket, region string) {
+	_, err := s.client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return
+	}
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || (aerr.Code() != s3.ErrCodeNoSuchBucket && aerr.Code() != "NotFound") {
+		s.Warn("检测存储桶是否存在失败！", zap.String("bucket", bucket), zap.Error(err))
+		return
+	}
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
+	if region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{LocationConstraint: aws.String(region)}
+	}
+	_, err = s.client.CreateBucket(input)
+	if err != nil {
+		s.Error("创建存储桶失败！", zap.String("bucket", bucket), zap.Error(err))
+		return
+	}
+	s.Info("已创建存储桶", zap.String("bucket", bucket))
+}
+
+func s3ObjectKey(filePath string) string {
+	return strings.TrimPrefix(filePath, "/")
+}
@@ -0,0 +1,102 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 只实现测试需要的接口（路径风格）
+type fakeS3 struct {
+	mu           sync.Mutex
+	objects      map[string][]byte
+	storageClass map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/tsdd/")
+	switch {
+	case r.Method == http.MethodHead && r.URL.Path == "/tsdd":
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.storageClass[key] = r.Header.Get("X-Amz-Storage-Class")
+		_, _ = io.WriteString(w, `<CopyObjectResult><ETag>"1"</ETag></CopyObjectResult>`)
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+		w.Header().Set("ETag", `"1"`)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestServiceS3(t *testing.T) {
+	s3Cfg := &extconfig.Get().S3
+	old := *s3Cfg
+	defer func() { *s3Cfg = old }()
+
+	fake := &fakeS3{objects: map[string][]byte{}, storageClass: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx := testutil.NewTestContext(config.New())
+	// 没有配置存储桶
+	s3Cfg.Bucket = ""
+	_, err := NewServiceS3(ctx).UploadFile("chat/1/a.txt", "text/plain", func(w io.Writer) error { return nil })
+	assert.Error(t, err)
+
+	*s3Cfg = extconfig.S3Config{Endpoint: server.URL, Region: "us-east-1", Bucket: "tsdd", AccessKeyID: "id", SecretAccessKey: "secret", ForcePathStyle: true, PresignExpire: time.Hour, PartSize: 5, Concurrency: 1}
+	service := NewServiceS3(ctx)
+	assert.NoError(t, service.Ping(context.Background()))
+
+	result, err := service.UploadFile("/chat/1/a.txt", "text/plain", func(w io.Writer) error {
+		_, err := w.Write([]byte("this is test content"))
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "chat/1/a.txt", result["path"])
+	assert.Equal(t, []byte("this is test content"), fake.objects["chat/1/a.txt"])
+
+	uploadURL, expire, err := service.PresignUploadURL("/chat/1/b.txt", "text/plain")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, expire)
+	assert.True(t, strings.HasPrefix(uploadURL, server.URL+"/tsdd/chat/1/b.txt?"))
+	assert.Contains(t, uploadURL, "X-Amz-Signature=")
+
+	downloadURL, err := service.DownloadURL("/chat/1/a.txt", "a.txt")
+	assert.NoError(t, err)
+	assert.Contains(t, downloadURL, "response-content-disposition=")
+	s3Cfg.DownloadURL = "https://cdn.example.com"
+	downloadURL, err = service.DownloadURL("/chat/1/a.txt", "a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/chat/1/a.txt", downloadURL)
+
+	assert.NoError(t, service.SetStorageClass("/chat/1/a.txt", "STANDARD_IA"))
+	assert.Equal(t, "STANDARD_IA", fake.storageClass["chat/1/a.txt"])
+
+	assert.NoError(t, service.DeleteFile("/chat/1/a.txt"))
+	assert.NotContains(t, fake.objects, "chat/1/a.txt")
+
+	// 上传过程中复制文件失败
+	_, err = service.UploadFile("/chat/1/c.txt", "text/plain", func(w io.Writer) error {
+		_, _ = io.Copy(w, bytes.NewBufferString("part"))
+		return io.ErrUnexpectedEOF
+	})
+	assert.Error(t, err)
+}
@@ -1,8 +1,10 @@
 package file
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"path/filepath"
 
@@ -36,6 +38,23 @@ func (s *SeaweedFS) UploadFile(filePath string, contentType string, copyFileWrit
 	return resultMap, err
 }
 
+// Ping 检查能否访问SeaweedFS的filer
+func (s *SeaweedFS) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.ctx.GetConfig().Seaweed.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("请求SeaweedFS失败！status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
 func (s *SeaweedFS) DownloadURL(path string, filename string) (string, error) {
 	seaweedConfig := s.ctx.GetConfig().Seaweed
 	rpath, _ := url.JoinPath(seaweedConfig.URL, path)
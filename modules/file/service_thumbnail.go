@@ -0,0 +1,182 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/disintegration/imaging"
+	"go.uber.org/zap"
+)
+
+// 原图最大字节数 超过则不生成缩略图
+const thumbnailMaxFileSize = 50 * 1024 * 1024
+
+// thumbnailJob 生成缩略图的任务
+type thumbnailJob struct {
+	path string // 原图路径 例如 chat/1/xxx.png
+	name string
+}
+
+// thumbnailWorker 异步生成图片缩略图和blurhash
+type thumbnailWorker struct {
+	log.Log
+	service IService
+	db      *db
+	jobs    chan thumbnailJob
+}
+
+func newThumbnailWorker(service IService, db *db) *thumbnailWorker {
+	cfg := extconfig.Get().Thumbnail
+	w := &thumbnailWorker{
+		Log:     log.NewTLog("ThumbnailWorker"),
+		service: service,
+		db:      db,
+		jobs:    make(chan thumbnailJob, cfg.QueueSize),
+	}
+	if cfg.Enable {
+		for i := 0; i < cfg.Workers; i++ {
+			go w.run()
+		}
+	}
+	return w
+}
+
+// enqueue 添加生成缩略图的任务 返回缩略图的预览地址（尺寸:地址） 不是图片或没有开启时返回nil
+func (w *thumbnailWorker) enqueue(fileType Type, path, name, contentType string) map[string]string {
+	cfg := extconfig.Get().Thumbnail
+	if !cfg.Enable || len(cfg.Sizes) == 0 || fileType == TypeSticker || !isThumbnailImage(path, contentType) {
+		return nil
+	}
+	select {
+	case w.jobs <- thumbnailJob{path: path, name: name}:
+	default:
+		w.Warn("生成缩略图的队列已满！", zap.String("path", path))
+		return nil
+	}
+	thumbnails := make(map[string]string, len(cfg.Sizes))
+	for _, size := range cfg.Sizes {
+		thumbnails[strconv.Itoa(size)] = fmt.Sprintf("file/preview/%s?size=%d", path, size)
+	}
+	return thumbnails
+}
+
+func (w *thumbnailWorker) run() {
+	for job := range w.jobs {
+		if err := w.generate(job); err != nil {
+			w.Warn("生成缩略图失败！", zap.String("path", job.path), zap.Error(err))
+		}
+	}
+}
+
+func (w *thumbnailWorker) generate(job thumbnailJob) error {
+	cfg := extconfig.Get().Thumbnail
+	downloadURL, err := w.service.DownloadURL("/"+job.path, job.name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	reader, err := w.service.DownloadImage(downloadURL, ctx)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, thumbnailMaxFileSize+1))
+	reader.Close()
+	if err != nil {
+		return err
+	}
+	if len(data) > thumbnailMaxFileSize {
+		return errors.New("图片太大")
+	}
+	imgCfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if imgCfg.Width*imgCfg.Height > cfg.MaxPixels {
+		return fmt.Errorf("图片像素太多 %dx%d", imgCfg.Width, imgCfg.Height)
+	}
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return err
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	// 缩小后计算blurhash 占位图只需要很少的像素
+	blurhash := encodeBlurHash(imaging.Fit(img, 32, 32, imaging.Box), 4, 3)
+
+	thumbnails := make(map[string]string)
+	// 需要生成其他格式的图片 尺寸为0的是原图
+	sources := []*variantSource{{path: job.path, img: img, size: len(data)}}
+	for _, size := range cfg.Sizes {
+		if size <= 0 || (width <= size && height <= size) {
+			continue
+		}
+		thumbPath, contentType := thumbnailPath(job.path, size, format)
+		thumb := imaging.Fit(img, size, size, imaging.Lanczos)
+		buf := new(bytes.Buffer)
+		if contentType == "image/png" {
+			err = png.Encode(buf, thumb)
+		} else {
+			err = jpeg.Encode(buf, thumb, &jpeg.Options{Quality: cfg.Quality})
+		}
+		if err != nil {
+			return err
+		}
+		_, err = w.service.UploadFile(thumbPath, contentType, func(writer io.Writer) error {
+			_, err := writer.Write(buf.Bytes())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		thumbnails[strconv.Itoa(size)] = thumbPath
+		sources = append(sources, &variantSource{path: thumbPath, thumbSize: size, img: thumb, size: buf.Len()})
+	}
+	thumbnailsJSON := ""
+	if len(thumbnails) > 0 {
+		thumbnailsData, _ := json.Marshal(thumbnails)
+		thumbnailsJSON = string(thumbnailsData)
+	}
+	if err = w.db.updateFileImage(job.path, width, height, blurhash, thumbnailsJSON); err != nil {
+		return err
+	}
+	if format == "jpeg" || format == "png" {
+		w.generateVariants(job.path, sources)
+	}
+	return nil
+}
+
+// thumbnailPath 缩略图与原图保存在同一目录 例如 chat/1/xxx.png 的480尺寸为 chat/1/xxx_480.png
+// png保留透明度 其他格式转为jpeg
+func thumbnailPath(path string, size int, format string) (string, string) {
+	ext := filepath.Ext(path)
+	thumbExt, contentType := ".jpg", "image/jpeg"
+	if format == "png" {
+		thumbExt, contentType = ".png", "image/png"
+	}
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(path, ext), size, thumbExt), contentType
+}
+
+func isThumbnailImage(path, contentType string) bool {
+	switch strings.ToLower(contentType) {
+	case "image/jpeg", "image/jpg", "image/png", "image/gif":
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
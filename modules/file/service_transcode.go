@@ -0,0 +1,300 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// PlayableVideo 转码后可播放的视频
+type PlayableVideo struct {
+	URL      string // 转码后的视频地址 例如 file/preview/chat/1/xxx_h264.mp4
+	Cover    string // 封面地址
+	Width    int
+	Height   int
+	Duration int // 视频时长（秒）
+}
+
+// transcodeWorker 异步把视频转码为H.264的mp4并截取封面
+// 任务保存在数据库中 多个实例通过claimTranscode抢任务 实例重启后未完成的任务会继续转码
+type transcodeWorker struct {
+	log.Log
+	ctx     *config.Context
+	service IService
+	db      *db
+	sem     chan struct{} // 限制同时转码的数量
+}
+
+func newTranscodeWorker(ctx *config.Context, service IService, db *db) *transcodeWorker {
+	cfg := extconfig.Get().Transcode
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	return &transcodeWorker{
+		Log:     log.NewTLog("TranscodeWorker"),
+		ctx:     ctx,
+		service: service,
+		db:      db,
+		sem:     make(chan struct{}, workers),
+	}
+}
+
+func (w *transcodeWorker) start() {
+	cfg := extconfig.Get().Transcode
+	if !cfg.Enable {
+		return
+	}
+	w.ctx.Schedule(cfg.ScanInterval, w.scan)
+}
+
+// enqueue 添加转码任务 返回转码状态 不是视频或没有开启时返回nil
+func (w *transcodeWorker) enqueue(fileType Type, uid, path, name, contentType string) map[string]interface{} {
+	if !extconfig.Get().Transcode.Enable || fileType != TypeChat || !isTranscodeVideo(path, contentType) {
+		return nil
+	}
+	err := w.db.insertOrResetTranscode(&transcodeModel{
+		UID:    uid,
+		Path:   path,
+		Name:   name,
+		Status: TranscodeStatusPending,
+	})
+	if err != nil {
+		w.Warn("添加转码任务失败！", zap.String("path", path), zap.Error(err))
+		return nil
+	}
+	return map[string]interface{}{
+		"status": TranscodeStatusPending,
+	}
+}
+
+func (w *transcodeWorker) scan() {
+	cfg := extconfig.Get().Transcode
+	err := w.db.resetStuckTranscodes(time.Now().Add(-cfg.Timeout * 2))
+	if err != nil {
+		w.Warn("重置超时的转码任务失败！", zap.Error(err))
+	}
+	idle := cap(w.sem) - len(w.sem)
+	if idle <= 0 {
+		return
+	}
+	jobs, err := w.db.queryPendingTranscodes(uint64(idle))
+	if err != nil {
+		w.Error("查询待转码任务失败！", zap.Error(err))
+		return
+	}
+	for _, job := range jobs {
+		select {
+		case w.sem <- struct{}{}:
+		default:
+			return
+		}
+		claimed, err := w.db.claimTranscode(job.Id)
+		if err != nil || !claimed {
+			<-w.sem
+			if err != nil {
+				w.Warn("获取转码任务失败！", zap.Int64("id", job.Id), zap.Error(err))
+			}
+			continue
+		}
+		job.Attempts++
+		go func(job *transcodeModel) {
+			defer func() { <-w.sem }()
+			w.process(job)
+		}(job)
+	}
+}
+
+func (w *transcodeWorker) process(job *transcodeModel) {
+	cfg := extconfig.Get().Transcode
+	err := w.transcode(job)
+	if err == nil {
+		err = w.db.updateTranscodeSuccess(job)
+		if err != nil {
+			w.Error("更新转码结果失败！", zap.String("path", job.Path), zap.Error(err))
+		}
+		return
+	}
+	w.Warn("视频转码失败！", zap.String("path", job.Path), zap.Int("attempts", job.Attempts), zap.Error(err))
+	status := TranscodeStatusPending
+	if job.Attempts >= cfg.MaxAttempts {
+		status = TranscodeStatusFailed
+	}
+	errMsg := err.Error()
+	if len(errMsg) > 500 {
+		errMsg = strings.ToValidUTF8(errMsg[len(errMsg)-500:], "")
+	}
+	err = w.db.updateTranscodeFailed(job.Id, status, errMsg)
+	if err != nil {
+		w.Error("更新转码失败的状态失败！", zap.String("path", job.Path), zap.Error(err))
+	}
+}
+
+func (w *transcodeWorker) transcode(job *transcodeModel) error {
+	cfg := extconfig.Get().Transcode
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	dir := filepath.Join(cfg.Dir, util.GenerUUID())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	inputPath := filepath.Join(dir, "input"+filepath.Ext(job.Path))
+	if err := w.download(ctx, job, inputPath); err != nil {
+		return err
+	}
+	inputProbe, err := probeVideo(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	outputPath := filepath.Join(dir, "output.mp4")
+	scale := fmt.Sprintf("scale='if(gt(iw,ih),min(iw,%d),-2)':'if(gt(iw,ih),-2,min(ih,%d))'", cfg.MaxSize, cfg.MaxSize)
+	// 固定GOP方便后续切片为HLS faststart让mp4可以边下边播
+	err = runFFmpeg(ctx, "-y", "-i", inputPath,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-c:v", "libx264", "-preset", cfg.Preset, "-crf", strconv.Itoa(cfg.CRF),
+		"-profile:v", "main", "-pix_fmt", "yuv420p", "-vf", scale,
+		"-g", "48", "-keyint_min", "48", "-sc_threshold", "0",
+		"-c:a", "aac", "-b:a", "128k", "-ac", "2",
+		"-movflags", "+faststart", outputPath)
+	if err != nil {
+		return err
+	}
+	outputProbe, err := probeVideo(ctx, outputPath)
+	if err != nil {
+		return err
+	}
+	posterPath := filepath.Join(dir, "poster.jpg")
+	// 优先截取第1秒的画面 避开黑屏的首帧
+	posterAt := math.Min(1, inputProbe.duration/2)
+	err = runFFmpeg(ctx, "-y", "-ss", strconv.FormatFloat(posterAt, 'f', 3, 64), "-i", outputPath, "-frames:v", "1", "-q:v", "3", posterPath)
+	if err != nil {
+		return err
+	}
+
+	job.OutputPath, job.PosterPath = transcodePaths(job.Path)
+	if err = w.upload(job.OutputPath, "video/mp4", outputPath); err != nil {
+		return err
+	}
+	if err = w.upload(job.PosterPath, "image/jpeg", posterPath); err != nil {
+		return err
+	}
+	job.Width = outputProbe.width
+	job.Height = outputProbe.height
+	job.Duration = int(math.Round(inputProbe.duration))
+	return nil
+}
+
+func (w *transcodeWorker) download(ctx context.Context, job *transcodeModel, inputPath string) error {
+	downloadURL, err := w.service.DownloadURL("/"+job.Path, job.Name)
+	if err != nil {
+		return err
+	}
+	reader, err := w.service.DownloadImage(downloadURL, ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	inputFile, err := os.Create(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+	_, err = io.Copy(inputFile, reader)
+	return err
+}
+
+func (w *transcodeWorker) upload(path, contentType, localPath string) error {
+	_, err := w.service.UploadFile(path, contentType, func(writer io.Writer) error {
+		localFile, err := os.Open(localPath)
+		if err != nil {
+			return err
+		}
+		defer localFile.Close()
+		_, err = io.Copy(writer, localFile)
+		return err
+	})
+	return err
+}
+
+type videoProbe struct {
+	width    int
+	height   int
+	duration float64 // 秒
+}
+
+func probeVideo(ctx context.Context, path string) (*videoProbe, error) {
+	cmd := exec.CommandContext(ctx, extconfig.Get().Transcode.FFprobePath, "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=width,height:format=duration", "-of", "json", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe失败：%v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var result struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err = json.Unmarshal(output, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Streams) == 0 {
+		return nil, errors.New("没有视频流")
+	}
+	duration, _ := strconv.ParseFloat(result.Format.Duration, 64)
+	return &videoProbe{
+		width:    result.Streams[0].Width,
+		height:   result.Streams[0].Height,
+		duration: duration,
+	}, nil
+}
+
+func runFFmpeg(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, extconfig.Get().Transcode.FFmpegPath, append([]string{"-hide_banner", "-loglevel", "error"}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg失败：%v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// transcodePaths 转码后的视频和封面与原视频保存在同一目录 例如 chat/1/xxx.mov 转码为 chat/1/xxx_h264.mp4 封面为 chat/1/xxx_poster.jpg
+func transcodePaths(path string) (string, string) {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	return base + "_h264.mp4", base + "_poster.jpg"
+}
+
+func isTranscodeVideo(path, contentType string) bool {
+	if strings.HasPrefix(strings.ToLower(contentType), "video/") {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".mov", ".m4v", ".avi", ".mkv", ".webm", ".3gp", ".flv", ".wmv":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranscodePaths(t *testing.T) {
+	video, poster := transcodePaths("chat/1/xxx.mov")
+	assert.Equal(t, "chat/1/xxx_h264.mp4", video)
+	assert.Equal(t, "chat/1/xxx_poster.jpg", poster)
+}
+
+func TestIsTranscodeVideo(t *testing.T) {
+	assert.True(t, isTranscodeVideo("chat/1/a.bin", "video/quicktime"))
+	assert.True(t, isTranscodeVideo("chat/1/a.MKV", ""))
+	assert.False(t, isTranscodeVideo("chat/1/a.jpg", "image/jpeg"))
+}
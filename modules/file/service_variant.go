@@ -0,0 +1,162 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"go.uber.org/zap"
+)
+
+const (
+	variantWebP = "webp"
+	variantAVIF = "avif"
+)
+
+// variantSource 需要生成其他格式的图片
+type variantSource struct {
+	path      string // 图片路径 例如 chat/1/xxx.png
+	thumbSize int    // 缩略图尺寸 原图为0
+	img       image.Image
+	size      int // 原格式的字节数 生成的图片不比它小时不使用
+}
+
+// variantPath 其他格式的图片与原图保存在同一目录 例如 chat/1/xxx_480.jpg 的webp为 chat/1/xxx_480.webp
+func variantPath(path, format string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + format
+}
+
+// generateVariants 生成原图和缩略图的webp、avif格式 结果保存到原图的文件记录
+func (w *thumbnailWorker) generateVariants(path string, sources []*variantSource) {
+	cfg := extconfig.Get().Variant
+	if !cfg.Enable || len(cfg.Formats) == 0 {
+		return
+	}
+	tmpDir, err := os.MkdirTemp("", "variant-*")
+	if err != nil {
+		w.Warn("创建临时目录失败！", zap.Error(err))
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	variants := make(map[string][]int)
+	for i, source := range sources {
+		// 解码后的图片已按EXIF方向旋转 用png作为ffmpeg的输入
+		srcFile := filepath.Join(tmpDir, fmt.Sprintf("%d.png", i))
+		if err = writePNG(srcFile, source.img); err != nil {
+			w.Warn("写入临时图片失败！", zap.String("path", source.path), zap.Error(err))
+			return
+		}
+		for _, format := range cfg.Formats {
+			if format != variantWebP && format != variantAVIF {
+				continue
+			}
+			if format == variantAVIF && !isOpaque(source.img) {
+				// avif的透明度需要单独编码 透明图片只生成webp
+				continue
+			}
+			data, err := encodeVariant(ctx, srcFile, filepath.Join(tmpDir, fmt.Sprintf("%d.%s", i, format)), format)
+			if err != nil {
+				w.Warn("生成其他格式的图片失败！", zap.String("path", source.path), zap.String("format", format), zap.Error(err))
+				continue
+			}
+			if len(data) >= source.size {
+				continue
+			}
+			_, err = w.service.UploadFile(variantPath(source.path, format), "image/"+format, func(writer io.Writer) error {
+				_, err := writer.Write(data)
+				return err
+			})
+			if err != nil {
+				w.Warn("上传其他格式的图片失败！", zap.String("path", source.path), zap.String("format", format), zap.Error(err))
+				continue
+			}
+			variants[format] = append(variants[format], source.thumbSize)
+		}
+	}
+	if len(variants) == 0 {
+		return
+	}
+	variantsData, _ := json.Marshal(variants)
+	if err = w.db.updateFileVariants(path, string(variantsData)); err != nil {
+		w.Warn("更新图片的其他格式失败！", zap.String("path", path), zap.Error(err))
+	}
+}
+
+func encodeVariant(ctx context.Context, srcFile, dstFile, format string) ([]byte, error) {
+	cfg := extconfig.Get().Variant
+	args := []string{"-y", "-i", srcFile, "-frames:v", "1"}
+	if format == variantAVIF {
+		args = append(args, "-c:v", "libaom-av1", "-still-picture", "1", "-crf", strconv.Itoa(cfg.AVIFCRF), "-b:v", "0", "-pix_fmt", "yuv420p")
+	} else {
+		args = append(args, "-c:v", "libwebp", "-quality", strconv.Itoa(cfg.WebPQuality))
+	}
+	if err := runFFmpeg(ctx, append(args, dstFile)...); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(dstFile)
+}
+
+func writePNG(filePath string, img image.Image) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err = encoder.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func isOpaque(img image.Image) bool {
+	if opaqueImg, ok := img.(interface{ Opaque() bool }); ok {
+		return opaqueImg.Opaque()
+	}
+	return false
+}
+
+// acceptedVariant 按Accept选择客户端支持的格式 avif优先 没有可用的格式时返回空
+func acceptedVariant(accept string, formats []string) string {
+	if accept == "" || len(formats) == 0 {
+		return ""
+	}
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		rejected := false
+		for _, param := range params[1:] {
+			if q := strings.ReplaceAll(strings.TrimSpace(param), " ", ""); strings.HasPrefix(q, "q=") {
+				if value, err := strconv.ParseFloat(q[2:], 64); err == nil && value <= 0 {
+					rejected = true
+				}
+			}
+		}
+		if !rejected {
+			accepted[mediaType] = true
+		}
+	}
+	for _, format := range []string{variantAVIF, variantWebP} {
+		if !accepted["image/"+format] {
+			continue
+		}
+		for _, available := range formats {
+			if available == format {
+				return format
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,418 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/cursor"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// sharePwdErrorCachePrefix 分享链接密码错误次数
+	sharePwdErrorCachePrefix = "fileSharePwdError:"
+	// sharePasswordMaxLen 分享密码的最大长度
+	sharePasswordMaxLen = 32
+	// shareContentCachePrefix 分享文件的下载凭证 值为分享编号
+	shareContentCachePrefix = "fileShareContent:"
+	// shareContentTTL 下载凭证的有效期 过期后需要重新下载（重新计数）
+	shareContentTTL = time.Minute * 5
+)
+
+var (
+	errShareNotExist = errors.New("分享不存在！")
+	errShareCanceled = errors.New("分享已取消！")
+	errShareExpired  = errors.New("分享已过期！")
+	errShareUsedUp   = errors.New("下载次数已用完！")
+	errShareToken    = errors.New("下载链接无效或已过期！")
+)
+
+// shareExpire 分享的有效期 expire为秒 不传时使用默认有效期
+func shareExpire(expire int64) (time.Duration, error) {
+	cfg := extconfig.Get().Share
+	if expire < 0 {
+		return 0, errors.New("有效期不能小于0！")
+	}
+	if expire == 0 {
+		return cfg.DefaultExpire, nil
+	}
+	duration := time.Duration(expire) * time.Second
+	if cfg.MaxExpire > 0 && duration > cfg.MaxExpire {
+		return 0, fmt.Errorf("有效期不能超过%d天！", int(cfg.MaxExpire.Hours()/24))
+	}
+	return duration, nil
+}
+
+// check 分享是否还能下载
+func (m *shareModel) check(now time.Time) error {
+	if err := m.checkAvailable(now); err != nil {
+		return err
+	}
+	if m.MaxDownloads > 0 && m.DownloadCount >= m.MaxDownloads {
+		return errShareUsedUp
+	}
+	return nil
+}
+
+// checkAvailable 分享是否已取消或过期 已计数的下载凭证只检查这两项
+func (m *shareModel) checkAvailable(now time.Time) error {
+	if m.Status != shareStatusNormal {
+		return errShareCanceled
+	}
+	if !now.Before(m.ExpiredAt) {
+		return errShareExpired
+	}
+	return nil
+}
+
+// shareURL 分享的落地页地址
+func (f *File) shareURL(shareNo string) string {
+	return fmt.Sprintf("%s/file_share.html?share_no=%s", f.ctx.GetConfig().External.H5BaseURL, shareNo)
+}
+
+// 生成文件的分享链接 只能分享自己上传的文件
+func (f *File) createShare(c *wkhttp.Context) {
+	if !extconfig.Get().Share.Enable {
+		c.ResponseError(errors.New("没有开启文件分享！"))
+		return
+	}
+	var req struct {
+		Path         string `json:"path"`
+		Expire       int64  `json:"expire"`        // 有效期（秒） 0为默认有效期
+		Password     string `json:"password"`      // 访问密码 为空不需要密码
+		MaxDownloads int    `json:"max_downloads"` // 最多下载次数 0为不限制
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	path := strings.TrimPrefix(strings.TrimPrefix(req.Path, "/"), "file/preview/")
+	if idx := strings.Index(path, "?"); idx >= 0 {
+		path = path[:idx]
+	}
+	if path == "" {
+		c.ResponseError(errcode.ErrFilePathRequired)
+		return
+	}
+	if len(req.Password) > sharePasswordMaxLen {
+		c.ResponseError(errors.New("密码太长！"))
+		return
+	}
+	if req.MaxDownloads < 0 {
+		c.ResponseError(errors.New("下载次数不能小于0！"))
+		return
+	}
+	expire, err := shareExpire(req.Expire)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	loginUID := c.GetLoginUID()
+	fileM, err := f.db.queryUserFileWithPath(loginUID, path)
+	if err != nil {
+		f.Error("查询文件记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询文件记录失败！"))
+		return
+	}
+	if fileM == nil {
+		c.ResponseError(errcode.ErrFileNotExist)
+		return
+	}
+	if fileM.Encrypted == 1 {
+		c.ResponseError(errors.New("加密的文件不能分享！"))
+		return
+	}
+	shareM := &shareModel{
+		ShareNo:      util.GenerUUID(),
+		UID:          loginUID,
+		Path:         fileM.Path,
+		Name:         fileM.Name,
+		Size:         fileM.Size,
+		ContentType:  fileM.ContentType,
+		ExpiredAt:    time.Now().Add(expire),
+		MaxDownloads: req.MaxDownloads,
+		Status:       shareStatusNormal,
+	}
+	if req.Password != "" {
+		shareM.Password = util.MD5(util.MD5(req.Password))
+	}
+	if err = f.db.insertShare(shareM); err != nil {
+		f.Error("添加分享失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加分享失败！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"share_no":   shareM.ShareNo,
+		"url":        f.shareURL(shareM.ShareNo),
+		"expired_at": shareM.ExpiredAt.Unix(),
+	})
+}
+
+// 查询自己的分享
+// 带cursor参数时按游标分页返回 不返回总数
+func (f *File) getShares(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	if cursor.Requested(c) {
+		page, err := cursor.Query(c)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		models, err := f.db.querySharesWithCursor(loginUID, page.Key(0), page.FetchLimit())
+		if err != nil {
+			f.Error("查询分享失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询分享失败！"))
+			return
+		}
+		n, hasMore := page.Keep(len(models))
+		models = models[:n]
+		var lastID int64
+		if n > 0 {
+			lastID = models[n-1].Id
+		}
+		c.Response(cursor.NewResult(f.shareResps(models), hasMore, lastID))
+		return
+	}
+	pageIndex, pageSize := cursor.OffsetPage(c)
+	models, err := f.db.queryShares(loginUID, pageIndex, pageSize)
+	if err != nil {
+		f.Error("查询分享失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询分享失败！"))
+		return
+	}
+	count, err := f.db.queryShareCount(loginUID)
+	if err != nil {
+		f.Error("查询分享数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询分享数量失败！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"list":  f.shareResps(models),
+		"count": count,
+	})
+}
+
+func (f *File) shareResps(models []*shareModel) []map[string]interface{} {
+	now := time.Now()
+	list := make([]map[string]interface{}, 0, len(models))
+	for _, m := range models {
+		valid := 1
+		if m.check(now) != nil {
+			valid = 0
+		}
+		list = append(list, map[string]interface{}{
+			"share_no":       m.ShareNo,
+			"url":            f.shareURL(m.ShareNo),
+			"path":           fmt.Sprintf("file/preview/%s", m.Path),
+			"name":           m.Name,
+			"size":           m.Size,
+			"need_password":  m.Password != "",
+			"expired_at":     m.ExpiredAt.Unix(),
+			"max_downloads":  m.MaxDownloads,
+			"download_count": m.DownloadCount,
+			"status":         m.Status,
+			"valid":          valid,
+			"created_at":     m.CreatedAt.String(),
+		})
+	}
+	return list
+}
+
+// 取消分享
+func (f *File) deleteShare(c *wkhttp.Context) {
+	shareNo := c.Param("share_no")
+	if err := f.db.cancelShare(c.GetLoginUID(), shareNo); err != nil {
+		f.Error("取消分享失败！", zap.Error(err))
+		c.ResponseError(errors.New("取消分享失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 落地页获取分享的文件信息 不需要登录
+func (f *File) getShare(c *wkhttp.Context) {
+	shareM, err := f.validShare(c.Param("share_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	resp := map[string]interface{}{
+		"name":          shareM.Name,
+		"size":          shareM.Size,
+		"content_type":  shareM.ContentType,
+		"need_password": shareM.Password != "",
+		"expired_at":    shareM.ExpiredAt.Unix(),
+		"max_downloads": shareM.MaxDownloads,
+	}
+	if shareM.MaxDownloads > 0 {
+		resp["remaining_downloads"] = shareM.MaxDownloads - shareM.DownloadCount
+	}
+	c.Response(resp)
+}
+
+// 下载分享的文件 需要密码时通过POST提交 不需要密码时GET直接跳转到文件地址
+func (f *File) downloadShare(c *wkhttp.Context) {
+	shareNo := c.Param("share_no")
+	shareM, err := f.validShare(shareNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if shareM.Password != "" {
+		var req struct {
+			Password string `json:"password"`
+		}
+		if c.Request.Method == http.MethodPost {
+			_ = c.BindJSON(&req)
+		}
+		if err = f.checkSharePassword(shareM, req.Password); err != nil {
+			c.ResponseErrorWithStatus(err, http.StatusForbidden)
+			return
+		}
+	}
+	// 文件可能已被生命周期规则删除
+	fileM, err := f.db.queryUserFileWithPath(shareM.UID, shareM.Path)
+	if err != nil {
+		f.Error("查询文件记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询文件记录失败！"))
+		return
+	}
+	if fileM == nil {
+		c.ResponseError(errors.New("文件已被删除！"))
+		return
+	}
+	ok, err := f.db.incrShareDownload(shareNo)
+	if err != nil {
+		f.Error("增加分享的下载次数失败！", zap.Error(err))
+		c.ResponseError(errors.New("下载失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errShareUsedUp)
+		return
+	}
+	// 短期有效的下载凭证 文件由服务端转发 不返回文件的预览地址 避免泄露后绕过分享的有效期、次数和取消
+	token := util.GenerUUID()
+	if err = f.ctx.Cache().SetAndExpire(shareContentCachePrefix+token, shareNo, shareContentTTL); err != nil {
+		f.Error("保存分享的下载凭证失败！", zap.Error(err))
+		c.ResponseError(errors.New("下载失败！"))
+		return
+	}
+	downloadURL := fmt.Sprintf("%s/file/share/%s/content?token=%s", f.ctx.GetConfig().External.APIBaseURL, shareNo, token)
+	if c.Request.Method == http.MethodPost {
+		c.Response(map[string]interface{}{
+			"url": downloadURL,
+		})
+		return
+	}
+	c.Redirect(http.StatusFound, downloadURL)
+}
+
+// 通过下载凭证获取分享的文件 凭证有效期内可以多次请求（断点续传）
+func (f *File) shareContent(c *wkhttp.Context) {
+	shareNo := c.Param("share_no")
+	token := c.Query("token")
+	if token == "" {
+		c.ResponseErrorWithStatus(errShareToken, http.StatusForbidden)
+		return
+	}
+	value, err := f.ctx.Cache().Get(shareContentCachePrefix + token)
+	if err != nil {
+		f.Error("查询分享的下载凭证失败！", zap.Error(err))
+		c.ResponseError(errors.New("下载失败！"))
+		return
+	}
+	if value == "" || value != shareNo {
+		c.ResponseErrorWithStatus(errShareToken, http.StatusForbidden)
+		return
+	}
+	shareM, err := f.db.queryShare(shareNo)
+	if err != nil {
+		f.Error("查询分享失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询分享失败！"))
+		return
+	}
+	if shareM == nil {
+		c.ResponseError(errShareNotExist)
+		return
+	}
+	if err = shareM.checkAvailable(time.Now()); err != nil {
+		c.ResponseErrorWithStatus(err, http.StatusForbidden)
+		return
+	}
+	downloadURL, err := f.service.DownloadURL("/"+shareM.Path, shareM.Name)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	f.streamFile(c, downloadURL)
+}
+
+// validShare 查询还能下载的分享
+func (f *File) validShare(shareNo string) (*shareModel, error) {
+	if shareNo == "" {
+		return nil, errShareNotExist
+	}
+	shareM, err := f.db.queryShare(shareNo)
+	if err != nil {
+		f.Error("查询分享失败！", zap.Error(err))
+		return nil, errors.New("查询分享失败！")
+	}
+	if shareM == nil {
+		return nil, errShareNotExist
+	}
+	if err = shareM.check(time.Now()); err != nil {
+		return nil, err
+	}
+	return shareM, nil
+}
+
+// sharePwdCache 记录分享密码错误次数使用的redis命令
+type sharePwdCache interface {
+	Incr(key string) (int64, error)
+	Decr(key string) (int64, error)
+	Expire(key string, expiration time.Duration) error
+}
+
+// checkSharePassword 校验分享密码 错误次数过多时暂时锁定
+// 校验前先原子地增加次数 同时校验的请求也不会超过错误次数 密码正确时再减回
+func (f *File) checkSharePassword(shareM *shareModel, password string) error {
+	if password == "" {
+		return errors.New("请输入密码！")
+	}
+	cfg := extconfig.Get().Share
+	cacheKey := sharePwdErrorCachePrefix + shareM.ShareNo
+	counted := false
+	if cfg.MaxPwdErrors > 0 {
+		count, err := f.shareCache.Incr(cacheKey)
+		if err != nil {
+			f.Warn("记录分享密码错误次数失败！", zap.Error(err))
+		} else {
+			counted = true
+			if count == 1 {
+				if err = f.shareCache.Expire(cacheKey, cfg.PwdLockTime); err != nil {
+					f.Warn("设置分享密码错误次数的过期时间失败！", zap.Error(err))
+				}
+			}
+			if count > int64(cfg.MaxPwdErrors) {
+				return errors.New("密码错误次数过多，请稍后再试！")
+			}
+		}
+	}
+	if util.MD5(util.MD5(password)) != shareM.Password {
+		return errors.New("密码错误！")
+	}
+	if counted {
+		if _, err := f.shareCache.Decr(cacheKey); err != nil {
+			f.Warn("更新分享密码错误次数失败！", zap.Error(err))
+		}
+	}
+	return nil
+}
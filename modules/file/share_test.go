@@ -0,0 +1,121 @@
+package file
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareExpire(t *testing.T) {
+	vp := viper.New()
+	vp.Set("share.defaultExpire", "24h")
+	vp.Set("share.maxExpire", "72h")
+	extconfig.Configure(vp)
+	t.Cleanup(func() {
+		extconfig.Configure(viper.New())
+	})
+
+	expire, err := shareExpire(0)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour*24, expire)
+	expire, err = shareExpire(3600)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, expire)
+	_, err = shareExpire(-1)
+	assert.Error(t, err)
+	_, err = shareExpire(int64((time.Hour * 73).Seconds()))
+	assert.Error(t, err)
+}
+
+func TestShareCheck(t *testing.T) {
+	now := time.Now()
+	m := &shareModel{Status: shareStatusNormal, ExpiredAt: now.Add(time.Hour)}
+	assert.NoError(t, m.check(now))
+
+	m.MaxDownloads = 2
+	m.DownloadCount = 1
+	assert.NoError(t, m.check(now))
+	m.DownloadCount = 2
+	assert.Equal(t, errShareUsedUp, m.check(now))
+
+	m.MaxDownloads = 0
+	assert.Equal(t, errShareExpired, m.check(now.Add(time.Hour)))
+
+	m.Status = shareStatusCanceled
+	assert.Equal(t, errShareCanceled, m.check(now))
+
+	// 已计数的下载凭证不检查下载次数
+	m = &shareModel{Status: shareStatusNormal, ExpiredAt: now.Add(time.Hour), MaxDownloads: 1, DownloadCount: 1}
+	assert.Equal(t, errShareUsedUp, m.check(now))
+	assert.NoError(t, m.checkAvailable(now))
+	assert.Equal(t, errShareExpired, m.checkAvailable(now.Add(time.Hour)))
+	m.Status = shareStatusCanceled
+	assert.Equal(t, errShareCanceled, m.checkAvailable(now))
+}
+
+// memPwdCache 内存中的错误次数 用于测试
+type memPwdCache struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (m *memPwdCache) Incr(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[key]++
+	return m.counts[key], nil
+}
+
+func (m *memPwdCache) Decr(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[key]--
+	return m.counts[key], nil
+}
+
+func (m *memPwdCache) Expire(key string, expiration time.Duration) error {
+	return nil
+}
+
+func TestShareCheckPasswordConcurrent(t *testing.T) {
+	shareCfg := &extconfig.Get().Share
+	maxPwdErrors := shareCfg.MaxPwdErrors
+	defer func() { shareCfg.MaxPwdErrors = maxPwdErrors }()
+	shareCfg.MaxPwdErrors = 5
+
+	f := &File{Log: log.NewTLog("File"), shareCache: &memPwdCache{counts: map[string]int64{}}}
+	shareM := &shareModel{ShareNo: "s1", Password: util.MD5(util.MD5("123456"))}
+
+	// 密码正确不计入错误次数
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, f.checkSharePassword(shareM, "123456"))
+	}
+
+	// 同时猜测的请求也只有MaxPwdErrors次校验密码
+	var wg sync.WaitGroup
+	var wrong, locked atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := f.checkSharePassword(shareM, fmt.Sprintf("%06d", i))
+			if err.Error() == "密码错误！" {
+				wrong.Add(1)
+			} else {
+				locked.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(5), wrong.Load())
+	assert.Equal(t, int32(45), locked.Load())
+	assert.EqualError(t, f.checkSharePassword(shareM, "123456"), "密码错误次数过多，请稍后再试！")
+}
@@ -0,0 +1,97 @@
+package file
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func configureFileSign(t *testing.T, bindUID bool) {
+	vp := viper.New()
+	vp.Set("fileSign.enable", true)
+	vp.Set("fileSign.secret", "test-secret")
+	vp.Set("fileSign.expire", "1h")
+	vp.Set("fileSign.bindUID", bindUID)
+	extconfig.Configure(vp)
+	t.Cleanup(func() {
+		extconfig.Configure(viper.New())
+	})
+}
+
+func signedQuery(t *testing.T, signedURL string) (string, url.Values) {
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(signedURL, filePreviewPrefix), "?")
+	query, err := url.ParseQuery(rawQuery)
+	assert.NoError(t, err)
+	return path, query
+}
+
+func TestSignFileURL(t *testing.T) {
+	configureFileSign(t, false)
+	now := time.Now()
+
+	signedURL := signFileURL("file/preview/chat/1/abc.png?size=240", "u1", now)
+	path, query := signedQuery(t, signedURL)
+	assert.Equal(t, "chat/1/abc.png", path)
+	assert.Equal(t, "240", query.Get("size"))
+	assert.Equal(t, "", query.Get(signQueryUID))
+	assert.NoError(t, verifyFileSign("/"+path, query, "", now))
+
+	// 重复签名替换旧的签名参数
+	resignedURL := signFileURL(signedURL, "u1", now.Add(time.Minute))
+	_, query = signedQuery(t, resignedURL)
+	assert.Len(t, query[signQuerySign], 1)
+	assert.NoError(t, verifyFileSign(path, query, "", now))
+
+	// 过期
+	assert.Equal(t, errFileSignExpired, verifyFileSign(path, query, "", now.Add(time.Hour*2)))
+
+	// 篡改路径
+	assert.Equal(t, errFileSignInvalid, verifyFileSign("chat/1/other.png", query, "", now))
+
+	// 不需要签名的类型和非预览地址原样返回
+	assert.Equal(t, "file/preview/avatar/1/abc.png", signFileURL("file/preview/avatar/1/abc.png", "u1", now))
+	assert.Equal(t, "https://example.com/a.png", signFileURL("https://example.com/a.png", "u1", now))
+}
+
+func TestSignFileURLBindUID(t *testing.T) {
+	configureFileSign(t, true)
+	now := time.Now()
+
+	signedURL := signFileURL("https://api.example.com/v1/file/preview/chat/1/abc.mp4", "u1", now)
+	assert.True(t, strings.HasPrefix(signedURL, "https://api.example.com/v1/file/preview/chat/1/abc.mp4?"))
+	path, query := signedQuery(t, signedURL[strings.Index(signedURL, filePreviewPrefix):])
+	assert.Equal(t, "u1", query.Get(signQueryUID))
+	assert.NoError(t, verifyFileSign(path, query, "u1", now))
+	assert.Equal(t, errFileSignInvalid, verifyFileSign(path, query, "u2", now))
+	assert.Equal(t, errFileSignInvalid, verifyFileSign(path, query, "", now))
+
+	// 修改uid参数后签名失效
+	query.Set(signQueryUID, "u2")
+	assert.Equal(t, errFileSignInvalid, verifyFileSign(path, query, "u2", now))
+}
+
+func TestCleanFilePath(t *testing.T) {
+	for _, p := range []string{"/chat/1/x.png", "chat/1/x.png"} {
+		cleaned, ok := cleanFilePath(p)
+		assert.True(t, ok, p)
+		assert.Equal(t, "/chat/1/x.png", cleaned)
+	}
+	// 不规范的路径会被文件服务还原 需要拒绝
+	for _, p := range []string{"", "/", "//chat/1/x.png", "/./chat/1/x.png", "/common/../chat/1/x.png", "/chat//1/x.png", "/../x.png", "/chat/1/x.png/"} {
+		_, ok := cleanFilePath(p)
+		assert.False(t, ok, p)
+	}
+}
+
+func TestFileSignRequiredNonCanonical(t *testing.T) {
+	configureFileSign(t, false)
+	for _, p := range []string{"/chat/1/x.png", "//chat/1/x.png", "/./chat/1/x.png", "/common/../chat/1/x.png"} {
+		assert.True(t, fileSignRequired(p), p)
+	}
+	assert.False(t, fileSignRequired("/common/1/x.png"))
+}
@@ -0,0 +1,37 @@
+-- +migrate Up
+
+-- ##########  文件 ##########
+create table `file`
+(
+    id           integer       not null primary key AUTO_INCREMENT,
+    uid          VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '上传用户',
+    file_type    VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '文件类型 chat moment sticker等',
+    path         VARCHAR(400)  NOT NULL DEFAULT '' COMMENT '文件路径（文件服务中的路径 例如 chat/1/xxx.mp4）',
+    name         VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '文件名',
+    size         BIGINT        NOT NULL DEFAULT 0  COMMENT '文件大小（字节）',
+    content_type VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '文件的Content-Type',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX file_path_idx on `file` (path);
+CREATE INDEX file_uid_idx on `file` (uid);
+
+-- ##########  断点续传（tus） ##########
+create table `file_upload`
+(
+    id            integer       not null primary key AUTO_INCREMENT,
+    upload_id     VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '上传ID',
+    uid           VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '上传用户',
+    file_type     VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '文件类型',
+    path          VARCHAR(400)  NOT NULL DEFAULT '' COMMENT '上传完成后保存的文件路径',
+    name          VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '文件名',
+    content_type  VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '文件的Content-Type',
+    size          BIGINT        NOT NULL DEFAULT 0  COMMENT '文件大小（字节）',
+    upload_offset BIGINT        NOT NULL DEFAULT 0  COMMENT '已上传的大小（字节）',
+    status        smallint      NOT NULL DEFAULT 0  COMMENT '状态 0.上传中 1.已完成',
+    expired_at    timeStamp     not null DEFAULT CURRENT_TIMESTAMP COMMENT '过期时间（上传中的才会过期）',
+    created_at    timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at    timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX file_upload_id_uidx on `file_upload` (upload_id);
+CREATE INDEX file_upload_expired_idx on `file_upload` (status, expired_at);
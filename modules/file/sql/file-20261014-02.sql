@@ -0,0 +1,6 @@
+-- +migrate Up
+
+ALTER TABLE `file` ADD COLUMN width integer NOT NULL DEFAULT 0 COMMENT '图片宽度';
+ALTER TABLE `file` ADD COLUMN height integer NOT NULL DEFAULT 0 COMMENT '图片高度';
+ALTER TABLE `file` ADD COLUMN blurhash VARCHAR(100) NOT NULL DEFAULT '' COMMENT '图片blurhash占位图';
+ALTER TABLE `file` ADD COLUMN thumbnails VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '缩略图（json 尺寸:文件路径）';
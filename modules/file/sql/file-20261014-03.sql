@@ -0,0 +1,22 @@
+-- +migrate Up
+
+-- ##########  视频转码 ##########
+create table `file_transcode`
+(
+    id           integer       not null primary key AUTO_INCREMENT,
+    uid          VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '上传用户',
+    path         VARCHAR(400)  NOT NULL DEFAULT '' COMMENT '原视频路径',
+    name         VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '文件名',
+    status       smallint      NOT NULL DEFAULT 0  COMMENT '状态 0.等待转码 1.转码中 2.成功 3.失败',
+    attempts     integer       NOT NULL DEFAULT 0  COMMENT '已尝试转码的次数',
+    output_path  VARCHAR(400)  NOT NULL DEFAULT '' COMMENT '转码后的视频路径（H.264 mp4）',
+    poster_path  VARCHAR(400)  NOT NULL DEFAULT '' COMMENT '封面路径',
+    width        integer       NOT NULL DEFAULT 0  COMMENT '转码后的视频宽度',
+    height       integer       NOT NULL DEFAULT 0  COMMENT '转码后的视频高度',
+    duration     integer       NOT NULL DEFAULT 0  COMMENT '视频时长（秒）',
+    err_msg      VARCHAR(500)  NOT NULL DEFAULT '' COMMENT '转码失败的原因',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX file_transcode_path_uidx on `file_transcode` (path);
+CREATE INDEX file_transcode_status_idx on `file_transcode` (status, updated_at);
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+ALTER TABLE `file` ADD COLUMN hash VARCHAR(64) NOT NULL DEFAULT '' COMMENT '文件内容的sha256';
+
+-- ##########  文件内容（相同内容的文件只保存一份） ##########
+create table `file_blob`
+(
+    id           integer       not null primary key AUTO_INCREMENT,
+    file_type    VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '文件类型',
+    hash         VARCHAR(64)   NOT NULL DEFAULT '' COMMENT '文件内容的sha256',
+    path         VARCHAR(400)  NOT NULL DEFAULT '' COMMENT '文件服务中保存的路径',
+    size         BIGINT        NOT NULL DEFAULT 0  COMMENT '文件大小（字节）',
+    content_type VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '文件的Content-Type',
+    ref_count    integer       NOT NULL DEFAULT 0  COMMENT '引用次数（文件记录数）',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX file_blob_hash_uidx on `file_blob` (file_type, hash);
@@ -0,0 +1,24 @@
+-- +migrate Up
+
+-- ##########  用户存储空间 ##########
+create table `file_quota`
+(
+    id           integer       not null primary key AUTO_INCREMENT,
+    uid          VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '用户',
+    used         BIGINT        NOT NULL DEFAULT 0  COMMENT '已使用的空间（字节）',
+    quota        BIGINT        NOT NULL DEFAULT -1 COMMENT '用户配额（字节） -1.使用角色或默认配额 0.不限制',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX file_quota_uid_uidx on `file_quota` (uid);
+
+-- ##########  角色的存储配额 ##########
+create table `file_quota_role`
+(
+    id           integer       not null primary key AUTO_INCREMENT,
+    role         VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '角色 user.普通用户 admin.管理员 superAdmin.超级管理员',
+    quota        BIGINT        NOT NULL DEFAULT 0  COMMENT '配额（字节） 0.不限制',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX file_quota_role_uidx on `file_quota_role` (role);
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+ALTER TABLE `file` ADD COLUMN storage_class VARCHAR(40) NOT NULL DEFAULT '' COMMENT '存储类型 空为标准存储';
+ALTER TABLE `file` ADD COLUMN is_deleted smallint NOT NULL DEFAULT 0 COMMENT '是否已被生命周期规则删除';
+CREATE INDEX file_type_created_idx on `file` (file_type, is_deleted, created_at);
+
+-- ##########  频道的文件生命周期规则 ##########
+create table `file_lifecycle_rule`
+(
+    id                integer       not null primary key AUTO_INCREMENT,
+    channel_id        VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '频道ID',
+    channel_type      smallint      NOT NULL DEFAULT 0  COMMENT '频道类型',
+    delete_after_days integer       NOT NULL DEFAULT -1 COMMENT '文件保存天数 -1.使用默认规则 0.永久保存',
+    cold_after_days   integer       NOT NULL DEFAULT -1 COMMENT '超过天数转为低频存储 -1.使用默认规则 0.不转',
+    created_at        timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at        timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX file_lifecycle_rule_channel_uidx on `file_lifecycle_rule` (channel_id, channel_type);
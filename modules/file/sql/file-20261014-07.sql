@@ -0,0 +1,4 @@
+-- +migrate Up
+
+ALTER TABLE `file` ADD COLUMN duration integer NOT NULL DEFAULT 0 COMMENT '语音时长（秒）';
+ALTER TABLE `file` ADD COLUMN waveform VARCHAR(500) NOT NULL DEFAULT '' COMMENT '语音波形（base64 每个字节为一段的峰值 0-255）';
@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE `file` ADD COLUMN variants VARCHAR(200) NOT NULL DEFAULT '' COMMENT '图片的其他格式（json 格式:[尺寸] 原图的尺寸为0）';
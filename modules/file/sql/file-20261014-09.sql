@@ -0,0 +1,30 @@
+-- +migrate Up
+
+-- ##########  需要审计文件访问的频道 ##########
+create table `file_audit_channel`
+(
+    id           integer       not null primary key AUTO_INCREMENT,
+    channel_id   VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '频道ID',
+    channel_type smallint      NOT NULL DEFAULT 0  COMMENT '频道类型',
+    operator     VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '设置的管理员uid',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX file_audit_channel_uidx on `file_audit_channel` (channel_id, channel_type);
+
+-- ##########  文件访问记录 ##########
+create table `file_audit_log`
+(
+    id           bigint        not null primary key AUTO_INCREMENT,
+    uid          VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '访问的用户uid 无法识别时为空',
+    path         VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '文件路径',
+    channel_id   VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '频道ID',
+    channel_type smallint      NOT NULL DEFAULT 0  COMMENT '频道类型',
+    ip           VARCHAR(50)   NOT NULL DEFAULT '' COMMENT '访问的IP',
+    user_agent   VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '访问的设备（User-Agent）',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX file_audit_log_channel_idx on `file_audit_log` (channel_id, channel_type, created_at);
+CREATE INDEX file_audit_log_uid_idx on `file_audit_log` (uid, created_at);
+CREATE INDEX file_audit_log_path_idx on `file_audit_log` (path);
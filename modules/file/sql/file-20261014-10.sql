@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE `file` ADD COLUMN encrypted smallint NOT NULL DEFAULT 0 COMMENT '是否为客户端加密的文件 加密的文件不生成缩略图、转码和波形';
+ALTER TABLE `file` ADD COLUMN encrypt_alg VARCHAR(40) NOT NULL DEFAULT '' COMMENT '加密算法 例如 AES-256-GCM';
+ALTER TABLE `file` ADD COLUMN key_wrap VARCHAR(2000) NOT NULL DEFAULT '' COMMENT '包装后的文件密钥等元数据 由客户端解析';
+ALTER TABLE `file` ADD COLUMN cipher_hash VARCHAR(64) NOT NULL DEFAULT '' COMMENT '密文的sha256';
+
+ALTER TABLE `file_upload` ADD COLUMN encrypted smallint NOT NULL DEFAULT 0 COMMENT '是否为客户端加密的文件';
+ALTER TABLE `file_upload` ADD COLUMN encrypt_alg VARCHAR(40) NOT NULL DEFAULT '' COMMENT '加密算法';
+ALTER TABLE `file_upload` ADD COLUMN key_wrap VARCHAR(2000) NOT NULL DEFAULT '' COMMENT '包装后的文件密钥等元数据';
+ALTER TABLE `file_upload` ADD COLUMN cipher_hash VARCHAR(64) NOT NULL DEFAULT '' COMMENT '密文的sha256';
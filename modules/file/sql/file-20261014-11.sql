@@ -0,0 +1,22 @@
+-- +migrate Up
+
+-- ##########  文件分享链接 ##########
+create table `file_share`
+(
+    id             integer       not null primary key AUTO_INCREMENT,
+    share_no       VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '分享编号',
+    uid            VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '分享者uid',
+    path           VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '文件路径',
+    name           VARCHAR(255)  NOT NULL DEFAULT '' COMMENT '文件名',
+    size           bigint        NOT NULL DEFAULT 0  COMMENT '文件大小',
+    content_type   VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '文件类型',
+    password       VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '访问密码 md5(md5(密码)) 为空不需要密码',
+    expired_at     timeStamp     not null DEFAULT CURRENT_TIMESTAMP COMMENT '过期时间',
+    max_downloads  integer       NOT NULL DEFAULT 0  COMMENT '最多下载次数 0为不限制',
+    download_count integer       NOT NULL DEFAULT 0  COMMENT '已下载次数',
+    status         smallint      NOT NULL DEFAULT 1  COMMENT '状态 0.已取消 1.正常',
+    created_at     timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at     timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX file_share_no_uidx on `file_share` (share_no);
+CREATE INDEX file_share_uid_idx on `file_share` (uid, created_at);
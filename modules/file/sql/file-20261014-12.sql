@@ -0,0 +1,5 @@
+-- +migrate Up
+
+-- 多组织 文件属于上传用户的组织 用于统计组织的存储空间
+ALTER TABLE `file` ADD COLUMN `tenant_id` VARCHAR(40) NOT NULL DEFAULT '' COMMENT '组织ID';
+CREATE INDEX file_tenant_idx on `file` (tenant_id);
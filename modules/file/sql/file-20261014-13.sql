@@ -0,0 +1,6 @@
+-- +migrate Up
+
+-- 多组织 文件内容只在同一组织内共用
+ALTER TABLE `file_blob` ADD COLUMN `tenant_id` VARCHAR(40) NOT NULL DEFAULT '' COMMENT '组织ID';
+DROP INDEX file_blob_hash_uidx ON `file_blob`;
+CREATE UNIQUE INDEX file_blob_hash_uidx on `file_blob` (tenant_id, file_type, hash);
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+-- 断点续传 记录保存分片的实例 tus.dir不共享时只能由该实例继续上传
+ALTER TABLE `file_upload` ADD COLUMN `node` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '保存分片的实例';
@@ -0,0 +1,103 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+var (
+	// streamRequestHeaders 转发给存储的请求头 Range和条件请求由存储处理
+	streamRequestHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since"}
+	// streamResponseHeaders 返回给客户端的存储响应头
+	streamResponseHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Content-Disposition", "Content-Encoding", "Accept-Ranges", "ETag", "Last-Modified", "Cache-Control", "Expires"}
+
+	streamClientOnce sync.Once
+	streamClient     *http.Client
+)
+
+// streamEnabled 该文件是否由服务端转发 path例如 /chat/1/xxx.mp4
+func streamEnabled(path string) bool {
+	cfg := extconfig.Get().Stream
+	if !cfg.Enable {
+		return false
+	}
+	fileType := fileTypeOfPath(path)
+	for _, streamType := range cfg.Types {
+		if streamType == fileType {
+			return true
+		}
+	}
+	return false
+}
+
+// getStreamClient 下载大文件不能限制整个请求的时间 只限制等待响应头的时间
+func getStreamClient() *http.Client {
+	streamClientOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = extconfig.Get().Stream.HeaderTimeout
+		// 保持存储返回的Content-Length和Content-Range 不自动解压
+		transport.DisableCompression = true
+		streamClient = &http.Client{Transport: transport}
+	})
+	return streamClient
+}
+
+// streamFile 转发存储的文件 支持Range断点下载、If-None-Match等条件请求和HEAD请求
+func (f *File) streamFile(c *wkhttp.Context, downloadURL string) {
+	// 存储的签名地址只允许GET HEAD请求也用GET获取响应头
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, downloadURL, nil)
+	if err != nil {
+		f.Error("创建下载请求失败！", zap.String("url", downloadURL), zap.Error(err))
+		c.ResponseErrorWithStatus(errors.New("获取文件失败！"), http.StatusInternalServerError)
+		return
+	}
+	for _, header := range streamRequestHeaders {
+		if value := c.GetHeader(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	resp, err := getStreamClient().Do(req)
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			// 客户端已断开
+			return
+		}
+		f.Error("获取存储的文件失败！", zap.String("url", downloadURL), zap.Error(err))
+		c.ResponseErrorWithStatus(errors.New("获取文件失败！"), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusNotModified, http.StatusPreconditionFailed, http.StatusRequestedRangeNotSatisfiable:
+	case http.StatusNotFound, http.StatusForbidden: // 对象不存在时部分存储返回403
+		c.ResponseErrorWithStatus(errcode.ErrFileNotExist, http.StatusNotFound)
+		return
+	default:
+		f.Warn("存储返回状态有误！", zap.String("url", downloadURL), zap.Int("status", resp.StatusCode))
+		c.ResponseErrorWithStatus(errors.New("获取文件失败！"), http.StatusBadGateway)
+		return
+	}
+	header := c.Writer.Header()
+	for _, name := range streamResponseHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	c.Status(resp.StatusCode)
+	if c.Request.Method == http.MethodHead || resp.StatusCode == http.StatusNotModified {
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	// 边读边写 不缓存整个文件
+	if _, err = io.Copy(c.Writer, resp.Body); err != nil && c.Request.Context().Err() == nil {
+		f.Warn("转发文件中断！", zap.String("url", downloadURL), zap.Error(err))
+	}
+}
@@ -0,0 +1,70 @@
+package file
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamFile(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.mp4" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "video/mp4")
+		http.ServeContent(w, r, "a.mp4", time.Unix(1700000000, 0), bytes.NewReader(content))
+	}))
+	defer storage.Close()
+
+	f := &File{Log: log.NewTLog("File")}
+	r := wkhttp.New()
+	handler := func(path string) wkhttp.HandlerFunc {
+		return func(c *wkhttp.Context) {
+			f.streamFile(c, storage.URL+path)
+		}
+	}
+	r.GET("/a.mp4", handler("/a.mp4"))
+	r.GET("/missing.mp4", handler("/missing.mp4"))
+
+	request := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/a.mp4", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.Bytes())
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	assert.Equal(t, "video/mp4", w.Header().Get("Content-Type"))
+
+	w = request("/a.mp4", map[string]string{"Range": "bytes=10-14"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "abcde", w.Body.String())
+	assert.Equal(t, "bytes 10-14/20", w.Header().Get("Content-Range"))
+
+	w = request("/a.mp4", map[string]string{"If-None-Match": `"v1"`})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+
+	w = request("/a.mp4", map[string]string{"Range": "bytes=100-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+
+	w = request("/missing.mp4", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":404`)
+}
@@ -0,0 +1,30 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptedVariant(t *testing.T) {
+	formats := []string{variantWebP, variantAVIF}
+	assert.Equal(t, variantAVIF, acceptedVariant("image/avif,image/webp,image/apng,*/*;q=0.8", formats))
+	assert.Equal(t, variantWebP, acceptedVariant("image/webp,*/*", formats))
+	assert.Equal(t, variantWebP, acceptedVariant("image/avif;q=0, image/webp;q=0.9", formats))
+	assert.Equal(t, "", acceptedVariant("image/*", formats))
+	assert.Equal(t, "", acceptedVariant("", formats))
+	assert.Equal(t, "", acceptedVariant("image/avif", []string{variantWebP}))
+}
+
+func TestVariantPaths(t *testing.T) {
+	assert.Equal(t, "chat/1/u1/a_480.webp", variantPath("chat/1/u1/a_480.jpg", variantWebP))
+
+	m := &fileModel{
+		Path:       "chat/1/u1/a.jpg",
+		Thumbnails: `{"480":"chat/1/u1/a_480.jpg"}`,
+		Variants:   `{"webp":[0,480],"avif":[480]}`,
+	}
+	assert.ElementsMatch(t, []string{variantWebP}, m.variantFormats(0))
+	assert.ElementsMatch(t, []string{variantWebP, variantAVIF}, m.variantFormats(480))
+	assert.ElementsMatch(t, []string{"chat/1/u1/a.webp", "chat/1/u1/a_480.webp", "chat/1/u1/a_480.avif"}, m.variantPaths())
+}
@@ -0,0 +1,142 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"go.uber.org/zap"
+)
+
+// VoiceWaveform 语音的时长和波形
+type VoiceWaveform struct {
+	Duration int    // 时长（秒）
+	Waveform string // 波形 base64 每个字节为一段的峰值 0-255
+}
+
+// voiceWaveform 计算上传的语音的时长和波形并保存到文件记录 不是聊天语音或计算失败时返回nil
+func (f *File) voiceWaveform(fileType Type, path, contentType string, content io.ReadSeeker) *VoiceWaveform {
+	cfg := extconfig.Get().Waveform
+	if !cfg.Enable || fileType != TypeChat || !isVoiceAudio(path, contentType) {
+		return nil
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		f.Warn("设置文件偏移量错误", zap.Error(err))
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	samples, err := decodeVoice(ctx, filepath.Ext(path), content, cfg.SampleRate)
+	if err != nil {
+		f.Warn("解码语音失败！", zap.String("path", path), zap.Error(err))
+		return nil
+	}
+	waveform := &VoiceWaveform{
+		Duration: sampleDuration(len(samples), cfg.SampleRate),
+		Waveform: base64.StdEncoding.EncodeToString(waveformPeaks(samples, cfg.Peaks)),
+	}
+	if err = f.db.updateFileAudio(strings.TrimPrefix(path, "/"), waveform.Duration, waveform.Waveform); err != nil {
+		f.Warn("更新语音的波形失败！", zap.String("path", path), zap.Error(err))
+	}
+	return waveform
+}
+
+// decodeVoice 用ffmpeg把语音解码为单声道16位的采样 m4a等格式需要能随机读取 所以先写到临时文件
+func decodeVoice(ctx context.Context, ext string, content io.Reader, sampleRate int) ([]int16, error) {
+	tmpFile, err := os.CreateTemp("", "voice-*"+ext)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = io.Copy(tmpFile, content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, extconfig.Get().Transcode.FFmpegPath, "-hide_banner", "-loglevel", "error", "-i", tmpFile.Name(), "-vn", "-ac", "1", "-ar", strconv.Itoa(sampleRate), "-f", "s16le", "-acodec", "pcm_s16le", "pipe:1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg失败：%v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if len(output) < 2 {
+		return nil, errors.New("没有音频数据")
+	}
+	samples := make([]int16, len(output)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(output[i*2:]))
+	}
+	return samples, nil
+}
+
+// sampleDuration 采样数对应的秒数 四舍五入 不足1秒按1秒
+func sampleDuration(sampleCount int, sampleRate int) int {
+	if sampleCount <= 0 || sampleRate <= 0 {
+		return 0
+	}
+	duration := int(math.Round(float64(sampleCount) / float64(sampleRate)))
+	if duration < 1 {
+		duration = 1
+	}
+	return duration
+}
+
+// waveformPeaks 把采样平均分为count段 取每段的最大振幅 按最大的一段归一化到0-255
+func waveformPeaks(samples []int16, count int) []byte {
+	if len(samples) == 0 || count <= 0 {
+		return []byte{}
+	}
+	if count > len(samples) {
+		count = len(samples)
+	}
+	peaks := make([]int, count)
+	maxPeak := 0
+	for i := range peaks {
+		start, end := i*len(samples)/count, (i+1)*len(samples)/count
+		for _, sample := range samples[start:end] {
+			amplitude := int(sample)
+			if amplitude < 0 {
+				amplitude = -amplitude
+			}
+			if amplitude > peaks[i] {
+				peaks[i] = amplitude
+			}
+		}
+		if peaks[i] > maxPeak {
+			maxPeak = peaks[i]
+		}
+	}
+	result := make([]byte, count)
+	if maxPeak == 0 {
+		return result
+	}
+	for i, peak := range peaks {
+		result[i] = byte(peak * 255 / maxPeak)
+	}
+	return result
+}
+
+func isVoiceAudio(path, contentType string) bool {
+	if strings.HasPrefix(strings.ToLower(contentType), "audio/") {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".aac", ".amr", ".m4a", ".mp3", ".ogg", ".opus", ".wav", ".spx":
+		return true
+	}
+	return false
+}
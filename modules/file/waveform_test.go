@@ -0,0 +1,32 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaveformPeaks(t *testing.T) {
+	samples := []int16{0, 100, -200, 50, 0, 0, -32768, 400}
+	assert.Equal(t, []byte{0, 1, 0, 255}, waveformPeaks(samples, 4))
+
+	// 采样数少于段数时每个采样一段
+	assert.Equal(t, []byte{127, 255}, waveformPeaks([]int16{-50, 100}, 64))
+
+	// 静音
+	assert.Equal(t, []byte{0, 0}, waveformPeaks([]int16{0, 0, 0, 0}, 2))
+	assert.Equal(t, []byte{}, waveformPeaks(nil, 64))
+}
+
+func TestSampleDuration(t *testing.T) {
+	assert.Equal(t, 0, sampleDuration(0, 8000))
+	assert.Equal(t, 1, sampleDuration(100, 8000))
+	assert.Equal(t, 3, sampleDuration(8000*3+3000, 8000))
+	assert.Equal(t, 4, sampleDuration(8000*3+5000, 8000))
+}
+
+func TestIsVoiceAudio(t *testing.T) {
+	assert.True(t, isVoiceAudio("chat/1/u1/a.bin", "audio/aac"))
+	assert.True(t, isVoiceAudio("chat/1/u1/a.M4A", "application/octet-stream"))
+	assert.False(t, isVoiceAudio("chat/1/u1/a.mp4", "video/mp4"))
+}
@@ -4,10 +4,12 @@ import (
 	"embed"
 	"fmt"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rpcapi"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/model"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+	"google.golang.org/grpc"
 )
 
 //go:embed sql
@@ -21,6 +23,9 @@ func init() {
 
 		fmt.Println("register......")
 		api := New(ctx.(*config.Context))
+		rpcapi.Register(func(s grpc.ServiceRegistrar) {
+			rpcapi.RegisterGroupServiceServer(s, newGroupRPC(api))
+		})
 		return register.Module{
 			Name: "group",
 			SetupAPI: func() register.APIRouter {
@@ -162,6 +167,8 @@ func newChannelRespWithGroupResp(groupResp *GroupResp) *model.ChannelResp {
 	extraMap["allow_view_history_msg"] = groupResp.AllowViewHistoryMsg
 	extraMap["group_type"] = groupResp.GroupType
 	extraMap["allow_member_pinned_message"] = groupResp.AllowMemberPinnedMessage
+	extraMap["push_privacy"] = groupResp.PushPrivacy
+	extraMap["notify_level"] = groupResp.NotifyLevel
 	if groupResp.MemberCount != 0 {
 		extraMap["member_count"] = groupResp.MemberCount
 	}
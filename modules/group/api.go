@@ -16,6 +16,11 @@ import (
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/cursor"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/eventbus"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/httpcache"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/model"
@@ -39,6 +44,7 @@ type Group struct {
 	groupService  IService
 	fileService   file.IService
 	commonService common2.IService
+	tenantService *tenant.Service
 }
 
 // New New
@@ -53,6 +59,7 @@ func New(ctx *config.Context) *Group {
 		groupService:  NewService(ctx),
 		fileService:   file.NewService(ctx),
 		commonService: common2.NewService(ctx),
+		tenantService: tenant.NewService(ctx),
 	}
 	g.ctx.AddEventListener(event.GroupDisband, g.handleGroupDisbandEvent)
 	g.ctx.AddEventListener(event.EventUserRegister, g.handleRegisterUserEvent)
@@ -186,24 +193,54 @@ func (g *Group) disband(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	g.ctx.EventCommit(eventID)
 	c.ResponseOK()
 }
 
+// 获取群成员
+// 带cursor参数时按游标分页返回 按创建者、管理者、普通成员排序
 func (g *Group) membersGet(c *wkhttp.Context) {
 	keyword := c.Query("keyword")
 	groupNo := c.Param("group_no")
+	if cursor.Requested(c) {
+		page, err := cursor.QueryWithLimit(c, memberListDefaultLimit, memberListMaxLimit)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		members, err := g.db.queryMembersWithCursor(groupNo, c.GetLoginUID(), keyword, page.Key(0), page.Key(1), page.FetchLimit())
+		if err != nil {
+			g.Error("查询成员列表失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询成员列表失败！"))
+			return
+		}
+		n, hasMore := page.Keep(len(members))
+		resps := make([]memberDetailResp, 0, n)
+		for _, memberModel := range members[:n] {
+			resp := memberDetailResp{}
+			resps = append(resps, resp.from(memberModel))
+		}
+		var rank, lastID int64
+		if n > 0 {
+			rank, lastID = memberRank(members[n-1].Role), members[n-1].Id
+		}
+		c.Response(cursor.NewResult(resps, hasMore, rank, lastID))
+		return
+	}
 	limit, _ := strconv.ParseUint(c.Query("limit"), 10, 64)
 	page, _ := strconv.ParseUint(c.Query("page"), 10, 64)
 	if page <= 0 {
 		page = 1
 	}
 
-	if limit <= 0 || limit > 100000 {
-		limit = 100
+	if limit <= 0 {
+		limit = memberListDefaultLimit
+	}
+	if limit > memberListMaxLimit {
+		limit = memberListMaxLimit
 	}
 	var members []*MemberDetailModel
 	var err error
@@ -283,7 +320,7 @@ func (g *Group) avatarUpload(c *wkhttp.Context) {
 	loginUID := c.GetLoginUID()
 	groupNo := c.Param("group_no")
 	if groupNo == "" {
-		c.ResponseError(errors.New("群编号不能为空"))
+		c.ResponseError(errcode.ErrGroupNoRequired)
 		return
 	}
 	_, err := g.getGroupInfo(groupNo)
@@ -302,7 +339,7 @@ func (g *Group) avatarUpload(c *wkhttp.Context) {
 	file, _, err := c.Request.FormFile("file")
 	if err != nil {
 		g.Error("读取文件失败！", zap.Error(err))
-		c.ResponseError(errors.New("读取文件失败！"))
+		c.ResponseError(errcode.ErrReadFile)
 		return
 	}
 
@@ -325,7 +362,7 @@ func (g *Group) avatarUpload(c *wkhttp.Context) {
 	defer file.Close()
 	if err != nil {
 		g.Error("上传文件失败！", zap.Error(err))
-		c.ResponseError(errors.New("上传文件失败！"))
+		c.ResponseError(errcode.ErrUploadFile)
 		return
 	}
 	err = g.db.updateAvatar(groupAvatarPath, groupNo)
@@ -364,7 +401,7 @@ func (g *Group) syncMembers(c *wkhttp.Context) {
 	group, err := g.db.QueryWithGroupNo(groupNo)
 	if err != nil {
 		g.Error("查询群信息失败！", zap.Error(err), zap.String("groupNo", groupNo))
-		c.ResponseError(errors.New("查询群信息失败！"))
+		c.ResponseError(errcode.ErrQueryGroup)
 		return
 	}
 	if group == nil {
@@ -378,9 +415,38 @@ func (g *Group) syncMembers(c *wkhttp.Context) {
 		return
 	}
 
+	// 带cursor参数时按游标分页返回 游标为上一页最后一个成员的version
+	if cursor.Requested(c) {
+		page, err := cursor.QueryWithLimit(c, memberListDefaultLimit, memberListMaxLimit)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		memberModels, err := g.db.SyncMembers(groupNo, page.Key(0), page.FetchLimit())
+		if err != nil {
+			g.Error("同步成员信息失败！", zap.Error(err), zap.String("groupNo", groupNo))
+			c.ResponseError(errors.New("同步成员信息失败！"))
+			return
+		}
+		n, hasMore := page.Keep(len(memberModels))
+		resps := make([]memberDetailResp, 0, n)
+		for _, memberModel := range memberModels[:n] {
+			resp := memberDetailResp{}
+			resps = append(resps, resp.from(memberModel))
+		}
+		var lastVersion int64
+		if n > 0 {
+			lastVersion = memberModels[n-1].Version
+		}
+		c.Response(cursor.NewResult(resps, hasMore, lastVersion))
+		return
+	}
 	limit, _ := strconv.ParseUint(c.Query("limit"), 10, 64)
 	if limit <= 0 {
-		limit = 100
+		limit = memberListDefaultLimit
+	}
+	if limit > memberListMaxLimit {
+		limit = memberListMaxLimit
 	}
 	version, _ := strconv.ParseInt(c.Query("version"), 10, 64)
 	memberModels, err := g.db.SyncMembers(groupNo, version, limit)
@@ -412,7 +478,7 @@ func (g *Group) groupGet(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
-	c.Response(groupResp)
+	httpcache.Response(c, groupResp)
 }
 
 // 获取群详情
@@ -421,11 +487,11 @@ func (g *Group) groupDetailGet(c *wkhttp.Context) {
 	groupModel, err := g.db.QueryWithGroupNo(groupNo)
 	if err != nil {
 		g.Error("查询群信息失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询群信息失败！"))
+		c.ResponseError(errcode.ErrQueryGroup)
 		return
 	}
 	if groupModel == nil {
-		c.ResponseError(errors.New("群不存在！"))
+		c.ResponseError(errcode.ErrGroupNotExist)
 		return
 	}
 	memberCount, err := g.db.QueryMemberCount(groupNo)
@@ -516,6 +582,15 @@ func (g *Group) groupCreate(c *wkhttp.Context) {
 		c.ResponseError(errors.New("添加用户非好友关系，请先添加好友"))
 		return
 	}
+	tenantID := tenant.ID(c.Context)
+	if err = g.tenantService.CheckGroupQuota(tenantID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err = g.tenantService.CheckUsers(tenantID, realUids...); err != nil {
+		c.ResponseError(err)
+		return
+	}
 	// 判断是否允许系统账号进入群聊
 	appConfig, err := g.commonService.GetAppConfig()
 	if err != nil {
@@ -608,6 +683,7 @@ func (g *Group) groupCreate(c *wkhttp.Context) {
 		Status:              GroupStatusNormal,
 		Version:             version,
 		AllowViewHistoryMsg: int(common.GroupAllowViewHistoryMsgEnabled),
+		TenantID:            tenantID,
 	}, tx)
 	if err != nil {
 		g.Error("添加群失败！", zap.Error(err))
@@ -654,7 +730,7 @@ func (g *Group) groupCreate(c *wkhttp.Context) {
 	if len(realMemberUids) <= 0 {
 		tx.RollbackUnlessCommitted()
 		g.Error("群成员不能为空！")
-		c.ResponseError(errors.New("群成员不能为空！"))
+		c.ResponseError(errcode.ErrGroupMembersRequired)
 		return
 	}
 	// 发布群创建事件
@@ -727,7 +803,7 @@ func (g *Group) groupCreate(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	g.ctx.EventCommit(eventID)
@@ -735,10 +811,16 @@ func (g *Group) groupCreate(c *wkhttp.Context) {
 	if unableAddDestroyAccount != 0 {
 		g.ctx.EventCommit(unableAddDestroyAccount)
 	}
+	eventbus.Publish(eventbus.NewEvent(eventbus.GroupCreated, groupNo, &eventbus.GroupCreatedData{
+		GroupNo: groupNo,
+		Name:    groupName,
+		Creator: creator,
+		Members: realMemberUids,
+	}))
 	groupModel, err := g.db.QueryWithGroupNo(groupNo)
 	if err != nil {
 		g.Error("查询群信息失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询群信息失败！"))
+		c.ResponseError(errcode.ErrQueryGroup)
 		return
 	}
 	groupResp := &GroupResp{}
@@ -759,7 +841,7 @@ func (g *Group) groupUpdate(c *wkhttp.Context) {
 	var groupMap map[string]string
 	if err := c.BindJSON(&groupMap); err != nil {
 		g.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if len(groupMap) <= 0 {
@@ -832,7 +914,7 @@ func (g *Group) groupUpdate(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	g.ctx.EventCommit(eventID)
@@ -863,6 +945,10 @@ func (g *Group) memberAdd(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
+	if err = g.tenantService.CheckUsers(group.TenantID, req.Members...); err != nil {
+		c.ResponseError(err)
+		return
+	}
 	// 判断是否允许系统账号进入群聊
 	appConfig, err := g.commonService.GetAppConfig()
 	if err != nil {
@@ -1190,7 +1276,7 @@ func (g *Group) addMembers(members []string, groupNo string, operator, operatorN
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		g.Error("提交事务失败！", zap.Error(err))
-		return errors.New("提交事务失败！")
+		return errcode.ErrTxCommit
 	}
 	if commitCallback != nil {
 		commitCallback()
@@ -1205,7 +1291,7 @@ func (g *Group) managerAdd(c *wkhttp.Context) {
 	var memberUIDs []string
 	if err := c.BindJSON(&memberUIDs); err != nil {
 		g.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if len(memberUIDs) <= 0 {
@@ -1264,7 +1350,7 @@ func (g *Group) managerAdd(c *wkhttp.Context) {
 	})
 	if err != nil {
 		g.Error("发送命令消息失败！", zap.Error(err))
-		c.ResponseError(errors.New("发送命令消息失败！"))
+		c.ResponseError(errcode.ErrSendCMD)
 		return
 	}
 	c.ResponseOK()
@@ -1276,7 +1362,7 @@ func (g *Group) managerRemove(c *wkhttp.Context) {
 	var memberUIDs []string
 	if err := c.BindJSON(&memberUIDs); err != nil {
 		g.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if len(memberUIDs) <= 0 {
@@ -1336,7 +1422,7 @@ func (g *Group) managerRemove(c *wkhttp.Context) {
 	})
 	if err != nil {
 		g.Error("发送命令消息失败！", zap.Error(err))
-		c.ResponseError(errors.New("发送命令消息失败！"))
+		c.ResponseError(errcode.ErrSendCMD)
 		return
 	}
 	c.ResponseOK()
@@ -1422,7 +1508,7 @@ func (g *Group) groupForbidden(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	g.ctx.EventCommit(eventID)
@@ -1500,10 +1586,10 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 	authCode := c.Query("auth_code")
 	groupNo := c.Param("group_no")
 	if groupNo == "" {
-		c.ResponseError(errors.New("群编号不能为空"))
+		c.ResponseError(errcode.ErrGroupNoRequired)
 		return
 	}
-	_, err := g.getGroupInfo(groupNo)
+	group, err := g.getGroupInfo(groupNo)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -1545,6 +1631,10 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 		c.ResponseError(errors.New("没有二维码扫码信息！"))
 		return
 	}
+	if err = g.tenantService.CheckUsers(group.TenantID, scaner); err != nil {
+		c.ResponseError(err)
+		return
+	}
 	existMember, err := g.db.ExistMember(scaner, groupNo)
 	if err != nil {
 		g.Error("查询是否存在群内时失败！", zap.Error(err))
@@ -1692,7 +1782,7 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	g.ctx.EventCommit(eventID)
@@ -1821,7 +1911,7 @@ func (g *Group) transferGrouper(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	g.ctx.EventCommit(eventID)
@@ -1863,7 +1953,7 @@ func (g *Group) memberUpdate(c *wkhttp.Context) {
 	var memberUpdateMap map[string]interface{}
 	if err := c.BindJSON(&memberUpdateMap); err != nil {
 		g.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	_, err := g.getGroupInfo(groupNo)
@@ -1915,7 +2005,7 @@ func (g *Group) memberUpdate(c *wkhttp.Context) {
 	})
 	if err != nil {
 		g.Error("发送命令消息失败！", zap.Error(err))
-		c.ResponseError(errors.New("发送命令消息失败！"))
+		c.ResponseError(errcode.ErrSendCMD)
 		return
 	}
 
@@ -1993,151 +2083,16 @@ func (g *Group) memberRemove(c *wkhttp.Context) {
 			}
 		}
 	}
-	realDeleteMemberModels, err := g.userDB.QueryByUIDs(req.Members)
-	if err != nil {
-		g.Error("查询成员用户信息失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询成员用户信息失败！"))
-		return
-	}
-	memberCount, err := g.db.QueryMemberCount(groupNo)
-	if err != nil {
-		g.Error("查询群成员数量失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询群成员数量失败！"))
-		return
-	}
-
-	userBaseVos := make([]*config.UserBaseVo, 0, len(realDeleteMemberModels))
-	for _, realMember := range realDeleteMemberModels {
-		userBaseVos = append(userBaseVos, &config.UserBaseVo{
-			UID:  realMember.UID,
-			Name: realMember.Name,
-		})
-	}
-	nowMemberCount := int(memberCount) - len(userBaseVos) // 当前成员数量
-
-	needGenGroupAvatar := false // 是否需要生成头像
-
-	if nowMemberCount < 9 && nowMemberCount > 0 {
-		needGenGroupAvatar = true
-	}
-	if !needGenGroupAvatar {
-		needGenGroupAvatar, err = g.db.membersInFirstNine(groupNo, req.Members)
-		if err != nil {
-			g.Error("查询最早加入的成员信息失败！", zap.Error(err))
-			c.ResponseError(errors.New("查询最早加入的成员信息失败！"))
-			return
-		}
-	}
-	groupIsUploadAvatar, err := g.db.queryGroupAvatarIsUpload(groupNo)
-	if err != nil {
-		g.Error("查询群头像是否用户上传过失败！", zap.String("group_no", groupNo), zap.Error(err))
-	}
-	if groupIsUploadAvatar == 1 {
-		needGenGroupAvatar = false
-	}
-
-	tx, err := g.db.session.Begin()
-	util.CheckErr(err)
-	defer func() {
-		if err := recover(); err != nil {
-			tx.RollbackUnlessCommitted()
-			panic(err)
-		}
-	}()
-
-	for _, realMember := range realDeleteMemberModels {
-
-		version := g.ctx.GenSeq(common.GroupMemberSeqKey)
-		err = g.db.DeleteMemberTx(groupNo, realMember.UID, version, tx)
-		if err != nil {
-			tx.RollbackUnlessCommitted()
-			g.Error("删除群成员失败！", zap.Error(err))
-			c.ResponseError(errors.New("删除群成员失败！"))
-			return
-		}
-	}
-
-	// 发布群成员删除事件
-	groupMemberRemoveReq := &config.MsgGroupMemberRemoveReq{
+	err = g.groupService.RemoveMembers(&RemoveMembersReq{
 		GroupNo:      groupNo,
 		Operator:     operator,
 		OperatorName: operatorName,
-		Members:      userBaseVos,
-	}
-	eventID, err := g.ctx.EventBegin(&wkevent.Data{
-		Event: event.GroupMemberRemove,
-		Type:  wkevent.Message,
-		Data:  groupMemberRemoveReq,
-	}, tx)
-	if err != nil {
-		tx.RollbackUnlessCommitted()
-		g.Error("开启事件失败！", zap.Error(err))
-		c.ResponseError(errors.New("开启事件失败！"))
-		return
-	}
-
-	var groupAvatarEventID int64
-	if needGenGroupAvatar {
-		nineMemberUIDs := make([]string, 0, 9)
-		nownineMembers, err := g.db.QueryMembersFirstNineExclude(groupNo, req.Members)
-		if err != nil {
-			tx.Rollback()
-			g.Error("查询先存成员信息失败！", zap.String("group_no", groupNo), zap.Error(err))
-			c.ResponseError(errors.New("查询先存成员信息失败！"))
-			return
-		}
-		if len(nownineMembers) > 0 {
-			for _, nowninceMember := range nownineMembers {
-				nineMemberUIDs = append(nineMemberUIDs, nowninceMember.UID)
-			}
-		}
-		if len(nineMemberUIDs) > 0 {
-			groupAvatarEventID, err = g.ctx.EventBegin(&wkevent.Data{
-				Event: event.GroupAvatarUpdate,
-				Type:  wkevent.CMD,
-				Data: &config.CMDGroupAvatarUpdateReq{
-					GroupNo: groupNo,
-					Members: nineMemberUIDs,
-				},
-			}, tx)
-			if err != nil {
-				tx.Rollback()
-				g.Error("开启群成员头像更新事件失败！", zap.Error(err))
-				c.ResponseError(errors.New("开启群成员头像更新事件失败！"))
-				return
-			}
-		}
-	}
-	if err := tx.Commit(); err != nil {
-		tx.RollbackUnlessCommitted()
-		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
-		return
-	}
-	// 提交事件
-	g.ctx.EventCommit(eventID)
-	if groupAvatarEventID != 0 {
-		g.ctx.EventCommit(groupAvatarEventID)
-	}
-
-	// 调用IM的移除订阅者
-	err = g.ctx.IMRemoveSubscriber(&config.SubscriberRemoveReq{
-		ChannelID:   groupNo,
-		ChannelType: common.ChannelTypeGroup.Uint8(),
-		Subscribers: req.Members,
+		MemberUIDs:   req.Members,
 	})
 	if err != nil {
-		g.Error("调用IM的移除订阅者接口失败！", zap.Error(err))
-		c.ResponseError(errors.New("调用IM的移除订阅者接口失败！"))
+		c.ResponseError(err)
 		return
 	}
-
-	//给被踢的成员发送被踢消息
-	err = g.ctx.SendGroupMemberBeRemove(groupMemberRemoveReq)
-	if err != nil {
-		g.Warn("发送群成员被踢消息失败！", zap.Error(err))
-	}
-
 	c.ResponseOK()
 }
 
@@ -2176,6 +2131,7 @@ func (g *Group) groupSettingUpdate(c *wkhttp.Context) {
 			setting.GroupNo = groupNo
 			setting.UID = loginUID
 			setting.Version = version
+			setting.TenantID = tenant.ID(c.Context)
 		} else {
 			setting.Version = version
 		}
@@ -2375,7 +2331,7 @@ func (g *Group) groupExit(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	g.ctx.EventCommit(eventID)
@@ -2424,7 +2380,7 @@ func (g *Group) blacklist(c *wkhttp.Context) {
 		return
 	}
 	if groupNo == "" {
-		c.ResponseError(errors.New("群编号不能为空"))
+		c.ResponseError(errcode.ErrGroupNoRequired)
 		return
 	}
 	if action == "" {
@@ -2562,13 +2518,13 @@ func (g *Group) forbiddenWithGroupMember(c *wkhttp.Context) {
 	var req forbiddenWithGroupMemberReq
 	if err := c.BindJSON(&req); err != nil {
 		g.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	loginUID := c.GetLoginUID()
 	groupNo := c.Param("group_no")
 	if groupNo == "" {
-		c.ResponseError(errors.New("群编号不能为空"))
+		c.ResponseError(errcode.ErrGroupNoRequired)
 		return
 	}
 	if req.MemberUID == "" {
@@ -2609,18 +2565,9 @@ func (g *Group) forbiddenWithGroupMember(c *wkhttp.Context) {
 		c.ResponseError(errors.New("操作用户权限不够"))
 		return
 	}
-	member.Version = g.ctx.GenSeq(common.GroupMemberSeqKey)
-	if req.Action == 0 {
-		// 解禁
-		member.ForbiddenExpirTime = 0
-		err := g.db.UpdateMember(member)
-		if err != nil {
-			g.Error("解除用户禁言错误", zap.Error(err))
-			c.ResponseError(errors.New("解除用户禁言错误"))
-			return
-		}
-	} else {
-		expirationTime := time.Now().Unix()
+	var expirationTime int64
+	if req.Action == 1 {
+		expirationTime = time.Now().Unix()
 		switch req.Key {
 		case 1:
 			expirationTime += 60
@@ -2641,34 +2588,10 @@ func (g *Group) forbiddenWithGroupMember(c *wkhttp.Context) {
 			c.ResponseError(errors.New("禁言成员时长参数错误"))
 			return
 		}
-		member.ForbiddenExpirTime = expirationTime
-		err = g.db.UpdateMember(member)
-		if err != nil {
-			g.Error("禁言用户错误", zap.Error(err))
-			c.ResponseError(errors.New("禁言用户错误"))
-			return
-		}
 	}
-
-	// 加入talk黑名单
-	uids := make([]string, 0)
-	uids = append(uids, req.MemberUID)
-	err = g.setGroupBlacklist(groupNo, uids, req.Action == 1)
+	err = g.groupService.MuteMember(groupNo, req.MemberUID, expirationTime)
 	if err != nil {
-		c.ResponseError(errors.New("设置IM黑名单错误"))
-		return
-	}
-	err = g.ctx.SendCMD(config.MsgCMDReq{
-		ChannelID:   groupNo,
-		ChannelType: common.ChannelTypeGroup.Uint8(),
-		CMD:         common.CMDGroupMemberUpdate,
-		Param: map[string]interface{}{
-			"group_no": groupNo,
-		},
-	})
-	if err != nil {
-		g.Error("发送命令消息失败！", zap.Error(err))
-		c.ResponseError(errors.New("发送命令消息失败！"))
+		c.ResponseError(err)
 		return
 	}
 	c.ResponseOK()
@@ -2831,7 +2754,7 @@ type groupReq struct {
 
 func (g groupReq) Check() error {
 	if len(g.Members) <= 0 {
-		return errors.New("群成员不能为空！")
+		return errcode.ErrGroupMembersRequired
 	}
 	return nil
 }
@@ -2846,7 +2769,7 @@ type memberAddReq struct {
 
 func (m memberAddReq) Check() error {
 	if len(m.Members) <= 0 {
-		return errors.New("群成员不能为空！")
+		return errcode.ErrGroupMembersRequired
 	}
 	return nil
 }
@@ -2857,7 +2780,7 @@ type memberRemoveReq struct {
 
 func (m memberRemoveReq) Check() error {
 	if len(m.Members) <= 0 {
-		return errors.New("群成员不能为空！")
+		return errcode.ErrGroupMembersRequired
 	}
 	return nil
 }
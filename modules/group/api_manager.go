@@ -7,6 +7,8 @@ import (
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -23,6 +25,7 @@ type Manager struct {
 	managerDB *managerDB
 	userDB    *user.DB
 	db        *DB
+	actionDB  *actionDB
 }
 
 // NewManager NewManager
@@ -33,6 +36,7 @@ func NewManager(ctx *config.Context) *Manager {
 		managerDB: newManagerDB(ctx.DB()),
 		userDB:    user.NewDB(ctx),
 		db:        NewDB(ctx),
+		actionDB:  newActionDB(ctx),
 	}
 }
 
@@ -47,12 +51,17 @@ func (m *Manager) Route(r *wkhttp.WKHttp) {
 		auth.GET("/groups/:group_no/members", m.members)             // 群成员
 		auth.GET("/groups/:group_no/members/blacklist", m.blacklist) // 群黑名单成员
 		auth.DELETE("/groups/:group_no/members", m.removeMember)     // 移除群成员
+
+		auth.GET("/groups/:group_no/actions/preview", m.actionPreview) // 解散或接管群前查看影响
+		auth.POST("/groups/:group_no/dissolve", m.dissolve)            // 解散群
+		auth.POST("/groups/:group_no/takeover", m.takeover)            // 接管群（转让群主）
+		auth.GET("/group/actions", m.actions)                          // 解散和接管群的记录
 	}
 }
 
 // 查询群列表
 func (m *Manager) list(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermGroupRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -159,7 +168,7 @@ func (m *Manager) getRespList(list []*managerGroupModel) ([]*managerGroupResp, e
 
 // 封禁群列表
 func (m *Manager) disablelist(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermGroupRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -190,7 +199,7 @@ func (m *Manager) disablelist(c *wkhttp.Context) {
 
 // 封禁或解禁某个群
 func (m *Manager) leftbangroup(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermGroupWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -269,7 +278,7 @@ func (m *Manager) leftbangroup(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		m.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	m.ctx.EventCommit(eventID)
@@ -279,7 +288,7 @@ func (m *Manager) leftbangroup(c *wkhttp.Context) {
 
 // 禁言
 func (m *Manager) forbidden(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermGroupWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -287,17 +296,17 @@ func (m *Manager) forbidden(c *wkhttp.Context) {
 	groupNo := c.Param("group_no")
 	on := c.Param("on")
 	if groupNo == "" {
-		c.ResponseError(errors.New("群编号不能为空"))
+		c.ResponseError(errcode.ErrGroupNoRequired)
 		return
 	}
 	groupModel, err := m.db.QueryWithGroupNo(groupNo)
 	if err != nil {
 		m.Error("查询群信息失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询群信息失败！"))
+		c.ResponseError(errcode.ErrQueryGroup)
 		return
 	}
 	if groupModel == nil {
-		c.ResponseError(errors.New("群不存在！"))
+		c.ResponseError(errcode.ErrGroupNotExist)
 		return
 	}
 	forbidden, _ := strconv.ParseInt(on, 10, 64)
@@ -360,7 +369,7 @@ func (m *Manager) forbidden(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		m.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	m.ctx.EventCommit(eventID)
@@ -370,7 +379,7 @@ func (m *Manager) forbidden(c *wkhttp.Context) {
 
 // 移除群成员
 func (m *Manager) removeMember(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermGroupWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -381,7 +390,7 @@ func (m *Manager) removeMember(c *wkhttp.Context) {
 
 // 群成员
 func (m *Manager) members(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermGroupRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -389,7 +398,7 @@ func (m *Manager) members(c *wkhttp.Context) {
 	groupNo := c.Param("group_no")
 	pageIndex, pageSize := c.GetPage()
 	if groupNo == "" {
-		c.ResponseError(errors.New("群编号不能为空"))
+		c.ResponseError(errcode.ErrGroupNoRequired)
 		return
 	}
 	keyword := c.Query("keyword")
@@ -441,7 +450,7 @@ func (m *Manager) members(c *wkhttp.Context) {
 
 // 群黑名单成员
 func (m *Manager) blacklist(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermGroupRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -449,7 +458,7 @@ func (m *Manager) blacklist(c *wkhttp.Context) {
 	groupNo := c.Param("group_no")
 	pageIndex, pageSize := c.GetPage()
 	if groupNo == "" {
-		c.ResponseError(errors.New("群编号不能为空"))
+		c.ResponseError(errcode.ErrGroupNoRequired)
 		return
 	}
 	list, err := m.managerDB.queryGroupMembersWithStatus(groupNo, int(common.GroupMemberStatusBlacklist), uint64(pageSize), uint64(pageIndex))
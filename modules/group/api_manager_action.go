@@ -0,0 +1,611 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// actionPreview 解散或接管群之前查看影响 包含群主最后活跃的时间和新群主的候选人
+func (m *Manager) actionPreview(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermGroupRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupModel, err := m.queryActionGroup(c.Param("group_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	memberCount, err := m.db.QueryMemberCount(groupModel.GroupNo)
+	if err != nil {
+		m.Error("查询群成员数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群成员数量失败！"))
+		return
+	}
+	onlineCount, err := m.db.queryMemberOnlineCount(groupModel.GroupNo)
+	if err != nil {
+		m.Error("查询在线成员数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询在线成员数量失败！"))
+		return
+	}
+	owner, err := m.queryOwnerInfo(groupModel.GroupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	members, err := m.managerDB.queryGroupMembers(groupModel.GroupNo, candidateCount+1, 1)
+	if err != nil {
+		m.Error("查询群成员失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrQueryGroupMember)
+		return
+	}
+	now := time.Now()
+	var purgeAt int64
+	if groupModel.Status != GroupStatusDisband {
+		purgeAt = messagePurgeAt(now, extconfig.Get().GroupDissolve.MessageRetention)
+	}
+	c.Response(&actionPreviewResp{
+		GroupNo:        groupModel.GroupNo,
+		Name:           groupModel.Name,
+		Status:         groupModel.Status,
+		MemberCount:    memberCount,
+		OnlineCount:    onlineCount,
+		Owner:          owner,
+		Abandoned:      owner.abandoned(now, extconfig.Get().GroupDissolve.AbandonedDays),
+		Candidates:     newCandidateResps(members, owner.UID),
+		MessagePurgeAt: purgeAt,
+	})
+}
+
+// dissolve 管理员解散群 先通知群成员再解散
+func (m *Manager) dissolve(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermGroupWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req actionReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupModel, err := m.queryActionGroup(c.Param("group_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if groupModel.Status == GroupStatusDisband {
+		c.ResponseError(errors.New("群已解散！"))
+		return
+	}
+	memberCount, err := m.db.QueryMemberCount(groupModel.GroupNo)
+	if err != nil {
+		m.Error("查询群成员数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群成员数量失败！"))
+		return
+	}
+	var owner string
+	ownerMember, err := m.actionDB.queryOwner(groupModel.GroupNo)
+	if err != nil {
+		m.Error("查询群主失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群主失败！"))
+		return
+	}
+	if ownerMember != nil {
+		owner = ownerMember.UID
+	}
+	notice := req.noticeOrDefault(defaultDissolveNotice)
+	// 解散后IM频道会被删除 需要在解散前通知
+	if err = m.sendActionNotice(groupModel.GroupNo, notice); err != nil {
+		m.Error("通知群成员失败！", zap.Error(err), zap.String("groupNo", groupModel.GroupNo))
+		c.ResponseError(errors.New("通知群成员失败！"))
+		return
+	}
+
+	action := &actionModel{
+		GroupNo:     groupModel.GroupNo,
+		Action:      ActionDissolve,
+		Operator:    c.GetLoginUID(),
+		Reason:      req.Reason,
+		Notice:      notice,
+		OldOwner:    owner,
+		MemberCount: int(memberCount),
+		PurgeAt:     messagePurgeAt(time.Now(), extconfig.Get().GroupDissolve.MessageRetention),
+	}
+	oldStatus := groupModel.Status
+	groupModel.Status = GroupStatusDisband
+
+	tx, err := m.ctx.DB().Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrTxBegin)
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	if err = m.db.UpdateTx(groupModel, tx); err != nil {
+		tx.Rollback()
+		m.Error("修改群状态失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改群状态失败！"))
+		return
+	}
+	if err = m.actionDB.insertTx(action, tx); err != nil {
+		tx.Rollback()
+		m.Error("保存解散记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("保存解散记录失败！"))
+		return
+	}
+	eventID, err := m.ctx.EventBegin(&wkevent.Data{
+		Event: event.GroupDisband,
+		Type:  wkevent.Message,
+		Data: &config.MsgGroupDisband{
+			GroupNo:      groupModel.GroupNo,
+			Operator:     c.GetLoginUID(),
+			OperatorName: c.GetLoginName(),
+		},
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("开启事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事件失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrTxCommit)
+		return
+	}
+	m.ctx.EventCommit(eventID)
+
+	audit.SetChange(c, fmt.Sprintf("group_no=%s", groupModel.GroupNo), map[string]interface{}{
+		"status":       oldStatus,
+		"owner":        owner,
+		"member_count": memberCount,
+	}, map[string]interface{}{
+		"status":   GroupStatusDisband,
+		"reason":   req.Reason,
+		"notice":   notice,
+		"purge_at": action.PurgeAt,
+	})
+	c.ResponseOK()
+}
+
+// takeover 把无人管理的群转让给群内的成员
+func (m *Manager) takeover(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermGroupWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req takeoverReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupModel, err := m.queryActionGroup(c.Param("group_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if groupModel.Status == GroupStatusDisband {
+		c.ResponseError(errors.New("群已解散！"))
+		return
+	}
+	owner, err := m.queryOwnerInfo(groupModel.GroupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if owner.UID == req.ToUID {
+		c.ResponseError(errors.New("该用户已经是群主！"))
+		return
+	}
+	abandonedDays := extconfig.Get().GroupDissolve.AbandonedDays
+	if !owner.abandoned(time.Now(), abandonedDays) {
+		c.ResponseError(fmt.Errorf("群主%d天内上线过，不能接管！", abandonedDays))
+		return
+	}
+	toMember, err := m.db.QueryMemberWithUID(req.ToUID, groupModel.GroupNo)
+	if err != nil {
+		m.Error("查询群成员失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrQueryGroupMember)
+		return
+	}
+	if toMember == nil || toMember.Status != 1 || toMember.Robot == 1 {
+		c.ResponseError(errors.New("新群主需要是群内的正常成员！"))
+		return
+	}
+	toUser, err := m.userDB.QueryByUID(req.ToUID)
+	if err != nil {
+		m.Error("查询用户失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户失败！"))
+		return
+	}
+	if toUser == nil || toUser.IsDestroy == 1 || toUser.Status != 1 {
+		c.ResponseError(errors.New("新群主不存在、已注销或已封禁！"))
+		return
+	}
+	memberCount, err := m.db.QueryMemberCount(groupModel.GroupNo)
+	if err != nil {
+		m.Error("查询群成员数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群成员数量失败！"))
+		return
+	}
+	notice := req.noticeOrDefault(defaultTakeoverNotice)
+	action := &actionModel{
+		GroupNo:     groupModel.GroupNo,
+		Action:      ActionTakeover,
+		Operator:    c.GetLoginUID(),
+		Reason:      req.Reason,
+		Notice:      notice,
+		OldOwner:    owner.UID,
+		NewOwner:    req.ToUID,
+		MemberCount: int(memberCount),
+	}
+
+	version := m.ctx.GenSeq(common.GroupMemberSeqKey)
+	tx, err := m.ctx.DB().Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrTxBegin)
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	eventID, err := m.ctx.EventBegin(&wkevent.Data{
+		Event: event.GroupMemberTransferGrouper,
+		Type:  wkevent.Message,
+		Data: config.MsgGroupTransferGrouper{
+			GroupNo:        groupModel.GroupNo,
+			OldGrouper:     owner.UID,
+			OldGrouperName: owner.Name,
+			NewGrouper:     req.ToUID,
+			NewGrouperName: toUser.Name,
+		},
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("开启事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事件失败！"))
+		return
+	}
+	if owner.UID != "" {
+		if err = m.db.UpdateMemberRoleTx(groupModel.GroupNo, owner.UID, MemberRoleCommon, version, tx); err != nil {
+			tx.Rollback()
+			m.Error("更新成普通成员失败！", zap.Error(err))
+			c.ResponseError(errors.New("更新成普通成员失败！"))
+			return
+		}
+	}
+	if err = m.db.UpdateMemberRoleTx(groupModel.GroupNo, req.ToUID, MemberRoleCreator, version, tx); err != nil {
+		tx.Rollback()
+		m.Error("更新成创建者失败！", zap.Error(err))
+		c.ResponseError(errors.New("更新成创建者失败！"))
+		return
+	}
+	if err = m.db.updateMemberForbiddenExpirTimeTx(groupModel.GroupNo, req.ToUID, 0, version, tx); err != nil {
+		tx.Rollback()
+		m.Error("修改成员禁言时长失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改成员禁言时长失败！"))
+		return
+	}
+	if err = m.actionDB.insertTx(action, tx); err != nil {
+		tx.Rollback()
+		m.Error("保存接管记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("保存接管记录失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrTxCommit)
+		return
+	}
+	m.ctx.EventCommit(eventID)
+
+	audit.SetChange(c, fmt.Sprintf("group_no=%s", groupModel.GroupNo), map[string]interface{}{
+		"owner":       owner.UID,
+		"last_active": owner.LastActiveAt,
+	}, map[string]interface{}{
+		"owner":  req.ToUID,
+		"reason": req.Reason,
+		"notice": notice,
+	})
+
+	if groupModel.Forbidden == 1 { // 全员禁言时新群主需要加入白名单
+		whitelist, err := m.db.QueryGroupManagerOrCreatorUIDS(groupModel.GroupNo)
+		if err == nil {
+			err = m.ctx.IMWhitelistSet(config.ChannelWhitelistReq{
+				ChannelReq: config.ChannelReq{
+					ChannelID:   groupModel.GroupNo,
+					ChannelType: common.ChannelTypeGroup.Uint8(),
+				},
+				UIDs: whitelist,
+			})
+		}
+		if err != nil {
+			m.Error("设置白名单失败！", zap.Error(err))
+			c.ResponseError(errors.New("设置白名单失败！"))
+			return
+		}
+	}
+	if toMember.ForbiddenExpirTime > 0 {
+		err = m.ctx.IMBlacklistRemove(config.ChannelBlacklistReq{
+			ChannelReq: config.ChannelReq{
+				ChannelID:   groupModel.GroupNo,
+				ChannelType: common.ChannelTypeGroup.Uint8(),
+			},
+			UIDs: []string{req.ToUID},
+		})
+		if err != nil {
+			m.Error("解除新群主的禁言失败！", zap.Error(err))
+			c.ResponseError(errors.New("解除新群主的禁言失败！"))
+			return
+		}
+	}
+	if err = m.sendActionNotice(groupModel.GroupNo, notice); err != nil {
+		m.Warn("通知群成员失败！", zap.Error(err), zap.String("groupNo", groupModel.GroupNo))
+	}
+	c.ResponseOK()
+}
+
+// actions 解散和接管群的记录
+func (m *Manager) actions(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermGroupRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupNo := c.Query("group_no")
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.actionDB.queryWithPage(groupNo, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询群操作记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群操作记录失败！"))
+		return
+	}
+	count, err := m.actionDB.queryCount(groupNo)
+	if err != nil {
+		m.Error("查询群操作记录数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群操作记录数量失败！"))
+		return
+	}
+	list := make([]*actionResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newActionResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+func (m *Manager) queryActionGroup(groupNo string) (*Model, error) {
+	if groupNo == "" {
+		return nil, errors.New("群编号不能为空！")
+	}
+	groupModel, err := m.db.QueryWithGroupNo(groupNo)
+	if err != nil {
+		m.Error("查询群信息失败！", zap.Error(err))
+		return nil, errcode.ErrQueryGroup
+	}
+	if groupModel == nil {
+		return nil, errcode.ErrGroupNotExist
+	}
+	return groupModel, nil
+}
+
+// queryOwnerInfo 查询群主和最后活跃的时间 没有群主时UID为空
+func (m *Manager) queryOwnerInfo(groupNo string) (*ownerResp, error) {
+	member, err := m.actionDB.queryOwner(groupNo)
+	if err != nil {
+		m.Error("查询群主失败！", zap.Error(err))
+		return nil, errors.New("查询群主失败！")
+	}
+	owner := &ownerResp{}
+	if member == nil {
+		return owner, nil
+	}
+	owner.UID = member.UID
+	userModel, err := m.userDB.QueryByUID(member.UID)
+	if err != nil {
+		m.Error("查询群主信息失败！", zap.Error(err))
+		return nil, errors.New("查询群主信息失败！")
+	}
+	if userModel != nil {
+		owner.Name = userModel.Name
+		owner.UserStatus = userModel.Status
+		owner.IsDestroy = userModel.IsDestroy
+	} else {
+		owner.IsDestroy = 1
+	}
+	activity, err := m.actionDB.queryActivity(member.UID)
+	if err != nil {
+		m.Error("查询群主在线状态失败！", zap.Error(err))
+		return nil, errors.New("查询群主在线状态失败！")
+	}
+	if activity != nil {
+		owner.Online = activity.Online
+		owner.LastActiveAt = activity.LastActive
+	}
+	return owner, nil
+}
+
+// sendActionNotice 以系统账号在群内发送通知
+func (m *Manager) sendActionNotice(groupNo string, notice string) error {
+	return m.ctx.SendMessage(&config.MsgSendReq{
+		FromUID:     m.ctx.GetConfig().Account.SystemUID,
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": notice,
+			"type":    common.Text,
+		})),
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+	})
+}
+
+// messagePurgeAt 解散后删除群消息的时间 retention为0时永久保留
+func messagePurgeAt(now time.Time, retention time.Duration) int64 {
+	if retention <= 0 {
+		return 0
+	}
+	return now.Add(retention).Unix()
+}
+
+// newCandidateResps 新群主的候选人 管理员在前 排除原群主和机器人
+func newCandidateResps(members []*managerMemberModel, ownerUID string) []*candidateResp {
+	list := make([]*candidateResp, 0, len(members))
+	for _, member := range members {
+		if member.UID == ownerUID || member.Robot == 1 || len(list) >= candidateCount {
+			continue
+		}
+		list = append(list, &candidateResp{
+			UID:  member.UID,
+			Name: member.Name,
+			Role: member.Role,
+		})
+	}
+	return list
+}
+
+type actionReq struct {
+	Reason string `json:"reason"` // 操作原因 只记录在操作记录和操作日志中
+	Notice string `json:"notice"` // 发给群成员的通知 为空时使用默认通知
+}
+
+func (r *actionReq) check() error {
+	r.Reason = strings.TrimSpace(r.Reason)
+	r.Notice = strings.TrimSpace(r.Notice)
+	if r.Reason == "" {
+		return errors.New("操作原因不能为空！")
+	}
+	if len([]rune(r.Reason)) > actionReasonMaxLen || len([]rune(r.Notice)) > actionReasonMaxLen {
+		return fmt.Errorf("操作原因和通知不能超过%d个字！", actionReasonMaxLen)
+	}
+	return nil
+}
+
+func (r *actionReq) noticeOrDefault(notice string) string {
+	if r.Notice != "" {
+		return r.Notice
+	}
+	return notice
+}
+
+type takeoverReq struct {
+	actionReq
+	ToUID string `json:"to_uid"` // 新群主
+}
+
+func (r *takeoverReq) check() error {
+	r.ToUID = strings.TrimSpace(r.ToUID)
+	if r.ToUID == "" {
+		return errors.New("新群主不能为空！")
+	}
+	return r.actionReq.check()
+}
+
+type ownerResp struct {
+	UID          string `json:"uid"` // 为空时群没有群主
+	Name         string `json:"name"`
+	UserStatus   int    `json:"user_status"` // 用户状态 0.已封禁 1.正常
+	IsDestroy    int    `json:"is_destroy"`
+	Online       int    `json:"online"`
+	LastActiveAt int64  `json:"last_active_at"` // 最后一次上线或离线的时间 0为没有记录
+}
+
+// abandoned 群主是否已不再管理群 没有群主、已注销、已封禁或超过days天没有上线
+func (o *ownerResp) abandoned(now time.Time, days int) bool {
+	if o.UID == "" || o.IsDestroy == 1 || o.UserStatus != int(common.UserAvailable) {
+		return true
+	}
+	if o.Online == 1 {
+		return false
+	}
+	return o.LastActiveAt < now.AddDate(0, 0, -days).Unix()
+}
+
+type candidateResp struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+	Role int    `json:"role"` // 成员角色 0.普通成员 2.管理员
+}
+
+type actionPreviewResp struct {
+	GroupNo        string           `json:"group_no"`
+	Name           string           `json:"name"`
+	Status         int              `json:"status"`
+	MemberCount    int64            `json:"member_count"`
+	OnlineCount    int64            `json:"online_count"`
+	Owner          *ownerResp       `json:"owner"`
+	Abandoned      bool             `json:"abandoned"`        // 是否可以接管
+	Candidates     []*candidateResp `json:"candidates"`       // 新群主的候选人
+	MessagePurgeAt int64            `json:"message_purge_at"` // 现在解散时删除群消息的时间 0为永久保留
+}
+
+type actionResp struct {
+	Id          int64  `json:"id"`
+	GroupNo     string `json:"group_no"`
+	Action      string `json:"action"`
+	Operator    string `json:"operator"`
+	Reason      string `json:"reason"`
+	Notice      string `json:"notice"`
+	OldOwner    string `json:"old_owner"`
+	NewOwner    string `json:"new_owner"`
+	MemberCount int    `json:"member_count"`
+	PurgeAt     int64  `json:"purge_at"`
+	PurgedAt    int64  `json:"purged_at"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func newActionResp(m *actionModel) *actionResp {
+	return &actionResp{
+		Id:          m.Id,
+		GroupNo:     m.GroupNo,
+		Action:      m.Action,
+		Operator:    m.Operator,
+		Reason:      m.Reason,
+		Notice:      m.Notice,
+		OldOwner:    m.OldOwner,
+		NewOwner:    m.NewOwner,
+		MemberCount: m.MemberCount,
+		PurgeAt:     m.PurgeAt,
+		PurgedAt:    m.PurgedAt,
+		CreatedAt:   m.CreatedAt.String(),
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
@@ -106,3 +107,45 @@ func TestBlackList(t *testing.T) {
 	assert.NoError(t, err)
 	s.GetRoute().ServeHTTP(w, req)
 }
+
+func TestOwnerAbandoned(t *testing.T) {
+	now := time.Now()
+	assert.True(t, (&ownerResp{}).abandoned(now, 30))
+	assert.True(t, (&ownerResp{UID: "u1", UserStatus: 1, IsDestroy: 1, Online: 1}).abandoned(now, 30))
+	assert.True(t, (&ownerResp{UID: "u1", UserStatus: 0, Online: 1}).abandoned(now, 30))
+	assert.False(t, (&ownerResp{UID: "u1", UserStatus: 1, Online: 1}).abandoned(now, 30))
+	assert.False(t, (&ownerResp{UID: "u1", UserStatus: 1, LastActiveAt: now.AddDate(0, 0, -29).Unix()}).abandoned(now, 30))
+	assert.True(t, (&ownerResp{UID: "u1", UserStatus: 1, LastActiveAt: now.AddDate(0, 0, -31).Unix()}).abandoned(now, 30))
+	assert.True(t, (&ownerResp{UID: "u1", UserStatus: 1}).abandoned(now, 30))
+}
+
+func TestMessagePurgeAt(t *testing.T) {
+	now := time.Unix(1000, 0)
+	assert.Equal(t, int64(0), messagePurgeAt(now, 0))
+	assert.Equal(t, int64(1000+3600), messagePurgeAt(now, time.Hour))
+}
+
+func TestNewCandidateResps(t *testing.T) {
+	members := []*managerMemberModel{
+		{UID: "owner", Role: MemberRoleCreator},
+		{UID: "manager", Role: MemberRoleManager},
+		{UID: "robot", Robot: 1},
+		{UID: "member"},
+	}
+	list := newCandidateResps(members, "owner")
+	assert.Len(t, list, 2)
+	assert.Equal(t, "manager", list[0].UID)
+	assert.Equal(t, "member", list[1].UID)
+}
+
+func TestTakeoverReqCheck(t *testing.T) {
+	req := &takeoverReq{ToUID: " u1 ", actionReq: actionReq{Reason: "  "}}
+	assert.Error(t, req.check())
+	req.Reason = "群主已注销"
+	assert.NoError(t, req.check())
+	assert.Equal(t, "u1", req.ToUID)
+	assert.Equal(t, defaultTakeoverNotice, req.noticeOrDefault(defaultTakeoverNotice))
+	req.Notice = strings.Repeat("通", actionReasonMaxLen+1)
+	assert.Error(t, req.check())
+	assert.Error(t, (&takeoverReq{actionReq: actionReq{Reason: "x"}}).check())
+}
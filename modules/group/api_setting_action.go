@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -110,7 +111,7 @@ func (g *groupUpdateContext) commmitGroupUpdateEvent(key, value string) error {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		g.g.Error("提交事务失败！", zap.Error(err))
-		return errors.New("提交事务失败！")
+		return errcode.ErrTxCommit
 	}
 	g.g.ctx.EventCommit(eventID)
 
@@ -173,6 +174,18 @@ var settingActionMap = map[string]groupSettingActionFnc{
 		ctx.groupSetting.FlameSecond = int(value.(float64))
 		return ctx.updateSettingAndSendCMD()
 	},
+	"push_privacy": func(ctx *settingContext, value interface{}) error { // 推送隐私
+		ctx.groupSetting.PushPrivacy = int(value.(float64))
+		return ctx.updateSettingAndSendCMD()
+	},
+	"notify_level": func(ctx *settingContext, value interface{}) error { // 通知级别
+		notifyLevel := int(value.(float64))
+		if notifyLevel != NotifyLevelAll && notifyLevel != NotifyLevelMention && notifyLevel != NotifyLevelNone {
+			return errors.New("通知级别有误！")
+		}
+		ctx.groupSetting.NotifyLevel = notifyLevel
+		return ctx.updateSettingAndSendCMD()
+	},
 }
 
 var groupUpdateActionMap = map[string]groupUpdateActionFnc{
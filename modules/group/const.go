@@ -20,6 +20,11 @@ const (
 	MemberRoleManager = 2
 )
 
+const (
+	memberListDefaultLimit = 100  // 群成员列表默认每页数量
+	memberListMaxLimit     = 1000 // 群成员列表和同步群成员每页最大数量
+)
+
 const (
 	// InviteStatusWait 等待确认
 	InviteStatusWait = 0
@@ -38,3 +43,29 @@ const (
 const (
 	ChannelServiceName = "channel"
 )
+
+// 群消息的通知级别
+const (
+	// NotifyLevelAll 所有消息都通知
+	NotifyLevelAll = 0
+	// NotifyLevelMention 仅@我和回复我的消息通知
+	NotifyLevelMention = 1
+	// NotifyLevelNone 不通知
+	NotifyLevelNone = 2
+)
+
+// 管理员对群的操作
+const (
+	// ActionDissolve 解散群
+	ActionDissolve = "dissolve"
+	// ActionTakeover 转让群主
+	ActionTakeover = "takeover"
+)
+
+const (
+	actionReasonMaxLen = 500 // 操作原因和通知的最大长度
+	candidateCount     = 10  // 预览时列出的新群主候选人数
+	// 默认发给群成员的通知
+	defaultDissolveNotice = "该群因违反平台规定已被解散"
+	defaultTakeoverNotice = "由于原群主长期未使用，平台已转让群主"
+)
@@ -285,7 +285,7 @@ func (d *DB) updateAvatar(avatar string, groupNo string) error {
 // QueryDetailWithGroupNo 查询群详情
 func (d *DB) QueryDetailWithGroupNo(groupNo string, uid string) (*DetailModel, error) {
 	var detailModel *DetailModel
-	_, err := d.session.Select("`group`.*,IFNULL(group_setting.version,0) + `group`.version  version,IFNULL(group_setting.chat_pwd_on,0) chat_pwd_on,IFNULL(group_setting.mute,0) mute,IFNULL(group_setting.top,0) top,IFNULL(group_setting.show_nick,0) show_nick,IFNULL(group_setting.save,0) save,IFNULL(group_setting.revoke_remind,0) revoke_remind,IFNULL(group_setting.revoke_remind,1) revoke_remind,IFNULL(group_setting.join_group_remind,0) join_group_remind,IFNULL(group_setting.screenshot,1) screenshot,IFNULL(group_setting.receipt,1) receipt,IFNULL(group_setting.flame,0) flame,IFNULL(group_setting.flame_second,0) flame_second,IFNULL(group_setting.remark,'') remark").From("`group`").LeftJoin(`group_setting`, "`group`.group_no=group_setting.group_no and group_setting.uid=?").Where("`group`.group_no=?", uid, groupNo).Load(&detailModel)
+	_, err := d.session.Select("`group`.*,IFNULL(group_setting.version,0) + `group`.version  version,IFNULL(group_setting.chat_pwd_on,0) chat_pwd_on,IFNULL(group_setting.mute,0) mute,IFNULL(group_setting.top,0) top,IFNULL(group_setting.show_nick,0) show_nick,IFNULL(group_setting.save,0) save,IFNULL(group_setting.revoke_remind,0) revoke_remind,IFNULL(group_setting.revoke_remind,1) revoke_remind,IFNULL(group_setting.join_group_remind,0) join_group_remind,IFNULL(group_setting.screenshot,1) screenshot,IFNULL(group_setting.receipt,1) receipt,IFNULL(group_setting.flame,0) flame,IFNULL(group_setting.flame_second,0) flame_second,IFNULL(group_setting.remark,'') remark,IFNULL(group_setting.push_privacy,0) push_privacy,IFNULL(group_setting.notify_level,0) notify_level").From("`group`").LeftJoin(`group_setting`, "`group`.group_no=group_setting.group_no and group_setting.uid=?").Where("`group`.group_no=?", uid, groupNo).Load(&detailModel)
 	return detailModel, err
 }
 
@@ -295,7 +295,7 @@ func (d *DB) QueryDetailWithGroupNos(groupNos []string, uid string) ([]*DetailMo
 		return nil, nil
 	}
 	var detailModels []*DetailModel
-	_, err := d.session.Select("`group`.*,IFNULL(group_setting.version,0) + `group`.version  version,IFNULL(group_setting.chat_pwd_on,0) chat_pwd_on,IFNULL(group_setting.mute,0) mute,IFNULL(group_setting.top,0) top,IFNULL(group_setting.show_nick,0) show_nick,IFNULL(group_setting.save,0) save,IFNULL(group_setting.revoke_remind,0) revoke_remind,IFNULL(group_setting.revoke_remind,1) revoke_remind,IFNULL(group_setting.join_group_remind,0) join_group_remind,IFNULL(group_setting.screenshot,1) screenshot,IFNULL(group_setting.receipt,1) receipt,IFNULL(group_setting.flame,0) flame,IFNULL(group_setting.flame_second,0) flame_second,IFNULL(group_setting.remark,'') remark").From("`group`").LeftJoin(`group_setting`, "`group`.group_no=group_setting.group_no and group_setting.uid=?").Where("`group`.group_no in ?", uid, groupNos).Load(&detailModels)
+	_, err := d.session.Select("`group`.*,IFNULL(group_setting.version,0) + `group`.version  version,IFNULL(group_setting.chat_pwd_on,0) chat_pwd_on,IFNULL(group_setting.mute,0) mute,IFNULL(group_setting.top,0) top,IFNULL(group_setting.show_nick,0) show_nick,IFNULL(group_setting.save,0) save,IFNULL(group_setting.revoke_remind,0) revoke_remind,IFNULL(group_setting.revoke_remind,1) revoke_remind,IFNULL(group_setting.join_group_remind,0) join_group_remind,IFNULL(group_setting.screenshot,1) screenshot,IFNULL(group_setting.receipt,1) receipt,IFNULL(group_setting.flame,0) flame,IFNULL(group_setting.flame_second,0) flame_second,IFNULL(group_setting.remark,'') remark,IFNULL(group_setting.push_privacy,0) push_privacy,IFNULL(group_setting.notify_level,0) notify_level").From("`group`").LeftJoin(`group_setting`, "`group`.group_no=group_setting.group_no and group_setting.uid=?").Where("`group`.group_no in ?", uid, groupNos).Load(&detailModels)
 	return detailModels, err
 }
 
@@ -370,6 +370,34 @@ func (d *DB) queryMembersWithKeyword(groupNo string, loginUID string, keyword st
 	return details, err
 }
 
+// memberRankSQL 成员列表的角色排序 创建者、管理者、普通成员 与memberRank相同
+var memberRankSQL = fmt.Sprintf("IF(group_member.role=%d,0,IF(group_member.role=%d,1,2))", MemberRoleCreator, MemberRoleManager)
+
+func memberRank(role int) int64 {
+	switch role {
+	case MemberRoleCreator:
+		return 0
+	case MemberRoleManager:
+		return 1
+	}
+	return 2
+}
+
+// queryMembersWithCursor 按创建者、管理者、普通成员的顺序查询成员 同一角色按id升序
+// rank和afterID为上一页最后一个成员的排序键 第一页都为0
+func (d *DB) queryMembersWithCursor(groupNo string, loginUID string, keyword string, rank int64, afterID int64, limit uint64) ([]*MemberDetailModel, error) {
+	var details []*MemberDetailModel
+	builder := d.session.Select("group_member.id,group_member.vercode,group_member.uid,group_member.status,group_member.group_no,group_member.remark,group_member.role,IFNULL(user.name,'') name,IFNULL(user.username,'') username,group_member.is_deleted,group_member.robot,group_member.version,group_member.invite_uid,group_member.forbidden_expir_time,group_member.created_at,group_member.updated_at").From("group_member").LeftJoin("user", "group_member.uid=user.uid").Where("group_member.group_no=? and group_member.is_deleted=0", groupNo)
+	if keyword != "" {
+		builder = builder.LeftJoin("user_setting", dbr.And(dbr.Expr("user_setting.uid=?", loginUID), dbr.Expr("user_setting.to_uid=group_member.uid"))).Where("group_member.remark like ? or user.name like ? or user_setting.remark like ?", "%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	if afterID > 0 {
+		builder = builder.Where(fmt.Sprintf("%s>? or (%s=? and group_member.id>?)", memberRankSQL, memberRankSQL), rank, rank, afterID)
+	}
+	_, err := builder.OrderAsc(memberRankSQL).OrderAsc("group_member.id").Limit(limit).Load(&details)
+	return details, err
+}
+
 func (d *DB) queryMembersWithGroupNo(groupNo string) ([]*MemberDetailModel, error) {
 	var details []*MemberDetailModel
 	_, err := d.session.Select("group_member.id,group_member.vercode,group_member.uid,group_member.status,group_member.group_no,group_member.remark,group_member.role,IFNULL(user.name,'') name,group_member.is_deleted,group_member.version,group_member.created_at,group_member.updated_at").From("group_member").LeftJoin("user", "group_member.uid=user.uid").Where("group_member.group_no=? and group_member.is_deleted=0", groupNo).Load(&details)
@@ -445,7 +473,7 @@ func (d *DB) queryCreatedCountWithDate(date string) (int64, error) {
 // querySavedGroups 查询我保存的群
 func (d *DB) querySavedGroups(uid string) ([]*DetailModel, error) {
 	var detailModels []*DetailModel
-	_, err := d.session.Select("`group`.*,IFNULL(group_setting.version,0) + `group`.version  version,IFNULL(group_setting.chat_pwd_on,0) chat_pwd_on,IFNULL(group_setting.mute,0) mute,IFNULL(group_setting.top,0) top,IFNULL(group_setting.show_nick,0) show_nick,IFNULL(group_setting.save,0) save,IFNULL(group_setting.remark,'') remark").From("`group`").LeftJoin(`group_setting`, "`group`.group_no=group_setting.group_no").Where("`group_setting`.save=1 and `group_setting`.uid=?", uid).Load(&detailModels)
+	_, err := d.session.Select("`group`.*,IFNULL(group_setting.version,0) + `group`.version  version,IFNULL(group_setting.chat_pwd_on,0) chat_pwd_on,IFNULL(group_setting.mute,0) mute,IFNULL(group_setting.top,0) top,IFNULL(group_setting.show_nick,0) show_nick,IFNULL(group_setting.save,0) save,IFNULL(group_setting.remark,'') remark,IFNULL(group_setting.push_privacy,0) push_privacy,IFNULL(group_setting.notify_level,0) notify_level").From("`group`").LeftJoin(`group_setting`, "`group`.group_no=group_setting.group_no").Where("`group_setting`.save=1 and `group_setting`.uid=?", uid).Load(&detailModels)
 	return detailModels, err
 }
 
@@ -494,6 +522,8 @@ type DetailModel struct {
 	Flame           int    // 是否开启阅后即焚
 	FlameSecond     int    // 阅后即焚秒数
 	Remark          string // 群备注
+	PushPrivacy     int    // 推送隐私 0.跟随用户的设置 1.隐藏推送内容 2.显示推送内容
+	NotifyLevel     int    // 通知级别 0.所有消息 1.仅@我和回复我的消息 2.不通知
 }
 
 // Model 群db model
@@ -512,6 +542,7 @@ type Model struct {
 	AllowViewHistoryMsg      int    // 是否允许新成员查看历史消息
 	AllowMemberPinnedMessage int    // 是否允许群成员置顶消息
 	Category                 string // 群分类
+	TenantID                 string // 所属组织 与创建者的组织相同
 	db.BaseModel
 }
 
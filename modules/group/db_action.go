@@ -0,0 +1,90 @@
+package group
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+// actionDB 管理员解散或接管群的记录
+type actionDB struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+func newActionDB(ctx *config.Context) *actionDB {
+	return &actionDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (a *actionDB) insertTx(m *actionModel, tx *dbr.Tx) error {
+	_, err := tx.InsertInto("group_manager_action").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (a *actionDB) queryWithPage(groupNo string, pageIndex, pageSize uint64) ([]*actionModel, error) {
+	var models []*actionModel
+	_, err := a.where(a.session.Select("*").From("group_manager_action"), groupNo).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (a *actionDB) queryCount(groupNo string) (int64, error) {
+	var count int64
+	_, err := a.where(a.session.Select("count(*)").From("group_manager_action"), groupNo).Load(&count)
+	return count, err
+}
+
+func (a *actionDB) where(builder *dbr.SelectStmt, groupNo string) *dbr.SelectStmt {
+	if groupNo != "" {
+		builder = builder.Where("group_no=?", groupNo)
+	}
+	return builder
+}
+
+// queryPurgeDue 保留时长已到期 还没有删除群消息的解散记录
+func (a *actionDB) queryPurgeDue(now int64, limit uint64) ([]*actionModel, error) {
+	var models []*actionModel
+	_, err := a.session.Select("*").From("group_manager_action").Where("action=? and purged_at=0 and purge_at>0 and purge_at<=?", ActionDissolve, now).OrderDir("purge_at", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+func (a *actionDB) updatePurged(id int64, purgedAt int64) error {
+	_, err := a.session.Update("group_manager_action").Set("purged_at", purgedAt).Where("id=?", id).Exec()
+	return err
+}
+
+// queryActivity 用户最后一次上线或离线的时间
+func (a *actionDB) queryActivity(uid string) (*activityModel, error) {
+	var model *activityModel
+	_, err := a.session.Select("IFNULL(max(online),0) online,IFNULL(max(greatest(last_online,last_offline)),0) last_active").From("user_online").Where("uid=?", uid).Load(&model)
+	return model, err
+}
+
+// queryOwner 查询群主 没有群主时返回nil
+func (a *actionDB) queryOwner(groupNo string) (*MemberModel, error) {
+	var model *MemberModel
+	_, err := a.session.Select("*").From("group_member").Where("group_no=? and role=? and is_deleted=0", groupNo, MemberRoleCreator).Limit(1).Load(&model)
+	return model, err
+}
+
+type actionModel struct {
+	GroupNo     string
+	Action      string
+	Operator    string
+	Reason      string
+	Notice      string
+	OldOwner    string
+	NewOwner    string
+	MemberCount int
+	PurgeAt     int64
+	PurgedAt    int64
+	db.BaseModel
+}
+
+type activityModel struct {
+	Online     int
+	LastActive int64
+}
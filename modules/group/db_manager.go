@@ -135,5 +135,6 @@ type managerMemberModel struct {
 	Version   int64
 	Vercode   string //验证码
 	IsDeleted int    // 是否删除
+	Robot     int    // 是否是机器人
 	db.BaseModel
 }
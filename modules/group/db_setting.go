@@ -74,6 +74,8 @@ func (s *settingDB) UpdateSetting(setting *Setting) error {
 		"flame":             setting.Flame,
 		"flame_second":      setting.FlameSecond,
 		"remark":            setting.Remark,
+		"push_privacy":      setting.PushPrivacy,
+		"notify_level":      setting.NotifyLevel,
 	}).Where("id=?", setting.Id).Exec()
 	return err
 }
@@ -96,6 +98,8 @@ func (s *settingDB) UpdateSettingWithTx(setting *Setting, tx *dbr.Tx) error {
 		"flame":             setting.Flame,
 		"flame_second":      setting.FlameSecond,
 		"remark":            setting.Remark,
+		"push_privacy":      setting.PushPrivacy,
+		"notify_level":      setting.NotifyLevel,
 	}).Where("id=?", setting.Id).Exec()
 	return err
 }
@@ -116,7 +120,10 @@ type Setting struct {
 	Flame           int    // 是否开启阅后即焚
 	FlameSecond     int    // 阅后即焚秒数
 	Remark          string // 群备注
+	PushPrivacy     int    // 推送隐私 0.跟随用户的设置 1.隐藏推送内容 2.显示推送内容
+	NotifyLevel     int    // 通知级别 0.所有消息 1.仅@我和回复我的消息 2.不通知
 	Version         int64  // 版本
+	TenantID        string // 所属组织 与群的组织相同
 	db.BaseModel
 }
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/pool"
@@ -82,6 +83,19 @@ func (g *Group) handleRegisterUserEvent(data []byte, commit config.EventCommit)
 		commit(errors.New("处理用户注册加入群聊UID不能为空"))
 		return
 	}
+	// 系统群属于默认组织 其他组织的用户不加入
+	if tenant.Enabled() {
+		userTenant, err := g.tenantService.UserTenant(uid)
+		if err != nil {
+			g.Error("查询用户的组织失败", zap.Error(err))
+			commit(err)
+			return
+		}
+		if userTenant != "" {
+			commit(nil)
+			return
+		}
+	}
 	//查询群聊是否存在
 	groupModel, err := g.db.QueryWithGroupNo(g.ctx.GetConfig().Account.SystemGroupID)
 	if err != nil {
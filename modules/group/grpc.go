@@ -0,0 +1,117 @@
+package group
+
+import (
+	"context"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rpcapi"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// groupRPC 内部gRPC接口的群服务
+type groupRPC struct {
+	rpcapi.UnimplementedGroupServiceServer
+	g *Group
+}
+
+func newGroupRPC(g *Group) *groupRPC {
+	return &groupRPC{g: g}
+}
+
+// GetGroup 查询群信息
+func (r *groupRPC) GetGroup(ctx context.Context, req *rpcapi.GetGroupReq) (*rpcapi.GetGroupResp, error) {
+	if req.GroupNo == "" {
+		return nil, status.Error(codes.InvalidArgument, "群编号不能为空！")
+	}
+	group, err := r.g.db.QueryWithGroupNo(req.GroupNo)
+	if err != nil {
+		r.g.Error("查询群信息失败！", zap.Error(err), zap.String("groupNo", req.GroupNo))
+		return nil, status.Error(codes.Internal, "查询群信息失败！")
+	}
+	resp := &rpcapi.GetGroupResp{}
+	if group != nil {
+		resp.Group = toRPCGroup(toInfoResp(group))
+	}
+	return resp, nil
+}
+
+// GetMembers 群成员列表
+func (r *groupRPC) GetMembers(ctx context.Context, req *rpcapi.GetMembersReq) (*rpcapi.GetMembersResp, error) {
+	if req.GroupNo == "" {
+		return nil, status.Error(codes.InvalidArgument, "群编号不能为空！")
+	}
+	members, err := r.g.groupService.GetMembers(req.GroupNo)
+	if err != nil {
+		r.g.Error("查询群成员失败！", zap.Error(err), zap.String("groupNo", req.GroupNo))
+		return nil, status.Error(codes.Internal, "查询群成员失败！")
+	}
+	resp := &rpcapi.GetMembersResp{
+		Members: make([]*rpcapi.Member, 0, len(members)),
+	}
+	for _, member := range members {
+		resp.Members = append(resp.Members, toRPCMember(member))
+	}
+	return resp, nil
+}
+
+// IsMember 查询用户是否是群成员
+func (r *groupRPC) IsMember(ctx context.Context, req *rpcapi.IsMemberReq) (*rpcapi.IsMemberResp, error) {
+	if req.GroupNo == "" || req.Uid == "" {
+		return nil, status.Error(codes.InvalidArgument, "群编号和uid不能为空！")
+	}
+	member, err := r.g.groupService.GetMember(req.GroupNo, req.Uid)
+	if err != nil {
+		r.g.Error("查询群成员失败！", zap.Error(err), zap.String("groupNo", req.GroupNo), zap.String("uid", req.Uid))
+		return nil, status.Error(codes.Internal, "查询群成员失败！")
+	}
+	resp := &rpcapi.IsMemberResp{}
+	if member != nil {
+		resp.IsMember = true
+		resp.Member = toRPCMember(member)
+	}
+	return resp, nil
+}
+
+// GetUserGroups 用户加入的所有群
+func (r *groupRPC) GetUserGroups(ctx context.Context, req *rpcapi.GetUserGroupsReq) (*rpcapi.GetUserGroupsResp, error) {
+	if req.Uid == "" {
+		return nil, status.Error(codes.InvalidArgument, "uid不能为空！")
+	}
+	groups, err := r.g.groupService.GetGroupsWithMemberUID(req.Uid)
+	if err != nil {
+		r.g.Error("查询用户的群失败！", zap.Error(err), zap.String("uid", req.Uid))
+		return nil, status.Error(codes.Internal, "查询用户的群失败！")
+	}
+	resp := &rpcapi.GetUserGroupsResp{
+		Groups: make([]*rpcapi.Group, 0, len(groups)),
+	}
+	for _, group := range groups {
+		resp.Groups = append(resp.Groups, toRPCGroup(group))
+	}
+	return resp, nil
+}
+
+func toRPCGroup(group *InfoResp) *rpcapi.Group {
+	return &rpcapi.Group{
+		GroupNo:   group.GroupNo,
+		GroupType: int32(group.GroupType),
+		Name:      group.Name,
+		Notice:    group.Notice,
+		Creator:   group.Creator,
+		Status:    int32(group.Status),
+		Forbidden: group.Forbidden == 1,
+		Version:   group.Version,
+	}
+}
+
+func toRPCMember(member *MemberResp) *rpcapi.Member {
+	return &rpcapi.Member{
+		GroupNo: member.GroupNo,
+		Uid:     member.UID,
+		Name:    member.Name,
+		Remark:  member.Remark,
+		Role:    int32(member.Role),
+		Version: member.Version,
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -24,7 +25,7 @@ func (g *Group) groupMemberInviteAdd(c *wkhttp.Context) {
 	var req InviteReq
 	if err := c.BindJSON(&req); err != nil {
 		g.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if err := req.Check(); err != nil {
@@ -106,7 +107,7 @@ func (g *Group) groupMemberInviteAdd(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	g.ctx.EventCommit(eventID)
@@ -119,7 +120,7 @@ func (g *Group) getToGroupMemberConfirmInviteDetailH5(c *wkhttp.Context) {
 	inviteNo := c.Query("invite_no")
 	loginUID := c.MustGet("uid").(string)
 	if groupNo == "" {
-		c.ResponseError(errors.New("群编号不能为空"))
+		c.ResponseError(errcode.ErrGroupNoRequired)
 		return
 	}
 	_, err := g.getGroupInfo(groupNo)
@@ -211,7 +212,7 @@ func (g *Group) groupMemberInviteSure(c *wkhttp.Context) {
 		members = append(members, inviteItemDetilModel.UID)
 	}
 	if groupNo == "" {
-		c.ResponseError(errors.New("群编号不能为空"))
+		c.ResponseError(errcode.ErrGroupNoRequired)
 		return
 	}
 	_, err = g.getGroupInfo(groupNo)
@@ -266,7 +267,7 @@ func (g *Group) groupMemberInviteSure(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 
@@ -0,0 +1,63 @@
+package group
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/postgres/pgtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPostgresGroup 群和群成员的语句在PostgreSQL上执行 需要设置TSDD_TEST_POSTGRES
+func TestPostgresGroup(t *testing.T) {
+	ctx := pgtest.NewTestContext(t)
+	d := NewDB(ctx)
+
+	_, err := ctx.DB().InsertInto("user").Columns("uid", "name", "short_no", "vercode", "qr_vercode").
+		Values("u1", "Alice", "s1", "v1", "q1").Values("u2", "Bob", "s2", "v2", "q2").Values("u3", "Carol", "s3", "v3", "q3").Exec()
+	assert.NoError(t, err)
+
+	assert.NoError(t, d.Insert(&Model{GroupNo: "g1", Name: "群1", Creator: "u1", Status: 1, Version: 1}))
+	assert.NoError(t, d.InsertMember(&MemberModel{GroupNo: "g1", UID: "u3", Role: MemberRoleCommon, Version: 1, Status: 1, Vercode: "m3"}))
+	assert.NoError(t, d.InsertMember(&MemberModel{GroupNo: "g1", UID: "u2", Role: MemberRoleManager, Version: 2, Status: 1, Vercode: "m2"}))
+	assert.NoError(t, d.InsertMember(&MemberModel{GroupNo: "g1", UID: "u1", Role: MemberRoleCreator, Version: 3, Status: 1, Vercode: "m1"}))
+
+	detail, err := d.QueryDetailWithGroupNo("g1", "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, "群1", detail.Name)
+	assert.Equal(t, 0, detail.Mute)
+	details, err := d.QueryDetailWithGroupNos([]string{"g1", "g2"}, "u1")
+	assert.NoError(t, err)
+	assert.Len(t, details, 1)
+
+	count, err := d.QueryMemberCount("g1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	created, err := d.querySameDayCreateCountWitUID("u1", time.Now().Format("2006-01-02"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	groupCount, err := d.queryCreatedCountWithDate(time.Now().Format("2006-01-02"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), groupCount)
+
+	members, err := d.SyncMembers("g1", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+	assert.Equal(t, "Bob", members[0].Name)
+
+	// 创建者、管理者、普通成员的顺序
+	members, err = d.queryMembersWithCursor("g1", "u1", "", 0, 0, 2)
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+	assert.Equal(t, "u1", members[0].UID)
+	assert.Equal(t, "u2", members[1].UID)
+	members, err = d.queryMembersWithCursor("g1", "u1", "", memberRank(members[1].Role), members[1].Id, 2)
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+	assert.Equal(t, "u3", members[0].UID)
+	// 关键字不区分大小写
+	members, err = d.queryMembersWithKeyword("g1", "u1", "carol", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+	assert.Equal(t, "u3", members[0].UID)
+}
@@ -4,9 +4,14 @@ import (
 	"errors"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
 	"go.uber.org/zap"
 )
 
@@ -64,6 +69,16 @@ type IService interface {
 	AddMember(model *AddMemberReq) error
 	// 获取指定一批群的指定成员信息
 	GetMembersWithUIDAndGroupIds(uid string, groupNos []string) ([]*MemberResp, error)
+	// RemoveMembers 移除群成员 不校验操作者权限
+	RemoveMembers(req *RemoveMembersReq) error
+	// MuteMember 禁言群成员到指定时间（秒级时间戳） 为0时解除禁言 不校验操作者权限
+	MuteMember(groupNo string, uid string, expireAt int64) error
+
+	// -------------------- 管理员解散群 --------------------
+	// GetDissolvedToPurge 保留时长已到期需要删除群消息的群
+	GetDissolvedToPurge(now int64, limit uint64) ([]*DissolvedResp, error)
+	// MarkDissolvedPurged 记录群消息已删除
+	MarkDissolvedPurged(id int64) error
 }
 
 // Service Service
@@ -73,6 +88,8 @@ type Service struct {
 	managerDB *managerDB
 	log.Log
 	settingDB *settingDB
+	userDB    *user.DB
+	actionDB  *actionDB
 }
 
 // NewService NewService
@@ -83,6 +100,8 @@ func NewService(ctx *config.Context) IService {
 		managerDB: newManagerDB(ctx.DB()),
 		Log:       log.NewTLog("groupService"),
 		settingDB: newSettingDB(ctx),
+		userDB:    user.NewDB(ctx),
+		actionDB:  newActionDB(ctx),
 	}
 }
 
@@ -150,7 +169,7 @@ func (s *Service) GetGroupWithDateSpace(startDate, endDate string) (map[string]i
 // GetGroupWithGroupNo 查询一个群信息
 func (s *Service) GetGroupWithGroupNo(groupNo string) (*InfoResp, error) {
 	if groupNo == "" {
-		return nil, errors.New("群编号不能为空")
+		return nil, errcode.ErrGroupNoRequired
 	}
 	group, err := s.db.QueryWithGroupNo(groupNo)
 	if err != nil {
@@ -181,7 +200,7 @@ func (s *Service) GetGroupDetail(groupNo string, uid string) (*GroupResp, error)
 	groupDetailModel, err := s.db.QueryDetailWithGroupNo(groupNo, uid)
 	if err != nil {
 		s.Error("查询群信息失败！", zap.Error(err))
-		return nil, errors.New("查询群信息失败！")
+		return nil, errcode.ErrQueryGroup
 	}
 	if groupDetailModel == nil {
 		return nil, nil
@@ -250,6 +269,222 @@ func (s *Service) AddMember(model *AddMemberReq) error {
 	})
 	return err
 }
+
+// RemoveMembers 移除群成员 发送群成员移除事件并更新群头像
+func (s *Service) RemoveMembers(req *RemoveMembersReq) error {
+	realDeleteMemberModels, err := s.userDB.QueryByUIDs(req.MemberUIDs)
+	if err != nil {
+		s.Error("查询成员用户信息失败！", zap.Error(err))
+		return errors.New("查询成员用户信息失败！")
+	}
+	memberCount, err := s.db.QueryMemberCount(req.GroupNo)
+	if err != nil {
+		s.Error("查询群成员数量失败！", zap.Error(err))
+		return errors.New("查询群成员数量失败！")
+	}
+
+	userBaseVos := make([]*config.UserBaseVo, 0, len(realDeleteMemberModels))
+	for _, realMember := range realDeleteMemberModels {
+		userBaseVos = append(userBaseVos, &config.UserBaseVo{
+			UID:  realMember.UID,
+			Name: realMember.Name,
+		})
+	}
+	nowMemberCount := int(memberCount) - len(userBaseVos) // 当前成员数量
+
+	needGenGroupAvatar := false // 是否需要生成头像
+
+	if nowMemberCount < 9 && nowMemberCount > 0 {
+		needGenGroupAvatar = true
+	}
+	if !needGenGroupAvatar {
+		needGenGroupAvatar, err = s.db.membersInFirstNine(req.GroupNo, req.MemberUIDs)
+		if err != nil {
+			s.Error("查询最早加入的成员信息失败！", zap.Error(err))
+			return errors.New("查询最早加入的成员信息失败！")
+		}
+	}
+	groupIsUploadAvatar, err := s.db.queryGroupAvatarIsUpload(req.GroupNo)
+	if err != nil {
+		s.Error("查询群头像是否用户上传过失败！", zap.String("group_no", req.GroupNo), zap.Error(err))
+	}
+	if groupIsUploadAvatar == 1 {
+		needGenGroupAvatar = false
+	}
+
+	tx, err := s.db.session.Begin()
+	util.CheckErr(err)
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+
+	for _, realMember := range realDeleteMemberModels {
+
+		version := s.ctx.GenSeq(common.GroupMemberSeqKey)
+		err = s.db.DeleteMemberTx(req.GroupNo, realMember.UID, version, tx)
+		if err != nil {
+			tx.RollbackUnlessCommitted()
+			s.Error("删除群成员失败！", zap.Error(err))
+			return errors.New("删除群成员失败！")
+		}
+	}
+
+	// 发布群成员删除事件
+	groupMemberRemoveReq := &config.MsgGroupMemberRemoveReq{
+		GroupNo:      req.GroupNo,
+		Operator:     req.Operator,
+		OperatorName: req.OperatorName,
+		Members:      userBaseVos,
+	}
+	eventID, err := s.ctx.EventBegin(&wkevent.Data{
+		Event: event.GroupMemberRemove,
+		Type:  wkevent.Message,
+		Data:  groupMemberRemoveReq,
+	}, tx)
+	if err != nil {
+		tx.RollbackUnlessCommitted()
+		s.Error("开启事件失败！", zap.Error(err))
+		return errors.New("开启事件失败！")
+	}
+
+	var groupAvatarEventID int64
+	if needGenGroupAvatar {
+		nineMemberUIDs := make([]string, 0, 9)
+		nownineMembers, err := s.db.QueryMembersFirstNineExclude(req.GroupNo, req.MemberUIDs)
+		if err != nil {
+			tx.Rollback()
+			s.Error("查询先存成员信息失败！", zap.String("group_no", req.GroupNo), zap.Error(err))
+			return errors.New("查询先存成员信息失败！")
+		}
+		if len(nownineMembers) > 0 {
+			for _, nowninceMember := range nownineMembers {
+				nineMemberUIDs = append(nineMemberUIDs, nowninceMember.UID)
+			}
+		}
+		if len(nineMemberUIDs) > 0 {
+			groupAvatarEventID, err = s.ctx.EventBegin(&wkevent.Data{
+				Event: event.GroupAvatarUpdate,
+				Type:  wkevent.CMD,
+				Data: &config.CMDGroupAvatarUpdateReq{
+					GroupNo: req.GroupNo,
+					Members: nineMemberUIDs,
+				},
+			}, tx)
+			if err != nil {
+				tx.Rollback()
+				s.Error("开启群成员头像更新事件失败！", zap.Error(err))
+				return errors.New("开启群成员头像更新事件失败！")
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		s.Error("提交事务失败！", zap.Error(err))
+		return errcode.ErrTxCommit
+	}
+	// 提交事件
+	s.ctx.EventCommit(eventID)
+	if groupAvatarEventID != 0 {
+		s.ctx.EventCommit(groupAvatarEventID)
+	}
+
+	// 调用IM的移除订阅者
+	err = s.ctx.IMRemoveSubscriber(&config.SubscriberRemoveReq{
+		ChannelID:   req.GroupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Subscribers: req.MemberUIDs,
+	})
+	if err != nil {
+		s.Error("调用IM的移除订阅者接口失败！", zap.Error(err))
+		return errors.New("调用IM的移除订阅者接口失败！")
+	}
+
+	//给被踢的成员发送被踢消息
+	err = s.ctx.SendGroupMemberBeRemove(groupMemberRemoveReq)
+	if err != nil {
+		s.Warn("发送群成员被踢消息失败！", zap.Error(err))
+	}
+
+	return nil
+}
+
+// MuteMember 禁言或解除禁言群成员
+func (s *Service) MuteMember(groupNo string, uid string, expireAt int64) error {
+	member, err := s.db.QueryMemberWithUID(uid, groupNo)
+	if err != nil {
+		s.Error("查询成员信息错误", zap.Error(err))
+		return errors.New("查询成员信息错误")
+	}
+	if member == nil {
+		return errors.New("该成员不在群内")
+	}
+	member.Version = s.ctx.GenSeq(common.GroupMemberSeqKey)
+	member.ForbiddenExpirTime = expireAt
+	err = s.db.UpdateMember(member)
+	if err != nil {
+		s.Error("修改成员禁言时间错误", zap.Error(err))
+		if expireAt == 0 {
+			return errors.New("解除用户禁言错误")
+		}
+		return errors.New("禁言用户错误")
+	}
+	// 加入talk黑名单
+	blacklistReq := config.ChannelBlacklistReq{
+		ChannelReq: config.ChannelReq{
+			ChannelID:   groupNo,
+			ChannelType: common.ChannelTypeGroup.Uint8(),
+		},
+		UIDs: []string{uid},
+	}
+	if expireAt > 0 {
+		err = s.ctx.IMBlacklistAdd(blacklistReq)
+	} else {
+		err = s.ctx.IMBlacklistRemove(blacklistReq)
+	}
+	if err != nil {
+		s.Error("设置群黑名单错误", zap.Error(err))
+		return errors.New("设置IM黑名单错误")
+	}
+	err = s.ctx.SendCMD(config.MsgCMDReq{
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		CMD:         common.CMDGroupMemberUpdate,
+		Param: map[string]interface{}{
+			"group_no": groupNo,
+		},
+	})
+	if err != nil {
+		s.Error("发送命令消息失败！", zap.Error(err))
+		return errcode.ErrSendCMD
+	}
+	return nil
+}
+
+// GetDissolvedToPurge 管理员解散后保留时长已到期 需要删除群消息的群
+func (s *Service) GetDissolvedToPurge(now int64, limit uint64) ([]*DissolvedResp, error) {
+	models, err := s.actionDB.queryPurgeDue(now, limit)
+	if err != nil {
+		return nil, err
+	}
+	resps := make([]*DissolvedResp, 0, len(models))
+	for _, m := range models {
+		resps = append(resps, &DissolvedResp{
+			ID:      m.Id,
+			GroupNo: m.GroupNo,
+			PurgeAt: m.PurgeAt,
+		})
+	}
+	return resps, nil
+}
+
+// MarkDissolvedPurged 群消息已删除
+func (s *Service) MarkDissolvedPurged(id int64) error {
+	return s.actionDB.updatePurged(id, time.Now().Unix())
+}
+
 func (s *Service) GetGroupMemberMaxVersion(groupNo string) (int64, error) {
 	version, err := s.db.queryGroupMemberMaxVersion(groupNo)
 	return version, err
@@ -380,6 +615,21 @@ type AddMemberReq struct {
 	MemberUID string
 }
 
+// RemoveMembersReq 移除群成员
+type RemoveMembersReq struct {
+	GroupNo      string
+	Operator     string // 操作者uid
+	OperatorName string // 操作者名称
+	MemberUIDs   []string
+}
+
+// DissolvedResp 管理员解散的群
+type DissolvedResp struct {
+	ID      int64 // 解散记录的ID
+	GroupNo string
+	PurgeAt int64 // 删除群消息的时间
+}
+
 // InfoResp 群信息
 type InfoResp struct {
 	GroupNo             string    `json:"group_no"`               // 群编号
@@ -452,6 +702,8 @@ type SettingResp struct {
 	JoinGroupRemind int    //进群提醒
 	Receipt         int    //消息是否回执
 	Remark          string // 群备注
+	PushPrivacy     int    // 推送隐私 0.跟随用户的设置 1.隐藏推送内容 2.显示推送内容
+	NotifyLevel     int    // 通知级别 0.所有消息 1.仅@我和回复我的消息 2.不通知
 	Version         int64  // 版本
 }
 
@@ -468,6 +720,8 @@ func toSettingResp(m *Setting) *SettingResp {
 		JoinGroupRemind: m.JoinGroupRemind,
 		Receipt:         m.Receipt,
 		Remark:          m.Remark,
+		PushPrivacy:     m.PushPrivacy,
+		NotifyLevel:     m.NotifyLevel,
 		Version:         m.Version,
 		UID:             m.UID,
 	}
@@ -502,6 +756,8 @@ type GroupResp struct {
 	Role                     int       `json:"role"`                        // 我在群聊里的角色
 	ForbiddenExpirTime       int64     `json:"forbidden_expir_time"`        // 我在此群的禁言过期时间
 	AllowMemberPinnedMessage int       `json:"allow_member_pinned_message"` //是否允许群成员置顶消息
+	PushPrivacy              int       `json:"push_privacy"`                // 推送隐私 0.跟随用户的设置 1.隐藏推送内容 2.显示推送内容
+	NotifyLevel              int       `json:"notify_level"`                // 通知级别 0.所有消息 1.仅@我和回复我的消息 2.不通知
 	CreatedAt                string    `json:"created_at"`
 	UpdatedAt                string    `json:"updated_at"`
 	Version                  int64     `json:"version"` // 群数据版本
@@ -533,6 +789,8 @@ func (g *GroupResp) from(model *DetailModel) *GroupResp {
 		Status:                   model.Status,
 		AllowViewHistoryMsg:      model.AllowViewHistoryMsg,
 		AllowMemberPinnedMessage: model.AllowMemberPinnedMessage,
+		PushPrivacy:              model.PushPrivacy,
+		NotifyLevel:              model.NotifyLevel,
 		CreatedAt:                model.CreatedAt.String(),
 		UpdatedAt:                model.UpdatedAt.String(),
 	}
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"go.uber.org/zap"
 )
 
@@ -52,7 +53,7 @@ func (g *Group) GetGroupMemberByUID(uid string, groupNo string) (*source.GroupMe
 		return nil, errors.New("uid不能为空")
 	}
 	if groupNo == "" {
-		return nil, errors.New("群编号不能为空")
+		return nil, errcode.ErrGroupNoRequired
 	}
 	model, err := g.db.QueryMemberWithUID(uid, groupNo)
 	if err != nil {
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+-- 推送隐私 0.跟随用户的设置 1.隐藏推送内容 2.显示推送内容
+ALTER TABLE `group_setting` ADD COLUMN push_privacy smallint NOT NULL DEFAULT 0 COMMENT '推送隐私 0.跟随用户的设置 1.隐藏推送内容 2.显示推送内容';
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+-- 群消息的通知级别 0.所有消息 1.仅@我和回复我的消息 2.不通知
+ALTER TABLE `group_setting` ADD COLUMN notify_level smallint NOT NULL DEFAULT 0 COMMENT '通知级别 0.所有消息 1.仅@我和回复我的消息 2.不通知';
@@ -0,0 +1,21 @@
+-- +migrate Up
+
+-- 管理员解散或接管群的记录 解散的群按保留时长到期后删除服务端保存的群消息
+create table `group_manager_action`
+(
+  id             bigint         not null primary key AUTO_INCREMENT,
+  group_no       VARCHAR(40)    not null default '',  -- 群编号
+  action         VARCHAR(20)    not null default '',  -- 操作 dissolve.解散 takeover.转让群主
+  operator       VARCHAR(40)    not null default '',  -- 操作的管理员
+  reason         VARCHAR(500)   not null default '',  -- 操作原因 只有管理员可以看到
+  notice         VARCHAR(500)   not null default '',  -- 发给群成员的通知
+  old_owner      VARCHAR(40)    not null default '',  -- 原群主
+  new_owner      VARCHAR(40)    not null default '',  -- 新群主 解散时为空
+  member_count   int            not null default 0,   -- 操作时的群成员数
+  purge_at       bigint         not null default 0,   -- 删除群消息的时间 0为永久保留
+  purged_at      bigint         not null default 0,   -- 删除群消息完成的时间
+  created_at     timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at     timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE INDEX `group_manager_action_group_idx` on `group_manager_action` (`group_no`);
+CREATE INDEX `group_manager_action_purge_idx` on `group_manager_action` (`purged_at`, `purge_at`);
@@ -0,0 +1,6 @@
+-- +migrate Up
+
+-- 多组织 群属于创建者的组织 默认组织为空
+ALTER TABLE `group` ADD COLUMN `tenant_id` VARCHAR(40) not null default '' COMMENT '组织ID';
+ALTER TABLE `group_setting` ADD COLUMN `tenant_id` VARCHAR(40) not null default '' COMMENT '组织ID 与群所属的组织相同';
+CREATE INDEX `group_tenant_idx` on `group` (`tenant_id`);
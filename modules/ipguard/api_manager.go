@@ -0,0 +1,498 @@
+package ipguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 自动封禁过期后保留的时长 方便管理员查看
+const autoBlockRetention = time.Hour * 24 * 7
+
+// 每次最多添加的黑名单或国家规则数
+const maxBatchSize = 1000
+
+// Manager IP黑名单和国家限制管理
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	return &Manager{
+		ctx: ctx,
+		Log: log.NewTLog("IPGuardManager"),
+		db:  newDB(ctx),
+	}
+}
+
+// Route 路由配置
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/ip_blocks", m.blocks)                       // IP黑名单
+		auth.POST("/ip_blocks", m.addBlocks)                   // 添加IP黑名单
+		auth.DELETE("/ip_blocks", m.deleteBlocks)              // 删除IP黑名单（解封）
+		auth.GET("/ip_country_rules", m.countryRules)          // 国家限制
+		auth.POST("/ip_country_rules", m.addCountryRules)      // 添加或修改国家限制
+		auth.DELETE("/ip_country_rules", m.deleteCountryRules) // 删除国家限制
+		auth.POST("/ip_blocks/check", m.check)                 // 检查IP是否会被拦截
+	}
+	if extconfig.Get().IPGuard.On {
+		m.reloadRules(false)
+		m.ctx.Schedule(extconfig.Get().IPGuard.ReloadInterval, func() {
+			m.reloadRules(false)
+			if err := rules.flushHits(m.db); err != nil {
+				m.Warn("写入IP规则的拦截次数失败！", zap.Error(err))
+			}
+		})
+		m.ctx.Schedule(time.Hour, m.cleanExpiredBlocks)
+	}
+}
+
+func (m *Manager) reloadRules(force bool) {
+	if err := rules.reload(m.db, force); err != nil {
+		m.Warn("加载IP规则失败！", zap.Error(err))
+	}
+}
+
+// cleanExpiredBlocks 清理过期一段时间的自动封禁
+func (m *Manager) cleanExpiredBlocks() {
+	count, err := m.db.deleteExpiredAutoBlocks(time.Now().Add(-autoBlockRetention).Unix())
+	if err != nil {
+		m.Warn("清理过期的自动封禁失败！", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		m.Info("已清理过期的自动封禁", zap.Int64("count", count))
+	}
+}
+
+// IP黑名单 status为active时只查询生效中的 expired时只查询已过期的
+func (m *Manager) blocks(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermSecurityRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	filter := blockFilter{
+		keyword: strings.TrimSpace(c.Query("keyword")),
+		source:  c.Query("source"),
+		scene:   c.Query("scene"),
+		now:     time.Now().Unix(),
+	}
+	switch c.Query("status") {
+	case "active":
+		expired := false
+		filter.expired = &expired
+	case "expired":
+		expired := true
+		filter.expired = &expired
+	}
+	models, err := m.db.queryBlocksWithPage(filter, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询IP黑名单失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询IP黑名单失败！"))
+		return
+	}
+	count, err := m.db.queryBlocksCount(filter)
+	if err != nil {
+		m.Error("查询IP黑名单数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询IP黑名单数量失败！"))
+		return
+	}
+	list := make([]*blockResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newBlockResp(model, filter.now))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+type addBlocksReq struct {
+	CIDRs    []string `json:"cidrs"`    // IP或IP段（CIDR格式）
+	Scene    string   `json:"scene"`    // 生效的场景 为空时为all
+	Reason   string   `json:"reason"`   // 封禁原因
+	Duration int64    `json:"duration"` // 封禁时长（秒） 为0时永久
+}
+
+// 添加IP黑名单 已存在的会覆盖场景以外的设置
+func (m *Manager) addBlocks(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermSecurityWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req addBlocksReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if req.Scene == "" {
+		req.Scene = SceneAll
+	}
+	if !validScene(req.Scene) {
+		c.ResponseError(errors.New("场景有误！"))
+		return
+	}
+	if req.Duration < 0 {
+		c.ResponseError(errors.New("封禁时长有误！"))
+		return
+	}
+	if len([]rune(req.Reason)) > 200 {
+		c.ResponseError(errors.New("封禁原因不能超过200个字！"))
+		return
+	}
+	if len(req.CIDRs) > maxBatchSize {
+		c.ResponseError(fmt.Errorf("每次最多添加%d个IP！", maxBatchSize))
+		return
+	}
+	var expireAt int64
+	if req.Duration > 0 {
+		expireAt = time.Now().Unix() + req.Duration
+	}
+	version := m.ctx.GenSeq(seqKey)
+	models := make([]*blockModel, 0, len(req.CIDRs))
+	exists := map[string]bool{}
+	for _, value := range req.CIDRs {
+		cidr, err := normalizeCIDR(value)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		if exists[cidr] {
+			continue
+		}
+		exists[cidr] = true
+		models = append(models, &blockModel{
+			CIDR:     cidr,
+			Scene:    req.Scene,
+			Source:   SourceManual,
+			Reason:   strings.TrimSpace(req.Reason),
+			ExpireAt: expireAt,
+			Creator:  c.GetLoginUID(),
+			Version:  version,
+		})
+	}
+	if len(models) == 0 {
+		c.ResponseError(errors.New("IP不能为空！"))
+		return
+	}
+	tx, _ := m.db.session.Begin()
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	if err := m.db.insertOrUpdateBlocksTx(models, tx); err != nil {
+		tx.Rollback()
+		m.Error("添加IP黑名单失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加IP黑名单失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		m.Error("事务提交失败！", zap.Error(err))
+		c.ResponseError(errors.New("事务提交失败！"))
+		return
+	}
+	m.reloadRules(false)
+	audit.SetChange(c, fmt.Sprintf("scene=%s", req.Scene), nil, req)
+	c.Response(map[string]interface{}{
+		"count": len(models),
+	})
+}
+
+// 删除IP黑名单（解封）
+func (m *Manager) deleteBlocks(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermSecurityWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if len(req.IDs) == 0 {
+		c.ResponseError(errors.New("要删除的IP不能为空！"))
+		return
+	}
+	models, err := m.db.queryBlocksWithIDs(req.IDs)
+	if err != nil {
+		m.Error("查询IP黑名单失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询IP黑名单失败！"))
+		return
+	}
+	if err := m.db.deleteBlocks(req.IDs, m.ctx.GenSeq(seqKey)); err != nil {
+		m.Error("删除IP黑名单失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除IP黑名单失败！"))
+		return
+	}
+	m.reloadRules(false)
+	before := make([]string, 0, len(models))
+	for _, model := range models {
+		before = append(before, fmt.Sprintf("%s@%s", model.CIDR, model.Scene))
+	}
+	audit.SetChange(c, "ip_blocks", before, nil)
+	c.ResponseOK()
+}
+
+// 国家限制
+func (m *Manager) countryRules(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermSecurityRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := m.db.queryCountryRules()
+	if err != nil {
+		m.Error("查询国家限制失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询国家限制失败！"))
+		return
+	}
+	list := make([]*countryRuleResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newCountryRuleResp(model))
+	}
+	c.Response(list)
+}
+
+type addCountryRulesReq struct {
+	Countries []string `json:"countries"` // 国家代码（ISO 3166-1 alpha-2） 例如 CN
+	Scene     string   `json:"scene"`     // 生效的场景 为空时为all
+	Action    string   `json:"action"`    // allow.只允许这些国家 block.禁止
+}
+
+// 添加国家限制 已存在的修改处理方式
+func (m *Manager) addCountryRules(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermSecurityWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req addCountryRulesReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if req.Scene == "" {
+		req.Scene = SceneAll
+	}
+	if !validScene(req.Scene) {
+		c.ResponseError(errors.New("场景有误！"))
+		return
+	}
+	if !validCountryAction(req.Action) {
+		c.ResponseError(errors.New("处理方式有误！"))
+		return
+	}
+	if len(req.Countries) > maxBatchSize {
+		c.ResponseError(fmt.Errorf("每次最多添加%d个国家！", maxBatchSize))
+		return
+	}
+	version := m.ctx.GenSeq(seqKey)
+	models := make([]*countryRuleModel, 0, len(req.Countries))
+	exists := map[string]bool{}
+	for _, value := range req.Countries {
+		country := normalizeCountry(value)
+		if country == "" {
+			c.ResponseError(fmt.Errorf("国家代码[%s]有误！", value))
+			return
+		}
+		if exists[country] {
+			continue
+		}
+		exists[country] = true
+		models = append(models, &countryRuleModel{
+			Scene:   req.Scene,
+			Country: country,
+			Action:  req.Action,
+			Creator: c.GetLoginUID(),
+			Version: version,
+		})
+	}
+	if len(models) == 0 {
+		c.ResponseError(errors.New("国家不能为空！"))
+		return
+	}
+	tx, _ := m.db.session.Begin()
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	if err := m.db.insertOrUpdateCountryRulesTx(models, tx); err != nil {
+		tx.Rollback()
+		m.Error("添加国家限制失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加国家限制失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		m.Error("事务提交失败！", zap.Error(err))
+		c.ResponseError(errors.New("事务提交失败！"))
+		return
+	}
+	m.reloadRules(false)
+	audit.SetChange(c, fmt.Sprintf("scene=%s", req.Scene), nil, req)
+	c.Response(map[string]interface{}{
+		"count": len(models),
+	})
+}
+
+// 删除国家限制
+func (m *Manager) deleteCountryRules(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermSecurityWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if len(req.IDs) == 0 {
+		c.ResponseError(errors.New("要删除的国家限制不能为空！"))
+		return
+	}
+	models, err := m.db.queryCountryRulesWithIDs(req.IDs)
+	if err != nil {
+		m.Error("查询国家限制失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询国家限制失败！"))
+		return
+	}
+	if err := m.db.deleteCountryRules(req.IDs, m.ctx.GenSeq(seqKey)); err != nil {
+		m.Error("删除国家限制失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除国家限制失败！"))
+		return
+	}
+	m.reloadRules(false)
+	before := make([]string, 0, len(models))
+	for _, model := range models {
+		before = append(before, fmt.Sprintf("%s:%s@%s", model.Action, model.Country, model.Scene))
+	}
+	audit.SetChange(c, "ip_country_rules", before, nil)
+	c.ResponseOK()
+}
+
+// 检查IP是否会被拦截 country为空时根据配置的IP段文件识别 用于验证规则
+func (m *Manager) check(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermSecurityRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		IP      string `json:"ip"`
+		Country string `json:"country"`
+		Scene   string `json:"scene"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	ip := net.ParseIP(strings.TrimSpace(req.IP))
+	if ip == nil {
+		c.ResponseError(errors.New("IP格式有误！"))
+		return
+	}
+	if req.Scene != SceneLogin && req.Scene != SceneRegister {
+		c.ResponseError(errors.New("场景有误！"))
+		return
+	}
+	cfg := extconfig.Get().IPGuard
+	country := normalizeCountry(req.Country)
+	if country == "" && cfg.CountryFile != "" {
+		country = countries.lookup(cfg.CountryFile, ip)
+	}
+	if !rules.isLoaded() {
+		m.reloadRules(false)
+	}
+	resp := map[string]interface{}{
+		"on":      cfg.On,
+		"country": country,
+		"blocked": false,
+	}
+	if h := rules.check(ip, country, req.Scene, time.Now().Unix(), cfg.BlockUnknownCountry); h != nil {
+		resp["blocked"] = true
+		resp["reason"] = h.reason
+		resp["rule_id"] = h.ruleID
+		resp["message"] = h.message(req.Scene)
+	}
+	c.Response(resp)
+}
+
+type blockResp struct {
+	ID        int64  `json:"id"`
+	CIDR      string `json:"cidr"`
+	Scene     string `json:"scene"`
+	Source    string `json:"source"` // manual.管理员添加 auto.异常检测自动添加
+	Reason    string `json:"reason"`
+	ExpireAt  int64  `json:"expire_at"` // 0为永久
+	Expired   bool   `json:"expired"`
+	HitCount  int64  `json:"hit_count"`
+	LastHitAt int64  `json:"last_hit_at"`
+	Creator   string `json:"creator"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func newBlockResp(m *blockModel, now int64) *blockResp {
+	return &blockResp{
+		ID:        m.Id,
+		CIDR:      m.CIDR,
+		Scene:     m.Scene,
+		Source:    m.Source,
+		Reason:    m.Reason,
+		ExpireAt:  m.ExpireAt,
+		Expired:   m.ExpireAt > 0 && m.ExpireAt <= now,
+		HitCount:  m.HitCount,
+		LastHitAt: m.LastHitAt,
+		Creator:   m.Creator,
+		CreatedAt: m.CreatedAt.String(),
+		UpdatedAt: m.UpdatedAt.String(),
+	}
+}
+
+type countryRuleResp struct {
+	ID        int64  `json:"id"`
+	Scene     string `json:"scene"`
+	Country   string `json:"country"`
+	Action    string `json:"action"`
+	HitCount  int64  `json:"hit_count"`
+	LastHitAt int64  `json:"last_hit_at"`
+	Creator   string `json:"creator"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func newCountryRuleResp(m *countryRuleModel) *countryRuleResp {
+	return &countryRuleResp{
+		ID:        m.Id,
+		Scene:     m.Scene,
+		Country:   m.Country,
+		Action:    m.Action,
+		HitCount:  m.HitCount,
+		LastHitAt: m.LastHitAt,
+		Creator:   m.Creator,
+		CreatedAt: m.CreatedAt.String(),
+		UpdatedAt: m.UpdatedAt.String(),
+	}
+}
@@ -0,0 +1,52 @@
+package ipguard
+
+// 规则生效的场景
+const (
+	SceneAll      = "all"      // 登录和注册
+	SceneLogin    = "login"    // 登录
+	SceneRegister = "register" // 注册
+)
+
+// 黑名单的来源
+const (
+	SourceManual = "manual" // 管理员添加
+	SourceAuto   = "auto"   // 异常检测自动添加
+)
+
+// 国家规则的处理
+const (
+	// CountryActionAllow 允许 场景配置了允许的国家后只有这些国家可以使用
+	CountryActionAllow = "allow"
+	// CountryActionBlock 禁止
+	CountryActionBlock = "block"
+)
+
+// 拦截的原因
+const (
+	reasonIP      = "ip"
+	reasonCountry = "country"
+)
+
+// seqKey 规则版本的序号
+const seqKey = "IPGuardRule"
+
+// cacheKeyAbuse 异常检测计数的缓存key
+const cacheKeyAbuse = "ipguardabuse:"
+
+func validScene(scene string) bool {
+	return scene == SceneAll || scene == SceneLogin || scene == SceneRegister
+}
+
+func validCountryAction(action string) bool {
+	return action == CountryActionAllow || action == CountryActionBlock
+}
+
+func sceneName(scene string) string {
+	switch scene {
+	case SceneLogin:
+		return "登录"
+	case SceneRegister:
+		return "注册"
+	}
+	return "登录和注册"
+}
@@ -0,0 +1,172 @@
+package ipguard
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
+)
+
+// unknownCountries CDN无法识别时返回的国家代码（Cloudflare的XX为未知 T1为Tor）
+var unknownCountries = map[string]bool{
+	"XX": true,
+	"T1": true,
+}
+
+// resolveCountry 请求的国家代码 优先使用CDN的请求头 然后查询IP段文件 无法识别时为空
+func resolveCountry(r *http.Request, ip net.IP, cfg extconfig.IPGuardConfig) string {
+	if cfg.CountryHeader != "" {
+		if country := normalizeCountry(r.Header.Get(cfg.CountryHeader)); country != "" && !unknownCountries[country] {
+			return country
+		}
+	}
+	if cfg.CountryFile == "" {
+		return ""
+	}
+	return countries.lookup(cfg.CountryFile, ip)
+}
+
+// countries IP段对应的国家（配置的文件）
+var countries = &countryDB{Log: log.NewTLog("IPGuardCountry")}
+
+type countryRange struct {
+	start   net.IP // 16字节
+	end     net.IP
+	country string
+}
+
+type countryDB struct {
+	log.Log
+	mu      sync.RWMutex
+	file    string
+	modTime time.Time
+	ranges  []countryRange // 按开始IP排序
+}
+
+// lookup 查询IP所在的国家 没有找到时为空
+func (d *countryDB) lookup(file string, ip net.IP) string {
+	d.reloadIfNeed(file)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return searchCountry(d.ranges, ip)
+}
+
+// reloadIfNeed 文件修改后重新加载
+func (d *countryDB) reloadIfNeed(file string) {
+	info, err := os.Stat(file)
+	if err != nil {
+		d.mu.Lock()
+		if d.file != file || !d.modTime.IsZero() {
+			d.Warn("读取IP段对应国家的文件失败！", zap.Error(err), zap.String("file", file))
+			d.file = file
+			d.modTime = time.Time{}
+			d.ranges = nil
+		}
+		d.mu.Unlock()
+		return
+	}
+	d.mu.RLock()
+	loaded := d.file == file && d.modTime.Equal(info.ModTime())
+	d.mu.RUnlock()
+	if loaded {
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		d.Warn("读取IP段对应国家的文件失败！", zap.Error(err), zap.String("file", file))
+		return
+	}
+	defer f.Close()
+	ranges, err := parseCountryRanges(f)
+	if err != nil {
+		d.Warn("读取IP段对应国家的文件失败！", zap.Error(err), zap.String("file", file))
+		return
+	}
+	d.mu.Lock()
+	d.file = file
+	d.modTime = info.ModTime()
+	d.ranges = ranges
+	d.mu.Unlock()
+	d.Info("已加载IP段对应国家的文件", zap.String("file", file), zap.Int("count", len(ranges)))
+}
+
+// parseCountryRanges 解析IP段文件 每行为 CIDR,国家代码 或 开始IP,结束IP,国家代码 格式有误的行忽略
+func parseCountryRanges(reader io.Reader) ([]countryRange, error) {
+	ranges := make([]countryRange, 0)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		for i := range fields {
+			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), `"`)
+		}
+		var r countryRange
+		switch len(fields) {
+		case 2:
+			_, network, err := net.ParseCIDR(fields[0])
+			if err != nil {
+				continue
+			}
+			r.start = network.IP.To16()
+			r.end = make(net.IP, len(r.start))
+			mask := net.IP(network.Mask)
+			if len(mask) == net.IPv4len {
+				mask = append(net.IP{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, mask...)
+			}
+			for i := range r.start {
+				r.end[i] = r.start[i] | ^mask[i]
+			}
+		case 3:
+			r.start = net.ParseIP(fields[0]).To16()
+			r.end = net.ParseIP(fields[1]).To16()
+			if r.start == nil || r.end == nil || bytes.Compare(r.start, r.end) > 0 {
+				continue
+			}
+		default:
+			continue
+		}
+		if r.country = normalizeCountry(fields[len(fields)-1]); r.country == "" {
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].start, ranges[j].start) < 0
+	})
+	return ranges, nil
+}
+
+// searchCountry 二分查找IP所在的IP段 IP段有重叠时以开始IP最大的为准
+func searchCountry(ranges []countryRange, ip net.IP) string {
+	ip = ip.To16()
+	if ip == nil {
+		return ""
+	}
+	idx := sort.Search(len(ranges), func(i int) bool {
+		return bytes.Compare(ranges[i].start, ip) > 0
+	})
+	if idx == 0 {
+		return ""
+	}
+	r := ranges[idx-1]
+	if bytes.Compare(ip, r.end) > 0 {
+		return ""
+	}
+	return r.country
+}
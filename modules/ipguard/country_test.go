@@ -0,0 +1,44 @@
+package ipguard
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCountryRanges(t *testing.T) {
+	ranges, err := parseCountryRanges(strings.NewReader(`# IP段,国家代码
+1.0.1.0/24,cn
+"1.0.2.0","1.0.3.255","CN"
+8.8.8.0/24,US
+2001:db8::/32,JP
+bad line
+9.9.9.9,10.0.0.0,XXX
+`))
+	assert.NoError(t, err)
+	assert.Len(t, ranges, 4)
+
+	assert.Equal(t, "CN", searchCountry(ranges, net.ParseIP("1.0.1.255")))
+	assert.Equal(t, "CN", searchCountry(ranges, net.ParseIP("1.0.3.1")))
+	assert.Equal(t, "", searchCountry(ranges, net.ParseIP("1.0.4.1")))
+	assert.Equal(t, "US", searchCountry(ranges, net.ParseIP("8.8.8.8")))
+	assert.Equal(t, "JP", searchCountry(ranges, net.ParseIP("2001:db8:1::1")))
+	assert.Equal(t, "", searchCountry(ranges, net.ParseIP("0.0.0.1")))
+	assert.Equal(t, "", searchCountry(nil, net.ParseIP("8.8.8.8")))
+}
+
+func TestResolveCountry(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/v1/user/login", nil)
+	req.Header.Set("CF-IPCountry", "jp")
+	ip := net.ParseIP("8.8.8.8")
+
+	assert.Equal(t, "JP", resolveCountry(req, ip, extconfig.IPGuardConfig{CountryHeader: "CF-IPCountry"}))
+	assert.Equal(t, "", resolveCountry(req, ip, extconfig.IPGuardConfig{}))
+
+	req.Header.Set("CF-IPCountry", "XX")
+	assert.Equal(t, "", resolveCountry(req, ip, extconfig.IPGuardConfig{CountryHeader: "CF-IPCountry"}))
+}
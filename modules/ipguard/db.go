@@ -0,0 +1,180 @@
+package ipguard
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// upsertBlockSQL 添加黑名单 已存在（包括已删除和已过期的）则恢复并覆盖 累计的拦截次数保留
+const upsertBlockSQL = "insert into ip_block(cidr,scene,source,reason,expire_at,creator,is_deleted,version) values(?,?,?,?,?,?,0,?) ON DUPLICATE KEY UPDATE source=VALUES(source),reason=VALUES(reason),expire_at=VALUES(expire_at),creator=VALUES(creator),is_deleted=0,version=VALUES(version)"
+
+// insertOrUpdateBlock 添加一个黑名单
+func (d *db) insertOrUpdateBlock(m *blockModel) error {
+	_, err := d.session.InsertBySql(upsertBlockSQL, m.CIDR, m.Scene, m.Source, m.Reason, m.ExpireAt, m.Creator, m.Version).Exec()
+	return err
+}
+
+func (d *db) insertOrUpdateBlocksTx(models []*blockModel, tx *dbr.Tx) error {
+	for _, m := range models {
+		_, err := tx.InsertBySql(upsertBlockSQL, m.CIDR, m.Scene, m.Source, m.Reason, m.ExpireAt, m.Creator, m.Version).Exec()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *db) deleteBlocks(ids []int64, version int64) error {
+	_, err := d.session.Update("ip_block").Set("is_deleted", 1).Set("version", version).Where("id in ? and is_deleted=0", ids).Exec()
+	return err
+}
+
+// deleteExpiredAutoBlocks 清理过期的自动封禁
+func (d *db) deleteExpiredAutoBlocks(before int64) (int64, error) {
+	result, err := d.session.DeleteFrom("ip_block").Where("source=? and expire_at>0 and expire_at<?", SourceAuto, before).Exec()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (d *db) queryBlocksWithIDs(ids []int64) ([]*blockModel, error) {
+	var models []*blockModel
+	_, err := d.session.Select("*").From("ip_block").Where("id in ? and is_deleted=0", ids).Load(&models)
+	return models, err
+}
+
+// queryActiveBlocks 查询所有未删除且未过期的黑名单
+func (d *db) queryActiveBlocks(now int64) ([]*blockModel, error) {
+	var models []*blockModel
+	_, err := d.session.Select("*").From("ip_block").Where("is_deleted=0 and (expire_at=0 or expire_at>?)", now).Load(&models)
+	return models, err
+}
+
+func (d *db) queryBlocksWithPage(filter blockFilter, pageIndex, pageSize uint64) ([]*blockModel, error) {
+	var models []*blockModel
+	_, err := d.blocksWhere(d.session.Select("*").From("ip_block"), filter).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryBlocksCount(filter blockFilter) (int64, error) {
+	var count int64
+	_, err := d.blocksWhere(d.session.Select("count(*)").From("ip_block"), filter).Load(&count)
+	return count, err
+}
+
+func (d *db) blocksWhere(builder *dbr.SelectStmt, filter blockFilter) *dbr.SelectStmt {
+	builder = builder.Where("is_deleted=0")
+	if filter.keyword != "" {
+		builder = builder.Where("(cidr like ? or reason like ?)", "%"+filter.keyword+"%", "%"+filter.keyword+"%")
+	}
+	if filter.source != "" {
+		builder = builder.Where("source=?", filter.source)
+	}
+	if filter.scene != "" {
+		builder = builder.Where("scene=?", filter.scene)
+	}
+	if filter.expired != nil {
+		if *filter.expired {
+			builder = builder.Where("expire_at>0 and expire_at<=?", filter.now)
+		} else {
+			builder = builder.Where("(expire_at=0 or expire_at>?)", filter.now)
+		}
+	}
+	return builder
+}
+
+// incrBlockHits 累加拦截次数 不修改版本
+func (d *db) incrBlockHits(id int64, count int64, lastHitAt int64) error {
+	_, err := d.session.UpdateBySql("update ip_block set hit_count=hit_count+?,last_hit_at=greatest(last_hit_at,?) where id=?", count, lastHitAt, id).Exec()
+	return err
+}
+
+// insertOrUpdateCountryRulesTx 添加国家规则 已存在（包括已删除的）则恢复并修改处理方式
+func (d *db) insertOrUpdateCountryRulesTx(models []*countryRuleModel, tx *dbr.Tx) error {
+	for _, m := range models {
+		_, err := tx.InsertBySql("insert into ip_country_rule(scene,country,action,creator,is_deleted,version) values(?,?,?,?,0,?) ON DUPLICATE KEY UPDATE action=VALUES(action),creator=VALUES(creator),is_deleted=0,version=VALUES(version)", m.Scene, m.Country, m.Action, m.Creator, m.Version).Exec()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *db) deleteCountryRules(ids []int64, version int64) error {
+	_, err := d.session.Update("ip_country_rule").Set("is_deleted", 1).Set("version", version).Where("id in ? and is_deleted=0", ids).Exec()
+	return err
+}
+
+func (d *db) queryCountryRulesWithIDs(ids []int64) ([]*countryRuleModel, error) {
+	var models []*countryRuleModel
+	_, err := d.session.Select("*").From("ip_country_rule").Where("id in ? and is_deleted=0", ids).Load(&models)
+	return models, err
+}
+
+// queryCountryRules 查询所有未删除的国家规则
+func (d *db) queryCountryRules() ([]*countryRuleModel, error) {
+	var models []*countryRuleModel
+	_, err := d.session.Select("*").From("ip_country_rule").Where("is_deleted=0").OrderDir("scene", true).OrderDir("country", true).Load(&models)
+	return models, err
+}
+
+// incrCountryRuleHits 累加拦截次数 不修改版本
+func (d *db) incrCountryRuleHits(id int64, count int64, lastHitAt int64) error {
+	_, err := d.session.UpdateBySql("update ip_country_rule set hit_count=hit_count+?,last_hit_at=greatest(last_hit_at,?) where id=?", count, lastHitAt, id).Exec()
+	return err
+}
+
+// queryMaxVersion 规则的最新版本 黑名单和国家规则的添加、修改和删除都会更新版本
+func (d *db) queryMaxVersion() (int64, error) {
+	var version int64
+	_, err := d.session.SelectBySql("select greatest((select IFNULL(max(version),0) from ip_block),(select IFNULL(max(version),0) from ip_country_rule))").Load(&version)
+	return version, err
+}
+
+type blockFilter struct {
+	keyword string
+	source  string
+	scene   string
+	expired *bool // 为空时查询全部
+	now     int64
+}
+
+type blockModel struct {
+	CIDR      string
+	Scene     string
+	Source    string
+	Reason    string
+	ExpireAt  int64
+	HitCount  int64
+	LastHitAt int64
+	Creator   string
+	IsDeleted int
+	Version   int64
+	dba.BaseModel
+}
+
+type countryRuleModel struct {
+	Scene     string
+	Country   string
+	Action    string
+	HitCount  int64
+	LastHitAt int64
+	Creator   string
+	IsDeleted int
+	Version   int64
+	dba.BaseModel
+}
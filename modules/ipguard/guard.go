@@ -0,0 +1,143 @@
+package ipguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	// blockedTotal 拦截的登录和注册请求数（按场景和原因）
+	blockedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_ipguard_blocked_total",
+		Help: "IP黑名单和国家限制拦截的请求数",
+	}, []string{"scene", "reason"})
+	// autoBlockTotal 异常检测自动封禁的IP数（按原因）
+	autoBlockTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_ipguard_auto_block_total",
+		Help: "异常检测自动封禁的IP数",
+	}, []string{"reason"})
+)
+
+// Guard 登录和注册的IP黑名单和国家限制
+type Guard struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// NewGuard NewGuard
+func NewGuard(ctx *config.Context) *Guard {
+	return &Guard{
+		ctx: ctx,
+		Log: log.NewTLog("IPGuard"),
+		db:  newDB(ctx),
+	}
+}
+
+// Middleware 检查请求的IP和国家 命中规则时拒绝请求 scene为SceneLogin或SceneRegister
+// 请求处理完后根据结果做异常检测
+func (g *Guard) Middleware(scene string) wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		cfg := extconfig.Get().IPGuard
+		if !cfg.On {
+			c.Next()
+			return
+		}
+		ip := net.ParseIP(util.GetTrustedClientIP(c.Request, cfg.TrustedProxies))
+		if ip == nil {
+			c.Next()
+			return
+		}
+		if !rules.isLoaded() {
+			// 管理模块还没有加载规则
+			if err := rules.reload(g.db, false); err != nil {
+				g.Warn("加载IP规则失败！", zap.Error(err))
+			}
+		}
+		country := resolveCountry(c.Request, ip, cfg)
+		if h := rules.check(ip, country, scene, time.Now().Unix(), cfg.BlockUnknownCountry); h != nil {
+			rules.addHit(h, time.Now().Unix())
+			blockedTotal.WithLabelValues(scene, h.reason).Inc()
+			g.Info("拦截请求", zap.String("scene", scene), zap.String("reason", h.reason), zap.String("ip", ip.String()), zap.String("country", country), zap.Int64("ruleID", h.ruleID))
+			c.ResponseErrorWithStatus(errors.New(h.message(scene)), http.StatusForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+		g.detectAbuse(ip, scene, c.Writer.Status(), cfg)
+	}
+}
+
+// detectAbuse 异常检测 同一IP在时间窗口内失败或注册的次数达到限制时自动封禁一段时间
+// 内网和本机IP不检测 避免没有配置X-Forwarded-For时封禁了代理的IP
+func (g *Guard) detectAbuse(ip net.IP, scene string, status int, cfg extconfig.IPGuardConfig) {
+	if cfg.AutoBlockDuration <= 0 || cfg.AutoBlockWindow < time.Second || ip.IsLoopback() || ip.IsPrivate() {
+		return
+	}
+	var kind string
+	var limit int
+	switch {
+	case status == http.StatusBadRequest:
+		kind, limit = "failure", cfg.AutoBlockFailures
+	case status == http.StatusOK && scene == SceneRegister:
+		kind, limit = "register", cfg.AutoBlockRegisters
+	}
+	if limit <= 0 {
+		return
+	}
+	window := int64(cfg.AutoBlockWindow / time.Second)
+	key := fmt.Sprintf("%s%s:%d:%s", cacheKeyAbuse, kind, time.Now().Unix()/window, ip.String())
+	count, err := g.ctx.GetRedisConn().Incr(key)
+	if err != nil {
+		g.Warn("异常检测计数失败！", zap.Error(err), zap.String("key", key))
+		return
+	}
+	if count == 1 {
+		_ = g.ctx.GetRedisConn().Expire(key, cfg.AutoBlockWindow)
+	}
+	// 只在达到限制时封禁一次 封禁后的请求会被拦截
+	if count != int64(limit) {
+		return
+	}
+	var reason string
+	if kind == "register" {
+		reason = fmt.Sprintf("%s内注册了%d个账号", cfg.AutoBlockWindow, count)
+	} else {
+		reason = fmt.Sprintf("%s内%s失败%d次", cfg.AutoBlockWindow, sceneName(scene), count)
+	}
+	g.autoBlock(ip, kind, reason, cfg.AutoBlockDuration)
+}
+
+// autoBlock 自动封禁IP 登录和注册都拦截
+func (g *Guard) autoBlock(ip net.IP, kind string, reason string, duration time.Duration) {
+	cidr := singleIPCIDR(ip)
+	err := g.db.insertOrUpdateBlock(&blockModel{
+		CIDR:     cidr,
+		Scene:    SceneAll,
+		Source:   SourceAuto,
+		Reason:   reason,
+		ExpireAt: time.Now().Add(duration).Unix(),
+		Version:  g.ctx.GenSeq(seqKey),
+	})
+	if err != nil {
+		g.Error("自动封禁IP失败！", zap.Error(err), zap.String("cidr", cidr))
+		return
+	}
+	autoBlockTotal.WithLabelValues(kind).Inc()
+	g.Warn("自动封禁IP", zap.String("cidr", cidr), zap.String("reason", reason), zap.Duration("duration", duration))
+	if err = rules.reload(g.db, false); err != nil {
+		g.Warn("加载IP规则失败！", zap.Error(err))
+	}
+}
@@ -0,0 +1,266 @@
+package ipguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// blockRule 内存中的黑名单
+type blockRule struct {
+	id       int64
+	network  *net.IPNet
+	scene    string
+	expireAt int64 // 0为永久
+}
+
+func (b *blockRule) active(now int64) bool {
+	return b.expireAt == 0 || b.expireAt > now
+}
+
+// countryRules 某个场景的国家规则 国家代码 => 规则id
+type countryRules struct {
+	allow map[string]int64
+	block map[string]int64
+}
+
+// hit 拦截结果
+type hit struct {
+	reason  string // ip or country
+	ruleID  int64  // 命中的规则 国家不在允许的列表中时为0
+	country string
+}
+
+// message 返回给客户端的提示
+func (h *hit) message(scene string) string {
+	if h.reason == reasonCountry {
+		return fmt.Sprintf("当前地区暂不支持%s！", sceneName(scene))
+	}
+	return fmt.Sprintf("当前网络环境存在风险，暂时无法%s！", sceneName(scene))
+}
+
+// ruleSet 内存中的规则 管理后台修改规则后各实例定时检查版本重新加载
+type ruleSet struct {
+	lock      sync.RWMutex
+	blocks    []*blockRule
+	countries map[string]*countryRules // 场景 => 国家规则
+	version   int64
+	loaded    bool
+
+	hitLock     sync.Mutex
+	blockHits   map[int64]int64 // 还没有写入数据库的拦截次数
+	countryHits map[int64]int64
+	lastHitAt   int64
+}
+
+// rules 所有Guard共用的规则
+var rules = newRuleSet()
+
+func newRuleSet() *ruleSet {
+	return &ruleSet{
+		countries:   map[string]*countryRules{},
+		blockHits:   map[int64]int64{},
+		countryHits: map[int64]int64{},
+	}
+}
+
+// reload 规则版本变化后重新加载 force为true时不比较版本
+func (r *ruleSet) reload(d *db, force bool) error {
+	version, err := d.queryMaxVersion()
+	if err != nil {
+		return err
+	}
+	r.lock.RLock()
+	unchanged := r.loaded && r.version == version
+	r.lock.RUnlock()
+	if unchanged && !force {
+		return nil
+	}
+	blocks, err := d.queryActiveBlocks(time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	countries, err := d.queryCountryRules()
+	if err != nil {
+		return err
+	}
+	r.set(blocks, countries, version)
+	return nil
+}
+
+func (r *ruleSet) set(blocks []*blockModel, countries []*countryRuleModel, version int64) {
+	blockRules := make([]*blockRule, 0, len(blocks))
+	for _, m := range blocks {
+		_, network, err := net.ParseCIDR(m.CIDR)
+		if err != nil {
+			continue
+		}
+		blockRules = append(blockRules, &blockRule{
+			id:       m.Id,
+			network:  network,
+			scene:    m.Scene,
+			expireAt: m.ExpireAt,
+		})
+	}
+	countryMap := map[string]*countryRules{}
+	for _, m := range countries {
+		rs := countryMap[m.Scene]
+		if rs == nil {
+			rs = &countryRules{allow: map[string]int64{}, block: map[string]int64{}}
+			countryMap[m.Scene] = rs
+		}
+		if m.Action == CountryActionAllow {
+			rs.allow[m.Country] = m.Id
+		} else {
+			rs.block[m.Country] = m.Id
+		}
+	}
+
+	r.lock.Lock()
+	r.blocks = blockRules
+	r.countries = countryMap
+	r.version = version
+	r.loaded = true
+	r.lock.Unlock()
+}
+
+func (r *ruleSet) isLoaded() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.loaded
+}
+
+// check 检查IP和国家 没有命中时返回nil
+// 先检查IP黑名单 然后检查禁止的国家 场景配置了允许的国家时不在其中的国家也拦截
+// 无法识别国家时只有blockUnknown为true且配置了允许的国家才拦截
+func (r *ruleSet) check(ip net.IP, country string, scene string, now int64, blockUnknown bool) *hit {
+	r.lock.RLock()
+	blocks := r.blocks
+	countries := r.countries
+	r.lock.RUnlock()
+
+	for _, b := range blocks {
+		if (b.scene == SceneAll || b.scene == scene) && b.active(now) && b.network.Contains(ip) {
+			return &hit{reason: reasonIP, ruleID: b.id, country: country}
+		}
+	}
+
+	hasAllow := false
+	for _, s := range []string{scene, SceneAll} {
+		rs := countries[s]
+		if rs == nil {
+			continue
+		}
+		if country != "" {
+			if id, ok := rs.block[country]; ok {
+				return &hit{reason: reasonCountry, ruleID: id, country: country}
+			}
+			if _, ok := rs.allow[country]; ok {
+				return nil
+			}
+		}
+		if len(rs.allow) > 0 {
+			hasAllow = true
+		}
+	}
+	if hasAllow && (country != "" || blockUnknown) {
+		return &hit{reason: reasonCountry, country: country}
+	}
+	return nil
+}
+
+// addHit 记录拦截次数 定时写入数据库
+func (r *ruleSet) addHit(h *hit, now int64) {
+	if h.ruleID <= 0 {
+		return
+	}
+	r.hitLock.Lock()
+	if h.reason == reasonIP {
+		r.blockHits[h.ruleID]++
+	} else {
+		r.countryHits[h.ruleID]++
+	}
+	r.lastHitAt = now
+	r.hitLock.Unlock()
+}
+
+// takeHits 取出还没有写入数据库的拦截次数
+func (r *ruleSet) takeHits() (blockHits map[int64]int64, countryHits map[int64]int64, lastHitAt int64) {
+	r.hitLock.Lock()
+	defer r.hitLock.Unlock()
+	blockHits, countryHits, lastHitAt = r.blockHits, r.countryHits, r.lastHitAt
+	r.blockHits = map[int64]int64{}
+	r.countryHits = map[int64]int64{}
+	return
+}
+
+// flushHits 把拦截次数写入数据库 写入失败的次数丢弃
+func (r *ruleSet) flushHits(d *db) error {
+	blockHits, countryHits, lastHitAt := r.takeHits()
+	var lastErr error
+	for id, count := range blockHits {
+		if err := d.incrBlockHits(id, count, lastHitAt); err != nil {
+			lastErr = err
+		}
+	}
+	for id, count := range countryHits {
+		if err := d.incrCountryRuleHits(id, count, lastHitAt); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// 拦截所有IP的网段 防止误操作
+const (
+	minIPv4Prefix = 8
+	minIPv6Prefix = 16
+)
+
+// normalizeCIDR 转为标准的CIDR格式 单个IP转为/32或/128
+func normalizeCIDR(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", errors.New("IP不能为空！")
+	}
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", fmt.Errorf("IP[%s]格式有误！", value)
+		}
+		return singleIPCIDR(ip), nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return "", fmt.Errorf("IP段[%s]格式有误！", value)
+	}
+	ones, bits := network.Mask.Size()
+	if (bits == 32 && ones < minIPv4Prefix) || (bits == 128 && ones < minIPv6Prefix) {
+		return "", fmt.Errorf("IP段[%s]的范围过大！", value)
+	}
+	return network.String(), nil
+}
+
+func singleIPCIDR(ip net.IP) string {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+// normalizeCountry 国家代码转为大写 只支持两位的国家代码
+func normalizeCountry(country string) string {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if len(country) != 2 {
+		return ""
+	}
+	for _, c := range country {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+	}
+	return country
+}
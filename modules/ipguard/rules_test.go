@@ -0,0 +1,86 @@
+package ipguard
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCIDR(t *testing.T) {
+	cidr, err := normalizeCIDR(" 1.2.3.4 ")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4/32", cidr)
+
+	cidr, err = normalizeCIDR("1.2.3.5/24")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.0/24", cidr)
+
+	cidr, err = normalizeCIDR("2001:db8::1")
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::1/128", cidr)
+
+	_, err = normalizeCIDR("0.0.0.0/0")
+	assert.Error(t, err)
+	_, err = normalizeCIDR("1.2.3")
+	assert.Error(t, err)
+	_, err = normalizeCIDR("")
+	assert.Error(t, err)
+}
+
+func TestRuleSetCheck(t *testing.T) {
+	r := newRuleSet()
+	r.set([]*blockModel{
+		{CIDR: "10.0.0.0/8", Scene: SceneAll},
+		{CIDR: "1.2.3.0/24", Scene: SceneRegister},
+		{CIDR: "5.6.7.8/32", Scene: SceneAll, ExpireAt: 100},
+	}, []*countryRuleModel{
+		{Scene: SceneAll, Country: "KP", Action: CountryActionBlock},
+		{Scene: SceneRegister, Country: "CN", Action: CountryActionAllow},
+		{Scene: SceneRegister, Country: "HK", Action: CountryActionAllow},
+	}, 1)
+	r.blocks[0].id, r.blocks[1].id = 1, 2
+
+	h := r.check(net.ParseIP("10.1.2.3"), "CN", SceneLogin, 50, false)
+	assert.Equal(t, reasonIP, h.reason)
+	assert.Equal(t, int64(1), h.ruleID)
+
+	// 只在注册时拦截
+	assert.Nil(t, r.check(net.ParseIP("1.2.3.4"), "", SceneLogin, 50, false))
+	assert.NotNil(t, r.check(net.ParseIP("1.2.3.4"), "CN", SceneRegister, 50, false))
+
+	// 过期后不再拦截
+	assert.NotNil(t, r.check(net.ParseIP("5.6.7.8"), "", SceneLogin, 50, false))
+	assert.Nil(t, r.check(net.ParseIP("5.6.7.8"), "", SceneLogin, 100, false))
+
+	// 禁止的国家
+	h = r.check(net.ParseIP("8.8.8.8"), "KP", SceneLogin, 50, false)
+	assert.Equal(t, reasonCountry, h.reason)
+
+	// 注册只允许配置的国家
+	assert.Nil(t, r.check(net.ParseIP("8.8.8.8"), "HK", SceneRegister, 50, false))
+	assert.NotNil(t, r.check(net.ParseIP("8.8.8.8"), "US", SceneRegister, 50, false))
+	assert.Nil(t, r.check(net.ParseIP("8.8.8.8"), "US", SceneLogin, 50, false))
+
+	// 无法识别国家
+	assert.Nil(t, r.check(net.ParseIP("8.8.8.8"), "", SceneRegister, 50, false))
+	assert.NotNil(t, r.check(net.ParseIP("8.8.8.8"), "", SceneRegister, 50, true))
+	assert.Nil(t, r.check(net.ParseIP("8.8.8.8"), "", SceneLogin, 50, true))
+}
+
+func TestRuleSetHits(t *testing.T) {
+	r := newRuleSet()
+	r.addHit(&hit{reason: reasonIP, ruleID: 1}, 10)
+	r.addHit(&hit{reason: reasonIP, ruleID: 1}, 11)
+	r.addHit(&hit{reason: reasonCountry, ruleID: 2}, 12)
+	r.addHit(&hit{reason: reasonCountry}, 13)
+
+	blockHits, countryHits, lastHitAt := r.takeHits()
+	assert.Equal(t, map[int64]int64{1: 2}, blockHits)
+	assert.Equal(t, map[int64]int64{2: 1}, countryHits)
+	assert.Equal(t, int64(12), lastHitAt)
+
+	blockHits, countryHits, _ = r.takeHits()
+	assert.Empty(t, blockHits)
+	assert.Empty(t, countryHits)
+}
@@ -0,0 +1,40 @@
+-- +migrate Up
+
+-- ##########  IP黑名单 ##########
+create table `ip_block`
+(
+    id          integer      not null primary key AUTO_INCREMENT,
+    cidr        VARCHAR(50)  NOT NULL DEFAULT '' COMMENT 'IP段（CIDR格式） 单个IP为/32或/128',
+    scene       VARCHAR(20)  NOT NULL DEFAULT 'all' COMMENT '生效的场景 all.登录和注册 login.登录 register.注册',
+    source      VARCHAR(20)  NOT NULL DEFAULT 'manual' COMMENT '来源 manual.管理员添加 auto.异常检测自动添加',
+    reason      VARCHAR(200) NOT NULL DEFAULT '' COMMENT '封禁原因',
+    expire_at   bigint       NOT NULL DEFAULT 0  COMMENT '过期时间（时间戳秒） 0为永久',
+    hit_count   bigint       NOT NULL DEFAULT 0  COMMENT '拦截次数',
+    last_hit_at bigint       NOT NULL DEFAULT 0  COMMENT '最后一次拦截的时间（时间戳秒）',
+    creator     VARCHAR(40)  NOT NULL DEFAULT '' COMMENT '添加的管理员uid 自动添加时为空',
+    is_deleted  smallint     NOT NULL DEFAULT 0  COMMENT '是否已删除',
+    version     bigint       NOT NULL DEFAULT 0  COMMENT '数据版本 规则变化后重新加载',
+    created_at  timeStamp    not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at  timeStamp    not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX ip_block_uidx on `ip_block` (cidr, scene);
+CREATE INDEX ip_block_version_idx on `ip_block` (version);
+CREATE INDEX ip_block_expire_idx on `ip_block` (source, expire_at);
+
+-- ##########  国家限制 ##########
+create table `ip_country_rule`
+(
+    id          integer      not null primary key AUTO_INCREMENT,
+    scene       VARCHAR(20)  NOT NULL DEFAULT 'all' COMMENT '生效的场景 all.登录和注册 login.登录 register.注册',
+    country     VARCHAR(10)  NOT NULL DEFAULT '' COMMENT '国家代码（ISO 3166-1 alpha-2） 例如 CN',
+    action      VARCHAR(20)  NOT NULL DEFAULT 'block' COMMENT '处理 allow.只允许这些国家 block.禁止',
+    hit_count   bigint       NOT NULL DEFAULT 0  COMMENT '拦截次数',
+    last_hit_at bigint       NOT NULL DEFAULT 0  COMMENT '最后一次拦截的时间（时间戳秒）',
+    creator     VARCHAR(40)  NOT NULL DEFAULT '' COMMENT '添加的管理员uid',
+    is_deleted  smallint     NOT NULL DEFAULT 0  COMMENT '是否已删除',
+    version     bigint       NOT NULL DEFAULT 0  COMMENT '数据版本 规则变化后重新加载',
+    created_at  timeStamp    not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at  timeStamp    not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX ip_country_rule_uidx on `ip_country_rule` (scene, country);
+CREATE INDEX ip_country_rule_version_idx on `ip_country_rule` (version);
@@ -0,0 +1,26 @@
+package job
+
+import (
+	_ "embed"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+)
+
+//go:embed swagger/api.yaml
+var swaggerContent string
+
+func init() {
+
+	// 后台任务队列的管理
+	register.AddModule(func(ctx interface{}) register.Module {
+
+		return register.Module{
+			Name: "job",
+			SetupAPI: func() register.APIRouter {
+				return NewManager(ctx.(*config.Context))
+			},
+			Swagger: swaggerContent,
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package job
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/jobqueue"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Manager 后台任务队列 查看队列的任务数 重试或删除失败的任务
+type Manager struct {
+	ctx *config.Context
+	log.Log
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	return &Manager{
+		ctx: ctx,
+		Log: log.NewTLog("JobManager"),
+	}
+}
+
+// Route 路由配置
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/jobs/queues", m.queues)    // 所有队列的任务数
+		auth.GET("/jobs/failed", m.failed)    // 队列中失败的任务
+		auth.POST("/jobs/:id/retry", m.retry) // 重新执行失败的任务
+		auth.DELETE("/jobs/:id", m.delete)    // 删除失败的任务
+	}
+}
+
+func (m *Manager) queues(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	q, ok := m.queue(c)
+	if !ok {
+		return
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		m.Error("查询任务队列失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询任务队列失败！"))
+		return
+	}
+	list := make([]*queueResp, 0, len(stats))
+	for _, s := range stats {
+		list = append(list, &queueResp{
+			Queue:     s.Queue,
+			Pending:   s.Pending,
+			Scheduled: s.Scheduled,
+			Active:    s.Active,
+			Failed:    s.Failed,
+		})
+	}
+	c.Response(list)
+}
+
+func (m *Manager) failed(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	queue := strings.TrimSpace(c.Query("queue"))
+	if queue == "" {
+		c.ResponseError(errors.New("队列不能为空"))
+		return
+	}
+	q, ok := m.queue(c)
+	if !ok {
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	jobs, count, err := q.FailedJobs(queue, int64((pageIndex-1)*pageSize), int64(pageSize))
+	if err != nil {
+		m.Error("查询失败的任务失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询失败的任务失败！"))
+		return
+	}
+	list := make([]*jobResp, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, newJobResp(job))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+func (m *Manager) retry(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	q, ok := m.queue(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	if err := q.RetryFailed(id); err != nil {
+		if errors.Is(err, jobqueue.ErrJobNotFound) {
+			c.ResponseError(errors.New("任务不存在或不是失败的任务"))
+			return
+		}
+		m.Error("重试任务失败！", zap.Error(err), zap.String("id", id))
+		c.ResponseError(errors.New("重试任务失败！"))
+		return
+	}
+	audit.SetChange(c, "job="+id, "failed", "pending")
+	c.ResponseOK()
+}
+
+func (m *Manager) delete(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	q, ok := m.queue(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	if err := q.DeleteFailed(id); err != nil {
+		if errors.Is(err, jobqueue.ErrJobNotFound) {
+			c.ResponseError(errors.New("任务不存在或不是失败的任务"))
+			return
+		}
+		m.Error("删除任务失败！", zap.Error(err), zap.String("id", id))
+		c.ResponseError(errors.New("删除任务失败！"))
+		return
+	}
+	audit.SetChange(c, "job="+id, "failed", "deleted")
+	c.ResponseOK()
+}
+
+func (m *Manager) queue(c *wkhttp.Context) (*jobqueue.Queue, bool) {
+	q := jobqueue.Get()
+	if q == nil {
+		c.ResponseError(jobqueue.ErrNotConfigured)
+		return nil, false
+	}
+	return q, true
+}
+
+type queueResp struct {
+	Queue     string `json:"queue"`     // 队列名
+	Pending   int64  `json:"pending"`   // 等待执行
+	Scheduled int64  `json:"scheduled"` // 延迟执行和等待重试
+	Active    int64  `json:"active"`    // 执行中
+	Failed    int64  `json:"failed"`    // 重试次数用完
+}
+
+type jobResp struct {
+	ID        string `json:"id"`
+	Queue     string `json:"queue"`
+	Type      string `json:"type"`       // 任务类型
+	Payload   string `json:"payload"`    // 任务参数（JSON）
+	State     string `json:"state"`      // 状态 pending scheduled active failed
+	Retried   int    `json:"retried"`    // 已重试的次数
+	MaxRetry  int    `json:"max_retry"`  // 最多重试的次数
+	LastError string `json:"last_error"` // 最后一次失败的原因
+	CreatedAt int64  `json:"created_at"` // 创建时间
+	ProcessAt int64  `json:"process_at"` // 最后一次执行的时间
+	FailedAt  int64  `json:"failed_at"`  // 失败的时间
+}
+
+func newJobResp(job *jobqueue.Job) *jobResp {
+	return &jobResp{
+		ID:        job.ID,
+		Queue:     job.Queue,
+		Type:      job.Type,
+		Payload:   string(job.Payload),
+		State:     job.State,
+		Retried:   job.Retried,
+		MaxRetry:  job.MaxRetry,
+		LastError: job.LastError,
+		CreatedAt: job.CreatedAt,
+		ProcessAt: job.ProcessAt,
+		FailedAt:  job.FailedAt,
+	}
+}
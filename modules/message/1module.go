@@ -3,8 +3,10 @@ package message
 import (
 	"embed"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rpcapi"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+	"google.golang.org/grpc"
 )
 
 //go:embed sql
@@ -20,10 +22,14 @@ func init() {
 
 	register.AddModule(func(ctx interface{}) register.Module {
 
+		api := New(ctx.(*config.Context))
+		rpcapi.Register(func(s grpc.ServiceRegistrar) {
+			rpcapi.RegisterMessageServiceServer(s, newMessageRPC(api))
+		})
 		return register.Module{
 			Name: "message",
 			SetupAPI: func() register.APIRouter {
-				return New(ctx.(*config.Context))
+				return api
 			},
 			SQLDir:  register.NewSQLFS(sqlFS),
 			Swagger: swaggerContent,
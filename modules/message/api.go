@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
@@ -16,7 +17,12 @@ import (
 	commonapi "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/sensitive"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/cursor"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/jobqueue"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -51,13 +57,33 @@ type Message struct {
 	commonService       commonapi.IService
 	fileService         file.IService
 	channelService      chservice.IService
+	sensitiveService    sensitive.IService
 	mutex               sync.Mutex
+	extraShardDB        *extraShardDB
+	purging             atomic.Bool // 是否正在删除解散群的消息
+	migratingExtra      atomic.Bool // 是否正在迁移消息扩展到分表
 }
 
 // New New
 func New(ctx *config.Context) *Message {
+	m := newMessage(ctx)
+	m.ctx.AddEventListener(event.GroupMemberAdd, m.handleGroupMemberAddEvent)
+	m.ctx.Schedule(extconfig.Get().GroupDissolve.PurgeInterval, m.purgeDissolvedGroupMessages) // 删除解散群保留时长到期的消息
+	jobqueue.Register(jobQueueCleanup, JobTypePurgeDissolvedGroup, m.handlePurgeDissolvedGroupJob)
+	if err := m.setupExtraShard(); err != nil {
+		// 分表配置有误时继续运行会把数据写到错误的表中
+		panic(fmt.Sprintf("消息扩展分表失败！%v", err))
+	}
+	if extraTableCount() > 1 {
+		m.ctx.Schedule(extconfig.Get().MessageExtraShard.MigrateInterval, m.migrateExtraShard) // 把原表的消息扩展迁移到分表
+	}
+	return m
+}
 
-	m := &Message{
+// newMessage 不注册事件监听 供Service使用
+func newMessage(ctx *config.Context) *Message {
+
+	return &Message{
 
 		ctx:                 ctx,
 		Log:                 log.NewTLog("Message"),
@@ -74,13 +100,13 @@ func New(ctx *config.Context) *Message {
 		deviceOffsetDB:      newDeviceOffsetDB(ctx.DB()),
 		remindersDB:         newRemindersDB(ctx),
 		pinnedDB:            newPinnedDB(ctx),
+		extraShardDB:        newExtraShardDB(ctx),
 		userService:         user.NewService(ctx),
 		commonService:       commonapi.NewService(ctx),
 		fileService:         file.NewService(ctx),
 		channelService:      channel.NewService(ctx),
+		sensitiveService:    sensitive.NewService(ctx),
 	}
-	m.ctx.AddEventListener(event.GroupMemberAdd, m.handleGroupMemberAddEvent)
-	return m
 }
 
 // Route 路由配置
@@ -123,6 +149,10 @@ func (m *Message) Route(r *wkhttp.WKHttp) {
 	{
 		reaction.POST("/sync", m.syncReaction)
 	}
+	channel := r.Group("/v1/channel", m.ctx.AuthMiddleware(r))
+	{
+		channel.POST("/sync-extra", m.syncChannelExtra) // 批量同步频道扩展数据
+	}
 	msg := r.Group("/v1/message")
 	{
 		msg.POST("/send", m.sendMsg) // 代发消息
@@ -213,6 +243,15 @@ func (m *Message) sendMsg(c *wkhttp.Context) {
 			return
 		}
 	}
+	if content, ok := sensitiveTextContent(req.Payload); ok {
+		result := m.sensitiveService.Check(content)
+		if result.Blocked() {
+			c.ResponseError(errors.New("消息包含敏感词"))
+			return
+		}
+		// 替换后的正文不会再被消息监听替换 review的词由消息监听记录
+		req.Payload["content"] = result.Text
+	}
 	err = m.sendMessage(req.ReceiveChannelID, req.ReceiveChannelType, uid, req.Payload)
 	if err != nil {
 		c.ResponseError(err)
@@ -260,22 +299,32 @@ func (m *Message) messageEdit(c *wkhttp.Context) {
 		return
 	}
 	if req.ChannelID == "" {
-		c.ResponseError(errors.New("频道ID不能为空！"))
+		c.ResponseError(errcode.ErrChannelIDRequired)
 		return
 	}
-	contentEdit := dbr.NewNullString(req.ContentEdit).String
-	contentMD5 := util.MD5(contentEdit)
+	if err := m.editMessageContent(c.GetLoginUID(), req.ChannelID, req.ChannelType, req.MessageID, req.MessageSeq, req.ContentEdit); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
 
-	exist, err := m.messageExtraDB.existContentEdit(req.MessageID, contentMD5)
+// editMessageContent 保存编辑后的正文并通知频道同步 个人频道的channelID为对方uid
+func (m *Message) editMessageContent(fromUID string, channelID string, channelType uint8, messageID string, messageSeq uint32, contentEdit string) error {
+	contentMD5 := util.MD5(dbr.NewNullString(contentEdit).String)
+
+	fakeChannelID := channelID
+	if channelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(fromUID, channelID)
+	}
+	exist, err := m.messageExtraDB.existContentEdit(fakeChannelID, messageID, contentMD5)
 	if err != nil {
 		m.Error("查询是否存在相同正文失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询是否存在相同正文失败！"))
-		return
+		return errors.New("查询是否存在相同正文失败！")
 	}
 	if exist {
 		m.Warn("存在相同编辑正文，不再处理！")
-		c.ResponseOK()
-		return
+		return nil
 	}
 
 	tx, _ := m.db.session.Begin()
@@ -285,64 +334,57 @@ func (m *Message) messageEdit(c *wkhttp.Context) {
 			panic(err)
 		}
 	}()
-	fakeChannelID := req.ChannelID
-	if req.ChannelType == common.ChannelTypePerson.Uint8() {
-		fakeChannelID = common.GetFakeChannelIDWith(c.GetLoginUID(), req.ChannelID)
-	}
 
 	version := m.genMessageExtraSeq(fakeChannelID)
 	err = m.messageExtraDB.insertOrUpdateContentEditTx(&messageExtraModel{
-		MessageID:       req.MessageID,
-		MessageSeq:      req.MessageSeq,
+		MessageID:       messageID,
+		MessageSeq:      messageSeq,
 		ChannelID:       fakeChannelID,
-		ChannelType:     req.ChannelType,
-		ContentEdit:     dbr.NewNullString(req.ContentEdit),
+		ChannelType:     channelType,
+		ContentEdit:     dbr.NewNullString(contentEdit),
 		ContentEditHash: contentMD5,
 		EditedAt:        int(time.Now().Unix()),
 		Version:         version,
 	}, tx)
 	if err != nil {
+		tx.Rollback()
 		m.Error("添加或修改编辑内容失败！", zap.Error(err))
-		c.ResponseError(errors.New("添加或修改编辑内容失败！"))
-		return
+		return errors.New("添加或修改编辑内容失败！")
 	}
 	msgIds := make([]string, 0)
-	msgIds = append(msgIds, req.MessageID)
+	msgIds = append(msgIds, messageID)
 	// 发布编辑事件
 	eventID, err := m.ctx.EventBegin(&wkevent.Data{
 		Event: event.EventUpdateSearchMessage,
 		Data: &config.UpdateSearchMessageReq{
 			MessageIDs: msgIds,
-			ChannelID:  req.ChannelID,
+			ChannelID:  channelID,
 		},
 		Type: wkevent.None,
 	}, tx)
 	if err != nil {
 		tx.Rollback()
 		m.Error("开启事件失败！", zap.Error(err))
-		c.ResponseError(errors.New("开启事件失败！"))
-		return
+		return errors.New("开启事件失败！")
 	}
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
-		c.ResponseErrorf("事务提交失败！", err)
-		return
+		m.Error("事务提交失败！", zap.Error(err))
+		return errors.New("事务提交失败！")
 	}
 	m.ctx.EventCommit(eventID)
 	err = m.ctx.SendCMD(config.MsgCMDReq{
 		NoPersist:   true,
-		ChannelID:   req.ChannelID,
-		ChannelType: req.ChannelType,
-		FromUID:     c.GetLoginUID(),
+		ChannelID:   channelID,
+		ChannelType: channelType,
+		FromUID:     fromUID,
 		CMD:         common.CMDSyncMessageExtra,
 	})
-
 	if err != nil {
 		m.Error("发送cmd失败！", zap.Error(err))
-		c.ResponseError(err)
-		return
+		return err
 	}
-	c.ResponseOK()
+	return nil
 }
 
 // 消息已读
@@ -591,61 +633,104 @@ func (m *Message) setMessageExtraVersion(uid, channelID string, channelType uint
 	return nil
 }
 
+// syncedExtraVersion 本次同步消息扩展的起始版本 客户端传的版本比缓存的大时更新缓存
+func (m *Message) syncedExtraVersion(uid, source, fakeChannelID string, channelType uint8, extraVersion int64) (int64, error) {
+	cacheExtraVersion, err := m.getMessageExtraVersion(uid, source, fakeChannelID, channelType)
+	if err != nil {
+		return 0, err
+	}
+	if cacheExtraVersion >= extraVersion {
+		return cacheExtraVersion, nil
+	}
+	err = m.setMessageExtraVersion(uid, fakeChannelID, channelType, source, extraVersion)
+	if err != nil {
+		return 0, err
+	}
+	return extraVersion, nil
+}
+
 // 同步扩展消息数据
+// 带cursor字段时按游标分页返回 游标为上一页最后一条扩展的版本号
 func (m *Message) syncMessageExtra(c *wkhttp.Context) {
 	var req struct {
-		ChannelID    string `json:"channel_id"`
-		ChannelType  uint8  `json:"channel_type"`
-		ExtraVersion int64  `json:"extra_version"`
-		Source       string `json:"source"` // 操作源
-		Limit        int    `json:"limit"`  // 数据限制
+		ChannelID    string  `json:"channel_id"`
+		ChannelType  uint8   `json:"channel_type"`
+		ExtraVersion int64   `json:"extra_version"`
+		Source       string  `json:"source"` // 操作源
+		Limit        int     `json:"limit"`  // 数据限制
+		Cursor       *string `json:"cursor"` // 游标 第一页传空字符串
 	}
 	if err := c.BindJSON(&req); err != nil {
 		c.ResponseErrorf("数据格式有误！", err)
 		return
 	}
+	var page cursor.Page
+	if req.Cursor != nil {
+		var err error
+		page, err = cursor.Parse(*req.Cursor, req.Limit, extraSyncDefaultLimit, extraSyncMaxCursorLimit)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		if !page.First() {
+			req.ExtraVersion = page.Key(0)
+		}
+	}
 	fakeChannelID := req.ChannelID
 	if req.ChannelType == common.ChannelTypePerson.Uint8() {
 		fakeChannelID = common.GetFakeChannelIDWith(c.GetLoginUID(), req.ChannelID)
 	}
-	cacheExtraVersion, err := m.getMessageExtraVersion(c.GetLoginUID(), req.Source, fakeChannelID, req.ChannelType)
+	extraVersion, err := m.syncedExtraVersion(c.GetLoginUID(), req.Source, fakeChannelID, req.ChannelType, req.ExtraVersion)
 	if err != nil {
-		c.ResponseErrorf("从缓存中获取消息扩展版本失败！", err)
+		c.ResponseErrorf("获取消息扩展版本失败！", err)
 		return
 	}
-	extraVersion := req.ExtraVersion
-	if cacheExtraVersion >= extraVersion {
-		extraVersion = cacheExtraVersion
-	} else {
-		err = m.setMessageExtraVersion(c.GetLoginUID(), fakeChannelID, req.ChannelType, req.Source, extraVersion)
-		if err != nil {
-			c.ResponseErrorf("缓存最大的消息扩展版本失败！", err)
-			return
-		}
-
-	}
-	limit := req.Limit
+	limit := uint64(req.Limit)
 	if limit <= 0 {
-		limit = 100
+		limit = extraSyncDefaultLimit
+	}
+	if limit > extraSyncMaxLimit {
+		limit = extraSyncMaxLimit
 	}
-	if limit > 10000 {
-		limit = 10000
+	if req.Cursor != nil {
+		limit = page.FetchLimit()
 	}
 	if strings.TrimSpace(req.ChannelID) == "" {
-		c.ResponseError(errors.New("频道ID不能为空！"))
+		c.ResponseError(errcode.ErrChannelIDRequired)
 		return
 	}
-	extraModels, err := m.messageExtraDB.sync(extraVersion, fakeChannelID, req.ChannelType, uint64(limit), c.GetLoginUID())
+	extraModels, err := m.messageExtraDB.sync(extraVersion, fakeChannelID, req.ChannelType, limit, c.GetLoginUID())
 	if err != nil {
 		c.ResponseErrorf("同步消息扩展数据失败！", err)
 		return
 	}
+	hasMore := false
+	if req.Cursor != nil {
+		if extraVersion == 0 {
+			// 版本号为0时返回的是最新的数据 不需要再往前翻页
+			if len(extraModels) > page.Limit {
+				extraModels = extraModels[len(extraModels)-page.Limit:]
+			}
+		} else {
+			var n int
+			n, hasMore = page.Keep(len(extraModels))
+			extraModels = extraModels[:n]
+		}
+	}
 	resps := make([]*messageExtraResp, 0, len(extraModels))
 	if len(extraModels) > 0 {
 		for _, extraModel := range extraModels {
 			resps = append(resps, newMessageExtraResp(extraModel))
 		}
 	}
+	if req.Cursor != nil {
+		var lastVersion int64
+		if len(extraModels) > 0 {
+			lastVersion = extraModels[len(extraModels)-1].Version
+		}
+		c.Response(cursor.NewResult(resps, hasMore, lastVersion))
+		return
+	}
 	c.Response(resps)
 }
 
@@ -654,7 +739,7 @@ func (m *Message) syncChannelMessage(c *wkhttp.Context) {
 	var req config.SyncChannelMessageReq
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 
@@ -700,7 +785,11 @@ func (m *Message) syncChannelMessage(c *wkhttp.Context) {
 	if len(channelSettings) > 0 && channelSettings[0].OffsetMessageSeq > 0 {
 		channelOffsetMessageSeq = channelSettings[0].OffsetMessageSeq
 	}
-	c.Response(newSyncChannelMessageResp(resp, c.GetLoginUID(), req.DeviceUUID, req.ChannelID, req.ChannelType, m.messageExtraDB, m.messageUserExtraDB, m.messageReactionDB, m.channelOffsetDB, m.deviceOffsetDB, channelOffsetMessageSeq))
+	syncResp := newSyncChannelMessageResp(resp, c.GetLoginUID(), req.DeviceUUID, req.ChannelID, req.ChannelType, m.messageExtraDB, m.messageUserExtraDB, m.messageReactionDB, m.channelOffsetDB, m.deviceOffsetDB, channelOffsetMessageSeq)
+	fillPlayableVideos(m.fileService, syncResp.Messages)
+	fillVoiceWaveforms(m.fileService, syncResp.Messages)
+	signPayloadURLs(m.fileService, c.GetLoginUID(), syncResp.Messages)
+	c.Response(syncResp)
 }
 
 // 输入中
@@ -818,7 +907,7 @@ func (m *Message) syncReaction(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	fakeChannelID := req.ChannelID
@@ -855,17 +944,7 @@ func (m *Message) syncReaction(c *wkhttp.Context) {
 	reactions := make([]*reactionResp, 0)
 	if len(list) > 0 {
 		for _, model := range list {
-			reactions = append(reactions, &reactionResp{
-				UID:         model.UID,
-				Name:        model.Name,
-				ChannelID:   toChannelID,
-				ChannelType: model.ChannelType,
-				Seq:         model.Seq,
-				MessageID:   model.MessageID,
-				CreatedAt:   model.CreatedAt.String(),
-				Emoji:       model.Emoji,
-				IsDeleted:   model.IsDeleted,
-			})
+			reactions = append(reactions, newReactionResp(model, toChannelID))
 		}
 	}
 	c.JSON(http.StatusOK, reactions)
@@ -883,7 +962,7 @@ func (m *Message) addOrCancelReaction(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	model, err := m.messageReactionDB.queryReactionWithUIDAndMessageID(loginUID, req.MessageID)
@@ -1082,7 +1161,7 @@ func (m *Message) mutualDelete(c *wkhttp.Context) {
 	var req deleteReq
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if err := req.check(); err != nil {
@@ -1157,7 +1236,7 @@ func (m *Message) delete(c *wkhttp.Context) {
 	var reqs []*deleteReq
 	if err := c.BindJSON(&reqs); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if len(reqs) == 0 {
@@ -1197,7 +1276,7 @@ func (m *Message) delete(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		m.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 
@@ -1235,7 +1314,7 @@ func (m *Message) offset(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	channelOffsetM, err := m.channelOffsetDB.queryWithUIDAndChannel(c.GetLoginUID(), req.ChannelID, req.ChannelType)
@@ -1377,8 +1456,6 @@ func (m *Message) cancelMentionReminderIfNeed(message *messageModel) {
 
 // 撤回消息
 func (m *Message) revoke(c *wkhttp.Context) {
-	loginUID := c.MustGet("uid").(string)
-	messageID := c.Query("message_id")
 	clientMsgNo := c.Query("client_msg_no") // TODO：后续版本不再使用messageID撤回，使用client_msg_no撤回，因为存在重试消息，clientMsgNo一样 但是messageID不一样
 	channelID := c.Query("channel_id")
 	channelType := c.Query("channel_type")
@@ -1396,46 +1473,47 @@ func (m *Message) revoke(c *wkhttp.Context) {
 		fakeChannelID = common.GetFakeChannelIDWith(channelID, c.GetLoginUID())
 	}
 
-	var messageIDs = []string{}
-	var err error
-
-	if clientMsgNo != "" {
-		messages, err := m.db.queryMessagesWithChannelClientMsgNo(fakeChannelID, uint8(channelTypeI), clientMsgNo)
-		if err != nil {
-			m.Error("撤回失败！", zap.String("fakeChannelID", fakeChannelID), zap.String("clientMsgNo", clientMsgNo), zap.String("loginUID", c.GetLoginUID()))
-			c.ResponseErrorf("查询消息失败！", err)
-			return
-		}
-		if len(messages) == 0 {
-			c.ResponseError(errors.New("撤回失败！"))
-			return
-		}
-		var message *messageModel
-		if len(messages) > 0 {
-			message = messages[0]
-			for _, message := range messages {
-				messageIDs = append(messageIDs, fmt.Sprintf("%d", message.MessageID))
-			}
-		}
-		if message != nil {
-			allow, err := m.hasRevokePermission(message, c.GetLoginUID())
-			if err != nil {
-				m.Error("权限判断失败！", zap.Error(err))
-				c.ResponseError(errors.New("权限判断失败！"))
-				return
-			}
-			if !allow {
-				c.ResponseError(errors.New("无权限撤回此消息！"))
-				return
-			}
-
-			m.cancelMentionReminderIfNeed(message)
+	messages, err := m.db.queryMessagesWithChannelClientMsgNo(fakeChannelID, uint8(channelTypeI), clientMsgNo)
+	if err != nil {
+		m.Error("撤回失败！", zap.String("fakeChannelID", fakeChannelID), zap.String("clientMsgNo", clientMsgNo), zap.String("loginUID", c.GetLoginUID()))
+		c.ResponseErrorf("查询消息失败！", err)
+		return
+	}
+	if len(messages) == 0 {
+		c.ResponseError(errors.New("撤回失败！"))
+		return
+	}
+	allow, err := m.hasRevokePermission(messages[0], c.GetLoginUID())
+	if err != nil {
+		m.Error("权限判断失败！", zap.Error(err))
+		c.ResponseError(errors.New("权限判断失败！"))
+		return
+	}
+	if !allow {
+		c.ResponseError(errors.New("无权限撤回此消息！"))
+		return
+	}
+	if err = m.revokeMessages(c.GetLoginUID(), c.GetLoginName(), channelID, uint8(channelTypeI), messages); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
 
-		}
+// revokeMessages 撤回消息并通知频道 messages为同一条消息（重试的消息clientMsgNo相同） 个人频道的channelID为对方uid
+func (m *Message) revokeMessages(operator string, operatorName string, channelID string, channelType uint8, messages []*messageModel) error {
+	if len(messages) == 0 {
+		return nil
 	}
+	m.cancelMentionReminderIfNeed(messages[0])
 
-	if len(messageIDs) == 0 {
-		messageIDs = append(messageIDs, messageID)
+	fakeChannelID := channelID
+	if channelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(channelID, operator)
+	}
+	messageIDs := make([]string, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, fmt.Sprintf("%d", message.MessageID))
 	}
 
 	tx, _ := m.db.session.Begin()
@@ -1447,27 +1525,25 @@ func (m *Message) revoke(c *wkhttp.Context) {
 	}()
 	for _, msgID := range messageIDs {
 		version := m.genMessageExtraSeq(fakeChannelID)
-		err = m.messageExtraDB.insertOrUpdateRevokeTx(&messageExtraModel{
+		err := m.messageExtraDB.insertOrUpdateRevokeTx(&messageExtraModel{
 			MessageID:   msgID,
 			ChannelID:   fakeChannelID,
-			ChannelType: uint8(channelTypeI),
+			ChannelType: channelType,
 			Revoke:      1,
 			Version:     version,
-			Revoker:     loginUID,
+			Revoker:     operator,
 		}, tx)
 		if err != nil {
 			tx.Rollback()
-			c.ResponseErrorf("更新消息为撤回状态失败！", err)
-			return
+			m.Error("更新消息为撤回状态失败！", zap.Error(err))
+			return errors.New("更新消息为撤回状态失败！")
 		}
 	}
-	msgIds := make([]string, 0)
-	msgIds = append(msgIds, messageID)
 	// 发布撤回消息事件
 	eventID, err := m.ctx.EventBegin(&wkevent.Data{
 		Event: event.EventUpdateSearchMessage,
 		Data: &config.UpdateSearchMessageReq{
-			MessageIDs: msgIds,
+			MessageIDs: messageIDs,
 			ChannelID:  channelID,
 		},
 		Type: wkevent.None,
@@ -1475,52 +1551,38 @@ func (m *Message) revoke(c *wkhttp.Context) {
 	if err != nil {
 		tx.Rollback()
 		m.Error("开启事件失败！", zap.Error(err))
-		c.ResponseError(errors.New("开启事件失败！"))
-		return
+		return errors.New("开启事件失败！")
 	}
-	err = m.deletePinnedMessage(channelID, uint8(channelTypeI), messageIDs, loginUID, tx)
+	err = m.deletePinnedMessage(channelID, channelType, messageIDs, operator, tx)
 	if err != nil {
-		c.ResponseError(err)
-		return
+		return err
 	}
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
-		c.ResponseErrorf("事务提交失败！", err)
-		return
+		m.Error("事务提交失败！", zap.Error(err))
+		return errors.New("事务提交失败！")
 	}
 	m.ctx.EventCommit(eventID)
-	// err = m.ctx.SendCMD(config.MsgCMDReq{
-	// 	NoPersist:   true,
-	// 	ChannelID:   channelID,
-	// 	ChannelType: uint8(channelTypeI),
-	// 	FromUID:     loginUID,
-	// 	CMD:         common.CMDSyncMessageExtra,
-	// })
-	// if err != nil {
-	// 	c.ResponseErrorf("发送同步命令失败！", err)
-	// 	return
-	// }
+	publishRevokedMessages(operator, channelID, channelType, messageIDs)
+	// 撤回的图片、视频等文件从CDN缓存中刷新掉
+	m.fileService.PurgeCDN(payloadFilePaths(messages))
 
-	for _, msgID := range messageIDs {
-		messageIDI, _ := strconv.ParseInt(msgID, 10, 64)
+	for _, message := range messages {
 		// 发给指定频道
 		err = m.ctx.SendRevoke(&config.MsgRevokeReq{
-			Operator:     loginUID,
-			OperatorName: c.GetLoginName(),
-			FromUID:      loginUID,
+			Operator:     operator,
+			OperatorName: operatorName,
+			FromUID:      operator,
 			ChannelID:    channelID,
-			ChannelType:  uint8(channelTypeI),
-			MessageID:    messageIDI,
+			ChannelType:  channelType,
+			MessageID:    message.MessageID,
 		})
 		if err != nil {
 			m.Error("发送撤回消息失败！", zap.Error(err))
-			c.ResponseError(errors.New("发送撤回消息失败！"))
-			return
+			return errors.New("发送撤回消息失败！")
 		}
 	}
-
-	c.ResponseOK()
-
+	return nil
 }
 
 // 同步违禁词
@@ -1630,8 +1692,12 @@ func newSyncChannelMessageResp(resp *config.SyncChannelMessageResp, loginUID str
 			messageIDs = append(messageIDs, fmt.Sprintf("%d", message.MessageID))
 		}
 
+		fakeChannelID := channelID
+		if channelType == common.ChannelTypePerson.Uint8() {
+			fakeChannelID = common.GetFakeChannelIDWith(loginUID, channelID)
+		}
 		// 消息全局扩张
-		messageExtras, err := messageExtraDB.queryWithMessageIDs(messageIDs, loginUID)
+		messageExtras, err := messageExtraDB.queryWithChannelMessageIDs(fakeChannelID, messageIDs, loginUID)
 		if err != nil {
 			log.Error("查询消息扩展字段失败！", zap.Error(err))
 		}
@@ -1933,6 +1999,20 @@ type reactionResp struct {
 	CreatedAt   string `json:"created_at"`
 }
 
+func newReactionResp(model *reactionModel, toChannelID string) *reactionResp {
+	return &reactionResp{
+		UID:         model.UID,
+		Name:        model.Name,
+		ChannelID:   toChannelID,
+		ChannelType: model.ChannelType,
+		Seq:         model.Seq,
+		MessageID:   model.MessageID,
+		CreatedAt:   model.CreatedAt.String(),
+		Emoji:       model.Emoji,
+		IsDeleted:   model.IsDeleted,
+	}
+}
+
 // 回应返回
 type reactionSimpleResp struct {
 	Seq       int64  `json:"seq"`        // 回复序列号
@@ -0,0 +1,194 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 批量同步频道的扩展数据（撤回、删除、编辑、回应和消息偏移）
+// 客户端冷启动时一次请求同步多个频道 代替逐个频道调用/message/extra/sync和/reaction/sync
+func (m *Message) syncChannelExtra(c *wkhttp.Context) {
+	var req channelSyncExtraReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	loginUID := c.GetLoginUID()
+	limit := req.Limit
+	if limit <= 0 {
+		limit = channelSyncExtraDefaultLimit
+	}
+	if limit > channelSyncExtraMaxLimit {
+		limit = channelSyncExtraMaxLimit
+	}
+
+	channelIDs := make([]string, 0, len(req.Channels))
+	groupNos := make([]string, 0)
+	for _, channel := range req.Channels {
+		channelIDs = append(channelIDs, channel.ChannelID)
+		if channel.ChannelType == common.ChannelTypeGroup.Uint8() {
+			groupNos = append(groupNos, channel.ChannelID)
+		}
+	}
+
+	// 不在群内的频道不返回数据
+	memberGroupMap := map[string]bool{}
+	if len(groupNos) > 0 {
+		memberGroupNos, err := m.groupService.ExistMembers(groupNos, loginUID)
+		if err != nil {
+			m.Error("查询是否在群内失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询是否在群内失败！"))
+			return
+		}
+		for _, groupNo := range memberGroupNos {
+			memberGroupMap[groupNo] = true
+		}
+	}
+
+	// ---------- 用户频道消息偏移  ----------
+	channelOffsetMap := map[string]uint32{}
+	channelOffsetModels, err := m.channelOffsetDB.queryWithUIDAndChannelIDs(loginUID, channelIDs)
+	if err != nil {
+		m.Error("查询用户频道偏移量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户频道偏移量失败！"))
+		return
+	}
+	for _, channelOffsetM := range channelOffsetModels {
+		channelOffsetMap[fmt.Sprintf("%s-%d", channelOffsetM.ChannelID, channelOffsetM.ChannelType)] = channelOffsetM.MessageSeq
+	}
+
+	// ---------- 频道设置  ----------
+	channelSettings, err := m.channelService.GetChannelSettings(channelIDs)
+	if err != nil {
+		m.Error("查询频道设置失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询频道设置失败！"))
+		return
+	}
+	channelSettingOffsetMap := map[string]uint32{}
+	for _, channelSetting := range channelSettings {
+		channelSettingOffsetMap[fmt.Sprintf("%s-%d", channelSetting.ChannelID, channelSetting.ChannelType)] = channelSetting.OffsetMessageSeq
+	}
+
+	resps := make([]*channelSyncExtraResp, 0, len(req.Channels))
+	for _, channel := range req.Channels {
+		if channel.ChannelType == common.ChannelTypeGroup.Uint8() && !memberGroupMap[channel.ChannelID] {
+			continue
+		}
+		fakeChannelID := channel.ChannelID
+		if channel.ChannelType == common.ChannelTypePerson.Uint8() {
+			fakeChannelID = common.GetFakeChannelIDWith(loginUID, channel.ChannelID)
+		}
+		channelKey := fmt.Sprintf("%s-%d", channel.ChannelID, channel.ChannelType)
+		resp := &channelSyncExtraResp{
+			ChannelID:               channel.ChannelID,
+			ChannelType:             channel.ChannelType,
+			OffsetMessageSeq:        channelOffsetMap[channelKey],
+			ChannelOffsetMessageSeq: channelSettingOffsetMap[channelKey],
+			ReactionSeq:             channel.ReactionSeq,
+		}
+
+		// ---------- 消息扩展（撤回、双向删除、编辑、已读等）  ----------
+		extraVersion, err := m.syncedExtraVersion(loginUID, req.Source, fakeChannelID, channel.ChannelType, channel.ExtraVersion)
+		if err != nil {
+			m.Error("获取消息扩展版本失败！", zap.Error(err), zap.String("channelID", channel.ChannelID))
+			c.ResponseError(errors.New("获取消息扩展版本失败！"))
+			return
+		}
+		resp.ExtraVersion = extraVersion
+		fetchLimit := uint64(limit)
+		if extraVersion > 0 {
+			fetchLimit++ // 多查一条判断是否还有更多
+		}
+		extraModels, err := m.messageExtraDB.sync(extraVersion, fakeChannelID, channel.ChannelType, fetchLimit, loginUID)
+		if err != nil {
+			m.Error("同步消息扩展数据失败！", zap.Error(err), zap.String("channelID", channel.ChannelID))
+			c.ResponseError(errors.New("同步消息扩展数据失败！"))
+			return
+		}
+		if len(extraModels) > limit {
+			extraModels = extraModels[:limit]
+			resp.ExtraHasMore = 1
+		}
+		resp.Extras = make([]*messageExtraResp, 0, len(extraModels))
+		for _, extraModel := range extraModels {
+			resp.Extras = append(resp.Extras, newMessageExtraResp(extraModel))
+			if extraModel.Version > resp.ExtraVersion {
+				resp.ExtraVersion = extraModel.Version
+			}
+		}
+
+		// ---------- 消息回应  ----------
+		fetchLimit = uint64(limit)
+		if channel.ReactionSeq > 0 {
+			fetchLimit++
+		}
+		reactionModels, err := m.messageReactionDB.queryReactionWithChannelAndSeq(fakeChannelID, channel.ChannelType, channel.ReactionSeq, fetchLimit)
+		if err != nil {
+			m.Error("同步回应数据失败！", zap.Error(err), zap.String("channelID", channel.ChannelID))
+			c.ResponseError(errors.New("同步回应数据失败！"))
+			return
+		}
+		if len(reactionModels) > limit {
+			reactionModels = reactionModels[:limit]
+			resp.ReactionHasMore = 1
+		}
+		resp.Reactions = make([]*reactionResp, 0, len(reactionModels))
+		for _, reactionModel := range reactionModels {
+			resp.Reactions = append(resp.Reactions, newReactionResp(reactionModel, channel.ChannelID))
+			if reactionModel.Seq > resp.ReactionSeq {
+				resp.ReactionSeq = reactionModel.Seq
+			}
+		}
+		resps = append(resps, resp)
+	}
+	c.Response(resps)
+}
+
+type channelSyncExtraReq struct {
+	Source   string `json:"source"` // 操作源(设备唯一编号)
+	Limit    int    `json:"limit"`  // 每个频道返回的扩展和回应的数量
+	Channels []*struct {
+		ChannelID    string `json:"channel_id"`
+		ChannelType  uint8  `json:"channel_type"`
+		ExtraVersion int64  `json:"extra_version"` // 本地最大的消息扩展版本
+		ReactionSeq  int64  `json:"reaction_seq"`  // 本地最大的回应序号
+	} `json:"channels"`
+}
+
+func (r channelSyncExtraReq) check() error {
+	if len(r.Channels) == 0 {
+		return errors.New("频道不能为空！")
+	}
+	if len(r.Channels) > channelSyncExtraMaxChannels {
+		return fmt.Errorf("一次最多同步%d个频道！", channelSyncExtraMaxChannels)
+	}
+	for _, channel := range r.Channels {
+		if channel == nil || strings.TrimSpace(channel.ChannelID) == "" {
+			return errcode.ErrChannelIDRequired
+		}
+	}
+	return nil
+}
+
+type channelSyncExtraResp struct {
+	ChannelID               string              `json:"channel_id"`
+	ChannelType             uint8               `json:"channel_type"`
+	Extras                  []*messageExtraResp `json:"extras"`                     // 消息扩展 包含撤回、双向删除、编辑等
+	ExtraVersion            int64               `json:"extra_version"`              // 下次同步传的消息扩展版本
+	ExtraHasMore            int                 `json:"extra_has_more"`             // 是否还有更多消息扩展 1.是
+	Reactions               []*reactionResp     `json:"reactions"`                  // 消息回应
+	ReactionSeq             int64               `json:"reaction_seq"`               // 下次同步传的回应序号
+	ReactionHasMore         int                 `json:"reaction_has_more"`          // 是否还有更多回应 1.是
+	OffsetMessageSeq        uint32              `json:"offset_message_seq"`         // 用户清除消息的偏移 小于等于该序号的消息不显示
+	ChannelOffsetMessageSeq uint32              `json:"channel_offset_message_seq"` // 频道清空消息的偏移 小于等于该序号的消息不显示
+}
@@ -0,0 +1,26 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelSyncExtraReqCheck(t *testing.T) {
+	var req channelSyncExtraReq
+	assert.Error(t, req.check())
+
+	err := util.ReadJsonByByte([]byte(`{"channels":[{"channel_id":"g1","channel_type":2,"extra_version":10},{"channel_id":" ","channel_type":1}]}`), &req)
+	assert.NoError(t, err)
+	assert.Error(t, req.check())
+
+	req.Channels = req.Channels[:1]
+	assert.NoError(t, req.check())
+	assert.Equal(t, int64(10), req.Channels[0].ExtraVersion)
+
+	for i := 0; i < channelSyncExtraMaxChannels; i++ {
+		req.Channels = append(req.Channels, req.Channels[0])
+	}
+	assert.Error(t, req.check())
+}
@@ -11,8 +11,10 @@ import (
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/channel"
 	chservice "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/channel/service"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -39,6 +41,7 @@ type Conversation struct {
 	service             IService
 	channelService      chservice.IService
 	conversationExtraDB *conversationExtraDB
+	fileService         file.IService
 
 	syncConversationResultCacheMap  map[string][]string
 	syncConversationVersionMap      map[string]int64
@@ -63,6 +66,7 @@ func NewConversation(ctx *config.Context) *Conversation {
 		groupService:                   group.NewService(ctx),
 		channelService:                 channel.NewService(ctx),
 		service:                        NewService(ctx),
+		fileService:                    file.NewService(ctx),
 		syncConversationResultCacheMap: map[string][]string{},
 		syncConversationVersionMap:     map[string]int64{},
 	}
@@ -129,7 +133,7 @@ func (co *Conversation) conversationExtraSync(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		co.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	loginUID := c.GetLoginUID()
@@ -157,7 +161,7 @@ func (co *Conversation) conversationExtraUpdate(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		co.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	channelID := c.Param("channel_id")
@@ -223,7 +227,7 @@ func (co *Conversation) syncUserConversation(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		co.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 
@@ -388,7 +392,7 @@ func (co *Conversation) syncUserConversation(c *wkhttp.Context) {
 		users, err = co.userService.GetUserDetails(uids, c.GetLoginUID())
 		if err != nil {
 			co.Error("查询用户信息失败！", zap.Error(err))
-			c.ResponseError(errors.New("查询用户信息失败！"))
+			c.ResponseError(errcode.ErrQueryUser)
 			return
 		}
 		if len(users) > 0 {
@@ -525,6 +529,14 @@ func (co *Conversation) syncUserConversation(c *wkhttp.Context) {
 	}
 	co.syncConversationResultCacheLock.Unlock()
 
+	recents := make([]*MsgSyncResp, 0, len(syncUserConversationResps))
+	for _, syncUserConversationResp := range syncUserConversationResps {
+		recents = append(recents, syncUserConversationResp.Recents...)
+	}
+	fillPlayableVideos(co.fileService, recents)
+	fillVoiceWaveforms(co.fileService, recents)
+	signPayloadURLs(co.fileService, loginUID, recents)
+
 	c.Response(SyncUserConversationRespWrap{
 		Conversations: syncUserConversationResps,
 		UID:           loginUID,
@@ -556,7 +568,7 @@ func (co *Conversation) syncUserConversationAck(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		co.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if co.ctx.GetConfig().MessageSaveAcrossDevice {
@@ -1045,8 +1057,12 @@ func newSyncUserConversationResp(resp *config.SyncUserConversationResp, extra *c
 			}
 		}
 
+		fakeChannelID := resp.ChannelID
+		if resp.ChannelType == common.ChannelTypePerson.Uint8() {
+			fakeChannelID = common.GetFakeChannelIDWith(loginUID, resp.ChannelID)
+		}
 		// 消息扩充数据
-		messageExtras, err := messageExtraDB.queryWithMessageIDs(messageIDs, loginUID)
+		messageExtras, err := messageExtraDB.queryWithChannelMessageIDs(fakeChannelID, messageIDs, loginUID)
 		if err != nil {
 			log.Error("查询消息扩展字段失败！", zap.Error(err))
 		}
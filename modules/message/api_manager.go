@@ -6,9 +6,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/jobqueue"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -27,18 +31,24 @@ type Manager struct {
 	groupService group.IService
 	managerDB    *managerDB
 	pinnedDB     *pinnedDB
+	db           *DB
+	extraShardDB *extraShardDB
 }
 
 // NewManager NewManager
 func NewManager(ctx *config.Context) *Manager {
-	return &Manager{
+	m := &Manager{
 		ctx:          ctx,
 		Log:          log.NewTLog("MessageManager"),
 		userService:  user.NewService(ctx),
 		groupService: group.NewService(ctx),
 		managerDB:    newManagerDB(ctx),
 		pinnedDB:     newPinnedDB(ctx),
+		db:           NewDB(ctx),
+		extraShardDB: newExtraShardDB(ctx),
 	}
+	jobqueue.Register(jobQueueMessage, JobTypeSendBatch, m.handleSendBatchJob)
+	return m
 }
 
 // Route 路由配置
@@ -55,10 +65,11 @@ func (m *Manager) Route(r *wkhttp.WKHttp) {
 		auth.GET("/message/prohibit_words", m.prohibitWords)          // 查询违禁词
 		auth.DELETE("/message/prohibit_words", m.deleteProhibitWords) // 删除违禁词
 		auth.DELETE("/message", m.delete)                             // 删除消息
+		auth.GET("/message/extra_shard", m.extraShard)                // 消息扩展分表的迁移进度
 	}
 }
 func (m *Manager) sendMsgToFriends(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermMessageSend)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -71,7 +82,7 @@ func (m *Manager) sendMsgToFriends(c *wkhttp.Context) {
 	var req ReqVO
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if req.UID == "" {
@@ -86,31 +97,21 @@ func (m *Manager) sendMsgToFriends(c *wkhttp.Context) {
 		c.ResponseError(errors.New("发送消息的订阅者不能为空"))
 		return
 	}
-	go m.sendMessageToFriends(req.ToUIDs, req.UID, req.Content)
-	c.ResponseOK()
-}
-
-func (m *Manager) sendMessageToFriends(toUids []string, fromUID string, content string) error {
-	err := m.ctx.SendMessageBatch(&config.MsgSendBatch{
-		Header: config.MsgHeader{
-			RedDot: 1,
-		},
-		FromUID: fromUID,
-		Payload: []byte(util.ToJson(map[string]interface{}{
-			"content": content,
-			"type":    1,
-		})),
-		Subscribers: toUids,
+	_, err = jobqueue.Enqueue(JobTypeSendBatch, &sendBatchJob{
+		FromUID: req.UID,
+		Content: req.Content,
+		UIDs:    req.ToUIDs,
 	})
 	if err != nil {
-		m.Error("发送消息错误", zap.Error(err))
-		return errors.New("发送消息错误")
+		m.Error("添加发送消息的任务失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加发送消息的任务失败！"))
+		return
 	}
-	return nil
+	c.ResponseOK()
 }
 func (m *Manager) delete(c *wkhttp.Context) {
 	loginUID := c.GetLoginUID()
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermMessageDelete)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -128,7 +129,7 @@ func (m *Manager) delete(c *wkhttp.Context) {
 	var req reqVO
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if len(req.List) == 0 {
@@ -223,7 +224,7 @@ func (m *Manager) delete(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		m.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	m.ctx.EventCommit(eventID)
@@ -257,10 +258,12 @@ func (m *Manager) delete(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
+	// 操作日志中记录删除的消息内容
+	audit.SetChange(c, fmt.Sprintf("channel_id=%s,channel_type=%d", req.ChannelID, req.ChannelType), m.deletedMessagesForLog(fakeChannelID, req.ChannelType, msgIds), nil)
 	c.ResponseOK()
 }
 func (m *Manager) deleteProhibitWords(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermContentWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -296,7 +299,7 @@ func (m *Manager) deleteProhibitWords(c *wkhttp.Context) {
 }
 
 func (m *Manager) prohibitWords(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermContentRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -357,7 +360,7 @@ func (m *Manager) prohibitWords(c *wkhttp.Context) {
 	})
 }
 func (m *Manager) addProhibitWords(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermContentWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -398,7 +401,7 @@ func (m *Manager) addProhibitWords(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 func (m *Manager) recordpersonal(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermMessageRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -500,7 +503,7 @@ func (m *Manager) recordpersonal(c *wkhttp.Context) {
 	})
 }
 func (m *Manager) record(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermMessageRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -599,7 +602,7 @@ func (m *Manager) record(c *wkhttp.Context) {
 	})
 }
 func (m *Manager) sendMsgToAllUsers(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermBroadcastSend)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -630,34 +633,25 @@ func (m *Manager) sendMsgToAllUsers(c *wkhttp.Context) {
 	if len(tempUserList) > 0 {
 		uids = append(uids, tempUserList)
 	}
-	go m.sendMessageBatch(uids, req.Content)
-	c.ResponseOK()
-}
-func (m *Manager) sendMessageBatch(uids [][]string, content string) error {
-	for _, list := range uids {
-		err := m.ctx.SendMessageBatch(&config.MsgSendBatch{
-			Header: config.MsgHeader{
-				RedDot: 1,
-			},
+	// 每批间隔1秒发送 每批单独重试
+	for i, list := range uids {
+		_, err = jobqueue.Enqueue(JobTypeSendBatch, &sendBatchJob{
 			FromUID: m.ctx.GetConfig().Account.SystemUID,
-			Payload: []byte(util.ToJson(map[string]interface{}{
-				"content": content,
-				"type":    1,
-			})),
-			Subscribers: list,
-		})
+			Content: req.Content,
+			UIDs:    list,
+		}, jobqueue.ProcessIn(time.Duration(i)*time.Second))
 		if err != nil {
-			m.Error("发送消息错误", zap.Error(err))
-			return errors.New("发送消息错误")
+			m.Error("添加发送消息的任务失败！", zap.Error(err), zap.Int("batch", i))
+			c.ResponseError(errors.New("添加发送消息的任务失败！"))
+			return
 		}
-		time.Sleep(time.Second)
 	}
-	return nil
+	c.ResponseOK()
 }
 
 // 发送消息
 func (m *Manager) sendMsg(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermMessageSend)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -738,7 +732,7 @@ func (m *Manager) sendMsg(c *wkhttp.Context) {
 
 // 代发消息列表
 func (m *Manager) list(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermMessageRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -839,3 +833,21 @@ type prohibitWordsVO struct {
 	Version   int64  `json:"version"`    // 版本
 	CreatedAt string `json:"created_at"` // 时间
 }
+
+// deletedMessagesForLog 被删除的消息内容 查询失败时只记录消息ID
+func (m *Manager) deletedMessagesForLog(fakeChannelID string, channelType uint8, messageIDs []string) interface{} {
+	messages, err := m.db.queryMessagesWithMessageIDs(fakeChannelID, channelType, messageIDs)
+	if err != nil {
+		m.Warn("查询删除的消息失败！", zap.Error(err))
+		return messageIDs
+	}
+	list := make([]map[string]interface{}, 0, len(messages))
+	for _, message := range messages {
+		list = append(list, map[string]interface{}{
+			"message_id": fmt.Sprintf("%d", message.MessageID),
+			"from_uid":   message.FromUID,
+			"payload":    string(message.Payload),
+		})
+	}
+	return list
+}
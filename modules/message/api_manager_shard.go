@@ -0,0 +1,59 @@
+package message
+
+import (
+	"errors"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// extraShard 消息扩展分表的迁移进度
+func (m *Manager) extraShard(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	count := extraTableCount()
+	resp := &extraShardResp{
+		TableCount: count,
+		Tables:     allExtraTables(count),
+	}
+	if count <= 1 {
+		c.Response(resp)
+		return
+	}
+	shard, err := m.extraShardDB.query(count)
+	if err != nil {
+		m.Error("查询消息扩展迁移进度失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询消息扩展迁移进度失败！"))
+		return
+	}
+	if shard == nil {
+		c.Response(resp)
+		return
+	}
+	resp.LastID = shard.LastID
+	resp.MigratedCount = shard.MigratedCount
+	resp.FinishedAt = shard.FinishedAt
+	resp.Migrating = shard.FinishedAt == 0
+	if resp.Migrating {
+		resp.Remaining, err = m.extraShardDB.queryLegacyRemaining(shard.LastID)
+		if err != nil {
+			m.Error("查询未迁移的消息扩展数量失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询未迁移的消息扩展数量失败！"))
+			return
+		}
+	}
+	c.Response(resp)
+}
+
+type extraShardResp struct {
+	TableCount    int      `json:"table_count"`    // 分表数量
+	Tables        []string `json:"tables"`         // 所有的表
+	Migrating     bool     `json:"migrating"`      // 是否正在迁移
+	LastID        int64    `json:"last_id"`        // 原表中已迁移到的id
+	MigratedCount int64    `json:"migrated_count"` // 已迁移的条数
+	Remaining     int64    `json:"remaining"`      // 原表中还需要检查的条数 包含本来就属于原表的数据
+	FinishedAt    int64    `json:"finished_at"`    // 迁移完成的时间
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -254,7 +255,7 @@ func (m *Message) clearPinnedMessage(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if req.ChannelID == "" {
@@ -391,7 +392,7 @@ func (m *Message) syncPinnedMessage(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if req.ChannelID == "" {
@@ -440,7 +441,7 @@ func (m *Message) syncPinnedMessage(c *wkhttp.Context) {
 		return
 	}
 	// 消息全局扩张
-	messageExtras, err := m.messageExtraDB.queryWithMessageIDs(messageIds, loginUID)
+	messageExtras, err := m.messageExtraDB.queryWithChannelMessageIDs(fakeChannelID, messageIds, loginUID)
 	if err != nil {
 		m.Error("查询消息扩展字段失败！", zap.Error(err))
 		c.ResponseError(errors.New("查询用户消息扩展错误"))
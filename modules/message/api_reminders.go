@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -19,7 +20,7 @@ import (
 func (m *Message) reminderDone(c *wkhttp.Context) {
 	var ids []int64
 	if err := c.BindJSON(&ids); err != nil {
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if len(ids) == 0 {
@@ -54,7 +55,7 @@ func (m *Message) reminderDone(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		m.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	err = m.ctx.SendCMD(config.MsgCMDReq{
@@ -80,7 +81,7 @@ func (m *Message) reminderSync(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	loginUID := c.GetLoginUID()
@@ -130,6 +131,12 @@ func (m *Message) listenerMessages(messages []*config.MessageResp) {
 		m.handleReminders(reminders)
 	}
 
+	m.checkSensitiveMessages(messages) // 敏感词
+
+	m.countMessages(messages) // 每天的消息数
+
+	m.publishSentMessages(messages) // 发布消息发送的领域事件
+
 }
 
 func (m *Message) getReminders(messages []*config.MessageResp) []*remindersModel {
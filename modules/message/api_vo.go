@@ -3,6 +3,8 @@ package message
 import (
 	"errors"
 	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 )
 
 type deleteReq struct {
@@ -17,10 +19,10 @@ func (d *deleteReq) check() error {
 		return errors.New("消息ID不能为空！")
 	}
 	if strings.TrimSpace(d.ChannelID) == "" {
-		return errors.New("频道ID不能为空！")
+		return errcode.ErrChannelIDRequired
 	}
 	if d.ChannelType == 0 {
-		return errors.New("频道类型不能为空！")
+		return errcode.ErrChannelTypeRequired
 	}
 	if d.MessageSeq == 0 {
 		return errors.New("消息序号不能为空！")
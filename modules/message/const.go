@@ -9,6 +9,16 @@ const (
 )
 const CacheReadedCountPrefix = "readedCount:" // 消息已读数量
 
+const (
+	extraSyncDefaultLimit   = 100   // 同步消息扩展默认数量
+	extraSyncMaxLimit       = 10000 // 同步消息扩展最大数量
+	extraSyncMaxCursorLimit = 1000  // 按游标分页同步消息扩展时每页最大数量
+
+	channelSyncExtraMaxChannels  = 100 // 批量同步频道扩展数据每次最多频道数
+	channelSyncExtraDefaultLimit = 100 // 批量同步时每个频道默认返回的扩展和回应数量
+	channelSyncExtraMaxLimit     = 500 // 批量同步时每个频道最多返回的扩展和回应数量
+)
+
 type ReminderType int
 
 const (
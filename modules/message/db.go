@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"hash/crc32"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -77,6 +78,43 @@ func (d *DB) getTable(channelID string) string {
 	return fmt.Sprintf("message%d", tableIndex)
 }
 
+// getTables 所有的消息表
+func (d *DB) getTables() []string {
+	count := d.ctx.GetConfig().TablePartitionConfig.MessageTableCount
+	tables := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if i == 0 {
+			tables = append(tables, "message")
+			continue
+		}
+		tables = append(tables, fmt.Sprintf("message%d", i))
+	}
+	return tables
+}
+
+// deleteChannelMessages 删除频道的一批消息 返回删除的条数
+func (d *DB) deleteChannelMessages(channelID string, channelType uint8, limit uint64) (int64, error) {
+	result, err := d.session.DeleteFrom(d.getTable(channelID)).Where("channel_id=? and channel_type=?", channelID, channelType).Limit(limit).Exec()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// searchMessages 在一张消息表中检索消息 按消息时间倒序
+func (d *DB) searchMessages(table string, req *SearchReq, limit uint64) ([]*messageModel, error) {
+	var models []*messageModel
+	builder := d.session.Select("*").From(table).Where("`timestamp`>=? and `timestamp`<?", req.StartAt, req.EndAt)
+	if req.GroupNo != "" {
+		builder = builder.Where("channel_id=? and channel_type=?", req.GroupNo, common.ChannelTypeGroup.Uint8())
+	}
+	if req.UID != "" {
+		builder = builder.Where("from_uid=?", req.UID)
+	}
+	_, err := builder.OrderDir("`timestamp`", false).OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
 // ProhibitWordModel 违禁词model
 type ProhibitWordModel struct {
 	Content   string
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"hash/crc32"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/replica"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
 	"github.com/gocraft/dbr/v2"
@@ -24,29 +25,32 @@ func newChannelOffsetDB(ctx *config.Context) *channelOffsetDB {
 func (c *channelOffsetDB) insertOrUpdate(m *channelOffsetModel) error {
 	sq := fmt.Sprintf("INSERT INTO %s (uid,channel_id,channel_type,message_seq) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE message_seq=IF(message_seq<VALUES(message_seq),VALUES(message_seq),message_seq)", c.getTable(m.UID))
 	_, err := c.session.InsertBySql(sq, m.UID, m.ChannelID, m.ChannelType, m.MessageSeq).Exec()
+	replica.MarkWritten(m.UID)
 	return err
 }
 
 func (c *channelOffsetDB) delete(uid string, channelID string, channelType uint8, tx *dbr.Tx) error {
 	_, err := tx.DeleteFrom(c.getTable(uid)).Where("uid=? and channel_id=? and channel_type=?", uid, channelID, channelType).Exec()
+	replica.MarkWritten(uid)
 	return err
 }
 
 func (c *channelOffsetDB) insertOrUpdateTx(m *channelOffsetModel, tx *dbr.Tx) error {
 	sq := fmt.Sprintf("INSERT INTO %s (uid,channel_id,channel_type,message_seq) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE  message_seq=IF(message_seq<VALUES(message_seq),VALUES(message_seq),message_seq)", c.getTable(m.UID))
 	_, err := tx.InsertBySql(sq, m.UID, m.ChannelID, m.ChannelType, m.MessageSeq).Exec()
+	replica.MarkWritten(m.UID)
 	return err
 }
 
 func (c *channelOffsetDB) queryWithUIDAndChannel(uid string, channelID string, channelType uint8) (*channelOffsetModel, error) {
 	var m *channelOffsetModel
-	_, err := c.session.Select("*").From(c.getTable(uid)).Where("(uid=? or uid='') and channel_id=? and channel_type=?", uid, channelID, channelType).OrderDesc("message_seq").Limit(1).Load(&m)
+	_, err := replica.Reader(c.session, uid).Select("*").From(c.getTable(uid)).Where("(uid=? or uid='') and channel_id=? and channel_type=?", uid, channelID, channelType).OrderDesc("message_seq").Limit(1).Load(&m)
 	return m, err
 }
 
 func (c *channelOffsetDB) queryWithUIDAndChannelIDs(uid string, channelIDs []string) ([]*channelOffsetModel, error) {
 	var models []*channelOffsetModel
-	_, err := c.session.Select("channel_id,channel_type,max(message_seq) message_seq").From(c.getTable(uid)).Where("(uid=? or uid='') and channel_id in ?", uid, channelIDs).GroupBy("channel_id", "channel_type").Load(&models)
+	_, err := replica.Reader(c.session, uid).Select("channel_id,channel_type,max(message_seq) message_seq").From(c.getTable(uid)).Where("(uid=? or uid='') and channel_id in ?", uid, channelIDs).GroupBy("channel_id", "channel_type").Load(&models)
 	return models, err
 }
 
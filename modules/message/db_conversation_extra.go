@@ -50,3 +50,10 @@ type conversationExtraModel struct {
 	Version        int64
 	db.BaseModel
 }
+
+// queryWithUID 用户所有会话的扩展（阅读位置和草稿）
+func (c *conversationExtraDB) queryWithUID(uid string, limit uint64) ([]*conversationExtraModel, error) {
+	var models []*conversationExtraModel
+	_, err := c.session.Select("*").From("conversation_extra").Where("uid=?", uid).OrderDir("id", true).Limit(limit).Load(&models)
+	return models, err
+}
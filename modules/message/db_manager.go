@@ -1,6 +1,8 @@
 package message
 
 import (
+	"strconv"
+
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -11,6 +13,7 @@ import (
 type managerDB struct {
 	session *dbr.Session
 	db      *DB
+	extraDB *messageExtraDB
 }
 
 // newManagerDB newManagerDB
@@ -18,6 +21,7 @@ func newManagerDB(ctx *config.Context) *managerDB {
 	return &managerDB{
 		session: ctx.DB(),
 		db:      NewDB(ctx),
+		extraDB: newMessageExtraDB(ctx),
 	}
 }
 
@@ -55,9 +59,11 @@ func (m *managerDB) queryRecordCount(channelID string) (int64, error) {
 }
 
 func (m *managerDB) queryMsgExtrWithMsgIds(msgIds []int64) ([]*messageExtraModel, error) {
-	var list []*messageExtraModel
-	_, err := m.session.Select("*").From("message_extra").Where("message_id in ?", msgIds).Load(&list)
-	return list, err
+	messageIDs := make([]string, 0, len(msgIds))
+	for _, msgID := range msgIds {
+		messageIDs = append(messageIDs, strconv.FormatInt(msgID, 10))
+	}
+	return m.extraDB.queryModelsWithMessageIDs(messageIDs)
 }
 
 func (m *managerDB) queryProhibitWordsWithContent(content string) (*prohibitWordsModel, error) {
@@ -108,8 +114,7 @@ func (m *managerDB) queryProhibitWordsCountWithContent(content string) (int64, e
 }
 
 func (m *managerDB) updateMsgExtraVersionAndDeletedTx(md *messageExtraModel, tx *dbr.Tx) error {
-	_, err := tx.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,is_deleted,version) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE is_deleted=VALUES(is_deleted),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.IsDeleted, md.Version).Exec()
-	return err
+	return m.extraDB.insertOrUpdateDeletedTx(md, tx)
 }
 
 type prohibitWordsModel struct {
@@ -1,8 +1,11 @@
 package message
 
 import (
+	"fmt"
 	"sort"
+	"strconv"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/replica"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
 	"github.com/gocraft/dbr/v2"
@@ -20,87 +23,206 @@ func newMessageExtraDB(ctx *config.Context) *messageExtraDB {
 	}
 }
 
+// deleteWithChannel 删除频道的一批消息扩展 返回删除的条数
+func (m *messageExtraDB) deleteWithChannel(channelID string, channelType uint8, limit uint64) (int64, error) {
+	var total int64
+	for _, table := range m.readTables(channelID) {
+		result, err := m.session.DeleteFrom(table).Where("channel_id=? and channel_type=?", channelID, channelType).Limit(limit).Exec()
+		if err != nil {
+			return total, err
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
 func (m *messageExtraDB) insertOrUpdateRevoke(md *messageExtraModel) error {
-	_, err := m.session.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,`revoke`,revoker,version) VALUES (?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE `revoke`=VALUES(`revoke`),revoker=VALUES(revoker),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.Revoke, md.Revoker, md.Version).Exec()
-	return err
+	return m.upsert(m.session, md, "INSERT INTO %s (message_id,message_seq,channel_id,channel_type,`revoke`,revoker,version) VALUES (?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE `revoke`=VALUES(`revoke`),revoker=VALUES(revoker),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.Revoke, md.Revoker, md.Version)
 }
 
 func (m *messageExtraDB) insertOrUpdateRevokeTx(md *messageExtraModel, tx *dbr.Tx) error {
-	_, err := tx.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,`revoke`,revoker,version) VALUES (?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE `revoke`=VALUES(`revoke`),revoker=VALUES(revoker),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.Revoke, md.Revoker, md.Version).Exec()
-	return err
+	return m.upsert(tx, md, "INSERT INTO %s (message_id,message_seq,channel_id,channel_type,`revoke`,revoker,version) VALUES (?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE `revoke`=VALUES(`revoke`),revoker=VALUES(revoker),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.Revoke, md.Revoker, md.Version)
 }
 
 // 更新已读数量
 func (m *messageExtraDB) insertOrUpdateReadedCount(md *messageExtraModel) error {
-	_, err := m.session.InsertBySql("INSERT INTO message_extra (clone_no,message_id,message_seq,from_uid,channel_id,channel_type,readed_count,version) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE clone_no=IF(clone_no='',VALUES(clone_no),clone_no),readed_count=VALUES(readed_count),version=VALUES(version)", md.CloneNo, md.MessageID, md.MessageSeq, md.FromUID, md.ChannelID, md.ChannelType, md.ReadedCount, md.Version).Exec()
-	return err
+	return m.upsert(m.session, md, "INSERT INTO %s (clone_no,message_id,message_seq,from_uid,channel_id,channel_type,readed_count,version) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE clone_no=IF(clone_no='',VALUES(clone_no),clone_no),readed_count=VALUES(readed_count),version=VALUES(version)", md.CloneNo, md.MessageID, md.MessageSeq, md.FromUID, md.ChannelID, md.ChannelType, md.ReadedCount, md.Version)
 }
 
 // 更新已读数量
 func (m *messageExtraDB) insertOrUpdateReadedCountTx(md *messageExtraModel, tx *dbr.Tx) error {
-	_, err := tx.InsertBySql("INSERT INTO message_extra (clone_no,message_id,message_seq,from_uid,channel_id,channel_type,readed_count,version) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE clone_no=IF(clone_no='',VALUES(clone_no),clone_no),readed_count=VALUES(readed_count),version=VALUES(version)", md.CloneNo, md.MessageID, md.MessageSeq, md.FromUID, md.ChannelID, md.ChannelType, md.ReadedCount, md.Version).Exec()
-	return err
+	return m.upsert(tx, md, "INSERT INTO %s (clone_no,message_id,message_seq,from_uid,channel_id,channel_type,readed_count,version) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE clone_no=IF(clone_no='',VALUES(clone_no),clone_no),readed_count=VALUES(readed_count),version=VALUES(version)", md.CloneNo, md.MessageID, md.MessageSeq, md.FromUID, md.ChannelID, md.ChannelType, md.ReadedCount, md.Version)
 }
 
 func (m *messageExtraDB) insertOrUpdateContentEdit(md *messageExtraModel) error {
-	_, err := m.session.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,content_edit,content_edit_hash,edited_at,version) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE content_edit=VALUES(content_edit),content_edit_hash=VALUES(content_edit_hash),edited_at=VALUES(edited_at),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.ContentEdit, md.ContentEditHash, md.EditedAt, md.Version).Exec()
-	return err
+	return m.upsert(m.session, md, "INSERT INTO %s (message_id,message_seq,channel_id,channel_type,content_edit,content_edit_hash,edited_at,version) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE content_edit=VALUES(content_edit),content_edit_hash=VALUES(content_edit_hash),edited_at=VALUES(edited_at),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.ContentEdit, md.ContentEditHash, md.EditedAt, md.Version)
 }
 
 func (m *messageExtraDB) insertOrUpdateContentEditTx(md *messageExtraModel, tx *dbr.Tx) error {
-	_, err := tx.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,content_edit,content_edit_hash,edited_at,version) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE content_edit=VALUES(content_edit),content_edit_hash=VALUES(content_edit_hash),edited_at=VALUES(edited_at),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.ContentEdit, md.ContentEditHash, md.EditedAt, md.Version).Exec()
-	return err
+	return m.upsert(tx, md, "INSERT INTO %s (message_id,message_seq,channel_id,channel_type,content_edit,content_edit_hash,edited_at,version) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE content_edit=VALUES(content_edit),content_edit_hash=VALUES(content_edit_hash),edited_at=VALUES(edited_at),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.ContentEdit, md.ContentEditHash, md.EditedAt, md.Version)
 }
 
 func (m *messageExtraDB) insertOrUpdatePinnedTx(md *messageExtraModel, tx *dbr.Tx) error {
-	_, err := tx.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,is_pinned,version) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE is_pinned=VALUES(is_pinned),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.IsPinned, md.Version).Exec()
-	return err
+	return m.upsert(tx, md, "INSERT INTO %s (message_id,message_seq,channel_id,channel_type,is_pinned,version) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE is_pinned=VALUES(is_pinned),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.IsPinned, md.Version)
 }
 
 func (m *messageExtraDB) insertOrUpdateDeleted(md *messageExtraModel) error {
-	_, err := m.session.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,is_deleted,version) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE is_deleted=VALUES(is_deleted),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.IsDeleted, md.Version).Exec()
+	return m.insertOrUpdateDeletedWith(m.session, md)
+}
+
+func (m *messageExtraDB) insertOrUpdateDeletedTx(md *messageExtraModel, tx *dbr.Tx) error {
+	return m.insertOrUpdateDeletedWith(tx, md)
+}
+
+func (m *messageExtraDB) insertOrUpdateDeletedWith(runner dbr.SessionRunner, md *messageExtraModel) error {
+	return m.upsert(runner, md, "INSERT INTO %s (message_id,message_seq,channel_id,channel_type,is_deleted,version) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE is_deleted=VALUES(is_deleted),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.IsDeleted, md.Version)
+}
+
+// upsert 写入频道所在的分表 query中的%s为表名
+// 迁移完成前先把原表中的数据复制到分表 避免只更新部分字段时丢失原表中的其他字段
+func (m *messageExtraDB) upsert(runner dbr.SessionRunner, md *messageExtraModel, query string, value ...interface{}) error {
+	table := m.table(md.ChannelID)
+	if table != legacyExtraTable && extraMigrating.Load() {
+		_, err := runner.InsertBySql(fmt.Sprintf("INSERT IGNORE INTO %s (%s) SELECT %s FROM %s WHERE message_id=?", table, extraColumns, extraColumns, legacyExtraTable), md.MessageID).Exec()
+		if err != nil {
+			return err
+		}
+	}
+	_, err := runner.InsertBySql(fmt.Sprintf(query, table), value...).Exec()
+	markChannelWritten(md)
 	return err
 }
 
 // 是否存在相同编辑内容
-func (m *messageExtraDB) existContentEdit(messageID string, contentEditHash string) (bool, error) {
-	var count int
-	err := m.session.Select("count(*)").From("message_extra").Where("message_id=? and content_edit_hash=?", messageID, contentEditHash).LoadOne(&count)
-	return count > 0, err
+func (m *messageExtraDB) existContentEdit(channelID string, messageID string, contentEditHash string) (bool, error) {
+	groups := make([][]*messageExtraModel, 0, 2)
+	for _, table := range m.readTables(channelID) {
+		var models []*messageExtraModel
+		_, err := m.session.Select("*").From(table).Where("message_id=?", messageID).Load(&models)
+		if err != nil {
+			return false, err
+		}
+		groups = append(groups, models)
+	}
+	models := mergeExtras(groups...)
+	return len(models) > 0 && models[0].ContentEditHash == contentEditHash, nil
 }
 
+// queryWithMessageIDs 不知道消息所在的频道时查询所有的分表
 func (m *messageExtraDB) queryWithMessageIDs(messageIDs []string, loginUID string) ([]*messageExtraDetailModel, error) {
+	return m.queryDetailsWithTables(m.session, allExtraTables(extraTableCount()), messageIDs, loginUID)
+}
+
+// queryWithChannelMessageIDs 查询频道内的消息扩展 个人频道的channelID为fakeChannelID
+func (m *messageExtraDB) queryWithChannelMessageIDs(channelID string, messageIDs []string, loginUID string) ([]*messageExtraDetailModel, error) {
+	return m.queryDetailsWithTables(m.session, m.readTables(channelID), messageIDs, loginUID)
+}
+
+func (m *messageExtraDB) queryDetailsWithTables(session *dbr.Session, tables []string, messageIDs []string, loginUID string) ([]*messageExtraDetailModel, error) {
 	if len(messageIDs) <= 0 {
 		return nil, nil
 	}
-	var models []*messageExtraDetailModel
-	_, err := m.session.Select("message_extra.*,(select count(*) from member_readed where member_readed.message_id=message_extra.message_id and member_readed.uid='"+loginUID+"') readed,(select created_at from member_readed where member_readed.message_id=message_extra.message_id and member_readed.uid='"+loginUID+"') readed_at").From("message_extra").Where("message_id in ?", messageIDs).Load(&models)
-	return models, err
+	groups := make([][]*messageExtraDetailModel, 0, len(tables))
+	for _, table := range tables {
+		var models []*messageExtraDetailModel
+		_, err := selectExtraDetails(session, table, loginUID).From(table).Where("message_id in ?", messageIDs).Load(&models)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, models)
+	}
+	return mergeExtras(groups...), nil
+}
+
+// queryModelsWithMessageIDs 消息的撤回、编辑和删除等状态
+func (m *messageExtraDB) queryModelsWithMessageIDs(messageIDs []string) ([]*messageExtraModel, error) {
+	if len(messageIDs) <= 0 {
+		return nil, nil
+	}
+	tables := allExtraTables(extraTableCount())
+	groups := make([][]*messageExtraModel, 0, len(tables))
+	for _, table := range tables {
+		var models []*messageExtraModel
+		_, err := m.session.Select("*").From(table).Where("message_id in ?", messageIDs).Load(&models)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, models)
+	}
+	return mergeExtras(groups...), nil
 }
 
 func (m *messageExtraDB) queryWithMessageID(messageID int64) (*messageExtraModel, error) {
-	var model *messageExtraModel
-	_, err := m.session.Select("*").From("message_extra").Where("message_id=?", messageID).Load(&model)
-	return model, err
+	models, err := m.queryModelsWithMessageIDs([]string{strconv.FormatInt(messageID, 10)})
+	if err != nil || len(models) == 0 {
+		return nil, err
+	}
+	return models[0], nil
 }
 
 func (m *messageExtraDB) sync(version int64, channelID string, channelType uint8, limit uint64, loginUID string) ([]*messageExtraDetailModel, error) {
-	var models []*messageExtraDetailModel
-	selectSql := "message_extra.*,(select count(*) from member_readed where member_readed.message_id=message_extra.message_id and member_readed.uid='" + loginUID + "') readed,(select created_at from member_readed where member_readed.message_id=message_extra.message_id and member_readed.uid='" + loginUID + "') readed_at"
-	builder := m.session.Select(selectSql).From("message_extra")
-	var err error
-	if version == 0 {
-		builder = builder.Where("channel_id=? and channel_type=?", channelID, channelType).OrderDesc("version").Limit(limit)
-		_, err = builder.Load(&models)
-		newModels := messageExtraDetailModelSlice(models)
-		sort.Sort(newModels)
-		models = newModels
-	} else {
-		builder = builder.Where("channel_id=? and channel_type=? and version>?", channelID, channelType, version).OrderAsc("version").Limit(limit)
+	// 允许少量延迟 可以使用从库
+	session := replica.Reader(m.session, replica.ChannelKey(channelID, channelType))
+	tables := m.readTables(channelID)
+	groups := make([][]*messageExtraDetailModel, 0, len(tables))
+	for _, table := range tables {
+		var models []*messageExtraDetailModel
+		builder := selectExtraDetails(session, table, loginUID).From(table)
+		var err error
+		if version == 0 {
+			builder = builder.Where("channel_id=? and channel_type=?", channelID, channelType).OrderDesc("version").Limit(limit)
+		} else {
+			builder = builder.Where("channel_id=? and channel_type=? and version>?", channelID, channelType, version).OrderAsc("version").Limit(limit)
+		}
 		_, err = builder.Load(&models)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, models)
+	}
+	newModels := messageExtraDetailModelSlice(mergeExtras(groups...))
+	sort.Sort(newModels)
+	if uint64(len(newModels)) > limit {
+		if version == 0 {
+			// 取最新的limit条
+			newModels = newModels[uint64(len(newModels))-limit:]
+		} else {
+			newModels = newModels[:limit]
+		}
 	}
+	return newModels, nil
+}
+
+// readTables 查询频道的消息扩展需要读取的表 迁移完成前同时读取原表 分表在前
+func (m *messageExtraDB) readTables(channelID string) []string {
+	table := m.table(channelID)
+	if table != legacyExtraTable && extraMigrating.Load() {
+		return []string{table, legacyExtraTable}
+	}
+	return []string{table}
+}
+
+func (m *messageExtraDB) table(channelID string) string {
+	return extraTable(channelID, extraTableCount())
+}
+
+// selectExtraDetails 查询消息扩展和登录用户的已读状态 loginUID作为参数传入
+func selectExtraDetails(session *dbr.Session, table string, loginUID string) *dbr.SelectStmt {
+	builder := session.Select(table + ".*")
+	builder.Column = append(builder.Column,
+		dbr.Expr(fmt.Sprintf("(select count(*) from member_readed where member_readed.message_id=%s.message_id and member_readed.uid=?) readed", table), loginUID),
+		dbr.Expr(fmt.Sprintf("(select created_at from member_readed where member_readed.message_id=%s.message_id and member_readed.uid=?) readed_at", table), loginUID),
+	)
+	return builder
+}
 
-	return models, err
+// markChannelWritten 频道的消息扩展刚修改过 之后短时间内同步消息扩展使用主库
+func markChannelWritten(md *messageExtraModel) {
+	replica.MarkWritten(replica.ChannelKey(md.ChannelID, md.ChannelType))
 }
 
 type messageExtraDetailModelSlice []*messageExtraDetailModel
@@ -136,3 +258,7 @@ type messageExtraModel struct {
 	IsPinned        int   // 是否置顶
 	db.BaseModel
 }
+
+func (m *messageExtraModel) extraMessageID() string {
+	return m.MessageID
+}
@@ -48,6 +48,13 @@ func (d *messageReactionDB) queryReactionWithUIDAndMessageID(uid string, message
 }
 
 // 新增回应
+// queryWithUID 用户对消息的回应（包括已取消的）
+func (d *messageReactionDB) queryWithUID(uid string, limit uint64) ([]*reactionModel, error) {
+	var models []*reactionModel
+	_, err := d.session.Select("*").From("reaction_users").Where("uid=?", uid).OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
 func (d *messageReactionDB) insertReaction(model *reactionModel) error {
 	_, err := d.session.InsertInto("reaction_users").Columns(util.AttrToUnderscore(model)...).Record(model).Exec()
 	return err
@@ -50,6 +50,13 @@ func (m *messageUserExtraDB) queryWithMessageIDsAndUID(messageIDs []string, uid
 	return models, err
 }
 
+// queryWithUID 用户删除的消息和已读的语音
+func (m *messageUserExtraDB) queryWithUID(uid string, limit uint64) ([]*messageUserExtraModel, error) {
+	var models []*messageUserExtraModel
+	_, err := m.session.Select("*").From(m.getTable(uid)).Where("uid=?", uid).OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
 func (m *messageUserExtraDB) getTable(uid string) string {
 	tableIndex := crc32.ChecksumIEEE([]byte(uid)) % uint32(m.ctx.GetConfig().TablePartitionConfig.MessageUserEditTableCount)
 	if tableIndex == 0 {
@@ -81,6 +81,13 @@ func (r *remindersDB) sync(uid string, version int64, limit uint64, channelIDs [
 	return models, err
 }
 
+// queryWithUID 提醒了用户和用户发布的提醒 不包括提醒所有人的
+func (r *remindersDB) queryWithUID(uid string, limit uint64) ([]*remindersModel, error) {
+	var models []*remindersModel
+	_, err := r.session.Select("*").From("reminders").Where("uid=? or publisher=?", uid, uid).OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
 func (r *remindersDB) insertDonesTx(ids []int64, uid string, tx *dbr.Tx) error {
 	for _, id := range ids {
 		_, err := tx.InsertBySql("insert ignore  into reminder_done(reminder_id,uid) values(?,?)", id, uid).Exec()
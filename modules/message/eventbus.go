@@ -0,0 +1,64 @@
+package message
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/eventbus"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+)
+
+// publishSentMessages 发布消息发送的领域事件 不存储和只同步一次的消息（命令消息等）不发布
+func (m *Message) publishSentMessages(messages []*config.MessageResp) {
+	if !eventbus.Enabled(eventbus.MessageSent) {
+		return
+	}
+	includePayload := extconfig.Get().EventBus.IncludeMessagePayload
+	events := make([]*eventbus.Event, 0, len(messages))
+	for _, message := range messages {
+		if message.Header.NoPersist == 1 || message.Header.SyncOnce == 1 {
+			continue
+		}
+		data := &eventbus.MessageSentData{
+			MessageID:   strconv.FormatInt(message.MessageID, 10),
+			MessageSeq:  message.MessageSeq,
+			ClientMsgNo: message.ClientMsgNo,
+			FromUID:     message.FromUID,
+			ChannelID:   message.ChannelID,
+			ChannelType: message.ChannelType,
+			Timestamp:   message.Timestamp,
+		}
+		// GetContentType在没有type时会panic
+		if payloadMap, err := message.GetPayloadMap(); err == nil {
+			if contentType, ok := payloadMap["type"].(json.Number); ok {
+				contentTypeInt64, _ := contentType.Int64()
+				data.ContentType = int(contentTypeInt64)
+			}
+		}
+		if includePayload {
+			data.Payload = message.Payload
+		}
+		events = append(events, eventbus.NewEvent(eventbus.MessageSent, eventChannelKey(message.FromUID, message.ChannelID, message.ChannelType), data))
+	}
+	eventbus.Publish(events...)
+}
+
+// publishRevokedMessages 发布消息撤回的领域事件 个人频道的channelID为对方uid
+func publishRevokedMessages(operator string, channelID string, channelType uint8, messageIDs []string) {
+	eventbus.Publish(eventbus.NewEvent(eventbus.MessageRevoked, eventChannelKey(operator, channelID, channelType), &eventbus.MessageRevokedData{
+		MessageIDs:  messageIDs,
+		ChannelID:   channelID,
+		ChannelType: channelType,
+		Operator:    operator,
+	}))
+}
+
+// eventChannelKey 事件的分区键 个人频道双方的消息使用同一个key
+func eventChannelKey(uid string, channelID string, channelType uint8) string {
+	if channelType == common.ChannelTypePerson.Uint8() {
+		return common.GetFakeChannelIDWith(uid, channelID)
+	}
+	return channelID
+}
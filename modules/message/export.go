@@ -0,0 +1,130 @@
+package message
+
+// ExportData 用户的消息相关数据（用于合规导出） 消息正文保存在悟空IM 不在导出范围内
+// 每一项最多导出limit条 数量等于limit时可能没有导出完整
+type ExportData struct {
+	Conversations []*ExportConversation `json:"conversations"` // 会话的阅读位置和草稿
+	Reactions     []*ExportReaction     `json:"reactions"`     // 对消息的回应
+	MessageFlags  []*ExportMessageFlag  `json:"message_flags"` // 删除的消息和已读的语音
+	Reminders     []*ExportReminder     `json:"reminders"`     // 提醒了用户和用户发布的提醒
+}
+
+// ExportConversation 会话扩展
+type ExportConversation struct {
+	ChannelID   string `json:"channel_id"`
+	ChannelType uint8  `json:"channel_type"`
+	BrowseTo    uint32 `json:"browse_to"` // 阅读到的消息序号
+	Draft       string `json:"draft"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// ExportReaction 消息回应
+type ExportReaction struct {
+	ChannelID   string `json:"channel_id"`
+	ChannelType uint8  `json:"channel_type"`
+	MessageID   string `json:"message_id"`
+	Emoji       string `json:"emoji"`
+	IsDeleted   int    `json:"is_deleted"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ExportMessageFlag 用户对消息的标记
+type ExportMessageFlag struct {
+	ChannelID        string `json:"channel_id"`
+	ChannelType      uint8  `json:"channel_type"`
+	MessageID        string `json:"message_id"`
+	MessageSeq       uint32 `json:"message_seq"`
+	VoiceReaded      int    `json:"voice_readed"`
+	MessageIsDeleted int    `json:"message_is_deleted"`
+	UpdatedAt        string `json:"updated_at"`
+}
+
+// ExportReminder 提醒
+type ExportReminder struct {
+	ChannelID    string `json:"channel_id"`
+	ChannelType  uint8  `json:"channel_type"`
+	MessageID    string `json:"message_id"`
+	MessageSeq   uint32 `json:"message_seq"`
+	ReminderType int    `json:"reminder_type"`
+	Publisher    string `json:"publisher"`
+	UID          string `json:"uid"`
+	Text         string `json:"text"`
+	IsDeleted    int    `json:"is_deleted"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// GetExportData 导出用户的消息相关数据
+func (s *Service) GetExportData(uid string, limit int) (*ExportData, error) {
+	max := uint64(limit)
+	data := &ExportData{
+		Conversations: make([]*ExportConversation, 0),
+		Reactions:     make([]*ExportReaction, 0),
+		MessageFlags:  make([]*ExportMessageFlag, 0),
+		Reminders:     make([]*ExportReminder, 0),
+	}
+
+	conversations, err := s.message.conversationExtradb.queryWithUID(uid, max)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range conversations {
+		data.Conversations = append(data.Conversations, &ExportConversation{
+			ChannelID:   m.ChannelID,
+			ChannelType: m.ChannelType,
+			BrowseTo:    m.BrowseTo,
+			Draft:       m.Draft,
+			UpdatedAt:   m.UpdatedAt.String(),
+		})
+	}
+
+	reactions, err := s.message.messageReactionDB.queryWithUID(uid, max)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range reactions {
+		data.Reactions = append(data.Reactions, &ExportReaction{
+			ChannelID:   m.ChannelID,
+			ChannelType: m.ChannelType,
+			MessageID:   m.MessageID,
+			Emoji:       m.Emoji,
+			IsDeleted:   m.IsDeleted,
+			CreatedAt:   m.CreatedAt.String(),
+		})
+	}
+
+	flags, err := s.message.messageUserExtraDB.queryWithUID(uid, max)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range flags {
+		data.MessageFlags = append(data.MessageFlags, &ExportMessageFlag{
+			ChannelID:        m.ChannelID,
+			ChannelType:      m.ChannelType,
+			MessageID:        m.MessageID,
+			MessageSeq:       m.MessageSeq,
+			VoiceReaded:      m.VoiceReaded,
+			MessageIsDeleted: m.MessageIsDeleted,
+			UpdatedAt:        m.UpdatedAt.String(),
+		})
+	}
+
+	reminders, err := s.message.remindersDB.queryWithUID(uid, max)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range reminders {
+		data.Reminders = append(data.Reminders, &ExportReminder{
+			ChannelID:    m.ChannelID,
+			ChannelType:  m.ChannelType,
+			MessageID:    m.MessageID,
+			MessageSeq:   m.MessageSeq,
+			ReminderType: m.ReminderType,
+			Publisher:    m.Publisher,
+			UID:          m.UID,
+			Text:         m.Text,
+			IsDeleted:    m.IsDeleted,
+			CreatedAt:    m.CreatedAt.String(),
+		})
+	}
+	return data, nil
+}
@@ -0,0 +1,278 @@
+package message
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sync/atomic"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+	"go.uber.org/zap"
+)
+
+// 消息扩展分表
+// message_extra按channel_id分到TableCount张表 第0张为原来的message_extra 其余为message_extra1、message_extra2...
+// 分表数量从1改为大于1后 原表中属于其他分表的数据由迁移任务分批移动到分表 迁移完成前：
+// 1. 写入前先把原表中的数据复制到分表 分表中有的数据总是最新的
+// 2. 读取时同时读取分表和原表 同一条消息以分表中的为准
+
+const legacyExtraTable = "message_extra"
+
+// extraColumns 迁移时复制的字段 不包含id 分表的id是自增的
+const extraColumns = "message_id,message_seq,from_uid,channel_id,channel_type,`revoke`,revoker,clone_no,`version`,readed_count,is_deleted,content_edit,content_edit_hash,edited_at,is_pinned,created_at,updated_at"
+
+// extraMigrating 是否正在把原表的数据迁移到分表 所有messageExtraDB共用
+var extraMigrating atomic.Bool
+
+func extraTableCount() int {
+	count := extconfig.Get().MessageExtraShard.TableCount
+	if count < 1 {
+		return 1
+	}
+	return count
+}
+
+// extraTable 频道的消息扩展所在的表
+func extraTable(channelID string, count int) string {
+	if count <= 1 {
+		return legacyExtraTable
+	}
+	tableIndex := crc32.ChecksumIEEE([]byte(channelID)) % uint32(count)
+	if tableIndex == 0 {
+		return legacyExtraTable
+	}
+	return fmt.Sprintf("%s%d", legacyExtraTable, tableIndex)
+}
+
+// allExtraTables 所有的分表 原表在最后 合并结果时分表中的数据优先
+func allExtraTables(count int) []string {
+	tables := make([]string, 0, count)
+	for i := 1; i < count; i++ {
+		tables = append(tables, fmt.Sprintf("%s%d", legacyExtraTable, i))
+	}
+	return append(tables, legacyExtraTable)
+}
+
+type extraRow interface {
+	extraMessageID() string
+}
+
+// mergeExtras 合并多张表的查询结果 同一条消息只保留先出现的
+func mergeExtras[T extraRow](groups ...[]T) []T {
+	if len(groups) == 1 {
+		return groups[0]
+	}
+	exists := map[string]struct{}{}
+	merged := make([]T, 0)
+	for _, models := range groups {
+		for _, model := range models {
+			if _, ok := exists[model.extraMessageID()]; ok {
+				continue
+			}
+			exists[model.extraMessageID()] = struct{}{}
+			merged = append(merged, model)
+		}
+	}
+	return merged
+}
+
+// setupExtraShard 创建分表并读取迁移进度
+// 分表数量只能从1修改为大于1 已经按其他数量分过表时不能再修改 否则数据会分到错误的表中
+func (m *Message) setupExtraShard() error {
+	count := extraTableCount()
+	if count <= 1 {
+		return nil
+	}
+	shards, err := m.extraShardDB.queryAll()
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if shard.TableCount != count {
+			return fmt.Errorf("消息扩展已经分为%d张表，不支持修改为%d张", shard.TableCount, count)
+		}
+	}
+	for _, table := range allExtraTables(count) {
+		if table == legacyExtraTable {
+			continue
+		}
+		if err = m.extraShardDB.createTable(table); err != nil {
+			return err
+		}
+	}
+	if err = m.extraShardDB.insertIgnore(count); err != nil {
+		return err
+	}
+	extraMigrating.Store(true)
+	return m.refreshExtraMigrating(count)
+}
+
+// refreshExtraMigrating 其他实例完成迁移后 当前实例不再读取原表
+func (m *Message) refreshExtraMigrating(count int) error {
+	shard, err := m.extraShardDB.query(count)
+	if err != nil {
+		return err
+	}
+	extraMigrating.Store(shard == nil || shard.FinishedAt == 0)
+	return nil
+}
+
+// migrateExtraShard 把原表中属于其他分表的数据分批移动到分表
+// 多个实例同时迁移同一批数据也不会出错：复制时分表中已有的数据不覆盖 删除的只是原表中已经复制过的数据
+func (m *Message) migrateExtraShard() {
+	count := extraTableCount()
+	if count <= 1 || !extraMigrating.Load() {
+		return
+	}
+	if !m.migratingExtra.CompareAndSwap(false, true) {
+		return
+	}
+	defer m.migratingExtra.Store(false)
+
+	if err := m.refreshExtraMigrating(count); err != nil {
+		m.Error("查询消息扩展迁移进度失败！", zap.Error(err))
+		return
+	}
+	if !extraMigrating.Load() {
+		return
+	}
+	shard, err := m.extraShardDB.query(count)
+	if err != nil || shard == nil {
+		m.Error("查询消息扩展迁移进度失败！", zap.Error(err))
+		return
+	}
+	batchSize := extconfig.Get().MessageExtraShard.MigrateBatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	rows, err := m.extraShardDB.queryLegacyRows(shard.LastID, uint64(batchSize))
+	if err != nil {
+		m.Error("查询需要迁移的消息扩展失败！", zap.Error(err))
+		return
+	}
+	if len(rows) == 0 {
+		if err = m.extraShardDB.updateFinished(count, time.Now().Unix()); err != nil {
+			m.Error("记录消息扩展迁移完成失败！", zap.Error(err))
+			return
+		}
+		extraMigrating.Store(false)
+		m.Info("消息扩展迁移到分表完成", zap.Int("tableCount", count), zap.Int64("migratedCount", shard.MigratedCount))
+		return
+	}
+	idsOfTable := map[string][]int64{}
+	for _, row := range rows {
+		table := extraTable(row.ChannelID, count)
+		if table == legacyExtraTable {
+			continue
+		}
+		idsOfTable[table] = append(idsOfTable[table], row.ID)
+	}
+	var migrated int64
+	for table, ids := range idsOfTable {
+		moved, err := m.extraShardDB.moveTo(table, ids)
+		if err != nil {
+			m.Error("迁移消息扩展失败！", zap.Error(err), zap.String("table", table))
+			return
+		}
+		migrated += moved
+	}
+	if err = m.extraShardDB.updateProgress(count, rows[len(rows)-1].ID, migrated); err != nil {
+		m.Error("更新消息扩展迁移进度失败！", zap.Error(err))
+	}
+}
+
+// extraShardDB 消息扩展分表的迁移进度
+type extraShardDB struct {
+	session *dbr.Session
+}
+
+func newExtraShardDB(ctx *config.Context) *extraShardDB {
+	return &extraShardDB{
+		session: ctx.DB(),
+	}
+}
+
+func (e *extraShardDB) queryAll() ([]*extraShardModel, error) {
+	var models []*extraShardModel
+	_, err := e.session.Select("*").From("message_extra_shard").Load(&models)
+	return models, err
+}
+
+func (e *extraShardDB) query(tableCount int) (*extraShardModel, error) {
+	var model *extraShardModel
+	_, err := e.session.Select("*").From("message_extra_shard").Where("table_count=?", tableCount).Load(&model)
+	return model, err
+}
+
+func (e *extraShardDB) insertIgnore(tableCount int) error {
+	_, err := e.session.InsertBySql("INSERT IGNORE INTO message_extra_shard (table_count) VALUES (?)", tableCount).Exec()
+	return err
+}
+
+// queryLegacyRows 原表中id大于lastID的一批数据
+func (e *extraShardDB) queryLegacyRows(lastID int64, limit uint64) ([]*extraShardRowModel, error) {
+	var models []*extraShardRowModel
+	_, err := e.session.Select("id,channel_id").From(legacyExtraTable).Where("id>?", lastID).OrderAsc("id").Limit(limit).Load(&models)
+	return models, err
+}
+
+// createTable 创建分表 结构和索引与原表相同
+func (e *extraShardDB) createTable(table string) error {
+	_, err := e.session.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE %s", table, legacyExtraTable))
+	return err
+}
+
+// moveTo 把原表中的数据复制到分表后从原表删除 返回删除的条数 分表中已有的数据比原表新 不覆盖
+func (e *extraShardDB) moveTo(table string, ids []int64) (int64, error) {
+	tx, err := e.session.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.RollbackUnlessCommitted()
+	_, err = tx.InsertBySql(fmt.Sprintf("INSERT IGNORE INTO %s (%s) SELECT %s FROM %s WHERE id in ?", table, extraColumns, extraColumns, legacyExtraTable), ids).Exec()
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.DeleteFrom(legacyExtraTable).Where("id in ?", ids).Exec()
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+func (e *extraShardDB) updateProgress(tableCount int, lastID int64, migrated int64) error {
+	_, err := e.session.UpdateBySql("UPDATE message_extra_shard SET last_id=GREATEST(last_id,?),migrated_count=migrated_count+? WHERE table_count=?", lastID, migrated, tableCount).Exec()
+	return err
+}
+
+func (e *extraShardDB) updateFinished(tableCount int, finishedAt int64) error {
+	_, err := e.session.Update("message_extra_shard").Set("finished_at", finishedAt).Where("table_count=? and finished_at=0", tableCount).Exec()
+	return err
+}
+
+// queryLegacyRemaining 原表中还没有迁移的数据条数（估算 包含本来就属于原表的数据）
+func (e *extraShardDB) queryLegacyRemaining(lastID int64) (int64, error) {
+	var count int64
+	_, err := e.session.Select("count(*)").From(legacyExtraTable).Where("id>?", lastID).Load(&count)
+	return count, err
+}
+
+type extraShardModel struct {
+	TableCount    int
+	LastID        int64
+	MigratedCount int64
+	FinishedAt    int64
+	db.BaseModel
+}
+
+type extraShardRowModel struct {
+	ID        int64
+	ChannelID string
+}
@@ -0,0 +1,54 @@
+package message
+
+import (
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/gocraft/dbr/v2/dialect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtraTable(t *testing.T) {
+	assert.Equal(t, "message_extra", extraTable("g1", 1))
+	assert.Equal(t, "message_extra", extraTable("g1", 0))
+
+	for _, channelID := range []string{"g1", "g2", "u1@u2", "abc"} {
+		index := crc32.ChecksumIEEE([]byte(channelID)) % 4
+		expect := "message_extra"
+		if index > 0 {
+			expect = fmt.Sprintf("message_extra%d", index)
+		}
+		assert.Equal(t, expect, extraTable(channelID, 4))
+	}
+
+	assert.Equal(t, []string{"message_extra"}, allExtraTables(1))
+	assert.Equal(t, []string{"message_extra1", "message_extra2", "message_extra"}, allExtraTables(3))
+}
+
+func TestMergeExtras(t *testing.T) {
+	sharded := []*messageExtraModel{{MessageID: "1", Revoke: 1}, {MessageID: "2"}}
+	legacy := []*messageExtraModel{{MessageID: "1"}, {MessageID: "3"}}
+
+	merged := mergeExtras(sharded, legacy)
+	assert.Len(t, merged, 3)
+	assert.Equal(t, "1", merged[0].MessageID)
+	assert.Equal(t, 1, merged[0].Revoke)
+	assert.Equal(t, "3", merged[2].MessageID)
+
+	details := mergeExtras([]*messageExtraDetailModel{{messageExtraModel: messageExtraModel{MessageID: "1"}}})
+	assert.Len(t, details, 1)
+}
+
+func TestSelectExtraDetails(t *testing.T) {
+	session := &dbr.Session{Connection: &dbr.Connection{Dialect: dialect.MySQL}, EventReceiver: &dbr.NullEventReceiver{}}
+	builder := selectExtraDetails(session, "message_extra", "u1' or '1'='1").From("message_extra")
+	buf := dbr.NewBuffer()
+	assert.NoError(t, builder.Build(dialect.MySQL, buf))
+	query, err := dbr.InterpolateForDialect(buf.String(), buf.Value(), dialect.MySQL)
+	assert.NoError(t, err)
+	// 登录用户的uid作为参数转义 不能改变语句
+	assert.Contains(t, query, `member_readed.uid='u1\' or \'1\'=\'1'`)
+	assert.NotContains(t, query, `uid='u1' or`)
+}
@@ -0,0 +1,60 @@
+package message
+
+import (
+	"context"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rpcapi"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// messageRPC 内部gRPC接口的消息服务
+type messageRPC struct {
+	rpcapi.UnimplementedMessageServiceServer
+	m *Message
+}
+
+func newMessageRPC(m *Message) *messageRPC {
+	return &messageRPC{m: m}
+}
+
+// GetMessageExtras 查询频道内消息的扩展
+func (r *messageRPC) GetMessageExtras(ctx context.Context, req *rpcapi.GetMessageExtrasReq) (*rpcapi.GetMessageExtrasResp, error) {
+	if req.ChannelId == "" {
+		return nil, status.Error(codes.InvalidArgument, "频道ID不能为空！")
+	}
+	fakeChannelID := req.ChannelId
+	if uint8(req.ChannelType) == common.ChannelTypePerson.Uint8() {
+		if req.LoginUid == "" {
+			return nil, status.Error(codes.InvalidArgument, "个人频道的查询者不能为空！")
+		}
+		fakeChannelID = common.GetFakeChannelIDWith(req.LoginUid, req.ChannelId)
+	}
+	extras, err := r.m.messageExtraDB.queryWithChannelMessageIDs(fakeChannelID, req.MessageIds, req.LoginUid)
+	if err != nil {
+		r.m.Error("查询消息扩展失败！", zap.Error(err), zap.String("channelID", req.ChannelId))
+		return nil, status.Error(codes.Internal, "查询消息扩展失败！")
+	}
+	resp := &rpcapi.GetMessageExtrasResp{
+		Extras: make([]*rpcapi.MessageExtra, 0, len(extras)),
+	}
+	for _, extra := range extras {
+		resp.Extras = append(resp.Extras, &rpcapi.MessageExtra{
+			MessageId:   extra.MessageID,
+			MessageSeq:  extra.MessageSeq,
+			FromUid:     extra.FromUID,
+			Revoke:      extra.Revoke == 1,
+			Revoker:     extra.Revoker,
+			ReadedCount: int64(extra.ReadedCount),
+			Readed:      extra.Readed == 1,
+			IsDeleted:   extra.IsDeleted == 1,
+			ContentEdit: extra.ContentEdit.String,
+			EditedAt:    int64(extra.EditedAt),
+			IsPinned:    extra.IsPinned == 1,
+			Version:     extra.Version,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,61 @@
+package message
+
+import (
+	"context"
+	"errors"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/jobqueue"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// 后台任务的队列
+const (
+	jobQueueMessage = "message" // 管理员代发消息
+	jobQueueCleanup = "cleanup" // 过期数据清理
+)
+
+// 后台任务的类型
+const (
+	JobTypeSendBatch           = "message.send_batch"            // 给一批用户发送消息
+	JobTypePurgeDissolvedGroup = "message.purge_dissolved_group" // 删除解散群保留时长到期的消息
+)
+
+type sendBatchJob struct {
+	FromUID string   `json:"from_uid"`
+	Content string   `json:"content"`
+	UIDs    []string `json:"uids"`
+}
+
+type purgeDissolvedGroupJob struct {
+	ID      int64  `json:"id"` // 解散记录的id
+	GroupNo string `json:"group_no"`
+}
+
+// handleSendBatchJob 给一批用户发送文本消息
+func (m *Manager) handleSendBatchJob(ctx context.Context, job *jobqueue.Job) error {
+	var req sendBatchJob
+	if err := job.Bind(&req); err != nil {
+		return err
+	}
+	if len(req.UIDs) == 0 {
+		return nil
+	}
+	err := m.ctx.SendMessageBatch(&config.MsgSendBatch{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		FromUID: req.FromUID,
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": req.Content,
+			"type":    1,
+		})),
+		Subscribers: req.UIDs,
+	})
+	if err != nil {
+		m.Error("发送消息错误", zap.Error(err))
+		return errors.New("发送消息错误")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package message
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"go.uber.org/zap"
+)
+
+const (
+	// messageCountPrefix 每天发送的消息数 hash messageCount:{日期} count => 消息数 用于统计模块每晚汇总
+	messageCountPrefix = "messageCount:"
+	// messageCountExpire 统计模块会补汇总最近几天的数据 保留一段时间
+	messageCountExpire     = time.Hour * 24 * 8
+	messageCountDateLayout = "2006-01-02"
+)
+
+// countMessages 按发送日期累加消息数
+func (m *Message) countMessages(messages []*config.MessageResp) {
+	for date, count := range messageCountByDate(messages) {
+		key := fmt.Sprintf("%s%s", messageCountPrefix, date)
+		if _, err := m.ctx.GetRedisConn().Hincrby(key, "count", count); err != nil {
+			m.Warn("累加消息数失败！", zap.Error(err), zap.String("date", date))
+			continue
+		}
+		if err := m.ctx.GetRedisConn().Expire(key, messageCountExpire); err != nil {
+			m.Warn("设置消息数过期时间失败！", zap.Error(err), zap.String("date", date))
+		}
+	}
+}
+
+// messageCountByDate 消息按发送日期分组的数量 同一批消息通常是同一天的
+func messageCountByDate(messages []*config.MessageResp) map[string]int {
+	countMap := map[string]int{}
+	for _, message := range messages {
+		if message.Timestamp <= 0 {
+			continue
+		}
+		date := time.Unix(int64(message.Timestamp), 0).Format(messageCountDateLayout)
+		countMap[date]++
+	}
+	return countMap
+}
+
+// GetMessageCountWithDate 某天发送的消息数 date格式为2006-01-02
+func (s *Service) GetMessageCountWithDate(date string) (int64, error) {
+	countStr, err := s.ctx.GetRedisConn().Hget(fmt.Sprintf("%s%s", messageCountPrefix, date), "count")
+	if err != nil {
+		return 0, err
+	}
+	if countStr == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(countStr, 10, 64)
+}
@@ -0,0 +1,21 @@
+package message
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageCountByDate(t *testing.T) {
+	day1 := time.Date(2026, 10, 13, 23, 59, 59, 0, time.Local)
+	day2 := time.Date(2026, 10, 14, 0, 0, 1, 0, time.Local)
+	countMap := messageCountByDate([]*config.MessageResp{
+		{Timestamp: int32(day1.Unix())},
+		{Timestamp: int32(day2.Unix())},
+		{Timestamp: int32(day2.Unix())},
+		{Timestamp: 0},
+	})
+	assert.Equal(t, map[string]int{"2026-10-13": 1, "2026-10-14": 2}, countMap)
+}
@@ -0,0 +1,203 @@
+package message
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// fillPlayableVideos 视频消息转码完成后 把payload里的视频地址替换为转码后的地址 原地址放到origin_url
+func fillPlayableVideos(fileService file.IService, messages []*MsgSyncResp) {
+	if len(messages) == 0 {
+		return
+	}
+	paths := make([]string, 0)
+	for _, message := range messages {
+		if videoPath := videoPathOfPayload(message.Payload); videoPath != "" {
+			paths = append(paths, videoPath)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	videos, err := fileService.PlayableVideos(paths)
+	if err != nil {
+		log.Warn("查询转码后的视频失败！", zap.Error(err))
+		return
+	}
+	if len(videos) == 0 {
+		return
+	}
+	for _, message := range messages {
+		videoPath := videoPathOfPayload(message.Payload)
+		if videoPath == "" {
+			continue
+		}
+		video := videos[videoPath]
+		if video == nil {
+			continue
+		}
+		message.Payload["origin_url"] = message.Payload["url"]
+		message.Payload["url"] = video.URL
+		if cover, _ := message.Payload["cover"].(string); cover == "" {
+			message.Payload["cover"] = video.Cover
+		}
+		if video.Width > 0 && video.Height > 0 {
+			message.Payload["width"] = video.Width
+			message.Payload["height"] = video.Height
+		}
+		secondNumber, _ := message.Payload["second"].(json.Number)
+		if second, _ := secondNumber.Int64(); second == 0 && video.Duration > 0 {
+			message.Payload["second"] = video.Duration
+		}
+	}
+}
+
+// videoPathOfPayload 视频消息的文件路径 例如 file/preview/chat/1/xxx.mov 返回 chat/1/xxx.mov
+func videoPathOfPayload(payload map[string]interface{}) string {
+	if len(payload) == 0 {
+		return ""
+	}
+	contentTypeNumber, ok := payload["type"].(json.Number)
+	if !ok {
+		return ""
+	}
+	contentType, _ := contentTypeNumber.Int64()
+	if int(contentType) != common.Video.Int() {
+		return ""
+	}
+	if _, ok := payload["origin_url"]; ok {
+		return ""
+	}
+	videoURL, _ := payload["url"].(string)
+	return previewPathOfURL(videoURL)
+}
+
+// previewPathOfURL 文件预览地址的文件路径 不是预览地址时返回空
+func previewPathOfURL(fileURL string) string {
+	idx := strings.Index(fileURL, "file/preview/")
+	if idx < 0 {
+		return ""
+	}
+	filePath := fileURL[idx+len("file/preview/"):]
+	if queryIdx := strings.Index(filePath, "?"); queryIdx >= 0 {
+		filePath = filePath[:queryIdx]
+	}
+	return strings.TrimPrefix(filePath, "/")
+}
+
+// fillVoiceWaveforms 语音消息没有带波形时 填上服务端计算的时长和波形
+func fillVoiceWaveforms(fileService file.IService, messages []*MsgSyncResp) {
+	paths := make([]string, 0)
+	for _, message := range messages {
+		if voicePath := voicePathOfPayload(message.Payload); voicePath != "" {
+			paths = append(paths, voicePath)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	waveforms, err := fileService.VoiceWaveforms(paths)
+	if err != nil {
+		log.Warn("查询语音的波形失败！", zap.Error(err))
+		return
+	}
+	if len(waveforms) == 0 {
+		return
+	}
+	for _, message := range messages {
+		voicePath := voicePathOfPayload(message.Payload)
+		if voicePath == "" {
+			continue
+		}
+		waveform := waveforms[voicePath]
+		if waveform == nil {
+			continue
+		}
+		message.Payload["waveform"] = waveform.Waveform
+		timeTradNumber, _ := message.Payload["timeTrad"].(json.Number)
+		if timeTrad, _ := timeTradNumber.Int64(); timeTrad == 0 && waveform.Duration > 0 {
+			message.Payload["timeTrad"] = waveform.Duration
+		}
+	}
+}
+
+// voicePathOfPayload 没有波形的语音消息的文件路径
+func voicePathOfPayload(payload map[string]interface{}) string {
+	if len(payload) == 0 {
+		return ""
+	}
+	contentTypeNumber, ok := payload["type"].(json.Number)
+	if !ok {
+		return ""
+	}
+	contentType, _ := contentTypeNumber.Int64()
+	if int(contentType) != common.Voice.Int() {
+		return ""
+	}
+	if waveform, _ := payload["waveform"].(string); waveform != "" {
+		return ""
+	}
+	voiceURL, _ := payload["url"].(string)
+	return previewPathOfURL(voiceURL)
+}
+
+// signPayloadURLs 给图片、语音、视频和文件消息的地址加上签名 签名地址过期后客户端通过/v1/file/sign重新获取
+func signPayloadURLs(fileService file.IService, loginUID string, messages []*MsgSyncResp) {
+	for _, message := range messages {
+		if len(message.Payload) == 0 {
+			continue
+		}
+		contentTypeNumber, ok := message.Payload["type"].(json.Number)
+		if !ok {
+			continue
+		}
+		contentType, _ := contentTypeNumber.Int64()
+		switch common.ContentType(contentType) {
+		case common.Image, common.GIF, common.Voice, common.Video, common.File:
+		default:
+			continue
+		}
+		for _, key := range []string{"url", "cover", "origin_url"} {
+			if fileURL, _ := message.Payload[key].(string); fileURL != "" {
+				message.Payload[key] = fileService.SignURL(fileURL, loginUID)
+			}
+		}
+	}
+}
+
+// payloadFilePaths 图片、语音、视频和文件消息中的文件路径
+func payloadFilePaths(messages []*messageModel) []string {
+	paths := make([]string, 0)
+	for _, message := range messages {
+		if len(message.Payload) == 0 {
+			continue
+		}
+		var payload map[string]interface{}
+		if err := util.ReadJsonByByte(message.Payload, &payload); err != nil {
+			continue
+		}
+		contentTypeNumber, ok := payload["type"].(json.Number)
+		if !ok {
+			continue
+		}
+		contentType, _ := contentTypeNumber.Int64()
+		switch common.ContentType(contentType) {
+		case common.Image, common.GIF, common.Voice, common.Video, common.File:
+		default:
+			continue
+		}
+		for _, key := range []string{"url", "cover", "origin_url"} {
+			fileURL, _ := payload[key].(string)
+			if filePath := previewPathOfURL(fileURL); filePath != "" {
+				paths = append(paths, filePath)
+			}
+		}
+	}
+	return paths
+}
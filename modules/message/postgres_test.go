@@ -0,0 +1,65 @@
+package message
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/postgres/pgtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPostgresOffset 消息偏移和会话扩展的语句在PostgreSQL上执行 需要设置TSDD_TEST_POSTGRES
+func TestPostgresOffset(t *testing.T) {
+	ctx := pgtest.NewTestContext(t)
+
+	lastOffsetDB := newUserLastOffsetDB(ctx)
+	tx, err := ctx.DB().Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, lastOffsetDB.insertOrUpdateTx(tx, &userLastOffsetModel{UID: "u1", ChannelID: "c1", ChannelType: 2, MessageSeq: 10}))
+	// 只会增大
+	assert.NoError(t, lastOffsetDB.insertOrUpdateTx(tx, &userLastOffsetModel{UID: "u1", ChannelID: "c1", ChannelType: 2, MessageSeq: 5}))
+	assert.NoError(t, tx.Commit())
+	offsets, err := lastOffsetDB.queryWithUID("u1")
+	assert.NoError(t, err)
+	assert.Len(t, offsets, 1)
+	assert.Equal(t, int64(10), offsets[0].MessageSeq)
+
+	channelOffsetDB := newChannelOffsetDB(ctx)
+	assert.NoError(t, channelOffsetDB.insertOrUpdate(&channelOffsetModel{UID: "u1", ChannelID: "c1", ChannelType: 2, MessageSeq: 3}))
+	assert.NoError(t, channelOffsetDB.insertOrUpdate(&channelOffsetModel{UID: "u1", ChannelID: "c1", ChannelType: 2, MessageSeq: 8}))
+	assert.NoError(t, channelOffsetDB.insertOrUpdate(&channelOffsetModel{UID: "u1", ChannelID: "c2", ChannelType: 1, MessageSeq: 1}))
+	channelOffset, err := channelOffsetDB.queryWithUIDAndChannel("u1", "c1", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8), channelOffset.MessageSeq)
+	channelOffsets, err := channelOffsetDB.queryWithUIDAndChannelIDs("u1", []string{"c1", "c2"})
+	assert.NoError(t, err)
+	assert.Len(t, channelOffsets, 2)
+}
+
+func TestPostgresConversationExtra(t *testing.T) {
+	ctx := pgtest.NewTestContext(t)
+	d := newConversationExtraDB(ctx)
+
+	assert.NoError(t, d.insertOrUpdate(&conversationExtraModel{UID: "u1", ChannelID: "c1", ChannelType: 2, BrowseTo: 20, Draft: "草稿'1", Version: 1}))
+	// 阅读位置只会增大 其他字段覆盖
+	assert.NoError(t, d.insertOrUpdate(&conversationExtraModel{UID: "u1", ChannelID: "c1", ChannelType: 2, BrowseTo: 10, KeepMessageSeq: 5, Draft: "草稿2", Version: 2}))
+	assert.NoError(t, d.insertOrUpdate(&conversationExtraModel{UID: "u1", ChannelID: "c2", ChannelType: 1, Version: 3}))
+
+	models, err := d.queryWithChannelIDs("u1", []string{"c1"})
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	assert.Equal(t, uint32(20), models[0].BrowseTo)
+	assert.Equal(t, uint32(5), models[0].KeepMessageSeq)
+	assert.Equal(t, "草稿2", models[0].Draft)
+	assert.False(t, time.Time(models[0].CreatedAt).IsZero())
+
+	models, err = d.sync("u1", 2)
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	assert.Equal(t, "c2", models[0].ChannelID)
+
+	models, err = d.queryWithUID("u1", 1)
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	assert.Equal(t, "c2", models[0].ChannelID)
+}
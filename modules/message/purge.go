@@ -0,0 +1,88 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/jobqueue"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"go.uber.org/zap"
+)
+
+// purgeGroupBatch 每次最多处理的解散群数量
+const purgeGroupBatch = 20
+
+// purgeDissolvedGroupMessages 为管理员解散后保留时长已到期的群添加删除消息的任务
+// 任务id包含解散记录的id 任务完成或失败前不会重复添加
+func (m *Message) purgeDissolvedGroupMessages() {
+	if !m.purging.CompareAndSwap(false, true) {
+		return
+	}
+	defer m.purging.Store(false)
+
+	groups, err := m.groupService.GetDissolvedToPurge(time.Now().Unix(), purgeGroupBatch)
+	if err != nil {
+		m.Error("查询需要删除消息的解散群失败！", zap.Error(err))
+		return
+	}
+	for _, g := range groups {
+		_, err = jobqueue.Enqueue(JobTypePurgeDissolvedGroup, &purgeDissolvedGroupJob{
+			ID:      g.ID,
+			GroupNo: g.GroupNo,
+		}, jobqueue.JobID(fmt.Sprintf("purge_dissolved_group:%d", g.ID)))
+		if err != nil && !errors.Is(err, jobqueue.ErrJobExists) {
+			m.Error("添加删除解散群消息的任务失败！", zap.Error(err), zap.String("groupNo", g.GroupNo))
+		}
+	}
+}
+
+// handlePurgeDissolvedGroupJob 删除解散群的消息 删除是幂等的 失败重试不会出错
+func (m *Message) handlePurgeDissolvedGroupJob(ctx context.Context, job *jobqueue.Job) error {
+	var req purgeDissolvedGroupJob
+	if err := job.Bind(&req); err != nil {
+		return err
+	}
+	batchSize := extconfig.Get().GroupDissolve.PurgeBatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	count, err := m.purgeChannelMessages(req.GroupNo, common.ChannelTypeGroup.Uint8(), uint64(batchSize))
+	if err != nil {
+		m.Error("删除解散群的消息失败！", zap.Error(err), zap.String("groupNo", req.GroupNo))
+		return err
+	}
+	if err = m.groupService.MarkDissolvedPurged(req.ID); err != nil {
+		m.Error("记录解散群的消息已删除失败！", zap.Error(err), zap.String("groupNo", req.GroupNo))
+		return err
+	}
+	m.Info("删除解散群的消息", zap.String("groupNo", req.GroupNo), zap.Int64("count", count))
+	return nil
+}
+
+// purgeChannelMessages 分批删除频道的消息和消息扩展 返回删除的消息条数
+func (m *Message) purgeChannelMessages(channelID string, channelType uint8, batchSize uint64) (int64, error) {
+	var total int64
+	for {
+		count, err := m.db.deleteChannelMessages(channelID, channelType, batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += count
+		if uint64(count) < batchSize {
+			break
+		}
+	}
+	for {
+		count, err := m.messageExtraDB.deleteWithChannel(channelID, channelType, batchSize)
+		if err != nil {
+			return total, err
+		}
+		if uint64(count) < batchSize {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,106 @@
+package message
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+)
+
+// SearchReq 检索用户或群的消息（合规调查）
+// 只传UID时检索用户在所有频道发送的消息 传GroupNo时检索群内的消息 都传时检索用户在群内发送的消息
+type SearchReq struct {
+	UID     string
+	GroupNo string
+	StartAt int64 // 消息时间的范围（秒） 包含StartAt 不包含EndAt
+	EndAt   int64
+	Limit   int
+}
+
+// SearchMessage 检索到的消息 Text为文本消息的原文 由调用方决定是否返回
+type SearchMessage struct {
+	MessageID   string `json:"message_id"`
+	MessageSeq  uint32 `json:"message_seq"`
+	ClientMsgNo string `json:"client_msg_no"`
+	FromUID     string `json:"from_uid"`
+	ChannelID   string `json:"channel_id"` // 单聊为两个用户uid组成的频道ID
+	ChannelType uint8  `json:"channel_type"`
+	ContentType int    `json:"content_type"` // 正文类型
+	Timestamp   int64  `json:"timestamp"`
+	IsDeleted   int    `json:"is_deleted"`
+	Revoke      int    `json:"revoke"` // 是否已撤回
+	Edited      int    `json:"edited"` // 是否编辑过
+	Text        string `json:"-"`
+}
+
+// SearchMessages 检索消息 按消息时间倒序 最多返回Limit条
+func (s *Service) SearchMessages(req *SearchReq) ([]*SearchMessage, error) {
+	limit := uint64(req.Limit)
+	tables := s.message.db.getTables()
+	if req.GroupNo != "" {
+		tables = []string{s.message.db.getTable(req.GroupNo)}
+	}
+	models := make([]*messageModel, 0)
+	for _, table := range tables {
+		list, err := s.message.db.searchMessages(table, req, limit)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, list...)
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		if models[i].Timestamp != models[j].Timestamp {
+			return models[i].Timestamp > models[j].Timestamp
+		}
+		return models[i].MessageID > models[j].MessageID
+	})
+	if uint64(len(models)) > limit {
+		models = models[:limit]
+	}
+	messageIDs := make([]string, 0, len(models))
+	for _, m := range models {
+		messageIDs = append(messageIDs, strconv.FormatInt(m.MessageID, 10))
+	}
+	extras, err := s.message.messageExtraDB.queryModelsWithMessageIDs(messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	extraMap := make(map[string]*messageExtraModel, len(extras))
+	for _, extra := range extras {
+		extraMap[extra.MessageID] = extra
+	}
+	results := make([]*SearchMessage, 0, len(models))
+	for _, m := range models {
+		result := newSearchMessage(m)
+		if extra := extraMap[result.MessageID]; extra != nil {
+			result.Revoke = extra.Revoke
+			if extra.ContentEdit.String != "" {
+				result.Edited = 1
+			}
+			if extra.IsDeleted == 1 {
+				result.IsDeleted = 1
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func newSearchMessage(m *messageModel) *SearchMessage {
+	result := &SearchMessage{
+		MessageID:   strconv.FormatInt(m.MessageID, 10),
+		MessageSeq:  m.MessageSeq,
+		ClientMsgNo: m.ClientMsgNo,
+		FromUID:     m.FromUID,
+		ChannelID:   m.ChannelID,
+		ChannelType: m.ChannelType,
+		Timestamp:   m.Timestamp,
+		IsDeleted:   m.IsDeleted,
+	}
+	var payloadMap map[string]interface{}
+	if err := util.ReadJsonByByte(m.Payload, &payloadMap); err == nil && payloadMap != nil {
+		result.ContentType = payloadContentType(payloadMap["type"])
+		result.Text, _ = sensitiveTextContent(payloadMap)
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/sensitive"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// checkSensitiveMessages 检查文本消息中的敏感词
+// block的消息撤回 replace的消息编辑为替换后的正文 命中后都记录下来供管理员审核
+func (m *Message) checkSensitiveMessages(messages []*config.MessageResp) {
+	for _, message := range messages {
+		if message.FromUID == "" || message.FromUID == m.ctx.GetConfig().Account.SystemUID {
+			continue
+		}
+		payloadMap, err := message.GetPayloadMap()
+		if err != nil || payloadMap == nil {
+			continue
+		}
+		content, ok := sensitiveTextContent(payloadMap)
+		if !ok {
+			continue
+		}
+		result := m.sensitiveService.Check(content)
+		if !result.Hit() {
+			continue
+		}
+		messageID := fmt.Sprintf("%d", message.MessageID)
+		switch result.Action {
+		case sensitive.ActionBlock:
+			m.revokeSensitiveMessage(message)
+		case sensitive.ActionReplace:
+			payloadMap["content"] = result.Text
+			err = m.editMessageContent(message.FromUID, message.ChannelID, message.ChannelType, messageID, message.MessageSeq, util.ToJson(payloadMap))
+			if err != nil {
+				m.Warn("替换消息中的敏感词失败！", zap.Error(err), zap.String("messageID", messageID))
+			}
+		}
+		err = m.sensitiveService.AddHit(&sensitive.HitReq{
+			UID:         message.FromUID,
+			Scene:       sensitive.SceneMessage,
+			ChannelID:   message.ChannelID,
+			ChannelType: message.ChannelType,
+			MessageID:   messageID,
+			Content:     content,
+			Words:       result.Words,
+			Action:      result.Action,
+		})
+		if err != nil {
+			m.Warn("保存敏感词命中记录失败！", zap.Error(err), zap.String("messageID", messageID))
+		}
+	}
+}
+
+// revokeSensitiveMessage 撤回包含拦截词的消息 以发送者的身份撤回
+func (m *Message) revokeSensitiveMessage(message *config.MessageResp) {
+	fakeChannelID := message.ChannelID
+	if message.ChannelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(message.FromUID, message.ChannelID)
+	}
+	messageModels, err := m.db.queryMessagesWithChannelClientMsgNo(fakeChannelID, message.ChannelType, message.ClientMsgNo)
+	if err != nil {
+		m.Warn("查询包含敏感词的消息失败！", zap.Error(err), zap.Int64("messageID", message.MessageID))
+		return
+	}
+	if err = m.revokeMessages(message.FromUID, "", message.ChannelID, message.ChannelType, messageModels); err != nil {
+		m.Warn("撤回包含敏感词的消息失败！", zap.Error(err), zap.Int64("messageID", message.MessageID))
+	}
+}
+
+// sensitiveTextContent 文本消息的正文
+func sensitiveTextContent(payloadMap map[string]interface{}) (string, bool) {
+	if payloadContentType(payloadMap["type"]) != common.Text.Int() {
+		return "", false
+	}
+	content, ok := payloadMap["content"].(string)
+	if !ok || content == "" {
+		return "", false
+	}
+	return content, true
+}
+
+// payloadContentType 消息监听中的payload数字为json.Number 请求中的为float64
+func payloadContentType(v interface{}) int {
+	switch t := v.(type) {
+	case json.Number:
+		i, _ := t.Int64()
+		return int(i)
+	case float64:
+		return int(t)
+	case int:
+		return t
+	}
+	return 0
+}
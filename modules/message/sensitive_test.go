@@ -0,0 +1,21 @@
+package message
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensitiveTextContent(t *testing.T) {
+	content, ok := sensitiveTextContent(map[string]interface{}{"type": json.Number("1"), "content": "你好"})
+	assert.True(t, ok)
+	assert.Equal(t, "你好", content)
+
+	_, ok = sensitiveTextContent(map[string]interface{}{"type": float64(common.Text.Int()), "content": ""})
+	assert.False(t, ok)
+
+	_, ok = sensitiveTextContent(map[string]interface{}{"type": float64(common.Image.Int()), "content": "你好"})
+	assert.False(t, ok)
+}
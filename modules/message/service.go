@@ -1,25 +1,47 @@
 package message
 
 import (
+	"errors"
+	"strconv"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/eventbus"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
 )
 
 type IService interface {
 	DeleteConversation(uid string, channelID string, channelType uint8) error
+	// EditMessage 编辑自己发送的消息 contentEdit为编辑后的正文（json） 个人频道的channelID为对方uid
+	EditMessage(fromUID string, channelID string, channelType uint8, messageID int64, contentEdit string) error
+	// RevokeMessage 撤回自己发送的消息 个人频道的channelID为对方uid
+	RevokeMessage(operator string, operatorName string, channelID string, channelType uint8, messageID int64) error
+	// RevokeGroupMessage 撤回群内任意成员的消息 不校验操作者权限
+	RevokeGroupMessage(operator string, operatorName string, groupNo string, messageID int64) error
+	// GetMessage 查询uid能看到的消息 有编辑时Payload为编辑后的正文 消息不存在或已撤回时返回nil
+	GetMessage(uid string, channelID string, channelType uint8, messageID int64) (*config.MessageResp, error)
+	// GetMessageCountWithDate 某天发送的消息数 只保留最近几天的数据
+	GetMessageCountWithDate(date string) (int64, error)
+	// GetExportData 导出用户的消息相关数据（合规导出） 不包含消息正文 每一项最多limit条
+	GetExportData(uid string, limit int) (*ExportData, error)
+	// SearchMessages 检索用户或群在一段时间内的消息（合规调查） 按消息时间倒序 最多Limit条
+	SearchMessages(req *SearchReq) ([]*SearchMessage, error)
 }
 
 type Service struct {
 	ctx *config.Context
 	log.Log
+	message *Message
 }
 
 func NewService(ctx *config.Context) *Service {
 
 	return &Service{
-		ctx: ctx,
-		Log: log.NewTLog("message.Service"),
+		ctx:     ctx,
+		Log:     log.NewTLog("message.Service"),
+		message: newMessage(ctx),
 	}
 }
 
@@ -32,6 +54,11 @@ func (s *Service) DeleteConversation(uid string, channelID string, channelType u
 	if err != nil {
 		return err
 	}
+	eventbus.Publish(eventbus.NewEvent(eventbus.ConversationDeleted, uid, &eventbus.ConversationDeletedData{
+		UID:         uid,
+		ChannelID:   channelID,
+		ChannelType: channelType,
+	}))
 	err = s.ctx.SendCMD(config.MsgCMDReq{
 		ChannelID:   uid,
 		ChannelType: common.ChannelTypePerson.Uint8(),
@@ -47,3 +74,93 @@ func (s *Service) DeleteConversation(uid string, channelID string, channelType u
 
 	return nil
 }
+
+func (s *Service) EditMessage(fromUID string, channelID string, channelType uint8, messageID int64, contentEdit string) error {
+	messageM, err := s.getOwnMessage(fromUID, channelID, channelType, messageID)
+	if err != nil {
+		return err
+	}
+	return s.message.editMessageContent(fromUID, channelID, channelType, strconv.FormatInt(messageID, 10), messageM.MessageSeq, contentEdit)
+}
+
+func (s *Service) RevokeMessage(operator string, operatorName string, channelID string, channelType uint8, messageID int64) error {
+	messageM, err := s.getOwnMessage(operator, channelID, channelType, messageID)
+	if err != nil {
+		return err
+	}
+	return s.message.revokeMessages(operator, operatorName, channelID, channelType, []*messageModel{messageM})
+}
+
+func (s *Service) RevokeGroupMessage(operator string, operatorName string, groupNo string, messageID int64) error {
+	messageM, err := s.message.db.queryMessageWithMessageID(groupNo, common.ChannelTypeGroup.Uint8(), strconv.FormatInt(messageID, 10))
+	if err != nil {
+		s.Error("查询消息失败！", zap.Error(err), zap.Int64("messageID", messageID))
+		return errcode.ErrQueryMessage
+	}
+	if messageM == nil || messageM.IsDeleted == 1 {
+		return errcode.ErrMessageNotExist
+	}
+	if messageM.FromUID == "" { // 没有fromUID的消息一般是命令类的消息，不被允许撤回
+		return errors.New("此消息不能撤回！")
+	}
+	return s.message.revokeMessages(operator, operatorName, groupNo, common.ChannelTypeGroup.Uint8(), []*messageModel{messageM})
+}
+
+func (s *Service) GetMessage(uid string, channelID string, channelType uint8, messageID int64) (*config.MessageResp, error) {
+	fakeChannelID := channelID
+	if channelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(uid, channelID)
+	}
+	messageM, err := s.message.db.queryMessageWithMessageID(fakeChannelID, channelType, strconv.FormatInt(messageID, 10))
+	if err != nil {
+		return nil, err
+	}
+	if messageM == nil || messageM.IsDeleted == 1 {
+		return nil, nil
+	}
+	payload := messageM.Payload
+	extraM, err := s.message.messageExtraDB.queryWithMessageID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if extraM != nil {
+		if extraM.Revoke == 1 || extraM.IsDeleted == 1 {
+			return nil, nil
+		}
+		if extraM.ContentEdit.String != "" {
+			payload = []byte(extraM.ContentEdit.String)
+		}
+	}
+	return &config.MessageResp{
+		Setting:     messageM.Setting,
+		MessageID:   messageM.MessageID,
+		MessageSeq:  messageM.MessageSeq,
+		ClientMsgNo: messageM.ClientMsgNo,
+		Expire:      messageM.Expire,
+		FromUID:     messageM.FromUID,
+		ChannelID:   messageM.ChannelID,
+		ChannelType: messageM.ChannelType,
+		Timestamp:   int32(messageM.Timestamp),
+		Payload:     payload,
+	}, nil
+}
+
+// getOwnMessage 查询uid自己发送的消息
+func (s *Service) getOwnMessage(uid string, channelID string, channelType uint8, messageID int64) (*messageModel, error) {
+	fakeChannelID := channelID
+	if channelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(uid, channelID)
+	}
+	messageM, err := s.message.db.queryMessageWithMessageID(fakeChannelID, channelType, strconv.FormatInt(messageID, 10))
+	if err != nil {
+		s.Error("查询消息失败！", zap.Error(err), zap.Int64("messageID", messageID))
+		return nil, errcode.ErrQueryMessage
+	}
+	if messageM == nil || messageM.IsDeleted == 1 {
+		return nil, errcode.ErrMessageNotExist
+	}
+	if messageM.FromUID != uid {
+		return nil, errors.New("只能操作自己发送的消息！")
+	}
+	return messageM, nil
+}
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+-- 合规调查按发送者或群和消息时间检索消息
+CREATE INDEX message_from_uid_idx on `message` (from_uid, `timestamp`);
+CREATE INDEX message_channel_idx on `message` (channel_id, channel_type, `timestamp`);
+CREATE INDEX message_from_uid_idx on `message1` (from_uid, `timestamp`);
+CREATE INDEX message_channel_idx on `message1` (channel_id, channel_type, `timestamp`);
+CREATE INDEX message_from_uid_idx on `message2` (from_uid, `timestamp`);
+CREATE INDEX message_channel_idx on `message2` (channel_id, channel_type, `timestamp`);
+CREATE INDEX message_from_uid_idx on `message3` (from_uid, `timestamp`);
+CREATE INDEX message_channel_idx on `message3` (channel_id, channel_type, `timestamp`);
+CREATE INDEX message_from_uid_idx on `message4` (from_uid, `timestamp`);
+CREATE INDEX message_channel_idx on `message4` (channel_id, channel_type, `timestamp`);
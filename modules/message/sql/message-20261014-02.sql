@@ -0,0 +1,17 @@
+-- +migrate Up
+
+-- 按版本同步频道的消息扩展 分表通过 CREATE TABLE ... LIKE message_extra 创建 会带上此索引
+CREATE INDEX channel_version_idx on `message_extra` (channel_id, channel_type, `version`);
+
+-- 消息扩展分表的迁移进度 每个分表数量一条记录
+create table `message_extra_shard`
+(
+  id             bigint         not null primary key AUTO_INCREMENT,
+  table_count    int            not null default 0,  -- 分表数量
+  last_id        bigint         not null default 0,  -- 原表中已迁移到的id
+  migrated_count bigint         not null default 0,  -- 已迁移的条数
+  finished_at    bigint         not null default 0,  -- 迁移完成的时间 0为未完成
+  created_at     timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at     timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX message_extra_shard_count_idx on `message_extra_shard` (table_count);
@@ -0,0 +1,58 @@
+package notice
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Notice 维护和客户端通知
+type Notice struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// New New
+func New(ctx *config.Context) *Notice {
+	return &Notice{
+		ctx: ctx,
+		Log: log.NewTLog("Notice"),
+		db:  newDB(ctx),
+	}
+}
+
+// Route 路由配置
+func (n *Notice) Route(r *wkhttp.WKHttp) {
+	// 维护期间用户可能无法登录 不需要token
+	common := r.Group("/v1/common")
+	{
+		common.GET("/notices", n.notices) // 正在展示的通知
+	}
+}
+
+// 正在展示的通知 客户端启动、回到前台和收到appNotice命令时查询
+func (n *Notice) notices(c *wkhttp.Context) {
+	models, err := notices.get(n.db, extconfig.Get().Notice.CacheTTL)
+	if err != nil {
+		n.Error("查询通知失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询通知失败！"))
+		return
+	}
+	version := strings.TrimSpace(c.GetHeader(VersionHeader))
+	if version == "" {
+		version = strings.TrimSpace(c.Query("version"))
+	}
+	active := activeNotices(models, time.Now().Unix())
+	list := make([]*noticeResp, 0, len(active))
+	for _, m := range active {
+		list = append(list, newNoticeResp(m, version))
+	}
+	c.Response(list)
+}
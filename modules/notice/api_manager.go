@@ -0,0 +1,330 @@
+package notice
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Manager 通知管理
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db            *db
+	onlineService user.IOnlineService
+	pushing       atomic.Bool
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	m := &Manager{
+		ctx:           ctx,
+		Log:           log.NewTLog("NoticeManager"),
+		db:            newDB(ctx),
+		onlineService: user.NewOnlineService(ctx),
+	}
+	m.ctx.Schedule(extconfig.Get().Notice.Interval, m.pushJob)
+	return m
+}
+
+// Route 路由配置
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/notices", m.list)                 // 通知列表
+		auth.POST("/notices", m.add)                 // 添加通知
+		auth.PUT("/notices/:notice_no", m.update)    // 修改通知 修改后重新推送
+		auth.DELETE("/notices/:notice_no", m.delete) // 删除通知
+		auth.POST("/notices/preview", m.preview)     // 预览客户端看到的通知
+	}
+}
+
+type noticeReq struct {
+	Message    string `json:"message"`     // 通知内容
+	Severity   string `json:"severity"`    // 级别 info.普通 warning.警告 maintenance.维护中
+	StartAt    int64  `json:"start_at"`    // 开始展示的时间（时间戳秒） 为0时立即展示
+	EndAt      int64  `json:"end_at"`      // 结束展示的时间（时间戳秒） 为0时一直展示
+	MinVersion string `json:"min_version"` // 最低支持的客户端版本 低于此版本的客户端提示升级
+}
+
+// toModel 校验并转换为model now为当前时间
+func (r noticeReq) toModel(now int64) (*model, error) {
+	message := strings.TrimSpace(r.Message)
+	if message == "" {
+		return nil, errors.New("通知内容不能为空")
+	}
+	if len([]rune(message)) > messageMaxLen {
+		return nil, fmt.Errorf("通知内容不能超过%d个字", messageMaxLen)
+	}
+	severity := r.Severity
+	if severity == "" {
+		severity = SeverityInfo
+	}
+	if !validSeverity(severity) {
+		return nil, errors.New("通知级别有误")
+	}
+	if r.StartAt < 0 || r.EndAt < 0 {
+		return nil, errors.New("时间有误")
+	}
+	startAt := r.StartAt
+	if startAt == 0 {
+		startAt = now
+	}
+	if r.EndAt > 0 && r.EndAt <= startAt {
+		return nil, errors.New("结束时间必须大于开始时间")
+	}
+	if r.EndAt > 0 && r.EndAt <= now {
+		return nil, errors.New("结束时间必须大于当前时间")
+	}
+	minVersion := strings.TrimSpace(r.MinVersion)
+	if len(minVersion) > versionMaxLen {
+		return nil, fmt.Errorf("版本号不能超过%d个字符", versionMaxLen)
+	}
+	return &model{
+		Message:    message,
+		Severity:   severity,
+		StartAt:    startAt,
+		EndAt:      r.EndAt,
+		MinVersion: minVersion,
+		PushStatus: PushStatusPending,
+	}, nil
+}
+
+// 通知列表 expired为1时查询已结束的通知
+func (m *Manager) list(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	expired := c.Query("expired") == "1"
+	now := time.Now().Unix()
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.db.queryWithPage(expired, now, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询通知失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询通知失败！"))
+		return
+	}
+	count, err := m.db.queryCount(expired, now)
+	if err != nil {
+		m.Error("查询通知数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询通知数量失败！"))
+		return
+	}
+	list := make([]*managerNoticeResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newManagerNoticeResp(model, now))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 添加通知 开始展示后推送给在线用户
+func (m *Manager) add(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermOperationWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req noticeReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	model, err := req.toModel(time.Now().Unix())
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model.NoticeNo = util.GenerUUID()
+	model.Creator = c.GetLoginUID()
+	model.Updater = c.GetLoginUID()
+	if err = m.db.insert(model); err != nil {
+		m.Error("添加通知失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加通知失败！"))
+		return
+	}
+	notices.invalidate()
+	audit.SetChange(c, fmt.Sprintf("notice_no=%s", model.NoticeNo), nil, req)
+	c.Response(map[string]interface{}{
+		"notice_no": model.NoticeNo,
+	})
+}
+
+// 修改通知 修改后重新推送给在线用户
+func (m *Manager) update(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermOperationWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req noticeReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	noticeNo := c.Param("notice_no")
+	old, err := m.db.queryWithNoticeNo(noticeNo)
+	if err != nil {
+		m.Error("查询通知失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询通知失败！"))
+		return
+	}
+	if old == nil {
+		c.ResponseError(errors.New("通知不存在"))
+		return
+	}
+	now := time.Now().Unix()
+	if req.StartAt == 0 && old.StartAt <= now {
+		// 已经开始展示的通知保持原来的开始时间
+		req.StartAt = old.StartAt
+	}
+	model, err := req.toModel(now)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model.NoticeNo = noticeNo
+	model.Updater = c.GetLoginUID()
+	ok, err := m.db.update(model)
+	if err != nil {
+		m.Error("修改通知失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改通知失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("通知不存在"))
+		return
+	}
+	notices.invalidate()
+	audit.SetChange(c, fmt.Sprintf("notice_no=%s", noticeNo), newNoticeReq(old), req)
+	c.ResponseOK()
+}
+
+// 删除通知 已经开始展示的通知推送给在线用户隐藏
+func (m *Manager) delete(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermOperationWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	noticeNo := c.Param("notice_no")
+	old, err := m.db.queryWithNoticeNo(noticeNo)
+	if err != nil {
+		m.Error("查询通知失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询通知失败！"))
+		return
+	}
+	if old == nil {
+		c.ResponseError(errors.New("通知不存在"))
+		return
+	}
+	now := time.Now().Unix()
+	pushStatus := PushStatusNone
+	if old.active(now) {
+		pushStatus = PushStatusPending
+	}
+	ok, err := m.db.delete(noticeNo, c.GetLoginUID(), pushStatus)
+	if err != nil {
+		m.Error("删除通知失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除通知失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("通知不存在"))
+		return
+	}
+	notices.invalidate()
+	audit.SetChange(c, fmt.Sprintf("notice_no=%s", noticeNo), newNoticeReq(old), nil)
+	c.ResponseOK()
+}
+
+// 预览某个客户端版本在某个时间看到的通知
+func (m *Manager) preview(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		Version string `json:"version"` // 客户端版本
+		At      int64  `json:"at"`      // 时间（时间戳秒） 为0时为当前时间
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	at := req.At
+	if at == 0 {
+		at = time.Now().Unix()
+	}
+	models, err := m.db.queryUnexpired(time.Now().Unix())
+	if err != nil {
+		m.Error("查询通知失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询通知失败！"))
+		return
+	}
+	active := activeNotices(models, at)
+	list := make([]*noticeResp, 0, len(active))
+	for _, model := range active {
+		list = append(list, newNoticeResp(model, strings.TrimSpace(req.Version)))
+	}
+	c.Response(list)
+}
+
+func newNoticeReq(m *model) noticeReq {
+	return noticeReq{
+		Message:    m.Message,
+		Severity:   m.Severity,
+		StartAt:    m.StartAt,
+		EndAt:      m.EndAt,
+		MinVersion: m.MinVersion,
+	}
+}
+
+type managerNoticeResp struct {
+	NoticeNo    string `json:"notice_no"`
+	Message     string `json:"message"`
+	Severity    string `json:"severity"`
+	StartAt     int64  `json:"start_at"`
+	EndAt       int64  `json:"end_at"`
+	MinVersion  string `json:"min_version"`
+	Active      int    `json:"active"`       // 1.正在展示
+	PushStatus  int    `json:"push_status"`  // 0.不需要推送或已推送完成 1.待推送
+	PushedCount int    `json:"pushed_count"` // 推送的用户数
+	Creator     string `json:"creator"`
+	Updater     string `json:"updater"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+func newManagerNoticeResp(m *model, now int64) *managerNoticeResp {
+	resp := &managerNoticeResp{
+		NoticeNo:    m.NoticeNo,
+		Message:     m.Message,
+		Severity:    m.Severity,
+		StartAt:     m.StartAt,
+		EndAt:       m.EndAt,
+		MinVersion:  m.MinVersion,
+		PushStatus:  m.PushStatus,
+		PushedCount: m.PushedCount,
+		Creator:     m.Creator,
+		Updater:     m.Updater,
+		CreatedAt:   m.CreatedAt.String(),
+		UpdatedAt:   m.UpdatedAt.String(),
+	}
+	if m.active(now) {
+		resp.Active = 1
+	}
+	return resp
+}
@@ -0,0 +1,40 @@
+package notice
+
+// 通知的级别
+const (
+	SeverityInfo        = "info"        // 普通
+	SeverityWarning     = "warning"     // 警告 例如即将停机维护
+	SeverityMaintenance = "maintenance" // 维护中 客户端可以展示维护页面
+)
+
+// 推送状态
+const (
+	PushStatusNone    = 0 // 不需要推送或已推送完成
+	PushStatusPending = 1 // 待推送 开始展示后推送给在线用户
+)
+
+// CMDAppNotice 通知生效、修改或删除后推送给在线用户的命令
+const CMDAppNotice = "appNotice"
+
+// VersionHeader 客户端版本的请求头 没有时使用查询参数version
+const VersionHeader = "X-App-Version"
+
+const (
+	messageMaxLen = 1000 // 通知内容的最大字数
+	versionMaxLen = 40   // 版本号的最大长度
+)
+
+func validSeverity(severity string) bool {
+	return severity == SeverityInfo || severity == SeverityWarning || severity == SeverityMaintenance
+}
+
+// severityLevel 级别越高越靠前
+func severityLevel(severity string) int {
+	switch severity {
+	case SeverityMaintenance:
+		return 3
+	case SeverityWarning:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,119 @@
+package notice
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *db) insert(m *model) error {
+	_, err := d.session.InsertInto("app_notice").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+// update 修改通知并重新推送 通知不存在时返回false
+func (d *db) update(m *model) (bool, error) {
+	result, err := d.session.UpdateBySql("update app_notice set message=?,severity=?,start_at=?,end_at=?,min_version=?,updater=?,push_status=?,push_version=push_version+1,push_cursor='' where notice_no=? and is_deleted=0", m.Message, m.Severity, m.StartAt, m.EndAt, m.MinVersion, m.Updater, PushStatusPending, m.NoticeNo).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// delete 删除通知 pushStatus为待推送时通知在线用户隐藏 通知不存在时返回false
+func (d *db) delete(noticeNo string, updater string, pushStatus int) (bool, error) {
+	result, err := d.session.UpdateBySql("update app_notice set is_deleted=1,updater=?,push_status=?,push_version=push_version+1,push_cursor='' where notice_no=? and is_deleted=0", updater, pushStatus, noticeNo).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (d *db) queryWithNoticeNo(noticeNo string) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("app_notice").Where("notice_no=? and is_deleted=0", noticeNo).Load(&m)
+	return m, err
+}
+
+// queryUnexpired 没有删除和没有结束的通知 包括还没有开始的
+func (d *db) queryUnexpired(now int64) ([]*model, error) {
+	var models []*model
+	_, err := d.session.Select("*").From("app_notice").Where("is_deleted=0 and (end_at=0 or end_at>?)", now).OrderDir("start_at", false).Load(&models)
+	return models, err
+}
+
+func (d *db) queryWithPage(expired bool, now int64, pageIndex, pageSize uint64) ([]*model, error) {
+	var models []*model
+	_, err := d.listWhere(d.session.Select("*").From("app_notice"), expired, now).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryCount(expired bool, now int64) (int64, error) {
+	var count int64
+	_, err := d.listWhere(d.session.Select("count(*)").From("app_notice"), expired, now).Load(&count)
+	return count, err
+}
+
+// listWhere expired为true时查询已结束的通知 否则查询没有结束的
+func (d *db) listWhere(builder *dbr.SelectStmt, expired bool, now int64) *dbr.SelectStmt {
+	builder = builder.Where("is_deleted=0")
+	if expired {
+		return builder.Where("end_at>0 and end_at<=?", now)
+	}
+	return builder.Where("(end_at=0 or end_at>?)", now)
+}
+
+// queryNextPush 已经开始展示的待推送通知 包括删除后需要通知隐藏的
+func (d *db) queryNextPush(now int64) (*model, error) {
+	var m *model
+	_, err := d.session.Select("*").From("app_notice").Where("push_status=? and start_at<=?", PushStatusPending, now).OrderDir("id", true).Limit(1).Load(&m)
+	return m, err
+}
+
+// updatePushCursor 领取下一批用户 通知修改后版本变化时返回false
+func (d *db) updatePushCursor(id int64, pushVersion int, cursor string, nextCursor string, count int) (bool, error) {
+	result, err := d.session.UpdateBySql("update app_notice set push_cursor=?,pushed_count=pushed_count+? where id=? and push_status=? and push_version=? and push_cursor=?", nextCursor, count, id, PushStatusPending, pushVersion, cursor).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// updatePushFinished 推送完成 通知修改后版本变化时不修改
+func (d *db) updatePushFinished(id int64, pushVersion int) error {
+	_, err := d.session.Update("app_notice").Set("push_status", PushStatusNone).Where("id=? and push_version=?", id, pushVersion).Exec()
+	return err
+}
+
+type model struct {
+	NoticeNo    string
+	Message     string
+	Severity    string
+	StartAt     int64
+	EndAt       int64
+	MinVersion  string
+	Creator     string
+	Updater     string
+	IsDeleted   int
+	PushStatus  int
+	PushVersion int
+	PushCursor  string
+	PushedCount int
+	dba.BaseModel
+}
@@ -0,0 +1,114 @@
+package notice
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/util"
+)
+
+// noticeCache 没有结束的通知 客户端查询频繁 缓存一段时间 本实例修改后立即失效
+type noticeCache struct {
+	lock     sync.RWMutex
+	notices  []*model
+	loadedAt time.Time
+}
+
+// notices 所有实例共用的缓存
+var notices = &noticeCache{}
+
+// get 缓存过期时重新查询
+func (n *noticeCache) get(d *db, ttl time.Duration) ([]*model, error) {
+	n.lock.RLock()
+	list, loadedAt := n.notices, n.loadedAt
+	n.lock.RUnlock()
+	if !loadedAt.IsZero() && time.Since(loadedAt) < ttl {
+		return list, nil
+	}
+	list, err := d.queryUnexpired(time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	n.lock.Lock()
+	n.notices = list
+	n.loadedAt = time.Now()
+	n.lock.Unlock()
+	return list, nil
+}
+
+func (n *noticeCache) invalidate() {
+	n.lock.Lock()
+	n.loadedAt = time.Time{}
+	n.lock.Unlock()
+}
+
+// activeNotices 正在展示的通知 维护中和警告在前 同级别的后开始的在前
+func activeNotices(models []*model, now int64) []*model {
+	active := make([]*model, 0, len(models))
+	for _, m := range models {
+		if m.active(now) {
+			active = append(active, m)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		li, lj := severityLevel(active[i].Severity), severityLevel(active[j].Severity)
+		if li != lj {
+			return li > lj
+		}
+		return active[i].StartAt > active[j].StartAt
+	})
+	return active
+}
+
+// active 通知在now时是否正在展示
+func (m *model) active(now int64) bool {
+	return m.StartAt <= now && (m.EndAt == 0 || m.EndAt > now)
+}
+
+// upgradeRequired 客户端版本低于通知的最低版本 没有传版本时不提示
+func upgradeRequired(version string, minVersion string) bool {
+	if minVersion == "" || strings.TrimSpace(version) == "" {
+		return false
+	}
+	return util.CompareVersion(version, minVersion) < 0
+}
+
+type noticeResp struct {
+	NoticeNo        string `json:"notice_no"`
+	Message         string `json:"message"`
+	Severity        string `json:"severity"`
+	StartAt         int64  `json:"start_at"`
+	EndAt           int64  `json:"end_at"`
+	MinVersion      string `json:"min_version"`
+	UpgradeRequired int    `json:"upgrade_required"` // 1.客户端版本低于最低版本 需要提示升级
+}
+
+func newNoticeResp(m *model, version string) *noticeResp {
+	resp := &noticeResp{
+		NoticeNo:   m.NoticeNo,
+		Message:    m.Message,
+		Severity:   m.Severity,
+		StartAt:    m.StartAt,
+		EndAt:      m.EndAt,
+		MinVersion: m.MinVersion,
+	}
+	if upgradeRequired(version, m.MinVersion) {
+		resp.UpgradeRequired = 1
+	}
+	return resp
+}
+
+// cmdParam 推送给在线用户的命令参数 deleted为1时客户端隐藏通知 客户端根据min_version判断是否需要升级
+func cmdParam(m *model) map[string]interface{} {
+	return map[string]interface{}{
+		"notice_no":   m.NoticeNo,
+		"message":     m.Message,
+		"severity":    m.Severity,
+		"start_at":    m.StartAt,
+		"end_at":      m.EndAt,
+		"min_version": m.MinVersion,
+		"deleted":     m.IsDeleted,
+	}
+}
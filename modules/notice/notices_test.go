@@ -0,0 +1,61 @@
+package notice
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveNotices(t *testing.T) {
+	models := []*model{
+		{NoticeNo: "info", Severity: SeverityInfo, StartAt: 100},
+		{NoticeNo: "future", Severity: SeverityMaintenance, StartAt: 300},
+		{NoticeNo: "ended", Severity: SeverityWarning, StartAt: 50, EndAt: 200},
+		{NoticeNo: "warning", Severity: SeverityWarning, StartAt: 150, EndAt: 400},
+		{NoticeNo: "info2", Severity: SeverityInfo, StartAt: 120},
+	}
+	var nos []string
+	for _, m := range activeNotices(models, 200) {
+		nos = append(nos, m.NoticeNo)
+	}
+	assert.Equal(t, []string{"warning", "info2", "info"}, nos)
+
+	nos = nil
+	for _, m := range activeNotices(models, 300) {
+		nos = append(nos, m.NoticeNo)
+	}
+	assert.Equal(t, []string{"future", "warning", "info2", "info"}, nos)
+}
+
+func TestUpgradeRequired(t *testing.T) {
+	assert.False(t, upgradeRequired("1.0.0", ""))
+	assert.False(t, upgradeRequired("", "1.0.0"))
+	assert.True(t, upgradeRequired("1.9.9", "2.0"))
+	assert.False(t, upgradeRequired("2.0.0", "2.0"))
+
+	resp := newNoticeResp(&model{NoticeNo: "a", MinVersion: "2.0"}, "1.0")
+	assert.Equal(t, 1, resp.UpgradeRequired)
+}
+
+func TestNoticeReqToModel(t *testing.T) {
+	m, err := noticeReq{Message: " 系统将于今晚22:00维护 "}.toModel(1000)
+	assert.NoError(t, err)
+	assert.Equal(t, "系统将于今晚22:00维护", m.Message)
+	assert.Equal(t, SeverityInfo, m.Severity)
+	assert.Equal(t, int64(1000), m.StartAt)
+	assert.Equal(t, PushStatusPending, m.PushStatus)
+
+	m, err = noticeReq{Message: "维护中", Severity: SeverityMaintenance, StartAt: 2000, EndAt: 3000, MinVersion: "1.2.0"}.toModel(1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2000), m.StartAt)
+	assert.Equal(t, "1.2.0", m.MinVersion)
+
+	_, err = noticeReq{}.toModel(1000)
+	assert.Error(t, err)
+	_, err = noticeReq{Message: "a", Severity: "error"}.toModel(1000)
+	assert.Error(t, err)
+	_, err = noticeReq{Message: "a", StartAt: 2000, EndAt: 2000}.toModel(1000)
+	assert.Error(t, err)
+	_, err = noticeReq{Message: "a", StartAt: 500, EndAt: 900}.toModel(1000)
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package notice
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"go.uber.org/zap"
+)
+
+// pushJob 每次只推送一批 下次执行时继续推送 推送进度保存在数据库中 重启后从上次的进度继续
+// 还没有开始展示的通知到开始时间后才推送
+func (m *Manager) pushJob() {
+	if !m.pushing.CompareAndSwap(false, true) {
+		return
+	}
+	defer m.pushing.Store(false)
+
+	notice, err := m.db.queryNextPush(time.Now().Unix())
+	if err != nil {
+		m.Error("查询需要推送的通知失败！", zap.Error(err))
+		return
+	}
+	if notice == nil {
+		return
+	}
+	if err = m.pushBatch(notice, extconfig.Get().Notice.BatchSize); err != nil {
+		m.Error("推送通知失败！", zap.Error(err), zap.String("noticeNo", notice.NoticeNo))
+	}
+}
+
+// pushBatch 给下一批在线用户推送通知 没有更多用户时推送完成
+func (m *Manager) pushBatch(notice *model, batchSize int) error {
+	uids, err := m.onlineService.GetOnlineUIDs(notice.PushCursor, batchSize)
+	if err != nil {
+		return err
+	}
+	if len(uids) == 0 {
+		m.Info("通知推送完成", zap.String("noticeNo", notice.NoticeNo), zap.Int("pushedCount", notice.PushedCount))
+		return m.db.updatePushFinished(notice.Id, notice.PushVersion)
+	}
+	// 先领取这一批用户 通知修改后从头重新推送
+	ok, err := m.db.updatePushCursor(notice.Id, notice.PushVersion, notice.PushCursor, uids[len(uids)-1], len(uids))
+	if err != nil || !ok {
+		return err
+	}
+	err = m.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		CMD:         CMDAppNotice,
+		Subscribers: uids,
+		Param:       cmdParam(notice),
+	})
+	if err != nil {
+		m.Warn("推送一批通知失败！", zap.Error(err), zap.String("noticeNo", notice.NoticeNo), zap.Int("count", len(uids)))
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+-- +migrate Up
+
+-- ##########  维护和客户端通知 ##########
+create table `app_notice`
+(
+    id           integer       not null primary key AUTO_INCREMENT,
+    notice_no    VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '通知编号',
+    message      VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '通知内容',
+    severity     VARCHAR(20)   NOT NULL DEFAULT 'info' COMMENT '级别 info.普通 warning.警告 maintenance.维护中',
+    start_at     bigint        NOT NULL DEFAULT 0  COMMENT '开始展示的时间（时间戳秒）',
+    end_at       bigint        NOT NULL DEFAULT 0  COMMENT '结束展示的时间（时间戳秒） 0为一直展示',
+    min_version  VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '最低支持的客户端版本 低于此版本的客户端提示升级 为空不提示',
+    creator      VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '创建的管理员uid',
+    updater      VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '最后修改的管理员uid',
+    is_deleted   smallint      NOT NULL DEFAULT 0  COMMENT '是否已删除',
+    push_status  smallint      NOT NULL DEFAULT 0  COMMENT '推送状态 0.不需要推送或已推送完成 1.待推送（开始展示后推送给在线用户）',
+    push_version integer       NOT NULL DEFAULT 0  COMMENT '推送版本 修改后加1 重新推送',
+    push_cursor  VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '已推送到的uid',
+    pushed_count integer       NOT NULL DEFAULT 0  COMMENT '推送的用户数（重新推送时累加）',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX app_notice_no_uidx on `app_notice` (notice_no);
+CREATE INDEX app_notice_push_idx on `app_notice` (push_status, start_at);
+CREATE INDEX app_notice_end_idx on `app_notice` (is_deleted, end_at);
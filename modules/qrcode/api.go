@@ -9,6 +9,7 @@ import (
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -92,7 +93,7 @@ func (q *QRCode) handleQRCodeInfo(c *wkhttp.Context) {
 			return
 		}
 		if userResp == nil {
-			c.ResponseError(errors.New("用户不存在！"))
+			c.ResponseError(errcode.ErrUserNotExist)
 			return
 		}
 		c.Response(NewHandleResult(ForwardNative, HandlerTypeUserInfo, map[string]interface{}{
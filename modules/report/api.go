@@ -4,24 +4,39 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
 )
 
 // Report 举报
 type Report struct {
 	ctx *config.Context
-	db  *db
+	log.Log
+	db             *db
+	messageService message.IService
+	triage         *triage
 }
 
 // New 创建一个举报对象
 func New(ctx *config.Context) *Report {
-	return &Report{
-		ctx: ctx,
-		db:  newDB(ctx),
-	}
+	r := &Report{
+		ctx:            ctx,
+		Log:            log.NewTLog("Report"),
+		db:             newDB(ctx),
+		messageService: message.NewService(ctx),
+		triage:         newTriage(ctx),
+	}
+	r.ctx.AddEventListener(event.EventUserBanAppeal, r.handleBanAppeal) // 封禁申诉进入举报处理队列
+	return r
 }
 
 // Route 配置路由规则
@@ -75,23 +90,48 @@ func (r *Report) report(c *wkhttp.Context) {
 		imgsStr = strings.Join(req.Imgs, ",")
 	}
 
-	err := r.db.insert(&model{
+	reportModel := &model{
 		UID:         c.GetLoginUID(),
 		CategoryNo:  req.CategoryNo,
 		Imgs:        imgsStr,
 		Remark:      req.Remark,
 		ChannelID:   req.ChannelID,
 		ChannelType: req.ChannelType,
-	})
+		MessageID:   req.MessageID,
+		TargetUID:   r.reportTargetUID(c.GetLoginUID(), req),
+		Status:      StatusPending,
+	}
+	err := r.db.insert(reportModel)
 	if err != nil {
 		c.ResponseErrorf("添加举报数据失败！", err)
 		return
 	}
+	r.triage.evaluate(reportModel)
 
 	c.ResponseOK()
 
 }
 
+// reportTargetUID 被举报的用户 个人频道为对方 举报消息时为消息的发送者
+func (r *Report) reportTargetUID(loginUID string, req reportReq) string {
+	if req.ChannelType == common.ChannelTypePerson.Uint8() {
+		return req.ChannelID
+	}
+	if req.MessageID == "" {
+		return ""
+	}
+	messageID, _ := strconv.ParseInt(req.MessageID, 10, 64)
+	messageResp, err := r.messageService.GetMessage(loginUID, req.ChannelID, req.ChannelType, messageID)
+	if err != nil {
+		r.Warn("查询被举报的消息失败！", zap.Error(err), zap.String("messageID", req.MessageID))
+		return ""
+	}
+	if messageResp == nil {
+		return ""
+	}
+	return messageResp.FromUID
+}
+
 // 举报类别
 func (r *Report) categoies(c *wkhttp.Context) {
 	lang := c.Query("lang")
@@ -174,19 +214,25 @@ type reportReq struct {
 	ChannelID   string   `json:"channel_id"`   // 频道id
 	ChannelType uint8    `json:"channel_type"` // 频道类型
 	CategoryNo  string   `json:"category_no"`  // 类别编号
+	MessageID   string   `json:"message_id"`   // 被举报的消息 可以为空
 	Imgs        []string `json:"imgs"`         // 举报图片内容
 	Remark      string   `json:"remark"`       // 举报备注
 }
 
 func (r reportReq) check() error {
 	if r.ChannelID == "" {
-		return errors.New("频道ID不能为空！")
+		return errcode.ErrChannelIDRequired
 	}
 	if r.ChannelType <= 0 {
-		return errors.New("频道类型不能为空！")
+		return errcode.ErrChannelTypeRequired
 	}
 	if r.CategoryNo == "" {
 		return errors.New("举报类别不能为空！")
 	}
+	if r.MessageID != "" {
+		if _, err := strconv.ParseInt(r.MessageID, 10, 64); err != nil {
+			return errors.New("消息ID有误！")
+		}
+	}
 	return nil
 }
@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -19,20 +21,28 @@ type Manager struct {
 	ctx       *config.Context
 	managerDB *managerDB
 	log.Log
-	userDB  *user.DB
-	db      *db
-	groupDB *group.DB
+	userDB         *user.DB
+	db             *db
+	groupDB        *group.DB
+	userService    user.IService
+	groupService   group.IService
+	messageService message.IService
+	ruleDB         *ruleDB
 }
 
 // NewManager 创建一个举报对象
 func NewManager(ctx *config.Context) *Manager {
 	return &Manager{
-		ctx:       ctx,
-		Log:       log.NewTLog("reportManager"),
-		managerDB: newManagerDB(ctx),
-		userDB:    user.NewDB(ctx),
-		db:        newDB(ctx),
-		groupDB:   group.NewDB(ctx),
+		ctx:            ctx,
+		Log:            log.NewTLog("reportManager"),
+		managerDB:      newManagerDB(ctx),
+		userDB:         user.NewDB(ctx),
+		db:             newDB(ctx),
+		groupDB:        group.NewDB(ctx),
+		userService:    user.NewService(ctx),
+		groupService:   group.NewService(ctx),
+		messageService: message.NewService(ctx),
+		ruleDB:         newRuleDB(ctx),
 	}
 }
 
@@ -41,13 +51,23 @@ func (m *Manager) Route(l *wkhttp.WKHttp) {
 
 	auth := l.Group("/v1/manager", l.AuthMiddleware(m.ctx.Cache(), m.ctx.GetConfig().Cache.TokenCachePrefix))
 	{
-		auth.GET("/report/list", m.reportList) // 举报列表
+		auth.GET("/report/list", m.reportList)      // 举报列表
+		auth.GET("/report/queue", m.queue)          // 举报处理队列
+		auth.PUT("/report/:id/assign", m.assign)    // 分配处理人
+		auth.POST("/report/:id/resolve", m.resolve) // 处理举报
+
+		// 自动处理规则
+		auth.GET("/report/rules", m.rules)                  // 规则列表
+		auth.POST("/report/rules", m.addRule)               // 添加规则
+		auth.PUT("/report/rules/:rule_no", m.updateRule)    // 修改规则
+		auth.DELETE("/report/rules/:rule_no", m.deleteRule) // 删除规则
+		auth.GET("/report/rule/logs", m.ruleLogs)           // 自动处理记录
 	}
 }
 
 // 举报列表
 func (m *Manager) reportList(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermReportRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -71,90 +91,125 @@ func (m *Manager) reportList(c *wkhttp.Context) {
 		c.ResponseError(errors.New("查询举报总数量错误"))
 		return
 	}
-	result := make([]*managerReportResp, 0)
-	if len(list) > 0 {
-		uids := make([]string, 0)
-		reportUserUIDs := make([]string, 0)
-		reportGroupIDs := make([]string, 0)
-		for _, report := range list {
-			uids = append(uids, report.UID)
-			if report.ChannelType == common.ChannelTypeGroup.Uint8() {
-				reportGroupIDs = append(reportGroupIDs, report.ChannelID)
-			} else {
-				reportUserUIDs = append(reportUserUIDs, report.ChannelID)
-			}
-		}
-		users, err := m.userDB.QueryByUIDs(uids)
-		if err != nil {
-			m.Error("查询用户信息错误", zap.Error(err))
-			c.ResponseError(errors.New("查询用户信息错误"))
-			return
+	result, err := m.toManagerReportResps(list)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  result,
+	})
+}
+
+// toManagerReportResps 填充举报者、被举报者和处理人的名称
+func (m *Manager) toManagerReportResps(list []*managerReportModel) ([]*managerReportResp, error) {
+	result := make([]*managerReportResp, 0, len(list))
+	if len(list) == 0 {
+		return result, nil
+	}
+	uids := make([]string, 0)
+	reportUserUIDs := make([]string, 0)
+	reportGroupIDs := make([]string, 0)
+	for _, report := range list {
+		uids = append(uids, report.UID)
+		if report.TargetUID != "" {
+			uids = append(uids, report.TargetUID)
 		}
-		reprotUsers, err := m.userDB.QueryByUIDs(reportUserUIDs)
-		if err != nil {
-			m.Error("查询举报用户集合错误", zap.Error(err))
-			c.ResponseError(errors.New("查询举报用户集合错误"))
-			return
+		if report.Assignee != "" {
+			uids = append(uids, report.Assignee)
 		}
-		reprotGroups, err := m.groupDB.QueryGroupsWithGroupNos(reportGroupIDs)
-		if err != nil {
-			m.Error("查询举报群集合错误", zap.Error(err))
-			c.ResponseError(errors.New("查询举报群集合错误"))
-			return
+		if report.ChannelType == common.ChannelTypeGroup.Uint8() {
+			reportGroupIDs = append(reportGroupIDs, report.ChannelID)
+		} else {
+			reportUserUIDs = append(reportUserUIDs, report.ChannelID)
 		}
-		for _, report := range list {
-			var username string
-			var channelName string
-			for _, user := range users {
-				if user.UID == report.UID {
-					username = user.Name
+	}
+	users, err := m.userDB.QueryByUIDs(uids)
+	if err != nil {
+		m.Error("查询用户信息错误", zap.Error(err))
+		return nil, errors.New("查询用户信息错误")
+	}
+	reprotUsers, err := m.userDB.QueryByUIDs(reportUserUIDs)
+	if err != nil {
+		m.Error("查询举报用户集合错误", zap.Error(err))
+		return nil, errors.New("查询举报用户集合错误")
+	}
+	reprotGroups, err := m.groupDB.QueryGroupsWithGroupNos(reportGroupIDs)
+	if err != nil {
+		m.Error("查询举报群集合错误", zap.Error(err))
+		return nil, errors.New("查询举报群集合错误")
+	}
+	userNames := map[string]string{}
+	for _, user := range users {
+		userNames[user.UID] = user.Name
+	}
+	for _, report := range list {
+		var channelName string
+		if report.ChannelType == common.ChannelTypeGroup.Uint8() {
+			for _, group := range reprotGroups {
+				if group.GroupNo == report.ChannelID {
+					channelName = group.Name
 				}
 			}
-			if report.ChannelType == common.ChannelTypeGroup.Uint8() {
-				for _, group := range reprotGroups {
-					if group.GroupNo == report.ChannelID {
-						channelName = group.Name
-					}
+		} else {
+			for _, user := range reprotUsers {
+				if user.UID == report.ChannelID {
+					channelName = user.Name
 				}
-			} else {
-				for _, user := range reprotUsers {
-					if user.UID == report.ChannelID {
-						channelName = user.Name
-					}
-				}
-			}
-			imgs := make([]string, 0)
-			if report.Imgs != "" {
-				imgs = strings.Split(report.Imgs, ",")
 			}
-			result = append(result, &managerReportResp{
-				UID:          report.UID,
-				Name:         username,
-				Imgs:         imgs,
-				ChannelID:    report.ChannelID,
-				ChannelType:  report.ChannelType,
-				ChannelName:  channelName,
-				Remark:       report.Remark,
-				CategoryName: report.CategoryName,
-				CreateAt:     report.CreatedAt.String(),
-			})
 		}
-
+		categoryName := report.CategoryName
+		if report.CategoryNo == CategoryBanAppeal {
+			categoryName = categoryBanAppealName
+		}
+		imgs := make([]string, 0)
+		if report.Imgs != "" {
+			imgs = strings.Split(report.Imgs, ",")
+		}
+		result = append(result, &managerReportResp{
+			ID:           report.Id,
+			UID:          report.UID,
+			Name:         userNames[report.UID],
+			Imgs:         imgs,
+			ChannelID:    report.ChannelID,
+			ChannelType:  report.ChannelType,
+			ChannelName:  channelName,
+			MessageID:    report.MessageID,
+			TargetUID:    report.TargetUID,
+			TargetName:   userNames[report.TargetUID],
+			Remark:       report.Remark,
+			CategoryName: categoryName,
+			Status:       report.Status,
+			Assignee:     report.Assignee,
+			AssigneeName: userNames[report.Assignee],
+			Action:       report.Action,
+			Result:       report.Result,
+			HandledAt:    report.HandledAt,
+			CreateAt:     report.CreatedAt.String(),
+		})
 	}
-	c.Response(map[string]interface{}{
-		"count": count,
-		"list":  result,
-	})
+	return result, nil
 }
 
 type managerReportResp struct {
+	ID           int64    `json:"id"`
 	UID          string   `json:"uid"`
 	Name         string   `json:"name"` //举报者名称
 	ChannelID    string   `json:"channel_id"`
 	ChannelType  uint8    `json:"channel_type"`
 	ChannelName  string   `json:"channel_name"` //被举报的名称 群名称｜用户名
+	MessageID    string   `json:"message_id"`   // 被举报的消息
+	TargetUID    string   `json:"target_uid"`   // 被举报的用户
+	TargetName   string   `json:"target_name"`
 	CategoryName string   `json:"category_name"`
 	Imgs         []string `json:"imgs"`   // 举报图片内容
 	Remark       string   `json:"remark"` // 举报备注
+	Status       int      `json:"status"` // 处理状态 0.待处理 1.处理中 2.已处理
+	Assignee     string   `json:"assignee"`
+	AssigneeName string   `json:"assignee_name"`
+	Action       string   `json:"action"`     // 处理方式
+	Result       string   `json:"result"`     // 处理说明
+	HandledAt    int64    `json:"handled_at"` // 处理时间
 	CreateAt     string   `json:"create_at"`
 }
@@ -0,0 +1,275 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 举报处理队列 status为空时查询未处理完的 mine=1时只查询分配给自己的
+func (m *Manager) queue(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermReportRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	status := -1
+	if c.Query("status") != "" {
+		status, _ = strconv.Atoi(c.Query("status"))
+	}
+	assignee := c.Query("assignee")
+	if c.Query("mine") == "1" {
+		assignee = c.GetLoginUID()
+	}
+	list, err := m.managerDB.queryQueue(status, assignee, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		m.Error("查询举报处理队列错误", zap.Error(err))
+		c.ResponseError(errors.New("查询举报处理队列错误"))
+		return
+	}
+	count, err := m.managerDB.queryQueueCount(status, assignee)
+	if err != nil {
+		m.Error("查询举报处理队列数量错误", zap.Error(err))
+		c.ResponseError(errors.New("查询举报处理队列数量错误"))
+		return
+	}
+	result, err := m.toManagerReportResps(list)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  result,
+	})
+}
+
+// 分配处理人 assignee为空时分配给自己 分配后状态为处理中
+func (m *Manager) assign(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermReportHandle); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	var req struct {
+		Assignee string `json:"assignee"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if id <= 0 {
+		c.ResponseError(errors.New("举报ID不能为空"))
+		return
+	}
+	assignee := strings.TrimSpace(req.Assignee)
+	if assignee == "" {
+		assignee = c.GetLoginUID()
+	}
+	if assignee != c.GetLoginUID() {
+		assigneeUser, err := m.userDB.QueryByUID(assignee)
+		if err != nil {
+			m.Error("查询处理人信息错误", zap.Error(err))
+			c.ResponseError(errors.New("查询处理人信息错误"))
+			return
+		}
+		if assigneeUser == nil || !rbac.HasPermission(assigneeUser.Role, rbac.PermReportHandle) {
+			c.ResponseError(errors.New("处理人没有处理举报的权限"))
+			return
+		}
+	}
+	ok, err := m.managerDB.assign(id, assignee)
+	if err != nil {
+		m.Error("分配举报处理人错误", zap.Error(err))
+		c.ResponseError(errors.New("分配举报处理人错误"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("举报不存在或已处理"))
+		return
+	}
+	c.ResponseOK()
+}
+
+type resolveReq struct {
+	Action      string `json:"action"`       // 处理方式
+	MuteSeconds int64  `json:"mute_seconds"` // 禁言时长（秒） 为0时禁言一天
+	BanSeconds  int64  `json:"ban_seconds"`  // 封禁时长（秒） 为0时永久封禁
+	BanReason   string `json:"ban_reason"`   // 封禁原因 被封禁的用户登录时可以看到 为空时使用举报类别
+	Result      string `json:"result"`       // 处理说明 会通知举报人
+}
+
+func (r resolveReq) check() error {
+	if _, ok := actionNotice[r.Action]; !ok {
+		return errors.New("处理方式有误")
+	}
+	if r.MuteSeconds < 0 {
+		return errors.New("禁言时长有误")
+	}
+	if r.BanSeconds < 0 {
+		return errors.New("封禁时长有误")
+	}
+	if len([]rune(r.Result)) > 800 {
+		return errors.New("处理说明不能超过800个字")
+	}
+	return nil
+}
+
+// 处理举报 执行处理方式后标记为已处理并通知举报人
+// 已分配给其他管理员的举报只有超级管理员可以处理
+func (m *Manager) resolve(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermReportHandle); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	var req resolveReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if req.Action == ActionBan || req.Action == ActionUnban {
+		if err := rbac.Check(c, rbac.PermUserBan); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+	report, err := m.managerDB.queryWithID(id)
+	if err != nil {
+		m.Error("查询举报错误", zap.Error(err))
+		c.ResponseError(errors.New("查询举报错误"))
+		return
+	}
+	if report == nil {
+		c.ResponseError(errors.New("举报不存在"))
+		return
+	}
+	if report.Status == StatusResolved {
+		c.ResponseError(errors.New("举报已处理"))
+		return
+	}
+	if err = checkActionForCategory(report.CategoryNo, req.Action); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if report.Assignee != "" && report.Assignee != c.GetLoginUID() && !rbac.IsSuperAdmin(c) {
+		c.ResponseError(errors.New("举报已分配给其他管理员"))
+		return
+	}
+	if err = m.applyAction(c.GetLoginUID(), c.GetLoginName(), report, req); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	ok, err := m.managerDB.resolve(id, c.GetLoginUID(), req.Action, req.Result, time.Now().Unix())
+	if err != nil {
+		m.Error("修改举报状态错误", zap.Error(err))
+		c.ResponseError(errors.New("修改举报状态错误"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("举报已处理"))
+		return
+	}
+	m.notifyReporter(report, req)
+	c.ResponseOK()
+}
+
+// applyAction 执行处理方式
+func (m *Manager) applyAction(operator string, operatorName string, report *managerReportModel, req resolveReq) error {
+	if req.Action == ActionNone {
+		return nil
+	}
+	if req.Action == ActionUnban {
+		return m.userService.UpdateUserStatus(report.TargetUID, int(common.UserAvailable))
+	}
+	if req.Action == ActionDeleteMessage {
+		if report.MessageID == "" {
+			return errors.New("举报中没有消息，不能删除消息")
+		}
+		messageID, _ := strconv.ParseInt(report.MessageID, 10, 64)
+		if report.ChannelType == common.ChannelTypeGroup.Uint8() {
+			return m.messageService.RevokeGroupMessage(operator, operatorName, report.ChannelID, messageID)
+		}
+		// 个人频道的消息以发送者的身份撤回
+		return m.messageService.RevokeMessage(report.TargetUID, "", report.UID, report.ChannelType, messageID)
+	}
+	if report.TargetUID == "" {
+		return errors.New("无法确定被举报的用户")
+	}
+	switch req.Action {
+	case ActionMute:
+		if report.ChannelType != common.ChannelTypeGroup.Uint8() {
+			return errors.New("只能禁言群内的用户")
+		}
+		muteSeconds := req.MuteSeconds
+		if muteSeconds == 0 {
+			muteSeconds = defaultMuteSeconds
+		}
+		return m.groupService.MuteMember(report.ChannelID, report.TargetUID, time.Now().Unix()+muteSeconds)
+	case ActionBan:
+		banReason := strings.TrimSpace(req.BanReason)
+		if banReason == "" {
+			banReason = report.CategoryName
+		}
+		return m.userService.BanUser(report.TargetUID, banReason, req.BanSeconds, operator)
+	}
+	return nil
+}
+
+// checkActionForCategory 封禁申诉只能维持封禁或解禁 解禁只能用于封禁申诉
+func checkActionForCategory(categoryNo string, action string) error {
+	if categoryNo == CategoryBanAppeal {
+		if _, ok := appealNotice[action]; !ok {
+			return errors.New("封禁申诉只能维持封禁或解禁")
+		}
+		return nil
+	}
+	if action == ActionUnban {
+		return errors.New("只有封禁申诉可以解禁")
+	}
+	return nil
+}
+
+// notifyReporter 通过系统账号通知举报人处理结果
+func (m *Manager) notifyReporter(report *managerReportModel, req resolveReq) {
+	err := m.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		ChannelID:   report.UID,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		FromUID:     m.ctx.GetConfig().Account.SystemUID,
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": reportNotice(report, req),
+			"type":    common.Text,
+		})),
+	})
+	if err != nil {
+		m.Warn("通知举报人处理结果失败！", zap.Error(err), zap.String("uid", report.UID))
+	}
+}
+
+func reportNotice(report *managerReportModel, req resolveReq) string {
+	createdAt := time.Time(report.CreatedAt).Format("2006-01-02 15:04")
+	notice := fmt.Sprintf("您于%s提交的举报已处理：%s。", createdAt, actionNotice[req.Action])
+	if report.CategoryNo == CategoryBanAppeal {
+		notice = fmt.Sprintf("您于%s提交的封禁申诉已处理：%s。", createdAt, appealNotice[req.Action])
+	}
+	if result := strings.TrimSpace(req.Result); result != "" {
+		notice = fmt.Sprintf("%s%s", notice, result)
+	}
+	return notice
+}
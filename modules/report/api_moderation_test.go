@@ -0,0 +1,46 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveReqCheck(t *testing.T) {
+	assert.NoError(t, resolveReq{Action: ActionNone}.check())
+	assert.NoError(t, resolveReq{Action: ActionMute, MuteSeconds: 3600}.check())
+	assert.EqualError(t, resolveReq{Action: "kick"}.check(), "处理方式有误")
+	assert.EqualError(t, resolveReq{Action: ActionMute, MuteSeconds: -1}.check(), "禁言时长有误")
+}
+
+func TestReportNotice(t *testing.T) {
+	report := &managerReportModel{
+		BaseModel: dba.BaseModel{
+			CreatedAt: dba.Time(time.Date(2026, 10, 14, 9, 30, 0, 0, time.Local)),
+		},
+	}
+	assert.Equal(t, "您于2026-10-14 09:30提交的举报已处理：违规消息已被删除。", reportNotice(report, resolveReq{Action: ActionDeleteMessage}))
+	assert.Equal(t, "您于2026-10-14 09:30提交的举报已处理：经核实暂未发现违规。感谢您的反馈", reportNotice(report, resolveReq{Action: ActionNone, Result: " 感谢您的反馈 "}))
+}
+
+func TestCheckActionForCategory(t *testing.T) {
+	assert.NoError(t, checkActionForCategory(CategoryBanAppeal, ActionNone))
+	assert.NoError(t, checkActionForCategory(CategoryBanAppeal, ActionUnban))
+	assert.EqualError(t, checkActionForCategory(CategoryBanAppeal, ActionMute), "封禁申诉只能维持封禁或解禁")
+	assert.EqualError(t, checkActionForCategory("ad", ActionUnban), "只有封禁申诉可以解禁")
+	assert.NoError(t, checkActionForCategory("ad", ActionBan))
+}
+
+func TestBanAppealNotice(t *testing.T) {
+	report := &managerReportModel{
+		BaseModel: dba.BaseModel{
+			CreatedAt: dba.Time(time.Date(2026, 10, 14, 9, 30, 0, 0, time.Local)),
+		},
+	}
+	report.CategoryNo = CategoryBanAppeal
+	assert.Equal(t, "您于2026-10-14 09:30提交的封禁申诉已处理：申诉已通过，账号已解封。", reportNotice(report, resolveReq{Action: ActionUnban}))
+	assert.Equal(t, "您于2026-10-14 09:30提交的封禁申诉已处理：经核实维持封禁。", reportNotice(report, resolveReq{Action: ActionNone}))
+	assert.Equal(t, "封禁原因：发布广告\n申诉内容：误封", banAppealRemark("发布广告", "误封"))
+}
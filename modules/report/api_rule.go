@@ -0,0 +1,313 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+type ruleReq struct {
+	Name          string `json:"name"`           // 规则名称
+	CategoryNo    string `json:"category_no"`    // 举报类别 为空时所有类别
+	Threshold     int    `json:"threshold"`      // 举报人数
+	WindowSeconds int    `json:"window_seconds"` // 统计的时间范围（秒）
+	Action        string `json:"action"`         // 处理方式 mute.在群内禁言 ban.封禁
+	ActionSeconds int64  `json:"action_seconds"` // 禁言或封禁的时长（秒） 禁言时为0默认一天
+	Status        int    `json:"status"`         // 0.停用 1.启用
+}
+
+func (r *ruleReq) check() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.CategoryNo = strings.TrimSpace(r.CategoryNo)
+	if r.Name == "" {
+		return errors.New("规则名称不能为空")
+	}
+	if len([]rune(r.Name)) > 100 {
+		return errors.New("规则名称不能超过100个字")
+	}
+	if r.CategoryNo == CategoryBanAppeal {
+		return errors.New("封禁申诉不能自动处理")
+	}
+	if r.Threshold < 2 || r.Threshold > ruleMaxThreshold {
+		return fmt.Errorf("举报人数需要在2到%d之间", ruleMaxThreshold)
+	}
+	if r.WindowSeconds < 60 || r.WindowSeconds > ruleMaxWindowSeconds {
+		return errors.New("统计的时间范围需要在1分钟到30天之间")
+	}
+	if !ruleActions[r.Action] {
+		return errors.New("处理方式有误")
+	}
+	if r.Action == ActionMute && r.ActionSeconds == 0 {
+		r.ActionSeconds = defaultMuteSeconds
+	}
+	if r.ActionSeconds <= 0 || r.ActionSeconds > ruleMaxActionSeconds {
+		return errors.New("处理时长需要在30天以内")
+	}
+	if r.Status != RuleStatusDisabled && r.Status != RuleStatusEnabled {
+		return errors.New("规则状态有误")
+	}
+	return nil
+}
+
+// checkRuleReq 检查请求 自动封禁的规则需要有封禁用户的权限
+func (m *Manager) checkRuleReq(c *wkhttp.Context, req *ruleReq) error {
+	if err := req.check(); err != nil {
+		return err
+	}
+	if req.Action == ActionBan {
+		if err := rbac.Check(c, rbac.PermUserBan); err != nil {
+			return err
+		}
+	}
+	if req.CategoryNo == "" {
+		return nil
+	}
+	categories, err := m.db.queryCategoryAll()
+	if err != nil {
+		m.Error("查询举报类别失败！", zap.Error(err))
+		return errors.New("查询举报类别失败！")
+	}
+	for _, category := range categories {
+		if category.CategoryNo == req.CategoryNo {
+			return nil
+		}
+	}
+	return errors.New("举报类别不存在")
+}
+
+// 自动处理规则列表
+func (m *Manager) rules(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermReportRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := m.ruleDB.queryAll()
+	if err != nil {
+		m.Error("查询自动处理规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询自动处理规则失败！"))
+		return
+	}
+	list := make([]*ruleResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newRuleResp(model))
+	}
+	c.Response(list)
+}
+
+// 添加自动处理规则
+func (m *Manager) addRule(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermReportRule); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req ruleReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if err := m.checkRuleReq(c, &req); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	rule := &ruleModel{
+		RuleNo:        util.GenerUUID(),
+		Name:          req.Name,
+		CategoryNo:    req.CategoryNo,
+		Threshold:     req.Threshold,
+		WindowSeconds: req.WindowSeconds,
+		Action:        req.Action,
+		ActionSeconds: req.ActionSeconds,
+		Status:        req.Status,
+		Creator:       c.GetLoginUID(),
+	}
+	if err := m.ruleDB.insert(rule); err != nil {
+		m.Error("添加自动处理规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加自动处理规则失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("rule_no=%s", rule.RuleNo), nil, req)
+	c.Response(map[string]interface{}{
+		"rule_no": rule.RuleNo,
+	})
+}
+
+// 修改自动处理规则
+func (m *Manager) updateRule(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermReportRule); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req ruleReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if err := m.checkRuleReq(c, &req); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	rule, err := m.queryRule(c.Param("rule_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	// 修改自动封禁的规则（包括改为禁言）也需要封禁用户的权限
+	if rule.Action == ActionBan {
+		if err = rbac.Check(c, rbac.PermUserBan); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+	before := newRuleResp(rule)
+	rule.Name = req.Name
+	rule.CategoryNo = req.CategoryNo
+	rule.Threshold = req.Threshold
+	rule.WindowSeconds = req.WindowSeconds
+	rule.Action = req.Action
+	rule.ActionSeconds = req.ActionSeconds
+	rule.Status = req.Status
+	if err = m.ruleDB.update(rule); err != nil {
+		m.Error("修改自动处理规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改自动处理规则失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("rule_no=%s", rule.RuleNo), before, req)
+	c.ResponseOK()
+}
+
+// 删除自动处理规则 自动处理记录保留
+func (m *Manager) deleteRule(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermReportRule); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	rule, err := m.queryRule(c.Param("rule_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if rule.Action == ActionBan {
+		if err = rbac.Check(c, rbac.PermUserBan); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+	if err = m.ruleDB.delete(rule.RuleNo); err != nil {
+		m.Error("删除自动处理规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除自动处理规则失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("rule_no=%s", rule.RuleNo), newRuleResp(rule), nil)
+	c.ResponseOK()
+}
+
+func (m *Manager) queryRule(ruleNo string) (*ruleModel, error) {
+	rule, err := m.ruleDB.queryWithRuleNo(ruleNo)
+	if err != nil {
+		m.Error("查询自动处理规则失败！", zap.Error(err))
+		return nil, errors.New("查询自动处理规则失败！")
+	}
+	if rule == nil {
+		return nil, errors.New("自动处理规则不存在")
+	}
+	return rule, nil
+}
+
+// 自动处理记录 可按规则和被处理的用户查询
+func (m *Manager) ruleLogs(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermReportRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	filter := ruleLogFilter{
+		ruleNo:    c.Query("rule_no"),
+		targetUID: c.Query("target_uid"),
+	}
+	models, err := m.ruleDB.queryLogsWithPage(filter, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询自动处理记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询自动处理记录失败！"))
+		return
+	}
+	count, err := m.ruleDB.queryLogCount(filter)
+	if err != nil {
+		m.Error("查询自动处理记录数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询自动处理记录数量失败！"))
+		return
+	}
+	list := make([]*ruleLogResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newRuleLogResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+type ruleResp struct {
+	RuleNo        string `json:"rule_no"`
+	Name          string `json:"name"`
+	CategoryNo    string `json:"category_no"`
+	Threshold     int    `json:"threshold"`
+	WindowSeconds int    `json:"window_seconds"`
+	Action        string `json:"action"`
+	ActionSeconds int64  `json:"action_seconds"`
+	Status        int    `json:"status"`
+	Creator       string `json:"creator"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+func newRuleResp(m *ruleModel) *ruleResp {
+	return &ruleResp{
+		RuleNo:        m.RuleNo,
+		Name:          m.Name,
+		CategoryNo:    m.CategoryNo,
+		Threshold:     m.Threshold,
+		WindowSeconds: m.WindowSeconds,
+		Action:        m.Action,
+		ActionSeconds: m.ActionSeconds,
+		Status:        m.Status,
+		Creator:       m.Creator,
+		CreatedAt:     m.CreatedAt.String(),
+		UpdatedAt:     m.UpdatedAt.String(),
+	}
+}
+
+type ruleLogResp struct {
+	RuleNo        string `json:"rule_no"`
+	RuleName      string `json:"rule_name"`
+	TargetUID     string `json:"target_uid"`
+	ChannelID     string `json:"channel_id"`
+	ChannelType   uint8  `json:"channel_type"`
+	Action        string `json:"action"`
+	ActionSeconds int64  `json:"action_seconds"`
+	ReporterCount int    `json:"reporter_count"`
+	Status        int    `json:"status"`
+	Error         string `json:"error"`
+	CreatedAt     string `json:"created_at"`
+}
+
+func newRuleLogResp(m *ruleLogModel) *ruleLogResp {
+	return &ruleLogResp{
+		RuleNo:        m.RuleNo,
+		RuleName:      m.RuleName,
+		TargetUID:     m.TargetUID,
+		ChannelID:     m.ChannelID,
+		ChannelType:   m.ChannelType,
+		Action:        m.Action,
+		ActionSeconds: m.ActionSeconds,
+		ReporterCount: m.ReporterCount,
+		Status:        m.Status,
+		Error:         m.Error,
+		CreatedAt:     m.CreatedAt.String(),
+	}
+}
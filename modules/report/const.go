@@ -0,0 +1,66 @@
+package report
+
+// 举报的处理状态
+const (
+	StatusPending   = 0 // 待处理
+	StatusReviewing = 1 // 处理中 已分配给管理员
+	StatusResolved  = 2 // 已处理
+)
+
+// 举报的处理方式
+const (
+	ActionNone          = "none"           // 未发现违规 不处理
+	ActionDeleteMessage = "delete_message" // 删除被举报的消息
+	ActionMute          = "mute"           // 在群内禁言被举报的用户
+	ActionBan           = "ban"            // 封禁被举报的用户
+	ActionUnban         = "unban"          // 封禁申诉通过 解禁用户
+)
+
+// CategoryBanAppeal 封禁申诉 不在举报类别中 用户提交申诉后进入举报处理队列
+const CategoryBanAppeal = "ban_appeal"
+
+// categoryBanAppealName 封禁申诉的类别名称
+const categoryBanAppealName = "封禁申诉"
+
+// defaultMuteSeconds 禁言时没有指定时长时默认禁言一天
+const defaultMuteSeconds = 60 * 60 * 24
+
+// actionNotice 通知举报人的处理结果
+var actionNotice = map[string]string{
+	ActionNone:          "经核实暂未发现违规",
+	ActionDeleteMessage: "违规消息已被删除",
+	ActionMute:          "被举报的用户已被禁言",
+	ActionBan:           "被举报的用户已被封禁",
+	ActionUnban:         "申诉已通过，账号已解封",
+}
+
+// appealNotice 通知申诉人的处理结果
+var appealNotice = map[string]string{
+	ActionNone:  "经核实维持封禁",
+	ActionUnban: "申诉已通过，账号已解封",
+}
+
+// 自动处理规则的状态
+const (
+	RuleStatusDisabled = 0 // 停用
+	RuleStatusEnabled  = 1 // 启用
+)
+
+// 自动处理记录的状态
+const (
+	RuleLogStatusFailed  = 0 // 执行失败
+	RuleLogStatusSuccess = 1 // 执行成功
+)
+
+// 自动处理规则的限制
+const (
+	ruleMaxThreshold     = 1000              // 最多的举报人数
+	ruleMaxWindowSeconds = 60 * 60 * 24 * 30 // 最长统计30天
+	ruleMaxActionSeconds = 60 * 60 * 24 * 30 // 自动禁言或封禁最长30天 不能永久封禁
+)
+
+// ruleActions 自动处理规则可以使用的处理方式
+var ruleActions = map[string]bool{
+	ActionMute: true,
+	ActionBan:  true,
+}
@@ -1,6 +1,7 @@
 package report
 
 import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -35,6 +36,34 @@ func (d *db) insert(m *model) error {
 	return err
 }
 
+// queryWithUID 用户提交的举报
+func (d *db) queryWithUID(uid string, limit uint64) ([]*detailModel, error) {
+	var models []*detailModel
+	_, err := d.session.Select("report.*,IFNULL(report_category.category_name,'') category_name").From("report").LeftJoin("report_category", "report.category_no=report_category.category_no").Where("report.uid=?", uid).OrderDir("report.id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
+// queryWithTarget 用户被举报的记录（举报用户、举报用户的消息或举报和用户的单聊）
+func (d *db) queryWithTarget(uid string, limit uint64) ([]*detailModel, error) {
+	var models []*detailModel
+	_, err := d.session.Select("report.*,IFNULL(report_category.category_name,'') category_name").From("report").LeftJoin("report_category", "report.category_no=report_category.category_no").Where("report.target_uid=? or (report.channel_type=? and report.channel_id=?)", uid, common.ChannelTypePerson.Uint8(), uid).OrderDir("report.id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
+// queryReportedMessageIDs 被举报过的消息ID
+func (d *db) queryReportedMessageIDs(messageIDs []string) ([]string, error) {
+	var ids []string
+	_, err := d.session.Select("distinct message_id").From("report").Where("message_id in ?", messageIDs).Load(&ids)
+	return ids, err
+}
+
+// queryCountWithStatus 某个处理状态的举报数
+func (d *db) queryCountWithStatus(status int) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("report").Where("status=?", status).Load(&count)
+	return count, err
+}
+
 type categoryModel struct {
 	CategoryNo       string
 	CategoryName     string
@@ -48,7 +77,19 @@ type model struct {
 	CategoryNo  string
 	ChannelID   string
 	ChannelType uint8
+	MessageID   string // 被举报的消息
+	TargetUID   string // 被举报的用户
 	Imgs        string
 	Remark      string
+	Status      int
+	Assignee    string
+	Action      string
+	Result      string
+	HandledAt   int64
 	dba.BaseModel
 }
+
+type detailModel struct {
+	model
+	CategoryName string
+}
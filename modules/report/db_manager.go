@@ -32,13 +32,70 @@ func (m *managerDB) queryReportCount(channelType int) (int64, error) {
 	return count, err
 }
 
+// queryQueue 举报处理队列 status小于0时不限制状态 assignee为空时不限制处理人 先提交的先处理
+func (m *managerDB) queryQueue(status int, assignee string, pageSize, page uint64) ([]*managerReportModel, error) {
+	var list []*managerReportModel
+	builder := m.session.Select("report.*,report_category.category_name").From("report").LeftJoin("report_category", "report.category_no=report_category.category_no")
+	_, err := m.queueWhere(builder, status, assignee).Offset((page-1)*pageSize).Limit(pageSize).OrderDir("report.created_at", true).Load(&list)
+	return list, err
+}
+
+func (m *managerDB) queryQueueCount(status int, assignee string) (int64, error) {
+	var count int64
+	_, err := m.queueWhere(m.session.Select("count(*)").From("report"), status, assignee).Load(&count)
+	return count, err
+}
+
+func (m *managerDB) queueWhere(builder *dbr.SelectStmt, status int, assignee string) *dbr.SelectStmt {
+	if status >= 0 {
+		builder = builder.Where("report.status=?", status)
+	}
+	if assignee != "" {
+		builder = builder.Where("report.assignee=?", assignee)
+	}
+	return builder
+}
+
+func (m *managerDB) queryWithID(id int64) (*managerReportModel, error) {
+	var model *managerReportModel
+	_, err := m.session.Select("report.*,report_category.category_name").From("report").LeftJoin("report_category", "report.category_no=report_category.category_no").Where("report.id=?", id).Load(&model)
+	return model, err
+}
+
+// assign 分配处理人 已处理的举报不能分配
+func (m *managerDB) assign(id int64, assignee string) (bool, error) {
+	result, err := m.session.Update("report").Set("assignee", assignee).Set("status", StatusReviewing).Where("id=? and status<>?", id, StatusResolved).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// resolve 标记为已处理 已处理过的返回false
+func (m *managerDB) resolve(id int64, assignee string, action string, resultMsg string, handledAt int64) (bool, error) {
+	result, err := m.session.Update("report").Set("status", StatusResolved).Set("assignee", assignee).Set("action", action).Set("result", resultMsg).Set("handled_at", handledAt).Where("id=? and status<>?", id, StatusResolved).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
 type managerReportModel struct {
 	UID          string
 	CategoryNo   string
 	ChannelID    string
 	ChannelType  uint8
+	MessageID    string
+	TargetUID    string
 	Imgs         string
 	Remark       string
 	CategoryName string
+	Status       int
+	Assignee     string
+	Action       string
+	Result       string
+	HandledAt    int64
 	dba.BaseModel
 }
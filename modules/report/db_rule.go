@@ -0,0 +1,143 @@
+package report
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type ruleDB struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newRuleDB(ctx *config.Context) *ruleDB {
+	return &ruleDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (r *ruleDB) insert(m *ruleModel) error {
+	_, err := r.session.InsertInto("report_rule").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (r *ruleDB) update(m *ruleModel) error {
+	_, err := r.session.Update("report_rule").SetMap(map[string]interface{}{
+		"name":           m.Name,
+		"category_no":    m.CategoryNo,
+		"threshold":      m.Threshold,
+		"window_seconds": m.WindowSeconds,
+		"action":         m.Action,
+		"action_seconds": m.ActionSeconds,
+		"status":         m.Status,
+	}).Where("rule_no=?", m.RuleNo).Exec()
+	return err
+}
+
+func (r *ruleDB) delete(ruleNo string) error {
+	_, err := r.session.DeleteFrom("report_rule").Where("rule_no=?", ruleNo).Exec()
+	return err
+}
+
+func (r *ruleDB) queryWithRuleNo(ruleNo string) (*ruleModel, error) {
+	var m *ruleModel
+	_, err := r.session.Select("*").From("report_rule").Where("rule_no=?", ruleNo).Load(&m)
+	return m, err
+}
+
+func (r *ruleDB) queryAll() ([]*ruleModel, error) {
+	var models []*ruleModel
+	_, err := r.session.Select("*").From("report_rule").OrderDir("id", true).Load(&models)
+	return models, err
+}
+
+func (r *ruleDB) queryEnabled() ([]*ruleModel, error) {
+	var models []*ruleModel
+	_, err := r.session.Select("*").From("report_rule").Where("status=?", RuleStatusEnabled).OrderDir("id", true).Load(&models)
+	return models, err
+}
+
+// queryReporterCount 从start开始举报用户的人数 同一举报人只计一次 不包括封禁申诉
+// categoryNo为空时不限制类别 channelID不为空时只统计在群内的举报
+func (r *ruleDB) queryReporterCount(targetUID string, categoryNo string, channelID string, start string) (int64, error) {
+	var count int64
+	builder := r.session.Select("count(distinct uid)").From("report").Where("target_uid=? and category_no<>? and created_at>=?", targetUID, CategoryBanAppeal, start)
+	if categoryNo != "" {
+		builder = builder.Where("category_no=?", categoryNo)
+	}
+	if channelID != "" {
+		builder = builder.Where("channel_id=? and channel_type=?", channelID, common.ChannelTypeGroup.Uint8())
+	}
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+func (r *ruleDB) insertLog(m *ruleLogModel) error {
+	_, err := r.session.InsertInto("report_rule_log").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+// existSuccessLog 从start开始规则是否已经处理过用户（群内禁言时为同一个群）
+func (r *ruleDB) existSuccessLog(ruleNo string, targetUID string, channelID string, start string) (bool, error) {
+	var count int64
+	_, err := r.session.Select("count(*)").From("report_rule_log").Where("target_uid=? and rule_no=? and channel_id=? and status=? and created_at>=?", targetUID, ruleNo, channelID, RuleLogStatusSuccess, start).Load(&count)
+	return count > 0, err
+}
+
+func (r *ruleDB) queryLogsWithPage(filter ruleLogFilter, pageIndex, pageSize uint64) ([]*ruleLogModel, error) {
+	var models []*ruleLogModel
+	_, err := r.logWhere(r.session.Select("*").From("report_rule_log"), filter).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (r *ruleDB) queryLogCount(filter ruleLogFilter) (int64, error) {
+	var count int64
+	_, err := r.logWhere(r.session.Select("count(*)").From("report_rule_log"), filter).Load(&count)
+	return count, err
+}
+
+func (r *ruleDB) logWhere(builder *dbr.SelectStmt, filter ruleLogFilter) *dbr.SelectStmt {
+	if filter.ruleNo != "" {
+		builder = builder.Where("rule_no=?", filter.ruleNo)
+	}
+	if filter.targetUID != "" {
+		builder = builder.Where("target_uid=?", filter.targetUID)
+	}
+	return builder
+}
+
+type ruleLogFilter struct {
+	ruleNo    string
+	targetUID string
+}
+
+type ruleModel struct {
+	RuleNo        string
+	Name          string
+	CategoryNo    string // 为空时所有类别
+	Threshold     int    // 举报人数
+	WindowSeconds int    // 统计的时间范围（秒）
+	Action        string
+	ActionSeconds int64
+	Status        int
+	Creator       string
+	dba.BaseModel
+}
+
+type ruleLogModel struct {
+	RuleNo        string
+	RuleName      string
+	TargetUID     string
+	ChannelID     string
+	ChannelType   uint8
+	Action        string
+	ActionSeconds int64
+	ReporterCount int
+	Status        int
+	Error         string
+	dba.BaseModel
+}
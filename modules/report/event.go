@@ -0,0 +1,59 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+type banAppealReq struct {
+	UID     string `json:"uid"`
+	BanID   int64  `json:"ban_id"`
+	Reason  string `json:"reason"`  // 封禁原因
+	Content string `json:"content"` // 申诉内容
+}
+
+// 处理封禁申诉 申诉人和被举报的用户都是被封禁的用户 处理时只能维持封禁或解禁
+func (r *Report) handleBanAppeal(data []byte, commit config.EventCommit) {
+	var req banAppealReq
+	err := util.ReadJsonByByte(data, &req)
+	if err != nil {
+		r.Error("封禁申诉参数有误", zap.Error(err))
+		commit(err)
+		return
+	}
+	if req.UID == "" {
+		r.Error("封禁申诉uid不能为空")
+		commit(errors.New("封禁申诉uid不能为空"))
+		return
+	}
+	err = r.db.insert(&model{
+		UID:         req.UID,
+		CategoryNo:  CategoryBanAppeal,
+		ChannelID:   req.UID,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		TargetUID:   req.UID,
+		Remark:      banAppealRemark(req.Reason, req.Content),
+		Status:      StatusPending,
+	})
+	if err != nil {
+		r.Error("添加封禁申诉失败！", zap.Error(err), zap.String("uid", req.UID), zap.Int64("banID", req.BanID))
+		commit(err)
+		return
+	}
+	commit(nil)
+}
+
+// banAppealRemark 申诉的备注 带上封禁原因方便处理人判断
+func banAppealRemark(reason string, content string) string {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return content
+	}
+	return fmt.Sprintf("封禁原因：%s\n申诉内容：%s", reason, content)
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+)
+
+// IService 举报相关
+type IService interface {
+	// GetExportData 导出用户提交的举报和被举报的记录（合规导出） 每一项最多limit条
+	GetExportData(uid string, limit int) (*ExportData, error)
+	// GetReportedMessageIDs 被举报过的消息 返回messageIDs中被举报过的消息ID
+	GetReportedMessageIDs(messageIDs []string) ([]string, error)
+	// GetBacklog 还没有处理完的举报数
+	GetBacklog() (*Backlog, error)
+}
+
+// Service Service
+type Service struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// NewService NewService
+func NewService(ctx *config.Context) IService {
+	return &Service{
+		ctx: ctx,
+		Log: log.NewTLog("reportService"),
+		db:  newDB(ctx),
+	}
+}
+
+// ExportData 用户相关的举报
+type ExportData struct {
+	Submitted []*ExportReport `json:"submitted"` // 用户提交的举报
+	Received  []*ExportReport `json:"received"`  // 用户被举报的记录 不包含举报人
+}
+
+// ExportReport 举报记录
+type ExportReport struct {
+	UID          string `json:"uid,omitempty"` // 举报人
+	CategoryNo   string `json:"category_no"`
+	CategoryName string `json:"category_name"`
+	ChannelID    string `json:"channel_id"`
+	ChannelType  uint8  `json:"channel_type"`
+	MessageID    string `json:"message_id"`
+	TargetUID    string `json:"target_uid"`
+	Remark       string `json:"remark"`
+	Status       int    `json:"status"`
+	Action       string `json:"action"`
+	Result       string `json:"result"`
+	HandledAt    int64  `json:"handled_at"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// GetExportData 导出用户相关的举报 被举报的记录隐去举报人和举报者填写的备注
+func (s *Service) GetExportData(uid string, limit int) (*ExportData, error) {
+	submitted, err := s.db.queryWithUID(uid, uint64(limit))
+	if err != nil {
+		return nil, err
+	}
+	received, err := s.db.queryWithTarget(uid, uint64(limit))
+	if err != nil {
+		return nil, err
+	}
+	data := &ExportData{
+		Submitted: make([]*ExportReport, 0, len(submitted)),
+		Received:  make([]*ExportReport, 0, len(received)),
+	}
+	for _, m := range submitted {
+		data.Submitted = append(data.Submitted, newExportReport(m))
+	}
+	for _, m := range received {
+		r := newExportReport(m)
+		r.UID = ""
+		r.Remark = ""
+		data.Received = append(data.Received, r)
+	}
+	return data, nil
+}
+
+// GetReportedMessageIDs 被举报过的消息
+func (s *Service) GetReportedMessageIDs(messageIDs []string) ([]string, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	return s.db.queryReportedMessageIDs(messageIDs)
+}
+
+// Backlog 还没有处理完的举报
+type Backlog struct {
+	Pending   int64 `json:"pending"`   // 待处理
+	Reviewing int64 `json:"reviewing"` // 处理中
+}
+
+// GetBacklog 还没有处理完的举报数
+func (s *Service) GetBacklog() (*Backlog, error) {
+	pending, err := s.db.queryCountWithStatus(StatusPending)
+	if err != nil {
+		return nil, err
+	}
+	reviewing, err := s.db.queryCountWithStatus(StatusReviewing)
+	if err != nil {
+		return nil, err
+	}
+	return &Backlog{
+		Pending:   pending,
+		Reviewing: reviewing,
+	}, nil
+}
+
+func newExportReport(m *detailModel) *ExportReport {
+	return &ExportReport{
+		UID:          m.UID,
+		CategoryNo:   m.CategoryNo,
+		CategoryName: m.CategoryName,
+		ChannelID:    m.ChannelID,
+		ChannelType:  m.ChannelType,
+		MessageID:    m.MessageID,
+		TargetUID:    m.TargetUID,
+		Remark:       m.Remark,
+		Status:       m.Status,
+		Action:       m.Action,
+		Result:       m.Result,
+		HandledAt:    m.HandledAt,
+		CreatedAt:    m.CreatedAt.String(),
+	}
+}
@@ -0,0 +1,12 @@
+-- +migrate Up
+
+-- ##########  举报处理 ##########
+ALTER TABLE `report` ADD COLUMN message_id VARCHAR(20) not null DEFAULT '' comment '被举报的消息ID';
+ALTER TABLE `report` ADD COLUMN target_uid VARCHAR(40) not null DEFAULT '' comment '被举报的用户';
+ALTER TABLE `report` ADD COLUMN status smallint not null DEFAULT 0 comment '处理状态 0.待处理 1.处理中 2.已处理';
+ALTER TABLE `report` ADD COLUMN assignee VARCHAR(40) not null DEFAULT '' comment '处理举报的管理员';
+ALTER TABLE `report` ADD COLUMN action VARCHAR(20) not null DEFAULT '' comment '处理方式 none.不处理 delete_message.删除消息 mute.禁言 ban.封禁';
+ALTER TABLE `report` ADD COLUMN result VARCHAR(800) not null DEFAULT '' comment '处理说明 会通知举报人';
+ALTER TABLE `report` ADD COLUMN handled_at integer not null DEFAULT 0 comment '处理时间';
+CREATE INDEX report_status_idx on `report` (status, created_at);
+CREATE INDEX report_assignee_idx on `report` (assignee, status);
@@ -0,0 +1,40 @@
+-- +migrate Up
+
+-- 举报的自动处理规则 一段时间内举报某个用户的人数达到阈值时自动处理 举报仍留在处理队列等待人工审核
+create table `report_rule`
+(
+  id             bigint         not null primary key AUTO_INCREMENT,
+  rule_no        VARCHAR(40)    not null default '',  -- 规则编号
+  name           VARCHAR(100)   not null default '',  -- 规则名称
+  category_no    VARCHAR(40)    not null default '',  -- 举报类别 为空时所有类别
+  threshold      int            not null default 0,   -- 举报人数（同一举报人只计一次）
+  window_seconds int            not null default 0,   -- 统计的时间范围（秒）
+  action         VARCHAR(20)    not null default '',  -- 处理方式 mute.在群内禁言 ban.封禁
+  action_seconds bigint         not null default 0,   -- 禁言或封禁的时长（秒）
+  status         smallint       not null default 1,   -- 状态 0.停用 1.启用
+  creator        VARCHAR(40)    not null default '',  -- 创建人
+  created_at     timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at     timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `report_rule_no_idx` on `report_rule` (`rule_no`);
+
+-- 自动处理的记录
+create table `report_rule_log`
+(
+  id             bigint         not null primary key AUTO_INCREMENT,
+  rule_no        VARCHAR(40)    not null default '',  -- 规则编号
+  rule_name      VARCHAR(100)   not null default '',  -- 规则名称
+  target_uid     VARCHAR(40)    not null default '',  -- 被处理的用户
+  channel_id     VARCHAR(100)   not null default '',  -- 禁言的群 封禁时为空
+  channel_type   smallint       not null default 0,   -- 频道类型
+  action         VARCHAR(20)    not null default '',  -- 处理方式
+  action_seconds bigint         not null default 0,   -- 禁言或封禁的时长（秒）
+  reporter_count int            not null default 0,   -- 触发时的举报人数
+  status         smallint       not null default 0,   -- 状态 0.失败 1.成功
+  error          VARCHAR(255)   not null default '',  -- 失败原因
+  created_at     timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at     timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE INDEX `report_rule_log_target_idx` on `report_rule_log` (`target_uid`, `rule_no`);
+CREATE INDEX `report_rule_log_rule_idx` on `report_rule_log` (`rule_no`, `created_at`);
+CREATE INDEX `report_target_idx` on `report` (`target_uid`, `created_at`);
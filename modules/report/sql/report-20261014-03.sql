@@ -0,0 +1,4 @@
+-- +migrate Up
+
+-- 合规调查时查询消息是否被举报过
+CREATE INDEX report_message_idx on `report` (message_id);
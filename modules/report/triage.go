@@ -0,0 +1,140 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
+)
+
+// triage 举报的自动处理 提交举报后检查规则 达到阈值时自动禁言或封禁被举报的用户
+// 自动处理不会修改举报的状态 举报仍需管理员审核
+type triage struct {
+	ctx *config.Context
+	log.Log
+	ruleDB       *ruleDB
+	userDB       *user.DB
+	userService  user.IService
+	groupService group.IService
+}
+
+func newTriage(ctx *config.Context) *triage {
+	return &triage{
+		ctx:          ctx,
+		Log:          log.NewTLog("reportTriage"),
+		ruleDB:       newRuleDB(ctx),
+		userDB:       user.NewDB(ctx),
+		userService:  user.NewService(ctx),
+		groupService: group.NewService(ctx),
+	}
+}
+
+// evaluate 检查新提交的举报是否触发了规则
+func (t *triage) evaluate(report *model) {
+	if report.TargetUID == "" || report.TargetUID == report.UID {
+		return
+	}
+	rules, err := t.ruleDB.queryEnabled()
+	if err != nil {
+		t.Error("查询举报自动处理规则失败！", zap.Error(err))
+		return
+	}
+	now := time.Now()
+	for _, rule := range rules {
+		if !ruleMatches(rule, report) {
+			continue
+		}
+		channelID := ruleChannelID(rule, report)
+		start := now.Add(-time.Duration(rule.WindowSeconds) * time.Second).Format("2006-01-02 15:04:05")
+		handled, err := t.ruleDB.existSuccessLog(rule.RuleNo, report.TargetUID, channelID, start)
+		if err != nil {
+			t.Error("查询自动处理记录失败！", zap.Error(err), zap.String("ruleNo", rule.RuleNo))
+			continue
+		}
+		if handled {
+			continue
+		}
+		count, err := t.ruleDB.queryReporterCount(report.TargetUID, rule.CategoryNo, channelID, start)
+		if err != nil {
+			t.Error("查询举报人数失败！", zap.Error(err), zap.String("ruleNo", rule.RuleNo))
+			continue
+		}
+		if count < int64(rule.Threshold) {
+			continue
+		}
+		logModel := &ruleLogModel{
+			RuleNo:        rule.RuleNo,
+			RuleName:      rule.Name,
+			TargetUID:     report.TargetUID,
+			ChannelID:     channelID,
+			ChannelType:   report.ChannelType,
+			Action:        rule.Action,
+			ActionSeconds: rule.ActionSeconds,
+			ReporterCount: int(count),
+			Status:        RuleLogStatusSuccess,
+		}
+		if err = t.apply(rule, report.TargetUID, channelID, now); err != nil {
+			t.Warn("举报自动处理失败！", zap.Error(err), zap.String("ruleNo", rule.RuleNo), zap.String("targetUID", report.TargetUID))
+			logModel.Status = RuleLogStatusFailed
+			logModel.Error = err.Error()
+		}
+		if err = t.ruleDB.insertLog(logModel); err != nil {
+			t.Error("添加自动处理记录失败！", zap.Error(err), zap.String("ruleNo", rule.RuleNo))
+		}
+	}
+}
+
+// apply 执行规则的处理方式
+func (t *triage) apply(rule *ruleModel, targetUID string, channelID string, now time.Time) error {
+	switch rule.Action {
+	case ActionMute:
+		return t.groupService.MuteMember(channelID, targetUID, now.Unix()+rule.ActionSeconds)
+	case ActionBan:
+		target, err := t.userDB.QueryByUID(targetUID)
+		if err != nil {
+			return err
+		}
+		if target == nil {
+			return errors.New("被举报的用户不存在")
+		}
+		if rbac.IsManagerRole(target.Role) {
+			return errors.New("不能自动封禁管理后台的账号")
+		}
+		return t.userService.BanUser(targetUID, ruleBanReason(rule), rule.ActionSeconds, t.ctx.GetConfig().Account.SystemUID)
+	}
+	return errors.New("处理方式有误")
+}
+
+// ruleMatches 举报是否适用规则 禁言只能用于群内的举报
+func ruleMatches(rule *ruleModel, report *model) bool {
+	if report.CategoryNo == CategoryBanAppeal {
+		return false
+	}
+	if rule.CategoryNo != "" && rule.CategoryNo != report.CategoryNo {
+		return false
+	}
+	if rule.Action == ActionMute && report.ChannelType != common.ChannelTypeGroup.Uint8() {
+		return false
+	}
+	return true
+}
+
+// ruleChannelID 禁言时按群统计举报人数 封禁时统计所有频道
+func ruleChannelID(rule *ruleModel, report *model) string {
+	if rule.Action == ActionMute {
+		return report.ChannelID
+	}
+	return ""
+}
+
+// ruleBanReason 自动封禁的原因 被封禁的用户登录时可以看到
+func ruleBanReason(rule *ruleModel) string {
+	return fmt.Sprintf("您被多人举报（%s），账号已暂时封禁，等待人工审核", rule.Name)
+}
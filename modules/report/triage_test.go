@@ -0,0 +1,40 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleReqCheck(t *testing.T) {
+	req := &ruleReq{Name: " 群内骚扰 ", Threshold: 10, WindowSeconds: 3600, Action: ActionMute, Status: RuleStatusEnabled}
+	assert.NoError(t, req.check())
+	assert.Equal(t, "群内骚扰", req.Name)
+	assert.Equal(t, int64(defaultMuteSeconds), req.ActionSeconds)
+
+	assert.EqualError(t, (&ruleReq{Threshold: 10, WindowSeconds: 3600, Action: ActionMute}).check(), "规则名称不能为空")
+	assert.EqualError(t, (&ruleReq{Name: "a", Threshold: 1, WindowSeconds: 3600, Action: ActionMute}).check(), "举报人数需要在2到1000之间")
+	assert.EqualError(t, (&ruleReq{Name: "a", Threshold: 10, WindowSeconds: 10, Action: ActionMute}).check(), "统计的时间范围需要在1分钟到30天之间")
+	assert.EqualError(t, (&ruleReq{Name: "a", Threshold: 10, WindowSeconds: 3600, Action: ActionDeleteMessage}).check(), "处理方式有误")
+	// 不能自动永久封禁
+	assert.EqualError(t, (&ruleReq{Name: "a", Threshold: 10, WindowSeconds: 3600, Action: ActionBan}).check(), "处理时长需要在30天以内")
+	assert.EqualError(t, (&ruleReq{Name: "a", CategoryNo: CategoryBanAppeal, Threshold: 10, WindowSeconds: 3600, Action: ActionBan, ActionSeconds: 3600}).check(), "封禁申诉不能自动处理")
+	assert.EqualError(t, (&ruleReq{Name: "a", Threshold: 10, WindowSeconds: 3600, Action: ActionBan, ActionSeconds: 3600, Status: 2}).check(), "规则状态有误")
+}
+
+func TestRuleMatches(t *testing.T) {
+	groupReport := &model{CategoryNo: "ad", ChannelID: "g1", ChannelType: common.ChannelTypeGroup.Uint8(), TargetUID: "u2"}
+	personReport := &model{CategoryNo: "ad", ChannelID: "u2", ChannelType: common.ChannelTypePerson.Uint8(), TargetUID: "u2"}
+
+	mute := &ruleModel{Action: ActionMute}
+	assert.True(t, ruleMatches(mute, groupReport))
+	assert.False(t, ruleMatches(mute, personReport))
+	assert.Equal(t, "g1", ruleChannelID(mute, groupReport))
+
+	ban := &ruleModel{Action: ActionBan, CategoryNo: "ad"}
+	assert.True(t, ruleMatches(ban, personReport))
+	assert.Equal(t, "", ruleChannelID(ban, groupReport))
+	assert.False(t, ruleMatches(&ruleModel{Action: ActionBan, CategoryNo: "fraud"}, personReport))
+	assert.False(t, ruleMatches(&ruleModel{Action: ActionBan}, &model{CategoryNo: CategoryBanAppeal, TargetUID: "u2"}))
+}
@@ -26,4 +26,13 @@ func init() {
 			Swagger: swaggerContent,
 		}
 	})
+
+	register.AddModule(func(ctx interface{}) register.Module {
+
+		return register.Module{
+			SetupAPI: func() register.APIRouter {
+				return NewManager(ctx.(*config.Context))
+			},
+		}
+	})
 }
@@ -12,7 +12,13 @@ import (
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/app"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -31,11 +37,22 @@ type Robot struct {
 	robotEventPrefix                  string
 	userService                       user.IService
 	appService                        app.IService
+	groupService                      group.IService
+	messageService                    message.IService
+	fileService                       file.IService
+	webhookClient                     *http.Client             // 推送消息到机器人的webhook
 	inlineQueryEventsMap              map[string][]*robotEvent // inlineQuery事件
 	inlineQueryEventsMapLock          sync.RWMutex
 	inlineQueryEventResultChanMap     map[string]chan *InlineQueryResult
 	inlineQueryEventResultChanMapLock sync.RWMutex
 	mentionRegexp                     *regexp.Regexp
+	callbackQueryWaiters              map[string]*callbackQueryWaiter // 等待机器人响应的按钮点击
+	callbackQueryWaitersLock          sync.Mutex
+	updatesNotifiers                  map[string]chan struct{} // 等待新事件的长轮询
+	updatesNotifiersLock              sync.Mutex
+	outgoingRegexps                   map[string]*regexp.Regexp // outgoing webhook编译后的正则表达式
+	outgoingRegexpsLock               sync.Mutex
+	outgoingPushSem                   chan struct{} // 限制同时推送outgoing webhook的数量
 }
 
 func New(ctx *config.Context) *Robot {
@@ -46,14 +63,30 @@ func New(ctx *config.Context) *Robot {
 		robotEventPrefix:              "robotEvent:",
 		userService:                   user.NewService(ctx),
 		appService:                    app.NewService(ctx),
+		groupService:                  group.NewService(ctx),
+		messageService:                message.NewService(ctx),
+		fileService:                   file.NewService(ctx),
+		webhookClient:                 newWebhookClient(extconfig.Get().Bot.WebhookTimeout, extconfig.Get().Bot.WebhookAllowPrivate),
 		inlineQueryEventsMap:          map[string][]*robotEvent{},
 		inlineQueryEventResultChanMap: map[string]chan *InlineQueryResult{},
 		mentionRegexp:                 regexp.MustCompile(`@\S+`),
+		callbackQueryWaiters:          map[string]*callbackQueryWaiter{},
+		updatesNotifiers:              map[string]chan struct{}{},
+		outgoingRegexps:               map[string]*regexp.Regexp{},
+		outgoingPushSem:               make(chan struct{}, outgoingPushMaxConcurrency),
 	}
 	ctx.AddMessagesListener(rb.messagesListen)
 
 	ctx.AddMessagesListener(rb.robotMessageListen)
 
+	ctx.AddMessagesListener(rb.outgoingWebhookListen)
+
+	ctx.AddEventListener(event.EventUserRegister, rb.handleUserRegister) // 注册后发送新用户引导消息
+
+	ctx.Schedule(time.Second*5, rb.retryWebhookDeliveries) // 重试推送失败的webhook
+	ctx.Schedule(time.Hour, rb.cleanWebhookDeliveries)     // 清理过期的webhook推送记录
+	ctx.Schedule(time.Hour, rb.cleanStats)                 // 清理过期的机器人统计
+
 	return rb
 }
 
@@ -62,23 +95,67 @@ func (rb *Robot) Route(r *wkhttp.WKHttp) {
 
 	auth := r.Group("/v1", rb.ctx.AuthMiddleware(r))
 	{
-		auth.POST("/robot/sync", rb.sync)                // 同步机器人菜单
-		auth.POST("/robot/inline_query", rb.inlineQuery) // 机器人行内搜索
+		auth.POST("/robot/sync", rb.sync)                       // 同步机器人菜单
+		auth.POST("/robot/inline_query", rb.inlineQuery)        // 机器人行内搜索
+		auth.POST("/robot/callback_query", rb.callbackQuery)    // 点击机器人消息的按钮
+		auth.GET("/robot/directory", rb.directories)            // 机器人目录
+		auth.GET("/robot/directory/:robot_id", rb.directoryGet) // 目录中的机器人详情
+
+		auth.GET("/groups/:group_no/outgoing_webhooks", rb.outgoingWebhooks)             // 群的outgoing webhook列表
+		auth.POST("/groups/:group_no/outgoing_webhooks", rb.outgoingWebhookAdd)          // 添加outgoing webhook
+		auth.PUT("/groups/:group_no/outgoing_webhooks/:id", rb.outgoingWebhookUpdate)    // 修改outgoing webhook
+		auth.DELETE("/groups/:group_no/outgoing_webhooks/:id", rb.outgoingWebhookDelete) // 删除outgoing webhook
+
+		auth.GET("/groups/:group_no/incoming_webhooks", rb.incomingWebhooks)                     // 群的incoming webhook列表
+		auth.POST("/groups/:group_no/incoming_webhooks", rb.incomingWebhookAdd)                  // 添加incoming webhook
+		auth.PUT("/groups/:group_no/incoming_webhooks/:id", rb.incomingWebhookUpdate)            // 修改incoming webhook
+		auth.POST("/groups/:group_no/incoming_webhooks/:id/token", rb.incomingWebhookResetToken) // 重新生成incoming webhook的token
+		auth.DELETE("/groups/:group_no/incoming_webhooks/:id", rb.incomingWebhookDelete)         // 删除incoming webhook
+
+		auth.GET("/groups/:group_no/bot_permissions", rb.groupPermissions)                   // 群授权给机器人的管理权限
+		auth.PUT("/groups/:group_no/bot_permissions/:robot_id", rb.groupPermissionSet)       // 设置机器人的管理权限
+		auth.DELETE("/groups/:group_no/bot_permissions/:robot_id", rb.groupPermissionDelete) // 取消机器人的管理权限
 	}
 
+	// 通过incoming webhook发送消息 通过token认证
+	r.POST("/v1/hooks/:token", rb.incomingWebhookPost)
+
 	robotAuth := r.Group("/v1/robots/:robot_id/:app_key", rb.authRobot()) // :robot_id即user的username
 	{
-		robotAuth.GET("/events", rb.getEventsForGet)               // 获取事件
-		robotAuth.POST("/events", rb.getEventsForPost)             // 获取事件（POST方式）
-		robotAuth.POST("/events/:event_id/ack", rb.eventAck)       // 事件确认
-		robotAuth.POST("/answerInlineQuery", rb.answerInlineQuery) // 响应inlineQuery
-		robotAuth.POST("/sendMessage", rb.sendMessage)             // 发送消息
-		robotAuth.POST("/typing", rb.typing)                       // 输入中
-		robotAuth.POST("/stream/start", rb.streamStart)            // 流式消息开启
-		robotAuth.POST("/stream/end", rb.streamEnd)                // 流式消息结束
+		robotAuth.GET("/events", rb.getEventsForGet)                   // 获取事件
+		robotAuth.POST("/events", rb.getEventsForPost)                 // 获取事件（POST方式）
+		robotAuth.POST("/events/:event_id/ack", rb.eventAck)           // 事件确认
+		robotAuth.POST("/answerInlineQuery", rb.answerInlineQuery)     // 响应inlineQuery
+		robotAuth.POST("/answerCallbackQuery", rb.answerCallbackQuery) // 响应按钮点击
+		robotAuth.POST("/sendMessage", rb.sendMessage)                 // 发送消息
+		robotAuth.POST("/typing", rb.typing)                           // 输入中
+		robotAuth.POST("/stream/start", rb.streamStart)                // 流式消息开启
+		robotAuth.POST("/stream/end", rb.streamEnd)                    // 流式消息结束
+		robotAuth.POST("/token", rb.resetToken)                        // 生成机器人HTTP API的token
 
 	}
 
+	botAuth := r.Group("/v1/bot/:token", rb.authBot(), rb.botRateLimit()) // 通过机器人token调用
+	{
+		botAuth.GET("/getMe", rb.botGetMe)                                    // 机器人信息
+		botAuth.GET("/getUpdates", rb.botGetUpdates)                          // 长轮询获取事件 没有设置webhook时使用
+		botAuth.POST("/sendMessage", rb.botSendMessage)                       // 发送消息
+		botAuth.POST("/sendPhoto", rb.botSendPhoto)                           // 上传图片并发送图片消息
+		botAuth.POST("/sendDocument", rb.botSendDocument)                     // 上传文件并发送文件消息
+		botAuth.GET("/getFile", rb.botGetFile)                                // 获取文件信息和下载地址
+		botAuth.POST("/editMessage", rb.botEditMessage)                       // 编辑机器人发送的消息
+		botAuth.POST("/deleteMessage", rb.botDeleteMessage)                   // 撤回机器人发送的消息（群授权后可撤回成员的消息）
+		botAuth.POST("/muteMember", rb.botMuteMember)                         // 禁言群成员 需要群授权
+		botAuth.POST("/kickMember", rb.botKickMember)                         // 移除群成员 需要群授权
+		botAuth.POST("/editMessageReplyMarkup", rb.botEditMessageReplyMarkup) // 修改机器人发送的消息的按钮
+		botAuth.POST("/answerCallbackQuery", rb.botAnswerCallbackQuery)       // 响应按钮点击
+		botAuth.GET("/getChat", rb.botGetChat)                                // 获取频道信息
+		botAuth.POST("/setWebhook", rb.botSetWebhook)                         // 设置接收消息的webhook
+		botAuth.POST("/deleteWebhook", rb.botDeleteWebhook)                   // 删除webhook 改为通过events拉取
+		botAuth.GET("/getWebhookInfo", rb.botGetWebhookInfo)                  // 获取webhook信息
+		botAuth.GET("/getStats", rb.botGetStats)                              // 机器人的每日统计
+	}
+
 	rb.insertSystemRobot()
 }
 
@@ -86,7 +163,7 @@ func (rb *Robot) streamStart(c *wkhttp.Context) {
 	var req config.MessageStreamStartReq
 	if err := c.BindJSON(&req); err != nil {
 		rb.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 
@@ -105,7 +182,7 @@ func (rb *Robot) streamEnd(c *wkhttp.Context) {
 	var req config.MessageStreamEndReq
 	if err := c.BindJSON(&req); err != nil {
 		rb.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	err := rb.ctx.IMStreamEnd(req)
@@ -165,7 +242,7 @@ func (rb *Robot) typing(c *wkhttp.Context) {
 	var req *TypingReq
 	if err := c.BindJSON(&req); err != nil {
 		rb.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if strings.TrimSpace(req.ChannelID) == "" {
@@ -198,53 +275,39 @@ func (rb *Robot) sendMessage(c *wkhttp.Context) {
 	var messageReq *MessageReq
 	if err := c.BindJSON(&messageReq); err != nil {
 		rb.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
-		return
-	}
-	if strings.TrimSpace(messageReq.ChannelID) == "" {
-		c.ResponseError(errors.New("channel_id不能为空！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
-	if messageReq.ChannelType == 0 {
-		c.ResponseError(errors.New("channel_type不能为空！"))
-		return
-	}
-	if len(messageReq.Payload) == 0 {
-		c.ResponseError(errors.New("payload不能为空！"))
+	result, err := rb.sendRobotMessage(c.Param("robot_id"), messageReq)
+	if err != nil {
+		c.ResponseError(err)
 		return
 	}
+	c.Response(result)
+}
 
-	if !rb.allowSendToChannel(messageReq.ChannelID, messageReq.ChannelType) {
-		c.ResponseError(errors.New("不允许发送消息到此频道！"))
-		return
+// sendRobotMessage 以机器人的身份发送消息
+func (rb *Robot) sendRobotMessage(robotID string, messageReq *MessageReq) (*config.MsgSendResp, error) {
+	if strings.TrimSpace(messageReq.ChannelID) == "" {
+		return nil, errors.New("channel_id不能为空！")
 	}
-
-	payloadResult := maputil.Data(messageReq.Payload)
-	contentTypeValue := payloadResult.Int("type")
-	if contentTypeValue == 0 {
-		c.ResponseError(errors.New("payload.type不能为空！"))
-		return
+	if messageReq.ChannelType == 0 {
+		return nil, errors.New("channel_type不能为空！")
 	}
-	contentType := common.ContentType(contentTypeValue)
-	if !rb.supportContentType(contentType) {
-		c.ResponseError(fmt.Errorf("不支持的type[%d]", contentType))
-		return
+	if err := checkPayload(messageReq.Payload); err != nil {
+		return nil, err
 	}
 
-	if !rb.payloadIsVail(payloadResult) {
-		c.ResponseError(fmt.Errorf("无效的payload[%s]", util.ToJson(messageReq.Payload)))
-		return
+	if !rb.allowSendToChannel(messageReq.ChannelID, messageReq.ChannelType) {
+		return nil, errors.New("不允许发送消息到此频道！")
 	}
-	robotID := c.Param("robot_id")
 	userResp, err := rb.userService.GetUserWithUsername(robotID)
 	if err != nil {
 		rb.Error("查询机器人的用户信息失败！", zap.Error(err))
-		c.ResponseError(fmt.Errorf("获取机器人[%s]信息失败！", robotID))
-		return
+		return nil, fmt.Errorf("获取机器人[%s]信息失败！", robotID)
 	}
 	if userResp == nil {
-		c.ResponseError(fmt.Errorf("机器人[%s]不存在！", robotID))
-		return
+		return nil, fmt.Errorf("机器人[%s]不存在！", robotID)
 	}
 	result, err := rb.ctx.SendMessageWithResult(&config.MsgSendReq{
 		StreamNo:    messageReq.StreamNo,
@@ -255,23 +318,49 @@ func (rb *Robot) sendMessage(c *wkhttp.Context) {
 	})
 	if err != nil {
 		rb.Error("发送robot消息失败！", zap.Error(err))
-		c.ResponseError(errors.New("发送消息失败！"))
-		return
+		return nil, errcode.ErrSendMessage
 	}
-	c.Response(result)
+	go rb.statMessageSent(robotID, messageReq.ChannelID, messageReq.ChannelType)
+	return result, nil
 }
 
-func (rb *Robot) supportContentType(contentType common.ContentType) bool {
-	return contentType == common.Text
+// checkPayload 校验机器人发送的消息正文
+func checkPayload(payload map[string]interface{}) error {
+	if len(payload) == 0 {
+		return errors.New("payload不能为空！")
+	}
+	payloadResult := maputil.Data(payload)
+	contentTypeValue := payloadResult.Int("type")
+	if contentTypeValue == 0 {
+		return errors.New("payload.type不能为空！")
+	}
+	contentType := common.ContentType(contentTypeValue)
+	if !supportContentType(contentType) {
+		return fmt.Errorf("不支持的type[%d]", contentType)
+	}
+	if !payloadIsVail(payloadResult) {
+		return fmt.Errorf("无效的payload[%s]", util.ToJson(payload))
+	}
+	return checkReplyMarkup(payload["reply_markup"])
 }
 
-func (rb *Robot) payloadIsVail(payloadResult maputil.Data) bool {
+func supportContentType(contentType common.ContentType) bool {
+	return contentType == common.Text || contentType == common.Image || contentType == common.File
+}
+
+func payloadIsVail(payloadResult maputil.Data) bool {
 	contentType := common.ContentType(payloadResult.Int("type"))
 	if contentType == common.Text {
 		if payloadResult.Get("content") != nil {
 			return true
 		}
 	}
+	// 图片和文件需要先通过sendPhoto、sendDocument或文件上传接口上传
+	if contentType == common.Image || contentType == common.File {
+		if payloadResult.Str("url") != "" {
+			return true
+		}
+	}
 	return false
 }
 
@@ -285,7 +374,7 @@ func (rb *Robot) answerInlineQuery(c *wkhttp.Context) {
 	var result *InlineQueryResult
 	if err := c.BindJSON(&result); err != nil {
 		rb.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if err := result.Check(); err != nil {
@@ -311,7 +400,7 @@ func (rb *Robot) inlineQuery(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		rb.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if len(req.Username) == 0 {
@@ -381,6 +470,7 @@ func (rb *Robot) addInlineQuery(robotID string, inlineQuery *InlineQuery) {
 	})
 	rb.inlineQueryEventsMap[robotID] = events
 	rb.inlineQueryEventsMapLock.Unlock()
+	rb.notifyUpdates(robotID)
 }
 
 func (rb *Robot) removeInlineQuery(robotID, sid string) {
@@ -491,7 +581,7 @@ func (rb *Robot) getEventsForPost(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		rb.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	results, err := rb.getEventsResult(robotID, req.EventID, req.Limit)
@@ -726,9 +816,10 @@ type menuResp struct {
 }
 
 type robotEventResp struct {
-	EventID     int64                   `json:"event_id,omitempty"` // 更新ID
-	Message     *simpleRobotMessageResp `json:"message,omitempty"`  // 消息对象
-	InlineQuery *InlineQuery            `json:"inline_query"`       // 查询
+	EventID       int64                   `json:"event_id,omitempty"`       // 更新ID
+	Message       *simpleRobotMessageResp `json:"message,omitempty"`        // 消息对象
+	InlineQuery   *InlineQuery            `json:"inline_query"`             // 查询
+	CallbackQuery *CallbackQuery          `json:"callback_query,omitempty"` // 点击消息按钮
 }
 
 func (s *robotEventResp) from(resp *robotEvent) {
@@ -741,6 +832,9 @@ func (s *robotEventResp) from(resp *robotEvent) {
 	if resp.InlineQuery != nil {
 		s.InlineQuery = resp.InlineQuery
 	}
+	if resp.CallbackQuery != nil {
+		s.CallbackQuery = resp.CallbackQuery
+	}
 
 }
 
@@ -2,8 +2,13 @@ package robot
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -29,22 +34,34 @@ func NewManager(ctx *config.Context) *Manager {
 func (m *Manager) Route(r *wkhttp.WKHttp) {
 	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
 	{
-		auth.GET("/robot/menus", m.list)                                 // 机器人菜单
-		auth.DELETE("/robot/:robot_id/:id", m.delete)                    // 删除某个机器人菜单
-		auth.PUT("/robot/status/:robot_id/:status", m.updateRobotStatus) // 修改机器人状态
+		auth.GET("/robot/menus", m.list)                                         // 机器人菜单
+		auth.DELETE("/robot/:robot_id/:id", m.delete)                            // 删除某个机器人菜单
+		auth.PUT("/robot/status/:robot_id/:status", m.updateRobotStatus)         // 修改机器人状态
+		auth.GET("/robot/webhook/deliveries", m.webhookDeliveries)               // 机器人webhook推送记录
+		auth.POST("/robot/webhook/deliveries/:id/redeliver", m.redeliverWebhook) // 重新推送webhook
+		auth.GET("/robot/stats", m.stats)                                        // 机器人的每日统计
+		auth.GET("/robot/onboarding/steps", m.onboardingSteps)                   // 新用户引导的步骤
+		auth.POST("/robot/onboarding/steps", m.onboardingStepAdd)                // 添加新用户引导的步骤
+		auth.PUT("/robot/onboarding/steps/:id", m.onboardingStepUpdate)          // 修改新用户引导的步骤
+		auth.DELETE("/robot/onboarding/steps/:id", m.onboardingStepDelete)       // 删除新用户引导的步骤
+		auth.POST("/robot/onboarding/preview", m.onboardingPreview)              // 发送新用户引导消息给自己预览
+		auth.GET("/robot/directory", m.directories)                              // 机器人目录
+		auth.POST("/robot/directory", m.directoryAdd)                            // 添加机器人到目录
+		auth.PUT("/robot/directory/:robot_id", m.directoryUpdate)                // 修改目录中的机器人
+		auth.DELETE("/robot/directory/:robot_id", m.directoryDelete)             // 从目录中移除机器人
 	}
 }
 
 // 查询某个机器人菜单
 func (m *Manager) list(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermConfigRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
 	}
 	robotID := c.Query("robot_id")
 	if robotID == "" {
-		c.ResponseError(errors.New("机器人ID不能为空"))
+		c.ResponseError(errcode.ErrRobotIDRequired)
 		return
 	}
 	list, err := m.db.queryMenusWithRobotID(robotID)
@@ -73,7 +90,7 @@ func (m *Manager) list(c *wkhttp.Context) {
 }
 
 func (m *Manager) delete(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -81,7 +98,7 @@ func (m *Manager) delete(c *wkhttp.Context) {
 	robot_id := c.Param("robot_id")
 	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
 	if robot_id == "" {
-		c.ResponseError(errors.New("机器人ID不能为空"))
+		c.ResponseError(errcode.ErrRobotIDRequired)
 		return
 	}
 	robot, err := m.db.queryRobotWithRobtID(robot_id)
@@ -127,7 +144,7 @@ func (m *Manager) delete(c *wkhttp.Context) {
 
 // 启用或禁用机器人
 func (m *Manager) updateRobotStatus(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -136,7 +153,7 @@ func (m *Manager) updateRobotStatus(c *wkhttp.Context) {
 	status, _ := strconv.ParseInt(c.Param("status"), 10, 64)
 
 	if robot_id == "" {
-		c.ResponseError(errors.New("机器人ID不能为空"))
+		c.ResponseError(errcode.ErrRobotIDRequired)
 		return
 	}
 	robot, err := m.db.queryRobotWithRobtID(robot_id)
@@ -157,6 +174,168 @@ func (m *Manager) updateRobotStatus(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 
+// 设置机器人上传文件的最大大小和允许的文件类型 为空时使用默认配置
+func (m *Manager) updateFileLimit(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		MaxSize   int64    `json:"max_size"`   // 最大大小（MB） 0为使用默认配置
+		MimeTypes []string `json:"mime_types"` // 允许的文件类型 例如 application/pdf、image/*
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if req.MaxSize < 0 {
+		c.ResponseError(errors.New("最大大小不能小于0"))
+		return
+	}
+	mimeTypes := make([]string, 0, len(req.MimeTypes))
+	for _, mimeType := range req.MimeTypes {
+		mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+		if mimeType == "" {
+			continue
+		}
+		if !strings.Contains(mimeType, "/") || strings.Contains(mimeType, ",") {
+			c.ResponseError(fmt.Errorf("文件类型[%s]格式有误", mimeType))
+			return
+		}
+		mimeTypes = append(mimeTypes, mimeType)
+	}
+	fileMimeTypes := strings.Join(mimeTypes, ",")
+	if len(fileMimeTypes) > 255 {
+		c.ResponseError(errors.New("文件类型太多"))
+		return
+	}
+	robotID := c.Param("robot_id")
+	robot, err := m.db.queryRobotWithRobtID(robotID)
+	if err != nil {
+		c.ResponseError(errors.New("查询操作的机器人错误"))
+		return
+	}
+	if robot == nil {
+		c.ResponseError(errors.New("操作的机器人不存在"))
+		return
+	}
+	err = m.db.updateFileLimit(robotID, req.MaxSize, fileMimeTypes)
+	if err != nil {
+		m.Error("设置机器人上传文件的限制错误", zap.Error(err))
+		c.ResponseError(errors.New("设置机器人上传文件的限制错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 查询机器人的webhook推送记录
+func (m *Manager) webhookDeliveries(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermLogRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	robotID := c.Query("robot_id")
+	if robotID == "" {
+		c.ResponseError(errcode.ErrRobotIDRequired)
+		return
+	}
+	status := -1
+	if statusStr := c.Query("status"); statusStr != "" {
+		status, _ = strconv.Atoi(statusStr)
+	}
+	pageIndex, pageSize := c.GetPage()
+	list, err := m.db.queryWebhookDeliveries(robotID, status, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询webhook推送记录错误", zap.Error(err))
+		c.ResponseError(errors.New("查询webhook推送记录错误"))
+		return
+	}
+	count, err := m.db.queryWebhookDeliveryCount(robotID, status)
+	if err != nil {
+		m.Error("查询webhook推送记录数量错误", zap.Error(err))
+		c.ResponseError(errors.New("查询webhook推送记录数量错误"))
+		return
+	}
+	result := make([]*webhookDeliveryResp, 0, len(list))
+	for _, delivery := range list {
+		result = append(result, newWebhookDeliveryResp(delivery))
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  result,
+	})
+}
+
+// 重新推送失败的webhook
+func (m *Manager) redeliverWebhook(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	delivery, err := m.db.queryWebhookDeliveryWithID(id)
+	if err != nil {
+		m.Error("查询webhook推送记录错误", zap.Error(err))
+		c.ResponseError(errors.New("查询webhook推送记录错误"))
+		return
+	}
+	if delivery == nil {
+		c.ResponseError(errors.New("推送记录不存在"))
+		return
+	}
+	if delivery.Status == webhookDeliverySuccess {
+		c.ResponseError(errors.New("推送已成功，不需要重新推送"))
+		return
+	}
+	err = m.db.redeliverWebhookDelivery(id)
+	if err != nil {
+		m.Error("重新推送webhook错误", zap.Error(err))
+		c.ResponseError(errors.New("重新推送webhook错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+type webhookDeliveryResp struct {
+	Id          int64  `json:"id"`
+	RobotID     string `json:"robot_id"`
+	EventID     int64  `json:"event_id"`
+	URL         string `json:"url"`
+	Body        string `json:"body"`
+	Status      int    `json:"status"` // 0.等待重试 1.成功 2.失败
+	Attempts    int    `json:"attempts"`
+	StatusCode  int    `json:"status_code"`
+	Error       string `json:"error"`
+	Duration    int64  `json:"duration"`
+	NextRetryAt int64  `json:"next_retry_at"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+func newWebhookDeliveryResp(m *webhookDeliveryModel) *webhookDeliveryResp {
+	resp := &webhookDeliveryResp{
+		Id:         m.Id,
+		RobotID:    m.RobotID,
+		EventID:    m.EventID,
+		URL:        m.URL,
+		Body:       m.Body,
+		Status:     m.Status,
+		Attempts:   m.Attempts,
+		StatusCode: m.StatusCode,
+		Error:      m.Error,
+		Duration:   m.Duration,
+		CreatedAt:  m.CreatedAt.String(),
+		UpdatedAt:  m.UpdatedAt.String(),
+	}
+	if m.Status == webhookDeliveryPending {
+		resp.NextRetryAt = m.NextRetryAt
+	}
+	return resp
+}
+
 type robotMenu struct {
 	Id        int64  `json:"id"`
 	CMD       string `json:"cmd"`
@@ -166,3 +345,291 @@ type robotMenu struct {
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
+
+// 机器人的每日统计
+func (m *Manager) stats(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermStatsRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	robotID := c.Query("robot_id")
+	if robotID == "" {
+		c.ResponseError(errcode.ErrRobotIDRequired)
+		return
+	}
+	start, end, err := parseStatDateRange(c.Query("start_date"), c.Query("end_date"), time.Now())
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	resp, err := queryRobotStatsResp(m.db, m.Log, robotID, start, end)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(resp)
+}
+
+// 新用户引导的全部步骤
+func (m *Manager) onboardingSteps(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	list, err := m.db.queryOnboardingSteps()
+	if err != nil {
+		m.Error("查询新用户引导步骤错误", zap.Error(err))
+		c.ResponseError(errors.New("查询新用户引导步骤错误"))
+		return
+	}
+	resps := make([]*onboardingStepResp, 0, len(list))
+	for _, step := range list {
+		resps = append(resps, newOnboardingStepResp(step))
+	}
+	c.Response(resps)
+}
+
+// 添加新用户引导的步骤
+func (m *Manager) onboardingStepAdd(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req onboardingStepReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	count, err := m.db.queryOnboardingStepCount(strings.TrimSpace(req.TriggerData))
+	if err != nil {
+		m.Error("查询新用户引导步骤数量错误", zap.Error(err))
+		c.ResponseError(errors.New("查询新用户引导步骤数量错误"))
+		return
+	}
+	if count >= onboardingMaxSteps {
+		c.ResponseError(fmt.Errorf("每个触发条件最多只能添加%d个步骤", onboardingMaxSteps))
+		return
+	}
+	step := &onboardingStepModel{
+		Status: 1,
+	}
+	req.fill(step)
+	err = m.db.insertOnboardingStep(step)
+	if err != nil {
+		m.Error("添加新用户引导步骤错误", zap.Error(err))
+		c.ResponseError(errors.New("添加新用户引导步骤错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 修改新用户引导的步骤
+func (m *Manager) onboardingStepUpdate(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req onboardingStepReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	step, err := m.db.queryOnboardingStepWithID(id)
+	if err != nil {
+		m.Error("查询新用户引导步骤错误", zap.Error(err))
+		c.ResponseError(errors.New("查询新用户引导步骤错误"))
+		return
+	}
+	if step == nil {
+		c.ResponseError(errors.New("新用户引导步骤不存在"))
+		return
+	}
+	req.fill(step)
+	err = m.db.updateOnboardingStep(step)
+	if err != nil {
+		m.Error("修改新用户引导步骤错误", zap.Error(err))
+		c.ResponseError(errors.New("修改新用户引导步骤错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 删除新用户引导的步骤
+func (m *Manager) onboardingStepDelete(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	err = m.db.deleteOnboardingStep(id)
+	if err != nil {
+		m.Error("删除新用户引导步骤错误", zap.Error(err))
+		c.ResponseError(errors.New("删除新用户引导步骤错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 按触发条件发送启用的引导消息给自己 用于预览
+func (m *Manager) onboardingPreview(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	steps, err := m.db.queryEnabledOnboardingSteps(strings.TrimSpace(c.Query("trigger_data")))
+	if err != nil {
+		m.Error("查询新用户引导步骤错误", zap.Error(err))
+		c.ResponseError(errors.New("查询新用户引导步骤错误"))
+		return
+	}
+	if len(steps) == 0 {
+		c.ResponseError(errors.New("没有启用的新用户引导步骤"))
+		return
+	}
+	go sendOnboardingSteps(m.ctx, m.Log, steps, c.GetLoginUID())
+	c.ResponseOK()
+}
+
+// 机器人目录 包括隐藏的
+func (m *Manager) directories(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	resps, count, err := queryDirectoryResps(m.db, m.ctx.GetConfig(), &directoryQuery{
+		category: strings.TrimSpace(c.Query("category")),
+		keyword:  strings.TrimSpace(c.Query("keyword")),
+	}, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询机器人目录错误", zap.Error(err))
+		c.ResponseError(errors.New("查询机器人目录错误"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  resps,
+	})
+}
+
+// 添加机器人到目录
+func (m *Manager) directoryAdd(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req directoryReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if strings.TrimSpace(req.RobotID) == "" {
+		c.ResponseError(errcode.ErrRobotIDRequired)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	robot, err := m.db.queryRobotWithRobtID(req.RobotID)
+	if err != nil {
+		m.Error("查询机器人错误", zap.Error(err))
+		c.ResponseError(errors.New("查询机器人错误"))
+		return
+	}
+	if robot == nil {
+		c.ResponseError(errors.New("机器人不存在"))
+		return
+	}
+	directory, err := m.db.queryDirectoryWithRobotID(req.RobotID)
+	if err != nil {
+		m.Error("查询机器人目录错误", zap.Error(err))
+		c.ResponseError(errors.New("查询机器人目录错误"))
+		return
+	}
+	if directory != nil {
+		c.ResponseError(errors.New("机器人已在目录中"))
+		return
+	}
+	directory = &directoryModel{
+		RobotID: req.RobotID,
+		Status:  1,
+	}
+	req.fill(directory)
+	err = m.db.insertDirectory(directory)
+	if err != nil {
+		m.Error("添加机器人到目录错误", zap.Error(err))
+		c.ResponseError(errors.New("添加机器人到目录错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 修改目录中的机器人
+func (m *Manager) directoryUpdate(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req directoryReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	directory, err := m.db.queryDirectoryWithRobotID(c.Param("robot_id"))
+	if err != nil {
+		m.Error("查询机器人目录错误", zap.Error(err))
+		c.ResponseError(errors.New("查询机器人目录错误"))
+		return
+	}
+	if directory == nil {
+		c.ResponseError(errors.New("机器人不在目录中"))
+		return
+	}
+	req.fill(directory)
+	err = m.db.updateDirectory(directory)
+	if err != nil {
+		m.Error("修改目录中的机器人错误", zap.Error(err))
+		c.ResponseError(errors.New("修改目录中的机器人错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 从目录中移除机器人
+func (m *Manager) directoryDelete(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	err = m.db.deleteDirectory(c.Param("robot_id"))
+	if err != nil {
+		m.Error("从目录中移除机器人错误", zap.Error(err))
+		c.ResponseError(errors.New("从目录中移除机器人错误"))
+		return
+	}
+	c.ResponseOK()
+}
@@ -0,0 +1,357 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// botContextKey 通过token认证后的机器人
+	botContextKey = "bot"
+	// botRateLimitPrefix 机器人每秒调用接口的次数
+	botRateLimitPrefix = "botRateLimit:"
+	// botChatRateLimitPrefix 机器人每分钟给某个频道发送的消息数
+	botChatRateLimitPrefix = "botChatRateLimit:"
+)
+
+// 生成机器人HTTP API的token 旧的token立即失效
+func (rb *Robot) resetToken(c *wkhttp.Context) {
+	robotID := c.Param("robot_id")
+	token := util.GenerUUID()
+	if err := rb.db.updateToken(robotID, token); err != nil {
+		rb.Error("修改机器人token失败！", zap.Error(err), zap.String("robotID", robotID))
+		c.ResponseError(errors.New("修改机器人token失败！"))
+		return
+	}
+	c.Response(gin.H{
+		"token": token,
+	})
+}
+
+// authBot 通过路径中的token认证机器人
+func (rb *Robot) authBot() wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		token := c.Param("token")
+		if strings.TrimSpace(token) == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"msg": "token不能为空！",
+			})
+			return
+		}
+		robotM, err := rb.db.queryVaildRobotWithToken(token)
+		if err != nil {
+			rb.Error("查询robot失败！", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"msg": "查询robot失败！",
+			})
+			return
+		}
+		if robotM == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"msg": "token不正确！",
+			})
+			return
+		}
+		c.Set(botContextKey, robotM)
+		c.Next()
+	}
+}
+
+// botRateLimit 限制每个机器人每秒调用接口的次数
+func (rb *Robot) botRateLimit() wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		limit := extconfig.Get().Bot.RateLimit
+		if limit > 0 {
+			key := fmt.Sprintf("%s%s:%d", botRateLimitPrefix, botFromContext(c).RobotID, time.Now().Unix())
+			if !rb.allowRate(key, limit, time.Second*2) {
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+					"msg": "请求过于频繁，请稍后再试！",
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// allowRate 固定窗口计数 redis出错时不限制
+func (rb *Robot) allowRate(key string, limit int, expire time.Duration) bool {
+	count, err := rb.ctx.GetRedisConn().Incr(key)
+	if err != nil {
+		rb.Warn("机器人限流计数失败！", zap.Error(err), zap.String("key", key))
+		return true
+	}
+	if count == 1 {
+		_ = rb.ctx.GetRedisConn().Expire(key, expire)
+	}
+	return count <= int64(limit)
+}
+
+func botFromContext(c *wkhttp.Context) *robot {
+	return c.MustGet(botContextKey).(*robot)
+}
+
+// 机器人信息
+func (rb *Robot) botGetMe(c *wkhttp.Context) {
+	robotM := botFromContext(c)
+	resp := gin.H{
+		"robot_id":    robotM.RobotID,
+		"username":    robotM.Username,
+		"inline_on":   robotM.InlineOn,
+		"placeholder": robotM.Placeholder,
+		"webhook_on":  robotM.WebhookURL != "",
+	}
+	userResp, err := rb.userService.GetUser(robotM.RobotID)
+	if err != nil {
+		rb.Warn("查询机器人的用户信息失败！", zap.Error(err))
+	}
+	if userResp != nil {
+		resp["name"] = userResp.Name
+	}
+	c.Response(resp)
+}
+
+// 发送消息
+func (rb *Robot) botSendMessage(c *wkhttp.Context) {
+	var messageReq *MessageReq
+	if err := c.BindJSON(&messageReq); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	robotM := botFromContext(c)
+	if !rb.checkBotChatRate(c, robotM.RobotID, messageReq.ChannelID, messageReq.ChannelType) {
+		return
+	}
+	result, err := rb.sendRobotMessage(robotM.RobotID, messageReq)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(result)
+}
+
+// checkBotChatRate 限制机器人每分钟给同一个频道发送的消息数 超过时返回429
+func (rb *Robot) checkBotChatRate(c *wkhttp.Context, robotID string, channelID string, channelType uint8) bool {
+	limit := extconfig.Get().Bot.ChatRateLimit
+	if limit <= 0 {
+		return true
+	}
+	key := fmt.Sprintf("%s%s:%d:%s:%d", botChatRateLimitPrefix, robotID, channelType, channelID, time.Now().Unix()/60)
+	if !rb.allowRate(key, limit, time.Minute*2) {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"msg": "发送消息过于频繁，请稍后再试！",
+		})
+		return false
+	}
+	return true
+}
+
+// 编辑机器人发送的消息
+func (rb *Robot) botEditMessage(c *wkhttp.Context) {
+	var req struct {
+		ChannelID   string                 `json:"channel_id"`
+		ChannelType uint8                  `json:"channel_type"`
+		MessageID   int64                  `json:"message_id"`
+		Payload     map[string]interface{} `json:"payload"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := checkBotMessageReq(req.ChannelID, req.ChannelType, req.MessageID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := checkPayload(req.Payload); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	robotM := botFromContext(c)
+	err := rb.messageService.EditMessage(robotM.RobotID, req.ChannelID, req.ChannelType, req.MessageID, util.ToJson(req.Payload))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 撤回机器人发送的消息 群授权后也可以撤回群成员的消息
+func (rb *Robot) botDeleteMessage(c *wkhttp.Context) {
+	var req struct {
+		ChannelID   string `json:"channel_id"`
+		ChannelType uint8  `json:"channel_type"`
+		MessageID   int64  `json:"message_id"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := checkBotMessageReq(req.ChannelID, req.ChannelType, req.MessageID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	robotM := botFromContext(c)
+	if req.ChannelType == common.ChannelTypeGroup.Uint8() {
+		// 群授权了撤回成员消息的权限时可以撤回其他成员的消息
+		revoked, err := rb.revokeGroupMemberMessage(robotM, req.ChannelID, req.MessageID)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		if revoked {
+			c.ResponseOK()
+			return
+		}
+	}
+	err := rb.messageService.RevokeMessage(robotM.RobotID, robotM.Username, req.ChannelID, req.ChannelType, req.MessageID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+func checkBotMessageReq(channelID string, channelType uint8, messageID int64) error {
+	if strings.TrimSpace(channelID) == "" {
+		return errors.New("channel_id不能为空！")
+	}
+	if channelType == 0 {
+		return errors.New("channel_type不能为空！")
+	}
+	if messageID == 0 {
+		return errors.New("message_id不能为空！")
+	}
+	return nil
+}
+
+// 获取频道信息 群聊需要机器人在群内
+func (rb *Robot) botGetChat(c *wkhttp.Context) {
+	channelID := c.Query("channel_id")
+	channelType, _ := strconv.ParseUint(c.Query("channel_type"), 10, 8)
+	if strings.TrimSpace(channelID) == "" {
+		c.ResponseError(errors.New("channel_id不能为空！"))
+		return
+	}
+	robotM := botFromContext(c)
+	switch uint8(channelType) {
+	case common.ChannelTypePerson.Uint8():
+		userResp, err := rb.userService.GetUser(channelID)
+		if err != nil {
+			rb.Error("查询用户信息失败！", zap.Error(err))
+			c.ResponseError(errcode.ErrQueryUser)
+			return
+		}
+		if userResp == nil {
+			c.ResponseError(errcode.ErrUserNotExist)
+			return
+		}
+		c.Response(gin.H{
+			"channel_id":   userResp.UID,
+			"channel_type": channelType,
+			"name":         userResp.Name,
+		})
+	case common.ChannelTypeGroup.Uint8():
+		isMember, err := rb.groupService.ExistMember(channelID, robotM.RobotID)
+		if err != nil {
+			rb.Error("查询群成员失败！", zap.Error(err))
+			c.ResponseError(errcode.ErrQueryGroupMember)
+			return
+		}
+		if !isMember {
+			c.ResponseError(errors.New("机器人不在此群内！"))
+			return
+		}
+		groupResp, err := rb.groupService.GetGroupWithGroupNo(channelID)
+		if err != nil {
+			rb.Error("查询群信息失败！", zap.Error(err))
+			c.ResponseError(errcode.ErrQueryGroup)
+			return
+		}
+		if groupResp == nil {
+			c.ResponseError(errcode.ErrGroupNotExist)
+			return
+		}
+		memberCount, _, err := rb.groupService.GetMemberTotalAndOnlineCount(channelID)
+		if err != nil {
+			rb.Warn("查询群成员数量失败！", zap.Error(err))
+		}
+		c.Response(gin.H{
+			"channel_id":   groupResp.GroupNo,
+			"channel_type": channelType,
+			"name":         groupResp.Name,
+			"notice":       groupResp.Notice,
+			"forbidden":    groupResp.Forbidden,
+			"member_count": memberCount,
+		})
+	default:
+		c.ResponseError(errors.New("不支持的频道类型！"))
+	}
+}
+
+// 设置接收消息的webhook 设置后新消息推送到webhook 不再保存到events
+func (rb *Robot) botSetWebhook(c *wkhttp.Context) {
+	var req struct {
+		URL         string `json:"url"`
+		SecretToken string `json:"secret_token"` // 推送时放在请求头X-Bot-Api-Secret-Token中
+	}
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := checkWebhookURL(req.URL); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if len(req.SecretToken) > webhookSecretMaxLen {
+		c.ResponseError(fmt.Errorf("secret_token不能超过%d个字符！", webhookSecretMaxLen))
+		return
+	}
+	robotM := botFromContext(c)
+	if err := rb.db.updateWebhook(robotM.RobotID, req.URL, req.SecretToken); err != nil {
+		rb.Error("设置webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置webhook失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 删除webhook
+func (rb *Robot) botDeleteWebhook(c *wkhttp.Context) {
+	robotM := botFromContext(c)
+	if err := rb.db.updateWebhook(robotM.RobotID, "", ""); err != nil {
+		rb.Error("删除webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除webhook失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 获取webhook信息
+func (rb *Robot) botGetWebhookInfo(c *wkhttp.Context) {
+	robotM := botFromContext(c)
+	resp := gin.H{
+		"url":        robotM.WebhookURL,
+		"has_secret": robotM.WebhookSecret != "",
+	}
+	if lastErr := rb.getWebhookError(robotM.RobotID); lastErr != nil {
+		resp["last_error_at"] = lastErr.Time
+		resp["last_error_message"] = lastErr.Message
+	}
+	c.Response(resp)
+}
@@ -0,0 +1,266 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// botPhotoMimeTypes sendPhoto支持的图片类型
+var botPhotoMimeTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// botFileMaxSize 机器人上传文件的最大字节数
+func botFileMaxSize(robotM *robot) int64 {
+	maxSize := extconfig.Get().Bot.FileMaxSize
+	if robotM.FileMaxSize > 0 {
+		maxSize = robotM.FileMaxSize
+	}
+	return maxSize * 1024 * 1024
+}
+
+// botFileMimeTypes 机器人允许上传的文件类型 为空则不限制
+func botFileMimeTypes(robotM *robot) []string {
+	if robotM.FileMimeTypes == "" {
+		return extconfig.Get().Bot.FileMimeTypes
+	}
+	mimeTypes := make([]string, 0)
+	for _, mimeType := range strings.Split(robotM.FileMimeTypes, ",") {
+		if mimeType = strings.TrimSpace(mimeType); mimeType != "" {
+			mimeTypes = append(mimeTypes, mimeType)
+		}
+	}
+	return mimeTypes
+}
+
+// matchMimeType 文件类型是否在允许的列表中 支持image/*的写法 列表为空时不限制
+func matchMimeType(allowed []string, mimeType string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mimeType = strings.ToLower(mimeType)
+	for _, item := range allowed {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == mimeType || item == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(item, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(item, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectMimeType 通过文件内容判断文件类型 无法判断时使用扩展名对应的类型
+func detectMimeType(content io.ReadSeeker, filename string) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(content, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err = content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	mimeType, _, _ := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if mimeType == "application/octet-stream" || mimeType == "application/zip" {
+		// office文档等按内容只能识别为zip
+		if extType, _, err := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(filename)))); err == nil && extType != "" {
+			return extType, nil
+		}
+	}
+	return mimeType, nil
+}
+
+// botUploadReq 机器人上传文件并发送的请求
+type botUploadReq struct {
+	robotM      *robot
+	channelID   string
+	channelType uint8
+	content     multipart.File
+	header      *multipart.FileHeader
+	mimeType    string
+}
+
+// readBotUpload 读取并校验上传的文件 出错时已返回错误
+func (rb *Robot) readBotUpload(c *wkhttp.Context, field string, maxSize int64, mimeTypes []string) (*botUploadReq, bool) {
+	channelID := c.PostForm("channel_id")
+	channelType, _ := strconv.ParseUint(c.PostForm("channel_type"), 10, 8)
+	if strings.TrimSpace(channelID) == "" {
+		c.ResponseError(errors.New("channel_id不能为空！"))
+		return nil, false
+	}
+	if channelType == 0 {
+		c.ResponseError(errors.New("channel_type不能为空！"))
+		return nil, false
+	}
+	content, header, err := c.Request.FormFile(field)
+	if err != nil {
+		c.ResponseError(fmt.Errorf("%s不能为空！", field))
+		return nil, false
+	}
+	if header.Size > maxSize {
+		content.Close()
+		c.ResponseError(fmt.Errorf("文件不能超过%dMB！", maxSize/1024/1024))
+		return nil, false
+	}
+	mimeType, err := detectMimeType(content, header.Filename)
+	if err != nil {
+		content.Close()
+		rb.Error("读取文件失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrReadFile)
+		return nil, false
+	}
+	if !matchMimeType(mimeTypes, mimeType) {
+		content.Close()
+		c.ResponseError(fmt.Errorf("不支持的文件类型[%s]！", mimeType))
+		return nil, false
+	}
+	robotM := botFromContext(c)
+	if !rb.checkBotChatRate(c, robotM.RobotID, channelID, uint8(channelType)) {
+		content.Close()
+		return nil, false
+	}
+	return &botUploadReq{
+		robotM:      robotM,
+		channelID:   channelID,
+		channelType: uint8(channelType),
+		content:     content,
+		header:      header,
+		mimeType:    mimeType,
+	}, true
+}
+
+// saveBotUpload 通过文件模块保存到聊天文件目录 路径与客户端发送文件时相同
+func (rb *Robot) saveBotUpload(req *botUploadReq) (map[string]interface{}, error) {
+	return rb.fileService.SaveFile(&file.SaveFileReq{
+		UID:         req.robotM.RobotID,
+		FileType:    file.TypeChat,
+		Path:        fmt.Sprintf("/%d/%s/%s%s", req.channelType, req.channelID, util.GenerUUID(), strings.ToLower(filepath.Ext(req.header.Filename))),
+		Name:        req.header.Filename,
+		ContentType: req.mimeType,
+		Content:     req.content,
+		Size:        req.header.Size,
+	})
+}
+
+// 上传图片并发送图片消息
+func (rb *Robot) botSendPhoto(c *wkhttp.Context) {
+	robotM := botFromContext(c)
+	maxSize := extconfig.Get().Bot.PhotoMaxSize * 1024 * 1024
+	if fileMaxSize := botFileMaxSize(robotM); fileMaxSize < maxSize {
+		maxSize = fileMaxSize
+	}
+	req, ok := rb.readBotUpload(c, "photo", maxSize, botPhotoMimeTypes)
+	if !ok {
+		return
+	}
+	defer req.content.Close()
+	if !matchMimeType(botFileMimeTypes(robotM), req.mimeType) {
+		c.ResponseError(fmt.Errorf("不支持的文件类型[%s]！", req.mimeType))
+		return
+	}
+	imgConfig, _, err := image.DecodeConfig(req.content)
+	if err != nil {
+		c.ResponseError(errors.New("图片格式有误！"))
+		return
+	}
+	if _, err = req.content.Seek(0, io.SeekStart); err != nil {
+		rb.Error("读取文件失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrReadFile)
+		return
+	}
+	fileResp, err := rb.saveBotUpload(req)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	result, err := rb.sendRobotMessage(robotM.RobotID, &MessageReq{
+		ChannelID:   req.channelID,
+		ChannelType: req.channelType,
+		Payload: map[string]interface{}{
+			"type":   common.Image,
+			"url":    fileResp["path"],
+			"width":  imgConfig.Width,
+			"height": imgConfig.Height,
+		},
+	})
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(gin.H{
+		"message": result,
+		"file":    fileResp,
+	})
+}
+
+// 上传文件并发送文件消息
+func (rb *Robot) botSendDocument(c *wkhttp.Context) {
+	robotM := botFromContext(c)
+	req, ok := rb.readBotUpload(c, "document", botFileMaxSize(robotM), botFileMimeTypes(robotM))
+	if !ok {
+		return
+	}
+	defer req.content.Close()
+	fileResp, err := rb.saveBotUpload(req)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	result, err := rb.sendRobotMessage(robotM.RobotID, &MessageReq{
+		ChannelID:   req.channelID,
+		ChannelType: req.channelType,
+		Payload: map[string]interface{}{
+			"type": common.File,
+			"url":  fileResp["path"],
+			"name": req.header.Filename,
+			"size": req.header.Size,
+		},
+	})
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(gin.H{
+		"message": result,
+		"file":    fileResp,
+	})
+}
+
+// 获取文件信息和下载地址 path为消息中的url
+func (rb *Robot) botGetFile(c *wkhttp.Context) {
+	path := c.Query("path")
+	if strings.TrimSpace(path) == "" {
+		c.ResponseError(errors.New("path不能为空！"))
+		return
+	}
+	resp, err := rb.fileService.GetFileInfo(path, botFromContext(c).RobotID)
+	if err != nil {
+		rb.Error("查询文件信息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询文件信息失败！"))
+		return
+	}
+	if resp == nil {
+		c.ResponseError(errcode.ErrFileNotExist)
+		return
+	}
+	c.Response(resp)
+}
@@ -0,0 +1,53 @@
+package robot
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchMimeType(t *testing.T) {
+	assert.True(t, matchMimeType(nil, "application/pdf"))
+	assert.True(t, matchMimeType([]string{"application/pdf"}, "application/pdf"))
+	assert.True(t, matchMimeType([]string{"image/*"}, "image/png"))
+	assert.True(t, matchMimeType([]string{"IMAGE/PNG"}, "image/png"))
+	assert.False(t, matchMimeType([]string{"image/*"}, "application/pdf"))
+	assert.False(t, matchMimeType([]string{"image/png"}, "image/gif"))
+}
+
+func TestDetectMimeType(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+	content := bytes.NewReader(buf.Bytes())
+	mimeType, err := detectMimeType(content, "a.jpg")
+	assert.NoError(t, err)
+	// 按内容识别 不使用扩展名
+	assert.Equal(t, "image/png", mimeType)
+	// 读取后回到文件开头
+	pos, _ := content.Seek(0, 1)
+	assert.Equal(t, int64(0), pos)
+
+	// 无法按内容识别时使用扩展名
+	mimeType, err = detectMimeType(bytes.NewReader([]byte{0, 1, 2, 3}), "a.PDF")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", mimeType)
+
+	mimeType, err = detectMimeType(bytes.NewReader([]byte("hello")), "a.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", mimeType)
+}
+
+func TestBotFileLimit(t *testing.T) {
+	robotM := &robot{}
+	assert.Equal(t, extconfig.Get().Bot.FileMaxSize*1024*1024, botFileMaxSize(robotM))
+	assert.Equal(t, extconfig.Get().Bot.FileMimeTypes, botFileMimeTypes(robotM))
+
+	robotM.FileMaxSize = 1
+	robotM.FileMimeTypes = "application/pdf, image/*,"
+	assert.Equal(t, int64(1024*1024), botFileMaxSize(robotM))
+	assert.Equal(t, []string{"application/pdf", "image/*"}, botFileMimeTypes(robotM))
+}
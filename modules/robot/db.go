@@ -29,6 +29,33 @@ func (d *robotDB) queryVaildRobotWithRobtID(robotID string) (*robot, error) {
 	return m, err
 }
 
+func (d *robotDB) queryVaildRobotWithToken(token string) (*robot, error) {
+	var m *robot
+	_, err := d.session.Select("*").From("robot").Where("token=? and status=1", token).Load(&m)
+	return m, err
+}
+
+func (d *robotDB) updateToken(robotID string, token string) error {
+	_, err := d.session.Update("robot").Set("token", token).Where("robot_id=?", robotID).Exec()
+	return err
+}
+
+func (d *robotDB) updateWebhook(robotID string, webhookURL string, webhookSecret string) error {
+	_, err := d.session.Update("robot").SetMap(map[string]interface{}{
+		"webhook_url":    webhookURL,
+		"webhook_secret": webhookSecret,
+	}).Where("robot_id=?", robotID).Exec()
+	return err
+}
+
+func (d *robotDB) updateFileLimit(robotID string, fileMaxSize int64, fileMimeTypes string) error {
+	_, err := d.session.Update("robot").SetMap(map[string]interface{}{
+		"file_max_size":   fileMaxSize,
+		"file_mime_types": fileMimeTypes,
+	}).Where("robot_id=?", robotID).Exec()
+	return err
+}
+
 func (d *robotDB) exist(robotID string) (bool, error) {
 	var cn int
 	err := d.session.Select("count(*)").From("robot").Where("robot_id=? and status=1", robotID).LoadOne(&cn)
@@ -110,13 +137,17 @@ type menu struct {
 	db.BaseModel
 }
 type robot struct {
-	AppID       string
-	RobotID     string // 机器人唯一ID
-	Username    string // 机器人用户名
-	InlineOn    int    // 是否开启行内搜索
-	Placeholder string // 输入框占位符，开启行内搜索有效
-	Token       string
-	Version     int64
-	Status      int
+	AppID         string
+	RobotID       string // 机器人唯一ID
+	Username      string // 机器人用户名
+	InlineOn      int    // 是否开启行内搜索
+	Placeholder   string // 输入框占位符，开启行内搜索有效
+	Token         string
+	WebhookURL    string // 接收消息的webhook地址 为空时通过events拉取
+	WebhookSecret string // 推送webhook时放在请求头中的密钥
+	FileMaxSize   int64  // 上传文件的最大大小（MB） 0为使用默认配置
+	FileMimeTypes string // 允许上传的文件类型 多个用逗号分隔 为空时使用默认配置
+	Version       int64
+	Status        int
 	db.BaseModel
 }
@@ -0,0 +1,96 @@
+package robot
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+func (d *robotDB) insertDirectory(m *directoryModel) error {
+	_, err := d.session.InsertInto("robot_directory").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *robotDB) updateDirectory(m *directoryModel) error {
+	_, err := d.session.Update("robot_directory").SetMap(map[string]interface{}{
+		"name":        m.Name,
+		"description": m.Description,
+		"category":    m.Category,
+		"screenshots": m.Screenshots,
+		"sort_num":    m.SortNum,
+		"status":      m.Status,
+		"updated_at":  time.Now(),
+	}).Where("robot_id=?", m.RobotID).Exec()
+	return err
+}
+
+func (d *robotDB) deleteDirectory(robotID string) error {
+	_, err := d.session.DeleteFrom("robot_directory").Where("robot_id=?", robotID).Exec()
+	return err
+}
+
+func (d *robotDB) queryDirectoryWithRobotID(robotID string) (*directoryModel, error) {
+	var m *directoryModel
+	_, err := d.session.Select("*").From("robot_directory").Where("robot_id=?", robotID).Load(&m)
+	return m, err
+}
+
+// directoryQuery 目录的查询条件 onlyListed为true时只查询展示中且机器人有效的
+type directoryQuery struct {
+	category   string
+	keyword    string
+	onlyListed bool
+}
+
+func (q *directoryQuery) build(builder *dbr.SelectStmt) *dbr.SelectStmt {
+	builder = builder.From("robot_directory").Join("robot", "robot.robot_id=robot_directory.robot_id")
+	if q.onlyListed {
+		builder = builder.Where("robot_directory.status=1 and robot.status=1")
+	}
+	if q.category != "" {
+		builder = builder.Where("robot_directory.category=?", q.category)
+	}
+	if q.keyword != "" {
+		keyword := "%" + q.keyword + "%"
+		builder = builder.Where("robot_directory.name like ? or robot_directory.description like ? or robot.username like ?", keyword, keyword, keyword)
+	}
+	return builder
+}
+
+func (d *robotDB) queryDirectories(q *directoryQuery, pageIndex, pageSize uint64) ([]*directoryDetailModel, error) {
+	var models []*directoryDetailModel
+	_, err := q.build(d.session.Select("robot_directory.*,robot.username")).OrderDesc("robot_directory.sort_num").OrderAsc("robot_directory.id").Offset((pageIndex-1)*pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *robotDB) queryDirectoryCount(q *directoryQuery) (int64, error) {
+	var count int64
+	err := q.build(d.session.Select("count(*)")).LoadOne(&count)
+	return count, err
+}
+
+// queryListedDirectory 查询展示中的机器人
+func (d *robotDB) queryListedDirectory(robotID string) (*directoryDetailModel, error) {
+	var m *directoryDetailModel
+	_, err := (&directoryQuery{onlyListed: true}).build(d.session.Select("robot_directory.*,robot.username")).Where("robot_directory.robot_id=?", robotID).Load(&m)
+	return m, err
+}
+
+// directoryModel 机器人目录
+type directoryModel struct {
+	RobotID     string
+	Name        string
+	Description string
+	Category    string
+	Screenshots string // 截图地址 json数组
+	SortNum     int
+	Status      int
+	db.BaseModel
+}
+
+type directoryDetailModel struct {
+	directoryModel
+	Username string
+}
@@ -0,0 +1,39 @@
+package robot
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+)
+
+// upsertGroupPermission 设置群授权给机器人的权限
+func (d *robotDB) upsertGroupPermission(m *groupPermissionModel) error {
+	_, err := d.session.InsertBySql("insert into robot_group_permission(group_no,robot_id,delete_message,mute_member,kick_member,operator) values(?,?,?,?,?,?) ON DUPLICATE KEY UPDATE delete_message=VALUES(delete_message),mute_member=VALUES(mute_member),kick_member=VALUES(kick_member),operator=VALUES(operator),updated_at=NOW()", m.GroupNo, m.RobotID, m.DeleteMessage, m.MuteMember, m.KickMember, m.Operator).Exec()
+	return err
+}
+
+func (d *robotDB) deleteGroupPermission(groupNo string, robotID string) error {
+	_, err := d.session.DeleteFrom("robot_group_permission").Where("group_no=? and robot_id=?", groupNo, robotID).Exec()
+	return err
+}
+
+func (d *robotDB) queryGroupPermission(groupNo string, robotID string) (*groupPermissionModel, error) {
+	var m *groupPermissionModel
+	_, err := d.session.Select("*").From("robot_group_permission").Where("group_no=? and robot_id=?", groupNo, robotID).Load(&m)
+	return m, err
+}
+
+func (d *robotDB) queryGroupPermissionsWithGroupNo(groupNo string) ([]*groupPermissionModel, error) {
+	var models []*groupPermissionModel
+	_, err := d.session.Select("*").From("robot_group_permission").Where("group_no=?", groupNo).OrderAsc("id").Load(&models)
+	return models, err
+}
+
+// groupPermissionModel 群授权给机器人的管理权限
+type groupPermissionModel struct {
+	GroupNo       string
+	RobotID       string
+	DeleteMessage int // 撤回成员的消息
+	MuteMember    int // 禁言成员
+	KickMember    int // 移除成员
+	Operator      string
+	db.BaseModel
+}
@@ -0,0 +1,62 @@
+package robot
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+)
+
+func (d *robotDB) insertIncomingWebhook(m *incomingWebhookModel) error {
+	_, err := d.session.InsertInto("robot_incoming_webhook").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *robotDB) updateIncomingWebhook(m *incomingWebhookModel) error {
+	_, err := d.session.Update("robot_incoming_webhook").SetMap(map[string]interface{}{
+		"name":       m.Name,
+		"token":      m.Token,
+		"status":     m.Status,
+		"updated_at": time.Now(),
+	}).Where("id=?", m.Id).Exec()
+	return err
+}
+
+func (d *robotDB) deleteIncomingWebhook(groupNo string, id int64) error {
+	_, err := d.session.DeleteFrom("robot_incoming_webhook").Where("group_no=? and id=?", groupNo, id).Exec()
+	return err
+}
+
+func (d *robotDB) queryIncomingWebhookWithID(groupNo string, id int64) (*incomingWebhookModel, error) {
+	var m *incomingWebhookModel
+	_, err := d.session.Select("*").From("robot_incoming_webhook").Where("group_no=? and id=?", groupNo, id).Load(&m)
+	return m, err
+}
+
+func (d *robotDB) queryIncomingWebhookWithToken(token string) (*incomingWebhookModel, error) {
+	var m *incomingWebhookModel
+	_, err := d.session.Select("*").From("robot_incoming_webhook").Where("token=?", token).Load(&m)
+	return m, err
+}
+
+func (d *robotDB) queryIncomingWebhooksWithGroupNo(groupNo string) ([]*incomingWebhookModel, error) {
+	var models []*incomingWebhookModel
+	_, err := d.session.Select("*").From("robot_incoming_webhook").Where("group_no=?", groupNo).OrderAsc("id").Load(&models)
+	return models, err
+}
+
+func (d *robotDB) queryIncomingWebhookCount(groupNo string) (int, error) {
+	var count int
+	err := d.session.Select("count(*)").From("robot_incoming_webhook").Where("group_no=?", groupNo).LoadOne(&count)
+	return count, err
+}
+
+// incomingWebhookModel 群的incoming webhook
+type incomingWebhookModel struct {
+	GroupNo string
+	Name    string // 消息中显示的发送者
+	Token   string
+	Status  int // 0.禁用 1.启用
+	Creator string
+	db.BaseModel
+}
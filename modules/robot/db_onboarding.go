@@ -0,0 +1,68 @@
+package robot
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+)
+
+func (d *robotDB) insertOnboardingStep(m *onboardingStepModel) error {
+	_, err := d.session.InsertInto("robot_onboarding_step").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *robotDB) updateOnboardingStep(m *onboardingStepModel) error {
+	_, err := d.session.Update("robot_onboarding_step").SetMap(map[string]interface{}{
+		"trigger_data": m.TriggerData,
+		"sort_num":     m.SortNum,
+		"delay":        m.Delay,
+		"payload":      m.Payload,
+		"remark":       m.Remark,
+		"status":       m.Status,
+		"updated_at":   time.Now(),
+	}).Where("id=?", m.Id).Exec()
+	return err
+}
+
+func (d *robotDB) deleteOnboardingStep(id int64) error {
+	_, err := d.session.DeleteFrom("robot_onboarding_step").Where("id=?", id).Exec()
+	return err
+}
+
+func (d *robotDB) queryOnboardingStepWithID(id int64) (*onboardingStepModel, error) {
+	var m *onboardingStepModel
+	_, err := d.session.Select("*").From("robot_onboarding_step").Where("id=?", id).Load(&m)
+	return m, err
+}
+
+// queryOnboardingSteps 查询全部步骤 按触发条件和顺序排序
+func (d *robotDB) queryOnboardingSteps() ([]*onboardingStepModel, error) {
+	var models []*onboardingStepModel
+	_, err := d.session.Select("*").From("robot_onboarding_step").OrderAsc("trigger_data").OrderAsc("sort_num").OrderAsc("id").Load(&models)
+	return models, err
+}
+
+// queryEnabledOnboardingSteps 查询某个触发条件下启用的步骤
+func (d *robotDB) queryEnabledOnboardingSteps(triggerData string) ([]*onboardingStepModel, error) {
+	var models []*onboardingStepModel
+	_, err := d.session.Select("*").From("robot_onboarding_step").Where("trigger_data=? and status=1", triggerData).OrderAsc("sort_num").OrderAsc("id").Load(&models)
+	return models, err
+}
+
+func (d *robotDB) queryOnboardingStepCount(triggerData string) (int64, error) {
+	var count int64
+	err := d.session.Select("count(*)").From("robot_onboarding_step").Where("trigger_data=?", triggerData).LoadOne(&count)
+	return count, err
+}
+
+// onboardingStepModel 新用户引导的步骤
+type onboardingStepModel struct {
+	TriggerData string // 触发的按钮callback_data 为空时注册后发送
+	SortNum     int
+	Delay       int // 发送前等待的秒数
+	Payload     string
+	Remark      string
+	Status      int
+	db.BaseModel
+}
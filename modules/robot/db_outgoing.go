@@ -0,0 +1,64 @@
+package robot
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+)
+
+func (d *robotDB) insertOutgoingWebhook(m *outgoingWebhookModel) error {
+	_, err := d.session.InsertInto("robot_outgoing_webhook").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *robotDB) updateOutgoingWebhook(m *outgoingWebhookModel) error {
+	_, err := d.session.Update("robot_outgoing_webhook").SetMap(map[string]interface{}{
+		"name":       m.Name,
+		"url":        m.URL,
+		"secret":     m.Secret,
+		"keywords":   m.Keywords,
+		"regex":      m.Regex,
+		"reply_on":   m.ReplyOn,
+		"status":     m.Status,
+		"updated_at": time.Now(),
+	}).Where("id=?", m.Id).Exec()
+	return err
+}
+
+func (d *robotDB) deleteOutgoingWebhook(groupNo string, id int64) error {
+	_, err := d.session.DeleteFrom("robot_outgoing_webhook").Where("group_no=? and id=?", groupNo, id).Exec()
+	return err
+}
+
+func (d *robotDB) queryOutgoingWebhookWithID(groupNo string, id int64) (*outgoingWebhookModel, error) {
+	var m *outgoingWebhookModel
+	_, err := d.session.Select("*").From("robot_outgoing_webhook").Where("group_no=? and id=?", groupNo, id).Load(&m)
+	return m, err
+}
+
+func (d *robotDB) queryOutgoingWebhooksWithGroupNo(groupNo string) ([]*outgoingWebhookModel, error) {
+	var models []*outgoingWebhookModel
+	_, err := d.session.Select("*").From("robot_outgoing_webhook").Where("group_no=?", groupNo).OrderAsc("id").Load(&models)
+	return models, err
+}
+
+func (d *robotDB) queryOutgoingWebhookCount(groupNo string) (int, error) {
+	var count int
+	err := d.session.Select("count(*)").From("robot_outgoing_webhook").Where("group_no=?", groupNo).LoadOne(&count)
+	return count, err
+}
+
+// outgoingWebhookModel 群的outgoing webhook
+type outgoingWebhookModel struct {
+	GroupNo  string
+	Name     string
+	URL      string
+	Secret   string // 签名密钥
+	Keywords string // 触发的关键词 json数组
+	Regex    string // 触发的正则表达式
+	ReplyOn  int    // 是否把返回的内容发送到群内
+	Status   int    // 0.禁用 1.启用
+	Creator  string
+	db.BaseModel
+}
@@ -0,0 +1,75 @@
+package robot
+
+// incrRobotStat 累加机器人当天的统计 field为robot_stat_daily的计数列
+func (d *robotDB) incrRobotStat(robotID string, date string, field string, count int64) error {
+	_, err := d.session.InsertBySql("insert into robot_stat_daily(robot_id,stat_date,"+field+") values(?,?,?) ON DUPLICATE KEY UPDATE "+field+"="+field+"+VALUES("+field+"),updated_at=NOW()", robotID, date, count).Exec()
+	return err
+}
+
+func (d *robotDB) incrRobotChatStat(robotID string, date string, channelID string, channelType uint8) error {
+	_, err := d.session.InsertBySql("insert into robot_stat_chat_daily(robot_id,stat_date,channel_id,channel_type,message_count) values(?,?,?,?,1) ON DUPLICATE KEY UPDATE message_count=message_count+1,updated_at=NOW()", robotID, date, channelID, channelType).Exec()
+	return err
+}
+
+func (d *robotDB) incrRobotCommandStat(robotID string, date string, command string) error {
+	_, err := d.session.InsertBySql("insert into robot_stat_command_daily(robot_id,stat_date,command,count) values(?,?,?,1) ON DUPLICATE KEY UPDATE count=count+1,updated_at=NOW()", robotID, date, command).Exec()
+	return err
+}
+
+// queryRobotStats 查询日期范围内（包含起止日期）的每日统计
+func (d *robotDB) queryRobotStats(robotID string, startDate, endDate string) ([]*robotStatModel, error) {
+	var models []*robotStatModel
+	_, err := d.session.Select("*").From("robot_stat_daily").Where("robot_id=? and stat_date>=? and stat_date<=?", robotID, startDate, endDate).OrderAsc("stat_date").Load(&models)
+	return models, err
+}
+
+// queryRobotActiveChats 每日活跃的频道数
+func (d *robotDB) queryRobotActiveChats(robotID string, startDate, endDate string) ([]*robotDateCountModel, error) {
+	var models []*robotDateCountModel
+	_, err := d.session.Select("stat_date,count(*) count").From("robot_stat_chat_daily").Where("robot_id=? and stat_date>=? and stat_date<=?", robotID, startDate, endDate).GroupBy("stat_date").Load(&models)
+	return models, err
+}
+
+// queryRobotActiveChatTotal 日期范围内活跃的频道数（去重）
+func (d *robotDB) queryRobotActiveChatTotal(robotID string, startDate, endDate string) (int64, error) {
+	var count int64
+	err := d.session.Select("count(distinct channel_id,channel_type)").From("robot_stat_chat_daily").Where("robot_id=? and stat_date>=? and stat_date<=?", robotID, startDate, endDate).LoadOne(&count)
+	return count, err
+}
+
+// queryRobotCommandStats 日期范围内命令的使用次数 按次数倒序
+func (d *robotDB) queryRobotCommandStats(robotID string, startDate, endDate string, limit uint64) ([]*robotCommandStatModel, error) {
+	var models []*robotCommandStatModel
+	_, err := d.session.Select("command,sum(count) count").From("robot_stat_command_daily").Where("robot_id=? and stat_date>=? and stat_date<=?", robotID, startDate, endDate).GroupBy("command").OrderDesc("count").Limit(limit).Load(&models)
+	return models, err
+}
+
+// deleteRobotStatsBefore 删除过期的统计
+func (d *robotDB) deleteRobotStatsBefore(date string) error {
+	for _, table := range []string{"robot_stat_daily", "robot_stat_chat_daily", "robot_stat_command_daily"} {
+		if _, err := d.session.DeleteFrom(table).Where("stat_date<?", date).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type robotStatModel struct {
+	RobotID         string
+	StatDate        string
+	MessageSent     int64
+	MessageReceived int64
+	CommandCount    int64
+	WebhookSuccess  int64
+	WebhookFailed   int64
+}
+
+type robotDateCountModel struct {
+	StatDate string
+	Count    int64
+}
+
+type robotCommandStatModel struct {
+	Command string
+	Count   int64
+}
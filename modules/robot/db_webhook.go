@@ -0,0 +1,112 @@
+package robot
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+)
+
+const (
+	webhookDeliveryPending = 0 // 等待重试
+	webhookDeliverySuccess = 1 // 成功
+	webhookDeliveryDead    = 2 // 失败（死信）
+)
+
+func (d *robotDB) insertWebhookDelivery(m *webhookDeliveryModel) error {
+	_, err := d.session.InsertInto("robot_webhook_delivery").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *robotDB) updateWebhookDelivery(m *webhookDeliveryModel) error {
+	_, err := d.session.Update("robot_webhook_delivery").SetMap(map[string]interface{}{
+		"url":           m.URL,
+		"status":        m.Status,
+		"attempts":      m.Attempts,
+		"status_code":   m.StatusCode,
+		"error":         m.Error,
+		"duration":      m.Duration,
+		"next_retry_at": m.NextRetryAt,
+		"updated_at":    time.Now(),
+	}).Where("id=?", m.Id).Exec()
+	return err
+}
+
+// queryDueWebhookDeliveries 查询到了重试时间的推送记录
+func (d *robotDB) queryDueWebhookDeliveries(now int64, limit uint64) ([]*webhookDeliveryModel, error) {
+	var models []*webhookDeliveryModel
+	_, err := d.session.Select("*").From("robot_webhook_delivery").Where("status=? and next_retry_at<=?", webhookDeliveryPending, now).OrderAsc("next_retry_at").Limit(limit).Load(&models)
+	return models, err
+}
+
+// lockWebhookDelivery 推迟下次重试的时间 多个实例同时重试同一条记录时只有一个能成功
+func (d *robotDB) lockWebhookDelivery(id int64, nextRetryAt int64, lockUntil int64) (bool, error) {
+	result, err := d.session.UpdateBySql("update robot_webhook_delivery set next_retry_at=? where id=? and status=? and next_retry_at=?", lockUntil, id, webhookDeliveryPending, nextRetryAt).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// redeliverWebhookDelivery 重新推送 重置推送次数并立即重试
+func (d *robotDB) redeliverWebhookDelivery(id int64) error {
+	_, err := d.session.Update("robot_webhook_delivery").SetMap(map[string]interface{}{
+		"status":        webhookDeliveryPending,
+		"attempts":      0,
+		"next_retry_at": time.Now().Unix(),
+		"updated_at":    time.Now(),
+	}).Where("id=?", id).Exec()
+	return err
+}
+
+func (d *robotDB) queryWebhookDeliveryWithID(id int64) (*webhookDeliveryModel, error) {
+	var m *webhookDeliveryModel
+	_, err := d.session.Select("*").From("robot_webhook_delivery").Where("id=?", id).Load(&m)
+	return m, err
+}
+
+// queryWebhookDeliveries 查询机器人的推送记录 status小于0时查询全部
+func (d *robotDB) queryWebhookDeliveries(robotID string, status int, pageIndex, pageSize uint64) ([]*webhookDeliveryModel, error) {
+	var models []*webhookDeliveryModel
+	builder := d.session.Select("*").From("robot_webhook_delivery").Where("robot_id=?", robotID)
+	if status >= 0 {
+		builder = builder.Where("status=?", status)
+	}
+	_, err := builder.OrderDesc("id").Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *robotDB) queryWebhookDeliveryCount(robotID string, status int) (int64, error) {
+	var count int64
+	builder := d.session.Select("count(*)").From("robot_webhook_delivery").Where("robot_id=?", robotID)
+	if status >= 0 {
+		builder = builder.Where("status=?", status)
+	}
+	err := builder.LoadOne(&count)
+	return count, err
+}
+
+// deleteWebhookDeliveriesBefore 删除过期的推送记录 等待重试的记录不删除
+func (d *robotDB) deleteWebhookDeliveriesBefore(before time.Time) error {
+	_, err := d.session.DeleteFrom("robot_webhook_delivery").Where("status<>? and created_at<?", webhookDeliveryPending, before).Exec()
+	return err
+}
+
+// webhookDeliveryModel webhook的推送记录
+type webhookDeliveryModel struct {
+	RobotID     string
+	EventID     int64
+	URL         string
+	Body        string
+	Status      int
+	Attempts    int    // 已推送次数
+	StatusCode  int    // 最近一次推送返回的状态码
+	Error       string // 最近一次推送失败的原因
+	Duration    int64  // 最近一次推送的耗时（毫秒）
+	NextRetryAt int64  // 下次重试的时间（秒级时间戳）
+	db.BaseModel
+}
@@ -0,0 +1,163 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// directoryNameMaxLen 名称的最大字符数
+	directoryNameMaxLen = 40
+	// directoryDescriptionMaxLen 介绍的最大字符数
+	directoryDescriptionMaxLen = 500
+	// directoryCategoryMaxLen 分类的最大字符数
+	directoryCategoryMaxLen = 40
+	// directoryScreenshotMaxCount 最多的截图数
+	directoryScreenshotMaxCount = 9
+)
+
+type directoryReq struct {
+	RobotID     string   `json:"robot_id"` // 修改时不需要
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`
+	Screenshots []string `json:"screenshots"` // 截图地址 通过文件上传接口上传
+	SortNum     int      `json:"sort_num"`    // 越大越靠前
+	Status      *int     `json:"status"`      // 0.隐藏 1.展示 默认展示
+}
+
+func (r *directoryReq) check() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("名称不能为空！")
+	}
+	if utf8.RuneCountInString(r.Name) > directoryNameMaxLen {
+		return fmt.Errorf("名称不能超过%d个字符！", directoryNameMaxLen)
+	}
+	if utf8.RuneCountInString(r.Description) > directoryDescriptionMaxLen {
+		return fmt.Errorf("介绍不能超过%d个字符！", directoryDescriptionMaxLen)
+	}
+	if utf8.RuneCountInString(r.Category) > directoryCategoryMaxLen {
+		return fmt.Errorf("分类不能超过%d个字符！", directoryCategoryMaxLen)
+	}
+	if len(r.Screenshots) > directoryScreenshotMaxCount {
+		return fmt.Errorf("截图不能超过%d张！", directoryScreenshotMaxCount)
+	}
+	for _, screenshot := range r.Screenshots {
+		if strings.TrimSpace(screenshot) == "" {
+			return errors.New("截图地址不能为空！")
+		}
+	}
+	if r.Status != nil && *r.Status != 0 && *r.Status != 1 {
+		return errors.New("status只能是0或1！")
+	}
+	return nil
+}
+
+func (r *directoryReq) fill(m *directoryModel) {
+	m.Name = r.Name
+	m.Description = r.Description
+	m.Category = strings.TrimSpace(r.Category)
+	screenshots := r.Screenshots
+	if screenshots == nil {
+		screenshots = make([]string, 0)
+	}
+	m.Screenshots = util.ToJson(screenshots)
+	m.SortNum = r.SortNum
+	if r.Status != nil {
+		m.Status = *r.Status
+	}
+}
+
+type directoryResp struct {
+	RobotID       string   `json:"robot_id"`
+	Username      string   `json:"username"`
+	Name          string   `json:"name"`
+	Avatar        string   `json:"avatar"`
+	Description   string   `json:"description"`
+	Category      string   `json:"category"`
+	Screenshots   []string `json:"screenshots"`
+	AddToGroupURL string   `json:"add_to_group_url"` // 添加到群聊的链接 打开后选择群添加机器人为群成员
+	SortNum       int      `json:"sort_num"`
+	Status        int      `json:"status"`
+	CreatedAt     string   `json:"created_at"`
+	UpdatedAt     string   `json:"updated_at"`
+}
+
+func newDirectoryResp(cfg *config.Config, m *directoryDetailModel) *directoryResp {
+	screenshots := make([]string, 0)
+	if m.Screenshots != "" {
+		_ = util.ReadJsonByByte([]byte(m.Screenshots), &screenshots)
+	}
+	return &directoryResp{
+		RobotID:       m.RobotID,
+		Username:      m.Username,
+		Name:          m.Name,
+		Avatar:        fmt.Sprintf("users/%s/avatar", m.RobotID),
+		Description:   m.Description,
+		Category:      m.Category,
+		Screenshots:   screenshots,
+		AddToGroupURL: fmt.Sprintf("%s/add_robot.html?robot_id=%s", cfg.External.H5BaseURL, m.RobotID),
+		SortNum:       m.SortNum,
+		Status:        m.Status,
+		CreatedAt:     m.CreatedAt.String(),
+		UpdatedAt:     m.UpdatedAt.String(),
+	}
+}
+
+// queryDirectoryResps 分页查询目录
+func queryDirectoryResps(d *robotDB, cfg *config.Config, q *directoryQuery, pageIndex, pageSize uint64) ([]*directoryResp, int64, error) {
+	list, err := d.queryDirectories(q, pageIndex, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := d.queryDirectoryCount(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	resps := make([]*directoryResp, 0, len(list))
+	for _, m := range list {
+		resps = append(resps, newDirectoryResp(cfg, m))
+	}
+	return resps, count, nil
+}
+
+// 机器人目录 只返回展示中的机器人
+func (rb *Robot) directories(c *wkhttp.Context) {
+	pageIndex, pageSize := c.GetPage()
+	resps, count, err := queryDirectoryResps(&rb.db, rb.ctx.GetConfig(), &directoryQuery{
+		category:   strings.TrimSpace(c.Query("category")),
+		keyword:    strings.TrimSpace(c.Query("keyword")),
+		onlyListed: true,
+	}, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		rb.Error("查询机器人目录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询机器人目录失败！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  resps,
+	})
+}
+
+// 机器人目录中的机器人详情
+func (rb *Robot) directoryGet(c *wkhttp.Context) {
+	m, err := rb.db.queryListedDirectory(c.Param("robot_id"))
+	if err != nil {
+		rb.Error("查询机器人目录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询机器人目录失败！"))
+		return
+	}
+	if m == nil {
+		c.ResponseError(errors.New("机器人不在目录中！"))
+		return
+	}
+	c.Response(newDirectoryResp(rb.ctx.GetConfig(), m))
+}
@@ -0,0 +1,40 @@
+package robot
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectoryReqCheck(t *testing.T) {
+	req := &directoryReq{Name: "天气", Screenshots: []string{"file/preview/a.png"}}
+	assert.NoError(t, req.check())
+
+	req.Screenshots = append(req.Screenshots, " ")
+	assert.Error(t, req.check())
+
+	req.Screenshots = make([]string, directoryScreenshotMaxCount+1)
+	for i := range req.Screenshots {
+		req.Screenshots[i] = "file/preview/a.png"
+	}
+	assert.Error(t, req.check())
+
+	assert.Error(t, (&directoryReq{}).check())
+}
+
+func TestNewDirectoryResp(t *testing.T) {
+	req := &directoryReq{Name: "天气", Category: " 工具 "}
+	m := &directoryDetailModel{Username: "weather_bot"}
+	m.RobotID = "u1"
+	req.fill(&m.directoryModel)
+	assert.Equal(t, "工具", m.Category)
+	assert.Equal(t, "[]", m.Screenshots)
+
+	cfg := &config.Config{}
+	cfg.External.H5BaseURL = "https://h5.example.com"
+	resp := newDirectoryResp(cfg, m)
+	assert.Equal(t, "users/u1/avatar", resp.Avatar)
+	assert.Equal(t, "https://h5.example.com/add_robot.html?robot_id=u1", resp.AddToGroupURL)
+	assert.Equal(t, 0, len(resp.Screenshots))
+}
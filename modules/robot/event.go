@@ -108,17 +108,27 @@ func (rb *Robot) robotMessageListen(messages []*config.MessageResp) {
 }
 
 func (rb *Robot) saveRobotMessage(message *config.MessageResp, robotID string) {
-
-	seq := rb.ctx.GenSeq(fmt.Sprintf("%s%s", common.RobotEventSeqKey, robotID))
-	messageUpdateJson := util.ToJson(&robotEvent{
-		EventID: seq,
+	rb.statMessageReceived(robotID, message)
+	rb.saveRobotEvent(robotID, &robotEvent{
 		Message: message,
-		Expire:  time.Now().Add(rb.ctx.GetConfig().Robot.MessageExpire).Unix(),
 	})
+}
+
+// saveRobotEvent 投递事件给机器人 设置了webhook的机器人直接推送 推送失败时由retryWebhookDeliveries重试
+func (rb *Robot) saveRobotEvent(robotID string, event *robotEvent) {
+	event.EventID = rb.ctx.GenSeq(fmt.Sprintf("%s%s", common.RobotEventSeqKey, robotID))
+	event.Expire = time.Now().Add(rb.ctx.GetConfig().Robot.MessageExpire).Unix()
+	if rb.pushToWebhook(robotID, event) {
+		return
+	}
+	messageUpdateJson := util.ToJson(event)
 	key := fmt.Sprintf("%s%s", rb.robotEventPrefix, robotID)
-	err := rb.ctx.GetRedisConn().ZAdd(key, float64(seq), messageUpdateJson)
+	err := rb.ctx.GetRedisConn().ZAdd(key, float64(event.EventID), messageUpdateJson)
 	if err != nil {
 		rb.Error("投递消息给机器人失败！", zap.Error(err), zap.String("robotID", robotID), zap.String("message", messageUpdateJson))
+	} else {
+		rb.trimEvents(robotID, event.EventID)
+		rb.notifyUpdates(robotID)
 	}
 	err = rb.ctx.GetRedisConn().Expire(key, rb.ctx.GetConfig().Robot.MessageExpire)
 	if err != nil {
@@ -0,0 +1,306 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// incomingWebhookMaxCount 每个群最多的incoming webhook数量
+	incomingWebhookMaxCount = 10
+	// incomingNameMaxLen 名称的最大字符数
+	incomingNameMaxLen = 40
+	// incomingTextMaxLen 消息内容的最大字符数 超过时截断
+	incomingTextMaxLen = 4000
+	// incomingRateLimitPrefix 每个incoming webhook每分钟发送的消息数
+	incomingRateLimitPrefix = "incomingWebhookRateLimit:"
+)
+
+type incomingWebhookReq struct {
+	Name   string `json:"name"`
+	Status *int   `json:"status"` // 默认启用
+}
+
+func (r *incomingWebhookReq) check() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("名称不能为空！")
+	}
+	if utf8.RuneCountInString(r.Name) > incomingNameMaxLen {
+		return fmt.Errorf("名称不能超过%d个字符！", incomingNameMaxLen)
+	}
+	if r.Status != nil && *r.Status != 0 && *r.Status != 1 {
+		return errors.New("status只能是0或1！")
+	}
+	return nil
+}
+
+type incomingWebhookResp struct {
+	ID        int64  `json:"id"`
+	GroupNo   string `json:"group_no"`
+	Name      string `json:"name"`
+	URL       string `json:"url"` // 调用的地址 包含token
+	Status    int    `json:"status"`
+	Creator   string `json:"creator"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func (rb *Robot) newIncomingWebhookResp(m *incomingWebhookModel) *incomingWebhookResp {
+	return &incomingWebhookResp{
+		ID:        m.Id,
+		GroupNo:   m.GroupNo,
+		Name:      m.Name,
+		URL:       fmt.Sprintf("%s/hooks/%s", rb.ctx.GetConfig().External.APIBaseURL, m.Token),
+		Status:    m.Status,
+		Creator:   m.Creator,
+		CreatedAt: m.CreatedAt.String(),
+		UpdatedAt: m.UpdatedAt.String(),
+	}
+}
+
+// checkGroupManager 群主和管理员可以配置incoming webhook和机器人的管理权限
+func (rb *Robot) checkGroupManager(groupNo string, uid string) error {
+	isManager, err := rb.groupService.IsCreatorOrManager(groupNo, uid)
+	if err != nil {
+		rb.Error("查询群成员失败！", zap.Error(err))
+		return errcode.ErrQueryGroupMember
+	}
+	if !isManager {
+		return errors.New("只有群主或管理员才能进行此操作！")
+	}
+	return nil
+}
+
+// 群的incoming webhook列表
+func (rb *Robot) incomingWebhooks(c *wkhttp.Context) {
+	groupNo := c.Param("group_no")
+	if err := rb.checkGroupManager(groupNo, c.GetLoginUID()); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := rb.db.queryIncomingWebhooksWithGroupNo(groupNo)
+	if err != nil {
+		rb.Error("查询incoming webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询incoming webhook失败！"))
+		return
+	}
+	resps := make([]*incomingWebhookResp, 0, len(models))
+	for _, m := range models {
+		resps = append(resps, rb.newIncomingWebhookResp(m))
+	}
+	c.Response(resps)
+}
+
+// 添加incoming webhook
+func (rb *Robot) incomingWebhookAdd(c *wkhttp.Context) {
+	var req incomingWebhookReq
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupNo := c.Param("group_no")
+	loginUID := c.GetLoginUID()
+	if err := rb.checkGroupManager(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	count, err := rb.db.queryIncomingWebhookCount(groupNo)
+	if err != nil {
+		rb.Error("查询incoming webhook数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询incoming webhook数量失败！"))
+		return
+	}
+	if count >= incomingWebhookMaxCount {
+		c.ResponseError(fmt.Errorf("每个群最多只能添加%d个incoming webhook！", incomingWebhookMaxCount))
+		return
+	}
+	m := &incomingWebhookModel{
+		GroupNo: groupNo,
+		Name:    req.Name,
+		Token:   util.GenerUUID(),
+		Status:  1,
+		Creator: loginUID,
+	}
+	if req.Status != nil {
+		m.Status = *req.Status
+	}
+	if err = rb.db.insertIncomingWebhook(m); err != nil {
+		rb.Error("添加incoming webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加incoming webhook失败！"))
+		return
+	}
+	c.Response(rb.newIncomingWebhookResp(m))
+}
+
+// 修改incoming webhook
+func (rb *Robot) incomingWebhookUpdate(c *wkhttp.Context) {
+	var req incomingWebhookReq
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	m, ok := rb.getIncomingWebhookForManage(c)
+	if !ok {
+		return
+	}
+	m.Name = req.Name
+	if req.Status != nil {
+		m.Status = *req.Status
+	}
+	if err := rb.db.updateIncomingWebhook(m); err != nil {
+		rb.Error("修改incoming webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改incoming webhook失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 重新生成incoming webhook的token 旧的地址立即失效
+func (rb *Robot) incomingWebhookResetToken(c *wkhttp.Context) {
+	m, ok := rb.getIncomingWebhookForManage(c)
+	if !ok {
+		return
+	}
+	m.Token = util.GenerUUID()
+	if err := rb.db.updateIncomingWebhook(m); err != nil {
+		rb.Error("修改incoming webhook的token失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改incoming webhook的token失败！"))
+		return
+	}
+	c.Response(rb.newIncomingWebhookResp(m))
+}
+
+// 删除incoming webhook
+func (rb *Robot) incomingWebhookDelete(c *wkhttp.Context) {
+	groupNo := c.Param("group_no")
+	if err := rb.checkGroupManager(groupNo, c.GetLoginUID()); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err := rb.db.deleteIncomingWebhook(groupNo, id); err != nil {
+		rb.Error("删除incoming webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除incoming webhook失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+func (rb *Robot) getIncomingWebhookForManage(c *wkhttp.Context) (*incomingWebhookModel, bool) {
+	groupNo := c.Param("group_no")
+	if err := rb.checkGroupManager(groupNo, c.GetLoginUID()); err != nil {
+		c.ResponseError(err)
+		return nil, false
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	m, err := rb.db.queryIncomingWebhookWithID(groupNo, id)
+	if err != nil {
+		rb.Error("查询incoming webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询incoming webhook失败！"))
+		return nil, false
+	}
+	if m == nil {
+		c.ResponseError(errors.New("incoming webhook不存在！"))
+		return nil, false
+	}
+	return m, true
+}
+
+// 通过incoming webhook发送消息到群内 请求体兼容Slack（json或表单中的payload字段） 与Slack一致返回纯文本
+func (rb *Robot) incomingWebhookPost(c *wkhttp.Context) {
+	webhook, err := rb.db.queryIncomingWebhookWithToken(c.Param("token"))
+	if err != nil {
+		rb.Error("查询incoming webhook失败！", zap.Error(err))
+		c.String(http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if webhook == nil {
+		c.String(http.StatusNotFound, "no_service")
+		return
+	}
+	if webhook.Status != 1 {
+		c.String(http.StatusForbidden, "action_prohibited")
+		return
+	}
+	var msg *slackMessage
+	if payload := c.PostForm("payload"); payload != "" {
+		err = util.ReadJsonByByte([]byte(payload), &msg)
+	} else {
+		err = c.ShouldBindJSON(&msg)
+	}
+	if err != nil || msg == nil {
+		c.String(http.StatusBadRequest, "invalid_payload")
+		return
+	}
+	text := msg.toText()
+	if text == "" {
+		c.String(http.StatusBadRequest, "no_text")
+		return
+	}
+	if limit := extconfig.Get().Bot.ChatRateLimit; limit > 0 {
+		key := fmt.Sprintf("%s%d:%d", incomingRateLimitPrefix, webhook.Id, time.Now().Unix()/60)
+		if !rb.allowRate(key, limit, time.Minute*2) {
+			c.String(http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+	}
+	groupResp, err := rb.groupService.GetGroupWithGroupNo(webhook.GroupNo)
+	if err != nil {
+		rb.Error("查询群信息失败！", zap.Error(err))
+		c.String(http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if groupResp == nil {
+		c.String(http.StatusNotFound, "channel_not_found")
+		return
+	}
+	name := webhook.Name
+	if strings.TrimSpace(msg.Username) != "" {
+		name = msg.Username
+	}
+	content := fmt.Sprintf("[%s]\n%s", name, text)
+	if utf8.RuneCountInString(content) > incomingTextMaxLen {
+		content = string([]rune(content)[:incomingTextMaxLen])
+	}
+	err = rb.ctx.SendMessage(&config.MsgSendReq{
+		FromUID:     rb.ctx.GetConfig().Account.SystemUID,
+		ChannelID:   webhook.GroupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"type":    common.Text,
+		})),
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+	})
+	if err != nil {
+		rb.Error("通过incoming webhook发送消息失败！", zap.Error(err), zap.Int64("id", webhook.Id), zap.String("groupNo", webhook.GroupNo))
+		c.String(http.StatusInternalServerError, "internal_error")
+		return
+	}
+	c.String(http.StatusOK, "ok")
+}
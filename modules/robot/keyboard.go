@@ -0,0 +1,311 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// inlineKeyboardMaxRows 按钮的最大行数
+	inlineKeyboardMaxRows = 10
+	// inlineKeyboardMaxColumns 每行按钮的最大数量
+	inlineKeyboardMaxColumns = 8
+	// buttonTextMaxLen 按钮文字的最大字符数
+	buttonTextMaxLen = 64
+	// callbackDataMaxLen callback_data的最大字节数
+	callbackDataMaxLen = 64
+	// answerTextMaxLen 响应提示内容的最大字符数
+	answerTextMaxLen = 200
+)
+
+// callbackQueryWaiter 等待机器人响应按钮点击
+type callbackQueryWaiter struct {
+	robotID string
+	answer  chan *CallbackQueryAnswer
+}
+
+// checkReplyMarkup 校验payload中的reply_markup 为空时不校验
+func checkReplyMarkup(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	var markup *ReplyMarkup
+	if err := util.ReadJsonByByte([]byte(util.ToJson(value)), &markup); err != nil || markup == nil {
+		return errors.New("reply_markup格式有误！")
+	}
+	if len(markup.InlineKeyboard) > inlineKeyboardMaxRows {
+		return fmt.Errorf("按钮不能超过%d行！", inlineKeyboardMaxRows)
+	}
+	for _, row := range markup.InlineKeyboard {
+		if len(row) == 0 {
+			return errors.New("按钮行不能为空！")
+		}
+		if len(row) > inlineKeyboardMaxColumns {
+			return fmt.Errorf("每行按钮不能超过%d个！", inlineKeyboardMaxColumns)
+		}
+		for _, button := range row {
+			if button == nil || strings.TrimSpace(button.Text) == "" {
+				return errors.New("按钮文字不能为空！")
+			}
+			if utf8.RuneCountInString(button.Text) > buttonTextMaxLen {
+				return fmt.Errorf("按钮文字不能超过%d个字符！", buttonTextMaxLen)
+			}
+			if (button.CallbackData == "") == (button.URL == "") {
+				return errors.New("按钮的callback_data和url必须有且只有一个！")
+			}
+			if len(button.CallbackData) > callbackDataMaxLen {
+				return fmt.Errorf("callback_data不能超过%d个字节！", callbackDataMaxLen)
+			}
+			if button.URL != "" && !strings.HasPrefix(button.URL, "http://") && !strings.HasPrefix(button.URL, "https://") {
+				return errors.New("按钮的url必须是http或https地址！")
+			}
+		}
+	}
+	return nil
+}
+
+// hasCallbackData 消息正文的按钮中是否有指定的callback_data
+func hasCallbackData(payload []byte, data string) bool {
+	var content struct {
+		ReplyMarkup *ReplyMarkup `json:"reply_markup"`
+	}
+	if err := util.ReadJsonByByte(payload, &content); err != nil || content.ReplyMarkup == nil {
+		return false
+	}
+	for _, row := range content.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			if button != nil && button.CallbackData != "" && button.CallbackData == data {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 用户点击消息按钮 推送给机器人并等待机器人响应
+func (rb *Robot) callbackQuery(c *wkhttp.Context) {
+	var req struct {
+		ChannelID   string `json:"channel_id"`
+		ChannelType uint8  `json:"channel_type"`
+		MessageID   int64  `json:"message_id"`
+		Data        string `json:"data"` // 按钮的callback_data
+	}
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := checkBotMessageReq(req.ChannelID, req.ChannelType, req.MessageID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if req.Data == "" {
+		c.ResponseError(errors.New("data不能为空！"))
+		return
+	}
+	loginUID := c.GetLoginUID()
+	messageResp, err := rb.messageService.GetMessage(loginUID, req.ChannelID, req.ChannelType, req.MessageID)
+	if err != nil {
+		rb.Error("查询消息失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrQueryMessage)
+		return
+	}
+	if messageResp == nil {
+		c.ResponseError(errcode.ErrMessageNotExist)
+		return
+	}
+	robotID := messageResp.FromUID
+	if robotID == rb.ctx.GetConfig().Account.SystemUID && req.ChannelType == common.ChannelTypePerson.Uint8() {
+		// 系统账号发送的新用户引导消息
+		rb.onboardingCallbackQuery(c, messageResp, req.Data)
+		return
+	}
+	exist, err := rb.existRobot(robotID)
+	if err != nil {
+		rb.Error("查询有效robotID失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询机器人失败！"))
+		return
+	}
+	if !exist {
+		c.ResponseError(errors.New("不是机器人发送的消息！"))
+		return
+	}
+	channelID := req.ChannelID
+	if req.ChannelType == common.ChannelTypePerson.Uint8() {
+		// 机器人通过用户uid回复
+		channelID = loginUID
+	} else if req.ChannelType == common.ChannelTypeGroup.Uint8() {
+		isMember, err := rb.groupService.ExistMember(req.ChannelID, loginUID)
+		if err != nil {
+			rb.Error("查询群成员失败！", zap.Error(err))
+			c.ResponseError(errcode.ErrQueryGroupMember)
+			return
+		}
+		if !isMember {
+			c.ResponseError(errors.New("不在此群内！"))
+			return
+		}
+	}
+	if !hasCallbackData(messageResp.Payload, req.Data) {
+		c.ResponseError(errors.New("按钮不存在！"))
+		return
+	}
+	callbackQuery := &CallbackQuery{
+		ID:          util.GenerUUID(),
+		FromUID:     loginUID,
+		ChannelID:   channelID,
+		ChannelType: req.ChannelType,
+		MessageID:   messageResp.MessageID,
+		MessageSeq:  messageResp.MessageSeq,
+		Data:        req.Data,
+	}
+	waiter := rb.addCallbackQueryWaiter(callbackQuery.ID, robotID)
+	defer rb.removeCallbackQueryWaiter(callbackQuery.ID)
+
+	go rb.saveRobotEvent(robotID, &robotEvent{
+		CallbackQuery: callbackQuery,
+	})
+
+	select {
+	case answer := <-waiter.answer:
+		c.Response(answer)
+	case <-time.After(extconfig.Get().Bot.CallbackTimeout):
+		// 机器人没有及时响应时不显示提示
+		c.Response(&CallbackQueryAnswer{
+			CallbackQueryID: callbackQuery.ID,
+		})
+	}
+}
+
+func (rb *Robot) addCallbackQueryWaiter(id string, robotID string) *callbackQueryWaiter {
+	waiter := &callbackQueryWaiter{
+		robotID: robotID,
+		answer:  make(chan *CallbackQueryAnswer, 1),
+	}
+	rb.callbackQueryWaitersLock.Lock()
+	rb.callbackQueryWaiters[id] = waiter
+	rb.callbackQueryWaitersLock.Unlock()
+	return waiter
+}
+
+func (rb *Robot) removeCallbackQueryWaiter(id string) {
+	rb.callbackQueryWaitersLock.Lock()
+	delete(rb.callbackQueryWaiters, id)
+	rb.callbackQueryWaitersLock.Unlock()
+}
+
+// answerCallback 机器人响应按钮点击 每次点击只能响应一次
+func (rb *Robot) answerCallback(robotID string, answer *CallbackQueryAnswer) error {
+	if answer.CallbackQueryID == "" {
+		return errors.New("callback_query_id不能为空！")
+	}
+	if utf8.RuneCountInString(answer.Text) > answerTextMaxLen {
+		return fmt.Errorf("text不能超过%d个字符！", answerTextMaxLen)
+	}
+	rb.callbackQueryWaitersLock.Lock()
+	waiter := rb.callbackQueryWaiters[answer.CallbackQueryID]
+	rb.callbackQueryWaitersLock.Unlock()
+	if waiter == nil || waiter.robotID != robotID {
+		return errors.New("callback_query不存在或已超时！")
+	}
+	select {
+	case waiter.answer <- answer:
+	default:
+		return errors.New("callback_query已响应！")
+	}
+	return nil
+}
+
+// 响应按钮点击（app_key认证）
+func (rb *Robot) answerCallbackQuery(c *wkhttp.Context) {
+	var answer *CallbackQueryAnswer
+	if err := c.BindJSON(&answer); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := rb.answerCallback(c.Param("robot_id"), answer); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 响应按钮点击（token认证）
+func (rb *Robot) botAnswerCallbackQuery(c *wkhttp.Context) {
+	var answer *CallbackQueryAnswer
+	if err := c.BindJSON(&answer); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := rb.answerCallback(botFromContext(c).RobotID, answer); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 修改机器人发送的消息的按钮 reply_markup为空时删除按钮
+func (rb *Robot) botEditMessageReplyMarkup(c *wkhttp.Context) {
+	var req struct {
+		ChannelID   string      `json:"channel_id"`
+		ChannelType uint8       `json:"channel_type"`
+		MessageID   int64       `json:"message_id"`
+		ReplyMarkup interface{} `json:"reply_markup"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := checkBotMessageReq(req.ChannelID, req.ChannelType, req.MessageID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := checkReplyMarkup(req.ReplyMarkup); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	robotM := botFromContext(c)
+	messageResp, err := rb.messageService.GetMessage(robotM.RobotID, req.ChannelID, req.ChannelType, req.MessageID)
+	if err != nil {
+		rb.Error("查询消息失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrQueryMessage)
+		return
+	}
+	if messageResp == nil {
+		c.ResponseError(errcode.ErrMessageNotExist)
+		return
+	}
+	if messageResp.FromUID != robotM.RobotID {
+		c.ResponseError(errors.New("只能修改机器人自己发送的消息！"))
+		return
+	}
+	var payload map[string]interface{}
+	if err = util.ReadJsonByByte(messageResp.Payload, &payload); err != nil || payload == nil {
+		c.ResponseError(errors.New("消息正文格式有误！"))
+		return
+	}
+	if req.ReplyMarkup == nil {
+		delete(payload, "reply_markup")
+	} else {
+		payload["reply_markup"] = req.ReplyMarkup
+	}
+	err = rb.messageService.EditMessage(robotM.RobotID, req.ChannelID, req.ChannelType, req.MessageID, util.ToJson(payload))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
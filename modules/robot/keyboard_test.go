@@ -0,0 +1,57 @@
+package robot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckReplyMarkup(t *testing.T) {
+	assert.NoError(t, checkReplyMarkup(nil))
+	assert.NoError(t, checkReplyMarkup(map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{
+			{{"text": "同意", "callback_data": "approve:1"}, {"text": "拒绝", "callback_data": "reject:1"}},
+			{{"text": "详情", "url": "https://example.com/1"}},
+		},
+	}))
+	assert.Error(t, checkReplyMarkup("abc"))
+	// callback_data和url都有或都没有
+	assert.Error(t, checkReplyMarkup(map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{{{"text": "同意"}}},
+	}))
+	assert.Error(t, checkReplyMarkup(map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{{{"text": "同意", "callback_data": "a", "url": "https://example.com"}}},
+	}))
+	assert.Error(t, checkReplyMarkup(map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{{{"text": "同意", "callback_data": strings.Repeat("a", callbackDataMaxLen+1)}}},
+	}))
+	assert.Error(t, checkReplyMarkup(map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{{{"text": "打开", "url": "javascript:alert(1)"}}},
+	}))
+	assert.Error(t, checkReplyMarkup(map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{{}},
+	}))
+}
+
+func TestHasCallbackData(t *testing.T) {
+	payload := []byte(`{"type":1,"content":"审批","reply_markup":{"inline_keyboard":[[{"text":"同意","callback_data":"approve:1"}],[{"text":"详情","url":"https://example.com"}]]}}`)
+	assert.True(t, hasCallbackData(payload, "approve:1"))
+	assert.False(t, hasCallbackData(payload, "reject:1"))
+	assert.False(t, hasCallbackData([]byte(`{"type":1,"content":"hi"}`), "approve:1"))
+}
+
+func TestAnswerCallback(t *testing.T) {
+	rb := &Robot{callbackQueryWaiters: map[string]*callbackQueryWaiter{}}
+	waiter := rb.addCallbackQueryWaiter("q1", "bot1")
+
+	assert.Error(t, rb.answerCallback("bot2", &CallbackQueryAnswer{CallbackQueryID: "q1"}))
+	assert.NoError(t, rb.answerCallback("bot1", &CallbackQueryAnswer{CallbackQueryID: "q1", Text: "已同意"}))
+	// 每次点击只能响应一次
+	assert.Error(t, rb.answerCallback("bot1", &CallbackQueryAnswer{CallbackQueryID: "q1"}))
+	answer := <-waiter.answer
+	assert.Equal(t, "已同意", answer.Text)
+
+	rb.removeCallbackQueryWaiter("q1")
+	assert.Error(t, rb.answerCallback("bot1", &CallbackQueryAnswer{CallbackQueryID: "q1"}))
+}
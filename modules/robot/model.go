@@ -7,10 +7,11 @@ import (
 )
 
 type robotEvent struct {
-	EventID     int64               `json:"event_id,omitempty"` // 更新ID
-	Message     *config.MessageResp `json:"message,omitempty"`  // 消息对象
-	InlineQuery *InlineQuery        `json:"inline_query,omitempty"`
-	Expire      int64               `json:"expire,omitempty"` // 过期时间
+	EventID       int64               `json:"event_id,omitempty"` // 更新ID
+	Message       *config.MessageResp `json:"message,omitempty"`  // 消息对象
+	InlineQuery   *InlineQuery        `json:"inline_query,omitempty"`
+	CallbackQuery *CallbackQuery      `json:"callback_query,omitempty"` // 点击消息按钮
+	Expire        int64               `json:"expire,omitempty"`         // 过期时间
 }
 
 type InlineQuery struct {
@@ -72,3 +73,34 @@ type TypingReq struct {
 	ChannelID   string `json:"channel_id"`
 	ChannelType uint8  `json:"channel_type"`
 }
+
+// ReplyMarkup 消息下方的按钮 放在payload.reply_markup中
+type ReplyMarkup struct {
+	InlineKeyboard [][]*InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// InlineKeyboardButton 消息按钮 callback_data和url必须有且只有一个
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data,omitempty"` // 点击后推送给机器人的数据
+	URL          string `json:"url,omitempty"`           // 点击后打开的链接
+}
+
+// CallbackQuery 用户点击了消息按钮
+type CallbackQuery struct {
+	ID          string `json:"id"`
+	FromUID     string `json:"from_uid"`     // 点击按钮的用户
+	ChannelID   string `json:"channel_id"`   // 消息所在的频道 个人频道为用户uid
+	ChannelType uint8  `json:"channel_type"` // 频道类型
+	MessageID   int64  `json:"message_id"`   // 按钮所在的消息
+	MessageSeq  uint32 `json:"message_seq"`
+	Data        string `json:"data"` // 按钮的callback_data
+}
+
+// CallbackQueryAnswer 机器人对按钮点击的响应 返回给点击按钮的用户
+type CallbackQueryAnswer struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`       // 提示内容
+	ShowAlert       bool   `json:"show_alert,omitempty"` // 是否以弹窗显示提示
+	URL             string `json:"url,omitempty"`        // 需要打开的链接
+}
@@ -0,0 +1,288 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// muteMaxDuration 机器人禁言成员的最长时间（秒）
+const muteMaxDuration = 60 * 60 * 24 * 30
+
+// 群授权给机器人的操作
+const (
+	groupActionDeleteMessage = "delete_message"
+	groupActionMuteMember    = "mute_member"
+	groupActionKickMember    = "kick_member"
+)
+
+type groupPermissionReq struct {
+	DeleteMessage bool `json:"delete_message"` // 撤回成员的消息
+	MuteMember    bool `json:"mute_member"`    // 禁言成员
+	KickMember    bool `json:"kick_member"`    // 移除成员
+}
+
+type groupPermissionResp struct {
+	RobotID       string `json:"robot_id"`
+	DeleteMessage bool   `json:"delete_message"`
+	MuteMember    bool   `json:"mute_member"`
+	KickMember    bool   `json:"kick_member"`
+	Operator      string `json:"operator"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+func newGroupPermissionResp(m *groupPermissionModel) *groupPermissionResp {
+	return &groupPermissionResp{
+		RobotID:       m.RobotID,
+		DeleteMessage: m.DeleteMessage == 1,
+		MuteMember:    m.MuteMember == 1,
+		KickMember:    m.KickMember == 1,
+		Operator:      m.Operator,
+		UpdatedAt:     m.UpdatedAt.String(),
+	}
+}
+
+// allow 是否授权了某个操作
+func (m *groupPermissionModel) allow(action string) bool {
+	switch action {
+	case groupActionDeleteMessage:
+		return m.DeleteMessage == 1
+	case groupActionMuteMember:
+		return m.MuteMember == 1
+	case groupActionKickMember:
+		return m.KickMember == 1
+	}
+	return false
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// 群授权给机器人的管理权限列表
+func (rb *Robot) groupPermissions(c *wkhttp.Context) {
+	groupNo := c.Param("group_no")
+	if err := rb.checkGroupManager(groupNo, c.GetLoginUID()); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := rb.db.queryGroupPermissionsWithGroupNo(groupNo)
+	if err != nil {
+		rb.Error("查询机器人的管理权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询机器人的管理权限失败！"))
+		return
+	}
+	resps := make([]*groupPermissionResp, 0, len(models))
+	for _, m := range models {
+		resps = append(resps, newGroupPermissionResp(m))
+	}
+	c.Response(resps)
+}
+
+// 设置群授权给机器人的管理权限 机器人需要在群内
+func (rb *Robot) groupPermissionSet(c *wkhttp.Context) {
+	var req groupPermissionReq
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	groupNo := c.Param("group_no")
+	robotID := c.Param("robot_id")
+	loginUID := c.GetLoginUID()
+	if err := rb.checkGroupManager(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	exist, err := rb.existRobot(robotID)
+	if err != nil {
+		rb.Error("查询有效robotID失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询机器人失败！"))
+		return
+	}
+	if !exist {
+		c.ResponseError(errors.New("机器人不存在！"))
+		return
+	}
+	isMember, err := rb.groupService.ExistMember(groupNo, robotID)
+	if err != nil {
+		rb.Error("查询群成员失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrQueryGroupMember)
+		return
+	}
+	if !isMember {
+		c.ResponseError(errors.New("机器人不在此群内！"))
+		return
+	}
+	err = rb.db.upsertGroupPermission(&groupPermissionModel{
+		GroupNo:       groupNo,
+		RobotID:       robotID,
+		DeleteMessage: boolToInt(req.DeleteMessage),
+		MuteMember:    boolToInt(req.MuteMember),
+		KickMember:    boolToInt(req.KickMember),
+		Operator:      loginUID,
+	})
+	if err != nil {
+		rb.Error("设置机器人的管理权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置机器人的管理权限失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 取消群授权给机器人的全部管理权限
+func (rb *Robot) groupPermissionDelete(c *wkhttp.Context) {
+	groupNo := c.Param("group_no")
+	if err := rb.checkGroupManager(groupNo, c.GetLoginUID()); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := rb.db.deleteGroupPermission(groupNo, c.Param("robot_id")); err != nil {
+		rb.Error("删除机器人的管理权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除机器人的管理权限失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// checkBotGroupAction 校验机器人是否可以对群成员执行操作 机器人需要在群内且被授权 不能操作群主和管理员
+func (rb *Robot) checkBotGroupAction(robotID string, groupNo string, action string, targetUID string) error {
+	permissionM, err := rb.db.queryGroupPermission(groupNo, robotID)
+	if err != nil {
+		rb.Error("查询机器人的管理权限失败！", zap.Error(err))
+		return errors.New("查询机器人的管理权限失败！")
+	}
+	if permissionM == nil || !permissionM.allow(action) {
+		return fmt.Errorf("机器人没有此群的[%s]权限！", action)
+	}
+	isMember, err := rb.groupService.ExistMember(groupNo, robotID)
+	if err != nil {
+		rb.Error("查询群成员失败！", zap.Error(err))
+		return errcode.ErrQueryGroupMember
+	}
+	if !isMember {
+		return errors.New("机器人不在此群内！")
+	}
+	if targetUID == robotID {
+		return errors.New("不能对机器人自己操作！")
+	}
+	member, err := rb.groupService.GetMember(groupNo, targetUID)
+	if err != nil {
+		rb.Error("查询群成员失败！", zap.Error(err))
+		return errcode.ErrQueryGroupMember
+	}
+	if member == nil {
+		if action == groupActionDeleteMessage {
+			// 已经退出或被移除的成员的消息也可以撤回
+			return nil
+		}
+		return errors.New("该成员不在群内！")
+	}
+	if member.Role == group.MemberRoleCreator || member.Role == group.MemberRoleManager {
+		return errors.New("不能对群主或管理员操作！")
+	}
+	return nil
+}
+
+type botGroupMemberReq struct {
+	GroupNo  string `json:"group_no"`
+	UID      string `json:"uid"`
+	Duration int64  `json:"duration"` // 禁言的秒数 0为解除禁言
+}
+
+func (r *botGroupMemberReq) check() error {
+	if strings.TrimSpace(r.GroupNo) == "" {
+		return errors.New("group_no不能为空！")
+	}
+	if strings.TrimSpace(r.UID) == "" {
+		return errcode.ErrUIDRequired
+	}
+	if r.Duration < 0 || r.Duration > muteMaxDuration {
+		return fmt.Errorf("duration只能是0到%d秒！", muteMaxDuration)
+	}
+	return nil
+}
+
+// 禁言或解除禁言群成员 需要群授权
+func (rb *Robot) botMuteMember(c *wkhttp.Context) {
+	var req botGroupMemberReq
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	robotM := botFromContext(c)
+	if err := rb.checkBotGroupAction(robotM.RobotID, req.GroupNo, groupActionMuteMember, req.UID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var expireAt int64
+	if req.Duration > 0 {
+		expireAt = time.Now().Unix() + req.Duration
+	}
+	if err := rb.groupService.MuteMember(req.GroupNo, req.UID, expireAt); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 移除群成员 需要群授权
+func (rb *Robot) botKickMember(c *wkhttp.Context) {
+	var req botGroupMemberReq
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	robotM := botFromContext(c)
+	if err := rb.checkBotGroupAction(robotM.RobotID, req.GroupNo, groupActionKickMember, req.UID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	err := rb.groupService.RemoveMembers(&group.RemoveMembersReq{
+		GroupNo:      req.GroupNo,
+		Operator:     robotM.RobotID,
+		OperatorName: robotM.Username,
+		MemberUIDs:   []string{req.UID},
+	})
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// revokeGroupMemberMessage 撤回群成员的消息 需要群授权 返回false时是机器人自己的消息或消息不存在
+func (rb *Robot) revokeGroupMemberMessage(robotM *robot, groupNo string, messageID int64) (bool, error) {
+	messageResp, err := rb.messageService.GetMessage(robotM.RobotID, groupNo, common.ChannelTypeGroup.Uint8(), messageID)
+	if err != nil {
+		rb.Error("查询消息失败！", zap.Error(err))
+		return false, errcode.ErrQueryMessage
+	}
+	if messageResp == nil || messageResp.FromUID == "" || messageResp.FromUID == robotM.RobotID {
+		return false, nil
+	}
+	if err = rb.checkBotGroupAction(robotM.RobotID, groupNo, groupActionDeleteMessage, messageResp.FromUID); err != nil {
+		return false, err
+	}
+	return true, rb.messageService.RevokeGroupMessage(robotM.RobotID, robotM.Username, groupNo, messageID)
+}
@@ -0,0 +1,24 @@
+package robot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupPermissionAllow(t *testing.T) {
+	m := &groupPermissionModel{DeleteMessage: 1, KickMember: 1}
+	assert.True(t, m.allow(groupActionDeleteMessage))
+	assert.False(t, m.allow(groupActionMuteMember))
+	assert.True(t, m.allow(groupActionKickMember))
+	assert.False(t, m.allow("unknown"))
+}
+
+func TestBotGroupMemberReqCheck(t *testing.T) {
+	assert.Error(t, (&botGroupMemberReq{UID: "u1"}).check())
+	assert.Error(t, (&botGroupMemberReq{GroupNo: "g1"}).check())
+	assert.Error(t, (&botGroupMemberReq{GroupNo: "g1", UID: "u1", Duration: -1}).check())
+	assert.Error(t, (&botGroupMemberReq{GroupNo: "g1", UID: "u1", Duration: muteMaxDuration + 1}).check())
+	assert.NoError(t, (&botGroupMemberReq{GroupNo: "g1", UID: "u1", Duration: 600}).check())
+	assert.NoError(t, (&botGroupMemberReq{GroupNo: "g1", UID: "u1"}).check())
+}
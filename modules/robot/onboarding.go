@@ -0,0 +1,148 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// onboardingMaxSteps 每个触发条件下最多的步骤数
+	onboardingMaxSteps = 20
+	// onboardingMaxDelay 每个步骤发送前最多等待的秒数
+	onboardingMaxDelay = 60
+	// onboardingRemarkMaxLen 备注的最大字符数
+	onboardingRemarkMaxLen = 100
+)
+
+type onboardingStepReq struct {
+	TriggerData string                 `json:"trigger_data"` // 触发的按钮callback_data 为空时注册后发送
+	SortNum     int                    `json:"sort_num"`
+	Delay       int                    `json:"delay"`   // 发送前等待的秒数
+	Payload     map[string]interface{} `json:"payload"` // 与机器人sendMessage的payload相同 可带reply_markup按钮
+	Remark      string                 `json:"remark"`
+	Status      *int                   `json:"status"` // 默认启用
+}
+
+func (r *onboardingStepReq) check() error {
+	if len(r.TriggerData) > callbackDataMaxLen {
+		return fmt.Errorf("trigger_data不能超过%d个字节！", callbackDataMaxLen)
+	}
+	if r.Delay < 0 || r.Delay > onboardingMaxDelay {
+		return fmt.Errorf("delay只能是0到%d秒！", onboardingMaxDelay)
+	}
+	if utf8.RuneCountInString(r.Remark) > onboardingRemarkMaxLen {
+		return fmt.Errorf("备注不能超过%d个字符！", onboardingRemarkMaxLen)
+	}
+	if r.Status != nil && *r.Status != 0 && *r.Status != 1 {
+		return errors.New("status只能是0或1！")
+	}
+	return checkPayload(r.Payload)
+}
+
+func (r *onboardingStepReq) fill(m *onboardingStepModel) {
+	m.TriggerData = strings.TrimSpace(r.TriggerData)
+	m.SortNum = r.SortNum
+	m.Delay = r.Delay
+	m.Payload = util.ToJson(r.Payload)
+	m.Remark = r.Remark
+	if r.Status != nil {
+		m.Status = *r.Status
+	}
+}
+
+type onboardingStepResp struct {
+	ID          int64                  `json:"id"`
+	TriggerData string                 `json:"trigger_data"`
+	SortNum     int                    `json:"sort_num"`
+	Delay       int                    `json:"delay"`
+	Payload     map[string]interface{} `json:"payload"`
+	Remark      string                 `json:"remark"`
+	Status      int                    `json:"status"`
+	CreatedAt   string                 `json:"created_at"`
+	UpdatedAt   string                 `json:"updated_at"`
+}
+
+func newOnboardingStepResp(m *onboardingStepModel) *onboardingStepResp {
+	var payload map[string]interface{}
+	_ = util.ReadJsonByByte([]byte(m.Payload), &payload)
+	return &onboardingStepResp{
+		ID:          m.Id,
+		TriggerData: m.TriggerData,
+		SortNum:     m.SortNum,
+		Delay:       m.Delay,
+		Payload:     payload,
+		Remark:      m.Remark,
+		Status:      m.Status,
+		CreatedAt:   m.CreatedAt.String(),
+		UpdatedAt:   m.UpdatedAt.String(),
+	}
+}
+
+// sendOnboardingSteps 由系统账号按顺序发送引导消息给用户
+func sendOnboardingSteps(ctx *config.Context, l log.Log, steps []*onboardingStepModel, uid string) {
+	for _, step := range steps {
+		if step.Delay > 0 {
+			time.Sleep(time.Duration(step.Delay) * time.Second)
+		}
+		err := ctx.SendMessage(&config.MsgSendReq{
+			FromUID:     ctx.GetConfig().Account.SystemUID,
+			ChannelID:   uid,
+			ChannelType: common.ChannelTypePerson.Uint8(),
+			Payload:     []byte(step.Payload),
+			Header: config.MsgHeader{
+				RedDot: 1,
+			},
+		})
+		if err != nil {
+			l.Error("发送新用户引导消息失败！", zap.Error(err), zap.Int64("stepID", step.Id), zap.String("uid", uid))
+			return
+		}
+	}
+}
+
+// sendOnboarding 发送某个触发条件下的引导消息
+func (rb *Robot) sendOnboarding(uid string, triggerData string) {
+	steps, err := rb.db.queryEnabledOnboardingSteps(triggerData)
+	if err != nil {
+		rb.Error("查询新用户引导步骤失败！", zap.Error(err), zap.String("triggerData", triggerData))
+		return
+	}
+	sendOnboardingSteps(rb.ctx, rb.Log, steps, uid)
+}
+
+// handleUserRegister 用户注册后发送引导消息
+func (rb *Robot) handleUserRegister(data []byte, commit config.EventCommit) {
+	var req map[string]interface{}
+	err := util.ReadJsonByByte(data, &req)
+	if err != nil {
+		rb.Error("新用户引导处理用户注册参数有误", zap.Error(err))
+		commit(err)
+		return
+	}
+	if uid, _ := req["uid"].(string); uid != "" {
+		go rb.sendOnboarding(uid, "")
+	}
+	commit(nil)
+}
+
+// onboardingCallbackQuery 用户点击了引导消息的按钮 发送按钮触发的步骤
+func (rb *Robot) onboardingCallbackQuery(c *wkhttp.Context, messageResp *config.MessageResp, data string) {
+	if !hasCallbackData(messageResp.Payload, data) {
+		c.ResponseError(errors.New("按钮不存在！"))
+		return
+	}
+	go rb.sendOnboarding(c.GetLoginUID(), data)
+	c.Response(&CallbackQueryAnswer{
+		CallbackQueryID: util.GenerUUID(),
+	})
+}
@@ -0,0 +1,54 @@
+package robot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnboardingStepReqCheck(t *testing.T) {
+	req := &onboardingStepReq{
+		Payload: map[string]interface{}{
+			"type":    1,
+			"content": "欢迎使用",
+			"reply_markup": map[string]interface{}{
+				"inline_keyboard": [][]map[string]interface{}{
+					{{"text": "开始", "callback_data": "onboarding_start"}},
+				},
+			},
+		},
+	}
+	assert.NoError(t, req.check())
+
+	req.Delay = onboardingMaxDelay + 1
+	assert.Error(t, req.check())
+	req.Delay = 0
+
+	status := 2
+	req.Status = &status
+	assert.Error(t, req.check())
+	req.Status = nil
+
+	req.Payload = map[string]interface{}{"type": 1}
+	assert.Error(t, req.check())
+}
+
+func TestOnboardingStepReqFill(t *testing.T) {
+	status := 0
+	req := &onboardingStepReq{
+		TriggerData: " onboarding_start ",
+		SortNum:     2,
+		Delay:       3,
+		Payload:     map[string]interface{}{"type": 1, "content": "第二步"},
+		Status:      &status,
+	}
+	m := &onboardingStepModel{Status: 1}
+	req.fill(m)
+	assert.Equal(t, "onboarding_start", m.TriggerData)
+	assert.Equal(t, 2, m.SortNum)
+	assert.Equal(t, 3, m.Delay)
+	assert.Equal(t, 0, m.Status)
+
+	resp := newOnboardingStepResp(m)
+	assert.Equal(t, "第二步", resp.Payload["content"])
+}
@@ -0,0 +1,489 @@
+package robot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/tidwall/gjson"
+	"go.uber.org/zap"
+)
+
+const (
+	// outgoingWebhookMaxCount 每个群最多的outgoing webhook数量
+	outgoingWebhookMaxCount = 10
+	// outgoingNameMaxLen 名称的最大字符数
+	outgoingNameMaxLen = 40
+	// outgoingKeywordMaxCount 关键词的最大数量
+	outgoingKeywordMaxCount = 20
+	// outgoingKeywordMaxLen 每个关键词的最大字符数
+	outgoingKeywordMaxLen = 50
+	// outgoingRegexMaxLen 正则表达式的最大长度
+	outgoingRegexMaxLen = 255
+	// outgoingResponseMaxSize 读取返回内容的最大字节数
+	outgoingResponseMaxSize = 64 * 1024
+	// outgoingReplyMaxLen 发送到群内的返回内容的最大字符数
+	outgoingReplyMaxLen = 4000
+	// outgoingWebhookCachePrefix 群是否有启用的outgoing webhook
+	outgoingWebhookCachePrefix = "robot:outgoing:"
+	// outgoingRegexpCacheSize 缓存的正则表达式的最大数量 超过时清空
+	outgoingRegexpCacheSize = 1000
+	// outgoingPushMaxConcurrency 同时推送outgoing webhook的最大数量 超过时丢弃
+	outgoingPushMaxConcurrency = 100
+)
+
+type outgoingWebhookReq struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Secret   *string  `json:"secret"` // 修改时为空表示不修改
+	Keywords []string `json:"keywords"`
+	Regex    string   `json:"regex"`
+	ReplyOn  int      `json:"reply_on"`
+	Status   *int     `json:"status"` // 默认启用
+}
+
+func (r *outgoingWebhookReq) check() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("名称不能为空！")
+	}
+	if utf8.RuneCountInString(r.Name) > outgoingNameMaxLen {
+		return fmt.Errorf("名称不能超过%d个字符！", outgoingNameMaxLen)
+	}
+	if err := checkWebhookURL(r.URL); err != nil {
+		return err
+	}
+	if r.Secret != nil && len(*r.Secret) > webhookSecretMaxLen {
+		return fmt.Errorf("secret不能超过%d个字符！", webhookSecretMaxLen)
+	}
+	if len(r.Keywords) == 0 && r.Regex == "" {
+		return errors.New("关键词和正则表达式不能都为空！")
+	}
+	if len(r.Keywords) > outgoingKeywordMaxCount {
+		return fmt.Errorf("关键词不能超过%d个！", outgoingKeywordMaxCount)
+	}
+	for _, keyword := range r.Keywords {
+		if strings.TrimSpace(keyword) == "" {
+			return errors.New("关键词不能为空！")
+		}
+		if utf8.RuneCountInString(keyword) > outgoingKeywordMaxLen {
+			return fmt.Errorf("关键词不能超过%d个字符！", outgoingKeywordMaxLen)
+		}
+	}
+	if len(r.Regex) > outgoingRegexMaxLen {
+		return fmt.Errorf("正则表达式不能超过%d个字符！", outgoingRegexMaxLen)
+	}
+	if r.Regex != "" {
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			return errors.New("正则表达式格式有误！")
+		}
+	}
+	if r.Status != nil && *r.Status != 0 && *r.Status != 1 {
+		return errors.New("status只能是0或1！")
+	}
+	return nil
+}
+
+type outgoingWebhookResp struct {
+	ID        int64    `json:"id"`
+	GroupNo   string   `json:"group_no"`
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	HasSecret bool     `json:"has_secret"`
+	Keywords  []string `json:"keywords"`
+	Regex     string   `json:"regex"`
+	ReplyOn   int      `json:"reply_on"`
+	Status    int      `json:"status"`
+	Creator   string   `json:"creator"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+func newOutgoingWebhookResp(m *outgoingWebhookModel) *outgoingWebhookResp {
+	return &outgoingWebhookResp{
+		ID:        m.Id,
+		GroupNo:   m.GroupNo,
+		Name:      m.Name,
+		URL:       m.URL,
+		HasSecret: m.Secret != "",
+		Keywords:  outgoingKeywords(m.Keywords),
+		Regex:     m.Regex,
+		ReplyOn:   m.ReplyOn,
+		Status:    m.Status,
+		Creator:   m.Creator,
+		CreatedAt: m.CreatedAt.String(),
+		UpdatedAt: m.UpdatedAt.String(),
+	}
+}
+
+// outgoingKeywords 解析保存的关键词
+func outgoingKeywords(value string) []string {
+	keywords := make([]string, 0)
+	if value == "" {
+		return keywords
+	}
+	_ = util.ReadJsonByByte([]byte(value), &keywords)
+	return keywords
+}
+
+// outgoingWebhookPayload 推送到outgoing webhook的内容
+type outgoingWebhookPayload struct {
+	WebhookID   int64  `json:"webhook_id"`
+	GroupNo     string `json:"group_no"`
+	MessageID   int64  `json:"message_id"`
+	MessageSeq  uint32 `json:"message_seq"`
+	FromUID     string `json:"from_uid"`
+	Content     string `json:"content"`
+	TriggerWord string `json:"trigger_word"` // 匹配到的关键词或正则匹配到的内容
+	Timestamp   int32  `json:"timestamp"`
+}
+
+// matchOutgoingWebhook 消息内容是否匹配关键词（不区分大小写）或正则 返回匹配到的内容
+func matchOutgoingWebhook(keywords []string, re *regexp.Regexp, content string) (string, bool) {
+	lowerContent := strings.ToLower(content)
+	for _, keyword := range keywords {
+		if keyword != "" && strings.Contains(lowerContent, strings.ToLower(keyword)) {
+			return keyword, true
+		}
+	}
+	if re != nil {
+		if loc := re.FindStringIndex(content); loc != nil {
+			return content[loc[0]:loc[1]], true
+		}
+	}
+	return "", false
+}
+
+// checkGroupCreator 只有群主可以配置outgoing webhook
+func (rb *Robot) checkGroupCreator(groupNo string, uid string) error {
+	member, err := rb.groupService.GetMember(groupNo, uid)
+	if err != nil {
+		rb.Error("查询群成员失败！", zap.Error(err))
+		return errcode.ErrQueryGroupMember
+	}
+	if member == nil || member.Role != group.MemberRoleCreator {
+		return errors.New("只有群主才能设置outgoing webhook！")
+	}
+	return nil
+}
+
+// 群的outgoing webhook列表
+func (rb *Robot) outgoingWebhooks(c *wkhttp.Context) {
+	groupNo := c.Param("group_no")
+	if err := rb.checkGroupCreator(groupNo, c.GetLoginUID()); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := rb.db.queryOutgoingWebhooksWithGroupNo(groupNo)
+	if err != nil {
+		rb.Error("查询outgoing webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询outgoing webhook失败！"))
+		return
+	}
+	resps := make([]*outgoingWebhookResp, 0, len(models))
+	for _, m := range models {
+		resps = append(resps, newOutgoingWebhookResp(m))
+	}
+	c.Response(resps)
+}
+
+// 添加outgoing webhook
+func (rb *Robot) outgoingWebhookAdd(c *wkhttp.Context) {
+	var req outgoingWebhookReq
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupNo := c.Param("group_no")
+	loginUID := c.GetLoginUID()
+	if err := rb.checkGroupCreator(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	count, err := rb.db.queryOutgoingWebhookCount(groupNo)
+	if err != nil {
+		rb.Error("查询outgoing webhook数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询outgoing webhook数量失败！"))
+		return
+	}
+	if count >= outgoingWebhookMaxCount {
+		c.ResponseError(fmt.Errorf("每个群最多只能添加%d个outgoing webhook！", outgoingWebhookMaxCount))
+		return
+	}
+	m := &outgoingWebhookModel{
+		GroupNo: groupNo,
+		Creator: loginUID,
+		Status:  1,
+	}
+	req.fill(m)
+	if err = rb.db.insertOutgoingWebhook(m); err != nil {
+		rb.Error("添加outgoing webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加outgoing webhook失败！"))
+		return
+	}
+	rb.clearOutgoingWebhookCache(groupNo)
+	c.ResponseOK()
+}
+
+// 修改outgoing webhook
+func (rb *Robot) outgoingWebhookUpdate(c *wkhttp.Context) {
+	var req outgoingWebhookReq
+	if err := c.BindJSON(&req); err != nil {
+		rb.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupNo := c.Param("group_no")
+	if err := rb.checkGroupCreator(groupNo, c.GetLoginUID()); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	m, err := rb.db.queryOutgoingWebhookWithID(groupNo, id)
+	if err != nil {
+		rb.Error("查询outgoing webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询outgoing webhook失败！"))
+		return
+	}
+	if m == nil {
+		c.ResponseError(errors.New("outgoing webhook不存在！"))
+		return
+	}
+	req.fill(m)
+	if err = rb.db.updateOutgoingWebhook(m); err != nil {
+		rb.Error("修改outgoing webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改outgoing webhook失败！"))
+		return
+	}
+	rb.clearOutgoingWebhookCache(groupNo)
+	c.ResponseOK()
+}
+
+// 删除outgoing webhook
+func (rb *Robot) outgoingWebhookDelete(c *wkhttp.Context) {
+	groupNo := c.Param("group_no")
+	if err := rb.checkGroupCreator(groupNo, c.GetLoginUID()); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err := rb.db.deleteOutgoingWebhook(groupNo, id); err != nil {
+		rb.Error("删除outgoing webhook失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除outgoing webhook失败！"))
+		return
+	}
+	rb.clearOutgoingWebhookCache(groupNo)
+	c.ResponseOK()
+}
+
+func (r *outgoingWebhookReq) fill(m *outgoingWebhookModel) {
+	m.Name = r.Name
+	m.URL = r.URL
+	if r.Secret != nil {
+		m.Secret = *r.Secret
+	}
+	m.Keywords = ""
+	if len(r.Keywords) > 0 {
+		m.Keywords = util.ToJson(r.Keywords)
+	}
+	m.Regex = r.Regex
+	m.ReplyOn = r.ReplyOn
+	if r.Status != nil {
+		m.Status = *r.Status
+	}
+}
+
+// hasOutgoingWebhooks 群是否配置了outgoing webhook 结果缓存在redis中 修改时清除
+func (rb *Robot) hasOutgoingWebhooks(groupNo string) (bool, error) {
+	key := outgoingWebhookCachePrefix + groupNo
+	value, err := rb.ctx.GetRedisConn().GetString(key)
+	if err != nil {
+		return false, err
+	}
+	if value != "" {
+		return value == "1", nil
+	}
+	count, err := rb.db.queryOutgoingWebhookCount(groupNo)
+	if err != nil {
+		return false, err
+	}
+	value = "0"
+	if count > 0 {
+		value = "1"
+	}
+	if err = rb.ctx.GetRedisConn().SetAndExpire(key, value, time.Hour); err != nil {
+		rb.Warn("缓存群的outgoing webhook失败！", zap.Error(err))
+	}
+	return count > 0, nil
+}
+
+func (rb *Robot) clearOutgoingWebhookCache(groupNo string) {
+	if err := rb.ctx.GetRedisConn().Del(outgoingWebhookCachePrefix + groupNo); err != nil {
+		rb.Warn("清除群的outgoing webhook缓存失败！", zap.Error(err))
+	}
+}
+
+// outgoingRegexp 编译后的正则表达式 按表达式缓存
+func (rb *Robot) outgoingRegexp(expr string) *regexp.Regexp {
+	if expr == "" {
+		return nil
+	}
+	rb.outgoingRegexpsLock.Lock()
+	defer rb.outgoingRegexpsLock.Unlock()
+	if re, ok := rb.outgoingRegexps[expr]; ok {
+		return re
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		rb.Warn("outgoing webhook的正则表达式有误！", zap.Error(err), zap.String("regex", expr))
+		return nil
+	}
+	if len(rb.outgoingRegexps) >= outgoingRegexpCacheSize {
+		rb.outgoingRegexps = map[string]*regexp.Regexp{}
+	}
+	rb.outgoingRegexps[expr] = re
+	return re
+}
+
+// outgoingWebhookListen 群内的文本消息匹配outgoing webhook时推送 系统和机器人发送的消息不推送 避免循环触发
+func (rb *Robot) outgoingWebhookListen(messages []*config.MessageResp) {
+	for _, message := range messages {
+		if message.ChannelType != common.ChannelTypeGroup.Uint8() || message.FromUID == rb.ctx.GetConfig().Account.SystemUID {
+			continue
+		}
+		payloadValue := gjson.ParseBytes(message.Payload)
+		if common.ContentType(payloadValue.Get("type").Int()) != common.Text {
+			continue
+		}
+		content := payloadValue.Get("content").String()
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		has, err := rb.hasOutgoingWebhooks(message.ChannelID)
+		if err != nil {
+			rb.Error("查询群的outgoing webhook失败！", zap.Error(err))
+			continue
+		}
+		if !has {
+			continue
+		}
+		isRobot, err := rb.existRobot(message.FromUID)
+		if err != nil {
+			rb.Error("查询有效robotID失败！", zap.Error(err))
+			continue
+		}
+		if isRobot {
+			continue
+		}
+		webhooks, err := rb.db.queryOutgoingWebhooksWithGroupNo(message.ChannelID)
+		if err != nil {
+			rb.Error("查询群的outgoing webhook失败！", zap.Error(err))
+			continue
+		}
+		for _, webhook := range webhooks {
+			if webhook.Status != 1 {
+				continue
+			}
+			triggerWord, ok := matchOutgoingWebhook(outgoingKeywords(webhook.Keywords), rb.outgoingRegexp(webhook.Regex), content)
+			if !ok {
+				continue
+			}
+			select {
+			case rb.outgoingPushSem <- struct{}{}:
+			default:
+				rb.Warn("同时推送的outgoing webhook过多，丢弃本次推送！", zap.Int64("id", webhook.Id), zap.String("groupNo", webhook.GroupNo))
+				continue
+			}
+			go rb.pushOutgoingWebhook(webhook, &outgoingWebhookPayload{
+				WebhookID:   webhook.Id,
+				GroupNo:     message.ChannelID,
+				MessageID:   message.MessageID,
+				MessageSeq:  message.MessageSeq,
+				FromUID:     message.FromUID,
+				Content:     content,
+				TriggerWord: triggerWord,
+				Timestamp:   message.Timestamp,
+			})
+		}
+	}
+}
+
+// pushOutgoingWebhook 推送消息到outgoing webhook 开启reply_on时把返回的text发送到群内
+func (rb *Robot) pushOutgoingWebhook(webhook *outgoingWebhookModel, payload *outgoingWebhookPayload) {
+	defer func() { <-rb.outgoingPushSem }()
+	text, err := postOutgoingWebhook(rb.webhookClient, webhook, []byte(util.ToJson(payload)))
+	if err != nil {
+		rb.Warn("推送消息到outgoing webhook失败！", zap.Error(err), zap.Int64("id", webhook.Id), zap.String("groupNo", webhook.GroupNo), zap.String("url", webhook.URL))
+		return
+	}
+	if webhook.ReplyOn != 1 || strings.TrimSpace(text) == "" {
+		return
+	}
+	if utf8.RuneCountInString(text) > outgoingReplyMaxLen {
+		text = string([]rune(text)[:outgoingReplyMaxLen])
+	}
+	err = rb.ctx.SendMessage(&config.MsgSendReq{
+		FromUID:     rb.ctx.GetConfig().Account.SystemUID,
+		ChannelID:   webhook.GroupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": text,
+			"type":    common.Text,
+		})),
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+	})
+	if err != nil {
+		rb.Error("发送outgoing webhook的返回内容失败！", zap.Error(err), zap.Int64("id", webhook.Id), zap.String("groupNo", webhook.GroupNo))
+	}
+}
+
+// postOutgoingWebhook 推送到outgoing webhook 返回内容为json时读取其中的text
+func postOutgoingWebhook(client *http.Client, webhook *outgoingWebhookModel, body []byte) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.Secret != "" {
+		signWebhookRequest(req, webhook.Secret, body)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, outgoingResponseMaxSize))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("outgoing webhook返回的状态码为%d", resp.StatusCode)
+	}
+	if !gjson.ValidBytes(respBody) {
+		return "", nil
+	}
+	return gjson.GetBytes(respBody, "text").String(), nil
+}
@@ -0,0 +1,92 @@
+package robot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchOutgoingWebhook(t *testing.T) {
+	word, ok := matchOutgoingWebhook([]string{"deploy", "发布"}, nil, "请帮忙 Deploy 一下")
+	assert.True(t, ok)
+	assert.Equal(t, "deploy", word)
+
+	word, ok = matchOutgoingWebhook([]string{"发布"}, nil, "今天发布新版本")
+	assert.True(t, ok)
+	assert.Equal(t, "发布", word)
+
+	re := regexp.MustCompile(`#\d+`)
+	word, ok = matchOutgoingWebhook(nil, re, "看一下工单 #123")
+	assert.True(t, ok)
+	assert.Equal(t, "#123", word)
+
+	_, ok = matchOutgoingWebhook([]string{"deploy"}, re, "没有匹配的内容")
+	assert.False(t, ok)
+}
+
+func TestOutgoingWebhookReqCheck(t *testing.T) {
+	req := &outgoingWebhookReq{Name: "ci", URL: "https://ci.example.com/hook", Keywords: []string{"deploy"}}
+	assert.NoError(t, req.check())
+
+	req.Keywords = nil
+	assert.Error(t, req.check())
+
+	req.Regex = `(`
+	assert.Error(t, req.check())
+
+	req.Regex = `^/ci\s+`
+	assert.NoError(t, req.check())
+
+	status := 2
+	req.Status = &status
+	assert.Error(t, req.check())
+}
+
+func TestPostOutgoingWebhook(t *testing.T) {
+	var gotBody, gotTimestamp, gotSignature string
+	respBody := `{"text":"ok"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTimestamp = r.Header.Get(webhookTimestampHeader)
+		gotSignature = r.Header.Get(webhookSignatureHeader)
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		_, _ = w.Write([]byte(respBody))
+	}))
+	defer server.Close()
+	client := &http.Client{Timeout: time.Second}
+
+	webhook := &outgoingWebhookModel{URL: server.URL, Secret: "s1"}
+	text, err := postOutgoingWebhook(client, webhook, []byte(`{"content":"deploy"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", text)
+	assert.Equal(t, `{"content":"deploy"}`, gotBody)
+	timestamp, _ := strconv.ParseInt(gotTimestamp, 10, 64)
+	assert.Equal(t, webhookSignature("s1", timestamp, []byte(`{"content":"deploy"}`)), gotSignature)
+
+	// 没有设置secret时不签名 返回的不是json时不回复
+	webhook.Secret = ""
+	respBody = "ok"
+	text, err = postOutgoingWebhook(client, webhook, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "", text)
+	assert.Equal(t, "", gotSignature)
+}
+
+func TestOutgoingRegexpCache(t *testing.T) {
+	rb := &Robot{Log: log.NewTLog("Robot"), outgoingRegexps: map[string]*regexp.Regexp{}}
+	re := rb.outgoingRegexp(`^/ci\s+`)
+	assert.NotNil(t, re)
+	assert.Same(t, re, rb.outgoingRegexp(`^/ci\s+`))
+	assert.Nil(t, rb.outgoingRegexp(`(`))
+	for i := 0; i < outgoingRegexpCacheSize+10; i++ {
+		rb.outgoingRegexp(`^/ci` + strconv.Itoa(i))
+	}
+	assert.LessOrEqual(t, len(rb.outgoingRegexps), outgoingRegexpCacheSize)
+}
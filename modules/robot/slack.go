@@ -0,0 +1,159 @@
+package robot
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// slackLinkRegexp Slack消息中的链接和提及 例如 <https://x.com|标题>、<!here>、<@U123>
+var slackLinkRegexp = regexp.MustCompile(`<([^<>|]+)(?:\|([^<>]*))?>`)
+
+// slackMessage 兼容Slack incoming webhook的消息格式
+type slackMessage struct {
+	Text        string             `json:"text"`
+	Username    string             `json:"username"`
+	IconURL     string             `json:"icon_url"`
+	Blocks      []*slackBlock      `json:"blocks"`
+	Attachments []*slackAttachment `json:"attachments"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type     string       `json:"type"`
+	Text     *slackText   `json:"text"`
+	Fields   []*slackText `json:"fields"`
+	Elements []*slackText `json:"elements"`
+}
+
+type slackAttachment struct {
+	Fallback   string        `json:"fallback"`
+	Color      string        `json:"color"`
+	Pretext    string        `json:"pretext"`
+	AuthorName string        `json:"author_name"`
+	Title      string        `json:"title"`
+	TitleLink  string        `json:"title_link"`
+	Text       string        `json:"text"`
+	Fields     []*slackField `json:"fields"`
+	Footer     string        `json:"footer"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// toText 转换为纯文本 有blocks时text只作为通知的预览（与Slack一致）
+func (m *slackMessage) toText() string {
+	lines := make([]string, 0)
+	if len(m.Blocks) > 0 {
+		for _, block := range m.Blocks {
+			lines = appendSlackLines(lines, block.text())
+		}
+	}
+	if len(lines) == 0 {
+		lines = appendSlackLines(lines, m.Text)
+	}
+	for _, attachment := range m.Attachments {
+		if attachment != nil {
+			lines = appendSlackLines(lines, attachment.text())
+		}
+	}
+	return formatSlackText(strings.Join(lines, "\n"))
+}
+
+func (b *slackBlock) text() string {
+	if b == nil {
+		return ""
+	}
+	switch b.Type {
+	case "header", "section":
+		lines := make([]string, 0)
+		if b.Text != nil {
+			lines = appendSlackLines(lines, b.Text.Text)
+		}
+		for _, field := range b.Fields {
+			if field != nil {
+				lines = appendSlackLines(lines, field.Text)
+			}
+		}
+		return strings.Join(lines, "\n")
+	case "context":
+		texts := make([]string, 0, len(b.Elements))
+		for _, element := range b.Elements {
+			// 图片等元素没有text
+			if element != nil && element.Text != "" {
+				texts = append(texts, element.Text)
+			}
+		}
+		return strings.Join(texts, " ")
+	case "divider":
+		return "----------"
+	}
+	return ""
+}
+
+func (a *slackAttachment) text() string {
+	lines := make([]string, 0)
+	lines = appendSlackLines(lines, a.Pretext)
+	lines = appendSlackLines(lines, a.AuthorName)
+	if a.Title != "" && a.TitleLink != "" {
+		lines = append(lines, fmt.Sprintf("%s (%s)", a.Title, a.TitleLink))
+	} else {
+		lines = appendSlackLines(lines, a.Title)
+	}
+	lines = appendSlackLines(lines, a.Text)
+	for _, field := range a.Fields {
+		if field == nil {
+			continue
+		}
+		if field.Title != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s", field.Title, field.Value))
+		} else {
+			lines = appendSlackLines(lines, field.Value)
+		}
+	}
+	lines = appendSlackLines(lines, a.Footer)
+	if len(lines) == 0 {
+		lines = appendSlackLines(lines, a.Fallback)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func appendSlackLines(lines []string, text string) []string {
+	if strings.TrimSpace(text) == "" {
+		return lines
+	}
+	return append(lines, text)
+}
+
+// formatSlackText 转换Slack的链接和提及格式并还原转义的字符
+func formatSlackText(text string) string {
+	text = slackLinkRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		match := slackLinkRegexp.FindStringSubmatch(s)
+		target, label := match[1], match[2]
+		switch {
+		case strings.HasPrefix(target, "!"):
+			// <!here> <!channel> <!subteam^ID|@team>
+			if label != "" {
+				return label
+			}
+			return "@" + strings.TrimPrefix(target, "!")
+		case strings.HasPrefix(target, "@") || strings.HasPrefix(target, "#"):
+			if label != "" {
+				return target[:1] + strings.TrimPrefix(label, target[:1])
+			}
+			return target
+		case label != "":
+			return fmt.Sprintf("%s (%s)", label, target)
+		}
+		return target
+	})
+	return strings.TrimSpace(html.UnescapeString(text))
+}
@@ -0,0 +1,54 @@
+package robot
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlackMessageToText(t *testing.T) {
+	var msg *slackMessage
+	err := util.ReadJsonByByte([]byte(`{"text":"构建 <https://ci.example.com/1|#1> 失败 <!here> &lt;main&gt;"}`), &msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "构建 #1 (https://ci.example.com/1) 失败 @here <main>", msg.toText())
+
+	// 有blocks时不使用text
+	msg = nil
+	err = util.ReadJsonByByte([]byte(`{
+		"text":"预览",
+		"blocks":[
+			{"type":"header","text":{"type":"plain_text","text":"告警"}},
+			{"type":"section","text":{"type":"mrkdwn","text":"*CPU* 使用率过高"},"fields":[{"type":"mrkdwn","text":"主机: web-1"}]},
+			{"type":"divider"},
+			{"type":"context","elements":[{"type":"image","image_url":"https://x.com/a.png"},{"type":"mrkdwn","text":"来自 <@U123>"}]}
+		]
+	}`), &msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "告警\n*CPU* 使用率过高\n主机: web-1\n----------\n来自 @U123", msg.toText())
+
+	// Grafana等使用attachments
+	msg = nil
+	err = util.ReadJsonByByte([]byte(`{
+		"attachments":[{
+			"color":"#D63232",
+			"title":"[Alerting] 磁盘空间不足",
+			"title_link":"https://grafana.example.com/d/1",
+			"text":"剩余 5%",
+			"fields":[{"title":"host","value":"db-1","short":true}],
+			"footer":"Grafana"
+		},{"fallback":"只有fallback"}]
+	}`), &msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "[Alerting] 磁盘空间不足 (https://grafana.example.com/d/1)\n剩余 5%\nhost: db-1\nGrafana\n只有fallback", msg.toText())
+
+	msg = &slackMessage{Text: "  "}
+	assert.Equal(t, "", msg.toText())
+}
+
+func TestIncomingWebhookReqCheck(t *testing.T) {
+	assert.NoError(t, (&incomingWebhookReq{Name: "CI"}).check())
+	assert.Error(t, (&incomingWebhookReq{Name: " "}).check())
+	status := 3
+	assert.Error(t, (&incomingWebhookReq{Name: "CI", Status: &status}).check())
+}
@@ -0,0 +1,6 @@
+-- +migrate Up
+
+ALTER TABLE `robot` ADD COLUMN webhook_url VARCHAR(255) not null DEFAULT '' comment '接收消息的webhook地址，为空时通过events拉取';
+ALTER TABLE `robot` ADD COLUMN webhook_secret VARCHAR(100) not null DEFAULT '' comment '推送webhook时放在请求头中的密钥';
+
+CREATE INDEX `robot_token_index` on `robot` (`token`);
@@ -0,0 +1,21 @@
+-- +migrate Up
+
+-- 机器人webhook的推送记录 推送失败的记录按退避时间重试 超过次数后进入死信（status=2）
+create table `robot_webhook_delivery`
+(
+  id            bigint         not null primary key AUTO_INCREMENT,
+  robot_id      VARCHAR(40)    not null default '',  -- 机器人ID
+  event_id      bigint         not null default 0,   -- 事件ID
+  url           VARCHAR(255)   not null default '',  -- 推送地址
+  body          text,                                -- 推送内容
+  status        smallint       not null default 0,   -- 0.等待重试 1.成功 2.失败（死信）
+  attempts      integer        not null default 0,   -- 已推送次数
+  status_code   integer        not null default 0,   -- 最近一次推送返回的状态码
+  error         VARCHAR(255)   not null default '',  -- 最近一次推送失败的原因
+  duration      integer        not null default 0,   -- 最近一次推送的耗时（毫秒）
+  next_retry_at bigint         not null default 0,   -- 下次重试的时间（秒级时间戳）
+  created_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE INDEX `robot_webhook_delivery_robot_idx` on `robot_webhook_delivery` (`robot_id`, `created_at`);
+CREATE INDEX `robot_webhook_delivery_retry_idx` on `robot_webhook_delivery` (`status`, `next_retry_at`);
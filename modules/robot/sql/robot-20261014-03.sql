@@ -0,0 +1,19 @@
+-- +migrate Up
+
+-- 群的outgoing webhook 群内消息匹配关键词或正则时推送到外部地址
+create table `robot_outgoing_webhook`
+(
+  id            bigint         not null primary key AUTO_INCREMENT,
+  group_no      VARCHAR(40)    not null default '',  -- 群编号
+  name          VARCHAR(40)    not null default '',  -- 名称
+  url           VARCHAR(255)   not null default '',  -- 推送地址
+  secret        VARCHAR(100)   not null default '',  -- 签名密钥
+  keywords      VARCHAR(1000)  not null default '',  -- 触发的关键词 json数组
+  regex         VARCHAR(255)   not null default '',  -- 触发的正则表达式
+  reply_on      smallint       not null default 0,   -- 是否把返回的内容发送到群内
+  status        smallint       not null default 1,   -- 0.禁用 1.启用
+  creator       VARCHAR(40)    not null default '',  -- 创建者uid
+  created_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE INDEX `robot_outgoing_webhook_group_idx` on `robot_outgoing_webhook` (`group_no`);
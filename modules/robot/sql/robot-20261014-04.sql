@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- 群的incoming webhook 通过token向群内发送消息（兼容Slack的消息格式）
+create table `robot_incoming_webhook`
+(
+  id            bigint         not null primary key AUTO_INCREMENT,
+  group_no      VARCHAR(40)    not null default '',  -- 群编号
+  name          VARCHAR(40)    not null default '',  -- 名称 消息中显示的发送者
+  token         VARCHAR(40)    not null default '',  -- 调用的token
+  status        smallint       not null default 1,   -- 0.禁用 1.启用
+  creator       VARCHAR(40)    not null default '',  -- 创建者uid
+  created_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `robot_incoming_webhook_token_idx` on `robot_incoming_webhook` (`token`);
+CREATE INDEX `robot_incoming_webhook_group_idx` on `robot_incoming_webhook` (`group_no`);
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+ALTER TABLE `robot` ADD COLUMN file_max_size bigint not null DEFAULT 0 comment '机器人上传文件的最大大小（MB），0为使用默认配置';
+ALTER TABLE `robot` ADD COLUMN file_mime_types VARCHAR(255) not null DEFAULT '' comment '机器人允许上传的文件类型，多个用逗号分隔，为空时使用默认配置';
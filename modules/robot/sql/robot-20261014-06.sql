@@ -0,0 +1,44 @@
+-- +migrate Up
+
+-- 机器人每日统计
+create table `robot_stat_daily`
+(
+  id                bigint         not null primary key AUTO_INCREMENT,
+  robot_id          VARCHAR(40)    not null default '',  -- 机器人ID
+  stat_date         VARCHAR(10)    not null default '',  -- 日期 例如 2026-10-14
+  message_sent      bigint         not null default 0,   -- 机器人发送的消息数
+  message_received  bigint         not null default 0,   -- 机器人收到的消息数
+  command_count     bigint         not null default 0,   -- 收到的命令数
+  webhook_success   bigint         not null default 0,   -- webhook推送成功次数
+  webhook_failed    bigint         not null default 0,   -- webhook推送失败次数（含重试）
+  created_at        timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at        timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `robot_stat_daily_robot_date_idx` on `robot_stat_daily` (`robot_id`, `stat_date`);
+
+-- 机器人每日活跃的频道 每个频道一条记录
+create table `robot_stat_chat_daily`
+(
+  id                bigint         not null primary key AUTO_INCREMENT,
+  robot_id          VARCHAR(40)    not null default '',  -- 机器人ID
+  stat_date         VARCHAR(10)    not null default '',  -- 日期
+  channel_id        VARCHAR(100)   not null default '',  -- 频道ID 个人频道为对方的uid
+  channel_type      smallint       not null default 0,   -- 频道类型
+  message_count     bigint         not null default 0,   -- 消息数（收发）
+  created_at        timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at        timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `robot_stat_chat_daily_idx` on `robot_stat_chat_daily` (`robot_id`, `stat_date`, `channel_id`, `channel_type`);
+
+-- 机器人每日命令的使用次数
+create table `robot_stat_command_daily`
+(
+  id                bigint         not null primary key AUTO_INCREMENT,
+  robot_id          VARCHAR(40)    not null default '',  -- 机器人ID
+  stat_date         VARCHAR(10)    not null default '',  -- 日期
+  command           VARCHAR(50)    not null default '',  -- 命令 例如 /start
+  count             bigint         not null default 0,   -- 使用次数
+  created_at        timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at        timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `robot_stat_command_daily_idx` on `robot_stat_command_daily` (`robot_id`, `stat_date`, `command`);
@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- 新用户引导 注册后由系统账号按顺序发送 用户点击按钮时发送触发的步骤
+create table `robot_onboarding_step`
+(
+  id            bigint         not null primary key AUTO_INCREMENT,
+  trigger_data  VARCHAR(64)    not null default '',  -- 触发的按钮callback_data 为空时注册后发送
+  sort_num      integer        not null default 0,   -- 发送顺序 从小到大
+  delay         integer        not null default 0,   -- 发送前等待的秒数
+  payload       text,                                -- 消息正文 与机器人sendMessage的payload相同 可带按钮
+  remark        VARCHAR(100)   not null default '',  -- 备注
+  status        smallint       not null default 1,   -- 0.禁用 1.启用
+  created_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE INDEX `robot_onboarding_step_trigger_idx` on `robot_onboarding_step` (`trigger_data`);
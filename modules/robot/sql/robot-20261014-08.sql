@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- 群授权给机器人的管理权限 由群主或管理员设置
+create table `robot_group_permission`
+(
+  id              bigint         not null primary key AUTO_INCREMENT,
+  group_no        VARCHAR(40)    not null default '',  -- 群编号
+  robot_id        VARCHAR(40)    not null default '',  -- 机器人ID
+  delete_message  smallint       not null default 0,   -- 是否可以撤回成员的消息
+  mute_member     smallint       not null default 0,   -- 是否可以禁言成员
+  kick_member     smallint       not null default 0,   -- 是否可以移除成员
+  operator        VARCHAR(40)    not null default '',  -- 最后设置的用户uid
+  created_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `robot_group_permission_idx` on `robot_group_permission` (`group_no`, `robot_id`);
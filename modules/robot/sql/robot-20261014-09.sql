@@ -0,0 +1,18 @@
+-- +migrate Up
+
+-- 机器人目录 后台维护 用户可以发现并添加官方机器人
+create table `robot_directory`
+(
+  id            bigint         not null primary key AUTO_INCREMENT,
+  robot_id      VARCHAR(40)    not null default '',  -- 机器人ID
+  name          VARCHAR(40)    not null default '',  -- 显示的名称
+  description   VARCHAR(500)   not null default '',  -- 介绍
+  category      VARCHAR(40)    not null default '',  -- 分类
+  screenshots   text,                                -- 截图地址 json数组
+  sort_num      integer        not null default 0,   -- 排序 越大越靠前
+  status        smallint       not null default 1,   -- 0.隐藏 1.展示
+  created_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `robot_directory_robot_id_idx` on `robot_directory` (`robot_id`);
+CREATE INDEX `robot_directory_category_idx` on `robot_directory` (`category`);
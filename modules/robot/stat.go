@@ -0,0 +1,278 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/tidwall/gjson"
+	"go.uber.org/zap"
+)
+
+const (
+	// statDateLayout 统计的日期格式
+	statDateLayout = "2006-01-02"
+	// statMaxDays 一次最多查询的天数
+	statMaxDays = 90
+	// statDefaultDays 默认查询最近7天
+	statDefaultDays = 7
+	// statCommandMaxLen 命令的最大字符数 超过的不统计
+	statCommandMaxLen = 50
+	// statTopCommandCount 返回使用次数最多的命令数
+	statTopCommandCount = 20
+)
+
+// robot_stat_daily的计数列
+const (
+	statFieldMessageSent     = "message_sent"
+	statFieldMessageReceived = "message_received"
+	statFieldCommandCount    = "command_count"
+	statFieldWebhookSuccess  = "webhook_success"
+	statFieldWebhookFailed   = "webhook_failed"
+)
+
+func statToday() string {
+	return time.Now().Format(statDateLayout)
+}
+
+// incrStat 累加机器人当天的计数 失败只记录日志
+func (rb *Robot) incrStat(robotID string, field string) {
+	if err := rb.db.incrRobotStat(robotID, statToday(), field, 1); err != nil {
+		rb.Warn("累加机器人统计失败！", zap.Error(err), zap.String("robotID", robotID), zap.String("field", field))
+	}
+}
+
+func (rb *Robot) incrChatStat(robotID string, channelID string, channelType uint8) {
+	if err := rb.db.incrRobotChatStat(robotID, statToday(), channelID, channelType); err != nil {
+		rb.Warn("累加机器人频道统计失败！", zap.Error(err), zap.String("robotID", robotID), zap.String("channelID", channelID))
+	}
+}
+
+// statMessageSent 统计机器人发送的消息
+func (rb *Robot) statMessageSent(robotID string, channelID string, channelType uint8) {
+	rb.incrStat(robotID, statFieldMessageSent)
+	rb.incrChatStat(robotID, channelID, channelType)
+}
+
+// statMessageReceived 统计机器人收到的消息和命令 个人频道以发送者作为频道
+func (rb *Robot) statMessageReceived(robotID string, message *config.MessageResp) {
+	rb.incrStat(robotID, statFieldMessageReceived)
+	channelID := message.ChannelID
+	if message.ChannelType == common.ChannelTypePerson.Uint8() {
+		channelID = message.FromUID
+	}
+	rb.incrChatStat(robotID, channelID, message.ChannelType)
+
+	command := commandFromPayload(message.Payload)
+	if command == "" {
+		return
+	}
+	rb.incrStat(robotID, statFieldCommandCount)
+	if err := rb.db.incrRobotCommandStat(robotID, statToday(), command); err != nil {
+		rb.Warn("累加机器人命令统计失败！", zap.Error(err), zap.String("robotID", robotID), zap.String("command", command))
+	}
+}
+
+// statWebhook 统计webhook的每次推送结果
+func (rb *Robot) statWebhook(robotID string, success bool) {
+	if success {
+		rb.incrStat(robotID, statFieldWebhookSuccess)
+	} else {
+		rb.incrStat(robotID, statFieldWebhookFailed)
+	}
+}
+
+// commandFromPayload 从文本消息中解析命令 例如 "@bot /start@bot arg" 解析为 /start
+func commandFromPayload(payload []byte) string {
+	payloadValue := gjson.ParseBytes(payload)
+	if common.ContentType(payloadValue.Get("type").Int()) != common.Text {
+		return ""
+	}
+	for _, word := range strings.Fields(payloadValue.Get("content").String()) {
+		if strings.HasPrefix(word, "@") {
+			continue
+		}
+		if !strings.HasPrefix(word, "/") || len(word) == 1 {
+			return ""
+		}
+		if idx := strings.Index(word, "@"); idx > 0 {
+			word = word[:idx]
+		}
+		if utf8.RuneCountInString(word) > statCommandMaxLen {
+			return ""
+		}
+		return strings.ToLower(word)
+	}
+	return ""
+}
+
+// cleanStats 删除过期的统计
+func (rb *Robot) cleanStats() {
+	before := time.Now().Add(-extconfig.Get().Bot.StatExpire).Format(statDateLayout)
+	if err := rb.db.deleteRobotStatsBefore(before); err != nil {
+		rb.Error("删除过期的机器人统计失败！", zap.Error(err))
+	}
+}
+
+// parseStatDateRange 解析查询的日期范围 默认最近7天
+func parseStatDateRange(startDate, endDate string, now time.Time) (time.Time, time.Time, error) {
+	end, err := time.ParseInLocation(statDateLayout, now.Format(statDateLayout), now.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if endDate != "" {
+		if end, err = time.ParseInLocation(statDateLayout, endDate, now.Location()); err != nil {
+			return time.Time{}, time.Time{}, errors.New("end_date格式有误！")
+		}
+	}
+	start := end.AddDate(0, 0, -(statDefaultDays - 1))
+	if startDate != "" {
+		if start, err = time.ParseInLocation(statDateLayout, startDate, now.Location()); err != nil {
+			return time.Time{}, time.Time{}, errors.New("start_date格式有误！")
+		}
+	}
+	if start.After(end) {
+		return time.Time{}, time.Time{}, errors.New("start_date不能大于end_date！")
+	}
+	if start.AddDate(0, 0, statMaxDays).Before(end.AddDate(0, 0, 1)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("最多只能查询%d天的统计！", statMaxDays)
+	}
+	return start, end, nil
+}
+
+// webhookFailureRate webhook推送的失败率 保留4位小数
+func webhookFailureRate(success, failed int64) float64 {
+	if success+failed == 0 {
+		return 0
+	}
+	return math.Round(float64(failed)/float64(success+failed)*10000) / 10000
+}
+
+type robotStatItem struct {
+	MessageSent        int64   `json:"message_sent"`         // 发送的消息数
+	MessageReceived    int64   `json:"message_received"`     // 收到的消息数
+	ActiveChats        int64   `json:"active_chats"`         // 活跃的频道数
+	CommandCount       int64   `json:"command_count"`        // 收到的命令数
+	WebhookSuccess     int64   `json:"webhook_success"`      // webhook推送成功次数
+	WebhookFailed      int64   `json:"webhook_failed"`       // webhook推送失败次数
+	WebhookFailureRate float64 `json:"webhook_failure_rate"` // webhook推送失败率
+}
+
+func (s *robotStatItem) add(m *robotStatModel) {
+	s.MessageSent += m.MessageSent
+	s.MessageReceived += m.MessageReceived
+	s.CommandCount += m.CommandCount
+	s.WebhookSuccess += m.WebhookSuccess
+	s.WebhookFailed += m.WebhookFailed
+	s.WebhookFailureRate = webhookFailureRate(s.WebhookSuccess, s.WebhookFailed)
+}
+
+type robotDayStat struct {
+	Date string `json:"date"`
+	robotStatItem
+}
+
+type robotCommandStat struct {
+	Command string `json:"command"`
+	Count   int64  `json:"count"`
+}
+
+type robotStatsResp struct {
+	RobotID   string              `json:"robot_id"`
+	StartDate string              `json:"start_date"`
+	EndDate   string              `json:"end_date"`
+	Total     *robotStatItem      `json:"total"`    // 日期范围内的合计 active_chats为去重后的频道数
+	Days      []*robotDayStat     `json:"days"`     // 每日统计 没有数据的日期为0
+	Commands  []*robotCommandStat `json:"commands"` // 使用次数最多的命令
+}
+
+// newRobotDayStats 按日期生成每日统计 没有数据的日期补0
+func newRobotDayStats(start, end time.Time, stats []*robotStatModel, activeChats []*robotDateCountModel) []*robotDayStat {
+	statMap := make(map[string]*robotStatModel, len(stats))
+	for _, stat := range stats {
+		statMap[stat.StatDate] = stat
+	}
+	chatMap := make(map[string]int64, len(activeChats))
+	for _, chat := range activeChats {
+		chatMap[chat.StatDate] = chat.Count
+	}
+	days := make([]*robotDayStat, 0)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(statDateLayout)
+		dayStat := &robotDayStat{Date: date}
+		if stat := statMap[date]; stat != nil {
+			dayStat.add(stat)
+		}
+		dayStat.ActiveChats = chatMap[date]
+		days = append(days, dayStat)
+	}
+	return days
+}
+
+// queryRobotStatsResp 查询机器人的统计 给机器人开发者和后台使用
+func queryRobotStatsResp(d *robotDB, l log.Log, robotID string, start, end time.Time) (*robotStatsResp, error) {
+	startDate, endDate := start.Format(statDateLayout), end.Format(statDateLayout)
+	stats, err := d.queryRobotStats(robotID, startDate, endDate)
+	if err != nil {
+		l.Error("查询机器人统计失败！", zap.Error(err), zap.String("robotID", robotID))
+		return nil, errors.New("查询机器人统计失败！")
+	}
+	activeChats, err := d.queryRobotActiveChats(robotID, startDate, endDate)
+	if err != nil {
+		l.Error("查询机器人活跃频道数失败！", zap.Error(err), zap.String("robotID", robotID))
+		return nil, errors.New("查询机器人活跃频道数失败！")
+	}
+	activeChatTotal, err := d.queryRobotActiveChatTotal(robotID, startDate, endDate)
+	if err != nil {
+		l.Error("查询机器人活跃频道数失败！", zap.Error(err), zap.String("robotID", robotID))
+		return nil, errors.New("查询机器人活跃频道数失败！")
+	}
+	commands, err := d.queryRobotCommandStats(robotID, startDate, endDate, statTopCommandCount)
+	if err != nil {
+		l.Error("查询机器人命令统计失败！", zap.Error(err), zap.String("robotID", robotID))
+		return nil, errors.New("查询机器人命令统计失败！")
+	}
+	total := &robotStatItem{}
+	for _, stat := range stats {
+		total.add(stat)
+	}
+	total.ActiveChats = activeChatTotal
+	commandResps := make([]*robotCommandStat, 0, len(commands))
+	for _, command := range commands {
+		commandResps = append(commandResps, &robotCommandStat{
+			Command: command.Command,
+			Count:   command.Count,
+		})
+	}
+	return &robotStatsResp{
+		RobotID:   robotID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Total:     total,
+		Days:      newRobotDayStats(start, end, stats, activeChats),
+		Commands:  commandResps,
+	}, nil
+}
+
+// 机器人的统计
+func (rb *Robot) botGetStats(c *wkhttp.Context) {
+	start, end, err := parseStatDateRange(c.Query("start_date"), c.Query("end_date"), time.Now())
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	resp, err := queryRobotStatsResp(&rb.db, rb.Log, botFromContext(c).RobotID, start, end)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(resp)
+}
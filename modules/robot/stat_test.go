@@ -0,0 +1,58 @@
+package robot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandFromPayload(t *testing.T) {
+	assert.Equal(t, "/start", commandFromPayload([]byte(`{"type":1,"content":"/start"}`)))
+	assert.Equal(t, "/help", commandFromPayload([]byte(`{"type":1,"content":"@bot /Help@bot arg"}`)))
+	assert.Equal(t, "", commandFromPayload([]byte(`{"type":1,"content":"hello /start"}`)))
+	assert.Equal(t, "", commandFromPayload([]byte(`{"type":1,"content":"/"}`)))
+	assert.Equal(t, "", commandFromPayload([]byte(`{"type":2,"url":"/start"}`)))
+}
+
+func TestParseStatDateRange(t *testing.T) {
+	now := time.Date(2026, 10, 14, 15, 0, 0, 0, time.Local)
+
+	start, end, err := parseStatDateRange("", "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-10-08", start.Format(statDateLayout))
+	assert.Equal(t, "2026-10-14", end.Format(statDateLayout))
+
+	start, end, err = parseStatDateRange("2026-07-17", "2026-10-14", now)
+	assert.NoError(t, err)
+	assert.Equal(t, 90, len(newRobotDayStats(start, end, nil, nil)))
+
+	_, _, err = parseStatDateRange("2026-07-16", "2026-10-14", now)
+	assert.Error(t, err)
+	_, _, err = parseStatDateRange("2026-10-15", "2026-10-14", now)
+	assert.Error(t, err)
+	_, _, err = parseStatDateRange("20261001", "", now)
+	assert.Error(t, err)
+}
+
+func TestNewRobotDayStats(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2026, 10, 3, 0, 0, 0, 0, time.Local)
+	days := newRobotDayStats(start, end, []*robotStatModel{
+		{StatDate: "2026-10-02", MessageSent: 5, WebhookSuccess: 3, WebhookFailed: 1},
+	}, []*robotDateCountModel{
+		{StatDate: "2026-10-02", Count: 2},
+	})
+	assert.Equal(t, 3, len(days))
+	assert.Equal(t, "2026-10-01", days[0].Date)
+	assert.Equal(t, int64(0), days[0].MessageSent)
+	assert.Equal(t, int64(5), days[1].MessageSent)
+	assert.Equal(t, int64(2), days[1].ActiveChats)
+	assert.Equal(t, 0.25, days[1].WebhookFailureRate)
+}
+
+func TestWebhookFailureRate(t *testing.T) {
+	assert.Equal(t, float64(0), webhookFailureRate(0, 0))
+	assert.Equal(t, 0.3333, webhookFailureRate(2, 1))
+	assert.Equal(t, float64(1), webhookFailureRate(0, 4))
+}
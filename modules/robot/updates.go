@@ -0,0 +1,107 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// getUpdatesCheckInterval 长轮询时重新查询事件的间隔 其他实例收到的事件通过查询发现
+const getUpdatesCheckInterval = time.Second
+
+// 长轮询获取事件 offset之前的事件视为已确认并删除
+func (rb *Robot) botGetUpdates(c *wkhttp.Context) {
+	robotM := botFromContext(c)
+	if robotM.WebhookURL != "" {
+		c.ResponseError(errors.New("已设置webhook，不能通过getUpdates获取事件！"))
+		return
+	}
+	offset, _ := strconv.ParseInt(c.Query("offset"), 10, 64)
+	limit, _ := strconv.ParseInt(c.Query("limit"), 10, 64)
+	timeoutSecond, _ := strconv.ParseInt(c.Query("timeout"), 10, 64)
+	timeout := time.Duration(timeoutSecond) * time.Second
+	if timeout < 0 {
+		timeout = 0
+	}
+	if maxTimeout := extconfig.Get().Bot.PollTimeout; timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	if offset > 0 {
+		if err := rb.removeEventsBefore(robotM.RobotID, offset); err != nil {
+			rb.Error("确认事件失败！", zap.Error(err), zap.String("robotID", robotM.RobotID))
+			c.ResponseError(errors.New("确认事件失败！"))
+			return
+		}
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(getUpdatesCheckInterval)
+	defer ticker.Stop()
+	for {
+		// 先取通知再查询 避免查询后到达的事件被漏掉
+		notify := rb.updatesNotifier(robotM.RobotID)
+		results, err := rb.getEventsResult(robotM.RobotID, offset-1, limit)
+		if err != nil {
+			rb.Error("获取事件失败！", zap.Error(err), zap.String("robotID", robotM.RobotID))
+			c.ResponseError(errors.New("获取事件失败！"))
+			return
+		}
+		if len(results) > 0 || timeout == 0 {
+			c.Response(results)
+			return
+		}
+		select {
+		case <-notify:
+		case <-ticker.C:
+		case <-deadline.C:
+			c.Response(results)
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// removeEventsBefore 删除小于eventID的事件
+func (rb *Robot) removeEventsBefore(robotID string, eventID int64) error {
+	return rb.ctx.GetRedisConn().ZRemRangeByScore(fmt.Sprintf("%s%s", rb.robotEventPrefix, robotID), "-inf", fmt.Sprintf("(%d", eventID))
+}
+
+// trimEvents 每个机器人最多保存UpdateQueueSize个事件 事件ID按机器人递增 删除最早的
+func (rb *Robot) trimEvents(robotID string, lastEventID int64) {
+	queueSize := int64(extconfig.Get().Bot.UpdateQueueSize)
+	if queueSize <= 0 || lastEventID <= queueSize {
+		return
+	}
+	if err := rb.removeEventsBefore(robotID, lastEventID-queueSize+1); err != nil {
+		rb.Warn("删除机器人多余的事件失败！", zap.Error(err), zap.String("robotID", robotID))
+	}
+}
+
+// updatesNotifier 机器人有新事件时关闭返回的chan
+func (rb *Robot) updatesNotifier(robotID string) chan struct{} {
+	rb.updatesNotifiersLock.Lock()
+	defer rb.updatesNotifiersLock.Unlock()
+	notify := rb.updatesNotifiers[robotID]
+	if notify == nil {
+		notify = make(chan struct{})
+		rb.updatesNotifiers[robotID] = notify
+	}
+	return notify
+}
+
+// notifyUpdates 唤醒等待机器人事件的长轮询
+func (rb *Robot) notifyUpdates(robotID string) {
+	rb.updatesNotifiersLock.Lock()
+	defer rb.updatesNotifiersLock.Unlock()
+	if notify := rb.updatesNotifiers[robotID]; notify != nil {
+		close(notify)
+		delete(rb.updatesNotifiers, robotID)
+	}
+}
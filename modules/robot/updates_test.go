@@ -0,0 +1,33 @@
+package robot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdatesNotifier(t *testing.T) {
+	rb := &Robot{updatesNotifiers: map[string]chan struct{}{}}
+	notify1 := rb.updatesNotifier("bot1")
+	notify2 := rb.updatesNotifier("bot1")
+	other := rb.updatesNotifier("bot2")
+	// 同一个机器人的长轮询共用一个通知
+	assert.Equal(t, notify1, notify2)
+
+	rb.notifyUpdates("bot1")
+	select {
+	case <-notify1:
+	case <-time.After(time.Second):
+		t.Fatal("没有收到新事件的通知")
+	}
+	select {
+	case <-other:
+		t.Fatal("其他机器人不应该收到通知")
+	default:
+	}
+	// 通知后重新等待使用新的chan
+	assert.NotEqual(t, notify1, rb.updatesNotifier("bot1"))
+	// 没有等待者时不报错
+	rb.notifyUpdates("bot3")
+}
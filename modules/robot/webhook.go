@@ -0,0 +1,307 @@
+package robot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+const (
+	// webhookSecretHeader 推送webhook时携带密钥的请求头
+	webhookSecretHeader = "X-Bot-Api-Secret-Token"
+	// webhookTimestampHeader 推送时间（秒级时间戳） 接收方应拒绝时间相差过大的请求
+	webhookTimestampHeader = "X-Bot-Api-Timestamp"
+	// webhookSignatureHeader 签名 hex(HMAC-SHA256(key, timestamp + "." + body))
+	webhookSignatureHeader = "X-Bot-Api-Signature"
+	// webhookEventIDHeader 事件ID 重试时不变 接收方可据此去重
+	webhookEventIDHeader = "X-Bot-Api-Event-Id"
+	// webhookErrorCachePrefix 最近一次推送webhook失败的原因
+	webhookErrorCachePrefix = "botWebhookError:"
+	// webhookURLMaxLen webhook地址的最大长度
+	webhookURLMaxLen = 255
+	// webhookSecretMaxLen webhook密钥的最大长度
+	webhookSecretMaxLen = 100
+	// webhookErrorMaxLen 推送记录中保存的失败原因的最大长度
+	webhookErrorMaxLen = 255
+	// webhookRetryMaxDelay 重试间隔的上限
+	webhookRetryMaxDelay = time.Hour
+	// webhookRetryBatchSize 每次重试的最大记录数
+	webhookRetryBatchSize = 100
+	// webhookRetryLockTime 重试时锁定记录的时间 避免多个实例重复推送
+	webhookRetryLockTime = time.Minute
+)
+
+// errWebhookPrivateAddr webhook解析后的地址为内网地址
+var errWebhookPrivateAddr = errors.New("url不能是内网地址！")
+
+// webhookSharedAddrSpace 运营商级NAT的地址段（100.64.0.0/10）
+var webhookSharedAddrSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+type webhookError struct {
+	Message string `json:"message"`
+	Time    int64  `json:"time"`
+}
+
+// checkWebhookURL webhook地址必须是http或https的完整地址
+func checkWebhookURL(webhookURL string) error {
+	if webhookURL == "" {
+		return errors.New("url不能为空！")
+	}
+	if len(webhookURL) > webhookURLMaxLen {
+		return fmt.Errorf("url不能超过%d个字符！", webhookURLMaxLen)
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url必须是http或https地址！")
+	}
+	if extconfig.Get().Bot.WebhookAllowPrivate {
+		return nil
+	}
+	// 域名解析后的地址在连接时检查 这里只提前拒绝明显的内网地址
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errWebhookPrivateAddr
+	}
+	if ip := net.ParseIP(host); ip != nil && !webhookIPAllowed(ip) {
+		return errWebhookPrivateAddr
+	}
+	return nil
+}
+
+// webhookIPAllowed 是否允许推送到该地址 回环、内网、链路本地、组播和未指定的地址不允许
+func webhookIPAllowed(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && (ip4[0] == 0 || webhookSharedAddrSpace.Contains(ip4)) {
+		return false
+	}
+	return true
+}
+
+// newWebhookClient 推送webhook的http客户端 allowPrivate为false时在连接前检查域名解析后的地址 重定向和DNS重绑定也无法访问内网
+func newWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !webhookIPAllowed(ip) {
+				return errWebhookPrivateAddr
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// 不使用环境变量中的代理 否则检查的是代理的地址
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     time.Second * 90,
+		},
+	}
+}
+
+// webhookSigningKey 签名的密钥 设置了secret_token时使用secret_token 否则使用机器人的token
+func webhookSigningKey(robotM *robot) string {
+	if robotM.WebhookSecret != "" {
+		return robotM.WebhookSecret
+	}
+	return robotM.Token
+}
+
+// webhookSignature 计算推送的签名
+func webhookSignature(key string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signWebhookRequest 设置推送的时间戳和签名请求头
+func signWebhookRequest(req *http.Request, key string, body []byte) {
+	timestamp := time.Now().Unix()
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhookSignatureHeader, webhookSignature(key, timestamp, body))
+}
+
+// webhookRetryDelay 第attempts次推送失败后到下次重试的间隔 每次翻倍
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := extconfig.Get().Bot.WebhookRetryDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+	return delay
+}
+
+// postWebhook 推送事件到webhook 返回的状态码不是2xx时视为失败
+func postWebhook(client *http.Client, robotM *robot, eventID int64, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, robotM.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if robotM.WebhookSecret != "" {
+		req.Header.Set(webhookSecretHeader, robotM.WebhookSecret)
+	}
+	req.Header.Set(webhookEventIDHeader, strconv.FormatInt(eventID, 10))
+	signWebhookRequest(req, webhookSigningKey(robotM), body)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook返回的状态码为%d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// pushToWebhook 机器人设置了webhook时推送事件并记录 推送失败时稍后重试 没有设置webhook时返回false 由调用方保存到events
+func (rb *Robot) pushToWebhook(robotID string, event *robotEvent) bool {
+	robotM, err := rb.db.queryVaildRobotWithRobtID(robotID)
+	if err != nil {
+		rb.Warn("查询机器人的webhook失败！", zap.Error(err), zap.String("robotID", robotID))
+		return false
+	}
+	if robotM == nil || robotM.WebhookURL == "" {
+		return false
+	}
+	eventResp := &robotEventResp{}
+	eventResp.from(event)
+	deliveryM := &webhookDeliveryModel{
+		RobotID: robotID,
+		EventID: event.EventID,
+		Body:    util.ToJson(eventResp),
+		Status:  webhookDeliveryPending,
+	}
+	rb.attemptDelivery(robotM, deliveryM)
+	if err = rb.db.insertWebhookDelivery(deliveryM); err != nil {
+		rb.Error("保存webhook推送记录失败！", zap.Error(err), zap.String("robotID", robotID), zap.Int64("eventID", event.EventID))
+	}
+	return true
+}
+
+// attemptDelivery 推送一次并更新推送记录的状态 超过最大次数后标记为失败
+func (rb *Robot) attemptDelivery(robotM *robot, m *webhookDeliveryModel) {
+	start := time.Now()
+	statusCode, err := postWebhook(rb.webhookClient, robotM, m.EventID, []byte(m.Body))
+	m.URL = robotM.WebhookURL
+	m.Attempts++
+	m.StatusCode = statusCode
+	m.Duration = time.Since(start).Milliseconds()
+	rb.statWebhook(robotM.RobotID, err == nil)
+	if err == nil {
+		m.Status = webhookDeliverySuccess
+		m.Error = ""
+		return
+	}
+	rb.Warn("推送消息到机器人的webhook失败！", zap.Error(err), zap.String("robotID", robotM.RobotID), zap.String("url", robotM.WebhookURL), zap.Int("attempts", m.Attempts))
+	rb.setWebhookError(robotM.RobotID, err)
+	m.Error = err.Error()
+	if len(m.Error) > webhookErrorMaxLen {
+		m.Error = m.Error[:webhookErrorMaxLen]
+	}
+	if m.Attempts >= extconfig.Get().Bot.WebhookMaxAttempts {
+		m.Status = webhookDeliveryDead
+		return
+	}
+	m.Status = webhookDeliveryPending
+	m.NextRetryAt = time.Now().Add(webhookRetryDelay(m.Attempts)).Unix()
+}
+
+// retryWebhookDeliveries 重试到了时间的webhook推送
+func (rb *Robot) retryWebhookDeliveries() {
+	now := time.Now().Unix()
+	deliveries, err := rb.db.queryDueWebhookDeliveries(now, webhookRetryBatchSize)
+	if err != nil {
+		rb.Error("查询需要重试的webhook推送失败！", zap.Error(err))
+		return
+	}
+	for _, deliveryM := range deliveries {
+		locked, err := rb.db.lockWebhookDelivery(deliveryM.Id, deliveryM.NextRetryAt, time.Now().Add(webhookRetryLockTime).Unix())
+		if err != nil {
+			rb.Error("锁定webhook推送记录失败！", zap.Error(err), zap.Int64("id", deliveryM.Id))
+			continue
+		}
+		if !locked {
+			continue
+		}
+		robotM, err := rb.db.queryVaildRobotWithRobtID(deliveryM.RobotID)
+		if err != nil {
+			rb.Error("查询机器人的webhook失败！", zap.Error(err), zap.String("robotID", deliveryM.RobotID))
+			continue
+		}
+		if robotM == nil || robotM.WebhookURL == "" {
+			// 机器人已禁用或已删除webhook 不再重试
+			deliveryM.Status = webhookDeliveryDead
+			deliveryM.Error = "机器人已禁用或未设置webhook"
+		} else {
+			rb.attemptDelivery(robotM, deliveryM)
+		}
+		if err = rb.db.updateWebhookDelivery(deliveryM); err != nil {
+			rb.Error("更新webhook推送记录失败！", zap.Error(err), zap.Int64("id", deliveryM.Id))
+		}
+	}
+}
+
+// cleanWebhookDeliveries 删除过期的webhook推送记录
+func (rb *Robot) cleanWebhookDeliveries() {
+	before := time.Now().Add(-extconfig.Get().Bot.WebhookLogExpire)
+	if err := rb.db.deleteWebhookDeliveriesBefore(before); err != nil {
+		rb.Error("删除过期的webhook推送记录失败！", zap.Error(err))
+	}
+}
+
+func (rb *Robot) setWebhookError(robotID string, err error) {
+	value := util.ToJson(&webhookError{
+		Message: err.Error(),
+		Time:    time.Now().Unix(),
+	})
+	if err := rb.ctx.Cache().SetAndExpire(webhookErrorCachePrefix+robotID, value, time.Hour*24); err != nil {
+		rb.Warn("记录webhook推送失败的原因失败！", zap.Error(err))
+	}
+}
+
+func (rb *Robot) getWebhookError(robotID string) *webhookError {
+	value, err := rb.ctx.Cache().Get(webhookErrorCachePrefix + robotID)
+	if err != nil {
+		rb.Warn("获取webhook推送失败的原因失败！", zap.Error(err))
+		return nil
+	}
+	if value == "" {
+		return nil
+	}
+	var lastErr *webhookError
+	if err = util.ReadJsonByByte([]byte(value), &lastErr); err != nil {
+		return nil
+	}
+	return lastErr
+}
@@ -0,0 +1,114 @@
+package robot
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckWebhookURL(t *testing.T) {
+	assert.NoError(t, checkWebhookURL("https://bot.example.com/hook"))
+	assert.NoError(t, checkWebhookURL("http://8.8.8.8:8080/hook?a=1"))
+	assert.Error(t, checkWebhookURL(""))
+	assert.Error(t, checkWebhookURL("ftp://bot.example.com/hook"))
+	assert.Error(t, checkWebhookURL("https:///hook"))
+	assert.Error(t, checkWebhookURL("bot.example.com/hook"))
+	// 内网地址
+	for _, u := range []string{"http://127.0.0.1:8080/hook", "http://localhost/hook", "http://[::1]/hook", "http://169.254.169.254/latest/meta-data", "http://10.0.0.1/hook"} {
+		assert.Equal(t, errWebhookPrivateAddr, checkWebhookURL(u), u)
+	}
+	cfg := &extconfig.Get().Bot
+	cfg.WebhookAllowPrivate = true
+	defer func() { cfg.WebhookAllowPrivate = false }()
+	assert.NoError(t, checkWebhookURL("http://127.0.0.1:8080/hook"))
+}
+
+func TestWebhookIPAllowed(t *testing.T) {
+	for _, ip := range []string{"8.8.8.8", "2001:4860:4860::8888", "100.128.0.1"} {
+		assert.True(t, webhookIPAllowed(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "fe80::1", "fc00::1", "0.0.0.0", "::", "0.1.2.3", "100.64.0.1", "224.0.0.1", "::ffff:127.0.0.1"} {
+		assert.False(t, webhookIPAllowed(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestNewWebhookClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	robotM := &robot{WebhookURL: server.URL, Token: "t1"}
+
+	// 域名解析后为回环地址时不允许连接
+	_, err := postWebhook(newWebhookClient(time.Second, false), robotM, 1, []byte(`{}`))
+	assert.ErrorIs(t, err, errWebhookPrivateAddr)
+	robotM.WebhookURL = strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	_, err = postWebhook(newWebhookClient(time.Second, false), robotM, 1, []byte(`{}`))
+	assert.ErrorIs(t, err, errWebhookPrivateAddr)
+
+	statusCode, err := postWebhook(newWebhookClient(time.Second, true), robotM, 1, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+}
+
+func TestPostWebhook(t *testing.T) {
+	var gotSecret, gotBody, gotEventID, gotTimestamp, gotSignature string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSecret = r.Header.Get(webhookSecretHeader)
+		gotEventID = r.Header.Get(webhookEventIDHeader)
+		gotTimestamp = r.Header.Get(webhookTimestampHeader)
+		gotSignature = r.Header.Get(webhookSignatureHeader)
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	client := &http.Client{Timeout: time.Second}
+
+	robotM := &robot{WebhookURL: server.URL, WebhookSecret: "s1", Token: "t1"}
+	statusCode, err := postWebhook(client, robotM, 1, []byte(`{"event_id":1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "s1", gotSecret)
+	assert.Equal(t, "1", gotEventID)
+	assert.Equal(t, `{"event_id":1}`, gotBody)
+	timestamp, _ := strconv.ParseInt(gotTimestamp, 10, 64)
+	assert.Equal(t, webhookSignature("s1", timestamp, []byte(`{"event_id":1}`)), gotSignature)
+
+	// 没有secret_token时使用token签名
+	robotM.WebhookSecret = ""
+	_, err = postWebhook(client, robotM, 2, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "", gotSecret)
+	timestamp, _ = strconv.ParseInt(gotTimestamp, 10, 64)
+	assert.Equal(t, webhookSignature("t1", timestamp, []byte(`{}`)), gotSignature)
+
+	status = http.StatusInternalServerError
+	statusCode, err = postWebhook(client, robotM, 3, []byte(`{}`))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusInternalServerError, statusCode)
+}
+
+func TestWebhookSignature(t *testing.T) {
+	// echo -n '1700000000.{"a":1}' | openssl dgst -sha256 -hmac key
+	assert.Equal(t, "a438e398bfafc57e4396bb7fc2304422f0f768e965d073ca313cb52e22e6ad03", webhookSignature("key", 1700000000, []byte(`{"a":1}`)))
+	assert.NotEqual(t, webhookSignature("key", 1700000000, []byte(`{"a":1}`)), webhookSignature("key", 1700000001, []byte(`{"a":1}`)))
+	assert.NotEqual(t, webhookSignature("key", 1700000000, []byte(`{"a":1}`)), webhookSignature("key2", 1700000000, []byte(`{"a":1}`)))
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	delay := extconfig.Get().Bot.WebhookRetryDelay
+	assert.Equal(t, delay, webhookRetryDelay(1))
+	assert.Equal(t, delay*2, webhookRetryDelay(2))
+	assert.Equal(t, delay*8, webhookRetryDelay(4))
+	assert.Equal(t, webhookRetryMaxDelay, webhookRetryDelay(100))
+}
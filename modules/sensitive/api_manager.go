@@ -0,0 +1,498 @@
+package sensitive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/sensitive"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// maxImportSize 导入词库文件的最大大小
+const maxImportSize = 10 * 1024 * 1024
+
+// Manager 敏感词管理
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db      *db
+	service IService
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	return &Manager{
+		ctx:     ctx,
+		Log:     log.NewTLog("SensitiveManager"),
+		db:      newDB(ctx),
+		service: NewService(ctx),
+	}
+}
+
+// Route 路由配置
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/sensitive_words", m.list)                     // 敏感词列表
+		auth.POST("/sensitive_words", m.add)                     // 添加敏感词
+		auth.PUT("/sensitive_words/:id", m.update)               // 修改敏感词的处理方式
+		auth.DELETE("/sensitive_words", m.delete)                // 删除敏感词
+		auth.POST("/sensitive_words/import", m.importWords)      // 导入词库
+		auth.GET("/sensitive_words/export", m.exportWords)       // 导出词库
+		auth.POST("/sensitive_words/reload", m.reload)           // 立即重新加载词库
+		auth.POST("/sensitive_words/check", m.check)             // 检查文本
+		auth.GET("/sensitive_words/hits", m.hits)                // 命中记录
+		auth.PUT("/sensitive_words/hits/:id", m.updateHitStatus) // 审核命中记录
+	}
+	if extconfig.Get().Sensitive.On {
+		m.reloadWords(false)
+	}
+	// 重新加载配置后可能开启或关闭敏感词过滤
+	if interval := extconfig.Get().Sensitive.ReloadInterval; interval > 0 {
+		m.ctx.Schedule(interval, func() {
+			if extconfig.Get().Sensitive.On {
+				m.reloadWords(false)
+			}
+		})
+	}
+	extconfig.OnReload("sensitive", func(c *extconfig.Config) error {
+		if c.Sensitive.On {
+			m.reloadWords(true)
+		}
+		return nil
+	})
+}
+
+func (m *Manager) reloadWords(force bool) {
+	if err := words.reload(m.db, force); err != nil {
+		m.Warn("加载敏感词库失败！", zap.Error(err))
+	}
+}
+
+// 敏感词列表
+func (m *Manager) list(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	keyword := sensitive.Normalize(c.Query("keyword"))
+	action := c.Query("action")
+	models, err := m.db.queryWordsWithPage(keyword, action, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询敏感词列表失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询敏感词列表失败！"))
+		return
+	}
+	count, err := m.db.queryWordsCount(keyword, action)
+	if err != nil {
+		m.Error("查询敏感词数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询敏感词数量失败！"))
+		return
+	}
+	list := make([]*wordResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newWordResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 添加敏感词
+func (m *Manager) add(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		Words  []string `json:"words"`
+		Action string   `json:"action"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if !validAction(req.Action) {
+		c.ResponseError(errors.New("处理方式有误！"))
+		return
+	}
+	entries := make([]wordEntry, 0, len(req.Words))
+	for _, word := range req.Words {
+		entries = append(entries, wordEntry{word: word, action: req.Action})
+	}
+	count, err := m.saveWords(entries)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+	})
+}
+
+// 修改敏感词的处理方式
+func (m *Manager) update(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	var req struct {
+		Action string `json:"action"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if id <= 0 || !validAction(req.Action) {
+		c.ResponseError(errors.New("参数错误！"))
+		return
+	}
+	if err := m.db.updateWordAction(id, req.Action, m.ctx.GenSeq(seqKey)); err != nil {
+		m.Error("修改敏感词失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改敏感词失败！"))
+		return
+	}
+	m.reloadWords(false)
+	c.ResponseOK()
+}
+
+// 删除敏感词
+func (m *Manager) delete(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if len(req.IDs) == 0 {
+		c.ResponseError(errors.New("要删除的敏感词不能为空！"))
+		return
+	}
+	if err := m.db.deleteWords(req.IDs, m.ctx.GenSeq(seqKey)); err != nil {
+		m.Error("删除敏感词失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除敏感词失败！"))
+		return
+	}
+	m.reloadWords(false)
+	c.ResponseOK()
+}
+
+// 导入词库 文件每行一个词 格式为 词[,处理方式] 没有处理方式时使用参数action
+func (m *Manager) importWords(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	defaultAction := c.PostForm("action")
+	if defaultAction == "" {
+		defaultAction = ActionReplace
+	}
+	if !validAction(defaultAction) {
+		c.ResponseError(errors.New("处理方式有误！"))
+		return
+	}
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.ResponseErrorf("读取文件失败！", err)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportSize+1))
+	if err != nil {
+		c.ResponseErrorf("读取文件失败！", err)
+		return
+	}
+	if len(data) > maxImportSize {
+		c.ResponseError(errors.New("文件不能超过10M！"))
+		return
+	}
+	entries, err := parseWordEntries(data, defaultAction)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	count, err := m.saveWords(entries)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+	})
+}
+
+// 导出词库 格式与导入相同
+func (m *Manager) exportWords(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := m.db.queryWords()
+	if err != nil {
+		m.Error("查询敏感词失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询敏感词失败！"))
+		return
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	for _, model := range models {
+		_ = writer.Write([]string{model.Word, model.Action})
+	}
+	writer.Flush()
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=sensitive_words_%s.csv", time.Now().Format("20060102")))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// 立即重新加载词库 其他实例在下次检查时加载
+func (m *Manager) reload(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := words.reload(m.db, true); err != nil {
+		m.Error("加载敏感词库失败！", zap.Error(err))
+		c.ResponseError(errors.New("加载敏感词库失败！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": words.len(),
+	})
+}
+
+// 检查文本 用于验证词库
+func (m *Manager) check(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	result := m.service.Check(req.Text)
+	hitWords := result.Words
+	if hitWords == nil {
+		hitWords = make([]string, 0)
+	}
+	c.Response(map[string]interface{}{
+		"action": result.Action,
+		"text":   result.Text,
+		"words":  hitWords,
+	})
+}
+
+// 命中记录 status为空时查询所有
+func (m *Manager) hits(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	status := -1
+	if c.Query("status") != "" {
+		status, _ = strconv.Atoi(c.Query("status"))
+	}
+	models, err := m.db.queryHitsWithPage(status, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询命中记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询命中记录失败！"))
+		return
+	}
+	count, err := m.db.queryHitsCount(status)
+	if err != nil {
+		m.Error("查询命中记录数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询命中记录数量失败！"))
+		return
+	}
+	list := make([]*hitResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newHitResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 审核命中记录
+func (m *Manager) updateHitStatus(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermContentReview); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	var req struct {
+		Status int `json:"status"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseErrorf("数据格式有误！", err)
+		return
+	}
+	if id <= 0 || (req.Status != HitStatusIgnored && req.Status != HitStatusConfirmed) {
+		c.ResponseError(errors.New("参数错误！"))
+		return
+	}
+	if err := m.db.updateHitStatus(id, req.Status, c.GetLoginUID()); err != nil {
+		m.Error("修改命中记录状态失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改命中记录状态失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// saveWords 保存敏感词 返回保存的数量
+func (m *Manager) saveWords(entries []wordEntry) (int, error) {
+	models := make([]*wordModel, 0, len(entries))
+	exists := map[string]bool{}
+	version := m.ctx.GenSeq(seqKey)
+	for _, entry := range entries {
+		word := sensitive.Normalize(entry.word)
+		if word == "" || exists[word] {
+			continue
+		}
+		if len([]rune(word)) > 100 {
+			return 0, fmt.Errorf("敏感词[%s]不能超过100个字！", entry.word)
+		}
+		exists[word] = true
+		models = append(models, &wordModel{
+			Word:    word,
+			Action:  entry.action,
+			Version: version,
+		})
+	}
+	if len(models) == 0 {
+		return 0, errors.New("敏感词不能为空！")
+	}
+	tx, _ := m.db.session.Begin()
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	if err := m.db.insertOrUpdateWordsTx(models, tx); err != nil {
+		tx.Rollback()
+		m.Error("保存敏感词失败！", zap.Error(err))
+		return 0, errors.New("保存敏感词失败！")
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		m.Error("事务提交失败！", zap.Error(err))
+		return 0, errors.New("事务提交失败！")
+	}
+	m.reloadWords(false)
+	return len(models), nil
+}
+
+type wordEntry struct {
+	word   string
+	action string
+}
+
+// parseWordEntries 解析导入的词库 空行和#开头的行忽略
+func parseWordEntries(data []byte, defaultAction string) ([]wordEntry, error) {
+	entries := make([]wordEntry, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word := line
+		action := defaultAction
+		if idx := strings.LastIndex(line, ","); idx >= 0 {
+			word = strings.TrimSpace(line[:idx])
+			action = strings.TrimSpace(line[idx+1:])
+			if !validAction(action) {
+				return nil, fmt.Errorf("第%d行的处理方式有误！", lineNo)
+			}
+		}
+		entries = append(entries, wordEntry{word: word, action: action})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("解析文件失败！")
+	}
+	return entries, nil
+}
+
+type wordResp struct {
+	ID        int64  `json:"id"`
+	Word      string `json:"word"`
+	Action    string `json:"action"`
+	Version   int64  `json:"version"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func newWordResp(m *wordModel) *wordResp {
+	return &wordResp{
+		ID:        m.Id,
+		Word:      m.Word,
+		Action:    m.Action,
+		Version:   m.Version,
+		CreatedAt: m.CreatedAt.String(),
+		UpdatedAt: m.UpdatedAt.String(),
+	}
+}
+
+type hitResp struct {
+	ID          int64    `json:"id"`
+	UID         string   `json:"uid"`
+	Scene       string   `json:"scene"`
+	ChannelID   string   `json:"channel_id"`
+	ChannelType uint8    `json:"channel_type"`
+	MessageID   string   `json:"message_id"`
+	Content     string   `json:"content"`
+	Words       []string `json:"words"`
+	Action      string   `json:"action"`
+	Status      int      `json:"status"`
+	Reviewer    string   `json:"reviewer"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+func newHitResp(m *hitModel) *hitResp {
+	hitWords := make([]string, 0)
+	if m.Words != "" {
+		hitWords = strings.Split(m.Words, ",")
+	}
+	return &hitResp{
+		ID:          m.Id,
+		UID:         m.UID,
+		Scene:       m.Scene,
+		ChannelID:   m.ChannelID,
+		ChannelType: m.ChannelType,
+		MessageID:   m.MessageID,
+		Content:     m.Content,
+		Words:       hitWords,
+		Action:      m.Action,
+		Status:      m.Status,
+		Reviewer:    m.Reviewer,
+		CreatedAt:   m.CreatedAt.String(),
+	}
+}
@@ -0,0 +1,41 @@
+package sensitive
+
+// 命中敏感词后的处理
+const (
+	// ActionBlock 拦截 消息会被撤回
+	ActionBlock = "block"
+	// ActionReplace 替换为mask后显示
+	ActionReplace = "replace"
+	// ActionReview 不处理内容 记录下来由管理员审核
+	ActionReview = "review"
+)
+
+// 命中记录的审核状态
+const (
+	HitStatusPending   = 0 // 待审核
+	HitStatusIgnored   = 1 // 已忽略
+	HitStatusConfirmed = 2 // 已确认违规
+)
+
+// SceneMessage 消息中命中的敏感词
+const SceneMessage = "message"
+
+// seqKey 词库版本的序号
+const seqKey = "SensitiveWord"
+
+func validAction(action string) bool {
+	return action == ActionBlock || action == ActionReplace || action == ActionReview
+}
+
+// actionLevel 同一段文本命中多个词时以最严重的处理为准
+func actionLevel(action string) int {
+	switch action {
+	case ActionBlock:
+		return 3
+	case ActionReview:
+		return 2
+	case ActionReplace:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package sensitive
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// insertOrUpdateWordsTx 添加敏感词 已存在（包括已删除的）则恢复并修改处理方式
+func (d *db) insertOrUpdateWordsTx(models []*wordModel, tx *dbr.Tx) error {
+	for _, m := range models {
+		_, err := tx.InsertBySql("insert into sensitive_word(word,action,is_deleted,version) values(?,?,0,?) ON DUPLICATE KEY UPDATE action=VALUES(action),is_deleted=0,version=VALUES(version)", m.Word, m.Action, m.Version).Exec()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *db) updateWordAction(id int64, action string, version int64) error {
+	_, err := d.session.Update("sensitive_word").Set("action", action).Set("version", version).Where("id=? and is_deleted=0", id).Exec()
+	return err
+}
+
+func (d *db) deleteWords(ids []int64, version int64) error {
+	_, err := d.session.Update("sensitive_word").Set("is_deleted", 1).Set("version", version).Where("id in ?", ids).Exec()
+	return err
+}
+
+// queryWords 查询所有未删除的敏感词
+func (d *db) queryWords() ([]*wordModel, error) {
+	var models []*wordModel
+	_, err := d.session.Select("*").From("sensitive_word").Where("is_deleted=0").Load(&models)
+	return models, err
+}
+
+// queryMaxVersion 词库的最新版本 添加、修改和删除都会更新版本
+func (d *db) queryMaxVersion() (int64, error) {
+	var version int64
+	_, err := d.session.Select("IFNULL(max(version),0)").From("sensitive_word").Load(&version)
+	return version, err
+}
+
+func (d *db) queryWordsWithPage(keyword string, action string, pageIndex, pageSize uint64) ([]*wordModel, error) {
+	var models []*wordModel
+	_, err := d.wordsWhere(d.session.Select("*").From("sensitive_word"), keyword, action).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryWordsCount(keyword string, action string) (int64, error) {
+	var count int64
+	_, err := d.wordsWhere(d.session.Select("count(*)").From("sensitive_word"), keyword, action).Load(&count)
+	return count, err
+}
+
+func (d *db) wordsWhere(builder *dbr.SelectStmt, keyword string, action string) *dbr.SelectStmt {
+	builder = builder.Where("is_deleted=0")
+	if keyword != "" {
+		builder = builder.Where("word like ?", "%"+keyword+"%")
+	}
+	if action != "" {
+		builder = builder.Where("action=?", action)
+	}
+	return builder
+}
+
+func (d *db) insertHit(m *hitModel) error {
+	_, err := d.session.InsertInto("sensitive_word_hit").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryHitsWithPage(status int, pageIndex, pageSize uint64) ([]*hitModel, error) {
+	var models []*hitModel
+	builder := d.session.Select("*").From("sensitive_word_hit")
+	if status >= 0 {
+		builder = builder.Where("status=?", status)
+	}
+	_, err := builder.OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *db) queryHitsCount(status int) (int64, error) {
+	var count int64
+	builder := d.session.Select("count(*)").From("sensitive_word_hit")
+	if status >= 0 {
+		builder = builder.Where("status=?", status)
+	}
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+// queryHitMessageIDs 命中过敏感词的消息ID
+func (d *db) queryHitMessageIDs(messageIDs []string) ([]string, error) {
+	var ids []string
+	_, err := d.session.Select("distinct message_id").From("sensitive_word_hit").Where("scene=? and message_id in ?", SceneMessage, messageIDs).Load(&ids)
+	return ids, err
+}
+
+func (d *db) updateHitStatus(id int64, status int, reviewer string) error {
+	_, err := d.session.Update("sensitive_word_hit").Set("status", status).Set("reviewer", reviewer).Where("id=?", id).Exec()
+	return err
+}
+
+type wordModel struct {
+	Word      string
+	Action    string
+	IsDeleted int
+	Version   int64
+	dba.BaseModel
+}
+
+type hitModel struct {
+	UID         string
+	Scene       string
+	ChannelID   string
+	ChannelType uint8
+	MessageID   string
+	Content     string
+	Words       string
+	Action      string
+	Status      int
+	Reviewer    string
+	dba.BaseModel
+}
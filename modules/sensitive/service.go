@@ -0,0 +1,128 @@
+package sensitive
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
+)
+
+// IService 敏感词服务
+type IService interface {
+	// Check 检查文本中的敏感词 没有开启或没有命中时Action为空
+	Check(text string) *CheckResult
+	// AddHit 记录命中敏感词 供管理员审核
+	AddHit(hit *HitReq) error
+	// GetHitMessageIDs 命中过敏感词的消息 返回messageIDs中命中过的消息ID
+	GetHitMessageIDs(messageIDs []string) ([]string, error)
+	// GetPendingHitCount 待审核的命中记录数
+	GetPendingHitCount() (int64, error)
+}
+
+// Service Service
+type Service struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// NewService NewService
+func NewService(ctx *config.Context) IService {
+	return &Service{
+		ctx: ctx,
+		Log: log.NewTLog("SensitiveService"),
+		db:  newDB(ctx),
+	}
+}
+
+// Check 检查文本中的敏感词
+func (s *Service) Check(text string) *CheckResult {
+	cfg := extconfig.Get().Sensitive
+	if !cfg.On || strings.TrimSpace(text) == "" {
+		return &CheckResult{Text: text}
+	}
+	if !words.isLoaded() {
+		// 管理模块还没有加载词库
+		if err := words.reload(s.db, false); err != nil {
+			s.Warn("加载敏感词库失败！", zap.Error(err))
+			return &CheckResult{Text: text}
+		}
+	}
+	return words.check(text, maskRune(cfg.Mask))
+}
+
+// AddHit 记录命中敏感词
+func (s *Service) AddHit(hit *HitReq) error {
+	return s.db.insertHit(&hitModel{
+		UID:         hit.UID,
+		Scene:       hit.Scene,
+		ChannelID:   hit.ChannelID,
+		ChannelType: hit.ChannelType,
+		MessageID:   hit.MessageID,
+		Content:     hit.Content,
+		Words:       truncateRunes(strings.Join(hit.Words, ","), 1000),
+		Action:      hit.Action,
+		Status:      HitStatusPending,
+	})
+}
+
+// GetHitMessageIDs 命中过敏感词的消息
+func (s *Service) GetHitMessageIDs(messageIDs []string) ([]string, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	return s.db.queryHitMessageIDs(messageIDs)
+}
+
+// GetPendingHitCount 待审核的命中记录数
+func (s *Service) GetPendingHitCount() (int64, error) {
+	return s.db.queryHitsCount(HitStatusPending)
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
+func maskRune(mask string) rune {
+	r, _ := utf8.DecodeRuneInString(mask)
+	if r == utf8.RuneError {
+		return '*'
+	}
+	return r
+}
+
+// CheckResult 检查结果
+type CheckResult struct {
+	Action string   // 需要执行的处理 命中多个词时取最严重的 block > review > replace
+	Text   string   // 替换后的文本 只替换处理方式为replace的词
+	Words  []string // 命中的敏感词
+}
+
+// Hit 是否命中了敏感词
+func (c *CheckResult) Hit() bool {
+	return len(c.Words) > 0
+}
+
+// Blocked 是否需要拦截
+func (c *CheckResult) Blocked() bool {
+	return c.Action == ActionBlock
+}
+
+// HitReq 命中记录
+type HitReq struct {
+	UID         string   // 发送者
+	Scene       string   // 场景
+	ChannelID   string   // 频道ID
+	ChannelType uint8    // 频道类型
+	MessageID   string   // 消息ID
+	Content     string   // 命中的原文
+	Words       []string // 命中的敏感词
+	Action      string   // 执行的处理
+}
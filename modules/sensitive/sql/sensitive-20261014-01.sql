@@ -0,0 +1,35 @@
+-- +migrate Up
+
+-- ##########  敏感词 ##########
+create table `sensitive_word`
+(
+    id         integer      not null primary key AUTO_INCREMENT,
+    word       VARCHAR(100) NOT NULL DEFAULT '' COMMENT '敏感词（归一化后）',
+    action     VARCHAR(20)  NOT NULL DEFAULT 'replace' COMMENT '命中后的处理 block.拦截 replace.替换 review.标记待审核',
+    is_deleted smallint     NOT NULL DEFAULT 0  COMMENT '是否已删除',
+    version    bigint       NOT NULL DEFAULT 0  COMMENT '数据版本 词库变化后重新加载',
+    created_at timeStamp    not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at timeStamp    not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX sensitive_word_uidx on `sensitive_word` (word);
+CREATE INDEX sensitive_word_version_idx on `sensitive_word` (version);
+
+-- ##########  敏感词命中记录 ##########
+create table `sensitive_word_hit`
+(
+    id           integer      not null primary key AUTO_INCREMENT,
+    uid          VARCHAR(40)  NOT NULL DEFAULT '' COMMENT '发送者uid',
+    scene        VARCHAR(20)  NOT NULL DEFAULT '' COMMENT '场景 message.消息',
+    channel_id   VARCHAR(100) NOT NULL DEFAULT '' COMMENT '频道ID',
+    channel_type smallint     NOT NULL DEFAULT 0  COMMENT '频道类型',
+    message_id   VARCHAR(20)  NOT NULL DEFAULT '' COMMENT '消息ID',
+    content      text                            COMMENT '命中的原文',
+    words        VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '命中的敏感词 多个用逗号分隔',
+    action       VARCHAR(20)  NOT NULL DEFAULT '' COMMENT '执行的处理',
+    status       smallint     NOT NULL DEFAULT 0  COMMENT '审核状态 0.待审核 1.已忽略 2.已确认违规',
+    reviewer     VARCHAR(40)  NOT NULL DEFAULT '' COMMENT '审核人uid',
+    created_at   timeStamp    not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp    not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX sensitive_word_hit_status_idx on `sensitive_word_hit` (status, created_at);
+CREATE INDEX sensitive_word_hit_uid_idx on `sensitive_word_hit` (uid);
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+-- 合规调查时查询消息是否命中过敏感词
+CREATE INDEX sensitive_word_hit_message_idx on `sensitive_word_hit` (message_id);
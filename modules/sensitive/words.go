@@ -0,0 +1,110 @@
+package sensitive
+
+import (
+	"sync"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/sensitive"
+)
+
+// wordList 内存中的词库 管理后台修改词库后各实例定时检查版本重新加载
+type wordList struct {
+	lock    sync.RWMutex
+	matcher *sensitive.Matcher
+	actions map[string]string // 归一化后的词 => 处理方式
+	version int64
+	loaded  bool
+}
+
+// words 所有Service共用的词库
+var words = newWordList()
+
+func newWordList() *wordList {
+	return &wordList{
+		matcher: sensitive.NewMatcher(nil),
+		actions: map[string]string{},
+	}
+}
+
+// reload 词库版本变化后重新构建 force为true时不比较版本
+func (w *wordList) reload(d *db, force bool) error {
+	version, err := d.queryMaxVersion()
+	if err != nil {
+		return err
+	}
+	w.lock.RLock()
+	unchanged := w.loaded && w.version == version
+	w.lock.RUnlock()
+	if unchanged && !force {
+		return nil
+	}
+	models, err := d.queryWords()
+	if err != nil {
+		return err
+	}
+	w.set(models, version)
+	return nil
+}
+
+func (w *wordList) set(models []*wordModel, version int64) {
+	list := make([]string, 0, len(models))
+	actions := make(map[string]string, len(models))
+	for _, m := range models {
+		word := sensitive.Normalize(m.Word)
+		if word == "" {
+			continue
+		}
+		list = append(list, word)
+		actions[word] = m.Action
+	}
+	matcher := sensitive.NewMatcher(list)
+
+	w.lock.Lock()
+	w.matcher = matcher
+	w.actions = actions
+	w.version = version
+	w.loaded = true
+	w.lock.Unlock()
+}
+
+func (w *wordList) isLoaded() bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.loaded
+}
+
+func (w *wordList) len() int {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.matcher.Len()
+}
+
+// check 检查文本 replace的词替换为mask block和review的词不修改原文
+func (w *wordList) check(text string, mask rune) *CheckResult {
+	w.lock.RLock()
+	matcher := w.matcher
+	actions := w.actions
+	w.lock.RUnlock()
+
+	result := &CheckResult{Text: text}
+	matches := matcher.Find(text)
+	if len(matches) == 0 {
+		return result
+	}
+	replaces := make([]sensitive.Match, 0, len(matches))
+	exists := map[string]bool{}
+	for _, match := range matches {
+		action := actions[match.Word]
+		if action == ActionReplace {
+			replaces = append(replaces, match)
+		}
+		if actionLevel(action) > actionLevel(result.Action) {
+			result.Action = action
+		}
+		if !exists[match.Word] {
+			exists[match.Word] = true
+			result.Words = append(result.Words, match.Word)
+		}
+	}
+	result.Text = sensitive.Replace(text, replaces, mask)
+	return result
+}
@@ -0,0 +1,60 @@
+package sensitive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordListCheck(t *testing.T) {
+	w := newWordList()
+	w.set([]*wordModel{
+		{Word: "赌博", Action: ActionReplace},
+		{Word: "代开发票", Action: ActionReview},
+		{Word: "枪支", Action: ActionBlock},
+	}, 3)
+
+	result := w.check("你好", '*')
+	assert.False(t, result.Hit())
+	assert.Equal(t, "", result.Action)
+	assert.Equal(t, "你好", result.Text)
+
+	result = w.check("来赌 博吧", '*')
+	assert.Equal(t, ActionReplace, result.Action)
+	assert.Equal(t, "来***吧", result.Text)
+	assert.Equal(t, []string{"赌博"}, result.Words)
+
+	// review的词不替换 取最严重的处理
+	result = w.check("赌博、代开发票", '*')
+	assert.Equal(t, ActionReview, result.Action)
+	assert.Equal(t, "**、代开发票", result.Text)
+	assert.Equal(t, []string{"赌博", "代开发票"}, result.Words)
+
+	result = w.check("出售枪支，赌博", '*')
+	assert.True(t, result.Blocked())
+	assert.Equal(t, []string{"枪支", "赌博"}, result.Words)
+}
+
+func TestWordListReplace(t *testing.T) {
+	w := newWordList()
+	w.set([]*wordModel{{Word: "赌博", Action: ActionReplace}}, 1)
+	assert.True(t, w.check("赌博", '*').Hit())
+
+	// 重新加载后删除的词不再命中
+	w.set([]*wordModel{{Word: "代开发票", Action: ActionReplace}}, 2)
+	assert.False(t, w.check("赌博", '*').Hit())
+	assert.Equal(t, 1, w.len())
+}
+
+func TestParseWordEntries(t *testing.T) {
+	entries, err := parseWordEntries([]byte("\xef\xbb\xbf# 注释\n赌博\n\n枪支,block\n 代开发票 , review \n"), ActionReplace)
+	assert.NoError(t, err)
+	assert.Equal(t, []wordEntry{
+		{word: "赌博", action: ActionReplace},
+		{word: "枪支", action: ActionBlock},
+		{word: "代开发票", action: ActionReview},
+	}, entries)
+
+	_, err = parseWordEntries([]byte("赌博\n枪支,delete\n"), ActionReplace)
+	assert.EqualError(t, err, "第2行的处理方式有误！")
+}
@@ -0,0 +1,72 @@
+package source
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+)
+
+// 好友来源类型（用于统计及展示“我们是怎么认识的”）
+const (
+	SourceTypeUnknown     = "unknown"      // 未知
+	SourceTypeSearch      = "search"       // 搜索（账号/短编号）
+	SourceTypePhoneSearch = "phone_search" // 手机号搜索
+	SourceTypeNearby      = "nearby"       // 附近的人
+	SourceTypeGroup       = "group"        // 群聊
+	SourceTypeQRCode      = "qrcode"       // 扫一扫
+	SourceTypeCard        = "card"         // 名片
+	SourceTypeMailList    = "maillist"     // 手机通讯录
+	SourceTypeInvite      = "invite"       // 邀请码
+)
+
+// GetSourceType 通过加好友的验证码获取来源类型
+// clientSource为客户端上报的来源，仅用于细分搜索类来源（手机号搜索、附近的人）
+func GetSourceType(code string, clientSource string) string {
+	strs := strings.Split(code, "@")
+	if len(strs) < 2 {
+		return SourceTypeUnknown
+	}
+	codeTypeInt, _ := strconv.Atoi(strs[len(strs)-1])
+	switch common.VercodeType(codeTypeInt) {
+	case common.User:
+		if clientSource == SourceTypePhoneSearch || clientSource == SourceTypeNearby {
+			return clientSource
+		}
+		return SourceTypeSearch
+	case common.GroupMember:
+		return SourceTypeGroup
+	case common.QRCode:
+		return SourceTypeQRCode
+	case common.Friend:
+		return SourceTypeCard
+	case common.MailList:
+		return SourceTypeMailList
+	case common.InvitationCode:
+		return SourceTypeInvite
+	}
+	return SourceTypeUnknown
+}
+
+// GetSourceTypeDesc 获取来源类型的描述
+func GetSourceTypeDesc(sourceType string) string {
+	switch sourceType {
+	case SourceTypeSearch:
+		return "通过搜索添加"
+	case SourceTypePhoneSearch:
+		return "通过手机号搜索添加"
+	case SourceTypeNearby:
+		return "通过附近的人添加"
+	case SourceTypeGroup:
+		return "通过群聊添加"
+	case SourceTypeQRCode:
+		return "通过扫一扫添加"
+	case SourceTypeCard:
+		return "通过名片添加"
+	case SourceTypeMailList:
+		return "通过手机通讯录添加"
+	case SourceTypeInvite:
+		return "通过邀请码注册添加"
+	}
+	return "未知来源"
+}
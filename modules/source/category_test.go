@@ -0,0 +1,30 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSourceType(t *testing.T) {
+	tests := []struct {
+		code         string
+		clientSource string
+		want         string
+	}{
+		{code: "u1@1", want: SourceTypeSearch},
+		{code: "u1@1", clientSource: SourceTypePhoneSearch, want: SourceTypePhoneSearch},
+		{code: "u1@1", clientSource: SourceTypeNearby, want: SourceTypeNearby},
+		{code: "u1@1", clientSource: SourceTypeQRCode, want: SourceTypeSearch},
+		{code: "m1@2", want: SourceTypeGroup},
+		{code: "u1@3", clientSource: SourceTypeNearby, want: SourceTypeQRCode},
+		{code: "f1@4", want: SourceTypeCard},
+		{code: "c1@5", want: SourceTypeMailList},
+		{code: "i1@6", want: SourceTypeInvite},
+		{code: "x1@9", want: SourceTypeUnknown},
+		{code: "", want: SourceTypeUnknown},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, GetSourceType(tt.code, tt.clientSource), tt.code+" "+tt.clientSource)
+	}
+}
@@ -1,10 +1,18 @@
 package statistics
 
 import (
+	"embed"
+
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
 )
 
+//go:embed sql
+var sqlFS embed.FS
+
+//go:embed swagger/api.yaml
+var swaggerContent string
+
 func init() {
 	register.AddModule(func(ctx interface{}) register.Module {
 		x := ctx.(*config.Context)
@@ -13,6 +21,8 @@ func init() {
 			SetupAPI: func() register.APIRouter {
 				return NewStatistics(x)
 			},
+			SQLDir:  register.NewSQLFS(sqlFS),
+			Swagger: swaggerContent,
 		}
 	})
 }
@@ -2,9 +2,13 @@ package statistics
 
 import (
 	"errors"
+	"sync/atomic"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
@@ -15,18 +19,27 @@ import (
 type Statistics struct {
 	ctx *config.Context
 	log.Log
-	userService  user.IService
-	groupService group.IService
+	userService    user.IService
+	groupService   group.IService
+	messageService message.IService
+	fileService    file.IService
+	db             *db
+	rollupRunning  atomic.Bool // 是否正在汇总每日统计
 }
 
 // NewStatistics 统计
 func NewStatistics(ctx *config.Context) *Statistics {
-	return &Statistics{
-		ctx:          ctx,
-		Log:          log.NewTLog("Statistics"),
-		userService:  user.NewService(ctx),
-		groupService: group.NewService(ctx),
+	s := &Statistics{
+		ctx:            ctx,
+		Log:            log.NewTLog("Statistics"),
+		userService:    user.NewService(ctx),
+		groupService:   group.NewService(ctx),
+		messageService: message.NewService(ctx),
+		fileService:    file.NewService(ctx),
+		db:             newDB(ctx),
 	}
+	ctx.Schedule(dailyRollupInterval, s.rollupJob) // 汇总前一天的运营统计
+	return s
 }
 
 // Route 路由配置
@@ -36,12 +49,14 @@ func (s *Statistics) Route(r *wkhttp.WKHttp) {
 		v.GET("/countnum", s.countNum)                                                // 统计数量
 		v.GET("/registeruser/:start_date/:end_date", s.registerUserListWithDateSpace) // 某个时间区间的注册统计数据
 		v.GET("/createdgroup/:start_date/:end_date", s.createGroupWithDateSpace)      // 某个时间段的建群数据
+		v.GET("/daily", s.daily)                                                      // 每日的运营统计（每晚汇总）
+		v.POST("/daily/rollup", s.rollupDaily)                                        // 重新汇总某天的统计
 	}
 }
 
 // 统计数量
 func (s *Statistics) countNum(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermStatsRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -93,7 +108,7 @@ func (s *Statistics) countNum(c *wkhttp.Context) {
 
 // 某个时间区间的注册数据
 func (s *Statistics) registerUserListWithDateSpace(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermStatsRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -114,7 +129,7 @@ func (s *Statistics) registerUserListWithDateSpace(c *wkhttp.Context) {
 
 // 获取某个时间段的建群数量
 func (s *Statistics) createGroupWithDateSpace(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermStatsRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -0,0 +1,275 @@
+package statistics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// dailyDateLayout 统计的日期格式
+	dailyDateLayout = "2006-01-02"
+	// dailyMaxDays 一次最多查询的天数
+	dailyMaxDays = 366
+	// dailyDefaultDays 默认查询最近30天
+	dailyDefaultDays = 30
+	// dailyBackfillDays 汇总最近几天中还没有汇总的日期（服务停止期间错过的） 消息数只保留了最近8天
+	dailyBackfillDays = 7
+	// dailyRollupInterval 检查是否需要汇总的间隔 过了零点后在一个间隔内汇总前一天的数据
+	dailyRollupInterval = time.Minute * 10
+)
+
+// rollupJob 汇总最近几天还没有汇总的数据 不包含今天
+func (s *Statistics) rollupJob() {
+	if !s.rollupRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.rollupRunning.Store(false)
+
+	dates := backfillDates(time.Now(), dailyBackfillDays)
+	existDates, err := s.db.queryExistDates(dates)
+	if err != nil {
+		s.Error("查询已汇总的日期失败！", zap.Error(err))
+		return
+	}
+	existMap := make(map[string]bool, len(existDates))
+	for _, date := range existDates {
+		existMap[date] = true
+	}
+	for _, date := range dates {
+		if existMap[date] {
+			continue
+		}
+		if _, err := s.rollup(date); err != nil {
+			s.Error("汇总每日统计失败！", zap.Error(err), zap.String("date", date))
+			continue
+		}
+		s.Info("汇总每日统计完成", zap.String("date", date))
+	}
+}
+
+// backfillDates 今天之前的days天 从早到晚
+func backfillDates(now time.Time, days int) []string {
+	dates := make([]string, 0, days)
+	for i := days; i >= 1; i-- {
+		dates = append(dates, now.AddDate(0, 0, -i).Format(dailyDateLayout))
+	}
+	return dates
+}
+
+// rollup 汇总某天的统计并保存
+// 注册数和建群数可以随时重新计算 日活根据最后一次上下线时间计算 用户总数、群总数和存储空间为汇总时的值
+func (s *Statistics) rollup(date string) (*dailyModel, error) {
+	registerCount, err := s.userService.GetRegisterWithDate(date)
+	if err != nil {
+		return nil, fmt.Errorf("查询注册用户数失败！%w", err)
+	}
+	activeCount, err := s.userService.GetActiveCountWithDate(date)
+	if err != nil {
+		return nil, fmt.Errorf("查询日活失败！%w", err)
+	}
+	messageCount, err := s.messageService.GetMessageCountWithDate(date)
+	if err != nil {
+		return nil, fmt.Errorf("查询消息数失败！%w", err)
+	}
+	groupCreatedCount, err := s.groupService.GetCreatedCountWithDate(date)
+	if err != nil {
+		return nil, fmt.Errorf("查询新建群数失败！%w", err)
+	}
+	userTotalCount, err := s.userService.GetAllUserCount()
+	if err != nil {
+		return nil, fmt.Errorf("查询用户总数失败！%w", err)
+	}
+	groupTotalCount, err := s.groupService.GetAllGroupCount()
+	if err != nil {
+		return nil, fmt.Errorf("查询群总数失败！%w", err)
+	}
+	storageUsed, err := s.fileService.GetStorageUsed()
+	if err != nil {
+		return nil, fmt.Errorf("查询已使用的存储空间失败！%w", err)
+	}
+	m := &dailyModel{
+		StatDate:          date,
+		RegisterCount:     registerCount,
+		ActiveCount:       activeCount,
+		MessageCount:      messageCount,
+		GroupCreatedCount: groupCreatedCount,
+		UserTotalCount:    userTotalCount,
+		GroupTotalCount:   groupTotalCount,
+		StorageUsed:       storageUsed,
+	}
+	if err = s.db.insertOrUpdateDaily(m); err != nil {
+		return nil, fmt.Errorf("保存每日统计失败！%w", err)
+	}
+	return m, nil
+}
+
+// 每日的运营统计 数据由每晚汇总 不包含今天
+func (s *Statistics) daily(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermStatsRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	start, end, err := parseDailyDateRange(c.Query("start_date"), c.Query("end_date"), time.Now())
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	startDate := start.Format(dailyDateLayout)
+	endDate := end.Format(dailyDateLayout)
+	models, err := s.db.queryDailyWithDateSpace(startDate, endDate)
+	if err != nil {
+		s.Error("查询每日统计失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询每日统计失败！"))
+		return
+	}
+	days := newDailyResps(start, end, models)
+	c.Response(&dailyStatsResp{
+		StartDate: startDate,
+		EndDate:   endDate,
+		Total:     sumDaily(days),
+		Days:      days,
+	})
+}
+
+// 重新汇总某天的统计 例如汇总失败或修正数据后
+func (s *Statistics) rollupDaily(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		Date string `json:"date"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	date, err := time.ParseInLocation(dailyDateLayout, req.Date, time.Local)
+	if err != nil {
+		c.ResponseError(errors.New("date格式有误！"))
+		return
+	}
+	if !date.Before(startOfDay(time.Now())) {
+		c.ResponseError(errors.New("只能汇总今天之前的数据！"))
+		return
+	}
+	m, err := s.rollup(req.Date)
+	if err != nil {
+		s.Error("汇总每日统计失败！", zap.Error(err), zap.String("date", req.Date))
+		c.ResponseError(errors.New("汇总每日统计失败！"))
+		return
+	}
+	c.Response(newDailyResp(req.Date, m))
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// parseDailyDateRange 解析查询的日期范围 默认最近30天（昨天为最后一天）
+func parseDailyDateRange(startDate, endDate string, now time.Time) (time.Time, time.Time, error) {
+	end := startOfDay(now).AddDate(0, 0, -1)
+	var err error
+	if endDate != "" {
+		if end, err = time.ParseInLocation(dailyDateLayout, endDate, now.Location()); err != nil {
+			return time.Time{}, time.Time{}, errors.New("end_date格式有误！")
+		}
+	}
+	start := end.AddDate(0, 0, -(dailyDefaultDays - 1))
+	if startDate != "" {
+		if start, err = time.ParseInLocation(dailyDateLayout, startDate, now.Location()); err != nil {
+			return time.Time{}, time.Time{}, errors.New("start_date格式有误！")
+		}
+	}
+	if start.After(end) {
+		return time.Time{}, time.Time{}, errors.New("start_date不能大于end_date！")
+	}
+	if start.AddDate(0, 0, dailyMaxDays).Before(end.AddDate(0, 0, 1)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("最多只能查询%d天的统计！", dailyMaxDays)
+	}
+	return start, end, nil
+}
+
+type dailyResp struct {
+	Date              string `json:"date"`
+	Rolled            bool   `json:"rolled"`              // 是否已汇总 未汇总的日期数据都为0
+	RegisterCount     int64  `json:"register_count"`      // 注册用户数
+	ActiveCount       int64  `json:"active_count"`        // 日活
+	MessageCount      int64  `json:"message_count"`       // 发送的消息数
+	GroupCreatedCount int64  `json:"group_created_count"` // 新建群数
+	UserTotalCount    int64  `json:"user_total_count"`    // 当天的用户总数
+	GroupTotalCount   int64  `json:"group_total_count"`   // 当天的群总数
+	StorageUsed       int64  `json:"storage_used"`        // 当天已使用的存储空间（字节）
+}
+
+func newDailyResp(date string, m *dailyModel) *dailyResp {
+	resp := &dailyResp{Date: date}
+	if m == nil {
+		return resp
+	}
+	resp.Rolled = true
+	resp.RegisterCount = m.RegisterCount
+	resp.ActiveCount = m.ActiveCount
+	resp.MessageCount = m.MessageCount
+	resp.GroupCreatedCount = m.GroupCreatedCount
+	resp.UserTotalCount = m.UserTotalCount
+	resp.GroupTotalCount = m.GroupTotalCount
+	resp.StorageUsed = m.StorageUsed
+	return resp
+}
+
+// newDailyResps 按日期生成每日统计 没有汇总的日期补0
+func newDailyResps(start, end time.Time, models []*dailyModel) []*dailyResp {
+	modelMap := make(map[string]*dailyModel, len(models))
+	for _, m := range models {
+		modelMap[m.StatDate] = m
+	}
+	resps := make([]*dailyResp, 0)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dailyDateLayout)
+		resps = append(resps, newDailyResp(date, modelMap[date]))
+	}
+	return resps
+}
+
+type dailyTotalResp struct {
+	RegisterCount     int64 `json:"register_count"`      // 注册用户数
+	MessageCount      int64 `json:"message_count"`       // 发送的消息数
+	GroupCreatedCount int64 `json:"group_created_count"` // 新建群数
+	MaxActiveCount    int64 `json:"max_active_count"`    // 最高日活
+	AvgActiveCount    int64 `json:"avg_active_count"`    // 平均日活 只计算已汇总的日期
+}
+
+// sumDaily 日期范围内的合计 日活不能相加 取最高和平均值
+func sumDaily(days []*dailyResp) *dailyTotalResp {
+	total := &dailyTotalResp{}
+	var activeSum, rolledDays int64
+	for _, day := range days {
+		total.RegisterCount += day.RegisterCount
+		total.MessageCount += day.MessageCount
+		total.GroupCreatedCount += day.GroupCreatedCount
+		if day.ActiveCount > total.MaxActiveCount {
+			total.MaxActiveCount = day.ActiveCount
+		}
+		if day.Rolled {
+			activeSum += day.ActiveCount
+			rolledDays++
+		}
+	}
+	if rolledDays > 0 {
+		total.AvgActiveCount = activeSum / rolledDays
+	}
+	return total
+}
+
+type dailyStatsResp struct {
+	StartDate string          `json:"start_date"`
+	EndDate   string          `json:"end_date"`
+	Total     *dailyTotalResp `json:"total"` // 日期范围内的合计
+	Days      []*dailyResp    `json:"days"`  // 每日统计
+}
@@ -0,0 +1,72 @@
+package statistics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDailyDateRange(t *testing.T) {
+	now := time.Date(2026, 10, 14, 10, 0, 0, 0, time.Local)
+
+	start, end, err := parseDailyDateRange("", "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-09-14", start.Format(dailyDateLayout))
+	assert.Equal(t, "2026-10-13", end.Format(dailyDateLayout))
+
+	start, end, err = parseDailyDateRange("2026-10-01", "2026-10-07", now)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-10-01", start.Format(dailyDateLayout))
+	assert.Equal(t, "2026-10-07", end.Format(dailyDateLayout))
+
+	_, _, err = parseDailyDateRange("2026-10-08", "2026-10-07", now)
+	assert.Error(t, err)
+	_, _, err = parseDailyDateRange("2024-01-01", "2026-10-07", now)
+	assert.Error(t, err)
+	_, _, err = parseDailyDateRange("20261001", "", now)
+	assert.Error(t, err)
+}
+
+func TestBackfillDates(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 5, 0, 0, time.Local)
+	assert.Equal(t, []string{"2026-09-28", "2026-09-29", "2026-09-30"}, backfillDates(now, 3))
+}
+
+func TestNewDailyResps(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2026, 10, 3, 0, 0, 0, 0, time.Local)
+	days := newDailyResps(start, end, []*dailyModel{
+		{StatDate: "2026-10-01", RegisterCount: 2, ActiveCount: 10, MessageCount: 100},
+		{StatDate: "2026-10-03", RegisterCount: 1, ActiveCount: 20, MessageCount: 50, GroupCreatedCount: 1},
+	})
+	assert.Len(t, days, 3)
+	assert.True(t, days[0].Rolled)
+	assert.False(t, days[1].Rolled)
+	assert.Equal(t, "2026-10-02", days[1].Date)
+	assert.Equal(t, int64(0), days[1].MessageCount)
+
+	total := sumDaily(days)
+	assert.Equal(t, int64(3), total.RegisterCount)
+	assert.Equal(t, int64(150), total.MessageCount)
+	assert.Equal(t, int64(1), total.GroupCreatedCount)
+	assert.Equal(t, int64(20), total.MaxActiveCount)
+	assert.Equal(t, int64(15), total.AvgActiveCount) // 未汇总的日期不计入平均值
+}
+
+func TestNewDailyTotal(t *testing.T) {
+	days := []*dailyResp{
+		{Date: "2026-10-12", Rolled: true, RegisterCount: 2, ActiveCount: 10, UserTotalCount: 100, GroupTotalCount: 5},
+		{Date: "2026-10-13", Rolled: true, RegisterCount: 3, ActiveCount: 20, UserTotalCount: 103, GroupTotalCount: 6},
+		{Date: "2026-10-14"},
+	}
+	total := newDailyTotal(days)
+	assert.Equal(t, int64(5), total.RegisterCount)
+	assert.Equal(t, int64(20), total.MaxActiveCount)
+	assert.Equal(t, int64(15), total.AvgActiveCount)
+	// 总数取最后一个已汇总的日期
+	assert.Equal(t, int64(103), total.UserTotalCount)
+	assert.Equal(t, int64(6), total.GroupTotalCount)
+	assert.Equal(t, 3, total.Days)
+	assert.Equal(t, 2, total.RolledDays)
+}
@@ -0,0 +1,54 @@
+package statistics
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// insertOrUpdateDaily 保存某天的统计 重新汇总时覆盖
+func (d *db) insertOrUpdateDaily(m *dailyModel) error {
+	_, err := d.session.InsertBySql("insert into statistics_daily(stat_date,register_count,active_count,message_count,group_created_count,user_total_count,group_total_count,storage_used) values(?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE register_count=VALUES(register_count),active_count=VALUES(active_count),message_count=VALUES(message_count),group_created_count=VALUES(group_created_count),user_total_count=VALUES(user_total_count),group_total_count=VALUES(group_total_count),storage_used=VALUES(storage_used),updated_at=NOW()", m.StatDate, m.RegisterCount, m.ActiveCount, m.MessageCount, m.GroupCreatedCount, m.UserTotalCount, m.GroupTotalCount, m.StorageUsed).Exec()
+	return err
+}
+
+// queryDailyWithDateSpace 查询日期范围内（包含起止日期）的每日统计
+func (d *db) queryDailyWithDateSpace(startDate, endDate string) ([]*dailyModel, error) {
+	var models []*dailyModel
+	_, err := d.session.Select("*").From("statistics_daily").Where("stat_date>=? and stat_date<=?", startDate, endDate).OrderAsc("stat_date").Load(&models)
+	return models, err
+}
+
+// queryExistDates 查询已经汇总过的日期
+func (d *db) queryExistDates(dates []string) ([]string, error) {
+	var existDates []string
+	if len(dates) == 0 {
+		return existDates, nil
+	}
+	_, err := d.session.Select("stat_date").From("statistics_daily").Where("stat_date in ?", dates).Load(&existDates)
+	return existDates, err
+}
+
+type dailyModel struct {
+	StatDate          string
+	RegisterCount     int64
+	ActiveCount       int64
+	MessageCount      int64
+	GroupCreatedCount int64
+	UserTotalCount    int64
+	GroupTotalCount   int64
+	StorageUsed       int64
+	dba.BaseModel
+}
@@ -0,0 +1,81 @@
+package statistics
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+)
+
+// IService 运营统计
+type IService interface {
+	// GetDailyTotal 日期范围内（包含起止日期）每日统计的合计 日期格式为 2006-01-02
+	GetDailyTotal(startDate, endDate string) (*DailyTotal, error)
+}
+
+// Service Service
+type Service struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// NewService NewService
+func NewService(ctx *config.Context) IService {
+	return &Service{
+		ctx: ctx,
+		Log: log.NewTLog("statisticsService"),
+		db:  newDB(ctx),
+	}
+}
+
+// DailyTotal 日期范围内每日统计的合计
+type DailyTotal struct {
+	RegisterCount     int64 `json:"register_count"`      // 注册用户数
+	MessageCount      int64 `json:"message_count"`       // 发送的消息数
+	GroupCreatedCount int64 `json:"group_created_count"` // 新建群数
+	MaxActiveCount    int64 `json:"max_active_count"`    // 最高日活
+	AvgActiveCount    int64 `json:"avg_active_count"`    // 平均日活
+	UserTotalCount    int64 `json:"user_total_count"`    // 最后一个已汇总日期的用户总数
+	GroupTotalCount   int64 `json:"group_total_count"`   // 最后一个已汇总日期的群总数
+	Days              int   `json:"days"`                // 日期范围的天数
+	RolledDays        int   `json:"rolled_days"`         // 已汇总的天数
+}
+
+// GetDailyTotal 日期范围内每日统计的合计
+func (s *Service) GetDailyTotal(startDate, endDate string) (*DailyTotal, error) {
+	start, err := time.ParseInLocation(dailyDateLayout, startDate, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.ParseInLocation(dailyDateLayout, endDate, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	models, err := s.db.queryDailyWithDateSpace(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	return newDailyTotal(newDailyResps(start, end, models)), nil
+}
+
+func newDailyTotal(days []*dailyResp) *DailyTotal {
+	sum := sumDaily(days)
+	total := &DailyTotal{
+		RegisterCount:     sum.RegisterCount,
+		MessageCount:      sum.MessageCount,
+		GroupCreatedCount: sum.GroupCreatedCount,
+		MaxActiveCount:    sum.MaxActiveCount,
+		AvgActiveCount:    sum.AvgActiveCount,
+		Days:              len(days),
+	}
+	for _, day := range days {
+		if !day.Rolled {
+			continue
+		}
+		total.RolledDays++
+		total.UserTotalCount = day.UserTotalCount
+		total.GroupTotalCount = day.GroupTotalCount
+	}
+	return total
+}
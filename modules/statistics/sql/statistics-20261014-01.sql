@@ -0,0 +1,18 @@
+-- +migrate Up
+
+-- 每日运营统计 每晚汇总前一天的数据 后台看板直接查询此表
+create table `statistics_daily`
+(
+  id                  bigint         not null primary key AUTO_INCREMENT,
+  stat_date           VARCHAR(10)    not null default '',  -- 日期 例如 2026-10-14
+  register_count      bigint         not null default 0,   -- 注册用户数
+  active_count        bigint         not null default 0,   -- 日活（当天在线过的用户数）
+  message_count       bigint         not null default 0,   -- 发送的消息数
+  group_created_count bigint         not null default 0,   -- 新建群数
+  user_total_count    bigint         not null default 0,   -- 汇总时的用户总数
+  group_total_count   bigint         not null default 0,   -- 汇总时的群总数
+  storage_used        bigint         not null default 0,   -- 汇总时已使用的存储空间（字节）
+  created_at          timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at          timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `statistics_daily_date_idx` on `statistics_daily` (`stat_date`);
@@ -4,12 +4,15 @@ import (
 	"embed"
 	"fmt"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rpcapi"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/model"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
-	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 //go:embed sql
@@ -27,6 +30,10 @@ func init() {
 	register.AddModule(func(ctx interface{}) register.Module {
 		x := ctx.(*config.Context)
 		api := New(x)
+		rpcapi.Register(func(s grpc.ServiceRegistrar) {
+			rpcapi.RegisterUserServiceServer(s, newUserRPC(api))
+			rpcapi.RegisterPresenceServiceServer(s, newPresenceRPC(api))
+		})
 		return register.Module{
 			Name: "user",
 			SetupAPI: func() register.APIRouter {
@@ -54,13 +61,26 @@ func init() {
 					if channelType != common.ChannelTypePerson.Uint8() {
 						return nil, register.ErrDatasourceNotProcess
 					}
+					// 其他组织的用户按不存在处理
+					if tenant.Enabled() {
+						loginTenant, err := api.tenantService.UserTenant(loginUID)
+						if err != nil {
+							return nil, err
+						}
+						if err = api.tenantService.CheckUsers(loginTenant, channelID); err != nil {
+							if err == errcode.ErrTenantCrossUser {
+								return nil, errcode.ErrUserNotExist
+							}
+							return nil, err
+						}
+					}
 					userDetailResp, err := api.userService.GetUserDetail(channelID, loginUID)
 					if err != nil {
 						return nil, err
 					}
 					if userDetailResp == nil {
 						api.Error("用户不存在！", zap.String("channel_id", channelID))
-						return nil, errors.New("用户不存在！")
+						return nil, errcode.ErrUserNotExist
 					}
 					return newChannelRespWithUserDetailResp(userDetailResp), nil
 				},
@@ -164,6 +184,7 @@ func newChannelRespWithUserDetailResp(user *UserDetailResp) *model.ChannelResp {
 	extraMap["vercode"] = user.Vercode
 	extraMap["screenshot"] = user.Screenshot
 	extraMap["revoke_remind"] = user.RevokeRemind
+	extraMap["push_privacy"] = user.PushPrivacy
 	resp.Extra = extraMap
 
 	return resp
@@ -18,7 +18,11 @@ import (
 	"unicode"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/ipguard"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/eventbus"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/httpcache"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/model"
 	"github.com/gocraft/dbr/v2"
@@ -29,6 +33,7 @@ import (
 	commonapi "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	common2 "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/network"
@@ -54,6 +59,7 @@ type User struct {
 	friendDB      *friendDB
 	deviceDB      *deviceDB
 	smsServie     commonapi.ISMSService
+	emailService  commonapi.IEmailService
 	fileService   file.IService
 	settingDB     *SettingDB
 	onlineDB      *onlineDB
@@ -73,10 +79,14 @@ type User struct {
 	identitieDB              *identitieDB
 	onetimePrekeysDB         *onetimePrekeysDB
 	maillistDB               *maillistDB
+	followDB                 *followDB
 	commonService            common2.IService
 	deviceFlagDB             *deviceFlagDB
 	deviceFlagsCache         []*deviceFlagModel
 	appService               app.IService
+	ipGuard                  *ipguard.Guard
+	registerDB               *registerDB
+	tenantService            *tenant.Service
 }
 
 // New New
@@ -87,6 +97,7 @@ func New(ctx *config.Context) *User {
 		deviceDB:                 newDeviceDB(ctx),
 		friendDB:                 newFriendDB(ctx),
 		smsServie:                commonapi.NewSMSService(ctx),
+		emailService:             commonapi.NewEmailService(ctx),
 		settingDB:                NewSettingDB(ctx.DB()),
 		setting:                  NewSetting(ctx),
 		userDeviceTokenPrefix:    common.UserDeviceTokenPrefix,
@@ -102,11 +113,15 @@ func New(ctx *config.Context) *User {
 		identitieDB:              newIdentitieDB(ctx),
 		onetimePrekeysDB:         newOnetimePrekeysDB(ctx),
 		maillistDB:               newMaillistDB(ctx),
+		followDB:                 newFollowDB(ctx),
 		deviceFlagDB:             newDeviceFlagDB(ctx),
 		giteeDB:                  newGiteeDB(ctx),
 		githubDB:                 newGithubDB(ctx),
 		commonService:            common2.NewService(ctx),
 		appService:               app.NewService(ctx),
+		ipGuard:                  ipguard.NewGuard(ctx),
+		registerDB:               newRegisterDB(ctx),
+		tenantService:            tenant.NewService(ctx),
 	}
 	u.updateSystemUserToken()
 	source.SetUserProvider(u)
@@ -147,6 +162,8 @@ func (u *User) Route(r *wkhttp.WKHttp) {
 		user.POST("/sms/destroy", u.sendDestroyCode)               //获取注销账号短信验证码
 		user.PUT("/updatepassword", u.updatePwd)                   // 修改登录密码
 		user.POST("/web3publickey", u.uploadWeb3PublicKey)         // 上传web3公钥
+		user.POST("/email/code", u.sendBindEmailCode)              // 获取绑定邮箱验证码
+		user.PUT("/email", u.bindEmail)                            // 绑定邮箱
 		// #################### 登录设备管理 ####################
 		user.GET("/devices", u.deviceList)                 // 用户登录设备
 		user.DELETE("/devices/:device_id", u.deviceDelete) // 删除登录设备
@@ -158,17 +175,26 @@ func (u *User) Route(r *wkhttp.WKHttp) {
 		user.POST("/maillist", u.addMaillist)
 		user.GET("/maillist", u.getMailList)
 
+		// #################### 单向关注 ####################
+		user.POST("/follow/:uid", u.follow)     // 关注用户
+		user.DELETE("/follow/:uid", u.unfollow) // 取消关注
+		user.GET("/followers", u.followers)     // 我的粉丝
+		user.GET("/following", u.following)     // 我的关注
+
 		// #################### 用户红点 ####################
 		user.GET("/reddot/:category", u.getRedDot)      // 获取用户红点
 		user.DELETE("/reddot/:category", u.clearRedDot) // 清除红点
 	}
 	v := r.Group("/v1")
 	{
+		// 登录和注册需要检查IP黑名单和国家限制
+		loginGuard := u.ipGuard.Middleware(ipguard.SceneLogin)
+		registerGuard := u.ipGuard.Middleware(ipguard.SceneRegister)
 
-		v.POST("/user/register", u.register)                 //用户注册
-		v.POST("/user/login", u.login)                       // 用户登录
-		v.POST("/user/usernamelogin", u.usernameLogin)       // 用户名登录
-		v.POST("/user/usernameregister", u.usernameRegister) // 用户名注册
+		v.POST("/user/register", registerGuard, u.register)                 //用户注册
+		v.POST("/user/login", loginGuard, u.login)                          // 用户登录
+		v.POST("/user/usernamelogin", loginGuard, u.usernameLogin)          // 用户名登录
+		v.POST("/user/usernameregister", registerGuard, u.usernameRegister) // 用户名注册
 
 		v.POST("/user/pwdforget_web3", u.resetPwdWithWeb3PublicKey) // 通过web3公钥重置密码
 		v.GET("/user/web3verifytext", u.getVerifyText)              // 获取验证字符串
@@ -181,26 +207,31 @@ func (u *User) Route(r *wkhttp.WKHttp) {
 		v.GET("/users/:uid/im", u.userIM)                // 获取用户所在IM节点信息
 		v.GET("/user/loginuuid", u.getLoginUUID)         // 获取扫描用的登录uuid
 		v.GET("/user/loginstatus", u.getloginStatus)
-		v.POST("/user/sms/registercode", u.sendRegisterCode)             //获取注册短信验证码
-		v.POST("/user/login_authcode/:auth_code", u.loginWithAuthCode)   // 通过认证码登录
-		v.POST("/user/sms/login_check_phone", u.sendLoginCheckPhoneCode) //发送登录设备验证验证码
-		v.POST("/user/login/check_phone", u.loginCheckPhone)             //登录验证设备手机号
+		v.POST("/user/sms/registercode", registerGuard, u.sendRegisterCode)          //获取注册短信验证码
+		v.POST("/user/login_authcode/:auth_code", loginGuard, u.loginWithAuthCode)   // 通过认证码登录
+		v.POST("/user/sms/login_check_phone", loginGuard, u.sendLoginCheckPhoneCode) //发送登录设备验证验证码
+		v.POST("/user/sms/voice", u.sendVoiceCode)                                   // 语音播报验证码
+		v.POST("/user/login/check_phone", loginGuard, u.loginCheckPhone)             //登录验证设备手机号
+		v.POST("/user/ban/appeal", u.banAppeal)                                      // 被封禁的用户提交申诉
+		v.POST("/user/email/forgetpwd", u.getForgetPwdEmail)                         // 获取忘记密码邮件验证码
+		v.POST("/user/email/pwdforget", u.pwdforgetWithEmail)                        // 通过邮箱重置登录密码
 
 		// #################### 第三方授权 ####################
 		v.GET("/user/thirdlogin/authcode", u.thirdAuthcode)     // 第三方授权码获取
 		v.GET("/user/thirdlogin/authstatus", u.thirdAuthStatus) // github认证页面
 		// github
-		v.GET("/user/github", u.github)            // github认证页面
-		v.GET("/user/oauth/github", u.githubOAuth) // github登录
+		v.GET("/user/github", u.github)                        // github认证页面
+		v.GET("/user/oauth/github", loginGuard, u.githubOAuth) // github登录
 		// gitee
-		v.GET("/user/gitee", u.gitee)            // gitee认证页面
-		v.GET("/user/oauth/gitee", u.giteeOAuth) // gitee登录
+		v.GET("/user/gitee", u.gitee)                        // gitee认证页面
+		v.GET("/user/oauth/gitee", loginGuard, u.giteeOAuth) // gitee登录
 
 	}
 
 	u.ctx.AddOnlineStatusListener(u.onlineService.listenOnlineStatus) // 监听在线状态
 	u.ctx.AddOnlineStatusListener(u.handleOnlineStatus)               // 需要放在listenOnlineStatus之后
 	u.ctx.Schedule(time.Minute*5, u.onlineStatusCheck)                // 在线状态定时检查
+	u.ctx.Schedule(time.Minute, u.autoUnban)                          // 解禁封禁到期的用户
 
 }
 
@@ -398,7 +429,7 @@ func (u *User) uploadAvatar(c *wkhttp.Context) {
 	file, _, err := c.Request.FormFile("file")
 	if err != nil {
 		u.Error("读取文件失败！", zap.Error(err))
-		c.ResponseError(errors.New("读取文件失败！"))
+		c.ResponseError(errcode.ErrReadFile)
 		return
 	}
 	avatarID := crc32.ChecksumIEEE([]byte(loginUID)) % uint32(u.ctx.GetConfig().Avatar.Partition)
@@ -409,7 +440,7 @@ func (u *User) uploadAvatar(c *wkhttp.Context) {
 	defer file.Close()
 	if err != nil {
 		u.Error("上传文件失败！", zap.Error(err))
-		c.ResponseError(errors.New("上传文件失败！"))
+		c.ResponseError(errcode.ErrUploadFile)
 		return
 	}
 	friends, err := u.friendDB.QueryFriends(loginUID)
@@ -470,7 +501,7 @@ func (u *User) qrcodeMy(c *wkhttp.Context) {
 		return
 	}
 	if userModel == nil {
-		c.ResponseError(errors.New("登录用户不存在！"))
+		c.ResponseError(errcode.ErrLoginUserNotExist)
 		return
 	}
 	if userModel.QRVercode == "" {
@@ -490,7 +521,7 @@ func (u *User) userUpdateWithField(c *wkhttp.Context) {
 	var reqMap map[string]interface{}
 	if err := c.BindJSON(&reqMap); err != nil {
 		u.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	// 查询用户信息
@@ -647,7 +678,7 @@ func (u *User) userUpdateSetting(c *wkhttp.Context) {
 	var reqMap map[string]interface{}
 	if err := c.BindJSON(&reqMap); err != nil {
 		u.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	// 查询用户信息
@@ -693,6 +724,16 @@ func (u *User) get(c *wkhttp.Context) {
 		u.ctx.GetHttpRoute().HandleContext(c)
 		return
 	}
+	// 其他组织的用户按不存在处理
+	if err := u.tenantService.CheckUsers(tenant.ID(c.Context), uid); err != nil {
+		if err == errcode.ErrTenantCrossUser {
+			c.ResponseError(errcode.ErrUserNotExist)
+			return
+		}
+		u.Error("查询用户所属组织失败！", zap.Error(err))
+		c.ResponseError(errors.New("获取用户详情失败！"))
+		return
+	}
 
 	userDetailResp, err := u.userService.GetUserDetail(uid, loginUID)
 	if err != nil {
@@ -701,7 +742,7 @@ func (u *User) get(c *wkhttp.Context) {
 		return
 	}
 	if userDetailResp == nil {
-		c.ResponseError(errors.New("用户不存在！"))
+		c.ResponseError(errcode.ErrUserNotExist)
 		return
 	}
 	isShowShortNo := false
@@ -731,7 +772,7 @@ func (u *User) get(c *wkhttp.Context) {
 			userDetailResp.Vercode = vercode
 		}
 	}
-	c.Response(userDetailResp)
+	httpcache.Response(c, userDetailResp)
 }
 
 //	获取用户详情
@@ -841,6 +882,10 @@ func (u *User) wxLogin(c *wkhttp.Context) {
 		}
 		u.execLoginAndRespose(userInfo, config.DeviceFlag(req.Flag), req.Device, loginSpanCtx, c)
 	} else {
+		if err = u.checkThirdRegister(); err != nil {
+			c.ResponseError(err)
+			return
+		}
 		// 创建用户
 		uid := util.GenerUUID()
 		var model = &createUserModel{
@@ -905,6 +950,12 @@ func (u *User) login(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
+	if userInfo == nil {
+		if err = u.pendingApplyError(req.Username); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
 	if userInfo == nil || userInfo.IsDestroy == 1 {
 		c.ResponseError(errors.New("用户不存在"))
 		return
@@ -920,8 +971,20 @@ func (u *User) login(c *wkhttp.Context) {
 	u.execLoginAndRespose(userInfo, config.DeviceFlag(req.Flag), req.Device, loginSpanCtx, c)
 }
 
+// checkLoginTenant 用户只能在所属的组织登录
+func checkLoginTenant(userInfo *Model, c *wkhttp.Context) error {
+	if tenant.Enabled() && userInfo.TenantID != tenant.ID(c.Context) {
+		return errcode.ErrTenantMismatch
+	}
+	return nil
+}
+
 // 验证登录用户信息
 func (u *User) execLoginAndRespose(userInfo *Model, flag config.DeviceFlag, device *deviceReq, loginSpanCtx context.Context, c *wkhttp.Context) {
+	if err := checkLoginTenant(userInfo, c); err != nil {
+		c.ResponseError(err)
+		return
+	}
 
 	result, err := u.execLogin(userInfo, flag, device, loginSpanCtx)
 	if err != nil {
@@ -938,7 +1001,7 @@ func (u *User) execLoginAndRespose(userInfo *Model, flag config.DeviceFlag, devi
 			})
 			return
 		}
-		c.ResponseError(err)
+		u.responseLoginError(c, err)
 		return
 	}
 
@@ -950,7 +1013,9 @@ func (u *User) execLoginAndRespose(userInfo *Model, flag config.DeviceFlag, devi
 
 func (u *User) execLogin(userInfo *Model, flag config.DeviceFlag, device *deviceReq, loginSpanCtx context.Context) (*loginUserDetailResp, error) {
 	if userInfo.Status == int(common.UserDisable) {
-		return nil, errors.New("该用户已被禁用")
+		if err := u.checkBan(userInfo); err != nil {
+			return nil, err
+		}
 	}
 	deviceLevel := config.DeviceLevelSlave
 	if flag == config.APP {
@@ -1058,7 +1123,7 @@ func (u *User) execLogin(userInfo *Model, flag config.DeviceFlag, device *device
 	updateTokenSpan.Finish()
 
 	if imResp.Status == config.UpdateTokenStatusBan {
-		return nil, errors.New("此账号已经被封禁！")
+		return nil, errcode.ErrAccountBanned
 	}
 
 	return newLoginUserDetailResp(userInfo, token, u.ctx), nil
@@ -1175,10 +1240,15 @@ func (u *User) register(c *wkhttp.Context) {
 		c.ResponseError(errors.New("该用户已存在"))
 		return
 	}
+	control, err := u.checkRegister(fmt.Sprintf("%s%s", req.Zone, req.Phone))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
 	//测试模式
 	if strings.TrimSpace(u.ctx.GetConfig().SMSCode) != "" {
 		if strings.TrimSpace(u.ctx.GetConfig().SMSCode) != req.Code {
-			c.ResponseError(errors.New("验证码错误"))
+			c.ResponseError(errcode.ErrVerifyCodeInvalid)
 			return
 		}
 	} else {
@@ -1189,6 +1259,16 @@ func (u *User) register(c *wkhttp.Context) {
 			return
 		}
 	}
+	if control.closedBeta {
+		u.submitRegisterApply(c, &registerApplyModel{
+			Username: fmt.Sprintf("%s%s", req.Zone, req.Phone),
+			Zone:     req.Zone,
+			Phone:    req.Phone,
+			Name:     req.Name,
+			Password: req.Password,
+		})
+		return
+	}
 	uid := util.GenerUUID()
 	var model = &createUserModel{
 		UID:      uid,
@@ -1209,10 +1289,11 @@ func (u *User) search(c *wkhttp.Context) {
 	useModel, err := u.db.QueryByKeyword(keyword)
 	if err != nil {
 		u.Error("查询用户信息失败！", zap.Error(err), zap.String("keyword", keyword))
-		c.ResponseError(errors.New("查询用户信息失败！"))
+		c.ResponseError(errcode.ErrQueryUser)
 		return
 	}
-	if useModel == nil {
+	// 只能搜索到同一组织的用户
+	if useModel == nil || (tenant.Enabled() && useModel.TenantID != tenant.ID(c.Context)) {
 		c.JSON(http.StatusOK, gin.H{
 			"exist": 0,
 		})
@@ -1249,20 +1330,24 @@ func (u *User) search(c *wkhttp.Context) {
 func (u *User) registerUserDeviceToken(c *wkhttp.Context) {
 	loginUID := c.MustGet("uid").(string)
 	var req struct {
-		DeviceToken string `json:"device_token"` // 设备token
-		DeviceType  string `json:"device_type"`  // 设备类型 IOS，MI，HMS
-		BundleID    string `json:"bundle_id"`    // app的唯一ID标示
+		DeviceToken  string `json:"device_token"` // 设备token
+		DeviceType   string `json:"device_type"`  // 设备类型 IOS，MI，HMS
+		BundleID     string `json:"bundle_id"`    // app的唯一ID标示
+		Manufacturer string `json:"manufacturer"` // 手机厂商（android的Build.MANUFACTURER） 推送时按厂商选择推送通道
+		Locale       string `json:"locale"`       // 设备的语言 例如 zh-CN、en 为空时使用Accept-Language 推送时按语言选择文案
+		VoIPToken    string `json:"voip_token"`   // iOS PushKit的token 来电时使用VoIP推送
+		FCMToken     string `json:"fcm_token"`    // android设备同时注册的FCM token 厂商通道不可用时使用FCM推送
 	}
 	if err := c.BindJSON(&req); err != nil {
 		u.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if strings.TrimSpace(req.DeviceToken) == "" {
 		c.ResponseError(errors.New("设备token不能为空！"))
 		return
 	}
-	if strings.TrimSpace(req.DeviceType) == "" {
+	if strings.TrimSpace(req.DeviceType) == "" && strings.TrimSpace(req.Manufacturer) == "" {
 		c.ResponseError(errors.New("设备类型不能为空！"))
 		return
 	}
@@ -1270,7 +1355,11 @@ func (u *User) registerUserDeviceToken(c *wkhttp.Context) {
 		c.ResponseError(errors.New("bundleID不能为空！"))
 		return
 	}
-	err := u.ctx.GetRedisConn().Hmset(fmt.Sprintf("%s%s", u.userDeviceTokenPrefix, loginUID), "device_type", req.DeviceType, "device_token", req.DeviceToken, "bundle_id", req.BundleID)
+	locale := strings.TrimSpace(req.Locale)
+	if locale == "" {
+		locale = c.GetHeader("Accept-Language")
+	}
+	err := u.ctx.GetRedisConn().Hmset(fmt.Sprintf("%s%s", u.userDeviceTokenPrefix, loginUID), "device_type", req.DeviceType, "device_token", req.DeviceToken, "bundle_id", req.BundleID, "manufacturer", strings.TrimSpace(req.Manufacturer), "locale", locale, "voip_token", strings.TrimSpace(req.VoIPToken), "fcm_token", strings.TrimSpace(req.FCMToken))
 	if err != nil {
 		u.Error("存储用户设备token失败！", zap.Error(err))
 		c.ResponseError(errors.New("存储用户设备token失败！"))
@@ -1287,7 +1376,7 @@ func (u *User) registerUserDeviceBadge(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		u.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	err := u.setUserBadge(loginUID, int64(req.Badge))
@@ -1432,7 +1521,7 @@ func (u *User) loginWithAuthCode(c *wkhttp.Context) {
 	userModel, err := u.db.QueryByUID(scaner)
 	if err != nil {
 		u.Error("用户不存在！", zap.String("uid", scaner), zap.Error(err))
-		c.ResponseError(errors.New("用户不存在！"))
+		c.ResponseError(errcode.ErrUserNotExist)
 		return
 	}
 
@@ -1448,7 +1537,7 @@ func (u *User) loginWithAuthCode(c *wkhttp.Context) {
 		return
 	}
 	if imResp.Status == config.UpdateTokenStatusBan {
-		c.ResponseError(errors.New("此账号已经被封禁！"))
+		c.ResponseError(errcode.ErrAccountBanned)
 		return
 	}
 
@@ -1583,8 +1672,9 @@ func (u *User) addBlacklist(c *wkhttp.Context) {
 	//如果没有设置记录先添加一条记录
 	if model == nil || strings.TrimSpace(model.UID) == "" {
 		userSettingModel := &SettingModel{
-			UID:   loginUID,
-			ToUID: uid,
+			UID:      loginUID,
+			ToUID:    uid,
+			TenantID: tenant.ID(c.Context),
 		}
 		err = u.settingDB.InsertUserSettingModel(userSettingModel)
 		if err != nil {
@@ -1774,11 +1864,11 @@ func (u *User) sendRegisterCode(c *wkhttp.Context) {
 		return
 	}
 	if strings.TrimSpace(req.Zone) == "" {
-		c.ResponseError(errors.New("区号不能为空！"))
+		c.ResponseError(errcode.ErrZoneRequired)
 		return
 	}
 	if strings.TrimSpace(req.Phone) == "" {
-		c.ResponseError(errors.New("手机号不能为空！"))
+		c.ResponseError(errcode.ErrPhoneRequired)
 		return
 	}
 	if u.ctx.GetConfig().Register.OnlyChina {
@@ -1798,7 +1888,7 @@ func (u *User) sendRegisterCode(c *wkhttp.Context) {
 	model, err := u.db.QueryByPhone(req.Zone, req.Phone)
 	if err != nil {
 		u.Error("查询用户信息失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询用户信息失败！"))
+		c.ResponseError(errcode.ErrQueryUser)
 		return
 	}
 	if model != nil {
@@ -1807,7 +1897,11 @@ func (u *User) sendRegisterCode(c *wkhttp.Context) {
 		})
 		return
 	}
-	err = u.smsServie.SendVerifyCode(spanCtx, req.Zone, req.Phone, commonapi.CodeTypeRegister)
+	if _, err = u.checkRegister(fmt.Sprintf("%s%s", req.Zone, req.Phone)); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	err = u.smsServie.SendVerifyCode(commonapi.WithSMSRequest(spanCtx, c), req.Zone, req.Phone, commonapi.CodeTypeRegister)
 	if err != nil {
 		u.Error("发送短信验证码失败", zap.Error(err))
 		c.ResponseError(errors.New("发送短信验证码失败！"))
@@ -1924,11 +2018,11 @@ func (u *User) sendLoginCheckPhoneCode(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		u.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if req.UID == "" {
-		c.ResponseError(errors.New("uid不能为空！"))
+		c.ResponseError(errcode.ErrUIDRequired)
 		return
 	}
 
@@ -1955,7 +2049,7 @@ func (u *User) sendLoginCheckPhoneCode(c *wkhttp.Context) {
 	// 	c.ResponseOK()
 	// 	return
 	// }
-	err = u.smsServie.SendVerifyCode(spanCtx, userinfo.Zone, userinfo.Phone, commonapi.CodeTypeCheckMobile)
+	err = u.smsServie.SendVerifyCode(commonapi.WithSMSRequest(spanCtx, c), userinfo.Zone, userinfo.Phone, commonapi.CodeTypeCheckMobile)
 	if err != nil {
 		u.Error("发送短信失败", zap.Error(err))
 		ext.LogError(span, err)
@@ -1973,15 +2067,15 @@ func (u *User) loginCheckPhone(c *wkhttp.Context) {
 	}
 	if err := c.BindJSON(&req); err != nil {
 		u.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	if req.UID == "" {
-		c.ResponseError(errors.New("uid不能为空！"))
+		c.ResponseError(errcode.ErrUIDRequired)
 		return
 	}
 	if req.Code == "" {
-		c.ResponseError(errors.New("验证码不能为空！"))
+		c.ResponseError(errcode.ErrVerifyCodeRequired)
 		return
 	}
 	span := u.ctx.Tracer().StartSpan(
@@ -2059,7 +2153,7 @@ func (u *User) loginCheckPhone(c *wkhttp.Context) {
 		return
 	}
 	if imResp.Status == config.UpdateTokenStatusBan {
-		c.ResponseError(errors.New("此账号已经被封禁！"))
+		c.ResponseError(errcode.ErrAccountBanned)
 		return
 	}
 	c.Response(newLoginUserDetailResp(userInfo, token, u.ctx))
@@ -2098,7 +2192,7 @@ func (u *User) sendDestroyCode(c *wkhttp.Context) {
 		c.ResponseError(errors.New("登录用户不存在"))
 		return
 	}
-	err = u.smsServie.SendVerifyCode(c.Context, userInfo.Zone, userInfo.Phone, commonapi.CodeTypeDestroyAccount)
+	err = u.smsServie.SendVerifyCode(commonapi.WithSMSRequest(c.Context, c), userInfo.Zone, userInfo.Phone, commonapi.CodeTypeDestroyAccount)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -2244,19 +2338,19 @@ func (u *User) pwdforget(c *wkhttp.Context) {
 		return
 	}
 	if strings.TrimSpace(req.Zone) == "" {
-		c.ResponseError(errors.New("区号不能为空！"))
+		c.ResponseError(errcode.ErrZoneRequired)
 		return
 	}
 	if strings.TrimSpace(req.Phone) == "" {
-		c.ResponseError(errors.New("手机号不能为空！"))
+		c.ResponseError(errcode.ErrPhoneRequired)
 		return
 	}
 	if strings.TrimSpace(req.Code) == "" {
-		c.ResponseError(errors.New("验证码不能为空！"))
+		c.ResponseError(errcode.ErrVerifyCodeRequired)
 		return
 	}
 	if strings.TrimSpace(req.Pwd) == "" {
-		c.ResponseError(errors.New("密码不能为空！"))
+		c.ResponseError(errcode.ErrPasswordRequired)
 		return
 	}
 	userInfo, err := u.db.QueryByPhone(req.Zone, req.Phone)
@@ -2272,7 +2366,7 @@ func (u *User) pwdforget(c *wkhttp.Context) {
 	//测试模式
 	if strings.TrimSpace(u.ctx.GetConfig().SMSCode) != "" {
 		if strings.TrimSpace(u.ctx.GetConfig().SMSCode) != req.Code {
-			c.ResponseError(errors.New("验证码错误"))
+			c.ResponseError(errcode.ErrVerifyCodeInvalid)
 			return
 		}
 	} else {
@@ -2301,11 +2395,11 @@ func (u *User) getForgetPwdSMS(c *wkhttp.Context) {
 		return
 	}
 	if strings.TrimSpace(req.Zone) == "" {
-		c.ResponseError(errors.New("区号不能为空！"))
+		c.ResponseError(errcode.ErrZoneRequired)
 		return
 	}
 	if strings.TrimSpace(req.Phone) == "" {
-		c.ResponseError(errors.New("手机号不能为空！"))
+		c.ResponseError(errcode.ErrPhoneRequired)
 		return
 	}
 
@@ -2319,14 +2413,14 @@ func (u *User) getForgetPwdSMS(c *wkhttp.Context) {
 	model, err := u.db.QueryByPhone(req.Zone, req.Phone)
 	if err != nil {
 		u.Error("查询用户信息失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询用户信息失败！"))
+		c.ResponseError(errcode.ErrQueryUser)
 		return
 	}
 	if model == nil {
 		c.ResponseError(errors.New("该手机号未注册"))
 		return
 	}
-	err = u.smsServie.SendVerifyCode(spanCtx, req.Zone, req.Phone, commonapi.CodeTypeForgetLoginPWD)
+	err = u.smsServie.SendVerifyCode(commonapi.WithSMSRequest(spanCtx, c), req.Zone, req.Phone, commonapi.CodeTypeForgetLoginPWD)
 	if err != nil {
 		u.Error("发送短信验证码失败", zap.Error(err))
 		c.ResponseError(errors.New("发送短信验证码失败！"))
@@ -2335,6 +2429,59 @@ func (u *User) getForgetPwdSMS(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 
+// 语音播报验证码（短信收不到时使用，需先获取过短信验证码）
+func (u *User) sendVoiceCode(c *wkhttp.Context) {
+	var req voiceCodeReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if req.CodeType < commonapi.CodeTypeRegister || req.CodeType > commonapi.CodeTypeDestroyAccount {
+		c.ResponseError(errors.New("验证码类型有误！"))
+		return
+	}
+	zone := strings.TrimSpace(req.Zone)
+	phone := strings.TrimSpace(req.Phone)
+	if strings.TrimSpace(req.UID) != "" {
+		userInfo, err := u.db.QueryByUID(req.UID)
+		if err != nil {
+			u.Error("查询用户信息失败！", zap.Error(err))
+			c.ResponseError(errcode.ErrQueryUser)
+			return
+		}
+		if userInfo == nil {
+			c.ResponseError(errors.New("该用户不存在"))
+			return
+		}
+		zone = userInfo.Zone
+		phone = userInfo.Phone
+	}
+	if zone == "" {
+		c.ResponseError(errcode.ErrZoneRequired)
+		return
+	}
+	if phone == "" {
+		c.ResponseError(errcode.ErrPhoneRequired)
+		return
+	}
+
+	span := u.ctx.Tracer().StartSpan(
+		"user.sendVoiceCode",
+		opentracing.ChildOf(c.GetSpanContext()),
+	)
+	defer span.Finish()
+	spanCtx := u.ctx.Tracer().ContextWithSpan(context.Background(), span)
+
+	err := u.smsServie.SendVoiceVerifyCode(spanCtx, zone, phone, req.CodeType)
+	if err != nil {
+		u.Error("发送语音验证码失败", zap.Error(err))
+		ext.LogError(span, err)
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
 // 是否允许更新
 func allowUpdateUserField(field string) bool {
 	allowfields := []string{"sex", "short_no", "name", "search_by_phone", "search_by_short", "new_msg_notice", "msg_show_detail", "voice_on", "shock_on", "msg_expire_second"}
@@ -2355,6 +2502,7 @@ func (u *User) createUser(registerSpanCtx context.Context, createUser *createUse
 		}
 	}()
 	publicIP := util.GetClientPublicIP(c.Request)
+	createUser.TenantID = tenant.ID(c.Context)
 	resp, err := u.createUserWithRespAndTx(registerSpanCtx, createUser, publicIP, invite, tx, func() error {
 		err := tx.Commit()
 		if err != nil {
@@ -2367,7 +2515,7 @@ func (u *User) createUser(registerSpanCtx context.Context, createUser *createUse
 	})
 	if err != nil {
 		tx.Rollback()
-		c.ResponseError(errors.New("注册失败！"))
+		c.ResponseError(registerError(err))
 		return
 	}
 	c.Response(resp)
@@ -2375,19 +2523,31 @@ func (u *User) createUser(registerSpanCtx context.Context, createUser *createUse
 
 func (u *User) createUserTx(registerSpanCtx context.Context, createUser *createUserModel, c *wkhttp.Context, commitCallback func() error, invite *model.Invite, tx *dbr.Tx) {
 	publicIP := util.GetClientPublicIP(c.Request)
+	createUser.TenantID = tenant.ID(c.Context)
 	resp, err := u.createUserWithRespAndTx(registerSpanCtx, createUser, publicIP, invite, tx, commitCallback)
 	if err != nil {
-		c.ResponseError(errors.New("注册失败！"))
+		c.ResponseError(registerError(err))
 		return
 	}
 	c.Response(resp)
 }
 
+// registerError 注册失败返回给客户端的错误 组织的用户数达到上限时提示用户
+func registerError(err error) error {
+	if errors.Is(err, errcode.ErrTenantUserQuota) {
+		return err
+	}
+	return errcode.ErrRegister
+}
+
 func (u *User) createUserWithRespAndTx(registerSpanCtx context.Context, createUser *createUserModel, publicIP string, invite *model.Invite, tx *dbr.Tx, commitCallback func() error) (*loginUserDetailResp, error) {
 	var (
 		shortNo = ""
 		err     error
 	)
+	if err = u.tenantService.CheckUserQuota(createUser.TenantID); err != nil {
+		return nil, err
+	}
 	if u.ctx.GetConfig().ShortNo.NumOn {
 		shortNo, err = u.commonService.GetShortno()
 		if err != nil {
@@ -2443,6 +2603,7 @@ func (u *User) createUserWithRespAndTx(registerSpanCtx context.Context, createUs
 	userModel.WXUnionid = createUser.WXUnionid
 	userModel.GiteeUID = createUser.GiteeUID
 	userModel.GithubUID = createUser.GithubUID
+	userModel.TenantID = createUser.TenantID
 
 	userModel.Status = int(common.UserAvailable)
 	err = u.db.insertTx(userModel, tx)
@@ -2501,6 +2662,12 @@ func (u *User) createUserWithRespAndTx(registerSpanCtx context.Context, createUs
 		commitCallback()
 	}
 	u.ctx.EventCommit(eventID)
+	eventbus.Publish(eventbus.NewEvent(eventbus.UserRegistered, createUser.UID, &eventbus.UserRegisteredData{
+		UID:       createUser.UID,
+		Name:      userModel.Name,
+		InviteUID: inviteUID,
+		Source:    "app",
+	}))
 	token := util.GenerUUID()
 	// 将token设置到缓存
 	err = u.ctx.Cache().SetAndExpire(u.ctx.GetConfig().Cache.TokenCachePrefix+token, fmt.Sprintf("%s@%s@%s", userModel.UID, userModel.Name, userModel.Role), u.ctx.GetConfig().Cache.TokenExpire)
@@ -2546,6 +2713,7 @@ type createUserModel struct {
 	Flag           int
 	IsUploadAvatar int
 	Device         *deviceReq
+	TenantID       string // 注册的组织
 }
 
 // 重置登录密码
@@ -2572,16 +2740,16 @@ type registerReq struct {
 
 func (r registerReq) CheckRegister() error {
 	if strings.TrimSpace(r.Zone) == "" {
-		return errors.New("区号不能为空！")
+		return errcode.ErrZoneRequired
 	}
 	if strings.TrimSpace(r.Phone) == "" {
-		return errors.New("手机号不能为空！")
+		return errcode.ErrPhoneRequired
 	}
 	if strings.TrimSpace(r.Code) == "" {
-		return errors.New("验证码不能为空！")
+		return errcode.ErrVerifyCodeRequired
 	}
 	if strings.TrimSpace(r.Password) == "" {
-		return errors.New("密码不能为空！")
+		return errcode.ErrPasswordRequired
 	}
 	if len(r.Password) < 6 {
 		return errors.New("密码长度必须大于6位！")
@@ -2600,6 +2768,13 @@ type codeReq struct {
 	Zone  string `json:"zone"`
 	Phone string `json:"phone"`
 }
+
+type voiceCodeReq struct {
+	Zone     string             `json:"zone"`
+	Phone    string             `json:"phone"`
+	UID      string             `json:"uid"`       // 不知道手机号时（例如登录设备验证）可传uid
+	CodeType commonapi.CodeType `json:"code_type"` // 验证码类型 0.注册 2.忘记密码 3.登录设备验证 4.注销账号
+}
 type loginReq struct {
 	Username string     `json:"username"`
 	Password string     `json:"password"`
@@ -2609,10 +2784,10 @@ type loginReq struct {
 
 func (r loginReq) Check() error {
 	if strings.TrimSpace(r.Username) == "" {
-		return errors.New("用户名不能为空！")
+		return errcode.ErrUsernameRequired
 	}
 	if strings.TrimSpace(r.Password) == "" {
-		return errors.New("密码不能为空！")
+		return errcode.ErrPasswordRequired
 	}
 	return nil
 }
@@ -0,0 +1,179 @@
+package user
+
+import (
+	"errors"
+	"strings"
+
+	commonapi "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 获取绑定邮箱验证码
+func (u *User) sendBindEmailCode(c *wkhttp.Context) {
+	var req emailCodeReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	email := commonapi.NormalizeEmail(req.Email)
+	if err := commonapi.CheckEmail(email); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	loginUID := c.GetLoginUID()
+	existUser, err := u.db.queryByEmail(email)
+	if err != nil {
+		u.Error("查询用户信息失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrQueryUser)
+		return
+	}
+	if existUser != nil {
+		if existUser.UID == loginUID {
+			c.ResponseError(errors.New("已绑定该邮箱！"))
+		} else {
+			c.ResponseError(errors.New("该邮箱已被其他账号绑定！"))
+		}
+		return
+	}
+	err = u.emailService.SendVerifyCode(c.Context, email, commonapi.CodeTypeBindEmail)
+	if err != nil {
+		u.Error("发送邮件验证码失败", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 绑定邮箱
+func (u *User) bindEmail(c *wkhttp.Context) {
+	var req emailVerifyReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	email := commonapi.NormalizeEmail(req.Email)
+	if err := commonapi.CheckEmail(email); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		c.ResponseError(errcode.ErrVerifyCodeRequired)
+		return
+	}
+	loginUID := c.GetLoginUID()
+	err := u.emailService.Verify(c.Context, email, req.Code, commonapi.CodeTypeBindEmail)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	existUser, err := u.db.queryByEmail(email)
+	if err != nil {
+		u.Error("查询用户信息失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrQueryUser)
+		return
+	}
+	if existUser != nil && existUser.UID != loginUID {
+		c.ResponseError(errors.New("该邮箱已被其他账号绑定！"))
+		return
+	}
+	err = u.db.UpdateUsersWithField("email", email, loginUID)
+	if err != nil {
+		u.Error("绑定邮箱失败！", zap.Error(err))
+		c.ResponseError(errors.New("绑定邮箱失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 获取忘记密码邮件验证码
+func (u *User) getForgetPwdEmail(c *wkhttp.Context) {
+	var req emailCodeReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	email := commonapi.NormalizeEmail(req.Email)
+	if err := commonapi.CheckEmail(email); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := u.db.queryByEmail(email)
+	if err != nil {
+		u.Error("查询用户信息失败！", zap.Error(err))
+		c.ResponseError(errcode.ErrQueryUser)
+		return
+	}
+	if model == nil {
+		c.ResponseError(errors.New("该邮箱未绑定账号"))
+		return
+	}
+	err = u.emailService.SendVerifyCode(c.Context, email, commonapi.CodeTypeForgetLoginPWD)
+	if err != nil {
+		u.Error("发送邮件验证码失败", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 通过邮箱重置登录密码
+func (u *User) pwdforgetWithEmail(c *wkhttp.Context) {
+	var req emailResetPwdReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	email := commonapi.NormalizeEmail(req.Email)
+	if err := commonapi.CheckEmail(email); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		c.ResponseError(errcode.ErrVerifyCodeRequired)
+		return
+	}
+	if strings.TrimSpace(req.Pwd) == "" {
+		c.ResponseError(errcode.ErrPasswordRequired)
+		return
+	}
+	userInfo, err := u.db.queryByEmail(email)
+	if err != nil {
+		u.Error("查询用户信息错误", zap.Error(err))
+		c.ResponseError(errors.New("查询用户信息错误"))
+		return
+	}
+	if userInfo == nil {
+		c.ResponseError(errors.New("该账号不存在"))
+		return
+	}
+	err = u.emailService.Verify(c.Context, email, req.Code, commonapi.CodeTypeForgetLoginPWD)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	err = u.db.UpdateUsersWithField("password", util.MD5(util.MD5(req.Pwd)), userInfo.UID)
+	if err != nil {
+		u.Error("修改登录密码错误", zap.Error(err))
+		c.ResponseError(errors.New("修改登录密码错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+type emailCodeReq struct {
+	Email string `json:"email"` // 邮箱
+}
+
+type emailVerifyReq struct {
+	Email string `json:"email"` // 邮箱
+	Code  string `json:"code"`  // 验证码
+}
+
+type emailResetPwdReq struct {
+	Email string `json:"email"` // 邮箱
+	Code  string `json:"code"`  // 验证码
+	Pwd   string `json:"pwd"`   // 新密码
+}
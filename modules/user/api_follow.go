@@ -0,0 +1,222 @@
+package user
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// 关注用户（单向关注，不产生聊天权限）
+func (u *User) follow(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	toUID := c.Param("uid")
+	if strings.TrimSpace(toUID) == "" {
+		c.ResponseError(errors.New("关注的用户ID不能为空！"))
+		return
+	}
+	if toUID == loginUID {
+		c.ResponseError(errors.New("不能关注自己！"))
+		return
+	}
+	if err := u.checkFollowOn(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := u.tenantService.CheckUsers(tenant.ID(c.Context), toUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	toUser, err := u.db.QueryByUID(toUID)
+	if err != nil {
+		u.Error("查询用户信息错误", zap.Error(err))
+		c.ResponseError(errors.New("查询用户信息错误"))
+		return
+	}
+	if toUser == nil || toUser.IsDestroy == 1 {
+		c.ResponseError(errors.New("关注的用户不存在！"))
+		return
+	}
+	blacklist, err := u.friendDB.existBlacklist(loginUID, toUID)
+	if err != nil {
+		u.Error("查询黑名单错误", zap.Error(err))
+		c.ResponseError(errors.New("查询黑名单错误"))
+		return
+	}
+	if blacklist {
+		c.ResponseError(errors.New("对方已将你拉黑或你已将对方拉黑，无法关注！"))
+		return
+	}
+	exist, err := u.followDB.exist(loginUID, toUID)
+	if err != nil {
+		u.Error("查询关注关系错误", zap.Error(err))
+		c.ResponseError(errors.New("查询关注关系错误"))
+		return
+	}
+	if exist {
+		c.ResponseOK()
+		return
+	}
+	err = u.followDB.insert(&followModel{
+		UID:   loginUID,
+		ToUID: toUID,
+	})
+	if err != nil {
+		u.Error("关注用户错误", zap.Error(err))
+		c.ResponseError(errors.New("关注用户错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 取消关注
+func (u *User) unfollow(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	toUID := c.Param("uid")
+	if strings.TrimSpace(toUID) == "" {
+		c.ResponseError(errors.New("取消关注的用户ID不能为空！"))
+		return
+	}
+	err := u.followDB.delete(loginUID, toUID)
+	if err != nil {
+		u.Error("取消关注错误", zap.Error(err))
+		c.ResponseError(errors.New("取消关注错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 我的粉丝列表
+func (u *User) followers(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	pageIndex, pageSize := u.followPage(c)
+	models, err := u.followDB.queryFollowersWithPage(loginUID, pageSize, pageIndex)
+	if err != nil {
+		u.Error("查询粉丝列表错误", zap.Error(err))
+		c.ResponseError(errors.New("查询粉丝列表错误"))
+		return
+	}
+	count, err := u.followDB.queryFollowerCount(loginUID)
+	if err != nil {
+		u.Error("查询粉丝数量错误", zap.Error(err))
+		c.ResponseError(errors.New("查询粉丝数量错误"))
+		return
+	}
+	uids := make([]string, 0, len(models))
+	for _, m := range models {
+		uids = append(uids, m.UID)
+	}
+	// 我是否也关注了对方
+	mutualModels, err := u.followDB.queryWithUIDAndToUIDs(loginUID, uids)
+	if err != nil {
+		u.Error("查询互相关注关系错误", zap.Error(err))
+		c.ResponseError(errors.New("查询互相关注关系错误"))
+		return
+	}
+	mutualMap := map[string]bool{}
+	for _, m := range mutualModels {
+		mutualMap[m.ToUID] = true
+	}
+	list := make([]*followResp, 0, len(models))
+	for _, m := range models {
+		resp := newFollowResp(m, m.UID)
+		if mutualMap[m.UID] {
+			resp.Mutual = 1
+		}
+		list = append(list, resp)
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+// 我的关注列表
+func (u *User) following(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	pageIndex, pageSize := u.followPage(c)
+	models, err := u.followDB.queryFollowingWithPage(loginUID, pageSize, pageIndex)
+	if err != nil {
+		u.Error("查询关注列表错误", zap.Error(err))
+		c.ResponseError(errors.New("查询关注列表错误"))
+		return
+	}
+	count, err := u.followDB.queryFollowingCount(loginUID)
+	if err != nil {
+		u.Error("查询关注数量错误", zap.Error(err))
+		c.ResponseError(errors.New("查询关注数量错误"))
+		return
+	}
+	uids := make([]string, 0, len(models))
+	for _, m := range models {
+		uids = append(uids, m.ToUID)
+	}
+	// 对方是否也关注了我
+	mutualModels, err := u.followDB.queryWithUIDsAndToUID(uids, loginUID)
+	if err != nil {
+		u.Error("查询互相关注关系错误", zap.Error(err))
+		c.ResponseError(errors.New("查询互相关注关系错误"))
+		return
+	}
+	mutualMap := map[string]bool{}
+	for _, m := range mutualModels {
+		mutualMap[m.UID] = true
+	}
+	list := make([]*followResp, 0, len(models))
+	for _, m := range models {
+		resp := newFollowResp(m, m.ToUID)
+		if mutualMap[m.ToUID] {
+			resp.Mutual = 1
+		}
+		list = append(list, resp)
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+func (u *User) followPage(c *wkhttp.Context) (uint64, uint64) {
+	pageIndex, _ := strconv.ParseUint(c.Query("page_index"), 10, 64)
+	pageSize, _ := strconv.ParseUint(c.Query("page_size"), 10, 64)
+	if pageIndex <= 0 {
+		pageIndex = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	return pageIndex, pageSize
+}
+
+// 检查是否开启了单向关注
+func (u *User) checkFollowOn() error {
+	appConfig, err := u.commonService.GetAppConfig()
+	if err != nil {
+		u.Error("查询应用配置失败！", zap.Error(err))
+		return errors.New("查询应用配置失败！")
+	}
+	if appConfig == nil || appConfig.FollowOn != 1 {
+		return errors.New("关注功能未开启！")
+	}
+	return nil
+}
+
+type followResp struct {
+	UID       string `json:"uid"`
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	Mutual    int    `json:"mutual"` // 是否互相关注 1.是
+	CreatedAt string `json:"created_at"`
+}
+
+func newFollowResp(m *followDetailModel, uid string) *followResp {
+	return &followResp{
+		UID:       uid,
+		Name:      m.Name,
+		Username:  m.Username,
+		CreatedAt: m.CreatedAt.String(),
+	}
+}
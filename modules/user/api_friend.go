@@ -5,10 +5,15 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	chservice "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/channel/service"
+	common2 "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/cursor"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -20,15 +25,21 @@ import (
 	"go.uber.org/zap"
 )
 
+// friendSyncMaxLimit 同步好友每次最多返回的数量
+const friendSyncMaxLimit = 1000
+
 // Friend 好友
 type Friend struct {
 	ctx *config.Context
 	log.Log
 	db            *friendDB
+	reminderDB    *friendReminderDB
 	settingDB     *SettingDB
 	userDB        *DB
 	onlineService IOnlineService
 	userService   IService
+	tenantService *tenant.Service
+	commonService common2.IService
 }
 
 // NewFriend 创建
@@ -38,9 +49,12 @@ func NewFriend(ctx *config.Context) *Friend {
 		Log:           log.NewTLog("Friend"),
 		userDB:        NewDB(ctx),
 		db:            newFriendDB(ctx),
+		reminderDB:    newFriendReminderDB(ctx),
 		onlineService: NewOnlineService(ctx),
 		settingDB:     NewSettingDB(ctx.DB()),
 		userService:   NewService(ctx),
+		tenantService: tenant.NewService(ctx),
+		commonService: common2.NewService(ctx),
 	}
 	f.ctx.AddEventListener(event.FriendSure, f.handleFriendSure)
 	f.ctx.AddEventListener(event.FriendDelete, f.handleDeleteFriend)
@@ -58,13 +72,20 @@ func (f *Friend) Route(r *wkhttp.WKHttp) {
 		friend.PUT("/refuse/:to_uid", f.refuseApply)   // 拒绝申请
 		friend.POST("/sure", f.friendSure)             // 好友确认
 		friend.GET("/sync", f.friendSync)              // 同步好友
+		friend.GET("/sync/diff", f.friendSyncDiff)     // 增量同步好友（新增/更新/删除）
 		friend.GET("/search", f.friendSearch)          // 查询好友
 		friend.PUT("/remark", f.remark)                //好友备注
+		friend.GET("/reminder/:uid", f.getReminder)    // 好友生日/纪念日设置
+		friend.PUT("/reminder", f.updateReminder)      // 修改好友生日/纪念日提醒
+		friend.GET("/export", f.exportContacts)        // 导出好友
+		friend.POST("/import", f.importContacts)       // 导入好友（支持预览）
 	}
 	friends := r.Group("/v1/friends", f.ctx.AuthMiddleware(r))
 	{
 		friends.DELETE("/:uid", f.delete) //删除好友
 	}
+
+	f.ctx.Schedule(time.Minute*10, f.friendReminderCheck) // 好友生日/纪念日提醒
 }
 
 // 拒绝申请
@@ -114,18 +135,52 @@ func (f *Friend) deleteApply(c *wkhttp.Context) {
 }
 
 // 好友申请列表
+// 带cursor参数时按游标分页返回 从最新的申请开始
 func (f *Friend) apply(c *wkhttp.Context) {
 	loginUID := c.GetLoginUID()
-	page := c.Query("page_index")
-	size := c.Query("page_size")
-	pageIndex, _ := strconv.Atoi(page)
-	pageSize, _ := strconv.Atoi(size)
-	applys, err := f.db.queryApplysWithPage(loginUID, uint64(pageSize), uint64(pageIndex))
+	if cursor.Requested(c) {
+		page, err := cursor.Query(c)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		applys, err := f.db.queryApplysWithCursor(loginUID, page.Key(0), page.FetchLimit())
+		if err != nil {
+			f.Error("查询好友申请列表错误", zap.Error(err))
+			c.ResponseError(errors.New("查询好友申请列表错误"))
+			return
+		}
+		n, hasMore := page.Keep(len(applys))
+		applys = applys[:n]
+		list, err := f.applyResps(applys)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		var lastID int64
+		if n > 0 {
+			lastID = applys[n-1].Id
+		}
+		c.Response(cursor.NewResult(list, hasMore, lastID))
+		return
+	}
+	pageIndex, pageSize := cursor.OffsetPage(c)
+	applys, err := f.db.queryApplysWithPage(loginUID, pageSize, pageIndex)
 	if err != nil {
 		f.Error("查询好友申请列表错误", zap.Error(err))
 		c.ResponseError(errors.New("查询好友申请列表错误"))
 		return
 	}
+	list, err := f.applyResps(applys)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(list)
+}
+
+// applyResps 申请记录带上申请者的名字
+func (f *Friend) applyResps(applys []*FriendApplyModel) ([]*friendApplyResp, error) {
 	list := make([]*friendApplyResp, 0)
 	if len(applys) > 0 {
 		uids := make([]string, 0)
@@ -135,12 +190,10 @@ func (f *Friend) apply(c *wkhttp.Context) {
 		users, err := f.userService.GetUsers(uids)
 		if err != nil {
 			f.Error("查询申请用户信息错误", zap.Error(err))
-			c.ResponseError(errors.New("查询申请用户信息错误"))
-			return
+			return nil, errors.New("查询申请用户信息错误")
 		}
 		if len(users) == 0 {
-			c.ResponseError(errors.New("申请者不存在"))
-			return
+			return nil, errors.New("申请者不存在")
 		}
 		for _, apply := range applys {
 			name := ""
@@ -162,7 +215,7 @@ func (f *Friend) apply(c *wkhttp.Context) {
 			})
 		}
 	}
-	c.Response(list)
+	return list, nil
 }
 
 // 删除好友
@@ -240,7 +293,7 @@ func (f *Friend) delete(c *wkhttp.Context) {
 	if err := tx.Commit(); err != nil {
 		tx.RollbackUnlessCommitted()
 		f.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	f.ctx.EventCommit(eventID)
@@ -286,6 +339,10 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 		c.ResponseError(errors.New("不能添加自己为好友！"))
 		return
 	}
+	if err := f.tenantService.CheckUsers(tenant.ID(c.Context), req.ToUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
 	loginUserInfo, err := f.userDB.QueryByUID(fromUID)
 	if err != nil {
 		f.Error("查询用户信息错误", zap.Error(err))
@@ -294,7 +351,7 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 	}
 	if loginUserInfo == nil || loginUserInfo.IsDestroy == 1 || loginUserInfo.Status != 1 {
 		f.Error("登录用户不存在！", zap.String("uid", fromUID))
-		c.ResponseError(errors.New("登录用户不存在！"))
+		c.ResponseError(errcode.ErrLoginUserNotExist)
 		return
 	}
 	// 是否是好友
@@ -318,7 +375,7 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 	toUser, err := f.userDB.QueryByUID(req.ToUID)
 	if err != nil {
 		f.Error("查询接收者用户信息失败！", zap.Error(err), zap.String("uid", fromUID))
-		c.ResponseError(errors.New("查询用户信息失败！"))
+		c.ResponseError(errcode.ErrQueryUser)
 		return
 	}
 	if toUser == nil || toUser.IsDestroy == 1 {
@@ -352,13 +409,15 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
+	sourceType := source.GetSourceType(req.Vercode, req.Source)
 	// 设置token
 	token := util.GenerUUID()
 
 	err = f.ctx.Cache().SetAndExpire(f.ctx.GetConfig().Cache.FriendApplyTokenCachePrefix+token+toUser.UID, util.ToJson(map[string]interface{}{
-		"from_uid": fromUID,
-		"vercode":  req.Vercode,
-		"remark":   req.Remark,
+		"from_uid":    fromUID,
+		"vercode":     req.Vercode,
+		"remark":      req.Remark,
+		"source_type": sourceType,
 	}), f.ctx.GetConfig().Cache.FriendApplyExpire)
 	if err != nil {
 		f.Error("设置申请token失败！", zap.Error(err))
@@ -394,6 +453,7 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 			ToUID:  fromUID,
 			Remark: req.Remark,
 			Token:  token,
+			Source: sourceType,
 		}, tx)
 		if err != nil {
 			tx.Rollback()
@@ -402,9 +462,10 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 			return
 		}
 	} else {
-		if apply.Status != 0 {
-			isAddCount = true
+		if apply.Status != 0 || apply.Source != sourceType {
+			isAddCount = apply.Status != 0
 			apply.Status = 0
+			apply.Source = sourceType
 			err = f.db.updateApplyTx(apply, tx)
 			if err != nil {
 				tx.Rollback()
@@ -500,7 +561,7 @@ func (f *Friend) friendSure(c *wkhttp.Context) {
 	loginUser, err := f.userDB.QueryByUID(loginUID)
 	if err != nil {
 		f.Error("查询用户信息失败！", zap.Error(err), zap.String("uid", loginUID))
-		c.ResponseError(errors.New("查询用户信息失败！"))
+		c.ResponseError(errcode.ErrQueryUser)
 		return
 	}
 	if loginUser == nil || loginUser.IsDestroy == 1 {
@@ -515,6 +576,13 @@ func (f *Friend) friendSure(c *wkhttp.Context) {
 	if valueMap["remark"] != nil {
 		remark = valueMap["remark"].(string)
 	}
+	sourceType := ""
+	if valueMap["source_type"] != nil {
+		sourceType = valueMap["source_type"].(string)
+	}
+	if sourceType == "" {
+		sourceType = source.GetSourceType(vercode, "")
+	}
 
 	applyUser, err := f.userDB.QueryByUID(applyUID)
 	if err != nil {
@@ -582,10 +650,11 @@ func (f *Friend) friendSure(c *wkhttp.Context) {
 			IsAlone:       0,
 			Vercode:       fmt.Sprintf("%s@%d", util.GenerUUID(), common.Friend),
 			SourceVercode: vercode,
+			SourceType:    sourceType,
 		}, tx)
 		if err != nil {
 			util.CheckErr(tx.Rollback())
-			c.ResponseError(errors.New("添加好友失败！"))
+			c.ResponseError(errcode.ErrAddFriend)
 			return
 		}
 	} else {
@@ -595,6 +664,12 @@ func (f *Friend) friendSure(c *wkhttp.Context) {
 			c.ResponseError(errors.New("修改好友关系失败"))
 			return
 		}
+		err = f.db.updateSourceTypeTx(loginUID, applyUID, sourceType, tx)
+		if err != nil {
+			util.CheckErr(tx.Rollback())
+			c.ResponseError(errors.New("修改好友来源失败"))
+			return
+		}
 	}
 	// 是否是好友
 	loginFriendModel, err := f.db.queryWithUID(applyUID, loginUID)
@@ -614,10 +689,11 @@ func (f *Friend) friendSure(c *wkhttp.Context) {
 			IsAlone:       0,
 			Vercode:       fmt.Sprintf("%s@%d", util.GenerUUID(), common.Friend),
 			SourceVercode: vercode,
+			SourceType:    sourceType,
 		}, tx)
 		if err != nil {
 			util.CheckErr(tx.Rollback())
-			c.ResponseError(errors.New("添加好友失败！"))
+			c.ResponseError(errcode.ErrAddFriend)
 			return
 		}
 	} else {
@@ -627,6 +703,12 @@ func (f *Friend) friendSure(c *wkhttp.Context) {
 			c.ResponseError(errors.New("修改好友关系失败"))
 			return
 		}
+		err = f.db.updateSourceTypeTx(applyUID, loginUID, sourceType, tx)
+		if err != nil {
+			util.CheckErr(tx.Rollback())
+			c.ResponseError(errors.New("修改好友来源失败"))
+			return
+		}
 	}
 	// 发布好友确认事件
 	eventID, err := f.ctx.EventBegin(&wkevent.Data{
@@ -663,7 +745,7 @@ func (f *Friend) friendSure(c *wkhttp.Context) {
 	}
 	if err := tx.Commit(); err != nil {
 		f.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
+		c.ResponseError(errcode.ErrTxCommit)
 		return
 	}
 	f.ctx.EventCommit(eventID)
@@ -680,7 +762,7 @@ func (f *Friend) friendSure(c *wkhttp.Context) {
 	})
 	if err != nil {
 		f.Error("发送消息失败！", zap.Error(err))
-		c.ResponseError(errors.New("发送消息失败！"))
+		c.ResponseError(errcode.ErrSendMessage)
 		return
 	}
 	content := "我们已经是好友了，可以愉快的聊天了！"
@@ -737,11 +819,38 @@ func (f *Friend) friendSure(c *wkhttp.Context) {
 }
 
 // 同步好友
+// 带cursor参数时按游标分页返回 游标为上一页最后一个好友的version
 func (f *Friend) friendSync(c *wkhttp.Context) {
 	uid := c.MustGet("uid").(string)
+	if cursor.Requested(c) {
+		page, err := cursor.QueryWithLimit(c, friendSyncMaxLimit, friendSyncMaxLimit)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		friends, err := f.db.SyncFriends(page.Key(0), uid, page.FetchLimit())
+		if err != nil {
+			f.Error("同步好友信息错误！", zap.Error(err))
+			c.ResponseError(errors.New("同步好友信息错误！"))
+			return
+		}
+		n, hasMore := page.Keep(len(friends))
+		friends = friends[:n]
+		resps, err := f.friendResps(friends, uid)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		var lastVersion int64
+		if n > 0 {
+			lastVersion = friends[n-1].Version
+		}
+		c.Response(cursor.NewResult(resps, hasMore, lastVersion))
+		return
+	}
 	limit, _ := strconv.ParseUint(c.Query("limit"), 10, 64)
-	if limit <= 0 {
-		limit = 1000
+	if limit <= 0 || limit > friendSyncMaxLimit {
+		limit = friendSyncMaxLimit
 	}
 	version, _ := strconv.ParseInt(c.Query("version"), 10, 64)
 	apiVersion, _ := strconv.ParseInt(c.Query("api_version"), 10, 64)
@@ -759,18 +868,26 @@ func (f *Friend) friendSync(c *wkhttp.Context) {
 			return
 		}
 	}
+	resps, err := f.friendResps(friends, uid)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, resps)
+}
 
+// friendResps 查询好友的用户详情
+func (f *Friend) friendResps(friends []*FriendModel, loginUID string) ([]*friendResp, error) {
 	friendUIDs := make([]string, 0, len(friends))
 	if len(friends) > 0 {
 		for _, f := range friends {
 			friendUIDs = append(friendUIDs, f.ToUID)
 		}
 	}
-	userDetails, err := f.userService.GetUserDetails(friendUIDs, c.GetLoginUID())
+	userDetails, err := f.userService.GetUserDetails(friendUIDs, loginUID)
 	if err != nil {
 		f.Error("获取用户详情失败！", zap.Error(err))
-		c.ResponseError(errors.New("获取用户详情失败！"))
-		return
+		return nil, errors.New("获取用户详情失败！")
 	}
 	userDetailMap := map[string]*UserDetailResp{}
 	if len(userDetails) > 0 {
@@ -792,7 +909,88 @@ func (f *Friend) friendSync(c *wkhttp.Context) {
 			resps = append(resps, resp)
 		}
 	}
-	c.JSON(http.StatusOK, resps)
+	return resps, nil
+}
+
+// 增量同步好友
+// 客户端保存上次同步返回的version，下次只拉取该版本之后的变更，变更按新增、更新、删除分类返回
+func (f *Friend) friendSyncDiff(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	limit, _ := strconv.ParseUint(c.Query("limit"), 10, 64)
+	if limit <= 0 || limit > friendSyncMaxLimit {
+		limit = friendSyncMaxLimit
+	}
+	version, _ := strconv.ParseInt(c.Query("version"), 10, 64)
+
+	resp := &friendSyncDiffResp{
+		Version: version,
+		Added:   make([]*friendResp, 0),
+		Updated: make([]*friendResp, 0),
+		Deleted: make([]string, 0),
+	}
+	maxVersion, err := f.db.queryMaxVersion(loginUID)
+	if err != nil {
+		f.Error("查询好友最新版本号错误！", zap.Error(err))
+		c.ResponseError(errors.New("查询好友最新版本号错误！"))
+		return
+	}
+	if version >= maxVersion { // 没有变化
+		c.Response(resp)
+		return
+	}
+	friends, err := f.db.syncFriendDiff(version, loginUID, limit+1)
+	if err != nil {
+		f.Error("增量同步好友信息错误！", zap.Error(err))
+		c.ResponseError(errors.New("增量同步好友信息错误！"))
+		return
+	}
+	if uint64(len(friends)) > limit {
+		resp.HasMore = 1
+		friends = friends[:limit]
+	}
+	friendUIDs := make([]string, 0, len(friends))
+	for _, friend := range friends {
+		if friend.IsDeleted == 0 {
+			friendUIDs = append(friendUIDs, friend.ToUID)
+		}
+	}
+	userDetailMap := map[string]*UserDetailResp{}
+	if len(friendUIDs) > 0 {
+		userDetails, err := f.userService.GetUserDetails(friendUIDs, loginUID)
+		if err != nil {
+			f.Error("获取用户详情失败！", zap.Error(err))
+			c.ResponseError(errors.New("获取用户详情失败！"))
+			return
+		}
+		for _, userDetail := range userDetails {
+			userDetailMap[userDetail.UID] = userDetail
+		}
+	}
+	for _, friend := range friends {
+		if friend.Version > resp.Version {
+			resp.Version = friend.Version
+		}
+		if friend.IsDeleted == 1 {
+			resp.Deleted = append(resp.Deleted, friend.ToUID)
+			continue
+		}
+		fresp := &friendResp{
+			IsDeleted: friend.IsDeleted,
+			Version:   friend.Version,
+			CreatedAt: friend.CreatedAt.String(),
+			UpdatedAt: friend.UpdatedAt.String(),
+		}
+		if userDetail := userDetailMap[friend.ToUID]; userDetail != nil {
+			fresp.UserDetailResp = *userDetail
+		}
+		fresp.Vercode = friend.Vercode
+		if version <= 0 || friend.AddVersion > version {
+			resp.Added = append(resp.Added, fresp)
+		} else {
+			resp.Updated = append(resp.Updated, fresp)
+		}
+	}
+	c.Response(resp)
 }
 
 func (f *Friend) friendSearch(c *wkhttp.Context) {
@@ -832,29 +1030,25 @@ func (f *Friend) remark(c *wkhttp.Context) {
 		c.ResponseError(errors.New("用户uid不能为空"))
 		return
 	}
-	settingM, err := f.settingDB.querySettingByUIDAndToUID(loginUID, req.UID)
+	reminderReq := &friendReminderReq{
+		UID:         req.UID,
+		Birthday:    req.Birthday,
+		Anniversary: req.Anniversary,
+		Timezone:    req.Timezone,
+	}
+	if err := reminderReq.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	err := f.saveRemark(loginUID, req.UID, req.Remark, tenant.ID(c.Context))
 	if err != nil {
-		f.Error("查询设置信息失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询设置信息失败！"))
+		c.ResponseError(err)
 		return
 	}
-	if settingM == nil {
-		settingM = newDefaultSettingModel()
-		settingM.UID = loginUID
-		settingM.ToUID = req.UID
-		settingM.Remark = req.Remark
-		err = f.settingDB.InsertUserSettingModel(settingM)
+	if reminderReq.hasValue() {
+		err = f.saveReminder(loginUID, reminderReq)
 		if err != nil {
-			f.Error("添加用户设置失败！", zap.Error(err))
-			c.ResponseError(errors.New("添加用户设置失败！"))
-			return
-		}
-	} else {
-		settingM.Remark = req.Remark
-		err = f.settingDB.UpdateUserSettingModel(settingM)
-		if err != nil {
-			f.Error("修改用户备注错误", zap.Error(err))
-			c.ResponseError(errors.New("修改用户备注错误"))
+			c.ResponseError(err)
 			return
 		}
 	}
@@ -869,18 +1063,51 @@ func (f *Friend) remark(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 
+// 保存好友备注 tenantID为登录用户的组织
+func (f *Friend) saveRemark(loginUID, toUID string, remark string, tenantID string) error {
+	settingM, err := f.settingDB.querySettingByUIDAndToUID(loginUID, toUID)
+	if err != nil {
+		f.Error("查询设置信息失败！", zap.Error(err))
+		return errors.New("查询设置信息失败！")
+	}
+	if settingM == nil {
+		settingM = newDefaultSettingModel()
+		settingM.UID = loginUID
+		settingM.ToUID = toUID
+		settingM.Remark = remark
+		settingM.TenantID = tenantID
+		err = f.settingDB.InsertUserSettingModel(settingM)
+		if err != nil {
+			f.Error("添加用户设置失败！", zap.Error(err))
+			return errors.New("添加用户设置失败！")
+		}
+	} else {
+		settingM.Remark = remark
+		err = f.settingDB.UpdateUserSettingModel(settingM)
+		if err != nil {
+			f.Error("修改用户备注错误", zap.Error(err))
+			return errors.New("修改用户备注错误")
+		}
+	}
+	return nil
+}
+
 // ---------- vo ----------
 // 好友申请请求
 type applyReq struct {
 	ToUID   string `json:"to_uid"`  // 向谁申请好友
 	Remark  string `json:"remark"`  // 备注
 	Vercode string `json:"vercode"` // 验证码
+	Source  string `json:"source"`  // 客户端细分来源（可选）phone_search.手机号搜索 nearby.附近的人
 }
 
 // 修改好友备注请求
 type remarkReq struct {
-	UID    string `json:"uid"`    //好友UID
-	Remark string `json:"remark"` //备注名称
+	UID         string  `json:"uid"`         //好友UID
+	Remark      string  `json:"remark"`      //备注名称
+	Birthday    *string `json:"birthday"`    // 生日（可选）YYYY-MM-DD 或 MM-DD
+	Anniversary *string `json:"anniversary"` // 纪念日（可选）YYYY-MM-DD 或 MM-DD
+	Timezone    *string `json:"timezone"`    // 提醒时区（可选）例如 Asia/Shanghai
 }
 
 func (r applyReq) Check() error {
@@ -928,6 +1155,15 @@ type friendResp struct {
 	Version   int64  `json:"version"`
 }
 
+// 增量同步好友返回
+type friendSyncDiffResp struct {
+	Version int64         `json:"version"`  // 本次同步到的版本号，下次同步时传入
+	HasMore int           `json:"has_more"` // 是否还有更多变更 1.是
+	Added   []*friendResp `json:"added"`    // 新增的好友
+	Updated []*friendResp `json:"updated"`  // 信息有变化的好友
+	Deleted []string      `json:"deleted"`  // 已删除的好友uid
+}
+
 type friendApplyResp struct {
 	Id        int64  `json:"id"`
 	UID       string `json:"uid"`
@@ -0,0 +1,632 @@
+package user
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// 导出文件格式标识
+	contactsFormatTSDD = "tsdd_contacts"
+	// 导出文件版本
+	contactsFileVersion = 1
+	// 单次最多导入的联系人数量
+	contactsImportMaxCount = 1000
+	// 每个用户每天最多匹配的联系人数量（包含仅预览） 避免通过导入批量探测手机号
+	contactsImportDailyMaxCount = 5000
+	// 每天匹配联系人数量的缓存
+	contactsImportCountCachePrefix = "contactsImportCount:"
+)
+
+// 导入文件来源
+const (
+	contactsImportFormatTSDD     = "tsdd"     // 本应用导出的文件
+	contactsImportFormatTelegram = "telegram" // Telegram Desktop 导出的 result.json
+	contactsImportFormatWechat   = "wechat"   // 微信联系人导出的csv（需包含手机号列）
+)
+
+// 冲突处理方式
+const (
+	contactsConflictSkip      = "skip"      // 已有备注或提醒时保留原有数据
+	contactsConflictOverwrite = "overwrite" // 使用导入的数据覆盖
+)
+
+// 导入结果
+const (
+	contactsActionUpdate    = "update"     // 更新备注/提醒
+	contactsActionUnchanged = "unchanged"  // 无变化
+	contactsActionConflict  = "conflict"   // 与现有数据冲突，已跳过
+	contactsActionNotFriend = "not_friend" // 找到用户但还不是好友
+	contactsActionNotFound  = "not_found"  // 未找到用户
+	contactsActionDuplicate = "duplicate"  // 文件内重复
+)
+
+// 导出好友
+func (f *Friend) exportContacts(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	file := &contactsFile{
+		Format:     contactsFormatTSDD,
+		Version:    contactsFileVersion,
+		ExportedAt: time.Now().Unix(),
+		Contacts:   make([]*contactsFileItem, 0),
+	}
+	friends, err := f.db.QueryFriends(loginUID)
+	if err != nil {
+		f.Error("查询好友列表错误", zap.Error(err))
+		c.ResponseError(errors.New("查询好友列表错误"))
+		return
+	}
+	if len(friends) == 0 {
+		c.Response(file)
+		return
+	}
+	uids := make([]string, 0, len(friends))
+	for _, friend := range friends {
+		uids = append(uids, friend.ToUID)
+	}
+	users, err := f.userDB.QueryByUIDs(uids)
+	if err != nil {
+		f.Error("查询好友信息错误", zap.Error(err))
+		c.ResponseError(errors.New("查询好友信息错误"))
+		return
+	}
+	userMap := map[string]*Model{}
+	for _, user := range users {
+		userMap[user.UID] = user
+	}
+	settings, err := f.settingDB.QueryUserSettings(uids, loginUID)
+	if err != nil {
+		f.Error("查询好友设置错误", zap.Error(err))
+		c.ResponseError(errors.New("查询好友设置错误"))
+		return
+	}
+	settingMap := map[string]*SettingModel{}
+	for _, setting := range settings {
+		settingMap[setting.ToUID] = setting
+	}
+	reminders, err := f.reminderDB.queryWithUID(loginUID)
+	if err != nil {
+		f.Error("查询好友提醒设置错误", zap.Error(err))
+		c.ResponseError(errors.New("查询好友提醒设置错误"))
+		return
+	}
+	reminderMap := map[string]*friendReminderModel{}
+	for _, reminder := range reminders {
+		reminderMap[reminder.ToUID] = reminder
+	}
+	for _, friend := range friends {
+		user := userMap[friend.ToUID]
+		if user == nil || user.IsDestroy == 1 {
+			continue
+		}
+		item := &contactsFileItem{
+			UID:       user.UID,
+			Name:      user.Name,
+			Username:  user.Username,
+			ShortNo:   user.ShortNo,
+			CreatedAt: friend.CreatedAt.String(),
+		}
+		if setting := settingMap[user.UID]; setting != nil {
+			item.Remark = setting.Remark
+		}
+		if reminder := reminderMap[user.UID]; reminder != nil {
+			item.Birthday = reminder.Birthday
+			item.Anniversary = reminder.Anniversary
+		}
+		file.Contacts = append(file.Contacts, item)
+	}
+	c.Response(file)
+}
+
+// 导入好友 只会补充已是好友的备注与提醒，不是好友的只返回匹配到的用户 由客户端通过搜索或名片发起好友申请
+func (f *Friend) importContacts(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req contactsImportReq
+	if err := c.BindJSON(&req); err != nil {
+		f.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	loginUser, err := f.userDB.QueryByUID(loginUID)
+	if err != nil {
+		f.Error("查询登录用户信息错误", zap.Error(err))
+		c.ResponseError(errors.New("查询登录用户信息错误"))
+		return
+	}
+	if loginUser == nil {
+		c.ResponseError(errcode.ErrLoginUserNotExist)
+		return
+	}
+	contacts, err := parseImportContacts(req.Format, req.Data, loginUser.Zone)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if len(contacts) > contactsImportMaxCount {
+		c.ResponseError(errors.Errorf("单次最多导入%d个联系人", contactsImportMaxCount))
+		return
+	}
+	if err := f.checkImportDailyCount(loginUID, len(contacts)); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	scope := &contactsMatchScope{
+		loginUID:    loginUID,
+		tenantID:    tenant.ID(c.Context),
+		phoneSearch: f.phoneSearchEnabled(),
+	}
+	users, err := f.matchImportContacts(scope, contacts)
+	if err != nil {
+		f.Error("匹配导入联系人错误", zap.Error(err))
+		c.ResponseError(errors.New("匹配导入联系人错误"))
+		return
+	}
+	friends, err := f.db.QueryFriends(loginUID)
+	if err != nil {
+		f.Error("查询好友列表错误", zap.Error(err))
+		c.ResponseError(errors.New("查询好友列表错误"))
+		return
+	}
+	friendMap := map[string]bool{}
+	friendUIDs := make([]string, 0, len(friends))
+	for _, friend := range friends {
+		friendMap[friend.ToUID] = true
+		friendUIDs = append(friendUIDs, friend.ToUID)
+	}
+	settingMap := map[string]*SettingModel{}
+	if len(friendUIDs) > 0 {
+		settings, err := f.settingDB.QueryUserSettings(friendUIDs, loginUID)
+		if err != nil {
+			f.Error("查询好友设置错误", zap.Error(err))
+			c.ResponseError(errors.New("查询好友设置错误"))
+			return
+		}
+		for _, setting := range settings {
+			settingMap[setting.ToUID] = setting
+		}
+	}
+	reminders, err := f.reminderDB.queryWithUID(loginUID)
+	if err != nil {
+		f.Error("查询好友提醒设置错误", zap.Error(err))
+		c.ResponseError(errors.New("查询好友提醒设置错误"))
+		return
+	}
+	reminderMap := map[string]*friendReminderModel{}
+	for _, reminder := range reminders {
+		reminderMap[reminder.ToUID] = reminder
+	}
+
+	resp := &contactsImportResp{
+		DryRun: req.DryRun,
+		Total:  len(contacts),
+		Items:  make([]*contactsImportItemResp, 0, len(contacts)),
+	}
+	seen := map[string]bool{}
+	for i, contact := range contacts {
+		user := users[i]
+		item := &contactsImportItemResp{
+			Name:   contact.Name,
+			Remark: contact.Remark,
+		}
+		resp.Items = append(resp.Items, item)
+		if user == nil {
+			item.Action = contactsActionNotFound
+			resp.NotFound++
+			continue
+		}
+		item.UID = user.UID
+		item.UserName = user.Name
+		if seen[user.UID] {
+			item.Action = contactsActionDuplicate
+			continue
+		}
+		seen[user.UID] = true
+		if !friendMap[user.UID] {
+			item.Action = contactsActionNotFriend
+			resp.NotFriend++
+			continue
+		}
+		if setting := settingMap[user.UID]; setting != nil {
+			item.OldRemark = setting.Remark
+		}
+		var oldBirthday, oldAnniversary string
+		if reminder := reminderMap[user.UID]; reminder != nil {
+			oldBirthday = reminder.Birthday
+			oldAnniversary = reminder.Anniversary
+		}
+		remarkChanged := contact.Remark != "" && contact.Remark != item.OldRemark
+		birthdayChanged := contact.Birthday != "" && contact.Birthday != oldBirthday
+		anniversaryChanged := contact.Anniversary != "" && contact.Anniversary != oldAnniversary
+		if !remarkChanged && !birthdayChanged && !anniversaryChanged {
+			item.Action = contactsActionUnchanged
+			resp.Unchanged++
+			continue
+		}
+		conflict := (remarkChanged && item.OldRemark != "") || (birthdayChanged && oldBirthday != "") || (anniversaryChanged && oldAnniversary != "")
+		if conflict && req.Conflict != contactsConflictOverwrite {
+			item.Action = contactsActionConflict
+			resp.Conflicts++
+			continue
+		}
+		item.Action = contactsActionUpdate
+		resp.Updated++
+		if req.DryRun == 1 {
+			continue
+		}
+		if remarkChanged {
+			if err := f.saveRemark(loginUID, user.UID, contact.Remark, tenant.ID(c.Context)); err != nil {
+				c.ResponseError(err)
+				return
+			}
+		}
+		if birthdayChanged || anniversaryChanged {
+			reminderReq := &friendReminderReq{UID: user.UID}
+			if birthdayChanged {
+				reminderReq.Birthday = &contact.Birthday
+			}
+			if anniversaryChanged {
+				reminderReq.Anniversary = &contact.Anniversary
+			}
+			if err := f.saveReminder(loginUID, reminderReq); err != nil {
+				c.ResponseError(err)
+				return
+			}
+		}
+		if remarkChanged {
+			err = f.ctx.SendChannelUpdateToUser(loginUID, config.ChannelReq{
+				ChannelID:   user.UID,
+				ChannelType: common.ChannelTypePerson.Uint8(),
+			})
+			if err != nil {
+				f.Warn("导入好友-发送频道更新消息失败", zap.Error(err))
+			}
+		}
+	}
+	c.Response(resp)
+}
+
+// checkImportDailyCount 检查并增加今天匹配联系人的数量
+func (f *Friend) checkImportDailyCount(loginUID string, count int) error {
+	key := fmt.Sprintf("%s%s:%s", contactsImportCountCachePrefix, time.Now().Format("20060102"), loginUID)
+	total, err := f.ctx.GetRedisConn().Hincrby(key, "count", count)
+	if err != nil {
+		f.Error("统计导入联系人数量错误", zap.Error(err))
+		return errors.New("统计导入联系人数量错误")
+	}
+	if total == int64(count) {
+		_ = f.ctx.GetRedisConn().Expire(key, time.Hour*24)
+	}
+	if total > contactsImportDailyMaxCount {
+		return errors.Errorf("每天最多导入%d个联系人", contactsImportDailyMaxCount)
+	}
+	return nil
+}
+
+// phoneSearchEnabled 是否允许通过手机号匹配 与搜索用户一致 后台或配置关闭了手机号搜索时不匹配手机号
+func (f *Friend) phoneSearchEnabled() bool {
+	if f.ctx.GetConfig().PhoneSearchOff {
+		return false
+	}
+	appconfig, err := f.commonService.GetAppConfig()
+	if err != nil {
+		f.Warn("查询应用配置错误", zap.Error(err))
+		return false
+	}
+	return appconfig == nil || appconfig.SearchByPhone != 0
+}
+
+// contactsMatchScope 可以匹配的联系人范围
+type contactsMatchScope struct {
+	loginUID    string
+	tenantID    string // 只匹配同一组织的用户
+	phoneSearch bool   // 是否通过手机号匹配
+}
+
+// allowed 用户是否可以被匹配 不匹配自己、已注销的用户、机器人和其他组织的用户
+func (s *contactsMatchScope) allowed(user *Model) bool {
+	if user == nil || user.UID == s.loginUID || user.IsDestroy == 1 || user.Robot == 1 {
+		return false
+	}
+	return !tenant.Enabled() || user.TenantID == s.tenantID
+}
+
+// 通过uid、短编号、用户名、手机号匹配导入的联系人 返回与contacts一一对应的用户（未匹配到为nil）
+func (f *Friend) matchImportContacts(scope *contactsMatchScope, contacts []*importContact) ([]*Model, error) {
+	uids := make([]string, 0)
+	shortNos := make([]string, 0)
+	usernames := make([]string, 0)
+	phones := make([]string, 0)
+	for _, contact := range contacts {
+		if contact.UID != "" {
+			uids = append(uids, contact.UID)
+		}
+		if contact.ShortNo != "" {
+			shortNos = append(shortNos, contact.ShortNo)
+		}
+		if contact.Username != "" {
+			usernames = append(usernames, contact.Username)
+		}
+		if contact.Phone != "" && scope.phoneSearch {
+			phones = append(phones, contact.Phone)
+		}
+	}
+	uidMap := map[string]*Model{}
+	shortNoMap := map[string]*Model{}
+	usernameMap := map[string]*Model{}
+	phoneMap := map[string]*Model{}
+	if len(uids) > 0 {
+		users, err := f.userDB.QueryByUIDs(uids)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			uidMap[user.UID] = user
+		}
+	}
+	if len(shortNos) > 0 {
+		users, err := f.userDB.queryByShortNos(shortNos)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			if user.SearchByShort == 1 {
+				shortNoMap[user.ShortNo] = user
+			}
+		}
+	}
+	if len(usernames) > 0 {
+		users, err := f.userDB.queryByUsernames(usernames)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			usernameMap[user.Username] = user
+		}
+	}
+	if len(phones) > 0 {
+		users, err := f.userDB.QueryByPhones(phones)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			if user.SearchByPhone == 1 {
+				phoneMap[user.Zone+user.Phone] = user
+			}
+		}
+	}
+	result := make([]*Model, len(contacts))
+	for i, contact := range contacts {
+		var user *Model
+		if contact.UID != "" {
+			user = uidMap[contact.UID]
+		}
+		if user == nil && contact.ShortNo != "" {
+			user = shortNoMap[contact.ShortNo]
+		}
+		if user == nil && contact.Username != "" {
+			user = usernameMap[contact.Username]
+		}
+		if user == nil && contact.Phone != "" {
+			user = phoneMap[contact.Phone]
+		}
+		if !scope.allowed(user) {
+			continue
+		}
+		result[i] = user
+	}
+	return result, nil
+}
+
+// parseImportContacts 解析导入文件 defaultZone为手机号未带区号时使用的区号
+func parseImportContacts(format string, data string, defaultZone string) ([]*importContact, error) {
+	data = strings.TrimPrefix(data, "\ufeff")
+	switch format {
+	case contactsImportFormatTSDD:
+		var file contactsFile
+		if err := json.Unmarshal([]byte(data), &file); err != nil || file.Format != contactsFormatTSDD {
+			return nil, errors.New("导入文件格式有误！")
+		}
+		contacts := make([]*importContact, 0, len(file.Contacts))
+		for _, item := range file.Contacts {
+			if item == nil {
+				continue
+			}
+			contact := &importContact{
+				UID:         strings.TrimSpace(item.UID),
+				Username:    strings.TrimSpace(item.Username),
+				ShortNo:     strings.TrimSpace(item.ShortNo),
+				Name:        strings.TrimSpace(item.Name),
+				Remark:      strings.TrimSpace(item.Remark),
+				Birthday:    strings.TrimSpace(item.Birthday),
+				Anniversary: strings.TrimSpace(item.Anniversary),
+			}
+			// 日期格式有误的直接忽略，不影响其他数据导入
+			if checkReminderDate(contact.Birthday) != nil {
+				contact.Birthday = ""
+			}
+			if checkReminderDate(contact.Anniversary) != nil {
+				contact.Anniversary = ""
+			}
+			contacts = append(contacts, contact)
+		}
+		return contacts, nil
+	case contactsImportFormatTelegram:
+		var file telegramExportFile
+		if err := json.Unmarshal([]byte(data), &file); err != nil {
+			return nil, errors.New("导入文件格式有误！")
+		}
+		contacts := make([]*importContact, 0, len(file.Contacts.List))
+		for _, item := range file.Contacts.List {
+			name := strings.TrimSpace(strings.TrimSpace(item.FirstName) + " " + strings.TrimSpace(item.LastName))
+			contacts = append(contacts, &importContact{
+				Name:   name,
+				Remark: name,
+				Phone:  normalizeContactPhone(item.PhoneNumber, defaultZone),
+			})
+		}
+		return contacts, nil
+	case contactsImportFormatWechat:
+		reader := csv.NewReader(strings.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		records, err := reader.ReadAll()
+		if err != nil || len(records) == 0 {
+			return nil, errors.New("导入文件格式有误！")
+		}
+		nameIdx, remarkIdx, phoneIdx := -1, -1, -1
+		for i, column := range records[0] {
+			switch strings.ToLower(strings.TrimSpace(column)) {
+			case "昵称", "nickname", "name":
+				nameIdx = i
+			case "备注", "remark":
+				remarkIdx = i
+			case "手机号", "手机", "电话", "phone":
+				phoneIdx = i
+			}
+		}
+		if phoneIdx == -1 {
+			return nil, errors.New("导入文件中未找到手机号列！")
+		}
+		column := func(record []string, idx int) string {
+			if idx < 0 || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+		contacts := make([]*importContact, 0, len(records)-1)
+		for _, record := range records[1:] {
+			contact := &importContact{
+				Name:   column(record, nameIdx),
+				Remark: column(record, remarkIdx),
+				Phone:  normalizeContactPhone(column(record, phoneIdx), defaultZone),
+			}
+			if contact.Remark == "" {
+				contact.Remark = contact.Name
+			}
+			contacts = append(contacts, contact)
+		}
+		return contacts, nil
+	}
+	return nil, errors.New("不支持的导入格式！")
+}
+
+// normalizeContactPhone 将手机号转为 区号+手机号 的格式（与 user 表中 zone+phone 一致）
+func normalizeContactPhone(phone string, defaultZone string) string {
+	phone = strings.TrimSpace(phone)
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	if digits.Len() == 0 {
+		return ""
+	}
+	if strings.HasPrefix(phone, "+") {
+		return "00" + digits.String()
+	}
+	if strings.HasPrefix(digits.String(), "00") {
+		return digits.String()
+	}
+	return defaultZone + digits.String()
+}
+
+type contactsImportReq struct {
+	Format   string `json:"format"`   // 文件来源 tsdd.本应用导出 telegram.Telegram导出的json wechat.微信联系人csv
+	Data     string `json:"data"`     // 文件内容
+	Conflict string `json:"conflict"` // 冲突处理 skip.保留原有数据（默认） overwrite.覆盖
+	DryRun   int    `json:"dry_run"`  // 1.仅预览不修改数据
+}
+
+func (r *contactsImportReq) check() error {
+	if strings.TrimSpace(r.Data) == "" {
+		return errors.New("导入内容不能为空！")
+	}
+	if r.Format == "" {
+		r.Format = contactsImportFormatTSDD
+	}
+	if r.Conflict == "" {
+		r.Conflict = contactsConflictSkip
+	}
+	if r.Conflict != contactsConflictSkip && r.Conflict != contactsConflictOverwrite {
+		return errors.New("冲突处理方式有误！")
+	}
+	return nil
+}
+
+// 导出文件
+type contactsFile struct {
+	Format     string              `json:"format"`      // 固定为 tsdd_contacts
+	Version    int                 `json:"version"`     // 文件版本
+	ExportedAt int64               `json:"exported_at"` // 导出时间（秒）
+	Contacts   []*contactsFileItem `json:"contacts"`
+}
+
+type contactsFileItem struct {
+	UID         string `json:"uid"`
+	Name        string `json:"name"`
+	Username    string `json:"username,omitempty"`
+	ShortNo     string `json:"short_no,omitempty"`
+	Remark      string `json:"remark,omitempty"`
+	Birthday    string `json:"birthday,omitempty"`
+	Anniversary string `json:"anniversary,omitempty"`
+	CreatedAt   string `json:"created_at,omitempty"` // 成为好友的时间
+}
+
+// Telegram Desktop 导出的 result.json（只解析联系人部分）
+type telegramExportFile struct {
+	Contacts struct {
+		List []struct {
+			FirstName   string `json:"first_name"`
+			LastName    string `json:"last_name"`
+			PhoneNumber string `json:"phone_number"`
+		} `json:"list"`
+	} `json:"contacts"`
+}
+
+// 解析后的待导入联系人
+type importContact struct {
+	UID         string
+	Username    string
+	ShortNo     string
+	Phone       string // 区号+手机号
+	Name        string
+	Remark      string
+	Birthday    string
+	Anniversary string
+}
+
+type contactsImportResp struct {
+	DryRun    int                       `json:"dry_run"`
+	Total     int                       `json:"total"`      // 导入的联系人数量
+	Updated   int                       `json:"updated"`    // 更新数量
+	Unchanged int                       `json:"unchanged"`  // 无变化数量
+	Conflicts int                       `json:"conflicts"`  // 冲突跳过数量
+	NotFriend int                       `json:"not_friend"` // 非好友数量
+	NotFound  int                       `json:"not_found"`  // 未找到数量
+	Items     []*contactsImportItemResp `json:"items"`
+}
+
+type contactsImportItemResp struct {
+	Action    string `json:"action"`               // update.更新 unchanged.无变化 conflict.冲突已跳过 not_friend.非好友 not_found.未找到 duplicate.重复
+	Name      string `json:"name"`                 // 文件中的名称
+	Remark    string `json:"remark"`               // 导入的备注
+	UID       string `json:"uid,omitempty"`        // 匹配到的用户uid
+	UserName  string `json:"user_name,omitempty"`  // 匹配到的用户名称
+	OldRemark string `json:"old_remark,omitempty"` // 原有备注
+}
@@ -0,0 +1,298 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// 默认提醒时区
+	friendReminderDefaultTimezone = "Asia/Shanghai"
+	// 每天几点（用户时区）之后开始发送提醒
+	friendReminderHour = 9
+)
+
+// 获取好友的生日与纪念日设置
+func (f *Friend) getReminder(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	toUID := c.Param("uid")
+	if strings.TrimSpace(toUID) == "" {
+		c.ResponseError(errors.New("好友uid不能为空"))
+		return
+	}
+	m, err := f.reminderDB.queryWithUIDAndToUID(loginUID, toUID)
+	if err != nil {
+		f.Error("查询好友提醒设置错误", zap.Error(err))
+		c.ResponseError(errors.New("查询好友提醒设置错误"))
+		return
+	}
+	resp := &friendReminderResp{
+		UID:               toUID,
+		BirthdayRemind:    1,
+		AnniversaryRemind: 1,
+		Timezone:          friendReminderDefaultTimezone,
+	}
+	if m != nil {
+		resp.Birthday = m.Birthday
+		resp.BirthdayRemind = m.BirthdayRemind
+		resp.Anniversary = m.Anniversary
+		resp.AnniversaryRemind = m.AnniversaryRemind
+		if m.Timezone != "" {
+			resp.Timezone = m.Timezone
+		}
+	}
+	c.Response(resp)
+}
+
+// 开启或关闭某个好友的生日/纪念日提醒
+func (f *Friend) updateReminder(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req friendReminderReq
+	if err := c.BindJSON(&req); err != nil {
+		f.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	if strings.TrimSpace(req.UID) == "" {
+		c.ResponseError(errors.New("好友uid不能为空"))
+		return
+	}
+	err := f.saveReminder(loginUID, &req)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 保存好友提醒信息 请求里未传的字段保持不变
+func (f *Friend) saveReminder(loginUID string, req *friendReminderReq) error {
+	if err := req.check(); err != nil {
+		return err
+	}
+	m, err := f.reminderDB.queryWithUIDAndToUID(loginUID, req.UID)
+	if err != nil {
+		f.Error("查询好友提醒设置错误", zap.Error(err))
+		return errors.New("查询好友提醒设置错误")
+	}
+	isAdd := false
+	if m == nil {
+		isAdd = true
+		m = &friendReminderModel{
+			UID:               loginUID,
+			ToUID:             req.UID,
+			BirthdayRemind:    1,
+			AnniversaryRemind: 1,
+			Timezone:          friendReminderDefaultTimezone,
+		}
+	}
+	if req.Birthday != nil {
+		m.Birthday = strings.TrimSpace(*req.Birthday)
+		m.BirthdayMd = reminderMonthDay(m.Birthday)
+	}
+	if req.Anniversary != nil {
+		m.Anniversary = strings.TrimSpace(*req.Anniversary)
+		m.AnniversaryMd = reminderMonthDay(m.Anniversary)
+	}
+	if req.BirthdayRemind != nil {
+		m.BirthdayRemind = *req.BirthdayRemind
+	}
+	if req.AnniversaryRemind != nil {
+		m.AnniversaryRemind = *req.AnniversaryRemind
+	}
+	if req.Timezone != nil && strings.TrimSpace(*req.Timezone) != "" {
+		m.Timezone = strings.TrimSpace(*req.Timezone)
+	}
+	if isAdd {
+		err = f.reminderDB.insert(m)
+	} else {
+		err = f.reminderDB.update(m)
+	}
+	if err != nil {
+		f.Error("保存好友提醒设置错误", zap.Error(err))
+		return errors.New("保存好友提醒设置错误")
+	}
+	return nil
+}
+
+// 定时检查今天是否有好友生日或纪念日
+func (f *Friend) friendReminderCheck() {
+	now := time.Now().UTC()
+	// 各时区与UTC最多相差一天，所以取UTC的前一天、当天、后一天作为候选
+	mds := []string{
+		now.AddDate(0, 0, -1).Format("01-02"),
+		now.Format("01-02"),
+		now.AddDate(0, 0, 1).Format("01-02"),
+	}
+	birthdays, err := f.reminderDB.queryBirthdaysWithMds(mds)
+	if err != nil {
+		f.Error("【好友提醒】查询生日列表失败！", zap.Error(err))
+		return
+	}
+	for _, m := range birthdays {
+		date, ok := reminderDueDate(m.BirthdayMd, m.Timezone, now)
+		if !ok || m.LastBirthdayRemind == date {
+			continue
+		}
+		marked, err := f.reminderDB.markBirthdayReminded(m.Id, date)
+		if err != nil {
+			f.Error("【好友提醒】标记生日提醒失败！", zap.Error(err))
+			continue
+		}
+		if marked {
+			f.sendReminderMsg(m, "今天是%s的生日，别忘了送上祝福哦！")
+		}
+	}
+
+	anniversaries, err := f.reminderDB.queryAnniversariesWithMds(mds)
+	if err != nil {
+		f.Error("【好友提醒】查询纪念日列表失败！", zap.Error(err))
+		return
+	}
+	for _, m := range anniversaries {
+		date, ok := reminderDueDate(m.AnniversaryMd, m.Timezone, now)
+		if !ok || m.LastAnniversaryRemind == date {
+			continue
+		}
+		marked, err := f.reminderDB.markAnniversaryReminded(m.Id, date)
+		if err != nil {
+			f.Error("【好友提醒】标记纪念日提醒失败！", zap.Error(err))
+			continue
+		}
+		if marked {
+			f.sendReminderMsg(m, "今天是你和%s的纪念日！")
+		}
+	}
+}
+
+// 发送提醒系统消息给用户
+func (f *Friend) sendReminderMsg(m *friendReminderModel, format string) {
+	name := m.ToUID
+	settingM, err := f.settingDB.querySettingByUIDAndToUID(m.UID, m.ToUID)
+	if err != nil {
+		f.Warn("【好友提醒】查询好友备注失败！", zap.Error(err))
+	}
+	if settingM != nil && strings.TrimSpace(settingM.Remark) != "" {
+		name = settingM.Remark
+	} else {
+		toUser, err := f.userDB.QueryByUID(m.ToUID)
+		if err != nil {
+			f.Warn("【好友提醒】查询好友信息失败！", zap.Error(err))
+		}
+		if toUser != nil {
+			name = toUser.Name
+		}
+	}
+	err = f.ctx.SendMessage(&config.MsgSendReq{
+		FromUID:     f.ctx.GetConfig().Account.SystemUID,
+		ChannelID:   m.UID,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": fmt.Sprintf(format, name),
+			"type":    common.Text,
+		})),
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+	})
+	if err != nil {
+		f.Error("【好友提醒】发送提醒消息失败！", zap.Error(err), zap.String("uid", m.UID), zap.String("toUID", m.ToUID))
+	}
+}
+
+// reminderDueDate 判断月日md在时区timezone下是否为今天且已到提醒时间，返回用户时区的当天日期
+func reminderDueDate(md string, timezone string, now time.Time) (string, bool) {
+	if md == "" {
+		return "", false
+	}
+	if timezone == "" {
+		timezone = friendReminderDefaultTimezone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc, _ = time.LoadLocation(friendReminderDefaultTimezone)
+		if loc == nil {
+			loc = time.Local
+		}
+	}
+	local := now.In(loc)
+	if local.Format("01-02") != md || local.Hour() < friendReminderHour {
+		return "", false
+	}
+	return local.Format("2006-01-02"), true
+}
+
+// reminderMonthDay 将 YYYY-MM-DD 或 MM-DD 转为 MM-DD
+func reminderMonthDay(date string) string {
+	if date == "" {
+		return ""
+	}
+	if len(date) == len("01-02") {
+		return date
+	}
+	return date[len(date)-len("01-02"):]
+}
+
+func checkReminderDate(date string) error {
+	if date == "" {
+		return nil
+	}
+	layout := "2006-01-02"
+	if len(date) == len("01-02") {
+		layout = "01-02"
+	}
+	if _, err := time.Parse(layout, date); err != nil {
+		return errors.New("日期格式有误，格式应为 YYYY-MM-DD 或 MM-DD")
+	}
+	return nil
+}
+
+type friendReminderReq struct {
+	UID               string  `json:"uid"`                // 好友uid
+	Birthday          *string `json:"birthday"`           // 生日 YYYY-MM-DD 或 MM-DD 传空字符串为清除
+	Anniversary       *string `json:"anniversary"`        // 纪念日 YYYY-MM-DD 或 MM-DD 传空字符串为清除
+	BirthdayRemind    *int    `json:"birthday_remind"`    // 是否开启生日提醒 1.开启 0.关闭
+	AnniversaryRemind *int    `json:"anniversary_remind"` // 是否开启纪念日提醒 1.开启 0.关闭
+	Timezone          *string `json:"timezone"`           // 提醒时区 例如 Asia/Shanghai
+}
+
+func (r *friendReminderReq) check() error {
+	if r.Birthday != nil {
+		if err := checkReminderDate(strings.TrimSpace(*r.Birthday)); err != nil {
+			return err
+		}
+	}
+	if r.Anniversary != nil {
+		if err := checkReminderDate(strings.TrimSpace(*r.Anniversary)); err != nil {
+			return err
+		}
+	}
+	if r.Timezone != nil && strings.TrimSpace(*r.Timezone) != "" {
+		if _, err := time.LoadLocation(strings.TrimSpace(*r.Timezone)); err != nil {
+			return errors.New("时区格式有误")
+		}
+	}
+	return nil
+}
+
+// 是否包含提醒相关的字段
+func (r *friendReminderReq) hasValue() bool {
+	return r.Birthday != nil || r.Anniversary != nil || r.BirthdayRemind != nil || r.AnniversaryRemind != nil || r.Timezone != nil
+}
+
+type friendReminderResp struct {
+	UID               string `json:"uid"`
+	Birthday          string `json:"birthday"`
+	BirthdayRemind    int    `json:"birthday_remind"`
+	Anniversary       string `json:"anniversary"`
+	AnniversaryRemind int    `json:"anniversary_remind"`
+	Timezone          string `json:"timezone"`
+}
@@ -0,0 +1,192 @@
+package user
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFriendSyncDiff(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	u := New(ctx)
+	f := NewFriend(ctx)
+	//清除数据
+	err := testutil.CleanAllTables(ctx)
+	assert.NoError(t, err)
+
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		err = u.db.Insert(&Model{UID: uid, Name: "name-" + uid, ShortNo: "short-" + uid})
+		assert.NoError(t, err)
+	}
+	// 客户端上次同步到版本3之后：a是新加的好友，b是之前的好友信息有变化，c被删除，d没有变化
+	friends := []*FriendModel{
+		{UID: testutil.UID, ToUID: "d", Vercode: "d@4", Version: 2, AddVersion: 1},
+		{UID: testutil.UID, ToUID: "a", Vercode: "a@4", Version: 5, AddVersion: 5},
+		{UID: testutil.UID, ToUID: "b", Vercode: "b@4", Version: 6, AddVersion: 1},
+		{UID: testutil.UID, ToUID: "c", Vercode: "c@4", Version: 7, AddVersion: 1, IsDeleted: 1},
+	}
+	for _, friend := range friends {
+		err = f.db.Insert(friend)
+		assert.NoError(t, err)
+	}
+
+	syncDiff := func(query string) *friendSyncDiffResp {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/friend/sync/diff?"+query, nil)
+		req.Header.Set("token", testutil.Token)
+		s.GetRoute().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp friendSyncDiffResp
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		return &resp
+	}
+	uids := func(list []*friendResp) []string {
+		result := make([]string, 0, len(list))
+		for _, item := range list {
+			result = append(result, item.UID)
+		}
+		return result
+	}
+
+	// 按客户端的版本号分类
+	resp := syncDiff("version=3")
+	assert.Equal(t, []string{"a"}, uids(resp.Added))
+	assert.Equal(t, "name-a", resp.Added[0].Name)
+	assert.Equal(t, []string{"b"}, uids(resp.Updated))
+	assert.Equal(t, []string{"c"}, resp.Deleted)
+	assert.Equal(t, int64(7), resp.Version)
+	assert.Equal(t, 0, resp.HasMore)
+
+	// 第一次同步返回所有好友 不返回已删除的好友
+	resp = syncDiff("version=0")
+	assert.Equal(t, []string{"d", "a", "b"}, uids(resp.Added))
+	assert.Equal(t, 0, len(resp.Updated))
+	assert.Equal(t, 0, len(resp.Deleted))
+	assert.Equal(t, int64(6), resp.Version)
+
+	// 已经是最新版本
+	resp = syncDiff("version=7")
+	assert.Equal(t, 0, len(resp.Added))
+	assert.Equal(t, 0, len(resp.Updated))
+	assert.Equal(t, 0, len(resp.Deleted))
+	assert.Equal(t, int64(7), resp.Version)
+	assert.Equal(t, 0, resp.HasMore)
+
+	// 分页 用返回的版本号继续同步
+	resp = syncDiff("version=3&limit=2")
+	assert.Equal(t, []string{"a"}, uids(resp.Added))
+	assert.Equal(t, []string{"b"}, uids(resp.Updated))
+	assert.Equal(t, 0, len(resp.Deleted))
+	assert.Equal(t, int64(6), resp.Version)
+	assert.Equal(t, 1, resp.HasMore)
+
+	resp = syncDiff("version=6&limit=2")
+	assert.Equal(t, 0, len(resp.Added))
+	assert.Equal(t, 0, len(resp.Updated))
+	assert.Equal(t, []string{"c"}, resp.Deleted)
+	assert.Equal(t, int64(7), resp.Version)
+	assert.Equal(t, 0, resp.HasMore)
+}
+
+func TestFriendSourceStats(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	u := New(ctx)
+	f := NewFriend(ctx)
+	//清除数据
+	err := testutil.CleanAllTables(ctx)
+	assert.NoError(t, err)
+
+	err = u.db.Insert(&Model{UID: testutil.UID, Name: "u1", ShortNo: "u1", Vercode: "u1@1", Status: 1})
+	assert.NoError(t, err)
+	err = u.db.Insert(&Model{UID: "111", Name: "111", ShortNo: "111", Vercode: "111@1", Status: 1})
+	assert.NoError(t, err)
+	err = u.db.Insert(&Model{UID: "222", Name: "222", ShortNo: "222", Vercode: "222@1", QRVercode: "222@3", Status: 1})
+	assert.NoError(t, err)
+
+	// 申请时记录客户端上报的细分来源
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/friend/apply", bytes.NewReader([]byte(util.ToJson(map[string]interface{}{
+		"remark":  "这是备注",
+		"to_uid":  "111",
+		"vercode": "111@1",
+		"source":  source.SourceTypePhoneSearch,
+	}))))
+	req.Header.Set("token", testutil.Token)
+	s.GetRoute().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	apply, err := f.db.queryApplyWithUidAndToUid("111", testutil.UID)
+	assert.NoError(t, err)
+	assert.NotNil(t, apply)
+	assert.Equal(t, source.SourceTypePhoneSearch, apply.Source)
+	assert.Equal(t, 0, apply.Status)
+
+	// 222扫码申请加好友 通过时好友关系记录来源
+	err = f.db.insertApply(&FriendApplyModel{
+		UID:    testutil.UID,
+		ToUID:  "222",
+		Remark: "扫码添加",
+		Source: source.SourceTypeQRCode,
+	})
+	assert.NoError(t, err)
+	token := util.GenerUUID()
+	err = ctx.Cache().SetAndExpire(ctx.GetConfig().Cache.FriendApplyTokenCachePrefix+token+testutil.UID, util.ToJson(map[string]interface{}{
+		"from_uid":    "222",
+		"vercode":     "u1@3",
+		"source_type": source.SourceTypeQRCode,
+	}), ctx.GetConfig().Cache.FriendApplyExpire)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/friend/sure", bytes.NewReader([]byte(util.ToJson(map[string]interface{}{
+		"token": token,
+	}))))
+	req.Header.Set("token", testutil.Token)
+	s.GetRoute().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	apply, err = f.db.queryApplyWithUidAndToUid(testutil.UID, "222")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, apply.Status)
+	friend, err := f.db.queryWithUID(testutil.UID, "222")
+	assert.NoError(t, err)
+	assert.Equal(t, source.SourceTypeQRCode, friend.SourceType)
+	friend, err = f.db.queryWithUID("222", testutil.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, source.SourceTypeQRCode, friend.SourceType)
+
+	// 后台按来源统计申请数和通过数
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/manager/friend/source/stats", nil)
+	req.Header.Set("token", testutil.Token)
+	s.GetRoute().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		List []*managerFriendSourceStatResp `json:"list"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	stats := map[string]*managerFriendSourceStatResp{}
+	for _, stat := range resp.List {
+		stats[stat.Source] = stat
+	}
+	assert.Equal(t, 2, len(stats))
+	assert.Equal(t, int64(1), stats[source.SourceTypePhoneSearch].ApplyCount)
+	assert.Equal(t, int64(0), stats[source.SourceTypePhoneSearch].AcceptCount)
+	assert.Equal(t, "通过手机号搜索添加", stats[source.SourceTypePhoneSearch].SourceDesc)
+	assert.Equal(t, int64(1), stats[source.SourceTypeQRCode].ApplyCount)
+	assert.Equal(t, int64(1), stats[source.SourceTypeQRCode].AcceptCount)
+	assert.Equal(t, "通过扫一扫添加", stats[source.SourceTypeQRCode].SourceDesc)
+
+	// 日期格式有误
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/manager/friend/source/stats?start_date=2026/10/01", nil)
+	req.Header.Set("token", testutil.Token)
+	s.GetRoute().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
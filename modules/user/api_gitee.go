@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/network"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -134,6 +136,10 @@ func (u *User) giteeOAuth(c *wkhttp.Context) {
 			c.ResponseError(errors.New("用户不存在"))
 			return
 		}
+		if err = checkLoginTenant(userInfoM, c); err != nil {
+			c.ResponseError(err)
+			return
+		}
 		loginResp, err = u.execLogin(userInfoM, deviceFlag, nil, loginSpanCtx)
 		if err != nil {
 			c.ResponseError(err)
@@ -143,6 +149,10 @@ func (u *User) giteeOAuth(c *wkhttp.Context) {
 		publicIP := util.GetClientPublicIP(c.Request)
 		go u.sentWelcomeMsg(publicIP, userInfoM.UID)
 	} else {
+		if err = u.checkThirdRegister(); err != nil {
+			c.ResponseError(err)
+			return
+		}
 		// 创建用户
 		uid := util.GenerUUID()
 		name := userInfo.Name
@@ -157,6 +167,7 @@ func (u *User) giteeOAuth(c *wkhttp.Context) {
 			Name:     name,
 			GiteeUID: userInfo.Login,
 			Flag:     int(deviceFlag.Uint8()),
+			TenantID: tenant.ID(c.Context),
 		}
 		if userInfo.AvatarURL != "" && !strings.HasSuffix(userInfo.AvatarURL, "no_portrait.png") {
 			timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -183,7 +194,7 @@ func (u *User) giteeOAuth(c *wkhttp.Context) {
 		}()
 		if err != nil {
 			u.Error("开启事务失败！", zap.Error(err))
-			c.ResponseError(errors.New("开启事务失败！"))
+			c.ResponseError(errcode.ErrTxBegin)
 			return
 		}
 
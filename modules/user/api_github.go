@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/network"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -71,6 +73,10 @@ func (u *User) githubOAuth(c *wkhttp.Context) {
 			c.ResponseError(errors.New("用户不存在"))
 			return
 		}
+		if err = checkLoginTenant(userInfoM, c); err != nil {
+			c.ResponseError(err)
+			return
+		}
 		loginResp, err = u.execLogin(userInfoM, deviceFlag, nil, loginSpanCtx)
 		if err != nil {
 			c.ResponseError(err)
@@ -80,6 +86,10 @@ func (u *User) githubOAuth(c *wkhttp.Context) {
 		publicIP := util.GetClientPublicIP(c.Request)
 		go u.sentWelcomeMsg(publicIP, userInfoM.UID)
 	} else {
+		if err = u.checkThirdRegister(); err != nil {
+			c.ResponseError(err)
+			return
+		}
 		// 创建用户
 		uid := util.GenerUUID()
 		name := userInfo.Name
@@ -94,6 +104,7 @@ func (u *User) githubOAuth(c *wkhttp.Context) {
 			Name:      name,
 			GithubUID: userInfo.Login,
 			Flag:      int(deviceFlag.Uint8()),
+			TenantID:  tenant.ID(c.Context),
 		}
 		if userInfo.AvatarURL != "" {
 			timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -120,7 +131,7 @@ func (u *User) githubOAuth(c *wkhttp.Context) {
 		}()
 		if err != nil {
 			u.Error("开启事务失败！", zap.Error(err))
-			c.ResponseError(errors.New("开启事务失败！"))
+			c.ResponseError(errcode.ErrTxBegin)
 			return
 		}
 
@@ -6,8 +6,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	common2 "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/ipguard"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/eventbus"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 
@@ -30,6 +37,8 @@ type Manager struct {
 	friendDB      *friendDB
 	onlineService IOnlineService
 	commonService common2.IService
+	ipGuard       *ipguard.Guard
+	registerDB    *registerDB
 }
 
 // NewManager NewManager
@@ -44,6 +53,8 @@ func NewManager(ctx *config.Context) *Manager {
 		userSettingDB: NewSettingDB(ctx.DB()),
 		onlineService: NewOnlineService(ctx),
 		commonService: common2.NewService(ctx),
+		ipGuard:       ipguard.NewGuard(ctx),
+		registerDB:    newRegisterDB(ctx),
 	}
 	m.createManagerAccount()
 	return m
@@ -53,13 +64,15 @@ func NewManager(ctx *config.Context) *Manager {
 func (m *Manager) Route(r *wkhttp.WKHttp) {
 	user := r.Group("/v1/manager")
 	{
-		user.POST("/login", m.login) // 账号登录
+		user.POST("/login", m.ipGuard.Middleware(ipguard.SceneLogin), m.login) // 账号登录
 	}
 	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
 	{
 		auth.POST("/user/admin", m.addAdminUser)              // 添加一个管理员
 		auth.GET("/user/admin", m.getAdminUsers)              // 查询管理员用户
 		auth.DELETE("/user/admin", m.deleteAdminUsers)        // 删除管理员用户
+		auth.PUT("/user/admin/:uid", m.updateAdminUser)       // 修改管理员的名字、角色或密码
+		auth.GET("/roles", m.roles)                           // 管理后台的角色和权限
 		auth.POST("/user/add", m.addUser)                     // 添加一个用户
 		auth.GET("/user/list", m.list)                        // 用户列表
 		auth.GET("/user/friends", m.friends)                  // 某个用户的好友
@@ -67,11 +80,88 @@ func (m *Manager) Route(r *wkhttp.WKHttp) {
 		auth.GET("/user/disablelist", m.disableUsers)         // 封禁用户列表
 		auth.GET("user/online", m.online)                     // 在线设备信息
 		auth.PUT("/user/liftban/:uid/:status", m.liftBanUser) // 解禁或封禁用户
+		auth.POST("/user/ban", m.banUser)                     // 封禁用户（可设置时长和原因）
+		auth.GET("/user/ban/:uid", m.banInfo)                 // 用户当前的封禁
 		auth.POST("/user/updatepassword", m.updatePwd)        // 修改用户密码
+		auth.GET("/friend/source/stats", m.friendSourceStats) // 好友来源统计
+
+		// 以只读方式模拟登录用户 用于排查问题
+		auth.POST("/user/impersonate", m.impersonate)                          // 创建只读的会话
+		auth.GET("/user/impersonations", m.impersonations)                     // 模拟登录记录
+		auth.DELETE("/user/impersonations/:session_no", m.revokeImpersonation) // 提前结束会话
+
+		// 实时在线
+		auth.GET("/online/stats", m.onlineStats)                                     // 在线人数和设备类型统计
+		auth.GET("/online/sessions", m.onlineSessions)                               // 在线的会话列表
+		auth.DELETE("/online/sessions/:uid/:device_flag", m.disconnectOnlineSession) // 强制设备下线
+
+		// 注册控制
+		auth.GET("/register/settings", m.registerSettings)                      // 注册控制设置
+		auth.PUT("/register/settings", m.updateRegisterSettings)                // 修改注册控制
+		auth.GET("/register/applies", m.registerApplies)                        // 内测模式的注册申请
+		auth.PUT("/register/applies/:apply_no/approve", m.approveRegisterApply) // 通过注册申请
+		auth.PUT("/register/applies/:apply_no/reject", m.rejectRegisterApply)   // 拒绝注册申请
+	}
+}
+
+// 好友来源统计
+func (m *Manager) friendSourceStats(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermStatsRead)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	now := time.Now()
+	startDate := c.Query("start_date")
+	endDate := c.Query("end_date")
+	if endDate == "" {
+		endDate = now.Format("2006-01-02")
+	}
+	if startDate == "" {
+		startDate = now.AddDate(0, 0, -30).Format("2006-01-02")
+	}
+	start, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+	if err != nil {
+		c.ResponseError(errors.New("开始日期格式有误！"))
+		return
 	}
+	end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+	if err != nil {
+		c.ResponseError(errors.New("结束日期格式有误！"))
+		return
+	}
+	if end.Before(start) {
+		c.ResponseError(errors.New("结束日期不能早于开始日期！"))
+		return
+	}
+	list, err := m.db.queryFriendApplySourceStats(start.Format("2006-01-02 15:04:05"), end.AddDate(0, 0, 1).Format("2006-01-02 15:04:05"))
+	if err != nil {
+		m.Error("查询好友来源统计错误", zap.Error(err))
+		c.ResponseError(errors.New("查询好友来源统计错误"))
+		return
+	}
+	result := make([]*managerFriendSourceStatResp, 0, len(list))
+	for _, stat := range list {
+		sourceType := stat.Source
+		if sourceType == "" {
+			sourceType = source.SourceTypeUnknown
+		}
+		result = append(result, &managerFriendSourceStatResp{
+			Source:      sourceType,
+			SourceDesc:  source.GetSourceTypeDesc(sourceType),
+			ApplyCount:  stat.ApplyCount,
+			AcceptCount: stat.AcceptCount,
+		})
+	}
+	c.Response(map[string]interface{}{
+		"start_date": startDate,
+		"end_date":   endDate,
+		"list":       result,
+	})
 }
+
 func (m *Manager) online(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermUserRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -127,10 +217,14 @@ func (m *Manager) login(c *wkhttp.Context) {
 		c.ResponseError(errors.New("用户名或密码错误"))
 		return
 	}
-	if userInfo.Role != string(wkhttp.Admin) && userInfo.Role != string(wkhttp.SuperAdmin) {
+	if !rbac.IsManagerRole(userInfo.Role) {
 		c.ResponseError(errors.New("登录账号未开通管理权限"))
 		return
 	}
+	if userInfo.TenantID != "" || tenant.ID(c.Context) != "" {
+		c.ResponseError(errcode.ErrTenantManagerOnly)
+		return
+	}
 	token := util.GenerUUID()
 	// 将token设置到缓存
 	err = m.ctx.Cache().SetAndExpire(m.ctx.GetConfig().Cache.TokenCachePrefix+token, fmt.Sprintf("%s@%s@%s", userInfo.UID, userInfo.Name, userInfo.Role), m.ctx.GetConfig().Cache.TokenExpire)
@@ -148,16 +242,17 @@ func (m *Manager) login(c *wkhttp.Context) {
 	}
 
 	c.Response(&managerLoginResp{
-		UID:   userInfo.UID,
-		Token: token,
-		Name:  userInfo.Name,
-		Role:  userInfo.Role,
+		UID:         userInfo.UID,
+		Token:       token,
+		Name:        userInfo.Name,
+		Role:        userInfo.Role,
+		Permissions: rbac.Permissions(userInfo.Role),
 	})
 }
 
 // 删除管理员用户
 func (m *Manager) deleteAdminUsers(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermAdminManage)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -177,45 +272,62 @@ func (m *Manager) deleteAdminUsers(c *wkhttp.Context) {
 		c.ResponseError(errors.New("该用户不存在"))
 		return
 	}
-	if user.Role == "" {
-		c.ResponseError(errors.New("该用户不是管理员账号不能删除"))
+	if user.Role == rbac.RoleSuperAdmin {
+		c.ResponseError(errors.New("超级管理员账号不能删除"))
 		return
 	}
-	if user.Role == string(wkhttp.SuperAdmin) {
-		c.ResponseError(errors.New("超级管理员账号不能删除"))
+	if !rbac.ValidRole(user.Role) {
+		c.ResponseError(errors.New("该用户不是管理员账号不能删除"))
 		return
 	}
-	err = m.db.deleteUserWithUIDAndRole(uid, string(wkhttp.Admin))
+	err = m.db.deleteUserWithUIDAndRole(uid, user.Role)
 	if err != nil {
 		m.Error("删除管理员错误", zap.Error(err))
 		c.ResponseError(errors.New("删除管理员错误"))
 		return
 	}
-	oldToken, err := m.ctx.Cache().Get(fmt.Sprintf("%s%d%s", m.ctx.GetConfig().Cache.UIDTokenCachePrefix, config.Web, user.UID))
+	if err = m.revokeManagerToken(uid); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("uid=%s", uid), map[string]interface{}{"username": user.Username, "role": user.Role}, nil)
+	c.ResponseOK()
+}
+
+// revokeManagerToken 清除管理后台账号的登录token 删除账号或修改角色、密码后需要重新登录
+func (m *Manager) revokeManagerToken(uid string) error {
+	oldToken, err := m.ctx.Cache().Get(fmt.Sprintf("%s%d%s", m.ctx.GetConfig().Cache.UIDTokenCachePrefix, config.Web, uid))
 	if err != nil {
 		m.Error("获取旧token错误", zap.Error(err))
-		c.ResponseError(errors.New("获取旧token错误"))
-		return
+		return errors.New("获取旧token错误")
 	}
 	if oldToken != "" {
 		err = m.ctx.Cache().Delete(m.ctx.GetConfig().Cache.TokenCachePrefix + oldToken)
 		if err != nil {
 			m.Error("清除旧token数据错误", zap.Error(err))
-			c.ResponseError(errors.New("清除旧token数据错误"))
-			return
+			return errors.New("清除旧token数据错误")
 		}
 	}
-	c.ResponseOK()
+	return nil
 }
 
-// 查询管理员列表
+// 查询管理员列表 不包括超级管理员
 func (m *Manager) getAdminUsers(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermAdminManage)
 	if err != nil {
 		c.ResponseError(err)
 		return
 	}
-	users, err := m.db.queryUsersWithRole(string(wkhttp.Admin))
+	role := c.Query("role")
+	roles := rbac.Roles()
+	if role != "" {
+		if !rbac.ValidRole(role) {
+			c.ResponseError(errors.New("角色有误"))
+			return
+		}
+		roles = []string{role}
+	}
+	users, err := m.db.queryUsersWithRoles(roles)
 	if err != nil {
 		m.Error("查询管理员用户错误", zap.Error(err))
 		c.ResponseError(errors.New("查询管理员用户错误"))
@@ -228,6 +340,9 @@ func (m *Manager) getAdminUsers(c *wkhttp.Context) {
 				UID:          user.UID,
 				Name:         user.Name,
 				Username:     user.Username,
+				Role:         user.Role,
+				RoleName:     rbac.RoleName(user.Role),
+				Status:       user.Status,
 				RegisterTime: user.CreatedAt.String(),
 			})
 		}
@@ -235,9 +350,9 @@ func (m *Manager) getAdminUsers(c *wkhttp.Context) {
 	c.Response(list)
 }
 
-// 添加一个管理员
+// 添加一个管理员 没有指定角色时为管理员（admin）
 func (m *Manager) addAdminUser(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermAdminManage)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -246,6 +361,7 @@ func (m *Manager) addAdminUser(c *wkhttp.Context) {
 		LoginName string `json:"login_name"`
 		Name      string `json:"name"`
 		Password  string `json:"password"`
+		Role      string `json:"role"` // admin.管理员 support.客服 moderator.审核员 auditor.审计员
 	}
 	var req reqVO
 	if err := c.BindJSON(&req); err != nil {
@@ -264,9 +380,16 @@ func (m *Manager) addAdminUser(c *wkhttp.Context) {
 		c.ResponseError(errors.New("密码不能为空"))
 		return
 	}
-	user, err := m.db.queryUserWithNameAndRole(req.Name, string(wkhttp.Admin))
+	if req.Role == "" {
+		req.Role = rbac.RoleAdmin
+	}
+	if !rbac.ValidRole(req.Role) {
+		c.ResponseError(errors.New("角色有误"))
+		return
+	}
+	user, err := m.userDB.QueryByUsername(req.LoginName)
 	if err != nil {
-		m.Error("查询用户是否存在错误", zap.String("username", req.Name))
+		m.Error("查询用户是否存在错误", zap.String("username", req.LoginName))
 		c.ResponseError(errors.New("查询用户是否存在错误"))
 		return
 	}
@@ -274,38 +397,106 @@ func (m *Manager) addAdminUser(c *wkhttp.Context) {
 		c.ResponseError(errors.New("该用户名已存在"))
 		return
 	}
-	userModel := &Model{}
-	userModel.UID = util.GenerUUID()
-	userModel.Name = req.Name
-	userModel.Vercode = fmt.Sprintf("%s@%d", util.GenerUUID(), common.User)
-	userModel.QRVercode = fmt.Sprintf("%s@%d", util.GenerUUID(), common.QRCode)
-	userModel.Phone = ""
-	userModel.Username = req.LoginName
-	userModel.Zone = ""
-	userModel.Role = string(wkhttp.Admin)
-	userModel.Password = util.MD5(util.MD5(req.Password))
-	userModel.ShortNo = util.Ten2Hex(time.Now().UnixNano())
-	userModel.IsUploadAvatar = 0
-	userModel.NewMsgNotice = 0
-	userModel.MsgShowDetail = 0
-	userModel.SearchByPhone = 0
-	userModel.SearchByShort = 0
-	userModel.VoiceOn = 0
-	userModel.ShockOn = 0
-	userModel.Sex = 1
-	userModel.Status = int(common.UserAvailable)
+	userModel := newAdminModel(req.LoginName, req.Name, req.Password, req.Role)
 	err = m.userDB.Insert(userModel)
 	if err != nil {
 		m.Error("添加管理员错误", zap.String("username", req.Name))
 		c.ResponseError(err)
 		return
 	}
+	audit.SetChange(c, fmt.Sprintf("uid=%s", userModel.UID), nil, map[string]interface{}{"username": req.LoginName, "name": req.Name, "role": req.Role})
 	c.ResponseOK()
 }
 
+// 修改管理员的名字、角色或密码 修改角色或密码后需要重新登录
+func (m *Manager) updateAdminUser(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermAdminManage)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		Name     string `json:"name"`     // 为空时不修改
+		Role     string `json:"role"`     // 为空时不修改
+		Password string `json:"password"` // 为空时不修改
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	uid := c.Param("uid")
+	user, err := m.userDB.QueryByUID(uid)
+	if err != nil {
+		m.Error("查询管理员用户错误", zap.Error(err))
+		c.ResponseError(errors.New("查询管理员用户错误"))
+		return
+	}
+	if user == nil || len(user.UID) == 0 {
+		c.ResponseError(errors.New("该用户不存在"))
+		return
+	}
+	if user.Role == rbac.RoleSuperAdmin {
+		c.ResponseError(errors.New("超级管理员账号不能修改"))
+		return
+	}
+	if !rbac.ValidRole(user.Role) {
+		c.ResponseError(errors.New("该用户不是管理员账号"))
+		return
+	}
+	if req.Role != "" && !rbac.ValidRole(req.Role) {
+		c.ResponseError(errors.New("角色有误"))
+		return
+	}
+	if req.Password != "" && len(req.Password) < 6 {
+		c.ResponseError(errors.New("密码长度必须大于6位"))
+		return
+	}
+	userMap := map[string]interface{}{}
+	if strings.TrimSpace(req.Name) != "" {
+		userMap["name"] = strings.TrimSpace(req.Name)
+	}
+	if req.Role != "" && req.Role != user.Role {
+		userMap["role"] = req.Role
+	}
+	if req.Password != "" {
+		userMap["password"] = util.MD5(util.MD5(req.Password))
+	}
+	if len(userMap) == 0 {
+		c.ResponseOK()
+		return
+	}
+	err = m.userDB.updateUser(userMap, uid)
+	if err != nil {
+		m.Error("修改管理员错误", zap.Error(err))
+		c.ResponseError(errors.New("修改管理员错误"))
+		return
+	}
+	if userMap["role"] != nil || userMap["password"] != nil {
+		if err = m.revokeManagerToken(uid); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+	audit.SetChange(c, fmt.Sprintf("uid=%s", uid), map[string]interface{}{"name": user.Name, "role": user.Role}, map[string]interface{}{"name": req.Name, "role": req.Role, "password_changed": req.Password != ""})
+	c.ResponseOK()
+}
+
+// 管理后台的角色和权限
+func (m *Manager) roles(c *wkhttp.Context) {
+	if err := rbac.CheckManager(c); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	list := make([]*managerRoleResp, 0, len(rbac.Roles())+1)
+	for _, role := range append([]string{rbac.RoleSuperAdmin}, rbac.Roles()...) {
+		list = append(list, newManagerRoleResp(role))
+	}
+	c.Response(list)
+}
+
 // 添加一个用户
 func (m *Manager) addUser(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermUserWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -329,15 +520,32 @@ func (m *Manager) addUser(c *wkhttp.Context) {
 		c.ResponseError(errors.New("该用户已存在"))
 		return
 	}
-	uid := util.GenerUUID()
+	userModel := &Model{}
+	userModel.UID = util.GenerUUID()
+	userModel.Name = req.Name
+	userModel.Phone = req.Phone
+	userModel.Username = fmt.Sprintf("%s%s", req.Zone, req.Phone)
+	userModel.Zone = req.Zone
+	userModel.Password = util.MD5(util.MD5(req.Password))
+	userModel.Sex = req.Sex
+	if err = m.createUser(userModel); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// createUser 后台创建用户 userModel需要设置好uid、名字、用户名和加密后的密码
+func (m *Manager) createUser(userModel *Model) error {
+	uid := userModel.UID
+	var err error
 	var shortNo = ""
 	var shortNumStatus = 0
 	if m.ctx.GetConfig().ShortNo.NumOn {
 		shortNo, err = m.commonService.GetShortno()
 		if err != nil {
 			m.Error("获取短编号失败！", zap.Error(err))
-			c.ResponseError(errors.New("获取短编号失败！"))
-			return
+			return errors.New("获取短编号失败！")
 		}
 	} else {
 		shortNo = util.Ten2Hex(time.Now().UnixNano())
@@ -352,15 +560,8 @@ func (m *Manager) addUser(c *wkhttp.Context) {
 			panic(err)
 		}
 	}()
-	userModel := &Model{}
-	userModel.UID = uid
-	userModel.Name = req.Name
 	userModel.Vercode = fmt.Sprintf("%s@%d", util.GenerUUID(), common.User)
 	userModel.QRVercode = fmt.Sprintf("%s@%d", util.GenerUUID(), common.QRCode)
-	userModel.Phone = req.Phone
-	userModel.Username = fmt.Sprintf("%s%s", req.Zone, req.Phone)
-	userModel.Zone = req.Zone
-	userModel.Password = util.MD5(util.MD5(req.Password))
 	userModel.ShortNo = shortNo
 	userModel.IsUploadAvatar = 0
 	userModel.NewMsgNotice = 1
@@ -370,27 +571,23 @@ func (m *Manager) addUser(c *wkhttp.Context) {
 	userModel.SearchByShort = 1
 	userModel.VoiceOn = 1
 	userModel.ShockOn = 1
-	userModel.Sex = req.Sex
 	userModel.Status = int(common.UserAvailable)
 	err = m.userDB.insertTx(userModel, tx)
 	if err != nil {
 		tx.Rollback()
-		m.Error("添加用户错误", zap.String("username", req.Phone))
-		c.ResponseError(err)
-		return
+		m.Error("添加用户错误", zap.String("username", userModel.Username))
+		return err
 	}
 
 	err = m.addSystemFriend(uid)
 	if err != nil {
 		tx.Rollback()
-		c.ResponseError(errors.New("添加后台生成用户和系统账号为好友关系失败"))
-		return
+		return errors.New("添加后台生成用户和系统账号为好友关系失败")
 	}
 	err = m.addFileHelperFriend(uid)
 	if err != nil {
 		tx.Rollback()
-		c.ResponseError(errors.New("添加后台生成用户和文件助手为好友关系失败"))
-		return
+		return errors.New("添加后台生成用户和文件助手为好友关系失败")
 	}
 	//发送用户注册事件
 	eventID, err := m.ctx.EventBegin(&wkevent.Data{
@@ -403,23 +600,26 @@ func (m *Manager) addUser(c *wkhttp.Context) {
 	if err != nil {
 		tx.RollbackUnlessCommitted()
 		m.Error("开启事件失败！", zap.Error(err))
-		c.ResponseError(errors.New("开启事件失败！"))
-		return
+		return errors.New("开启事件失败！")
 	}
 	err = tx.Commit()
 	if err != nil {
 		tx.Rollback()
 		m.Error("数据库事物提交失败", zap.Error(err))
-		c.ResponseError(errors.New("数据库事物提交失败"))
-		return
+		return errors.New("数据库事物提交失败")
 	}
 	m.ctx.EventCommit(eventID)
-	c.ResponseOK()
+	eventbus.Publish(eventbus.NewEvent(eventbus.UserRegistered, uid, &eventbus.UserRegisteredData{
+		UID:    uid,
+		Name:   userModel.Name,
+		Source: "manager",
+	}))
+	return nil
 }
 
 // 用户列表
 func (m *Manager) list(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermUserRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -548,7 +748,7 @@ func (m *Manager) list(c *wkhttp.Context) {
 
 // 查询某个用户的好友
 func (m *Manager) friends(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermUserRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -580,7 +780,7 @@ func (m *Manager) friends(c *wkhttp.Context) {
 
 // 查询某个用户的黑名单
 func (m *Manager) blacklist(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermUserRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -609,7 +809,7 @@ func (m *Manager) blacklist(c *wkhttp.Context) {
 
 // 查看封禁用户列表
 func (m *Manager) disableUsers(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermUserRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -649,7 +849,7 @@ func (m *Manager) disableUsers(c *wkhttp.Context) {
 
 // 封禁或解禁用户
 func (m *Manager) liftBanUser(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermUserBan)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -665,29 +865,51 @@ func (m *Manager) liftBanUser(c *wkhttp.Context) {
 		return
 	}
 	userStatus, _ := strconv.Atoi(status)
-	if userStatus != int(common.UserAvailable) && userStatus != int(common.UserDisable) {
-		c.ResponseError(errors.New("修改状态类型不匹配"))
-		return
-	}
 	userInfo, err := m.userDB.QueryByUID(uid)
 	if err != nil {
 		m.Error("查询用户信息失败！", zap.String("uid", uid))
 		c.ResponseError(errors.New("查询用户信息错误"))
 		return
 	}
-	if userInfo == nil {
-		c.ResponseError(errors.New("操作用户不存在"))
+	if userStatus == int(common.UserDisable) {
+		err = banUser(m.ctx, m.userDB, m.Log, uid, "", 0, c.GetLoginUID())
+	} else {
+		err = updateUserStatus(m.ctx, m.userDB, m.Log, uid, userStatus)
+	}
+	if err != nil {
+		c.ResponseError(err)
 		return
 	}
+	audit.SetChange(c, fmt.Sprintf("uid=%s", uid), map[string]interface{}{"status": userInfo.Status}, map[string]interface{}{"status": userStatus})
+	c.ResponseOK()
+}
+
+// updateUserStatus 修改用户状态 封禁时同时封禁IM频道并下线用户的所有设备
+func updateUserStatus(ctx *config.Context, userDB *DB, lg log.Log, uid string, userStatus int) error {
+	if userStatus != int(common.UserAvailable) && userStatus != int(common.UserDisable) {
+		return errors.New("修改状态类型不匹配")
+	}
+	userInfo, err := userDB.QueryByUID(uid)
+	if err != nil {
+		lg.Error("查询用户信息失败！", zap.String("uid", uid))
+		return errors.New("查询用户信息错误")
+	}
+	if userInfo == nil {
+		return errors.New("操作用户不存在")
+	}
+	if userStatus == int(common.UserAvailable) {
+		if err = userDB.liftBans(uid); err != nil {
+			lg.Error("解除封禁记录失败！", zap.Error(err), zap.String("uid", uid))
+			return errors.New("解除封禁记录失败")
+		}
+	}
 	if userInfo.Status == userStatus {
-		c.ResponseOK()
-		return
+		return nil
 	}
-	err = m.userDB.UpdateUsersWithField("status", status, uid)
+	err = userDB.UpdateUsersWithField("status", strconv.Itoa(userStatus), uid)
 	if err != nil {
-		m.Error("修改用户状态错误", zap.Error(err))
-		c.ResponseError(errors.New("修改用户状态错误"))
-		return
+		lg.Error("修改用户状态错误", zap.Error(err))
+		return errors.New("修改用户状态错误")
 	}
 
 	ban := 0
@@ -695,28 +917,26 @@ func (m *Manager) liftBanUser(c *wkhttp.Context) {
 		ban = 1
 	}
 
-	err = m.ctx.IMCreateOrUpdateChannelInfo(&config.ChannelInfoCreateReq{
+	err = ctx.IMCreateOrUpdateChannelInfo(&config.ChannelInfoCreateReq{
 		ChannelID:   uid,
 		ChannelType: common.ChannelTypePerson.Uint8(),
 		Ban:         ban,
 	})
 	if err != nil {
-		m.Error("更新WebIM的token失败！", zap.Error(err))
-		c.ResponseError(errors.New("更新IM的token失败！"))
-		return
+		lg.Error("更新WebIM的token失败！", zap.Error(err))
+		return errors.New("更新IM的token失败！")
 	}
-	err = m.ctx.QuitUserDevice(userInfo.UID, -1)
+	err = ctx.QuitUserDevice(userInfo.UID, -1)
 	if err != nil {
-		m.Error("下线用户所有登录设备错误", zap.Error(err), zap.String("uid", uid))
-		c.ResponseError(errors.New("下线用户所有登录设备错误"))
-		return
+		lg.Error("下线用户所有登录设备错误", zap.Error(err), zap.String("uid", uid))
+		return errors.New("下线用户所有登录设备错误")
 	}
-	c.ResponseOK()
+	return nil
 }
 
 // 修改登录密码
 func (m *Manager) updatePwd(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.CheckManager(c)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -767,23 +987,23 @@ func (m *Manager) updatePwd(c *wkhttp.Context) {
 }
 func (r managerAddUserReq) checkAddUserReq() error {
 	if strings.TrimSpace(r.Name) == "" {
-		return errors.New("用户名不能为空！")
+		return errcode.ErrUsernameRequired
 	}
 	if strings.TrimSpace(r.Password) == "" {
-		return errors.New("密码不能为空！")
+		return errcode.ErrPasswordRequired
 	}
 	if strings.TrimSpace(r.Phone) == "" {
-		return errors.New("手机号不能为空！")
+		return errcode.ErrPhoneRequired
 	}
 
 	return nil
 }
 func (r managerLoginReq) Check() error {
 	if strings.TrimSpace(r.Username) == "" {
-		return errors.New("用户名不能为空！")
+		return errcode.ErrUsernameRequired
 	}
 	if strings.TrimSpace(r.Password) == "" {
-		return errors.New("密码不能为空！")
+		return errcode.ErrPasswordRequired
 	}
 	return nil
 }
@@ -874,6 +1094,63 @@ func (m *Manager) addSystemFriend(uid string) error {
 	return nil
 }
 
+// newAdminModel 管理后台账号 不能被搜索到 也不接收消息通知
+func newAdminModel(loginName string, name string, password string, role string) *Model {
+	userModel := &Model{}
+	userModel.UID = util.GenerUUID()
+	userModel.Name = name
+	userModel.Vercode = fmt.Sprintf("%s@%d", util.GenerUUID(), common.User)
+	userModel.QRVercode = fmt.Sprintf("%s@%d", util.GenerUUID(), common.QRCode)
+	userModel.Phone = ""
+	userModel.Username = loginName
+	userModel.Zone = ""
+	userModel.Role = role
+	userModel.Password = util.MD5(util.MD5(password))
+	userModel.ShortNo = util.Ten2Hex(time.Now().UnixNano())
+	userModel.IsUploadAvatar = 0
+	userModel.NewMsgNotice = 0
+	userModel.MsgShowDetail = 0
+	userModel.SearchByPhone = 0
+	userModel.SearchByShort = 0
+	userModel.VoiceOn = 0
+	userModel.ShockOn = 0
+	userModel.Sex = 1
+	userModel.Status = int(common.UserAvailable)
+	return userModel
+}
+
+// CreateAdmin 创建管理后台的账号 供create-admin命令在没有管理员时初始化 role为空时为管理员（admin） 返回账号的uid
+func CreateAdmin(ctx *config.Context, loginName string, name string, password string, role string) (string, error) {
+	if loginName == "" {
+		return "", errors.New("登录用户名不能为空")
+	}
+	if name == "" {
+		name = loginName
+	}
+	if password == "" {
+		return "", errcode.ErrPasswordRequired
+	}
+	if role == "" {
+		role = rbac.RoleAdmin
+	}
+	if !rbac.IsManagerRole(role) {
+		return "", errors.New("角色有误")
+	}
+	userDB := NewDB(ctx)
+	user, err := userDB.QueryByUsername(loginName)
+	if err != nil {
+		return "", err
+	}
+	if user != nil && len(user.UID) > 0 {
+		return "", errors.New("该用户名已存在")
+	}
+	userModel := newAdminModel(loginName, name, password, role)
+	if err = userDB.Insert(userModel); err != nil {
+		return "", err
+	}
+	return userModel.UID, nil
+}
+
 // 创建一个系统管理账户
 func (m *Manager) createManagerAccount() {
 	user, err := m.userDB.QueryByUID(m.ctx.GetConfig().Account.AdminUID)
@@ -885,8 +1162,8 @@ func (m *Manager) createManagerAccount() {
 		return
 	}
 
-	username := string(wkhttp.SuperAdmin)
-	role := string(wkhttp.SuperAdmin)
+	username := rbac.RoleSuperAdmin
+	role := rbac.RoleSuperAdmin
 	var pwd = m.ctx.GetConfig().AdminPwd
 	err = m.userDB.Insert(&Model{
 		UID:      m.ctx.GetConfig().Account.AdminUID,
@@ -930,10 +1207,11 @@ type managerLoginReq struct {
 }
 
 type managerLoginResp struct {
-	UID   string `json:"uid"`
-	Token string `json:"token"`
-	Name  string `json:"name"`
-	Role  string `json:"role"`
+	UID         string            `json:"uid"`
+	Token       string            `json:"token"`
+	Name        string            `json:"name"`
+	Role        string            `json:"role"`
+	Permissions []rbac.Permission `json:"permissions"` // 登录账号拥有的权限
 }
 type managerAddUserReq struct {
 	Name     string `json:"name"`
@@ -951,8 +1229,26 @@ type adminUserResp struct {
 	Name         string `json:"name"`
 	UID          string `json:"uid"`
 	Username     string `json:"username"`
+	Role         string `json:"role"`
+	RoleName     string `json:"role_name"`
+	Status       int    `json:"status"`
 	RegisterTime string `json:"register_time"`
 }
+
+type managerRoleResp struct {
+	Role        string            `json:"role"`
+	Name        string            `json:"name"`
+	Permissions []rbac.Permission `json:"permissions"`
+}
+
+func newManagerRoleResp(role string) *managerRoleResp {
+	return &managerRoleResp{
+		Role:        role,
+		Name:        rbac.RoleName(role),
+		Permissions: rbac.Permissions(role),
+	}
+}
+
 type managerUserResp struct {
 	Name           string `json:"name"`
 	UID            string `json:"uid"`
@@ -980,6 +1276,13 @@ type managerFriendResp struct {
 	RelationshipTime string `json:"relationship_time"`
 }
 
+type managerFriendSourceStatResp struct {
+	Source      string `json:"source"`       // 来源类型
+	SourceDesc  string `json:"source_desc"`  // 来源描述
+	ApplyCount  int64  `json:"apply_count"`  // 申请数量
+	AcceptCount int64  `json:"accept_count"` // 通过数量
+}
+
 type managerDisableUserResp struct {
 	Name         string `json:"name"`
 	UID          string `json:"uid"`
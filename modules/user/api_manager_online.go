@@ -0,0 +1,176 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 在线统计 数据来自IM的上下线事件
+func (m *Manager) onlineStats(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	onlineUsers, err := m.onlineService.GetOnlineCount()
+	if err != nil {
+		m.Error("查询在线人数失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询在线人数失败！"))
+		return
+	}
+	stats, err := m.db.queryOnlinePlatformStats()
+	if err != nil {
+		m.Error("查询设备在线统计失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询设备在线统计失败！"))
+		return
+	}
+	c.Response(newOnlineStatsResp(onlineUsers, stats))
+}
+
+// 在线的会话列表 可按用户和设备类型查询
+func (m *Manager) onlineSessions(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	deviceFlag := -1
+	if flagStr := c.Query("device_flag"); flagStr != "" {
+		flag, err := parseDeviceFlag(flagStr)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		deviceFlag = int(flag)
+	}
+	filter := onlineSessionFilter{
+		keyword:    strings.TrimSpace(c.Query("keyword")),
+		deviceFlag: deviceFlag,
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.db.queryOnlineSessionsWithPage(filter, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询在线会话失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询在线会话失败！"))
+		return
+	}
+	count, err := m.db.queryOnlineSessionCount(filter)
+	if err != nil {
+		m.Error("查询在线会话数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询在线会话数量失败！"))
+		return
+	}
+	list := make([]*onlineSessionResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newOnlineSessionResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 强制用户某个设备类型下线
+func (m *Manager) disconnectOnlineSession(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserBan); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	uid := c.Param("uid")
+	deviceFlag, err := parseDeviceFlag(c.Param("device_flag"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	online, err := m.onlineService.DeviceOnline(uid, deviceFlag)
+	if err != nil {
+		m.Error("查询设备在线状态失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询设备在线状态失败！"))
+		return
+	}
+	if !online {
+		c.ResponseError(errors.New("该设备不在线"))
+		return
+	}
+	err = m.ctx.QuitUserDevice(uid, int(deviceFlag))
+	if err != nil {
+		m.Error("强制设备下线失败！", zap.Error(err), zap.String("uid", uid), zap.Uint8("deviceFlag", deviceFlag.Uint8()))
+		c.ResponseError(errors.New("强制设备下线失败！"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("uid=%s", uid), map[string]interface{}{"device_flag": deviceFlag, "online": 1}, map[string]interface{}{"device_flag": deviceFlag, "online": 0})
+	c.ResponseOK()
+}
+
+// parseDeviceFlag 解析设备类型 只支持APP、Web和PC
+func parseDeviceFlag(value string) (config.DeviceFlag, error) {
+	flag, err := strconv.ParseUint(strings.TrimSpace(value), 10, 8)
+	if err != nil || config.DeviceFlag(flag) > config.PC {
+		return 0, errors.New("设备类型不正确")
+	}
+	return config.DeviceFlag(flag), nil
+}
+
+type onlineStatsResp struct {
+	OnlineUsers   int64                 `json:"online_users"`   // 在线的用户数
+	OnlineDevices int64                 `json:"online_devices"` // 在线的设备类型数（同一用户的同一设备类型计一次）
+	Connections   int64                 `json:"connections"`    // 连接数
+	Platforms     []*onlinePlatformResp `json:"platforms"`      // 按设备类型统计
+}
+
+type onlinePlatformResp struct {
+	DeviceFlag  uint8  `json:"device_flag"`
+	DeviceName  string `json:"device_name"`
+	Users       int64  `json:"users"`
+	Connections int64  `json:"connections"`
+}
+
+func newOnlineStatsResp(onlineUsers int64, stats []*onlinePlatformStatModel) *onlineStatsResp {
+	resp := &onlineStatsResp{
+		OnlineUsers: onlineUsers,
+		Platforms:   make([]*onlinePlatformResp, 0, len(stats)),
+	}
+	for _, stat := range stats {
+		resp.OnlineDevices += stat.UserCount
+		resp.Connections += stat.ConnectionCount
+		resp.Platforms = append(resp.Platforms, &onlinePlatformResp{
+			DeviceFlag:  stat.DeviceFlag,
+			DeviceName:  stat.DeviceName,
+			Users:       stat.UserCount,
+			Connections: stat.ConnectionCount,
+		})
+	}
+	return resp
+}
+
+type onlineSessionResp struct {
+	UID         string `json:"uid"`
+	Name        string `json:"name"`
+	Username    string `json:"username"`
+	DeviceFlag  uint8  `json:"device_flag"`
+	DeviceName  string `json:"device_name"`
+	LastOnline  int    `json:"last_online"`  // 上线时间
+	OnlineCount int    `json:"online_count"` // 该设备类型下的连接数
+}
+
+func newOnlineSessionResp(m *onlineSessionModel) *onlineSessionResp {
+	onlineCount := m.OnlineCount
+	if onlineCount <= 0 { // 升级前上线的会话没有记录连接数
+		onlineCount = 1
+	}
+	return &onlineSessionResp{
+		UID:         m.UID,
+		Name:        m.Name,
+		Username:    m.Username,
+		DeviceFlag:  m.DeviceFlag,
+		DeviceName:  m.DeviceName,
+		LastOnline:  m.LastOnline,
+		OnlineCount: onlineCount,
+	}
+}
@@ -0,0 +1,47 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeviceFlag(t *testing.T) {
+	flag, err := parseDeviceFlag("0")
+	assert.NoError(t, err)
+	assert.Equal(t, config.APP, flag)
+
+	flag, err = parseDeviceFlag("2")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PC, flag)
+
+	_, err = parseDeviceFlag("3")
+	assert.Error(t, err)
+	_, err = parseDeviceFlag("-1")
+	assert.Error(t, err)
+	_, err = parseDeviceFlag("web")
+	assert.Error(t, err)
+}
+
+func TestNewOnlineStatsResp(t *testing.T) {
+	resp := newOnlineStatsResp(3, []*onlinePlatformStatModel{
+		{DeviceFlag: 0, DeviceName: "手机", UserCount: 3, ConnectionCount: 4},
+		{DeviceFlag: 1, DeviceName: "Web", UserCount: 1, ConnectionCount: 2},
+	})
+	assert.Equal(t, int64(3), resp.OnlineUsers)
+	assert.Equal(t, int64(4), resp.OnlineDevices)
+	assert.Equal(t, int64(6), resp.Connections)
+	assert.Len(t, resp.Platforms, 2)
+	assert.Equal(t, "Web", resp.Platforms[1].DeviceName)
+
+	resp = newOnlineStatsResp(0, nil)
+	assert.NotNil(t, resp.Platforms)
+}
+
+func TestNewOnlineSessionResp(t *testing.T) {
+	resp := newOnlineSessionResp(&onlineSessionModel{UID: "u1", DeviceFlag: 1, OnlineCount: 0})
+	assert.Equal(t, 1, resp.OnlineCount)
+	resp = newOnlineSessionResp(&onlineSessionModel{UID: "u1", DeviceFlag: 1, OnlineCount: 3})
+	assert.Equal(t, 3, resp.OnlineCount)
+}
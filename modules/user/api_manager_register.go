@@ -0,0 +1,298 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// registerRejectReasonMaxLen 拒绝原因的最大长度
+const registerRejectReasonMaxLen = 200
+
+// 注册控制设置
+func (m *Manager) registerSettings(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	setting, err := m.registerDB.querySetting()
+	if err != nil {
+		m.Error("查询注册控制失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询注册控制失败！"))
+		return
+	}
+	c.Response(newRegisterSettingResp(setting))
+}
+
+// 修改注册控制 立即生效
+func (m *Manager) updateRegisterSettings(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermConfigWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req registerSettingReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	setting, err := req.toModel()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	old, err := m.registerDB.querySetting()
+	if err != nil {
+		m.Error("查询注册控制失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询注册控制失败！"))
+		return
+	}
+	setting.Updater = c.GetLoginUID()
+	if err = m.registerDB.updateSetting(setting); err != nil {
+		m.Error("修改注册控制失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改注册控制失败！"))
+		return
+	}
+	audit.SetChange(c, "register_setting", newRegisterSettingResp(old), newRegisterSettingResp(setting))
+	c.ResponseOK()
+}
+
+// 注册申请列表 status为空时查询所有
+func (m *Manager) registerApplies(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	status := -1
+	if statusStr := strings.TrimSpace(c.Query("status")); statusStr != "" {
+		value, err := strconv.Atoi(statusStr)
+		if err != nil || value < RegisterApplyPending || value > RegisterApplyRejected {
+			c.ResponseError(errors.New("申请状态不正确"))
+			return
+		}
+		status = value
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.registerDB.queryApplysWithPage(status, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询注册申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询注册申请失败！"))
+		return
+	}
+	count, err := m.registerDB.queryApplyCount(status)
+	if err != nil {
+		m.Error("查询注册申请数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询注册申请数量失败！"))
+		return
+	}
+	list := make([]*registerApplyResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newRegisterApplyResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 通过注册申请 创建账号
+func (m *Manager) approveRegisterApply(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	apply, err := m.queryPendingApply(c.Param("apply_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	userInfo, err := m.userDB.QueryByUsername(apply.Username)
+	if err != nil {
+		m.Error("查询用户信息失败！", zap.Error(err), zap.String("username", apply.Username))
+		c.ResponseError(errcode.ErrQueryUser)
+		return
+	}
+	if userInfo != nil {
+		c.ResponseError(errors.New("该用户已存在，请拒绝此申请"))
+		return
+	}
+	uid := util.GenerUUID()
+	// 先把申请改为已通过 防止重复创建用户
+	ok, err := m.registerDB.updateApplyReviewed(apply.Id, RegisterApplyApproved, c.GetLoginUID(), "", uid, time.Now().Unix())
+	if err != nil {
+		m.Error("修改注册申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改注册申请失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("该申请已被审核"))
+		return
+	}
+	userModel := &Model{}
+	userModel.UID = uid
+	userModel.Name = apply.Name
+	userModel.Username = apply.Username
+	userModel.Zone = apply.Zone
+	userModel.Phone = apply.Phone
+	userModel.Password = apply.Password
+	userModel.Sex = 1
+	if err = m.createUser(userModel); err != nil {
+		if resetErr := m.registerDB.resetApplyPending(apply.Id); resetErr != nil {
+			m.Error("恢复注册申请失败！", zap.Error(resetErr), zap.String("applyNo", apply.ApplyNo))
+		}
+		c.ResponseError(err)
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("apply_no=%s", apply.ApplyNo), map[string]interface{}{"status": RegisterApplyPending}, map[string]interface{}{"status": RegisterApplyApproved, "uid": uid})
+	c.Response(map[string]interface{}{
+		"uid": uid,
+	})
+}
+
+// 拒绝注册申请
+func (m *Manager) rejectRegisterApply(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserWrite); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"` // 拒绝原因
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("请求数据格式有误！"))
+		return
+	}
+	if len([]rune(req.Reason)) > registerRejectReasonMaxLen {
+		c.ResponseError(fmt.Errorf("拒绝原因不能超过%d个字", registerRejectReasonMaxLen))
+		return
+	}
+	apply, err := m.queryPendingApply(c.Param("apply_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	ok, err := m.registerDB.updateApplyReviewed(apply.Id, RegisterApplyRejected, c.GetLoginUID(), req.Reason, "", time.Now().Unix())
+	if err != nil {
+		m.Error("修改注册申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改注册申请失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("该申请已被审核"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("apply_no=%s", apply.ApplyNo), map[string]interface{}{"status": RegisterApplyPending}, map[string]interface{}{"status": RegisterApplyRejected, "reason": req.Reason})
+	c.ResponseOK()
+}
+
+func (m *Manager) queryPendingApply(applyNo string) (*registerApplyModel, error) {
+	if strings.TrimSpace(applyNo) == "" {
+		return nil, errors.New("申请编号不能为空")
+	}
+	apply, err := m.registerDB.queryApplyWithApplyNo(applyNo)
+	if err != nil {
+		m.Error("查询注册申请失败！", zap.Error(err))
+		return nil, errors.New("查询注册申请失败！")
+	}
+	if apply == nil {
+		return nil, errors.New("注册申请不存在")
+	}
+	if apply.Status != RegisterApplyPending {
+		return nil, errors.New("该申请已被审核")
+	}
+	return apply, nil
+}
+
+type registerSettingReq struct {
+	PhoneAllowPrefixes []string `json:"phone_allow_prefixes"` // 允许注册的手机号前缀（带区号） 为空时不限制
+	PhoneDenyPrefixes  []string `json:"phone_deny_prefixes"`  // 禁止注册的手机号前缀 优先于允许的前缀
+	DailyLimit         int      `json:"daily_limit"`          // 每天最多注册的人数 0为不限制
+	ClosedBeta         int      `json:"closed_beta"`          // 内测模式 1.开启 注册需要审核
+}
+
+func (r registerSettingReq) toModel() (*registerSettingModel, error) {
+	if r.DailyLimit < 0 {
+		return nil, errors.New("每天注册人数不能小于0")
+	}
+	if r.ClosedBeta != 0 && r.ClosedBeta != 1 {
+		return nil, errors.New("内测模式只能为0或1")
+	}
+	allowPrefixes, err := normalizePrefixes(r.PhoneAllowPrefixes)
+	if err != nil {
+		return nil, err
+	}
+	denyPrefixes, err := normalizePrefixes(r.PhoneDenyPrefixes)
+	if err != nil {
+		return nil, err
+	}
+	return &registerSettingModel{
+		PhoneAllowPrefixes: strings.Join(allowPrefixes, ","),
+		PhoneDenyPrefixes:  strings.Join(denyPrefixes, ","),
+		DailyLimit:         r.DailyLimit,
+		ClosedBeta:         r.ClosedBeta,
+	}, nil
+}
+
+type registerSettingResp struct {
+	PhoneAllowPrefixes []string `json:"phone_allow_prefixes"`
+	PhoneDenyPrefixes  []string `json:"phone_deny_prefixes"`
+	DailyLimit         int      `json:"daily_limit"`
+	ClosedBeta         int      `json:"closed_beta"`
+	Updater            string   `json:"updater"`
+}
+
+func newRegisterSettingResp(m *registerSettingModel) *registerSettingResp {
+	if m == nil {
+		return &registerSettingResp{
+			PhoneAllowPrefixes: make([]string, 0),
+			PhoneDenyPrefixes:  make([]string, 0),
+		}
+	}
+	return &registerSettingResp{
+		PhoneAllowPrefixes: splitPrefixes(m.PhoneAllowPrefixes),
+		PhoneDenyPrefixes:  splitPrefixes(m.PhoneDenyPrefixes),
+		DailyLimit:         m.DailyLimit,
+		ClosedBeta:         m.ClosedBeta,
+		Updater:            m.Updater,
+	}
+}
+
+type registerApplyResp struct {
+	ApplyNo      string `json:"apply_no"`
+	Username     string `json:"username"`
+	Zone         string `json:"zone"`
+	Phone        string `json:"phone"`
+	Name         string `json:"name"`
+	IP           string `json:"ip"`
+	Status       int    `json:"status"`
+	Reviewer     string `json:"reviewer"`
+	RejectReason string `json:"reject_reason"`
+	ReviewedAt   int64  `json:"reviewed_at"`
+	UID          string `json:"uid"`
+	CreatedAt    string `json:"created_at"`
+}
+
+func newRegisterApplyResp(m *registerApplyModel) *registerApplyResp {
+	return &registerApplyResp{
+		ApplyNo:      m.ApplyNo,
+		Username:     m.Username,
+		Zone:         m.Zone,
+		Phone:        m.Phone,
+		Name:         m.Name,
+		IP:           m.IP,
+		Status:       m.Status,
+		Reviewer:     m.Reviewer,
+		RejectReason: m.RejectReason,
+		ReviewedAt:   m.ReviewedAt,
+		UID:          m.UID,
+		CreatedAt:    m.CreatedAt.String(),
+	}
+}
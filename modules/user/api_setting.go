@@ -1,6 +1,8 @@
 package user
 
 import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -28,7 +30,7 @@ func (u *Setting) userSettingUpdate(c *wkhttp.Context) {
 	var settingMap map[string]interface{}
 	if err := c.BindJSON(&settingMap); err != nil {
 		u.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	model, err := u.db.QueryUserSettingModel(toUID, loginUID)
@@ -43,6 +45,7 @@ func (u *Setting) userSettingUpdate(c *wkhttp.Context) {
 		model = newDefaultSettingModel()
 		model.UID = loginUID
 		model.ToUID = toUID
+		model.TenantID = tenant.ID(c.Context)
 	}
 	for key, value := range settingMap {
 		switch key {
@@ -64,6 +67,8 @@ func (u *Setting) userSettingUpdate(c *wkhttp.Context) {
 			model.FlameSecond = int(value.(float64))
 		case "remark":
 			model.Remark = value.(string)
+		case "push_privacy":
+			model.PushPrivacy = int(value.(float64))
 		}
 	}
 	version := u.ctx.GenSeq(common.UserSettingSeqKey)
@@ -676,3 +676,73 @@ func TestDeleteUserRedDot(t *testing.T) {
 	s.GetRoute().ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestFollow(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	u := New(ctx)
+	//清除数据
+	err := testutil.CleanAllTables(ctx)
+	assert.NoError(t, err)
+	err = u.db.Insert(&Model{UID: testutil.UID, Name: "10000", Username: "10000", Vercode: "10000@1", QRVercode: "10000@3"})
+	assert.NoError(t, err)
+	err = u.db.Insert(&Model{UID: "111", Name: "111", Username: "111", Vercode: "111@1", QRVercode: "111@3"})
+	assert.NoError(t, err)
+	err = u.db.Insert(&Model{UID: "222", Name: "222", Username: "222", Vercode: "222@1", QRVercode: "222@3"})
+	assert.NoError(t, err)
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("token", token)
+		s.GetRoute().ServeHTTP(w, req)
+		return w
+	}
+
+	// 没有开启单向关注
+	w := request("POST", "/v1/user/follow/111")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "关注功能未开启"))
+
+	_, err = ctx.DB().InsertInto("app_config").Columns("follow_on").Values(1).Exec()
+	assert.NoError(t, err)
+
+	// 不能关注自己
+	w = request("POST", fmt.Sprintf("/v1/user/follow/%s", testutil.UID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// 用户不存在
+	w = request("POST", "/v1/user/follow/333")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// 被拉黑后不能关注
+	_, err = ctx.DB().InsertInto("user_setting").Columns("uid", "to_uid", "blacklist").Values("222", testutil.UID, 1).Exec()
+	assert.NoError(t, err)
+	w = request("POST", "/v1/user/follow/222")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = request("POST", "/v1/user/follow/111")
+	assert.Equal(t, http.StatusOK, w.Code)
+	// 重复关注
+	w = request("POST", "/v1/user/follow/111")
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = u.followDB.insert(&followModel{UID: "111", ToUID: testutil.UID})
+	assert.NoError(t, err)
+
+	w = request("GET", "/v1/user/following")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"count":1`))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"uid":"111"`))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"mutual":1`))
+
+	w = request("GET", "/v1/user/followers")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"uid":"111"`))
+
+	w = request("DELETE", "/v1/user/follow/111")
+	assert.Equal(t, http.StatusOK, w.Code)
+	exist, err := u.followDB.exist(testutil.UID, "111")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	w = request("GET", "/v1/user/followers")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"mutual":0`))
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/tenant"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
@@ -33,7 +35,7 @@ func (u *User) usernameRegister(c *wkhttp.Context) {
 		return
 	}
 	if strings.TrimSpace(req.Password) == "" {
-		c.Response(errors.New("密码不能为空！"))
+		c.Response(errcode.ErrPasswordRequired)
 		return
 	}
 	if len(req.Username) < 8 || len(req.Username) > 22 {
@@ -50,6 +52,19 @@ func (u *User) usernameRegister(c *wkhttp.Context) {
 		c.ResponseError(errors.New("该用户名已存在"))
 		return
 	}
+	control, err := u.checkRegister("")
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if control.closedBeta {
+		u.submitRegisterApply(c, &registerApplyModel{
+			Username: req.Username,
+			Name:     req.Name,
+			Password: req.Password,
+		})
+		return
+	}
 	// 通过用户名注册
 	u.registerWithUsername(req.Username, req.Name, req.Password, int(req.Flag), req.Device, c)
 }
@@ -84,6 +99,10 @@ func (u *User) usernameLogin(c *wkhttp.Context) {
 		return
 	}
 	if userInfo == nil {
+		if err = u.pendingApplyError(req.Username); err != nil {
+			c.ResponseError(err)
+			return
+		}
 		c.ResponseError(errors.New("该用户名不存在"))
 		return
 	}
@@ -93,9 +112,13 @@ func (u *User) usernameLogin(c *wkhttp.Context) {
 		return
 	}
 
+	if err = checkLoginTenant(userInfo, c); err != nil {
+		c.ResponseError(err)
+		return
+	}
 	result, err := u.execLogin(userInfo, config.DeviceFlag(req.Flag), req.Device, loginSpanCtx)
 	if err != nil {
-		c.ResponseError(err)
+		u.responseLoginError(c, err)
 		return
 	}
 	needUploadWeb3PublicKey := 0
@@ -130,6 +153,7 @@ func (u *User) registerWithUsername(username string, name string, password strin
 		Password: password,
 		Flag:     flag,
 		Device:   device,
+		TenantID: tenant.ID(c.Context),
 	}
 	tx, _ := u.db.session.Begin()
 	defer func() {
@@ -151,7 +175,7 @@ func (u *User) registerWithUsername(username string, name string, password strin
 	})
 	if err != nil {
 		tx.Rollback()
-		c.ResponseError(errors.New("注册失败！"))
+		c.ResponseError(registerError(err))
 		return
 	}
 	c.Response(map[string]interface{}{
@@ -0,0 +1,317 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// banAppealTokenPrefix 申诉凭证 被封禁的用户无法登录 登录失败时返回凭证用于提交申诉
+	banAppealTokenPrefix = "banAppealToken:"
+	// banAppealTokenExpire 申诉凭证的有效期
+	banAppealTokenExpire = time.Minute * 30
+	// banReasonMaxLen 封禁原因的最大字数
+	banReasonMaxLen = 500
+	// banAppealMaxLen 申诉内容的最大字数
+	banAppealMaxLen = 800
+	// banLoginStatus 登录时用户已被封禁的状态码
+	banLoginStatus = 111
+)
+
+// bannedError 用户已被封禁 登录时返回封禁原因和到期时间
+type bannedError struct {
+	ban *banModel
+}
+
+func (e *bannedError) Error() string {
+	msg := "该用户已被封禁"
+	if reason := strings.TrimSpace(e.ban.Reason); reason != "" {
+		msg = fmt.Sprintf("%s，原因：%s", msg, reason)
+	}
+	if e.ban.ExpireAt > 0 {
+		msg = fmt.Sprintf("%s，解封时间：%s", msg, time.Unix(e.ban.ExpireAt, 0).Format("2006-01-02 15:04"))
+	}
+	return msg
+}
+
+// banUser 封禁用户 duration为封禁时长（秒） 0为永久封禁 已封禁的用户以本次封禁为准
+func banUser(ctx *config.Context, userDB *DB, lg log.Log, uid string, reason string, duration int64, operator string) error {
+	if duration < 0 {
+		return errors.New("封禁时长有误")
+	}
+	reason = strings.TrimSpace(reason)
+	if len([]rune(reason)) > banReasonMaxLen {
+		return fmt.Errorf("封禁原因不能超过%d个字", banReasonMaxLen)
+	}
+	err := updateUserStatus(ctx, userDB, lg, uid, int(common.UserDisable))
+	if err != nil {
+		return err
+	}
+	err = userDB.liftBans(uid)
+	if err != nil {
+		lg.Error("解除之前的封禁记录失败！", zap.Error(err), zap.String("uid", uid))
+		return errors.New("保存封禁记录失败")
+	}
+	var expireAt int64
+	if duration > 0 {
+		expireAt = time.Now().Unix() + duration
+	}
+	err = userDB.insertBan(&banModel{
+		UID:      uid,
+		Reason:   reason,
+		ExpireAt: expireAt,
+		Operator: operator,
+		Status:   banStatusActive,
+	})
+	if err != nil {
+		lg.Error("保存封禁记录失败！", zap.Error(err), zap.String("uid", uid))
+		return errors.New("保存封禁记录失败")
+	}
+	return nil
+}
+
+// checkBan 登录时检查用户的封禁 封禁到期的自动解禁
+func (u *User) checkBan(userInfo *Model) error {
+	ban, err := u.db.queryActiveBan(userInfo.UID)
+	if err != nil {
+		u.Error("查询封禁记录失败！", zap.Error(err), zap.String("uid", userInfo.UID))
+		return errors.New("该用户已被禁用")
+	}
+	if ban == nil {
+		// 没有封禁记录的为永久封禁
+		return errors.New("该用户已被禁用")
+	}
+	if !ban.expired(time.Now()) {
+		return &bannedError{ban: ban}
+	}
+	if err = updateUserStatus(u.ctx, u.db, u.Log, userInfo.UID, int(common.UserAvailable)); err != nil {
+		return err
+	}
+	userInfo.Status = int(common.UserAvailable)
+	return nil
+}
+
+// bannedResp 登录时用户已被封禁的返回 没有申诉过的返回申诉凭证
+func (u *User) bannedResp(banErr *bannedError) map[string]interface{} {
+	resp := map[string]interface{}{
+		"status":    banLoginStatus,
+		"msg":       banErr.Error(),
+		"reason":    banErr.ban.Reason,
+		"expire_at": banErr.ban.ExpireAt,
+	}
+	if banErr.ban.AppealAt > 0 {
+		return resp
+	}
+	token := util.GenerUUID()
+	err := u.ctx.GetRedisConn().SetAndExpire(fmt.Sprintf("%s%s", banAppealTokenPrefix, token), banErr.ban.UID, banAppealTokenExpire)
+	if err != nil {
+		u.Warn("保存申诉凭证失败！", zap.Error(err), zap.String("uid", banErr.ban.UID))
+		return resp
+	}
+	resp["appeal_token"] = token
+	return resp
+}
+
+// responseLoginError 返回登录失败的原因
+func (u *User) responseLoginError(c *wkhttp.Context, err error) {
+	var banErr *bannedError
+	if errors.As(err, &banErr) {
+		c.ResponseWithStatus(http.StatusBadRequest, u.bannedResp(banErr))
+		return
+	}
+	c.ResponseError(err)
+}
+
+// 被封禁的用户提交申诉 使用登录时返回的申诉凭证 申诉进入举报处理队列
+func (u *User) banAppeal(c *wkhttp.Context) {
+	var req struct {
+		AppealToken string `json:"appeal_token"`
+		Content     string `json:"content"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		c.ResponseError(errors.New("申诉内容不能为空"))
+		return
+	}
+	if len([]rune(content)) > banAppealMaxLen {
+		c.ResponseError(fmt.Errorf("申诉内容不能超过%d个字", banAppealMaxLen))
+		return
+	}
+	if strings.TrimSpace(req.AppealToken) == "" {
+		c.ResponseError(errors.New("申诉凭证不能为空"))
+		return
+	}
+	tokenKey := fmt.Sprintf("%s%s", banAppealTokenPrefix, req.AppealToken)
+	uid, err := u.ctx.GetRedisConn().GetString(tokenKey)
+	if err != nil {
+		u.Error("查询申诉凭证失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询申诉凭证失败"))
+		return
+	}
+	if uid == "" {
+		c.ResponseError(errors.New("申诉凭证已过期，请重新登录后申诉"))
+		return
+	}
+	ban, err := u.db.queryActiveBan(uid)
+	if err != nil {
+		u.Error("查询封禁记录失败！", zap.Error(err), zap.String("uid", uid))
+		c.ResponseError(errors.New("查询封禁记录失败"))
+		return
+	}
+	if ban == nil || ban.expired(time.Now()) {
+		c.ResponseError(errors.New("账号未被封禁"))
+		return
+	}
+	tx, _ := u.db.session.Begin()
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	ok, err := u.db.updateBanAppealTx(ban.Id, time.Now().Unix(), tx)
+	if err != nil {
+		tx.Rollback()
+		u.Error("保存申诉失败！", zap.Error(err), zap.String("uid", uid))
+		c.ResponseError(errors.New("保存申诉失败"))
+		return
+	}
+	if !ok {
+		tx.Rollback()
+		c.ResponseError(errors.New("已提交过申诉，请等待处理"))
+		return
+	}
+	eventID, err := u.ctx.EventBegin(&wkevent.Data{
+		Event: event.EventUserBanAppeal,
+		Type:  wkevent.Message,
+		Data: map[string]interface{}{
+			"uid":     uid,
+			"ban_id":  ban.Id,
+			"reason":  ban.Reason,
+			"content": content,
+		},
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		u.Error("开启事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("保存申诉失败"))
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		u.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("保存申诉失败"))
+		return
+	}
+	u.ctx.EventCommit(eventID)
+	if err = u.ctx.GetRedisConn().Del(tokenKey); err != nil {
+		u.Warn("删除申诉凭证失败！", zap.Error(err))
+	}
+	c.ResponseOK()
+}
+
+// autoUnban 解禁封禁已到期的用户
+func (u *User) autoUnban() {
+	bans, err := u.db.queryExpiredBans(time.Now().Unix(), 100)
+	if err != nil {
+		u.Error("查询到期的封禁记录失败！", zap.Error(err))
+		return
+	}
+	for _, ban := range bans {
+		if err = updateUserStatus(u.ctx, u.db, u.Log, ban.UID, int(common.UserAvailable)); err != nil {
+			u.Warn("自动解禁用户失败！", zap.Error(err), zap.String("uid", ban.UID))
+			continue
+		}
+		u.Info("封禁到期，自动解禁用户", zap.String("uid", ban.UID))
+	}
+}
+
+type managerBanReq struct {
+	UID      string `json:"uid"`
+	Reason   string `json:"reason"`   // 封禁原因 登录时展示给用户
+	Duration int64  `json:"duration"` // 封禁时长（秒） 0为永久封禁
+}
+
+// 封禁用户 可以设置封禁时长和原因
+func (m *Manager) banUser(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserBan); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req managerBanReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if strings.TrimSpace(req.UID) == "" {
+		c.ResponseError(errors.New("操作用户id不能为空"))
+		return
+	}
+	before, err := m.userDB.queryActiveBan(req.UID)
+	if err != nil {
+		m.Error("查询封禁记录失败！", zap.Error(err), zap.String("uid", req.UID))
+		c.ResponseError(errors.New("查询封禁记录失败"))
+		return
+	}
+	err = banUser(m.ctx, m.userDB, m.Log, req.UID, req.Reason, req.Duration, c.GetLoginUID())
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var beforeValue interface{}
+	if before != nil {
+		beforeValue = map[string]interface{}{"reason": before.Reason, "expire_at": before.ExpireAt}
+	}
+	audit.SetChange(c, fmt.Sprintf("uid=%s", req.UID), beforeValue, req)
+	c.ResponseOK()
+}
+
+// 查询用户当前的封禁
+func (m *Manager) banInfo(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	uid := c.Param("uid")
+	ban, err := m.userDB.queryActiveBan(uid)
+	if err != nil {
+		m.Error("查询封禁记录失败！", zap.Error(err), zap.String("uid", uid))
+		c.ResponseError(errors.New("查询封禁记录失败"))
+		return
+	}
+	if ban == nil {
+		c.Response(map[string]interface{}{
+			"uid":    uid,
+			"banned": 0,
+		})
+		return
+	}
+	c.Response(map[string]interface{}{
+		"uid":       uid,
+		"banned":    1,
+		"reason":    ban.Reason,
+		"expire_at": ban.ExpireAt,
+		"operator":  ban.Operator,
+		"appeal_at": ban.AppealAt,
+		"create_at": ban.CreatedAt.String(),
+	})
+}
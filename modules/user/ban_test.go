@@ -0,0 +1,23 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBanExpired(t *testing.T) {
+	now := time.Unix(1791000000, 0)
+	assert.False(t, (&banModel{}).expired(now))
+	assert.False(t, (&banModel{ExpireAt: now.Unix() + 1}).expired(now))
+	assert.True(t, (&banModel{ExpireAt: now.Unix()}).expired(now))
+}
+
+func TestBannedError(t *testing.T) {
+	assert.EqualError(t, &bannedError{ban: &banModel{}}, "该用户已被封禁")
+	assert.EqualError(t, &bannedError{ban: &banModel{Reason: " 发布广告 "}}, "该用户已被封禁，原因：发布广告")
+
+	expireAt := time.Date(2026, 10, 20, 8, 0, 0, 0, time.Local)
+	assert.EqualError(t, &bannedError{ban: &banModel{Reason: "发布广告", ExpireAt: expireAt.Unix()}}, "该用户已被封禁，原因：发布广告，解封时间：2026-10-20 08:00")
+}
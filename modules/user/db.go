@@ -58,6 +58,13 @@ func (d *DB) QueryByPhone(zone string, phone string) (*Model, error) {
 	return model, err
 }
 
+// queryByEmail 通过邮箱查询未注销的用户信息
+func (d *DB) queryByEmail(email string) (*Model, error) {
+	var model *Model
+	_, err := d.session.Select("*").From("user").Where("email=? and is_destroy=0", email).Load(&model)
+	return model, err
+}
+
 // 查询多个手机号用户
 func (d *DB) QueryByPhones(phones []string) ([]*Model, error) {
 	var models []*Model
@@ -136,6 +143,26 @@ func (d *DB) QueryByUIDs(uids []string) ([]*Model, error) {
 	return models, err
 }
 
+// 通过短编号批量查询用户
+func (d *DB) queryByShortNos(shortNos []string) ([]*Model, error) {
+	if len(shortNos) <= 0 {
+		return nil, nil
+	}
+	var models []*Model
+	_, err := d.session.Select("*").From("user").Where("short_no in ?", shortNos).Load(&models)
+	return models, err
+}
+
+// 通过用户名批量查询用户
+func (d *DB) queryByUsernames(usernames []string) ([]*Model, error) {
+	if len(usernames) <= 0 {
+		return nil, nil
+	}
+	var models []*Model
+	_, err := d.session.Select("*").From("user").Where("username in ?", usernames).Load(&models)
+	return models, err
+}
+
 // QueryUserWithOnlyShortNo 通过short_no获取用户信息
 func (d *DB) QueryUserWithOnlyShortNo(shortNo string) (*Model, error) {
 	var model *Model
@@ -346,6 +373,7 @@ type Model struct {
 	GithubUID         string // github uid
 	Web3PublicKey     string // web3公钥
 	MsgExpireSecond   int64  // 消息过期时长
+	TenantID          string // 所属组织 默认组织为空
 	db.BaseModel
 }
 
@@ -0,0 +1,73 @@
+package user
+
+import (
+	"time"
+
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+// 封禁记录的状态
+const (
+	banStatusLifted = 0 // 已解禁
+	banStatusActive = 1 // 封禁中
+)
+
+func (d *DB) insertBan(m *banModel) error {
+	_, err := d.session.InsertInto("user_ban").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+// queryActiveBan 用户当前的封禁记录 没有时返回nil
+func (d *DB) queryActiveBan(uid string) (*banModel, error) {
+	var m *banModel
+	_, err := d.session.Select("*").From("user_ban").Where("uid=? and status=?", uid, banStatusActive).OrderDir("id", false).Limit(1).Load(&m)
+	return m, err
+}
+
+// liftBans 解除用户所有的封禁记录
+func (d *DB) liftBans(uid string) error {
+	_, err := d.session.Update("user_ban").Set("status", banStatusLifted).Set("lifted_at", time.Now().Unix()).Where("uid=? and status=?", uid, banStatusActive).Exec()
+	return err
+}
+
+// queryBansWithUID 用户所有的封禁记录 最新的在前
+func (d *DB) queryBansWithUID(uid string, limit uint64) ([]*banModel, error) {
+	var models []*banModel
+	_, err := d.session.Select("*").From("user_ban").Where("uid=?", uid).OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
+// queryExpiredBans 已到期但还没有解禁的封禁记录
+func (d *DB) queryExpiredBans(now int64, limit uint64) ([]*banModel, error) {
+	var models []*banModel
+	_, err := d.session.Select("*").From("user_ban").Where("status=? and expire_at>0 and expire_at<=?", banStatusActive, now).OrderDir("expire_at", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+// updateBanAppealTx 记录申诉时间 已申诉过的返回false
+func (d *DB) updateBanAppealTx(id int64, appealAt int64, tx *dbr.Tx) (bool, error) {
+	result, err := tx.Update("user_ban").Set("appeal_at", appealAt).Where("id=? and status=? and appeal_at=0", id, banStatusActive).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+type banModel struct {
+	UID      string
+	Reason   string
+	ExpireAt int64 // 封禁到期时间（秒） 0.永久封禁
+	Operator string
+	Status   int
+	AppealAt int64
+	LiftedAt int64
+	dba.BaseModel
+}
+
+// expired 是否已到期
+func (m *banModel) expired(now time.Time) bool {
+	return m.ExpireAt > 0 && m.ExpireAt <= now.Unix()
+}
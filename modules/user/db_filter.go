@@ -0,0 +1,53 @@
+package user
+
+import (
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+)
+
+// UserFilter 按条件筛选用户 用于给一部分用户发送公告等 条件为空的不限制
+type UserFilter struct {
+	RegisterStart string   // 注册日期的开始 例如 2026-10-01
+	RegisterEnd   string   // 注册日期的结束（包含）
+	ActiveDays    int      // 最近几天内在线过
+	ExcludeUIDs   []string // 排除的用户 例如系统账号
+}
+
+// filterWhere 正常的用户（未注销、未封禁、不是机器人和系统账号）中符合条件的
+func (d *DB) filterWhere(builder *dbr.SelectStmt, filter UserFilter, now time.Time) *dbr.SelectStmt {
+	builder = builder.Where("is_destroy=0 and bench_no='' and robot=0 and status=1 and category not in ?", []string{CategorySystem, CategoryCustomerService})
+	if filter.RegisterStart != "" {
+		builder = builder.Where("date_format(created_at,'%Y-%m-%d')>=?", filter.RegisterStart)
+	}
+	if filter.RegisterEnd != "" {
+		builder = builder.Where("date_format(created_at,'%Y-%m-%d')<=?", filter.RegisterEnd)
+	}
+	if filter.ActiveDays > 0 {
+		activeAt := now.AddDate(0, 0, -filter.ActiveDays).Unix()
+		builder = builder.Where("uid in (select uid from user_online where online=1 or last_online>=? or last_offline>=?)", activeAt, activeAt)
+	}
+	if len(filter.ExcludeUIDs) > 0 {
+		builder = builder.Where("uid not in ?", filter.ExcludeUIDs)
+	}
+	return builder
+}
+
+type filterUIDModel struct {
+	Id  int64
+	UID string
+}
+
+// queryUIDsWithFilter 按id顺序查询afterID之后符合条件的用户
+func (d *DB) queryUIDsWithFilter(filter UserFilter, afterID int64, limit uint64) ([]*filterUIDModel, error) {
+	var models []*filterUIDModel
+	_, err := d.filterWhere(d.session.Select("id,uid").From("user"), filter, time.Now()).Where("id>?", afterID).OrderDir("id", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+// queryCountWithFilter 符合条件的用户数
+func (d *DB) queryCountWithFilter(filter UserFilter) (int64, error) {
+	var count int64
+	_, err := d.filterWhere(d.session.Select("count(*)").From("user"), filter, time.Now()).Load(&count)
+	return count, err
+}
@@ -0,0 +1,101 @@
+package user
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type followDB struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newFollowDB(ctx *config.Context) *followDB {
+	return &followDB{
+		session: ctx.DB(),
+		ctx:     ctx,
+	}
+}
+
+func (d *followDB) insert(m *followModel) error {
+	_, err := d.session.InsertInto("user_follow").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *followDB) delete(uid, toUID string) error {
+	_, err := d.session.DeleteFrom("user_follow").Where("uid=? and to_uid=?", uid, toUID).Exec()
+	return err
+}
+
+func (d *followDB) exist(uid, toUID string) (bool, error) {
+	var count int
+	_, err := d.session.Select("count(*)").From("user_follow").Where("uid=? and to_uid=?", uid, toUID).Load(&count)
+	return count > 0, err
+}
+
+// 查询uid关注了toUIDs中的哪些用户
+func (d *followDB) queryWithUIDAndToUIDs(uid string, toUIDs []string) ([]*followModel, error) {
+	var models []*followModel
+	if len(toUIDs) == 0 {
+		return models, nil
+	}
+	_, err := d.session.Select("*").From("user_follow").Where("uid=? and to_uid in ?", uid, toUIDs).Load(&models)
+	return models, err
+}
+
+// 查询uids中关注了toUID的用户
+func (d *followDB) queryWithUIDsAndToUID(uids []string, toUID string) ([]*followModel, error) {
+	var models []*followModel
+	if len(uids) == 0 {
+		return models, nil
+	}
+	_, err := d.session.Select("*").From("user_follow").Where("uid in ? and to_uid=?", uids, toUID).Load(&models)
+	return models, err
+}
+
+// 查询关注toUID的所有用户uid
+func (d *followDB) queryFollowerUIDs(toUID string) ([]string, error) {
+	var uids []string
+	_, err := d.session.Select("uid").From("user_follow").Where("to_uid=?", toUID).Load(&uids)
+	return uids, err
+}
+
+// 分页查询粉丝列表
+func (d *followDB) queryFollowersWithPage(toUID string, pageSize, page uint64) ([]*followDetailModel, error) {
+	var models []*followDetailModel
+	_, err := d.session.Select("user_follow.*,IFNULL(user.name,'') name,IFNULL(user.username,'') username").From("user_follow").LeftJoin("user", "user_follow.uid=user.uid").Where("user_follow.to_uid=?", toUID).OrderDir("user_follow.created_at", false).Offset((page - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+// 分页查询关注列表
+func (d *followDB) queryFollowingWithPage(uid string, pageSize, page uint64) ([]*followDetailModel, error) {
+	var models []*followDetailModel
+	_, err := d.session.Select("user_follow.*,IFNULL(user.name,'') name,IFNULL(user.username,'') username").From("user_follow").LeftJoin("user", "user_follow.to_uid=user.uid").Where("user_follow.uid=?", uid).OrderDir("user_follow.created_at", false).Offset((page - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *followDB) queryFollowerCount(toUID string) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("user_follow").Where("to_uid=?", toUID).Load(&count)
+	return count, err
+}
+
+func (d *followDB) queryFollowingCount(uid string) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("user_follow").Where("uid=?", uid).Load(&count)
+	return count, err
+}
+
+type followModel struct {
+	UID   string // 关注者
+	ToUID string // 被关注者
+	db.BaseModel
+}
+
+type followDetailModel struct {
+	followModel
+	Name     string // 对方名称
+	Username string // 对方用户名
+}
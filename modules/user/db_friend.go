@@ -3,6 +3,7 @@ package user
 import (
 	"fmt"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/replica"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -25,7 +26,11 @@ func newFriendDB(ctx *config.Context) *friendDB {
 
 // InsertTx 插入好友信息
 func (d *friendDB) InsertTx(m *FriendModel, tx *dbr.Tx) error {
+	if m.AddVersion == 0 {
+		m.AddVersion = m.Version
+	}
 	_, err := tx.InsertInto("friend").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	replica.MarkWritten(m.UID)
 	if err != nil {
 		return err
 	}
@@ -36,7 +41,11 @@ func (d *friendDB) InsertTx(m *FriendModel, tx *dbr.Tx) error {
 
 // Insert 插入好友信息
 func (d *friendDB) Insert(m *FriendModel) error {
+	if m.AddVersion == 0 {
+		m.AddVersion = m.Version
+	}
 	_, err := d.session.InsertInto("friend").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	replica.MarkWritten(m.UID)
 	if err != nil {
 		return err
 	}
@@ -61,12 +70,17 @@ func (d *friendDB) IsFriend(uid, toUID string) (bool, error) {
 
 // 修改好友关系
 func (d *friendDB) updateRelationshipTx(uid, toUID string, isDeleted, isAlone int, sourceVercode string, version int64, tx *dbr.Tx) error {
-	_, err := tx.Update("friend").SetMap(map[string]interface{}{
+	setMap := map[string]interface{}{
 		"is_deleted":     isDeleted,
 		"is_alone":       isAlone,
 		"source_vercode": sourceVercode,
 		"version":        version,
-	}).Where("uid=? and to_uid=?", uid, toUID).Exec()
+	}
+	if isDeleted == 0 {
+		setMap["add_version"] = version // 重新成为好友
+	}
+	_, err := tx.Update("friend").SetMap(setMap).Where("uid=? and to_uid=?", uid, toUID).Exec()
+	replica.MarkWritten(uid)
 	if err != nil {
 		return err
 	}
@@ -86,6 +100,7 @@ func (d *friendDB) updateRelationship2Tx(uid, toUID string, isDeleted, isAlone i
 		"is_alone":   isAlone,
 		"version":    version,
 	}).Where("uid=? and to_uid=?", uid, toUID).Exec()
+	replica.MarkWritten(uid)
 	if err != nil {
 		return err
 	}
@@ -102,6 +117,7 @@ func (d *friendDB) updateRelationship2Tx(uid, toUID string, isDeleted, isAlone i
 // 修改好友单项关系
 func (d *friendDB) updateAloneTx(uid, toUID string, isAlone int, tx *dbr.Tx) error {
 	_, err := tx.Update("friend").Set("is_alone", isAlone).Where("uid=? and to_uid=?", uid, toUID).Exec()
+	replica.MarkWritten(uid)
 	return err
 }
 
@@ -201,11 +217,29 @@ func (d *friendDB) SyncFriendsOfDeprecated(version int64, uid string, limit uint
 
 func (d *friendDB) SyncFriends(version int64, uid string, limit uint64) ([]*FriendModel, error) {
 	var models []*FriendModel
-	builder := d.session.Select("*").From("friend").Where("friend.uid=?", uid).OrderDir("friend.version", true)
+	builder := replica.Reader(d.session, uid).Select("*").From("friend").Where("friend.uid=?", uid).OrderDir("friend.version", true)
 	_, err := builder.Where("friend.version > ?", version).Limit(limit).Load(&models)
 	return models, err
 }
 
+// syncFriendDiff 增量同步好友 version为0时（首次同步）不返回已删除的好友
+func (d *friendDB) syncFriendDiff(version int64, uid string, limit uint64) ([]*FriendModel, error) {
+	var models []*FriendModel
+	builder := replica.Reader(d.session, uid).Select("*").From("friend").Where("uid=? and version>?", uid, version)
+	if version <= 0 {
+		builder = builder.Where("is_deleted=0")
+	}
+	_, err := builder.OrderDir("version", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+// queryMaxVersion 查询用户好友数据的最新版本号
+func (d *friendDB) queryMaxVersion(uid string) (int64, error) {
+	var version int64
+	_, err := replica.Reader(d.session, uid).Select("IFNULL(max(version),0)").From("friend").Where("uid=?", uid).Load(&version)
+	return version, err
+}
+
 // QueryFriends 查询用户的所有好友
 func (d *friendDB) QueryFriends(uid string) ([]*DetailModel, error) {
 	var details []*DetailModel
@@ -222,6 +256,7 @@ func (d *friendDB) QueryFriendsWithUIDs(uid string, toUIDs []string) ([]*FriendD
 
 func (d *friendDB) updateVersionTx(version int64, uid string, toUID string, tx *dbr.Tx) error {
 	_, err := tx.Update("friend").Set("version", version).Where("uid=? and to_uid=?", uid, toUID).Exec()
+	replica.MarkWritten(uid)
 	return err
 }
 
@@ -246,6 +281,31 @@ func (d *friendDB) queryApplysWithPage(uid string, pageSize, page uint64) ([]*Fr
 	return list, err
 }
 
+// queryApplysWithCursor 按id倒序查询申请记录 beforeID为0时从最新的开始
+func (d *friendDB) queryApplysWithCursor(uid string, beforeID int64, limit uint64) ([]*FriendApplyModel, error) {
+	var list []*FriendApplyModel
+	builder := d.session.Select("*").From("friend_apply_record").Where("uid=?", uid)
+	if beforeID > 0 {
+		builder = builder.Where("id<?", beforeID)
+	}
+	_, err := builder.OrderDir("id", false).Limit(limit).Load(&list)
+	return list, err
+}
+
+// queryAllWithUID 用户所有的好友关系（包括已删除的）
+func (d *friendDB) queryAllWithUID(uid string, limit uint64) ([]*FriendModel, error) {
+	var models []*FriendModel
+	_, err := d.session.Select("*").From("friend").Where("uid=?", uid).OrderDir("id", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+// queryApplysWithUID 用户发出和收到的好友申请
+func (d *friendDB) queryApplysWithUID(uid string, limit uint64) ([]*FriendApplyModel, error) {
+	var models []*FriendApplyModel
+	_, err := d.session.Select("*").From("friend_apply_record").Where("uid=? or to_uid=?", uid, uid).OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
+
 func (d *friendDB) deleteApplyWithUidAndToUid(uid, toUid string) error {
 	_, err := d.session.DeleteFrom("friend_apply_record").Where("uid=? and to_uid=?", uid, toUid).Exec()
 	return err
@@ -266,10 +326,18 @@ func (d *friendDB) updateApply(apply *FriendApplyModel) error {
 func (d *friendDB) updateApplyTx(apply *FriendApplyModel, tx *dbr.Tx) error {
 	_, err := tx.Update("friend_apply_record").SetMap(map[string]interface{}{
 		"status": apply.Status,
+		"source": apply.Source,
 	}).Where("id=?", apply.Id).Exec()
 	return err
 }
 
+// 修改好友来源类型
+func (d *friendDB) updateSourceTypeTx(uid, toUID string, sourceType string, tx *dbr.Tx) error {
+	_, err := tx.Update("friend").Set("source_type", sourceType).Where("uid=? and to_uid=?", uid, toUID).Exec()
+	replica.MarkWritten(uid)
+	return err
+}
+
 // DetailModel 好友详情
 type DetailModel struct {
 	Remark     string //好友备注
@@ -301,6 +369,8 @@ type FriendModel struct {
 	Vercode       string
 	SourceVercode string //来源验证码
 	Initiator     int    //1:发起方
+	AddVersion    int64  // 好友关系生效时的版本号
+	SourceType    string // 来源类型
 	db.BaseModel
 }
 
@@ -317,6 +387,7 @@ type FriendApplyModel struct {
 	ToUID  string
 	Remark string
 	Token  string
-	Status int // 状态 0.未处理 1.通过 2.拒绝
+	Status int    // 状态 0.未处理 1.通过 2.拒绝
+	Source string // 申请来源类型
 	db.BaseModel
 }
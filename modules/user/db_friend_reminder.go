@@ -0,0 +1,100 @@
+package user
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type friendReminderDB struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newFriendReminderDB(ctx *config.Context) *friendReminderDB {
+	return &friendReminderDB{
+		session: ctx.DB(),
+		ctx:     ctx,
+	}
+}
+
+func (d *friendReminderDB) insert(m *friendReminderModel) error {
+	_, err := d.session.InsertInto("friend_reminder").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *friendReminderDB) update(m *friendReminderModel) error {
+	_, err := d.session.Update("friend_reminder").SetMap(map[string]interface{}{
+		"birthday":           m.Birthday,
+		"birthday_md":        m.BirthdayMd,
+		"birthday_remind":    m.BirthdayRemind,
+		"anniversary":        m.Anniversary,
+		"anniversary_md":     m.AnniversaryMd,
+		"anniversary_remind": m.AnniversaryRemind,
+		"timezone":           m.Timezone,
+	}).Where("id=?", m.Id).Exec()
+	return err
+}
+
+func (d *friendReminderDB) queryWithUIDAndToUID(uid, toUID string) (*friendReminderModel, error) {
+	var m *friendReminderModel
+	_, err := d.session.Select("*").From("friend_reminder").Where("uid=? and to_uid=?", uid, toUID).Load(&m)
+	return m, err
+}
+
+// 查询用户设置的所有好友提醒
+func (d *friendReminderDB) queryWithUID(uid string) ([]*friendReminderModel, error) {
+	var models []*friendReminderModel
+	_, err := d.session.Select("*").From("friend_reminder").Where("uid=?", uid).Load(&models)
+	return models, err
+}
+
+// 查询月日在mds范围内且开启了提醒的生日
+func (d *friendReminderDB) queryBirthdaysWithMds(mds []string) ([]*friendReminderModel, error) {
+	var models []*friendReminderModel
+	_, err := d.session.Select("*").From("friend_reminder").Where("birthday_md in ? and birthday_remind=1", mds).Load(&models)
+	return models, err
+}
+
+// 查询月日在mds范围内且开启了提醒的纪念日
+func (d *friendReminderDB) queryAnniversariesWithMds(mds []string) ([]*friendReminderModel, error) {
+	var models []*friendReminderModel
+	_, err := d.session.Select("*").From("friend_reminder").Where("anniversary_md in ? and anniversary_remind=1", mds).Load(&models)
+	return models, err
+}
+
+// 标记某日已提醒生日 返回是否标记成功（多节点下只有一个节点能标记成功）
+func (d *friendReminderDB) markBirthdayReminded(id int64, date string) (bool, error) {
+	result, err := d.session.Update("friend_reminder").Set("last_birthday_remind", date).Where("id=? and last_birthday_remind<>?", id, date).Exec()
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// 标记某日已提醒纪念日 返回是否标记成功
+func (d *friendReminderDB) markAnniversaryReminded(id int64, date string) (bool, error) {
+	result, err := d.session.Update("friend_reminder").Set("last_anniversary_remind", date).Where("id=? and last_anniversary_remind<>?", id, date).Exec()
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+type friendReminderModel struct {
+	UID                   string
+	ToUID                 string
+	Birthday              string // 生日 YYYY-MM-DD 或 MM-DD
+	BirthdayMd            string // 生日月日 MM-DD
+	BirthdayRemind        int    // 是否开启生日提醒
+	LastBirthdayRemind    string // 最后一次生日提醒日期
+	Anniversary           string // 纪念日 YYYY-MM-DD 或 MM-DD
+	AnniversaryMd         string // 纪念日月日 MM-DD
+	AnniversaryRemind     int    // 是否开启纪念日提醒
+	LastAnniversaryRemind string // 最后一次纪念日提醒日期
+	Timezone              string // 时区
+	db.BaseModel
+}
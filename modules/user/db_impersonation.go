@@ -0,0 +1,59 @@
+package user
+
+import (
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+func (d *DB) insertImpersonation(m *impersonationModel) error {
+	_, err := d.session.InsertInto("user_impersonation").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *DB) queryImpersonationWithSessionNo(sessionNo string) (*impersonationModel, error) {
+	var m *impersonationModel
+	_, err := d.session.Select("*").From("user_impersonation").Where("session_no=?", sessionNo).Load(&m)
+	return m, err
+}
+
+func (d *DB) queryImpersonationsWithPage(uid string, pageIndex, pageSize uint64) ([]*impersonationModel, error) {
+	var models []*impersonationModel
+	_, err := d.impersonationWhere(d.session.Select("*").From("user_impersonation"), uid).OrderDir("id", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (d *DB) queryImpersonationCount(uid string) (int64, error) {
+	var count int64
+	_, err := d.impersonationWhere(d.session.Select("count(*)").From("user_impersonation"), uid).Load(&count)
+	return count, err
+}
+
+func (d *DB) impersonationWhere(builder *dbr.SelectStmt, uid string) *dbr.SelectStmt {
+	if uid != "" {
+		builder = builder.Where("uid=?", uid)
+	}
+	return builder
+}
+
+// revokeImpersonation 提前结束会话 已结束或已过期时返回false
+func (d *DB) revokeImpersonation(id int64, revoker string, now int64) (bool, error) {
+	result, err := d.session.Update("user_impersonation").Set("revoked_at", now).Set("revoker", revoker).Where("id=? and revoked_at=0 and expire_at>?", id, now).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+type impersonationModel struct {
+	SessionNo    string
+	UID          string
+	Operator     string
+	OperatorName string
+	Reason       string
+	ExpireAt     int64
+	RevokedAt    int64
+	Revoker      string
+	dba.BaseModel
+}
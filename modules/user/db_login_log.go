@@ -40,3 +40,10 @@ type LoginLogModel struct {
 	UID     string
 	db.BaseModel
 }
+
+// queryWithUID 用户的登录日志 最新的在前
+func (l *LoginLogDB) queryWithUID(uid string, limit uint64) ([]*LoginLogModel, error) {
+	var models []*LoginLogModel
+	_, err := l.session.Select("*").From("login_log").Where("uid=?", uid).OrderDir("id", false).Limit(limit).Load(&models)
+	return models, err
+}
@@ -93,15 +93,9 @@ func (m *managerDB) queryUserOnline(uid string) ([]*userOnline, error) {
 	return list, err
 }
 
-func (m *managerDB) queryUserWithNameAndRole(username string, role string) (*managerUserModel, error) {
-	var user *managerUserModel
-	_, err := m.session.Select("*").From("user").Where("username=? and role=?", username, role).Load(&user)
-	return user, err
-}
-
-func (m *managerDB) queryUsersWithRole(role string) ([]*managerUserModel, error) {
+func (m *managerDB) queryUsersWithRoles(roles []string) ([]*managerUserModel, error) {
 	var list []*managerUserModel
-	_, err := m.session.Select("*").From("user").Where("role=?", role).Load(&list)
+	_, err := m.session.Select("*").From("user").Where("role in ?", roles).Load(&list)
 	return list, err
 }
 func (m *managerDB) deleteUserWithUIDAndRole(uid, role string) error {
@@ -109,18 +103,33 @@ func (m *managerDB) deleteUserWithUIDAndRole(uid, role string) error {
 	return err
 }
 
+// 按来源统计某个时间区间的好友申请数量及通过数量
+func (m *managerDB) queryFriendApplySourceStats(startTime, endTime string) ([]*friendSourceStatModel, error) {
+	var list []*friendSourceStatModel
+	_, err := m.session.Select("source,count(*) apply_count,ifnull(sum(status=1),0) accept_count").From("friend_apply_record").Where("created_at>=? and created_at<?", startTime, endTime).GroupBy("source").Load(&list)
+	return list, err
+}
+
+type friendSourceStatModel struct {
+	Source      string
+	ApplyCount  int64
+	AcceptCount int64
+}
+
 type managerLoginModel struct {
 	Username string
 	UID      string
 	Name     string
 	Password string
 	Role     string
+	TenantID string
 }
 
 type managerUserModel struct {
 	Username  string
 	Name      string
 	UID       string
+	Role      string
 	Status    int
 	Phone     string
 	ShortNo   string
@@ -147,3 +156,60 @@ type userOnline struct {
 	Version     int64 // 数据版本
 	db.BaseModel
 }
+
+// queryOnlinePlatformStats 按设备类型统计在线的用户数和连接数
+func (m *managerDB) queryOnlinePlatformStats() ([]*onlinePlatformStatModel, error) {
+	var list []*onlinePlatformStatModel
+	_, err := m.session.SelectBySql("select user_online.device_flag,IFNULL(device_flag.remark,'') device_name,count(*) user_count,IFNULL(sum(greatest(user_online.online_count,1)),0) connection_count from user_online left join device_flag on user_online.device_flag=device_flag.device_flag where user_online.online=1 group by user_online.device_flag,device_flag.remark order by user_online.device_flag").Load(&list)
+	return list, err
+}
+
+// queryOnlineSessionsWithPage 分页查询在线的会话 最近上线的在前
+func (m *managerDB) queryOnlineSessionsWithPage(filter onlineSessionFilter, pageIndex, pageSize uint64) ([]*onlineSessionModel, error) {
+	var list []*onlineSessionModel
+	builder := m.session.Select("user_online.uid,user_online.device_flag,user_online.last_online,user_online.online_count,IFNULL(user.name,'') name,IFNULL(user.username,'') username,IFNULL(device_flag.remark,'') device_name").From("user_online").LeftJoin("user", "user_online.uid=user.uid").LeftJoin("device_flag", "user_online.device_flag=device_flag.device_flag")
+	_, err := m.onlineSessionWhere(builder, filter).OrderDir("user_online.last_online", false).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&list)
+	return list, err
+}
+
+// queryOnlineSessionCount 在线的会话数量
+func (m *managerDB) queryOnlineSessionCount(filter onlineSessionFilter) (int64, error) {
+	var count int64
+	builder := m.session.Select("count(*)").From("user_online").LeftJoin("user", "user_online.uid=user.uid")
+	_, err := m.onlineSessionWhere(builder, filter).Load(&count)
+	return count, err
+}
+
+func (m *managerDB) onlineSessionWhere(builder *dbr.SelectStmt, filter onlineSessionFilter) *dbr.SelectStmt {
+	builder = builder.Where("user_online.online=1")
+	if filter.deviceFlag >= 0 {
+		builder = builder.Where("user_online.device_flag=?", filter.deviceFlag)
+	}
+	if filter.keyword != "" {
+		keyword := "%" + filter.keyword + "%"
+		builder = builder.Where("user_online.uid like ? or user.name like ? or user.username like ? or user.phone like ?", keyword, keyword, keyword, keyword)
+	}
+	return builder
+}
+
+type onlineSessionFilter struct {
+	keyword    string
+	deviceFlag int // -1 为所有设备类型
+}
+
+type onlinePlatformStatModel struct {
+	DeviceFlag      uint8
+	DeviceName      string
+	UserCount       int64 // 在线的用户数
+	ConnectionCount int64 // 连接数
+}
+
+type onlineSessionModel struct {
+	UID         string
+	DeviceFlag  uint8
+	DeviceName  string
+	LastOnline  int
+	OnlineCount int
+	Name        string
+	Username    string
+}
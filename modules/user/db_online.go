@@ -26,14 +26,20 @@ func newOnlineDB(ctx *config.Context) *onlineDB {
 func (o *onlineDB) insertOrUpdateUserOnlineTx(m *onlineStatusModel, tx *dbr.Tx) error {
 	var err error
 	if m.Online == 1 {
-		_, err = tx.UpdateBySql("insert into user_online (uid,device_flag,last_online,online,version) values(?,?,?,1,?) ON DUPLICATE KEY UPDATE last_online=VALUES(last_online),online=VALUES(online),updated_at=NOW(),version=VALUES(version)", m.UID, m.DeviceFlag, m.LastOnline, m.Version).Exec()
+		_, err = tx.UpdateBySql("insert into user_online (uid,device_flag,last_online,online,online_count,version) values(?,?,?,1,?,?) ON DUPLICATE KEY UPDATE last_online=VALUES(last_online),online=VALUES(online),online_count=VALUES(online_count),updated_at=NOW(),version=VALUES(version)", m.UID, m.DeviceFlag, m.LastOnline, m.OnlineCount, m.Version).Exec()
 	} else {
-		_, err = tx.UpdateBySql("insert into user_online (uid,device_flag,last_offline,online,version) values(?,?,?,0,?) ON DUPLICATE KEY UPDATE last_offline=VALUES(last_offline),online=VALUES(online),updated_at=NOW(),version=VALUES(version)", m.UID, m.DeviceFlag, m.LastOffline, m.Version).Exec()
+		_, err = tx.UpdateBySql("insert into user_online (uid,device_flag,last_offline,online,online_count,version) values(?,?,?,0,0,?) ON DUPLICATE KEY UPDATE last_offline=VALUES(last_offline),online=VALUES(online),online_count=0,updated_at=NOW(),version=VALUES(version)", m.UID, m.DeviceFlag, m.LastOffline, m.Version).Exec()
 	}
 
 	return err
 }
 
+// updateOnlineCountTx 设备类型下还有其他连接时只更新连接数 不修改在线状态
+func (o *onlineDB) updateOnlineCountTx(uid string, deviceFlag uint8, onlineCount int, tx *dbr.Tx) error {
+	_, err := tx.Update("user_online").Set("online_count", onlineCount).Where("uid=? and device_flag=?", uid, deviceFlag).Exec()
+	return err
+}
+
 // queryUserOnlineRecets 查询最近在线的用户(最近是指一小时内在线的,最多查询到1000条)
 func (o *onlineDB) queryUserOnlineRecets(uids []string) ([]*onlineStatusWeightModel, error) {
 	if len(uids) == 0 {
@@ -148,6 +154,21 @@ func (o *onlineDB) queryOnlineCount() (int64, error) {
 	return count, err
 }
 
+// queryOnlineUIDs 按uid顺序查询在线的用户 afterUID为上一批最后一个uid
+func (o *onlineDB) queryOnlineUIDs(afterUID string, limit uint64) ([]string, error) {
+	var uids []string
+	_, err := o.session.SelectBySql("select distinct uid from user_online where online=1 and uid>? order by uid limit ?", afterUID, limit).Load(&uids)
+	return uids, err
+}
+
+// queryActiveCount 在[start,end)内在线过的用户数 start和end为秒
+// 只保存了最后一次上下线的时间 结束后又重新上线的用户不会被统计
+func (o *onlineDB) queryActiveCount(start int64, end int64) (int64, error) {
+	var count int64
+	_, err := o.session.SelectBySql("select count(distinct uid) as count from user_online where last_online<? and (online=1 or last_offline>=?)", end, start).Load(&count)
+	return count, err
+}
+
 // OnlineStatusModel 在线状态model
 type onlineStatusModel struct {
 	UID         string
@@ -155,6 +176,7 @@ type onlineStatusModel struct {
 	LastOnline  int   // 最后一次在线时间
 	LastOffline int   // 最后一次离线时间
 	Online      int
+	OnlineCount int   // 设备类型下的连接数
 	Version     int64 // 数据版本
 	db.BaseModel
 }
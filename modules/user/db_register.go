@@ -0,0 +1,125 @@
+package user
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+// registerSettingID 注册控制只有一行
+const registerSettingID = 1
+
+type registerDB struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newRegisterDB(ctx *config.Context) *registerDB {
+	return &registerDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// querySetting 注册控制 没有设置时返回nil
+func (r *registerDB) querySetting() (*registerSettingModel, error) {
+	var m *registerSettingModel
+	_, err := r.session.Select("*").From("register_setting").Where("id=?", registerSettingID).Load(&m)
+	return m, err
+}
+
+func (r *registerDB) updateSetting(m *registerSettingModel) error {
+	_, err := r.session.InsertBySql("insert into register_setting (id,phone_allow_prefixes,phone_deny_prefixes,daily_limit,closed_beta,updater) values(?,?,?,?,?,?) ON DUPLICATE KEY UPDATE phone_allow_prefixes=VALUES(phone_allow_prefixes),phone_deny_prefixes=VALUES(phone_deny_prefixes),daily_limit=VALUES(daily_limit),closed_beta=VALUES(closed_beta),updater=VALUES(updater),updated_at=NOW()", registerSettingID, m.PhoneAllowPrefixes, m.PhoneDenyPrefixes, m.DailyLimit, m.ClosedBeta, m.Updater).Exec()
+	return err
+}
+
+func (r *registerDB) insertApply(m *registerApplyModel) error {
+	_, err := r.session.InsertInto("register_apply").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (r *registerDB) queryApplyWithApplyNo(applyNo string) (*registerApplyModel, error) {
+	var m *registerApplyModel
+	_, err := r.session.Select("*").From("register_apply").Where("apply_no=?", applyNo).Load(&m)
+	return m, err
+}
+
+// queryPendingApplyWithUsername 用户名待审核的申请
+func (r *registerDB) queryPendingApplyWithUsername(username string) (*registerApplyModel, error) {
+	var m *registerApplyModel
+	_, err := r.session.Select("*").From("register_apply").Where("username=? and status=?", username, RegisterApplyPending).OrderDir("id", false).Limit(1).Load(&m)
+	return m, err
+}
+
+// queryApplysWithPage status小于0时查询所有状态 先申请的在前
+func (r *registerDB) queryApplysWithPage(status int, pageIndex, pageSize uint64) ([]*registerApplyModel, error) {
+	var models []*registerApplyModel
+	_, err := r.applyWhere(r.session.Select("*").From("register_apply"), status).OrderDir("id", true).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+func (r *registerDB) queryApplyCount(status int) (int64, error) {
+	var count int64
+	_, err := r.applyWhere(r.session.Select("count(*)").From("register_apply"), status).Load(&count)
+	return count, err
+}
+
+func (r *registerDB) applyWhere(builder *dbr.SelectStmt, status int) *dbr.SelectStmt {
+	if status >= 0 {
+		builder = builder.Where("status=?", status)
+	}
+	return builder
+}
+
+// updateApplyReviewed 审核申请 已审核时返回false
+func (r *registerDB) updateApplyReviewed(id int64, status int, reviewer string, rejectReason string, uid string, reviewedAt int64) (bool, error) {
+	result, err := r.session.Update("register_apply").SetMap(map[string]interface{}{
+		"status":        status,
+		"reviewer":      reviewer,
+		"reject_reason": rejectReason,
+		"uid":           uid,
+		"reviewed_at":   reviewedAt,
+	}).Where("id=? and status=?", id, RegisterApplyPending).Exec()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// resetApplyPending 通过申请后创建用户失败时恢复为待审核
+func (r *registerDB) resetApplyPending(id int64) error {
+	_, err := r.session.Update("register_apply").SetMap(map[string]interface{}{
+		"status":      RegisterApplyPending,
+		"reviewer":    "",
+		"uid":         "",
+		"reviewed_at": 0,
+	}).Where("id=? and status=?", id, RegisterApplyApproved).Exec()
+	return err
+}
+
+type registerSettingModel struct {
+	PhoneAllowPrefixes string // 逗号分隔
+	PhoneDenyPrefixes  string // 逗号分隔
+	DailyLimit         int
+	ClosedBeta         int
+	Updater            string
+	dba.BaseModel
+}
+
+type registerApplyModel struct {
+	ApplyNo      string
+	Username     string
+	Zone         string
+	Phone        string
+	Name         string
+	Password     string
+	IP           string
+	Status       int
+	Reviewer     string
+	RejectReason string
+	ReviewedAt   int64
+	UID          string
+	dba.BaseModel
+}
@@ -78,6 +78,7 @@ func (d *SettingDB) updateUserSettingModelWithToUIDTx(setting *SettingModel, uid
 		"flame":         setting.Flame,
 		"flame_second":  setting.FlameSecond,
 		"remark":        setting.Remark,
+		"push_privacy":  setting.PushPrivacy,
 	}).Where("uid=? and to_uid=?", uid, toUID).Exec()
 	return err
 }
@@ -95,6 +96,7 @@ func (d *SettingDB) UpdateUserSettingModel(setting *SettingModel) error {
 		"flame":         setting.Flame,
 		"flame_second":  setting.FlameSecond,
 		"remark":        setting.Remark,
+		"push_privacy":  setting.PushPrivacy,
 	}).Where("id=?", setting.Id).Exec()
 	return err
 }
@@ -122,6 +124,8 @@ type SettingModel struct {
 	FlameSecond  int    // 阅后即焚秒数
 	Version      int64  // 版本
 	Remark       string // 备注
+	PushPrivacy  int    // 推送隐私 0.跟随用户的设置 1.隐藏推送内容 2.显示推送内容
+	TenantID     string // 所属组织 与UID的组织相同
 	db.BaseModel
 }
 
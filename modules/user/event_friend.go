@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -155,10 +156,11 @@ func (f *Friend) handleUserRegister(data []byte, commit config.EventCommit) {
 			IsAlone:       0,
 			Vercode:       fmt.Sprintf("%s@%d", util.GenerUUID(), common.Friend),
 			SourceVercode: inviteVercode,
+			SourceType:    source.SourceTypeInvite,
 		}, tx)
 		if err != nil {
 			util.CheckErr(tx.Rollback())
-			commit(errors.New("添加好友失败！"))
+			commit(errcode.ErrAddFriend)
 			return
 		}
 	} else {
@@ -187,10 +189,11 @@ func (f *Friend) handleUserRegister(data []byte, commit config.EventCommit) {
 			IsAlone:       0,
 			Vercode:       fmt.Sprintf("%s@%d", util.GenerUUID(), common.Friend),
 			SourceVercode: inviteVercode,
+			SourceType:    source.SourceTypeInvite,
 		}, tx)
 		if err != nil {
 			util.CheckErr(tx.Rollback())
-			commit(errors.New("添加好友失败！"))
+			commit(errcode.ErrAddFriend)
 			return
 		}
 	} else {
@@ -203,7 +206,7 @@ func (f *Friend) handleUserRegister(data []byte, commit config.EventCommit) {
 	}
 	if err := tx.Commit(); err != nil {
 		f.Error("提交事务失败！", zap.Error(err))
-		commit(errors.New("提交事务失败！"))
+		commit(errcode.ErrTxCommit)
 		return
 	}
 	// 添加白名单
@@ -254,7 +257,7 @@ func (f *Friend) handleUserRegister(data []byte, commit config.EventCommit) {
 	})
 	if err != nil {
 		f.Error("发送消息失败！", zap.Error(err))
-		commit(errors.New("发送消息失败！"))
+		commit(errcode.ErrSendMessage)
 		return
 	}
 	content := "我们已经是好友了，可以愉快的聊天了！"
@@ -0,0 +1,272 @@
+package user
+
+// ExportData 用户在系统中保存的资料（用于合规导出） 不包含密码、验证码等凭证
+// 每一项最多导出limit条 数量等于limit时可能没有导出完整
+type ExportData struct {
+	Profile      *ExportProfile       `json:"profile"`
+	Devices      []*ExportDevice      `json:"devices"`       // 登录过的设备
+	LoginLogs    []*ExportLoginLog    `json:"login_logs"`    // 登录日志
+	Friends      []*ExportFriend      `json:"friends"`       // 好友关系（包括已删除的）
+	FriendApplys []*ExportFriendApply `json:"friend_applys"` // 发出和收到的好友申请
+	Blacklist    []*ExportContact     `json:"blacklist"`     // 黑名单
+	Maillist     []*ExportMaillist    `json:"maillist"`      // 上传的通讯录
+	Following    []*ExportContact     `json:"following"`     // 关注的用户
+	Followers    []*ExportContact     `json:"followers"`     // 粉丝
+	Bans         []*ExportBan         `json:"bans"`          // 封禁记录
+}
+
+// ExportProfile 用户资料
+type ExportProfile struct {
+	UID           string `json:"uid"`
+	Name          string `json:"name"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	Zone          string `json:"zone"`
+	Phone         string `json:"phone"`
+	ShortNo       string `json:"short_no"`
+	Sex           int    `json:"sex"`
+	Category      string `json:"category"`
+	Role          string `json:"role"`
+	Robot         int    `json:"robot"`
+	Status        int    `json:"status"`     // 0.禁用 1.启用
+	IsDestroy     int    `json:"is_destroy"` // 是否已注销
+	WXOpenid      string `json:"wx_openid"`
+	WXUnionid     string `json:"wx_unionid"`
+	GiteeUID      string `json:"gitee_uid"`
+	GithubUID     string `json:"github_uid"`
+	Web3PublicKey string `json:"web3_public_key"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+// ExportDevice 登录过的设备
+type ExportDevice struct {
+	DeviceID    string `json:"device_id"`
+	DeviceName  string `json:"device_name"`
+	DeviceModel string `json:"device_model"`
+	LastLogin   int64  `json:"last_login"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ExportLoginLog 登录日志
+type ExportLoginLog struct {
+	LoginIP   string `json:"login_ip"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ExportFriend 好友关系
+type ExportFriend struct {
+	ToUID      string `json:"to_uid"`
+	IsDeleted  int    `json:"is_deleted"`
+	IsAlone    int    `json:"is_alone"`  // 是否为单项好友
+	Initiator  int    `json:"initiator"` // 1.发起方
+	SourceType string `json:"source_type"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+// ExportFriendApply 好友申请
+type ExportFriendApply struct {
+	UID       string `json:"uid"`    // 收到申请的用户
+	ToUID     string `json:"to_uid"` // 发起申请的用户
+	Remark    string `json:"remark"`
+	Status    int    `json:"status"` // 0.未处理 1.通过 2.拒绝
+	Source    string `json:"source"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ExportContact 黑名单、关注和粉丝
+type ExportContact struct {
+	UID       string `json:"uid"`
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// ExportMaillist 上传的通讯录
+type ExportMaillist struct {
+	Zone      string `json:"zone"`
+	Phone     string `json:"phone"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ExportBan 封禁记录
+type ExportBan struct {
+	Reason    string `json:"reason"`
+	ExpireAt  int64  `json:"expire_at"` // 0.永久封禁
+	Operator  string `json:"operator"`
+	Status    int    `json:"status"` // 0.已解禁 1.封禁中
+	AppealAt  int64  `json:"appeal_at"`
+	LiftedAt  int64  `json:"lifted_at"`
+	CreatedAt string `json:"created_at"`
+}
+
+// GetExportData 导出用户保存的资料 用户不存在时返回nil
+func (s *Service) GetExportData(uid string, limit int) (*ExportData, error) {
+	user, err := s.db.QueryByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+	max := uint64(limit)
+	data := &ExportData{
+		Profile: &ExportProfile{
+			UID:           user.UID,
+			Name:          user.Name,
+			Username:      user.Username,
+			Email:         user.Email,
+			Zone:          user.Zone,
+			Phone:         user.Phone,
+			ShortNo:       user.ShortNo,
+			Sex:           user.Sex,
+			Category:      user.Category,
+			Role:          user.Role,
+			Robot:         user.Robot,
+			Status:        user.Status,
+			IsDestroy:     user.IsDestroy,
+			WXOpenid:      user.WXOpenid,
+			WXUnionid:     user.WXUnionid,
+			GiteeUID:      user.GiteeUID,
+			GithubUID:     user.GithubUID,
+			Web3PublicKey: user.Web3PublicKey,
+			CreatedAt:     user.CreatedAt.String(),
+			UpdatedAt:     user.UpdatedAt.String(),
+		},
+		Devices:      make([]*ExportDevice, 0),
+		LoginLogs:    make([]*ExportLoginLog, 0),
+		Friends:      make([]*ExportFriend, 0),
+		FriendApplys: make([]*ExportFriendApply, 0),
+		Blacklist:    make([]*ExportContact, 0),
+		Maillist:     make([]*ExportMaillist, 0),
+		Following:    make([]*ExportContact, 0),
+		Followers:    make([]*ExportContact, 0),
+		Bans:         make([]*ExportBan, 0),
+	}
+
+	devices, err := newDeviceDB(s.ctx).queryDeviceWithUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range devices {
+		data.Devices = append(data.Devices, &ExportDevice{
+			DeviceID:    m.DeviceID,
+			DeviceName:  m.DeviceName,
+			DeviceModel: m.DeviceModel,
+			LastLogin:   m.LastLogin,
+			CreatedAt:   m.CreatedAt.String(),
+		})
+	}
+
+	loginLogs, err := NewLoginLogDB(s.ctx.DB()).queryWithUID(uid, max)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range loginLogs {
+		data.LoginLogs = append(data.LoginLogs, &ExportLoginLog{
+			LoginIP:   m.LoginIP,
+			CreatedAt: m.CreatedAt.String(),
+		})
+	}
+
+	friends, err := s.friendDB.queryAllWithUID(uid, max)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range friends {
+		data.Friends = append(data.Friends, &ExportFriend{
+			ToUID:      m.ToUID,
+			IsDeleted:  m.IsDeleted,
+			IsAlone:    m.IsAlone,
+			Initiator:  m.Initiator,
+			SourceType: m.SourceType,
+			CreatedAt:  m.CreatedAt.String(),
+			UpdatedAt:  m.UpdatedAt.String(),
+		})
+	}
+
+	applys, err := s.friendDB.queryApplysWithUID(uid, max)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range applys {
+		data.FriendApplys = append(data.FriendApplys, &ExportFriendApply{
+			UID:       m.UID,
+			ToUID:     m.ToUID,
+			Remark:    m.Remark,
+			Status:    m.Status,
+			Source:    m.Source,
+			CreatedAt: m.CreatedAt.String(),
+		})
+	}
+
+	blacklists, err := s.db.Blacklists(uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range blacklists {
+		data.Blacklist = append(data.Blacklist, &ExportContact{
+			UID:      m.UID,
+			Name:     m.Name,
+			Username: m.Username,
+		})
+	}
+
+	maillists, err := newMaillistDB(s.ctx).query(uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range maillists {
+		data.Maillist = append(data.Maillist, &ExportMaillist{
+			Zone:      m.Zone,
+			Phone:     m.Phone,
+			Name:      m.Name,
+			CreatedAt: m.CreatedAt.String(),
+		})
+	}
+
+	following, err := s.followDB.queryFollowingWithPage(uid, max, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range following {
+		data.Following = append(data.Following, &ExportContact{
+			UID:       m.ToUID,
+			Name:      m.Name,
+			Username:  m.Username,
+			CreatedAt: m.CreatedAt.String(),
+		})
+	}
+
+	followers, err := s.followDB.queryFollowersWithPage(uid, max, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range followers {
+		data.Followers = append(data.Followers, &ExportContact{
+			UID:       m.UID,
+			Name:      m.Name,
+			Username:  m.Username,
+			CreatedAt: m.CreatedAt.String(),
+		})
+	}
+
+	bans, err := s.db.queryBansWithUID(uid, max)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range bans {
+		data.Bans = append(data.Bans, &ExportBan{
+			Reason:    m.Reason,
+			ExpireAt:  m.ExpireAt,
+			Operator:  m.Operator,
+			Status:    m.Status,
+			AppealAt:  m.AppealAt,
+			LiftedAt:  m.LiftedAt,
+			CreatedAt: m.CreatedAt.String(),
+		})
+	}
+	return data, nil
+}
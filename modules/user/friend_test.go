@@ -6,8 +6,10 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
 	"github.com/stretchr/testify/assert"
@@ -381,3 +383,127 @@ func TestDeleteApply(t *testing.T) {
 	s.GetRoute().ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestReminderDueDate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 16, 30, 0, 0, time.UTC) // 上海时间 3月2日 00:30
+
+	_, ok := reminderDueDate("03-02", "Asia/Shanghai", now)
+	assert.False(t, ok) // 未到提醒时间
+
+	date, ok := reminderDueDate("03-01", "America/New_York", now) // 纽约时间 3月1日 11:30
+	assert.True(t, ok)
+	assert.Equal(t, "2024-03-01", date)
+
+	date, ok = reminderDueDate("03-02", "Asia/Shanghai", now.Add(time.Hour*9))
+	assert.True(t, ok)
+	assert.Equal(t, "2024-03-02", date)
+
+	assert.Equal(t, "03-02", reminderMonthDay("1990-03-02"))
+	assert.Equal(t, "03-02", reminderMonthDay("03-02"))
+	assert.Error(t, checkReminderDate("1990-13-02"))
+}
+
+func TestParseImportContacts(t *testing.T) {
+	assert.Equal(t, "008613800138000", normalizeContactPhone("+86 138-0013-8000", "0086"))
+	assert.Equal(t, "008613800138000", normalizeContactPhone("13800138000", "0086"))
+	assert.Equal(t, "0014155550100", normalizeContactPhone("0014155550100", "0086"))
+	assert.Equal(t, "", normalizeContactPhone("无", "0086"))
+
+	contacts, err := parseImportContacts("telegram", `{"contacts":{"list":[{"first_name":"Tom","last_name":"Lee","phone_number":"+1 415 555 0100"}]}}`, "0086")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(contacts))
+	assert.Equal(t, "Tom Lee", contacts[0].Remark)
+	assert.Equal(t, "0014155550100", contacts[0].Phone)
+
+	contacts, err = parseImportContacts("wechat", "\ufeff昵称,备注,手机号\n小明,,13800138000\n小红,红红,+8613900139000\n", "0086")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(contacts))
+	assert.Equal(t, "小明", contacts[0].Remark)
+	assert.Equal(t, "红红", contacts[1].Remark)
+	assert.Equal(t, "008613900139000", contacts[1].Phone)
+
+	contacts, err = parseImportContacts("tsdd", util.ToJson(&contactsFile{
+		Format:   contactsFormatTSDD,
+		Version:  contactsFileVersion,
+		Contacts: []*contactsFileItem{{UID: "u1", Remark: "老王", Birthday: "1990-13-01"}},
+	}), "0086")
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", contacts[0].UID)
+	assert.Equal(t, "", contacts[0].Birthday)
+
+	_, err = parseImportContacts("tsdd", `{"contacts":[]}`, "0086")
+	assert.Error(t, err)
+}
+
+func TestContactsMatchScope(t *testing.T) {
+	scope := &contactsMatchScope{loginUID: "u1", tenantID: "acme"}
+	assert.False(t, scope.allowed(nil))
+	assert.False(t, scope.allowed(&Model{UID: "u1"}))
+	assert.False(t, scope.allowed(&Model{UID: "u2", IsDestroy: 1}))
+	assert.False(t, scope.allowed(&Model{UID: "u2", Robot: 1}))
+	// 没有开启多组织时不检查组织
+	assert.True(t, scope.allowed(&Model{UID: "u2", TenantID: "globex"}))
+
+	cfg := &extconfig.Get().Tenant
+	cfg.Enable = true
+	defer func() { cfg.Enable = false }()
+	assert.True(t, scope.allowed(&Model{UID: "u2", TenantID: "acme"}))
+	assert.False(t, scope.allowed(&Model{UID: "u3", TenantID: "globex"}))
+	assert.False(t, scope.allowed(&Model{UID: "u4"}))
+}
+
+func TestTenantIsolation(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	u := New(ctx)
+	u.Route(s.GetRoute())
+	f := NewFriend(ctx)
+	f.Route(s.GetRoute())
+	//清除数据
+	err := testutil.CleanAllTables(ctx)
+	assert.NoError(t, err)
+	cfg := &extconfig.Get().Tenant
+	cfg.Enable = true
+	defer func() { cfg.Enable = false }()
+
+	_, err = ctx.DB().InsertInto("app_config").Columns("follow_on").Values(1).Exec()
+	assert.NoError(t, err)
+	err = u.db.Insert(&Model{UID: testutil.UID, Name: "10000", Username: "10000", Vercode: "10000@1", QRVercode: "10000@3"})
+	assert.NoError(t, err)
+	// 其他组织的用户
+	err = u.db.Insert(&Model{UID: "111", Name: "111", Username: "111", Vercode: "111@1", QRVercode: "111@3", TenantID: "globex"})
+	assert.NoError(t, err)
+
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		reader := bytes.NewReader(nil)
+		if body != nil {
+			reader = bytes.NewReader([]byte(util.ToJson(body)))
+		}
+		req, _ := http.NewRequest(method, path, reader)
+		req.Header.Set("token", testutil.Token)
+		s.GetRoute().ServeHTTP(w, req)
+		return w
+	}
+
+	// 查询用户信息
+	w := request("GET", "/v1/users/111", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, strings.Contains(w.Body.String(), `"uid":"111"`))
+
+	// 关注
+	w = request("POST", "/v1/user/follow/111", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	exist, err := u.followDB.exist(testutil.UID, "111")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	// 导入联系人
+	w = request("POST", "/v1/friend/import", map[string]interface{}{
+		"format":  contactsImportFormatTSDD,
+		"data":    util.ToJson(&contactsFile{Format: contactsFormatTSDD, Version: contactsFileVersion, Contacts: []*contactsFileItem{{UID: "111", Username: "111"}}}),
+		"dry_run": 1,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), `"not_found":1`))
+	assert.False(t, strings.Contains(w.Body.String(), `"uid":"111"`))
+}
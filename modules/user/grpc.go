@@ -0,0 +1,113 @@
+package user
+
+import (
+	"context"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rpcapi"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userRPC 内部gRPC接口的用户服务
+type userRPC struct {
+	rpcapi.UnimplementedUserServiceServer
+	u *User
+}
+
+func newUserRPC(u *User) *userRPC {
+	return &userRPC{u: u}
+}
+
+// GetUsers 批量查询用户
+func (r *userRPC) GetUsers(ctx context.Context, req *rpcapi.GetUsersReq) (*rpcapi.GetUsersResp, error) {
+	users, err := r.u.userService.GetUsers(req.Uids)
+	if err != nil {
+		r.u.Error("查询用户失败！", zap.Error(err))
+		return nil, status.Error(codes.Internal, "查询用户失败！")
+	}
+	resp := &rpcapi.GetUsersResp{
+		Users: make([]*rpcapi.User, 0, len(users)),
+	}
+	for _, user := range users {
+		resp.Users = append(resp.Users, toRPCUser(user))
+	}
+	return resp, nil
+}
+
+// GetUserWithUsername 通过用户名查询用户
+func (r *userRPC) GetUserWithUsername(ctx context.Context, req *rpcapi.GetUserWithUsernameReq) (*rpcapi.GetUserResp, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "用户名不能为空！")
+	}
+	user, err := r.u.userService.GetUserWithUsername(req.Username)
+	if err != nil {
+		r.u.Error("通过用户名查询用户失败！", zap.Error(err), zap.String("username", req.Username))
+		return nil, status.Error(codes.Internal, "查询用户失败！")
+	}
+	resp := &rpcapi.GetUserResp{}
+	if user != nil {
+		resp.User = toRPCUser(user)
+	}
+	return resp, nil
+}
+
+// IsFriend 查询两个用户是否为好友
+func (r *userRPC) IsFriend(ctx context.Context, req *rpcapi.IsFriendReq) (*rpcapi.IsFriendResp, error) {
+	if req.Uid == "" || req.ToUid == "" {
+		return nil, status.Error(codes.InvalidArgument, "uid和to_uid不能为空！")
+	}
+	isFriend, err := r.u.userService.IsFriend(req.Uid, req.ToUid)
+	if err != nil {
+		r.u.Error("查询好友关系失败！", zap.Error(err), zap.String("uid", req.Uid), zap.String("toUID", req.ToUid))
+		return nil, status.Error(codes.Internal, "查询好友关系失败！")
+	}
+	return &rpcapi.IsFriendResp{IsFriend: isFriend}, nil
+}
+
+// presenceRPC 内部gRPC接口的在线状态服务
+type presenceRPC struct {
+	rpcapi.UnimplementedPresenceServiceServer
+	u *User
+}
+
+func newPresenceRPC(u *User) *presenceRPC {
+	return &presenceRPC{u: u}
+}
+
+// GetOnlineStatus 批量查询用户的在线状态
+func (r *presenceRPC) GetOnlineStatus(ctx context.Context, req *rpcapi.GetOnlineStatusReq) (*rpcapi.GetOnlineStatusResp, error) {
+	if len(req.Uids) == 0 {
+		return &rpcapi.GetOnlineStatusResp{}, nil
+	}
+	onlines, err := r.u.userService.GetUserOnlineStatus(req.Uids)
+	if err != nil {
+		r.u.Error("查询用户在线状态失败！", zap.Error(err))
+		return nil, status.Error(codes.Internal, "查询用户在线状态失败！")
+	}
+	resp := &rpcapi.GetOnlineStatusResp{
+		Statuses: make([]*rpcapi.OnlineStatus, 0, len(onlines)),
+	}
+	for _, online := range onlines {
+		resp.Statuses = append(resp.Statuses, &rpcapi.OnlineStatus{
+			Uid:         online.UID,
+			Online:      online.Online == 1,
+			LastOffline: int64(online.LastOffline),
+			DeviceFlag:  uint32(online.DeviceFlag),
+		})
+	}
+	return resp, nil
+}
+
+func toRPCUser(user *Resp) *rpcapi.User {
+	return &rpcapi.User{
+		Uid:       user.UID,
+		Name:      user.Name,
+		Zone:      user.Zone,
+		Phone:     user.Phone,
+		Email:     user.Email,
+		Status:    int32(user.Status),
+		IsDestroy: user.IsDestroy == 1,
+		CreatedAt: user.CreatedAt,
+	}
+}
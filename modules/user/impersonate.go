@@ -0,0 +1,375 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/audit"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// impersonationTokenFlag 模拟登录的token前缀 用于识别只读的会话
+	impersonationTokenFlag = "imp_"
+	// impersonationSessionPrefix 会话编号对应的token 用于提前结束会话
+	impersonationSessionPrefix = "impersonationSession:"
+	// impersonationDefaultTTL 默认的会话时长
+	impersonationDefaultTTL = time.Minute * 30
+	// impersonationMaxTTL 最长的会话时长
+	impersonationMaxTTL = time.Hour * 2
+	// impersonationReasonMaxLen 原因的最大字数
+	impersonationReasonMaxLen = 500
+)
+
+// impersonationReadPaths 只读的会话可以调用的POST接口（只查询数据的同步接口）
+var impersonationReadPaths = map[string]bool{
+	"/v1/conversation/sync":       true,
+	"/v1/conversation/extra/sync": true,
+	"/v1/message/channel/sync":    true,
+	"/v1/message/extra/sync":      true,
+	"/v1/message/reminder/sync":   true,
+	"/v1/message/pinned/sync":     true,
+	"/v1/reaction/sync":           true,
+	"/v1/robot/sync":              true,
+}
+
+// impersonationGetPaths 只读的会话可以调用的GET接口 只开放已确认只查询数据的接口
+// 不包括返回上传凭证、授权码、webhook地址等可以用来修改数据的凭证的接口和会修改数据的GET接口（如扫码加群、分享下载计数）
+var impersonationGetPaths = map[string]bool{
+	"/v1/users/:uid":                           true,
+	"/v1/users/:uid/avatar":                    true,
+	"/v1/user/search":                          true,
+	"/v1/user/qrcode":                          true,
+	"/v1/user/blacklists":                      true,
+	"/v1/user/customerservices":                true,
+	"/v1/user/devices":                         true,
+	"/v1/user/online":                          true,
+	"/v1/user/maillist":                        true,
+	"/v1/user/followers":                       true,
+	"/v1/user/following":                       true,
+	"/v1/user/reddot/:category":                true,
+	"/v1/friend/apply":                         true,
+	"/v1/friend/sync":                          true,
+	"/v1/friend/sync/diff":                     true,
+	"/v1/friend/search":                        true,
+	"/v1/friend/reminder/:uid":                 true,
+	"/v1/friend/export":                        true,
+	"/v1/group/my":                             true,
+	"/v1/group/forbidden_times":                true,
+	"/v1/groups/:group_no":                     true,
+	"/v1/groups/:group_no/members":             true,
+	"/v1/groups/:group_no/membersync":          true,
+	"/v1/groups/:group_no/avatar":              true,
+	"/v1/groups/:group_no/detail":              true,
+	"/v1/groups/:group_no/bot_permissions":     true,
+	"/v1/coversations":                         true,
+	"/v1/message/sync/sensitivewords":          true,
+	"/v1/message/prohibit_words/sync":          true,
+	"/v1/messages/:message_id/receipt":         true,
+	"/v1/channel/state":                        true,
+	"/v1/channels/:channel_id/:channel_type":   true,
+	"/v1/file/preview/*path":                   true,
+	"/v1/file/quota":                           true,
+	"/v1/file/info":                            true,
+	"/v1/file/transcode":                       true,
+	"/v1/file/sign":                            true,
+	"/v1/file/upload/check":                    true,
+	"/v1/file/shares":                          true,
+	"/v1/workplace/banner":                     true,
+	"/v1/workplace/app/record":                 true,
+	"/v1/workplace/app":                        true,
+	"/v1/workplace/category":                   true,
+	"/v1/workplace/categorys/:category_no/app": true,
+	"/v1/robot/directory":                      true,
+	"/v1/robot/directory/:robot_id":            true,
+	"/v1/common/features":                      true,
+	"/v1/common/notices":                       true,
+	"/v1/common/appversion/:os/:version":       true,
+	"/v1/common/appversion/list":               true,
+	"/v1/common/chatbg":                        true,
+	"/v1/common/appmodule":                     true,
+	"/v1/common/countries":                     true,
+	"/v1/common/appconfig":                     true,
+	"/v1/common/tenant":                        true,
+	"/v1/webpush/vapid_public_key":             true,
+}
+
+// impersonationAllowed 只读的会话是否可以调用接口 path为路由的路径
+func impersonationAllowed(method string, path string) bool {
+	switch method {
+	case http.MethodOptions:
+		return true
+	case http.MethodGet, http.MethodHead:
+		return impersonationGetPaths[path]
+	case http.MethodPost:
+		return impersonationReadPaths[path]
+	}
+	return false
+}
+
+// ImpersonationMiddleware 模拟登录的会话只读 拦截修改数据的请求 需要在模块安装（注册路由）之前添加
+func ImpersonationMiddleware() gin.HandlerFunc {
+	lg := log.NewTLog("Impersonation")
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.GetHeader("token"), impersonationTokenFlag) {
+			c.Next()
+			return
+		}
+		if !impersonationAllowed(c.Request.Method, c.FullPath()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"msg":    "模拟登录为只读，不能执行此操作",
+				"status": http.StatusForbidden,
+			})
+			return
+		}
+		c.Next()
+		lg.Info("模拟登录的请求", zap.String("uid", c.GetString("uid")), zap.String("method", c.Request.Method), zap.String("path", c.Request.URL.Path), zap.Int("status", c.Writer.Status()))
+	}
+}
+
+// impersonationTTL 会话时长 minutes为0时使用默认时长
+func impersonationTTL(minutes int) (time.Duration, error) {
+	if minutes < 0 {
+		return 0, errors.New("会话时长有误")
+	}
+	if minutes == 0 {
+		return impersonationDefaultTTL, nil
+	}
+	ttl := time.Duration(minutes) * time.Minute
+	if ttl > impersonationMaxTTL {
+		return 0, fmt.Errorf("会话时长不能超过%d分钟", int(impersonationMaxTTL/time.Minute))
+	}
+	return ttl, nil
+}
+
+// impersonationNotice 通知用户的内容
+func impersonationNotice(operatorName string, reason string, expireAt time.Time) string {
+	return fmt.Sprintf("为排查你反馈的问题，客服[%s]将以只读方式查看你的账号数据，不会进行任何修改，查看权限将于%s失效。原因：%s", operatorName, expireAt.Format("2006-01-02 15:04"), reason)
+}
+
+type impersonateReq struct {
+	UID     string `json:"uid"`
+	Reason  string `json:"reason"`  // 原因 会通知给用户
+	Minutes int    `json:"minutes"` // 会话时长（分钟） 为0时默认30分钟
+}
+
+// 以只读方式模拟登录用户 返回的token只能调用查询类的接口 会通知用户并记录到操作日志
+func (m *Manager) impersonate(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserImpersonate); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req impersonateReq
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		c.ResponseError(errors.New("原因不能为空"))
+		return
+	}
+	if len([]rune(reason)) > impersonationReasonMaxLen {
+		c.ResponseError(fmt.Errorf("原因不能超过%d个字", impersonationReasonMaxLen))
+		return
+	}
+	ttl, err := impersonationTTL(req.Minutes)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	user, err := m.userDB.QueryByUID(req.UID)
+	if err != nil {
+		m.Error("查询用户失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户失败！"))
+		return
+	}
+	if user == nil {
+		c.ResponseError(errors.New("用户不存在"))
+		return
+	}
+	account := m.ctx.GetConfig().Account
+	if rbac.IsManagerRole(user.Role) || user.Robot == 1 || user.UID == account.SystemUID || user.UID == account.FileHelperUID {
+		c.ResponseError(errors.New("不能模拟登录管理后台账号、系统账号或机器人"))
+		return
+	}
+	if user.IsDestroy == 1 {
+		c.ResponseError(errors.New("用户已注销"))
+		return
+	}
+
+	expireAt := time.Now().Add(ttl)
+	session := &impersonationModel{
+		SessionNo:    util.GenerUUID(),
+		UID:          user.UID,
+		Operator:     c.GetLoginUID(),
+		OperatorName: c.GetLoginName(),
+		Reason:       reason,
+		ExpireAt:     expireAt.Unix(),
+	}
+	if err = m.userDB.insertImpersonation(session); err != nil {
+		m.Error("添加模拟登录记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加模拟登录记录失败！"))
+		return
+	}
+	token := impersonationTokenFlag + util.GenerUUID()
+	// 不带角色 模拟登录的会话不能访问管理后台
+	err = m.ctx.Cache().SetAndExpire(m.ctx.GetConfig().Cache.TokenCachePrefix+token, fmt.Sprintf("%s@%s", user.UID, user.Name), ttl)
+	if err != nil {
+		m.Error("设置token缓存失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置token缓存失败！"))
+		return
+	}
+	err = m.ctx.Cache().SetAndExpire(impersonationSessionPrefix+session.SessionNo, token, ttl)
+	if err != nil {
+		m.Error("设置模拟登录会话缓存失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置模拟登录会话缓存失败！"))
+		return
+	}
+	err = m.ctx.SendMessage(&config.MsgSendReq{
+		FromUID:     account.SystemUID,
+		ChannelID:   user.UID,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": impersonationNotice(c.GetLoginName(), reason, expireAt),
+			"type":    common.Text,
+		})),
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+	})
+	if err != nil {
+		m.Warn("发送模拟登录通知失败！", zap.Error(err), zap.String("uid", user.UID))
+	}
+	m.Info("模拟登录用户", zap.String("sessionNo", session.SessionNo), zap.String("uid", user.UID), zap.String("operator", session.Operator), zap.Duration("ttl", ttl))
+	audit.SetChange(c, fmt.Sprintf("uid=%s", user.UID), nil, map[string]interface{}{
+		"session_no": session.SessionNo,
+		"reason":     reason,
+		"expire_at":  session.ExpireAt,
+	})
+	c.Response(map[string]interface{}{
+		"session_no": session.SessionNo,
+		"uid":        user.UID,
+		"token":      token,
+		"expire_at":  session.ExpireAt,
+	})
+}
+
+// 模拟登录记录
+func (m *Manager) impersonations(c *wkhttp.Context) {
+	role := c.GetLoginRole()
+	if !rbac.HasPermission(role, rbac.PermUserImpersonate) && !rbac.HasPermission(role, rbac.PermAuditRead) {
+		c.ResponseError(errcode.ErrPermissionDenied)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	uid := c.Query("uid")
+	models, err := m.userDB.queryImpersonationsWithPage(uid, uint64(pageIndex), uint64(pageSize))
+	if err != nil {
+		m.Error("查询模拟登录记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询模拟登录记录失败！"))
+		return
+	}
+	count, err := m.userDB.queryImpersonationCount(uid)
+	if err != nil {
+		m.Error("查询模拟登录记录数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询模拟登录记录数量失败！"))
+		return
+	}
+	now := time.Now().Unix()
+	list := make([]*impersonationResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newImpersonationResp(model, now))
+	}
+	c.Response(map[string]interface{}{
+		"list":  list,
+		"count": count,
+	})
+}
+
+// 提前结束模拟登录的会话
+func (m *Manager) revokeImpersonation(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermUserImpersonate); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	sessionNo := c.Param("session_no")
+	session, err := m.userDB.queryImpersonationWithSessionNo(sessionNo)
+	if err != nil {
+		m.Error("查询模拟登录记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询模拟登录记录失败！"))
+		return
+	}
+	if session == nil {
+		c.ResponseError(errors.New("模拟登录记录不存在"))
+		return
+	}
+	token, err := m.ctx.Cache().Get(impersonationSessionPrefix + sessionNo)
+	if err != nil {
+		m.Error("查询模拟登录会话缓存失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询模拟登录会话缓存失败！"))
+		return
+	}
+	if token != "" {
+		if err = m.ctx.Cache().Delete(m.ctx.GetConfig().Cache.TokenCachePrefix + token); err != nil {
+			m.Error("删除token缓存失败！", zap.Error(err))
+			c.ResponseError(errors.New("删除token缓存失败！"))
+			return
+		}
+		_ = m.ctx.Cache().Delete(impersonationSessionPrefix + sessionNo)
+	}
+	ok, err := m.userDB.revokeImpersonation(session.Id, c.GetLoginUID(), time.Now().Unix())
+	if err != nil {
+		m.Error("结束模拟登录失败！", zap.Error(err))
+		c.ResponseError(errors.New("结束模拟登录失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("会话已结束"))
+		return
+	}
+	audit.SetChange(c, fmt.Sprintf("uid=%s", session.UID), nil, map[string]interface{}{"session_no": sessionNo, "revoked": true})
+	c.ResponseOK()
+}
+
+type impersonationResp struct {
+	SessionNo    string `json:"session_no"`
+	UID          string `json:"uid"`
+	Operator     string `json:"operator"`
+	OperatorName string `json:"operator_name"`
+	Reason       string `json:"reason"`
+	ExpireAt     int64  `json:"expire_at"`
+	RevokedAt    int64  `json:"revoked_at"`
+	Revoker      string `json:"revoker"`
+	Active       int    `json:"active"` // 会话是否有效 1.是
+	CreatedAt    string `json:"created_at"`
+}
+
+func newImpersonationResp(m *impersonationModel, now int64) *impersonationResp {
+	resp := &impersonationResp{
+		SessionNo:    m.SessionNo,
+		UID:          m.UID,
+		Operator:     m.Operator,
+		OperatorName: m.OperatorName,
+		Reason:       m.Reason,
+		ExpireAt:     m.ExpireAt,
+		RevokedAt:    m.RevokedAt,
+		Revoker:      m.Revoker,
+		CreatedAt:    m.CreatedAt.String(),
+	}
+	if m.RevokedAt == 0 && m.ExpireAt > now {
+		resp.Active = 1
+	}
+	return resp
+}
@@ -0,0 +1,79 @@
+package user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpersonationAllowed(t *testing.T) {
+	assert.True(t, impersonationAllowed(http.MethodGet, "/v1/users/:uid"))
+	assert.True(t, impersonationAllowed(http.MethodPost, "/v1/conversation/sync"))
+	assert.False(t, impersonationAllowed(http.MethodPost, "/v1/conversation/syncack"))
+	assert.False(t, impersonationAllowed(http.MethodPut, "/v1/user/current"))
+	assert.False(t, impersonationAllowed(http.MethodDelete, "/v1/friends/:uid"))
+	// 返回可以修改数据的凭证的GET接口
+	assert.False(t, impersonationAllowed(http.MethodGet, "/v1/file/upload/credentials"))
+	assert.False(t, impersonationAllowed(http.MethodGet, "/v1/file/upload/presign"))
+	assert.False(t, impersonationAllowed(http.MethodGet, "/v1/file/upload"))
+	assert.False(t, impersonationAllowed(http.MethodGet, "/v1/groups/:group_no/incoming_webhooks"))
+	assert.False(t, impersonationAllowed(http.MethodGet, "/v1/user/grant_login"))
+	// 会修改数据的GET接口和没有确认只读的GET接口
+	assert.False(t, impersonationAllowed(http.MethodGet, "/v1/groups/:group_no/scanjoin"))
+	assert.False(t, impersonationAllowed(http.MethodGet, "/v1/file/share/:share_no/download"))
+	assert.False(t, impersonationAllowed(http.MethodGet, "/v1/unknown"))
+	assert.True(t, impersonationAllowed(http.MethodGet, "/v1/file/upload/check"))
+	assert.True(t, impersonationAllowed(http.MethodHead, "/v1/file/preview/*path"))
+}
+
+func TestImpersonationTTL(t *testing.T) {
+	ttl, err := impersonationTTL(0)
+	assert.NoError(t, err)
+	assert.Equal(t, impersonationDefaultTTL, ttl)
+
+	ttl, err = impersonationTTL(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, ttl)
+
+	_, err = impersonationTTL(-1)
+	assert.Error(t, err)
+	_, err = impersonationTTL(int(impersonationMaxTTL/time.Minute) + 1)
+	assert.Error(t, err)
+}
+
+func TestImpersonationMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ImpersonationMiddleware())
+	ok := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}
+	r.GET("/v1/users/:uid", ok)
+	r.PUT("/v1/user/current", ok)
+	r.POST("/v1/conversation/sync", ok)
+
+	request := func(method string, path string, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("token", token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	// 普通的token不拦截
+	assert.Equal(t, http.StatusOK, request(http.MethodPut, "/v1/user/current", "abc"))
+	// 模拟登录的token只能查询
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/v1/users/u1", impersonationTokenFlag+"abc"))
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/v1/conversation/sync", impersonationTokenFlag+"abc"))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPut, "/v1/user/current", impersonationTokenFlag+"abc"))
+}
+
+func TestNewImpersonationResp(t *testing.T) {
+	now := int64(1000)
+	assert.Equal(t, 1, newImpersonationResp(&impersonationModel{ExpireAt: now + 1}, now).Active)
+	assert.Equal(t, 0, newImpersonationResp(&impersonationModel{ExpireAt: now}, now).Active)
+	assert.Equal(t, 0, newImpersonationResp(&impersonationModel{ExpireAt: now + 1, RevokedAt: now}, now).Active)
+}
@@ -0,0 +1,59 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/postgres/pgtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPostgresUser 用户和用户设置的语句在PostgreSQL上执行 需要设置TSDD_TEST_POSTGRES
+func TestPostgresUser(t *testing.T) {
+	ctx := pgtest.NewTestContext(t)
+	userDB := NewDB(ctx)
+	settingDB := NewSettingDB(ctx.DB())
+
+	assert.NoError(t, userDB.Insert(&Model{UID: "u1", Name: "Alice", Username: "alice", ShortNo: "s1", Zone: "0086", Phone: "13000000001", Status: 1, Vercode: "v1"}))
+	assert.NoError(t, userDB.Insert(&Model{UID: "u2", Name: "Bob's", Username: "bob", ShortNo: "s2", Zone: "0086", Phone: "13000000002", Status: 1, Vercode: "v2"}))
+
+	m, err := userDB.QueryByUID("u2")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob's", m.Name)
+	assert.NotZero(t, m.Id)
+	m, err = userDB.QueryByPhone("0086", "13000000001")
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", m.UID)
+	models, err := userDB.QueryByUIDs([]string{"u1", "u2", "u3"})
+	assert.NoError(t, err)
+	assert.Len(t, models, 2)
+	count, err := userDB.queryUserCount()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	count, err = userDB.queryRegisterCountWithDate(time.Now().Format("2006-01-02"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	assert.NoError(t, userDB.updateUser(map[string]interface{}{"name": "Alice2", "sex": 1}, "u1"))
+	// 没有设置时用户设置的字段为0
+	detail, err := userDB.QueryDetailByUID("u2", "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, detail.Mute)
+	setting := newDefaultSettingModel()
+	setting.UID = "u1"
+	setting.ToUID = "u2"
+	setting.Mute = 1
+	setting.Remark = "备注"
+	assert.NoError(t, settingDB.InsertUserSettingModel(setting))
+	detail, err = userDB.QueryDetailByUID("u2", "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, detail.Mute)
+	details, err := userDB.QueryDetailByUIDs([]string{"u1", "u2"}, "u1")
+	assert.NoError(t, err)
+	assert.Len(t, details, 2)
+
+	settings, err := settingDB.QueryTwoUserSettingModel("u2", "u1")
+	assert.NoError(t, err)
+	assert.Len(t, settings, 1)
+	assert.Equal(t, "备注", settings[0].Remark)
+}
@@ -0,0 +1,181 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 注册申请的状态
+const (
+	RegisterApplyPending  = 0 // 待审核
+	RegisterApplyApproved = 1 // 已通过
+	RegisterApplyRejected = 2 // 已拒绝
+)
+
+const (
+	// registerApplyStatus 内测模式下提交注册申请后返回的status 客户端据此提示等待审核
+	registerApplyStatus = 111
+	// registerPrefixMaxCount 每个名单最多的手机号前缀数量
+	registerPrefixMaxCount = 100
+)
+
+// registerControl 注册控制 由管理后台设置 随时生效
+type registerControl struct {
+	allowPrefixes []string // 允许注册的手机号前缀 为空时不限制
+	denyPrefixes  []string // 禁止注册的手机号前缀
+	dailyLimit    int      // 每天最多注册的人数 0为不限制
+	closedBeta    bool     // 内测模式 注册需要审核
+}
+
+func newRegisterControl(m *registerSettingModel) *registerControl {
+	if m == nil {
+		return &registerControl{}
+	}
+	return &registerControl{
+		allowPrefixes: splitPrefixes(m.PhoneAllowPrefixes),
+		denyPrefixes:  splitPrefixes(m.PhoneDenyPrefixes),
+		dailyLimit:    m.DailyLimit,
+		closedBeta:    m.ClosedBeta == 1,
+	}
+}
+
+// checkPhone 检查手机号是否可以注册 username为区号加手机号 禁止的前缀优先
+func (r *registerControl) checkPhone(username string) error {
+	for _, prefix := range r.denyPrefixes {
+		if strings.HasPrefix(username, prefix) {
+			return errors.New("该手机号暂不支持注册")
+		}
+	}
+	if len(r.allowPrefixes) == 0 {
+		return nil
+	}
+	for _, prefix := range r.allowPrefixes {
+		if strings.HasPrefix(username, prefix) {
+			return nil
+		}
+	}
+	return errors.New("该手机号暂不支持注册")
+}
+
+func splitPrefixes(value string) []string {
+	prefixes := make([]string, 0)
+	for _, prefix := range strings.Split(value, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// normalizePrefixes 校验并去重手机号前缀 +86开头的转换为0086
+func normalizePrefixes(prefixes []string) ([]string, error) {
+	if len(prefixes) > registerPrefixMaxCount {
+		return nil, fmt.Errorf("手机号前缀不能超过%d个", registerPrefixMaxCount)
+	}
+	result := make([]string, 0, len(prefixes))
+	exist := map[string]bool{}
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSpace(prefix)
+		if strings.HasPrefix(prefix, "+") {
+			prefix = "00" + prefix[1:]
+		}
+		if prefix == "" {
+			continue
+		}
+		if len(prefix) > 18 || strings.Trim(prefix, "0123456789") != "" {
+			return nil, fmt.Errorf("手机号前缀[%s]有误", prefix)
+		}
+		if exist[prefix] {
+			continue
+		}
+		exist[prefix] = true
+		result = append(result, prefix)
+	}
+	return result, nil
+}
+
+// checkRegister 检查注册控制 phoneUsername为区号加手机号 不是手机号注册时为空
+func (u *User) checkRegister(phoneUsername string) (*registerControl, error) {
+	setting, err := u.registerDB.querySetting()
+	if err != nil {
+		u.Error("查询注册控制失败！", zap.Error(err))
+		return nil, errors.New("查询注册控制失败！")
+	}
+	control := newRegisterControl(setting)
+	if phoneUsername != "" {
+		if err = control.checkPhone(phoneUsername); err != nil {
+			return nil, err
+		}
+	}
+	if control.dailyLimit > 0 {
+		count, err := u.db.queryRegisterCountWithDate(time.Now().Format("2006-01-02"))
+		if err != nil {
+			u.Error("查询今日注册人数失败！", zap.Error(err))
+			return nil, errors.New("查询今日注册人数失败！")
+		}
+		if count >= int64(control.dailyLimit) {
+			return nil, errors.New("今日注册人数已达上限，请明天再试")
+		}
+	}
+	return control, nil
+}
+
+// checkThirdRegister 第三方授权登录创建账号前检查 内测模式下不能通过第三方授权注册
+func (u *User) checkThirdRegister() error {
+	control, err := u.checkRegister("")
+	if err != nil {
+		return err
+	}
+	if control.closedBeta {
+		return errors.New("内测期间请使用手机号注册，审核通过后即可登录")
+	}
+	return nil
+}
+
+// submitRegisterApply 内测模式下提交注册申请 审核通过后创建账号
+func (u *User) submitRegisterApply(c *wkhttp.Context, apply *registerApplyModel) {
+	pending, err := u.registerDB.queryPendingApplyWithUsername(apply.Username)
+	if err != nil {
+		u.Error("查询注册申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询注册申请失败！"))
+		return
+	}
+	if pending != nil {
+		c.ResponseError(errors.New("注册申请正在审核中，请耐心等待"))
+		return
+	}
+	apply.ApplyNo = util.GenerUUID()
+	apply.Password = util.MD5(util.MD5(apply.Password))
+	apply.IP = util.GetClientPublicIP(c.Request)
+	apply.Status = RegisterApplyPending
+	if err = u.registerDB.insertApply(apply); err != nil {
+		u.Error("添加注册申请失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加注册申请失败！"))
+		return
+	}
+	c.ResponseWithStatus(http.StatusBadRequest, map[string]interface{}{
+		"status":   registerApplyStatus,
+		"msg":      "注册申请已提交，审核通过后即可登录",
+		"apply_no": apply.ApplyNo,
+	})
+}
+
+// pendingApplyError 登录的账号不存在时 如果有待审核的注册申请则提示等待审核
+func (u *User) pendingApplyError(username string) error {
+	pending, err := u.registerDB.queryPendingApplyWithUsername(username)
+	if err != nil {
+		u.Warn("查询注册申请失败！", zap.Error(err))
+		return nil
+	}
+	if pending == nil {
+		return nil
+	}
+	return errors.New("注册申请正在审核中，审核通过后即可登录")
+}
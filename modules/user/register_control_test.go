@@ -0,0 +1,59 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterControlCheckPhone(t *testing.T) {
+	control := newRegisterControl(nil)
+	assert.NoError(t, control.checkPhone("008613000000000"))
+
+	control = newRegisterControl(&registerSettingModel{
+		PhoneAllowPrefixes: "0086, 00852",
+		PhoneDenyPrefixes:  "0086170",
+	})
+	assert.NoError(t, control.checkPhone("008613000000000"))
+	assert.NoError(t, control.checkPhone("0085261234567"))
+	// 禁止的前缀优先
+	assert.Error(t, control.checkPhone("008617012345678"))
+	// 不在允许的前缀内
+	assert.Error(t, control.checkPhone("0012025550123"))
+
+	control = newRegisterControl(&registerSettingModel{PhoneDenyPrefixes: "001"})
+	assert.Error(t, control.checkPhone("0012025550123"))
+	assert.NoError(t, control.checkPhone("008613000000000"))
+}
+
+func TestNormalizePrefixes(t *testing.T) {
+	prefixes, err := normalizePrefixes([]string{"+86", " 0086 ", "", "00852"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0086", "00852"}, prefixes)
+
+	_, err = normalizePrefixes([]string{"0086a"})
+	assert.Error(t, err)
+	_, err = normalizePrefixes(make([]string, registerPrefixMaxCount+1))
+	assert.Error(t, err)
+}
+
+func TestRegisterSettingReqToModel(t *testing.T) {
+	m, err := registerSettingReq{
+		PhoneAllowPrefixes: []string{"+86", "00852"},
+		DailyLimit:         100,
+		ClosedBeta:         1,
+	}.toModel()
+	assert.NoError(t, err)
+	assert.Equal(t, "0086,00852", m.PhoneAllowPrefixes)
+	assert.Equal(t, "", m.PhoneDenyPrefixes)
+	assert.Equal(t, 100, m.DailyLimit)
+	assert.True(t, newRegisterControl(m).closedBeta)
+
+	_, err = registerSettingReq{DailyLimit: -1}.toModel()
+	assert.Error(t, err)
+	_, err = registerSettingReq{ClosedBeta: 2}.toModel()
+	assert.Error(t, err)
+
+	resp := newRegisterSettingResp(nil)
+	assert.Equal(t, 0, len(resp.PhoneAllowPrefixes))
+}
@@ -7,13 +7,14 @@ import (
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
 	"go.uber.org/zap"
 )
 
-var ErrorUserNotExist = errors.New("用户不存在！")
+var ErrorUserNotExist = errcode.ErrUserNotExist
 
 // IService 用户服务接口
 type IService interface {
@@ -72,12 +73,28 @@ type IService interface {
 	GetDeviceOnline(uid string, deviceFlag config.DeviceFlag) (*config.OnlinestatusResp, error)
 	// 查询在线用户总数量
 	GetOnlineCount() (int64, error)
+	// GetActiveCountWithDate 某天在线过的用户数（日活） 根据最后一次上下线时间计算 为近似值
+	GetActiveCountWithDate(date string) (int64, error)
 	// 存在黑明单
 	ExistBlacklist(uid string, toUID string) (bool, error)
 	// 更新用户消息过期时长
 	UpdateUserMsgExpireSecond(uid string, msgExpireSecond int64) error
 	// 搜索好友
 	SearchFriendsWithKeyword(uid string, keyword string) ([]*FriendResp, error)
+	// 获取关注了某个用户的所有用户uid（用于动态、广播等下发）
+	GetFollowerUIDs(uid string) ([]string, error)
+	// IsFollow 查询uid是否关注了toUID
+	IsFollow(uid string, toUID string) (bool, error)
+	// UpdateUserStatus 封禁或解禁用户 封禁后用户的所有设备会被下线 不校验操作者权限
+	UpdateUserStatus(uid string, status int) error
+	// BanUser 封禁用户 duration为封禁时长（秒） 0为永久封禁 reason会在登录时展示给用户
+	BanUser(uid string, reason string, duration int64, operator string) error
+	// GetUIDsWithFilter 按id顺序分批查询符合条件的用户uid 返回本批最后一个用户的id 用于查询下一批 没有更多用户时uid集合为空
+	GetUIDsWithFilter(filter UserFilter, afterID int64, limit int) ([]string, int64, error)
+	// GetCountWithFilter 符合条件的用户数
+	GetCountWithFilter(filter UserFilter) (int64, error)
+	// GetExportData 导出用户保存的资料（合规导出） 每一项最多limit条 用户不存在时返回nil
+	GetExportData(uid string, limit int) (*ExportData, error)
 }
 
 // Service Service
@@ -90,6 +107,7 @@ type Service struct {
 	settingDB        *SettingDB
 	onetimePrekeysDB *onetimePrekeysDB
 	onlineService    *OnlineService
+	followDB         *followDB
 }
 
 // NewService NewService
@@ -103,9 +121,30 @@ func NewService(ctx *config.Context) IService {
 		onlineDB:         newOnlineDB(ctx),
 		Log:              log.NewTLog("userService"),
 		onlineService:    NewOnlineService(ctx),
+		followDB:         newFollowDB(ctx),
 	}
 }
 
+// GetUIDsWithFilter 按id顺序分批查询符合条件的用户uid
+func (s *Service) GetUIDsWithFilter(filter UserFilter, afterID int64, limit int) ([]string, int64, error) {
+	models, err := s.db.queryUIDsWithFilter(filter, afterID, uint64(limit))
+	if err != nil {
+		return nil, afterID, err
+	}
+	uids := make([]string, 0, len(models))
+	lastID := afterID
+	for _, m := range models {
+		uids = append(uids, m.UID)
+		lastID = m.Id
+	}
+	return uids, lastID, nil
+}
+
+// GetCountWithFilter 符合条件的用户数
+func (s *Service) GetCountWithFilter(filter UserFilter) (int64, error) {
+	return s.db.queryCountWithFilter(filter)
+}
+
 // 获取所有用户
 func (s *Service) GetAllUsers() ([]*Resp, error) {
 	models, err := s.db.queryAll()
@@ -203,6 +242,9 @@ func (s *Service) GetUserDetail(uid string, loginUID string) (*UserDetailResp, e
 		follow = 1
 		//查询加好友来源
 		sourceFrom = source.GetSoruce(friend.SourceVercode)
+		if friend.SourceType == source.SourceTypePhoneSearch || friend.SourceType == source.SourceTypeNearby {
+			sourceFrom = source.GetSourceTypeDesc(friend.SourceType)
+		}
 		if friend.Initiator == 0 && sourceFrom != "" {
 			sourceFrom = fmt.Sprintf("对方%s", sourceFrom)
 		}
@@ -222,7 +264,12 @@ func (s *Service) GetUserDetail(uid string, loginUID string) (*UserDetailResp, e
 	if toUserSetting != nil {
 		beBlacklist = toUserSetting.Blacklist
 	}
-	return NewUserDetailResp(model, remark, loginUID, sourceFrom, online, lastOffline, deviceFlag, follow, blacklist, beDeleted, beBlacklist, userSetting, vercode), nil
+	resp := NewUserDetailResp(model, remark, loginUID, sourceFrom, online, lastOffline, deviceFlag, follow, blacklist, beDeleted, beBlacklist, userSetting, vercode)
+	if follow == 1 {
+		resp.SourceType = friend.SourceType
+		resp.FriendAt = friend.CreatedAt.String()
+	}
+	return resp, nil
 }
 
 func (s *Service) GetUserDetails(uids []string, loginUID string) ([]*UserDetailResp, error) {
@@ -325,10 +372,15 @@ func (s *Service) GetUserDetails(uids []string, loginUID string) ([]*UserDetailR
 		nameRemark := ""
 		sourceFrom := ""
 		vercode := ""
+		sourceType := ""
 		friend := friendMap[uid]
 		if friend != nil && friend.IsDeleted == 0 {
 			follow = 1
 			sourceFrom = friendVercodeSourceMap[friend.SourceVercode]
+			if friend.SourceType == source.SourceTypePhoneSearch || friend.SourceType == source.SourceTypeNearby {
+				sourceFrom = source.GetSourceTypeDesc(friend.SourceType)
+			}
+			sourceType = friend.SourceType
 			vercode = friend.Vercode
 		}
 
@@ -357,7 +409,9 @@ func (s *Service) GetUserDetails(uids []string, loginUID string) ([]*UserDetailR
 		} else {
 			beDeleted = 1
 		}
-		userDetailResps = append(userDetailResps, NewUserDetailResp(userDetail, nameRemark, loginUID, sourceFrom, online, lastOffline, deviceFlag, follow, status, beDeleted, beBlacklist, setting, vercode))
+		userDetailResp := NewUserDetailResp(userDetail, nameRemark, loginUID, sourceFrom, online, lastOffline, deviceFlag, follow, status, beDeleted, beBlacklist, setting, vercode)
+		userDetailResp.SourceType = sourceType
+		userDetailResps = append(userDetailResps, userDetailResp)
 	}
 
 	return userDetailResps, nil
@@ -475,7 +529,7 @@ func (s *Service) GetUser(uid string) (*Resp, error) {
 		return nil, err
 	}
 	if userM == nil {
-		return nil, errors.New("用户不存在！")
+		return nil, errcode.ErrUserNotExist
 	}
 	if userM.Status != StatusEnable.Int() {
 		return nil, errors.New("用户不可用！")
@@ -646,7 +700,7 @@ func (s *Service) UpdateUser(req UserUpdateReq) error {
 
 func (s *Service) UpdateLoginPassword(req UpdateLoginPasswordReq) error {
 	if req.UID == "" {
-		return errors.New("uid不能为空！")
+		return errcode.ErrUIDRequired
 	}
 	if req.Password == "" {
 		return errors.New("原密码不能为空！")
@@ -656,7 +710,7 @@ func (s *Service) UpdateLoginPassword(req UpdateLoginPasswordReq) error {
 		return err
 	}
 	if userM == nil {
-		return errors.New("用户不存在！")
+		return errcode.ErrUserNotExist
 	}
 	if util.MD5(util.MD5(req.Password)) != userM.Password {
 		return errors.New("原密码不正确！")
@@ -718,6 +772,15 @@ func (s *Service) GetOnlineCount() (int64, error) {
 	return count, nil
 }
 
+// GetActiveCountWithDate 某天在线过的用户数 date格式为2006-01-02
+func (s *Service) GetActiveCountWithDate(date string) (int64, error) {
+	start, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return 0, err
+	}
+	return s.onlineDB.queryActiveCount(start.Unix(), start.AddDate(0, 0, 1).Unix())
+}
+
 func (s *Service) ExistBlacklist(uid string, toUID string) (bool, error) {
 	return s.friendDB.existBlacklist(uid, toUID)
 }
@@ -746,6 +809,16 @@ func (s *Service) SearchFriendsWithKeyword(uid string, keyword string) ([]*Frien
 	return list, nil
 }
 
+// GetFollowerUIDs 获取关注了某个用户的所有用户uid
+func (s *Service) GetFollowerUIDs(uid string) ([]string, error) {
+	return s.followDB.queryFollowerUIDs(uid)
+}
+
+// IsFollow 查询uid是否关注了toUID
+func (s *Service) IsFollow(uid string, toUID string) (bool, error) {
+	return s.followDB.exist(uid, toUID)
+}
+
 // Resp 用户返回
 type Resp struct {
 	UID             string
@@ -828,6 +901,7 @@ type SettingResp struct {
 	RevokeRemind int    //撤回提醒
 	Blacklist    int    //黑名单
 	Receipt      int    //消息是否回执
+	PushPrivacy  int    // 推送隐私 0.跟随用户的设置 1.隐藏推送内容 2.显示推送内容
 	Version      int64  // 版本
 }
 
@@ -849,6 +923,7 @@ func toSettingResp(m *SettingModel) *SettingResp {
 		RevokeRemind: m.RevokeRemind,
 		Blacklist:    m.Blacklist,
 		Receipt:      m.Receipt,
+		PushPrivacy:  m.PushPrivacy,
 		Version:      m.Version,
 	}
 }
@@ -878,6 +953,8 @@ type UserDetailResp struct {
 	Code           string            `json:"code"`             //加好友所需vercode TODO: code不再使用 请使用Vercode
 	Vercode        string            `json:"vercode"`          //
 	SourceDesc     string            `json:"source_desc"`      // 好友来源
+	SourceType     string            `json:"source_type"`      // 好友来源类型 search.搜索 phone_search.手机号搜索 nearby.附近的人 group.群聊 qrcode.扫一扫 card.名片 maillist.手机通讯录 invite.邀请码
+	FriendAt       string            `json:"friend_at"`        // 成为好友的时间
 	Remark         string            `json:"remark"`           //好友备注
 	IsUploadAvatar int               `json:"is_upload_avatar"` // 是否上传头像
 	Status         int               `json:"status"`           //用户状态 1 正常 2:黑名单
@@ -885,6 +962,7 @@ type UserDetailResp struct {
 	IsDestroy      int               `json:"is_destroy"`       // 是否注销0.否1.是
 	Flame          int               `json:"flame"`            // 是否开启阅后即焚
 	FlameSecond    int               `json:"flame_second"`     // 阅后即焚秒数
+	PushPrivacy    int               `json:"push_privacy"`     // 推送隐私 0.跟随用户的设置 1.隐藏推送内容 2.显示推送内容
 }
 
 func NewUserDetailResp(m *Detail, remark, loginUID string, sourceFrom string, onLine int, lastOffline int, deviceFlag config.DeviceFlag, follow int, status int, beDeleted int, beBlacklist int, setting *SettingModel, vercode string) *UserDetailResp {
@@ -904,9 +982,11 @@ func NewUserDetailResp(m *Detail, remark, loginUID string, sourceFrom string, on
 	}
 	var flame int
 	var flameSecond int
+	var pushPrivacy int
 	if setting != nil {
 		flame = setting.Flame
 		flameSecond = setting.FlameSecond
+		pushPrivacy = setting.PushPrivacy
 
 	}
 
@@ -940,6 +1020,17 @@ func NewUserDetailResp(m *Detail, remark, loginUID string, sourceFrom string, on
 		IsDestroy:      m.IsDestroy,
 		Flame:          flame,
 		FlameSecond:    flameSecond,
+		PushPrivacy:    pushPrivacy,
 		Vercode:        vercode,
 	}
 }
+
+// UpdateUserStatus 封禁或解禁用户
+func (s *Service) UpdateUserStatus(uid string, status int) error {
+	return updateUserStatus(s.ctx, s.db, s.Log, uid, status)
+}
+
+// BanUser 封禁用户 到期后自动解禁
+func (s *Service) BanUser(uid string, reason string, duration int64, operator string) error {
+	return banUser(s.ctx, s.db, s.Log, uid, reason, duration, operator)
+}
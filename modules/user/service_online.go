@@ -17,6 +17,9 @@ type IOnlineService interface {
 
 	// 总在线人数
 	GetOnlineCount() (int64, error)
+
+	// 分批获取在线的用户 afterUID为上一批最后一个uid 第一批为空
+	GetOnlineUIDs(afterUID string, limit int) ([]string, error)
 }
 
 type OnlineService struct {
@@ -48,7 +51,13 @@ func (o *OnlineService) listenOnlineStatus(onlineStatusList []config.OnlineStatu
 
 	for _, onlineStatus := range onlineStatusList {
 
-		if !onlineStatus.Online && onlineStatus.OnlineCount > 0 { // 如果离线，但是还有设备在线 则不更新数据库的状态
+		if !onlineStatus.Online && onlineStatus.OnlineCount > 0 { // 如果离线，但是还有设备在线 则不更新数据库的状态 只更新连接数
+			err := o.onlineDB.updateOnlineCountTx(onlineStatus.UID, onlineStatus.DeviceFlag, onlineStatus.OnlineCount, tx)
+			if err != nil {
+				tx.Rollback()
+				o.Error("更新用户在线连接数失败！", zap.Error(err))
+				return
+			}
 			continue
 		}
 		status := 0
@@ -61,6 +70,7 @@ func (o *OnlineService) listenOnlineStatus(onlineStatusList []config.OnlineStatu
 			LastOffline: int(time.Now().Unix()),
 			LastOnline:  int(time.Now().Unix()),
 			Online:      status,
+			OnlineCount: onlineStatus.OnlineCount,
 			Version:     time.Now().UnixNano() / 1000,
 		}, tx)
 		if err != nil {
@@ -104,3 +114,8 @@ func (o *OnlineService) DeviceOnline(uid string, device config.DeviceFlag) (bool
 func (o *OnlineService) GetOnlineCount() (int64, error) {
 	return o.onlineDB.queryOnlineCount()
 }
+
+// GetOnlineUIDs 分批获取在线的用户
+func (o *OnlineService) GetOnlineUIDs(afterUID string, limit int) ([]string, error) {
+	return o.onlineDB.queryOnlineUIDs(afterUID, uint64(limit))
+}
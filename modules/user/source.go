@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"go.uber.org/zap"
 )
 
@@ -59,7 +60,7 @@ func (u *User) GetFriendByVercode(vercode string) (*source.FriendModel, error) {
 		return nil, errors.New("通过vercode查询好友信息错误")
 	}
 	if model == nil {
-		return nil, errors.New("验证码错误")
+		return nil, errcode.ErrVerifyCodeInvalid
 	}
 	return &source.FriendModel{
 		UID:     model.UID,
@@ -123,7 +124,7 @@ func (u *User) GetUserByMailListVercode(vercode string) (*source.UserModel, erro
 		return nil, err
 	}
 	if model == nil {
-		return nil, errors.New("验证码错误")
+		return nil, errcode.ErrVerifyCodeInvalid
 	}
 	user, err := u.db.QueryByPhone(model.Zone, model.Phone)
 	if err != nil {
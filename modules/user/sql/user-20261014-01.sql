@@ -0,0 +1,14 @@
+-- +migrate Up
+
+-- 用户单向关注
+create table `user_follow`
+(
+  id         bigint         not null primary key AUTO_INCREMENT,
+  uid        VARCHAR(40)    not null default '',                -- 关注者uid
+  to_uid     VARCHAR(40)    not null default '',                -- 被关注者uid
+  created_at timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE UNIQUE INDEX `user_follow_uid_to_uidx` on `user_follow` (`uid`,`to_uid`);
+CREATE INDEX `user_follow_to_uidx` on `user_follow` (`to_uid`);
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+-- 好友关系生效（新增或重新添加）时的版本号，用于增量同步区分新增与更新
+ALTER TABLE `friend` ADD COLUMN add_version bigint not null DEFAULT 0 COMMENT '好友关系生效时的版本号';
+UPDATE `friend` SET add_version=version WHERE is_deleted=0;
+
+CREATE INDEX `friend_uid_versionx` on `friend` (`uid`,`version`);
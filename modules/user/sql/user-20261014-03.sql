@@ -0,0 +1,24 @@
+-- +migrate Up
+
+-- 好友生日与纪念日提醒
+create table `friend_reminder`
+(
+  id                      bigint         not null primary key AUTO_INCREMENT,
+  uid                     VARCHAR(40)    not null default '',                -- 用户uid
+  to_uid                  VARCHAR(40)    not null default '',                -- 好友uid
+  birthday                VARCHAR(10)    not null default '',                -- 生日 格式 YYYY-MM-DD 或 MM-DD
+  birthday_md             VARCHAR(5)     not null default '',                -- 生日月日 MM-DD（用于查询）
+  birthday_remind         smallint       not null default 1,                 -- 是否开启生日提醒 1.是
+  last_birthday_remind    VARCHAR(10)    not null default '',                -- 最后一次生日提醒的日期（用户时区）
+  anniversary             VARCHAR(10)    not null default '',                -- 纪念日 格式 YYYY-MM-DD 或 MM-DD
+  anniversary_md          VARCHAR(5)     not null default '',                -- 纪念日月日 MM-DD（用于查询）
+  anniversary_remind      smallint       not null default 1,                 -- 是否开启纪念日提醒 1.是
+  last_anniversary_remind VARCHAR(10)    not null default '',                -- 最后一次纪念日提醒的日期（用户时区）
+  timezone                VARCHAR(40)    not null default '',                -- 提醒时区 例如 Asia/Shanghai
+  created_at              timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at              timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE UNIQUE INDEX `friend_reminder_uid_to_uidx` on `friend_reminder` (`uid`,`to_uid`);
+CREATE INDEX `friend_reminder_birthday_mdx` on `friend_reminder` (`birthday_md`);
+CREATE INDEX `friend_reminder_anniversary_mdx` on `friend_reminder` (`anniversary_md`);
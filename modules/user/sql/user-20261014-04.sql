@@ -0,0 +1,8 @@
+-- +migrate Up
+
+-- 好友申请来源类型，用于统计好友来源
+ALTER TABLE `friend_apply_record` ADD COLUMN source varchar(40) not null DEFAULT '' COMMENT '申请来源 search.搜索 phone_search.手机号搜索 nearby.附近的人 group.群聊 qrcode.扫一扫 card.名片 maillist.手机通讯录 invite.邀请码';
+CREATE INDEX `friend_apply_record_created_atx` on `friend_apply_record` (`created_at`);
+
+-- 好友来源类型，用于展示“我们是怎么认识的”
+ALTER TABLE `friend` ADD COLUMN source_type varchar(40) not null DEFAULT '' COMMENT '加好友来源类型';
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+-- 通过邮箱查询用户（绑定邮箱、通过邮箱重置密码）
+CREATE INDEX `user_email_idx` on `user` (`email`);
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+-- 用户封禁记录 每次封禁一条 解禁后status为0
+create table `user_ban`
+(
+  id          bigint        not null primary key AUTO_INCREMENT,
+  uid         VARCHAR(40)   not null default '',  -- 被封禁的用户
+  reason      VARCHAR(500)  not null default '',  -- 封禁原因 登录时展示给用户
+  expire_at   integer       not null default 0,   -- 封禁到期时间（秒） 0.永久封禁
+  operator    VARCHAR(40)   not null default '',  -- 操作的管理员
+  status      smallint      not null default 1,   -- 1.封禁中 0.已解禁
+  appeal_at   integer       not null default 0,   -- 提交申诉的时间 每次封禁只能申诉一次
+  lifted_at   integer       not null default 0,   -- 解禁时间
+  created_at  timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at  timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE INDEX `user_ban_uid_idx` on `user_ban` (`uid`);
+CREATE INDEX `user_ban_status_expire_idx` on `user_ban` (`status`, `expire_at`);
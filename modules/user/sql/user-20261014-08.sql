@@ -0,0 +1,19 @@
+-- +migrate Up
+
+-- 管理后台以只读方式模拟登录用户的记录 用于排查问题
+create table `user_impersonation`
+(
+  id            bigint        not null primary key AUTO_INCREMENT,
+  session_no    VARCHAR(40)   not null default '',  -- 会话编号
+  uid           VARCHAR(40)   not null default '',  -- 被模拟登录的用户
+  operator      VARCHAR(40)   not null default '',  -- 操作的管理员
+  operator_name VARCHAR(100)  not null default '',  -- 操作的管理员名称
+  reason        VARCHAR(500)  not null default '',  -- 原因 会通知给用户
+  expire_at     integer       not null default 0,   -- 会话到期时间（秒）
+  revoked_at    integer       not null default 0,   -- 提前结束的时间
+  revoker       VARCHAR(40)   not null default '',  -- 提前结束会话的管理员
+  created_at    timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at    timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `user_impersonation_session_no_idx` on `user_impersonation` (`session_no`);
+CREATE INDEX `user_impersonation_uid_idx` on `user_impersonation` (`uid`);
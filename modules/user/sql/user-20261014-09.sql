@@ -0,0 +1,5 @@
+-- +migrate Up
+
+-- 设备类型下的连接数 由IM的上下线事件更新
+ALTER TABLE `user_online` ADD COLUMN online_count integer not null default 0;
+CREATE INDEX `user_online_online_idx` on `user_online` (`online`, `device_flag`);
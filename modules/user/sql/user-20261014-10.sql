@@ -0,0 +1,38 @@
+-- +migrate Up
+
+-- 注册控制 管理后台可以随时修改 只有一行
+create table `register_setting`
+(
+  id                   bigint         not null primary key AUTO_INCREMENT,
+  phone_allow_prefixes VARCHAR(2000)  not null default '',  -- 允许注册的手机号前缀（带区号 例如0086或0086138） 逗号分隔 为空时不限制
+  phone_deny_prefixes  VARCHAR(2000)  not null default '',  -- 禁止注册的手机号前缀 逗号分隔 优先于允许的前缀
+  daily_limit          int            not null default 0,   -- 每天最多注册的人数 0为不限制
+  closed_beta          smallint       not null default 0,   -- 内测模式 开启后注册需要管理员审核
+  updater              VARCHAR(40)    not null default '',  -- 最后修改的管理员
+  created_at           timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at           timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+insert into `register_setting` (id) values (1);
+
+-- 内测模式下的注册申请 审核通过后创建账号
+create table `register_apply`
+(
+  id            bigint         not null primary key AUTO_INCREMENT,
+  apply_no      VARCHAR(40)    not null default '',  -- 申请编号
+  username      VARCHAR(40)    not null default '',  -- 登录用户名 手机号注册时为区号加手机号
+  zone          VARCHAR(40)    not null default '',  -- 区号
+  phone         VARCHAR(40)    not null default '',  -- 手机号
+  name          VARCHAR(100)   not null default '',  -- 昵称
+  password      VARCHAR(40)    not null default '',  -- 登录密码 和用户表相同的加密方式
+  ip            VARCHAR(100)   not null default '',  -- 申请的IP
+  status        smallint       not null default 0,   -- 状态 0.待审核 1.已通过 2.已拒绝
+  reviewer      VARCHAR(40)    not null default '',  -- 审核人
+  reject_reason VARCHAR(500)   not null default '',  -- 拒绝原因
+  reviewed_at   bigint         not null default 0,   -- 审核时间
+  uid           VARCHAR(40)    not null default '',  -- 审核通过后创建的用户
+  created_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at    timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX `register_apply_no_idx` on `register_apply` (`apply_no`);
+CREATE INDEX `register_apply_username_idx` on `register_apply` (`username`, `status`);
+CREATE INDEX `register_apply_status_idx` on `register_apply` (`status`, `created_at`);
@@ -0,0 +1,6 @@
+-- +migrate Up
+
+-- 多组织 用户属于注册时的组织 默认组织为空
+ALTER TABLE `user` ADD COLUMN `tenant_id` VARCHAR(40) not null default '' COMMENT '组织ID';
+ALTER TABLE `user_setting` ADD COLUMN `tenant_id` VARCHAR(40) not null default '' COMMENT '组织ID 与uid所属的组织相同';
+CREATE INDEX `user_tenant_idx` on `user` (`tenant_id`);
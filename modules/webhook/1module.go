@@ -10,16 +10,21 @@ import (
 //go:embed sql
 var sqlFS embed.FS
 
+//go:embed swagger/api.yaml
+var swaggerContent string
+
 func init() {
 
 	register.AddModule(func(ctx interface{}) register.Module {
 		wk := New(ctx.(*config.Context))
 		return register.Module{
+			Name: "webhook",
 			SetupAPI: func() register.APIRouter {
 
 				return wk
 			},
-			SQLDir: register.NewSQLFS(sqlFS),
+			SQLDir:  register.NewSQLFS(sqlFS),
+			Swagger: swaggerContent,
 			Start: func() error {
 				return wk.Start()
 			},
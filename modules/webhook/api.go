@@ -11,9 +11,13 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -32,7 +36,12 @@ type Webhook struct {
 	supportTypes []common.ContentType
 	db           *DB
 	messageDB    *messageDB
+	pushLock     sync.RWMutex // 重新加载配置时替换推送客户端
 	pushMap      map[common.DeviceType]map[string]Push
+	fallbackPush Push // 厂商通道不可用时使用的FCM推送
+	webPush      *WebPush
+	webPushDB    *webPushDB
+	pushLogDB    *pushLogDB
 	groupService group.IService
 	userService  user.IService
 	wkhook.UnimplementedWebhookServiceServer
@@ -44,6 +53,27 @@ func New(ctx *config.Context) *Webhook {
 
 	supportTypes := getSupportTypes() // 支持推送的消息类型
 
+	pushMap, fallbackPush, webPush, _ := newPushClients(ctx, false)
+	w := &Webhook{
+		db:           NewDB(ctx.DB()),
+		supportTypes: supportTypes,
+		ctx:          ctx,
+		Log:          log.NewTLog("Webhook"),
+		pushMap:      pushMap,
+		fallbackPush: fallbackPush,
+		webPush:      webPush,
+		webPushDB:    newWebPushDB(ctx),
+		pushLogDB:    newPushLogDB(ctx),
+		messageDB:    newMessageDB(ctx),
+		groupService: group.NewService(ctx),
+		userService:  user.NewService(ctx),
+	}
+	extconfig.OnReload("webhook.push", w.reloadPush)
+	return w
+}
+
+// newPushClients 创建各推送方式的客户端 strict为true时扩展配置中的推送初始化失败返回错误 否则只记录日志
+func newPushClients(ctx *config.Context, strict bool) (map[common.DeviceType]map[string]Push, Push, *WebPush, error) {
 	pushMap := map[common.DeviceType]map[string]Push{}
 
 	apns := ctx.GetConfig().Push.APNS
@@ -83,17 +113,55 @@ func New(ctx *config.Context) *Webhook {
 			ctx.GetConfig().Push.FIREBASE.PackageName: NewFIREBASEPush(firebase.JsonPath, firebase.PackageName, firebase.ProjectId, ""),
 		}
 	}
-	return &Webhook{
-		db:           NewDB(ctx.DB()),
-		supportTypes: supportTypes,
-		ctx:          ctx,
-		Log:          log.NewTLog("Webhook"),
-		pushMap:      pushMap,
-		messageDB:    newMessageDB(ctx),
-		groupService: group.NewService(ctx),
-		userService:  user.NewService(ctx),
+	apnsToken := extconfig.Get().Push.APNs
+	if apns.Topic != "" && apnsToken.KeyPath != "" {
+		// 配置了.p8密钥时使用token认证 替换证书认证
+		apnsTokenPush, err := NewAPNsTokenPush(apns.Topic, apns.Dev, apnsToken.KeyPath, apnsToken.KeyID, apnsToken.TeamID, apnsToken.PoolSize)
+		if err != nil {
+			if strict {
+				return nil, nil, nil, fmt.Errorf("初始化iOS token推送失败：%w", err)
+			}
+			log.Error("初始化iOS token推送失败！", zap.Error(err))
+		} else {
+			pushMap[common.DeviceTypeIOS] = map[string]Push{
+				apns.Topic: apnsTokenPush,
+			}
+		}
 	}
+	var fallbackPush Push
+	fcm := extconfig.Get().Push.FCM
+	if fcm.PackageName != "" {
+		fcmPush, err := NewFCMPush(fcm.JSONPath, fcm.ProjectID, fcm.PackageName, fcm.ChannelID)
+		if err != nil {
+			if strict {
+				return nil, nil, nil, fmt.Errorf("初始化FCM推送失败：%w", err)
+			}
+			log.Error("初始化FCM推送失败！", zap.Error(err))
+		} else {
+			// 与旧的推送包名相同时替换旧的推送
+			for _, deviceType := range fcm.DeviceTypes {
+				if pushMap[common.DeviceType(deviceType)] == nil {
+					pushMap[common.DeviceType(deviceType)] = map[string]Push{}
+				}
+				pushMap[common.DeviceType(deviceType)][fcm.PackageName] = fcmPush
+			}
+			fallbackPush = fcmPush
+		}
+	}
+	var webPush *WebPush
+	if webCfg := extconfig.Get().Push.Web; webCfg.PrivateKey != "" {
+		var err error
+		webPush, err = NewWebPush(webCfg.PrivateKey, webCfg.Subject, webCfg.TTL)
+		if err != nil {
+			if strict {
+				return nil, nil, nil, fmt.Errorf("初始化浏览器推送失败：%w", err)
+			}
+			log.Error("初始化浏览器推送失败！", zap.Error(err))
+		}
+	}
+	return pushMap, fallbackPush, webPush, nil
 }
+
 func getSupportTypes() []common.ContentType {
 	return []common.ContentType{common.Text, common.Image, common.GIF, common.Voice, common.Video, common.File, common.Location, common.Card, common.MultipleForward, common.VectorSticker, common.EmojiSticker}
 }
@@ -110,6 +178,17 @@ func (w *Webhook) Route(r *wkhttp.WKHttp) {
 
 	r.POST("/v1/webhook/github", w.github) // github webhook
 
+	r.POST("/v1/badge/reconcile", w.ctx.AuthMiddleware(r), w.badgeReconcile) // 重新计算红点数
+
+	webPush := r.Group("/v1/webpush", w.ctx.AuthMiddleware(r))
+	{
+		webPush.GET("/vapid_public_key", w.webPushPublicKey)   // 获取VAPID公钥
+		webPush.POST("/subscriptions", w.webPushSubscribe)     // 添加浏览器推送订阅
+		webPush.DELETE("/subscriptions", w.webPushUnsubscribe) // 取消浏览器推送订阅
+	}
+
+	r.GET("/v1/manager/push/channel_stats", w.ctx.AuthMiddleware(r), w.managerPushChannelStats) // 推送通道切换统计
+
 }
 
 func (w *Webhook) Start() error {
@@ -133,8 +212,9 @@ func (w *Webhook) Start() error {
 
 }
 
+// Stop 停止接收IM的webhook 等待处理中的事件结束
 func (w *Webhook) Stop() error {
-	w.grpcServer.Stop()
+	w.grpcServer.GracefulStop()
 	return nil
 }
 
@@ -154,7 +234,7 @@ func (w *Webhook) messageNotify(c *wkhttp.Context) {
 	var messages []MsgResp
 	if err := c.BindJSON(&messages); err != nil {
 		w.Error("数据格式有误！", zap.Error(err))
-		c.ResponseError(errors.New("数据格式有误！"))
+		c.ResponseError(errcode.ErrInvalidData)
 		return
 	}
 	messageIDs, err := w.handleMessageNotify(messages)
@@ -183,6 +263,7 @@ func (w *Webhook) handleMessageNotify(messages []MsgResp) ([]string, error) {
 	}()
 	for _, message := range messages {
 		messageIDs = append(messageIDs, fmt.Sprintf("%d", message.MessageID))
+		w.handleCallAnswered(message)
 
 		if message.Header.SyncOnce == 1 || message.Header.NoPersist == 1 { // 只同步一次或有标记为不存储的消息，不进行存储
 			continue
@@ -359,11 +440,10 @@ func (w *Webhook) pushTo(msgResp msgOfflineNotify, toUids []string) error {
 		if contentMap["type"] == nil {
 			return errors.New("type为空！")
 		}
-		if contentMap["cmd"] != nil {
-			cmd := contentMap["cmd"].(string)
-			if cmd == "room.invoke" || cmd == "rtc.p2p.invoke" {
-				isVideoCall = true
-			}
+		if cmd, _ := contentMap["cmd"].(string); cmd != "" {
+			// 来电邀请和取消都需要推送
+			msgResp.call = parseCall(cmd, contentMap, msgResp.FromUID)
+			isVideoCall = msgResp.call != nil
 		}
 		contentTypeInt64, _ := contentMap["type"].(json.Number).Int64()
 		contentType := common.ContentType(contentTypeInt64)
@@ -414,7 +494,7 @@ func (w *Webhook) pushTo(msgResp msgOfflineNotify, toUids []string) error {
 
 	for _, toUID := range toUids {
 		if !isVideoCall {
-			if !w.allowPush(users, userSettings, groupSettings, toUID) {
+			if !w.allowPush(msgResp, users, userSettings, groupSettings, toUID) {
 				continue
 			}
 		} else {
@@ -434,21 +514,19 @@ func (w *Webhook) pushTo(msgResp msgOfflineNotify, toUids []string) error {
 			continue
 		}
 
+		userMsgResp := msgResp
+		userMsgResp.pushPrivacy = conversationPushPrivacy(userSettings, groupSettings, toUID)
 		w.ctx.PushPool.Work <- &pool.Job{
 			Data: map[string]interface{}{
-				"toUser": toUser,
-				"msg":    msgResp,
+				"toUser":      toUser,
+				"msg":         userMsgResp,
+				"isVideoCall": isVideoCall,
 			},
 			JobFunc: func(id int64, data interface{}) {
 				dataMap := data.(map[string]interface{})
 				toUser := dataMap["toUser"].(*user.Resp)
 				msgResp := dataMap["msg"].(msgOfflineNotify)
-				result, err := w.push(toUser, msgResp)
-				if err != nil {
-					w.Debug("推送失败！", zap.String("uid", toUser.UID), zap.String("deviceType", result.deviceType), zap.String("deviceToken", result.deviceToken), zap.Error(err))
-				} else {
-					w.Debug("推送成功！", zap.String("uid", toUser.UID), zap.String("deviceType", result.deviceType), zap.String("deviceToken", result.deviceToken))
-				}
+				w.pushWithAggregate(toUser, msgResp, dataMap["isVideoCall"].(bool))
 			},
 		}
 
@@ -457,7 +535,7 @@ func (w *Webhook) pushTo(msgResp msgOfflineNotify, toUids []string) error {
 }
 
 // 是否允许推送
-func (w *Webhook) allowPush(users []*user.Resp, userSettings []*user.SettingResp, groupSettings []*group.SettingResp, toUID string) bool {
+func (w *Webhook) allowPush(msgResp msgOfflineNotify, users []*user.Resp, userSettings []*user.SettingResp, groupSettings []*group.SettingResp, toUID string) bool {
 	isPush := true
 	if len(users) > 0 {
 		for _, user := range users {
@@ -483,7 +561,10 @@ func (w *Webhook) allowPush(users []*user.Resp, userSettings []*user.SettingResp
 	if isPush && groupSettings != nil && len(groupSettings) > 0 {
 		for _, groupSetting := range groupSettings {
 			if groupSetting.UID == toUID {
-				if groupSetting.Mute == 1 {
+				if groupSetting.Mute == 1 || groupSetting.NotifyLevel == group.NotifyLevelNone {
+					isPush = false
+				} else if groupSetting.NotifyLevel == group.NotifyLevelMention && !isMentionedOrReplied(msgResp, toUID) {
+					// 仅@我和回复我的消息通知
 					isPush = false
 				}
 				break
@@ -507,23 +588,65 @@ func (w *Webhook) push(toUser *user.Resp, msgResp msgOfflineNotify) (pushResp, e
 	deviceToken := deviceMap["device_token"]
 	deviceType := deviceMap["device_type"]
 	bundleID := deviceMap["bundle_id"]
+	manufacturer := deviceMap["manufacturer"]
 
-	w.Debug("开始推送", zap.String("uid", toUID), zap.String("deviceType", deviceType), zap.String("deviceToken", deviceToken))
+	w.Debug("开始推送", zap.String("uid", toUID), zap.String("deviceType", deviceType), zap.String("manufacturer", manufacturer), zap.String("deviceToken", deviceToken))
 
-	if w.pushMap[common.DeviceType(deviceType)] == nil {
-		return pushResp{
-			deviceType:  deviceType,
-			deviceToken: deviceToken,
-		}, errors.New("不支持的推送设备！")
-	}
-	pusher := w.pushMap[common.DeviceType(deviceType)][bundleID]
+	routeDeviceType, pusher := routePush(w.getPushMap(), deviceType, manufacturer, bundleID)
 	if pusher == nil {
-		w.Warn("不支持的推送设备！", zap.String("deviceType", deviceType), zap.String("uid", toUID), zap.String("bundleID", bundleID))
+		w.Warn("不支持的推送设备！", zap.String("deviceType", deviceType), zap.String("manufacturer", manufacturer), zap.String("uid", toUID), zap.String("bundleID", bundleID))
 		return pushResp{
 			deviceType:  deviceType,
 			deviceToken: deviceToken,
 		}, errors.New("不支持的推送设备！")
 	}
+	deviceType = string(routeDeviceType)
+	// 厂商通道连续失败时切换到FCM或只通过应用内送达
+	var channelHealth pushChannelHealth
+	fallback := ""
+	onInvalid := func() {
+		w.removeInvalidDeviceToken(toUID, deviceToken)
+	}
+	if supportFallback(deviceType) {
+		fallback = w.pushFallbackChannel(deviceMap)
+		channelHealth = w.getPushChannelHealth(toUID, deviceToken)
+		if channelHealth.inFallback(time.Now()) {
+			if fallback == pushFallbackInApp {
+				w.Debug("厂商推送通道不可用，只通过应用内送达", zap.String("uid", toUID), zap.String("deviceType", deviceType))
+				return pushResp{
+					deviceType:  pushFallbackInApp,
+					deviceToken: deviceToken,
+				}, nil
+			}
+			pusher = w.getFallbackPush()
+			deviceType = pushFallbackFCM
+			deviceToken = deviceMap["fcm_token"]
+			fallback = "" // 只记录厂商通道的健康状态
+			onInvalid = func() {
+				if err := w.ctx.GetRedisConn().Hdel(fmt.Sprintf("%s%s", common.UserDeviceTokenPrefix, toUID), "fcm_token"); err != nil {
+					w.Warn("删除失效的fcm token失败！", zap.Error(err), zap.String("uid", toUID))
+				}
+			}
+		}
+	}
+	var onDone func(error)
+	if fallback != "" {
+		vendorDeviceType := deviceType
+		onDone = func(err error) {
+			w.updatePushChannelHealth(toUID, vendorDeviceType, channelHealth, fallback, err)
+		}
+	}
+	msgResp.locale = deviceMap["locale"]
+	if extconfig.Get().Push.Badge.ServerCompute {
+		badge, err := w.syncBadge(toUID)
+		if err != nil {
+			// 计算失败时使用累加的红点
+			w.Warn("计算红点数失败！", zap.Error(err), zap.String("uid", toUID))
+		} else {
+			msgResp.badgeComputed = true
+			msgResp.badge = pushBadge(badge, msgResp)
+		}
+	}
 	payload, err := pusher.GetPayload(msgResp, w.ctx, toUser)
 	if err != nil {
 		return pushResp{
@@ -531,7 +654,45 @@ func (w *Webhook) push(toUser *user.Resp, msgResp msgOfflineNotify) (pushResp, e
 			deviceToken: deviceToken,
 		}, err
 	}
-	err = pusher.Push(deviceToken, payload)
+	voipToken := deviceMap["voip_token"]
+	voipPusher, supportVoIP := pusher.(VoIPPush)
+	rtcPayload := payload.GetRTCPayload()
+	if supportVoIP && voipToken != "" && rtcPayload != nil && rtcPayload.GetOperation() == callOperationInvite {
+		// 来电邀请使用VoIP推送 取消使用普通推送 VoIP推送失败时直接使用普通推送不重试
+		err = w.deliver(&pushDelivery{
+			provider:    fmt.Sprintf("%s_VOIP", deviceType),
+			uid:         toUID,
+			deviceToken: voipToken,
+			messageID:   msgResp.MessageID,
+			send: func() error {
+				return voipPusher.PushVoIP(voipToken, payload)
+			},
+			onInvalid: func() {
+				if err := w.ctx.GetRedisConn().Hdel(fmt.Sprintf("%s%s", common.UserDeviceTokenPrefix, toUID), "voip_token"); err != nil {
+					w.Warn("删除失效的voip token失败！", zap.Error(err), zap.String("uid", toUID))
+				}
+			},
+		})
+		if err == nil {
+			return pushResp{
+				deviceType:  deviceType,
+				deviceToken: voipToken,
+			}, nil
+		}
+		w.Warn("VoIP推送失败，使用普通推送！", zap.Error(err), zap.String("uid", toUID))
+	}
+	err = w.deliver(&pushDelivery{
+		provider:    deviceType,
+		uid:         toUID,
+		deviceToken: deviceToken,
+		messageID:   msgResp.MessageID,
+		retry:       msgResp.call == nil,
+		send: func() error {
+			return pusher.Push(deviceToken, payload)
+		},
+		onInvalid: onInvalid,
+		onDone:    onDone,
+	})
 	if err != nil {
 		return pushResp{
 			deviceType:  deviceType,
@@ -544,6 +705,24 @@ func (w *Webhook) push(toUser *user.Resp, msgResp msgOfflineNotify) (pushResp, e
 	}, nil
 }
 
+// removeInvalidDeviceToken 删除已失效的设备注册信息 期间用户重新注册了新的token则不删除
+func (w *Webhook) removeInvalidDeviceToken(uid string, deviceToken string) {
+	key := fmt.Sprintf("%s%s", common.UserDeviceTokenPrefix, uid)
+	currentToken, err := w.ctx.GetRedisConn().Hget(key, "device_token")
+	if err != nil {
+		w.Warn("查询用户设备token失败！", zap.Error(err), zap.String("uid", uid))
+		return
+	}
+	if currentToken != deviceToken {
+		return
+	}
+	if err = w.ctx.GetRedisConn().Del(key); err != nil {
+		w.Warn("删除失效的设备token失败！", zap.Error(err), zap.String("uid", uid))
+		return
+	}
+	w.Info("设备token已失效，已删除设备注册信息", zap.String("uid", uid))
+}
+
 func (w *Webhook) containSupportType(contentType common.ContentType) bool {
 	for _, t := range w.supportTypes {
 		if t == contentType {
@@ -639,6 +818,17 @@ type msgOfflineNotify struct {
 	Compress        string   `json:"compress,omitempty"`         // 压缩ToUIDs 如果为空 表示不压缩 为gzip则采用gzip压缩
 	CompresssToUIDs []byte   `json:"compress_to_uids,omitempty"` // 已压缩的to_uids
 	SourceID        int64    `json:"source_id,omitempty"`        // 来源节点ID
+
+	// 以下为推送时使用
+	locale         string    // 接收设备的语言 用于选择推送文案
+	collapseKey    string    // 开启合并推送时的会话key 同一个会话的通知互相替换
+	aggregateCount int64     // 合并推送的消息数 大于1时推送“n条新消息”
+	badgeIncr      int64     // 合并推送时红点增加的数量
+	call           *callInfo // 来电邀请或取消
+	skipBadge      bool      // 不累加红点 浏览器推送与手机推送是同一条消息
+	badgeComputed  bool      // 是否已由服务端计算红点
+	badge          int       // 服务端计算的红点
+	pushPrivacy    int       // 接收者对会话的推送隐私设置
 }
 
 type pushResp struct {
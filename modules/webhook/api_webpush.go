@@ -0,0 +1,237 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 获取VAPID公钥 web端订阅时作为applicationServerKey
+func (w *Webhook) webPushPublicKey(c *wkhttp.Context) {
+	webPush := w.getWebPush()
+	if webPush == nil {
+		c.ResponseError(errors.New("未开启浏览器推送！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"public_key": webPush.PublicKey(),
+	})
+}
+
+// 添加浏览器推送订阅 请求内容为PushSubscription.toJSON()
+func (w *Webhook) webPushSubscribe(c *wkhttp.Context) {
+	if w.getWebPush() == nil {
+		c.ResponseError(errors.New("未开启浏览器推送！"))
+		return
+	}
+	var req webPushSubscribeReq
+	if err := c.BindJSON(&req); err != nil {
+		w.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	locale := strings.TrimSpace(req.Locale)
+	if locale == "" {
+		locale = c.GetHeader("Accept-Language")
+	}
+	err := w.webPushDB.insertOrUpdate(&webPushSubscriptionModel{
+		UID:          c.GetLoginUID(),
+		EndpointHash: webPushEndpointHash(req.Endpoint),
+		Endpoint:     req.Endpoint,
+		P256dh:       req.Keys.P256dh,
+		Auth:         req.Keys.Auth,
+		Locale:       normalizePushLocale(locale),
+		ExpiredAt:    req.ExpirationTime,
+	})
+	if err != nil {
+		w.Error("添加浏览器推送订阅失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加浏览器推送订阅失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 取消浏览器推送订阅
+func (w *Webhook) webPushUnsubscribe(c *wkhttp.Context) {
+	var req struct {
+		Endpoint string `json:"endpoint"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		w.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errcode.ErrInvalidData)
+		return
+	}
+	if strings.TrimSpace(req.Endpoint) == "" {
+		c.ResponseError(errors.New("endpoint不能为空！"))
+		return
+	}
+	err := w.webPushDB.deleteWithUIDAndEndpointHash(c.GetLoginUID(), webPushEndpointHash(req.Endpoint))
+	if err != nil {
+		w.Error("取消浏览器推送订阅失败！", zap.Error(err))
+		c.ResponseError(errors.New("取消浏览器推送订阅失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+type webPushSubscribeReq struct {
+	Endpoint       string `json:"endpoint"`
+	ExpirationTime int64  `json:"expirationTime"` // 订阅的过期时间（毫秒） 为空则不过期
+	Keys           struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+	Locale string `json:"locale"` // 浏览器的语言 为空时使用Accept-Language
+}
+
+func (r webPushSubscribeReq) check() error {
+	u, err := url.Parse(r.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("endpoint格式有误！")
+	}
+	if r.ExpirationTime > 0 && r.ExpirationTime <= time.Now().UnixMilli() {
+		return errors.New("订阅已过期！")
+	}
+	p256dh, err := decodeWebPushKey(r.Keys.P256dh)
+	if err != nil || len(p256dh) != 65 {
+		return errors.New("p256dh格式有误！")
+	}
+	auth, err := decodeWebPushKey(r.Keys.Auth)
+	if err != nil || len(auth) != 16 {
+		return errors.New("auth格式有误！")
+	}
+	return nil
+}
+
+func webPushEndpointHash(endpoint string) string {
+	hash := sha256.Sum256([]byte(strings.TrimSpace(endpoint)))
+	return hex.EncodeToString(hash[:])
+}
+
+// pushWeb 推送给用户订阅的所有浏览器 过期或已失效的订阅会被删除
+func (w *Webhook) pushWeb(toUser *user.Resp, msgResp msgOfflineNotify) {
+	webPush := w.getWebPush()
+	if webPush == nil {
+		return
+	}
+	subscriptions, err := w.webPushDB.queryWithUID(toUser.UID)
+	if err != nil {
+		w.Warn("查询浏览器推送订阅失败！", zap.Error(err), zap.String("uid", toUser.UID))
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+	msgResp.skipBadge = true
+	urgency := webPushUrgencyNormal
+	if msgResp.call != nil {
+		urgency = webPushUrgencyHigh
+	}
+	topic := webPushTopic(webPushTag(msgResp))
+	nowMilli := time.Now().UnixMilli()
+	localeData := map[string][]byte{} // 同一种语言的推送内容只生成一次
+	for _, subscription := range subscriptions {
+		if subscription.ExpiredAt > 0 && subscription.ExpiredAt <= nowMilli {
+			w.removeWebPushSubscription(subscription)
+			continue
+		}
+		data, ok := localeData[subscription.Locale]
+		if !ok {
+			msgResp.locale = subscription.Locale
+			pushInfo, err := ParsePushInfo(msgResp, w.ctx, toUser)
+			if err != nil {
+				w.Warn("获取浏览器推送内容失败！", zap.Error(err), zap.String("uid", toUser.UID))
+				return
+			}
+			data = []byte(util.ToJson(newWebPushData(msgResp, pushInfo)))
+			localeData[subscription.Locale] = data
+		}
+		sub := webPushSubscription{
+			Endpoint: subscription.Endpoint,
+			P256dh:   subscription.P256dh,
+			Auth:     subscription.Auth,
+		}
+		removeSubscription := subscription
+		err = w.deliver(&pushDelivery{
+			provider:    pushProviderWeb,
+			uid:         toUser.UID,
+			deviceToken: subscription.Endpoint,
+			messageID:   msgResp.MessageID,
+			retry:       msgResp.call == nil,
+			send: func() error {
+				return webPush.Send(sub, data, topic, urgency)
+			},
+			onInvalid: func() {
+				w.removeWebPushSubscription(removeSubscription)
+			},
+		})
+		if err != nil {
+			w.Debug("浏览器推送失败！", zap.Error(err), zap.String("uid", toUser.UID), zap.String("endpoint", subscription.Endpoint))
+			continue
+		}
+		w.Debug("浏览器推送成功！", zap.String("uid", toUser.UID), zap.String("endpoint", subscription.Endpoint))
+	}
+}
+
+func (w *Webhook) removeWebPushSubscription(subscription *webPushSubscriptionModel) {
+	if err := w.webPushDB.deleteWithEndpointHash(subscription.EndpointHash); err != nil {
+		w.Warn("删除失效的浏览器推送订阅失败！", zap.Error(err), zap.String("uid", subscription.UID))
+	}
+}
+
+// newWebPushData 浏览器推送的内容 由web端的Service Worker显示通知
+func newWebPushData(msgResp msgOfflineNotify, pushInfo *PayloadInfo) map[string]interface{} {
+	data := map[string]interface{}{
+		"title":        pushInfo.Title,
+		"content":      pushInfo.Content,
+		"tag":          webPushTag(msgResp),
+		"channel_id":   msgResp.ChannelID,
+		"channel_type": msgResp.ChannelType,
+		"message_id":   fmt.Sprintf("%d", msgResp.MessageID),
+	}
+	if msgResp.ChannelType == common.ChannelTypePerson.Uint8() {
+		// 个人会话的channel_id为接收者 web端需要打开与发送者的会话
+		data["channel_id"] = msgResp.FromUID
+	}
+	if pushInfo.IsVideoCall {
+		data["call"] = map[string]interface{}{
+			"call_id":   pushInfo.CallID,
+			"call_type": pushInfo.CallType,
+			"operation": pushInfo.Operation,
+			"from_uid":  pushInfo.FromUID,
+		}
+	}
+	return data
+}
+
+// webPushTag 通知的tag 相同tag的通知在浏览器中互相替换
+func webPushTag(msgResp msgOfflineNotify) string {
+	if msgResp.call != nil {
+		return fmt.Sprintf("rtc_%s", msgResp.call.callID)
+	}
+	if msgResp.collapseKey != "" {
+		return msgResp.collapseKey
+	}
+	return fmt.Sprintf("%d", msgResp.MessageID)
+}
+
+// webPushTopic 推送服务中相同topic未送达的推送会被替换 topic最多32个base64url字符
+func webPushTopic(tag string) string {
+	hash := sha256.Sum256([]byte(tag))
+	return hex.EncodeToString(hash[:16])
+}
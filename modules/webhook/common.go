@@ -35,6 +35,7 @@ type PayloadInfo struct {
 	FromUID     string // rtc消息需要
 	CallType    common.RTCCallType
 	Operation   string
+	CallID      string
 }
 
 func (p *PayloadInfo) toPayload() Payload {
@@ -50,6 +51,7 @@ func (p *PayloadInfo) toPayload() Payload {
 			fromUID:     p.FromUID,
 			operation:   p.Operation,
 			callType:    p.CallType,
+			callID:      p.CallID,
 		}
 	} else {
 		payload = &basePayload
@@ -66,21 +68,47 @@ func ParsePushInfo(msgResp msgOfflineNotify, ctx *config.Context, toUser *user.R
 	}
 
 	// 红点
-	badge, err := getUserBadge(toUID, ctx)
-	if err != nil {
-		log.Warn("获取用户红点失败", zap.Error(err), zap.String("uid", toUID))
+	var badge int
+	if msgResp.badgeComputed {
+		badge = msgResp.badge
+	} else if !msgResp.skipBadge {
+		badge, err = getUserBadge(toUID, msgResp.badgeIncr, ctx)
+		if err != nil {
+			log.Warn("获取用户红点失败", zap.Error(err), zap.String("uid", toUID))
+		}
 	}
 
 	payloadInfo := &PayloadInfo{
 		Badge: badge,
 	}
 
-	content, err := getMessageAlert(msgResp, toUser, ctx)
-	if err != nil {
-		return nil, err
+	var content string
+	if msgResp.call != nil {
+		payloadInfo.IsVideoCall = true
+		payloadInfo.FromUID = msgResp.call.fromUID
+		payloadInfo.CallType = msgResp.call.callType
+		payloadInfo.Operation = msgResp.call.operation
+		payloadInfo.CallID = msgResp.call.callID
+		if msgResp.call.operation == callOperationCancel {
+			content = pushText(msgResp.locale, pushTplCallCancel, nil)
+		} else {
+			content = pushText(msgResp.locale, pushTplNewCall, nil)
+		}
+	} else if msgResp.aggregateCount > 1 {
+		content = pushText(msgResp.locale, pushTplAggregate, map[string]string{
+			"count": fmt.Sprintf("%d", msgResp.aggregateCount),
+		})
+	} else {
+		content, err = getMessageAlert(msgResp, toUser, ctx)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if msgResp.ChannelType == common.ChannelTypePerson.Uint8() {
+	if isPushPrivacy(msgResp, toUser) {
+		// 隐藏推送内容时不显示发送者和群名
+		payloadInfo.Title = ctx.GetConfig().AppName
+	} else if msgResp.ChannelType == common.ChannelTypePerson.Uint8() {
 		payloadInfo.Title = fromName
 	} else {
 		var groupName string
@@ -90,7 +118,10 @@ func ParsePushInfo(msgResp msgOfflineNotify, ctx *config.Context, toUser *user.R
 			return nil, err
 		}
 		payloadInfo.Title = groupName
-		content = fmt.Sprintf("%s：%s", fromName, content)
+		content = pushText(msgResp.locale, pushTplGroupContent, map[string]string{
+			"name":    fromName,
+			"content": content,
+		})
 	}
 	payloadInfo.Content = content
 
@@ -108,41 +139,22 @@ func getFromName(msgResp msgOfflineNotify, ctx *config.Context) (string, error)
 
 func getMessageAlert(msg msgOfflineNotify, toUser *user.Resp, ctx *config.Context) (string, error) {
 	setting := config.SettingFromUint8(msg.Setting)
-	if msg.PayloadMap == nil || setting.Signal || !ctx.GetConfig().Push.ContentDetailOn || toUser.MsgShowDetail == 1 {
+	if msg.PayloadMap == nil || setting.Signal || !ctx.GetConfig().Push.ContentDetailOn || isPushPrivacy(msg, toUser) {
 		if msg.PayloadMap != nil && msg.PayloadMap["cmd"] != nil {
-			return "您收到新的来电", nil
+			return pushText(msg.locale, pushTplNewCall, nil), nil
 		}
-		return "您有一条新的消息", nil
+		return pushText(msg.locale, pushTplNewMessage, nil), nil
 	}
 
 	var alert string
 	contentTypeInt64, _ := msg.PayloadMap["type"].(json.Number).Int64()
 	contentType := common.ContentType(contentTypeInt64)
-	switch contentType {
-	case common.Text:
+	if contentType == common.Text {
 		if msg.PayloadMap["content"] != nil {
 			alert = msg.PayloadMap["content"].(string)
 		}
-	case common.Image:
-		alert = "[图片]"
-	case common.GIF:
-		alert = "[GIF]"
-	case common.Voice:
-		alert = "[语音]"
-	case common.Video:
-		alert = "[视频]"
-	case common.Card:
-		alert = "[名片]"
-	case common.File:
-		alert = "[文件]"
-	case common.Location:
-		alert = "[位置]"
-	case common.VectorSticker:
-		alert = "[动画表情]"
-	case common.EmojiSticker:
-		alert = "[emoji表情]"
-	case common.MultipleForward:
-		alert = "[聊天记录]"
+	} else if tplKey := pushTplContentTypes[contentType]; tplKey != "" {
+		alert = pushText(msg.locale, tplKey, nil)
 	}
 	return alert, nil
 }
@@ -251,8 +263,12 @@ func getAndCacheGroupName(msgResp msgOfflineNotify, ctx *config.Context) (string
 	return groupName, nil
 }
 
-func getUserBadge(uid string, ctx *config.Context) (int, error) {
-	badge, err := ctx.GetRedisConn().Hincrby(common.UserDeviceBadgePrefix, uid, 1)
+// getUserBadge 增加并返回用户的红点数 incr小于1时增加1
+func getUserBadge(uid string, incr int64, ctx *config.Context) (int, error) {
+	if incr < 1 {
+		incr = 1
+	}
+	badge, err := ctx.GetRedisConn().Hincrby(common.UserDeviceBadgePrefix, uid, int(incr))
 	if err != nil {
 		log.Error("获取红点数失败！", zap.Error(err))
 		return 0, err
@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+// 推送记录的状态
+const (
+	pushLogStatusSuccess      = 1 // 成功
+	pushLogStatusFailed       = 2 // 失败
+	pushLogStatusInvalidToken = 3 // token已失效
+)
+
+type pushLogDB struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+func newPushLogDB(ctx *config.Context) *pushLogDB {
+	return &pushLogDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *pushLogDB) insert(m *pushLogModel) error {
+	_, err := d.session.InsertInto("push_log").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+type pushLogModel struct {
+	UID         string
+	Provider    string
+	DeviceToken string
+	MessageID   int64
+	Attempt     int
+	Status      int
+	ErrMsg      string
+	Latency     int64
+	db.BaseModel
+}
@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+)
+
+type webPushDB struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+func newWebPushDB(ctx *config.Context) *webPushDB {
+	return &webPushDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// insertOrUpdate 添加或更新订阅 同一个endpoint重新订阅时更新密钥和所属用户
+func (d *webPushDB) insertOrUpdate(m *webPushSubscriptionModel) error {
+	_, err := d.session.InsertBySql("insert into web_push_subscription(uid,endpoint_hash,endpoint,p256dh,auth,locale,expired_at) values(?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE uid=VALUES(uid),p256dh=VALUES(p256dh),auth=VALUES(auth),locale=VALUES(locale),expired_at=VALUES(expired_at),updated_at=NOW()", m.UID, m.EndpointHash, m.Endpoint, m.P256dh, m.Auth, m.Locale, m.ExpiredAt).Exec()
+	return err
+}
+
+// queryWithUID 查询用户的订阅
+func (d *webPushDB) queryWithUID(uid string) ([]*webPushSubscriptionModel, error) {
+	var models []*webPushSubscriptionModel
+	_, err := d.session.Select("*").From("web_push_subscription").Where("uid=?", uid).Load(&models)
+	return models, err
+}
+
+// deleteWithEndpointHash 删除订阅
+func (d *webPushDB) deleteWithEndpointHash(endpointHash string) error {
+	_, err := d.session.DeleteFrom("web_push_subscription").Where("endpoint_hash=?", endpointHash).Exec()
+	return err
+}
+
+// deleteWithUIDAndEndpointHash 删除用户的订阅
+func (d *webPushDB) deleteWithUIDAndEndpointHash(uid string, endpointHash string) error {
+	_, err := d.session.DeleteFrom("web_push_subscription").Where("uid=? and endpoint_hash=?", uid, endpointHash).Exec()
+	return err
+}
+
+type webPushSubscriptionModel struct {
+	UID          string
+	EndpointHash string
+	Endpoint     string
+	P256dh       string
+	Auth         string
+	Locale       string
+	ExpiredAt    int64 // 过期时间（毫秒） 0为不过期
+	db.BaseModel
+}
@@ -1,11 +1,16 @@
 package webhook
 
 import (
+	"errors"
+
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 )
 
+// ErrInvalidDeviceToken 设备token已失效（卸载或token过期） 推送返回此错误时删除设备的注册信息
+var ErrInvalidDeviceToken = errors.New("设备token已失效！")
+
 // Payload 推送内容
 type Payload interface {
 	GetTitle() string   // 推送标题
@@ -19,6 +24,7 @@ type RTCPayload interface {
 	GetCallType() common.RTCCallType // 音视频呼叫类型
 	GetOperation() string            // 音视频操作 invite: 邀请音视频 cancel：取消邀请
 	GetFromUID() string              // 发起人的uid
+	GetCallID() string               // 来电id 单聊为发起人的uid 多人通话为房间id
 }
 
 // BasePayload 基础负载
@@ -52,6 +58,7 @@ type BaseRTCPayload struct {
 	callType  common.RTCCallType
 	operation string
 	fromUID   string
+	callID    string
 }
 
 func (b *BaseRTCPayload) GetCallType() common.RTCCallType {
@@ -66,6 +73,10 @@ func (b *BaseRTCPayload) GetFromUID() string {
 	return b.fromUID
 }
 
+func (b *BaseRTCPayload) GetCallID() string {
+	return b.callID
+}
+
 func (b *BaseRTCPayload) GetRTCPayload() RTCPayload {
 	return b
 }
@@ -75,3 +86,8 @@ type Push interface {
 	GetPayload(msg msgOfflineNotify, ctx *config.Context, toUser *user.Resp) (Payload, error)
 	Push(deviceToken string, payload Payload) error
 }
+
+// VoIPPush 支持VoIP推送（iOS PushKit）的推送 来电时使用设备的voip token推送
+type VoIPPush interface {
+	PushVoIP(voipToken string, payload Payload) error
+}
@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"go.uber.org/zap"
+)
+
+// pushAggregatePrefix 会话在合并窗口内收到的消息数
+const pushAggregatePrefix = "pushAggregate:"
+
+// pushCollapseKey 会话的合并key 个人会话以发送者作为会话
+func pushCollapseKey(msg msgOfflineNotify) string {
+	channelID := msg.ChannelID
+	if msg.ChannelType == common.ChannelTypePerson.Uint8() {
+		channelID = msg.FromUID
+	}
+	return fmt.Sprintf("%s_%d", channelID, msg.ChannelType)
+}
+
+// pushNotifyID 推送的通知id 相同的通知id在手机上只显示最新的一条
+// 开启合并时同一个会话使用相同的通知id 否则使用消息的序号
+func pushNotifyID(msg msgOfflineNotify) string {
+	if msg.collapseKey == "" {
+		return fmt.Sprintf("%d", msg.MessageSeq)
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(msg.collapseKey))
+	// 部分厂商的通知id需要为非负整数
+	return fmt.Sprintf("%d", h.Sum32()&0x7fffffff)
+}
+
+// pushWithAggregate 合并推送
+// 会话的第一条消息立即推送 合并窗口内的后续消息只计数 窗口结束时推送一条“n条新消息”替换之前的通知
+// 窗口内一直有新消息时每个窗口最多推送一次 计数保存在redis中 多个节点收到同一个会话的消息时也只推送一次
+func (w *Webhook) pushWithAggregate(toUser *user.Resp, msgResp msgOfflineNotify, isVideoCall bool) {
+	if isVideoCall {
+		w.pushCall(toUser, msgResp)
+		return
+	}
+	window := extconfig.Get().Push.AggregateWindow
+	if window <= 0 {
+		w.pushAndLog(toUser, msgResp)
+		return
+	}
+	msgResp.collapseKey = pushCollapseKey(msgResp)
+	key := fmt.Sprintf("%s%s:%s", pushAggregatePrefix, toUser.UID, msgResp.collapseKey)
+	count, err := w.ctx.GetRedisConn().Incr(key)
+	if err != nil {
+		w.Warn("累加合并推送的消息数失败！", zap.Error(err), zap.String("uid", toUser.UID))
+		w.pushAndLog(toUser, msgResp)
+		return
+	}
+	if count > 1 {
+		// 由会话的第一条消息在窗口结束时合并推送
+		return
+	}
+	w.expireAggregate(key, window)
+	w.pushAndLog(toUser, msgResp)
+	time.AfterFunc(window, func() {
+		w.flushAggregate(key, toUser, msgResp, 1, window)
+	})
+}
+
+// flushAggregate 合并窗口结束 有新消息时推送合并的通知并开始下一个窗口 pushed为已推送的消息数
+func (w *Webhook) flushAggregate(key string, toUser *user.Resp, msgResp msgOfflineNotify, pushed int64, window time.Duration) {
+	countStr, err := w.ctx.GetRedisConn().GetString(key)
+	if err != nil {
+		w.Warn("查询合并推送的消息数失败！", zap.Error(err), zap.String("uid", toUser.UID))
+		return
+	}
+	count, _ := strconv.ParseInt(countStr, 10, 64)
+	if count <= pushed {
+		// 窗口内没有新消息 下一条消息重新开始计数
+		if err = w.ctx.GetRedisConn().Del(key); err != nil {
+			w.Warn("删除合并推送的消息数失败！", zap.Error(err), zap.String("uid", toUser.UID))
+		}
+		return
+	}
+	msgResp.aggregateCount = count
+	msgResp.badgeIncr = count - pushed
+	w.expireAggregate(key, window)
+	w.pushAndLog(toUser, msgResp)
+	time.AfterFunc(window, func() {
+		w.flushAggregate(key, toUser, msgResp, count, window)
+	})
+}
+
+// expireAggregate 节点异常没有删除时计数也会过期
+func (w *Webhook) expireAggregate(key string, window time.Duration) {
+	if err := w.ctx.GetRedisConn().Expire(key, window*3); err != nil {
+		w.Warn("设置合并推送的过期时间失败！", zap.Error(err), zap.String("key", key))
+	}
+}
+
+func (w *Webhook) pushAndLog(toUser *user.Resp, msgResp msgOfflineNotify) {
+	result, err := w.push(toUser, msgResp)
+	if err != nil {
+		w.Debug("推送失败！", zap.String("uid", toUser.UID), zap.String("deviceType", result.deviceType), zap.String("deviceToken", result.deviceToken), zap.Error(err))
+	} else {
+		w.Debug("推送成功！", zap.String("uid", toUser.UID), zap.String("deviceType", result.deviceType), zap.String("deviceToken", result.deviceToken))
+	}
+	w.pushWeb(toUser, msgResp)
+}
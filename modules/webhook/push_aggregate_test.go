@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushCollapseKey(t *testing.T) {
+	msg := msgOfflineNotify{}
+	msg.FromUID = "u1"
+	msg.ChannelID = "u2"
+	msg.ChannelType = common.ChannelTypePerson.Uint8()
+	assert.Equal(t, "u1_1", pushCollapseKey(msg))
+
+	msg.ChannelID = "g1"
+	msg.ChannelType = common.ChannelTypeGroup.Uint8()
+	assert.Equal(t, "g1_2", pushCollapseKey(msg))
+}
+
+func TestPushNotifyID(t *testing.T) {
+	msg := msgOfflineNotify{}
+	msg.MessageSeq = 12
+	assert.Equal(t, "12", pushNotifyID(msg))
+
+	msg.collapseKey = "g1_2"
+	notifyID := pushNotifyID(msg)
+	id, err := strconv.ParseInt(notifyID, 10, 64)
+	assert.NoError(t, err)
+	assert.True(t, id >= 0)
+	msg.MessageSeq = 13
+	assert.Equal(t, notifyID, pushNotifyID(msg))
+	msg.collapseKey = "g2_2"
+	assert.NotEqual(t, notifyID, pushNotifyID(msg))
+}
+
+func TestPushTextAggregate(t *testing.T) {
+	assert.Equal(t, "5条新消息", pushText("", pushTplAggregate, map[string]string{"count": "5"}))
+	assert.Equal(t, "5 new messages", pushText("en", pushTplAggregate, map[string]string{"count": "5"}))
+}
@@ -0,0 +1,194 @@
+package webhook
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/sideshow/apns2"
+	"github.com/sideshow/apns2/token"
+	"go.uber.org/zap"
+)
+
+const (
+	// apnsCollapseIDMaxLen apns-collapse-id的最大字节数
+	apnsCollapseIDMaxLen = 64
+	// apnsRTCExpiration 音视频邀请的有效期 过期后APNs不再送达
+	apnsRTCExpiration = time.Second * 60
+)
+
+// APNsTokenPayload iOS token推送负载
+type APNsTokenPayload struct {
+	Payload
+	collapseID string
+}
+
+// NewAPNsTokenPayload NewAPNsTokenPayload
+func NewAPNsTokenPayload(payloadInfo *PayloadInfo, collapseID string) *APNsTokenPayload {
+	if len(collapseID) > apnsCollapseIDMaxLen {
+		collapseID = collapseID[:apnsCollapseIDMaxLen]
+	}
+	return &APNsTokenPayload{
+		Payload:    payloadInfo.toPayload(),
+		collapseID: collapseID,
+	}
+}
+
+// APNsTokenPush 使用.p8密钥（token）认证的iOS推送 多个HTTP/2连接轮流使用
+type APNsTokenPush struct {
+	topic   string
+	clients []*apns2.Client
+	next    uint32
+	log.Log
+}
+
+// NewAPNsTokenPush NewAPNsTokenPush poolSize为HTTP/2连接数 每个连接可以并发多个请求
+func NewAPNsTokenPush(topic string, dev bool, keyPath string, keyID string, teamID string, poolSize int) (*APNsTokenPush, error) {
+	authKey, err := token.AuthKeyFromFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	// token在多个连接之间共用 过期后自动重新生成
+	tk := &token.Token{
+		AuthKey: authKey,
+		KeyID:   keyID,
+		TeamID:  teamID,
+	}
+	if poolSize <= 0 {
+		poolSize = 1
+	}
+	clients := make([]*apns2.Client, 0, poolSize)
+	for i := 0; i < poolSize; i++ {
+		client := apns2.NewTokenClient(tk)
+		if dev {
+			client = client.Development()
+		} else {
+			client = client.Production()
+		}
+		clients = append(clients, client)
+	}
+	return &APNsTokenPush{
+		topic:   topic,
+		clients: clients,
+		Log:     log.NewTLog("APNsTokenPush"),
+	}, nil
+}
+
+// GetPayload 获取推送负载
+func (p *APNsTokenPush) GetPayload(msg msgOfflineNotify, ctx *config.Context, toUser *user.Resp) (Payload, error) {
+	pushInfo, err := ParsePushInfo(msg, ctx, toUser)
+	if err != nil {
+		return nil, err
+	}
+	return NewAPNsTokenPayload(pushInfo, apnsCollapseID(msg, pushInfo)), nil
+}
+
+// apnsCollapseID 同一条消息重复推送时只显示一条 同一个音视频呼叫的取消会替换邀请 开启合并推送时同一个会话只显示一条
+func apnsCollapseID(msg msgOfflineNotify, pushInfo *PayloadInfo) string {
+	if pushInfo.IsVideoCall {
+		if pushInfo.CallID != "" {
+			return fmt.Sprintf("rtc_%s", pushInfo.CallID)
+		}
+		return fmt.Sprintf("rtc_%s", pushInfo.FromUID)
+	}
+	if msg.collapseKey != "" {
+		return msg.collapseKey
+	}
+	if msg.MessageID != 0 {
+		return fmt.Sprintf("%d", msg.MessageID)
+	}
+	return ""
+}
+
+// Push iOS推送
+func (p *APNsTokenPush) Push(deviceToken string, payload Payload) error {
+	notification := newAPNsTokenNotification(deviceToken, p.topic, payload.(*APNsTokenPayload))
+	res, err := p.client().Push(notification)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != apns2.StatusSent {
+		p.Warn("iOS推送失败！", zap.Int("status", res.StatusCode), zap.String("reason", res.Reason), zap.String("apnsID", res.ApnsID))
+		if isAPNsInvalidToken(res) {
+			return fmt.Errorf("%w[%s]", ErrInvalidDeviceToken, res.Reason)
+		}
+		return fmt.Errorf("iOS推送返回错误！[%d] %s", res.StatusCode, res.Reason)
+	}
+	return nil
+}
+
+// PushVoIP 来电邀请使用PushKit推送 topic为bundleID加.voip 客户端收到后需要立即通过CallKit显示来电
+func (p *APNsTokenPush) PushVoIP(voipToken string, payload Payload) error {
+	res, err := p.client().Push(newAPNsVoIPNotification(voipToken, p.topic, payload))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != apns2.StatusSent {
+		p.Warn("iOS VoIP推送失败！", zap.Int("status", res.StatusCode), zap.String("reason", res.Reason), zap.String("apnsID", res.ApnsID))
+		if isAPNsInvalidToken(res) {
+			return fmt.Errorf("%w[%s]", ErrInvalidDeviceToken, res.Reason)
+		}
+		return fmt.Errorf("iOS VoIP推送返回错误！[%d] %s", res.StatusCode, res.Reason)
+	}
+	return nil
+}
+
+func newAPNsVoIPNotification(voipToken string, topic string, payload Payload) *apns2.Notification {
+	callPayload := map[string]interface{}{
+		"title":   payload.GetTitle(),
+		"content": payload.GetContent(),
+	}
+	if rtcPayload := payload.GetRTCPayload(); rtcPayload != nil {
+		callPayload["call_id"] = rtcPayload.GetCallID()
+		callPayload["call_type"] = rtcPayload.GetCallType()
+		callPayload["operation"] = rtcPayload.GetOperation()
+		callPayload["from_uid"] = rtcPayload.GetFromUID()
+	}
+	return &apns2.Notification{
+		DeviceToken: voipToken,
+		Topic:       topic + ".voip",
+		PushType:    apns2.PushTypeVOIP,
+		Priority:    apns2.PriorityHigh,
+		Expiration:  time.Now().Add(apnsRTCExpiration),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"aps":  map[string]interface{}{},
+			"call": callPayload,
+		})),
+	}
+}
+
+func (p *APNsTokenPush) client() *apns2.Client {
+	n := atomic.AddUint32(&p.next, 1)
+	return p.clients[n%uint32(len(p.clients))]
+}
+
+func newAPNsTokenNotification(deviceToken string, topic string, payload *APNsTokenPayload) *apns2.Notification {
+	notification := &apns2.Notification{
+		DeviceToken: deviceToken,
+		Topic:       topic,
+		CollapseID:  payload.collapseID,
+		Payload:     iosNotificationPayload(payload),
+	}
+	if payload.GetRTCPayload() != nil {
+		notification.PushType = apns2.PushTypeBackground
+		notification.Priority = apns2.PriorityLow
+		notification.Expiration = time.Now().Add(apnsRTCExpiration)
+	} else {
+		notification.PushType = apns2.PushTypeAlert
+		notification.Priority = apns2.PriorityHigh
+	}
+	return notification
+}
+
+// isAPNsInvalidToken 设备token已失效 需要删除设备的注册信息
+func isAPNsInvalidToken(res *apns2.Response) bool {
+	switch res.Reason {
+	case apns2.ReasonUnregistered, apns2.ReasonBadDeviceToken, apns2.ReasonDeviceTokenNotForTopic:
+		return true
+	}
+	return res.StatusCode == 410
+}
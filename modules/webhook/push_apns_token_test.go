@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sideshow/apns2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPNsTokenPush(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "AuthKey.p8")
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}), 0600)
+	assert.NoError(t, err)
+
+	p, err := NewAPNsTokenPush("com.xinbida.tangsengdaodao", true, keyPath, "keyID", "teamID", 3)
+	assert.NoError(t, err)
+	assert.Len(t, p.clients, 3)
+	assert.Equal(t, apns2.HostDevelopment, p.clients[0].Host)
+	// 轮流使用连接
+	assert.NotSame(t, p.client(), p.client())
+
+	_, err = NewAPNsTokenPush("com.xinbida.tangsengdaodao", false, filepath.Join(t.TempDir(), "none.p8"), "keyID", "teamID", 1)
+	assert.Error(t, err)
+}
+
+func TestNewAPNsTokenNotification(t *testing.T) {
+	payload := NewAPNsTokenPayload(&PayloadInfo{
+		Title:   "title",
+		Content: "content",
+		Badge:   1,
+	}, "1001")
+	notification := newAPNsTokenNotification("deviceToken", "topic", payload)
+	assert.Equal(t, "1001", notification.CollapseID)
+	assert.Equal(t, apns2.PushTypeAlert, notification.PushType)
+	assert.Equal(t, apns2.PriorityHigh, notification.Priority)
+
+	payload = NewAPNsTokenPayload(&PayloadInfo{
+		IsVideoCall: true,
+		FromUID:     "u1",
+		Operation:   "invite",
+	}, "rtc_u1")
+	notification = newAPNsTokenNotification("deviceToken", "topic", payload)
+	assert.Equal(t, apns2.PushTypeBackground, notification.PushType)
+	assert.Equal(t, apns2.PriorityLow, notification.Priority)
+	assert.False(t, notification.Expiration.IsZero())
+
+	payload = NewAPNsTokenPayload(&PayloadInfo{}, strings.Repeat("a", 100))
+	assert.Len(t, payload.collapseID, apnsCollapseIDMaxLen)
+}
+
+func TestAPNsCollapseID(t *testing.T) {
+	msg := msgOfflineNotify{}
+	msg.MessageID = 1001
+	assert.Equal(t, "1001", apnsCollapseID(msg, &PayloadInfo{}))
+	assert.Equal(t, "rtc_u1", apnsCollapseID(msg, &PayloadInfo{IsVideoCall: true, FromUID: "u1"}))
+	assert.Equal(t, "", apnsCollapseID(msgOfflineNotify{}, &PayloadInfo{}))
+}
+
+func TestIsAPNsInvalidToken(t *testing.T) {
+	assert.True(t, isAPNsInvalidToken(&apns2.Response{StatusCode: 410, Reason: apns2.ReasonUnregistered}))
+	assert.True(t, isAPNsInvalidToken(&apns2.Response{StatusCode: 400, Reason: apns2.ReasonBadDeviceToken}))
+	assert.True(t, isAPNsInvalidToken(&apns2.Response{StatusCode: 400, Reason: apns2.ReasonDeviceTokenNotForTopic}))
+	assert.False(t, isAPNsInvalidToken(&apns2.Response{StatusCode: 429, Reason: apns2.ReasonTooManyRequests}))
+	assert.False(t, isAPNsInvalidToken(&apns2.Response{StatusCode: 403, Reason: apns2.ReasonExpiredProviderToken}))
+}
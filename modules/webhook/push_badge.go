@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// computeBadge 计算用户的真实未读数 免打扰和不通知的会话不计入
+func (w *Webhook) computeBadge(uid string) (int, error) {
+	conversations, err := w.ctx.IMGetConversations(uid)
+	if err != nil {
+		return 0, err
+	}
+	personUIDs := make([]string, 0)
+	groupNos := make([]string, 0)
+	for _, conversation := range conversations {
+		if conversation.Unread <= 0 {
+			continue
+		}
+		if conversation.ChannelType == common.ChannelTypePerson.Uint8() {
+			personUIDs = append(personUIDs, conversation.ChannelID)
+		} else if conversation.ChannelType == common.ChannelTypeGroup.Uint8() {
+			groupNos = append(groupNos, conversation.ChannelID)
+		}
+	}
+	muted := map[string]bool{}
+	if len(personUIDs) > 0 {
+		userSettings, err := w.userService.GetUserSettings(personUIDs, uid)
+		if err != nil {
+			return 0, err
+		}
+		for _, userSetting := range userSettings {
+			if userSetting.Mute == 1 {
+				muted[badgeChannelKey(userSetting.UID, common.ChannelTypePerson.Uint8())] = true
+			}
+		}
+	}
+	if len(groupNos) > 0 {
+		groupSettings, err := w.groupService.GetSettings(groupNos, uid)
+		if err != nil {
+			return 0, err
+		}
+		for _, groupSetting := range groupSettings {
+			if groupSetting.Mute == 1 || groupSetting.NotifyLevel == group.NotifyLevelNone {
+				muted[badgeChannelKey(groupSetting.GroupNo, common.ChannelTypeGroup.Uint8())] = true
+			}
+		}
+	}
+	return sumBadge(conversations, muted), nil
+}
+
+// sumBadge 未读数的合计 muted为免打扰的会话
+func sumBadge(conversations []*config.ConversationResp, muted map[string]bool) int {
+	var badge int64
+	for _, conversation := range conversations {
+		if conversation.Unread <= 0 || muted[badgeChannelKey(conversation.ChannelID, conversation.ChannelType)] {
+			continue
+		}
+		badge += conversation.Unread
+	}
+	return int(badge)
+}
+
+func badgeChannelKey(channelID string, channelType uint8) string {
+	return fmt.Sprintf("%s_%d", channelID, channelType)
+}
+
+// pushBadge 推送使用的红点 IM可能还没有累加正在推送的消息的未读数 所以消息推送的红点至少为1
+func pushBadge(badge int, msgResp msgOfflineNotify) int {
+	if badge < 1 && msgResp.call == nil {
+		return 1
+	}
+	return badge
+}
+
+// syncBadge 计算用户的未读数并保存 之后上传或累加红点都以此为准
+func (w *Webhook) syncBadge(uid string) (int, error) {
+	badge, err := w.computeBadge(uid)
+	if err != nil {
+		return 0, err
+	}
+	if err = w.ctx.GetRedisConn().Hset(common.UserDeviceBadgePrefix, uid, fmt.Sprintf("%d", badge)); err != nil {
+		return 0, err
+	}
+	return badge, nil
+}
+
+// 客户端阅读消息后调用 重新计算红点数
+func (w *Webhook) badgeReconcile(c *wkhttp.Context) {
+	badge, err := w.syncBadge(c.GetLoginUID())
+	if err != nil {
+		w.Error("计算红点数失败！", zap.Error(err), zap.String("uid", c.GetLoginUID()))
+		c.ResponseError(errors.New("计算红点数失败！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"badge": badge,
+	})
+}
@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSumBadge(t *testing.T) {
+	conversations := []*config.ConversationResp{
+		{ChannelID: "u1", ChannelType: common.ChannelTypePerson.Uint8(), Unread: 3},
+		{ChannelID: "u2", ChannelType: common.ChannelTypePerson.Uint8(), Unread: 2},
+		{ChannelID: "g1", ChannelType: common.ChannelTypeGroup.Uint8(), Unread: 10},
+		{ChannelID: "g2", ChannelType: common.ChannelTypeGroup.Uint8(), Unread: 0},
+	}
+	assert.Equal(t, 15, sumBadge(conversations, nil))
+	assert.Equal(t, 3, sumBadge(conversations, map[string]bool{
+		badgeChannelKey("u2", common.ChannelTypePerson.Uint8()): true,
+		badgeChannelKey("g1", common.ChannelTypeGroup.Uint8()):  true,
+	}))
+	// 个人会话和群的id相同时互不影响
+	assert.Equal(t, 12, sumBadge(conversations, map[string]bool{
+		badgeChannelKey("u1", common.ChannelTypeGroup.Uint8()):  true,
+		badgeChannelKey("u1", common.ChannelTypePerson.Uint8()): true,
+	}))
+}
+
+func TestPushBadge(t *testing.T) {
+	assert.Equal(t, 5, pushBadge(5, msgOfflineNotify{}))
+	assert.Equal(t, 1, pushBadge(0, msgOfflineNotify{}))
+	assert.Equal(t, 0, pushBadge(0, msgOfflineNotify{call: &callInfo{}}))
+}
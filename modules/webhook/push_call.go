@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// pushCallPrefix 等待接听的来电 存在时重试推送邀请
+const pushCallPrefix = "pushCall:"
+
+// 音视频推送的操作
+const (
+	callOperationInvite = "invite"
+	callOperationCancel = "cancel"
+)
+
+// callCmdOperations 音视频信令对应的推送操作
+var callCmdOperations = map[string]string{
+	"rtc.p2p.invoke": callOperationInvite,
+	"room.invoke":    callOperationInvite,
+	"rtc.p2p.cancel": callOperationCancel,
+	"rtc.p2p.hangup": callOperationCancel,
+	"room.cancel":    callOperationCancel,
+}
+
+// callAnsweredCmds 被叫方处理了来电的信令 收到后不再重试邀请
+var callAnsweredCmds = map[string]bool{
+	"rtc.p2p.accept": true,
+	"rtc.p2p.refuse": true,
+	"room.join":      true,
+	"room.refuse":    true,
+}
+
+// callInfo 音视频信令中的来电信息
+type callInfo struct {
+	operation string             // invite或cancel
+	callID    string             // 单聊为主叫的uid 多人通话为房间id
+	fromUID   string             // 主叫的uid
+	callType  common.RTCCallType // 语音或视频
+}
+
+// parseCall 解析音视频信令 不是来电相关的信令返回nil
+func parseCall(cmd string, payloadMap map[string]interface{}, fromUID string) *callInfo {
+	operation := callCmdOperations[cmd]
+	if operation == "" {
+		return nil
+	}
+	call := &callInfo{
+		operation: operation,
+		callID:    fromUID,
+		fromUID:   fromUID,
+	}
+	param, _ := payloadMap["param"].(map[string]interface{})
+	if roomID, _ := param["room_id"].(string); roomID != "" {
+		call.callID = roomID
+	}
+	if callType, ok := param["call_type"].(json.Number); ok {
+		callTypeI64, _ := callType.Int64()
+		call.callType = common.RTCCallType(callTypeI64)
+	}
+	return call
+}
+
+// callAnswered 解析被叫方处理来电的信令 返回被叫方的uid和来电id
+func callAnswered(message MsgResp) (string, string, bool) {
+	if config.SettingFromUint8(message.Setting).Signal {
+		// 加密的消息无法解析
+		return "", "", false
+	}
+	payloadMap, err := util.JsonToMap(string(message.Payload))
+	if err != nil {
+		return "", "", false
+	}
+	cmd, _ := payloadMap["cmd"].(string)
+	if !callAnsweredCmds[cmd] {
+		return "", "", false
+	}
+	callID := message.ChannelID
+	param, _ := payloadMap["param"].(map[string]interface{})
+	if roomID, _ := param["room_id"].(string); roomID != "" {
+		callID = roomID
+	}
+	return message.FromUID, callID, true
+}
+
+func pushCallKey(toUID string, callID string) string {
+	return fmt.Sprintf("%s%s:%s", pushCallPrefix, toUID, callID)
+}
+
+// pushCall 来电推送
+// 邀请在来电有效期内按间隔重试 直到被叫方接听、拒绝或主叫方取消 取消时推送取消通知给被叫方
+func (w *Webhook) pushCall(toUser *user.Resp, msgResp msgOfflineNotify) {
+	call := msgResp.call
+	if call == nil {
+		w.pushAndLog(toUser, msgResp)
+		return
+	}
+	callCfg := extconfig.Get().Push.Call
+	key := pushCallKey(toUser.UID, call.callID)
+	if call.operation == callOperationCancel {
+		if err := w.ctx.GetRedisConn().Del(key); err != nil {
+			w.Warn("删除等待接听的来电失败！", zap.Error(err), zap.String("uid", toUser.UID))
+		}
+		w.pushAndLog(toUser, msgResp)
+		return
+	}
+	if err := w.ctx.GetRedisConn().SetAndExpire(key, "1", callCfg.RingTimeout); err != nil {
+		w.Warn("保存等待接听的来电失败！", zap.Error(err), zap.String("uid", toUser.UID))
+		w.pushAndLog(toUser, msgResp)
+		return
+	}
+	w.pushAndLog(toUser, msgResp)
+	w.retryCall(key, toUser, msgResp, callCfg.MaxRetries, callCfg.RetryInterval)
+}
+
+// retryCall 来电还在等待接听时重试推送
+func (w *Webhook) retryCall(key string, toUser *user.Resp, msgResp msgOfflineNotify, remain int, interval time.Duration) {
+	if remain <= 0 || interval <= 0 {
+		return
+	}
+	time.AfterFunc(interval, func() {
+		waiting, err := w.ctx.GetRedisConn().GetString(key)
+		if err != nil {
+			w.Warn("查询等待接听的来电失败！", zap.Error(err), zap.String("uid", toUser.UID))
+			return
+		}
+		if waiting == "" {
+			// 已接听、拒绝、取消或超时
+			return
+		}
+		w.Debug("重试来电推送", zap.String("uid", toUser.UID), zap.String("callID", msgResp.call.callID))
+		w.pushAndLog(toUser, msgResp)
+		w.retryCall(key, toUser, msgResp, remain-1, interval)
+	})
+}
+
+// handleCallAnswered 被叫方处理了来电 停止重试
+func (w *Webhook) handleCallAnswered(message MsgResp) {
+	uid, callID, ok := callAnswered(message)
+	if !ok {
+		return
+	}
+	if err := w.ctx.GetRedisConn().Del(pushCallKey(uid, callID)); err != nil {
+		w.Warn("删除等待接听的来电失败！", zap.Error(err), zap.String("uid", uid))
+	}
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/sideshow/apns2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCall(t *testing.T) {
+	payloadMap, err := util.JsonToMap(`{"cmd":"rtc.p2p.invoke","param":{"call_type":1}}`)
+	assert.NoError(t, err)
+	call := parseCall("rtc.p2p.invoke", payloadMap, "u1")
+	assert.Equal(t, &callInfo{
+		operation: callOperationInvite,
+		callID:    "u1",
+		fromUID:   "u1",
+		callType:  common.RTCCallType(1),
+	}, call)
+
+	// 多人通话使用房间id
+	payloadMap = map[string]interface{}{
+		"param": map[string]interface{}{"room_id": "room1"},
+	}
+	call = parseCall("room.cancel", payloadMap, "u1")
+	assert.Equal(t, callOperationCancel, call.operation)
+	assert.Equal(t, "room1", call.callID)
+
+	assert.Nil(t, parseCall("rtc.p2p.accept", payloadMap, "u1"))
+}
+
+func TestCallAnswered(t *testing.T) {
+	uid, callID, ok := callAnswered(MsgResp{
+		FromUID:   "u2",
+		ChannelID: "u1",
+		Payload:   []byte(`{"cmd":"rtc.p2p.accept"}`),
+	})
+	assert.True(t, ok)
+	assert.Equal(t, "u2", uid)
+	assert.Equal(t, "u1", callID)
+
+	_, callID, ok = callAnswered(MsgResp{
+		FromUID: "u2",
+		Payload: []byte(`{"cmd":"room.join","param":{"room_id":"room1"}}`),
+	})
+	assert.True(t, ok)
+	assert.Equal(t, "room1", callID)
+
+	_, _, ok = callAnswered(MsgResp{
+		FromUID: "u2",
+		Payload: []byte(`{"type":1,"content":"hello"}`),
+	})
+	assert.False(t, ok)
+}
+
+func TestNewAPNsVoIPNotification(t *testing.T) {
+	payload := (&PayloadInfo{
+		Title:       "title",
+		Content:     "content",
+		IsVideoCall: true,
+		FromUID:     "u1",
+		Operation:   callOperationInvite,
+		CallID:      "u1",
+	}).toPayload()
+	notification := newAPNsVoIPNotification("voipToken", "com.xinbida.tangsengdaodao", payload)
+	assert.Equal(t, "com.xinbida.tangsengdaodao.voip", notification.Topic)
+	assert.Equal(t, apns2.PushTypeVOIP, notification.PushType)
+	assert.Equal(t, apns2.PriorityHigh, notification.Priority)
+
+	var body map[string]map[string]interface{}
+	assert.NoError(t, json.Unmarshal(notification.Payload.([]byte), &body))
+	assert.Equal(t, "u1", body["call"]["call_id"])
+	assert.Equal(t, callOperationInvite, body["call"]["operation"])
+}
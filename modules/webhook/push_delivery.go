@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// pushProviderWeb 浏览器推送的通道名
+const pushProviderWeb = "WEB"
+
+const (
+	pushMetricsResultSuccess      = "success"
+	pushMetricsResultFailed       = "failed"
+	pushMetricsResultInvalidToken = "invalid_token"
+)
+
+var (
+	// pushAttemptTotal 推送尝试次数（按通道和结果） 包含重试
+	pushAttemptTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_push_attempt_total",
+		Help: "推送尝试次数",
+	}, []string{"provider", "result"})
+	// pushDeliveryTotal 推送的最终结果（按通道和结果） 重试后成功的算成功
+	pushDeliveryTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_push_delivery_total",
+		Help: "推送的最终结果",
+	}, []string{"provider", "result"})
+	// pushRetryTotal 推送重试次数
+	pushRetryTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_push_retry_total",
+		Help: "推送重试次数",
+	}, []string{"provider"})
+	// pushDuration 调用推送接口的耗时
+	pushDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tsdd_push_duration_seconds",
+		Help:    "调用推送接口的耗时",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+	}, []string{"provider"})
+)
+
+// invalidTokenCodes 厂商返回的表示token已失效（应用已卸载或token已过期）的错误码
+var invalidTokenCodes = map[common.DeviceType][]string{
+	common.DeviceTypeHMS:  {"80300007"}, // 所有token都无效
+	common.DeviceTypeMI:   {"20301"},    // regId无效
+	common.DeviceTypeOPPO: {"10000"},    // registration_id无效
+	common.DeviceTypeVIVO: {"10302"},    // regId无效
+}
+
+// vendorPushError 厂商推送接口返回的错误 错误码表示token已失效时返回ErrInvalidDeviceToken
+func vendorPushError(deviceType common.DeviceType, code string, msg string) error {
+	if isInvalidTokenCode(deviceType, code) {
+		return fmt.Errorf("%w[%s] %s", ErrInvalidDeviceToken, code, msg)
+	}
+	return fmt.Errorf("[%s] %s", code, msg)
+}
+
+// isInvalidTokenCode 优先使用内置的错误码 再使用配置的错误码
+func isInvalidTokenCode(deviceType common.DeviceType, code string) bool {
+	if code == "" {
+		return false
+	}
+	for _, c := range invalidTokenCodes[deviceType] {
+		if c == code {
+			return true
+		}
+	}
+	for _, c := range extconfig.Get().Push.Delivery.InvalidTokenCodes[string(deviceType)] {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// pushDelivery 一次推送
+type pushDelivery struct {
+	provider    string       // 推送通道 设备类型或WEB
+	uid         string       // 接收推送的用户
+	deviceToken string       // 设备token 浏览器推送为endpoint
+	messageID   int64        // 消息ID
+	retry       bool         // 失败时是否重试 来电邀请有单独的重试
+	send        func() error // 调用推送接口
+	onInvalid   func()       // token已失效时调用 删除设备的注册信息
+	onDone      func(error)  // 得到最终结果（成功、token失效或重试后仍然失败）时调用
+}
+
+// deliver 推送 第一次同步推送并返回结果 失败时在后台按退避间隔重试 token失效不重试
+func (w *Webhook) deliver(d *pushDelivery) error {
+	return w.deliverAttempt(d, 1)
+}
+
+func (w *Webhook) deliverAttempt(d *pushDelivery, attempt int) error {
+	deliveryCfg := extconfig.Get().Push.Delivery
+	start := time.Now()
+	err := d.send()
+	w.recordPush(d, attempt, err, time.Since(start))
+	if err == nil {
+		pushDeliveryTotal.WithLabelValues(d.provider, pushMetricsResultSuccess).Inc()
+		d.done(nil)
+		return nil
+	}
+	if errors.Is(err, ErrInvalidDeviceToken) {
+		pushDeliveryTotal.WithLabelValues(d.provider, pushMetricsResultInvalidToken).Inc()
+		if d.onInvalid != nil {
+			d.onInvalid()
+		}
+		d.done(err)
+		return err
+	}
+	if !d.retry || attempt >= deliveryCfg.MaxAttempts {
+		pushDeliveryTotal.WithLabelValues(d.provider, pushMetricsResultFailed).Inc()
+		if attempt > 1 {
+			w.Warn("推送重试后仍然失败！", zap.Error(err), zap.String("uid", d.uid), zap.String("provider", d.provider), zap.Int("attempt", attempt))
+		}
+		d.done(err)
+		return err
+	}
+	pushRetryTotal.WithLabelValues(d.provider).Inc()
+	time.AfterFunc(pushRetryBackoff(attempt, deliveryCfg.RetryBackoff, deliveryCfg.MaxBackoff), func() {
+		_ = w.deliverAttempt(d, attempt+1)
+	})
+	return err
+}
+
+func (d *pushDelivery) done(err error) {
+	if d.onDone != nil {
+		d.onDone(err)
+	}
+}
+
+// pushRetryBackoff 第attempt次失败后的重试间隔 每次翻倍 不超过maxBackoff
+func pushRetryBackoff(attempt int, backoff time.Duration, maxBackoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if maxBackoff > 0 && backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	if maxBackoff > 0 && backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+// recordPush 记录推送尝试的结果
+func (w *Webhook) recordPush(d *pushDelivery, attempt int, err error, latency time.Duration) {
+	status := pushLogStatusSuccess
+	result := pushMetricsResultSuccess
+	var errMsg string
+	if err != nil {
+		status = pushLogStatusFailed
+		result = pushMetricsResultFailed
+		if errors.Is(err, ErrInvalidDeviceToken) {
+			status = pushLogStatusInvalidToken
+			result = pushMetricsResultInvalidToken
+		}
+		errMsg = truncateRunes(err.Error(), 500)
+	}
+	pushAttemptTotal.WithLabelValues(d.provider, result).Inc()
+	pushDuration.WithLabelValues(d.provider).Observe(latency.Seconds())
+
+	if !extconfig.Get().Push.Delivery.Log || w.pushLogDB == nil {
+		return
+	}
+	err = w.pushLogDB.insert(&pushLogModel{
+		UID:         d.uid,
+		Provider:    d.provider,
+		DeviceToken: truncateRunes(d.deviceToken, 1000),
+		MessageID:   d.messageID,
+		Attempt:     attempt,
+		Status:      status,
+		ErrMsg:      errMsg,
+		Latency:     latency.Milliseconds(),
+	})
+	if err != nil {
+		w.Warn("保存推送记录失败！", zap.Error(err), zap.String("uid", d.uid))
+	}
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
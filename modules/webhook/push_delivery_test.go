@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func configureDelivery(t *testing.T, values map[string]interface{}) {
+	vp := viper.New()
+	for k, v := range values {
+		vp.Set(k, v)
+	}
+	extconfig.Configure(vp)
+	t.Cleanup(func() {
+		extconfig.Configure(viper.New())
+	})
+}
+
+func TestVendorPushError(t *testing.T) {
+	configureDelivery(t, map[string]interface{}{
+		"push.delivery.invalidTokenCodes": map[string]interface{}{
+			"oppo": []string{"10001"},
+		},
+	})
+	assert.True(t, errors.Is(vendorPushError(common.DeviceTypeHMS, "80300007", "All the tokens are invalid"), ErrInvalidDeviceToken))
+	assert.True(t, errors.Is(vendorPushError(common.DeviceTypeOPPO, "10001", "invalid"), ErrInvalidDeviceToken))
+
+	err := vendorPushError(common.DeviceTypeHMS, "80000001", "system error")
+	assert.False(t, errors.Is(err, ErrInvalidDeviceToken))
+	assert.Contains(t, err.Error(), "system error")
+}
+
+func TestPushRetryBackoff(t *testing.T) {
+	assert.Equal(t, time.Second*2, pushRetryBackoff(1, time.Second*2, time.Second*30))
+	assert.Equal(t, time.Second*8, pushRetryBackoff(3, time.Second*2, time.Second*30))
+	assert.Equal(t, time.Second*30, pushRetryBackoff(10, time.Second*2, time.Second*30))
+	assert.Equal(t, time.Second, pushRetryBackoff(1, 0, 0))
+}
+
+func TestDeliverRetry(t *testing.T) {
+	configureDelivery(t, map[string]interface{}{
+		"push.delivery.maxAttempts":  3,
+		"push.delivery.retryBackoff": "10ms",
+	})
+	w := &Webhook{Log: log.NewTLog("test")}
+
+	attempts := make(chan int, 3)
+	count := 0
+	err := w.deliver(&pushDelivery{
+		provider: "MI",
+		retry:    true,
+		send: func() error {
+			count++
+			attempt := count
+			attempts <- attempt
+			if attempt < 3 {
+				return errors.New("timeout")
+			}
+			return nil
+		},
+	})
+	assert.Error(t, err)
+	for i := 1; i <= 3; i++ {
+		select {
+		case attempt := <-attempts:
+			assert.Equal(t, i, attempt)
+		case <-time.After(time.Second):
+			t.Fatal("没有重试推送")
+		}
+	}
+
+	// token已失效不重试
+	invalid := false
+	invalidCount := 0
+	err = w.deliver(&pushDelivery{
+		provider: "HMS",
+		retry:    true,
+		send: func() error {
+			invalidCount++
+			return vendorPushError(common.DeviceTypeHMS, "80300007", "All the tokens are invalid")
+		},
+		onInvalid: func() {
+			invalid = true
+		},
+	})
+	assert.True(t, errors.Is(err, ErrInvalidDeviceToken))
+	assert.True(t, invalid)
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, 1, invalidCount)
+}
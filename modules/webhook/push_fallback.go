@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+const (
+	// pushChannelHealthPrefix 设备的厂商推送通道健康状态 hash uid => {device_token,failures,fallback_until}
+	pushChannelHealthPrefix = "pushChannelHealth:"
+	// pushChannelStatsKey 推送通道切换的统计 hash {通道}:{事件} => 次数
+	pushChannelStatsKey = "pushChannelStats"
+)
+
+// 厂商通道连续失败后切换到的通道
+const (
+	pushFallbackFCM   = "FCM"   // 设备上报了fcm_token时使用FCM推送
+	pushFallbackInApp = "INAPP" // 没有可用的通道 只通过应用内（长连接）送达
+)
+
+// pushChannelRecovered 切换后厂商通道恢复正常
+const pushChannelRecovered = "RECOVERED"
+
+// pushChannelSwitchTotal 推送通道切换次数
+var pushChannelSwitchTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tsdd_push_channel_switch_total",
+	Help: "推送通道切换次数",
+}, []string{"from", "to"})
+
+// pushChannelHealth 设备的厂商推送通道健康状态
+type pushChannelHealth struct {
+	deviceToken   string // 状态对应的token 设备重新注册了token后状态失效
+	failures      int    // 连续失败的次数
+	fallbackUntil int64  // 在此时间（秒）之前使用备用通道
+}
+
+func parsePushChannelHealth(healthMap map[string]string) pushChannelHealth {
+	failures, _ := strconv.Atoi(healthMap["failures"])
+	fallbackUntil, _ := strconv.ParseInt(healthMap["fallback_until"], 10, 64)
+	return pushChannelHealth{
+		deviceToken:   healthMap["device_token"],
+		failures:      failures,
+		fallbackUntil: fallbackUntil,
+	}
+}
+
+// forToken 设备的token变化后之前的状态不再有效
+func (h pushChannelHealth) forToken(deviceToken string) pushChannelHealth {
+	if h.deviceToken != deviceToken {
+		return pushChannelHealth{deviceToken: deviceToken}
+	}
+	return h
+}
+
+// inFallback 是否正在使用备用通道 到期后重新尝试厂商通道
+func (h pushChannelHealth) inFallback(now time.Time) bool {
+	return h.fallbackUntil > now.Unix()
+}
+
+// supportFallback 只有android的厂商通道可以切换 iOS和FCM本身没有备用通道
+func supportFallback(deviceType string) bool {
+	if !extconfig.Get().Push.Fallback.On {
+		return false
+	}
+	switch common.DeviceType(deviceType) {
+	case common.DeviceTypeIOS, common.DeviceTypeFirebase:
+		return false
+	}
+	for _, fcmDeviceType := range extconfig.Get().Push.FCM.DeviceTypes {
+		if strings.EqualFold(fcmDeviceType, deviceType) {
+			return false
+		}
+	}
+	return true
+}
+
+// pushFallbackChannel 厂商通道不可用时使用的通道
+func (w *Webhook) pushFallbackChannel(deviceMap map[string]string) string {
+	if w.getFallbackPush() != nil && deviceMap["fcm_token"] != "" {
+		return pushFallbackFCM
+	}
+	return pushFallbackInApp
+}
+
+func (w *Webhook) getPushChannelHealth(uid string, deviceToken string) pushChannelHealth {
+	healthMap, err := w.ctx.GetRedisConn().Hgetall(fmt.Sprintf("%s%s", pushChannelHealthPrefix, uid))
+	if err != nil {
+		w.Warn("查询推送通道健康状态失败！", zap.Error(err), zap.String("uid", uid))
+		return pushChannelHealth{deviceToken: deviceToken}
+	}
+	return parsePushChannelHealth(healthMap).forToken(deviceToken)
+}
+
+// updatePushChannelHealth 记录厂商通道的推送结果 连续失败达到阈值后在一段时间内使用备用通道
+func (w *Webhook) updatePushChannelHealth(uid string, deviceType string, health pushChannelHealth, fallback string, pushErr error) {
+	fallbackCfg := extconfig.Get().Push.Fallback
+	key := fmt.Sprintf("%s%s", pushChannelHealthPrefix, uid)
+	if pushErr == nil {
+		if health.failures == 0 {
+			return
+		}
+		if health.failures >= fallbackCfg.FailureThreshold {
+			w.incrPushChannelStats(deviceType, pushChannelRecovered)
+			w.Info("厂商推送通道已恢复", zap.String("uid", uid), zap.String("deviceType", deviceType))
+		}
+		if err := w.ctx.GetRedisConn().Del(key); err != nil {
+			w.Warn("重置推送通道健康状态失败！", zap.Error(err), zap.String("uid", uid))
+		}
+		return
+	}
+	if errors.Is(pushErr, ErrInvalidDeviceToken) {
+		// token失效会删除设备注册信息 不是通道的问题
+		return
+	}
+	health.failures++
+	fieldValues := []string{"device_token", health.deviceToken, "failures", strconv.Itoa(health.failures)}
+	if health.failures >= fallbackCfg.FailureThreshold {
+		// 保留失败次数 到期后重新尝试厂商通道仍然失败时立即切换
+		health.fallbackUntil = time.Now().Add(fallbackCfg.Duration).Unix()
+		fieldValues = append(fieldValues, "fallback_until", strconv.FormatInt(health.fallbackUntil, 10))
+		w.incrPushChannelStats(deviceType, fallback)
+		w.Warn("厂商推送通道连续失败，切换推送通道", zap.String("uid", uid), zap.String("deviceType", deviceType), zap.String("fallback", fallback), zap.Int("failures", health.failures))
+	}
+	if err := w.ctx.GetRedisConn().Hmset(key, fieldValues...); err != nil {
+		w.Warn("保存推送通道健康状态失败！", zap.Error(err), zap.String("uid", uid))
+		return
+	}
+	if err := w.ctx.GetRedisConn().Expire(key, pushChannelHealthExpire(fallbackCfg.Duration)); err != nil {
+		w.Warn("设置推送通道健康状态过期时间失败！", zap.Error(err), zap.String("uid", uid))
+	}
+}
+
+// pushChannelHealthExpire 长时间没有推送的设备不保留状态
+func pushChannelHealthExpire(fallbackDuration time.Duration) time.Duration {
+	if fallbackDuration < time.Hour*12 {
+		return time.Hour * 24
+	}
+	return fallbackDuration * 2
+}
+
+func (w *Webhook) incrPushChannelStats(deviceType string, event string) {
+	pushChannelSwitchTotal.WithLabelValues(deviceType, event).Inc()
+	if _, err := w.ctx.GetRedisConn().Hincrby(pushChannelStatsKey, fmt.Sprintf("%s:%s", deviceType, event), 1); err != nil {
+		w.Warn("保存推送通道切换统计失败！", zap.Error(err), zap.String("deviceType", deviceType))
+	}
+}
+
+// pushChannelStatsResp 厂商推送通道的切换统计
+type pushChannelStatsResp struct {
+	DeviceType string `json:"device_type"` // 厂商通道
+	ToFCM      int64  `json:"to_fcm"`      // 切换到FCM的次数
+	ToInApp    int64  `json:"to_inapp"`    // 切换到应用内送达的次数
+	Recovered  int64  `json:"recovered"`   // 切换后恢复的次数
+}
+
+func toPushChannelStatsResps(statsMap map[string]string) []*pushChannelStatsResp {
+	respMap := map[string]*pushChannelStatsResp{}
+	resps := make([]*pushChannelStatsResp, 0)
+	for field, value := range statsMap {
+		deviceType, event, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		count, _ := strconv.ParseInt(value, 10, 64)
+		resp := respMap[deviceType]
+		if resp == nil {
+			resp = &pushChannelStatsResp{DeviceType: deviceType}
+			respMap[deviceType] = resp
+			resps = append(resps, resp)
+		}
+		switch event {
+		case pushFallbackFCM:
+			resp.ToFCM = count
+		case pushFallbackInApp:
+			resp.ToInApp = count
+		case pushChannelRecovered:
+			resp.Recovered = count
+		}
+	}
+	sort.Slice(resps, func(i, j int) bool {
+		return resps[i].DeviceType < resps[j].DeviceType
+	})
+	return resps
+}
+
+// 管理员查询推送通道的切换统计
+func (w *Webhook) managerPushChannelStats(c *wkhttp.Context) {
+	if err := rbac.Check(c, rbac.PermStatsRead); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	statsMap, err := w.ctx.GetRedisConn().Hgetall(pushChannelStatsKey)
+	if err != nil {
+		w.Error("查询推送通道切换统计失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询推送通道切换统计失败！"))
+		return
+	}
+	c.Response(toPushChannelStatsResps(statsMap))
+}
@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushChannelHealth(t *testing.T) {
+	now := time.Unix(1000, 0)
+	health := parsePushChannelHealth(map[string]string{
+		"device_token":   "token1",
+		"failures":       "3",
+		"fallback_until": "1600",
+	})
+	assert.Equal(t, 3, health.forToken("token1").failures)
+	assert.True(t, health.forToken("token1").inFallback(now))
+	assert.False(t, health.forToken("token1").inFallback(time.Unix(1600, 0)))
+
+	// 重新注册了token
+	assert.Equal(t, 0, health.forToken("token2").failures)
+	assert.False(t, health.forToken("token2").inFallback(now))
+
+	assert.False(t, parsePushChannelHealth(nil).forToken("token1").inFallback(now))
+}
+
+func TestSupportFallback(t *testing.T) {
+	configureDelivery(t, map[string]interface{}{
+		"push.fcm.deviceTypes": []string{"FIREBASE", "GOOGLE"},
+	})
+	assert.True(t, supportFallback("MI"))
+	assert.True(t, supportFallback("HMS"))
+	assert.False(t, supportFallback("IOS"))
+	assert.False(t, supportFallback("FIREBASE"))
+	assert.False(t, supportFallback("GOOGLE"))
+
+	configureDelivery(t, map[string]interface{}{
+		"push.fallback.on": false,
+	})
+	assert.False(t, supportFallback("MI"))
+}
+
+func TestPushFallbackChannel(t *testing.T) {
+	w := &Webhook{}
+	assert.Equal(t, pushFallbackInApp, w.pushFallbackChannel(map[string]string{"fcm_token": "fcm"}))
+	w.fallbackPush = &FCMPush{}
+	assert.Equal(t, pushFallbackInApp, w.pushFallbackChannel(map[string]string{}))
+	assert.Equal(t, pushFallbackFCM, w.pushFallbackChannel(map[string]string{"fcm_token": "fcm"}))
+}
+
+func TestToPushChannelStatsResps(t *testing.T) {
+	resps := toPushChannelStatsResps(map[string]string{
+		"MI:FCM":        "3",
+		"MI:RECOVERED":  "1",
+		"HMS:INAPP":     "2",
+		"invalid_field": "1",
+	})
+	assert.Len(t, resps, 2)
+	assert.Equal(t, &pushChannelStatsResp{DeviceType: "HMS", ToInApp: 2}, resps[0])
+	assert.Equal(t, &pushChannelStatsResp{DeviceType: "MI", ToFCM: 3, Recovered: 1}, resps[1])
+}
+
+func TestDeliverDone(t *testing.T) {
+	configureDelivery(t, map[string]interface{}{
+		"push.delivery.maxAttempts":  2,
+		"push.delivery.retryBackoff": "10ms",
+	})
+	w := &Webhook{Log: log.NewTLog("test")}
+
+	done := make(chan error, 1)
+	err := w.deliver(&pushDelivery{
+		provider: "MI",
+		retry:    true,
+		send: func() error {
+			return errors.New("timeout")
+		},
+		onDone: func(err error) {
+			done <- err
+		},
+	})
+	assert.Error(t, err)
+	select {
+	case err = <-done:
+		// 重试后仍然失败才算最终结果
+		assert.EqualError(t, err, "timeout")
+	case <-time.After(time.Second):
+		t.Fatal("没有得到最终结果")
+	}
+
+	err = w.deliver(&pushDelivery{
+		provider: "MI",
+		send: func() error {
+			return nil
+		},
+		onDone: func(err error) {
+			done <- err
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,216 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/tidwall/gjson"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// fcmScope FCM HTTP v1需要的授权范围
+	fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+	// fcmTopicPrefix deviceToken以此开头时推送到主题
+	fcmTopicPrefix = "/topics/"
+	// fcmRTCTTL 音视频邀请的有效期 过期后不再送达
+	fcmRTCTTL = "60s"
+	// fcmErrorUnregistered 设备token已失效
+	fcmErrorUnregistered = "UNREGISTERED"
+)
+
+// FCMPush Firebase Cloud Messaging HTTP v1推送 文档 https://firebase.google.com/docs/reference/fcm/rest/v1/projects.messages
+type FCMPush struct {
+	projectID   string // serviceAccountJson中的project_id值
+	packageName string // android包名
+	channelID   string // android通知渠道id 如果有则填写
+	sendURL     string
+	client      *http.Client // 通过serviceAccount签发JWT换取access token 过期后自动刷新
+	log.Log
+}
+
+// NewFCMPush NewFCMPush projectID为空时使用serviceAccountJson中的project_id
+func NewFCMPush(jsonPath string, projectID string, packageName string, channelID string) (*FCMPush, error) {
+	jsonData, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+	jwtConfig, err := google.JWTConfigFromJSON(jsonData, fcmScope)
+	if err != nil {
+		return nil, err
+	}
+	if projectID == "" {
+		projectID = gjson.GetBytes(jsonData, "project_id").String()
+	}
+	if projectID == "" {
+		return nil, errors.New("serviceAccount的JSON中没有project_id！")
+	}
+	client := jwtConfig.Client(context.Background())
+	client.Timeout = time.Second * 10
+	return &FCMPush{
+		projectID:   projectID,
+		packageName: packageName,
+		channelID:   channelID,
+		sendURL:     fmt.Sprintf("https://fcm.googleapis.com/v1/projects/%s/messages:send", projectID),
+		client:      client,
+		Log:         log.NewTLog("FCMPush"),
+	}, nil
+}
+
+// FCMPayload FCM负载
+type FCMPayload struct {
+	Payload
+	notifyID string
+}
+
+// NewFCMPayload NewFCMPayload
+func NewFCMPayload(payloadInfo *PayloadInfo, notifyID string) *FCMPayload {
+	return &FCMPayload{
+		Payload:  payloadInfo.toPayload(),
+		notifyID: notifyID,
+	}
+}
+
+// GetPayload 获取推送负载
+func (f *FCMPush) GetPayload(msg msgOfflineNotify, ctx *config.Context, toUser *user.Resp) (Payload, error) {
+	payloadInfo, err := ParsePushInfo(msg, ctx, toUser)
+	if err != nil {
+		return nil, err
+	}
+	return NewFCMPayload(payloadInfo, pushNotifyID(msg)), nil
+}
+
+// Push 推送 deviceToken为/topics/xxx时推送到主题
+func (f *FCMPush) Push(deviceToken string, payload Payload) error {
+	if strings.HasPrefix(deviceToken, fcmTopicPrefix) {
+		return f.PushTopic(strings.TrimPrefix(deviceToken, fcmTopicPrefix), payload)
+	}
+	return f.send(newFCMMessage("token", deviceToken, payload.(*FCMPayload), f.channelID))
+}
+
+// PushTopic 推送到订阅了主题的所有设备
+func (f *FCMPush) PushTopic(topic string, payload Payload) error {
+	return f.send(newFCMMessage("topic", topic, payload.(*FCMPayload), f.channelID))
+}
+
+func (f *FCMPush) send(message map[string]interface{}) error {
+	body := util.ToJson(map[string]interface{}{
+		"message": message,
+	})
+	req, err := http.NewRequest(http.MethodPost, f.sendURL, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		errorCode, errorMsg := parseFCMError(respBody)
+		f.Warn("FCM推送失败！", zap.Int("status", resp.StatusCode), zap.String("errorCode", errorCode), zap.String("body", string(respBody)))
+		if errorCode == fcmErrorUnregistered {
+			return fmt.Errorf("%w[%s]", ErrInvalidDeviceToken, errorCode)
+		}
+		return fmt.Errorf("FCM推送返回错误！[%s] %s", errorCode, errorMsg)
+	}
+	f.Debug("FCM推送成功", zap.String("name", gjson.GetBytes(respBody, "name").String()))
+	return nil
+}
+
+// newFCMMessage 生成FCM的消息 target为token或topic
+// 普通消息同时带notification和data 音视频消息只带data 由客户端自己处理响铃
+func newFCMMessage(target string, value string, payload *FCMPayload, channelID string) map[string]interface{} {
+	data := map[string]string{
+		"notify_id": payload.notifyID,
+		"title":     payload.GetTitle(),
+		"body":      payload.GetContent(),
+		"badge":     fmt.Sprintf("%d", payload.GetBadge()),
+	}
+	message := map[string]interface{}{
+		target: value,
+	}
+	android := map[string]interface{}{}
+	rtcPayload := payload.GetRTCPayload()
+	if rtcPayload != nil {
+		data["call_type"] = fmt.Sprintf("%d", rtcPayload.GetCallType())
+		data["operation"] = rtcPayload.GetOperation()
+		data["from_uid"] = rtcPayload.GetFromUID()
+		data["call_id"] = rtcPayload.GetCallID()
+		android["priority"] = "HIGH"
+		android["ttl"] = fcmRTCTTL
+		message["apns"] = map[string]interface{}{
+			"headers": map[string]string{
+				"apns-priority":  "5",
+				"apns-push-type": "background",
+			},
+			"payload": map[string]interface{}{
+				"aps": map[string]interface{}{
+					"content-available": 1,
+				},
+			},
+		}
+	} else {
+		message["notification"] = map[string]string{
+			"title": payload.GetTitle(),
+			"body":  payload.GetContent(),
+		}
+		androidNotification := map[string]interface{}{
+			"notification_count": payload.GetBadge(),
+		}
+		if channelID != "" {
+			androidNotification["channel_id"] = channelID
+		}
+		if payload.notifyID != "" {
+			// 同一条消息只显示一次
+			androidNotification["tag"] = payload.notifyID
+		}
+		android["priority"] = "NORMAL"
+		android["notification"] = androidNotification
+		message["apns"] = map[string]interface{}{
+			"payload": map[string]interface{}{
+				"aps": map[string]interface{}{
+					"badge": payload.GetBadge(),
+					"sound": "default",
+				},
+			},
+		}
+	}
+	message["android"] = android
+	message["data"] = data
+	return message
+}
+
+// parseFCMError 解析FCM返回的错误 errorCode例如UNREGISTERED、INVALID_ARGUMENT
+func parseFCMError(body []byte) (string, string) {
+	errorValue := gjson.GetBytes(body, "error")
+	errorCode := errorValue.Get("status").String()
+	for _, detail := range errorValue.Get("details").Array() {
+		if code := detail.Get("errorCode").String(); code != "" {
+			errorCode = code
+			break
+		}
+	}
+	errorMsg := errorValue.Get("message").String()
+	if errorMsg == "" {
+		errorMsg = string(body)
+	}
+	return errorCode, errorMsg
+}
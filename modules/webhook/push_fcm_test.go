@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFCMMessage(t *testing.T) {
+	payload := NewFCMPayload(&PayloadInfo{
+		Title:   "title",
+		Content: "content",
+		Badge:   3,
+	}, "11")
+	message := newFCMMessage("token", "deviceToken", payload, "wk_new_msg_notification")
+	assert.Equal(t, "deviceToken", message["token"])
+	assert.Equal(t, map[string]string{"title": "title", "body": "content"}, message["notification"])
+	data := message["data"].(map[string]string)
+	assert.Equal(t, "11", data["notify_id"])
+	assert.Equal(t, "3", data["badge"])
+	android := message["android"].(map[string]interface{})
+	androidNotification := android["notification"].(map[string]interface{})
+	assert.Equal(t, "wk_new_msg_notification", androidNotification["channel_id"])
+	assert.Equal(t, 3, androidNotification["notification_count"])
+
+	message = newFCMMessage("topic", "news", payload, "")
+	assert.Equal(t, "news", message["topic"])
+	assert.Nil(t, message["token"])
+	_, ok := message["android"].(map[string]interface{})["notification"].(map[string]interface{})["channel_id"]
+	assert.False(t, ok)
+}
+
+func TestNewFCMMessageWithRTC(t *testing.T) {
+	payload := NewFCMPayload(&PayloadInfo{
+		Title:       "title",
+		Content:     "邀请你语音通话",
+		IsVideoCall: true,
+		FromUID:     "u1",
+		CallType:    common.RTCCallTypeAudio,
+		Operation:   "invite",
+	}, "12")
+	message := newFCMMessage("token", "deviceToken", payload, "")
+	assert.Nil(t, message["notification"])
+	data := message["data"].(map[string]string)
+	assert.Equal(t, "u1", data["from_uid"])
+	assert.Equal(t, "invite", data["operation"])
+	android := message["android"].(map[string]interface{})
+	assert.Equal(t, "HIGH", android["priority"])
+	assert.Equal(t, fcmRTCTTL, android["ttl"])
+}
+
+func TestParseFCMError(t *testing.T) {
+	errorCode, errorMsg := parseFCMError([]byte(`{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"UNREGISTERED"}]}}`))
+	assert.Equal(t, "UNREGISTERED", errorCode)
+	assert.Equal(t, "Requested entity was not found.", errorMsg)
+
+	errorCode, _ = parseFCMError([]byte(`{"error":{"code":401,"message":"invalid","status":"UNAUTHENTICATED"}}`))
+	assert.Equal(t, "UNAUTHENTICATED", errorCode)
+}
@@ -2,7 +2,6 @@ package webhook
 
 import (
 	"context"
-	"fmt"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/messaging"
@@ -74,7 +73,7 @@ func (m *FIREBASEPush) GetPayload(msg msgOfflineNotify, ctx *config.Context, toU
 	if err != nil {
 		return nil, err
 	}
-	return NewFIREBASEPayload(payloadInfo, fmt.Sprintf("%d", msg.MessageSeq)), nil
+	return NewFIREBASEPayload(payloadInfo, pushNotifyID(msg)), nil
 }
 
 // Push 推送
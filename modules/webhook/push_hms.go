@@ -2,13 +2,13 @@ package webhook
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/network"
@@ -129,7 +129,8 @@ func (h *HMSPush) Push(deviceToken string, payload Payload) error {
 	if resultMap != nil && resultMap["code"] != nil {
 		code := resultMap["code"].(string)
 		if code != "80000000" {
-			return errors.New(resultMap["msg"].(string))
+			msg, _ := resultMap["msg"].(string)
+			return vendorPushError(common.DeviceTypeHMS, code, msg)
 		}
 	}
 	return nil
@@ -74,34 +74,7 @@ func (p *IOSPush) Push(deviceToken string, payload Payload) error {
 	notification := &apns2.Notification{}
 	notification.DeviceToken = deviceToken
 	notification.Topic = p.topic
-
-	rtcPayload := payload.GetRTCPayload()
-	if rtcPayload != nil {
-		fmt.Println("音视频推送。。。。。")
-		notification.Payload = []byte(util.ToJson(map[string]interface{}{
-			"aps": map[string]interface{}{
-				"content-available": 1,
-				"alert":             "",
-				"badge":             payload.GetBadge(),
-				"sound":             "default",
-			},
-			"content":   payload.GetContent(),
-			"call_type": rtcPayload.GetCallType(),
-			"from_uid":  rtcPayload.GetFromUID(),
-		}))
-	} else {
-		fmt.Println("普通推送。。。。。")
-		notification.Payload = []byte(util.ToJson(map[string]interface{}{
-			"aps": map[string]interface{}{
-				"alert": map[string]interface{}{
-					"title": payload.GetTitle(),
-					"body":  payload.GetContent(),
-				},
-				"badge": payload.GetBadge(),
-				"sound": "default",
-			},
-		}))
-	}
+	notification.Payload = iosNotificationPayload(payload)
 
 	var err error
 	if p.client == nil {
@@ -115,7 +88,40 @@ func (p *IOSPush) Push(deviceToken string, payload Payload) error {
 		return err
 	}
 	if res.StatusCode != 200 {
+		if isAPNsInvalidToken(res) {
+			return fmt.Errorf("%w[%s]", ErrInvalidDeviceToken, res.Reason)
+		}
 		return errors.New(res.Reason)
 	}
 	return nil
 }
+
+// iosNotificationPayload 生成APNs的推送内容 音视频消息为静默推送 由客户端自己处理响铃
+func iosNotificationPayload(payload Payload) []byte {
+	rtcPayload := payload.GetRTCPayload()
+	if rtcPayload != nil {
+		return []byte(util.ToJson(map[string]interface{}{
+			"aps": map[string]interface{}{
+				"content-available": 1,
+				"alert":             "",
+				"badge":             payload.GetBadge(),
+				"sound":             "default",
+			},
+			"content":   payload.GetContent(),
+			"call_type": rtcPayload.GetCallType(),
+			"from_uid":  rtcPayload.GetFromUID(),
+			"call_id":   rtcPayload.GetCallID(),
+			"operation": rtcPayload.GetOperation(),
+		}))
+	}
+	return []byte(util.ToJson(map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]interface{}{
+				"title": payload.GetTitle(),
+				"body":  payload.GetContent(),
+			},
+			"badge": payload.GetBadge(),
+			"sound": "default",
+		},
+	}))
+}
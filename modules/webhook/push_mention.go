@@ -0,0 +1,29 @@
+package webhook
+
+import "encoding/json"
+
+// isMentionedOrReplied 消息是否@了用户（包括@所有人）或回复了用户的消息 加密消息无法解析 返回false
+func isMentionedOrReplied(msg msgOfflineNotify, uid string) bool {
+	if msg.PayloadMap == nil {
+		return false
+	}
+	if mentionMap, ok := msg.PayloadMap["mention"].(map[string]interface{}); ok {
+		if all, ok := mentionMap["all"].(json.Number); ok {
+			if allI, _ := all.Int64(); allI == 1 {
+				return true
+			}
+		}
+		uids, _ := mentionMap["uids"].([]interface{})
+		for _, mentionUID := range uids {
+			if mentionUID == uid {
+				return true
+			}
+		}
+	}
+	if replyMap, ok := msg.PayloadMap["reply"].(map[string]interface{}); ok {
+		if fromUID, _ := replyMap["from_uid"].(string); fromUID != "" && fromUID == uid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsMentionedOrReplied(t *testing.T) {
+	newMsg := func(payload string) msgOfflineNotify {
+		payloadMap, err := util.JsonToMap(payload)
+		assert.NoError(t, err)
+		msg := msgOfflineNotify{}
+		msg.PayloadMap = payloadMap
+		return msg
+	}
+	assert.False(t, isMentionedOrReplied(msgOfflineNotify{}, "u1"))
+	assert.False(t, isMentionedOrReplied(newMsg(`{"type":1,"content":"hello"}`), "u1"))
+	assert.True(t, isMentionedOrReplied(newMsg(`{"type":1,"mention":{"uids":["u2","u1"]}}`), "u1"))
+	assert.False(t, isMentionedOrReplied(newMsg(`{"type":1,"mention":{"uids":["u2"]}}`), "u1"))
+	assert.True(t, isMentionedOrReplied(newMsg(`{"type":1,"mention":{"all":1}}`), "u1"))
+	assert.False(t, isMentionedOrReplied(newMsg(`{"type":1,"mention":{"all":0}}`), "u1"))
+	assert.True(t, isMentionedOrReplied(newMsg(`{"type":1,"reply":{"message_id":"1","from_uid":"u1"}}`), "u1"))
+	assert.False(t, isMentionedOrReplied(newMsg(`{"type":1,"reply":{"message_id":"1","from_uid":"u2"}}`), "u1"))
+}
@@ -1,11 +1,11 @@
 package webhook
 
 import (
-	"errors"
 	"fmt"
 	"net/url"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/network"
@@ -52,7 +52,7 @@ func (m *MIPush) GetPayload(msg msgOfflineNotify, ctx *config.Context, toUser *u
 	if err != nil {
 		return nil, err
 	}
-	return NewMIPayload(payloadInfo, fmt.Sprintf("%d", msg.MessageSeq)), nil
+	return NewMIPayload(payloadInfo, pushNotifyID(msg)), nil
 }
 
 // Push 推送
@@ -82,10 +82,11 @@ func (m *MIPush) Push(deviceToken string, payload Payload) error {
 	}
 	m.Debug("返回", zap.Any("data", result))
 	if result != nil && result["result"].(string) != "ok" {
-		if result["reason"] != nil {
-			return errors.New(result["reason"].(string))
+		reason, _ := result["reason"].(string)
+		if reason == "" {
+			reason, _ = result["description"].(string)
 		}
-		return errors.New(result["description"].(string))
+		return vendorPushError(common.DeviceTypeMI, fmt.Sprintf("%v", result["code"]), reason)
 	}
 	return nil
 }
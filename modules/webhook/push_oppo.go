@@ -4,11 +4,11 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/network"
@@ -59,7 +59,7 @@ func (o *OPPOPush) GetPayload(msg msgOfflineNotify, ctx *config.Context, toUser
 	if err != nil {
 		return nil, err
 	}
-	return NewOPPOPayload(payloadInfo, fmt.Sprintf("%d", msg.MessageSeq)), nil
+	return NewOPPOPayload(payloadInfo, pushNotifyID(msg)), nil
 }
 
 // Push Push
@@ -89,7 +89,8 @@ func (o *OPPOPush) Push(deviceToken string, payload Payload) error {
 	if resp != nil && resp["code"] != nil {
 		code, _ := resp["code"].(json.Number).Int64()
 		if code != 0 {
-			return errors.New(resp["message"].(string))
+			message, _ := resp["message"].(string)
+			return vendorPushError(common.DeviceTypeOPPO, fmt.Sprintf("%d", code), message)
 		}
 	}
 	return nil
@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+)
+
+// 会话的推送隐私设置
+const (
+	pushPrivacyDefault = 0 // 跟随用户的设置（显示消息通知详情）
+	pushPrivacyHide    = 1 // 隐藏推送内容
+	pushPrivacyShow    = 2 // 显示推送内容
+)
+
+// isPushPrivacy 是否隐藏推送内容 只显示“您有一条新的消息” 会话的设置优先于用户的设置
+func isPushPrivacy(msg msgOfflineNotify, toUser *user.Resp) bool {
+	switch msg.pushPrivacy {
+	case pushPrivacyHide:
+		return true
+	case pushPrivacyShow:
+		return false
+	}
+	return toUser.MsgShowDetail == 0
+}
+
+// conversationPushPrivacy 接收者对会话的推送隐私设置 个人会话只查询了第一个接收者对发送者的设置
+func conversationPushPrivacy(userSettings []*user.SettingResp, groupSettings []*group.SettingResp, toUID string) int {
+	if len(userSettings) > 0 {
+		return userSettings[0].PushPrivacy
+	}
+	for _, groupSetting := range groupSettings {
+		if groupSetting.UID == toUID {
+			return groupSetting.PushPrivacy
+		}
+	}
+	return pushPrivacyDefault
+}
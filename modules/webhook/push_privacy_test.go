@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPushPrivacy(t *testing.T) {
+	showDetail := &user.Resp{MsgShowDetail: 1}
+	hideDetail := &user.Resp{MsgShowDetail: 0}
+
+	msg := msgOfflineNotify{}
+	assert.False(t, isPushPrivacy(msg, showDetail))
+	assert.True(t, isPushPrivacy(msg, hideDetail))
+
+	msg.pushPrivacy = pushPrivacyHide
+	assert.True(t, isPushPrivacy(msg, showDetail))
+
+	msg.pushPrivacy = pushPrivacyShow
+	assert.False(t, isPushPrivacy(msg, hideDetail))
+}
+
+func TestConversationPushPrivacy(t *testing.T) {
+	assert.Equal(t, pushPrivacyDefault, conversationPushPrivacy(nil, nil, "u1"))
+	assert.Equal(t, pushPrivacyHide, conversationPushPrivacy([]*user.SettingResp{{UID: "u2", PushPrivacy: pushPrivacyHide}}, nil, "u1"))
+
+	groupSettings := []*group.SettingResp{
+		{UID: "u1", PushPrivacy: pushPrivacyShow},
+		{UID: "u2", PushPrivacy: pushPrivacyHide},
+	}
+	assert.Equal(t, pushPrivacyHide, conversationPushPrivacy(nil, groupSettings, "u2"))
+	assert.Equal(t, pushPrivacyDefault, conversationPushPrivacy(nil, groupSettings, "u3"))
+}
@@ -0,0 +1,40 @@
+package webhook
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"go.uber.org/zap"
+)
+
+// reloadPush 扩展配置重新加载后重新创建推送客户端 创建失败时保留原来的客户端
+func (w *Webhook) reloadPush(_ *extconfig.Config) error {
+	pushMap, fallbackPush, webPush, err := newPushClients(w.ctx, true)
+	if err != nil {
+		return err
+	}
+	w.pushLock.Lock()
+	w.pushMap = pushMap
+	w.fallbackPush = fallbackPush
+	w.webPush = webPush
+	w.pushLock.Unlock()
+	w.Info("推送配置已重新加载", zap.Int("deviceTypes", len(pushMap)), zap.Bool("fcm", fallbackPush != nil), zap.Bool("web", webPush != nil))
+	return nil
+}
+
+func (w *Webhook) getPushMap() map[common.DeviceType]map[string]Push {
+	w.pushLock.RLock()
+	defer w.pushLock.RUnlock()
+	return w.pushMap
+}
+
+func (w *Webhook) getFallbackPush() Push {
+	w.pushLock.RLock()
+	defer w.pushLock.RUnlock()
+	return w.fallbackPush
+}
+
+func (w *Webhook) getWebPush() *WebPush {
+	w.pushLock.RLock()
+	defer w.pushLock.RUnlock()
+	return w.webPush
+}
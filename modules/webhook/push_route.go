@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+)
+
+// manufacturerDeviceTypes 手机厂商（android的Build.MANUFACTURER 小写）对应的推送通道
+var manufacturerDeviceTypes = map[string]common.DeviceType{
+	"huawei":  common.DeviceTypeHMS,
+	"honor":   common.DeviceTypeHMS,
+	"xiaomi":  common.DeviceTypeMI,
+	"redmi":   common.DeviceTypeMI,
+	"oppo":    common.DeviceTypeOPPO,
+	"realme":  common.DeviceTypeOPPO,
+	"oneplus": common.DeviceTypeOPPO,
+	"vivo":    common.DeviceTypeVIVO,
+	"iqoo":    common.DeviceTypeVIVO,
+}
+
+// deviceTypeOfManufacturer 按手机厂商选择推送通道 优先使用配置的对应关系
+func deviceTypeOfManufacturer(manufacturer string, custom map[string]string) common.DeviceType {
+	manufacturer = strings.ToLower(strings.TrimSpace(manufacturer))
+	if manufacturer == "" {
+		return ""
+	}
+	if deviceType := custom[manufacturer]; deviceType != "" {
+		return common.DeviceType(strings.ToUpper(deviceType))
+	}
+	return manufacturerDeviceTypes[manufacturer]
+}
+
+// routePush 选择推送通道
+// 上报的设备类型有对应的推送时直接使用 否则（例如只上报了ANDROID）按手机厂商选择
+// 不同通道的token不通用 所以不会退回到其他通道
+func routePush(pushMap map[common.DeviceType]map[string]Push, deviceType string, manufacturer string, bundleID string) (common.DeviceType, Push) {
+	if pusher := pushMap[common.DeviceType(deviceType)][bundleID]; pusher != nil {
+		return common.DeviceType(deviceType), pusher
+	}
+	if manufacturerType := deviceTypeOfManufacturer(manufacturer, extconfig.Get().Push.Manufacturers); manufacturerType != "" {
+		if pusher := pushMap[manufacturerType][bundleID]; pusher != nil {
+			return manufacturerType, pusher
+		}
+	}
+	return common.DeviceType(deviceType), nil
+}
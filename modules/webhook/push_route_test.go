@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceTypeOfManufacturer(t *testing.T) {
+	assert.Equal(t, common.DeviceTypeHMS, deviceTypeOfManufacturer("HUAWEI", nil))
+	assert.Equal(t, common.DeviceTypeMI, deviceTypeOfManufacturer(" Xiaomi ", nil))
+	assert.Equal(t, common.DeviceTypeOPPO, deviceTypeOfManufacturer("OnePlus", nil))
+	assert.Equal(t, common.DeviceTypeVIVO, deviceTypeOfManufacturer("vivo", nil))
+	assert.Equal(t, common.DeviceType(""), deviceTypeOfManufacturer("samsung", nil))
+	assert.Equal(t, common.DeviceType(""), deviceTypeOfManufacturer("", nil))
+
+	custom := map[string]string{"samsung": "firebase", "honor": "FIREBASE"}
+	assert.Equal(t, common.DeviceTypeFirebase, deviceTypeOfManufacturer("samsung", custom))
+	assert.Equal(t, common.DeviceTypeFirebase, deviceTypeOfManufacturer("HONOR", custom))
+}
+
+func TestRoutePush(t *testing.T) {
+	hms := NewHMSPush("appID", "appSecret", "com.xinbida.tangsengdaodao")
+	mi := NewMIPush("appID", "appSecret", "com.xinbida.tangsengdaodao", "")
+	pushMap := map[common.DeviceType]map[string]Push{
+		common.DeviceTypeHMS: {"com.xinbida.tangsengdaodao": hms},
+		common.DeviceTypeMI:  {"com.xinbida.tangsengdaodao": mi},
+	}
+
+	deviceType, pusher := routePush(pushMap, "MI", "HUAWEI", "com.xinbida.tangsengdaodao")
+	assert.Equal(t, common.DeviceTypeMI, deviceType)
+	assert.Same(t, mi, pusher)
+
+	deviceType, pusher = routePush(pushMap, "ANDROID", "HUAWEI", "com.xinbida.tangsengdaodao")
+	assert.Equal(t, common.DeviceTypeHMS, deviceType)
+	assert.Same(t, hms, pusher)
+
+	_, pusher = routePush(pushMap, "ANDROID", "HUAWEI", "com.other")
+	assert.Nil(t, pusher)
+
+	deviceType, pusher = routePush(pushMap, "ANDROID", "vivo", "com.xinbida.tangsengdaodao")
+	assert.Equal(t, common.DeviceType("ANDROID"), deviceType)
+	assert.Nil(t, pusher)
+}
@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+)
+
+// 推送文案模版的key
+const (
+	pushTplNewMessage      = "new_message"      // 不显示详情时的内容
+	pushTplNewCall         = "new_call"         // 来电的内容
+	pushTplCallCancel      = "call_cancel"      // 对方取消来电的内容
+	pushTplGroupContent    = "group_content"    // 群消息的内容 {name}为发送者 {content}为消息内容
+	pushTplAggregate       = "aggregate"        // 合并推送的内容 {count}为消息数
+	pushTplImage           = "image"            // 图片
+	pushTplGIF             = "gif"              // GIF
+	pushTplVoice           = "voice"            // 语音
+	pushTplVideo           = "video"            // 视频
+	pushTplCard            = "card"             // 名片
+	pushTplFile            = "file"             // 文件
+	pushTplLocation        = "location"         // 位置
+	pushTplVectorSticker   = "vector_sticker"   // 动画表情
+	pushTplEmojiSticker    = "emoji_sticker"    // emoji表情
+	pushTplMultipleForward = "multiple_forward" // 聊天记录
+)
+
+// pushDefaultLocale 设备没有上报语言且没有配置默认语言时使用的语言
+const pushDefaultLocale = "zh"
+
+// pushTplContentTypes 消息类型对应的模版
+var pushTplContentTypes = map[common.ContentType]string{
+	common.Image:           pushTplImage,
+	common.GIF:             pushTplGIF,
+	common.Voice:           pushTplVoice,
+	common.Video:           pushTplVideo,
+	common.Card:            pushTplCard,
+	common.File:            pushTplFile,
+	common.Location:        pushTplLocation,
+	common.VectorSticker:   pushTplVectorSticker,
+	common.EmojiSticker:    pushTplEmojiSticker,
+	common.MultipleForward: pushTplMultipleForward,
+}
+
+var pushTemplatesHant = map[string]string{
+	pushTplNewMessage:      "您有一條新的消息",
+	pushTplNewCall:         "您收到新的來電",
+	pushTplCallCancel:      "對方已取消通話",
+	pushTplGroupContent:    "{name}：{content}",
+	pushTplAggregate:       "{count}條新消息",
+	pushTplImage:           "[圖片]",
+	pushTplGIF:             "[GIF]",
+	pushTplVoice:           "[語音]",
+	pushTplVideo:           "[視頻]",
+	pushTplCard:            "[名片]",
+	pushTplFile:            "[文件]",
+	pushTplLocation:        "[位置]",
+	pushTplVectorSticker:   "[動畫表情]",
+	pushTplEmojiSticker:    "[emoji表情]",
+	pushTplMultipleForward: "[聊天記錄]",
+}
+
+// pushTemplates 内置的推送文案 语言为小写
+var pushTemplates = map[string]map[string]string{
+	"zh": {
+		pushTplNewMessage:      "您有一条新的消息",
+		pushTplNewCall:         "您收到新的来电",
+		pushTplCallCancel:      "对方已取消通话",
+		pushTplGroupContent:    "{name}：{content}",
+		pushTplAggregate:       "{count}条新消息",
+		pushTplImage:           "[图片]",
+		pushTplGIF:             "[GIF]",
+		pushTplVoice:           "[语音]",
+		pushTplVideo:           "[视频]",
+		pushTplCard:            "[名片]",
+		pushTplFile:            "[文件]",
+		pushTplLocation:        "[位置]",
+		pushTplVectorSticker:   "[动画表情]",
+		pushTplEmojiSticker:    "[emoji表情]",
+		pushTplMultipleForward: "[聊天记录]",
+	},
+	"zh-hant": pushTemplatesHant,
+	"zh-tw":   pushTemplatesHant,
+	"zh-hk":   pushTemplatesHant,
+	"en": {
+		pushTplNewMessage:      "You have a new message",
+		pushTplNewCall:         "Incoming call",
+		pushTplCallCancel:      "Call cancelled",
+		pushTplGroupContent:    "{name}: {content}",
+		pushTplAggregate:       "{count} new messages",
+		pushTplImage:           "[Image]",
+		pushTplGIF:             "[GIF]",
+		pushTplVoice:           "[Voice]",
+		pushTplVideo:           "[Video]",
+		pushTplCard:            "[Contact Card]",
+		pushTplFile:            "[File]",
+		pushTplLocation:        "[Location]",
+		pushTplVectorSticker:   "[Sticker]",
+		pushTplEmojiSticker:    "[Emoji]",
+		pushTplMultipleForward: "[Chat History]",
+	},
+}
+
+// pushLocales 模版匹配的语言顺序 例如 zh-Hans-CN => zh-hans-cn > zh-hans > zh > 默认语言
+func pushLocales(locale string, defaultLocale string) []string {
+	locale = strings.ToLower(normalizePushLocale(locale))
+	locales := make([]string, 0, 4)
+	for locale != "" {
+		locales = append(locales, locale)
+		idx := strings.LastIndex(locale, "-")
+		if idx <= 0 {
+			break
+		}
+		locale = locale[:idx]
+	}
+	if defaultLocale = strings.ToLower(normalizePushLocale(defaultLocale)); defaultLocale != "" {
+		locales = append(locales, defaultLocale)
+	}
+	return append(locales, pushDefaultLocale)
+}
+
+// normalizePushLocale 取第一个语言 例如 "zh-CN,zh;q=0.9" => "zh-CN"
+func normalizePushLocale(locale string) string {
+	locale = strings.TrimSpace(locale)
+	if idx := strings.IndexAny(locale, ",;"); idx >= 0 {
+		locale = locale[:idx]
+	}
+	return strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+}
+
+// pushText 获取指定语言的推送文案 优先使用配置的模版
+func pushText(locale string, key string, params map[string]string) string {
+	pushCfg := extconfig.Get().Push
+	var tpl string
+	for _, l := range pushLocales(locale, pushCfg.DefaultLocale) {
+		if text, ok := pushCfg.Templates[l][key]; ok {
+			tpl = text
+			break
+		}
+		if text, ok := pushTemplates[l][key]; ok {
+			tpl = text
+			break
+		}
+	}
+	if len(params) == 0 {
+		return tpl
+	}
+	// 一次替换全部变量 避免消息内容中的{name}等被再次替换
+	oldnew := make([]string, 0, len(params)*2)
+	for k, v := range params {
+		oldnew = append(oldnew, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(oldnew...).Replace(tpl)
+}
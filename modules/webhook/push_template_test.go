@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushLocales(t *testing.T) {
+	assert.Equal(t, []string{"zh-hans-cn", "zh-hans", "zh", "zh"}, pushLocales("zh-Hans-CN", ""))
+	assert.Equal(t, []string{"en-us", "en", "zh"}, pushLocales("en_US,en;q=0.9", ""))
+	assert.Equal(t, []string{"en", "zh"}, pushLocales("", "en"))
+}
+
+func TestPushText(t *testing.T) {
+	assert.Equal(t, "[图片]", pushText("", pushTplImage, nil))
+	assert.Equal(t, "[图片]", pushText("zh-Hans-CN", pushTplImage, nil))
+	assert.Equal(t, "[圖片]", pushText("zh-Hant-TW", pushTplImage, nil))
+	assert.Equal(t, "[Image]", pushText("en-US", pushTplImage, nil))
+	assert.Equal(t, "[图片]", pushText("fr-FR", pushTplImage, nil))
+	assert.Equal(t, "张三: {content}?", pushText("en", pushTplGroupContent, map[string]string{
+		"name":    "张三",
+		"content": "{content}?",
+	}))
+
+	vp := viper.New()
+	vp.Set("push.defaultLocale", "en")
+	vp.Set("push.templates", map[string]interface{}{
+		"en": map[string]interface{}{
+			"image": "[Photo]",
+		},
+	})
+	extconfig.Configure(vp)
+	t.Cleanup(func() {
+		extconfig.Configure(viper.New())
+	})
+	assert.Equal(t, "[Photo]", pushText("en-GB", pushTplImage, nil))
+	assert.Equal(t, "[Photo]", pushText("fr-FR", pushTplImage, nil))
+	assert.Equal(t, "[Voice]", pushText("fr-FR", pushTplVoice, nil))
+	assert.Equal(t, "[图片]", pushText("zh-CN", pushTplImage, nil))
+}
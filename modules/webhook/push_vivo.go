@@ -2,12 +2,12 @@ package webhook
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/network"
@@ -57,7 +57,7 @@ func (v *VIVOPush) GetPayload(msg msgOfflineNotify, ctx *config.Context, toUser
 	if err != nil {
 		return nil, err
 	}
-	return NewVIVOPayload(payloadInfo, fmt.Sprintf("%d", msg.MessageSeq)), nil
+	return NewVIVOPayload(payloadInfo, pushNotifyID(msg)), nil
 }
 
 // Push Push
@@ -96,7 +96,8 @@ func (v *VIVOPush) Push(deviceToken string, payload Payload) error {
 	if resultMap != nil && resultMap["result"] != nil {
 		code, _ := resultMap["result"].(json.Number).Int64()
 		if code != 0 {
-			return errors.New(resultMap["desc"].(string))
+			desc, _ := resultMap["desc"].(string)
+			return vendorPushError(common.DeviceTypeVIVO, fmt.Sprintf("%d", code), desc)
 		}
 	}
 	return nil
@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// webPushRecordSize aes128gcm的记录大小 推送内容只使用一个记录
+	webPushRecordSize = 4096
+	// webPushMaxPayload 加密前推送内容的最大字节数 4096减去头部(86)、tag(16)和分隔符(1)
+	webPushMaxPayload = webPushRecordSize - 86 - 16 - 1
+	// webPushVAPIDExpiration VAPID认证的有效期 最长为24小时
+	webPushVAPIDExpiration = time.Hour * 12
+)
+
+// 推送的紧急程度 浏览器在省电模式下只接收high的推送
+const (
+	webPushUrgencyNormal = "normal"
+	webPushUrgencyHigh   = "high"
+)
+
+// webPushSubscription 浏览器的推送订阅（PushSubscription）
+type webPushSubscription struct {
+	Endpoint string // 推送服务的地址
+	P256dh   string // 浏览器的公钥（base64url）
+	Auth     string // 浏览器的认证密钥（base64url）
+}
+
+// WebPush 浏览器推送（RFC 8030） 使用VAPID（RFC 8292）认证 内容按RFC 8291加密
+type WebPush struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  string // base64url编码的公钥 web端订阅时作为applicationServerKey
+	subject    string
+	ttl        time.Duration
+	client     *http.Client
+}
+
+// NewWebPush NewWebPush privateKey为base64url编码的VAPID私钥
+func NewWebPush(privateKey string, subject string, ttl time.Duration) (*WebPush, error) {
+	d, err := decodeWebPushKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID私钥格式错误！%w", err)
+	}
+	ecdhKey, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID私钥格式错误！%w", err)
+	}
+	publicKey := ecdhKey.PublicKey().Bytes()
+	return &WebPush{
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(publicKey[1:33]),
+				Y:     new(big.Int).SetBytes(publicKey[33:]),
+			},
+			D: new(big.Int).SetBytes(d),
+		},
+		publicKey: base64.RawURLEncoding.EncodeToString(publicKey),
+		subject:   subject,
+		ttl:       ttl,
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+	}, nil
+}
+
+// PublicKey VAPID公钥
+func (w *WebPush) PublicKey() string {
+	return w.publicKey
+}
+
+// Send 发送推送 topic相同的未送达推送会被替换 订阅已失效时返回ErrInvalidDeviceToken
+func (w *WebPush) Send(sub webPushSubscription, data []byte, topic string, urgency string) error {
+	if len(data) > webPushMaxPayload {
+		return fmt.Errorf("浏览器推送内容超过最大长度[%d]！", webPushMaxPayload)
+	}
+	body, err := encryptWebPush(sub, data)
+	if err != nil {
+		return err
+	}
+	authorization, err := w.vapidAuthorization(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", fmt.Sprintf("%d", int64(w.ttl.Seconds())))
+	if urgency != "" {
+		req.Header.Set("Urgency", urgency)
+	}
+	if topic != "" {
+		req.Header.Set("Topic", topic)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		// 用户取消了订阅或订阅已过期
+		return fmt.Errorf("%w[%d]", ErrInvalidDeviceToken, resp.StatusCode)
+	}
+	return fmt.Errorf("浏览器推送返回错误！[%d] %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+}
+
+// vapidAuthorization VAPID认证头 aud为推送服务的origin
+func (w *WebPush) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", errors.New("浏览器推送的endpoint格式错误！")
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(util.ToJson(map[string]interface{}{
+		"aud": fmt.Sprintf("%s://%s", u.Scheme, u.Host),
+		"exp": time.Now().Add(webPushVAPIDExpiration).Unix(),
+		"sub": w.subject,
+	})))
+	unsigned := header + "." + claims
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, w.privateKey, hash[:])
+	if err != nil {
+		return "", err
+	}
+	// ES256的签名为32字节的r和32字节的s
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, w.publicKey), nil
+}
+
+// encryptWebPush 按RFC 8291加密推送内容（aes128gcm）
+func encryptWebPush(sub webPushSubscription, data []byte) ([]byte, error) {
+	uaPublic, err := decodeWebPushKey(sub.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("浏览器公钥格式错误！%w", err)
+	}
+	authSecret, err := decodeWebPushKey(sub.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("浏览器认证密钥格式错误！%w", err)
+	}
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("浏览器公钥格式错误！%w", err)
+	}
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptWebPushWithKey(uaKey, authSecret, asKey, salt, data)
+}
+
+func encryptWebPushWithKey(uaKey *ecdh.PublicKey, authSecret []byte, asKey *ecdh.PrivateKey, salt []byte, data []byte) ([]byte, error) {
+	ecdhSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	// IKM = HKDF(auth_secret, ecdh_secret, "WebPush: info" || 0x00 || ua_public || as_public)
+	keyInfo := append([]byte("WebPush: info\x00"), uaKey.Bytes()...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm := make([]byte, 32)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, ecdhSecret, authSecret, keyInfo), ikm); err != nil {
+		return nil, err
+	}
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek := make([]byte, 16)
+	if _, err = io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: aes128gcm\x00")), cek); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 12)
+	if _, err = io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 头部：salt(16) | rs(4) | idlen(1) | keyid(服务端的临时公钥)
+	header := make([]byte, 0, 21+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+	// 0x02为最后一个记录的分隔符
+	plaintext := append(append([]byte{}, data...), 0x02)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// decodeWebPushKey 浏览器上报的密钥为base64url编码 兼容带padding和标准base64
+func decodeWebPushKey(key string) ([]byte, error) {
+	key = strings.TrimRight(strings.TrimSpace(key), "=")
+	key = strings.NewReplacer("+", "-", "/", "_").Replace(key)
+	return base64.RawURLEncoding.DecodeString(key)
+}
@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/hkdf"
+)
+
+func newTestWebPush(t *testing.T) *WebPush {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	p, err := NewWebPush(base64.RawURLEncoding.EncodeToString(key.Bytes()), "mailto:admin@example.com", time.Hour)
+	assert.NoError(t, err)
+	return p
+}
+
+// decryptWebPush 浏览器端按RFC 8291解密
+func decryptWebPush(t *testing.T, uaKey *ecdh.PrivateKey, authSecret []byte, body []byte) []byte {
+	salt := body[:16]
+	assert.Equal(t, uint32(webPushRecordSize), binary.BigEndian.Uint32(body[16:20]))
+	idLen := int(body[20])
+	asPublic, err := ecdh.P256().NewPublicKey(body[21 : 21+idLen])
+	assert.NoError(t, err)
+	ecdhSecret, err := uaKey.ECDH(asPublic)
+	assert.NoError(t, err)
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaKey.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, asPublic.Bytes()...)
+	ikm := make([]byte, 32)
+	_, err = io.ReadFull(hkdf.New(sha256.New, ecdhSecret, authSecret, keyInfo), ikm)
+	assert.NoError(t, err)
+	cek := make([]byte, 16)
+	_, err = io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), cek)
+	assert.NoError(t, err)
+	nonce := make([]byte, 12)
+	_, err = io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), nonce)
+	assert.NoError(t, err)
+
+	block, err := aes.NewCipher(cek)
+	assert.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+	plaintext, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x02), plaintext[len(plaintext)-1])
+	return plaintext[:len(plaintext)-1]
+}
+
+func TestEncryptWebPush(t *testing.T) {
+	uaKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	authSecret := make([]byte, 16)
+	_, err = rand.Read(authSecret)
+	assert.NoError(t, err)
+
+	body, err := encryptWebPush(webPushSubscription{
+		P256dh: base64.RawURLEncoding.EncodeToString(uaKey.PublicKey().Bytes()),
+		// 兼容带padding的base64
+		Auth: base64.URLEncoding.EncodeToString(authSecret),
+	}, []byte(`{"title":"title"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"title":"title"}`, string(decryptWebPush(t, uaKey, authSecret, body)))
+
+	_, err = encryptWebPush(webPushSubscription{P256dh: "abc", Auth: "abc"}, []byte("data"))
+	assert.Error(t, err)
+}
+
+func TestWebPushVAPIDAuthorization(t *testing.T) {
+	p := newTestWebPush(t)
+	authorization, err := p.vapidAuthorization("https://fcm.googleapis.com/fcm/send/xxx")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(authorization, "vapid t="))
+	assert.True(t, strings.HasSuffix(authorization, ", k="+p.PublicKey()))
+
+	token := strings.TrimSuffix(strings.TrimPrefix(authorization, "vapid t="), ", k="+p.PublicKey())
+	parts := strings.Split(token, ".")
+	assert.Len(t, parts, 3)
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+	assert.Contains(t, string(claims), `"aud":"https://fcm.googleapis.com"`)
+	assert.Contains(t, string(claims), `"sub":"mailto:admin@example.com"`)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(&p.privateKey.PublicKey, hash[:], r, s))
+
+	_, err = p.vapidAuthorization("/push")
+	assert.Error(t, err)
+}
+
+func TestWebPushSend(t *testing.T) {
+	p := newTestWebPush(t)
+	uaKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	authSecret := make([]byte, 16)
+	_, err = rand.Read(authSecret)
+	assert.NoError(t, err)
+
+	status := http.StatusCreated
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "aes128gcm", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "3600", r.Header.Get("TTL"))
+		assert.Equal(t, webPushUrgencyHigh, r.Header.Get("Urgency"))
+		assert.Equal(t, "topic", r.Header.Get("Topic"))
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "hello", string(decryptWebPush(t, uaKey, authSecret, body)))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	sub := webPushSubscription{
+		Endpoint: server.URL + "/push/1",
+		P256dh:   base64.RawURLEncoding.EncodeToString(uaKey.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(authSecret),
+	}
+	assert.NoError(t, p.Send(sub, []byte("hello"), "topic", webPushUrgencyHigh))
+
+	status = http.StatusGone
+	err = p.Send(sub, []byte("hello"), "topic", webPushUrgencyHigh)
+	assert.True(t, errors.Is(err, ErrInvalidDeviceToken))
+
+	status = http.StatusBadRequest
+	err = p.Send(sub, []byte("hello"), "topic", webPushUrgencyHigh)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrInvalidDeviceToken))
+
+	err = p.Send(sub, make([]byte, webPushMaxPayload+1), "", "")
+	assert.Error(t, err)
+}
+
+func TestNewWebPushWithInvalidKey(t *testing.T) {
+	_, err := NewWebPush("abc", "", time.Hour)
+	assert.Error(t, err)
+}
+
+func TestWebPushSubscribeReqCheck(t *testing.T) {
+	uaKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	req := webPushSubscribeReq{Endpoint: "https://updates.push.services.mozilla.com/wpush/v2/xxx"}
+	req.Keys.P256dh = base64.RawURLEncoding.EncodeToString(uaKey.PublicKey().Bytes())
+	req.Keys.Auth = base64.RawURLEncoding.EncodeToString(make([]byte, 16))
+	assert.NoError(t, req.check())
+
+	expired := req
+	expired.ExpirationTime = time.Now().Add(-time.Minute).UnixMilli()
+	assert.Error(t, expired.check())
+
+	insecure := req
+	insecure.Endpoint = "http://push.example.com/xxx"
+	assert.Error(t, insecure.check())
+
+	invalidAuth := req
+	invalidAuth.Keys.Auth = "abc"
+	assert.Error(t, invalidAuth.check())
+}
+
+func TestWebPushTag(t *testing.T) {
+	msg := msgOfflineNotify{}
+	msg.MessageID = 1001
+	assert.Equal(t, "1001", webPushTag(msg))
+	msg.collapseKey = "g1_2"
+	assert.Equal(t, "g1_2", webPushTag(msg))
+	msg.call = &callInfo{callID: "u1"}
+	assert.Equal(t, "rtc_u1", webPushTag(msg))
+	assert.Len(t, webPushTopic(webPushTag(msg)), 32)
+}
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+-- ##########  浏览器推送订阅 ##########
+create table `web_push_subscription`
+(
+    id            integer       not null primary key AUTO_INCREMENT,
+    uid           VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '用户uid',
+    endpoint_hash VARCHAR(64)   NOT NULL DEFAULT '' COMMENT 'endpoint的sha256',
+    endpoint      VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '推送服务的地址',
+    p256dh        VARCHAR(200)  NOT NULL DEFAULT '' COMMENT '浏览器的公钥（base64url）',
+    auth          VARCHAR(100)  NOT NULL DEFAULT '' COMMENT '浏览器的认证密钥（base64url）',
+    locale        VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '浏览器的语言',
+    expired_at    BIGINT        NOT NULL DEFAULT 0  COMMENT '订阅的过期时间（毫秒） 0为不过期',
+    created_at    timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at    timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX web_push_subscription_endpoint_uidx on `web_push_subscription` (endpoint_hash);
+CREATE INDEX web_push_subscription_uid_idx on `web_push_subscription` (uid);
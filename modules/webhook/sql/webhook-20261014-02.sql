@@ -0,0 +1,20 @@
+-- +migrate Up
+
+-- ##########  推送记录 ##########
+create table `push_log`
+(
+    id           bigint        not null primary key AUTO_INCREMENT,
+    uid          VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '接收推送的用户',
+    provider     VARCHAR(40)   NOT NULL DEFAULT '' COMMENT '推送通道 IOS HMS MI OPPO VIVO FIREBASE WEB等',
+    device_token VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '设备token（浏览器推送为endpoint）',
+    message_id   BIGINT        NOT NULL DEFAULT 0  COMMENT '消息ID',
+    attempt      smallint      NOT NULL DEFAULT 0  COMMENT '第几次尝试',
+    status       smallint      NOT NULL DEFAULT 0  COMMENT '状态 1.成功 2.失败 3.token已失效',
+    err_msg      VARCHAR(500)  NOT NULL DEFAULT '' COMMENT '错误信息',
+    latency      integer       NOT NULL DEFAULT 0  COMMENT '推送接口耗时（毫秒）',
+    created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX push_log_uid_idx on `push_log` (uid);
+CREATE INDEX push_log_message_idx on `push_log` (message_id);
+CREATE INDEX push_log_created_at_idx on `push_log` (created_at);
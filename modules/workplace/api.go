@@ -3,6 +3,7 @@ package workplace
 import (
 	"errors"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/log"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
@@ -108,7 +109,7 @@ func (w *Workplace) addRecord(c *wkhttp.Context) {
 	loginUID := c.GetLoginUID()
 	appId := c.Param("app_id")
 	if appId == "" {
-		c.ResponseError(errors.New("应用ID不能为空"))
+		c.ResponseError(errcode.ErrAppIDRequired)
 		return
 	}
 	app, err := w.db.queryAppWithAppId(appId)
@@ -252,7 +253,7 @@ func (w *Workplace) addApp(c *wkhttp.Context) {
 	loginUID := c.GetLoginUID()
 	appId := c.Param("app_id")
 	if appId == "" {
-		c.ResponseError(errors.New("应用ID不能为空"))
+		c.ResponseError(errcode.ErrAppIDRequired)
 		return
 	}
 	app, err := w.db.queryAppWithAppId(appId)
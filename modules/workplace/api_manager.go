@@ -5,7 +5,9 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/rbac"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/util"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
@@ -56,7 +58,7 @@ func (m *manager) Route(r *wkhttp.WKHttp) {
 
 // 排序横幅
 func (m *manager) reorderBanner(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -100,6 +102,11 @@ func (m *manager) reorderBanner(c *wkhttp.Context) {
 
 // 编辑分类
 func (m *manager) updateCategory(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
 	categoryNo := c.Param("category_no")
 	if categoryNo == "" {
 		c.ResponseError(errors.New("分类ID不能为空"))
@@ -136,12 +143,17 @@ func (m *manager) updateCategory(c *wkhttp.Context) {
 
 // 删除分类
 func (m *manager) deleteCategory(c *wkhttp.Context) {
+	err := rbac.Check(c, rbac.PermConfigWrite)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
 	categoryNo := c.Param("category_no")
 	if categoryNo == "" {
 		c.ResponseError(errors.New("分类ID不能为空"))
 		return
 	}
-	err := m.db.deleteCategory(categoryNo)
+	err = m.db.deleteCategory(categoryNo)
 	if err != nil {
 		m.Error("删除分类错误", zap.Error(err))
 		c.ResponseError(errors.New("删除分类错误"))
@@ -151,7 +163,7 @@ func (m *manager) deleteCategory(c *wkhttp.Context) {
 }
 
 func (m *manager) getApps(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermConfigRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -215,7 +227,7 @@ func (m *manager) getApps(c *wkhttp.Context) {
 }
 
 func (m *manager) deleteCategoryApp(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -227,7 +239,7 @@ func (m *manager) deleteCategoryApp(c *wkhttp.Context) {
 		return
 	}
 	if appId == "" {
-		c.ResponseError(errors.New("应用ID不能为空"))
+		c.ResponseError(errcode.ErrAppIDRequired)
 		return
 	}
 	err = m.db.deleteCategoryApp(appId, categoryNo)
@@ -240,7 +252,7 @@ func (m *manager) deleteCategoryApp(c *wkhttp.Context) {
 }
 
 func (m *manager) addCategoryApp(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -260,7 +272,7 @@ func (m *manager) addCategoryApp(c *wkhttp.Context) {
 		return
 	}
 	if len(req.AppIds) == 0 {
-		c.ResponseError(errors.New("应用ID不能为空"))
+		c.ResponseError(errcode.ErrAppIDRequired)
 		return
 	}
 	appList, err := m.wpDB.queryAppWithAppIds(req.AppIds)
@@ -340,7 +352,7 @@ func (m *manager) addCategoryApp(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 func (m *manager) reorderCategoryApp(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermOperationWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -360,7 +372,7 @@ func (m *manager) reorderCategoryApp(c *wkhttp.Context) {
 		return
 	}
 	if len(req.AppIds) == 0 {
-		c.ResponseError(errors.New("应用ID不能为空"))
+		c.ResponseError(errcode.ErrAppIDRequired)
 		return
 	}
 	tx, _ := m.ctx.DB().Begin()
@@ -392,7 +404,7 @@ func (m *manager) reorderCategoryApp(c *wkhttp.Context) {
 }
 
 func (m *manager) getCategoryApps(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermConfigRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -431,7 +443,7 @@ func (m *manager) getCategoryApps(c *wkhttp.Context) {
 }
 
 func (m *manager) updateBanner(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -472,7 +484,7 @@ func (m *manager) updateBanner(c *wkhttp.Context) {
 }
 
 func (m *manager) getBanners(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermConfigRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -502,7 +514,7 @@ func (m *manager) getBanners(c *wkhttp.Context) {
 }
 
 func (m *manager) deleteBanner(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -522,7 +534,7 @@ func (m *manager) deleteBanner(c *wkhttp.Context) {
 }
 
 func (m *manager) getCategory(c *wkhttp.Context) {
-	err := c.CheckLoginRole()
+	err := rbac.Check(c, rbac.PermConfigRead)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -547,7 +559,7 @@ func (m *manager) getCategory(c *wkhttp.Context) {
 }
 
 func (m *manager) addBanner(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -582,7 +594,7 @@ func (m *manager) addBanner(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 func (m *manager) updateApp(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -631,7 +643,7 @@ func (m *manager) updateApp(c *wkhttp.Context) {
 }
 
 func (m *manager) deleteApp(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -696,7 +708,7 @@ func (m *manager) deleteApp(c *wkhttp.Context) {
 }
 
 func (m *manager) reorderCategory(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -739,7 +751,7 @@ func (m *manager) reorderCategory(c *wkhttp.Context) {
 }
 
 func (m *manager) addApp(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -786,7 +798,7 @@ func (m *manager) addApp(c *wkhttp.Context) {
 }
 
 func (m *manager) addCategory(c *wkhttp.Context) {
-	err := c.CheckLoginRoleIsSuperAdmin()
+	err := rbac.Check(c, rbac.PermConfigWrite)
 	if err != nil {
 		c.ResponseError(err)
 		return
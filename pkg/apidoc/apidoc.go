@@ -0,0 +1,137 @@
+// Package apidoc 合并各模块的swagger文档生成OpenAPI 3文档 提供Swagger UI
+//
+// 修改模块的swagger/*.yaml后在本目录执行 go generate 重新生成openapi.json
+package apidoc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+)
+
+//go:generate go run gen.go
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec 构建时生成的OpenAPI 3文档（json）
+func Spec() []byte {
+	return spec
+}
+
+// Generate 合并root下modules/*/swagger/*.yaml生成OpenAPI 3文档
+// 文档名为模块名 模块有多个文档时非api.yaml的文档名为文件名
+func Generate(root string) ([]byte, error) {
+	files, err := filepath.Glob(filepath.Join(root, "modules", "*", "swagger", "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	docs := make([]Doc, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".yaml")
+		if name == "api" {
+			name = filepath.Base(filepath.Dir(filepath.Dir(file)))
+		}
+		docs = append(docs, Doc{Name: name, Content: content})
+	}
+	doc, err := Convert(Info{
+		Title:       "唐僧叨叨 API",
+		Version:     "1.0.0",
+		Description: "由各模块的swagger文档生成 需要认证的接口在请求头token中传用户token 管理后台的接口也可以传API密钥",
+	}, docs)
+	if err != nil {
+		return nil, err
+	}
+	var buff bytes.Buffer
+	encoder := json.NewEncoder(&buff)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+// Options 文档接口的配置
+type Options struct {
+	Token string // 访问文档需要的token（Authorization: Bearer xxx 或 ?token=xxx） 为空则不校验
+	UIURL string // swagger-ui-dist的地址
+}
+
+var uiTemplate = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>唐僧叨叨 API</title>
+  <link rel="stylesheet" href="{{.UIURL}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.UIURL}}/swagger-ui-bundle.js"></script>
+<script>
+  window.ui = SwaggerUIBundle({
+    url: {{.SpecURL}},
+    dom_id: "#swagger-ui",
+    persistAuthorization: true
+  });
+</script>
+</body>
+</html>
+`))
+
+// Route 注册 /apidoc（Swagger UI）和 /apidoc/openapi.json
+func Route(r *wkhttp.WKHttp, opts Options) {
+	uiURL := strings.TrimSuffix(opts.UIURL, "/")
+	r.GET("/apidoc/openapi.json", func(c *wkhttp.Context) {
+		if !authorized(c, opts.Token) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+	})
+	r.GET("/apidoc", func(c *wkhttp.Context) {
+		if !authorized(c, opts.Token) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		specURL := "apidoc/openapi.json"
+		if token := c.Query("token"); token != "" {
+			specURL += "?token=" + url.QueryEscape(token)
+		}
+		var buff bytes.Buffer
+		if err := uiTemplate.Execute(&buff, map[string]string{
+			"UIURL":   uiURL,
+			"SpecURL": specURL,
+		}); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buff.Bytes())
+	})
+}
+
+func authorized(c *wkhttp.Context, token string) bool {
+	if token == "" {
+		return true
+	}
+	reqToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if reqToken == "" {
+		reqToken = c.Query("token")
+	}
+	return hmac.Equal([]byte(token), []byte(reqToken))
+}
@@ -0,0 +1,55 @@
+package apidoc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+// 修改swagger文档后没有执行go generate时失败
+func TestSpecUpToDate(t *testing.T) {
+	generated, err := Generate("../..")
+	assert.NoError(t, err)
+	assert.Equal(t, string(generated), string(Spec()), "openapi.json不是最新的，请在pkg/apidoc下执行go generate")
+}
+
+// 新增接口时需要同时写swagger文档
+func TestRoutesDocumented(t *testing.T) {
+	undocumented, err := Undocumented("../..", Spec())
+	assert.NoError(t, err)
+	for _, route := range undocumented {
+		t.Errorf("没有文档的接口：%s %s（%s）", route.Method, route.Path, route.File)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	r := wkhttp.New()
+	Route(r, Options{Token: "secret", UIURL: "https://cdn.example.com/swagger-ui/"})
+
+	request := func(path string, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("/apidoc/openapi.json", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("/apidoc/openapi.json", "Bearer wrong").Code)
+
+	w := request("/apidoc/openapi.json", "Bearer secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, string(Spec()), w.Body.String())
+
+	// 通过query传token时文档地址也带上token
+	w = request("/apidoc?token=secret", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), `href="https://cdn.example.com/swagger-ui/swagger-ui.css"`))
+	assert.True(t, strings.Contains(w.Body.String(), `"apidoc/openapi.json?token=secret"`))
+}
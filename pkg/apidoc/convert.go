@@ -0,0 +1,482 @@
+package apidoc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Doc 模块的swagger 2.0文档
+type Doc struct {
+	Name    string // 文档名 定义重名时作为前缀
+	Content []byte
+}
+
+// Info 生成的文档信息
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+const (
+	swaggerRefPrefix   = "#/definitions/"
+	parameterRefPrefix = "#/parameters/"
+	schemaRefPrefix    = "#/components/schemas/"
+	defaultMediaType   = "application/json"
+)
+
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// Convert 把各模块的swagger 2.0文档合并为一个OpenAPI 3文档
+// basePath合并到路径中 不同文档中内容不同的同名定义加上文档名作为前缀
+func Convert(info Info, docs []Doc) (map[string]interface{}, error) {
+	swaggers := make([]map[string]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		var v interface{}
+		if err := yaml.Unmarshal(doc.Content, &v); err != nil {
+			return nil, fmt.Errorf("解析文档%s失败：%w", doc.Name, err)
+		}
+		m, _ := normalize(v).(map[string]interface{})
+		if m == nil {
+			return nil, fmt.Errorf("文档%s为空", doc.Name)
+		}
+		swaggers = append(swaggers, m)
+	}
+
+	schemas := map[string]interface{}{}
+	renames := make([]map[string]string, len(docs))
+	// 先确定每个文档的定义在合并后的名字
+	definitionDocs := map[string][]int{}
+	for i, swagger := range swaggers {
+		for name := range mapValue(swagger, "definitions") {
+			definitionDocs[name] = append(definitionDocs[name], i)
+		}
+	}
+	for i := range swaggers {
+		renames[i] = map[string]string{}
+	}
+	for name, indexes := range definitionDocs {
+		conflict := false
+		first := mapValue(swaggers[indexes[0]], "definitions")[name]
+		for _, i := range indexes[1:] {
+			if !equalYAML(first, mapValue(swaggers[i], "definitions")[name]) {
+				conflict = true
+				break
+			}
+		}
+		for _, i := range indexes {
+			if conflict {
+				renames[i][name] = docs[i].Name + "_" + name
+			} else {
+				renames[i][name] = name
+			}
+		}
+	}
+
+	paths := map[string]interface{}{}
+	securitySchemes := map[string]interface{}{}
+	var tags []interface{}
+	tagNames := map[string]bool{}
+	operationIDs := map[string]string{}
+	for i, swagger := range swaggers {
+		c := &converter{
+			renames:    renames[i],
+			parameters: mapValue(swagger, "parameters"),
+		}
+		for name, definition := range mapValue(swagger, "definitions") {
+			schemas[renames[i][name]] = c.schema(definition)
+		}
+		for name, scheme := range mapValue(swagger, "securityDefinitions") {
+			securitySchemes[name] = securityScheme(scheme)
+		}
+		for _, tag := range sliceValue(swagger, "tags") {
+			name, _ := mapOf(tag)["name"].(string)
+			if name == "" || tagNames[name] {
+				continue
+			}
+			tagNames[name] = true
+			tags = append(tags, tag)
+		}
+		basePath := strings.TrimSuffix(stringValue(swagger, "basePath"), "/")
+		for path, item := range mapValue(swagger, "paths") {
+			fullPath := basePath + path
+			pathItem, _ := paths[fullPath].(map[string]interface{})
+			if pathItem == nil {
+				pathItem = map[string]interface{}{}
+				paths[fullPath] = pathItem
+			}
+			itemMap := mapOf(item)
+			for _, method := range operationMethods {
+				op, ok := itemMap[method]
+				if !ok {
+					continue
+				}
+				if _, exist := pathItem[method]; exist {
+					return nil, fmt.Errorf("接口%s %s在多个文档中定义", strings.ToUpper(method), fullPath)
+				}
+				operation, err := c.operation(mapOf(op), swagger)
+				if err != nil {
+					return nil, fmt.Errorf("接口%s %s：%w", strings.ToUpper(method), fullPath, err)
+				}
+				if id, _ := operation["operationId"].(string); id != "" {
+					if other, exist := operationIDs[id]; exist {
+						return nil, fmt.Errorf("接口%s %s的operationId(%s)与%s重复", strings.ToUpper(method), fullPath, id, other)
+					}
+					operationIDs[id] = strings.ToUpper(method) + " " + fullPath
+				}
+				pathItem[method] = operation
+			}
+			if params := sliceOf(itemMap["parameters"]); len(params) > 0 {
+				converted := make([]interface{}, 0, len(params))
+				for _, p := range params {
+					param, err := c.parameter(p)
+					if err != nil {
+						return nil, fmt.Errorf("接口%s：%w", fullPath, err)
+					}
+					converted = append(converted, param)
+				}
+				pathItem["parameters"] = converted
+			}
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return mapOf(tags[i])["name"].(string) < mapOf(tags[j])["name"].(string)
+	})
+
+	infoMap := map[string]interface{}{
+		"title":   info.Title,
+		"version": info.Version,
+	}
+	if info.Description != "" {
+		infoMap["description"] = info.Description
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    infoMap,
+		"tags":    tags,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas":         schemas,
+			"securitySchemes": securitySchemes,
+		},
+	}, nil
+}
+
+type converter struct {
+	renames    map[string]string
+	parameters map[string]interface{}
+}
+
+// operation 转换接口 body和formData参数转为requestBody 响应的schema移到content中
+func (c *converter) operation(op map[string]interface{}, swagger map[string]interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for key, value := range op {
+		switch key {
+		case "consumes", "produces", "parameters", "responses", "schemes":
+		default:
+			result[key] = c.refs(value)
+		}
+	}
+	consumes := stringsOf(op["consumes"])
+	if len(consumes) == 0 {
+		consumes = stringsOf(swagger["consumes"])
+	}
+	produces := stringsOf(op["produces"])
+	if len(produces) == 0 {
+		produces = stringsOf(swagger["produces"])
+	}
+	if len(produces) == 0 {
+		produces = []string{defaultMediaType}
+	}
+
+	var (
+		params       []interface{}
+		body         map[string]interface{}
+		formProps    = map[string]interface{}{}
+		formRequired []interface{}
+	)
+	for _, p := range sliceOf(op["parameters"]) {
+		param, err := c.resolveParameter(p)
+		if err != nil {
+			return nil, err
+		}
+		switch param["in"] {
+		case "body":
+			body = param
+		case "formData":
+			name, _ := param["name"].(string)
+			formProps[name] = c.formSchema(param)
+			if required, _ := param["required"].(bool); required {
+				formRequired = append(formRequired, name)
+			}
+		default:
+			converted, err := c.parameter(param)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, converted)
+		}
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+	if body != nil {
+		mediaTypes := consumes
+		if len(mediaTypes) == 0 {
+			mediaTypes = []string{defaultMediaType}
+		}
+		content := map[string]interface{}{}
+		schema := c.schema(body["schema"])
+		for _, mediaType := range mediaTypes {
+			content[mediaType] = map[string]interface{}{"schema": schema}
+		}
+		requestBody := map[string]interface{}{"content": content}
+		if description, ok := body["description"]; ok {
+			requestBody["description"] = description
+		}
+		if required, ok := body["required"]; ok {
+			requestBody["required"] = required
+		}
+		result["requestBody"] = requestBody
+	} else if len(formProps) > 0 {
+		mediaType := "multipart/form-data"
+		for _, consume := range consumes {
+			if consume == "application/x-www-form-urlencoded" {
+				mediaType = consume
+			}
+		}
+		schema := map[string]interface{}{
+			"type":       "object",
+			"properties": formProps,
+		}
+		if len(formRequired) > 0 {
+			schema["required"] = formRequired
+		}
+		result["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{
+				mediaType: map[string]interface{}{"schema": schema},
+			},
+		}
+	}
+
+	responses := map[string]interface{}{}
+	for code, resp := range mapOf(op["responses"]) {
+		respMap := mapOf(resp)
+		converted := map[string]interface{}{}
+		description, _ := respMap["description"].(string)
+		converted["description"] = description
+		if schema, ok := respMap["schema"]; ok {
+			content := map[string]interface{}{}
+			s := c.schema(schema)
+			for _, mediaType := range produces {
+				content[mediaType] = map[string]interface{}{"schema": s}
+			}
+			converted["content"] = content
+		}
+		if headers := mapOf(respMap["headers"]); len(headers) > 0 {
+			convertedHeaders := map[string]interface{}{}
+			for name, header := range headers {
+				h := mapOf(header)
+				convertedHeader := map[string]interface{}{"schema": c.schema(schemaOfParameter(h))}
+				if description, ok := h["description"]; ok {
+					convertedHeader["description"] = description
+				}
+				convertedHeaders[name] = convertedHeader
+			}
+			converted["headers"] = convertedHeaders
+		}
+		responses[code] = converted
+	}
+	if len(responses) == 0 {
+		responses["default"] = map[string]interface{}{"description": ""}
+	}
+	result["responses"] = responses
+	return result, nil
+}
+
+// resolveParameter 展开引用的公共参数
+func (c *converter) resolveParameter(p interface{}) (map[string]interface{}, error) {
+	param := mapOf(p)
+	if ref, ok := param["$ref"].(string); ok {
+		if !strings.HasPrefix(ref, parameterRefPrefix) {
+			return nil, fmt.Errorf("不支持的参数引用：%s", ref)
+		}
+		target := mapOf(c.parameters[strings.TrimPrefix(ref, parameterRefPrefix)])
+		if target == nil {
+			return nil, fmt.Errorf("参数%s不存在", ref)
+		}
+		return target, nil
+	}
+	return param, nil
+}
+
+// parameter 转换path、query和header参数 类型信息移到schema中
+func (c *converter) parameter(p interface{}) (map[string]interface{}, error) {
+	param, err := c.resolveParameter(p)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	for _, key := range []string{"name", "in", "description", "required", "deprecated", "allowEmptyValue"} {
+		if value, ok := param[key]; ok {
+			result[key] = value
+		}
+	}
+	if param["in"] == "path" {
+		result["required"] = true
+	}
+	schema, ok := param["schema"]
+	if !ok {
+		schema = schemaOfParameter(param)
+	}
+	result["schema"] = c.schema(schema)
+	return result, nil
+}
+
+// formSchema 表单字段的schema 文件类型转为二进制字符串
+func (c *converter) formSchema(param map[string]interface{}) interface{} {
+	if param["type"] == "file" {
+		schema := map[string]interface{}{"type": "string", "format": "binary"}
+		if description, ok := param["description"]; ok {
+			schema["description"] = description
+		}
+		return schema
+	}
+	schema := mapOf(c.schema(schemaOfParameter(param)))
+	if description, ok := param["description"]; ok {
+		schema["description"] = description
+	}
+	return schema
+}
+
+// schemaOfParameter swagger 2.0参数上的类型字段
+func schemaOfParameter(param map[string]interface{}) map[string]interface{} {
+	schema := map[string]interface{}{}
+	for _, key := range []string{"type", "format", "items", "enum", "default", "minimum", "maximum", "maxLength", "minLength", "pattern"} {
+		if value, ok := param[key]; ok {
+			schema[key] = value
+		}
+	}
+	if len(schema) == 0 {
+		schema["type"] = "string"
+	}
+	return schema
+}
+
+func (c *converter) schema(v interface{}) interface{} {
+	return c.refs(v)
+}
+
+// refs 把#/definitions/的引用改为#/components/schemas/ 同时处理重命名的定义
+func (c *converter) refs(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(x))
+		for key, value := range x {
+			if key == "$ref" {
+				if ref, ok := value.(string); ok && strings.HasPrefix(ref, swaggerRefPrefix) {
+					name := strings.TrimPrefix(ref, swaggerRefPrefix)
+					if renamed, ok := c.renames[name]; ok {
+						name = renamed
+					}
+					result[key] = schemaRefPrefix + name
+					continue
+				}
+			}
+			if key == "x-nullable" {
+				result["nullable"] = value
+				continue
+			}
+			result[key] = c.refs(value)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(x))
+		for _, value := range x {
+			result = append(result, c.refs(value))
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func securityScheme(v interface{}) interface{} {
+	scheme := mapOf(v)
+	if scheme["type"] == "basic" {
+		return map[string]interface{}{"type": "http", "scheme": "basic"}
+	}
+	return scheme
+}
+
+// normalize yaml中的数字key（例如状态码）转为字符串
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for key, value := range x {
+			x[key] = normalize(value)
+		}
+		return x
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(x))
+		for key, value := range x {
+			result[fmt.Sprint(key)] = normalize(value)
+		}
+		return result
+	case []interface{}:
+		for i, value := range x {
+			x[i] = normalize(value)
+		}
+		return x
+	default:
+		return v
+	}
+}
+
+func equalYAML(a, b interface{}) bool {
+	da, err := yaml.Marshal(a)
+	if err != nil {
+		return false
+	}
+	db, err := yaml.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(da) == string(db)
+}
+
+func mapOf(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func sliceOf(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+func stringsOf(v interface{}) []string {
+	var result []string
+	for _, item := range sliceOf(v) {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func mapValue(m map[string]interface{}, key string) map[string]interface{} {
+	return mapOf(m[key])
+}
+
+func sliceValue(m map[string]interface{}, key string) []interface{} {
+	return sliceOf(m[key])
+}
+
+func stringValue(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
@@ -0,0 +1,169 @@
+package apidoc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testUserDoc = `
+swagger: "2.0"
+basePath: "/v1"
+tags:
+  - name: "user"
+paths:
+  /users/{uid}:
+    put:
+      operationId: "update user"
+      consumes:
+        - "application/json"
+      parameters:
+        - $ref: "#/parameters/uid"
+        - in: "body"
+          name: "body"
+          required: true
+          schema:
+            $ref: "#/definitions/user"
+      responses:
+        200:
+          description: "返回"
+          schema:
+            $ref: "#/definitions/response"
+  /users/{uid}/avatar:
+    post:
+      operationId: "upload avatar"
+      parameters:
+        - in: "path"
+          name: "uid"
+          type: string
+        - in: "formData"
+          name: "file"
+          type: file
+          required: true
+parameters:
+  uid:
+    in: "path"
+    name: "uid"
+    type: string
+definitions:
+  user:
+    type: object
+    properties:
+      name:
+        type: string
+  response:
+    type: object
+    properties:
+      msg:
+        type: string
+`
+
+const testGroupDoc = `
+swagger: "2.0"
+basePath: "/v1"
+tags:
+  - name: "group"
+  - name: "user"
+paths:
+  /groups/{group_no}:
+    get:
+      operationId: "get group"
+      parameters:
+        - in: "path"
+          name: "group_no"
+          type: string
+      responses:
+        200:
+          description: "返回"
+          schema:
+            $ref: "#/definitions/user"
+definitions:
+  user:
+    type: object
+    properties:
+      uid:
+        type: string
+  response:
+    type: object
+    properties:
+      msg:
+        type: string
+`
+
+func TestConvert(t *testing.T) {
+	doc, err := Convert(Info{Title: "test", Version: "1.0.0"}, []Doc{
+		{Name: "user", Content: []byte(testUserDoc)},
+		{Name: "group", Content: []byte(testGroupDoc)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Len(t, doc["tags"], 2)
+
+	paths := doc["paths"].(map[string]interface{})
+	update := paths["/v1/users/{uid}"].(map[string]interface{})["put"].(map[string]interface{})
+	// 引用的公共参数展开 路径参数必填
+	params := update["parameters"].([]interface{})
+	assert.Len(t, params, 1)
+	assert.Equal(t, true, params[0].(map[string]interface{})["required"])
+	assert.Equal(t, map[string]interface{}{"type": "string"}, params[0].(map[string]interface{})["schema"])
+	// body参数转为requestBody 同名但内容不同的定义加上文档名前缀
+	content := update["requestBody"].(map[string]interface{})["content"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/user_user"}, content["application/json"].(map[string]interface{})["schema"])
+
+	upload := paths["/v1/users/{uid}/avatar"].(map[string]interface{})["post"].(map[string]interface{})
+	form := upload["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["multipart/form-data"].(map[string]interface{})["schema"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"file"}, form["required"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "binary"}, form["properties"].(map[string]interface{})["file"])
+	// 没有响应时补上default
+	assert.Contains(t, upload["responses"], "default")
+
+	group := paths["/v1/groups/{group_no}"].(map[string]interface{})["get"].(map[string]interface{})
+	resp := group["responses"].(map[string]interface{})["200"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/group_user"}, resp["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.Contains(t, schemas, "user_user")
+	assert.Contains(t, schemas, "group_user")
+	// 内容相同的定义只保留一个
+	assert.Contains(t, schemas, "response")
+	assert.NotContains(t, schemas, "user_response")
+}
+
+func TestConvertDuplicate(t *testing.T) {
+	_, err := Convert(Info{}, []Doc{
+		{Name: "user", Content: []byte(testUserDoc)},
+		{Name: "user2", Content: []byte(testUserDoc)},
+	})
+	assert.Error(t, err)
+}
+
+func TestScanRoutes(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "api.go"), []byte(`package user
+
+func (u *User) Route(r *wkhttp.WKHttp) {
+	v := r.Group("/v1")
+	{
+		v.GET("/users/:uid", u.get)
+		v.Handle(http.MethodHead, "/users/:uid/avatar", u.avatar)
+	}
+	auth := v.Group("/manager/", u.ctx.AuthMiddleware(r))
+	{
+		auth.Any("/user/*path", u.proxy)
+	}
+	u.Info("注册接口", zap.Any("members", 1))
+}
+`), 0644)
+	assert.NoError(t, err)
+
+	routes, err := ScanRoutes(dir)
+	assert.NoError(t, err)
+	file := filepath.ToSlash(filepath.Join(dir, "api.go"))
+	assert.Equal(t, []Endpoint{
+		{Method: "any", Path: "/v1/manager/user/{path}", File: file},
+		{Method: "get", Path: "/v1/users/{uid}", File: file},
+		{Method: "head", Path: "/v1/users/{uid}/avatar", File: file},
+	}, routes)
+}
@@ -0,0 +1,31 @@
+//go:build ignore
+
+// 生成openapi.json 并列出没有写入swagger文档的接口
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/apidoc"
+)
+
+func main() {
+	spec, err := apidoc.Generate("../..")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile("openapi.json", spec, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	undocumented, err := apidoc.Undocumented("../..", spec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, route := range undocumented {
+		fmt.Fprintf(os.Stderr, "没有文档的接口：%s %s（%s）\n", route.Method, route.Path, route.File)
+	}
+}
@@ -0,0 +1,216 @@
+package apidoc
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Endpoint 代码中注册的接口
+type Endpoint struct {
+	Method string // get post put delete patch 通过Any注册的为any
+	Path   string // OpenAPI格式的路径 例如 /v1/users/{uid}
+	File   string // 注册接口的文件
+}
+
+var routeMethods = map[string]string{
+	"GET":     "get",
+	"POST":    "post",
+	"PUT":     "put",
+	"DELETE":  "delete",
+	"PATCH":   "patch",
+	"HEAD":    "head",
+	"OPTIONS": "options",
+	"Any":     "any",
+}
+
+var pathParamRegexp = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// openAPIPath gin的路径转为OpenAPI的路径 /users/:uid -> /users/{uid}
+func openAPIPath(path string) string {
+	return pathParamRegexp.ReplaceAllString(path, "{$1}")
+}
+
+// ScanRoutes 扫描目录下（不含测试文件）通过r.GET("/xxx")、r.Handle(http.MethodHead, "/xxx")或分组g := r.Group("/v1")注册的接口
+// 只能识别路径为字符串常量的注册方式
+func ScanRoutes(dir string) ([]Endpoint, error) {
+	var routes []Endpoint
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		fileRoutes, err := scanFile(path)
+		if err != nil {
+			return err
+		}
+		routes = append(routes, fileRoutes...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes, nil
+}
+
+func scanFile(path string) ([]Endpoint, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var routes []Endpoint
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		// 每个函数内分组变量的前缀
+		prefixes := map[string]string{}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.AssignStmt:
+				if len(x.Lhs) != 1 || len(x.Rhs) != 1 {
+					return true
+				}
+				name, ok := x.Lhs[0].(*ast.Ident)
+				if !ok {
+					return true
+				}
+				recv, method, arg, ok := routeCall(x.Rhs[0])
+				if ok && method == "Group" {
+					prefixes[name.Name] = joinPaths(prefixes[recv], arg)
+				}
+			case *ast.CallExpr:
+				recv, method, arg, ok := routeCall(x)
+				if !ok {
+					return true
+				}
+				m, ok := routeMethods[method]
+				if !ok {
+					return true
+				}
+				// 不是以/开头的不是接口 例如zap.Any("members", ...)
+				fullPath := joinPaths(prefixes[recv], arg)
+				if strings.HasPrefix(fullPath, "/") {
+					routes = append(routes, Endpoint{
+						Method: m,
+						Path:   openAPIPath(fullPath),
+						File:   filepath.ToSlash(path),
+					})
+				}
+			}
+			return true
+		})
+	}
+	return routes, nil
+}
+
+// routeCall 解析 recv.Method("字符串", ...) 和 recv.Handle(http.MethodXxx, "字符串", ...) 形式的调用
+func routeCall(expr ast.Expr) (recv string, method string, arg string, ok bool) {
+	call, isCall := expr.(*ast.CallExpr)
+	if !isCall || len(call.Args) == 0 {
+		return
+	}
+	sel, isSel := call.Fun.(*ast.SelectorExpr)
+	if !isSel {
+		return
+	}
+	ident, isIdent := sel.X.(*ast.Ident)
+	if !isIdent {
+		return
+	}
+	method = sel.Sel.Name
+	pathArg := call.Args[0]
+	if method == "Handle" {
+		if len(call.Args) < 2 {
+			return
+		}
+		method, ok = handleMethod(call.Args[0])
+		if !ok {
+			return
+		}
+		pathArg = call.Args[1]
+	}
+	value, isString := stringLit(pathArg)
+	if !isString {
+		return "", "", "", false
+	}
+	return ident.Name, method, value, true
+}
+
+// handleMethod Handle的第一个参数 http.MethodHead或"HEAD"
+func handleMethod(expr ast.Expr) (string, bool) {
+	if value, ok := stringLit(expr); ok {
+		return strings.ToUpper(value), true
+	}
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || !strings.HasPrefix(sel.Sel.Name, "Method") {
+		return "", false
+	}
+	return strings.ToUpper(strings.TrimPrefix(sel.Sel.Name, "Method")), true
+}
+
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+// joinPaths 与gin拼接分组路径的方式相同
+func joinPaths(absolute string, relative string) string {
+	if relative == "" {
+		return absolute
+	}
+	final := path.Join(absolute, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(final, "/") {
+		return final + "/"
+	}
+	return final
+}
+
+// Undocumented 代码中注册了但是文档中没有的接口
+func Undocumented(root string, spec []byte) ([]Endpoint, error) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, err
+	}
+	routes, err := ScanRoutes(filepath.Join(root, "modules"))
+	if err != nil {
+		return nil, err
+	}
+	var undocumented []Endpoint
+	for _, route := range routes {
+		item := doc.Paths[route.Path]
+		if route.Method == "any" {
+			if len(item) == 0 {
+				undocumented = append(undocumented, route)
+			}
+			continue
+		}
+		if _, ok := item[route.Method]; !ok {
+			undocumented = append(undocumented, route)
+		}
+	}
+	return undocumented, nil
+}
@@ -0,0 +1,252 @@
+package backup
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"unicode/utf8"
+)
+
+// 备份文件的格式
+const (
+	Format        = "tsdd-backup"
+	FormatVersion = 1
+	manifestName  = "manifest.json"
+)
+
+// Manifest 备份文件的说明 保存为manifest.json 最后写入 包含其他文件的行数和校验值
+type Manifest struct {
+	Format        string        `json:"format"`
+	FormatVersion int           `json:"format_version"`
+	Version       string        `json:"version"`    // 服务的版本
+	CreatedAt     int64         `json:"created_at"` // 开始备份的时间
+	Tables        []*TableEntry `json:"tables"`
+	Redis         *Entry        `json:"redis"`
+	Files         *FilesEntry   `json:"files"`
+	Notes         []string      `json:"notes"`
+}
+
+// Entry 备份文件中的一个文件
+type Entry struct {
+	File   string `json:"file"`
+	Count  int    `json:"count"`  // 行数
+	SHA256 string `json:"sha256"` // 文件内容的sha256
+}
+
+// TableEntry 一个表的数据 每行为按Columns顺序的值的JSON数组
+type TableEntry struct {
+	Entry
+	Name    string   `json:"name"`
+	Schema  string   `json:"schema"` // 建表语句
+	Columns []string `json:"columns"`
+}
+
+// FilesEntry 文件服务中的文件清单 只包含路径和大小 文件内容需要从文件服务单独备份
+type FilesEntry struct {
+	Entry
+	Storage string `json:"storage"` // 文件服务类型 例如 minio
+}
+
+// notes 备份文件的说明
+var notes = []string{
+	"消息正文保存在悟空IM，不在备份范围内",
+	"files.jsonl只是文件清单，文件内容需要从文件服务（例如minio的数据目录）单独备份",
+	"restore会删除并重建备份中的表，redis中备份的key会被覆盖",
+}
+
+// archiveWriter 写入zip 每个文件按行写入并计算行数和校验值
+type archiveWriter struct {
+	zw *zip.Writer
+}
+
+func newArchiveWriter(w io.Writer) *archiveWriter {
+	return &archiveWriter{zw: zip.NewWriter(w)}
+}
+
+// lineWriter 一个jsonl文件
+type lineWriter struct {
+	w     io.Writer
+	hash  hash.Hash
+	entry *Entry
+}
+
+func (a *archiveWriter) create(name string) (*lineWriter, error) {
+	fw, err := a.zw.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	return &lineWriter{
+		w:     io.MultiWriter(fw, h),
+		hash:  h,
+		entry: &Entry{File: name},
+	}, nil
+}
+
+// writeLine 写入一行JSON
+func (l *lineWriter) writeLine(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err = l.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	l.entry.Count++
+	return nil
+}
+
+func (l *lineWriter) close() Entry {
+	l.entry.SHA256 = hex.EncodeToString(l.hash.Sum(nil))
+	return *l.entry
+}
+
+// close 写入说明并结束zip
+func (a *archiveWriter) close(m *Manifest) error {
+	fw, err := a.zw.Create(manifestName)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err = fw.Write(data); err != nil {
+		return err
+	}
+	return a.zw.Close()
+}
+
+// Verify 校验备份文件 说明的格式、每个文件的行数和校验值都一致时返回说明
+func Verify(r io.ReaderAt, size int64) (*Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的备份文件：%w", err)
+	}
+	return verifyZip(zr)
+}
+
+func verifyZip(zr *zip.Reader) (*Manifest, error) {
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	mf, ok := files[manifestName]
+	if !ok {
+		return nil, errors.New("备份文件中没有manifest.json")
+	}
+	rc, err := mf.Open()
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	err = json.NewDecoder(rc).Decode(&m)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("解析manifest.json失败：%w", err)
+	}
+	if m.Format != Format {
+		return nil, errors.New("不是有效的备份文件")
+	}
+	if m.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("不支持的备份格式版本：%d", m.FormatVersion)
+	}
+	entries := make([]Entry, 0, len(m.Tables)+2)
+	for _, t := range m.Tables {
+		entries = append(entries, t.Entry)
+	}
+	if m.Redis != nil {
+		entries = append(entries, *m.Redis)
+	}
+	if m.Files != nil {
+		entries = append(entries, m.Files.Entry)
+	}
+	for _, entry := range entries {
+		f, ok := files[entry.File]
+		if !ok {
+			return nil, fmt.Errorf("备份文件中没有%s", entry.File)
+		}
+		count, sum, err := checksum(f)
+		if err != nil {
+			return nil, fmt.Errorf("读取%s失败：%w", entry.File, err)
+		}
+		if count != entry.Count || sum != entry.SHA256 {
+			return nil, fmt.Errorf("%s的校验值不一致，备份文件可能已损坏", entry.File)
+		}
+	}
+	return &m, nil
+}
+
+// checksum 文件的行数和sha256
+func checksum(f *zip.File) (int, string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	count := 0
+	err = eachLine(io.TeeReader(rc, h), func([]byte) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	return count, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// maxLineSize 一行最大的长度 一行为一条数据库记录或一个redis key
+const maxLineSize = 64 * 1024 * 1024
+
+// eachLine 按行读取jsonl
+func eachLine(r io.Reader, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// binaryValue 不是utf8的值（例如blob） 使用base64保存
+type binaryValue struct {
+	Base64 string `json:"base64"`
+}
+
+// encodeValue 数据库的值保存到JSON null为nil
+func encodeValue(v []byte, null bool) interface{} {
+	if null {
+		return nil
+	}
+	if utf8.Valid(v) {
+		return string(v)
+	}
+	return binaryValue{Base64: base64.StdEncoding.EncodeToString(v)}
+}
+
+// decodeValue JSON中的值转换为插入数据库的参数
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+	var b binaryValue
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(b.Base64)
+}
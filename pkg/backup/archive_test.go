@@ -0,0 +1,106 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestArchive(t *testing.T, rows [][]interface{}) []byte {
+	var buf bytes.Buffer
+	aw := newArchiveWriter(&buf)
+	lw, err := aw.create("mysql/user.jsonl")
+	assert.NoError(t, err)
+	for _, row := range rows {
+		assert.NoError(t, lw.writeLine(row))
+	}
+	m := &Manifest{Format: Format, FormatVersion: FormatVersion, Version: "test"}
+	m.Tables = append(m.Tables, &TableEntry{Entry: lw.close(), Name: "user", Columns: []string{"uid", "name"}})
+	flw, err := aw.create("files.jsonl")
+	assert.NoError(t, err)
+	m.Files = &FilesEntry{Entry: flw.close(), Storage: "minio"}
+	assert.NoError(t, aw.close(m))
+	return buf.Bytes()
+}
+
+// rewriteArchive 修改备份中的一个文件
+func rewriteArchive(t *testing.T, data []byte, name string, fn func([]byte) []byte) []byte {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		assert.NoError(t, err)
+		if f.Name == name {
+			content = fn(content)
+		}
+		fw, err := zw.Create(f.Name)
+		assert.NoError(t, err)
+		_, err = fw.Write(content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestVerify(t *testing.T) {
+	data := writeTestArchive(t, [][]interface{}{
+		{encodeValue([]byte("u1"), false), encodeValue(nil, true)},
+		{encodeValue([]byte("u2"), false), encodeValue([]byte("张三"), false)},
+	})
+	m, err := Verify(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, "test", m.Version)
+	assert.Equal(t, 2, m.Tables[0].Count)
+	assert.Equal(t, 0, m.Files.Count)
+
+	// 修改数据后校验失败
+	tampered := rewriteArchive(t, data, "mysql/user.jsonl", func(b []byte) []byte {
+		return bytes.Replace(b, []byte(`"u2"`), []byte(`"u3"`), 1)
+	})
+	_, err = Verify(bytes.NewReader(tampered), int64(len(tampered)))
+	assert.Error(t, err)
+
+	_, err = Verify(bytes.NewReader([]byte("not a zip")), 9)
+	assert.Error(t, err)
+}
+
+func TestVerifyMissingManifest(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_, err := zw.Create("mysql/user.jsonl")
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	_, err = Verify(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.Error(t, err)
+}
+
+func TestEncodeValue(t *testing.T) {
+	for _, v := range [][]byte{[]byte("abc"), []byte(""), {0xff, 0x00, 0xfe}} {
+		data, err := json.Marshal(encodeValue(v, false))
+		assert.NoError(t, err)
+		decoded, err := decodeValue(data)
+		assert.NoError(t, err)
+		switch d := decoded.(type) {
+		case string:
+			assert.Equal(t, string(v), d)
+		case []byte:
+			assert.Equal(t, v, d)
+		default:
+			t.Fatalf("unexpected type %T", decoded)
+		}
+	}
+	data, err := json.Marshal(encodeValue(nil, true))
+	assert.NoError(t, err)
+	decoded, err := decodeValue(data)
+	assert.NoError(t, err)
+	assert.Nil(t, decoded)
+}
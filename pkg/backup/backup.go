@@ -0,0 +1,330 @@
+// Package backup 小规模私有部署的备份和恢复
+//
+// 备份文件为zip：
+//
+//	mysql/{表名}.jsonl  每行为一条记录（按列顺序的值） 建表语句在manifest.json中
+//	redis.jsonl         按key的模式备份的redis数据（同步版本号、红点等） 按数据类型保存 不依赖redis的版本
+//	files.jsonl         文件服务中的文件清单
+//	manifest.json       版本、每个文件的行数和sha256 恢复前先校验
+//
+// 数据库在一个只读的可重复读事务中导出 保证各表的数据一致
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	rd "github.com/go-redis/redis"
+)
+
+// Options 备份的配置
+type Options struct {
+	Version       string     // 服务的版本 写入manifest
+	Storage       string     // 文件服务类型 写入manifest
+	Redis         *rd.Client // 为nil时不备份redis
+	RedisPatterns []string   // 需要备份的redis key的模式 例如 userMaxVersion:*
+	ExcludeTables []string   // 不备份的表
+}
+
+// errNotMySQL 备份文件中的建表语句和数据格式为MySQL的
+var errNotMySQL = errors.New("备份和恢复只支持MySQL")
+
+func checkDriver() error {
+	if extconfig.Get().Database.Driver == "postgres" {
+		return errNotMySQL
+	}
+	return nil
+}
+
+// newRedisClient 备份和恢复使用的redis连接 用完需要关闭
+func newRedisClient(ctx *config.Context) *rd.Client {
+	return rd.NewClient(&rd.Options{
+		Addr:       ctx.GetConfig().DB.RedisAddr,
+		Password:   ctx.GetConfig().DB.RedisPass,
+		MaxRetries: 3,
+	})
+}
+
+// Run 按配置备份服务的数据到w
+func Run(ctx context.Context, appCtx *config.Context, w io.Writer) (*Manifest, error) {
+	if err := checkDriver(); err != nil {
+		return nil, err
+	}
+	cfg := extconfig.Get().Backup
+	client := newRedisClient(appCtx)
+	defer client.Close()
+	return Backup(ctx, w, appCtx.DB().DB, Options{
+		Version:       appCtx.GetConfig().Version,
+		Storage:       appCtx.GetConfig().FileService.String(),
+		Redis:         client,
+		RedisPatterns: cfg.RedisPatterns,
+		ExcludeTables: cfg.ExcludeTables,
+	})
+}
+
+// Backup 导出数据库、redis和文件清单到w
+func Backup(ctx context.Context, w io.Writer, db *sql.DB, opts Options) (*Manifest, error) {
+	m := &Manifest{
+		Format:        Format,
+		FormatVersion: FormatVersion,
+		Version:       opts.Version,
+		CreatedAt:     time.Now().Unix(),
+		Notes:         notes,
+	}
+	aw := newArchiveWriter(w)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tables, err := listTables(ctx, tx, opts.ExcludeTables)
+	if err != nil {
+		return nil, err
+	}
+	hasFileTable := false
+	for _, table := range tables {
+		entry, err := backupTable(ctx, tx, aw, table)
+		if err != nil {
+			return nil, fmt.Errorf("备份表%s失败：%w", table, err)
+		}
+		m.Tables = append(m.Tables, entry)
+		if table == "file" {
+			hasFileTable = true
+		}
+	}
+	m.Files = &FilesEntry{Storage: opts.Storage}
+	if m.Files.Entry, err = backupFiles(ctx, tx, aw, hasFileTable); err != nil {
+		return nil, fmt.Errorf("备份文件清单失败：%w", err)
+	}
+	if opts.Redis != nil {
+		entry, err := backupRedis(opts.Redis, aw, opts.RedisPatterns)
+		if err != nil {
+			return nil, fmt.Errorf("备份redis失败：%w", err)
+		}
+		m.Redis = &entry
+	}
+	if err = aw.close(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// listTables 需要备份的表
+func listTables(ctx context.Context, tx *sql.Tx, exclude []string) ([]string, error) {
+	excluded := make(map[string]bool, len(exclude))
+	for _, table := range exclude {
+		excluded[strings.TrimSpace(table)] = true
+	}
+	rows, err := tx.QueryContext(ctx, "SHOW FULL TABLES WHERE Table_type = 'BASE TABLE'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := make([]string, 0, 100)
+	for rows.Next() {
+		var table, tableType string
+		if err := rows.Scan(&table, &tableType); err != nil {
+			return nil, err
+		}
+		if !excluded[table] {
+			tables = append(tables, table)
+		}
+	}
+	return tables, rows.Err()
+}
+
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func backupTable(ctx context.Context, tx *sql.Tx, aw *archiveWriter, table string) (*TableEntry, error) {
+	var name, schema string
+	if err := tx.QueryRowContext(ctx, "SHOW CREATE TABLE "+quoteName(table)).Scan(&name, &schema); err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT * FROM "+quoteName(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	lw, err := aw.create("mysql/" + table + ".jsonl")
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	row := make([]interface{}, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			row[i] = encodeValue(v, v == nil)
+		}
+		if err := lw.writeLine(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &TableEntry{
+		Entry:   lw.close(),
+		Name:    table,
+		Schema:  schema,
+		Columns: columns,
+	}, nil
+}
+
+// fileItem 文件清单中的一个文件
+type fileItem struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// backupFiles 文件清单 来自file表 没有file表时为空
+func backupFiles(ctx context.Context, tx *sql.Tx, aw *archiveWriter, hasFileTable bool) (Entry, error) {
+	lw, err := aw.create("files.jsonl")
+	if err != nil {
+		return Entry{}, err
+	}
+	if !hasFileTable {
+		return lw.close(), nil
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT path, size, content_type FROM `file` ORDER BY id")
+	if err != nil {
+		return Entry{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item fileItem
+		if err := rows.Scan(&item.Path, &item.Size, &item.ContentType); err != nil {
+			return Entry{}, err
+		}
+		if err := lw.writeLine(&item); err != nil {
+			return Entry{}, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Entry{}, err
+	}
+	return lw.close(), nil
+}
+
+// redisItem redis中的一个key
+type redisItem struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`   // string、hash、set、zset、list
+	TTLMs int64       `json:"ttl_ms"` // 剩余的过期时间 0为不过期
+	Value interface{} `json:"value"`
+}
+
+// zsetMember 有序集合的成员
+type zsetMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+func backupRedis(client *rd.Client, aw *archiveWriter, patterns []string) (Entry, error) {
+	lw, err := aw.create("redis.jsonl")
+	if err != nil {
+		return Entry{}, err
+	}
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(cursor, pattern, 1000).Result()
+			if err != nil {
+				return Entry{}, err
+			}
+			for _, key := range keys {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				item, err := redisValue(client, key)
+				if err != nil {
+					return Entry{}, fmt.Errorf("读取%s失败：%w", key, err)
+				}
+				if item == nil { // 已过期或已删除
+					continue
+				}
+				if err := lw.writeLine(item); err != nil {
+					return Entry{}, err
+				}
+			}
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+	return lw.close(), nil
+}
+
+func redisValue(client *rd.Client, key string) (*redisItem, error) {
+	keyType, err := client.Type(key).Result()
+	if err != nil {
+		return nil, err
+	}
+	item := &redisItem{Key: key, Type: keyType}
+	switch keyType {
+	case "none":
+		return nil, nil
+	case "string":
+		item.Value, err = client.Get(key).Result()
+	case "hash":
+		item.Value, err = client.HGetAll(key).Result()
+	case "set":
+		item.Value, err = client.SMembers(key).Result()
+	case "list":
+		item.Value, err = client.LRange(key, 0, -1).Result()
+	case "zset":
+		var zs []rd.Z
+		zs, err = client.ZRangeWithScores(key, 0, -1).Result()
+		members := make([]zsetMember, 0, len(zs))
+		for _, z := range zs {
+			members = append(members, zsetMember{Member: fmt.Sprint(z.Member), Score: z.Score})
+		}
+		item.Value = members
+	default:
+		return nil, fmt.Errorf("不支持的类型：%s", keyType)
+	}
+	if err == rd.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := client.PTTL(key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		item.TTLMs = ttl.Milliseconds()
+	}
+	return item, nil
+}
@@ -0,0 +1,226 @@
+package backup
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	rd "github.com/go-redis/redis"
+)
+
+// RestoreOptions 恢复的配置
+type RestoreOptions struct {
+	Redis *rd.Client // 为nil时不恢复redis
+}
+
+// insertBatch 每条INSERT语句插入的行数
+const insertBatch = 200
+
+// Restore 校验备份文件后恢复数据库和redis
+// 备份中的表会被删除并重建 恢复后校验每个表的行数
+func Restore(ctx context.Context, r io.ReaderAt, size int64, db *sql.DB, opts RestoreOptions) (*Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的备份文件：%w", err)
+	}
+	m, err := verifyZip(zr)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	// FOREIGN_KEY_CHECKS只对当前连接有效
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "SET FOREIGN_KEY_CHECKS = 1")
+	for _, t := range m.Tables {
+		if err := restoreTable(ctx, conn, files[t.File], t); err != nil {
+			return nil, fmt.Errorf("恢复表%s失败：%w", t.Name, err)
+		}
+	}
+	if opts.Redis != nil && m.Redis != nil {
+		if err := restoreRedis(opts.Redis, files[m.Redis.File]); err != nil {
+			return nil, fmt.Errorf("恢复redis失败：%w", err)
+		}
+	}
+	return m, nil
+}
+
+// RunRestore 恢复服务的数据 skipRedis为true时只恢复数据库
+func RunRestore(ctx context.Context, appCtx *config.Context, r io.ReaderAt, size int64, skipRedis bool) (*Manifest, error) {
+	if err := checkDriver(); err != nil {
+		return nil, err
+	}
+	var opts RestoreOptions
+	if !skipRedis {
+		client := newRedisClient(appCtx)
+		defer client.Close()
+		opts.Redis = client
+	}
+	return Restore(ctx, r, size, appCtx.DB().DB, opts)
+}
+
+func restoreTable(ctx context.Context, conn *sql.Conn, f *zip.File, t *TableEntry) error {
+	table := quoteName(t.Name)
+	if _, err := conn.ExecContext(ctx, "DROP TABLE IF EXISTS "+table); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, t.Schema); err != nil {
+		return err
+	}
+	columns := make([]string, 0, len(t.Columns))
+	for _, column := range t.Columns {
+		columns = append(columns, quoteName(column))
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ","))
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	args := make([]interface{}, 0, insertBatch*len(columns))
+	rows := 0
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		stmt := insertSQL + strings.TrimSuffix(strings.Repeat(placeholder+",", rows), ",")
+		if _, err := conn.ExecContext(ctx, stmt, args...); err != nil {
+			return err
+		}
+		args = args[:0]
+		rows = 0
+		return nil
+	}
+	err = eachLine(rc, func(line []byte) error {
+		var values []json.RawMessage
+		if err := json.Unmarshal(line, &values); err != nil {
+			return err
+		}
+		if len(values) != len(t.Columns) {
+			return fmt.Errorf("列数不一致：%d != %d", len(values), len(t.Columns))
+		}
+		for _, raw := range values {
+			v, err := decodeValue(raw)
+			if err != nil {
+				return err
+			}
+			args = append(args, v)
+		}
+		rows++
+		if rows >= insertBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err = flush(); err != nil {
+		return err
+	}
+	var count int
+	if err = conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&count); err != nil {
+		return err
+	}
+	if count != t.Count {
+		return fmt.Errorf("恢复后的行数不一致：%d != %d", count, t.Count)
+	}
+	return nil
+}
+
+func restoreRedis(client *rd.Client, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return eachLine(rc, func(line []byte) error {
+		var item struct {
+			redisItem
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(line, &item); err != nil {
+			return err
+		}
+		if err := restoreRedisKey(client, item.Key, item.Type, item.Value); err != nil {
+			return fmt.Errorf("恢复%s失败：%w", item.Key, err)
+		}
+		if item.TTLMs > 0 {
+			return client.PExpire(item.Key, time.Duration(item.TTLMs)*time.Millisecond).Err()
+		}
+		return nil
+	})
+}
+
+func restoreRedisKey(client *rd.Client, key, keyType string, raw json.RawMessage) error {
+	pipe := client.TxPipeline()
+	pipe.Del(key)
+	switch keyType {
+	case "string":
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		pipe.Set(key, value, 0)
+	case "hash":
+		var value map[string]string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		fields := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			fields[k] = v
+		}
+		if len(fields) > 0 {
+			pipe.HMSet(key, fields)
+		}
+	case "set", "list":
+		var value []string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		members := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			members = append(members, v)
+		}
+		if len(members) > 0 && keyType == "set" {
+			pipe.SAdd(key, members...)
+		} else if len(members) > 0 {
+			pipe.RPush(key, members...)
+		}
+	case "zset":
+		var value []zsetMember
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		members := make([]rd.Z, 0, len(value))
+		for _, v := range value {
+			members = append(members, rd.Z{Member: v.Member, Score: v.Score})
+		}
+		if len(members) > 0 {
+			pipe.ZAdd(key, members...)
+		}
+	default:
+		return fmt.Errorf("不支持的类型：%s", keyType)
+	}
+	_, err := pipe.Exec()
+	return err
+}
@@ -0,0 +1,149 @@
+// Package cursor 列表接口的游标分页
+//
+// 请求时传limit和上一页返回的next_cursor（第一页传空） 返回
+//
+//	{"list": [...], "next_cursor": "xxx", "has_more": 1}
+//
+// 游标是本页最后一条数据的排序键编码后的字符串 客户端不需要解析
+// 列表接口原来的分页参数继续可用 请求中带了cursor参数（可以为空）时才按游标分页返回
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+)
+
+const (
+	DefaultLimit = 20  // 默认每页数量
+	MaxLimit     = 100 // 每页最大数量
+
+	MaxPageSize = 1000 // 旧的page_index/page_size参数每页最大数量
+)
+
+// ErrInvalidCursor 游标格式有误
+var ErrInvalidCursor = errors.New("cursor格式有误！")
+
+const maxKeys = 4
+
+// Page 游标分页的请求参数
+type Page struct {
+	Limit int     // 每页数量
+	keys  []int64 // 上一页最后一条数据的排序键 第一页为空
+}
+
+// Parse 解析游标和每页数量 limit小于等于0时为defaultLimit 超过maxLimit时为maxLimit
+func Parse(cursor string, limit int, defaultLimit int, maxLimit int) (Page, error) {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	page := Page{Limit: limit}
+	cursor = strings.TrimSpace(cursor)
+	if cursor == "" {
+		return page, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return page, ErrInvalidCursor
+	}
+	values := strings.Split(string(data), ",")
+	if len(values) > maxKeys {
+		return page, ErrInvalidCursor
+	}
+	keys := make([]int64, 0, len(values))
+	for _, value := range values {
+		key, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return page, ErrInvalidCursor
+		}
+		keys = append(keys, key)
+	}
+	page.keys = keys
+	return page, nil
+}
+
+// Query 从query中读取cursor和limit 每页默认DefaultLimit条 最多MaxLimit条
+func Query(c *wkhttp.Context) (Page, error) {
+	return QueryWithLimit(c, DefaultLimit, MaxLimit)
+}
+
+// QueryWithLimit 从query中读取cursor和limit 同步类接口每页数量较大时使用
+func QueryWithLimit(c *wkhttp.Context, defaultLimit int, maxLimit int) (Page, error) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	return Parse(c.Query("cursor"), limit, defaultLimit, maxLimit)
+}
+
+// Requested 请求中是否带了cursor参数 没带时列表接口按原来的格式返回
+func Requested(c *wkhttp.Context) bool {
+	_, ok := c.GetQuery("cursor")
+	return ok
+}
+
+// OffsetPage 读取旧的page_index和page_size参数 page_size不超过MaxPageSize
+func OffsetPage(c *wkhttp.Context) (pageIndex uint64, pageSize uint64) {
+	index, size := c.GetPage()
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+	return uint64(index), uint64(size)
+}
+
+// First 是否是第一页
+func (p Page) First() bool {
+	return len(p.keys) == 0
+}
+
+// Key 上一页最后一条数据的第i个排序键 第一页或没有该排序键时为0
+func (p Page) Key(i int) int64 {
+	if i < 0 || i >= len(p.keys) {
+		return 0
+	}
+	return p.keys[i]
+}
+
+// FetchLimit 查询的数量 多查一条用于判断是否还有更多数据
+func (p Page) FetchLimit() uint64 {
+	return uint64(p.Limit) + 1
+}
+
+// Keep 按FetchLimit查询到n条数据后 本页返回的数量和是否还有更多数据
+func (p Page) Keep(n int) (int, bool) {
+	if n > p.Limit {
+		return p.Limit, true
+	}
+	return n, false
+}
+
+// Encode 把最后一条数据的排序键编码为游标
+func Encode(keys ...int64) string {
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, strconv.FormatInt(key, 10))
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(values, ",")))
+}
+
+// Result 游标分页的返回
+type Result struct {
+	List       interface{} `json:"list"`
+	NextCursor string      `json:"next_cursor"` // 下一页的游标 没有更多数据时为空
+	HasMore    int         `json:"has_more"`    // 是否还有更多数据 1.是
+}
+
+// NewResult list为本页数据（不能为nil） keys为本页最后一条数据的排序键
+func NewResult(list interface{}, hasMore bool, keys ...int64) *Result {
+	result := &Result{
+		List: list,
+	}
+	if hasMore {
+		result.HasMore = 1
+		result.NextCursor = Encode(keys...)
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	page, err := Parse("", 0, DefaultLimit, MaxLimit)
+	assert.NoError(t, err)
+	assert.True(t, page.First())
+	assert.Equal(t, DefaultLimit, page.Limit)
+	assert.Equal(t, int64(0), page.Key(0))
+
+	page, err = Parse(Encode(3, 1024), 1000, DefaultLimit, MaxLimit)
+	assert.NoError(t, err)
+	assert.False(t, page.First())
+	assert.Equal(t, MaxLimit, page.Limit)
+	assert.Equal(t, int64(3), page.Key(0))
+	assert.Equal(t, int64(1024), page.Key(1))
+	assert.Equal(t, int64(0), page.Key(2))
+
+	for _, invalid := range []string{"!!", base64.RawURLEncoding.EncodeToString([]byte("a")), Encode(1, 2, 3, 4, 5)} {
+		_, err = Parse(invalid, 10, DefaultLimit, MaxLimit)
+		assert.Equal(t, ErrInvalidCursor, err, invalid)
+	}
+}
+
+func TestKeep(t *testing.T) {
+	page := Page{Limit: 2}
+	assert.Equal(t, uint64(3), page.FetchLimit())
+	n, more := page.Keep(3)
+	assert.Equal(t, 2, n)
+	assert.True(t, more)
+	n, more = page.Keep(2)
+	assert.Equal(t, 2, n)
+	assert.False(t, more)
+}
+
+func TestNewResult(t *testing.T) {
+	result := NewResult([]int{1, 2}, true, 2)
+	assert.Equal(t, 1, result.HasMore)
+	page, err := Parse(result.NextCursor, 2, DefaultLimit, MaxLimit)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.Key(0))
+
+	result = NewResult([]int{}, false, 0)
+	assert.Equal(t, 0, result.HasMore)
+	assert.Equal(t, "", result.NextCursor)
+}
@@ -0,0 +1,487 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// translateDDL 把MySQL修改表结构的语句改写为PostgreSQL的语句
+// 去掉COMMENT、ENGINE、CHARSET和AFTER等PostgreSQL不支持的部分 字段类型转为PostgreSQL的类型
+// 索引名在PostgreSQL中不能与其他表的索引重复 统一加上表名作为前缀
+func translateDDL(tokens []token) ([]string, error) {
+	tokens = compact(tokens)
+	switch {
+	case isWords(tokens, 0, "create", "table"), isWords(tokens, 0, "create", "temporary", "table"):
+		return createTable(tokens)
+	case isWords(tokens, 0, "create", "index"), isWords(tokens, 0, "create", "unique", "index"):
+		return createIndex(tokens)
+	case isWords(tokens, 0, "alter", "table"):
+		return alterTable(tokens)
+	case isWords(tokens, 0, "drop", "index"):
+		// DROP INDEX name ON table
+		if len(tokens) >= 5 && tokens[3].is("on") {
+			return []string{"DROP INDEX " + quoteIdent(indexName(tokens[4].name(), tokens[2].name()))}, nil
+		}
+	case isWords(tokens, 0, "rename", "table"):
+		// RENAME TABLE a TO b
+		if len(tokens) == 5 && tokens[3].is("to") {
+			return []string{fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdent(tokens[2].name()), quoteIdent(tokens[4].name()))}, nil
+		}
+		return nil, errors.New("不支持同时修改多个表名")
+	}
+	return []string{renderDDL(rewrite(tokens))}, nil
+}
+
+// createTable CREATE TABLE 表中定义的索引改为单独的CREATE INDEX
+func createTable(tokens []token) ([]string, error) {
+	i := 2
+	if tokens[1].is("temporary") {
+		i = 3
+	}
+	head := tokens[:i]
+	if isWords(tokens, i, "if", "not", "exists") {
+		head = tokens[:i+3]
+		i += 3
+	}
+	if i >= len(tokens) || !tokens[i].isName() {
+		return nil, errors.New("CREATE TABLE缺少表名")
+	}
+	name := tokens[i].name()
+	head = append(append([]token(nil), head...), ident(name))
+	i++
+	// CREATE TABLE a LIKE b 或 CREATE TABLE a (LIKE b)
+	like := i
+	if like < len(tokens) && tokens[like].is("(") {
+		like++
+	}
+	if like+1 < len(tokens) && tokens[like].is("like") {
+		out := append(head, symbol("("), word("LIKE"), ident(tokens[like+1].name()), word("INCLUDING"), word("ALL"), symbol(")"))
+		return []string{renderDDL(out)}, nil
+	}
+	if i >= len(tokens) || !tokens[i].is("(") {
+		return nil, fmt.Errorf("不支持的CREATE TABLE语句：%s", renderDDL(tokens))
+	}
+	end := matchParen(tokens, i)
+	out := append(head, symbol("("))
+	var indexes []string
+	columns := 0
+	for _, item := range splitTop(tokens[i+1:end], ",") {
+		switch {
+		case len(item) == 0:
+			continue
+		case item[0].is("key"), item[0].is("index"), item[0].is("unique"), item[0].is("fulltext"):
+			index, err := tableIndex(name, item)
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, index)
+			continue
+		case item[0].is("primary"), item[0].is("constraint"), item[0].is("foreign"), item[0].is("check"):
+			if columns > 0 {
+				out = append(out, symbol(","))
+			}
+			out = append(out, indexColumns(rewrite(item))...)
+		default:
+			def, err := parseColumn(item)
+			if err != nil {
+				return nil, err
+			}
+			if columns > 0 {
+				out = append(out, symbol(","))
+			}
+			out = append(out, def.tokens()...)
+		}
+		columns++
+	}
+	// 表的选项（ENGINE、CHARSET、COMMENT等）都去掉
+	out = append(out, symbol(")"))
+	return append([]string{renderDDL(out)}, indexes...), nil
+}
+
+// createIndex CREATE [UNIQUE] INDEX name ON table (cols)
+func createIndex(tokens []token) ([]string, error) {
+	i := 2
+	unique := tokens[1].is("unique")
+	if unique {
+		i = 3
+	}
+	if i+3 >= len(tokens) || !tokens[i+1].is("on") {
+		return nil, fmt.Errorf("不支持的CREATE INDEX语句：%s", renderDDL(tokens))
+	}
+	tableName := tokens[i+2].name()
+	open := i + 3
+	if !tokens[open].is("(") {
+		return nil, fmt.Errorf("不支持的CREATE INDEX语句：%s", renderDDL(tokens))
+	}
+	end := matchParen(tokens, open)
+	return []string{indexSQL(unique, indexName(tableName, tokens[i].name()), tableName, tokens[open:end+1])}, nil
+}
+
+// tableIndex 表或ALTER TABLE中定义的索引 [UNIQUE] KEY|INDEX name (cols)
+func tableIndex(tableName string, item []token) (string, error) {
+	unique := false
+	i := 0
+	if item[i].is("unique") {
+		unique = true
+		i++
+	} else if item[i].is("fulltext") {
+		return "", errors.New("PostgreSQL不支持FULLTEXT索引")
+	}
+	if i < len(item) && (item[i].is("key") || item[i].is("index")) {
+		i++
+	}
+	name := ""
+	if i < len(item) && item[i].isName() {
+		name = item[i].name()
+		i++
+	}
+	if i >= len(item) || !item[i].is("(") {
+		return "", fmt.Errorf("不支持的索引定义：%s", renderDDL(item))
+	}
+	end := matchParen(item, i)
+	cols := item[i : end+1]
+	if name == "" {
+		// 没有名字时与MySQL相同使用第一个字段名
+		name = cols[1].name()
+	}
+	return indexSQL(unique, indexName(tableName, name), tableName, cols), nil
+}
+
+func indexSQL(unique bool, name, tableName string, cols []token) string {
+	out := []token{word("CREATE")}
+	if unique {
+		out = append(out, word("UNIQUE"))
+	}
+	out = append(out, word("INDEX"), ident(name), word("ON"), ident(tableName))
+	return renderDDL(append(out, indexColumns(cols)...))
+}
+
+// indexColumns 去掉索引字段的前缀长度 content(100)
+func indexColumns(tokens []token) []token {
+	out := make([]token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].isName() && i+3 < len(tokens) && tokens[i+1].is("(") && isDigit(tokens[i+2].text[0]) && tokens[i+3].is(")") {
+			out = append(out, ident(tokens[i].name()))
+			i += 3
+			continue
+		}
+		if tokens[i].kind == tokenWord && i > 0 && (tokens[i-1].is("(") || tokens[i-1].is(",")) {
+			out = append(out, ident(tokens[i].name()))
+			continue
+		}
+		out = append(out, tokens[i])
+	}
+	return out
+}
+
+func indexName(tableName, name string) string {
+	return tableName + "_" + name
+}
+
+// alterTable ALTER TABLE t spec[,spec] 添加和删除索引改为单独的语句
+func alterTable(tokens []token) ([]string, error) {
+	if len(tokens) < 4 {
+		return nil, errors.New("ALTER TABLE缺少表名")
+	}
+	tableName := tokens[2].name()
+	var specs [][]token
+	var before, after []string
+	for _, spec := range splitTop(tokens[3:], ",") {
+		if len(spec) == 0 {
+			continue
+		}
+		switch {
+		case isWords(spec, 0, "add", "column"), spec[0].is("add") && len(spec) > 1 && !isIndexItem(spec[1]) && !spec[1].is("primary") && !spec[1].is("constraint") && !spec[1].is("foreign"):
+			col := spec[1:]
+			if col[0].is("column") {
+				col = col[1:]
+			}
+			def, err := parseColumn(col)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, append([]token{word("ADD"), word("COLUMN")}, def.tokens()...))
+		case spec[0].is("add") && len(spec) > 1 && isIndexItem(spec[1]):
+			index, err := tableIndex(tableName, spec[1:])
+			if err != nil {
+				return nil, err
+			}
+			after = append(after, index)
+		case spec[0].is("modify"), spec[0].is("change"):
+			col := spec[1:]
+			if len(col) > 0 && col[0].is("column") {
+				col = col[1:]
+			}
+			if spec[0].is("change") {
+				// CHANGE old new type 先改名再修改类型 改名不能与其他修改放在同一条语句中
+				if len(col) < 2 {
+					return nil, fmt.Errorf("不支持的ALTER TABLE语句：%s", renderDDL(spec))
+				}
+				if oldName, newName := col[0].name(), col[1].name(); oldName != newName {
+					before = append(before, renderDDL([]token{word("ALTER"), word("TABLE"), ident(tableName), word("RENAME"), word("COLUMN"), ident(oldName), word("TO"), ident(newName)}))
+				}
+				col = col[1:]
+			}
+			def, err := parseColumn(col)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, def.modify()...)
+		case isWords(spec, 0, "drop", "index"), isWords(spec, 0, "drop", "key"):
+			if len(spec) < 3 {
+				return nil, fmt.Errorf("不支持的ALTER TABLE语句：%s", renderDDL(spec))
+			}
+			after = append(after, "DROP INDEX "+quoteIdent(indexName(tableName, spec[2].name())))
+		case isWords(spec, 0, "drop", "primary", "key"):
+			specs = append(specs, []token{word("DROP"), word("CONSTRAINT"), ident(tableName + "_pkey")})
+		case isWords(spec, 0, "rename", "index"), isWords(spec, 0, "rename", "key"):
+			if len(spec) < 5 {
+				return nil, fmt.Errorf("不支持的ALTER TABLE语句：%s", renderDDL(spec))
+			}
+			after = append(after, fmt.Sprintf("ALTER INDEX %s RENAME TO %s", quoteIdent(indexName(tableName, spec[2].name())), quoteIdent(indexName(tableName, spec[4].name()))))
+		case spec[0].is("engine"), spec[0].is("comment"), spec[0].is("auto_increment"), spec[0].is("convert"), spec[0].is("charset"), spec[0].is("collate"),
+			isWords(spec, 0, "default", "charset"), isWords(spec, 0, "character", "set"), isWords(spec, 0, "default", "character"):
+			// 表的选项在PostgreSQL中不需要
+		default:
+			specs = append(specs, rewrite(spec))
+		}
+	}
+	queries := before
+	if len(specs) > 0 {
+		out := []token{word("ALTER"), word("TABLE"), ident(tableName)}
+		for n, spec := range specs {
+			if n > 0 {
+				out = append(out, symbol(","))
+			}
+			out = append(out, spec...)
+		}
+		queries = append(queries, renderDDL(out))
+	}
+	return append(queries, after...), nil
+}
+
+func isIndexItem(t token) bool {
+	return t.is("index") || t.is("key") || t.is("unique") || t.is("fulltext")
+}
+
+// column 字段的定义
+type column struct {
+	name     string
+	typ      []token
+	notNull  bool
+	null     bool
+	def      []token // 默认值
+	others   []token // PRIMARY KEY、UNIQUE等
+	hasDef   bool
+	serial   bool
+	size     []token
+	baseType string
+}
+
+// parseColumn 解析字段的定义 name type [NOT NULL] [DEFAULT x] [AUTO_INCREMENT] [COMMENT 'x'] ...
+func parseColumn(tokens []token) (*column, error) {
+	if len(tokens) < 2 || !tokens[0].isName() || tokens[1].kind != tokenWord {
+		return nil, fmt.Errorf("不支持的字段定义：%s", renderDDL(tokens))
+	}
+	c := &column{name: tokens[0].name(), baseType: strings.ToLower(tokens[1].text)}
+	i := 2
+	if i < len(tokens) && tokens[i].is("(") {
+		end := matchParen(tokens, i)
+		c.size = tokens[i : end+1]
+		i = end + 1
+	}
+	// timestamp with time zone、double precision等多个词的类型
+	var typeWords []token
+	for i < len(tokens) && tokens[i].kind == tokenWord && isTypeWord(tokens[i]) {
+		typeWords = append(typeWords, tokens[i])
+		i++
+	}
+	for i < len(tokens) {
+		t := tokens[i]
+		switch {
+		case isWords(tokens, i, "not", "null"):
+			c.notNull = true
+			i += 2
+		case t.is("null"):
+			c.null = true
+			i++
+		case t.is("default"):
+			i++
+			start := i
+			if i < len(tokens) && (tokens[i].is("-") || tokens[i].is("+")) {
+				i++
+			}
+			if i < len(tokens) && tokens[i].is("(") {
+				i = matchParen(tokens, i) + 1
+			} else if i < len(tokens) {
+				i++
+				// 小数 或 CURRENT_TIMESTAMP(3)
+				if i+1 < len(tokens) && tokens[i].is(".") {
+					i += 2
+				} else if i < len(tokens) && tokens[i].is("(") {
+					i = matchParen(tokens, i) + 1
+				}
+			}
+			c.def = rewrite(tokens[start:i])
+			c.hasDef = true
+		case t.is("auto_increment"):
+			c.serial = true
+			i++
+		case t.is("comment"), t.is("after"), t.is("charset"), t.is("collate"):
+			i += 2
+		case t.is("first"), t.is("unsigned"), t.is("zerofill"), t.is("signed"):
+			i++
+		case isWords(tokens, i, "character", "set"):
+			i += 3
+		case isWords(tokens, i, "on", "update"):
+			// 没有对应的写法 需要修改数据时设置
+			i += 3
+			if i < len(tokens) && tokens[i].is("(") {
+				i = matchParen(tokens, i) + 1
+			}
+		case isWords(tokens, i, "primary", "key"):
+			c.others = append(c.others, word("PRIMARY"), word("KEY"))
+			i += 2
+		case t.is("unique"):
+			c.others = append(c.others, word("UNIQUE"))
+			i++
+			if i < len(tokens) && tokens[i].is("key") {
+				i++
+			}
+		case t.is("key"):
+			c.others = append(c.others, word("PRIMARY"), word("KEY"))
+			i++
+		default:
+			c.others = append(c.others, rewrite([]token{t})...)
+			i++
+		}
+	}
+	c.typ = columnType(c.baseType, c.size, typeWords, c.serial)
+	if len(c.typ) > 0 && c.typ[0].is("smallint") && c.hasDef && len(c.def) == 1 {
+		// 布尔值字段使用smallint
+		switch {
+		case c.def[0].is("true"):
+			c.def = []token{word("1")}
+		case c.def[0].is("false"):
+			c.def = []token{word("0")}
+		}
+	}
+	return c, nil
+}
+
+func (c *column) tokens() []token {
+	out := append([]token{ident(c.name)}, c.typ...)
+	if c.notNull {
+		out = append(out, word("NOT"), word("NULL"))
+	} else if c.null {
+		out = append(out, word("NULL"))
+	}
+	if c.hasDef && !c.serial {
+		out = append(out, word("DEFAULT"))
+		out = append(out, c.def...)
+	}
+	return append(out, c.others...)
+}
+
+// modify MODIFY COLUMN 改为ALTER COLUMN 与MySQL相同 没有NOT NULL和DEFAULT时去掉原来的设置
+func (c *column) modify() [][]token {
+	col := []token{word("ALTER"), word("COLUMN"), ident(c.name)}
+	typ := append(append(append([]token(nil), col...), word("TYPE")), c.typ...)
+	typ = append(typ, word("USING"), ident(c.name), symbol("::"))
+	specs := [][]token{append(typ, c.typ...)}
+	if c.notNull {
+		specs = append(specs, append(append([]token(nil), col...), word("SET"), word("NOT"), word("NULL")))
+	} else {
+		specs = append(specs, append(append([]token(nil), col...), word("DROP"), word("NOT"), word("NULL")))
+	}
+	if c.hasDef {
+		specs = append(specs, append(append(append([]token(nil), col...), word("SET"), word("DEFAULT")), c.def...))
+	} else {
+		specs = append(specs, append(append([]token(nil), col...), word("DROP"), word("DEFAULT")))
+	}
+	return specs
+}
+
+// columnType MySQL的字段类型对应的PostgreSQL类型
+func columnType(name string, size []token, typeWords []token, serial bool) []token {
+	switch name {
+	case "tinyint", "bool", "boolean", "year":
+		if serial {
+			return []token{word("smallserial")}
+		}
+		return []token{word("smallint")}
+	case "smallint", "mediumint", "int", "integer":
+		if serial {
+			if name == "smallint" {
+				return []token{word("smallserial")}
+			}
+			return []token{word("serial")}
+		}
+		if name == "smallint" {
+			return []token{word("smallint")}
+		}
+		return []token{word("integer")}
+	case "bigint":
+		if serial {
+			return []token{word("bigserial")}
+		}
+		return []token{word("bigint")}
+	case "float":
+		return []token{word("real")}
+	case "double":
+		return []token{word("double"), word("precision")}
+	case "datetime", "timestamp":
+		// 与MySQL相同精确到秒
+		precision := size
+		if len(precision) == 0 {
+			precision = []token{symbol("("), word("0"), symbol(")")}
+		}
+		return append(append([]token{word("timestamp")}, precision...), typeWords...)
+	case "tinytext", "mediumtext", "longtext", "text":
+		return []token{word("text")}
+	case "tinyblob", "blob", "mediumblob", "longblob", "binary", "varbinary":
+		return []token{word("bytea")}
+	case "json":
+		// jsonb与MySQL的json相同 可以比较是否相等
+		return []token{word("jsonb")}
+	case "enum", "set":
+		return []token{word("varchar"), symbol("("), word("255"), symbol(")")}
+	}
+	return append(append([]token{word(name)}, size...), typeWords...)
+}
+
+func isTypeWord(t token) bool {
+	switch strings.ToLower(t.text) {
+	case "with", "without", "time", "zone", "precision", "varying":
+		return true
+	}
+	return false
+}
+
+// compact 去掉空白
+func compact(tokens []token) []token {
+	tokens, _ = trimSemicolon(tokens)
+	out := make([]token, 0, len(tokens))
+	for _, t := range tokens {
+		if t.kind != tokenSpace {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// renderDDL 用空格连接 点、括号和逗号的两边不加空格
+func renderDDL(tokens []token) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		if i > 0 {
+			prev := tokens[i-1]
+			if !(prev.is(".") || prev.is("(") || prev.is("::") || t.is(".") || t.is(")") || t.is(",") || t.is("::") || (t.is("(") && prev.isName())) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(t.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func translateDDLQueries(t *testing.T, query string) []string {
+	stmts, err := translate(query, 0, testLookup)
+	assert.NoError(t, err)
+	queries := make([]string, 0, len(stmts))
+	for _, stmt := range stmts {
+		assert.True(t, stmt.ddl)
+		queries = append(queries, stmt.query)
+	}
+	return queries
+}
+
+func TestCreateTable(t *testing.T) {
+	queries := translateDDLQueries(t, "CREATE TABLE IF NOT EXISTS `message_extra` (id integer not null primary key AUTO_INCREMENT, message_id VARCHAR(20) NOT NULL DEFAULT '' COMMENT '消息ID', is_deleted tinyint(1) not null default false, "+
+		"payload mediumblob, content text(1000), version bigint unsigned not null default 0, created_at timeStamp not null DEFAULT CURRENT_TIMESTAMP, "+
+		"KEY message_id_idx (message_id), UNIQUE KEY message_id_version (message_id, version), INDEX (content(100))) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='消息扩展';")
+	assert.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "message_extra"("id" serial NOT NULL PRIMARY KEY, "message_id" varchar(20) NOT NULL DEFAULT '', "is_deleted" smallint NOT NULL DEFAULT 0, "payload" bytea, "content" text, "version" bigint NOT NULL DEFAULT 0, "created_at" timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE INDEX "message_extra_message_id_idx" ON "message_extra"("message_id")`,
+		`CREATE UNIQUE INDEX "message_extra_message_id_version" ON "message_extra"("message_id", "version")`,
+		`CREATE INDEX "message_extra_content" ON "message_extra"("content")`,
+	}, queries)
+
+	queries = translateDDLQueries(t, "CREATE TABLE message_extra1 LIKE message_extra")
+	assert.Equal(t, []string{`CREATE TABLE "message_extra1"(LIKE "message_extra" INCLUDING ALL)`}, queries)
+
+	// sql-migrate的PostgreSQL方言创建的迁移记录表
+	queries = translateDDLQueries(t, `create table if not exists "gorp_migrations" ("id" text not null primary key, "applied_at" timestamp with time zone) ;`)
+	assert.Equal(t, []string{`create table if not exists "gorp_migrations"("id" text NOT NULL PRIMARY KEY, "applied_at" timestamp(0) with time zone)`}, queries)
+
+	_, err := translate("CREATE TABLE t (content text, FULLTEXT KEY content (content))", 0, testLookup)
+	assert.Error(t, err)
+}
+
+func TestAlterTable(t *testing.T) {
+	queries := translateDDLQueries(t, "ALTER TABLE `user` ADD COLUMN web3_public_key VARCHAR(200) NOT NULL DEFAULT '' COMMENT 'web3公钥' AFTER uid, ADD INDEX web3_idx(web3_public_key), "+
+		"MODIFY COLUMN name varchar(100) not null default '', CHANGE old_name new_name text, DROP INDEX name_idx")
+	assert.Equal(t, []string{
+		`ALTER TABLE "user" RENAME COLUMN "old_name" TO "new_name"`,
+		`ALTER TABLE "user" ADD COLUMN "web3_public_key" varchar(200) NOT NULL DEFAULT '', ALTER COLUMN "name" TYPE varchar(100) USING "name"::varchar(100), ALTER COLUMN "name" SET NOT NULL, ALTER COLUMN "name" SET DEFAULT '', ` +
+			`ALTER COLUMN "new_name" TYPE text USING "new_name"::text, ALTER COLUMN "new_name" DROP NOT NULL, ALTER COLUMN "new_name" DROP DEFAULT`,
+		`CREATE INDEX "user_web3_idx" ON "user"("web3_public_key")`,
+		`DROP INDEX "user_name_idx"`,
+	}, queries)
+
+	assert.Equal(t, []string{`DROP INDEX "user_name_idx"`}, translateDDLQueries(t, "DROP INDEX name_idx ON `user`"))
+	assert.Equal(t, []string{`CREATE UNIQUE INDEX "user_phone" ON "user"("zone", "phone")`}, translateDDLQueries(t, "create unique index phone on `user` (zone, phone(20))"))
+	assert.Equal(t, []string{`ALTER TABLE "a" RENAME TO "b"`}, translateDDLQueries(t, "RENAME TABLE a TO b"))
+	assert.Equal(t, []string{`ALTER TABLE "user" ALTER COLUMN status SET DEFAULT 1`}, translateDDLQueries(t, "ALTER TABLE `user` ALTER COLUMN status SET DEFAULT 1"))
+}
+
+// TestMigrations 各模块的迁移文件都可以改写 改写后没有MySQL特有的写法
+func TestMigrations(t *testing.T) {
+	files, err := filepath.Glob("../../../modules/*/sql/*.sql")
+	assert.NoError(t, err)
+	nested, err := filepath.Glob("../../../modules/*/*/sql/*.sql")
+	assert.NoError(t, err)
+	files = append(files, nested...)
+	assert.NotEmpty(t, files)
+	// 迁移中ON DUPLICATE KEY UPDATE的表 执行时从数据库读取
+	lookup := func(name string) (*table, error) {
+		if name == "user_setting" {
+			return &table{columns: map[string]bool{"uid": true, "to_uid": true, "remark": true}, uniqueKeys: [][]string{{"uid", "to_uid"}}}, nil
+		}
+		return testLookup(name)
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		assert.NoError(t, err)
+		migration, err := migrate.ParseMigration(filepath.Base(file), f)
+		f.Close()
+		assert.NoError(t, err, file)
+		for _, query := range append(migration.Up, migration.Down...) {
+			stmts, err := translate(query, 0, lookup)
+			if !assert.NoError(t, err, "%s: %s", file, query) {
+				continue
+			}
+			for _, stmt := range stmts {
+				upper := strings.ToUpper(stmt.query)
+				for _, s := range []string{"`", "AUTO_INCREMENT", "ENGINE", " COMMENT ", "CHARSET", "MEDIUMBLOB", "TINYINT", "DATETIME", "ON DUPLICATE", "INSERT IGNORE"} {
+					assert.NotContains(t, upper, s, "%s: %s", file, stmt.query)
+				}
+			}
+		}
+	}
+}
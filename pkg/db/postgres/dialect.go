@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/gocraft/dbr/v2/dialect"
+)
+
+// pgDialect dbr的PostgreSQL方言 表中的布尔值字段为smallint 布尔值写为1和0
+type pgDialect struct{}
+
+var _ dbr.Dialect = pgDialect{}
+
+func (pgDialect) QuoteIdent(id string) string {
+	return dialect.PostgreSQL.QuoteIdent(id)
+}
+
+func (pgDialect) EncodeString(s string) string {
+	return dialect.PostgreSQL.EncodeString(s)
+}
+
+func (pgDialect) EncodeBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func (pgDialect) EncodeTime(t time.Time) string {
+	return dialect.PostgreSQL.EncodeTime(t)
+}
+
+func (pgDialect) EncodeBytes(b []byte) string {
+	return dialect.PostgreSQL.EncodeBytes(b)
+}
+
+func (pgDialect) Placeholder(n int) string {
+	return dialect.PostgreSQL.Placeholder(n)
+}
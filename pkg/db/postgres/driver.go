@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+// connector 在pq的连接上改写语句 业务代码中的MySQL语句不需要修改
+type connector struct {
+	driver.Connector
+	catalog *catalog
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn, catalog: c.catalog}, nil
+}
+
+type conn struct {
+	driver.Conn
+	catalog *catalog
+}
+
+var (
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+)
+
+func (c *conn) queryer() driver.QueryerContext {
+	return c.Conn.(driver.QueryerContext)
+}
+
+func (c *conn) translate(ctx context.Context, query string, args int) ([]statement, error) {
+	return translate(query, args, func(name string) (*table, error) {
+		return c.catalog.lookup(ctx, c.queryer(), name)
+	})
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stmts, err := c.translate(ctx, query, len(args))
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) > 1 && len(args) > 0 {
+		return nil, errors.New("改写为多条语句时不能使用参数")
+	}
+	args = convertArgs(args)
+	var result driver.Result
+	for _, stmt := range stmts {
+		result, err = c.exec(ctx, stmt, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (c *conn) exec(ctx context.Context, stmt statement, args []driver.NamedValue) (driver.Result, error) {
+	execer := c.Conn.(driver.ExecerContext)
+	if stmt.ddl {
+		defer c.catalog.reset()
+		return execer.ExecContext(ctx, stmt.query, args)
+	}
+	if stmt.insert != "" && !stmt.returning {
+		// pq不支持LastInsertId 有自增字段时通过RETURNING获取
+		t, err := c.catalog.lookup(ctx, c.queryer(), stmt.insert)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil && t.serial != "" {
+			return c.insertReturning(ctx, stmt.query+" RETURNING "+quoteIdent(t.serial), args)
+		}
+	}
+	return execer.ExecContext(ctx, stmt.query, args)
+}
+
+func (c *conn) insertReturning(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.queryer().QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	r := &result{}
+	values := make([]driver.Value, 1)
+	for {
+		err = rows.Next(values)
+		if err == io.EOF {
+			return r, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 与MySQL相同 插入多条时返回第一条的ID
+		if r.rowsAffected == 0 {
+			r.lastInsertID, _ = values[0].(int64)
+		}
+		r.rowsAffected++
+	}
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmts, err := c.translate(ctx, query, len(args))
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) != 1 {
+		return nil, errors.New("查询不能改写为多条语句")
+	}
+	if stmts[0].ddl {
+		defer c.catalog.reset()
+	}
+	r, err := c.queryer().QueryContext(ctx, stmts[0].query, convertArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	return &rows{Rows: r}, nil
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmts, err := c.translate(ctx, query, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) != 1 {
+		return nil, errors.New("预处理的语句不能改写为多条语句")
+	}
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, stmts[0].query)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+// convertArgs 布尔值的参数转为1和0 表中的布尔值字段为smallint
+func convertArgs(args []driver.NamedValue) []driver.NamedValue {
+	for i, arg := range args {
+		if b, ok := arg.Value.(bool); ok {
+			args[i].Value = boolInt(b)
+		}
+	}
+	return args
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r *result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r *result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// rows 查询结果中的布尔值转为1和0 与MySQL相同
+type rows struct {
+	driver.Rows
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, v := range dest {
+		if b, ok := v.(bool); ok {
+			dest[i] = boolInt(b)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConn 记录执行的语句 查询返回rows中的数据
+type fakeConn struct {
+	driver.Conn
+	queries []string
+	rows    [][]driver.Value
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.queries = append(c.queries, query)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.queries = append(c.queries, query)
+	return &fakeRows{values: c.rows}, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func newFakeConn() (*conn, *fakeConn) {
+	fake := &fakeConn{}
+	c := &conn{Conn: fake, catalog: &catalog{tables: testTables}}
+	return c, fake
+}
+
+func TestConnInsertReturning(t *testing.T) {
+	c, fake := newFakeConn()
+	fake.rows = [][]driver.Value{{int64(7)}, {int64(8)}}
+	result, err := c.ExecContext(context.Background(), "INSERT INTO file_quota (uid,used) VALUES ('a',1),('b',2)", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`INSERT INTO file_quota(uid,used) VALUES('a',1),('b',2) RETURNING "id"`}, fake.queries)
+	id, _ := result.LastInsertId()
+	assert.Equal(t, int64(7), id)
+	affected, _ := result.RowsAffected()
+	assert.Equal(t, int64(2), affected)
+
+	// 没有自增字段时直接执行
+	fake.queries = nil
+	_, err = c.ExecContext(context.Background(), "insert into gorp_migrations (id,applied_at) values (?,?)", []driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: true}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"insert into gorp_migrations(id,applied_at) values($1,$2)"}, fake.queries)
+}
+
+func TestConnDDL(t *testing.T) {
+	c, fake := newFakeConn()
+	_, err := c.ExecContext(context.Background(), "CREATE TABLE t (id integer not null primary key AUTO_INCREMENT, uid varchar(40) not null default '', KEY uid_idx (uid))", nil)
+	assert.NoError(t, err)
+	assert.Len(t, fake.queries, 2)
+	// 修改表结构后重新加载
+	assert.Nil(t, c.catalog.tables)
+
+	_, err = c.ExecContext(context.Background(), "CREATE TABLE t (id integer, KEY uid_idx (uid))", []driver.NamedValue{{Ordinal: 1, Value: "a"}})
+	assert.Error(t, err)
+}
+
+func TestConvertValues(t *testing.T) {
+	args := convertArgs([]driver.NamedValue{{Value: true}, {Value: false}, {Value: "x"}})
+	assert.Equal(t, int64(1), args[0].Value)
+	assert.Equal(t, int64(0), args[1].Value)
+	assert.Equal(t, "x", args[2].Value)
+
+	r := &rows{Rows: &fakeRows{values: [][]driver.Value{{true}}}}
+	dest := make([]driver.Value, 1)
+	assert.NoError(t, r.Next(dest))
+	assert.Equal(t, int64(1), dest[0])
+
+	assert.Equal(t, "1", pgDialect{}.EncodeBool(true))
+	assert.Equal(t, "0", pgDialect{}.EncodeBool(false))
+	assert.Equal(t, `"user"`, pgDialect{}.QuoteIdent("user"))
+	assert.Equal(t, "$2", pgDialect{}.Placeholder(1))
+}
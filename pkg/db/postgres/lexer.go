@@ -0,0 +1,181 @@
+package postgres
+
+import "strings"
+
+type tokenKind int
+
+const (
+	tokenSpace  tokenKind = iota // 空白和注释
+	tokenWord                    // 关键字、标识符和数字
+	tokenString                  // 字符串
+	tokenIdent                   // 引号中的标识符 text为去掉引号后的名字
+	tokenParam                   // 参数 ? 或 $1
+	tokenSymbol                  // 其他符号
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func (t token) String() string {
+	if t.kind == tokenIdent {
+		return quoteIdent(t.text)
+	}
+	return t.text
+}
+
+// is 是否为指定的关键字或符号 不区分大小写
+func (t token) is(word string) bool {
+	return (t.kind == tokenWord || t.kind == tokenSymbol) && strings.EqualFold(t.text, word)
+}
+
+// name 标识符的名字 关键字和没有引号的标识符转为小写
+func (t token) name() string {
+	if t.kind == tokenIdent {
+		return t.text
+	}
+	return strings.ToLower(t.text)
+}
+
+func (t token) isName() bool {
+	return t.kind == tokenWord || t.kind == tokenIdent
+}
+
+func word(text string) token {
+	return token{kind: tokenWord, text: text}
+}
+
+func symbol(text string) token {
+	return token{kind: tokenSymbol, text: text}
+}
+
+func ident(name string) token {
+	return token{kind: tokenIdent, text: name}
+}
+
+var space = token{kind: tokenSpace, text: " "}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// tokenize 拆分SQL语句 同时支持MySQL和PostgreSQL的写法
+// 反引号和双引号都是标识符 字符串用两个单引号转义 E'...'中的反斜杠为转义
+func tokenize(query string) []token {
+	tokens := make([]token, 0, len(query)/4)
+	for i := 0; i < len(query); {
+		ch := query[i]
+		start := i
+		switch {
+		case isSpace(ch):
+			for i < len(query) && isSpace(query[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenSpace, text: query[start:i]})
+		case ch == '#' || (ch == '-' && strings.HasPrefix(query[i:], "--")):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end
+			}
+			tokens = append(tokens, token{kind: tokenSpace, text: " "})
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+			tokens = append(tokens, token{kind: tokenSpace, text: " "})
+		case ch == '\'':
+			escape := len(tokens) > 0 && tokens[len(tokens)-1].kind == tokenWord && strings.EqualFold(tokens[len(tokens)-1].text, "E")
+			i = skipString(query, i, escape)
+			if escape {
+				// E'...' 合并为一个字符串
+				tokens = tokens[:len(tokens)-1]
+				start--
+			}
+			tokens = append(tokens, token{kind: tokenString, text: query[start:i]})
+		case ch == '`' || ch == '"':
+			var b strings.Builder
+			for i++; i < len(query); i++ {
+				if query[i] == ch {
+					if i+1 < len(query) && query[i+1] == ch {
+						b.WriteByte(ch)
+						i++
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(query[i])
+			}
+			// MySQL的字段名不区分大小写 表结构都使用小写
+			tokens = append(tokens, ident(strings.ToLower(b.String())))
+		case ch == '?':
+			i++
+			tokens = append(tokens, token{kind: tokenParam, text: "?"})
+		case ch == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			for i++; i < len(query) && isDigit(query[i]); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenParam, text: query[start:i]})
+		case isWordByte(ch):
+			for i < len(query) && isWordByte(query[i]) {
+				i++
+			}
+			tokens = append(tokens, word(query[start:i]))
+		default:
+			i++
+			// 两个字符的运算符
+			if i < len(query) {
+				switch query[start : i+1] {
+				case "<>", "<=", ">=", "!=", "::", "||":
+					i++
+				}
+			}
+			tokens = append(tokens, symbol(query[start:i]))
+		}
+	}
+	return tokens
+}
+
+// skipString 跳过从start开始的字符串 返回字符串后面的位置
+func skipString(query string, start int, escape bool) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if escape {
+				i++
+			}
+		case '\'':
+			if i+1 < len(query) && query[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+func render(tokens []token) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString(t.String())
+	}
+	return b.String()
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isWordByte(ch byte) bool {
+	return isDigit(ch) || ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch >= 0x80
+}
@@ -0,0 +1,71 @@
+// Package postgres 使用PostgreSQL代替MySQL
+// 在驱动层把业务代码和迁移文件中的MySQL语句改写为PostgreSQL的语句（反引号、LIMIT a,b、ON DUPLICATE KEY UPDATE、
+// INSERT IGNORE、IFNULL、IF、DATE_FORMAT、UNIX_TIMESTAMP和建表语句等） 模块的代码和sql目录不需要修改
+// 布尔值字段为smallint 与MySQL相同返回1和0 不支持从库（replica）和备份恢复
+// 需要PostgreSQL 14及以上版本（字段别名可以不写AS）
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/gocraft/dbr/v2"
+	"github.com/lib/pq"
+)
+
+// Driver 配置database.driver中PostgreSQL的值
+const Driver = "postgres"
+
+// Enabled 是否使用PostgreSQL
+func Enabled() bool {
+	return extconfig.Get().Database.Driver == Driver
+}
+
+// Open 连接PostgreSQL 返回的连接执行的语句按MySQL的写法改写
+func Open(dsn string) (*dbr.Connection, error) {
+	pqConnector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(&connector{Connector: pqConnector, catalog: &catalog{}})
+	return &dbr.Connection{DB: db, Dialect: pgDialect{}, EventReceiver: &dbr.NullEventReceiver{}}, nil
+}
+
+// Setup 使用PostgreSQL时替换ctx.DB()的连接 需要在使用数据库之前调用
+func Setup(ctx *config.Context) error {
+	cfg := extconfig.Get()
+	switch cfg.Database.Driver {
+	case "", "mysql":
+		return nil
+	case Driver:
+	default:
+		return fmt.Errorf("database.driver不支持：%s", cfg.Database.Driver)
+	}
+	if cfg.Database.PostgresAddr == "" {
+		return errors.New("database.postgresAddr不能为空")
+	}
+	if len(cfg.Replica.Addrs) > 0 {
+		return errors.New("使用PostgreSQL时不支持replica.addrs")
+	}
+	conn, err := Open(cfg.Database.PostgresAddr)
+	if err != nil {
+		return err
+	}
+	dbCfg := ctx.GetConfig().DB
+	conn.SetMaxOpenConns(dbCfg.MySQLMaxOpenConns)
+	conn.SetMaxIdleConns(dbCfg.MySQLMaxIdleConns)
+	conn.SetConnMaxLifetime(dbCfg.MySQLConnMaxLifetime)
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return fmt.Errorf("连接PostgreSQL失败：%w", err)
+	}
+	// ctx.DB()的会话在第一次调用时创建（MySQL的连接不会立即建立） 替换为PostgreSQL的连接
+	session := ctx.DB()
+	old := session.Connection
+	session.Connection = conn
+	session.EventReceiver = conn.EventReceiver
+	return old.Close()
+}
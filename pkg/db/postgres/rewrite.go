@@ -0,0 +1,516 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// table 表结构 改写ON DUPLICATE KEY UPDATE和获取自增ID时使用
+type table struct {
+	columns    map[string]bool
+	primaryKey []string
+	uniqueKeys [][]string // 主键以外的唯一索引 按名字排序
+	serial     string     // 自增的字段
+}
+
+// lookupFunc 获取表结构 表不存在时返回nil
+type lookupFunc func(name string) (*table, error)
+
+// statement 改写后的语句
+type statement struct {
+	query     string
+	ddl       bool   // 是否为修改表结构的语句 执行后需要重新加载表结构
+	insert    string // INSERT语句的表名
+	returning bool   // 是否已有RETURNING
+}
+
+// reservedIdents 在PostgreSQL中是保留字或类型名 但表结构中作为表名或字段名使用的词 改写时加上引号
+var reservedIdents = map[string]bool{
+	"user":      true,
+	"timestamp": true,
+}
+
+// translate 把MySQL的语句改写为PostgreSQL的语句 args为参数的数量 修改表结构的语句可能改写为多条
+func translate(query string, args int, lookup lookupFunc) ([]statement, error) {
+	tokens := tokenize(query)
+	first := nextIndex(tokens, -1)
+	if first >= len(tokens) {
+		return []statement{{query: query}}, nil
+	}
+	switch strings.ToLower(tokens[first].text) {
+	case "create", "alter", "drop", "rename":
+		if tokens[first].kind == tokenWord {
+			queries, err := translateDDL(tokens)
+			if err != nil {
+				return nil, err
+			}
+			stmts := make([]statement, 0, len(queries))
+			for _, q := range queries {
+				stmts = append(stmts, statement{query: q, ddl: true})
+			}
+			return stmts, nil
+		}
+	}
+	// 去掉末尾的分号 INSERT语句可能需要加上RETURNING
+	tokens, _ = trimSemicolon(tokens)
+	if len(tokens) == 0 {
+		return []statement{{query: query}}, nil
+	}
+	stmt := statement{}
+	if tokens[0].is("insert") {
+		var err error
+		tokens, stmt.insert, err = rewriteInsert(tokens, 0, lookup)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tokens = rewrite(tokens)
+	if args > 0 {
+		tokens = numberParams(tokens)
+	}
+	for _, t := range tokens {
+		if t.is("returning") {
+			stmt.returning = true
+		}
+	}
+	stmt.query = render(tokens)
+	return []statement{stmt}, nil
+}
+
+// rewrite 改写表达式中MySQL特有的函数和写法
+func rewrite(tokens []token) []token {
+	out := make([]token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != tokenWord {
+			out = append(out, t)
+			continue
+		}
+		next := nextIndex(tokens, i)
+		if next < len(tokens) && tokens[next].is("(") && !lastIs(out, ".") {
+			end := matchParen(tokens, next)
+			args := splitTop(tokens[next+1:end], ",")
+			for n := range args {
+				args[n] = rewrite(args[n])
+			}
+			out = append(out, rewriteFunc(t, args)...)
+			i = end
+			continue
+		}
+		switch {
+		case reservedIdents[strings.ToLower(t.text)] && !lastIs(out, "as") && !lastIs(out, "::") && !(next < len(tokens) && tokens[next].kind == tokenString):
+			// CAST(x AS timestamp)、x::timestamp和timestamp '2006-01-02'中为类型
+			out = append(out, ident(strings.ToLower(t.text)))
+		case t.is("like"):
+			// MySQL的字符串比较默认不区分大小写
+			out = append(out, word("ILIKE"))
+		case t.is("limit"):
+			// LIMIT offset,count
+			a := nextIndex(tokens, i)
+			comma := nextIndex(tokens, a)
+			b := nextIndex(tokens, comma)
+			if b < len(tokens) && tokens[comma].is(",") && isValue(tokens[a]) && isValue(tokens[b]) {
+				out = append(out, t, space, tokens[b], space, word("OFFSET"), space, tokens[a])
+				i = b
+				continue
+			}
+			out = append(out, t)
+		default:
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// rewriteFunc 改写函数调用 args为已改写的参数
+func rewriteFunc(name token, args [][]token) []token {
+	call := func(fn string, args ...[]token) []token {
+		out := []token{word(fn), symbol("(")}
+		for n, arg := range args {
+			if n > 0 {
+				out = append(out, symbol(","))
+			}
+			out = append(out, arg...)
+		}
+		return append(out, symbol(")"))
+	}
+	switch strings.ToLower(name.text) {
+	case "ifnull":
+		if len(args) == 2 && isString(args[1]) {
+			// 字段为时间或数字时 MySQL会转为字符串
+			return call("COALESCE", cast(args[0], "TEXT"), args[1])
+		}
+		return call("COALESCE", args...)
+	case "if":
+		if len(args) == 3 {
+			out := []token{symbol("("), word("CASE"), space, word("WHEN"), space}
+			out = append(out, args[0]...)
+			out = append(out, space, word("THEN"), space)
+			out = append(out, args[1]...)
+			out = append(out, space, word("ELSE"), space)
+			out = append(out, args[2]...)
+			return append(out, space, word("END"), symbol(")"))
+		}
+	case "date_format":
+		if len(args) == 2 && isString(args[1]) {
+			format := trimSpace(args[1])[0]
+			return call("to_char", args[0], []token{{kind: tokenString, text: convertDateFormat(format.text)}})
+		}
+	case "unix_timestamp":
+		var value []token
+		if len(args) == 1 && len(trimSpace(args[0])) > 0 {
+			value = cast(args[0], "TIMESTAMPTZ")
+		} else {
+			value = []token{word("NOW"), symbol("("), symbol(")")}
+		}
+		extract := append([]token{word("EPOCH"), space, word("FROM"), space}, value...)
+		return cast(call("EXTRACT", extract), "BIGINT")
+	case "rand":
+		return call("random")
+	case "md5":
+		if len(args) == 1 {
+			return call("md5", cast(args[0], "TEXT"))
+		}
+	}
+	if reservedIdents[strings.ToLower(name.text)] {
+		name = ident(strings.ToLower(name.text))
+	}
+	return call(name.text, args...)
+}
+
+func cast(value []token, typ string) []token {
+	out := []token{word("CAST"), symbol("(")}
+	out = append(out, value...)
+	return append(out, space, word("AS"), space, word(typ), symbol(")"))
+}
+
+// dateFormats DATE_FORMAT和to_char的格式
+var dateFormats = map[byte]string{
+	'Y': "YYYY",
+	'y': "YY",
+	'm': "MM",
+	'c': "FMMM",
+	'd': "DD",
+	'e': "FMDD",
+	'H': "HH24",
+	'k': "FMHH24",
+	'h': "HH12",
+	'I': "HH12",
+	'i': "MI",
+	's': "SS",
+	'S': "SS",
+	'p': "AM",
+	'%': "%",
+}
+
+// convertDateFormat 把DATE_FORMAT的格式转为to_char的格式 format为带引号的字符串
+func convertDateFormat(format string) string {
+	format = strings.ReplaceAll(format[1:len(format)-1], "''", "'")
+	var b strings.Builder
+	literal := false
+	setLiteral := func(v bool) {
+		if literal != v {
+			b.WriteByte('"')
+			literal = v
+		}
+	}
+	for i := 0; i < len(format); i++ {
+		ch := format[i]
+		if ch == '%' && i+1 < len(format) {
+			if f, ok := dateFormats[format[i+1]]; ok {
+				setLiteral(false)
+				b.WriteString(f)
+				i++
+				continue
+			}
+		}
+		// 字母在to_char中可能是格式 需要放在双引号中
+		setLiteral(isWordByte(ch) && !isDigit(ch) && ch != '_')
+		b.WriteByte(ch)
+	}
+	setLiteral(false)
+	return "'" + strings.ReplaceAll(b.String(), "'", "''") + "'"
+}
+
+// rewriteInsert 改写INSERT IGNORE、VALUE和ON DUPLICATE KEY UPDATE 返回改写后的语句和表名
+func rewriteInsert(tokens []token, start int, lookup lookupFunc) ([]token, string, error) {
+	tokens = append([]token(nil), tokens...)
+	ignore := false
+	i := nextIndex(tokens, start)
+	if i < len(tokens) && tokens[i].is("ignore") {
+		ignore = true
+		from := i
+		if from > 0 && tokens[from-1].kind == tokenSpace {
+			from--
+		}
+		tokens = append(tokens[:from], tokens[i+1:]...)
+		i = nextIndex(tokens, start)
+	}
+	if i < len(tokens) && tokens[i].is("into") {
+		i = nextIndex(tokens, i)
+	}
+	if i >= len(tokens) || !tokens[i].isName() {
+		return nil, "", errors.New("INSERT语句缺少表名")
+	}
+	// 库名.表名
+	for next := nextIndex(tokens, i); next+1 < len(tokens) && tokens[next].is("."); next = nextIndex(tokens, i) {
+		i = nextIndex(tokens, next)
+	}
+	if reservedIdents[tokens[i].name()] {
+		tokens[i] = ident(tokens[i].name())
+	}
+	tableName := tokens[i].name()
+	var columns []string
+	if open := nextIndex(tokens, i); open < len(tokens) && tokens[open].is("(") {
+		end := matchParen(tokens, open)
+		for _, col := range splitTop(tokens[open+1:end], ",") {
+			if col = trimSpace(col); len(col) == 1 {
+				columns = append(columns, col[0].name())
+			}
+		}
+		i = end
+	}
+	if v := nextIndex(tokens, i); v < len(tokens) && tokens[v].is("value") {
+		tokens[v] = word("VALUES")
+	}
+
+	dup := -1
+	for n, depth := i+1, 0; n < len(tokens); n++ {
+		switch {
+		case tokens[n].is("("):
+			depth++
+		case tokens[n].is(")"):
+			depth--
+		case depth == 0 && tokens[n].is("on") && isWords(tokens, n, "on", "duplicate", "key", "update"):
+			dup = n
+		}
+		if dup >= 0 {
+			break
+		}
+	}
+	switch {
+	case dup >= 0:
+		t, err := lookup(tableName)
+		if err != nil {
+			return nil, "", err
+		}
+		if t == nil {
+			return nil, "", fmt.Errorf("表%s不存在", tableName)
+		}
+		key := conflictKey(t, columns)
+		if key == nil {
+			return nil, "", fmt.Errorf("表%s没有可用于ON DUPLICATE KEY UPDATE的唯一索引", tableName)
+		}
+		update := dup
+		for n := 0; n < 4; n++ {
+			update = nextIndex(tokens, update)
+		}
+		out := append([]token(nil), tokens[:dup]...)
+		out = append(out, word("ON"), space, word("CONFLICT"), space, symbol("("))
+		for n, col := range key {
+			if n > 0 {
+				out = append(out, symbol(","))
+			}
+			out = append(out, ident(col))
+		}
+		out = append(out, symbol(")"), space, word("DO"), space, word("UPDATE"), space, word("SET"), space)
+		for n, assign := range splitTop(tokens[update:], ",") {
+			if n > 0 {
+				out = append(out, symbol(","))
+			}
+			out = append(out, rewriteAssign(assign, tableName, t)...)
+		}
+		tokens = out
+	case ignore:
+		tokens = append(tokens, space, word("ON"), space, word("CONFLICT"), space, word("DO"), space, word("NOTHING"))
+	}
+	return tokens, tableName, nil
+}
+
+// rewriteAssign 改写ON DUPLICATE KEY UPDATE中的赋值 VALUES(col)改为EXCLUDED.col 表达式中的字段加上表名
+func rewriteAssign(assign []token, tableName string, t *table) []token {
+	eq := -1
+	for n, tok := range assign {
+		if tok.is("=") {
+			eq = n
+			break
+		}
+	}
+	if eq < 0 {
+		return assign
+	}
+	out := append([]token(nil), assign[:eq+1]...)
+	expr := assign[eq+1:]
+	for n := 0; n < len(expr); n++ {
+		tok := expr[n]
+		if !tok.isName() {
+			out = append(out, tok)
+			continue
+		}
+		next := nextIndex(expr, n)
+		if next < len(expr) && expr[next].is("(") {
+			end := matchParen(expr, next)
+			if tok.is("values") {
+				inner := trimSpace(expr[next+1 : end])
+				if len(inner) == 1 && inner[0].isName() {
+					out = append(out, word("EXCLUDED"), symbol("."), ident(inner[0].name()))
+					n = end
+					continue
+				}
+			}
+			out = append(out, tok)
+			continue
+		}
+		if t.columns[tok.name()] && !lastIs(out, ".") && !(next < len(expr) && expr[next].is(".")) {
+			out = append(out, ident(tableName), symbol("."), ident(tok.name()))
+			continue
+		}
+		out = append(out, tok)
+	}
+	return out
+}
+
+// conflictKey 插入的字段包含的唯一索引 与MySQL任意唯一索引冲突时更新不同 PostgreSQL需要指定一个
+// 优先使用主键以外的唯一索引 自增的主键一般不在插入的字段中
+func conflictKey(t *table, columns []string) []string {
+	inserted := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		inserted[col] = true
+	}
+	contains := func(key []string) bool {
+		for _, col := range key {
+			if !inserted[col] {
+				return false
+			}
+		}
+		return len(key) > 0
+	}
+	for _, key := range t.uniqueKeys {
+		if contains(key) {
+			return key
+		}
+	}
+	if contains(t.primaryKey) {
+		return t.primaryKey
+	}
+	return nil
+}
+
+// numberParams 把?改为$1、$2 已经是$1时不修改
+func numberParams(tokens []token) []token {
+	for _, t := range tokens {
+		if t.kind == tokenParam && t.text != "?" {
+			return tokens
+		}
+	}
+	n := 0
+	for i, t := range tokens {
+		if t.kind == tokenParam {
+			n++
+			tokens[i].text = "$" + strconv.Itoa(n)
+		}
+	}
+	return tokens
+}
+
+// nextIndex i之后第一个不是空白的位置 没有时返回len(tokens)
+func nextIndex(tokens []token, i int) int {
+	for i++; i < len(tokens); i++ {
+		if tokens[i].kind != tokenSpace {
+			return i
+		}
+	}
+	return len(tokens)
+}
+
+// matchParen 与open位置的左括号对应的右括号 没有时返回len(tokens)-1
+func matchParen(tokens []token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is("("):
+			depth++
+		case tokens[i].is(")"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// splitTop 按不在括号中的分隔符拆分
+func splitTop(tokens []token, sep string) [][]token {
+	if len(trimSpace(tokens)) == 0 {
+		return nil
+	}
+	var parts [][]token
+	depth, start := 0, 0
+	for i, t := range tokens {
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth == 0 && t.is(sep):
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+func trimSpace(tokens []token) []token {
+	for len(tokens) > 0 && tokens[0].kind == tokenSpace {
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1].kind == tokenSpace {
+		tokens = tokens[:len(tokens)-1]
+	}
+	return tokens
+}
+
+// trimSemicolon 去掉末尾的分号
+func trimSemicolon(tokens []token) ([]token, bool) {
+	tokens = trimSpace(tokens)
+	semicolon := false
+	for len(tokens) > 0 && tokens[len(tokens)-1].is(";") {
+		semicolon = true
+		tokens = trimSpace(tokens[:len(tokens)-1])
+	}
+	return tokens, semicolon
+}
+
+func lastIs(tokens []token, text string) bool {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].kind != tokenSpace {
+			return tokens[i].is(text)
+		}
+	}
+	return false
+}
+
+// isWords 从i开始的词（跳过空白）是否为words
+func isWords(tokens []token, i int, words ...string) bool {
+	for n, w := range words {
+		if n > 0 {
+			i = nextIndex(tokens, i)
+		}
+		if i >= len(tokens) || !tokens[i].is(w) {
+			return false
+		}
+	}
+	return true
+}
+
+func isString(tokens []token) bool {
+	tokens = trimSpace(tokens)
+	return len(tokens) == 1 && tokens[0].kind == tokenString
+}
+
+func isValue(t token) bool {
+	return t.kind == tokenParam || (t.kind == tokenWord && isDigit(t.text[0]))
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testTables = map[string]*table{
+	"file_quota": {
+		columns:    map[string]bool{"id": true, "uid": true, "used": true, "updated_at": true},
+		primaryKey: []string{"id"},
+		uniqueKeys: [][]string{{"uid"}},
+		serial:     "id",
+	},
+	"user_last_offset": {
+		columns:    map[string]bool{"id": true, "uid": true, "channel_id": true, "channel_type": true, "message_seq": true},
+		primaryKey: []string{"id"},
+		uniqueKeys: [][]string{{"uid", "channel_id", "channel_type"}},
+		serial:     "id",
+	},
+	"gorp_migrations": {
+		columns:    map[string]bool{"id": true, "applied_at": true},
+		primaryKey: []string{"id"},
+	},
+}
+
+func testLookup(name string) (*table, error) {
+	return testTables[name], nil
+}
+
+func translateOne(t *testing.T, query string, args int) statement {
+	stmts, err := translate(query, args, testLookup)
+	assert.NoError(t, err)
+	assert.Len(t, stmts, 1)
+	return stmts[0]
+}
+
+func TestTranslateSelect(t *testing.T) {
+	stmt := translateOne(t, "SELECT user.uid,IFNULL(user.name,''),IFNULL(user.sex,0) FROM `user` WHERE name like ? AND `timestamp`>? LIMIT 10,20", 2)
+	assert.Equal(t, `SELECT "user".uid,COALESCE(CAST("user".name AS TEXT),''),COALESCE("user".sex,0) FROM "user" WHERE name ILIKE $1 AND "timestamp">$2 LIMIT 20 OFFSET 10`, stmt.query)
+	assert.Empty(t, stmt.insert)
+
+	// 类型名和字符串中的内容不修改
+	stmt = translateOne(t, "SELECT CAST(x AS timestamp),'user like ?' FROM t WHERE timestamp '2024-01-01 00:00:00'<created_at LIMIT 1", 0)
+	assert.Equal(t, "SELECT CAST(x AS timestamp),'user like ?' FROM t WHERE timestamp '2024-01-01 00:00:00'<created_at LIMIT 1", stmt.query)
+
+	stmt = translateOne(t, "SELECT COUNT(*) AS count FROM `group` WHERE creator='a''b' AND DATE(created_at)='2024-01-01';", 0)
+	assert.Equal(t, `SELECT COUNT(*) AS count FROM "group" WHERE creator='a''b' AND DATE(created_at)='2024-01-01'`, stmt.query)
+}
+
+func TestTranslateFunc(t *testing.T) {
+	stmt := translateOne(t, "SELECT uid FROM group_member ORDER BY IF(group_member.role=1,0,IF(group_member.role=2,1,2))", 0)
+	assert.Equal(t, "SELECT uid FROM group_member ORDER BY (CASE WHEN group_member.role=1 THEN 0 ELSE (CASE WHEN group_member.role=2 THEN 1 ELSE 2 END) END)", stmt.query)
+
+	stmt = translateOne(t, "SELECT DATE_FORMAT(created_at,'%Y-%m-%d %H:%i:%s'),DATE_FORMAT(created_at,'%Y年%c月') FROM t", 0)
+	assert.Equal(t, `SELECT to_char(created_at,'YYYY-MM-DD HH24:MI:SS'),to_char(created_at,'YYYY"年"FMMM"月"') FROM t`, stmt.query)
+
+	stmt = translateOne(t, "SELECT UNIX_TIMESTAMP(created_at),UNIX_TIMESTAMP(),MD5(uid) FROM t ORDER BY RAND()", 0)
+	assert.Equal(t, "SELECT CAST(EXTRACT(EPOCH FROM CAST(created_at AS TIMESTAMPTZ)) AS BIGINT),CAST(EXTRACT(EPOCH FROM NOW()) AS BIGINT),md5(CAST(uid AS TEXT)) FROM t ORDER BY random()", stmt.query)
+}
+
+func TestConvertDateFormat(t *testing.T) {
+	assert.Equal(t, "'YYYY-MM-DD'", convertDateFormat("'%Y-%m-%d'"))
+	assert.Equal(t, `'HH24"h"MI'`, convertDateFormat("'%Hh%i'"))
+	assert.Equal(t, "'%'", convertDateFormat("'%%'"))
+}
+
+func TestTranslateInsert(t *testing.T) {
+	stmt := translateOne(t, "insert into file_quota(uid,used) values(?,GREATEST(?,0)) ON DUPLICATE KEY UPDATE used=GREATEST(used+?,0),updated_at=NOW()", 3)
+	assert.Equal(t, `insert into file_quota(uid,used) values($1,GREATEST($2,0)) ON CONFLICT("uid") DO UPDATE SET used=GREATEST("file_quota"."used"+$3,0),updated_at=NOW()`, stmt.query)
+	assert.Equal(t, "file_quota", stmt.insert)
+	assert.False(t, stmt.returning)
+
+	stmt = translateOne(t, "INSERT INTO user_last_offset (uid,channel_id,channel_type,message_seq) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE message_seq=IF(message_seq<VALUES(message_seq),VALUES(message_seq),message_seq)", 4)
+	assert.Equal(t, `INSERT INTO user_last_offset(uid,channel_id,channel_type,message_seq) VALUES($1,$2,$3,$4) ON CONFLICT("uid","channel_id","channel_type") DO UPDATE SET message_seq=(CASE WHEN "user_last_offset"."message_seq"<EXCLUDED."message_seq" THEN EXCLUDED."message_seq" ELSE "user_last_offset"."message_seq" END)`, stmt.query)
+
+	stmt = translateOne(t, "insert ignore into `user` (uid,name) value ('a','b')", 0)
+	assert.Equal(t, `insert into "user" (uid,name) VALUES('a','b') ON CONFLICT DO NOTHING`, stmt.query)
+	assert.Equal(t, "user", stmt.insert)
+
+	// 已经是PostgreSQL的写法时不修改
+	stmt = translateOne(t, `insert into "gorp_migrations" ("id","applied_at") values ($1,$2) RETURNING id`, 2)
+	assert.Equal(t, `insert into "gorp_migrations" ("id","applied_at") values($1,$2) RETURNING id`, stmt.query)
+	assert.True(t, stmt.returning)
+
+	// 没有包含唯一索引的字段时不能改写
+	_, err := translate("INSERT INTO file_quota (used) VALUES (1) ON DUPLICATE KEY UPDATE used=1", 0, testLookup)
+	assert.Error(t, err)
+	_, err = translate("INSERT INTO not_exist (uid) VALUES (1) ON DUPLICATE KEY UPDATE uid=1", 0, testLookup)
+	assert.Error(t, err)
+}
+
+func TestConflictKey(t *testing.T) {
+	tb := &table{primaryKey: []string{"id"}, uniqueKeys: [][]string{{"uid", "channel_id"}, {"vercode"}}}
+	assert.Equal(t, []string{"uid", "channel_id"}, conflictKey(tb, []string{"uid", "channel_id", "name"}))
+	assert.Equal(t, []string{"vercode"}, conflictKey(tb, []string{"uid", "vercode"}))
+	assert.Equal(t, []string{"id"}, conflictKey(tb, []string{"id", "name"}))
+	assert.Nil(t, conflictKey(tb, []string{"name"}))
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize("SELECT `a`, \"B\", 'it''s', E'\\'x', $12 -- comment\n# comment\n/* c */ FROM t WHERE a<>?")
+	var kinds []tokenKind
+	for _, tk := range tokens {
+		if tk.kind != tokenSpace {
+			kinds = append(kinds, tk.kind)
+		}
+	}
+	assert.Equal(t, []tokenKind{tokenWord, tokenIdent, tokenSymbol, tokenIdent, tokenSymbol, tokenString, tokenSymbol, tokenString, tokenSymbol, tokenParam, tokenWord, tokenWord, tokenWord, tokenWord, tokenSymbol, tokenParam}, kinds)
+	assert.Equal(t, "SELECT \"a\", \"b\", 'it''s', E'\\'x', $12  \n \n  FROM t WHERE a<>?", render(tokens))
+}
@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	columnsSQL = "SELECT table_name, column_name, COALESCE(column_default, '') FROM information_schema.columns WHERE table_schema = current_schema()"
+	// 只需要普通字段的唯一索引 表达式索引和部分索引不能用于ON CONFLICT
+	uniqueKeysSQL = `SELECT t.relname, i.relname, x.indisprimary, a.attname
+FROM pg_index x
+JOIN pg_class t ON t.oid = x.indrelid
+JOIN pg_class i ON i.oid = x.indexrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+JOIN LATERAL unnest(x.indkey) WITH ORDINALITY AS k(attnum, pos) ON true
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE n.nspname = current_schema() AND x.indisunique AND x.indpred IS NULL AND x.indexprs IS NULL
+ORDER BY t.relname, i.relname, k.pos`
+)
+
+// catalog 缓存表结构 执行修改表结构的语句后重新加载
+type catalog struct {
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+func (c *catalog) reset() {
+	c.mu.Lock()
+	c.tables = nil
+	c.mu.Unlock()
+}
+
+// lookup 获取表结构 没有缓存时通过conn加载 在事务中时可以看到事务中新建的表
+// 表不存在时返回nil 其他实例修改了表结构时也会重新加载
+func (c *catalog) lookup(ctx context.Context, conn driver.QueryerContext, name string) (*table, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.tables[name]; ok {
+		return t, nil
+	}
+	tables, err := loadTables(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("读取表结构失败：%w", err)
+	}
+	c.tables = tables
+	return tables[name], nil
+}
+
+func loadTables(ctx context.Context, conn driver.QueryerContext) (map[string]*table, error) {
+	tables := map[string]*table{}
+	get := func(name string) *table {
+		t := tables[name]
+		if t == nil {
+			t = &table{columns: map[string]bool{}}
+			tables[name] = t
+		}
+		return t
+	}
+	err := queryRows(ctx, conn, columnsSQL, func(values []driver.Value) {
+		t := get(toString(values[0]))
+		col := toString(values[1])
+		t.columns[col] = true
+		if strings.HasPrefix(toString(values[2]), "nextval(") {
+			t.serial = col
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]map[string][]string{}
+	primary := map[string]string{}
+	err = queryRows(ctx, conn, uniqueKeysSQL, func(values []driver.Value) {
+		tableName, index := toString(values[0]), toString(values[1])
+		if keys[tableName] == nil {
+			keys[tableName] = map[string][]string{}
+		}
+		keys[tableName][index] = append(keys[tableName][index], toString(values[3]))
+		if b, _ := values[2].(bool); b {
+			primary[tableName] = index
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for tableName, indexes := range keys {
+		t := get(tableName)
+		names := make([]string, 0, len(indexes))
+		for index := range indexes {
+			if index == primary[tableName] {
+				t.primaryKey = indexes[index]
+				continue
+			}
+			names = append(names, index)
+		}
+		sort.Strings(names)
+		for _, index := range names {
+			t.uniqueKeys = append(t.uniqueKeys, indexes[index])
+		}
+	}
+	return tables, nil
+}
+
+func queryRows(ctx context.Context, conn driver.QueryerContext, query string, fn func(values []driver.Value)) error {
+	rows, err := conn.QueryContext(ctx, query, nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	values := make([]driver.Value, len(rows.Columns()))
+	for {
+		err = rows.Next(values)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(values)
+	}
+}
+
+func toString(v driver.Value) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,250 @@
+// Package replica MySQL读写分离
+// 写和需要强一致的读使用主库 允许少量延迟的读（消息扩展、好友列表、消息偏移等）通过Reader使用从库
+// 从库的复制延迟超过MaxLag、复制中断或连接失败时不使用 没有可用的从库时使用主库
+package replica
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	_ "github.com/go-sql-driver/mysql" // mysql
+	"github.com/gocraft/dbr/v2"
+	"go.uber.org/zap"
+)
+
+// lagColumns 复制状态中的延迟字段 MySQL 8.0.22以后为Seconds_Behind_Source
+var lagColumns = []string{"Seconds_Behind_Source", "Seconds_Behind_Master"}
+
+var (
+	errReplicationStopped = errors.New("从库复制已中断")
+	errNoLagColumn        = errors.New("复制状态中没有延迟字段")
+)
+
+// Options 从库配置
+type Options struct {
+	MaxLag          time.Duration // 复制延迟超过多久不使用
+	StickyDuration  time.Duration // 写入后多久内同一个key的读使用主库 只在当前实例生效
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// Set 一组从库
+type Set struct {
+	log.Log
+	nodes   []*node
+	opts    Options
+	next    atomic.Uint32
+	written sync.Map // key -> 最后写入的时间（纳秒）
+}
+
+type node struct {
+	name      string // 日志中显示的名称 不包含密码
+	session   *dbr.Session
+	available atomic.Bool
+	lag       atomic.Int64 // 复制延迟（秒）
+}
+
+var current atomic.Pointer[Set]
+
+// Open 连接从库 连接后需要调用CheckLag检查复制延迟 检查通过前不使用从库
+func Open(addrs []string, opts Options) (*Set, error) {
+	sessions := make([]*dbr.Session, 0, len(addrs))
+	names := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		conn, err := dbr.Open("mysql", addr, nil)
+		if err != nil {
+			return nil, err
+		}
+		conn.SetMaxOpenConns(opts.MaxOpenConns)
+		conn.SetMaxIdleConns(opts.MaxIdleConns)
+		conn.SetConnMaxLifetime(opts.ConnMaxLifetime)
+		sessions = append(sessions, conn.NewSession(nil))
+		names = append(names, addrName(addr))
+	}
+	return newSet(sessions, names, opts), nil
+}
+
+func newSet(sessions []*dbr.Session, names []string, opts Options) *Set {
+	s := &Set{
+		Log:  log.NewTLog("replica"),
+		opts: opts,
+	}
+	for i, session := range sessions {
+		s.nodes = append(s.nodes, &node{
+			name:    names[i],
+			session: session,
+		})
+	}
+	return s
+}
+
+// Configure 设置全局使用的从库 为nil时所有的读使用主库
+func Configure(s *Set) {
+	current.Store(s)
+}
+
+// Get 全局使用的从库 没有配置从库时为nil
+func Get() *Set {
+	return current.Load()
+}
+
+// Reader 允许延迟的读使用的session
+// key为读取的数据所属的用户或频道 key在StickyDuration内写入过时使用主库 为空时不判断
+func Reader(primary *dbr.Session, key string) *dbr.Session {
+	s := current.Load()
+	if s == nil {
+		return primary
+	}
+	return s.reader(primary, key, time.Now())
+}
+
+// MarkWritten 记录key刚刚写入 之后StickyDuration内key的读使用主库 避免读不到自己刚写入的数据
+func MarkWritten(keys ...string) {
+	s := current.Load()
+	if s == nil {
+		return
+	}
+	s.markWritten(time.Now(), keys...)
+}
+
+// ChannelKey 频道数据使用的key
+func ChannelKey(channelID string, channelType uint8) string {
+	return channelID + "@" + strconv.Itoa(int(channelType))
+}
+
+// Sessions 所有从库的session 用于监控连接池
+func (s *Set) Sessions() map[string]*dbr.Session {
+	sessions := make(map[string]*dbr.Session, len(s.nodes))
+	for _, n := range s.nodes {
+		sessions[n.name] = n.session
+	}
+	return sessions
+}
+
+// CheckLag 检查每个从库的复制延迟 并清理已过期的写入记录
+func (s *Set) CheckLag() {
+	for _, n := range s.nodes {
+		lag, err := queryLag(n.session)
+		if err != nil {
+			if n.available.Swap(false) {
+				s.Warn("从库不可用，读取将使用主库！", zap.String("replica", n.name), zap.Error(err))
+			}
+			continue
+		}
+		n.lag.Store(lag)
+		usable := time.Duration(lag)*time.Second <= s.opts.MaxLag
+		if n.available.Swap(usable) != usable {
+			s.Info("从库状态变化", zap.String("replica", n.name), zap.Bool("available", usable), zap.Int64("lag", lag))
+		}
+	}
+	s.cleanWritten(time.Now())
+}
+
+func (s *Set) reader(primary *dbr.Session, key string, now time.Time) *dbr.Session {
+	if key != "" && s.recentlyWritten(key, now) {
+		return primary
+	}
+	count := len(s.nodes)
+	if count == 0 {
+		return primary
+	}
+	start := int(s.next.Add(1))
+	for i := 0; i < count; i++ {
+		n := s.nodes[(start+i)%count]
+		if n.available.Load() {
+			return n.session
+		}
+	}
+	return primary
+}
+
+func (s *Set) markWritten(now time.Time, keys ...string) {
+	if s.opts.StickyDuration <= 0 {
+		return
+	}
+	for _, key := range keys {
+		if key != "" {
+			s.written.Store(key, now.UnixNano())
+		}
+	}
+}
+
+func (s *Set) recentlyWritten(key string, now time.Time) bool {
+	value, ok := s.written.Load(key)
+	if !ok {
+		return false
+	}
+	return now.UnixNano()-value.(int64) < int64(s.opts.StickyDuration)
+}
+
+func (s *Set) cleanWritten(now time.Time) {
+	s.written.Range(func(key, value interface{}) bool {
+		if now.UnixNano()-value.(int64) >= int64(s.opts.StickyDuration) {
+			s.written.Delete(key)
+		}
+		return true
+	})
+}
+
+// queryLag 查询复制延迟（秒）
+// 没有复制状态时（例如云数据库的只读地址）视为没有延迟 复制中断时延迟为NULL 返回错误
+func queryLag(session *dbr.Session) (int64, error) {
+	rows, err := session.Query("SHOW REPLICA STATUS")
+	if err != nil {
+		// MySQL 8.0.22之前的版本
+		rows, err = session.Query("SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	return parseLag(columns, values)
+}
+
+func parseLag(columns []string, values []sql.NullString) (int64, error) {
+	for _, lagColumn := range lagColumns {
+		for i, column := range columns {
+			if !strings.EqualFold(column, lagColumn) {
+				continue
+			}
+			if !values[i].Valid {
+				return 0, errReplicationStopped
+			}
+			return strconv.ParseInt(values[i].String, 10, 64)
+		}
+	}
+	return 0, errNoLagColumn
+}
+
+// addrName 去掉连接地址中的用户名和密码 例如 root:pwd@tcp(127.0.0.1:3306)/tsdd 为 tcp(127.0.0.1:3306)/tsdd
+func addrName(addr string) string {
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		addr = addr[i+1:]
+	}
+	if i := strings.Index(addr, "?"); i >= 0 {
+		addr = addr[:i]
+	}
+	return addr
+}
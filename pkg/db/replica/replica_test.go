@@ -0,0 +1,73 @@
+package replica
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLag(t *testing.T) {
+	lag, err := parseLag([]string{"Replica_IO_State", "Seconds_Behind_Source"}, []sql.NullString{{String: "", Valid: true}, {String: "3", Valid: true}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), lag)
+
+	lag, err = parseLag([]string{"Seconds_Behind_Master"}, []sql.NullString{{String: "12", Valid: true}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), lag)
+
+	_, err = parseLag([]string{"Seconds_Behind_Source"}, []sql.NullString{{Valid: false}})
+	assert.Equal(t, errReplicationStopped, err)
+
+	_, err = parseLag([]string{"Replica_IO_State"}, []sql.NullString{{String: "", Valid: true}})
+	assert.Equal(t, errNoLagColumn, err)
+}
+
+func TestAddrName(t *testing.T) {
+	assert.Equal(t, "tcp(127.0.0.1:3307)/test", addrName("root:demo@tcp(127.0.0.1:3307)/test?charset=utf8mb4&parseTime=true"))
+	assert.Equal(t, "tcp(db:3306)/tsdd", addrName("tcp(db:3306)/tsdd"))
+}
+
+func TestReader(t *testing.T) {
+	primary := &dbr.Session{}
+	r1 := &dbr.Session{}
+	r2 := &dbr.Session{}
+	s := newSet([]*dbr.Session{r1, r2}, []string{"r1", "r2"}, Options{
+		MaxLag:         time.Second * 5,
+		StickyDuration: time.Second * 5,
+	})
+	now := time.Now()
+
+	// 检查复制延迟之前不使用从库
+	assert.Same(t, primary, s.reader(primary, "u1", now))
+
+	s.nodes[0].available.Store(true)
+	s.nodes[1].available.Store(true)
+	first := s.reader(primary, "u1", now)
+	second := s.reader(primary, "u1", now)
+	assert.NotSame(t, primary, first)
+	assert.NotSame(t, first, second)
+
+	s.nodes[0].available.Store(false)
+	assert.Same(t, r2, s.reader(primary, "u1", now))
+	assert.Same(t, r2, s.reader(primary, "u1", now))
+
+	// 写入后StickyDuration内使用主库
+	s.markWritten(now, "u1")
+	assert.Same(t, primary, s.reader(primary, "u1", now.Add(time.Second)))
+	assert.Same(t, r2, s.reader(primary, "u2", now.Add(time.Second)))
+	assert.Same(t, r2, s.reader(primary, "u1", now.Add(time.Second*6)))
+
+	s.cleanWritten(now.Add(time.Second * 6))
+	_, ok := s.written.Load("u1")
+	assert.False(t, ok)
+}
+
+func TestReaderWithoutReplicas(t *testing.T) {
+	Configure(nil)
+	primary := &dbr.Session{}
+	assert.Same(t, primary, Reader(primary, "u1"))
+	MarkWritten("u1")
+}
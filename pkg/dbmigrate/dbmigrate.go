@@ -0,0 +1,159 @@
+// Package dbmigrate 执行、回滚和查看各模块sql目录中的数据库迁移
+//
+// 迁移文件与启动时自动执行的相同（sql-migrate格式 记录在gorp_migrations表） 关闭自动迁移后通过migrate命令手动执行
+package dbmigrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/db/postgres"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+// dialect 迁移记录表（gorp_migrations）的方言 迁移文件中的语句使用PostgreSQL时在驱动层改写
+func dialect() string {
+	if postgres.Enabled() {
+		return "postgres"
+	}
+	return "mysql"
+}
+
+// Source 所有模块的迁移文件 按迁移ID排序
+type Source struct {
+	sqlfss []*register.SQLFS
+}
+
+// NewSource 创建模块的迁移文件源
+func NewSource(modules []register.Module) *Source {
+	s := &Source{}
+	for _, m := range modules {
+		if m.SQLDir != nil {
+			s.sqlfss = append(s.sqlfss, m.SQLDir)
+		}
+	}
+	return s
+}
+
+// FindMigrations 实现migrate.MigrationSource
+func (s *Source) FindMigrations() ([]*migrate.Migration, error) {
+	migrations := make([]*migrate.Migration, 0, 100)
+	for _, sqlfs := range s.sqlfss {
+		files, err := sqlfs.ReadDir("sql")
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range files {
+			if !strings.HasSuffix(info.Name(), ".sql") {
+				continue
+			}
+			file, err := sqlfs.Open(path.Join("sql", info.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("打开%s失败：%w", info.Name(), err)
+			}
+			migration, err := migrate.ParseMigration(info.Name(), file.(io.ReadSeeker))
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("解析%s失败：%w", info.Name(), err)
+			}
+			migrations = append(migrations, migration)
+		}
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Less(migrations[j])
+	})
+	return migrations, nil
+}
+
+// Up 执行未执行的迁移 max为最多执行的数量 0为全部 返回执行的数量
+func Up(db *sql.DB, source migrate.MigrationSource, max int) (int, error) {
+	return migrate.ExecMax(db, dialect(), source, migrate.Up, max)
+}
+
+// Down 按执行的倒序回滚steps个迁移 返回回滚的数量
+// 迁移文件没有Down语句时不回滚 避免只删除了迁移记录而表结构没有变化
+func Down(db *sql.DB, source migrate.MigrationSource, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, errors.New("回滚的数量需要大于0")
+	}
+	planned, _, err := migrate.PlanMigration(db, dialect(), source, migrate.Down, steps)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range planned {
+		if len(m.Queries) == 0 {
+			return 0, fmt.Errorf("迁移%s没有Down语句，不能回滚", m.Id)
+		}
+	}
+	return migrate.ExecMax(db, dialect(), source, migrate.Down, steps)
+}
+
+// Status 迁移的状态
+type Status struct {
+	ID        string
+	Applied   bool      // 是否已执行
+	AppliedAt time.Time // 执行的时间
+	Unknown   bool      // 数据库中有记录但没有对应的迁移文件 一般为新版本执行过的迁移
+	Rollback  bool      // 是否可以回滚（有Down语句）
+}
+
+// Statuses 所有迁移的状态 按迁移ID排序
+func Statuses(db *sql.DB, source migrate.MigrationSource) ([]*Status, error) {
+	migrations, err := source.FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+	records, err := migrate.GetMigrationRecords(db, dialect())
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[string]time.Time, len(records))
+	for _, r := range records {
+		appliedAt[r.Id] = r.AppliedAt
+	}
+	statuses := make([]*Status, 0, len(migrations)+len(records))
+	known := make(map[string]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Id] = true
+		at, applied := appliedAt[m.Id]
+		statuses = append(statuses, &Status{
+			ID:        m.Id,
+			Applied:   applied,
+			AppliedAt: at,
+			Rollback:  len(m.Down) > 0,
+		})
+	}
+	for _, r := range records {
+		if !known[r.Id] {
+			statuses = append(statuses, &Status{
+				ID:        r.Id,
+				Applied:   true,
+				AppliedAt: r.AppliedAt,
+				Unknown:   true,
+			})
+		}
+	}
+	return statuses, nil
+}
+
+// Pending 未执行的迁移ID
+func Pending(db *sql.DB, source migrate.MigrationSource) ([]string, error) {
+	statuses, err := Statuses(db, source)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]string, 0)
+	for _, s := range statuses {
+		if !s.Applied {
+			pending = append(pending, s.ID)
+		}
+	}
+	return pending, nil
+}
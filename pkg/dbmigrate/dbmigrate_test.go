@@ -0,0 +1,25 @@
+package dbmigrate
+
+import (
+	"bytes"
+	"testing"
+
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeeds(t *testing.T) {
+	assert.Equal(t, []string{"demo"}, Seeds())
+	for _, name := range Seeds() {
+		data, err := seedFS.ReadFile("seed/" + name + ".sql")
+		assert.NoError(t, err)
+		seed, err := migrate.ParseMigration(name+".sql", bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, seed.Up, name)
+	}
+}
+
+func TestDownSteps(t *testing.T) {
+	_, err := Down(nil, &Source{}, 0)
+	assert.Error(t, err)
+}
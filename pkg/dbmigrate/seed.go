@@ -0,0 +1,51 @@
+package dbmigrate
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+//go:embed seed
+var seedFS embed.FS
+
+// Seeds 内置的初始数据 例如 demo
+func Seeds() []string {
+	files, _ := seedFS.ReadDir("seed")
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".sql") {
+			names = append(names, strings.TrimSuffix(f.Name(), ".sql"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Seed 在一个事务中写入初始数据 需要先执行迁移 初始数据的SQL可以重复执行
+func Seed(db *sql.DB, name string) error {
+	data, err := seedFS.ReadFile("seed/" + name + ".sql")
+	if err != nil {
+		return fmt.Errorf("初始数据%s不存在，可选：%s", name, strings.Join(Seeds(), "、"))
+	}
+	seed, err := migrate.ParseMigration(name+".sql", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("解析初始数据%s失败：%w", name, err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range seed.Up {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("写入初始数据%s失败：%w", name, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+-- 演示数据 可以重复执行 已存在的数据不修改
+-- 演示用户 使用用户名（demo_alice、demo_bob、demo_carol）登录 密码都为 demo123456 登录时会在IM中注册token
+insert ignore into `user` (uid, name, short_no, sex, username, password, zone, phone, vercode, qr_vercode, status)
+values
+  ('demo_alice', 'Alice', 'demo_alice', 0, 'demo_alice', MD5(MD5('demo123456')), '', '', 'demo_alice_vercode@1', 'demo_alice_qrvercode@3', 1),
+  ('demo_bob', 'Bob', 'demo_bob', 1, 'demo_bob', MD5(MD5('demo123456')), '', '', 'demo_bob_vercode@1', 'demo_bob_qrvercode@3', 1),
+  ('demo_carol', 'Carol', 'demo_carol', 0, 'demo_carol', MD5(MD5('demo123456')), '', '', 'demo_carol_vercode@1', 'demo_carol_qrvercode@3', 1);
+
+-- 演示用户互为好友
+insert ignore into `friend` (uid, to_uid, version, vercode, source_vercode, initiator)
+values
+  ('demo_alice', 'demo_bob', 1, 'demo_alice_bob@1', 'demo_bob_vercode@1', 1),
+  ('demo_bob', 'demo_alice', 1, 'demo_bob_alice@1', 'demo_bob_vercode@1', 0),
+  ('demo_alice', 'demo_carol', 1, 'demo_alice_carol@1', 'demo_carol_vercode@1', 1),
+  ('demo_carol', 'demo_alice', 1, 'demo_carol_alice@1', 'demo_carol_vercode@1', 0),
+  ('demo_bob', 'demo_carol', 1, 'demo_bob_carol@1', 'demo_carol_vercode@1', 1),
+  ('demo_carol', 'demo_bob', 1, 'demo_carol_bob@1', 'demo_carol_vercode@1', 0);
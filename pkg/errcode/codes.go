@@ -0,0 +1,203 @@
+package errcode
+
+// 通用
+var (
+	ErrInvalidData      = New("invalid_data", "数据格式有误！")
+	ErrTxBegin          = New("tx_begin_failed", "开启事务失败！")
+	ErrTxCommit         = New("tx_commit_failed", "提交事务失败！")
+	ErrPermissionDenied = New("permission_denied", "该用户无权执行此操作")
+	ErrTooFrequent      = New("too_frequent", "请求过于频繁，请稍后再试！")
+)
+
+// 用户
+var (
+	ErrUserNotExist      = New("user_not_exist", "用户不存在！")
+	ErrLoginUserNotExist = New("login_user_not_exist", "登录用户不存在！")
+	ErrQueryUser         = New("user_query_failed", "查询用户信息失败！")
+	ErrUIDRequired       = New("user_uid_required", "uid不能为空！")
+	ErrUsernameRequired  = New("user_username_required", "用户名不能为空！")
+	ErrPasswordRequired  = New("user_password_required", "密码不能为空！")
+	ErrPhoneRequired     = New("user_phone_required", "手机号不能为空！")
+	ErrZoneRequired      = New("user_zone_required", "区号不能为空！")
+	ErrAccountBanned     = New("user_banned", "此账号已经被封禁！")
+	ErrRegister          = New("user_register_failed", "注册失败！")
+	ErrAddFriend         = New("friend_add_failed", "添加好友失败！")
+	ErrLoginRequired     = New("auth_login_required", "请先登录！")
+	ErrTokenRequired     = New("auth_token_required", "token不能为空，请先登录！")
+	ErrTokenInvalid      = New("auth_token_invalid", "token有误！")
+)
+
+// 验证码
+var (
+	ErrVerifyCodeRequired    = New("verify_code_required", "验证码不能为空！")
+	ErrVerifyCodeInvalid     = New("verify_code_invalid", "验证码错误")
+	ErrVerifyCodeTooFrequent = New("verify_code_too_frequent", "验证码发送过于频繁，请稍后再试！")
+	ErrVoiceCodeTooFrequent  = New("verify_code_voice_too_frequent", "语音验证码发送过于频繁，请稍后再试！")
+	ErrSMSTooFrequent        = New("sms_too_frequent", "发送过于频繁，请稍后再试！")
+)
+
+// 群和频道
+var (
+	ErrGroupNoRequired      = New("group_no_required", "群编号不能为空")
+	ErrGroupNotExist        = New("group_not_exist", "群不存在！")
+	ErrQueryGroup           = New("group_query_failed", "查询群信息失败！")
+	ErrGroupMembersRequired = New("group_members_required", "群成员不能为空！")
+	ErrQueryGroupMember     = New("group_query_member_failed", "查询群成员失败！")
+	ErrChannelIDRequired    = New("channel_id_required", "频道ID不能为空！")
+	ErrChannelTypeRequired  = New("channel_type_required", "频道类型不能为空！")
+)
+
+// 消息
+var (
+	ErrMessageNotExist = New("message_not_exist", "消息不存在！")
+	ErrQueryMessage    = New("message_query_failed", "查询消息失败！")
+	ErrSendMessage     = New("message_send_failed", "发送消息失败！")
+	ErrSendCMD         = New("message_send_cmd_failed", "发送命令消息失败！")
+)
+
+// 文件
+var (
+	ErrFileNotExist     = New("file_not_exist", "文件不存在！")
+	ErrFilePathRequired = New("file_path_required", "文件路径不能为空！")
+	ErrReadFile         = New("file_read_failed", "读取文件失败！")
+	ErrUploadFile       = New("file_upload_failed", "上传文件失败！")
+)
+
+// 机器人和应用
+var (
+	ErrRobotIDRequired = New("robot_id_required", "机器人ID不能为空")
+	ErrAppIDRequired   = New("app_id_required", "应用ID不能为空")
+)
+
+// 组织（多租户）
+var (
+	ErrTenantNotExist    = New("tenant_not_exist", "组织不存在！")
+	ErrTenantMismatch    = New("tenant_mismatch", "账号不属于当前组织！")
+	ErrTenantCrossUser   = New("tenant_cross_user", "不能与其他组织的用户建立关系！")
+	ErrTenantUserQuota   = New("tenant_user_quota", "组织的用户数已达上限！")
+	ErrTenantGroupQuota  = New("tenant_group_quota", "组织的群数已达上限！")
+	ErrTenantFileQuota   = New("tenant_file_quota", "组织的文件存储空间已达上限！")
+	ErrTenantManagerOnly = New("tenant_manager_forbidden", "组织的账号不能使用管理后台！")
+)
+
+func init() {
+	// 各模块中意思相同的旧信息
+	Alias(ErrInvalidData, "请求数据格式有误！")
+	Alias(ErrTxBegin, "开启事件失败！")
+	Alias(ErrTxCommit, "事务提交失败！", "数据库事物提交失败")
+	Alias(ErrUserNotExist, "该用户不存在", "用户信息不存在！")
+	Alias(ErrQueryUser, "查询用户信息错误", "查询用户失败！", "获取用户详情失败！")
+	Alias(ErrUIDRequired, "用户ID不能为空", "用户uid不能为空")
+	Alias(ErrGroupNotExist, "群不存在")
+	Alias(ErrQueryGroupMember, "查询群成员错误")
+	Alias(ErrChannelIDRequired, "channel_id不能为空！", "频道不能为空！")
+	Alias(ErrChannelTypeRequired, "channel_type不能为空！")
+}
+
+var messagesHant = map[string]string{
+	ErrInvalidData.Code:           "數據格式有誤！",
+	ErrTxBegin.Code:               "開啟事務失敗！",
+	ErrTxCommit.Code:              "提交事務失敗！",
+	ErrPermissionDenied.Code:      "該用戶無權執行此操作",
+	ErrTooFrequent.Code:           "請求過於頻繁，請稍後再試！",
+	ErrUserNotExist.Code:          "用戶不存在！",
+	ErrLoginUserNotExist.Code:     "登錄用戶不存在！",
+	ErrQueryUser.Code:             "查詢用戶信息失敗！",
+	ErrUIDRequired.Code:           "uid不能為空！",
+	ErrUsernameRequired.Code:      "用戶名不能為空！",
+	ErrPasswordRequired.Code:      "密碼不能為空！",
+	ErrPhoneRequired.Code:         "手機號不能為空！",
+	ErrZoneRequired.Code:          "區號不能為空！",
+	ErrAccountBanned.Code:         "此賬號已經被封禁！",
+	ErrRegister.Code:              "註冊失敗！",
+	ErrAddFriend.Code:             "添加好友失敗！",
+	ErrLoginRequired.Code:         "請先登錄！",
+	ErrTokenRequired.Code:         "token不能為空，請先登錄！",
+	ErrTokenInvalid.Code:          "token有誤！",
+	ErrVerifyCodeRequired.Code:    "驗證碼不能為空！",
+	ErrVerifyCodeInvalid.Code:     "驗證碼錯誤",
+	ErrVerifyCodeTooFrequent.Code: "驗證碼發送過於頻繁，請稍後再試！",
+	ErrVoiceCodeTooFrequent.Code:  "語音驗證碼發送過於頻繁，請稍後再試！",
+	ErrSMSTooFrequent.Code:        "發送過於頻繁，請稍後再試！",
+	ErrGroupNoRequired.Code:       "群編號不能為空",
+	ErrGroupNotExist.Code:         "群不存在！",
+	ErrQueryGroup.Code:            "查詢群信息失敗！",
+	ErrGroupMembersRequired.Code:  "群成員不能為空！",
+	ErrQueryGroupMember.Code:      "查詢群成員失敗！",
+	ErrChannelIDRequired.Code:     "頻道ID不能為空！",
+	ErrChannelTypeRequired.Code:   "頻道類型不能為空！",
+	ErrMessageNotExist.Code:       "消息不存在！",
+	ErrQueryMessage.Code:          "查詢消息失敗！",
+	ErrSendMessage.Code:           "發送消息失敗！",
+	ErrSendCMD.Code:               "發送命令消息失敗！",
+	ErrFileNotExist.Code:          "文件不存在！",
+	ErrFilePathRequired.Code:      "文件路徑不能為空！",
+	ErrReadFile.Code:              "讀取文件失敗！",
+	ErrUploadFile.Code:            "上傳文件失敗！",
+	ErrRobotIDRequired.Code:       "機器人ID不能為空",
+	ErrAppIDRequired.Code:         "應用ID不能為空",
+	ErrTenantNotExist.Code:        "組織不存在！",
+	ErrTenantMismatch.Code:        "賬號不屬於當前組織！",
+	ErrTenantCrossUser.Code:       "不能與其他組織的用戶建立關係！",
+	ErrTenantUserQuota.Code:       "組織的用戶數已達上限！",
+	ErrTenantGroupQuota.Code:      "組織的群數已達上限！",
+	ErrTenantFileQuota.Code:       "組織的文件存儲空間已達上限！",
+	ErrTenantManagerOnly.Code:     "組織的賬號不能使用管理後台！",
+}
+
+// catalog 内置的错误信息 语言（小写） => 错误码 => 信息 中文使用代码中的信息
+var catalog = map[string]map[string]string{
+	"zh-hant": messagesHant,
+	"zh-tw":   messagesHant,
+	"zh-hk":   messagesHant,
+	"en": {
+		ErrInvalidData.Code:           "Invalid request data",
+		ErrTxBegin.Code:               "Failed to start transaction",
+		ErrTxCommit.Code:              "Failed to commit transaction",
+		ErrPermissionDenied.Code:      "You do not have permission to perform this operation",
+		ErrTooFrequent.Code:           "Too many requests, please try again later",
+		ErrUserNotExist.Code:          "User does not exist",
+		ErrLoginUserNotExist.Code:     "Logged-in user does not exist",
+		ErrQueryUser.Code:             "Failed to query user",
+		ErrUIDRequired.Code:           "uid is required",
+		ErrUsernameRequired.Code:      "Username is required",
+		ErrPasswordRequired.Code:      "Password is required",
+		ErrPhoneRequired.Code:         "Phone number is required",
+		ErrZoneRequired.Code:          "Country code is required",
+		ErrAccountBanned.Code:         "This account has been banned",
+		ErrRegister.Code:              "Registration failed",
+		ErrAddFriend.Code:             "Failed to add friend",
+		ErrLoginRequired.Code:         "Please log in first",
+		ErrTokenRequired.Code:         "Token is required, please log in first",
+		ErrTokenInvalid.Code:          "Invalid token",
+		ErrVerifyCodeRequired.Code:    "Verification code is required",
+		ErrVerifyCodeInvalid.Code:     "Incorrect verification code",
+		ErrVerifyCodeTooFrequent.Code: "Verification codes are being sent too frequently, please try again later",
+		ErrVoiceCodeTooFrequent.Code:  "Voice verification codes are being sent too frequently, please try again later",
+		ErrSMSTooFrequent.Code:        "Sent too frequently, please try again later",
+		ErrGroupNoRequired.Code:       "Group number is required",
+		ErrGroupNotExist.Code:         "Group does not exist",
+		ErrQueryGroup.Code:            "Failed to query group",
+		ErrGroupMembersRequired.Code:  "Group members are required",
+		ErrQueryGroupMember.Code:      "Failed to query group members",
+		ErrChannelIDRequired.Code:     "Channel ID is required",
+		ErrChannelTypeRequired.Code:   "Channel type is required",
+		ErrMessageNotExist.Code:       "Message does not exist",
+		ErrQueryMessage.Code:          "Failed to query messages",
+		ErrSendMessage.Code:           "Failed to send message",
+		ErrSendCMD.Code:               "Failed to send command message",
+		ErrFileNotExist.Code:          "File does not exist",
+		ErrFilePathRequired.Code:      "File path is required",
+		ErrReadFile.Code:              "Failed to read file",
+		ErrUploadFile.Code:            "Failed to upload file",
+		ErrRobotIDRequired.Code:       "Robot ID is required",
+		ErrAppIDRequired.Code:         "App ID is required",
+		ErrTenantNotExist.Code:        "Organization does not exist",
+		ErrTenantMismatch.Code:        "This account does not belong to the current organization",
+		ErrTenantCrossUser.Code:       "Cannot connect with users from another organization",
+		ErrTenantUserQuota.Code:       "The organization has reached its user limit",
+		ErrTenantGroupQuota.Code:      "The organization has reached its group limit",
+		ErrTenantFileQuota.Code:       "The organization has reached its storage limit",
+		ErrTenantManagerOnly.Code:     "Organization accounts cannot use the admin console",
+	},
+}
@@ -0,0 +1,165 @@
+// Package errcode 接口的错误码和多语言的错误信息
+//
+// 错误通过New创建 Error()返回中文信息 与原来的errors.New用法相同 可以直接传给c.ResponseError
+// Middleware按错误信息找到错误码 按Accept-Language替换为对应语言的信息 返回：
+//
+//	{"status": 400, "code": "verify_code_too_frequent", "msg": "Verification codes are being sent too frequently, please try again later"}
+//
+// 没有登记的中文信息使用按状态码的通用错误码（例如 bad_request） 信息不翻译
+package errcode
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+)
+
+// DefaultLocale 没有配置默认语言时使用的语言 与代码中的错误信息相同
+const DefaultLocale = "zh"
+
+// Error 带错误码的错误
+type Error struct {
+	Code    string // 错误码 例如 verify_code_too_frequent
+	Message string // 中文信息
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	registryLock sync.RWMutex
+	codeMessages = map[string]string{} // 错误码 => 中文信息
+	messageCodes = map[string]string{} // 中文信息（去掉结尾的标点） => 错误码
+)
+
+// New 创建并登记错误码 同一个错误码只能登记一次
+func New(code string, message string) *Error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := codeMessages[code]; ok {
+		panic("错误码重复：" + code)
+	}
+	codeMessages[code] = message
+	messageCodes[messageKey(message)] = code
+	return &Error{Code: code, Message: message}
+}
+
+// Alias 登记与错误意思相同的旧信息 例如“用户不存在”与“该用户不存在”
+func Alias(err *Error, messages ...string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	for _, message := range messages {
+		messageCodes[messageKey(message)] = err.Code
+	}
+}
+
+// Lookup 中文信息对应的错误码
+func Lookup(message string) (string, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	code, ok := messageCodes[messageKey(message)]
+	return code, ok
+}
+
+// messageKey 忽略结尾的标点 “用户不存在！”与“用户不存在”为同一个信息
+func messageKey(message string) string {
+	return strings.TrimRight(strings.TrimSpace(message), "！!。.")
+}
+
+// StatusCode 没有登记的错误按状态码使用的错误码
+func StatusCode(status int) string {
+	switch status {
+	case 400:
+		return "bad_request"
+	case 401:
+		return "unauthorized"
+	case 403:
+		return "forbidden"
+	case 404:
+		return "not_found"
+	case 429:
+		return "too_many_requests"
+	}
+	if status >= 500 {
+		return "internal_error"
+	}
+	return "error_" + strconv.Itoa(status)
+}
+
+// Message 错误码对应语言的信息 先找配置的信息再找内置的信息 locales为按优先级排列的语言（小写）
+// 匹配到中文或没有对应的信息时返回false 调用方使用原来的中文信息
+func Message(code string, locales []string) (string, bool) {
+	configured := extconfig.Get().I18n.Messages
+	for _, locale := range locales {
+		if message, ok := configured[locale][code]; ok {
+			return message, true
+		}
+		if locale == DefaultLocale {
+			return "", false
+		}
+		if message, ok := catalog[locale][code]; ok {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+// Locales 按Accept-Language的权重排列的语言 每个语言依次去掉地区 最后是默认语言
+// 例如 "zh-TW,en;q=0.8" => zh-tw、zh、en、默认语言
+func Locales(acceptLanguage string, defaultLocale string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	items := make([]weighted, 0, 4)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		params := strings.Split(part, ";")
+		locale := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(params[0]), "_", "-"))
+		if locale == "" || locale == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		items = append(items, weighted{locale: locale, q: q})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+
+	locales := make([]string, 0, len(items)*2+2)
+	seen := map[string]bool{}
+	add := func(locale string) {
+		if locale != "" && !seen[locale] {
+			seen[locale] = true
+			locales = append(locales, locale)
+		}
+	}
+	for _, item := range items {
+		locale := item.locale
+		for locale != "" {
+			add(locale)
+			idx := strings.LastIndex(locale, "-")
+			if idx <= 0 {
+				break
+			}
+			locale = locale[:idx]
+		}
+	}
+	add(strings.ToLower(strings.TrimSpace(defaultLocale)))
+	add(DefaultLocale)
+	return locales
+}
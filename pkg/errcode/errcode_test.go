@@ -0,0 +1,89 @@
+package errcode
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocales(t *testing.T) {
+	assert.Equal(t, []string{"zh"}, Locales("", ""))
+	assert.Equal(t, []string{"en", "zh"}, Locales("", "en"))
+	assert.Equal(t, []string{"zh-hant-tw", "zh-hant", "zh", "en"}, Locales("en;q=0.8, zh-Hant-TW", "en"))
+	assert.Equal(t, []string{"en-us", "en", "zh"}, Locales("en_US,fr;q=0", ""))
+}
+
+func TestLookup(t *testing.T) {
+	code, ok := Lookup("验证码发送过于频繁，请稍后再试！")
+	assert.True(t, ok)
+	assert.Equal(t, ErrVerifyCodeTooFrequent.Code, code)
+	// 忽略结尾的标点和意思相同的旧信息
+	code, _ = Lookup("用户不存在")
+	assert.Equal(t, ErrUserNotExist.Code, code)
+	code, _ = Lookup("该用户不存在")
+	assert.Equal(t, ErrUserNotExist.Code, code)
+	_, ok = Lookup("没有登记的错误")
+	assert.False(t, ok)
+}
+
+func TestCatalog(t *testing.T) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	for locale, messages := range catalog {
+		for code := range messages {
+			_, ok := codeMessages[code]
+			assert.True(t, ok, "%s: %s", locale, code)
+		}
+	}
+	// 内置的翻译需要覆盖所有的错误码
+	for code := range codeMessages {
+		assert.NotEmpty(t, catalog["en"][code], code)
+		assert.NotEmpty(t, catalog["zh-hant"][code], code)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/coded", func(c *gin.Context) {
+		c.JSON(http.StatusBadRequest, gin.H{"msg": ErrVerifyCodeTooFrequent.Error(), "status": http.StatusBadRequest})
+	})
+	r.GET("/unknown", func(c *gin.Context) {
+		c.JSON(http.StatusBadRequest, gin.H{"msg": errors.New("没有登记的错误").Error(), "status": http.StatusBadRequest})
+	})
+	r.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"msg": ErrVerifyCodeTooFrequent.Error()})
+	})
+
+	request := func(path string, acceptLanguage string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/coded", "en-US,en;q=0.9")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"code":"verify_code_too_frequent","msg":"Verification codes are being sent too frequently, please try again later","status":400}`, w.Body.String())
+
+	w = request("/coded", "")
+	assert.JSONEq(t, `{"code":"verify_code_too_frequent","msg":"验证码发送过于频繁，请稍后再试！","status":400}`, w.Body.String())
+
+	w = request("/coded", "zh-TW")
+	assert.JSONEq(t, `{"code":"verify_code_too_frequent","msg":"驗證碼發送過於頻繁，請稍後再試！","status":400}`, w.Body.String())
+
+	w = request("/unknown", "en")
+	assert.JSONEq(t, `{"code":"bad_request","msg":"没有登记的错误","status":400}`, w.Body.String())
+
+	// 成功的返回不处理
+	w = request("/ok", "en")
+	assert.JSONEq(t, `{"msg":"验证码发送过于频繁，请稍后再试！"}`, w.Body.String())
+}
@@ -0,0 +1,77 @@
+package errcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware 错误的返回（状态码>=400的JSON 包含msg）加上错误码 并按Accept-Language翻译msg
+// 已有的字段（例如 status、uid）保持不变 返回中已有code的不处理
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &errorWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.buf == nil {
+			return
+		}
+		body := w.buf.Bytes()
+		if newBody, ok := localize(body, w.Status(), c.GetHeader("Accept-Language")); ok {
+			body = newBody
+		}
+		w.Header().Del("Content-Length")
+		_, _ = w.ResponseWriter.Write(body)
+	}
+}
+
+// localize 返回加上错误码和翻译后的内容 不是错误的返回时返回false
+func localize(body []byte, status int, acceptLanguage string) ([]byte, bool) {
+	var resp map[string]interface{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, false
+	}
+	msg, ok := resp["msg"].(string)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := resp["code"]; ok {
+		return nil, false
+	}
+	code, ok := Lookup(msg)
+	if !ok {
+		code = StatusCode(status)
+	} else if message, ok := Message(code, Locales(acceptLanguage, extconfig.Get().I18n.DefaultLocale)); ok {
+		resp["msg"] = message
+	}
+	resp["code"] = code
+	newBody, err := json.Marshal(resp)
+	if err != nil {
+		return nil, false
+	}
+	return newBody, true
+}
+
+// errorWriter 错误的JSON先写入buf 请求处理完后再翻译 其他的返回直接写入
+type errorWriter struct {
+	gin.ResponseWriter
+	buf *bytes.Buffer
+}
+
+func (w *errorWriter) Write(data []byte) (int, error) {
+	if w.buf == nil && !w.ResponseWriter.Written() && w.Status() >= 400 && strings.Contains(w.Header().Get("Content-Type"), "json") {
+		w.buf = &bytes.Buffer{}
+	}
+	if w.buf != nil {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,246 @@
+// Package eventbus 把领域事件（消息发送、撤回、用户注册、群创建、会话删除等）发布到Kafka或NATS 供数据分析和第三方集成消费
+// 事件先写入后台任务队列 再由任务批量发布 发布失败时按任务队列的规则重试 重试次数用完后可以在任务管理接口中重新执行
+// 没有配置Kafka或NATS时事件也会交给订阅者（例如事件webhook）
+// 同一个事件可能被发布多次 也不保证顺序 消费方需要用事件id去重 需要顺序时使用occurred_at或data中的版本号
+//
+// 事件格式（JSON）：
+//
+//	{
+//	  "id": "事件id",
+//	  "type": "message.sent",
+//	  "version": 1,              // data的结构版本 只会增加字段 删除或修改字段时版本号加1
+//	  "source": "tsdd",          // 产生事件的服务
+//	  "occurred_at": 1700000000000, // 事件发生的时间（毫秒）
+//	  "key": "频道id或uid",        // 分区键 同一个key的事件发到Kafka的同一个分区
+//	  "data": {...}              // 事件内容 见events.go
+//	}
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/jobqueue"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"go.uber.org/zap"
+)
+
+// 事件通过后台任务发布
+const (
+	jobQueueEventBus  = "eventbus"
+	JobTypePublish    = "eventbus.publish"
+	maxEventsOfOneJob = 100 // 一个任务最多发布的事件数
+)
+
+// Event 领域事件
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	Source     string          `json:"source"`
+	OccurredAt int64           `json:"occurred_at"`
+	Key        string          `json:"key"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Publisher 把事件发布到消息中间件 全部发布成功才返回nil
+type Publisher interface {
+	Publish(ctx context.Context, events []*Event) error
+	Close() error
+}
+
+// Subscriber 事件的订阅者 发布事件时同步调用Receive 需要尽快返回
+type Subscriber interface {
+	// Enabled 是否订阅了此类型的事件
+	Enabled(eventType string) bool
+	// Receive 收到事件 只包含订阅了的类型
+	Receive(events []*Event)
+}
+
+// Options 事件总线配置
+type Options struct {
+	Source string   // 产生事件的服务 默认为tsdd
+	Events []string // 发布的事件类型 为空时发布所有事件
+}
+
+// Bus 事件总线
+type Bus struct {
+	log.Log
+	publisher Publisher
+	source    string
+	events    map[string]struct{}
+}
+
+var current atomic.Pointer[Bus]
+
+var (
+	subscribersLock sync.RWMutex
+	subscribers     []Subscriber
+)
+
+// defaultSource 没有配置事件总线时事件的来源
+const defaultSource = "tsdd"
+
+// New 创建事件总线
+func New(publisher Publisher, opts Options) *Bus {
+	if opts.Source == "" {
+		opts.Source = defaultSource
+	}
+	var events map[string]struct{}
+	if len(opts.Events) > 0 {
+		events = make(map[string]struct{}, len(opts.Events))
+		for _, eventType := range opts.Events {
+			events[eventType] = struct{}{}
+		}
+	}
+	return &Bus{
+		Log:       log.NewTLog("EventBus"),
+		publisher: publisher,
+		source:    opts.Source,
+		events:    events,
+	}
+}
+
+// Configure 设置全局使用的事件总线 需要在任务队列Start之前调用 为nil时不发布事件
+func Configure(b *Bus) {
+	if old := current.Swap(b); old != nil && old != b {
+		_ = old.publisher.Close()
+	}
+	if b != nil {
+		jobqueue.Register(jobQueueEventBus, JobTypePublish, handlePublishJob)
+	}
+}
+
+// Close 关闭事件总线的连接 进程退出时在任务队列停止后调用 之后不再发布事件
+func Close() error {
+	if old := current.Swap(nil); old != nil {
+		return old.publisher.Close()
+	}
+	return nil
+}
+
+// Get 全局使用的事件总线 没有配置时为nil
+func Get() *Bus {
+	return current.Load()
+}
+
+// Subscribe 添加订阅者 一般在模块初始化时添加
+func Subscribe(s Subscriber) {
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	subscribers = append(subscribers, s)
+}
+
+func getSubscribers() []Subscriber {
+	subscribersLock.RLock()
+	defer subscribersLock.RUnlock()
+	return subscribers
+}
+
+// Enabled 是否需要发布此类型的事件 组装事件内容代价较大时先判断
+func Enabled(eventType string) bool {
+	if b := current.Load(); b != nil && b.enabled(eventType) {
+		return true
+	}
+	for _, s := range getSubscribers() {
+		if s.Enabled(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEvent 创建事件 data会序列化为JSON
+func NewEvent(eventType string, key string, data interface{}) *Event {
+	raw, _ := json.Marshal(data)
+	return &Event{
+		ID:         util.GenerUUID(),
+		Type:       eventType,
+		Version:    SchemaVersion,
+		OccurredAt: time.Now().UnixMilli(),
+		Key:        key,
+		Data:       raw,
+	}
+}
+
+// Publish 使用全局的事件总线发布事件并交给订阅者 没有配置事件总线时只交给订阅者
+// 在数据库事务提交后调用 只写入任务队列 不等待发布到消息中间件 失败时只记录日志 不影响业务
+func Publish(events ...*Event) {
+	b := current.Load()
+	source := defaultSource
+	if b != nil {
+		source = b.source
+	}
+	for _, e := range events {
+		if e != nil {
+			e.Source = source
+		}
+	}
+	if b != nil {
+		b.Publish(events...)
+	}
+	for _, s := range getSubscribers() {
+		subscribed := make([]*Event, 0, len(events))
+		for _, e := range events {
+			if e != nil && s.Enabled(e.Type) {
+				subscribed = append(subscribed, e)
+			}
+		}
+		if len(subscribed) > 0 {
+			s.Receive(subscribed)
+		}
+	}
+}
+
+// Publish 发布事件
+func (b *Bus) Publish(events ...*Event) {
+	batch := make([]*Event, 0, len(events))
+	for _, e := range events {
+		if e == nil || !b.enabled(e.Type) {
+			continue
+		}
+		if e.Source == "" {
+			e.Source = b.source
+		}
+		batch = append(batch, e)
+	}
+	for len(batch) > 0 {
+		n := len(batch)
+		if n > maxEventsOfOneJob {
+			n = maxEventsOfOneJob
+		}
+		if _, err := jobqueue.Enqueue(JobTypePublish, batch[:n]); err != nil {
+			b.Error("添加发布事件的任务失败！", zap.Error(err), zap.String("type", batch[0].Type), zap.Int("count", n))
+		}
+		batch = batch[n:]
+	}
+}
+
+func (b *Bus) enabled(eventType string) bool {
+	if b.events == nil {
+		return true
+	}
+	_, ok := b.events[eventType]
+	return ok
+}
+
+// handlePublishJob 把任务中的事件发布到消息中间件
+func handlePublishJob(ctx context.Context, job *jobqueue.Job) error {
+	b := current.Load()
+	if b == nil {
+		return errors.New("事件总线未配置")
+	}
+	var events []*Event
+	if err := job.Bind(&events); err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return b.publisher.Publish(ctx, events)
+}
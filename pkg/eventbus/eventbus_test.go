@@ -0,0 +1,185 @@
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEvent(t *testing.T) {
+	e := NewEvent(MessageRevoked, "g1", &MessageRevokedData{
+		MessageIDs:  []string{"1"},
+		ChannelID:   "g1",
+		ChannelType: 2,
+		Operator:    "u1",
+	})
+	assert.NotEmpty(t, e.ID)
+	assert.Equal(t, SchemaVersion, e.Version)
+	assert.True(t, e.OccurredAt > 0)
+
+	data, err := json.Marshal(e)
+	assert.NoError(t, err)
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &m))
+	for _, field := range []string{"id", "type", "version", "source", "occurred_at", "key", "data"} {
+		assert.Contains(t, m, field)
+	}
+	assert.Equal(t, "u1", m["data"].(map[string]interface{})["operator"])
+}
+
+func TestBusEnabled(t *testing.T) {
+	b := New(nil, Options{})
+	assert.Equal(t, "tsdd", b.source)
+	assert.True(t, b.enabled(MessageSent))
+
+	b = New(nil, Options{Events: []string{UserRegistered}})
+	assert.True(t, b.enabled(UserRegistered))
+	assert.False(t, b.enabled(MessageSent))
+
+	Configure(nil)
+	assert.False(t, Enabled(UserRegistered))
+	Publish(NewEvent(UserRegistered, "u1", nil))
+}
+
+// fakeNATS 读取客户端的命令 收到PING时回复PONG
+func fakeNATS(t *testing.T, errOnPub bool) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	received := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte(`INFO {"max_payload":1048576}` + "\r\n"))
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case line == "PING":
+				conn.Write([]byte("PONG\r\n"))
+			case strings.HasPrefix(line, "PUB "):
+				parts := strings.Split(line, " ")
+				size, _ := strconv.Atoi(parts[2])
+				payload := make([]byte, size+2)
+				io.ReadFull(reader, payload)
+				if errOnPub {
+					conn.Write([]byte("-ERR 'Permissions Violation'\r\n"))
+					continue
+				}
+				received <- parts[1] + " " + string(payload[:size])
+			case strings.HasPrefix(line, "CONNECT "):
+				received <- line
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestNATSPublisher(t *testing.T) {
+	addr, received := fakeNATS(t, false)
+	p, err := NewNATSPublisher(NATSOptions{Addrs: []string{"nats://" + addr}, Subject: "tsdd.events", Token: "secret"})
+	assert.NoError(t, err)
+	defer p.Close()
+
+	e := NewEvent(GroupCreated, "g1", &GroupCreatedData{GroupNo: "g1"})
+	assert.NoError(t, p.Publish(context.Background(), []*Event{e}))
+
+	connect := <-received
+	assert.True(t, strings.HasPrefix(connect, "CONNECT "))
+	assert.Contains(t, connect, `"auth_token":"secret"`)
+	pub := <-received
+	assert.True(t, strings.HasPrefix(pub, "tsdd.events.group.created "))
+	var got Event
+	assert.NoError(t, json.Unmarshal([]byte(strings.SplitN(pub, " ", 2)[1]), &got))
+	assert.Equal(t, e.ID, got.ID)
+	assert.Equal(t, int64(1048576), p.(*natsPublisher).maxPayload)
+}
+
+func TestNATSPublisherError(t *testing.T) {
+	addr, _ := fakeNATS(t, true)
+	p, err := NewNATSPublisher(NATSOptions{Addrs: []string{addr}, Subject: "tsdd.events"})
+	assert.NoError(t, err)
+	defer p.Close()
+
+	err = p.Publish(context.Background(), []*Event{NewEvent(GroupCreated, "g1", nil)})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Permissions Violation")
+	// 出错后断开连接 下次发布时重新连接
+	assert.Nil(t, p.(*natsPublisher).conn)
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var records []kafkaRecord
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/tsdd.events", r.URL.Path)
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "u", user)
+		assert.Equal(t, "p", pass)
+		var body struct {
+			Records []kafkaRecord `json:"records"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		records = body.Records
+		if fail {
+			w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"Kafka error"}]}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":10,"error_code":null,"error":null}]}`))
+	}))
+	defer server.Close()
+
+	p, err := NewKafkaPublisher(KafkaOptions{RestURL: server.URL + "/", Topic: "tsdd.events", Username: "u", Password: "p"})
+	assert.NoError(t, err)
+	e := NewEvent(UserRegistered, "u1", &UserRegisteredData{UID: "u1"})
+	assert.NoError(t, p.Publish(context.Background(), []*Event{e}))
+	assert.Len(t, records, 1)
+	assert.Equal(t, "u1", records[0].Key)
+	assert.Equal(t, e.ID, records[0].Value.ID)
+
+	fail = true
+	err = p.Publish(context.Background(), []*Event{e})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "50003")
+}
+
+type fakeSubscriber struct {
+	eventType string
+	received  []*Event
+}
+
+func (f *fakeSubscriber) Enabled(eventType string) bool { return eventType == f.eventType }
+func (f *fakeSubscriber) Receive(events []*Event)       { f.received = append(f.received, events...) }
+
+func TestSubscriber(t *testing.T) {
+	Configure(nil)
+	s := &fakeSubscriber{eventType: GroupCreated}
+	Subscribe(s)
+	defer func() {
+		subscribers = nil
+	}()
+	assert.True(t, Enabled(GroupCreated))
+	assert.False(t, Enabled(MessageSent))
+
+	Publish(NewEvent(MessageSent, "g1", nil), NewEvent(GroupCreated, "g1", nil))
+	assert.Len(t, s.received, 1)
+	assert.Equal(t, GroupCreated, s.received[0].Type)
+	assert.Equal(t, defaultSource, s.received[0].Source)
+}
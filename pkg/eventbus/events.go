@@ -0,0 +1,61 @@
+package eventbus
+
+// SchemaVersion 事件内容的结构版本
+const SchemaVersion = 1
+
+// 事件类型
+const (
+	MessageSent         = "message.sent"         // 消息已发送 key为频道id
+	MessageRevoked      = "message.revoked"      // 消息已撤回 key为频道id
+	UserRegistered      = "user.registered"      // 用户已注册 key为uid
+	GroupCreated        = "group.created"        // 群已创建 key为群编号
+	ConversationDeleted = "conversation.deleted" // 最近会话已删除 key为uid
+)
+
+// EventTypes 所有的事件类型
+var EventTypes = []string{MessageSent, MessageRevoked, UserRegistered, GroupCreated, ConversationDeleted}
+
+// MessageSentData message.sent的内容
+// 个人频道的channel_id为接收者的uid
+type MessageSentData struct {
+	MessageID   string `json:"message_id"`
+	MessageSeq  uint32 `json:"message_seq"`
+	ClientMsgNo string `json:"client_msg_no"`
+	FromUID     string `json:"from_uid"`
+	ChannelID   string `json:"channel_id"`
+	ChannelType uint8  `json:"channel_type"`
+	ContentType int    `json:"content_type"`      // 消息正文的类型
+	Timestamp   int32  `json:"timestamp"`         // 消息时间（秒）
+	Payload     []byte `json:"payload,omitempty"` // 消息正文（base64） 配置了包含消息正文时才有
+}
+
+// MessageRevokedData message.revoked的内容
+type MessageRevokedData struct {
+	MessageIDs  []string `json:"message_ids"`
+	ChannelID   string   `json:"channel_id"`
+	ChannelType uint8    `json:"channel_type"`
+	Operator    string   `json:"operator"` // 撤回者
+}
+
+// UserRegisteredData user.registered的内容
+type UserRegisteredData struct {
+	UID       string `json:"uid"`
+	Name      string `json:"name"`
+	InviteUID string `json:"invite_uid,omitempty"` // 邀请者
+	Source    string `json:"source"`               // 注册方式 app或manager（后台添加）
+}
+
+// GroupCreatedData group.created的内容
+type GroupCreatedData struct {
+	GroupNo string   `json:"group_no"`
+	Name    string   `json:"name"`
+	Creator string   `json:"creator"`
+	Members []string `json:"members"` // 所有成员的uid 包含创建者
+}
+
+// ConversationDeletedData conversation.deleted的内容
+type ConversationDeletedData struct {
+	UID         string `json:"uid"`
+	ChannelID   string `json:"channel_id"`
+	ChannelType uint8  `json:"channel_type"`
+}
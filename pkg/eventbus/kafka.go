@@ -0,0 +1,121 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KafkaOptions Kafka配置
+type KafkaOptions struct {
+	RestURL  string        // Kafka REST Proxy（v2接口）的地址 例如 http://127.0.0.1:8082
+	Topic    string        // topic 所有事件发到同一个topic 按事件的key分区
+	Username string        // Basic认证的用户名
+	Password string        // Basic认证的密码
+	Timeout  time.Duration // 请求超时时间
+}
+
+// kafkaPublisher 通过Kafka REST Proxy发布事件
+type kafkaPublisher struct {
+	opts   KafkaOptions
+	client *http.Client
+	url    string
+}
+
+// NewKafkaPublisher 创建Kafka发布者
+func NewKafkaPublisher(opts KafkaOptions) (Publisher, error) {
+	if opts.RestURL == "" {
+		return nil, errors.New("没有配置Kafka REST Proxy的地址")
+	}
+	if opts.Topic == "" {
+		return nil, errors.New("没有配置Kafka的topic")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second * 5
+	}
+	return &kafkaPublisher{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		url:    strings.TrimSuffix(opts.RestURL, "/") + "/topics/" + url.PathEscape(opts.Topic),
+	}, nil
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key,omitempty"`
+	Value *Event `json:"value"`
+}
+
+type kafkaProduceResp struct {
+	Offsets []struct {
+		Partition int    `json:"partition"`
+		Offset    int64  `json:"offset"`
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+type kafkaErrorResp struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func (k *kafkaPublisher) Publish(ctx context.Context, events []*Event) error {
+	records := make([]kafkaRecord, 0, len(events))
+	for _, e := range events {
+		records = append(records, kafkaRecord{Key: e.Key, Value: e})
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"records": records,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.opts.Username != "" {
+		req.SetBasicAuth(k.opts.Username, k.opts.Password)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp kafkaErrorResp
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Message != "" {
+			return fmt.Errorf("Kafka REST Proxy返回错误[%d]：%s", errResp.ErrorCode, errResp.Message)
+		}
+		return fmt.Errorf("Kafka REST Proxy返回状态码%d", resp.StatusCode)
+	}
+	var produceResp kafkaProduceResp
+	if err = json.Unmarshal(respBody, &produceResp); err != nil {
+		return err
+	}
+	// 部分事件写入失败时整批重试 已写入的事件会重复
+	for i, offset := range produceResp.Offsets {
+		if offset.ErrorCode != nil && i < len(events) {
+			return fmt.Errorf("事件[%s]写入Kafka失败[%d]：%s", events[i].ID, *offset.ErrorCode, offset.Error)
+		}
+	}
+	return nil
+}
+
+func (k *kafkaPublisher) Close() error {
+	k.client.CloseIdleConnections()
+	return nil
+}
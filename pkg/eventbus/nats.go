@@ -0,0 +1,238 @@
+package eventbus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSOptions NATS配置
+type NATSOptions struct {
+	Addrs    []string      // 服务地址 例如 nats://127.0.0.1:4222 使用tls://时开启TLS 多个地址时连接失败换下一个
+	Subject  string        // subject前缀 实际subject为 {Subject}.{事件类型}
+	User     string        // 用户名
+	Password string        // 密码
+	Token    string        // token 与用户名密码二选一
+	Timeout  time.Duration // 连接和发布的超时时间
+}
+
+// natsPublisher 使用NATS的文本协议发布事件
+// 发布后发送PING 收到PONG说明服务端已处理前面的PUB（没有-ERR） 不等待JetStream的确认
+type natsPublisher struct {
+	opts NATSOptions
+
+	mu         sync.Mutex
+	conn       net.Conn
+	reader     *bufio.Reader
+	maxPayload int64
+	next       int // 下次连接的地址
+}
+
+// NewNATSPublisher 创建NATS发布者 第一次发布时连接
+func NewNATSPublisher(opts NATSOptions) (Publisher, error) {
+	if len(opts.Addrs) == 0 {
+		return nil, errors.New("没有配置NATS的地址")
+	}
+	if opts.Subject == "" {
+		return nil, errors.New("没有配置NATS的subject")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second * 5
+	}
+	return &natsPublisher{opts: opts}, nil
+}
+
+type natsInfo struct {
+	MaxPayload  int64 `json:"max_payload"`
+	TLSRequired bool  `json:"tls_required"`
+}
+
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+func (n *natsPublisher) Publish(ctx context.Context, events []*Event) error {
+	var buf bytes.Buffer
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if n.maxPayload > 0 && int64(len(data)) > n.maxPayload {
+			return fmt.Errorf("事件[%s]的大小%d超过了NATS的限制%d", e.ID, len(data), n.maxPayload)
+		}
+		writeNATSPub(&buf, n.opts.Subject+"."+e.Type, data)
+	}
+	buf.WriteString("PING\r\n")
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+	}
+	n.conn.SetDeadline(n.deadline(ctx))
+	if _, err := n.conn.Write(buf.Bytes()); err != nil {
+		n.closeConn()
+		return err
+	}
+	if err := n.waitPong(); err != nil {
+		n.closeConn()
+		return err
+	}
+	return nil
+}
+
+// writeNATSPub PUB <subject> <bytes>\r\n<payload>\r\n
+func writeNATSPub(buf *bytes.Buffer, subject string, data []byte) {
+	fmt.Fprintf(buf, "PUB %s %d\r\n", subject, len(data))
+	buf.Write(data)
+	buf.WriteString("\r\n")
+}
+
+func (n *natsPublisher) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(n.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+func (n *natsPublisher) connect(ctx context.Context) error {
+	var lastErr error
+	for i := 0; i < len(n.opts.Addrs); i++ {
+		addr := n.opts.Addrs[n.next%len(n.opts.Addrs)]
+		n.next++
+		if lastErr = n.dial(ctx, addr); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (n *natsPublisher) dial(ctx context.Context, addr string) error {
+	useTLS := false
+	host := addr
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return err
+		}
+		useTLS = u.Scheme == "tls"
+		host = u.Host
+	}
+	dialer := net.Dialer{Timeout: n.opts.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(n.deadline(ctx))
+	reader := bufio.NewReader(conn)
+	// 连接后服务端先发送INFO
+	line, err := readNATSLine(reader)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("NATS连接返回了错误的数据：%s", line)
+	}
+	var info natsInfo
+	if err = json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		conn.Close()
+		return err
+	}
+	if useTLS || info.TLSRequired {
+		serverName, _, _ := net.SplitHostPort(host)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName})
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+	connect, _ := json.Marshal(natsConnect{
+		Name:    "tsdd-eventbus",
+		Lang:    "go",
+		Version: "1.0.0",
+		User:    n.opts.User,
+		Pass:    n.opts.Password,
+		Token:   n.opts.Token,
+	})
+	if _, err = fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		conn.Close()
+		return err
+	}
+	n.conn = conn
+	n.reader = reader
+	n.maxPayload = info.MaxPayload
+	// 认证失败时返回-ERR
+	if err = n.waitPong(); err != nil {
+		n.closeConn()
+		return err
+	}
+	return nil
+}
+
+// waitPong 读取到PONG为止 服务端的PING需要回复PONG
+func (n *natsPublisher) waitPong() error {
+	for {
+		line, err := readNATSLine(n.reader)
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err = n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS返回错误：%s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK和INFO忽略
+	}
+}
+
+func readNATSLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (n *natsPublisher) closeConn() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	n.conn = nil
+	n.reader = nil
+}
+
+func (n *natsPublisher) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closeConn()
+	return nil
+}
@@ -0,0 +1,233 @@
+package extconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/util"
+	"github.com/spf13/viper"
+)
+
+// ReloadHook 配置重新加载后的处理 例如重新创建推送客户端 返回错误时恢复原来的配置
+type ReloadHook func(c *Config) error
+
+// ReloadResult 重新加载的结果
+type ReloadResult struct {
+	Changed         []string `json:"changed"`          // 有变化的配置项
+	RestartRequired []string `json:"restart_required"` // 有变化但是需要重启才生效的配置项
+}
+
+// 只在启动时读取的配置项 重新加载后需要重启才生效
+var restartRequiredFields = map[string]bool{
+	"Database":          true,
+	"Replica":           true,
+	"MessageExtraShard": true,
+	"JobQueue":          true,
+	"EventBus":          true,
+	"RPCAPI":            true,
+	"Tus":               true,
+	"APIDoc":            true,
+	"Health":            true,
+	"Migration":         true,
+}
+
+var (
+	loader     func() (*viper.Viper, error)
+	hookNames  []string
+	hooks      = map[string]ReloadHook{}
+	reloadLock sync.Mutex
+)
+
+// SetLoader 设置重新加载时读取配置的方法 一般为重新读取启动时的配置文件
+func SetLoader(l func() (*viper.Viper, error)) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	loader = l
+}
+
+// OnReload 注册配置重新加载后的处理 按注册顺序调用 同名的处理只保留最后注册的
+func OnReload(name string, hook ReloadHook) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	if _, ok := hooks[name]; !ok {
+		hookNames = append(hookNames, name)
+	}
+	hooks[name] = hook
+}
+
+// Reload 重新读取配置 校验通过后替换当前配置并调用OnReload注册的处理
+// 读取失败、校验失败或处理出错时保留原来的配置
+func Reload() (*ReloadResult, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	if loader == nil {
+		return nil, errors.New("不支持重新加载配置")
+	}
+	vp, err := loader()
+	if err != nil {
+		return nil, fmt.Errorf("读取配置失败：%w", err)
+	}
+	c := New()
+	c.ConfigureWithViper(vp)
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("配置校验失败：%w", err)
+	}
+	old := Get()
+	set(c)
+	for _, name := range hookNames {
+		if err := hooks[name](c); err != nil {
+			set(old)
+			// 恢复原配置时的处理出错不影响回滚
+			for _, rollbackName := range hookNames {
+				_ = hooks[rollbackName](old)
+			}
+			return nil, fmt.Errorf("%s应用新配置失败，已恢复原配置：%w", name, err)
+		}
+	}
+	return diff(old, c), nil
+}
+
+func set(c *Config) {
+	cfgLock.Lock()
+	cfg = c
+	cfgLock.Unlock()
+}
+
+// diff 顶层配置项的变化
+func diff(old *Config, c *Config) *ReloadResult {
+	result := &ReloadResult{
+		Changed:         make([]string, 0),
+		RestartRequired: make([]string, 0),
+	}
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(c).Elem()
+	for i := 0; i < newValue.NumField(); i++ {
+		field := newValue.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+		result.Changed = append(result.Changed, field.Name)
+		if restartRequiredFields[field.Name] {
+			result.RestartRequired = append(result.RestartRequired, field.Name)
+		}
+	}
+	return result
+}
+
+var smsProviders = map[string]bool{
+	"aliyun":              true,
+	"aliyunInternational": true,
+	"unisms":              true,
+	"twilio":              true,
+	"tencent":             true,
+	"aws":                 true,
+	"mock":                true,
+}
+
+// Validate 校验配置 重新加载时校验不通过的配置不会生效
+func (c *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	// 数据库
+	switch c.Database.Driver {
+	case "mysql":
+	case "postgres":
+		check(c.Database.PostgresAddr != "", "database.postgresAddr不能为空")
+		check(len(c.Replica.Addrs) == 0, "使用PostgreSQL时不支持replica.addrs")
+	default:
+		check(false, "database.driver不支持：%s", c.Database.Driver)
+	}
+	// 短信
+	for i, route := range c.SMSRoutes {
+		check(len(route.Zones) > 0, "smsRoutes[%d].zones不能为空", i)
+		check(smsProviders[route.Provider], "smsRoutes[%d].provider不支持：%s", i, route.Provider)
+	}
+	check(c.OTP.Length > 0 && c.OTP.TTL > 0, "otp.length和otp.ttl必须大于0")
+	check(c.SMSQuota.DailyLimit >= 0 && c.SMSQuota.MonthlyLimit >= 0 && c.SMSQuota.PhoneDailyLimit >= 0 && c.SMSQuota.PhoneMonthlyLimit >= 0, "smsQuota的限制不能小于0")
+	check(c.SMSQuota.AlertPercent >= 0 && c.SMSQuota.AlertPercent <= 100, "smsQuota.alertPercent必须在0到100之间")
+	switch c.SMSQuota.OverBudgetAction {
+	case "reject", "captcha":
+	case "provider":
+		check(smsProviders[c.SMSQuota.OverBudgetProvider], "smsQuota.overBudgetProvider不支持：%s", c.SMSQuota.OverBudgetProvider)
+	default:
+		check(false, "smsQuota.overBudgetAction不支持：%s", c.SMSQuota.OverBudgetAction)
+	}
+	check(c.SMSAbuse.Action == "captcha" || c.SMSAbuse.Action == "reject", "smsAbuse.action不支持：%s", c.SMSAbuse.Action)
+	check(c.SMSAbuse.IPHourlyLimit >= 0 && c.SMSAbuse.IPRangeHourlyLimit >= 0 && c.SMSAbuse.DeviceHourlyLimit >= 0 && c.SMSAbuse.DevicePhoneLimit >= 0 && c.SMSAbuse.PrefixHourlyLimit >= 0, "smsAbuse的限制不能小于0")
+	// 断点续传
+	check(len(c.Tus.Node) <= 64, "tus.node不能超过64个字符：%s", c.Tus.Node)
+	// 机器人
+	check(c.Bot.RateLimit >= 0 && c.Bot.ChatRateLimit >= 0, "bot.rateLimit和bot.chatRateLimit不能小于0")
+	// IM熔断
+	if c.IMBreaker.Enable {
+		check(c.IMBreaker.FailureThreshold > 0 && c.IMBreaker.OpenTimeout > 0, "imBreaker.failureThreshold和imBreaker.openTimeout必须大于0")
+		check(c.IMBreaker.MaxRetry >= 0, "imBreaker.maxRetry不能小于0")
+	}
+	// 推送 证书文件不存在时推送客户端创建失败
+	if c.Push.FCM.PackageName != "" {
+		check(fileExists(c.Push.FCM.JSONPath), "push.fcm.jsonPath文件不存在：%s", c.Push.FCM.JSONPath)
+	}
+	if c.Push.APNs.KeyPath != "" {
+		check(fileExists(c.Push.APNs.KeyPath), "push.apns.keyPath文件不存在：%s", c.Push.APNs.KeyPath)
+	}
+	// 多组织
+	tenantIDs := map[string]bool{}
+	tenantDomains := map[string]bool{}
+	for i, t := range c.Tenant.Tenants {
+		check(len(t.ID) <= 40 && !tenantIDs[t.ID], "tenant.tenants[%d].id重复或超过40个字符：%s", i, t.ID)
+		tenantIDs[t.ID] = true
+		for _, domain := range t.Domains {
+			check(!tenantDomains[strings.ToLower(domain)], "tenant.tenants[%d].domains重复：%s", i, domain)
+			tenantDomains[strings.ToLower(domain)] = true
+		}
+		for j, route := range t.SMSRoutes {
+			check(len(route.Zones) > 0, "tenant.tenants[%d].smsRoutes[%d].zones不能为空", i, j)
+			check(smsProviders[route.Provider], "tenant.tenants[%d].smsRoutes[%d].provider不支持：%s", i, j, route.Provider)
+		}
+		check(t.MaxUsers >= 0 && t.MaxGroups >= 0 && t.MaxStorage >= 0, "tenant.tenants[%d]的上限不能小于0", i)
+	}
+	if c.Tenant.Enable {
+		check(c.Tenant.Header != "", "tenant.header不能为空")
+	}
+	// IP
+	for i, proxy := range c.IPGuard.TrustedProxies {
+		_, ok := util.ParseTrustedProxy(proxy)
+		check(ok, "ipGuard.trustedProxies[%d]不是有效的IP或CIDR：%s", i, proxy)
+	}
+	// 敏感词
+	if c.Sensitive.On {
+		check(c.Sensitive.ReloadInterval > 0, "sensitive.reloadInterval必须大于0")
+	}
+	// 内部gRPC接口
+	if c.RPCAPI.Addr != "" {
+		check(c.RPCAPI.Token != "", "rpcAPI.addr不为空时需要设置rpcAPI.token")
+	}
+	// 性能分析
+	check(c.Profiling.SlowSQL >= 0 && c.Profiling.SlowHTTP >= 0 && c.Profiling.MaxEntries >= 0, "profiling的阈值和maxEntries不能小于0")
+	if c.Profiling.PProf {
+		check(c.Profiling.PProfToken != "", "profiling.pprof开启时需要设置profiling.pprofToken")
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "；"))
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	if strings.TrimSpace(path) == "" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
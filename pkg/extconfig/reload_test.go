@@ -0,0 +1,126 @@
+package extconfig
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func yamlLoader(content *string) func() (*viper.Viper, error) {
+	return func() (*viper.Viper, error) {
+		vp := viper.New()
+		vp.SetConfigType("yaml")
+		if err := vp.ReadConfig(bytes.NewBufferString(*content)); err != nil {
+			return nil, err
+		}
+		return vp, nil
+	}
+}
+
+func TestReload(t *testing.T) {
+	content := "bot:\n  rateLimit: 10\n"
+	SetLoader(yamlLoader(&content))
+	defer SetLoader(nil)
+	defer set(New())
+
+	var hookFail bool
+	var hookCalls []int
+	OnReload("test", func(c *Config) error {
+		hookCalls = append(hookCalls, c.Bot.RateLimit)
+		if hookFail {
+			return errors.New("fail")
+		}
+		return nil
+	})
+	defer OnReload("test", func(c *Config) error { return nil })
+
+	result, err := Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bot"}, result.Changed)
+	assert.Empty(t, result.RestartRequired)
+	assert.Equal(t, 10, Get().Bot.RateLimit)
+
+	// 校验不通过不替换配置 也不调用处理
+	content = "bot:\n  rateLimit: -1\nsmsRoutes:\n  - zones: ['0086']\n    provider: unknown\n"
+	_, err = Reload()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "smsRoutes[0].provider")
+	assert.Contains(t, err.Error(), "bot.rateLimit")
+	assert.Equal(t, 10, Get().Bot.RateLimit)
+	assert.Equal(t, []int{10}, hookCalls)
+
+	// 格式错误
+	content = "bot: [\n"
+	_, err = Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 10, Get().Bot.RateLimit)
+
+	// 处理出错时恢复原配置 并用原配置重新调用处理
+	content = "bot:\n  rateLimit: 20\nreplica:\n  checkInterval: 1s\n"
+	hookFail = true
+	_, err = Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 10, Get().Bot.RateLimit)
+	assert.Equal(t, []int{10, 20, 10}, hookCalls)
+
+	hookFail = false
+	result, err = Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, 20, Get().Bot.RateLimit)
+	assert.Contains(t, result.RestartRequired, "Replica")
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, New().Validate())
+
+	c := New()
+	c.SMSQuota.OverBudgetAction = "provider"
+	c.SMSQuota.OverBudgetProvider = "twilio"
+	assert.NoError(t, c.Validate())
+	c.SMSQuota.OverBudgetProvider = ""
+	assert.Error(t, c.Validate())
+
+	c = New()
+	c.Database.Driver = "postgres"
+	assert.Error(t, c.Validate())
+	c.Database.PostgresAddr = "postgres://127.0.0.1:5432/tsdd"
+	assert.NoError(t, c.Validate())
+	c.Replica.Addrs = []string{"root:demo@tcp(127.0.0.1:3307)/test"}
+	assert.Error(t, c.Validate())
+	c.Database.Driver = "sqlite"
+	assert.Error(t, c.Validate())
+
+	c = New()
+	c.Push.FCM.PackageName = "com.example"
+	c.Push.FCM.JSONPath = "not_exist.json"
+	assert.Error(t, c.Validate())
+
+	c = New()
+	c.Tenant.Tenants = []TenantItemConfig{{ID: "a", Domains: []string{"a.example.com"}}, {ID: "b", Domains: []string{"A.example.com"}}}
+	assert.Error(t, c.Validate())
+	c.Tenant.Tenants[1].Domains = []string{"b.example.com"}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, "b", c.Tenant.Get("b").ID)
+	assert.Nil(t, c.Tenant.Get("c"))
+
+	c = New()
+	c.IPGuard.TrustedProxies = []string{"10.0.0.0/8", "127.0.0.1", "proxy.local"}
+	assert.Error(t, c.Validate())
+	c.IPGuard.TrustedProxies = c.IPGuard.TrustedProxies[:2]
+	assert.NoError(t, c.Validate())
+
+	c = New()
+	c.RPCAPI.Addr = "127.0.0.1:6980"
+	assert.Error(t, c.Validate())
+	c.RPCAPI.Token = "secret"
+	assert.NoError(t, c.Validate())
+
+	c = New()
+	c.Profiling.PProf = true
+	assert.Error(t, c.Validate())
+	c.Profiling.PProfToken = "secret"
+	assert.NoError(t, c.Validate())
+}
@@ -0,0 +1,178 @@
+// Package health 依赖服务的健康检查
+//
+// 并发执行注册的检查并记录每项的耗时 单项超过超时时间视为不可用
+// 检查结果缓存一小段时间 避免探针和负载均衡频繁请求时把压力传到数据库等依赖服务
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp       = "up"       // 可用
+	StatusDown     = "down"     // 必需的依赖不可用
+	StatusDegraded = "degraded" // 只有非必需的依赖不可用
+	StatusDraining = "draining" // 正在退出 不再接收新请求
+)
+
+// Check 检查依赖是否可用 需要在ctx取消后尽快返回
+type Check func(ctx context.Context) error
+
+// Result 单项检查的结果
+type Result struct {
+	Status    string `json:"status"`
+	Required  bool   `json:"required"`        // 是否必需 必需的依赖不可用时服务不可用
+	LatencyMs int64  `json:"latency_ms"`      // 耗时（毫秒）
+	Error     string `json:"error,omitempty"` // 不可用的原因
+}
+
+// Report 所有检查的结果
+type Report struct {
+	Status    string             `json:"status"`
+	CheckedAt int64              `json:"checked_at"` // 检查时间（毫秒时间戳）
+	Checks    map[string]*Result `json:"checks"`
+}
+
+// Ready 必需的依赖是否都可用
+func (r *Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+type namedCheck struct {
+	name     string
+	required bool
+	check    Check
+}
+
+// Checker 健康检查
+type Checker struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	checks []namedCheck
+
+	lock      sync.Mutex
+	last      *Report
+	lastAt    time.Time
+	nowFunc   func() time.Time
+	checkLock sync.Mutex // 同一时间只执行一次检查 并发的请求共用结果
+}
+
+// New timeout为单项检查的超时时间 cacheTTL为检查结果的缓存时间 为0则每次都重新检查
+func New(timeout time.Duration, cacheTTL time.Duration) *Checker {
+	return &Checker{
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+		nowFunc:  time.Now,
+	}
+}
+
+// Register 注册检查 需要在Run之前注册
+func (c *Checker) Register(name string, required bool, check Check) {
+	c.checks = append(c.checks, namedCheck{
+		name:     name,
+		required: required,
+		check:    check,
+	})
+}
+
+// Run 执行所有检查 缓存时间内返回上一次的结果
+func (c *Checker) Run(ctx context.Context) *Report {
+	if report := c.cached(); report != nil {
+		return report
+	}
+	c.checkLock.Lock()
+	defer c.checkLock.Unlock()
+	// 等待锁的期间其他请求可能已经检查过
+	if report := c.cached(); report != nil {
+		return report
+	}
+	report := c.run(ctx)
+	c.lock.Lock()
+	c.last = report
+	c.lastAt = c.nowFunc()
+	c.lock.Unlock()
+	return report
+}
+
+func (c *Checker) cached() *Report {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.last == nil || c.cacheTTL <= 0 || c.nowFunc().Sub(c.lastAt) >= c.cacheTTL {
+		return nil
+	}
+	return c.last
+}
+
+func (c *Checker) run(ctx context.Context) *Report {
+	report := &Report{
+		Status:    StatusUp,
+		CheckedAt: c.nowFunc().UnixMilli(),
+		Checks:    make(map[string]*Result, len(c.checks)),
+	}
+	results := make([]*Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check namedCheck) {
+			defer wg.Done()
+			results[i] = c.runCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+	for i, check := range c.checks {
+		result := results[i]
+		report.Checks[check.name] = result
+		if result.Status == StatusUp {
+			continue
+		}
+		if check.required {
+			report.Status = StatusDown
+		} else if report.Status == StatusUp {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// runCheck 检查不响应ctx时也按超时时间返回
+func (c *Checker) runCheck(ctx context.Context, check namedCheck) *Result {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	errChan := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- errors.New("检查异常")
+			}
+		}()
+		errChan <- check.check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := &Result{
+		Status:    StatusUp,
+		Required:  check.required,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "检查超时"
+		} else {
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	checker := New(time.Millisecond*50, 0)
+	checker.Register("db", true, func(ctx context.Context) error { return nil })
+	checker.Register("storage", false, func(ctx context.Context) error { return errors.New("连接失败") })
+	report := checker.Run(context.Background())
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.True(t, report.Ready())
+	assert.Equal(t, StatusUp, report.Checks["db"].Status)
+	assert.Equal(t, StatusDown, report.Checks["storage"].Status)
+	assert.Equal(t, "连接失败", report.Checks["storage"].Error)
+
+	// 不响应ctx的检查按超时返回
+	checker.Register("im", true, func(ctx context.Context) error {
+		time.Sleep(time.Millisecond * 200)
+		return nil
+	})
+	start := time.Now()
+	report = checker.Run(context.Background())
+	assert.Less(t, time.Since(start), time.Millisecond*150)
+	assert.Equal(t, StatusDown, report.Status)
+	assert.False(t, report.Ready())
+	assert.Equal(t, "检查超时", report.Checks["im"].Error)
+	assert.True(t, report.Checks["im"].Required)
+}
+
+func TestRunCache(t *testing.T) {
+	now := time.Now()
+	count := 0
+	checker := New(time.Second, time.Second)
+	checker.nowFunc = func() time.Time { return now }
+	checker.Register("db", true, func(ctx context.Context) error {
+		count++
+		return nil
+	})
+	checker.Run(context.Background())
+	checker.Run(context.Background())
+	assert.Equal(t, 1, count)
+
+	now = now.Add(time.Second)
+	checker.Run(context.Background())
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,112 @@
+// Package httpcache 读多写少接口的HTTP缓存
+//
+// 返回数据时带上ETag（返回内容的摘要） 客户端下次请求时带上If-None-Match 数据没有变化时返回304不再返回内容
+// 返回的数据只和某个资源有关时 可以在修改资源的地方调用Touch记录修改时间 返回时带上Last-Modified 支持If-Modified-Since
+package httpcache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+)
+
+const modifiedKeyPrefix = "httpcache:modified:"
+
+// 服务启动时间 配置文件的修改需要重启才生效 修改时间不早于启动时间
+var startedAt = time.Now().Truncate(time.Second)
+
+// Store 保存资源的修改时间 *redis.Conn实现了该接口
+type Store interface {
+	Set(key string, value interface{}) error
+	GetString(key string) (string, error)
+}
+
+// Touch 资源被修改 之后的请求If-Modified-Since早于当前时间时重新返回数据
+func Touch(store Store, key string) error {
+	return store.Set(modifiedKeyPrefix+key, strconv.FormatInt(time.Now().Unix(), 10))
+}
+
+// Modified 资源的修改时间 没有调用过Touch时为零值
+func Modified(store Store, key string) time.Time {
+	value, err := store.GetString(modifiedKeyPrefix + key)
+	if err != nil || value == "" {
+		return time.Time{}
+	}
+	unix, _ := strconv.ParseInt(value, 10, 64)
+	if unix <= 0 {
+		return time.Time{}
+	}
+	modified := time.Unix(unix, 0)
+	if modified.Before(startedAt) {
+		return startedAt
+	}
+	return modified
+}
+
+// Response 返回JSON数据并带上ETag 请求的If-None-Match和ETag一致时返回304
+func Response(c *wkhttp.Context, data interface{}) {
+	ResponseWithModified(c, data, time.Time{})
+}
+
+// ResponseWithModified 返回JSON数据并带上ETag和Last-Modified modified为零值时不返回Last-Modified
+func ResponseWithModified(c *wkhttp.Context, data interface{}, modified time.Time) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		c.ResponseErrorf("数据编码失败！", err)
+		return
+	}
+	etag := ETag(body)
+	c.Header("ETag", etag)
+	// 允许客户端缓存 但是每次使用前都需要校验
+	c.Header("Cache-Control", "private, no-cache")
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if NotModified(c.Request, etag, modified) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// ETag 返回内容的ETag
+func ETag(body []byte) string {
+	sum := sha1.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// NotModified 客户端缓存的数据是否还是最新的 带了If-None-Match时只比较ETag
+func NotModified(r *http.Request, etag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return matchETag(ifNoneMatch, etag)
+	}
+	if modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// matchETag If-None-Match使用弱比较 W/"xxx"和"xxx"视为相同
+func matchETag(ifNoneMatch string, etag string) bool {
+	for _, value := range strings.Split(ifNoneMatch, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.TrimPrefix(value, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
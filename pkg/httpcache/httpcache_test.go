@@ -0,0 +1,89 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryStore map[string]string
+
+func (m memoryStore) Set(key string, value interface{}) error {
+	m[key] = value.(string)
+	return nil
+}
+
+func (m memoryStore) GetString(key string) (string, error) {
+	return m[key], nil
+}
+
+func TestResponse(t *testing.T) {
+	r := wkhttp.New()
+	r.GET("/users/:uid", func(c *wkhttp.Context) {
+		Response(c, map[string]string{"uid": c.Param("uid")})
+	})
+	request := func(path string, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			req.Header.Set("If-None-Match", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/users/u1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"uid":"u1"}`, w.Body.String())
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, ETag([]byte(`{"uid":"u1"}`)), etag)
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+
+	w = request("/users/u1", "W/"+etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// 内容不同ETag也不同
+	w = request("/users/u2", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Unix(1700000000, 0)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.False(t, NotModified(req, `"a"`, modified))
+
+	req.Header.Set("If-Modified-Since", modified.UTC().Format(http.TimeFormat))
+	assert.True(t, NotModified(req, `"a"`, modified))
+	assert.False(t, NotModified(req, `"a"`, modified.Add(time.Second)))
+	assert.False(t, NotModified(req, `"a"`, time.Time{}))
+
+	// 带了If-None-Match时忽略If-Modified-Since
+	req.Header.Set("If-None-Match", `"b", "c"`)
+	assert.False(t, NotModified(req, `"a"`, modified))
+	assert.True(t, NotModified(req, `"c"`, modified))
+	req.Header.Set("If-None-Match", "*")
+	assert.True(t, NotModified(req, `"a"`, modified))
+
+	req.Method = http.MethodPost
+	assert.False(t, NotModified(req, `"a"`, modified))
+}
+
+func TestModified(t *testing.T) {
+	store := memoryStore{}
+	assert.True(t, Modified(store, "appconfig").IsZero())
+
+	assert.NoError(t, Touch(store, "appconfig"))
+	modified := Modified(store, "appconfig")
+	assert.False(t, modified.IsZero())
+	assert.False(t, modified.Before(startedAt))
+
+	// 修改时间早于启动时间时为启动时间
+	store[modifiedKeyPrefix+"appconfig"] = "1000"
+	assert.Equal(t, startedAt, Modified(store, "appconfig"))
+}
@@ -0,0 +1,101 @@
+package imbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State 熔断器状态
+type State int
+
+const (
+	StateClosed   State = iota // 正常请求
+	StateOpen                  // 熔断中 请求直接失败
+	StateHalfOpen              // 熔断时间已过 放行一个请求探测是否恢复
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker 连续失败次数达到阈值后熔断 熔断时间过后放行一个请求 成功则恢复 失败则继续熔断
+type Breaker struct {
+	lock     sync.Mutex
+	state    State
+	failures int       // 连续失败的次数
+	openedAt time.Time // 熔断的时间
+	probing  bool      // 半开状态下是否已放行了探测请求
+
+	OnStateChange func(from State, to State) // 状态变化时调用 在锁内调用 不能再调用Breaker的方法
+}
+
+// Allow 是否可以发送请求 openTimeout为熔断的时长
+func (b *Breaker) Allow(now time.Time, openTimeout time.Duration) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) < openTimeout {
+			return false
+		}
+		b.setState(StateHalfOpen)
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Record 记录请求的结果 threshold为熔断需要的连续失败次数
+func (b *Breaker) Record(success bool, now time.Time, threshold int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if success {
+		b.failures = 0
+		b.probing = false
+		if b.state != StateClosed {
+			b.setState(StateClosed)
+		}
+		return
+	}
+	b.failures++
+	switch b.state {
+	case StateHalfOpen:
+		b.probing = false
+		b.openedAt = now
+		b.setState(StateOpen)
+	case StateClosed:
+		if threshold > 0 && b.failures >= threshold {
+			b.openedAt = now
+			b.setState(StateOpen)
+		}
+	}
+}
+
+// State 当前状态
+func (b *Breaker) State() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+func (b *Breaker) setState(state State) {
+	from := b.state
+	b.state = state
+	if b.OnStateChange != nil && from != state {
+		b.OnStateChange(from, state)
+	}
+}
@@ -0,0 +1,376 @@
+// Package imbreaker 调用IM接口的熔断、超时、重试和失败后排队重发
+//
+// IM接口通过rest.DefaultClient调用 Install替换它的Transport 只处理apiURL下的请求：
+//
+//   - 每个请求有超时时间 IM响应慢时不会一直占用接口的goroutine
+//   - 连续失败（连接失败、超时或5xx）达到阈值后熔断 熔断期间请求直接失败 熔断时间过后放行一个请求探测是否恢复
+//   - 幂等的接口（查询、设置类）连接失败或返回502/503/504时重试
+//   - 不需要返回结果的写请求（持久化的CMD消息、删除最近会话）失败或熔断时放入任务队列稍后重发 调用方视为成功
+package imbreaker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/jobqueue"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/sendgrid/rest"
+	"go.uber.org/zap"
+)
+
+const (
+	jobQueueIM = "im"
+	// JobTypeRequest 重发IM请求
+	JobTypeRequest = "im.request"
+)
+
+// ErrOpen IM接口熔断中
+var ErrOpen = errors.New("IM服务不可用，已熔断！")
+
+// 连接失败或返回这些状态码时 幂等的接口可以重试
+var retryStatus = map[int]bool{
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// idempotentPaths 重复调用结果相同的POST接口 GET接口都视为幂等
+var idempotentPaths = map[string]bool{
+	"/user/token":                true,
+	"/user/onlinestatus":         true,
+	"/channel":                   true,
+	"/channel/info":              true,
+	"/channel/delete":            true,
+	"/channel/subscriber_add":    true,
+	"/channel/subscriber_remove": true,
+	"/channel/blacklist_add":     true,
+	"/channel/blacklist_set":     true,
+	"/channel/blacklist_remove":  true,
+	"/channel/whitelist_add":     true,
+	"/channel/whitelist_set":     true,
+	"/channel/whitelist_remove":  true,
+	"/channel/max_message_seq":   true,
+	"/channel/messagesync":       true,
+	"/conversations":             true,
+	"/conversation/sync":         true,
+	"/conversations/delete":      true,
+	"/conversations/setUnread":   true,
+	"/message/sync":              true,
+	"/message/syncack":           true,
+	"/message/search":            true,
+	"/messages":                  true,
+}
+
+type replayKey struct{}
+
+// Transport 调用IM接口的Transport
+type Transport struct {
+	log.Log
+	apiURL  string
+	next    http.RoundTripper
+	breaker *Breaker
+	sleep   func(ctx context.Context, d time.Duration) error
+	enqueue func(req *requestJob) error
+}
+
+var installOnce sync.Once
+
+// Install 替换rest.DefaultClient的Transport 并注册重发请求的任务 需要在任务队列Start之前调用
+func Install(apiURL string) {
+	apiURL = strings.TrimSuffix(strings.TrimSpace(apiURL), "/")
+	if apiURL == "" {
+		return
+	}
+	installOnce.Do(func() {
+		httpClient := rest.DefaultClient.HTTPClient
+		t := New(apiURL, httpClient.Transport)
+		httpClient.Transport = t
+		jobqueue.Register(jobQueueIM, JobTypeRequest, func(ctx context.Context, job *jobqueue.Job) error {
+			// 通过rest.DefaultClient重发 与其他IM请求一样统计指标和熔断
+			return t.handleRequestJob(ctx, job, httpClient)
+		})
+	})
+}
+
+// New 创建Transport next为nil时使用http.DefaultTransport
+func New(apiURL string, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &Transport{
+		Log:    log.NewTLog("IMBreaker"),
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		next:   next,
+		sleep:  sleep,
+		enqueue: func(req *requestJob) error {
+			_, err := jobqueue.Enqueue(JobTypeRequest, req)
+			return err
+		},
+	}
+	t.breaker = &Breaker{
+		OnStateChange: func(from State, to State) {
+			if to == StateOpen {
+				t.Warn("IM接口连续失败，已熔断", zap.String("from", from.String()))
+			} else {
+				t.Info("IM接口熔断状态变化", zap.String("from", from.String()), zap.String("to", to.String()))
+			}
+		},
+	}
+	return t
+}
+
+// State 熔断器状态
+func (t *Transport) State() State {
+	return t.breaker.State()
+}
+
+// RoundTrip 实现http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, ok := t.imPath(req)
+	cfg := extconfig.Get().IMBreaker
+	if !ok || !cfg.Enable {
+		return t.next.RoundTrip(req)
+	}
+	attempts := 1
+	if req.Method == http.MethodGet || idempotentPaths[path] {
+		attempts += cfg.MaxRetry
+	}
+	var (
+		resp *http.Response
+		err  error
+	)
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			if err = t.sleep(req.Context(), cfg.RetryBackoff*time.Duration(i)); err != nil {
+				break
+			}
+		}
+		if !t.breaker.Allow(time.Now(), cfg.OpenTimeout) {
+			resp, err = nil, ErrOpen
+			break
+		}
+		resp, err = t.send(req, i, cfg.Timeout)
+		t.breaker.Record(err == nil && resp.StatusCode < http.StatusInternalServerError, time.Now(), cfg.FailureThreshold)
+		if !retryable(req, resp, err) || i == attempts-1 {
+			break
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	if err == nil && resp.StatusCode < http.StatusInternalServerError {
+		return resp, nil
+	}
+	if cfg.Queue && req.Context().Value(replayKey{}) == nil {
+		if queuedResp, ok := t.queue(req, path); ok {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			t.Warn("IM请求失败，已放入任务队列稍后重发", zap.String("path", path), zap.Error(err))
+			return queuedResp, nil
+		}
+	}
+	return resp, err
+}
+
+// send 第attempt次发送请求 重试时重新读取请求内容
+func (t *Transport) send(req *http.Request, attempt int, timeout time.Duration) (*http.Response, error) {
+	if attempt > 0 {
+		if req.Body != nil && req.GetBody == nil {
+			return nil, errors.New("请求内容不能重复读取")
+		}
+		req = req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+	if timeout <= 0 {
+		return t.next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// 读取完响应内容后再取消
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retryable 连接失败或返回502/503/504时重试 超时不重试 避免加重IM的负载
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.DeadlineExceeded)
+	}
+	return retryStatus[resp.StatusCode]
+}
+
+// imPath 请求IM的接口路径 不是请求IM时返回false
+func (t *Transport) imPath(req *http.Request) (string, bool) {
+	if req.URL == nil {
+		return "", false
+	}
+	reqURL := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+	if !strings.HasPrefix(reqURL, t.apiURL) {
+		return "", false
+	}
+	path := strings.TrimPrefix(reqURL, t.apiURL)
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", false
+	}
+	return path, true
+}
+
+// requestJob 重发的IM请求
+type requestJob struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// queue 不需要返回结果的写请求放入任务队列 返回给调用方的成功响应
+func (t *Transport) queue(req *http.Request, path string) (*http.Response, bool) {
+	if req.Method != http.MethodPost || req.GetBody == nil {
+		return nil, false
+	}
+	bodyReader, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	body, err := io.ReadAll(bodyReader)
+	bodyReader.Close()
+	if err != nil || !queueable(path, body) {
+		return nil, false
+	}
+	err = t.enqueue(&requestJob{
+		Method:      req.Method,
+		Path:        path,
+		ContentType: req.Header.Get("Content-Type"),
+		Body:        body,
+	})
+	if err != nil {
+		t.Error("IM请求放入任务队列失败！", zap.String("path", path), zap.Error(err))
+		return nil, false
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader("{}")),
+		ContentLength: 2,
+		Request:       req,
+	}, true
+}
+
+// queueable 调用方不需要返回结果 晚一些执行也不影响结果的请求
+// 持久化的CMD消息（客户端收到后同步数据）和删除最近会话
+func queueable(path string, body []byte) bool {
+	switch path {
+	case "/conversations/delete":
+		return true
+	case "/message/send", "/message/sendbatch":
+		var msg struct {
+			Header struct {
+				NoPersist int `json:"no_persist"`
+				SyncOnce  int `json:"sync_once"`
+			} `json:"header"`
+			Payload []byte `json:"payload"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return false
+		}
+		// 不持久化的CMD（例如正在输入）过时后没有意义 不重发
+		if msg.Header.NoPersist == 1 || msg.Header.SyncOnce != 1 {
+			return false
+		}
+		var content struct {
+			Type int `json:"type"`
+		}
+		if err := json.Unmarshal(msg.Payload, &content); err != nil {
+			return false
+		}
+		return content.Type == int(common.CMD)
+	}
+	return false
+}
+
+// handleRequestJob 重发IM请求 连接失败或5xx时按任务队列的配置重试
+func (t *Transport) handleRequestJob(ctx context.Context, job *jobqueue.Job, client *http.Client) error {
+	var reqJob requestJob
+	if err := job.Bind(&reqJob); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, replayKey{}, true), reqJob.Method, t.apiURL+reqJob.Path, bytes.NewReader(reqJob.Body))
+	if err != nil {
+		return err
+	}
+	if reqJob.ContentType != "" {
+		req.Header.Set("Content-Type", reqJob.ContentType)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("IM返回状态码：%d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// 请求内容有误 重试也不会成功
+		t.Warn("重发IM请求失败！", zap.String("path", reqJob.Path), zap.Int("status", resp.StatusCode), zap.String("body", string(respBody)))
+	}
+	return nil
+}
+
+// cancelBody 关闭响应时取消请求的ctx
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,146 @@
+package imbreaker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	b := &Breaker{}
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		assert.True(t, b.Allow(now, time.Second))
+		b.Record(false, now, 3)
+	}
+	assert.Equal(t, StateClosed, b.State())
+	b.Record(false, now, 3)
+	assert.Equal(t, StateOpen, b.State())
+	assert.False(t, b.Allow(now.Add(time.Millisecond*500), time.Second))
+
+	// 熔断时间过后只放行一个探测请求
+	assert.True(t, b.Allow(now.Add(time.Second), time.Second))
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.False(t, b.Allow(now.Add(time.Second), time.Second))
+	b.Record(false, now.Add(time.Second), 3)
+	assert.Equal(t, StateOpen, b.State())
+
+	assert.True(t, b.Allow(now.Add(time.Second*2), time.Second))
+	b.Record(true, now.Add(time.Second*2), 3)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func newTestTransport(apiURL string) *Transport {
+	t := New(apiURL, nil)
+	t.sleep = func(ctx context.Context, d time.Duration) error {
+		return nil
+	}
+	return t
+}
+
+func TestRoundTripRetry(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"uid":"u1"}`, string(body))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: newTestTransport(srv.URL)}
+
+	// 幂等的接口重试
+	resp, err := client.Post(srv.URL+"/user/token", "application/json", strings.NewReader(`{"uid":"u1"}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// 非幂等的接口不重试
+	atomic.StoreInt32(&calls, 0)
+	resp, err = client.Post(srv.URL+"/message/send", "application/json", strings.NewReader(`{"uid":"u1"}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRoundTripOpen(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	tr := newTestTransport(srv.URL)
+	var queued []*requestJob
+	tr.enqueue = func(req *requestJob) error {
+		queued = append(queued, req)
+		return nil
+	}
+	client := &http.Client{Transport: tr}
+	for i := 0; i < 5; i++ {
+		resp, err := client.Post(srv.URL+"/message/send", "application/json", strings.NewReader(`{}`))
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, StateOpen, tr.State())
+
+	// 熔断中不再请求IM
+	_, err := client.Post(srv.URL+"/message/send", "application/json", strings.NewReader(`{}`))
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+
+	// 熔断中删除最近会话放入队列 调用方视为成功
+	resp, err := client.Post(srv.URL+"/conversations/delete", "application/json", strings.NewReader(`{"uid":"u1"}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, queued, 1)
+	assert.Equal(t, "/conversations/delete", queued[0].Path)
+	assert.Equal(t, `{"uid":"u1"}`, string(queued[0].Body))
+
+	// 不是IM的请求不经过熔断
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer other.Close()
+	resp, err = client.Get(other.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestQueueable(t *testing.T) {
+	message := func(noPersist int, syncOnce int, contentType int) []byte {
+		payload, _ := json.Marshal(map[string]interface{}{"type": contentType})
+		body, _ := json.Marshal(map[string]interface{}{
+			"header": map[string]interface{}{
+				"no_persist": noPersist,
+				"red_dot":    0,
+				"sync_once":  syncOnce,
+			},
+			"payload": payload,
+		})
+		return body
+	}
+	assert.True(t, queueable("/message/send", message(0, 1, 99)))
+	assert.True(t, queueable("/message/sendbatch", message(0, 1, 99)))
+	// 不持久化的CMD 例如正在输入
+	assert.False(t, queueable("/message/send", message(1, 0, 99)))
+	// 普通消息需要返回结果
+	assert.False(t, queueable("/message/send", message(0, 0, 1)))
+	assert.True(t, queueable("/conversations/delete", []byte(`{}`)))
+	assert.False(t, queueable("/channel/subscriber_add", []byte(`{}`)))
+}
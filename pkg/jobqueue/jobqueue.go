@@ -0,0 +1,622 @@
+// Package jobqueue 基于Redis的后台任务队列
+// 任务按类型注册处理函数 每种类型属于一个队列 每个队列单独配置并发数
+// 失败的任务按退避间隔重试 重试次数用完后进入失败列表 可以通过管理接口查看、重试或删除
+// 执行中的任务有租约 实例退出或卡住导致租约到期后任务会被当作一次失败重试 处理函数需要是幂等的
+//
+// Redis中的key：
+//
+//	{prefix}queues            所有的队列 set
+//	{prefix}job:{id}          任务 hash
+//	{prefix}{queue}:pending   等待执行 list
+//	{prefix}{queue}:scheduled 延迟执行和等待重试 zset score为执行时间（毫秒）
+//	{prefix}{queue}:active    执行中 zset score为租约到期时间（毫秒）
+//	{prefix}{queue}:failed    重试次数用完 zset score为失败时间（毫秒）
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	rd "github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// 任务状态
+const (
+	StatePending   = "pending"
+	StateScheduled = "scheduled"
+	StateActive    = "active"
+	StateFailed    = "failed"
+)
+
+// maintainBatch 每次转移到期任务的最大数量
+const maintainBatch = 100
+
+var (
+	// ErrNotConfigured 没有配置任务队列
+	ErrNotConfigured = errors.New("任务队列未配置")
+	// ErrJobExists 相同id的任务已存在
+	ErrJobExists = errors.New("任务已存在")
+	// ErrJobNotFound 任务不存在或不在失败列表中
+	ErrJobNotFound = errors.New("任务不存在")
+	// errLeaseExpired 执行超时或实例退出
+	errLeaseExpired = errors.New("执行超时或执行的实例已退出")
+)
+
+// Handler 任务处理函数 返回错误时按配置重试
+type Handler func(ctx context.Context, job *Job) error
+
+// Job 任务
+type Job struct {
+	ID        string
+	Queue     string
+	Type      string
+	Payload   []byte
+	State     string
+	Retried   int    // 已重试的次数
+	MaxRetry  int    // 最多重试的次数
+	LastError string // 最后一次失败的原因
+	CreatedAt int64  // 创建时间（秒）
+	ProcessAt int64  // 执行时间（秒）
+	FailedAt  int64  // 进入失败列表的时间（秒）
+}
+
+// Bind 解析任务的参数
+func (j *Job) Bind(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Options 队列配置
+type Options struct {
+	Prefix             string         // Redis中key的前缀
+	Concurrency        map[string]int // 每个队列的并发数
+	DefaultConcurrency int            // 没有单独配置的队列的并发数
+	PollInterval       time.Duration  // 没有任务时多久检查一次 也是转移到期任务的间隔
+	LeaseTimeout       time.Duration  // 任务执行的超时时间 超时后重试
+	MaxRetry           int            // 默认最多重试的次数
+	RetryBackoff       time.Duration  // 第一次重试的间隔 之后每次翻倍
+	MaxBackoff         time.Duration  // 最大重试间隔
+	FailedRetention    time.Duration  // 失败的任务保留多久
+}
+
+// EnqueueOption 添加任务的选项
+type EnqueueOption func(j *Job)
+
+// ProcessIn 延迟执行
+func ProcessIn(d time.Duration) EnqueueOption {
+	return func(j *Job) {
+		j.ProcessAt = time.Now().Add(d).Unix()
+	}
+}
+
+// ProcessAt 在指定时间执行
+func ProcessAt(t time.Time) EnqueueOption {
+	return func(j *Job) {
+		j.ProcessAt = t.Unix()
+	}
+}
+
+// JobID 指定任务id 相同id的任务还没有完成时不重复添加 返回ErrJobExists
+func JobID(id string) EnqueueOption {
+	return func(j *Job) {
+		j.ID = id
+	}
+}
+
+// MaxRetry 最多重试的次数 0为不重试
+func MaxRetry(n int) EnqueueOption {
+	return func(j *Job) {
+		j.MaxRetry = n
+	}
+}
+
+type registration struct {
+	queue   string
+	handler Handler
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]registration{} // 任务类型 -> 处理函数
+)
+
+// Register 注册任务类型和处理函数 需要在Start之前调用 一般在模块初始化时注册
+func Register(queue string, jobType string, handler Handler) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[jobType] = registration{queue: queue, handler: handler}
+}
+
+func lookup(jobType string) (registration, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	r, ok := registry[jobType]
+	return r, ok
+}
+
+// registeredQueues 注册过任务的队列
+func registeredQueues() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	exists := map[string]bool{}
+	queues := make([]string, 0)
+	for _, r := range registry {
+		if !exists[r.queue] {
+			exists[r.queue] = true
+			queues = append(queues, r.queue)
+		}
+	}
+	return queues
+}
+
+// Queue 任务队列
+type Queue struct {
+	log.Log
+	client *rd.Client
+	opts   Options
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+var current atomic.Pointer[Queue]
+
+// New 创建任务队列 调用Start后开始执行任务
+func New(client *rd.Client, opts Options) *Queue {
+	if opts.DefaultConcurrency <= 0 {
+		opts.DefaultConcurrency = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.LeaseTimeout <= 0 {
+		opts.LeaseTimeout = time.Minute * 30
+	}
+	return &Queue{
+		Log:    log.NewTLog("JobQueue"),
+		client: client,
+		opts:   opts,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Configure 设置全局使用的任务队列
+func Configure(q *Queue) {
+	current.Store(q)
+}
+
+// Get 全局使用的任务队列 没有配置时为nil
+func Get() *Queue {
+	return current.Load()
+}
+
+// Enqueue 使用全局的任务队列添加任务 payload会序列化为JSON
+func Enqueue(jobType string, payload interface{}, opts ...EnqueueOption) (*Job, error) {
+	q := current.Load()
+	if q == nil {
+		return nil, ErrNotConfigured
+	}
+	return q.Enqueue(jobType, payload, opts...)
+}
+
+// Enqueue 添加任务
+func (q *Queue) Enqueue(jobType string, payload interface{}, opts ...EnqueueOption) (*Job, error) {
+	r, ok := lookup(jobType)
+	if !ok {
+		return nil, fmt.Errorf("任务类型[%s]未注册", jobType)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	job := &Job{
+		ID:        util.GenerUUID(),
+		Queue:     r.queue,
+		Type:      jobType,
+		Payload:   data,
+		MaxRetry:  q.opts.MaxRetry,
+		CreatedAt: now,
+		ProcessAt: now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	job.State = StatePending
+	score := "0"
+	target := q.key(job.Queue, StatePending)
+	if job.ProcessAt > now {
+		job.State = StateScheduled
+		score = strconv.FormatInt(job.ProcessAt*1000, 10)
+		target = q.key(job.Queue, StateScheduled)
+	}
+	args := []interface{}{score}
+	for field, value := range encodeJob(job) {
+		args = append(args, field, value)
+	}
+	added, err := enqueueScript.Run(q.client, []string{q.jobKey(job.ID), target, q.prefix() + "queues"}, append(args, job.Queue)...).Int()
+	if err != nil {
+		return nil, err
+	}
+	if added != 1 {
+		return job, ErrJobExists
+	}
+	return job, nil
+}
+
+// Start 为每个注册过任务的队列启动worker
+func (q *Queue) Start() {
+	for _, queue := range registeredQueues() {
+		concurrency := q.concurrency(queue)
+		for i := 0; i < concurrency; i++ {
+			q.wg.Add(1)
+			go q.work(queue)
+		}
+		q.wg.Add(1)
+		go q.maintain(queue)
+		q.Info("启动任务队列", zap.String("queue", queue), zap.Int("concurrency", concurrency))
+	}
+}
+
+// Stop 停止获取新任务 等待正在执行的任务结束
+func (q *Queue) Stop() {
+	close(q.stop)
+	q.wg.Wait()
+}
+
+func (q *Queue) concurrency(queue string) int {
+	if c, ok := q.opts.Concurrency[queue]; ok && c > 0 {
+		return c
+	}
+	return q.opts.DefaultConcurrency
+}
+
+func (q *Queue) work(queue string) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+		job, err := q.dequeue(queue)
+		if err != nil {
+			q.Warn("获取任务失败！", zap.String("queue", queue), zap.Error(err))
+		}
+		if job == nil {
+			select {
+			case <-q.stop:
+				return
+			case <-time.After(q.opts.PollInterval):
+			}
+			continue
+		}
+		q.process(job)
+	}
+}
+
+func (q *Queue) process(job *Job) {
+	r, ok := lookup(job.Type)
+	if !ok {
+		q.failed(job, fmt.Errorf("任务类型[%s]未注册", job.Type), false)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), q.opts.LeaseTimeout)
+	defer cancel()
+	err := safeRun(ctx, r.handler, job)
+	if err == nil {
+		if err = q.complete(job); err != nil {
+			q.Warn("删除已完成的任务失败！", zap.String("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+	q.Warn("任务执行失败！", zap.String("type", job.Type), zap.String("id", job.ID), zap.Int("retried", job.Retried), zap.Error(err))
+	q.failed(job, err, true)
+}
+
+// safeRun 执行处理函数 panic时作为失败处理
+func safeRun(ctx context.Context, handler Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// failed 还有重试次数时按退避间隔重试 否则进入失败列表
+func (q *Queue) failed(job *Job, err error, retry bool) {
+	if retry && job.Retried < job.MaxRetry {
+		processAt := time.Now().Add(Backoff(job.Retried+1, q.opts.RetryBackoff, q.opts.MaxBackoff))
+		_, moveErr := q.move(job, StateScheduled, processAt, err.Error(), job.Retried+1)
+		if moveErr != nil {
+			q.Error("任务设置为重试失败！", zap.String("id", job.ID), zap.Error(moveErr))
+		}
+		return
+	}
+	_, moveErr := q.move(job, StateFailed, time.Now(), err.Error(), job.Retried)
+	if moveErr != nil {
+		q.Error("任务设置为失败失败！", zap.String("id", job.ID), zap.Error(moveErr))
+	}
+}
+
+// Backoff 第retry次重试的间隔 每次翻倍 不超过maxBackoff
+func Backoff(retry int, backoff time.Duration, maxBackoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		backoff = time.Second * 10
+	}
+	d := backoff
+	for i := 1; i < retry; i++ {
+		d *= 2
+		if maxBackoff > 0 && d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	if maxBackoff > 0 && d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// maintain 把到期的延迟任务转为等待执行 租约到期的任务重试 清理过期的失败任务
+func (q *Queue) maintain(queue string) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if err := forwardScript.Run(q.client, []string{q.key(queue, StateScheduled), q.key(queue, StatePending)}, now.UnixMilli(), maintainBatch, q.prefix()+"job:").Err(); err != nil && err != rd.Nil {
+			q.Warn("转移到期的延迟任务失败！", zap.String("queue", queue), zap.Error(err))
+		}
+		q.recoverExpired(queue, now)
+		if q.opts.FailedRetention > 0 {
+			if err := trimScript.Run(q.client, []string{q.key(queue, StateFailed)}, now.Add(-q.opts.FailedRetention).UnixMilli(), maintainBatch, q.prefix()+"job:").Err(); err != nil && err != rd.Nil {
+				q.Warn("清理过期的失败任务失败！", zap.String("queue", queue), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (q *Queue) recoverExpired(queue string, now time.Time) {
+	ids, err := q.client.ZRangeByScore(q.key(queue, StateActive), rd.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: maintainBatch,
+	}).Result()
+	if err != nil {
+		q.Warn("查询租约到期的任务失败！", zap.String("queue", queue), zap.Error(err))
+		return
+	}
+	for _, id := range ids {
+		job, err := q.getJob(id)
+		if err != nil {
+			q.Warn("查询租约到期的任务失败！", zap.String("id", id), zap.Error(err))
+			continue
+		}
+		if job == nil {
+			q.client.ZRem(q.key(queue, StateActive), id)
+			continue
+		}
+		q.failed(job, errLeaseExpired, true)
+	}
+}
+
+func (q *Queue) dequeue(queue string) (*Job, error) {
+	deadline := time.Now().Add(q.opts.LeaseTimeout).UnixMilli()
+	id, err := dequeueScript.Run(q.client, []string{q.key(queue, StatePending), q.key(queue, StateActive)}, deadline, q.prefix()+"job:").String()
+	if err == rd.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job, err := q.getJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		// 任务已被删除
+		q.client.ZRem(q.key(queue, StateActive), id)
+		return nil, nil
+	}
+	return job, nil
+}
+
+func (q *Queue) complete(job *Job) error {
+	return completeScript.Run(q.client, []string{q.key(job.Queue, StateActive), q.jobKey(job.ID)}, job.ID).Err()
+}
+
+// move 把执行中的任务移到延迟执行或失败列表 任务已经不在执行中（其他实例处理过）时返回false
+func (q *Queue) move(job *Job, state string, at time.Time, lastError string, retried int) (bool, error) {
+	processAt, failedAt := job.ProcessAt, job.FailedAt
+	if state == StateFailed {
+		failedAt = at.Unix()
+	} else {
+		processAt = at.Unix()
+	}
+	moved, err := moveScript.Run(q.client, []string{q.key(job.Queue, StateActive), q.key(job.Queue, state), q.jobKey(job.ID)},
+		job.ID, at.UnixMilli(), state, truncate(lastError, 1000), retried, processAt, failedAt).Int()
+	return moved == 1, err
+}
+
+// Stats 队列中各状态的任务数
+type Stats struct {
+	Queue     string
+	Pending   int64
+	Scheduled int64
+	Active    int64
+	Failed    int64
+}
+
+// Stats 所有队列的任务数
+func (q *Queue) Stats() ([]*Stats, error) {
+	queues, err := q.client.SMembers(q.prefix() + "queues").Result()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Stats, 0, len(queues))
+	for _, queue := range queues {
+		pipe := q.client.Pipeline()
+		pending := pipe.LLen(q.key(queue, StatePending))
+		scheduled := pipe.ZCard(q.key(queue, StateScheduled))
+		active := pipe.ZCard(q.key(queue, StateActive))
+		failed := pipe.ZCard(q.key(queue, StateFailed))
+		if _, err = pipe.Exec(); err != nil {
+			return nil, err
+		}
+		results = append(results, &Stats{
+			Queue:     queue,
+			Pending:   pending.Val(),
+			Scheduled: scheduled.Val(),
+			Active:    active.Val(),
+			Failed:    failed.Val(),
+		})
+	}
+	return results, nil
+}
+
+// FailedJobs 队列中失败的任务 按失败时间倒序
+func (q *Queue) FailedJobs(queue string, offset int64, limit int64) ([]*Job, int64, error) {
+	key := q.key(queue, StateFailed)
+	total, err := q.client.ZCard(key).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	ids, err := q.client.ZRevRange(key, offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	jobs := make([]*Job, 0, len(ids))
+	for _, id := range ids {
+		job, err := q.getJob(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		if job != nil {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, total, nil
+}
+
+// GetJob 查询任务 不存在时返回nil
+func (q *Queue) GetJob(id string) (*Job, error) {
+	return q.getJob(id)
+}
+
+// RetryFailed 重新执行失败的任务 重试次数清零
+func (q *Queue) RetryFailed(id string) error {
+	job, err := q.getJob(id)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotFound
+	}
+	ok, err := retryScript.Run(q.client, []string{q.key(job.Queue, StateFailed), q.key(job.Queue, StatePending), q.jobKey(id)}, id).Int()
+	if err != nil {
+		return err
+	}
+	if ok != 1 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// DeleteFailed 删除失败的任务
+func (q *Queue) DeleteFailed(id string) error {
+	job, err := q.getJob(id)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotFound
+	}
+	ok, err := deleteScript.Run(q.client, []string{q.key(job.Queue, StateFailed), q.jobKey(id)}, id).Int()
+	if err != nil {
+		return err
+	}
+	if ok != 1 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (q *Queue) getJob(id string) (*Job, error) {
+	values, err := q.client.HGetAll(q.jobKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return decodeJob(values), nil
+}
+
+func (q *Queue) prefix() string {
+	return q.opts.Prefix
+}
+
+func (q *Queue) key(queue string, state string) string {
+	return q.opts.Prefix + queue + ":" + state
+}
+
+func (q *Queue) jobKey(id string) string {
+	return q.opts.Prefix + "job:" + id
+}
+
+func encodeJob(j *Job) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         j.ID,
+		"queue":      j.Queue,
+		"type":       j.Type,
+		"payload":    string(j.Payload),
+		"state":      j.State,
+		"retried":    j.Retried,
+		"max_retry":  j.MaxRetry,
+		"last_error": j.LastError,
+		"created_at": j.CreatedAt,
+		"process_at": j.ProcessAt,
+		"failed_at":  j.FailedAt,
+	}
+}
+
+func decodeJob(values map[string]string) *Job {
+	toInt64 := func(key string) int64 {
+		v, _ := strconv.ParseInt(values[key], 10, 64)
+		return v
+	}
+	return &Job{
+		ID:        values["id"],
+		Queue:     values["queue"],
+		Type:      values["type"],
+		Payload:   []byte(values["payload"]),
+		State:     values["state"],
+		Retried:   int(toInt64("retried")),
+		MaxRetry:  int(toInt64("max_retry")),
+		LastError: values["last_error"],
+		CreatedAt: toInt64("created_at"),
+		ProcessAt: toInt64("process_at"),
+		FailedAt:  toInt64("failed_at"),
+	}
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,65 @@
+package jobqueue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second*10, Backoff(1, time.Second*10, time.Minute))
+	assert.Equal(t, time.Second*20, Backoff(2, time.Second*10, time.Minute))
+	assert.Equal(t, time.Second*40, Backoff(3, time.Second*10, time.Minute))
+	assert.Equal(t, time.Minute, Backoff(4, time.Second*10, time.Minute))
+	assert.Equal(t, time.Minute, Backoff(100, time.Second*10, time.Minute))
+	assert.Equal(t, time.Second*10, Backoff(1, 0, 0))
+}
+
+func TestEncodeJob(t *testing.T) {
+	job := &Job{
+		ID:        "1",
+		Queue:     "message",
+		Type:      "message.send_batch",
+		Payload:   []byte(`{"uids":["u1"]}`),
+		State:     StateFailed,
+		Retried:   2,
+		MaxRetry:  5,
+		LastError: "超时",
+		CreatedAt: 100,
+		ProcessAt: 200,
+		FailedAt:  300,
+	}
+	values := map[string]string{}
+	for field, value := range encodeJob(job) {
+		values[field] = fmt.Sprint(value)
+	}
+	assert.Equal(t, job, decodeJob(values))
+
+	var payload struct {
+		UIDs []string `json:"uids"`
+	}
+	assert.NoError(t, job.Bind(&payload))
+	assert.Equal(t, []string{"u1"}, payload.UIDs)
+}
+
+func TestRegister(t *testing.T) {
+	Register("test", "test.noop", func(ctx context.Context, job *Job) error { return nil })
+	r, ok := lookup("test.noop")
+	assert.True(t, ok)
+	assert.Equal(t, "test", r.queue)
+	assert.Contains(t, registeredQueues(), "test")
+
+	Configure(nil)
+	_, err := Enqueue("test.noop", nil)
+	assert.Equal(t, ErrNotConfigured, err)
+}
+
+func TestSafeRun(t *testing.T) {
+	err := safeRun(context.Background(), func(ctx context.Context, job *Job) error {
+		panic("boom")
+	}, &Job{})
+	assert.EqualError(t, err, "panic: boom")
+}
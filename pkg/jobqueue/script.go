@@ -0,0 +1,98 @@
+package jobqueue
+
+import rd "github.com/go-redis/redis"
+
+// enqueueScript 添加任务 任务已存在时不添加
+// KEYS[1] 任务key KEYS[2] pending或scheduled KEYS[3] 所有队列
+// ARGV[1] 执行时间 为0时立即执行 ARGV[2...n-1] 任务的字段和值 ARGV[n] 队列名
+var enqueueScript = rd.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+redis.call("HMSET", KEYS[1], unpack(ARGV, 2, #ARGV - 1))
+if ARGV[1] == "0" then
+	redis.call("LPUSH", KEYS[2], redis.call("HGET", KEYS[1], "id"))
+else
+	redis.call("ZADD", KEYS[2], ARGV[1], redis.call("HGET", KEYS[1], "id"))
+end
+redis.call("SADD", KEYS[3], ARGV[#ARGV])
+return 1
+`)
+
+// dequeueScript 取出一个等待执行的任务并设置租约
+// KEYS[1] pending KEYS[2] active ARGV[1] 租约到期时间 ARGV[2] 任务key的前缀
+var dequeueScript = rd.NewScript(`
+local id = redis.call("RPOP", KEYS[1])
+if not id then
+	return false
+end
+redis.call("ZADD", KEYS[2], ARGV[1], id)
+redis.call("HSET", ARGV[2] .. id, "state", "active")
+return id
+`)
+
+// forwardScript 把到期的延迟任务转为等待执行
+// KEYS[1] scheduled KEYS[2] pending ARGV[1] 当前时间 ARGV[2] 最多转移的数量 ARGV[3] 任务key的前缀
+var forwardScript = rd.NewScript(`
+local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call("ZREM", KEYS[1], id)
+	redis.call("LPUSH", KEYS[2], id)
+	redis.call("HSET", ARGV[3] .. id, "state", "pending")
+end
+return #ids
+`)
+
+// completeScript 删除已完成的任务 租约已到期被其他实例重新执行时不删除
+// KEYS[1] active KEYS[2] 任务key ARGV[1] 任务id
+var completeScript = rd.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("DEL", KEYS[2])
+return 1
+`)
+
+// moveScript 把执行中的任务移到延迟执行或失败列表
+// KEYS[1] active KEYS[2] 目标zset KEYS[3] 任务key
+// ARGV[1] 任务id ARGV[2] score ARGV[3] 状态 ARGV[4] 失败原因 ARGV[5] 已重试次数 ARGV[6] 执行时间 ARGV[7] 失败时间
+var moveScript = rd.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[2], ARGV[1])
+redis.call("HMSET", KEYS[3], "state", ARGV[3], "last_error", ARGV[4], "retried", ARGV[5], "process_at", ARGV[6], "failed_at", ARGV[7])
+return 1
+`)
+
+// retryScript 失败的任务重新执行
+// KEYS[1] failed KEYS[2] pending KEYS[3] 任务key ARGV[1] 任务id
+var retryScript = rd.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("HMSET", KEYS[3], "state", "pending", "retried", 0, "failed_at", 0)
+redis.call("LPUSH", KEYS[2], ARGV[1])
+return 1
+`)
+
+// deleteScript 删除失败的任务
+// KEYS[1] failed KEYS[2] 任务key ARGV[1] 任务id
+var deleteScript = rd.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("DEL", KEYS[2])
+return 1
+`)
+
+// trimScript 清理过期的失败任务
+// KEYS[1] failed ARGV[1] 失败时间早于此时间的删除 ARGV[2] 最多删除的数量 ARGV[3] 任务key的前缀
+var trimScript = rd.NewScript(`
+local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call("ZREM", KEYS[1], id)
+	redis.call("DEL", ARGV[3] .. id)
+end
+return #ids
+`)
@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sendgrid/rest"
+)
+
+// routeUnmatched 没有匹配到路由的请求（404） 不使用原始路径避免指标数量无限增长
+const routeUnmatched = "unmatched"
+
+var (
+	// httpRequestTotal 接口请求次数（按路由和状态码）
+	httpRequestTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_http_requests_total",
+		Help: "接口请求次数",
+	}, []string{"method", "route", "status"})
+	// httpRequestDuration 接口耗时（按路由）
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tsdd_http_request_duration_seconds",
+		Help:    "接口耗时",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+	// httpRequestsInFlight 正在处理的请求数
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "tsdd_http_requests_in_flight",
+		Help: "正在处理的请求数",
+	})
+	// imRequestTotal 调用IM接口的次数（按接口和结果）
+	imRequestTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdd_im_requests_total",
+		Help: "调用IM接口的次数",
+	}, []string{"path", "result"})
+	// imRequestDuration 调用IM接口的耗时
+	imRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tsdd_im_request_duration_seconds",
+		Help:    "调用IM接口的耗时",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"path"})
+)
+
+var (
+	// httpErrorCount 接口返回5xx的次数 用于定时报告统计错误 不需要查询prometheus
+	httpErrorCount atomic.Int64
+	// imErrorCount 调用IM接口失败的次数
+	imErrorCount atomic.Int64
+)
+
+// ErrorTotals 当前实例启动以来接口返回5xx的次数和调用IM接口失败的次数
+func ErrorTotals() (int64, int64) {
+	return httpErrorCount.Load(), imErrorCount.Load()
+}
+
+const (
+	imResultSuccess = "success"
+	imResultFailed  = "failed" // IM返回了错误的状态码
+	imResultError   = "error"  // 网络错误或超时
+)
+
+// HTTPMiddleware 记录接口的请求次数和耗时 路由使用注册时的路径 例如 /v1/users/:uid
+func HTTPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = routeUnmatched
+		}
+		httpRequestTotal.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			httpErrorCount.Add(1)
+		}
+		httpRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDB 采集数据库连接池的状态（连接数、等待次数和等待时长等）
+func RegisterDB(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterRedis 每次采集时ping一次redis 记录是否可用和耗时
+// 使用的redis客户端没有暴露连接池 所以只能通过ping反映redis的状态
+func RegisterRedis(ping func() error) error {
+	return prometheus.Register(&redisCollector{
+		ping: ping,
+		upDesc: prometheus.NewDesc(
+			"tsdd_redis_up",
+			"redis是否可用 1.可用 0.不可用",
+			nil, nil,
+		),
+		durationDesc: prometheus.NewDesc(
+			"tsdd_redis_ping_duration_seconds",
+			"ping redis的耗时",
+			nil, nil,
+		),
+	})
+}
+
+type redisCollector struct {
+	ping         func() error
+	upDesc       *prometheus.Desc
+	durationDesc *prometheus.Desc
+}
+
+func (r *redisCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.upDesc
+	ch <- r.durationDesc
+}
+
+func (r *redisCollector) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	err := r.ping()
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	ch <- prometheus.MustNewConstMetric(r.upDesc, prometheus.GaugeValue, up)
+	ch <- prometheus.MustNewConstMetric(r.durationDesc, prometheus.GaugeValue, time.Since(start).Seconds())
+}
+
+var instrumentIMOnce sync.Once
+
+// InstrumentIM 记录调用IM接口的次数、错误和耗时
+// IM接口通过rest.DefaultClient调用 所以替换它的Transport 只统计apiURL下的请求
+func InstrumentIM(apiURL string) {
+	apiURL = strings.TrimSuffix(strings.TrimSpace(apiURL), "/")
+	if apiURL == "" {
+		return
+	}
+	instrumentIMOnce.Do(func() {
+		httpClient := rest.DefaultClient.HTTPClient
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = &imTransport{
+			apiURL: apiURL,
+			next:   next,
+		}
+	})
+}
+
+type imTransport struct {
+	apiURL string
+	next   http.RoundTripper
+}
+
+func (t *imTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, ok := t.imPath(req)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	imRequestDuration.WithLabelValues(path).Observe(time.Since(start).Seconds())
+	result := imResult(resp, err)
+	imRequestTotal.WithLabelValues(path, result).Inc()
+	if result != imResultSuccess {
+		imErrorCount.Add(1)
+	}
+	return resp, err
+}
+
+// imPath 请求IM的接口路径 不是请求IM时返回false
+func (t *imTransport) imPath(req *http.Request) (string, bool) {
+	if req.URL == nil {
+		return "", false
+	}
+	reqURL := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+	if !strings.HasPrefix(reqURL, t.apiURL) {
+		return "", false
+	}
+	path := strings.TrimPrefix(reqURL, t.apiURL)
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		// 只是host的前缀相同 例如 http://im:5001 和 http://im:50011
+		return "", false
+	}
+	return path, true
+}
+
+func imResult(resp *http.Response, err error) string {
+	if err != nil {
+		return imResultError
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return imResultFailed
+	}
+	return imResultSuccess
+}
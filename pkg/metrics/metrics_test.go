@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(HTTPMiddleware())
+	r.GET("/v1/users/:uid", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/v1/users/u1", "/v1/users/u2", "/notfound"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequestTotal.WithLabelValues(http.MethodGet, "/v1/users/:uid", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequestTotal.WithLabelValues(http.MethodGet, routeUnmatched, "404")))
+}
+
+func TestErrorTotals(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(HTTPMiddleware())
+	r.GET("/v1/errors", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+	httpErrors, _ := ErrorTotals()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/errors", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/notfound", nil))
+	// 只统计5xx
+	current, _ := ErrorTotals()
+	assert.Equal(t, httpErrors+1, current)
+}
+
+func TestIMPath(t *testing.T) {
+	tr := &imTransport{apiURL: "http://127.0.0.1:5001"}
+
+	req := httptest.NewRequest(http.MethodPost, "http://127.0.0.1:5001/channel/info?a=1", nil)
+	path, ok := tr.imPath(req)
+	assert.True(t, ok)
+	assert.Equal(t, "/channel/info", path)
+
+	req = httptest.NewRequest(http.MethodPost, "http://127.0.0.1:50011/channel/info", nil)
+	_, ok = tr.imPath(req)
+	assert.False(t, ok)
+
+	req = httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	_, ok = tr.imPath(req)
+	assert.False(t, ok)
+}
+
+func TestIMResult(t *testing.T) {
+	assert.Equal(t, imResultError, imResult(nil, errors.New("timeout")))
+	assert.Equal(t, imResultFailed, imResult(&http.Response{StatusCode: http.StatusBadRequest}, nil))
+	assert.Equal(t, imResultSuccess, imResult(&http.Response{StatusCode: http.StatusOK}, nil))
+}
@@ -0,0 +1,99 @@
+package profiling
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxFingerprintLen 指纹的最大长度 超过时截断
+const maxFingerprintLen = 1024
+
+var (
+	// valueListRegexp IN (1,2,3) 和 VALUES (?,?) 中的值列表
+	valueListRegexp = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	// valueRowsRegexp 批量插入的多行 VALUES (?+),(?+)
+	valueRowsRegexp = regexp.MustCompile(`\(\?\+\)(?:\s*,\s*\(\?\+\))+`)
+)
+
+// Fingerprint SQL语句的指纹 值替换为? 值的列表合并为(?+) 连续的空白合并为一个空格 关键字和表名转为小写
+// 参数不同的同一条语句指纹相同 指纹中不包含用户数据
+func Fingerprint(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	write := func(token string) {
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteString(token)
+	}
+	writeByte := func(ch byte) {
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(ch)
+	}
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			i = skipQuoted(query, i)
+			write("?")
+		case ch == '`':
+			end := strings.IndexByte(query[i+1:], '`')
+			if end < 0 {
+				write(query[i:])
+				i = len(query)
+				continue
+			}
+			write(query[i : i+end+2])
+			i += end + 1
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			space = b.Len() > 0
+		case isDigit(ch) && (space || b.Len() == 0 || !isIdent(b.String()[b.Len()-1])):
+			for i+1 < len(query) && (isIdent(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			write("?")
+		default:
+			if ch >= 'A' && ch <= 'Z' {
+				ch += 'a' - 'A'
+			}
+			writeByte(ch)
+		}
+	}
+	fp := valueListRegexp.ReplaceAllString(b.String(), "(?+)")
+	fp = valueRowsRegexp.ReplaceAllString(fp, "(?+)")
+	if len(fp) > maxFingerprintLen {
+		fp = fp[:maxFingerprintLen]
+	}
+	return fp
+}
+
+// skipQuoted 跳过从start开始的字符串 支持反斜杠转义和两个引号的转义 返回字符串最后一个字符的位置
+func skipQuoted(query string, start int) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(query) - 1
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdent(ch byte) bool {
+	return isDigit(ch) || ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
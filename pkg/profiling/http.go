@@ -0,0 +1,29 @@
+package profiling
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HTTPMiddleware 统计超过阈值的接口 路由使用注册时的路径 例如 /v1/users/:uid 没有匹配到路由的请求不统计
+func HTTPMiddleware() gin.HandlerFunc {
+	lg := log.NewTLog("Profiling")
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		cfg := extconfig.Get().Profiling
+		d := time.Since(start)
+		route := c.FullPath()
+		if cfg.SlowHTTP <= 0 || d < cfg.SlowHTTP || route == "" {
+			return
+		}
+		if httpRecorder.Record(c.Request.Method, route, d, cfg.MaxEntries) {
+			lg.Warn("慢接口", zap.String("method", c.Request.Method), zap.String("route", route), zap.Int("status", c.Writer.Status()), zap.Duration("duration", d))
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package profiling
+
+import (
+	"crypto/hmac"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+)
+
+// RoutePProf 注册 /debug/pprof 没有开启profiling.pprof或没有设置token时返回404
+// 例如 go tool pprof "https://api.xxx.com/debug/pprof/heap?token=xxx"
+func RoutePProf(r *wkhttp.WKHttp) {
+	handler := func(c *wkhttp.Context) {
+		cfg := extconfig.Get().Profiling
+		if !cfg.PProf || cfg.PProfToken == "" {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		reqToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if reqToken == "" {
+			reqToken = c.Query("token")
+		}
+		if !hmac.Equal([]byte(cfg.PProfToken), []byte(reqToken)) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		switch c.Param("name") {
+		case "/cmdline":
+			pprof.Cmdline(c.Writer, c.Request)
+		case "/profile":
+			pprof.Profile(c.Writer, c.Request)
+		case "/symbol":
+			pprof.Symbol(c.Writer, c.Request)
+		case "/trace":
+			pprof.Trace(c.Writer, c.Request)
+		default: // 索引页和heap、goroutine等profile
+			pprof.Index(c.Writer, c.Request)
+		}
+	}
+	r.GET("/debug/pprof/*name", handler)
+	r.POST("/debug/pprof/*name", handler) // symbol
+}
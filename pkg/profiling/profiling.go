@@ -0,0 +1,167 @@
+// Package profiling 慢查询和慢接口的统计 以及受保护的pprof
+//
+// 超过阈值（profiling.slowSQL、profiling.slowHTTP）的SQL按指纹、接口按路由聚合
+// 同一条语句或接口每分钟最多记录一次日志 统计只保存在当前实例的内存中 重启后清空
+package profiling
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// logInterval 同一条语句或接口记录日志的最小间隔
+const logInterval = time.Minute
+
+const (
+	SortTotal = "total" // 按总耗时排序
+	SortMax   = "max"   // 按最大耗时排序
+	SortAvg   = "avg"   // 按平均耗时排序
+	SortCount = "count" // 按次数排序
+)
+
+// Stat 一条语句或一个接口的统计
+type Stat struct {
+	Tag     string  `json:"tag"`      // SQL为数据库（tsdd、replica:从库名） 接口为请求方法
+	Key     string  `json:"key"`      // SQL的指纹或接口的路由
+	Count   int64   `json:"count"`    // 超过阈值的次数
+	TotalMs float64 `json:"total_ms"` // 总耗时（毫秒）
+	AvgMs   float64 `json:"avg_ms"`   // 平均耗时（毫秒）
+	MaxMs   float64 `json:"max_ms"`   // 最大耗时（毫秒）
+	LastAt  int64   `json:"last_at"`  // 最后一次的时间
+}
+
+type entry struct {
+	tag, key   string
+	count      int64
+	total, max time.Duration
+	last       time.Time
+	lastLogged time.Time
+}
+
+// Report 统计报告
+type Report struct {
+	Since   int64  `json:"since"`   // 开始统计的时间
+	Dropped int64  `json:"dropped"` // 超过maxEntries没有统计的次数
+	Items   []Stat `json:"items"`
+}
+
+// Recorder 按语句或接口聚合耗时
+type Recorder struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	dropped int64
+	since   time.Time
+}
+
+// NewRecorder 创建统计
+func NewRecorder() *Recorder {
+	return &Recorder{
+		entries: map[string]*entry{},
+		since:   time.Now(),
+	}
+}
+
+// Record 记录一次耗时 返回是否需要记录日志 maxEntries为0时不限制统计的数量
+func (r *Recorder) Record(tag, key string, d time.Duration, maxEntries int) bool {
+	now := time.Now()
+	mapKey := tag + " " + key
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entries[mapKey]
+	if e == nil {
+		if maxEntries > 0 && len(r.entries) >= maxEntries {
+			r.dropped++
+			return false
+		}
+		e = &entry{tag: tag, key: key}
+		r.entries[mapKey] = e
+	}
+	e.count++
+	e.total += d
+	if d > e.max {
+		e.max = d
+	}
+	e.last = now
+	if now.Sub(e.lastLogged) < logInterval {
+		return false
+	}
+	e.lastLogged = now
+	return true
+}
+
+// Top 排序后的前limit条 limit<=0时返回全部
+func (r *Recorder) Top(sortBy string, limit int) *Report {
+	r.mu.Lock()
+	report := &Report{Since: r.since.Unix(), Dropped: r.dropped, Items: make([]Stat, 0, len(r.entries))}
+	for _, e := range r.entries {
+		report.Items = append(report.Items, Stat{
+			Tag:     e.tag,
+			Key:     e.key,
+			Count:   e.count,
+			TotalMs: milliseconds(e.total),
+			AvgMs:   milliseconds(e.total / time.Duration(e.count)),
+			MaxMs:   milliseconds(e.max),
+			LastAt:  e.last.Unix(),
+		})
+	}
+	r.mu.Unlock()
+
+	value := func(s Stat) float64 {
+		switch sortBy {
+		case SortMax:
+			return s.MaxMs
+		case SortAvg:
+			return s.AvgMs
+		case SortCount:
+			return float64(s.Count)
+		default:
+			return s.TotalMs
+		}
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		vi, vj := value(report.Items[i]), value(report.Items[j])
+		if vi != vj {
+			return vi > vj
+		}
+		return report.Items[i].Key < report.Items[j].Key
+	})
+	if limit > 0 && len(report.Items) > limit {
+		report.Items = report.Items[:limit]
+	}
+	return report
+}
+
+// Reset 清空统计
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = map[string]*entry{}
+	r.dropped = 0
+	r.since = time.Now()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+var (
+	sqlRecorder  = NewRecorder()
+	httpRecorder = NewRecorder()
+)
+
+// SQLReport 慢查询的统计
+func SQLReport(sortBy string, limit int) *Report {
+	return sqlRecorder.Top(sortBy, limit)
+}
+
+// HTTPReport 慢接口的统计
+func HTTPReport(sortBy string, limit int) *Report {
+	return httpRecorder.Top(sortBy, limit)
+}
+
+// Reset 清空慢查询和慢接口的统计
+func Reset() {
+	sqlRecorder.Reset()
+	httpRecorder.Reset()
+}
@@ -0,0 +1,115 @@
+package profiling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gocraft/dbr/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM `user` WHERE uid='u1' AND status=1":                          "select * from `user` where uid=? and status=?",
+		"select  *\n from user where name = 'it\\'s' and note=\"a\"\"b\"":           "select * from user where name = ? and note=?",
+		"SELECT uid FROM user2 WHERE id IN (1,2,3) LIMIT 20":                        "select uid from user2 where id in (?+) limit ?",
+		"INSERT INTO `group` (group_no,name) VALUES ('g1','群1'),('g2','群2')":        "insert into `group` (group_no,name) values (?+)",
+		"UPDATE t SET version=version+1, price=1.5e3 WHERE created_at>'2026-10-14'": "update t set version=version+?, price=? where created_at>?",
+		"SELECT * FROM `User` WHERE name='张三'":                                      "select * from `User` where name=?",
+	}
+	for query, expected := range cases {
+		assert.Equal(t, expected, Fingerprint(query), query)
+	}
+	assert.Equal(t, Fingerprint("select * from user where uid='a'"), Fingerprint("select * from user where uid='b'"))
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	assert.True(t, r.Record("tsdd", "select ?", time.Millisecond*300, 2))
+	assert.False(t, r.Record("tsdd", "select ?", time.Millisecond*100, 2)) // 一分钟内只记录一次日志
+	assert.True(t, r.Record("tsdd", "update t", time.Millisecond*250, 2))
+	assert.False(t, r.Record("tsdd", "delete t", time.Second, 2)) // 超过maxEntries
+
+	report := r.Top(SortTotal, 0)
+	assert.Equal(t, int64(1), report.Dropped)
+	assert.Len(t, report.Items, 2)
+	assert.Equal(t, "select ?", report.Items[0].Key)
+	assert.Equal(t, int64(2), report.Items[0].Count)
+	assert.Equal(t, 400.0, report.Items[0].TotalMs)
+	assert.Equal(t, 200.0, report.Items[0].AvgMs)
+	assert.Equal(t, 300.0, report.Items[0].MaxMs)
+
+	report = r.Top(SortAvg, 1)
+	assert.Len(t, report.Items, 1)
+	assert.Equal(t, "update t", report.Items[0].Key)
+
+	r.Reset()
+	assert.Empty(t, r.Top(SortTotal, 0).Items)
+}
+
+func TestInstrumentDB(t *testing.T) {
+	Reset()
+	session := &dbr.Session{EventReceiver: &dbr.NullEventReceiver{}}
+	InstrumentDB(session, "tsdd")
+	InstrumentDB(session, "tsdd") // 重复调用不会重复统计
+	receiver, ok := session.EventReceiver.(*sqlReceiver)
+	assert.True(t, ok)
+	_, ok = receiver.EventReceiver.(*sqlReceiver)
+	assert.False(t, ok)
+
+	slow := extconfig.Get().Profiling.SlowSQL
+	session.TimingKv("dbr.select", (slow / 2).Nanoseconds(), map[string]string{"sql": "SELECT 1"})
+	session.TimingKv("dbr.select", (slow * 2).Nanoseconds(), map[string]string{"sql": "SELECT * FROM user WHERE uid='u1'"})
+	report := SQLReport(SortTotal, 10)
+	assert.Len(t, report.Items, 1)
+	assert.Equal(t, "tsdd", report.Items[0].Tag)
+	assert.Equal(t, "select * from user where uid=?", report.Items[0].Key)
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	Reset()
+	cfg := &extconfig.Get().Profiling
+	slow := cfg.SlowHTTP
+	cfg.SlowHTTP = time.Millisecond * 10
+	defer func() { cfg.SlowHTTP = slow }()
+
+	r := wkhttp.New()
+	r.UseGin(HTTPMiddleware())
+	r.GET("/v1/users/:uid", func(c *wkhttp.Context) {
+		if c.Param("uid") == "slow" {
+			time.Sleep(time.Millisecond * 20)
+		}
+		c.ResponseOK()
+	})
+	for _, path := range []string{"/v1/users/fast", "/v1/users/slow", "/v1/not_found"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	report := HTTPReport(SortTotal, 10)
+	assert.Len(t, report.Items, 1)
+	assert.Equal(t, "GET", report.Items[0].Tag)
+	assert.Equal(t, "/v1/users/:uid", report.Items[0].Key)
+}
+
+func TestRoutePProf(t *testing.T) {
+	r := wkhttp.New()
+	RoutePProf(r)
+	request := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+	// 没有开启
+	assert.Equal(t, http.StatusNotFound, request("/debug/pprof/"))
+
+	cfg := &extconfig.Get().Profiling
+	defer func() { cfg.PProf, cfg.PProfToken = false, "" }()
+	cfg.PProf, cfg.PProfToken = true, "secret"
+	assert.Equal(t, http.StatusUnauthorized, request("/debug/pprof/"))
+	assert.Equal(t, http.StatusUnauthorized, request("/debug/pprof/heap?token=wrong"))
+	assert.Equal(t, http.StatusOK, request("/debug/pprof/?token=secret"))
+	assert.Equal(t, http.StatusOK, request("/debug/pprof/goroutine?token=secret"))
+}
@@ -0,0 +1,52 @@
+package profiling
+
+import (
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/gocraft/dbr/v2"
+	"go.uber.org/zap"
+)
+
+// sqlReceiver 统计dbr执行的SQL的耗时 其他事件交给原来的EventReceiver
+type sqlReceiver struct {
+	dbr.EventReceiver
+	tag string
+	log.Log
+}
+
+// TimingKv dbr执行SQL后调用 kvs中的sql为替换参数后的语句
+func (r *sqlReceiver) TimingKv(eventName string, nanoseconds int64, kvs map[string]string) {
+	r.EventReceiver.TimingKv(eventName, nanoseconds, kvs)
+	query := kvs["sql"]
+	if query == "" {
+		return
+	}
+	cfg := extconfig.Get().Profiling
+	d := time.Duration(nanoseconds)
+	if cfg.SlowSQL <= 0 || d < cfg.SlowSQL {
+		return
+	}
+	fingerprint := Fingerprint(query)
+	if sqlRecorder.Record(r.tag, fingerprint, d, cfg.MaxEntries) {
+		r.Warn("慢查询", zap.String("db", r.tag), zap.String("fingerprint", fingerprint), zap.Duration("duration", d))
+	}
+}
+
+// InstrumentDB 统计session执行的慢查询 tag为数据库的名称
+// 语句和事务创建时复制session的EventReceiver 需要在处理请求之前调用
+func InstrumentDB(session *dbr.Session, tag string) {
+	if _, ok := session.EventReceiver.(*sqlReceiver); ok {
+		return
+	}
+	next := session.EventReceiver
+	if next == nil {
+		next = &dbr.NullEventReceiver{}
+	}
+	session.EventReceiver = &sqlReceiver{
+		EventReceiver: next,
+		tag:           tag,
+		Log:           log.NewTLog("Profiling"),
+	}
+}
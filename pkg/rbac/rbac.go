@@ -0,0 +1,235 @@
+package rbac
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+)
+
+// 管理后台的角色 保存在用户的role字段
+const (
+	RoleSuperAdmin = string(wkhttp.SuperAdmin) // 超级管理员 拥有所有权限
+	RoleAdmin      = string(wkhttp.Admin)      // 管理员 之前版本的管理员角色 保留之前的权限
+	RoleSupport    = "support"                 // 客服 查询用户、群和消息
+	RoleModerator  = "moderator"               // 审核员 处理举报、封禁用户和管理敏感词
+	RoleAuditor    = "auditor"                 // 审计员 只读 可以查看管理后台操作日志
+)
+
+// Permission 管理后台的权限
+type Permission string
+
+const (
+	PermUserRead       Permission = "user:read"       // 查询用户
+	PermUserWrite      Permission = "user:write"      // 添加用户、修改用户密码
+	PermUserBan        Permission = "user:ban"        // 封禁和解禁用户
+	PermGroupRead      Permission = "group:read"      // 查询群
+	PermGroupWrite     Permission = "group:write"     // 封禁群、禁言和移除群成员
+	PermMessageRead    Permission = "message:read"    // 查询消息记录和公告
+	PermMessageSend    Permission = "message:send"    // 代用户发送消息
+	PermMessageDelete  Permission = "message:delete"  // 删除消息
+	PermBroadcastSend  Permission = "broadcast:send"  // 发送和取消系统公告
+	PermReportRead     Permission = "report:read"     // 查询举报
+	PermReportHandle   Permission = "report:handle"   // 分配和处理举报
+	PermContentRead    Permission = "content:read"    // 查询敏感词和违禁词
+	PermContentWrite   Permission = "content:write"   // 修改敏感词和违禁词
+	PermContentReview  Permission = "content:review"  // 审核敏感词命中记录
+	PermStatsRead      Permission = "stats:read"      // 查询统计数据
+	PermLogRead        Permission = "log:read"        // 查询短信、文件访问和webhook等记录
+	PermAuditRead      Permission = "audit:read"      // 查询和导出管理后台操作日志
+	PermSecurityRead   Permission = "security:read"   // 查询IP黑名单和国家限制
+	PermSecurityWrite  Permission = "security:write"  // 修改IP黑名单和国家限制
+	PermConfigRead     Permission = "config:read"     // 查询系统配置（应用、机器人、工作台等）
+	PermConfigWrite    Permission = "config:write"    // 修改系统配置
+	PermOperationWrite Permission = "operation:write" // 日常运营配置（版本、短信模版、文件规则等）
+	PermAdminManage    Permission = "admin:manage"    // 管理后台账号
+
+	PermUserImpersonate   Permission = "user:impersonate"   // 以只读方式模拟登录用户 用于排查问题
+	PermComplianceExport  Permission = "compliance:export"  // 申请和下载用户数据的合规导出
+	PermComplianceApprove Permission = "compliance:approve" // 审批合规导出 不能审批自己的申请
+	PermComplianceSearch  Permission = "compliance:search"  // 检索用户或群的消息（合规调查） 需要填写原因
+	PermReportRule        Permission = "report:rule"        // 管理举报的自动处理规则
+	PermBackup            Permission = "system:backup"      // 下载全量数据备份
+	PermProfiling         Permission = "system:profiling"   // 查询和清空慢查询、慢接口统计
+)
+
+// allPermissions 所有权限 按展示顺序
+var allPermissions = []Permission{
+	PermUserRead, PermUserWrite, PermUserBan, PermUserImpersonate,
+	PermGroupRead, PermGroupWrite,
+	PermMessageRead, PermMessageSend, PermMessageDelete, PermBroadcastSend,
+	PermReportRead, PermReportHandle, PermReportRule,
+	PermContentRead, PermContentWrite, PermContentReview,
+	PermStatsRead, PermLogRead, PermAuditRead,
+	PermSecurityRead, PermSecurityWrite,
+	PermConfigRead, PermConfigWrite, PermOperationWrite,
+	PermAdminManage,
+	PermComplianceExport, PermComplianceApprove, PermComplianceSearch,
+	PermBackup, PermProfiling,
+}
+
+// roles 可以分配的角色（不包括超级管理员）
+var roles = []string{RoleAdmin, RoleSupport, RoleModerator, RoleAuditor}
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermUserRead, PermGroupRead, PermMessageRead, PermReportRead, PermReportHandle,
+		PermContentRead, PermContentReview, PermStatsRead, PermLogRead, PermSecurityRead,
+		PermConfigRead, PermOperationWrite, PermProfiling,
+	},
+	RoleSupport: {
+		PermUserRead, PermUserImpersonate, PermGroupRead, PermMessageRead, PermReportRead, PermLogRead,
+	},
+	RoleModerator: {
+		PermUserRead, PermUserBan, PermGroupRead, PermGroupWrite, PermMessageRead, PermMessageDelete,
+		PermReportRead, PermReportHandle, PermReportRule, PermContentRead, PermContentWrite, PermContentReview,
+		PermSecurityRead, PermSecurityWrite,
+	},
+	RoleAuditor: {
+		PermUserRead, PermGroupRead, PermMessageRead, PermReportRead, PermContentRead,
+		PermStatsRead, PermLogRead, PermAuditRead, PermSecurityRead, PermConfigRead,
+		PermComplianceApprove,
+	},
+}
+
+// RoleAPIKeyPrefix API密钥的角色前缀 角色为前缀加上逗号分隔的权限范围 例如 apikey:read-stats,send-broadcast
+const RoleAPIKeyPrefix = "apikey:"
+
+// Scope API密钥的权限范围 用于外部工具对接管理后台
+type Scope string
+
+const (
+	ScopeReadStats     Scope = "read-stats"     // 查询统计数据
+	ScopeManageUsers   Scope = "manage-users"   // 查询、添加和封禁用户
+	ScopeSendBroadcast Scope = "send-broadcast" // 发送和取消系统公告
+)
+
+// scopes API密钥可以使用的权限范围 按展示顺序
+var scopes = []Scope{ScopeReadStats, ScopeManageUsers, ScopeSendBroadcast}
+
+var scopePermissions = map[Scope][]Permission{
+	ScopeReadStats:     {PermStatsRead},
+	ScopeManageUsers:   {PermUserRead, PermUserWrite, PermUserBan},
+	ScopeSendBroadcast: {PermBroadcastSend},
+}
+
+// roleNames 角色名称
+var roleNames = map[string]string{
+	RoleSuperAdmin: "超级管理员",
+	RoleAdmin:      "管理员",
+	RoleSupport:    "客服",
+	RoleModerator:  "审核员",
+	RoleAuditor:    "审计员",
+}
+
+// IsManagerRole 是否为管理后台的角色（可以登录管理后台）
+func IsManagerRole(role string) bool {
+	return role == RoleSuperAdmin || ValidRole(role)
+}
+
+// ValidRole 是否为可以分配给管理后台账号的角色
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Roles 可以分配的角色
+func Roles() []string {
+	return roles
+}
+
+// RoleName 角色名称
+func RoleName(role string) string {
+	return roleNames[role]
+}
+
+// Permissions 角色拥有的权限
+func Permissions(role string) []Permission {
+	if role == RoleSuperAdmin {
+		return allPermissions
+	}
+	if IsAPIKeyRole(role) {
+		return ScopePermissions(strings.Split(strings.TrimPrefix(role, RoleAPIKeyPrefix), ","))
+	}
+	return rolePermissions[role]
+}
+
+// Scopes API密钥可以使用的权限范围
+func Scopes() []Scope {
+	return scopes
+}
+
+// ValidScope 是否为API密钥可以使用的权限范围
+func ValidScope(scope string) bool {
+	_, ok := scopePermissions[Scope(scope)]
+	return ok
+}
+
+// ScopePermissions 权限范围包含的权限 忽略无效的权限范围
+func ScopePermissions(scopeList []string) []Permission {
+	perms := make([]Permission, 0)
+	for _, scope := range scopeList {
+		perms = append(perms, scopePermissions[Scope(scope)]...)
+	}
+	return perms
+}
+
+// APIKeyRole API密钥的角色 权限范围去重并排序
+func APIKeyRole(scopeList []string) string {
+	values := make([]string, 0, len(scopeList))
+	exist := map[string]bool{}
+	for _, scope := range scopeList {
+		if exist[scope] {
+			continue
+		}
+		exist[scope] = true
+		values = append(values, scope)
+	}
+	sort.Strings(values)
+	return RoleAPIKeyPrefix + strings.Join(values, ",")
+}
+
+// IsAPIKeyRole 是否为API密钥的角色 API密钥不是管理后台的角色 只能调用权限范围内的接口
+func IsAPIKeyRole(role string) bool {
+	return strings.HasPrefix(role, RoleAPIKeyPrefix)
+}
+
+// HasPermission 角色是否拥有权限
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range Permissions(role) {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 检查登录用户是否拥有权限
+func Check(c *wkhttp.Context, perm Permission) error {
+	role := c.GetLoginRole()
+	if role == "" {
+		return errors.New("登录用户角色错误")
+	}
+	if !HasPermission(role, perm) {
+		return errors.New("该用户无权执行此操作")
+	}
+	return nil
+}
+
+// CheckManager 检查登录用户为管理后台的账号 用于所有角色都可以执行的操作
+func CheckManager(c *wkhttp.Context) error {
+	role := c.GetLoginRole()
+	if role == "" {
+		return errors.New("登录用户角色错误")
+	}
+	if !IsManagerRole(role) {
+		return errors.New("该用户无权执行此操作")
+	}
+	return nil
+}
+
+// IsSuperAdmin 登录用户是否为超级管理员
+func IsSuperAdmin(c *wkhttp.Context) bool {
+	return c.GetLoginRole() == RoleSuperAdmin
+}
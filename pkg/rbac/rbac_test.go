@@ -0,0 +1,78 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasPermission(t *testing.T) {
+	// 超级管理员拥有所有权限
+	for _, perm := range allPermissions {
+		assert.True(t, HasPermission(RoleSuperAdmin, perm))
+	}
+
+	// 之前版本的管理员保留之前的权限 但不能管理后台账号
+	assert.True(t, HasPermission(RoleAdmin, PermUserRead))
+	assert.True(t, HasPermission(RoleAdmin, PermReportHandle))
+	assert.False(t, HasPermission(RoleAdmin, PermAdminManage))
+	assert.False(t, HasPermission(RoleAdmin, PermUserBan))
+
+	assert.True(t, HasPermission(RoleSupport, PermMessageRead))
+	assert.False(t, HasPermission(RoleSupport, PermMessageDelete))
+	assert.True(t, HasPermission(RoleSupport, PermUserImpersonate))
+	assert.False(t, HasPermission(RoleModerator, PermUserImpersonate))
+
+	assert.True(t, HasPermission(RoleModerator, PermUserBan))
+	assert.True(t, HasPermission(RoleModerator, PermReportRule))
+	assert.False(t, HasPermission(RoleAdmin, PermReportRule))
+	assert.False(t, HasPermission(RoleModerator, PermConfigWrite))
+
+	assert.True(t, HasPermission(RoleAuditor, PermAuditRead))
+	assert.False(t, HasPermission(RoleAuditor, PermUserWrite))
+
+	// 合规导出由超级管理员申请 审计员审批
+	assert.True(t, HasPermission(RoleAuditor, PermComplianceApprove))
+	assert.False(t, HasPermission(RoleAuditor, PermComplianceExport))
+	assert.False(t, HasPermission(RoleAdmin, PermComplianceExport))
+	assert.False(t, HasPermission(RoleAdmin, PermBackup))
+	assert.True(t, HasPermission(RoleAdmin, PermProfiling))
+	assert.False(t, HasPermission(RoleSupport, PermProfiling))
+	// 消息检索只有超级管理员可以使用
+	assert.True(t, HasPermission(RoleSuperAdmin, PermComplianceSearch))
+	assert.False(t, HasPermission(RoleAdmin, PermComplianceSearch))
+	assert.False(t, HasPermission(RoleAuditor, PermComplianceSearch))
+
+	assert.False(t, HasPermission("", PermUserRead))
+	assert.False(t, HasPermission("user", PermUserRead))
+}
+
+func TestAPIKeyRole(t *testing.T) {
+	role := APIKeyRole([]string{string(ScopeSendBroadcast), string(ScopeReadStats), string(ScopeReadStats)})
+	assert.Equal(t, "apikey:read-stats,send-broadcast", role)
+	assert.True(t, IsAPIKeyRole(role))
+	assert.False(t, IsManagerRole(role))
+	assert.False(t, ValidRole(role))
+
+	assert.True(t, HasPermission(role, PermStatsRead))
+	assert.True(t, HasPermission(role, PermBroadcastSend))
+	assert.False(t, HasPermission(role, PermUserRead))
+	assert.False(t, HasPermission(role, PermAdminManage))
+	assert.True(t, HasPermission(APIKeyRole([]string{string(ScopeManageUsers)}), PermUserBan))
+
+	assert.True(t, ValidScope("manage-users"))
+	assert.False(t, ValidScope("admin"))
+	assert.Empty(t, Permissions("apikey:admin"))
+}
+
+func TestRoles(t *testing.T) {
+	assert.True(t, IsManagerRole(RoleSuperAdmin))
+	assert.False(t, ValidRole(RoleSuperAdmin))
+	for _, role := range Roles() {
+		assert.True(t, ValidRole(role))
+		assert.True(t, IsManagerRole(role))
+		assert.NotEmpty(t, RoleName(role))
+	}
+	assert.False(t, IsManagerRole(""))
+	assert.False(t, IsManagerRole("user"))
+}
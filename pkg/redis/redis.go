@@ -203,6 +203,11 @@ func (rc *Conn) Expire(key string, expiration time.Duration) error {
 	return rc.client.Expire(key, expiration).Err()
 }
 
+// TTL 剩余的过期时间 key不存在或没有设置过期时间时小于0
+func (rc *Conn) TTL(key string) (time.Duration, error) {
+	return rc.client.TTL(key).Result()
+}
+
 func (rc *Conn) Hset(key, field, value string) error {
 
 	return rc.client.HSet(key, field, value).Err()
@@ -257,6 +262,12 @@ func (rc *Conn) SRem(key string, member interface{}) error {
 	return rc.client.SRem(key, member).Err()
 }
 
+// SCard 集合的成员数量
+func (rc *Conn) SCard(key string) (int64, error) {
+
+	return rc.client.SCard(key).Result()
+}
+
 /*
 *
 ZADD key score member [[score member] [score member] ...]
@@ -333,6 +344,28 @@ func (rc *Conn) Decr(key string) (int64, error) {
 	return rc.client.Decr(key).Result()
 }
 
+// SetNX key不存在时设置值和过期时间 返回是否设置成功（可用作分布式锁）
+func (rc *Conn) SetNX(key string, value interface{}, expire time.Duration) (bool, error) {
+
+	return rc.client.SetNX(key, value, expire).Result()
+}
+
+var delIfValueScript = rd.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`)
+
+// DelIfValue key的值等于value时才删除（释放SetNX获取的锁，避免删除其他人的锁）
+func (rc *Conn) DelIfValue(key string, value string) error {
+
+	return delIfValueScript.Run(rc.client, []string{key}, value).Err()
+}
+
+var expireIfValueScript = rd.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`)
+
+// ExpireIfValue key的值等于value时才设置过期时间（续期SetNX获取的锁，避免续期其他人的锁）
+func (rc *Conn) ExpireIfValue(key string, value string, expire time.Duration) error {
+
+	return expireIfValueScript.Run(rc.client, []string{key}, value, expire.Milliseconds()).Err()
+}
+
 /*
 *
 设置某个key的过期时间
@@ -0,0 +1,115 @@
+package rpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Options 内部gRPC接口的配置
+type Options struct {
+	Addr  string // 监听地址
+	Token string // 调用需要的token（metadata authorization: Bearer xxx） 必须设置
+}
+
+var (
+	registrarsLock sync.Mutex
+	registrars     []func(s grpc.ServiceRegistrar)
+)
+
+// Register 注册gRPC服务 模块安装时调用 需要在New之前注册
+func Register(fn func(s grpc.ServiceRegistrar)) {
+	registrarsLock.Lock()
+	defer registrarsLock.Unlock()
+	registrars = append(registrars, fn)
+}
+
+// Server 内部gRPC接口 供sidecar和拆分出去的服务直接调用业务层
+// 与IM的webhook（GRPCAddr）使用不同的端口 只应在内网开放
+type Server struct {
+	log.Log
+	opts   Options
+	server *grpc.Server
+}
+
+// New 创建服务并添加已注册的gRPC服务
+func New(opts Options) *Server {
+	s := &Server{
+		Log:  log.NewTLog("RPCAPI"),
+		opts: opts,
+	}
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(s.recover, s.auth))
+	registrarsLock.Lock()
+	for _, fn := range registrars {
+		fn(s.server)
+	}
+	registrarsLock.Unlock()
+	return s
+}
+
+// Start 开始监听 没有配置token时不启动
+func (s *Server) Start() error {
+	if s.opts.Token == "" {
+		return errors.New("内部gRPC接口需要配置rpcAPI.token")
+	}
+	lis, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			s.Error("内部gRPC接口服务退出！", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Serve 在指定的listener上提供服务
+func (s *Server) Serve(lis net.Listener) error {
+	return s.server.Serve(lis)
+}
+
+// Stop 等待进行中的调用结束后停止
+func (s *Server) Stop() {
+	s.server.GracefulStop()
+}
+
+// auth 校验metadata中的token
+func (s *Server) auth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if s.opts.Token == "" { // 没有配置token时拒绝所有调用
+		return nil, status.Error(codes.Unauthenticated, "没有配置token")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "缺少token")
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(values[0], "Bearer "))
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "token无效")
+	}
+	return handler(ctx, req)
+}
+
+// recover 处理中的panic转为Internal错误 避免整个服务退出
+func (s *Server) recover(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.Error("内部gRPC接口处理出错！", zap.String("method", info.FullMethod), zap.Any("panic", r), zap.String("stack", string(debug.Stack())))
+			err = status.Error(codes.Internal, fmt.Sprintf("%v", r))
+		}
+	}()
+	return handler(ctx, req)
+}
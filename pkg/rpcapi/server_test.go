@@ -0,0 +1,92 @@
+package rpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakePresence struct {
+	UnimplementedPresenceServiceServer
+}
+
+func (fakePresence) GetOnlineStatus(ctx context.Context, req *GetOnlineStatusReq) (*GetOnlineStatusResp, error) {
+	if len(req.Uids) == 0 {
+		panic("uids为空")
+	}
+	return &GetOnlineStatusResp{Statuses: []*OnlineStatus{{Uid: req.Uids[0], Online: true}}}, nil
+}
+
+func TestServer(t *testing.T) {
+	Register(func(s grpc.ServiceRegistrar) {
+		RegisterPresenceServiceServer(s, fakePresence{})
+	})
+	defer func() {
+		registrars = nil
+	}()
+	s := New(Options{Token: "secret"})
+	lis := bufconn.Listen(1024 * 1024)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := NewPresenceServiceClient(conn)
+
+	_, err = client.GetOnlineStatus(context.Background(), &GetOnlineStatusReq{Uids: []string{"u1"}})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	_, err = client.GetOnlineStatus(ctx, &GetOnlineStatusReq{Uids: []string{"u1"}})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	resp, err := client.GetOnlineStatus(ctx, &GetOnlineStatusReq{Uids: []string{"u1"}})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Statuses, 1)
+	assert.Equal(t, "u1", resp.Statuses[0].Uid)
+	assert.True(t, resp.Statuses[0].Online)
+
+	// panic转为Internal错误 服务继续可用
+	_, err = client.GetOnlineStatus(ctx, &GetOnlineStatusReq{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// 没有注册的服务
+	_, err = NewUserServiceClient(conn).GetUsers(ctx, &GetUsersReq{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestServerWithoutToken(t *testing.T) {
+	Register(func(s grpc.ServiceRegistrar) {
+		RegisterPresenceServiceServer(s, fakePresence{})
+	})
+	defer func() {
+		registrars = nil
+	}()
+	s := New(Options{Addr: "127.0.0.1:0"})
+	assert.Error(t, s.Start())
+
+	// 没有配置token时拒绝所有调用
+	lis := bufconn.Listen(1024 * 1024)
+	go s.Serve(lis)
+	defer s.Stop()
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer ")
+	_, err = NewPresenceServiceClient(conn).GetOnlineStatus(ctx, &GetOnlineStatusReq{Uids: []string{"u1"}})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
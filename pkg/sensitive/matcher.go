@@ -0,0 +1,161 @@
+package sensitive
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Match 匹配到的敏感词 Start和End为原文中的字符（rune）位置 [Start,End)
+type Match struct {
+	Word  string // 词库中的词（归一化后）
+	Start int
+	End   int
+}
+
+type node struct {
+	children map[rune]*node
+	word     string // 不为空时表示一个词的结尾
+}
+
+// Matcher 基于trie的敏感词匹配 忽略大小写和全角半角 词中间夹杂的空格和符号也能匹配
+// 构建后只读 可以并发使用
+type Matcher struct {
+	root  *node
+	count int
+}
+
+// NewMatcher NewMatcher
+func NewMatcher(words []string) *Matcher {
+	m := &Matcher{
+		root: &node{},
+	}
+	for _, word := range words {
+		m.add(word)
+	}
+	return m
+}
+
+func (m *Matcher) add(word string) {
+	word = Normalize(word)
+	if word == "" {
+		return
+	}
+	n := m.root
+	for _, r := range word {
+		if n.children == nil {
+			n.children = map[rune]*node{}
+		}
+		child := n.children[r]
+		if child == nil {
+			child = &node{}
+			n.children[r] = child
+		}
+		n = child
+	}
+	if n.word == "" {
+		m.count++
+	}
+	n.word = word
+}
+
+// Len 词的数量
+func (m *Matcher) Len() int {
+	return m.count
+}
+
+// Find 查找文本中的敏感词 从左到右取最长的匹配 匹配之间不重叠
+func (m *Matcher) Find(text string) []Match {
+	if m.count == 0 || text == "" {
+		return nil
+	}
+	runes := []rune(text)
+	normalized := make([]rune, len(runes))
+	for i, r := range runes {
+		normalized[i] = normalizeRune(r)
+	}
+	var matches []Match
+	for start := 0; start < len(normalized); {
+		if isNoise(normalized[start]) {
+			start++
+			continue
+		}
+		end, word := m.longestMatch(normalized, start)
+		if word == "" {
+			start++
+			continue
+		}
+		matches = append(matches, Match{Word: word, Start: start, End: end})
+		start = end
+	}
+	return matches
+}
+
+// longestMatch 从start开始的最长匹配 跳过词中间的空格和符号
+func (m *Matcher) longestMatch(text []rune, start int) (int, string) {
+	n := m.root
+	end := 0
+	word := ""
+	for i := start; i < len(text); i++ {
+		r := text[i]
+		child := n.children[r]
+		if child == nil {
+			if i > start && isNoise(r) {
+				continue
+			}
+			break
+		}
+		n = child
+		if n.word != "" {
+			end = i + 1
+			word = n.word
+		}
+	}
+	return end, word
+}
+
+// Contains 文本中是否有敏感词
+func (m *Matcher) Contains(text string) bool {
+	return len(m.Find(text)) > 0
+}
+
+// Replace 将匹配到的敏感词替换为mask 每个字符替换为一个mask
+func Replace(text string, matches []Match, mask rune) string {
+	if len(matches) == 0 {
+		return text
+	}
+	runes := []rune(text)
+	for _, match := range matches {
+		for i := match.Start; i < match.End && i < len(runes); i++ {
+			runes[i] = mask
+		}
+	}
+	return string(runes)
+}
+
+// Normalize 归一化 转为小写、全角转半角并去掉空格和符号
+func Normalize(word string) string {
+	var b strings.Builder
+	for _, r := range word {
+		r = normalizeRune(r)
+		if isNoise(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func normalizeRune(r rune) rune {
+	if r == 0x3000 { // 全角空格
+		return ' '
+	}
+	if r >= 0xFF01 && r <= 0xFF5E { // 全角字符
+		r -= 0xFEE0
+	}
+	return unicode.ToLower(r)
+}
+
+// isNoise 夹杂在敏感词中间用于规避匹配的字符
+func isNoise(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
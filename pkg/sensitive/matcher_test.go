@@ -0,0 +1,42 @@
+package sensitive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcherFind(t *testing.T) {
+	m := NewMatcher([]string{"赌博", "赌博网站", "Spam", "", "  "})
+	assert.Equal(t, 3, m.Len())
+
+	matches := m.Find("这是一个赌博网站，不是赌博")
+	assert.Equal(t, []Match{
+		{Word: "赌博网站", Start: 4, End: 8},
+		{Word: "赌博", Start: 11, End: 13},
+	}, matches)
+
+	// 忽略大小写和全角
+	assert.Equal(t, []Match{{Word: "spam", Start: 2, End: 6}}, m.Find("a SPAM b"))
+	assert.Equal(t, []Match{{Word: "spam", Start: 0, End: 4}}, m.Find("ｓｐａｍ"))
+
+	// 词中间夹杂空格和符号
+	assert.Equal(t, []Match{{Word: "赌博", Start: 0, End: 3}}, m.Find("赌 博"))
+	assert.Equal(t, []Match{{Word: "赌博", Start: 1, End: 4}}, m.Find("【赌*博】"))
+
+	assert.Empty(t, m.Find("正常的消息"))
+	assert.Empty(t, NewMatcher(nil).Find("赌博"))
+	assert.True(t, m.Contains("spam"))
+	assert.False(t, m.Contains("span"))
+}
+
+func TestReplace(t *testing.T) {
+	m := NewMatcher([]string{"赌博"})
+	text := "不要赌-博哦"
+	assert.Equal(t, "不要***哦", Replace(text, m.Find(text), '*'))
+	assert.Equal(t, "hello", Replace("hello", nil, '*'))
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "abc赌博", Normalize(" A-B_C ＂赌　博"))
+}
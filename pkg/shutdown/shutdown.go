@@ -0,0 +1,127 @@
+// Package shutdown 进程退出时按阶段执行清理 滚动发布时不丢失请求和未写入的数据
+//
+// 收到退出信号后先标记为停止中（/readyz返回503 负载均衡不再转发新请求） 等待DrainDelay后按阶段执行注册的处理：
+//
+//	PhaseHTTP  停止接收新请求 等待处理中的请求结束
+//	PhaseFlush 写入内存中还未写入的数据 例如批量写入的操作日志
+//	PhaseStop  停止后台处理 例如任务队列、定时任务和各模块
+//	PhaseClose 关闭事件总线、数据库和redis连接
+//
+// 所有处理共用一个截止时间 超过截止时间后不再等待还没结束的处理 也不再执行剩下的处理
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Phase 执行阶段 按从小到大的顺序执行
+type Phase int
+
+const (
+	PhaseHTTP Phase = iota
+	PhaseFlush
+	PhaseStop
+	PhaseClose
+)
+
+// Hook 退出时的处理 需要在ctx取消后尽快返回
+type Hook func(ctx context.Context) error
+
+type namedHook struct {
+	phase Phase
+	name  string
+	hook  Hook
+}
+
+var (
+	hooksLock sync.Mutex
+	hooks     []namedHook
+	draining  atomic.Bool
+)
+
+// Register 注册退出时的处理 同一阶段内按注册顺序执行
+func Register(phase Phase, name string, hook Hook) {
+	hooksLock.Lock()
+	defer hooksLock.Unlock()
+	hooks = append(hooks, namedHook{
+		phase: phase,
+		name:  name,
+		hook:  hook,
+	})
+}
+
+// Draining 是否正在退出
+func Draining() bool {
+	return draining.Load()
+}
+
+// Options 退出的配置
+type Options struct {
+	Timeout    time.Duration // 所有处理的截止时间 为0则一直等待
+	DrainDelay time.Duration // 标记为停止中后继续接收请求的时间 等待负载均衡摘除实例
+	OnError    func(name string, err error)
+}
+
+// Run 执行所有阶段的处理 出错的处理不影响后面的处理 返回所有的错误
+func Run(opts Options) error {
+	draining.Store(true)
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if opts.DrainDelay > 0 {
+		select {
+		case <-time.After(opts.DrainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	hooksLock.Lock()
+	phaseHooks := make(map[Phase][]namedHook)
+	for _, h := range hooks {
+		phaseHooks[h.phase] = append(phaseHooks[h.phase], h)
+	}
+	hooksLock.Unlock()
+
+	var errs []error
+	for phase := PhaseHTTP; phase <= PhaseClose; phase++ {
+		for _, h := range phaseHooks[phase] {
+			if err := runHook(ctx, h.hook); err != nil {
+				if opts.OnError != nil {
+					opts.OnError(h.name, err)
+				}
+				errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runHook 处理不响应ctx时也在截止时间返回
+func runHook(ctx context.Context, hook Hook) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errChan <- hook(ctx)
+	}()
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,63 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reset() {
+	hooksLock.Lock()
+	hooks = nil
+	hooksLock.Unlock()
+	draining.Store(false)
+}
+
+func TestRun(t *testing.T) {
+	reset()
+	defer reset()
+	var order []string
+	record := func(name string, err error) Hook {
+		return func(ctx context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+	Register(PhaseClose, "db", record("db", nil))
+	Register(PhaseFlush, "audit", record("audit", errors.New("写入失败")))
+	Register(PhaseHTTP, "http", record("http", nil))
+	Register(PhaseStop, "queue", record("queue", nil))
+	Register(PhaseClose, "redis", record("redis", nil))
+
+	var failed []string
+	err := Run(Options{OnError: func(name string, err error) {
+		failed = append(failed, name)
+	}})
+	assert.Error(t, err)
+	assert.True(t, Draining())
+	assert.Equal(t, []string{"http", "audit", "queue", "db", "redis"}, order)
+	assert.Equal(t, []string{"audit"}, failed)
+}
+
+func TestRunTimeout(t *testing.T) {
+	reset()
+	defer reset()
+	done := false
+	Register(PhaseHTTP, "slow", func(ctx context.Context) error {
+		time.Sleep(time.Millisecond * 200)
+		return nil
+	})
+	Register(PhaseClose, "db", func(ctx context.Context) error {
+		done = true
+		return nil
+	})
+	start := time.Now()
+	err := Run(Options{Timeout: time.Millisecond * 50})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Millisecond*150)
+	// 超时后不再执行剩下的处理
+	assert.False(t, done)
+}
@@ -0,0 +1,136 @@
+package tenant
+
+import (
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/gocraft/dbr/v2"
+)
+
+const (
+	// userTenantCachePrefix 用户所属组织的缓存
+	userTenantCachePrefix = "tenantOfUser:"
+	// userTenantCacheTTL 缓存时长 用户的组织注册后不会改变
+	userTenantCacheTTL = time.Hour * 24
+)
+
+// Service 组织的查询、配额和隔离检查 没有开启多组织时不检查
+type Service struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+// NewService 创建组织服务
+func NewService(ctx *config.Context) *Service {
+	return &Service{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// UserTenant 用户所属的组织 用户不存在时为默认组织
+func (s *Service) UserTenant(uid string) (string, error) {
+	cacheKey := userTenantCachePrefix + uid
+	value, err := s.ctx.Cache().Get(cacheKey)
+	if err != nil {
+		return "", err
+	}
+	// 缓存的值带前缀 区分默认组织和没有缓存
+	if strings.HasPrefix(value, "t:") {
+		return strings.TrimPrefix(value, "t:"), nil
+	}
+	var tenantIDs []string
+	_, err = s.session.Select("tenant_id").From("user").Where("uid=?", uid).Load(&tenantIDs)
+	if err != nil {
+		return "", err
+	}
+	if len(tenantIDs) == 0 {
+		return "", nil
+	}
+	if err = s.ctx.Cache().SetAndExpire(cacheKey, "t:"+tenantIDs[0], userTenantCacheTTL); err != nil {
+		return "", err
+	}
+	return tenantIDs[0], nil
+}
+
+// isSystemUID 系统账号、文件助手和系统管理员属于所有组织
+func (s *Service) isSystemUID(uid string) bool {
+	account := s.ctx.GetConfig().Account
+	return uid == account.SystemUID || uid == account.FileHelperUID || uid == account.AdminUID
+}
+
+// CheckUsers 检查用户都属于组织 添加好友、拉人进群等建立关系的操作前调用
+func (s *Service) CheckUsers(tenantID string, uids ...string) error {
+	if !Enabled() {
+		return nil
+	}
+	for _, uid := range uids {
+		if uid == "" || s.isSystemUID(uid) {
+			continue
+		}
+		userTenant, err := s.UserTenant(uid)
+		if err != nil {
+			return err
+		}
+		if userTenant != tenantID {
+			return errcode.ErrTenantCrossUser
+		}
+	}
+	return nil
+}
+
+// CheckUserQuota 注册用户前检查组织的用户数
+func (s *Service) CheckUserQuota(tenantID string) error {
+	cfg := Config(tenantID)
+	if cfg == nil || cfg.MaxUsers <= 0 {
+		return nil
+	}
+	count, err := s.count("user", "COUNT(*)", tenantID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(cfg.MaxUsers) {
+		return errcode.ErrTenantUserQuota
+	}
+	return nil
+}
+
+// CheckGroupQuota 创建群前检查组织的群数
+func (s *Service) CheckGroupQuota(tenantID string) error {
+	cfg := Config(tenantID)
+	if cfg == nil || cfg.MaxGroups <= 0 {
+		return nil
+	}
+	count, err := s.count("group", "COUNT(*)", tenantID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(cfg.MaxGroups) {
+		return errcode.ErrTenantGroupQuota
+	}
+	return nil
+}
+
+// CheckFileQuota 上传文件前检查组织已使用的存储空间 size为要上传的文件大小
+func (s *Service) CheckFileQuota(tenantID string, size int64) error {
+	cfg := Config(tenantID)
+	if cfg == nil || cfg.MaxStorage <= 0 {
+		return nil
+	}
+	used, err := s.count("file", "IFNULL(SUM(size),0)", tenantID)
+	if err != nil {
+		return err
+	}
+	if used+size > cfg.MaxStorage {
+		return errcode.ErrTenantFileQuota
+	}
+	return nil
+}
+
+func (s *Service) count(table string, expr string, tenantID string) (int64, error) {
+	var count int64
+	_, err := s.session.Select(expr).From("`"+table+"`").Where("tenant_id=?", tenantID).Load(&count)
+	return count, err
+}
@@ -0,0 +1,130 @@
+// Package tenant 多组织（多租户） 一个部署为多个相互隔离的组织提供服务
+//
+// 用户、群和文件属于注册或创建时的组织（tenant_id 默认组织为空）
+// 请求的组织按顺序确定：请求头（tenant.header）、域名（tenant.tenants[].domains）、登录用户的组织
+// 登录用户只能访问自己的组织 组织的账号不能使用管理后台
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ginKey 请求的组织ID在gin.Context中的key
+const ginKey = "tenant_id"
+
+// managerPathPrefix 管理后台的接口
+const managerPathPrefix = "/v1/manager"
+
+// Enabled 是否开启了多组织
+func Enabled() bool {
+	return extconfig.Get().Tenant.Enable
+}
+
+// ID 请求的组织ID 默认组织或没有开启时为空
+func ID(c *gin.Context) string {
+	return c.GetString(ginKey)
+}
+
+type ctxKey struct{}
+
+// WithContext 把组织ID放到context中 用于没有请求的服务（例如短信）
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext context中的组织ID
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Config 组织的配置 默认组织或没有配置时返回nil
+func Config(id string) *extconfig.TenantItemConfig {
+	cfg := extconfig.Get().Tenant
+	if !cfg.Enable {
+		return nil
+	}
+	return cfg.Get(id)
+}
+
+// resolve 请求头或域名指定的组织 specified为false表示没有指定
+func resolve(r *http.Request, cfg extconfig.TenantConfig) (id string, specified bool, err error) {
+	if id = strings.TrimSpace(r.Header.Get(cfg.Header)); id != "" {
+		if cfg.Get(id) == nil {
+			return "", false, errcode.ErrTenantNotExist
+		}
+		return id, true, nil
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, t := range cfg.Tenants {
+		for _, domain := range t.Domains {
+			if strings.EqualFold(domain, host) {
+				return t.ID, true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// Middleware 确定请求的组织 登录用户不属于请求的组织时拒绝 需要在模块安装（注册路由）之前、API密钥中间件之后添加
+func Middleware(ctx *config.Context) gin.HandlerFunc {
+	s := NewService(ctx)
+	lg := log.NewTLog("Tenant")
+	return func(c *gin.Context) {
+		cfg := extconfig.Get().Tenant
+		if !cfg.Enable {
+			c.Next()
+			return
+		}
+		id, specified, err := resolve(c.Request, cfg)
+		if err != nil {
+			abort(c, http.StatusBadRequest, err)
+			return
+		}
+		if token := c.GetHeader("token"); token != "" {
+			uidAndName := wkhttp.GetLoginUID(token, ctx.GetConfig().Cache.TokenCachePrefix, ctx.Cache())
+			if uid := strings.Split(uidAndName, "@")[0]; uid != "" {
+				userTenant, err := s.UserTenant(uid)
+				if err != nil {
+					lg.Error("查询用户的组织失败！", zap.Error(err), zap.String("uid", uid))
+					abort(c, http.StatusInternalServerError, errors.New("查询用户的组织失败！"))
+					return
+				}
+				if specified && userTenant != id {
+					abort(c, http.StatusForbidden, errcode.ErrTenantMismatch)
+					return
+				}
+				if userTenant != "" && strings.HasPrefix(c.Request.URL.Path, managerPathPrefix) {
+					abort(c, http.StatusForbidden, errcode.ErrTenantManagerOnly)
+					return
+				}
+				id = userTenant
+			}
+		}
+		c.Set(ginKey, id)
+		c.Request = c.Request.WithContext(WithContext(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+func abort(c *gin.Context, status int, err error) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"msg":    err.Error(),
+		"status": status,
+	})
+}
@@ -0,0 +1,51 @@
+package tenant
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/errcode"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	cfg := extconfig.TenantConfig{
+		Enable: true,
+		Header: "X-Tenant-ID",
+		Tenants: []extconfig.TenantItemConfig{
+			{ID: "acme", Domains: []string{"im.acme.com"}},
+			{ID: "globex", Domains: []string{"chat.globex.com"}},
+		},
+	}
+	cases := []struct {
+		name      string
+		host      string
+		header    string
+		id        string
+		specified bool
+		err       error
+	}{
+		{name: "请求头", host: "im.acme.com", header: "globex", id: "globex", specified: true},
+		{name: "不存在的组织", header: "unknown", err: errcode.ErrTenantNotExist},
+		{name: "域名", host: "IM.acme.com:8090", id: "acme", specified: true},
+		{name: "默认组织", host: "api.example.com"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/v1/common/tenant", nil)
+		r.Host = c.host
+		if c.header != "" {
+			r.Header.Set(cfg.Header, c.header)
+		}
+		id, specified, err := resolve(r, cfg)
+		assert.Equal(t, c.err, err, c.name)
+		assert.Equal(t, c.id, id, c.name)
+		assert.Equal(t, c.specified, specified, c.name)
+	}
+}
+
+func TestContext(t *testing.T) {
+	assert.Equal(t, "", FromContext(context.Background()))
+	assert.Equal(t, "acme", FromContext(WithContext(context.Background(), "acme")))
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// GetTrustedClientIP 获取客户端IP 只有请求来自可信的代理时才使用X-Forwarded-For和X-Real-Ip
+// X-Forwarded-For从右往左跳过可信的代理 第一个不可信的地址为客户端IP 最左边的地址可以由客户端伪造 不使用
+// trustedProxies为可信代理的IP或CIDR 为空时只使用连接的地址
+func GetTrustedClientIP(r *http.Request, trustedProxies []string) string {
+	remoteIP := strings.TrimSpace(r.RemoteAddr)
+	if host, _, err := net.SplitHostPort(remoteIP); err == nil {
+		remoteIP = host
+	}
+	trusted := parseTrustedProxies(trustedProxies)
+	if !ipTrusted(net.ParseIP(remoteIP), trusted) {
+		return remoteIP
+	}
+	forwarded := strings.TrimSpace(r.Header.Get("X-Forwarded-For"))
+	if forwarded == "" {
+		// 没有经过多级代理时使用代理设置的X-Real-Ip
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); ip != nil {
+			return ip.String()
+		}
+		return remoteIP
+	}
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			// 格式错误时无法继续判断 使用最后一个可信的地址
+			return remoteIP
+		}
+		if !ipTrusted(ip, trusted) {
+			return ip.String()
+		}
+		remoteIP = ip.String()
+	}
+	return remoteIP
+}
+
+// ParseTrustedProxy 解析可信代理的IP或CIDR
+func ParseTrustedProxy(proxy string) (*net.IPNet, bool) {
+	proxy = strings.TrimSpace(proxy)
+	if _, ipNet, err := net.ParseCIDR(proxy); err == nil {
+		return ipNet, true
+	}
+	ip := net.ParseIP(proxy)
+	if ip == nil {
+		return nil, false
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, true
+}
+
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if ipNet, ok := ParseTrustedProxy(proxy); ok {
+			nets = append(nets, ipNet)
+		}
+	}
+	return nets
+}
+
+func ipTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
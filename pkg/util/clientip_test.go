@@ -0,0 +1,43 @@
+package util
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTrustedClientIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "127.0.0.1"}
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		ip         string
+	}{
+		{name: "直连时不使用请求头", remoteAddr: "1.2.3.4:5678", forwarded: "8.8.8.8", realIP: "8.8.4.4", ip: "1.2.3.4"},
+		{name: "可信代理", remoteAddr: "10.0.0.2:5678", forwarded: "1.2.3.4", ip: "1.2.3.4"},
+		{name: "伪造的最左边地址", remoteAddr: "10.0.0.2:5678", forwarded: "8.8.8.8, 1.2.3.4", ip: "1.2.3.4"},
+		{name: "多级可信代理", remoteAddr: "127.0.0.1:5678", forwarded: "8.8.8.8, 1.2.3.4, 10.0.0.3", ip: "1.2.3.4"},
+		{name: "全部为可信代理", remoteAddr: "10.0.0.2:5678", forwarded: "10.0.0.4, 10.0.0.3", ip: "10.0.0.4"},
+		{name: "格式错误", remoteAddr: "10.0.0.2:5678", forwarded: "1.2.3.4, unknown", ip: "10.0.0.2"},
+		{name: "X-Real-Ip", remoteAddr: "10.0.0.2:5678", realIP: "1.2.3.4", ip: "1.2.3.4"},
+		{name: "没有可信代理", remoteAddr: "[2001:db8::1]:5678", forwarded: "1.2.3.4", ip: "2001:db8::1"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/v1/user/login", nil)
+		r.RemoteAddr = c.remoteAddr
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if c.realIP != "" {
+			r.Header.Set("X-Real-Ip", c.realIP)
+		}
+		assert.Equal(t, c.ip, GetTrustedClientIP(r, trusted), c.name)
+	}
+	r := httptest.NewRequest("GET", "/v1/user/login", nil)
+	r.RemoteAddr = "10.0.0.2:5678"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	assert.Equal(t, "10.0.0.2", GetTrustedClientIP(r, nil))
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersion 比较版本号 a小于b返回-1 等于返回0 大于返回1
+// 例如1.2.10大于1.2.9 每一段只取开头的数字（1.2.0-beta按1.2.0比较） 缺少的段按0比较
+func CompareVersion(a string, b string) int {
+	as := versionParts(a)
+	bs := versionParts(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var av, bv int
+		if i < len(as) {
+			av = as[i]
+		}
+		if i < len(bs) {
+			bv = bs[i]
+		}
+		if av != bv {
+			if av < bv {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v")
+	parts := strings.Split(version, ".")
+	values := make([]int, 0, len(parts))
+	for _, part := range parts {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		value, _ := strconv.Atoi(part[:end])
+		values = append(values, value)
+	}
+	return values
+}
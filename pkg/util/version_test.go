@@ -0,0 +1,15 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersion(t *testing.T) {
+	assert.Equal(t, 0, CompareVersion("1.2.0", "1.2"))
+	assert.Equal(t, 1, CompareVersion("1.2.10", "1.2.9"))
+	assert.Equal(t, -1, CompareVersion("1.2", "1.10"))
+	assert.Equal(t, 0, CompareVersion("v1.2.0-beta", "1.2.0"))
+	assert.Equal(t, 1, CompareVersion("2", "1.99.99"))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/extconfig"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/shutdown"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/module"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/server"
+	"go.uber.org/zap"
+)
+
+// apiServer 代替server.Server的启动和停止
+// server.Server通过gin的Run监听 退出时不能等待处理中的请求结束 所以自己创建http.Server
+type apiServer struct {
+	*server.Server
+	ctx *config.Context
+}
+
+func newAPIServer(ctx *config.Context) *apiServer {
+	s := &apiServer{
+		Server: server.New(ctx),
+		ctx:    ctx,
+	}
+	// 各模块停止 例如webhook模块停止接收IM的webhook
+	shutdown.Register(shutdown.PhaseHTTP, "modules", func(context.Context) error {
+		return module.Stop(s.ctx)
+	})
+	return s
+}
+
+// Start 注册与server.Server相同的路由 然后开始监听
+func (s *apiServer) Start() error {
+	r := s.GetRoute()
+	r.Static("/web", "./assets/web")
+	r.Any("/v1/ping", func(c *wkhttp.Context) {
+		c.ResponseOK()
+	})
+	r.Any("/swagger/:module", func(c *wkhttp.Context) {
+		m := register.GetModuleByName(c.Param("module"), s.ctx)
+		if strings.TrimSpace(m.Swagger) == "" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.String(http.StatusOK, m.Swagger)
+	})
+
+	cfg := s.ctx.GetConfig()
+	addr := cfg.Addr
+	if addr == "" {
+		addr = ":8080" // 与gin的Run相同
+	}
+	s.listen(&http.Server{Addr: addr, Handler: r}, "", "")
+	if cfg.SSLAddr != "" {
+		r.Use(server.TlsHandler(cfg.SSLAddr))
+		currDir, _ := os.Getwd()
+		s.listen(&http.Server{Addr: cfg.SSLAddr, Handler: r}, currDir+"/assets/ssl/ssl.pem", currDir+"/assets/ssl/ssl.key")
+	}
+	return module.Start(s.ctx)
+}
+
+func (s *apiServer) listen(srv *http.Server, certFile string, keyFile string) {
+	go func() {
+		var err error
+		if certFile != "" {
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+	// 停止接收新请求 等待处理中的请求结束
+	shutdown.Register(shutdown.PhaseHTTP, "http "+srv.Addr, srv.Shutdown)
+}
+
+// Stop 收到SIGINT或SIGTERM时按阶段执行退出的处理
+// 处理失败时只记录日志 返回错误会导致svc.Run返回后panic
+func (s *apiServer) Stop() error {
+	cfg := extconfig.Get().Shutdown
+	log.Info("开始退出", zap.Duration("timeout", cfg.Timeout), zap.Duration("drainDelay", cfg.DrainDelay))
+	_ = shutdown.Run(shutdown.Options{
+		Timeout:    cfg.Timeout,
+		DrainDelay: cfg.DrainDelay,
+		OnError: func(name string, err error) {
+			log.Warn("退出时的处理失败！", zap.String("name", name), zap.Error(err))
+		},
+	})
+	log.Info("已退出")
+	return nil
+}